@@ -1,11 +1,14 @@
 package action
 
 import (
-	"path/filepath"
+	"bufio"
+	"fmt"
+	"strings"
 
 	"github.com/helm/helm-classic/config"
+	"github.com/helm/helm-classic/helmpath"
+	"github.com/helm/helm-classic/lock"
 	"github.com/helm/helm-classic/log"
-	helm "github.com/helm/helm-classic/util"
 )
 
 const (
@@ -15,17 +18,61 @@ const (
 )
 
 // mustConfig parses a config file or dies trying.
+//
+// The repositories are given the Defaults.
 func mustConfig(homedir string) *config.Configfile {
-	rpath := filepath.Join(homedir, helm.Configfile)
-	cfg, err := config.Load(rpath)
+	rpath := helmpath.Home(homedir).Config()
+	cfg, err := config.LoadHome(homedir)
 	if err != nil {
-		log.Warn("Oops! Looks like we had some issues running your command! Running `helmc doctor` to ensure we have all the necessary prerequisites in place...")
-		Doctor(homedir)
-		cfg, err = config.Load(rpath)
+		log.Warn("Oops! Looks like we had some issues running your command! Checking that your home has all the necessary prerequisites in place...")
+		CheckLocalPrereqs(homedir)
+		cfg, err = config.LoadHome(homedir)
 		if err != nil {
 			log.Die("Oops! Could not load %s. Error: %s", rpath, err)
 		}
 		log.Info("Continuing onwards and upwards!")
 	}
+	Defaults.apply(cfg.Repos, nil)
 	return cfg
 }
+
+// lockConfig takes the lock on the configuration file, or dies trying.
+//
+// Commands that change the configuration hold it from before they load the
+// file until after they save it. It returns a function that releases the lock.
+func lockConfig(homedir string) func() {
+	return mustLock(helmpath.Home(homedir).Locks("config.lock"))
+}
+
+// lockChart takes the lock on a workspace chart, or dies trying.
+//
+// It is held while the chart is fetched, generated, or edited. It returns a
+// function that releases the lock.
+func lockChart(homedir, chartName string) func() {
+	return mustLock(helmpath.Home(homedir).Locks("charts", chartName+".lock"))
+}
+
+func mustLock(path string) func() {
+	l, err := lock.Acquire(path)
+	if err != nil {
+		log.Die("Could not lock %s: %s", path, err)
+	}
+	return func() { l.Release() }
+}
+
+// Confirm asks the user a yes/no question on log.Stdin.
+//
+// Anything other than "y" or "yes" is a no, including a closed input.
+func Confirm(format string, v ...interface{}) bool {
+	fmt.Fprintf(log.Stdout, format+" [y/N] ", v...)
+	answer, err := bufio.NewReader(log.Stdin).ReadString('\n')
+	if err != nil && answer == "" {
+		fmt.Fprintln(log.Stdout)
+		return false
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
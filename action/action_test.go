@@ -17,6 +17,10 @@ func (r TestRunner) Create(stdin []byte, ns string) ([]byte, error) {
 	return r.out, r.err
 }
 
+func (r TestRunner) Replace(stdin []byte, ns string) ([]byte, error) {
+	return r.out, r.err
+}
+
 func (r TestRunner) Delete(name, ktype, ns string) ([]byte, error) {
 	return r.out, r.err
 }
@@ -24,3 +28,27 @@ func (r TestRunner) Delete(name, ktype, ns string) ([]byte, error) {
 func (r TestRunner) Get(stdin []byte, ns string) ([]byte, error) {
 	return r.out, r.err
 }
+
+func (r TestRunner) GetObject(name, ktype, ns string) ([]byte, error) {
+	return r.out, r.err
+}
+
+func (r TestRunner) List(kinds, selector, ns string) ([]byte, error) {
+	return r.out, r.err
+}
+
+func (r TestRunner) CanI(verb, kind, ns string) ([]byte, error) {
+	return r.out, r.err
+}
+
+func (r TestRunner) Logs(name, ktype, ns string) ([]byte, error) {
+	return r.out, r.err
+}
+
+func (r TestRunner) DryRun(stdin []byte, ns string) ([]byte, error) {
+	return r.out, r.err
+}
+
+func (r TestRunner) Version() ([]byte, error) {
+	return r.out, r.err
+}
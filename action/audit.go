@@ -0,0 +1,127 @@
+package action
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/helm/helm-classic/audit"
+	"github.com/helm/helm-classic/chart"
+	"github.com/helm/helm-classic/helmpath"
+	"github.com/helm/helm-classic/log"
+	helm "github.com/helm/helm-classic/util"
+)
+
+// AuditTail prints the last n entries of the audit log, oldest first.
+//
+// The format is "json", "yaml", or "" for a table with one line per entry.
+func AuditTail(homedir string, n int, format string) {
+	c := newClient(homedir, nil)
+	path := c.auditPath()
+	entries, err := audit.Tail(path, n)
+	if err != nil {
+		log.Die("Could not read the audit log: %s", err)
+	}
+	if format != "" {
+		if err := printFormatted(entries, format); err != nil {
+			log.Die("%s", err)
+		}
+		return
+	}
+	if len(entries) == 0 {
+		log.Info("The audit log %s is empty.", path)
+		return
+	}
+
+	w := tabwriter.NewWriter(log.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tUSER\tCONTEXT\tOPERATION\tCHART\tVERSION\tNAMESPACE\tRESOURCES\tOUTCOME")
+	for _, e := range entries {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%d\t%s\n", e.Time.Local().Format("2006-01-02 15:04:05"), dash(e.User), dash(e.Context), e.Operation, e.Chart, dash(e.Version), dash(e.Namespace), len(e.Resources), e.Outcome)
+	}
+	w.Flush()
+}
+
+// auditPath returns the file of the audit log: the configured audit.path, or
+// audit.log in the home directory.
+func (c *Client) auditPath() string {
+	home := helmpath.Home(c.stateDir())
+	cfg, err := c.config()
+	if err != nil || cfg.Audit == nil || cfg.Audit.Path == "" {
+		return home.Audit()
+	}
+	p := helmpath.ExpandHome(os.ExpandEnv(cfg.Audit.Path))
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(home.String(), p)
+	}
+	return p
+}
+
+// newAuditEntry starts the audit entry of an operation on the chart in dir,
+// which was loaded as ch, with the kubeconfig identity that it runs as.
+func (c *Client) newAuditEntry(op string, ch *chart.Chart, dir, namespace string) *audit.Entry {
+	id := c.kube().ActiveIdentity()
+	e := &audit.Entry{
+		Time:      time.Now().UTC(),
+		User:      id.User,
+		Context:   id.Context,
+		Cluster:   id.Cluster,
+		Operation: op,
+		Chart:     ch.Chartfile.Name,
+		Version:   ch.Chartfile.Version,
+		Namespace: namespace,
+		Resources: []*audit.Resource{},
+		Outcome:   audit.Succeeded,
+	}
+	if d, err := chart.Digest(dir); err == nil {
+		e.Digest = d
+	}
+	return e
+}
+
+// auditInstall records the install of the workspace chart name, which was
+// loaded as ch, what happened to each of its resources, and the options it
+// ran with, so that Reinstall can repeat it.
+func (c *Client) auditInstall(ch *chart.Chart, name string, opts InstallOptions, res *InstallResult, err error) {
+	e := c.newAuditEntry(audit.OpInstall, ch, helm.WorkspaceChartDirectory(c.Home, name), opts.Namespace)
+	e.Parameters = &audit.Parameters{
+		Workspace:  name,
+		Mode:       opts.Mode,
+		Atomic:     opts.Atomic,
+		Annotate:   opts.Annotate,
+		Labels:     opts.Labels,
+		Force:      opts.Force,
+		Generate:   opts.Generate,
+		SkipSchema: opts.SkipSchema,
+		Exclude:    opts.Exclude,
+		Values:     opts.Values.AbsFiles(),
+		Set:        opts.Values.Set,
+		SetFrom:    opts.Values.Redacted(),
+		Env:        opts.Values.Env,
+
+		InjectNamespace: opts.InjectNamespace,
+	}
+	for _, rr := range res.Resources {
+		e.Resources = append(e.Resources, &audit.Resource{Kind: rr.Kind, Name: rr.Name, Namespace: rr.Namespace, Status: rr.Status, Error: rr.Error})
+	}
+	c.recordAudit(e, err)
+}
+
+// recordAudit appends an entry to the audit log, with the outcome that err
+// gives.
+//
+// Every operation that changes Kubernetes is recorded, and there is no way
+// to turn it off. A log that cannot be written is a warning, since the
+// operation has already happened.
+func (c *Client) recordAudit(e *audit.Entry, err error) {
+	if err != nil {
+		e.Outcome, e.Error = audit.Failed, err.Error()
+	}
+	path := c.auditPath()
+	if err := audit.Append(path, e); err != nil {
+		c.Log.Warn("Could not write the audit log %s: %s", path, err)
+		return
+	}
+	c.Log.Debug("Recorded the %s of %s in %s", e.Operation, e.Chart, path)
+}
@@ -0,0 +1,92 @@
+package action
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/helm/helm-classic/audit"
+	"github.com/helm/helm-classic/config"
+	"github.com/helm/helm-classic/kubectl"
+	"github.com/helm/helm-classic/log"
+	"github.com/helm/helm-classic/test"
+)
+
+func TestAudit(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+	test.FakeUpdate(tmpHome)
+
+	kc := filepath.Join(tmpHome, "kubeconfig")
+	ioutil.WriteFile(kc, []byte("current-context: ci\ncontexts:\n- name: ci\n  context:\n    cluster: staging\n    user: deployer\n"), 0600)
+	defer os.Setenv("KUBECONFIG", os.Getenv("KUBECONFIG"))
+	os.Setenv("KUBECONFIG", kc)
+
+	c := newClient(tmpHome, &kubectl.FakeRunner{Out: []byte(`pod "redis" created`)})
+	test.CaptureOutput(func() {
+		if _, err := c.Install("redis", InstallOptions{Namespace: "cache", Force: true}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	test.CaptureOutput(func() {
+		Uninstall("redis", tmpHome, "cache", UninstallOptions{Yes: true}, TestRunner{err: errors.New("oh snap")})
+	})
+	// A dry run is not recorded.
+	test.CaptureOutput(func() {
+		newClient(tmpHome, kubectl.PrintRunner{}).Install("redis", InstallOptions{Namespace: "cache"})
+	})
+
+	entries, err := audit.Tail(filepath.Join(tmpHome, "audit.log"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected an install and an uninstall, got %d entries", len(entries))
+	}
+	in, un := entries[0], entries[1]
+	test.ExpectEquals(t, in.Operation, audit.OpInstall)
+	test.ExpectEquals(t, in.Outcome, audit.Succeeded)
+	test.ExpectEquals(t, in.User+" "+in.Context+" "+in.Cluster, "deployer ci staging")
+	test.ExpectEquals(t, in.Chart+" "+in.Version+" "+in.Namespace, "redis 0.0.1 cache")
+	if len(in.Digest) != 64 || in.Time.IsZero() {
+		t.Errorf("Expected a digest and a time, got %q and %s", in.Digest, in.Time)
+	}
+	if len(in.Resources) != 1 || in.Resources[0].Kind != "Pod" || in.Resources[0].Name != "redis" || in.Resources[0].Status != StatusCreated {
+		t.Errorf("Unexpected resources: %+v", in.Resources)
+	}
+	test.ExpectEquals(t, un.Operation, audit.OpUninstall)
+	test.ExpectEquals(t, un.Outcome, audit.Failed)
+	test.ExpectEquals(t, un.Error, "1 resources could not be deleted")
+	if len(un.Resources) != 1 || un.Resources[0].Status != StatusFailed || un.Resources[0].Error != "oh snap" {
+		t.Errorf("Unexpected resources: %+v", un.Resources)
+	}
+
+	var out bytes.Buffer
+	o := log.Stdout
+	log.Stdout = &out
+	defer func() { log.Stdout = o }()
+	AuditTail(tmpHome, 1, "")
+	test.ExpectContains(t, out.String(), "deployer  ci       uninstall")
+	if strings.Count(out.String(), "\n") != 2 {
+		t.Errorf("Expected only the last entry, got %s", out.String())
+	}
+
+	// The log can be kept elsewhere. If it cannot be written, the install
+	// still succeeds.
+	c.Config.Audit = &config.Audit{Path: "logs/audit.jsonl"}
+	test.CaptureOutput(func() { c.Install("redis", InstallOptions{Namespace: "cache", Force: true}) })
+	if entries, _ := audit.Tail(filepath.Join(tmpHome, "logs", "audit.jsonl"), 0); len(entries) != 1 {
+		t.Errorf("Expected the install in the configured log, got %d entries", len(entries))
+	}
+	c.Config.Audit.Path = tmpHome
+	actual := test.CaptureOutput(func() {
+		if _, err := c.Install("redis", InstallOptions{Namespace: "cache", Force: true}); err != nil {
+			t.Errorf("Expected the install to succeed, got %s", err)
+		}
+	})
+	test.ExpectContains(t, actual, "Could not write the audit log "+tmpHome)
+}
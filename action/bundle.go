@@ -0,0 +1,229 @@
+package action
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/helm/helm-classic/chart"
+	"github.com/helm/helm-classic/helmpath"
+	"github.com/helm/helm-classic/repo"
+	helm "github.com/helm/helm-classic/util"
+)
+
+// BundleIndex is the file of a bundle that lists its charts.
+const BundleIndex = "bundle.yaml"
+
+// Bundle is the content of the BundleIndex of a bundle: a chart and all of
+// its dependencies, which 'helmc install --from-bundle' installs on a machine
+// that has no access to the repositories.
+type Bundle struct {
+	// Chart is the name of the chart that the bundle was exported for.
+	Chart string `yaml:"chart"`
+	// Generated is set if the generators of the charts ran before they were
+	// bundled, so that the bundle holds what they wrote.
+	Generated bool `yaml:"generated,omitempty"`
+	// Charts are the chart and its dependencies, each after those it
+	// depends on, and the chart last.
+	Charts []*BundledChart `yaml:"charts"`
+}
+
+// BundledChart is a chart of a bundle.
+type BundledChart struct {
+	// Name is its name in the workspace.
+	Name    string `yaml:"name"`
+	Version string `yaml:"version"`
+	// Digest is its digest, as chart.Digest gives it.
+	Digest string `yaml:"digest"`
+}
+
+// ExportOptions control what Client.Export puts in a bundle.
+type ExportOptions struct {
+	// Generate runs the generators of each chart before it is bundled. Values
+	// are given to those of the exported chart only.
+	Generate   bool
+	SkipSchema bool
+	Values     ValueSources
+	// Force overwrites the bundle if it exists.
+	Force bool
+}
+
+// Export writes a bundle of a chart of the workspace, and of its
+// dependencies, to filename, so that it can be installed where the
+// repositories cannot be reached.
+//
+// - chartName is the name of the chart in the workspace
+// - homedir is the home directory for the user
+// - filename is the bundle to write
+// - o says whether the generators run first
+func Export(chartName, homedir, filename string, o ExportOptions) error {
+	c := newClient(homedir, nil)
+	c.Config = mustConfig(homedir)
+	_, err := c.Export(chartName, filename, o)
+	return err
+}
+
+// Export is like the package-level Export. It returns the index of the
+// bundle.
+//
+// The dependencies that the workspace is missing are fetched first, as with
+// 'helmc fetch --deps', and recorded in the dependency.LockFile of the chart.
+// With o.Generate, the generators of every chart then run, so that what they
+// write is bundled, and they need not run again. The bundle is a gzipped tar
+// archive (see repo.WriteBundle) with the BundleIndex, and each chart as it
+// is in the workspace, under its name.
+func (c *Client) Export(chartName, filename string, o ExportOptions) (*Bundle, error) {
+	if _, err := os.Stat(filename); err == nil && !o.Force {
+		return nil, fmt.Errorf("%s already exists. Use --force to overwrite it.", filename)
+	}
+	if !chartFetched(chartName, c.Home, c.Log) {
+		return nil, fmt.Errorf("Chart %s is not in your workspace. Fetch it with 'helmc fetch %s', and review it, before you export it.", chartName, chartName)
+	}
+	dir := helm.WorkspaceChartDirectory(c.Home, chartName)
+	cf, err := chart.LoadChartfile(filepath.Join(dir, Chartfile))
+	if err != nil {
+		return nil, fmt.Errorf("Could not load %s: %s", chartName, err)
+	}
+	deps, err := c.fetchDeps(chartName, cf, FetchOptions{})
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(deps)+1)
+	for _, d := range deps {
+		names = append(names, d.Chart)
+	}
+	names = append(names, chartName)
+
+	b := &Bundle{Chart: chartName, Generated: o.Generate}
+	dirs := map[string]string{}
+	for _, name := range names {
+		d := helm.WorkspaceChartDirectory(c.Home, name)
+		if o.Generate {
+			var values ValueSources
+			if name == chartName {
+				values = o.Values
+			}
+			if _, err := c.Generate(name, GenerateOptions{SkipSchema: o.SkipSchema, Values: values}); err != nil {
+				return nil, err
+			}
+		}
+		cf, err := chart.LoadChartfile(filepath.Join(d, Chartfile))
+		if err != nil {
+			return nil, fmt.Errorf("Could not load %s: %s", name, err)
+		}
+		digest, err := chart.Digest(d)
+		if err != nil {
+			return nil, err
+		}
+		b.Charts = append(b.Charts, &BundledChart{Name: name, Version: cf.Version, Digest: digest})
+		dirs[name] = d
+	}
+
+	data, err := yaml.Marshal(b)
+	if err != nil {
+		return nil, err
+	}
+	if err := repo.WriteBundle(filename, BundleIndex, data, dirs); err != nil {
+		return nil, fmt.Errorf("Could not write %s: %s", filename, err)
+	}
+	c.Log.Info("Exported %s and %d dependencies to %s", chartName, len(deps), filename)
+	return b, nil
+}
+
+// ImportBundle adds the charts of a bundle to the workspace, and returns the
+// name of the chart that it was exported for.
+//
+// - filename is the bundle, as Export writes it
+// - homedir is the home directory for the user
+// - force replaces the workspace charts of the same names that differ
+func ImportBundle(filename, homedir string, force bool) (string, error) {
+	c := newClient(homedir, nil)
+	c.Config = mustConfig(homedir)
+	b, err := c.ImportBundle(filename, force)
+	if err != nil {
+		return "", err
+	}
+	return b.Chart, nil
+}
+
+// ImportBundle is like the package-level ImportBundle. It returns the index
+// of the bundle.
+//
+// Nothing is fetched. Each chart must have the digest that the index
+// records, or nothing is imported. A workspace chart that is the same as the
+// one of the bundle is left as it is; one that differs is only replaced if
+// force is set. The limits of the configuration on the charts that are
+// fetched (see config.Fetch) bound the whole bundle.
+func (c *Client) ImportBundle(filename string, force bool) (*Bundle, error) {
+	cfg, err := c.config()
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read bundle: %s", err)
+	}
+	ws := helmpath.Home(c.Home).Workspace()
+	if err := os.MkdirAll(ws, 0755); err != nil {
+		return nil, fmt.Errorf("Could not create %q: %s", ws, err)
+	}
+	tmp, cleanup, err := helm.TempDir(ws, ".bundle-")
+	if err != nil {
+		return nil, fmt.Errorf("Could not create a staging directory: %s", err)
+	}
+	defer cleanup()
+	limits := cfg.Limits()
+	if err := repo.Expand(data, tmp, limits.Files, limits.Bytes); err != nil {
+		return nil, fmt.Errorf("Could not expand %s: %s", filename, err)
+	}
+
+	b := &Bundle{}
+	idx, err := ioutil.ReadFile(filepath.Join(tmp, BundleIndex))
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a bundle: it has no %s", filename, BundleIndex)
+	}
+	if err := yaml.Unmarshal(idx, b); err != nil {
+		return nil, fmt.Errorf("Could not parse the %s of %s: %s", BundleIndex, filename, err)
+	}
+	if b.Chart == "" || len(b.Charts) == 0 {
+		return nil, fmt.Errorf("The %s of %s names no charts", BundleIndex, filename)
+	}
+	found := false
+	for _, bc := range b.Charts {
+		found = found || bc.Name == b.Chart
+		if bc.Name == "" || bc.Name == "." || bc.Name == ".." || strings.ContainsAny(bc.Name, `/\`) {
+			return nil, fmt.Errorf("%s: %q is not the name of a chart", filename, bc.Name)
+		}
+		digest, err := chart.Digest(filepath.Join(tmp, repo.BundleCharts, bc.Name))
+		if err != nil {
+			return nil, fmt.Errorf("%s: could not read %s: %s", filename, bc.Name, err)
+		}
+		if digest != bc.Digest {
+			return nil, fmt.Errorf("%s: chart %s has digest %s, not %s as its %s records, so the bundle was changed since it was exported", filename, bc.Name, digest, bc.Digest, BundleIndex)
+		}
+	}
+
+	if !found {
+		return nil, fmt.Errorf("The %s of %s does not bundle its chart, %s", BundleIndex, filename, b.Chart)
+	}
+
+	label := filepath.Base(filename)
+	for _, bc := range b.Charts {
+		unlock, err := c.lockChart(bc.Name)
+		if err != nil {
+			return nil, err
+		}
+		_, err = c.place(filepath.Join(tmp, repo.BundleCharts, bc.Name), bc.Name, label+":"+bc.Name, bc.Digest, FetchOptions{Force: force})
+		unlock()
+		if err != nil {
+			return nil, err
+		}
+		c.Log.Debug("Imported %s %s from %s", bc.Name, bc.Version, filename)
+	}
+	c.Log.Info("Imported %s and %d dependencies from %s", b.Chart, len(b.Charts)-1, filename)
+	return b, nil
+}
@@ -0,0 +1,85 @@
+package action
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/helm/helm-classic/chart"
+	"github.com/helm/helm-classic/log"
+	"github.com/helm/helm-classic/repo"
+	"github.com/helm/helm-classic/test"
+	"github.com/helm/helm-classic/util"
+)
+
+func TestExportImportBundle(t *testing.T) {
+	home := test.CreateTmpHome()
+	defer os.RemoveAll(home)
+	test.FakeUpdate(home)
+	for name, data := range map[string]string{
+		"app": "name: app\nversion: 1.0.0\ndependencies:\n  - name: web\n    version: ^1\n",
+		"web": "name: web\nversion: 1.2.0\ndependencies:\n  - name: db\n    version: ~9.4\n",
+		"db":  "name: db\nversion: 9.4.1\n",
+	} {
+		dir := util.CacheDirectory(home, "charts", name)
+		os.MkdirAll(filepath.Join(dir, "manifests"), 0755)
+		ioutil.WriteFile(filepath.Join(dir, Chartfile), []byte(data), 0644)
+		ioutil.WriteFile(filepath.Join(dir, "manifests", name+".yaml"), []byte("kind: ConfigMap\napiVersion: v1\nmetadata:\n  name: "+name+"\n"), 0644)
+	}
+
+	var out bytes.Buffer
+	c := &Client{Home: home, Log: &log.Logger{Stdout: &out, Stderr: &out}}
+	if _, err := c.Fetch("app", "", FetchOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	bundle := filepath.Join(home, "app-bundle.tgz")
+	b, err := c.Export("app", bundle, ExportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b.Charts) != 3 || b.Charts[0].Name != "db" || b.Charts[1].Name != "web" || b.Charts[2].Name != "app" {
+		t.Fatalf("Expected db, web, then app, got %+v", b.Charts)
+	}
+	if _, err := c.Export("app", bundle, ExportOptions{}); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("Expected an existing bundle to need --force, got %v", err)
+	}
+
+	// Another home, whose caches do not have the charts.
+	other := test.CreateTmpHome()
+	defer os.RemoveAll(other)
+	test.FakeUpdate(other)
+	oc := &Client{Home: other, Log: &log.Logger{Stdout: &out, Stderr: &out}}
+	if _, err := oc.ImportBundle(bundle, false); err != nil {
+		t.Fatal(err)
+	}
+	for _, bc := range b.Charts {
+		if d, err := chart.Digest(util.WorkspaceChartDirectory(other, bc.Name)); err != nil || d != bc.Digest {
+			t.Errorf("Expected %s with digest %s, got %s (%v)", bc.Name, bc.Digest, d, err)
+		}
+	}
+
+	// An identical chart is left alone; one that differs needs force.
+	if _, err := oc.ImportBundle(bundle, false); err != nil {
+		t.Errorf("Expected the same charts to be imported again, got %s", err)
+	}
+	ioutil.WriteFile(util.WorkspaceChartDirectory(other, "web", "manifests", "web.yaml"), []byte("kind: ConfigMap\napiVersion: v1\nmetadata:\n  name: changed\n"), 0644)
+	if _, err := oc.ImportBundle(bundle, false); err == nil || !strings.Contains(err.Error(), "different chart named web") {
+		t.Errorf("Expected a changed workspace chart to be kept, got %v", err)
+	}
+	if _, err := oc.ImportBundle(bundle, true); err != nil {
+		t.Errorf("Expected force to replace the changed chart, got %s", err)
+	}
+
+	// A bundle whose chart does not have its digest.
+	bad := filepath.Join(home, "bad.tgz")
+	index := []byte("chart: db\ncharts:\n- name: db\n  version: 9.4.1\n  digest: " + strings.Repeat("0", 64) + "\n")
+	if err := repo.WriteBundle(bad, BundleIndex, index, map[string]string{"db": util.WorkspaceChartDirectory(home, "db")}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := oc.ImportBundle(bad, true); err == nil || !strings.Contains(err.Error(), "was changed since it was exported") {
+		t.Errorf("Expected a digest mismatch, got %v", err)
+	}
+}
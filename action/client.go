@@ -0,0 +1,153 @@
+package action
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/helm/helm-classic/config"
+	"github.com/helm/helm-classic/helmpath"
+	"github.com/helm/helm-classic/kubectl"
+	"github.com/helm/helm-classic/lock"
+	"github.com/helm/helm-classic/log"
+)
+
+// Settings are the options that helmc takes from its global flags.
+type Settings struct {
+	// Offline forbids anything that needs the network. See config.Repos.Offline.
+	Offline bool
+	// InsecureSkipVerify accepts HTTP indices that fail verification. See
+	// config.Repos.InsecureSkipVerify.
+	InsecureSkipVerify bool
+	// TraceGit is the level of git tracing. See config.Repos.TraceGit.
+	TraceGit int
+	// GitBackend is the Git backend of the repositories that do not name
+	// one. See config.Repos.GitBackend.
+	GitBackend string
+	// GitTimeout bounds each git operation that uses the network. See
+	// config.Repos.GitTimeout.
+	GitTimeout time.Duration
+	// AllowGenerators are the commands that generators may run, in addition
+	// to those of config.Generate.Allow. See generator.Policy.
+	AllowGenerators []string
+	// EventsFile, if it is set, is a file that each event of an operation is
+	// appended to, as a line of JSON. See EventRecord.
+	EventsFile string
+	// EventsWebhook, if it is set, is a URL that each event of an operation is
+	// posted to, as JSON. It is not used when Offline is set.
+	EventsWebhook string
+}
+
+// Defaults are the settings of the package-level functions, such as Fetch
+// and Install. helmc sets them from its global flags. A Client does not use
+// them.
+var Defaults Settings
+
+// Client runs Helm Classic operations for a program that embeds them.
+//
+// Everything an operation depends on is a field of the Client, so that
+// several clients, with different homes and clusters, can be used in one
+// process. The cluster, and how kubectl reaches it, are the
+// kubectl.Settings of the Kube runner (see kubectl.WithSettings); a runner
+// without its own uses kubectl.Defaults. Operations report failures with
+// the errors of the github.com/helm/helm-classic/errors package, and never
+// exit.
+//
+// A Client is not safe for use by multiple goroutines. Separate clients may
+// share a home, since operations lock what they change.
+type Client struct {
+	Settings
+
+	// Home is the Helm Classic home directory. It must already exist; see
+	// helmpath.Home.Ensure.
+	Home string
+	// Kube sends manifests to Kubernetes, with the kubectl.Settings it
+	// carries. kubectl.FakeRunner can be used to install nothing.
+	Kube kubectl.Runner
+	// Log receives the messages of each operation. If it is nil, they are
+	// printed with the package-level log functions.
+	Log *log.Logger
+	// Events receives the events of each operation, such as each manifest
+	// that Install applies. If it is nil, they are rendered on Log, as
+	// LogEvents does.
+	Events Listener
+	// Config is the configuration. If it is nil, it is loaded from Home the
+	// first time it is needed.
+	Config *config.Configfile
+
+	// stateHome is the home that the audit log and the release history are
+	// kept in while Home is a temporary one (see useStatelessHome).
+	stateHome string
+	// sinkFailed is set once a failure to record an event was warned about.
+	sinkFailed map[string]bool
+	// kubeSettings are the settings that Kube was given for the operation
+	// in progress (see useKube), or nil.
+	kubeSettings *kubectl.Settings
+}
+
+// newClient returns a client with the default settings, for the package-level functions.
+//
+// Unless the operation can do without, the caller sets Config with
+// mustConfig, so that a broken home is repaired as it always has been.
+func newClient(home string, kube kubectl.Runner) *Client {
+	return &Client{Settings: Defaults, Home: home, Kube: kube, Events: LogEvents(nil)}
+}
+
+// kube returns the settings of the client's runner: how it reaches the
+// cluster, and the flags that it gives kubectl.
+func (c *Client) kube() *kubectl.Settings {
+	if c.kubeSettings != nil {
+		return c.kubeSettings
+	}
+	return kubectl.SettingsOf(c.Kube)
+}
+
+// useKube gives Kube the settings that change returns for a copy of its
+// own, until the returned function is called. Other clients with the same
+// runner are not affected.
+func (c *Client) useKube(change func(s *kubectl.Settings)) func() {
+	s := *c.kube()
+	change(&s)
+	kube, prev := c.Kube, c.kubeSettings
+	c.Kube, c.kubeSettings = kubectl.WithSettings(c.Kube, &s), &s
+	return func() { c.Kube, c.kubeSettings = kube, prev }
+}
+
+// config returns the configuration, loading it if necessary.
+//
+// The repositories are given the client's settings and logger.
+func (c *Client) config() (*config.Configfile, error) {
+	if c.Config == nil {
+		cfg, err := config.LoadHome(c.Home)
+		if err != nil {
+			return nil, fmt.Errorf("Could not load %s: %s", helmpath.Home(c.Home).Config(), err)
+		}
+		c.Config = cfg
+	}
+	c.apply(c.Config.Repos, c.Log)
+	return c.Config, nil
+}
+
+// apply gives the settings and a logger to the repositories.
+func (s Settings) apply(r *config.Repos, l *log.Logger) {
+	if r == nil {
+		return
+	}
+	r.Offline = s.Offline
+	r.InsecureSkipVerify = s.InsecureSkipVerify
+	r.TraceGit = s.TraceGit
+	r.GitBackend = s.GitBackend
+	r.GitTimeout = s.GitTimeout
+	r.Log = l
+}
+
+// lockChart takes the lock on a workspace chart.
+//
+// It returns a function that releases the lock.
+func (c *Client) lockChart(chartName string) (func(), error) {
+	path := helmpath.Home(c.Home).Locks("charts", chartName+".lock")
+	l, err := lock.Acquire(path)
+	if err != nil {
+		return nil, fmt.Errorf("Could not lock %s: %s", path, err)
+	}
+	return func() { l.Release() }, nil
+}
@@ -0,0 +1,54 @@
+package action
+
+import (
+	"path/filepath"
+	"sort"
+
+	"github.com/helm/helm-classic/config"
+	"github.com/helm/helm-classic/search"
+	helm "github.com/helm/helm-classic/util"
+)
+
+// The kinds of names that CompletionNames completes.
+const (
+	// CompleteCharts are the charts of the workspace, and those of the
+	// caches of the repositories.
+	CompleteCharts = "charts"
+	// CompleteRepos are the repositories of the configuration.
+	CompleteRepos = "repos"
+)
+
+// CompletionNames returns the names of a kind, CompleteCharts or
+// CompleteRepos, that the completion of a shell offers for an argument,
+// sorted and without duplicates.
+//
+// Only the home is read, never the network, so that completing is fast. A
+// home whose configuration cannot be read has no names, rather than an
+// error, since a shell could only print it into the command line.
+func CompletionNames(homedir, kind string) []string {
+	cfg, err := config.LoadHome(homedir)
+	if err != nil {
+		return nil
+	}
+	seen := map[string]bool{}
+	switch kind {
+	case CompleteRepos:
+		for _, t := range cfg.Repos.Tables {
+			seen[t.Name] = true
+		}
+	case CompleteCharts:
+		dirs, _ := filepath.Glob(helm.WorkspaceChartDirectory(homedir, "*", Chartfile))
+		for _, d := range dirs {
+			seen[filepath.Base(filepath.Dir(d))] = true
+		}
+		for _, n := range search.NewIndex(cfg, cfg.Repos.Dir).Names() {
+			seen[n] = true
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for n := range seen {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,131 @@
+package action
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/helm/helm-classic/config"
+	"github.com/helm/helm-classic/kubectl"
+	"github.com/helm/helm-classic/log"
+	helm "github.com/helm/helm-classic/util"
+)
+
+// ConfigGet prints the value of a configuration key.
+//
+// A single value is printed as it is, and a section or list as YAML. Nothing
+// is printed for a key that is not set.
+func ConfigGet(homedir, key string) {
+	v, err := mustConfig(homedir).Get(key)
+	if err != nil {
+		log.Die("%s", err)
+	}
+	if v == nil {
+		return
+	}
+	switch reflect.Indirect(reflect.ValueOf(v)).Kind() {
+	case reflect.Struct, reflect.Slice, reflect.Map:
+		printConfigYAML(v)
+	default:
+		log.Msg("%s", helm.Redact(fmt.Sprint(v)))
+	}
+}
+
+// ConfigSet sets a configuration key, and saves the configuration.
+//
+// The value is parsed for the type of the key. The whole configuration is
+// validated before it is written, and left as it was if it is not valid.
+func ConfigSet(homedir, key, value string) {
+	defer lockConfig(homedir)()
+	cfg := mustConfig(homedir)
+
+	if err := cfg.Set(key, value); err != nil {
+		log.Die("%s", err)
+	}
+	saveConfig(cfg, key)
+}
+
+// ConfigUnset returns a configuration key to its default, and saves the
+// configuration.
+func ConfigUnset(homedir, key string) {
+	defer lockConfig(homedir)()
+	cfg := mustConfig(homedir)
+
+	if err := cfg.Unset(key); err != nil {
+		log.Die("%s", err)
+	}
+	saveConfig(cfg, key)
+}
+
+// saveConfig validates and saves a configuration after key was changed.
+func saveConfig(cfg *config.Configfile, key string) {
+	if err := cfg.Validate(); err != nil {
+		log.Die("Not changing %s, because the configuration would be invalid: %s", key, err)
+	}
+	if err := cfg.Save(""); err != nil {
+		log.Die("Could not save configuration: %s", err)
+	}
+}
+
+// ConfigView prints the configuration that commands use.
+//
+// The kubectl path is the one that --kubectl-path or $HELMC_KUBECTL chose,
+// if either did, and global settings that are not saved in the file are
+// listed in comments. Credentials in URLs are redacted.
+func ConfigView(homedir string) {
+	cfg := mustConfig(homedir)
+	if path := kubectl.Defaults.Path; path != kubectl.DefaultPath || cfg.Kubectl != nil {
+		cfg.Kubectl = &config.Kubectl{Path: path}
+	}
+	printConfigYAML(cfg)
+
+	settings := []string{}
+	if Defaults.Offline {
+		settings = append(settings, "offline: true")
+	}
+	if Defaults.InsecureSkipVerify {
+		settings = append(settings, "insecureSkipVerify: true")
+	}
+	if Defaults.TraceGit > 0 {
+		settings = append(settings, fmt.Sprintf("traceGit: %d", Defaults.TraceGit))
+	}
+	if b := Defaults.GitBackend; b != "" && b != config.BackendExec {
+		settings = append(settings, "gitBackend: "+b)
+	}
+	if len(settings) > 0 {
+		log.Msg("# Set by flags or the environment:\n# %s", strings.Join(settings, "\n# "))
+	}
+}
+
+// ConfigProfiles prints the profiles of the configuration, with an asterisk
+// by the active one, which --profile selected.
+func ConfigProfiles(homedir, active string) {
+	cfg := mustConfig(homedir)
+	if len(cfg.Profiles) == 0 {
+		log.Info("No profiles are defined. Define one with 'helmc config set profiles.NAME.namespace NAMESPACE'.")
+		return
+	}
+	w := tabwriter.NewWriter(log.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "\tNAME\tNAMESPACE\tKUBE CONTEXT\tKUBECONFIG")
+	for _, name := range cfg.ProfileNames() {
+		p := cfg.Profiles[name]
+		mark := ""
+		if name == active {
+			mark = "*"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", mark, name, dash(p.Namespace), dash(p.KubeContext), dash(p.Kubeconfig))
+	}
+	w.Flush()
+}
+
+// printConfigYAML prints part of the configuration as YAML, without secrets.
+func printConfigYAML(v interface{}) {
+	b, err := yaml.Marshal(v)
+	if err != nil {
+		log.Die("%s", err)
+	}
+	fmt.Fprint(log.Stdout, helm.Redact(string(b)))
+}
@@ -4,49 +4,163 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
-	"text/template"
+	"strings"
 
 	"github.com/helm/helm-classic/chart"
+	"github.com/helm/helm-classic/generator"
 	"github.com/helm/helm-classic/log"
 	helm "github.com/helm/helm-classic/util"
+	"gopkg.in/yaml.v2"
 )
 
-// readmeSkel is the template for the README.md
-const readmeSkel = `# {{.Name}}
-
-Describe your chart here. Link to upstream repositories, Docker images or any
-external documentation.
-
-If your application requires any specific configuration like Secrets, you may
-include that information here.
-`
-
-// manifestSkel is an example manifest for a new chart
-const manifestSkel = `---
-apiVersion: v1
-kind: Pod
-metadata:
-  name: example-pod
-  labels:
-    heritage: helm
-spec:
-  restartPolicy: Never
-  containers:
-  - name: example
-    image: "alpine:3.2"
-    command: ["/bin/sleep","9000"]
-`
+// CreateOptions are the fields of the Chart.yaml of a new chart, and what it
+// is copied from. An empty field is that of the Chart.yaml of the starter, or
+// of the example Chart.yaml if the starter has none.
+type CreateOptions struct {
+	// Starter names the starter to copy; if blank, DefaultStarter is used.
+	Starter     string
+	Description string
+	Version     string
+	// Maintainer and Email are those of the chart's maintainer.
+	Maintainer string
+	Email      string
+	Keywords   []string
+	// NoExamples leaves out the manifests of the starter.
+	NoExamples bool
+	// Scaffold names the scaffold to generate the chart from, instead of a
+	// starter; see loadScaffold.
+	Scaffold string
+}
 
 // Create a chart
 //
 // - chartName being created
 // - homeDir is the helm home directory for the user
-func Create(chartName, homeDir string) {
-	chart := newSkelChartfile(chartName)
-	createWithChart(chart, chartName, homeDir)
+// - starter names the starter to copy; if blank, DefaultStarter is used
+func Create(chartName, homeDir, starter string) {
+	CreateChart(chartName, homeDir, CreateOptions{Starter: starter})
+}
+
+// CreateChart creates a chart with the Chart.yaml fields of o. A version
+// that is not SemVer, or an email address that is not one, is refused.
+//
+// A chart that is created from a scaffold is generated once it is created,
+// so that its manifests are those of its templates and values.yaml.
+func CreateChart(chartName, homeDir string, o CreateOptions) {
+	if o.Scaffold != "" && (o.Starter != "" && o.Starter != DefaultStarter || o.NoExamples) {
+		log.Die("A chart is created from either a scaffold or a starter. Use --from empty for a chart without manifests.")
+	}
+	if o.Starter == "" {
+		o.Starter = DefaultStarter
+	}
+	if o.Version != "" {
+		if err := checkVersion(o.Version); err != nil {
+			log.Die("%s", err)
+		}
+	}
+	if o.Email != "" {
+		if err := checkEmail(o.Email); err != nil {
+			log.Die("%s", err)
+		}
+	}
+	var files map[string]string
+	var err error
+	if o.Scaffold != "" {
+		files, err = loadScaffold(homeDir, o.Scaffold, chartName)
+	} else {
+		files, err = loadStarter(homeDir, o.Starter)
+	}
+	if err != nil {
+		log.Die("%s", err)
+	}
+	cf, err := starterChartfile(files, chartName)
+	if err != nil {
+		log.Die("Could not read the starter's Chart.yaml: %s", err)
+	}
+	o.apply(cf)
+
+	// The starter's Chart.yaml is replaced by cf.
+	res := map[string]string{}
+	for p, content := range files {
+		if p != Chartfile && !(o.NoExamples && strings.HasPrefix(p, "manifests/")) {
+			res[p] = content
+		}
+	}
+	createWithStarter(res, cf, chartName, homeDir)
+
+	if o.Scaffold != "" {
+		if err := Generate(chartName, homeDir, GenerateOptions{}); err != nil {
+			log.Die("Could not generate the manifests of %s: %s", chartName, err)
+		}
+	}
+}
+
+// starterChartfile returns the Chart.yaml of a starter for a new chart, or
+// the example Chart.yaml if the starter has none.
+func starterChartfile(files map[string]string, chartName string) (*chart.Chartfile, error) {
+	content, ok := files[Chartfile]
+	if !ok {
+		return newSkelChartfile(chartName), nil
+	}
+	cf := &chart.Chartfile{}
+	if err := yaml.Unmarshal([]byte(strings.Replace(content, StarterChartName, chartName, -1)), cf); err != nil {
+		return nil, err
+	}
+	cf.Name = chartName
+	return cf, nil
+}
+
+// apply sets the fields of cf that o gives.
+func (o CreateOptions) apply(cf *chart.Chartfile) {
+	if o.Description != "" {
+		cf.Description = o.Description
+	}
+	if o.Version != "" {
+		cf.Version = o.Version
+	}
+	if o.Maintainer != "" || o.Email != "" {
+		name, email := "", ""
+		if len(cf.Maintainers) > 0 {
+			name, email = splitMaintainer(cf.Maintainers[0])
+		}
+		cf.Maintainers = []string{joinMaintainer(or(o.Maintainer, name), or(o.Email, email))}
+	}
+	if len(o.Keywords) > 0 {
+		cf.Keywords = o.Keywords
+	}
+}
+
+// splitMaintainer splits a maintainer of a Chart.yaml, such as "Your Name
+// <email@address>", into a name and an email address.
+func splitMaintainer(m string) (name, email string) {
+	i := strings.LastIndex(m, "<")
+	if i < 0 || !strings.HasSuffix(m, ">") {
+		return strings.TrimSpace(m), ""
+	}
+	return strings.TrimSpace(m[:i]), m[i+1 : len(m)-1]
+}
+
+// joinMaintainer is the maintainer of a Chart.yaml with a name and an email
+// address, either of which may be empty.
+func joinMaintainer(name, email string) string {
+	if email == "" {
+		return name
+	}
+	return strings.TrimSpace(name + " <" + email + ">")
 }
 
 func createWithChart(chart *chart.Chartfile, chartName, homeDir string) {
+	createWithStarter(builtinStarters[DefaultStarter].files, chart, chartName, homeDir)
+}
+
+// createWithStarter copies the files of a starter into the workspace
+// directory chartName.
+//
+// StarterChartName is replaced by the name of cf in the path and contents
+// of each file, except that files with generator directives are copied
+// intact. If the starter has no Chart.yaml, cf is saved as the Chart.yaml;
+// otherwise, only its name is set.
+func createWithStarter(files map[string]string, cf *chart.Chartfile, chartName, homeDir string) {
 	chartDir := helm.WorkspaceChartDirectory(homeDir, chartName)
 
 	// create directories
@@ -54,24 +168,41 @@ func createWithChart(chart *chart.Chartfile, chartName, homeDir string) {
 		log.Die("Could not create %q: %s", chartDir, err)
 	}
 
-	// create Chartfile.yaml
-	if err := chart.Save(filepath.Join(chartDir, Chartfile)); err != nil {
-		log.Die("Could not create Chart.yaml: err", err)
+	for p, content := range files {
+		dest := filepath.Join(chartDir, filepath.FromSlash(strings.Replace(p, StarterChartName, cf.Name, -1)))
+		if !strings.Contains(content, generator.GeneratorKeyword) && !strings.Contains(content, generator.RawGeneratorKeyword) {
+			content = strings.Replace(content, StarterChartName, cf.Name, -1)
+		}
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			log.Die("Could not create %q: %s", filepath.Dir(dest), err)
+		}
+		if err := ioutil.WriteFile(dest, []byte(content), 0644); err != nil {
+			log.Die("Could not create %s: %s", p, err)
+		}
 	}
 
-	// create README.md
-	if err := createReadme(chartDir, chart); err != nil {
-		log.Die("Could not create README.md: err", err)
+	// create Chartfile.yaml
+	if _, ok := files[Chartfile]; ok {
+		sc, err := chart.LoadChartfile(filepath.Join(chartDir, Chartfile))
+		if err != nil {
+			log.Die("Could not read the starter's Chart.yaml: %s", err)
+		}
+		sc.Name = cf.Name
+		cf = sc
 	}
-
-	// create example-pod
-	if err := createExampleManifest(chartDir); err != nil {
-		log.Die("Could not create example manifest: err", err)
+	if err := cf.Save(filepath.Join(chartDir, Chartfile)); err != nil {
+		log.Die("Could not create Chart.yaml: %s", err)
 	}
 
 	log.Info("Created chart in %s", chartDir)
 }
 
+// The maintainer of the example Chart.yaml.
+const (
+	skelMaintainer = "Your Name"
+	skelEmail      = "email@address"
+)
+
 // newSkelChartfile populates a Chartfile struct with example data
 func newSkelChartfile(chartName string) *chart.Chartfile {
 	return &chart.Chartfile{
@@ -79,24 +210,7 @@ func newSkelChartfile(chartName string) *chart.Chartfile {
 		Home:        "http://example.com/your/project/home",
 		Version:     "0.1.0",
 		Description: "Provide a brief description of your application here.",
-		Maintainers: []string{"Your Name <email@address>"},
+		Maintainers: []string{joinMaintainer(skelMaintainer, skelEmail)},
 		Details:     "This section allows you to provide additional details about your application.\nProvide any information that would be useful to users at a glance.",
 	}
 }
-
-// createReadme populates readmeSkel and saves to the chart directory
-func createReadme(chartDir string, c *chart.Chartfile) error {
-	tmpl := template.Must(template.New("info").Parse(readmeSkel))
-
-	readmeFile, err := os.Create(filepath.Join(chartDir, "README.md"))
-	if err != nil {
-		return err
-	}
-
-	return tmpl.Execute(readmeFile, c)
-}
-
-// createExampleManifest saves manifestSkel to the manifests directory
-func createExampleManifest(chartDir string) error {
-	return ioutil.WriteFile(filepath.Join(chartDir, "manifests/example-pod.yaml"), []byte(manifestSkel), 0644)
-}
@@ -1,9 +1,15 @@
 package action
 
 import (
+	"bytes"
 	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
 	"testing"
 
+	"github.com/helm/helm-classic/helmpath"
 	"github.com/helm/helm-classic/test"
 	"github.com/helm/helm-classic/util"
 )
@@ -11,7 +17,7 @@ import (
 func TestCreate(t *testing.T) {
 	tmpHome := test.CreateTmpHome()
 
-	Create("mychart", tmpHome)
+	Create("mychart", tmpHome, "")
 
 	// assert chartfile
 	chartfile, err := ioutil.ReadFile(util.WorkspaceChartDirectory(tmpHome, "mychart/Chart.yaml"))
@@ -66,6 +72,149 @@ spec:
   - name: example
     image: "alpine:3.2"
     command: ["/bin/sleep","9000"]
+    resources:
+      requests:
+        cpu: 10m
+        memory: 16Mi
 `
 	test.ExpectEquals(t, actualManifest, expectedManifest)
 }
+
+func TestCreateStarters(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+
+	Create("web", tmpHome, "web-service")
+	svc, err := ioutil.ReadFile(util.WorkspaceChartDirectory(tmpHome, "web", "manifests", "web-service.yaml"))
+	if err != nil {
+		t.Fatalf("Could not read the service: %s", err)
+	}
+	test.ExpectContains(t, string(svc), "  name: web\n")
+
+	// A starter in the home keeps its Chart.yaml and generator directives.
+	dir := helmpath.Home(tmpHome).Starters("cron")
+	os.MkdirAll(filepath.Join(dir, "manifests"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, Chartfile), []byte("name: cron\nversion: 1.0.0\ndescription: A periodic job.\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "manifests", "<CHARTNAME>-job.yaml"), []byte("metadata:\n  name: <CHARTNAME>-job\n"), 0644)
+	gen := "#helm:generate helmc tpl -o manifests/<CHARTNAME>.yaml tpl/job.yaml\n"
+	os.MkdirAll(filepath.Join(dir, "tpl"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "tpl", "job.yaml"), []byte(gen), 0644)
+
+	out := test.CaptureOutput(func() {
+		ListStarters(tmpHome)
+	})
+	test.ExpectMatches(t, out, "cron +"+regexp.QuoteMeta(dir)+" +A periodic job.")
+	test.ExpectMatches(t, out, `web-service +\(built-in\) +A Deployment behind a Service.`)
+
+	Create("nightly", tmpHome, "cron")
+	cf, err := ioutil.ReadFile(util.WorkspaceChartDirectory(tmpHome, "nightly", Chartfile))
+	if err != nil || !strings.HasPrefix(string(cf), "name: nightly\n") || !strings.Contains(string(cf), "version: 1.0.0") {
+		t.Errorf("Expected the starter's Chart.yaml with the new name, got %q, %v", cf, err)
+	}
+	job, _ := ioutil.ReadFile(util.WorkspaceChartDirectory(tmpHome, "nightly", "manifests", "nightly-job.yaml"))
+	test.ExpectEquals(t, string(job), "metadata:\n  name: nightly-job\n")
+	tpl, _ := ioutil.ReadFile(util.WorkspaceChartDirectory(tmpHome, "nightly", "tpl", "job.yaml"))
+	test.ExpectEquals(t, string(tpl), gen)
+	if _, err := os.Stat(util.WorkspaceChartDirectory(tmpHome, "nightly", "manifests", "nightly.yaml")); err == nil {
+		t.Errorf("Expected the generator not to run")
+	}
+
+	if _, err := loadStarter(tmpHome, "nope"); err == nil || !strings.Contains(err.Error(), "Available starters: cron, daemon, default, web-service") {
+		t.Errorf("Expected an unknown starter to list the available ones, got %v", err)
+	}
+}
+
+func TestCreateInteractive(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+	test.FakeUpdate(tmpHome)
+
+	// Pressing enter takes each default, which makes the chart of Create.
+	var out bytes.Buffer
+	test.CaptureOutput(func() {
+		if err := createInteractive("", tmpHome, CreateOptions{}, strings.NewReader("plain\n\n\n\n\n\n\n\n"), &out); err != nil {
+			t.Fatal(err)
+		}
+		Create("reference", tmpHome, "")
+	})
+	plain, _ := ioutil.ReadFile(util.WorkspaceChartDirectory(tmpHome, "plain", Chartfile))
+	ref, _ := ioutil.ReadFile(util.WorkspaceChartDirectory(tmpHome, "reference", Chartfile))
+	test.ExpectEquals(t, strings.Replace(string(plain), "plain", "reference", 1), string(ref))
+	test.ExpectContains(t, out.String(), "Version [0.1.0]: ")
+
+	// Invalid answers are asked again, and the printed command makes the
+	// same chart without prompts.
+	out.Reset()
+	answers := "plain\nweb\nweb-service\nA web server\nnot-a-version\n1.2.0\nJo Doe\njo\njo@example.com\nweb, nginx\nmaybe\nn\n"
+	output := test.CaptureOutput(func() {
+		if err := createInteractive("", tmpHome, CreateOptions{}, strings.NewReader(answers), &out); err != nil {
+			t.Fatal(err)
+		}
+	})
+	test.ExpectContains(t, out.String(), "Your workspace already has a chart named plain.")
+	test.ExpectContains(t, out.String(), "not-a-version is not a SemVer version")
+	test.ExpectContains(t, out.String(), "jo is not an email address.")
+	test.ExpectContains(t, out.String(), "Answer y or n.")
+	cmd := "helmc create --description 'A web server' --chart-version 1.2.0 --maintainer 'Jo Doe' --email jo@example.com --keywords web,nginx --starter web-service --no-examples web"
+	test.ExpectContains(t, output, cmd)
+	test.ExpectContains(t, output, "Chart [web] has passed all necessary checks")
+
+	web, _ := ioutil.ReadFile(util.WorkspaceChartDirectory(tmpHome, "web", Chartfile))
+	test.ExpectContains(t, string(web), "version: 1.2.0\ndescription: A web server\nmaintainers:\n- Jo Doe <jo@example.com>\n")
+	test.ExpectContains(t, string(web), "keywords:\n- web\n- nginx\n")
+	if _, err := os.Stat(util.WorkspaceChartDirectory(tmpHome, "web", "manifests", "web-service.yaml")); err == nil {
+		t.Error("Expected no example manifests")
+	}
+	test.CaptureOutput(func() {
+		CreateChart("web2", tmpHome, CreateOptions{Starter: "web-service", Description: "A web server", Version: "1.2.0", Maintainer: "Jo Doe", Email: "jo@example.com", Keywords: []string{"web", "nginx"}, NoExamples: true})
+	})
+	web2, _ := ioutil.ReadFile(util.WorkspaceChartDirectory(tmpHome, "web2", Chartfile))
+	test.ExpectEquals(t, strings.Replace(string(web2), "web2", "web", 1), string(web))
+
+	// Running out of answers creates nothing, and so does a stdin that is
+	// not a terminal.
+	if err := createInteractive("partial", tmpHome, CreateOptions{}, strings.NewReader("\n"), &out); err == nil {
+		t.Error("Expected the wizard to stop without answers")
+	}
+	if err := CreateInteractive("partial", tmpHome, CreateOptions{}); err == nil || !strings.Contains(err.Error(), "flags of 'helmc create'") {
+		t.Errorf("Expected the wizard to need a terminal, got %v", err)
+	}
+	if _, err := os.Stat(util.WorkspaceChartDirectory(tmpHome, "partial")); err == nil {
+		t.Error("Expected no chart to be created")
+	}
+}
+
+func TestCreateScaffold(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+	test.FakeUpdate(tmpHome)
+
+	test.CaptureOutput(func() {
+		CreateChart("nightly", tmpHome, CreateOptions{Scaffold: "cronjob"})
+	})
+	job, err := ioutil.ReadFile(util.WorkspaceChartDirectory(tmpHome, "nightly", "manifests", "cronjob.yaml"))
+	if err != nil {
+		t.Fatalf("Expected the manifests to be generated: %s", err)
+	}
+	test.ExpectContains(t, string(job), "  name: nightly\n")
+	test.ExpectContains(t, string(job), `  schedule: "0 3 * * *"`)
+	test.ExpectContains(t, string(job), `command: ["/bin/echo","hello"]`)
+	readme, _ := ioutil.ReadFile(util.WorkspaceChartDirectory(tmpHome, "nightly", "README.md"))
+	test.ExpectContains(t, string(readme), "# nightly\n")
+
+	// A scaffold of the home replaces the files of the built-in one.
+	dir := helmpath.Home(tmpHome).Scaffolds("cronjob")
+	os.MkdirAll(dir, 0755)
+	ioutil.WriteFile(filepath.Join(dir, "values.yaml"), []byte("# [[.Name]]\nschedule: \"@hourly\"\nimage: {repository: busybox, tag: latest}\ncommand: [date]\nresources: {cpu: 1m, memory: 1Mi}\n"), 0644)
+	test.CaptureOutput(func() {
+		CreateChart("hourly", tmpHome, CreateOptions{Scaffold: "cronjob"})
+	})
+	values, _ := ioutil.ReadFile(util.WorkspaceChartDirectory(tmpHome, "hourly", "values.yaml"))
+	test.ExpectContains(t, string(values), "# hourly\n")
+	job, _ = ioutil.ReadFile(util.WorkspaceChartDirectory(tmpHome, "hourly", "manifests", "cronjob.yaml"))
+	test.ExpectContains(t, string(job), `  schedule: "@hourly"`)
+
+	if _, err := loadScaffold(tmpHome, "nope", "x"); err == nil || !strings.Contains(err.Error(), "Available scaffolds: cronjob, daemonset, deployment, empty") {
+		t.Errorf("Expected an unknown scaffold to list the available ones, got %v", err)
+	}
+}
@@ -0,0 +1,243 @@
+package action
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"github.com/helm/helm-classic/generator"
+	"github.com/helm/helm-classic/log"
+	helm "github.com/helm/helm-classic/util"
+)
+
+// chartNameRE matches the names that a new chart may have, which are also
+// the names of its directory.
+var chartNameRE = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]*$`)
+
+// CreateInteractive asks for the fields of a new chart, and then creates it
+// as CreateChart does. chartName and the fields of o are the defaults of the
+// answers, and an empty answer takes the default.
+//
+// Once the chart is created, the 'helmc create' command that creates the same
+// chart without prompts is printed, and the chart is linted, with its
+// findings shown. Findings do not fail the command, since the chart is only a
+// start.
+//
+// The questions are asked on log.Stdout, and read from log.Stdin, which
+// must be a terminal.
+func CreateInteractive(chartName, homeDir string, o CreateOptions) error {
+	if !stdinIsTerminal() {
+		return errors.New("helmc create --interactive asks its questions in a terminal, and stdin is not one. Give the answers with the flags of 'helmc create' instead, as 'helmc help create' lists them.")
+	}
+	return createInteractive(chartName, homeDir, o, log.Stdin, log.Stdout)
+}
+
+// createInteractive is CreateInteractive, for any input.
+func createInteractive(chartName, homeDir string, o CreateOptions, in io.Reader, out io.Writer) error {
+	chartName, o, err := askCreate(bufio.NewReader(in), out, chartName, homeDir, o)
+	if err != nil {
+		return err
+	}
+	CreateChart(chartName, homeDir, o)
+	log.Info("To create the same chart without prompts, run:")
+	log.Msg("\t%s", createCommand(chartName, o))
+
+	dir := helm.WorkspaceChartDirectory(homeDir, chartName)
+	if err := Lint(dir, homeDir, LintOptions{}); err != nil {
+		log.Warn("%s Fix the findings above in %s, and run 'helmc lint %s' again.", err, dir, chartName)
+	}
+	return nil
+}
+
+// askCreate asks for the name and fields of a new chart.
+func askCreate(in *bufio.Reader, out io.Writer, chartName, homeDir string, o CreateOptions) (string, CreateOptions, error) {
+	ask := func(question, def string, valid func(string) error) (string, error) {
+		return prompt(in, out, question, def, valid)
+	}
+	var err error
+	if chartName, err = ask("Name of the chart", chartName, func(a string) error {
+		switch {
+		case !chartNameRE.MatchString(a):
+			return errors.New("A name has letters, digits, '.', '_', and '-', and starts with a letter or digit.")
+		case chartFetched(a, homeDir, nil):
+			return fmt.Errorf("Your workspace already has a chart named %s.", a)
+		}
+		return nil
+	}); err != nil {
+		return "", o, err
+	}
+
+	names := []string{}
+	for _, s := range starters(homeDir) {
+		names = append(names, s.name)
+	}
+	if o.Starter, err = ask("Starter ("+strings.Join(names, ", ")+")", or(o.Starter, DefaultStarter), func(a string) error {
+		for _, n := range names {
+			if a == n {
+				return nil
+			}
+		}
+		return fmt.Errorf("Unknown starter %q.", a)
+	}); err != nil {
+		return "", o, err
+	}
+
+	files, err := loadStarter(homeDir, o.Starter)
+	if err != nil {
+		return "", o, err
+	}
+	def, err := starterChartfile(files, chartName)
+	if err != nil {
+		return "", o, fmt.Errorf("Could not read the Chart.yaml of the starter %s: %s", o.Starter, err)
+	}
+	defName, defEmail := "", ""
+	if len(def.Maintainers) > 0 {
+		defName, defEmail = splitMaintainer(def.Maintainers[0])
+	}
+
+	if o.Description, err = ask("Description", or(o.Description, def.Description), nil); err != nil {
+		return "", o, err
+	}
+	if o.Version, err = ask("Version", or(o.Version, def.Version), checkVersion); err != nil {
+		return "", o, err
+	}
+	if o.Maintainer, err = ask("Name of the maintainer", or(o.Maintainer, defName), nil); err != nil {
+		return "", o, err
+	}
+	if o.Email, err = ask("Email of the maintainer", or(o.Email, defEmail), checkEmail); err != nil {
+		return "", o, err
+	}
+	keywords, err := ask("Keywords, separated by commas", strings.Join(orList(o.Keywords, def.Keywords), ","), nil)
+	if err != nil {
+		return "", o, err
+	}
+	o.Keywords = SplitKeywords(keywords)
+
+	examples := "y"
+	if o.NoExamples {
+		examples = "n"
+	}
+	if examples, err = ask("Include the example manifests of the starter (y/n)", examples, func(a string) error {
+		if _, ok := yesNo(a); !ok {
+			return errors.New("Answer y or n.")
+		}
+		return nil
+	}); err != nil {
+		return "", o, err
+	}
+	yes, _ := yesNo(examples)
+	o.NoExamples = !yes
+	return chartName, o, nil
+}
+
+// checkVersion checks the version of a new chart.
+func checkVersion(v string) error {
+	if _, err := semver.NewVersion(v); err != nil {
+		return fmt.Errorf("%s is not a SemVer version, such as 0.1.0.", v)
+	}
+	return nil
+}
+
+// checkEmail checks the email address of the maintainer of a new chart,
+// which may be left out.
+func checkEmail(e string) error {
+	if e != "" && !strings.Contains(e, "@") || strings.ContainsAny(e, " <>") {
+		return fmt.Errorf("%s is not an email address.", e)
+	}
+	return nil
+}
+
+// prompt asks a question until the answer is valid, and returns it. An empty
+// answer is def. valid may be nil.
+func prompt(in *bufio.Reader, out io.Writer, question, def string, valid func(string) error) (string, error) {
+	for {
+		if def != "" {
+			fmt.Fprintf(out, "%s [%s]: ", question, def)
+		} else {
+			fmt.Fprintf(out, "%s: ", question)
+		}
+		line, err := in.ReadString('\n')
+		if err != nil && line == "" {
+			fmt.Fprintln(out)
+			return "", fmt.Errorf("No answer to %q. Nothing was created", question)
+		}
+		a := strings.TrimSpace(line)
+		if a == "" {
+			a = def
+		}
+		if valid == nil {
+			return a, nil
+		}
+		verr := valid(a)
+		if verr == nil {
+			return a, nil
+		}
+		fmt.Fprintln(out, verr)
+		if err != nil {
+			return "", fmt.Errorf("No valid answer to %q. Nothing was created", question)
+		}
+	}
+}
+
+// yesNo reads a yes or no answer.
+func yesNo(a string) (yes, ok bool) {
+	switch strings.ToLower(a) {
+	case "y", "yes":
+		return true, true
+	case "n", "no":
+		return false, true
+	}
+	return false, false
+}
+
+// or returns a, or def if a is empty.
+func or(a, def string) string {
+	if a == "" {
+		return def
+	}
+	return a
+}
+
+// orList returns a, or def if a is empty.
+func orList(a, def []string) []string {
+	if len(a) == 0 {
+		return def
+	}
+	return a
+}
+
+// SplitKeywords splits a list of keywords that are separated by commas.
+func SplitKeywords(s string) []string {
+	var res []string
+	for _, k := range strings.Split(s, ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			res = append(res, k)
+		}
+	}
+	return res
+}
+
+// createCommand returns the 'helmc create' command that creates the chart
+// that the answers describe.
+func createCommand(chartName string, o CreateOptions) string {
+	args := []string{"helmc", "create"}
+	flag := func(name, value string) {
+		if value != "" {
+			args = append(args, "--"+name, generator.ShellQuote(value))
+		}
+	}
+	flag("description", o.Description)
+	flag("chart-version", o.Version)
+	flag("maintainer", o.Maintainer)
+	flag("email", o.Email)
+	flag("keywords", strings.Join(o.Keywords, ","))
+	flag("starter", o.Starter)
+	if o.NoExamples {
+		args = append(args, "--no-examples")
+	}
+	return strings.Join(append(args, generator.ShellQuote(chartName)), " ")
+}
@@ -0,0 +1,179 @@
+package action
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/helm/helm-classic/chart"
+	"github.com/helm/helm-classic/config"
+	"github.com/helm/helm-classic/dependency"
+	"github.com/helm/helm-classic/log"
+	helm "github.com/helm/helm-classic/util"
+)
+
+// Deps prints the dependency tree of a chart of the workspace or of a
+// repository.
+//
+// - chartName is the name of the chart in the workspace, or of a chart of a
+// repository, such as charts/redis
+// - homedir is the home directory for the user
+// - graph is "" for an indented tree, "dot" for Graphviz, or "json"
+func Deps(chartName, homedir, graph string) {
+	c := newClient(homedir, nil)
+	c.Config = mustConfig(homedir)
+	g, err := c.Deps(chartName)
+	if err != nil {
+		log.Die("%s", err)
+	}
+
+	switch graph {
+	case "":
+		printDepsTree(g, c.Config.Repos)
+	case "dot":
+		fmt.Fprint(log.Stdout, depsDot(g, c.Config.Repos))
+	case "json":
+		b, err := json.MarshalIndent(g, "", "  ")
+		if err != nil {
+			log.Die("%s", err)
+		}
+		log.Msg(string(b))
+	default:
+		log.Die("Unknown graph format %q. Use dot or json.", graph)
+	}
+}
+
+// Deps resolves the dependencies of a chart of the workspace, or of the
+// cache of a repository, against the other charts in the workspace. Those
+// that the workspace does not have are resolved against the caches of the
+// repositories, as 'helmc fetch --deps' would fetch them, without fetching
+// them.
+func (c *Client) Deps(chartName string) (*dependency.Graph, error) {
+	cfg, err := c.config()
+	if err != nil {
+		return nil, err
+	}
+	cf, err := c.depsChartfile(cfg, chartName)
+	if err != nil {
+		return nil, err
+	}
+	src := &previewSource{repoSource{c: c, r: cfg.Repos}}
+	return dependency.Build(cf, helm.WorkspaceChartDirectory(c.Home), src)
+}
+
+// depsChartfile reads the Chart.yaml of the workspace chart chartName, or
+// if the workspace does not have it, of the chart of a repository.
+func (c *Client) depsChartfile(cfg *config.Configfile, chartName string) (*chart.Chartfile, error) {
+	if strings.Contains(chartName, "/") || !chartFetched(chartName, c.Home, c.Log) {
+		repo, name, err := cfg.Repos.Resolve(chartName)
+		if err != nil {
+			return nil, err
+		}
+		cf, err := cfg.Repos.CachedChart(repo, name)
+		if err != nil {
+			return nil, fmt.Errorf("Could not find chart %s in the workspace or in the cache of %s: %s", chartName, repo, err)
+		}
+		return cf, nil
+	}
+	cf, err := chart.LoadChartfile(filepath.Join(helm.WorkspaceChartDirectory(c.Home, chartName), Chartfile))
+	if err != nil {
+		return nil, fmt.Errorf("Could not find chart %s in the workspace: %s", chartName, err)
+	}
+	return cf, nil
+}
+
+// previewSource is a repoSource that reads the Chart.yaml of a candidate
+// from the cache of its repository, rather than fetching it.
+//
+// The charts of an HTTP repository are read from its index, which does not
+// list their dependencies.
+type previewSource struct {
+	repoSource
+}
+
+// Fetch returns the Chart.yaml of a candidate.
+func (s *previewSource) Fetch(cd *dependency.Candidate) (*chart.Chartfile, error) {
+	return s.r.CachedChart(cd.Repo, cd.Name)
+}
+
+// depsRepo names the repository a chart was fetched from, by its name in the
+// configuration if it has one.
+func depsRepo(r *config.Repos, url string) string {
+	for _, t := range r.Tables {
+		if t.Repo == url {
+			return t.Name
+		}
+	}
+	return url
+}
+
+// depsLabel describes a node for the tree and the graph.
+func depsLabel(n *dependency.Node, r *config.Repos) string {
+	switch {
+	case n.Missing:
+		return n.ID + " (missing)"
+	case n.Fetch:
+		return n.ID + " [" + depsRepo(r, n.Repo) + "] (to fetch)"
+	case n.Repo == "":
+		return n.ID
+	}
+	return n.ID + " [" + depsRepo(r, n.Repo) + "]"
+}
+
+// printDepsTree prints each dependency under the chart that declares it,
+// with its constraint. The dependencies of a cycle are not repeated.
+func printDepsTree(g *dependency.Graph, r *config.Repos) {
+	log.Msg(depsLabel(g.Node(g.Root), r))
+	var walk func(id, indent string)
+	walk = func(id, indent string) {
+		for _, e := range g.Children(id) {
+			line := fmt.Sprintf("%s%s %s", indent, depsLabel(g.Node(e.To), r), e.Constraint)
+			if e.Cycle {
+				log.Msg("%s (cycle)", line)
+				continue
+			}
+			log.Msg(line)
+			walk(e.To, indent+"  ")
+		}
+	}
+	walk(g.Root, "  ")
+}
+
+// depsDot renders a dependency graph in the Graphviz DOT language.
+//
+// Missing charts are dashed and red, charts to fetch are dashed, and the
+// edges that close a cycle are red.
+func depsDot(g *dependency.Graph, r *config.Repos) string {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "digraph %s {\n", strconv.Quote(g.Root))
+	b.WriteString("\tnode [shape=box];\n")
+	for _, n := range g.Nodes {
+		var attrs []string
+		switch {
+		case n.Missing:
+			attrs = []string{"label=" + strconv.Quote(n.ID+"\nmissing"), "style=dashed", "color=red", "fontcolor=red"}
+		case n.Fetch:
+			attrs = []string{"label=" + strconv.Quote(n.ID+"\n"+depsRepo(r, n.Repo)+"\nto fetch"), "style=dashed"}
+		case n.Repo != "":
+			attrs = []string{"label=" + strconv.Quote(n.ID+"\n"+depsRepo(r, n.Repo))}
+		default:
+			attrs = []string{"label=" + strconv.Quote(n.ID)}
+		}
+		if n.ID == g.Root {
+			attrs = append(attrs, "style=bold")
+		}
+		fmt.Fprintf(&b, "\t%s [%s];\n", strconv.Quote(n.ID), strings.Join(attrs, ", "))
+	}
+	for _, e := range g.Edges {
+		attrs := []string{"label=" + strconv.Quote(e.Constraint)}
+		if e.Cycle {
+			attrs = append(attrs, "color=red", "fontcolor=red", "penwidth=2")
+		}
+		fmt.Fprintf(&b, "\t%s -> %s [%s];\n", strconv.Quote(e.From), strconv.Quote(e.To), strings.Join(attrs, ", "))
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
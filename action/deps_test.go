@@ -0,0 +1,59 @@
+package action
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/helm/helm-classic/test"
+)
+
+func TestDeps(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+	test.FakeUpdate(tmpHome)
+	// The fake repository has no remote, so the chart has no origin to show.
+	Fetch("kitchensink", "", tmpHome, FetchOptions{})
+
+	actual := test.CaptureOutput(func() {
+		Deps("kitchensink", tmpHome, "")
+	})
+	test.ExpectContains(t, actual, "kitchensink@0.0.1\n  bogodep@~10.21 (missing) ~10.21\n")
+
+	actual = test.CaptureOutput(func() {
+		Deps("kitchensink", tmpHome, "dot")
+	})
+	test.ExpectContains(t, actual, `digraph "kitchensink@0.0.1" {`)
+	test.ExpectContains(t, actual, `"bogodep@~10.21" [label="bogodep@~10.21\nmissing", style=dashed, color=red, fontcolor=red];`)
+	test.ExpectContains(t, actual, `"kitchensink@0.0.1" [label="kitchensink@0.0.1", style=bold];`)
+	test.ExpectContains(t, actual, `"kitchensink@0.0.1" -> "bogodep@~10.21" [label="~10.21"];`)
+
+	actual = test.CaptureOutput(func() {
+		Deps("kitchensink", tmpHome, "json")
+	})
+	test.ExpectContains(t, actual, `"root": "kitchensink@0.0.1"`)
+	test.ExpectContains(t, actual, `"missing": true`)
+}
+
+func TestDepsToFetch(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+	test.FakeUpdate(tmpHome)
+
+	// deptest is only in the cache, and so is kitchensink, which it depends on.
+	actual := test.CaptureOutput(func() {
+		Deps("charts/deptest", tmpHome, "")
+	})
+	test.ExpectContains(t, actual, "deptest@2.3.4\n")
+	test.ExpectContains(t, actual, "  kitchensink@0.0.1 [charts] (to fetch) *\n    bogodep@~10.21 (missing) ~10.21\n")
+	test.ExpectContains(t, actual, "  dep1@1.2.3 [charts] (to fetch) ~1.2\n")
+	test.ExpectContains(t, actual, "  dep3@<=2.0 (missing) <=2.0\n")
+	if _, err := os.Stat(filepath.Join(tmpHome, "workspace", "charts", "kitchensink")); err == nil {
+		t.Errorf("Expected kitchensink not to be fetched")
+	}
+
+	actual = test.CaptureOutput(func() {
+		Deps("charts/deptest", tmpHome, "dot")
+	})
+	test.ExpectContains(t, actual, `"kitchensink@0.0.1" [label="kitchensink@0.0.1\ncharts\nto fetch", style=dashed];`)
+}
@@ -0,0 +1,140 @@
+package action
+
+import (
+	"fmt"
+
+	"github.com/helm/helm-classic/chart"
+	"github.com/helm/helm-classic/kubectl"
+	"github.com/helm/helm-classic/kubediff"
+	"github.com/helm/helm-classic/log"
+	"github.com/helm/helm-classic/manifest"
+	"github.com/helm/helm-classic/output"
+	helm "github.com/helm/helm-classic/util"
+)
+
+// Resource statuses in a ResourceDiff.
+const (
+	// ResourceUnchanged is a resource that Kubernetes runs as its manifest
+	// has it.
+	ResourceUnchanged = "unchanged"
+	// ResourceChanged is a resource whose manifest would change it.
+	ResourceChanged = "changed"
+	// ResourceNotInstalled is a manifest that Kubernetes has no resource of.
+	ResourceNotInstalled = "not-installed"
+)
+
+// resourceColors are the colors of the resource statuses on a terminal.
+var resourceColors = map[string]output.Color{
+	ResourceUnchanged:    output.Green,
+	ResourceChanged:      output.Yellow,
+	ResourceNotInstalled: output.Red,
+}
+
+// ResourceDiff is the difference between a resource in Kubernetes and its
+// manifest in a workspace chart.
+type ResourceDiff struct {
+	Kind string
+	Name string
+	// Status is ResourceUnchanged, ResourceChanged, or ResourceNotInstalled.
+	Status string
+	// Diff is a unified diff from the resource to the manifest, as
+	// kubediff.Diff makes it. It is empty for an unchanged resource.
+	Diff string
+}
+
+// Diff prints a unified diff from each resource in Kubernetes to its
+// manifest in a workspace chart.
+//
+// - chartName is the name of the chart in the workspace
+// - home is the home directory for the user
+// - namespace is the namespace of the resources that do not name theirs
+//
+// On a terminal, the statuses and the diffs are colored.
+func Diff(chartName, home, namespace string, client kubectl.Runner) error {
+	CheckClientPrereqs(client)
+	c := newClient(home, client)
+	c.Config = mustConfig(home)
+	diffs, err := c.Diff(chartName, namespace)
+	if err != nil {
+		return err
+	}
+
+	p := output.New(log.Stdout)
+	changed := 0
+	for _, d := range diffs {
+		log.Msg("\t%s %s/%s", p.Paint(resourceColors[d.Status], fmt.Sprintf("%-13s", d.Status)), d.Kind, d.Name)
+		if d.Status != ResourceUnchanged {
+			changed++
+		}
+	}
+	for _, d := range diffs {
+		p.Diff(d.Diff)
+	}
+	if changed == 0 {
+		log.Info("Kubernetes runs the %d resources of %s as the workspace has them.", len(diffs), chartName)
+	} else {
+		log.Info("%d of the %d resources of %s differ from the workspace.", changed, len(diffs), chartName)
+	}
+	return nil
+}
+
+// Diff compares each manifest of a workspace chart with its resource in
+// Kubernetes, in the order in which they are installed. Manifests without a
+// name are left out.
+//
+// The resources are normalized first, as kubediff.Normalize does: only the
+// fields that the manifest sets are compared. The annotations that install
+// adds are left out, so that a resource that was installed from the chart is
+// unchanged.
+func (c *Client) Diff(chartName, namespace string) ([]*ResourceDiff, error) {
+	if !chartFetched(chartName, c.Home, c.Log) {
+		return nil, fmt.Errorf("No chart named %q in your workspace.", chartName)
+	}
+	ch, err := chart.Load(helm.WorkspaceChartDirectory(c.Home, chartName))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load chart: %s", err)
+	}
+
+	res := []*ResourceDiff{}
+	for _, m := range installManifests(ch, nil) {
+		if m.Name == "" || hookOf(m) == manifest.HookPreDelete {
+			continue
+		}
+		data, err := m.VersionedObject.JSON()
+		if err != nil {
+			return nil, fmt.Errorf("Could not encode %s %s: %s", m.Kind, m.Name, err)
+		}
+		desired, err := kubediff.Decode(data)
+		if err != nil {
+			return nil, fmt.Errorf("Could not read %s %s: %s", m.Kind, m.Name, err)
+		}
+
+		ns := namespace
+		if meta, err := m.VersionedObject.Meta(); err == nil && meta.Namespace != "" {
+			ns = meta.Namespace
+		}
+		d := &ResourceDiff{Kind: m.Kind, Name: m.Name, Status: ResourceChanged}
+		var live map[string]interface{}
+		out, err := c.Kube.GetObject(m.Name, m.Kind, ns)
+		switch {
+		case err != nil && kubectl.IsNotFound(out):
+			d.Status = ResourceNotInstalled
+		case err != nil:
+			return nil, fmt.Errorf("Could not get %s %s: %s", m.Kind, m.Name, failure(out, err))
+		default:
+			if live, err = kubediff.Decode(out); err != nil {
+				return nil, fmt.Errorf("Could not read %s %s from Kubernetes: %s", m.Kind, m.Name, err)
+			}
+		}
+
+		label := m.Kind + "/" + m.Name
+		if d.Diff, err = kubediff.Diff(live, desired, "live/"+label, chartName+"/"+label); err != nil {
+			return nil, fmt.Errorf("Could not diff %s: %s", label, err)
+		}
+		if d.Diff == "" {
+			d.Status = ResourceUnchanged
+		}
+		res = append(res, d)
+	}
+	return res, nil
+}
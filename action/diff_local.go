@@ -0,0 +1,285 @@
+package action
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+
+	"github.com/helm/helm-classic/chart"
+	"github.com/helm/helm-classic/config"
+	helmerrors "github.com/helm/helm-classic/errors"
+	"github.com/helm/helm-classic/generator"
+	"github.com/helm/helm-classic/log"
+	"github.com/helm/helm-classic/output"
+	helm "github.com/helm/helm-classic/util"
+)
+
+// File statuses in a ChartDiff.
+const (
+	// FileAdded is a file that only the workspace chart has.
+	FileAdded = "added"
+	// FileRemoved is a file that only the cached chart has.
+	FileRemoved = "removed"
+	// FileModified is a file whose contents differ.
+	FileModified = "modified"
+)
+
+// fileColors are the colors of the file statuses on a terminal.
+var fileColors = map[string]output.Color{
+	FileAdded:    output.Green,
+	FileRemoved:  output.Red,
+	FileModified: output.Yellow,
+}
+
+// ChartDiff is the difference between a workspace chart and the cached chart
+// it was fetched from.
+type ChartDiff struct {
+	// Chart is the name of the chart in the workspace.
+	Chart string
+	// Source is the cached chart, as repo/chart.
+	Source string
+	// Fetched is the version of the chart when it was fetched.
+	Fetched string
+	// Version is the version of the cached chart. If it is not Fetched, the
+	// repository has changed the chart since, and some differences may be
+	// its own.
+	Version string
+	// Files are the files that differ, sorted by path.
+	Files []*FileDiff
+}
+
+// FileDiff is a file that differs between a workspace chart and the cached chart.
+type FileDiff struct {
+	// Path is relative to the chart, with slashes.
+	Path string
+	// Status is FileAdded, FileRemoved, or FileModified.
+	Status string
+	// Diff is a unified diff from the cached file to the workspace file. It
+	// is empty for binary files.
+	Diff string
+}
+
+// DiffLocal lists the local modifications of a workspace chart.
+//
+// - chartName is the name of the chart in the workspace
+// - homedir is the home directory for the user
+// - unified prints a unified diff of each modified file
+//
+// On a terminal, the statuses and the unified diffs are colored.
+func DiffLocal(chartName, homedir string, unified bool) {
+	c := newClient(homedir, nil)
+	c.Config = mustConfig(homedir)
+	d, err := c.DiffLocal(chartName)
+	if err != nil {
+		log.Die("%s", err)
+	}
+	d.print(c.Log, unified)
+}
+
+// print lists the files that differ, with a unified diff of each if unified
+// is true.
+func (d *ChartDiff) print(l *log.Logger, unified bool) {
+	if d.Version != d.Fetched {
+		l.Warn("%s was fetched at version %s, but %s is now at version %s. Some differences may come from the repository.", d.Chart, d.Fetched, d.Source, d.Version)
+	}
+	if len(d.Files) == 0 {
+		l.Info("Chart %s has no local modifications to %s.", d.Chart, d.Source)
+		return
+	}
+	l.Info("Chart %s differs from %s:", d.Chart, d.Source)
+	p := output.New(l.Out())
+	for _, f := range d.Files {
+		l.Msg("\t%s %s", p.Paint(fileColors[f.Status], fmt.Sprintf("%-8s", f.Status)), f.Path)
+	}
+	if unified {
+		for _, f := range d.Files {
+			if f.Diff == "" {
+				l.Msg("Binary files %s differ", f.Path)
+			} else {
+				p.Diff(f.Diff)
+			}
+		}
+	}
+}
+
+// DiffLocal compares a workspace chart with the cached chart it was fetched
+// from.
+//
+// The cached chart is fetched into a temporary directory first, so that its
+// Chart.yaml has the same name and origin as the workspace copy. Files that
+// the workspace chart's .helmignore excludes are not compared, and neither
+// are the files that its generators wrote.
+func (c *Client) DiffLocal(chartName string) (*ChartDiff, error) {
+	dir := helm.WorkspaceChartDirectory(c.Home, chartName)
+	cf, err := chart.LoadChartfile(filepath.Join(dir, Chartfile))
+	if err != nil {
+		return nil, fmt.Errorf("Could not find chart %s in the workspace: %s", chartName, err)
+	}
+	if cf.From == nil {
+		return nil, fmt.Errorf("Chart %s was not fetched from a repository, so it has nothing to be compared with.", chartName)
+	}
+
+	cfg, err := c.config()
+	if err != nil {
+		return nil, err
+	}
+	repo, name, err := cachedSource(cfg.Repos, cf.From, chartName)
+	if err != nil {
+		return nil, err
+	}
+	src := helm.CacheDirectory(c.Home, repo, name)
+	sc, err := chart.LoadChartfile(filepath.Join(src, Chartfile))
+	if err != nil {
+		return nil, err
+	}
+
+	tmp, cleanup, err := helm.TempDir("", "helmc-diff")
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	if _, err := chart.CopyFiles(src, tmp, chart.Limits{}, false); err != nil {
+		return nil, fmt.Errorf("Failed copying %s to %s: %s", src, tmp, err)
+	}
+	if err := writeFetchedChartfile(src, tmp, chartName, cf.From.Repo); err != nil {
+		return nil, err
+	}
+
+	files, err := diffCharts(tmp, dir, repo+"/"+name, chartName)
+	if err != nil {
+		return nil, err
+	}
+	return &ChartDiff{
+		Chart:   chartName,
+		Source:  repo + "/" + name,
+		Fetched: cf.From.Version,
+		Version: sc.Version,
+		Files:   files,
+	}, nil
+}
+
+// cachedSource finds the cached chart that a workspace chart, lname, was
+// fetched from, and returns its repository and name.
+//
+// The repositories whose URL is the origin that from records are searched
+// first, or all of them if none is. A chart is looked for under its base
+// name and under lname, since the directory of a chart need not be its name.
+func cachedSource(r *config.Repos, from *chart.Dependency, lname string) (string, string, error) {
+	var tables []*config.Table
+	for _, t := range r.Tables {
+		if from.Repo != "" && t.Repo == from.Repo {
+			tables = append(tables, t)
+		}
+	}
+	if len(tables) == 0 {
+		tables = r.Tables
+	}
+	searched := make([]string, len(tables))
+	for i, t := range tables {
+		searched[i] = t.Name
+		for _, name := range []string{from.Name, lname} {
+			sc, err := chart.LoadChartfile(filepath.Join(r.Dir, t.Name, name, Chartfile))
+			if err == nil && sc.Name == from.Name {
+				return t.Name, name, nil
+			}
+		}
+	}
+	return "", "", &helmerrors.ChartNotFoundError{Name: from.Name, Repos: searched}
+}
+
+// diffCharts lists the files that differ between the chart in base and the
+// chart in dir, which are labelled baseName and name in the diffs. The
+// .helmignore of dir applies to both, and the files that generators wrote,
+// as the generator.StateFile of either chart records them, are left out.
+func diffCharts(base, dir, baseName, name string) ([]*FileDiff, error) {
+	ig, err := chart.LoadIgnore(dir)
+	if err != nil {
+		return nil, err
+	}
+	bfiles, err := chart.Files(base, ig)
+	if err != nil {
+		return nil, err
+	}
+	files, err := chart.Files(dir, ig)
+	if err != nil {
+		return nil, err
+	}
+	generated, err := generatedFiles(base, dir)
+	if err != nil {
+		return nil, err
+	}
+	for p := range generated {
+		delete(bfiles, p)
+		delete(files, p)
+	}
+
+	res := []*FileDiff{}
+	for p, sum := range files {
+		switch bsum, ok := bfiles[p]; {
+		case !ok:
+			res = append(res, &FileDiff{Path: p, Status: FileAdded})
+		case bsum != sum:
+			res = append(res, &FileDiff{Path: p, Status: FileModified})
+		}
+	}
+	for p := range bfiles {
+		if _, ok := files[p]; !ok {
+			res = append(res, &FileDiff{Path: p, Status: FileRemoved})
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Path < res[j].Path })
+
+	for _, f := range res {
+		a, _ := ioutil.ReadFile(filepath.Join(base, filepath.FromSlash(f.Path)))
+		b, _ := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(f.Path)))
+		if bytes.IndexByte(a, 0) >= 0 || bytes.IndexByte(b, 0) >= 0 {
+			continue
+		}
+		ud := difflib.UnifiedDiff{
+			A:        splitLines(a),
+			B:        splitLines(b),
+			FromFile: baseName + "/" + f.Path,
+			ToFile:   name + "/" + f.Path,
+			Context:  3,
+		}
+		if f.Status == FileAdded {
+			ud.A, ud.FromFile = nil, "/dev/null"
+		} else if f.Status == FileRemoved {
+			ud.B, ud.ToFile = nil, "/dev/null"
+		}
+		if f.Diff, err = difflib.GetUnifiedDiffString(ud); err != nil {
+			return nil, fmt.Errorf("Could not diff %s: %s", f.Path, err)
+		}
+	}
+	return res, nil
+}
+
+// generatedFiles returns the generator.StateFile and the outputs that it
+// records in each of the charts in dirs, by path relative to the chart.
+func generatedFiles(dirs ...string) (map[string]bool, error) {
+	res := map[string]bool{generator.StateFile: true}
+	for _, dir := range dirs {
+		state, err := generator.LoadState(dir)
+		if err != nil {
+			return nil, fmt.Errorf("Could not read the %s of %s: %s", generator.StateFile, dir, err)
+		}
+		for p := range state {
+			res[p] = true
+		}
+	}
+	return res, nil
+}
+
+// splitLines splits a file into lines for a diff, keeping their line endings.
+func splitLines(b []byte) []string {
+	lines := strings.SplitAfter(string(b), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
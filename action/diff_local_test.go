@@ -0,0 +1,84 @@
+package action
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/helm/helm-classic/chart"
+	"github.com/helm/helm-classic/generator"
+	"github.com/helm/helm-classic/test"
+	"github.com/helm/helm-classic/util"
+)
+
+func TestDiffLocal(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+	test.FakeUpdate(tmpHome)
+	Fetch("redis", "", tmpHome, FetchOptions{})
+
+	actual := test.CaptureOutput(func() {
+		DiffLocal("redis", tmpHome, false)
+	})
+	test.ExpectContains(t, actual, "Chart redis has no local modifications to charts/redis.")
+
+	dir := util.WorkspaceChartDirectory(tmpHome, "redis")
+	ioutil.WriteFile(filepath.Join(dir, "notes.txt"), []byte("Mine.\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "notes.txt~"), []byte("Mine\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, chart.IgnoreFile), []byte("*~\n"), 0644)
+	pod := filepath.Join(dir, "manifests", "redis-pod.yaml")
+	b, _ := ioutil.ReadFile(pod)
+	ioutil.WriteFile(pod, []byte(strings.Replace(string(b), "redis", "valkey", 1)), 0644)
+
+	actual = test.CaptureOutput(func() {
+		DiffLocal("redis", tmpHome, true)
+	})
+	test.ExpectContains(t, actual, "Chart redis differs from charts/redis:")
+	test.ExpectContains(t, actual, "added    .helmignore")
+	test.ExpectContains(t, actual, "added    notes.txt")
+	test.ExpectContains(t, actual, "modified manifests/redis-pod.yaml")
+	test.ExpectContains(t, actual, "--- charts/redis/manifests/redis-pod.yaml\n+++ redis/manifests/redis-pod.yaml")
+	test.ExpectContains(t, actual, "-  name: redis\n+  name: valkey\n")
+	test.ExpectContains(t, actual, "+++ redis/notes.txt\n@@ -0,0 +1 @@\n+Mine.\n")
+	if strings.Contains(actual, "notes.txt~") {
+		t.Errorf("Expected files excluded by .helmignore to be skipped, got %s", actual)
+	}
+
+	// Fetching over local modifications needs --force.
+	test.CaptureOutput(func() {
+		if err := Fetch("redis", "", tmpHome, FetchOptions{}); err == nil || !strings.Contains(err.Error(), "--force") {
+			t.Errorf("Expected fetching over local modifications to fail, got %v", err)
+		}
+	})
+	if _, err := os.Stat(filepath.Join(dir, "notes.txt")); err != nil {
+		t.Errorf("Expected the local modifications to be kept: %s", err)
+	}
+}
+
+func TestDiffLocalGenerated(t *testing.T) {
+	ch := "generate"
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+	test.FakeUpdate(tmpHome)
+	Fetch(ch, ch, tmpHome, FetchOptions{})
+	dir := util.WorkspaceChartDirectory(tmpHome, ch)
+
+	// The outputs of the generators, and their state, are not local modifications.
+	Generate(ch, tmpHome, GenerateOptions{Exclude: []string{"ignore"}, Force: true})
+	if _, err := os.Stat(filepath.Join(dir, generator.StateFile)); err != nil {
+		t.Fatalf("Expected the generators to record their outputs: %s", err)
+	}
+	actual := test.CaptureOutput(func() {
+		DiffLocal(ch, tmpHome, false)
+	})
+	test.ExpectContains(t, actual, "Chart generate has no local modifications to charts/generate.")
+
+	// So fetching the chart again does not need --force.
+	test.CaptureOutput(func() {
+		if err := Fetch(ch, ch, tmpHome, FetchOptions{}); err != nil {
+			t.Errorf("Expected a generated chart to be replaced, got %s", err)
+		}
+	})
+}
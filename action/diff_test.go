@@ -0,0 +1,40 @@
+package action
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/helm/helm-classic/kubectl"
+	"github.com/helm/helm-classic/test"
+)
+
+func TestDiff(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+	test.FakeUpdate(tmpHome)
+	Fetch("redis", "", tmpHome, FetchOptions{})
+
+	tests := []struct {
+		out      string
+		err      error
+		expected string
+	}{
+		{`{"apiVersion": "v1", "kind": "Pod", "metadata": {"name": "redis", "uid": "1", "annotations": {"chart.helm.sh/version": "0.1.0"}}, "spec": {"containers": [{"name": "redis"}], "restartPolicy": "Never", "image": "redis", "dnsPolicy": "ClusterFirst"}, "status": {}}`, nil, "unchanged"},
+		{`{"apiVersion": "v1", "kind": "Pod", "metadata": {"name": "redis"}, "spec": {"containers": [{"name": "redis"}], "restartPolicy": "Always", "image": "redis"}}`, nil, "-  restartPolicy: Always\n+  restartPolicy: Never\n"},
+		{`Error from server: pods "redis" not found`, errors.New("exit status 1"), "not-installed"},
+	}
+	for _, tt := range tests {
+		actual := test.CaptureOutput(func() {
+			if err := Diff("redis", tmpHome, "default", &kubectl.FakeRunner{Out: []byte(tt.out), Err: tt.err}); err != nil {
+				t.Error(err)
+			}
+		})
+		test.ExpectContains(t, actual, tt.expected)
+	}
+
+	if err := Diff("redis", tmpHome, "default", &kubectl.FakeRunner{Out: []byte("Unable to connect to the server"), Err: errors.New("exit status 1")}); err == nil || !strings.Contains(err.Error(), "Unable to connect") {
+		t.Errorf("Expected kubectl to fail, got %v", err)
+	}
+}
@@ -1,6 +1,12 @@
 // Package action provides implementations for each Helm Classic command.
 //
-// This is not intended to be a stand-alone library, as
-// many of the commands will write to output, and even os.Exit
-// when things go wrong.
+// The package-level functions are the commands of helmc. Many of them write
+// to the terminal, and even exit the process when things go wrong.
+//
+// Programs that embed Helm Classic use a Client instead. Its Fetch,
+// Generate, Lint, and Install operations take every dependency, from the
+// home directory to the Kubernetes client and the logger, from the Client,
+// and return their results and errors rather than exiting. Their progress,
+// such as each generator that runs and each manifest that is applied, is
+// given to the Client's Events listener as typed events.
 package action
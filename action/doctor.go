@@ -1,19 +1,275 @@
 package action
 
 import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
 
+	"github.com/helm/helm-classic/config"
+	"github.com/helm/helm-classic/generator"
+	"github.com/helm/helm-classic/helmpath"
+	"github.com/helm/helm-classic/kubectl"
 	"github.com/helm/helm-classic/log"
 	helm "github.com/helm/helm-classic/util"
 )
 
-// Doctor helps you see what's wrong with your Helm Classic setup
-func Doctor(home string) {
-	log.Info("Checking things locally...")
-	CheckLocalPrereqs(home)
-	CheckKubePrereqs()
+// Doctor checks, in the order they are run.
+const (
+	// DoctorHome is whether the parts of the home can be written, and the
+	// configuration file read.
+	DoctorHome = "home"
+	// DoctorTools is whether the programs that helmc runs itself are found.
+	DoctorTools = "tools"
+	// DoctorKubectl is the kubectl binary, and whether its version is close
+	// enough to that of the cluster.
+	DoctorKubectl = "kubectl"
+	// DoctorCluster is whether Kubernetes can be reached.
+	DoctorCluster = "cluster"
+	// DoctorRepositories is whether each repository can be reached.
+	DoctorRepositories = "repositories"
+	// DoctorGenerators is whether the programs that the generators of the
+	// workspace charts run are found.
+	DoctorGenerators = "generators"
+)
+
+// LevelOK is the level of a check that passed, which only Doctor reports.
+const LevelOK = "ok"
+
+// DoctorFinding is the outcome of a check of Doctor.
+type DoctorFinding struct {
+	Check   string `json:"check"`
+	Level   string `json:"level"`
+	Message string `json:"message"`
+	// Fix says how to fix a problem. It is empty for a check that passed.
+	Fix string `json:"fix,omitempty"`
+}
+
+// DoctorResult is the outcome of the checks of Doctor.
+type DoctorResult struct {
+	Findings []*DoctorFinding `json:"findings"`
+}
+
+func (r *DoctorResult) add(check, level, fix, format string, v ...interface{}) {
+	r.Findings = append(r.Findings, &DoctorFinding{Check: check, Level: level, Message: fmt.Sprintf(format, v...), Fix: fix})
+}
+
+// count returns the number of findings of a level.
+func (r *DoctorResult) count(level string) int {
+	n := 0
+	for _, f := range r.Findings {
+		if f.Level == level {
+			n++
+		}
+	}
+	return n
+}
+
+// print prints the findings as a table, with the fix of each problem below
+// it, or as JSON if format is "json".
+func (r *DoctorResult) print(l *log.Logger, format string) error {
+	switch format {
+	case "json":
+		b, err := json.MarshalIndent(r, "", "  ")
+		if err != nil {
+			return err
+		}
+		l.Msg(string(b))
+		return nil
+	case "", "table":
+		w := tabwriter.NewWriter(l.Out(), 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "CHECK\tLEVEL\tFINDING")
+		for _, f := range r.Findings {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", f.Check, f.Level, f.Message)
+			if f.Fix != "" {
+				fmt.Fprintf(w, "\t\tFix: %s\n", f.Fix)
+			}
+		}
+		w.Flush()
+		l.Msg("%d errors, %d warnings", r.count(LevelError), r.count(LevelWarning))
+		return nil
+	}
+	return fmt.Errorf("unknown output format %q", format)
+}
+
+// Doctor checks the setup of Helm Classic, and reports what it finds, with
+// how to fix each problem, as a table or, if output is "json", as JSON.
+//
+// The home must be writable, and its configuration readable. kubectl must be
+// found, if client runs it, and be within one minor version of the cluster,
+// which must be reachable. Every repository must be reachable, unless
+// Defaults.Offline is set, and in the cache. And the programs that the
+// generators of the workspace charts run, as their 'helm:generate' headers
+// declare them, must be found.
+//
+// An error is returned if a check found an error.
+func Doctor(home, output string, client kubectl.Runner) error {
+	c := newClient(home, client)
+	if output != "json" {
+		c.Log.Info(buildInfo())
+	}
+	res := c.Doctor()
+	if err := res.print(c.Log, output); err != nil {
+		return err
+	}
+	if n := res.count(LevelError); n > 0 {
+		return fmt.Errorf("helmc doctor found %d problems. Fix them as suggested above, and run it again.", n)
+	}
+	if output != "json" {
+		c.Log.Info("Everything looks good! Happy helming!")
+	}
+	return nil
+}
+
+// Doctor runs the checks of the package-level Doctor.
+func (c *Client) Doctor() *DoctorResult {
+	res := &DoctorResult{Findings: []*DoctorFinding{}}
+	cfg := c.doctorHome(res)
+	c.doctorTools(res)
+	c.doctorCluster(res)
+	if cfg != nil {
+		c.doctorRepositories(cfg, res)
+		c.doctorGenerators(cfg, res)
+	}
+	return res
+}
+
+// doctorHome checks that the parts of the home can be written, and returns
+// its configuration, or nil if it cannot be read.
+func (c *Client) doctorHome(res *DoctorResult) *config.Configfile {
+	h := helmpath.Home(c.Home)
+	fix := "Make it writable, or choose another home with --home or $HELMC_HOME ('helmc home --paths' prints where each part is)."
+	if _, err := h.Ensure(); err != nil {
+		res.add(DoctorHome, LevelError, fix, "Could not create the home %s: %s", h, err)
+		return nil
+	}
+	l := h.Layout()
+	ok := true
+	for _, dir := range []string{h.String(), filepath.Dir(l.Config), l.Cache, l.Workspace} {
+		f, err := ioutil.TempFile(dir, ".doctor-")
+		if err != nil {
+			res.add(DoctorHome, LevelError, fix, "%s is not writable: %s", dir, err)
+			ok = false
+			continue
+		}
+		f.Close()
+		os.Remove(f.Name())
+	}
+	cfg, err := c.config()
+	if err != nil {
+		res.add(DoctorHome, LevelError, "Correct the file, or move it away so that a new one is written.", "%s", err)
+		return nil
+	}
+	if ok {
+		res.add(DoctorHome, LevelOK, "", "The home %s is writable, and its configuration %s is valid.", h, l.Config)
+	}
+	return cfg
+}
+
+// doctorTools checks that git is found, unless the native Git backend is the
+// default.
+func (c *Client) doctorTools(res *DoctorResult) {
+	if c.GitBackend == config.BackendNative {
+		return
+	}
+	path, err := exec.LookPath("git")
+	if err != nil {
+		res.add(DoctorTools, LevelError, "Install git, or use the Git backend built into helmc with --git-backend native.", "git is not on $PATH: %s", err)
+		return
+	}
+	res.add(DoctorTools, LevelOK, "", "git is %s.", path)
+}
+
+// doctorCluster checks the kubeconfig, kubectl if the client runs it, and
+// that Kubernetes can be reached, with a kubectl within one minor version of
+// it.
+func (c *Client) doctorCluster(res *DoctorResult) {
+	_, exe := c.Kube.(kubectl.RealRunner)
+	if exe {
+		path, v, err := c.kube().CheckBinary()
+		if err != nil {
+			res.add(DoctorKubectl, LevelError, fmt.Sprintf("Install kubectl %s or later, or give its location with --kubectl-path or $HELMC_KUBECTL.", kubectl.MinVersion), "%s", err)
+			return
+		}
+		res.add(DoctorKubectl, LevelOK, "", "kubectl %s is %s.", v, path)
+	}
+
+	if err := c.kube().CheckKubeconfig(); err != nil {
+		res.add(DoctorCluster, LevelError, "Point $KUBECONFIG, or --kubeconfig, at a readable kubeconfig file.", "Could not read kubeconfig: %s", err)
+		return
+	}
+	ctx := c.kube().ActiveContext()
+	vs, err := kubectl.ClusterVersions(c.Kube)
+	if err != nil {
+		res.add(DoctorCluster, LevelError, "Check that the cluster is up, and that you can log into it; choose another context with --kube-context ('kubectl config get-contexts' lists them).", "Kubernetes cannot be reached with the context %s: %s", ctx, err)
+		return
+	}
+	res.add(DoctorCluster, LevelOK, "", "Kubernetes %s is reachable with the context %s.", vs.Server, ctx)
+	if exe && vs.Client != nil && vs.Server != nil {
+		skew := vs.Client.Minor() - vs.Server.Minor()
+		if skew < 0 {
+			skew = -skew
+		}
+		if vs.Client.Major() != vs.Server.Major() || skew > 1 {
+			res.add(DoctorKubectl, LevelWarning, "Install a kubectl within one minor version of the cluster, or give its location with --kubectl-path.", "kubectl %s is more than one minor version from Kubernetes %s, which is more skew than kubectl supports.", vs.Client, vs.Server)
+		}
+	}
+}
 
-	log.Info("Everything looks good! Happy helming!")
+// doctorRepositories checks that each repository of cfg can be reached, and
+// is in the cache.
+func (c *Client) doctorRepositories(cfg *config.Configfile, res *DoctorResult) {
+	if c.Offline {
+		res.add(DoctorRepositories, LevelWarning, "Run 'helmc doctor' without --offline to check them.", "The repositories are not checked, since --offline is set.")
+		return
+	}
+	for _, t := range cfg.Repos.Tables {
+		if err := cfg.Repos.Verify(t); err != nil {
+			res.add(DoctorRepositories, LevelError, fmt.Sprintf("Check its URL with 'helmc repository list', and your network. A private repository needs a token: 'helmc repository login %s'.", t.Name), "Repository %s cannot be reached: %s", t.Name, err)
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(cfg.Repos.Dir, t.Name)); err != nil {
+			res.add(DoctorRepositories, LevelWarning, "Download it with 'helmc update'.", "Repository %s is reachable, but not in the cache.", t.Name)
+			continue
+		}
+		res.add(DoctorRepositories, LevelOK, "", "Repository %s is reachable, at %s.", t.Name, helm.Redact(t.Repo))
+	}
+}
+
+// doctorGenerators checks that the programs that the generators of each
+// workspace chart run are found.
+func (c *Client) doctorGenerators(cfg *config.Configfile, res *DoctorResult) {
+	files, _ := filepath.Glob(helm.WorkspaceChartDirectory(c.Home, "*", Chartfile))
+	sort.Strings(files)
+	programs, charts, missing := 0, 0, 0
+	for _, f := range files {
+		dir := filepath.Dir(f)
+		name := filepath.Base(dir)
+		env := generateEnv(c.Home, name, dir, cfg.Repos.Default, false, false, ValueSources{})
+		tools, err := generator.Tools(dir, nil, env)
+		if err != nil {
+			res.add(DoctorGenerators, LevelWarning, fmt.Sprintf("Check the headers with 'helmc generate --dry-run %s'.", name), "Could not read the generators of %s: %s", name, err)
+			continue
+		}
+		if len(tools) > 0 {
+			charts++
+		}
+		for _, t := range tools {
+			programs++
+			if t.Path == "" {
+				missing++
+				res.add(DoctorGenerators, LevelError, fmt.Sprintf("Install %s, or skip the files that need it with 'helmc generate --exclude'.", t.Name), "The generators of %s run %s, which is not found (%s).", name, t.Name, strings.Join(t.Files, ", "))
+			}
+		}
+	}
+	if programs > 0 && missing == 0 {
+		res.add(DoctorGenerators, LevelOK, "", "The %d programs that the generators of %d charts run are found.", programs, charts)
+	}
 }
 
 // CheckAllPrereqs makes sure we have all the tools we need for overall
@@ -26,14 +282,47 @@ func CheckAllPrereqs(home string) {
 // CheckKubePrereqs makes sure we have the tools necessary to interact
 // with a kubernetes cluster
 func CheckKubePrereqs() {
-	ensureCommand("kubectl")
+	path, v := ensureKubectl(&kubectl.Defaults)
+	log.Debug("Using kubectl %s at %s", v, path)
+}
+
+// ensureKubectl finds the kubectl of s and checks its version, or dies trying.
+func ensureKubectl(s *kubectl.Settings) (string, string) {
+	path, v, err := s.CheckBinary()
+	if err != nil {
+		log.Die("%s", err)
+	}
+	return path, v.String()
+}
+
+// CheckClientPrereqs makes sure the client can be used, and reports the
+// context it will change. Only the kubectl clients need the binary.
+//
+// An unreadable kubeconfig stops the command, unless it is a dry run.
+func CheckClientPrereqs(client kubectl.Runner) {
+	s := kubectl.SettingsOf(client)
+	if _, dry := client.(kubectl.PrintRunner); !dry {
+		if err := s.CheckKubeconfig(); err != nil {
+			log.Die("Could not read kubeconfig: %s", err)
+		}
+		log.Info("Using Kubernetes context %s", s.ActiveContext())
+	}
+	switch client.(type) {
+	case kubectl.RealRunner, kubectl.PrintRunner:
+		path, v := ensureKubectl(s)
+		log.Debug("Using kubectl %s at %s", v, path)
+	}
 }
 
 // CheckLocalPrereqs makes sure we have all the tools we need to work with
 // charts locally
+//
+// git is not needed if the native Git backend is the default.
 func CheckLocalPrereqs(home string) {
 	helm.EnsureHome(home)
-	ensureCommand("git")
+	if Defaults.GitBackend != config.BackendNative {
+		ensureCommand("git")
+	}
 }
 
 func ensureCommand(command string) {
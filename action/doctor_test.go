@@ -1,10 +1,13 @@
 package action
 
 import (
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/helm/helm-classic/kubectl"
 	"github.com/helm/helm-classic/test"
 	"github.com/helm/helm-classic/util"
 )
@@ -23,3 +26,42 @@ func TestEnsureHome(t *testing.T) {
 	tmpHome := test.CreateTmpHome()
 	util.EnsureHome(tmpHome)
 }
+
+func TestDoctor(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+	test.FakeUpdate(tmpHome)
+	tpl := util.WorkspaceChartDirectory(tmpHome, "gen", "tpl")
+	os.MkdirAll(tpl, 0755)
+	ioutil.WriteFile(filepath.Join(tpl, "..", Chartfile), []byte("name: gen\nversion: 0.1.0\n"), 0644)
+	ioutil.WriteFile(filepath.Join(tpl, "a.yaml"), []byte("#helm:generate helmc-test-missing-tool -o a\n"), 0644)
+	ioutil.WriteFile(filepath.Join(tpl, "b.yaml"), []byte("#helm:generate helm tpl -o b.yaml $HELM_GENERATE_FILE\n"), 0644)
+
+	c := newClient(tmpHome, &kubectl.FakeRunner{})
+	c.Offline = true
+	res := c.Doctor()
+	found := map[string]*DoctorFinding{}
+	for _, f := range res.Findings {
+		found[f.Check+" "+f.Level] = f
+	}
+	if found[DoctorHome+" "+LevelOK] == nil {
+		t.Errorf("Expected the home to be fine, got %v", res.Findings)
+	}
+	if found[DoctorRepositories+" "+LevelWarning] == nil {
+		t.Errorf("Expected the repositories to be skipped offline, got %v", res.Findings)
+	}
+	f := found[DoctorGenerators+" "+LevelError]
+	if f == nil {
+		t.Fatalf("Expected the missing generator program to be found, got %v", res.Findings)
+	}
+	test.ExpectContains(t, f.Message, "helmc-test-missing-tool")
+	test.ExpectContains(t, f.Message, "tpl/a.yaml")
+	if f.Fix == "" {
+		t.Errorf("Expected a fix for the missing program")
+	}
+	for _, f := range res.Findings {
+		if strings.Contains(f.Message, "tpl/b.yaml") {
+			t.Errorf("Expected the templates that helmc renders not to be programs, got %s", f.Message)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package action
+
+import (
+	"fmt"
+
+	"github.com/helm/helm-classic/kubectl"
+	"github.com/helm/helm-classic/manifest"
+)
+
+// DryRunInstall sends a chart's manifests to Kubernetes for validation,
+// without persisting them.
+//
+// This catches problems, such as admission or schema errors, that only the
+// server can detect. Manifests are sent in InstallOrder, and every one is
+// sent even if an earlier one is rejected. If any manifest is rejected,
+// DryRunInstall returns an error after printing the summary.
+//
+// The options are those of Install; Mode, Atomic, Preflight, and Wait are
+// ignored.
+func DryRunInstall(chartName, home string, opts InstallOptions, client kubectl.Runner) error {
+	CheckClientPrereqs(client)
+
+	c := newClient(home, client)
+	c.Config = mustConfig(home)
+	_, err := c.DryRunInstall(chartName, opts)
+	return err
+}
+
+// DryRunInstall is like the package-level DryRunInstall. It returns the
+// outcome for each manifest.
+func (c *Client) DryRunInstall(chartName string, opts InstallOptions) (res *InstallResult, err error) {
+	defer c.completed(OpDryRunInstall, chartName, c.started(OpDryRunInstall, chartName), &err)
+	if opts.Stateless {
+		restore, err := c.useStatelessHome()
+		if err != nil {
+			return nil, err
+		}
+		defer restore()
+	}
+	ch, _, ms, err := c.installPlan(chartName, &opts)
+	if err != nil {
+		return nil, err
+	}
+	restore, err := c.useChartArgs(ch)
+	if err != nil {
+		return nil, err
+	}
+	defer restore()
+	defer c.useRetryEvents()()
+
+	c.Log.Info("Sending manifests to Kubernetes for a server-side dry run ...")
+	res = &InstallResult{Chart: ch.Chartfile.Name, DryRun: true, Resources: []*ResourceResult{}}
+	hooks := make([]string, len(ms))
+	for i, m := range ms {
+		hooks[i] = hookOf(m)
+	}
+	stage := c.hookStages(hooks, false)
+	for i, m := range ms {
+		stage(hooks[i])
+		c.dryRunManifest(m, opts.Namespace, res)
+		res.Resources[len(res.Resources)-1].Hook = hooks[i]
+	}
+	if err := res.print(c.Log, opts.Output); err != nil {
+		c.Log.Err("Could not print dry run summary: %s", err)
+	}
+
+	if n := res.Totals()[StatusRejected]; n > 0 {
+		return res, fmt.Errorf("%d of %d manifests were rejected", n, len(res.Resources))
+	}
+	c.Log.Info("All manifests were accepted. Nothing was changed.")
+	return res, nil
+}
+
+// dryRunManifest sends a single manifest to Kubernetes for validation, recording the outcome on res.
+func (c *Client) dryRunManifest(m *manifest.Manifest, namespace string, res *InstallResult) {
+	rr := newResourceResult(m, namespace, res)
+
+	data, err := m.VersionedObject.JSON()
+	if err != nil {
+		rr.Status = StatusRejected
+		rr.Error = err.Error()
+		return
+	}
+	c.Log.Debug("File: %s", string(data))
+	out, err := c.Kube.DryRun(data, namespace)
+	rr.Status = StatusAccepted
+	if err != nil {
+		rr.Status = StatusRejected
+		rr.Error = failure(out, err)
+	}
+	c.emit(&ManifestApplied{Kind: rr.Kind, Name: rr.Name, Namespace: rr.Namespace, Status: rr.Status, Output: string(out), Err: err})
+}
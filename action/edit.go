@@ -1,43 +1,172 @@
 package action
 
 import (
+	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
 
+	"github.com/helm/helm-classic/chart"
+	"github.com/helm/helm-classic/kubectl"
 	"github.com/helm/helm-classic/log"
 	"github.com/helm/helm-classic/util"
 )
 
+// EditOptions are the options of Edit.
+type EditOptions struct {
+	// Exclude are the files and directories whose generators are not run
+	// again, as in Generate.
+	Exclude []string
+	// NoGenerate does not run the generators again.
+	NoGenerate bool
+	// Yes upgrades an installed chart with the changes without asking, and
+	// NoApply never offers to.
+	Yes, NoApply bool
+}
+
+// EditResult is what an edit changed.
+type EditResult struct {
+	// Changed are the files of the chart that were added, changed, or
+	// removed in the editor, relative to the chart, with slashes, in order.
+	Changed []string
+	// Diff is how the chart now differs from the cached chart it was
+	// fetched from, or nil if it was not fetched.
+	Diff *ChartDiff
+	// Generators is the number of generators that were run again.
+	Generators int
+	// Upgrade is the outcome of the upgrade, if the chart was upgraded.
+	Upgrade *InstallResult
+}
+
 // Edit charts using the shell-defined $EDITOR
 //
 // - chartName being edited
 // - homeDir is the Helm Classic home directory for the user
-func Edit(chartName, homeDir string) {
-
-	chartDir := util.WorkspaceChartDirectory(homeDir, chartName)
+// - opts says what is done with the changes, see Client.Edit
+// - client is how an installed chart is upgraded with them
+func Edit(chartName, homeDir string, opts EditOptions, client kubectl.Runner) {
+	c := newClient(homeDir, client)
+	c.Config = mustConfig(homeDir)
+	if _, err := c.Edit(chartName, opts); err != nil {
+		log.Die("%s", err)
+	}
+}
 
+// Edit opens a workspace chart in $EDITOR, and, when the editor exits, says
+// what changed.
+//
+// The files that were changed are found by comparing their contents before
+// and after, so that a file which is only saved again is not a change. If
+// the chart was fetched, how it now differs from the cached chart is listed,
+// as by DiffLocal. The generators that read a changed file, as
+// 'helmc generate --watch' finds them, are run again. If the chart is
+// installed, the user is then asked whether to upgrade it with the changes,
+// unless opts says otherwise; without a terminal, it is only upgraded with
+// opts.Yes.
+func (c *Client) Edit(chartName string, opts EditOptions) (*EditResult, error) {
+	chartDir := util.WorkspaceChartDirectory(c.Home, chartName)
 	if _, err := os.Stat(chartDir); os.IsNotExist(err) {
-		log.Die("Could not find chart: %s", chartName)
+		return nil, fmt.Errorf("Could not find chart: %s", chartName)
 	}
 
-	openEditor(chartDir)
+	unlock, err := c.lockChart(chartName)
+	if err != nil {
+		return nil, err
+	}
+	before, err := chart.Files(chartDir, nil)
+	if err == nil {
+		err = openEditor(chartDir)
+	}
+	var after map[string]string
+	if err == nil {
+		after, err = chart.Files(chartDir, nil)
+	}
+	unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	res := &EditResult{Changed: changedFiles(before, after)}
+	if len(res.Changed) == 0 {
+		c.Log.Info("No files of %s were changed.", chartName)
+		return res, nil
+	}
+	c.Log.Info("Changed in %s: %s", chartName, strings.Join(res.Changed, ", "))
+	if cf, err := chart.LoadChartfile(filepath.Join(chartDir, Chartfile)); err == nil && cf.From != nil {
+		if res.Diff, err = c.DiffLocal(chartName); err != nil {
+			c.Log.Warn("Could not compare %s with the chart it was fetched from: %s", chartName, err)
+		} else {
+			res.Diff.print(c.Log, false)
+		}
+	}
+
+	if !opts.NoGenerate {
+		w, err := c.Watcher(chartName, GenerateOptions{Exclude: opts.Exclude})
+		if err != nil {
+			return res, err
+		}
+		counts, err := w.RunChanged(util.Context(), res.Changed)
+		res.Generators = counts.Total()
+		if err != nil {
+			return res, fmt.Errorf("Failed to complete generation: %w", err)
+		}
+		if res.Generators > 0 {
+			c.Log.Info("Ran %d generators that read the changed files.", res.Generators)
+		}
+	}
+
+	revs, err := c.History(chartName)
+	if err != nil || len(revs) == 0 {
+		return res, err
+	}
+	apply := opts.Yes
+	if !apply && !opts.NoApply && stdinIsTerminal() {
+		apply = Confirm("%s is installed in namespace %s. Upgrade it with the changes?", chartName, dash(revs[len(revs)-1].Namespace))
+	}
+	if !apply {
+		c.Log.Info("Run 'helmc upgrade %s' to apply the changes to the cluster.", chartName)
+		return res, nil
+	}
+	res.Upgrade, err = c.Upgrade(chartName, InstallOptions{Annotate: true, Labels: true})
+	return res, err
+}
+
+// changedFiles returns the files whose digests differ between before and
+// after, or that only one has, in order.
+func changedFiles(before, after map[string]string) []string {
+	changed := []string{}
+	for f, sum := range after {
+		if before[f] != sum {
+			changed = append(changed, f)
+		}
+	}
+	for f := range before {
+		if _, ok := after[f]; !ok {
+			changed = append(changed, f)
+		}
+	}
+	sort.Strings(changed)
+	return changed
 }
 
 // openEditor opens the given filename in an interactive editor
-func openEditor(path string) {
+func openEditor(path string) error {
 	editor := os.Getenv("EDITOR")
 	if editor == "" {
-		log.Die("must set shell $EDITOR")
+		return fmt.Errorf("must set shell $EDITOR")
 	}
 
 	editorPath, err := exec.LookPath(editor)
 	if err != nil {
-		log.Die("Could not find %s in PATH", editor)
+		return fmt.Errorf("Could not find %s in PATH", editor)
 	}
 
 	cmd := exec.Command(editorPath, path)
 	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
 	if err := cmd.Run(); err != nil {
-		log.Die("Could not open $EDITOR: %s", err)
+		return fmt.Errorf("Could not open $EDITOR: %s", err)
 	}
+	return nil
 }
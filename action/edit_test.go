@@ -1,11 +1,18 @@
 package action
 
 import (
+	"io/ioutil"
 	"os"
 	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
 
+	"github.com/helm/helm-classic/kubectl"
+	"github.com/helm/helm-classic/log"
 	"github.com/helm/helm-classic/test"
+	"github.com/helm/helm-classic/util"
 )
 
 func TestEdit(t *testing.T) {
@@ -17,12 +24,74 @@ func TestEdit(t *testing.T) {
 	defer os.RemoveAll(tmpHome)
 	test.FakeUpdate(tmpHome)
 
-	Fetch("redis", "", tmpHome)
+	Fetch("redis", "", tmpHome, FetchOptions{})
 
 	expected := path.Join(tmpHome, "workspace/charts/redis")
 	actual := test.CaptureOutput(func() {
-		Edit("redis", tmpHome)
+		Edit("redis", tmpHome, EditOptions{}, &kubectl.FakeRunner{})
 	})
 
 	test.ExpectContains(t, actual, expected)
 }
+
+func TestEditChanges(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("The test editor is a shell script")
+	}
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+	test.FakeUpdate(tmpHome)
+
+	kube := &kubectl.FakeRunner{}
+	c := &Client{
+		Home:   tmpHome,
+		Kube:   kube,
+		Log:    &log.Logger{Stdout: ioutil.Discard, Stderr: ioutil.Discard},
+		Events: func(Event) {},
+	}
+	if _, err := c.Fetch("redis", "", FetchOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	dir := util.WorkspaceChartDirectory(tmpHome, "redis")
+	ioutil.WriteFile(filepath.Join(dir, "values.txt"), []byte("one\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "gen.txt"), []byte("#helm:generate cp values.txt copied.txt\n"), 0644)
+	c.AllowGenerators = []string{"cp"}
+	if _, err := c.Install("redis", InstallOptions{Namespace: "cache", Annotate: true, Labels: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The editor adds a service, and changes the values that the generator
+	// reads. Chart.yaml is only saved again.
+	editor := filepath.Join(tmpHome, "editor.sh")
+	svc := "apiVersion: v1\nkind: Service\nmetadata:\n  name: redis\n"
+	ioutil.WriteFile(editor, []byte("#!/bin/sh\nprintf '"+svc+"' > $1/manifests/redis-svc.yaml\necho two > $1/values.txt\ntouch $1/Chart.yaml\n"), 0755)
+	defer os.Setenv("EDITOR", os.Getenv("EDITOR"))
+	os.Setenv("EDITOR", editor)
+
+	kube.Calls = nil
+	res, err := c.Edit("redis", EditOptions{Yes: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.ExpectEquals(t, strings.Join(res.Changed, " "), "manifests/redis-svc.yaml values.txt")
+	if res.Diff == nil || len(res.Diff.Files) != 3 {
+		t.Errorf("Expected the diff with the cached chart to have the new files, got %v", res.Diff)
+	}
+	test.ExpectEquals(t, res.Generators, 1)
+	if b, _ := ioutil.ReadFile(filepath.Join(dir, "copied.txt")); string(b) != "two\n" {
+		t.Errorf("Expected the generator that reads values.txt to run again, got %q", b)
+	}
+	if res.Upgrade == nil || len(kube.Calls) != 1 || kube.Calls[0] != "apply cache" {
+		t.Errorf("Expected only the new service to be applied into cache, got %v", kube.Calls)
+	}
+
+	// Nothing is changed, or upgraded, when the editor changes nothing.
+	os.Setenv("EDITOR", "true")
+	kube.Calls = nil
+	if res, err = c.Edit("redis", EditOptions{Yes: true}); err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Changed) != 0 || res.Upgrade != nil || len(kube.Calls) != 0 {
+		t.Errorf("Expected no changes, got %v and %v", res.Changed, kube.Calls)
+	}
+}
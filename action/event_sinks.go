@@ -0,0 +1,157 @@
+package action
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// EventRecord is an event as the events file and the webhook of Settings
+// give it, as a JSON object. Event says which kind of event it is, and the
+// fields of its kind are set.
+type EventRecord struct {
+	Time time.Time `json:"time"`
+	// Event is the name of the event's type, such as "operationStarted" or
+	// "manifestApplied".
+	Event string `json:"event"`
+
+	Operation string `json:"operation,omitempty"`
+	Chart     string `json:"chart,omitempty"`
+	// Name and Repo are those of a ChartResolved.
+	Name   string `json:"name,omitempty"`
+	Repo   string `json:"repo,omitempty"`
+	Reason string `json:"reason,omitempty"`
+	// File and Command are those of a generator.
+	File    string `json:"file,omitempty"`
+	Command string `json:"command,omitempty"`
+	// Kind, Resource, Namespace, Op, Status, Output, and Printed are those
+	// of a ManifestApplied.
+	Kind      string `json:"kind,omitempty"`
+	Resource  string `json:"resource,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	Op        string `json:"op,omitempty"`
+	Status    string `json:"status,omitempty"`
+	Output    string `json:"output,omitempty"`
+	Printed   bool   `json:"printed,omitempty"`
+	// What, Attempt, Retries, and DelayMs are those of a RetryAttempted.
+	What       string `json:"what,omitempty"`
+	Attempt    int    `json:"attempt,omitempty"`
+	Retries    int    `json:"retries,omitempty"`
+	DelayMs    int64  `json:"delayMs,omitempty"`
+	DurationMs int64  `json:"durationMs,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// NewEventRecord returns the record of an event that happened at t.
+func NewEventRecord(e Event, t time.Time) *EventRecord {
+	r := &EventRecord{Time: t.UTC()}
+	errString := func(err error) string {
+		if err == nil {
+			return ""
+		}
+		return err.Error()
+	}
+	switch e := e.(type) {
+	case *OperationStarted:
+		r.Event, r.Operation, r.Chart = "operationStarted", e.Operation, e.Chart
+	case *ChartResolved:
+		r.Event, r.Name, r.Repo, r.Chart, r.Reason = "chartResolved", e.Name, e.Repo, e.Chart, e.Reason
+	case *GeneratorStarted:
+		r.Event, r.File, r.Command = "generatorStarted", e.File, e.Command
+	case *GeneratorFinished:
+		r.Event, r.File, r.Command, r.Error = "generatorFinished", e.File, e.Command, errString(e.Err)
+		r.DurationMs = e.Duration.Milliseconds()
+	case *ManifestApplied:
+		r.Event, r.Kind, r.Resource, r.Namespace, r.Op = "manifestApplied", e.Kind, e.Name, e.Namespace, e.Op
+		r.Status, r.Output, r.Printed, r.Error = e.Status, e.Output, e.Printed, errString(e.Err)
+	case *RetryAttempted:
+		r.Event, r.What, r.Reason, r.Attempt, r.Retries = "retryAttempted", e.What, e.Reason, e.Attempt, e.Retries
+		r.DelayMs = e.Delay.Milliseconds()
+	case *OperationCompleted:
+		r.Event, r.Operation, r.Chart, r.Error = "operationCompleted", e.Operation, e.Chart, errString(e.Err)
+		r.DurationMs = e.Duration.Milliseconds()
+	default:
+		r.Event = fmt.Sprintf("%T", e)
+	}
+	return r
+}
+
+// webhookTimeout bounds each post of an event to the webhook.
+var webhookTimeout = 5 * time.Second
+
+// record appends an event to the events file, and posts it to the webhook,
+// of the client's settings. Neither can fail the operation: the first
+// failure of each is warned about, and later ones are not.
+func (c *Client) record(e Event) {
+	if c.EventsFile == "" && c.EventsWebhook == "" {
+		return
+	}
+	b, err := json.Marshal(NewEventRecord(e, time.Now()))
+	if err != nil {
+		c.sinkFailure("the events file and webhook", err)
+		return
+	}
+	if c.EventsFile != "" {
+		if err := appendLine(c.EventsFile, b); err != nil {
+			c.sinkFailure(c.EventsFile, err)
+		}
+	}
+	switch {
+	case c.EventsWebhook == "":
+	case c.Offline:
+		c.sinkFailure(c.EventsWebhook, fmt.Errorf("it is not used offline"))
+	default:
+		if err := postEvent(c.EventsWebhook, b); err != nil {
+			c.sinkFailure(c.EventsWebhook, err)
+		}
+	}
+}
+
+// sinkFailure warns that an event could not be recorded in sink, unless it
+// was warned about already.
+func (c *Client) sinkFailure(sink string, err error) {
+	if c.sinkFailed[sink] {
+		return
+	}
+	if c.sinkFailed == nil {
+		c.sinkFailed = map[string]bool{}
+	}
+	c.sinkFailed[sink] = true
+	c.Log.Warn("Could not record the events of helmc in %s: %s", sink, err)
+}
+
+// appendLine appends b and a newline to the file at path, creating it and
+// its directory if necessary. The line is a single write, so that the lines
+// of processes that share the file are not interleaved.
+func appendLine(path string, b []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// postEvent posts the JSON of an event to url.
+func postEvent(url string, b []byte) error {
+	client := &http.Client{Timeout: webhookTimeout}
+	res, err := client.Post(url, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		return fmt.Errorf("it answered %s", res.Status)
+	}
+	return nil
+}
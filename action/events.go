@@ -0,0 +1,194 @@
+package action
+
+import (
+	"time"
+
+	"github.com/helm/helm-classic/generator"
+	"github.com/helm/helm-classic/kubectl"
+	"github.com/helm/helm-classic/log"
+	"github.com/helm/helm-classic/metrics"
+)
+
+// Operations, as OperationCompleted names them.
+const (
+	OpFetch         = "fetch"
+	OpGenerate      = "generate"
+	OpInstall       = "install"
+	OpDryRunInstall = "dry-run install"
+	OpPrune         = "prune"
+	OpRollback      = "rollback"
+	OpTest          = "test"
+	OpUninstall     = "uninstall"
+	OpUpgrade       = "upgrade"
+)
+
+// Event is something that happened during an operation of a Client: one of
+// *OperationStarted, *ChartResolved, *GeneratorStarted, *GeneratorFinished,
+// *ManifestApplied, *RetryAttempted, or *OperationCompleted.
+type Event interface {
+	event()
+}
+
+// Listener receives the events of a Client's operations, in the order they
+// happen, on the goroutine that runs the operation.
+type Listener func(Event)
+
+// OperationStarted is emitted when an operation begins.
+type OperationStarted struct {
+	// Operation is one of the Op constants.
+	Operation string
+	Chart     string
+}
+
+// ChartResolved is emitted when a chart name is resolved to a repository's
+// chart, before it is fetched.
+type ChartResolved struct {
+	// Name is the chart name as it was given.
+	Name  string
+	Repo  string
+	Chart string
+	// Reason tells why the repository was chosen, if the name did not name
+	// it, e.g. "the only repository with the chart".
+	Reason string
+}
+
+// GeneratorStarted is emitted before a generator is executed.
+type GeneratorStarted struct {
+	// File declares the generator.
+	File string
+	// Command is the expanded command.
+	Command string
+}
+
+// GeneratorFinished is emitted after a generator exited.
+type GeneratorFinished struct {
+	File     string
+	Command  string
+	Duration time.Duration
+	// Err is set if the generator failed.
+	Err error
+}
+
+// ManifestApplied is emitted after a manifest was sent to Kubernetes.
+type ManifestApplied struct {
+	Kind      string
+	Name      string
+	Namespace string
+	// Op is the install mode: ModeCreate, ModeApply, or ModeReplace. It is
+	// empty for a server-side dry run.
+	Op string
+	// Status is the outcome, one of the Status constants.
+	Status string
+	// Output is kubectl's output.
+	Output string
+	// Printed is set if the command was only printed, as 'helmc install
+	// --dry-run' does.
+	Printed bool
+	// Err is set if Kubernetes rejected the manifest.
+	Err error
+}
+
+// RetryAttempted is emitted when a request to Kubernetes failed for a
+// transient reason, and is about to be retried. See kubectl.RetryPolicy.
+type RetryAttempted struct {
+	kubectl.Retrying
+}
+
+// OperationCompleted is emitted when an operation ends, whether or not it
+// succeeded.
+type OperationCompleted struct {
+	// Operation is one of the Op constants.
+	Operation string
+	Chart     string
+	Duration  time.Duration
+	Err       error
+}
+
+func (*OperationStarted) event()   {}
+func (*ChartResolved) event()      {}
+func (*GeneratorStarted) event()   {}
+func (*GeneratorFinished) event()  {}
+func (*ManifestApplied) event()    {}
+func (*RetryAttempted) event()     {}
+func (*OperationCompleted) event() {}
+
+// LogEvents returns the listener that helmc uses: it renders each event on l
+// as a message, as helmc always has. If l is nil, the package-level log
+// functions are used.
+func LogEvents(l *log.Logger) Listener {
+	return func(e Event) {
+		switch e := e.(type) {
+		case *OperationStarted:
+			l.Debug("Starting the %s of %s", e.Operation, e.Chart)
+		case *ChartResolved:
+			if e.Reason != "" {
+				l.Info("Resolved %s to %s/%s: %s", e.Name, e.Repo, e.Chart, e.Reason)
+			}
+		case *GeneratorStarted:
+			l.Debug("Running %s (%s)", e.Command, e.File)
+		case *GeneratorFinished:
+			l.Debug("Ran %s (%s) in %s", e.Command, e.File, e.Duration)
+		case *ManifestApplied:
+			if e.Printed {
+				l.Msg(e.Output)
+			} else {
+				l.Debug(e.Output)
+			}
+		case *RetryAttempted:
+			l.Info("%s failed: %s. Retrying in %s (retry %d of %d)", e.What, e.Reason, e.Delay, e.Attempt, e.Retries)
+		case *OperationCompleted:
+			if e.Err != nil {
+				l.Debug("The %s of %s failed after %s", e.Operation, e.Chart, e.Duration)
+			} else {
+				l.Debug("The %s of %s took %s", e.Operation, e.Chart, e.Duration)
+			}
+		}
+	}
+}
+
+// emit hands an event to the client's listener. A client without one renders
+// the event on its Log. The event is then recorded in the events file and
+// sent to the webhook of the client's settings, if they are set.
+func (c *Client) emit(e Event) {
+	if c.Events != nil {
+		c.Events(e)
+	} else {
+		LogEvents(c.Log)(e)
+	}
+	c.record(e)
+}
+
+// started emits the OperationStarted of an operation, and returns the time
+// it began, for completed.
+func (c *Client) started(op, chartName string) time.Time {
+	c.emit(&OperationStarted{Operation: op, Chart: chartName})
+	return time.Now()
+}
+
+// completed emits the OperationCompleted of an operation that began at
+// start. It is deferred, with the operation's error.
+func (c *Client) completed(op, chartName string, start time.Time, err *error) {
+	d := time.Since(start)
+	metrics.Observe(op, chartName, d, *err)
+	c.emit(&OperationCompleted{Operation: op, Chart: chartName, Duration: d, Err: *err})
+}
+
+// useRetryEvents emits the retries of the requests that the client's runner
+// makes next as RetryAttempted events. The returned function stops it.
+func (c *Client) useRetryEvents() func() {
+	return c.useKube(func(s *kubectl.Settings) {
+		s.Retry.Notify = func(r *kubectl.Retrying) { c.emit(&RetryAttempted{Retrying: *r}) }
+	})
+}
+
+// generatorHooks emits the generators that a walk runs as events.
+func (c *Client) generatorHooks() *generator.Hooks {
+	return &generator.Hooks{
+		Started: func(file, command string) {
+			c.emit(&GeneratorStarted{File: file, Command: command})
+		},
+		Finished: func(file, command string, d time.Duration, err error) {
+			c.emit(&GeneratorFinished{File: file, Command: command, Duration: d, Err: err})
+		},
+	}
+}
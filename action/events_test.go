@@ -0,0 +1,186 @@
+package action
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/helm/helm-classic/kubectl"
+	"github.com/helm/helm-classic/log"
+	"github.com/helm/helm-classic/test"
+	"github.com/helm/helm-classic/util"
+)
+
+// describeEvent gives the fields of an event that do not change from run
+// to run.
+func describeEvent(e Event) string {
+	switch e := e.(type) {
+	case *OperationStarted:
+		return fmt.Sprintf("started %s %s", e.Operation, e.Chart)
+	case *ChartResolved:
+		return fmt.Sprintf("resolved %s to %s/%s: %s", e.Name, e.Repo, e.Chart, e.Reason)
+	case *GeneratorStarted:
+		return fmt.Sprintf("started %s (%s)", e.Command, filepath.Base(e.File))
+	case *GeneratorFinished:
+		return fmt.Sprintf("finished %s (%s): %v", e.Command, filepath.Base(e.File), e.Err)
+	case *ManifestApplied:
+		return fmt.Sprintf("applied %s %s %s %q: %s, %v", e.Kind, e.Name, e.Namespace, e.Op, e.Status, e.Err)
+	case *RetryAttempted:
+		return fmt.Sprintf("retry %d of %d: %s", e.Attempt, e.Retries, e.Reason)
+	case *OperationCompleted:
+		return fmt.Sprintf("completed %s %s: %v", e.Operation, e.Chart, e.Err)
+	}
+	return fmt.Sprintf("unknown %T", e)
+}
+
+func TestEvents(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+	test.FakeUpdate(tmpHome)
+
+	events := []string{}
+	c := &Client{
+		Home: tmpHome,
+		Kube: &kubectl.FakeRunner{},
+		Log:  &log.Logger{Stdout: ioutil.Discard, Stderr: ioutil.Discard},
+		Events: func(e Event) {
+			events = append(events, describeEvent(e))
+		},
+	}
+	if _, err := c.Fetch("redis", "", FetchOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	gen := filepath.Join(util.WorkspaceChartDirectory(tmpHome, "redis"), "gen.txt")
+	if err := ioutil.WriteFile(gen, []byte("#helm:generate true\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c.AllowGenerators = []string{"true"}
+	if _, err := c.DryRunInstall("redis", InstallOptions{Namespace: "cache", Generate: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []string{
+		"started fetch redis",
+		"resolved redis to charts/redis: the only repository with the chart",
+		"completed fetch redis: <nil>",
+		"started dry-run install redis",
+		"started generate redis",
+		"started true (gen.txt)",
+		"finished true (gen.txt): <nil>",
+		"completed generate redis: <nil>",
+		`applied Pod redis cache "": accepted, <nil>`,
+		"completed dry-run install redis: <nil>",
+	}
+	test.ExpectEquals(t, strings.Join(events, "\n"), strings.Join(expect, "\n"))
+}
+
+func TestLogEvents(t *testing.T) {
+	actual := test.CaptureOutput(func() {
+		l := LogEvents(nil)
+		l(&ChartResolved{Name: "redis", Repo: "charts", Chart: "redis", Reason: "named by --repo"})
+		l(&ChartResolved{Name: "charts/redis", Repo: "charts", Chart: "redis"})
+		l(&ManifestApplied{Kind: "Pod", Name: "redis", Op: ModeCreate, Output: "kubectl create -f - ", Printed: true})
+		l(&RetryAttempted{kubectl.Retrying{What: "kubectl create", Reason: "i/o timeout", Delay: time.Second, Attempt: 1, Retries: 3}})
+	})
+	test.ExpectEquals(t, actual, "---> Resolved redis to charts/redis: named by --repo\nkubectl create -f - \n---> kubectl create failed: i/o timeout. Retrying in 1s (retry 1 of 3)\n")
+}
+
+func TestRetryEvents(t *testing.T) {
+	events := []string{}
+	c := &Client{Kube: kubectl.PrintRunner{}, Events: func(e Event) {
+		events = append(events, describeEvent(e))
+	}}
+	other := &Client{Kube: kubectl.PrintRunner{}}
+
+	stop := c.useRetryEvents()
+	notify := kubectl.SettingsOf(c.Kube).Retry.Notify
+	if notify == nil {
+		t.Fatal("Expected the client's runner to emit its retries")
+	}
+	if kubectl.Defaults.Retry.Notify != nil || other.kube().Retry.Notify != nil {
+		t.Error("Expected the retries of other runners not to be emitted")
+	}
+	notify(&kubectl.Retrying{What: "kubectl create", Reason: "i/o timeout", Attempt: 1, Retries: 3})
+	test.ExpectEquals(t, strings.Join(events, "\n"), "retry 1 of 3: i/o timeout")
+
+	stop()
+	if c.Kube != (kubectl.PrintRunner{}) || c.kube().Retry.Notify != nil {
+		t.Error("Expected the runner to be given back")
+	}
+}
+
+func TestEventSinks(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+	test.FakeUpdate(tmpHome)
+
+	posted := []*EventRecord{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &EventRecord{}
+		if err := json.NewDecoder(r.Body).Decode(rec); err != nil || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Expected an event as JSON, got %v", err)
+		}
+		posted = append(posted, rec)
+	}))
+	defer srv.Close()
+
+	file := filepath.Join(tmpHome, "ci", "events.jsonl")
+	c := &Client{
+		Settings: Settings{EventsFile: file, EventsWebhook: srv.URL},
+		Home:     tmpHome,
+		Kube:     &kubectl.FakeRunner{Out: []byte(`pod "redis" created`)},
+		Log:      &log.Logger{Stdout: ioutil.Discard, Stderr: ioutil.Discard},
+		Events:   func(Event) {},
+	}
+	if _, err := c.Install("redis", InstallOptions{Namespace: "cache"}); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if len(lines) != len(posted) {
+		t.Errorf("Expected the file and the webhook to have the same events, got %d and %d", len(lines), len(posted))
+	}
+	events := []string{}
+	for _, l := range lines {
+		rec := &EventRecord{}
+		if err := json.Unmarshal([]byte(l), rec); err != nil {
+			t.Fatalf("Expected a line of JSON, got %q: %s", l, err)
+		}
+		if rec.Time.IsZero() {
+			t.Errorf("Expected the time of %s", l)
+		}
+		events = append(events, rec.Event+" "+rec.Operation+rec.Kind+" "+rec.Chart+rec.Resource+" "+rec.Status)
+	}
+	expect := []string{
+		"operationStarted install redis ",
+		"chartResolved  redis ",
+		"manifestApplied Pod redis created",
+		"operationCompleted install redis ",
+	}
+	test.ExpectEquals(t, strings.Join(events, "\n"), strings.Join(expect, "\n"))
+
+	// A webhook that fails is warned about once, and does not fail the
+	// operation.
+	var out bytes.Buffer
+	c.Log = &log.Logger{Stdout: &out, Stderr: &out}
+	c.EventsFile, c.EventsWebhook = "", srv.URL+"/gone"
+	srv.Config.Handler = http.NotFoundHandler()
+	if _, err := c.Fetch("redis", "", FetchOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(out.String(), "Could not record the events of helmc in "+srv.URL+"/gone: it answered 404 Not Found"); n != 1 {
+		t.Errorf("Expected one warning, got %q", out.String())
+	}
+}
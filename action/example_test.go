@@ -0,0 +1,56 @@
+package action_test
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/helm/helm-classic/action"
+	"github.com/helm/helm-classic/kubectl"
+	"github.com/helm/helm-classic/log"
+	"github.com/helm/helm-classic/test"
+)
+
+// A Client fetches a chart, runs its generators, and sends its manifests to
+// Kubernetes for a dry run, all within the calling process. Its messages go
+// to a buffer instead of the terminal.
+func ExampleClient() {
+	home := test.CreateTmpHome()
+	defer os.RemoveAll(home)
+	test.FakeUpdate(home)
+
+	var out bytes.Buffer
+	kube := &kubectl.FakeRunner{}
+	c := &action.Client{
+		Home:     home,
+		Kube:     kube,
+		Log:      &log.Logger{Stdout: &out, Stderr: &out},
+		Settings: action.Settings{Offline: true},
+	}
+
+	if _, err := c.Fetch("redis", "", action.FetchOptions{}); err != nil {
+		fmt.Println(err)
+		return
+	}
+	n, err := c.Generate("redis", action.GenerateOptions{})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Printf("Ran %d generators\n", n)
+
+	res, err := c.DryRunInstall("redis", action.InstallOptions{Namespace: "default"})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for _, rr := range res.Resources {
+		fmt.Printf("%s %s: %s\n", rr.Kind, rr.Name, rr.Status)
+	}
+	fmt.Println(kube.Calls)
+
+	// Output:
+	// Ran 0 generators
+	// Pod redis: accepted
+	// [dry-run default]
+}
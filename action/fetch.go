@@ -1,88 +1,526 @@
 package action
 
 import (
+	"bufio"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/ssh/terminal"
 
 	"github.com/helm/helm-classic/chart"
+	"github.com/helm/helm-classic/config"
 	"github.com/helm/helm-classic/dependency"
+	helmerrors "github.com/helm/helm-classic/errors"
+	"github.com/helm/helm-classic/helmpath"
 	"github.com/helm/helm-classic/log"
 	helm "github.com/helm/helm-classic/util"
 )
 
+// FetchOptions control how a fetch resolves a chart name that several
+// repositories have, and what it does when the workspace already has a chart
+// of the local name.
+type FetchOptions struct {
+	// Repo is the repository to fetch an unqualified chart name from.
+	Repo string
+	// Force replaces a workspace chart of the same local name that differs
+	// from the fetched one.
+	Force bool
+	// IfAbsent makes the fetch a no-op if the workspace already has the
+	// chart, with the same digest.
+	IfAbsent bool
+	// AllowUnsafe copies the unsafe files of the chart as they are, and
+	// lifts the limits on its size. See chart.CopyFiles.
+	AllowUnsafe bool
+	// AcceptDeprecated fetches a deprecated chart even if the configuration
+	// is strict. See config.Fetch.Strict.
+	AcceptDeprecated bool
+	// PrintChecksum prints the checksum of the fetched chart, as 'helmc
+	// install --checksum' takes it. See chart.Checksum.
+	PrintChecksum bool
+	// Deps fetches the dependencies of the chart that the workspace is
+	// missing, and theirs in turn, and records what they were resolved to in
+	// the dependency.LockFile of the chart. See dependency.ResolveAll.
+	Deps bool
+	// VerifyKeyring, if set, is the keyring that the provenance file of the
+	// chart must be signed by, before the chart is copied into the
+	// workspace. See Verify.
+	VerifyKeyring string
+	// Lock, if set, is the chart that the fetch must reproduce: the fetch
+	// fails unless the cache of a Git repository has its commit checked out,
+	// and the fetched chart has its digest. See FetchLocked.
+	Lock *LockedChart
+	// Choose picks one of the candidates for an ambiguous chart name, and
+	// returns its index. If it is nil, an ambiguous name is an error.
+	Choose func(candidates []*Candidate) (int, error)
+}
+
+// Candidate is a repository's chart that an unqualified chart name could mean.
+type Candidate struct {
+	Repo        string
+	Name        string
+	Version     string
+	Description string
+}
+
 // Fetch gets a chart from the source repo and copies to the workdir.
 //
-// - chartName is the source
+// - chartName is the source: a chart of a repository, or a chart archive (.tgz)
 // - lname is the local name for that chart (chart-name); if blank, it is set to the chart.
 // - homedir is the home directory for the user
-func Fetch(chartName, lname, homedir string) {
-
-	r := mustConfig(homedir).Repos
-	repository, chartName := r.RepoChart(chartName)
+// - o controls how name collisions are resolved
+//
+// If stdin is a terminal and o has no chooser, the user is asked to pick
+// among the candidates for an ambiguous name.
+//
+// If o.PrintChecksum is set, the checksum of the chart in the workspace is
+// printed last, as 'helmc install --checksum' takes it.
+//
+// A chart that no repository has is reported with a *helmerrors.ChartNotFoundError,
+// an ambiguous name with a *helmerrors.AmbiguousChartError, and a repository
+// that could not be read with a *helmerrors.RepoError.
+func Fetch(chartName, lname, homedir string, o FetchOptions) error {
+	c := newClient(homedir, nil)
+	c.Config = mustConfig(homedir)
+	if o.Choose == nil && stdinIsTerminal() {
+		o.Choose = chooseCandidate
+	}
+	dir, err := c.Fetch(chartName, lname, o)
+	if err != nil || !o.PrintChecksum {
+		return err
+	}
+	sum, err := chart.Checksum(dir)
+	if err != nil {
+		return fmt.Errorf("Could not compute the checksum of %s: %s", dir, err)
+	}
+	log.Msg(sum)
+	return nil
+}
 
-	if lname == "" {
-		lname = chartName
+// Fetch is like the package-level Fetch, but it never prompts. It returns the
+// chart's directory in the workspace.
+//
+// Every resolution is logged: which repository the chart came from and why,
+// and whether a workspace chart was replaced or left alone.
+func (c *Client) Fetch(chartName, lname string, o FetchOptions) (dir string, err error) {
+	defer c.completed(OpFetch, chartName, c.started(OpFetch, chartName), &err)
+	cfg, err := c.config()
+	if err != nil {
+		return "", err
 	}
+	var fetched bool
+	if isChartArchive(chartName) {
+		if o.Repo != "" {
+			return "", fmt.Errorf("%s is a chart archive, which is not of a repository. Drop --repo.", chartName)
+		}
+		if lname, fetched, err = c.fetchArchive(chartName, lname, o); err != nil {
+			return "", err
+		}
+	} else {
+		r := cfg.Repos
+		searched := r.Searched(chartName)
+		if o.Repo != "" {
+			searched = []string{o.Repo}
+		}
+		repository, chartName, err := c.resolveFetch(r, chartName, o)
+		if err != nil {
+			return "", err
+		}
 
-	fetch(chartName, lname, homedir, repository)
+		if lname == "" {
+			lname = chartName
+		}
 
-	chartFilePath := helm.WorkspaceChartDirectory(homedir, lname, Chartfile)
-	cfile, err := chart.LoadChartfile(chartFilePath)
+		if fetched, err = c.fetch(chartName, lname, repository, searched, o); err != nil {
+			return "", err
+		}
+	}
+
+	dir = helm.WorkspaceChartDirectory(c.Home, lname)
+	if !fetched && !o.Deps {
+		return dir, nil
+	}
+	cfile, err := chart.LoadChartfile(filepath.Join(dir, Chartfile))
 	if err != nil {
-		log.Die("Source is not a valid chart. Missing Chart.yaml: %s", err)
+		return "", fmt.Errorf("Source is not a valid chart. Missing Chart.yaml: %s", err)
+	}
+	if o.Deps {
+		if _, err := c.fetchDeps(lname, cfile, o); err != nil {
+			return "", err
+		}
+		if !fetched {
+			return dir, nil
+		}
 	}
 
-	deps, err := dependency.Resolve(cfile, helm.WorkspaceChartDirectory(homedir))
+	deps, err := dependency.Resolve(cfile, helm.WorkspaceChartDirectory(c.Home))
 	if err != nil {
-		log.Warn("Could not check dependencies: %s", err)
-		return
+		c.Log.Warn("Could not check dependencies: %s", err)
+		return dir, nil
 	}
 
 	if len(deps) > 0 {
-		log.Warn("Unsatisfied dependencies:")
+		c.Log.Warn("Unsatisfied dependencies:")
 		for _, d := range deps {
-			log.Msg("\t%s %s", d.Name, d.Version)
+			c.Log.Msg("\t%s %s", d.Name, d.Version)
 		}
 	}
 
-	log.Info("Fetched chart into workspace %s", helm.WorkspaceChartDirectory(homedir, lname))
-	log.Info("Done")
+	c.Log.Info("Fetched chart into workspace %s", dir)
+	c.Log.Info("Done")
+	return dir, nil
 }
 
-func fetch(chartName, lname, homedir, chartpath string) {
-	src := helm.CacheDirectory(homedir, chartpath, chartName)
-	dest := helm.WorkspaceChartDirectory(homedir, lname)
+// resolveFetch returns the repository and chart that a fetch of name is
+// from, and emits a ChartResolved that tells how it was chosen.
+func (c *Client) resolveFetch(r *config.Repos, name string, o FetchOptions) (string, string, error) {
+	if o.Repo != "" {
+		if strings.Contains(name, "/") {
+			return "", "", fmt.Errorf("Chart name %s already names a repository. Drop --repo, or the repository from the name.", name)
+		}
+		if r.Lookup(o.Repo) == nil {
+			return "", "", fmt.Errorf("Repository %s not found. See 'helmc repo list'.", o.Repo)
+		}
+		c.emit(&ChartResolved{Name: name, Repo: o.Repo, Chart: name, Reason: "named by --repo"})
+		return o.Repo, name, nil
+	}
+
+	repository, chartName, err := r.Resolve(name)
+	var amb *helmerrors.AmbiguousChartError
+	switch {
+	case errors.As(err, &amb) && o.Choose != nil:
+		candidates := make([]*Candidate, len(amb.Candidates))
+		for i, qn := range amb.Candidates {
+			repo, ch := r.RepoChart(qn)
+			candidates[i] = &Candidate{Repo: repo, Name: ch}
+			if cf, err := r.CachedChart(repo, ch); err == nil {
+				candidates[i].Version = cf.Version
+				candidates[i].Description = cf.Description
+			}
+		}
+		n, err := o.Choose(candidates)
+		if err != nil {
+			return "", "", fmt.Errorf("%s: %w", err, amb)
+		}
+		if n < 0 || n >= len(candidates) {
+			return "", "", fmt.Errorf("No candidate %d: %w", n+1, amb)
+		}
+		cd := candidates[n]
+		c.emit(&ChartResolved{Name: name, Repo: cd.Repo, Chart: cd.Name, Reason: "chosen from " + strings.Join(amb.Candidates, ", ")})
+		return cd.Repo, cd.Name, nil
+	case err != nil:
+		return "", "", err
+	}
+
+	e := &ChartResolved{Name: name, Repo: repository, Chart: chartName}
+	switch found := r.Candidates(chartName); {
+	case strings.Contains(name, "/"):
+	case len(found) == 1:
+		e.Reason = "the only repository with the chart"
+	case len(found) > 1:
+		e.Reason = "the highest priority, among " + strings.Join(found, ", ")
+	}
+	c.emit(e)
+	return repository, chartName, nil
+}
+
+// fetch copies a chart from the cache of the chartpath repository into the
+// workspace. searched names the repositories that the chart was looked for
+// in, for the error if it is not found.
+//
+// The chart is copied into a staging directory first, so that it can be
+// compared with a workspace chart of the same local name. It returns false
+// if o.IfAbsent left an identical workspace chart alone.
+func (c *Client) fetch(chartName, lname, chartpath string, searched []string, o FetchOptions) (bool, error) {
+	src := helm.CacheDirectory(c.Home, chartpath, chartName)
+	unlock, err := c.lockChart(lname)
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	cfg, err := c.config()
+	if err != nil {
+		return false, err
+	}
+	origin := ""
+	r := cfg.Repos
+	// The index of an HTTP repository tells, so nothing is downloaded.
+	if cf, err := r.CachedChart(chartpath, chartName); err == nil {
+		if err := c.checkDeprecated(cf, o.AcceptDeprecated); err != nil {
+			return false, err
+		}
+	}
+	if t := r.Lookup(chartpath); t != nil && t.IsHTTP() {
+		if err := r.FetchChart(chartpath, chartName); err != nil {
+			return false, fmt.Errorf("Could not download %s: %w", chartName, err)
+		}
+		origin = t.Repo
+	} else if t != nil && t.IsDir() {
+		origin = t.Repo
+	}
 
 	fi, err := os.Stat(src)
 	if err != nil {
-		log.Warn("Oops. Looks like there was an issue finding the chart, %s, in %s. Running `helmc update` to ensure you have the latest version of all Charts from Github...", lname, src)
-		Update(homedir)
+		c.Log.Warn("Oops. Looks like there was an issue finding the chart, %s, in %s. Running `helmc update` to ensure you have the latest version of all Charts from Github...", lname, src)
+		if err := r.UpdateAll(false); err != nil {
+			c.Log.Warn("Not all repos could be updated: %s", err)
+		}
 		fi, err = os.Stat(src)
 		if err != nil {
-			log.Die("Chart %s not found in %s", lname, src)
+			return false, &helmerrors.ChartNotFoundError{Name: chartName, Repos: searched}
 		}
-		log.Info("Good news! Looks like that did the trick. Onwards and upwards!")
+		c.Log.Info("Good news! Looks like that did the trick. Onwards and upwards!")
 	}
 
 	if !fi.IsDir() {
-		log.Die("Malformed chart %s: Chart must be in a directory.", chartName)
+		return false, fmt.Errorf("Malformed chart %s: Chart must be in a directory.", chartName)
 	}
+	if o.VerifyKeyring != "" {
+		if err := c.verifyFetched(src, provenancePath(src), chartpath+"/"+chartName, o.VerifyKeyring); err != nil {
+			return false, err
+		}
+	}
+	commit, err := r.Commit(chartpath)
+	if err != nil {
+		c.Log.Debug("Could not read the commit of %s: %s", chartpath, err)
+	}
+	if o.Lock != nil && o.Lock.Commit != "" && o.Lock.Commit != commit {
+		return false, fmt.Errorf("The cache of %s is at commit %s, not %s as the lock file records. Check the commit out in %s, or fetch %s without --locked.", chartpath, dash(commit), o.Lock.Commit, helm.CacheDirectory(c.Home, chartpath), lname)
+	}
+	fetched, err := c.stage(src, lname, chartpath+"/"+chartName, origin, o)
+	if fetched {
+		c.recordFetch(lname, &LockedChart{Repo: chartpath, Chart: chartName, Commit: commit})
+	}
+	return fetched, err
+}
 
-	if err := os.MkdirAll(dest, 0755); err != nil {
-		log.Die("Could not create %q: %s", dest, err)
+// stage copies the chart in src into the workspace as lname, as fetch does.
+// label names the chart in messages, and origin is the repository it is
+// from; if it is empty, it is the origin of the Git repository of src.
+func (c *Client) stage(src, lname, label, origin string, o FetchOptions) (bool, error) {
+	cfg, err := c.config()
+	if err != nil {
+		return false, err
+	}
+
+	// The staging directory is next to the charts, so that it can be renamed
+	// into place, but outside them, so that it is never taken for a chart.
+	ws := helmpath.Home(c.Home).Workspace()
+	if err := os.MkdirAll(ws, 0755); err != nil {
+		return false, fmt.Errorf("Could not create %q: %s", ws, err)
+	}
+	stage, cleanup, err := helm.TempDir(ws, ".fetch-"+lname+"-")
+	if err != nil {
+		return false, fmt.Errorf("Could not create a staging directory: %s", err)
+	}
+	defer cleanup()
+
+	c.Log.Debug("Fetching %s to %s", src, stage)
+	unsafe, err := chart.CopyFiles(src, stage, cfg.Limits(), o.AllowUnsafe)
+	for _, u := range unsafe {
+		c.Log.Warn("%s: %s", label, u)
+	}
+	if err != nil {
+		var uf *chart.UnsafeFile
+		if errors.As(err, &uf) {
+			return false, fmt.Errorf("Not fetching %s: %s. Re-run with --allow-unsafe to fetch it anyway, or raise fetch.maxFiles or fetch.maxSizeMB.", label, err)
+		}
+		return false, fmt.Errorf("Failed copying %s to %s: %s", src, stage, err)
+	}
+
+	if err := c.updateChartfile(src, stage, lname, origin); err != nil {
+		return false, fmt.Errorf("Failed to update Chart.yaml: %s", err)
+	}
+
+	digest, err := chart.Digest(stage)
+	if err != nil {
+		return false, err
+	}
+	if err := checkLocked(o, label, digest); err != nil {
+		return false, err
+	}
+	return c.place(stage, lname, label, digest, o)
+}
+
+// place moves the chart staged in stage into the workspace as lname. A
+// workspace chart of that name is replaced if it is the same, and otherwise
+// only with o.Force; with o.IfAbsent, one that is the same is left alone, and
+// place returns false.
+func (c *Client) place(stage, lname, label, digest string, o FetchOptions) (bool, error) {
+	dest := helm.WorkspaceChartDirectory(c.Home, lname)
+	if _, err := os.Stat(dest); err == nil {
+		// The workspace chart's .helmignore decides which files count, so
+		// that Force is not needed to replace files that are not the chart's.
+		changed, err := diffCharts(stage, dest, label, lname)
+		if err != nil {
+			return false, err
+		}
+		switch {
+		case len(changed) == 0 && o.IfAbsent:
+			c.Log.Info("Skipped %s: the workspace already has it as %s, with digest %s (--if-absent)", label, lname, digest)
+			return false, nil
+		case len(changed) == 0:
+			// Replacing a chart by an identical one is harmless.
+		case !o.Force:
+			c.Log.Warn("Chart %s in the workspace differs from %s:", lname, label)
+			for _, f := range changed {
+				c.Log.Msg("\t%-8s %s", f.Status, f.Path)
+			}
+			return false, fmt.Errorf("The workspace already has a different chart named %s, in %s. See 'helmc diff-local %s'. Re-run with --force to replace it, or give the chart another name.", lname, dest, lname)
+		default:
+			c.Log.Info("Replacing %s in the workspace, with %d changed files, by %s, with digest %s (--force)", lname, len(changed), label, digest)
+		}
+		if err := os.RemoveAll(dest); err != nil {
+			return false, fmt.Errorf("Could not remove %s: %s", dest, err)
+		}
+	} else if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return false, fmt.Errorf("Could not create %q: %s", filepath.Dir(dest), err)
+	}
+
+	if err := os.Rename(stage, dest); err != nil {
+		return false, fmt.Errorf("Could not move %s into the workspace: %s", lname, err)
+	}
+	c.Log.Debug("Chart %s has digest %s", lname, digest)
+	return true, nil
+}
+
+// fetchDeps fetches the dependencies of the workspace chart lname, whose
+// Chart.yaml is cf, that the workspace is missing, and theirs in turn, and
+// writes what they were resolved to in the dependency.LockFile of the chart.
+// It returns them, each after those it depends on.
+//
+// Of o, only AllowUnsafe and AcceptDeprecated apply to the dependencies.
+func (c *Client) fetchDeps(lname string, cf *chart.Chartfile, o FetchOptions) ([]*dependency.Resolved, error) {
+	cfg, err := c.config()
+	if err != nil {
+		return nil, err
 	}
+	src := &repoSource{c: c, r: cfg.Repos, o: FetchOptions{AllowUnsafe: o.AllowUnsafe, AcceptDeprecated: o.AcceptDeprecated}}
+	res, err := dependency.ResolveAll(cf, helm.WorkspaceChartDirectory(c.Home), src)
+	if err != nil {
+		return nil, fmt.Errorf("Could not resolve the dependencies of %s: %w", lname, err)
+	}
+	for _, d := range res {
+		if d.Fetched {
+			c.Log.Info("Fetched dependency %s %s, for %s", d.Name, d.Version, strings.Join(d.RequiredBy, ", "))
+		} else {
+			c.Log.Debug("Dependency %s %s is in the workspace as %s", d.Name, d.Version, d.Chart)
+		}
+	}
+	dir := helm.WorkspaceChartDirectory(c.Home, lname)
+	if err := (&dependency.Lock{Dependencies: res}).Save(dir); err != nil {
+		return nil, fmt.Errorf("Could not write %s: %s", dependency.LockFile, err)
+	}
+	if len(res) > 0 {
+		c.Log.Info("Resolved %d dependencies of %s into %s", len(res), lname, filepath.Join(dir, dependency.LockFile))
+	}
+	return res, nil
+}
+
+// repoSource is the dependency.Source of a client: the charts in the
+// caches of its repositories.
+type repoSource struct {
+	c *Client
+	r *config.Repos
+	o FetchOptions
+}
+
+// Candidates returns the charts of a name in the caches of the
+// repositories, by priority, and the default repository first among equals.
+// Unlike Repos.Resolve, a tie is not ambiguous here: the dependency picks
+// among the candidates by version.
+func (s *repoSource) Candidates(name string) ([]*dependency.Candidate, error) {
+	var tables []*config.Table
+	for _, repo := range s.r.Candidates(name) {
+		tables = append(tables, s.r.Lookup(repo))
+	}
+	sort.SliceStable(tables, func(i, j int) bool {
+		if tables[i].Priority != tables[j].Priority {
+			return tables[i].Priority > tables[j].Priority
+		}
+		return tables[i].Name == s.r.Default && tables[j].Name != s.r.Default
+	})
+	res := []*dependency.Candidate{}
+	for _, t := range tables {
+		cf, err := s.r.CachedChart(t.Name, name)
+		if err != nil {
+			s.c.Log.Warn("Could not read %s/%s: %s", t.Name, name, err)
+			continue
+		}
+		res = append(res, &dependency.Candidate{Repo: t.Name, Origin: t.Repo, Name: name, Version: cf.Version})
+	}
+	return res, nil
+}
+
+// Fetch fetches a candidate into the workspace, under its name.
+func (s *repoSource) Fetch(cd *dependency.Candidate) (*chart.Chartfile, error) {
+	s.c.emit(&ChartResolved{Name: cd.Name, Repo: cd.Repo, Chart: cd.Name, Reason: "the highest version that satisfies the dependency"})
+	if _, err := s.c.fetch(cd.Name, cd.Name, cd.Repo, []string{cd.Repo}, s.o); err != nil {
+		return nil, err
+	}
+	return chart.LoadChartfile(helm.WorkspaceChartDirectory(s.c.Home, cd.Name, Chartfile))
+}
 
-	log.Debug("Fetching %s to %s", src, dest)
-	if err := helm.CopyDir(src, dest); err != nil {
-		log.Die("Failed copying %s to %s", src, dest)
+// checkDeprecated warns that a chart is deprecated. If the configuration is
+// strict, a deprecated chart is an error instead, unless accept is set.
+func (c *Client) checkDeprecated(cf *chart.Chartfile, accept bool) error {
+	n := cf.DeprecationNotice()
+	if n == "" {
+		return nil
 	}
+	if cfg, err := c.config(); err == nil && cfg.Strict() && !accept {
+		return fmt.Errorf("%s Re-run with --accept-deprecated to use it anyway, since fetch.strict is set.", n)
+	}
+	c.Log.Warn("%s", n)
+	return nil
+}
+
+// stdinIsTerminal returns true if log.Stdin is a terminal, so that the user can be asked questions.
+func stdinIsTerminal() bool {
+	f, ok := log.Stdin.(*os.File)
+	return ok && terminal.IsTerminal(int(f.Fd()))
+}
 
-	if err := updateChartfile(src, dest, lname); err != nil {
-		log.Die("Failed to update Chart.yaml: %s", err)
+// chooseCandidate asks the user on log.Stdin which of the candidates to fetch.
+func chooseCandidate(candidates []*Candidate) (int, error) {
+	fmt.Fprintf(log.Stdout, "Several repositories have a chart named %s:\n", candidates[0].Name)
+	for i, cd := range candidates {
+		fmt.Fprintf(log.Stdout, "  %d) %s/%s %s\t%s\n", i+1, cd.Repo, cd.Name, cd.Version, cd.Description)
+	}
+	fmt.Fprintf(log.Stdout, "Fetch which one? [1-%d] ", len(candidates))
+	answer, err := bufio.NewReader(log.Stdin).ReadString('\n')
+	if err != nil && answer == "" {
+		fmt.Fprintln(log.Stdout)
+		return -1, errors.New("No chart chosen")
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(answer))
+	if err != nil || n < 1 || n > len(candidates) {
+		return -1, fmt.Errorf("No chart chosen: %q is not one of 1-%d", strings.TrimSpace(answer), len(candidates))
+	}
+	return n - 1, nil
+}
+
+// updateChartfile records where a fetched chart came from.
+//
+// If origin is empty, the Git remote of src is used.
+func (c *Client) updateChartfile(src, dest, lname, origin string) error {
+	if origin == "" {
+		var err error
+		if origin, err = chart.RepoOrigin(src); err != nil {
+			c.Log.Err("%s", err)
+		}
 	}
+	return writeFetchedChartfile(src, dest, lname, origin)
 }
 
-func updateChartfile(src, dest, lname string) error {
+// writeFetchedChartfile renames the chart copied from src to dest to lname,
+// and records that it is based on the chart in src, from origin.
+func writeFetchedChartfile(src, dest, lname, origin string) error {
 	sc, err := chart.LoadChartfile(filepath.Join(src, Chartfile))
 	if err != nil {
 		return err
@@ -97,7 +535,7 @@ func updateChartfile(src, dest, lname string) error {
 	dc.From = &chart.Dependency{
 		Name:    sc.Name,
 		Version: sc.Version,
-		Repo:    chart.RepoName(src),
+		Repo:    origin,
 	}
 
 	return dc.Save(filepath.Join(dest, Chartfile))
@@ -1,8 +1,21 @@
 package action
 
 import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
 
+	"github.com/helm/helm-classic/chart"
+	"github.com/helm/helm-classic/config"
+	"github.com/helm/helm-classic/dependency"
+	helmerrors "github.com/helm/helm-classic/errors"
+	"github.com/helm/helm-classic/kubeschema"
+	"github.com/helm/helm-classic/log"
 	"github.com/helm/helm-classic/test"
 	"github.com/helm/helm-classic/util"
 )
@@ -13,9 +26,258 @@ func TestFetch(t *testing.T) {
 	chartName := "kitchensink"
 
 	actual := test.CaptureOutput(func() {
-		Fetch(chartName, "", tmpHome)
+		Fetch(chartName, "", tmpHome, FetchOptions{})
 	})
 
 	workspacePath := util.WorkspaceChartDirectory(tmpHome, chartName)
 	test.ExpectContains(t, actual, "Fetched chart into workspace "+workspacePath)
 }
+
+func TestFetchCollisions(t *testing.T) {
+	home := test.CreateTmpHome()
+	defer os.RemoveAll(home)
+	test.FakeUpdate(home)
+
+	// Two repositories of the same priority have different redis charts.
+	cfg, err := config.Load(filepath.Join(home, util.Configfile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a", "b"} {
+		util.CopyDir(util.CacheDirectory(home, "charts", "redis"), util.CacheDirectory(home, name, "redis"))
+		cfg.Repos.Tables = append(cfg.Repos.Tables, &config.Table{Name: name, Repo: "https://example.com/" + name, Priority: 1})
+	}
+	ioutil.WriteFile(util.CacheDirectory(home, "b", "redis", Chartfile), []byte("name: redis\nversion: 0.0.2\ndescription: Redis from b.\n"), 0644)
+
+	var out bytes.Buffer
+	c := &Client{Home: home, Config: cfg, Log: &log.Logger{Stdout: &out, Stderr: &out}}
+
+	if _, err := c.Fetch("redis", "", FetchOptions{}); !errors.Is(err, helmerrors.ErrAmbiguousChart) {
+		t.Fatalf("Expected an ambiguous chart error, got %v", err)
+	}
+
+	var choices []*Candidate
+	choose := func(candidates []*Candidate) (int, error) {
+		choices = candidates
+		return 1, nil
+	}
+	if _, err := c.Fetch("redis", "", FetchOptions{Choose: choose}); err != nil {
+		t.Fatalf("Could not fetch a chosen chart: %s", err)
+	}
+	if len(choices) != 2 || choices[1].Repo != "b" || choices[1].Version != "0.0.2" || choices[1].Description != "Redis from b." {
+		t.Errorf("Expected to choose between a/redis and b/redis 0.0.2, got %+v %+v", choices[0], choices[1])
+	}
+	test.ExpectContains(t, out.String(), "Resolved redis to b/redis: chosen from a/redis, b/redis")
+
+	// A different chart of the same name is only replaced with Force.
+	if _, err := c.Fetch("redis", "", FetchOptions{Repo: "a"}); err == nil || !strings.Contains(err.Error(), "--force") {
+		t.Errorf("Expected fetching over a different chart to fail, got %v", err)
+	}
+	if _, err := c.Fetch("redis", "", FetchOptions{Repo: "a", Force: true}); err != nil {
+		t.Fatalf("Could not replace a chart: %s", err)
+	}
+	test.ExpectContains(t, out.String(), "Resolved redis to a/redis: named by --repo")
+	test.ExpectContains(t, out.String(), "Replacing redis in the workspace")
+	cf, err := chart.LoadChartfile(util.WorkspaceChartDirectory(home, "redis", Chartfile))
+	if err != nil || cf.From.Version != "0.0.1" {
+		t.Errorf("Expected redis 0.0.1 in the workspace, got %+v, %v", cf, err)
+	}
+
+	// An identical chart is left alone with IfAbsent.
+	out.Reset()
+	if _, err := c.Fetch("a/redis", "", FetchOptions{IfAbsent: true}); err != nil {
+		t.Fatalf("Expected fetching an identical chart to succeed: %s", err)
+	}
+	test.ExpectContains(t, out.String(), "Skipped a/redis")
+	if strings.Contains(out.String(), "Fetched chart") {
+		t.Errorf("Expected no fetch, got %q", out.String())
+	}
+
+	if _, err := c.Fetch("a/redis", "", FetchOptions{Repo: "b"}); err == nil {
+		t.Error("Expected --repo with a qualified name to fail")
+	}
+	if _, err := c.Fetch("redis", "", FetchOptions{Repo: "nope"}); err == nil {
+		t.Error("Expected --repo with an unknown repository to fail")
+	}
+}
+
+func TestFetchUnsafe(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Symlinks need a Unix system")
+	}
+	home := test.CreateTmpHome()
+	defer os.RemoveAll(home)
+	test.FakeUpdate(home)
+	src := util.CacheDirectory(home, "charts", "redis")
+	os.Symlink("/etc/passwd", filepath.Join(src, "passwd"))
+
+	var out bytes.Buffer
+	c := &Client{Home: home, Log: &log.Logger{Stdout: &out, Stderr: &out}}
+	if _, err := c.Fetch("redis", "", FetchOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	test.ExpectContains(t, out.String(), "charts/redis: passwd: is a symlink to /etc/passwd, outside the chart (left out)")
+	if _, err := os.Lstat(util.WorkspaceChartDirectory(home, "redis", "passwd")); !os.IsNotExist(err) {
+		t.Errorf("Expected the symlink to be left out, got %v", err)
+	}
+
+	// Lint reports the same files.
+	out.Reset()
+	if err := c.lint(src, kubeschema.NewSet()); err == nil {
+		t.Error("Expected lint to fail")
+	}
+	test.ExpectContains(t, out.String(), "passwd: is a symlink to /etc/passwd, outside the chart")
+
+	cfg, err := c.config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Fetch = &config.Fetch{MaxFiles: 1}
+	if _, err := c.Fetch("redis", "", FetchOptions{Force: true}); err == nil || !strings.Contains(err.Error(), "more than the limit of 1. Re-run with --allow-unsafe") {
+		t.Errorf("Expected the chart to be too big, got %v", err)
+	}
+	if _, err := c.Fetch("redis", "", FetchOptions{Force: true, AllowUnsafe: true}); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := ioutil.ReadFile(util.WorkspaceChartDirectory(home, "redis", "passwd")); !strings.Contains(string(b), "root") {
+		t.Errorf("Expected --allow-unsafe to copy what the symlink points to")
+	}
+}
+
+func TestFetchDeprecated(t *testing.T) {
+	home := test.CreateTmpHome()
+	defer os.RemoveAll(home)
+	test.FakeUpdate(home)
+	src := util.CacheDirectory(home, "charts", "oldredis")
+	util.CopyDir(util.CacheDirectory(home, "charts", "redis"), src)
+	ioutil.WriteFile(filepath.Join(src, Chartfile), []byte("name: oldredis\nversion: 0.0.1\ndescription: Redis.\ndeprecated: true\ndeprecationMessage: Use redis.\n"), 0644)
+
+	actual := test.CaptureOutput(func() { Search("redis", home, SearchOptions{}) })
+	test.ExpectContains(t, actual, "oldredis (DEPRECATED) - Redis.")
+	actual = test.CaptureOutput(func() { Info("oldredis", home, "", "", false) })
+	test.ExpectContains(t, actual, "DEPRECATED: Use redis.\n\nName: oldredis")
+
+	var out bytes.Buffer
+	c := &Client{Home: home, Log: &log.Logger{Stdout: &out, Stderr: &out}}
+	if _, err := c.Fetch("oldredis", "", FetchOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	test.ExpectContains(t, out.String(), "Chart oldredis is deprecated: Use redis.")
+
+	// In strict mode, neither fetch nor install take it unless it is accepted.
+	cfg, err := c.config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Fetch = &config.Fetch{Strict: true}
+	if _, err := c.Fetch("oldredis", "", FetchOptions{Force: true}); err == nil || !strings.Contains(err.Error(), "Re-run with --accept-deprecated") {
+		t.Errorf("Expected a strict fetch to fail, got %v", err)
+	}
+	if _, err := c.DryRunInstall("oldredis", InstallOptions{}); err == nil || !strings.Contains(err.Error(), "Re-run with --accept-deprecated") {
+		t.Errorf("Expected a strict install to fail, got %v", err)
+	}
+	if _, err := c.Fetch("oldredis", "", FetchOptions{Force: true, AcceptDeprecated: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Lint asks for the reason.
+	ioutil.WriteFile(filepath.Join(src, Chartfile), []byte("name: oldredis\nversion: 0.0.1\ndeprecated: true\n"), 0644)
+	out.Reset()
+	c.lint(src, kubeschema.NewSet())
+	test.ExpectContains(t, out.String(), "A deprecated chart gives a deprecationMessage : false")
+}
+
+func TestFetchDeps(t *testing.T) {
+	home := test.CreateTmpHome()
+	defer os.RemoveAll(home)
+	test.FakeUpdate(home)
+	for name, data := range map[string]string{
+		"app": "name: app\nversion: 1.0.0\ndependencies:\n  - name: web\n    version: ^1\n",
+		"web": "name: web\nversion: 1.2.0\ndependencies:\n  - name: db\n    version: ~9.4\n",
+		"db":  "name: db\nversion: 9.4.1\n",
+	} {
+		dir := util.CacheDirectory(home, "charts", name)
+		os.MkdirAll(dir, 0755)
+		ioutil.WriteFile(filepath.Join(dir, Chartfile), []byte(data), 0644)
+	}
+
+	var out bytes.Buffer
+	c := &Client{Home: home, Log: &log.Logger{Stdout: &out, Stderr: &out}}
+	if _, err := c.Fetch("app", "", FetchOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	test.ExpectContains(t, out.String(), "Unsatisfied dependencies:")
+
+	out.Reset()
+	if _, err := c.Fetch("app", "", FetchOptions{Deps: true, IfAbsent: true}); err != nil {
+		t.Fatalf("Could not fetch the dependencies: %s", err)
+	}
+	test.ExpectContains(t, out.String(), "Fetched dependency db 9.4.1, for web@1.2.0")
+	lk, err := dependency.LoadLock(util.WorkspaceChartDirectory(home, "app"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lk.Dependencies) != 2 || lk.Dependencies[0].Name != "db" || lk.Dependencies[1].Name != "web" {
+		t.Errorf("Expected db, then web, got %+v", lk.Dependencies)
+	}
+	for _, name := range []string{"web", "db"} {
+		if _, err := os.Stat(util.WorkspaceChartDirectory(home, name, Chartfile)); err != nil {
+			t.Errorf("Expected %s in the workspace: %s", name, err)
+		}
+	}
+
+	// A range that no repository satisfies.
+	ioutil.WriteFile(util.CacheDirectory(home, "charts", "web", Chartfile), []byte("name: web\nversion: 1.3.0\ndependencies:\n  - name: db\n    version: ^10\n"), 0644)
+	if _, err := c.Fetch("web", "", FetchOptions{Deps: true, Force: true}); err == nil || !strings.Contains(err.Error(), "No chart satisfies db ^10, which web@1.3.0 depends on. Found charts/db@9.4.1.") {
+		t.Errorf("Expected db ^10 not to be satisfied, got %v", err)
+	}
+}
+
+func TestFetchLocked(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+	test.FakeUpdate(tmpHome)
+
+	test.CaptureOutput(func() {
+		if err := Fetch("redis", "myredis", tmpHome, FetchOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	lockfile := filepath.Join(tmpHome, "workspace", WorkspaceLockFile)
+	lk, err := LoadWorkspaceLock(lockfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lc := lk.Lookup("myredis")
+	if lc == nil || lc.Repo != "charts" || lc.Chart != "redis" || lc.Version != "0.0.1" || lc.Digest == "" {
+		t.Fatalf("Expected myredis to be recorded, got %+v", lc)
+	}
+
+	// A workspace without the chart gets it back, with the same digest.
+	dir := util.WorkspaceChartDirectory(tmpHome, "myredis")
+	os.RemoveAll(dir)
+	test.CaptureOutput(func() {
+		if err := FetchLocked("", tmpHome, nil, FetchOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if digest, _ := chart.Digest(dir); digest != lc.Digest {
+		t.Errorf("Expected digest %s, got %s", lc.Digest, digest)
+	}
+
+	// A chart that changed since is not fetched.
+	os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(tmpHome, "cache", "charts", "redis", "README.md"), []byte("changed"), 0644)
+	test.CaptureOutput(func() {
+		err = FetchLocked(lockfile, tmpHome, []string{"myredis"}, FetchOptions{})
+	})
+	if err == nil || !strings.Contains(err.Error(), "changed since it was locked") {
+		t.Errorf("Expected a changed chart to fail, got %v", err)
+	}
+	if _, err := os.Stat(dir); err == nil {
+		t.Errorf("Expected the changed chart not to be fetched")
+	}
+	if err := FetchLocked(lockfile, tmpHome, []string{"nope"}, FetchOptions{}); err == nil {
+		t.Errorf("Expected a chart that the lock file does not have to fail")
+	}
+}
@@ -1,35 +1,463 @@
 package action
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/helm/helm-classic/chart"
+	"github.com/helm/helm-classic/config"
+	helmerrors "github.com/helm/helm-classic/errors"
 	"github.com/helm/helm-classic/generator"
+	"github.com/helm/helm-classic/helmpath"
 	"github.com/helm/helm-classic/log"
+	"github.com/helm/helm-classic/output"
 	"github.com/helm/helm-classic/util"
 )
 
+// GenerateOptions are the options of Generate, GeneratePlan, GenerateWatch,
+// and ExplainGenerator.
+type GenerateOptions struct {
+	// Exclude are the files and directories of the chart whose generators do
+	// not run.
+	Exclude []string
+	// Force lets the generators overwrite files, and, with Incremental, run
+	// even if their inputs did not change.
+	Force bool
+	// DryRun lists the generators, but does not run them.
+	DryRun bool
+	// Strict fails a generator that uses an undefined variable, instead of
+	// running it with an empty value.
+	Strict bool
+	// SkipSchema renders the templates without validating their values
+	// against the chart's schema.
+	SkipSchema bool
+	// Verbose prints the output of the generators as it is, instead of
+	// logging it line by line with the file of each generator.
+	Verbose bool
+	// Incremental only runs the generators whose inputs changed since they
+	// last ran.
+	Incremental bool
+	// Jobs is how many generators run at a time. With 1 or less, they run
+	// one after the other.
+	Jobs int
+	// Timeout, if it is positive, is how long each generator may run before
+	// it is killed.
+	Timeout time.Duration
+	// Values are given to the templates that 'helmc template' renders; see
+	// Template.
+	Values ValueSources
+}
+
 // Generate runs generators on the entire chart.
 //
 // By design, this only operates on workspaces, as it should never be run
-// on the cache.
-func Generate(chart, homedir string, exclude []string, force bool) {
+// on the cache. See GenerateOptions and generator.Walk for how the
+// generators run.
+//
+// A generator that fails is a *helmerrors.GeneratorError.
+func Generate(chartName, homedir string, opts GenerateOptions) error {
+	c := newClient(homedir, nil)
+	c.Config = mustConfig(homedir)
+	_, err := c.Generate(chartName, opts)
+	return err
+}
+
+// Generate is like the package-level Generate. It returns the number of
+// generators that were found.
+func (c *Client) Generate(chartName string, opts GenerateOptions) (count int, err error) {
+	defer c.completed(OpGenerate, chartName, c.started(OpGenerate, chartName), &err)
+	if err := opts.Values.Check(); err != nil {
+		return 0, err
+	}
+	homedir := c.Home
 	if abs, err := filepath.Abs(homedir); err == nil {
 		homedir = abs
 	}
-	chartPath := util.WorkspaceChartDirectory(homedir, chart)
+	cfg, err := c.config()
+	if err != nil {
+		return 0, err
+	}
+	chartPath := util.WorkspaceChartDirectory(homedir, chartName)
+	unlock, err := c.lockChart(chartName)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
 
-	// Although helmc itself may use the new HELMC_HOME environment variable to optionally define its
-	// home directory, to maintain compatibility with charts created for the ORIGINAL helm, we
-	// continue to support expansion of these "legacy" environment variables, including HELM_HOME.
-	os.Setenv("HELM_HOME", homedir)
-	os.Setenv("HELM_DEFAULT_REPO", mustConfig(homedir).Repos.Default)
-	os.Setenv("HELM_FORCE_FLAG", strconv.FormatBool(force))
+	env := generateEnv(homedir, chartName, chartPath, cfg.Repos.Default, opts.Force, opts.SkipSchema, opts.Values)
+	if !opts.SkipSchema && !opts.DryRun {
+		if err := checkValues(chartPath, opts.Exclude, env, opts.Values); err != nil {
+			return 0, err
+		}
+	}
+	if err := c.generatorValues(env, chartName, chartPath, opts.Values); err != nil {
+		return 0, err
+	}
+	counts, err := generator.Walk(util.Context(), chartPath, generator.Options{
+		Exclude:     opts.Exclude,
+		Force:       opts.Force,
+		DryRun:      opts.DryRun,
+		Strict:      opts.Strict,
+		Verbose:     opts.Verbose,
+		Incremental: opts.Incremental,
+		Jobs:        opts.Jobs,
+		Timeout:     opts.Timeout,
+		Policy:      c.generatorPolicy(cfg),
+		Env:         env,
+		Log:         c.Log,
+		Hooks:       c.generatorHooks(),
+	})
+	count = counts.Total()
+	if err != nil {
+		var ge *helmerrors.GeneratorError
+		if errors.As(err, &ge) {
+			ge.Chart = chartName
+		}
+		return count, fmt.Errorf("Failed to complete generation: %w", err)
+	}
+	if opts.DryRun {
+		c.Log.Info("Found %d generators.", count)
+		return count, nil
+	}
+	c.Log.Info("Ran %d generators.", count)
+	return count, nil
+}
+
+// GeneratorPlan is what 'helmc generate --dry-run' reports: the generators
+// of a chart that would run, in the order that they would run in.
+type GeneratorPlan struct {
+	Chart      string            `json:"chart"`
+	Generators []*generator.Step `json:"generators"`
+}
 
-	count, err := generator.Walk(chartPath, exclude, force)
+// print prints the plan as a table, or as JSON if format is "json".
+func (p *GeneratorPlan) print(l *log.Logger, format string) error {
+	switch format {
+	case "json":
+		b, err := json.MarshalIndent(p, "", "  ")
+		if err != nil {
+			return err
+		}
+		l.Msg(string(b))
+		return nil
+	case "", "table":
+		if len(p.Generators) == 0 {
+			l.Info("Chart %s has no generators to run.", p.Chart)
+			return nil
+		}
+		t := &output.Table{Header: []string{"FILE", "AFTER", "COMMAND"}, Flex: 2}
+		for _, s := range p.Generators {
+			after := "-"
+			if len(s.After) > 0 {
+				after = strings.Join(s.After, ",")
+			}
+			t.Add(s.File, after, s.Command)
+		}
+		output.New(l.Out()).Table(t)
+		for _, s := range p.Generators {
+			if len(s.Undefined) > 0 {
+				l.Warn("The generator of %s uses variables that are not defined, and expand to the empty string: %s", s.File, strings.Join(s.Undefined, ", "))
+			}
+		}
+		l.Info("Found %d generators.", len(p.Generators))
+		return nil
+	}
+	return fmt.Errorf("unknown output format %q", format)
+}
+
+// GeneratePlan prints the generators of a chart that Generate would run,
+// with their expanded commands, in the order that they would run in, as a
+// table or, if format is "json", as JSON. Nothing is run, whatever
+// opts.DryRun is.
+func GeneratePlan(chartName, homedir string, opts GenerateOptions, format string) error {
+	c := newClient(homedir, nil)
+	c.Config = mustConfig(homedir)
+	plan, err := c.GeneratePlan(chartName, opts)
 	if err != nil {
-		log.Die("Failed to complete generation: %s", err)
+		return err
+	}
+	return plan.print(c.Log, format)
+}
+
+// GeneratePlan is like the package-level GeneratePlan. It returns the plan,
+// instead of printing it.
+func (c *Client) GeneratePlan(chartName string, opts GenerateOptions) (*GeneratorPlan, error) {
+	if err := opts.Values.Check(); err != nil {
+		return nil, err
 	}
-	log.Info("Ran %d generators.", count)
+	homedir := c.Home
+	if abs, err := filepath.Abs(homedir); err == nil {
+		homedir = abs
+	}
+	cfg, err := c.config()
+	if err != nil {
+		return nil, err
+	}
+	chartPath := util.WorkspaceChartDirectory(homedir, chartName)
+	if _, err := os.Stat(chartPath); err != nil {
+		return nil, fmt.Errorf("Could not find chart %s in the workspace: %s", chartName, err)
+	}
+	env := generateEnv(homedir, chartName, chartPath, cfg.Repos.Default, opts.Force, opts.SkipSchema, opts.Values)
+	if err := c.generatorValues(env, chartName, chartPath, opts.Values); err != nil {
+		return nil, err
+	}
+	steps, err := generator.WalkPlan(chartPath, opts.Exclude, opts.Strict, env)
+	if err != nil {
+		return nil, fmt.Errorf("Could not plan the generators: %w", err)
+	}
+	return &GeneratorPlan{Chart: chartName, Generators: steps}, nil
+}
+
+// GenerateClean deletes the files that the generators of a chart wrote, as
+// its generator.StateFile records them: those whose generator is gone, or no
+// longer declares them, and those that 'helmc generate' would write again.
+// Files that were edited since they were generated are kept, unless force
+// is true. If dryRun is true, the files are listed, but not deleted.
+func GenerateClean(chartName, homedir string, force, dryRun bool) error {
+	c := newClient(homedir, nil)
+	c.Config = mustConfig(homedir)
+	_, err := c.GenerateClean(chartName, force, dryRun)
+	return err
+}
+
+// GenerateClean is like the package-level GenerateClean. It returns the
+// files that were deleted, or that would be with dryRun, relative to the
+// chart.
+func (c *Client) GenerateClean(chartName string, force, dryRun bool) ([]string, error) {
+	homedir := c.Home
+	if abs, err := filepath.Abs(homedir); err == nil {
+		homedir = abs
+	}
+	cfg, err := c.config()
+	if err != nil {
+		return nil, err
+	}
+	chartPath := util.WorkspaceChartDirectory(homedir, chartName)
+	if _, err := os.Stat(chartPath); err != nil {
+		return nil, fmt.Errorf("Could not find chart %s in the workspace: %s", chartName, err)
+	}
+	unlock, err := c.lockChart(chartName)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	state, err := generator.LoadState(chartPath)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read %s: %s", generator.StateFile, err)
+	}
+	if len(state) == 0 {
+		c.Log.Info("Chart %s has no generated files on record. Only the files of an -o, --out, or --output of a generator are.", chartName)
+		return nil, nil
+	}
+	env := generateEnv(homedir, chartName, chartPath, cfg.Repos.Default, false, false, ValueSources{})
+	declared, err := generator.Declared(chartPath, nil, env)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read the generators: %s", err)
+	}
+
+	deleted, kept := []string{}, 0
+	for _, o := range state.Sorted() {
+		file := filepath.Join(chartPath, filepath.FromSlash(o.Path))
+		if _, err := os.Stat(file); os.IsNotExist(err) {
+			delete(state, o.Path)
+			continue
+		}
+		why := "its generator would write it again"
+		if src, ok := declared[o.Path]; !ok {
+			why = fmt.Sprintf("the generator of %s no longer writes it", o.Source)
+			if _, err := os.Stat(filepath.Join(chartPath, filepath.FromSlash(o.Source))); os.IsNotExist(err) {
+				why = fmt.Sprintf("its generator, %s, is gone", o.Source)
+			}
+		} else if src != o.Source {
+			why = fmt.Sprintf("the generator of %s would write it again", src)
+		}
+		if o.Edited(chartPath) && !force {
+			c.Log.Warn("Keeping %s, which was edited since it was generated. Delete it by hand, or use --force.", o.Path)
+			kept++
+			continue
+		}
+		if dryRun {
+			c.Log.Info("Would delete %s: %s.", o.Path, why)
+			deleted = append(deleted, o.Path)
+			continue
+		}
+		if err := os.Remove(file); err != nil {
+			return deleted, fmt.Errorf("Could not delete %s: %s", o.Path, err)
+		}
+		c.Log.Info("Deleted %s: %s.", o.Path, why)
+		deleted = append(deleted, o.Path)
+		delete(state, o.Path)
+	}
+	if dryRun {
+		c.Log.Info("Would delete %d generated files, and keep %d.", len(deleted), kept)
+		return deleted, nil
+	}
+	if err := state.Save(chartPath); err != nil {
+		return deleted, fmt.Errorf("Could not write %s: %s", generator.StateFile, err)
+	}
+	c.Log.Info("Deleted %d generated files, and kept %d. Run 'helmc generate %s' to write them again.", len(deleted), kept, chartName)
+	return deleted, nil
+}
+
+// GenerateWatch runs the generators of a chart, as Generate does, and then
+// runs again those whose files change, until helmc is interrupted. Runs that
+// fail are logged, and watching goes on. When helmc is interrupted, it prints
+// how many runs there were. opts.DryRun and opts.Incremental do not apply.
+func GenerateWatch(chartName, homedir string, opts GenerateOptions) {
+	c := newClient(homedir, nil)
+	c.Config = mustConfig(homedir)
+	w, err := c.Watcher(chartName, opts)
+	if err != nil {
+		log.Die("%s", err)
+	}
+
+	// Watching only ends when helmc is stopped, which exits once its cleanups
+	// are done, so the summary is one of them. It is never unregistered.
+	start := time.Now()
+	done := make(chan struct{})
+	util.OnInterrupt(func() {
+		select {
+		case <-done:
+		case <-time.After(util.StopGrace):
+		}
+		c.Log.Info("Watched %s for %s: %s.", chartName, time.Since(start).Round(time.Second), w.Summary())
+	})
+
+	err = w.Watch(util.Context())
+	close(done)
+	if err != nil {
+		log.Die("Stopped watching: %s", err)
+	}
+}
+
+// Watcher returns the generator.Watcher of GenerateWatch. It takes the lock
+// of the chart for each run, and does not watch the files of the chart's
+// .helmignore.
+func (c *Client) Watcher(chartName string, opts GenerateOptions) (*generator.Watcher, error) {
+	homedir := c.Home
+	if abs, err := filepath.Abs(homedir); err == nil {
+		homedir = abs
+	}
+	cfg, err := c.config()
+	if err != nil {
+		return nil, err
+	}
+	chartPath := util.WorkspaceChartDirectory(homedir, chartName)
+	if _, err := os.Stat(chartPath); err != nil {
+		return nil, fmt.Errorf("Could not find chart %s in the workspace: %s", chartName, err)
+	}
+	ig, err := chart.LoadIgnore(chartPath)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read %s: %s", chart.IgnoreFile, err)
+	}
+	env := generateEnv(homedir, chartName, chartPath, cfg.Repos.Default, opts.Force, opts.SkipSchema, opts.Values)
+	if err := c.generatorValues(env, chartName, chartPath, opts.Values); err != nil {
+		return nil, err
+	}
+	return &generator.Watcher{
+		Dir:     chartPath,
+		Exclude: opts.Exclude,
+		Force:   opts.Force,
+		Strict:  opts.Strict,
+		Verbose: opts.Verbose,
+		Jobs:    opts.Jobs,
+		Timeout: opts.Timeout,
+		Policy:  c.generatorPolicy(cfg),
+		Env:     env,
+		Log:     c.Log,
+		Hooks:   c.generatorHooks(),
+		Ignored: ig.Ignored,
+		Lock:    func() (func(), error) { return c.lockChart(chartName) },
+	}, nil
+}
+
+// ExplainGenerator prints how Generate would run the generator of a file of
+// a chart, as a shell script for each of its commands that runs it by hand.
+// Nothing is run.
+//
+// file is relative to the chart. Of opts, only Exclude, Force, Strict,
+// SkipSchema, and Values apply.
+func ExplainGenerator(chartName, homedir, file string, opts GenerateOptions) {
+	c := newClient(homedir, nil)
+	c.Config = mustConfig(homedir)
+	invs, err := c.ExplainGenerator(chartName, file, opts)
+	if err != nil {
+		log.Die("%s", err)
+	}
+	for i, inv := range invs {
+		if i > 0 {
+			fmt.Fprintln(log.Stdout)
+		}
+		fmt.Fprint(log.Stdout, inv.Script())
+	}
+}
+
+// ExplainGenerator is like the package-level ExplainGenerator. It returns
+// the invocations of the commands of the generator, instead of printing them.
+func (c *Client) ExplainGenerator(chartName, file string, opts GenerateOptions) ([]*generator.Invocation, error) {
+	homedir := c.Home
+	if abs, err := filepath.Abs(homedir); err == nil {
+		homedir = abs
+	}
+	cfg, err := c.config()
+	if err != nil {
+		return nil, err
+	}
+	chartPath := util.WorkspaceChartDirectory(homedir, chartName)
+	if _, err := os.Stat(chartPath); err != nil {
+		return nil, fmt.Errorf("Could not find chart %s in the workspace: %s", chartName, err)
+	}
+	env := generateEnv(homedir, chartName, chartPath, cfg.Repos.Default, opts.Force, opts.SkipSchema, opts.Values)
+	if err := c.generatorValues(env, chartName, chartPath, opts.Values); err != nil {
+		return nil, err
+	}
+	return generator.Explain(chartPath, file, opts.Exclude, opts.Force, opts.Strict, env)
+}
+
+// generatorPolicy returns the policy of the generators that c runs: the
+// commands of the generate.allow of cfg, and AllowGenerators. Without
+// either, it is nil, which allows only the templates that helmc renders.
+func (c *Client) generatorPolicy(cfg *config.Configfile) *generator.Policy {
+	var allow []string
+	if cfg != nil && cfg.Generate != nil {
+		allow = append(allow, cfg.Generate.Allow...)
+	}
+	allow = append(allow, c.AllowGenerators...)
+	if len(allow) == 0 {
+		return nil
+	}
+	return &generator.Policy{Allow: allow}
+}
+
+// generatorValues gives the generators of a chart its values, rendered into
+// the values directory of the home directory; see ValueSources.generatorEnv.
+func (c *Client) generatorValues(env map[string]string, chartName, chartPath string, sources ValueSources) error {
+	if err := sources.generatorEnv(env, chartPath, helmpath.Home(c.Home).Values(chartName+".yaml")); err != nil {
+		return fmt.Errorf("Could not render the values of %s: %s", chartName, err)
+	}
+	return nil
+}
+
+// generateEnv returns the environment of the generators of a chart.
+func generateEnv(homedir, chartName, chartPath, defaultRepo string, force, skipSchema bool, sources ValueSources) map[string]string {
+	ec := &util.EnvChart{Name: chartName, Path: chartPath}
+	if cf, err := chart.LoadChartfile(filepath.Join(chartPath, Chartfile)); err == nil {
+		ec.Version = cf.Version
+	}
+	// Although helmc itself may use the new HELMC_HOME environment variable to optionally define its
+	// home directory, to maintain compatibility with charts created for the ORIGINAL helm, we
+	// continue to support expansion of these "legacy" environment variables, including HELM_HOME.
+	env := util.HelmEnv(helmpath.Home(homedir), ec)
+	env["HELM_DEFAULT_REPO"] = defaultRepo
+	env["HELM_FORCE_FLAG"] = strconv.FormatBool(force)
+	env["HELM_SKIP_SCHEMA"] = strconv.FormatBool(skipSchema)
+	sources.env(env)
+	return env
 }
@@ -1,9 +1,17 @@
 package action
 
 import (
+	"encoding/json"
+	"errors"
 	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/helm/helm-classic/chart"
+	"github.com/helm/helm-classic/config"
+	"github.com/helm/helm-classic/generator"
 	"github.com/helm/helm-classic/test"
 	"github.com/helm/helm-classic/util"
 )
@@ -12,9 +20,9 @@ func TestGenerate(t *testing.T) {
 	ch := "generate"
 	homedir := test.CreateTmpHome()
 	test.FakeUpdate(homedir)
-	Fetch(ch, ch, homedir)
+	Fetch(ch, ch, homedir, FetchOptions{})
 
-	Generate(ch, homedir, []string{"ignore"}, true)
+	Generate(ch, homedir, GenerateOptions{Exclude: []string{"ignore"}, Force: true})
 
 	// Now we should be able to load and read the `pod.yaml` file.
 	path := util.WorkspaceChartDirectory(homedir, "generate/manifests/pod.yaml")
@@ -26,3 +34,270 @@ func TestGenerate(t *testing.T) {
 	test.ExpectContains(t, pod, "image: ozo")
 	test.ExpectContains(t, pod, "name: www-server")
 }
+
+func TestGenerateInProcess(t *testing.T) {
+	ch := "generate"
+	homedir := test.CreateTmpHome()
+	test.FakeUpdate(homedir)
+	Fetch(ch, ch, homedir, FetchOptions{})
+
+	// The 'helm tpl' generator is rendered without executing helmc.
+	defer os.Setenv("PATH", os.Getenv("PATH"))
+	os.Setenv("PATH", homedir)
+	if err := Generate(ch, homedir, GenerateOptions{Exclude: []string{"ignore"}, Force: true}); err != nil {
+		t.Fatal(err)
+	}
+	d, err := ioutil.ReadFile(util.WorkspaceChartDirectory(homedir, ch, "manifests", "pod.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.ExpectContains(t, string(d), "image: ozo")
+}
+
+func TestGeneratePolicy(t *testing.T) {
+	ch := "generate"
+	homedir := test.CreateTmpHome()
+	test.FakeUpdate(homedir)
+	Fetch(ch, ch, homedir, FetchOptions{})
+
+	c := &Client{Home: homedir}
+	cfg, err := c.config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Generate = &config.Generate{Allow: []string{"sed"}}
+	_, err = c.Generate(ch, GenerateOptions{Exclude: []string{"ignore"}, Force: true})
+	var pe *generator.PolicyError
+	if !errors.As(err, &pe) || pe.File != "tpl/pod.tpl.yaml" {
+		t.Fatalf("Expected the template to be refused, got %v", err)
+	}
+
+	// --allow-generators adds to the configuration.
+	c.AllowGenerators = []string{"tpl"}
+	if n, err := c.Generate(ch, GenerateOptions{Exclude: []string{"ignore"}, Force: true}); err != nil || n != 1 {
+		t.Errorf("Expected the template to be allowed, got %d, %v", n, err)
+	}
+}
+
+func TestGenerateDefaultPolicy(t *testing.T) {
+	ch := "generate"
+	homedir := test.CreateTmpHome()
+	test.FakeUpdate(homedir)
+	Fetch(ch, ch, homedir, FetchOptions{})
+	dir := util.WorkspaceChartDirectory(homedir, ch)
+	ioutil.WriteFile(filepath.Join(dir, "tpl", "ran.sh"), []byte("#helm:generate sh -c 'touch ran'\n"), 0644)
+
+	// Without a configured policy, only the templates of helmc may run.
+	c := &Client{Home: homedir}
+	_, err := c.Generate(ch, GenerateOptions{Exclude: []string{"ignore"}, Force: true})
+	var pe *generator.PolicyError
+	if !errors.As(err, &pe) || pe.File != "tpl/ran.sh" {
+		t.Fatalf("Expected sh to be refused, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "ran")); err == nil {
+		t.Error("Expected no generator to run")
+	}
+
+	// --allow-generators=all is the way to run every command.
+	c.AllowGenerators = []string{generator.AllowAll}
+	if n, err := c.Generate(ch, GenerateOptions{Exclude: []string{"ignore"}, Force: true}); err != nil || n != 2 {
+		t.Errorf("Expected both generators to run, got %d, %v", n, err)
+	}
+}
+
+func TestGenerateDryRun(t *testing.T) {
+	ch := "generate"
+	homedir := test.CreateTmpHome()
+	test.FakeUpdate(homedir)
+	Fetch(ch, ch, homedir, FetchOptions{})
+
+	out := test.CaptureOutput(func() {
+		Generate(ch, homedir, GenerateOptions{Exclude: []string{"ignore"}, Force: true, DryRun: true})
+	})
+	test.ExpectContains(t, out, "Would run helm tpl")
+	test.ExpectContains(t, out, filepath.Join("generate", "tpl", "pod.tpl.yaml"))
+	test.ExpectContains(t, out, "Found 1 generators.")
+
+	// Nothing is generated.
+	path := util.WorkspaceChartDirectory(homedir, ch, "manifests", "pod.yaml")
+	d, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(d), "image: ozo") {
+		t.Errorf("Expected a dry run to leave %s alone", path)
+	}
+}
+
+func TestGeneratePlan(t *testing.T) {
+	ch := "generate"
+	homedir := test.CreateTmpHome()
+	test.FakeUpdate(homedir)
+	Fetch(ch, ch, homedir, FetchOptions{})
+	dir := util.WorkspaceChartDirectory(homedir, ch)
+
+	out := test.CaptureOutput(func() {
+		if err := GeneratePlan(ch, homedir, GenerateOptions{Exclude: []string{"ignore"}}, ""); err != nil {
+			t.Error(err)
+		}
+	})
+	test.ExpectContains(t, out, "FILE              AFTER  COMMAND")
+	test.ExpectContains(t, out, "tpl/pod.tpl.yaml  -      helm tpl -o manifests/pod.yaml -d "+dir+"/values.toml "+dir+"/tpl/pod.tpl.yaml")
+	test.ExpectContains(t, out, "Found 1 generators.")
+
+	out = test.CaptureOutput(func() {
+		if err := GeneratePlan(ch, homedir, GenerateOptions{Exclude: []string{"ignore"}}, "json"); err != nil {
+			t.Error(err)
+		}
+	})
+	// The debug messages go to stderr, which is captured too.
+	var plan GeneratorPlan
+	if err := json.Unmarshal([]byte(out[strings.Index(out, "{"):]), &plan); err != nil {
+		t.Fatalf("Expected JSON, got %q: %s", out, err)
+	}
+	if len(plan.Generators) != 1 || plan.Generators[0].File != "tpl/pod.tpl.yaml" || plan.Generators[0].Outputs[0] != "manifests/pod.yaml" {
+		t.Errorf("Expected the generator of tpl/pod.tpl.yaml, got %+v", plan.Generators)
+	}
+
+	if err := GeneratePlan(ch, homedir, GenerateOptions{}, "yaml"); err == nil {
+		t.Error("Expected an unknown format to be an error")
+	}
+
+	// Nothing is generated.
+	d, err := ioutil.ReadFile(filepath.Join(dir, "manifests", "pod.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(d), "image: ozo") {
+		t.Error("Expected a plan to generate nothing")
+	}
+}
+
+func TestExplainGenerator(t *testing.T) {
+	ch := "generate"
+	homedir := test.CreateTmpHome()
+	test.FakeUpdate(homedir)
+	Fetch(ch, ch, homedir, FetchOptions{})
+
+	out := test.CaptureOutput(func() {
+		ExplainGenerator(ch, homedir, "tpl/pod.tpl.yaml", GenerateOptions{})
+	})
+	dir := util.WorkspaceChartDirectory(homedir, ch)
+	test.ExpectContains(t, out, "# Directive: helm:generate helm tpl -o manifests/pod.yaml -d $HELM_GENERATE_DIR/values.toml $HELM_GENERATE_FILE")
+	test.ExpectContains(t, out, "export HELM_CHART_NAME='generate'")
+	test.ExpectContains(t, out, "helmc tpl -o manifests/pod.yaml -d "+dir+"/values.toml "+dir+"/tpl/pod.tpl.yaml")
+
+	c := &Client{Home: homedir}
+	if _, err := c.ExplainGenerator(ch, "ignore/ignoreme.yaml", GenerateOptions{Exclude: []string{"ignore"}}); err == nil || !strings.Contains(err.Error(), "--exclude ignore") {
+		t.Errorf("Expected the excluding rule, got %v", err)
+	}
+}
+
+func TestGenerateClean(t *testing.T) {
+	ch := "generate"
+	homedir := test.CreateTmpHome()
+	test.FakeUpdate(homedir)
+	Fetch(ch, ch, homedir, FetchOptions{})
+	dir := util.WorkspaceChartDirectory(homedir, ch)
+	pod := filepath.Join(dir, "manifests", "pod.yaml")
+
+	Generate(ch, homedir, GenerateOptions{Exclude: []string{"ignore"}, Force: true})
+	state, err := ioutil.ReadFile(filepath.Join(dir, generator.StateFile))
+	if err != nil {
+		t.Fatalf("Expected the output of the generator to be recorded: %s", err)
+	}
+	test.ExpectContains(t, string(state), "manifests/pod.yaml\ttpl/pod.tpl.yaml\tsha256:")
+
+	// A file that was edited is kept, and lint warns about it.
+	ioutil.WriteFile(pod, []byte("kind: Pod\n"), 0644)
+	out := test.CaptureOutput(func() {
+		Lint(dir, homedir, LintOptions{})
+	})
+	test.ExpectContains(t, out, "manifests/pod.yaml was edited since the generator of tpl/pod.tpl.yaml wrote it")
+	out = test.CaptureOutput(func() {
+		GenerateClean(ch, homedir, false, false)
+	})
+	test.ExpectContains(t, out, "Keeping manifests/pod.yaml, which was edited since it was generated")
+	if _, err := os.Stat(pod); err != nil {
+		t.Errorf("Expected the edited file to be kept: %s", err)
+	}
+
+	// A dry run deletes nothing.
+	out = test.CaptureOutput(func() {
+		GenerateClean(ch, homedir, true, true)
+	})
+	test.ExpectContains(t, out, "Would delete manifests/pod.yaml: its generator would write it again.")
+	if _, err := os.Stat(pod); err != nil {
+		t.Errorf("Expected a dry run to delete nothing: %s", err)
+	}
+
+	// Once its generator is gone, the output is deleted, and so is the state.
+	Generate(ch, homedir, GenerateOptions{Exclude: []string{"ignore"}, Force: true})
+	os.Remove(filepath.Join(dir, "tpl", "pod.tpl.yaml"))
+	out = test.CaptureOutput(func() {
+		GenerateClean(ch, homedir, false, false)
+	})
+	test.ExpectContains(t, out, "Deleted manifests/pod.yaml: its generator, tpl/pod.tpl.yaml, is gone.")
+	test.ExpectContains(t, out, "Deleted 1 generated files, and kept 0.")
+	for _, f := range []string{pod, filepath.Join(dir, generator.StateFile)} {
+		if _, err := os.Stat(f); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be deleted, got %v", f, err)
+		}
+	}
+}
+
+func TestGenerateValuesSchema(t *testing.T) {
+	homedir := test.CreateTmpHome()
+	defer os.RemoveAll(homedir)
+	dir := util.WorkspaceChartDirectory(homedir, "typed")
+	files := map[string]string{
+		Chartfile:         "name: typed\nversion: 0.1.0\nvalueTypes:\n  image:\n    repository: string\n    tag?: string\n  replicas: integer\n  password: string\n",
+		chart.ValuesFile:  "image: {repository: redis}\nreplicas: 1\n",
+		"tpl/pod.yaml":    "#helm:generate helm tpl -o manifests/pod.yaml $HELM_GENERATE_FILE\nimage: {{.image.repository}}\npassword: {{.password}}\n",
+		"tpl/first.sh":    "#helm:generate touch ran\n",
+		"manifests/.keep": "",
+	}
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(p), 0755)
+		if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	defer func(a []string) { Defaults.AllowGenerators = a }(Defaults.AllowGenerators)
+	Defaults.AllowGenerators = []string{"tpl", "touch"}
+
+	// Nothing runs if the values do not conform.
+	err := Generate("typed", homedir, GenerateOptions{Force: true, Values: ValueSources{Set: []string{"replicas=three"}}})
+	if err == nil {
+		t.Fatal("Expected the values to be rejected")
+	}
+	test.ExpectContains(t, err.Error(), "do not conform to the valueTypes of "+filepath.Join(dir, Chartfile))
+	test.ExpectContains(t, err.Error(), "password: is required, but not set")
+	test.ExpectContains(t, err.Error(), "replicas: expected integer, got string")
+	if _, err := os.Stat(filepath.Join(dir, "ran")); err == nil {
+		t.Error("Expected no generator to run")
+	}
+
+	// A key from a source only has to be set, since it is not read until
+	// the template is rendered.
+	defer os.Unsetenv("HELMC_TEST_PASSWORD")
+	os.Setenv("HELMC_TEST_PASSWORD", "hunter2")
+	if err := Generate("typed", homedir, GenerateOptions{Force: true, Values: ValueSources{SetFrom: []string{"password=env:HELMC_TEST_PASSWORD"}}}); err != nil {
+		t.Fatalf("Expected the values to conform, got %s", err)
+	}
+	d, err := ioutil.ReadFile(filepath.Join(dir, "manifests", "pod.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.ExpectContains(t, string(d), "password: hunter2")
+
+	os.Remove(filepath.Join(dir, "ran"))
+	if err := Generate("typed", homedir, GenerateOptions{Exclude: []string{"tpl/pod.yaml"}, Force: true, SkipSchema: true, Values: ValueSources{Set: []string{"replicas=three"}}}); err != nil {
+		t.Errorf("Expected --skip-schema to generate, got %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "ran")); err != nil {
+		t.Error("Expected the generators to run with --skip-schema")
+	}
+}
@@ -1,8 +1,28 @@
 package action
 
-import "github.com/helm/helm-classic/log"
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/helm/helm-classic/log"
+)
 
 func init() {
 	// Turn on debug output, convert os.Exit(1) to panic()
 	log.IsDebugging = true
+	// Never download Kubernetes schemas.
+	KubeSchemaURL = ""
+}
+
+// expectError fails unless err matches target, and its message contains msg.
+func expectError(t *testing.T, err, target error, msg string) {
+	t.Helper()
+	if !errors.Is(err, target) {
+		t.Errorf("Expected %q error, got %v", target, err)
+		return
+	}
+	if !strings.Contains(err.Error(), msg) {
+		t.Errorf("Expected error to contain %q, got %q", msg, err)
+	}
 }
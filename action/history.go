@@ -0,0 +1,192 @@
+package action
+
+import (
+	"errors"
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/helm/helm-classic/audit"
+	"github.com/helm/helm-classic/chart"
+	"github.com/helm/helm-classic/helmpath"
+	"github.com/helm/helm-classic/history"
+	"github.com/helm/helm-classic/kubectl"
+	"github.com/helm/helm-classic/log"
+	helm "github.com/helm/helm-classic/util"
+)
+
+// History prints the revisions of the release of a workspace chart, oldest
+// first.
+//
+// The format is "json", "yaml", or "" for a table with one line per revision.
+func History(name, home, format string) error {
+	c := newClient(home, nil)
+	revs, err := c.History(name)
+	if err != nil {
+		return err
+	}
+	if format != "" {
+		return printFormatted(revs, format)
+	}
+	if len(revs) == 0 {
+		log.Info("Chart %s has never been installed.", name)
+		return nil
+	}
+
+	w := tabwriter.NewWriter(log.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "REVISION\tTIME\tCHART\tVERSION\tNAMESPACE\tSTATUS\tDESCRIPTION")
+	for _, r := range revs {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n", r.Revision, r.Time.Local().Format("2006-01-02 15:04:05"), r.Chart, dash(r.Version), dash(r.Namespace), r.Status, r.Description)
+	}
+	w.Flush()
+	return nil
+}
+
+// History returns the revisions of the release of a workspace chart, oldest
+// first.
+func (c *Client) History(name string) ([]*history.Revision, error) {
+	revs, err := history.List(c.releasesPath(), name)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read the history of %s: %s", name, err)
+	}
+	return revs, nil
+}
+
+// Rollback sends the manifests of a revision of the release of a workspace
+// chart to Kubernetes again, and records them as a new revision.
+//
+// - name is the name of the chart in the workspace
+// - home is the home directory for the user
+// - revision is the revision to roll back to, as 'helmc history' lists it
+func Rollback(name, home string, revision int, client kubectl.Runner) error {
+	CheckClientPrereqs(client)
+	c := newClient(home, client)
+	c.Config = mustConfig(home)
+	_, err := c.Rollback(name, revision)
+	return err
+}
+
+// Rollback is like the package-level Rollback. It returns the outcome for
+// each resource, even if the rollback fails.
+//
+// The manifests of the revision are applied, as by 'kubectl apply', into the
+// namespace of the revision, so that the resources that exist are changed
+// back. Hooks are not run again. Resources that the latest revision has and
+// the revision rolled back to does not are left alone, and listed.
+func (c *Client) Rollback(name string, revision int) (res *InstallResult, err error) {
+	defer c.completed(OpRollback, name, c.started(OpRollback, name), &err)
+	revs, err := c.History(name)
+	if err != nil {
+		return nil, err
+	}
+	if len(revs) == 0 {
+		return nil, fmt.Errorf("Chart %s has never been installed, so it has no revision to roll back to.", name)
+	}
+	r, err := history.Get(c.releasesPath(), name, revision)
+	if errors.Is(err, history.ErrNotFound) {
+		return nil, fmt.Errorf("%s. See 'helmc history %s'.", err, name)
+	} else if err != nil {
+		return nil, err
+	}
+	if r.Status == history.Failed {
+		c.Log.Warn("Revision %d of %s failed: %s. Rolling back to it sends the manifests it had.", r.Revision, name, r.Error)
+	}
+
+	ops := []*PlanOperation{}
+	kept := map[string]bool{}
+	for _, m := range r.Manifests {
+		kept[m.Kind+"/"+m.Name] = true
+		if m.Hook != "" {
+			c.Log.Debug("Not running the %s hook %s %s again", m.Hook, m.Kind, m.Name)
+			continue
+		}
+		ops = append(ops, &PlanOperation{Op: ModeApply, Kind: m.Kind, Name: m.Name, Namespace: m.Namespace, Manifest: m.Manifest})
+	}
+	for _, m := range revs[len(revs)-1].Manifests {
+		if !kept[m.Kind+"/"+m.Name] && m.Hook == "" {
+			c.Log.Warn("%s %s is not in revision %d, and is left in Kubernetes. See 'helmc prune %s'.", m.Kind, m.Name, r.Revision, name)
+		}
+	}
+
+	defer c.useRetryEvents()()
+	c.Log.Info("Rolling %s back to revision %d, %s %s ...", name, r.Revision, r.Chart, r.Version)
+	res, err = c.uploadManifests(r.Chart, ops, r.Namespace, false)
+	if _, dry := c.Kube.(kubectl.PrintRunner); !dry {
+		e := &audit.Entry{
+			Operation: audit.OpRollback,
+			Chart:     r.Chart,
+			Version:   r.Version,
+			Digest:    r.Digest,
+			Namespace: r.Namespace,
+			Resources: []*audit.Resource{},
+			Outcome:   audit.Succeeded,
+		}
+		id := c.kube().ActiveIdentity()
+		e.Time, e.User, e.Context, e.Cluster = time.Now().UTC(), id.User, id.Context, id.Cluster
+		for _, rr := range res.Resources {
+			e.Resources = append(e.Resources, &audit.Resource{Kind: rr.Kind, Name: rr.Name, Namespace: rr.Namespace, Status: rr.Status, Error: rr.Error})
+		}
+		c.recordAudit(e, err)
+		c.recordRelease(&history.Revision{
+			Name:        name,
+			Chart:       r.Chart,
+			Version:     r.Version,
+			Digest:      r.Digest,
+			Namespace:   r.Namespace,
+			Description: fmt.Sprintf("rollback to %d", r.Revision),
+		}, ops, err)
+		if perr := res.print(c.Log, ""); perr != nil {
+			c.Log.Err("Could not print install summary: %s", perr)
+		}
+	}
+	if err != nil {
+		return res, fmt.Errorf("Failed to roll back: %w", err)
+	}
+	c.Log.Info("Done")
+	return res, nil
+}
+
+// releasesPath returns the directory of the release histories.
+func (c *Client) releasesPath() string {
+	return helmpath.Home(c.stateDir()).Releases()
+}
+
+// installRelease starts the revision of an install of the workspace chart
+// name, which was loaded as ch.
+func (c *Client) installRelease(ch *chart.Chart, name string, opts InstallOptions) *history.Revision {
+	r := &history.Revision{
+		Name:        name,
+		Chart:       ch.Chartfile.Name,
+		Version:     ch.Chartfile.Version,
+		Namespace:   opts.Namespace,
+		Description: "install (" + opts.Mode + ")",
+	}
+	if d, err := chart.Digest(helm.WorkspaceChartDirectory(c.Home, name)); err == nil {
+		r.Digest = d
+	}
+	return r
+}
+
+// recordRelease adds r to the history of its release, with the manifests of
+// the operations that were sent and the outcome that err gives.
+//
+// Like the audit log, a history that cannot be written is a warning, since
+// the operation has already happened.
+func (c *Client) recordRelease(r *history.Revision, ops []*PlanOperation, err error) {
+	r.Time, r.Status = time.Now().UTC(), history.Deployed
+	if err != nil {
+		r.Status, r.Error = history.Failed, err.Error()
+	}
+	r.Manifests = []*history.Manifest{}
+	for _, op := range ops {
+		if op.Op == OpDelete {
+			continue
+		}
+		r.Manifests = append(r.Manifests, &history.Manifest{Kind: op.Kind, Name: op.Name, Namespace: op.Namespace, Hook: op.Hook, Manifest: op.Manifest})
+	}
+	if err := history.Record(c.releasesPath(), r); err != nil {
+		c.Log.Warn("Could not record the release of %s: %s", r.Name, err)
+		return
+	}
+	c.Log.Debug("Recorded revision %d of %s", r.Revision, r.Name)
+}
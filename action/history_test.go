@@ -0,0 +1,53 @@
+package action
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/helm/helm-classic/kubectl"
+	"github.com/helm/helm-classic/test"
+)
+
+func TestRollback(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+	test.FakeUpdate(tmpHome)
+	Fetch("redis", "", tmpHome, FetchOptions{})
+
+	client := &kubectl.FakeRunner{}
+	test.CaptureOutput(func() {
+		for _, ns := range []string{"one", "two"} {
+			if err := Install("redis", tmpHome, InstallOptions{Namespace: ns, Force: true, Annotate: true, Labels: true}, client); err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+	c := newClient(tmpHome, client)
+	revs, err := c.History("redis")
+	if err != nil || len(revs) != 2 || revs[1].Namespace != "two" || len(revs[0].Manifests) == 0 {
+		t.Fatalf("Expected two revisions, got %v, %v", revs, err)
+	}
+
+	client.Calls = nil
+	test.CaptureOutput(func() {
+		err = Rollback("redis", tmpHome, 1, client)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(client.Calls) == 0 || client.Calls[len(client.Calls)-1] != "apply one" {
+		t.Errorf("Expected revision 1 to be applied into its namespace, got %v", client.Calls)
+	}
+	revs, _ = c.History("redis")
+	if len(revs) != 3 || revs[2].Description != "rollback to 1" || revs[2].Namespace != "one" {
+		t.Errorf("Expected the rollback to be revision 3, got %v", revs)
+	}
+
+	test.CaptureOutput(func() {
+		err = Rollback("redis", tmpHome, 9, client)
+	})
+	if err == nil || !strings.Contains(err.Error(), "has no revision 9") {
+		t.Errorf("Expected an unknown revision to fail, got %v", err)
+	}
+}
@@ -0,0 +1,54 @@
+package action
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/helm/helm-classic/helmpath"
+	"github.com/helm/helm-classic/kubectl"
+	"github.com/helm/helm-classic/test"
+)
+
+// TestHomeIsolation runs a full fetch, generate, and install cycle under a
+// temporary home, and checks that nothing outside of it was written.
+func TestHomeIsolation(t *testing.T) {
+	user, _ := ioutil.TempDir("", "helmc-user")
+	defer os.RemoveAll(user)
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+	defer os.Setenv(helmpath.EnvVar, os.Getenv(helmpath.EnvVar))
+	os.Setenv("HOME", user)
+
+	tmp := test.CreateTmpHome()
+	defer os.RemoveAll(tmp)
+	os.Setenv(helmpath.EnvVar, tmp)
+	h, err := helmpath.Resolve("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.String() != tmp {
+		t.Fatalf("Expected home %s, got %s", tmp, h)
+	}
+
+	pp := os.Getenv("PATH")
+	defer os.Setenv("PATH", pp)
+	os.Setenv("PATH", filepath.Join(test.HelmRoot, "testdata")+":"+pp)
+
+	test.FakeUpdate(h.String())
+	Fetch("generate", "", h.String(), FetchOptions{})
+	Generate("generate", h.String(), GenerateOptions{Exclude: []string{"ignore"}, Force: true})
+	if _, err := os.Stat(h.WorkspaceCharts("generate", "manifests", "pod.yaml")); err != nil {
+		t.Errorf("Expected generated manifest in the home: %s", err)
+	}
+	if os.Getenv(helmpath.LegacyEnvVar) == tmp {
+		t.Errorf("Expected the generator environment to be set only for the generator")
+	}
+	test.CaptureOutput(func() {
+		Install("redis", h.String(), InstallOptions{Annotate: true, Labels: true}, kubectl.PrintRunner{})
+	})
+
+	if fi, _ := ioutil.ReadDir(user); len(fi) != 0 {
+		t.Errorf("Expected nothing to be written outside of the home, found %s", fi[0].Name())
+	}
+}
@@ -0,0 +1,227 @@
+package action
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/helm/helm-classic/audit"
+	"github.com/helm/helm-classic/chart"
+	helmerrors "github.com/helm/helm-classic/errors"
+	"github.com/helm/helm-classic/kubectl"
+	"github.com/helm/helm-classic/manifest"
+)
+
+// hookTimeout is how long an install waits for a hook Job or Pod to
+// complete.
+var hookTimeout = 5 * time.Minute
+
+// hookOf returns the hook of a manifest (see manifest.Hook), or "".
+func hookOf(m *manifest.Manifest) string {
+	data, err := m.VersionedObject.JSON()
+	if err != nil {
+		return ""
+	}
+	return manifest.Hook(data)
+}
+
+// orderHooks returns manifests, which are in install order, with the
+// pre-install hooks first and the post-install hooks last. The pre-delete
+// hooks are left out, since an install does not send them. A manifest with an
+// unknown hook is an error.
+func orderHooks(ms []*manifest.Manifest) ([]*manifest.Manifest, error) {
+	var pre, main, post []*manifest.Manifest
+	for _, m := range ms {
+		switch h := hookOf(m); h {
+		case manifest.HookPreInstall:
+			pre = append(pre, m)
+		case manifest.HookPostInstall:
+			post = append(post, m)
+		case manifest.HookPreDelete:
+			// Uninstall sends them.
+		default:
+			if err := manifest.CheckHook(h); err != nil {
+				return nil, fmt.Errorf("%s %s (%s) has an %s", m.Kind, m.Name, m.Source, err)
+			}
+			main = append(main, m)
+		}
+	}
+	return append(append(pre, main...), post...), nil
+}
+
+// hookStages returns a function that is called with the hook of each
+// operation of an install, in order. If the install has hooks, it announces
+// each stage, so that the hooks stand apart from the other manifests. With
+// printed, the announcements are comments among the printed commands.
+func (c *Client) hookStages(hooks []string, printed bool) func(hook string) {
+	hasHooks := false
+	for _, h := range hooks {
+		hasHooks = hasHooks || h != ""
+	}
+	if !hasHooks {
+		return func(string) {}
+	}
+	started, stage := false, ""
+	return func(hook string) {
+		if started && hook == stage {
+			return
+		}
+		started, stage = true, hook
+		what := "the manifests"
+		if hook != "" {
+			what = "the " + hook + " hooks"
+		}
+		if printed {
+			c.Log.Msg("# %s", what)
+		} else {
+			c.Log.Info("Applying %s ...", what)
+		}
+	}
+}
+
+// runHook completes a hook that was just applied. A Job or a Pod is waited
+// on until it completes, and then deleted, unless it is a keeper (see
+// manifest.IsKeeper). A hook of another kind is only applied before or after
+// the other manifests, and stays.
+//
+// A hook that fails, or does not complete within hookTimeout, is a
+// *helmerrors.HookError with its logs. It is left in the cluster, so that it
+// can be inspected.
+func (c *Client) runHook(op *PlanOperation, rr *ResourceResult) error {
+	if _, dry := c.Kube.(kubectl.PrintRunner); dry || (rr.Kind != "Job" && rr.Kind != "Pod") {
+		return nil
+	}
+	c.Log.Info("Waiting for the %s hook %s %s to complete ...", op.Hook, rr.Kind, rr.Name)
+	if reason := c.waitForHook(rr); reason != "" {
+		rr.Status, rr.Error = StatusFailed, reason
+		e := &helmerrors.HookError{Hook: op.Hook, Kind: rr.Kind, Name: rr.Name, Namespace: rr.Namespace, Reason: reason}
+		if out, err := c.Kube.Logs(rr.Name, rr.Kind, rr.Namespace); err != nil {
+			c.Log.Warn("Could not read the logs of %s %s: %s", rr.Kind, rr.Name, failure(out, err))
+		} else {
+			e.Logs = string(out)
+		}
+		return e
+	}
+	rr.Status = StatusCompleted
+	if a := manifest.KeptBy(op.Manifest); a != "" {
+		c.Log.Info("The %s hook %s %s completed. Keeping it, because of its %q annotation.", op.Hook, rr.Kind, rr.Name, a)
+		return nil
+	}
+	c.Log.Info("The %s hook %s %s completed. Deleting it.", op.Hook, rr.Kind, rr.Name)
+	if out, err := c.Kube.Delete(rr.Name, rr.Kind, rr.Namespace); err != nil && !kubectl.IsNotFound(out) {
+		c.Log.Warn("Could not delete %s %s: %s", rr.Kind, rr.Name, failure(out, err))
+	}
+	return nil
+}
+
+// preDeleteHooks returns the pre-delete hooks of a chart, in install order.
+func preDeleteHooks(ch *chart.Chart) []*manifest.Manifest {
+	var hooks []*manifest.Manifest
+	for _, m := range installManifests(ch, nil) {
+		if hookOf(m) == manifest.HookPreDelete {
+			hooks = append(hooks, m)
+		}
+	}
+	return hooks
+}
+
+// runPreDeleteHooks creates the pre-delete hooks of a chart in namespace, in
+// order, and completes each as runHook does, before an uninstall deletes
+// anything. It returns the outcome of each hook that it ran, for the audit
+// log. The first hook that cannot be created, or that fails, stops them.
+func (c *Client) runPreDeleteHooks(hooks []*manifest.Manifest, namespace string) ([]*audit.Resource, error) {
+	ran := []*audit.Resource{}
+	if len(hooks) == 0 {
+		return ran, nil
+	}
+	c.Log.Info("Applying the %s hooks ...", manifest.HookPreDelete)
+	res := &InstallResult{Resources: []*ResourceResult{}}
+	for _, m := range hooks {
+		data, err := m.VersionedObject.JSON()
+		if err != nil {
+			return ran, fmt.Errorf("Could not encode %s %s (%s): %s", m.Kind, m.Name, m.Source, err)
+		}
+		op := &PlanOperation{Op: ModeCreate, Kind: m.Kind, Name: m.Name, Namespace: namespace, Manifest: data, Hook: manifest.HookPreDelete}
+		err = c.uploadManifest(op, namespace, res)
+		rr := res.Resources[len(res.Resources)-1]
+		if err == nil {
+			err = c.runHook(op, rr)
+		}
+		ran = append(ran, &audit.Resource{Kind: rr.Kind, Name: rr.Name, Namespace: rr.Namespace, Status: rr.Status, Error: rr.Error})
+		if err != nil {
+			return ran, err
+		}
+	}
+	return ran, nil
+}
+
+// waitForHook polls a hook Job or Pod until it completes. It returns why the
+// hook failed, or "" if it succeeded.
+func (c *Client) waitForHook(rr *ResourceResult) string {
+	return c.waitForCompletion(rr, time.Now().Add(hookTimeout), hookTimeout)
+}
+
+// waitForCompletion polls a Job or a Pod until it completes, or until
+// deadline, which is timeout from when the wait began. It returns why it
+// failed, or "" if it succeeded.
+func (c *Client) waitForCompletion(rr *ResourceResult, deadline time.Time, timeout time.Duration) string {
+	for {
+		out, err := c.Kube.GetObject(rr.Name, rr.Kind, rr.Namespace)
+		if err != nil {
+			if kubectl.IsNotFound(out) {
+				return "it was deleted before it completed"
+			}
+			return "it could not be checked: " + failure(out, err)
+		}
+		if done, reason := hookDone(rr.Kind, out); done {
+			return reason
+		}
+		if time.Now().After(deadline) {
+			return fmt.Sprintf("it did not complete within %s", timeout)
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// hookDone reads the state of a hook Job or Pod, as JSON. It reports whether
+// the hook is done, and if it failed, why.
+func hookDone(kind string, data []byte) (bool, string) {
+	obj := struct {
+		Status struct {
+			Phase      string `json:"phase"`
+			Reason     string `json:"reason"`
+			Message    string `json:"message"`
+			Succeeded  int    `json:"succeeded"`
+			Conditions []struct {
+				Type    string `json:"type"`
+				Status  string `json:"status"`
+				Message string `json:"message"`
+			} `json:"conditions"`
+		} `json:"status"`
+	}{}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return true, fmt.Sprintf("its state could not be read: %s", err)
+	}
+	st := obj.Status
+	if kind == "Pod" {
+		switch st.Phase {
+		case "Succeeded":
+			return true, ""
+		case "Failed":
+			return true, or(st.Message, or(st.Reason, "the Pod failed"))
+		}
+		return false, ""
+	}
+	for _, cond := range st.Conditions {
+		if cond.Status != "True" {
+			continue
+		}
+		switch cond.Type {
+		case "Complete":
+			return true, ""
+		case "Failed":
+			return true, or(cond.Message, "the Job failed")
+		}
+	}
+	return st.Succeeded > 0, ""
+}
@@ -0,0 +1,283 @@
+package action
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/helm/helm-classic/audit"
+	"github.com/helm/helm-classic/chart"
+	helmerrors "github.com/helm/helm-classic/errors"
+	"github.com/helm/helm-classic/kubectl"
+	"github.com/helm/helm-classic/test"
+	"github.com/helm/helm-classic/util"
+)
+
+const hookManifests = `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+---
+apiVersion: extensions/v1beta1
+kind: Job
+metadata:
+  name: migrate
+  annotations:
+    helm.sh/hook: pre-install
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: smoke
+  annotations:
+    helm.sh/hook: post-install
+    helm-keep: "true"
+`
+
+// hookRunner answers GetObject with the state of a finished hook, a Job that
+// failed if failed is set, and a hook that is gone if gone is set.
+type hookRunner struct {
+	kubectl.FakeRunner
+	failed, gone bool
+}
+
+func (r *hookRunner) GetObject(name, ktype, ns string) ([]byte, error) {
+	r.FakeRunner.GetObject(name, ktype, ns)
+	switch {
+	case r.gone:
+		return []byte(`Error from server: jobs "migrate" not found`), errors.New("exit status 1")
+	case ktype == "Pod":
+		return []byte(`{"status": {"phase": "Succeeded"}}`), nil
+	case r.failed:
+		return []byte(`{"status": {"conditions": [{"type": "Failed", "status": "True", "message": "Job has reached the specified backoff limit"}]}}`), nil
+	}
+	return []byte(`{"status": {"succeeded": 1, "conditions": [{"type": "Complete", "status": "True"}]}}`), nil
+}
+
+func (r *hookRunner) Logs(name, ktype, ns string) ([]byte, error) {
+	r.FakeRunner.Logs(name, ktype, ns)
+	return []byte("migration failed: no database\n"), nil
+}
+
+// hookChart creates the chart hooks in the workspace.
+func hookChart(home string) {
+	dir := util.WorkspaceChartDirectory(home, "hooks")
+	os.MkdirAll(filepath.Join(dir, "manifests"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, Chartfile), []byte("name: hooks\nversion: 0.1.0\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "manifests", "all.yaml"), []byte(hookManifests), 0644)
+}
+
+func TestInstallHooks(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+	test.FakeUpdate(tmpHome)
+	hookChart(tmpHome)
+
+	client := &hookRunner{}
+	var err error
+	actual := test.CaptureOutput(func() {
+		err = Install("hooks", tmpHome, InstallOptions{Namespace: "ns"}, client)
+	})
+	if err != nil {
+		t.Fatalf("Expected the install to succeed, got %s\n%s", err, actual)
+	}
+	expected := []string{"create ns", "get Job migrate ns", "delete Job migrate ns", "create ns", "apply ns", "get Pod smoke ns"}
+	if strings.Join(client.Calls, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected %v, got %v", expected, client.Calls)
+	}
+	for i, kind := range []string{"Job", "Service", "Pod"} {
+		if !strings.Contains(string(client.Stdin[i]), `"kind":"`+kind+`"`) {
+			t.Errorf("Expected manifest %d to be the %s, got %s", i, kind, client.Stdin[i])
+		}
+	}
+	test.ExpectContains(t, actual, "Applying the pre-install hooks")
+	test.ExpectContains(t, actual, "completed (pre-install hook)")
+	test.ExpectContains(t, actual, `Keeping it, because of its "helm-keep" annotation`)
+	test.ExpectContains(t, actual, "1 created, 0 configured, 0 failed")
+	test.ExpectContains(t, actual, "2 hooks completed")
+
+	// A dry run prints the commands in their stages.
+	actual = test.CaptureOutput(func() {
+		_, err = newClient(tmpHome, kubectl.PrintRunner{}).Install("hooks", InstallOptions{Namespace: "ns"})
+	})
+	pre, main, post := strings.Index(actual, "# the pre-install hooks"), strings.Index(actual, "# the manifests"), strings.Index(actual, "# the post-install hooks")
+	if err != nil || pre < 0 || main < pre || post < main {
+		t.Errorf("Expected the stages of the install in order, got %v\n%s", err, actual)
+	}
+}
+
+func TestInstallHookFails(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+	test.FakeUpdate(tmpHome)
+	hookChart(tmpHome)
+
+	client := &hookRunner{failed: true}
+	var err error
+	test.CaptureOutput(func() {
+		err = Install("hooks", tmpHome, InstallOptions{Namespace: "ns"}, client)
+	})
+	var he *helmerrors.HookError
+	if !errors.As(err, &he) || he.Hook != "pre-install" || he.Name != "migrate" {
+		t.Fatalf("Expected the pre-install hook to fail, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "backoff limit") || !strings.Contains(err.Error(), "migration failed: no database") {
+		t.Errorf("Expected the reason and the logs of the hook, got %s", err)
+	}
+	expected := []string{"create ns", "get Job migrate ns", "logs Job migrate ns"}
+	if strings.Join(client.Calls, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected the failed hook to be kept, and nothing else applied, got %v", client.Calls)
+	}
+}
+
+func TestUninstallSkipsDeletedHooks(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+	test.FakeUpdate(tmpHome)
+	hookChart(tmpHome)
+
+	client := &hookRunner{gone: true}
+	actual := test.CaptureOutput(func() {
+		Uninstall("hooks", tmpHome, "ns", UninstallOptions{Yes: true}, client)
+	})
+	test.ExpectContains(t, actual, "Job/migrate (pre-install hook)")
+	test.ExpectContains(t, actual, "Skipping the pre-install hook Job migrate, which was deleted when it completed.")
+	for _, c := range client.Calls {
+		if strings.HasPrefix(c, "delete Job") {
+			t.Errorf("Expected the deleted hook to be skipped, got %v", client.Calls)
+		}
+	}
+	test.ExpectContains(t, actual, "1 deleted, 1 kept, 0 already gone, 1 skipped, 0 failed")
+}
+
+func TestPreDeleteHooks(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+	test.FakeUpdate(tmpHome)
+	hookChart(tmpHome)
+	backup := "---\napiVersion: extensions/v1beta1\nkind: Job\nmetadata:\n  name: backup\n  annotations:\n    helm.sh/hook: pre-delete\n"
+	dir := util.WorkspaceChartDirectory(tmpHome, "hooks", "manifests")
+	if err := ioutil.WriteFile(filepath.Join(dir, "backup.yaml"), []byte(backup), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	client := &hookRunner{}
+	test.CaptureOutput(func() {
+		if err := Install("hooks", tmpHome, InstallOptions{Namespace: "ns"}, client); err != nil {
+			t.Fatal(err)
+		}
+	})
+	for _, s := range client.Stdin {
+		if strings.Contains(string(s), "backup") {
+			t.Errorf("Expected the install not to send the pre-delete hook, got %s", s)
+		}
+	}
+
+	client = &hookRunner{}
+	actual := test.CaptureOutput(func() {
+		Uninstall("hooks", tmpHome, "ns", UninstallOptions{Yes: true}, client)
+	})
+	test.ExpectContains(t, actual, "Applying the pre-delete hooks")
+	test.ExpectContains(t, actual, "The pre-delete hook Job backup completed. Deleting it.")
+	if len(client.Calls) < 3 || strings.Join(client.Calls[:3], ",") != "create ns,get Job backup ns,delete Job backup ns" {
+		t.Errorf("Expected the pre-delete hook to run before anything is deleted, got %v", client.Calls)
+	}
+
+	client = &hookRunner{failed: true}
+	ch, err := chart.Load(util.WorkspaceChartDirectory(tmpHome, "hooks"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ran []*audit.Resource
+	test.CaptureOutput(func() {
+		ran, err = newClient(tmpHome, client).runPreDeleteHooks(preDeleteHooks(ch), "ns")
+	})
+	var he *helmerrors.HookError
+	if !errors.As(err, &he) || he.Hook != "pre-delete" || len(ran) != 1 || ran[0].Status != StatusFailed {
+		t.Errorf("Expected the pre-delete hook to fail, got %v, %v", err, ran)
+	}
+	if strings.Contains(strings.Join(client.Calls, ","), "delete") {
+		t.Errorf("Expected the failed hook to be kept, got %v", client.Calls)
+	}
+
+	client = &hookRunner{}
+	test.CaptureOutput(func() {
+		Uninstall("hooks", tmpHome, "ns", UninstallOptions{Yes: true, NoHooks: true}, client)
+	})
+	if len(client.Calls) > 0 && client.Calls[0] == "create ns" {
+		t.Errorf("Expected --no-hooks not to run the pre-delete hook, got %v", client.Calls)
+	}
+}
+
+const testManifests = `apiVersion: v1
+kind: Pod
+metadata:
+  name: ping
+  annotations:
+    helm.sh/hook: test
+---
+apiVersion: extensions/v1beta1
+kind: Job
+metadata:
+  name: migrate
+  annotations:
+    helm.sh/hook: test
+`
+
+func TestChartTests(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+	test.FakeUpdate(tmpHome)
+	hookChart(tmpHome)
+	dir := util.WorkspaceChartDirectory(tmpHome, "hooks")
+
+	client := &hookRunner{failed: true}
+	var err error
+	test.CaptureOutput(func() {
+		err = Test("hooks", tmpHome, "", 0, false, client)
+	})
+	if err == nil || !strings.Contains(err.Error(), "never been installed") {
+		t.Errorf("Expected a chart that was never installed to fail, got %v", err)
+	}
+	test.CaptureOutput(func() {
+		if err := Install("hooks", tmpHome, InstallOptions{Namespace: "web", Force: true, Annotate: true, Labels: true}, &hookRunner{}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	test.CaptureOutput(func() {
+		err = Test("hooks", tmpHome, "", 0, false, client)
+	})
+	if err == nil || !strings.Contains(err.Error(), "has no tests") {
+		t.Errorf("Expected a chart without tests to fail, got %v", err)
+	}
+
+	os.MkdirAll(filepath.Join(dir, "tests"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "tests", "smoke.yaml"), []byte(testManifests), 0644)
+	client.Calls = nil
+	actual := test.CaptureOutput(func() {
+		err = Test("hooks", tmpHome, "", 0, false, client)
+	})
+	var te *helmerrors.TestError
+	if !errors.As(err, &te) || te.Total != 2 || len(te.Failed) != 1 || !strings.HasPrefix(te.Failed[0], "Job migrate") {
+		t.Fatalf("Expected the Job to fail, got %v", err)
+	}
+	test.ExpectContains(t, actual, "PASS\tPod ping")
+	test.ExpectContains(t, actual, "FAIL\tJob migrate")
+	test.ExpectContains(t, actual, "\tmigration failed: no database\n")
+	test.ExpectContains(t, actual, "1 passed, 1 failed")
+	calls := strings.Join(client.Calls, "\n")
+	for _, c := range []string{"create web", "delete Pod ping web", "delete Job migrate web"} {
+		test.ExpectContains(t, calls, c)
+	}
+
+	ioutil.WriteFile(filepath.Join(dir, "tests", "svc.yaml"), []byte("apiVersion: v1\nkind: Service\nmetadata:\n  name: web\n"), 0644)
+	test.CaptureOutput(func() {
+		err = Test("hooks", tmpHome, "", 0, false, client)
+	})
+	if err == nil || !strings.Contains(err.Error(), "not a Pod or a Job") {
+		t.Errorf("Expected a Service in the tests to fail, got %v", err)
+	}
+}
@@ -0,0 +1,471 @@
+package action
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/helm/helm-classic/codec"
+	"github.com/helm/helm-classic/kubectl"
+	"github.com/helm/helm-classic/log"
+	"github.com/helm/helm-classic/manifest"
+	helm "github.com/helm/helm-classic/util"
+)
+
+// ImportKinds are the kinds that Import looks for unless it is given others:
+// those of InstallOrder, except the cluster-wide Namespace,
+// CustomResourceDefinition, and PersistentVolume.
+var ImportKinds = []string{"Secret", "ConfigMap", "ServiceAccount", "Service", "Pod", "ReplicationController", "Deployment", "DaemonSet", "Ingress", "Job"}
+
+// importSkippedKinds are the kinds that Kubernetes maintains for other
+// resources. They are never imported, even if they are asked for.
+var importSkippedKinds = map[string]bool{"Endpoints": true, "EndpointSlice": true, "Event": true}
+
+// Import creates a chart in the workspace from the resources of a namespace
+// that match a label selector.
+//
+// Each resource is written to its own manifest, without the fields that
+// Kubernetes sets itself: its status, its uid, resourceVersion, and
+// creationTimestamp, and the fields that hold defaults. Resources that
+// Kubernetes maintains, such as Endpoints, Events, and pods owned by a
+// controller, are skipped.
+//
+// If kinds is empty, ImportKinds are looked for. If adopt is set, the chart
+// is then installed with ModeApply, so that the live resources get the
+// chart's labels and annotations.
+func Import(chartName, home, namespace, selector string, kinds []string, adopt bool, client kubectl.Runner) error {
+	CheckClientPrereqs(client)
+
+	c := newClient(home, client)
+	if adopt {
+		c.Config = mustConfig(home)
+	}
+	_, err := c.Import(chartName, ImportOptions{
+		Namespace: namespace,
+		Selector:  selector,
+		Kinds:     kinds,
+		Adopt:     adopt,
+	})
+	return err
+}
+
+// ImportOptions are the options of Client.Import.
+//
+// Each is the parameter of the same name of the package-level Import.
+type ImportOptions struct {
+	Namespace string
+	Selector  string
+	Kinds     []string
+	Adopt     bool
+}
+
+// ImportedResource is a resource that Import found.
+type ImportedResource struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	// File is the manifest of the resource, relative to the chart. It is
+	// empty if the resource was skipped.
+	File string `json:"file,omitempty"`
+	// Skipped is why the resource was not imported.
+	Skipped string `json:"skipped,omitempty"`
+}
+
+// Import is like the package-level Import. It returns the resources that
+// were found, in the order of their manifests, followed by those that were
+// skipped.
+func (c *Client) Import(chartName string, opts ImportOptions) ([]*ImportedResource, error) {
+	if opts.Selector == "" {
+		return nil, fmt.Errorf("A label selector is required, such as app=%s", chartName)
+	}
+	chartDir := helm.WorkspaceChartDirectory(c.Home, chartName)
+	if _, err := os.Stat(chartDir); err == nil {
+		return nil, fmt.Errorf("A chart named %q is already in your workspace", chartName)
+	}
+	kinds := opts.Kinds
+	if len(kinds) == 0 {
+		kinds = ImportKinds
+	}
+
+	out, err := c.Kube.List(strings.Join(kinds, ","), opts.Selector, opts.Namespace)
+	if err != nil {
+		return nil, fmt.Errorf("Could not list the resources that match %s: %s", opts.Selector, failure(out, err))
+	}
+	var list struct {
+		Items []map[string]interface{} `json:"items"`
+	}
+	if err := json.Unmarshal(out, &list); err != nil {
+		return nil, fmt.Errorf("Could not read the resources that match %s: %s", opts.Selector, err)
+	}
+
+	files := map[string][]byte{}
+	var imported, skipped []*ImportedResource
+	for _, obj := range list.Items {
+		r := &ImportedResource{Kind: str(obj["kind"]), Name: str(field(obj, "metadata", "name"))}
+		if r.Skipped = importSkipReason(obj); r.Skipped != "" {
+			skipped = append(skipped, r)
+			continue
+		}
+		cleanImported(obj)
+		r.File = importFile(r, files)
+		var b bytes.Buffer
+		if err := codec.YAML.Encode(&b).One(obj); err != nil {
+			return nil, fmt.Errorf("Could not write %s %s: %s", r.Kind, r.Name, err)
+		}
+		files[r.File] = b.Bytes()
+		imported = append(imported, r)
+	}
+	if len(imported) == 0 {
+		return skipped, fmt.Errorf("No resources to import match %s", opts.Selector)
+	}
+	sort.SliceStable(imported, func(i, j int) bool {
+		return sortIndex(imported[i].Kind, InstallOrder) < sortIndex(imported[j].Kind, InstallOrder)
+	})
+
+	unlock, err := c.lockChart(chartName)
+	if err != nil {
+		return nil, err
+	}
+	err = writeImported(chartDir, chartName, opts, imported, files)
+	unlock()
+	if err != nil {
+		os.RemoveAll(chartDir)
+		return nil, err
+	}
+	res := append(imported, skipped...)
+	printImported(c.Log, res)
+	c.Log.Info("Created chart %s in %s", chartName, chartDir)
+
+	if opts.Adopt {
+		if _, err := c.Install(chartName, InstallOptions{Namespace: opts.Namespace, Mode: ModeApply, Annotate: true, Labels: true}); err != nil {
+			return res, fmt.Errorf("Could not adopt the resources of %s: %s", chartName, err)
+		}
+	}
+	return res, nil
+}
+
+// sortIndex returns the index of kind in order, or the length of order for
+// kinds that are not in it.
+func sortIndex(kind string, order []string) int {
+	for i, k := range order {
+		if k == kind {
+			return i
+		}
+	}
+	return len(order)
+}
+
+// importSkipReason returns why a resource is not imported, or "" if it is.
+func importSkipReason(obj map[string]interface{}) string {
+	kind := str(obj["kind"])
+	if importSkippedKinds[kind] {
+		return "maintained by Kubernetes"
+	}
+	refs, _ := field(obj, "metadata", "ownerReferences").([]interface{})
+	for _, ref := range refs {
+		ref, _ := ref.(map[string]interface{})
+		if controller, _ := ref["controller"].(bool); controller {
+			return fmt.Sprintf("owned by %s %s", str(ref["kind"]), str(ref["name"]))
+		}
+	}
+	if kind == "Secret" && str(obj["type"]) == "kubernetes.io/service-account-token" {
+		return "a service account token"
+	}
+	return ""
+}
+
+// importFile returns the manifest file of a resource, relative to the chart:
+// manifests/<name>-<kind>.yaml, numbered if another resource has the file.
+func importFile(r *ImportedResource, files map[string][]byte) string {
+	base := fmt.Sprintf("manifests/%s-%s", r.Name, strings.ToLower(r.Kind))
+	file := base + ".yaml"
+	for i := 2; files[file] != nil; i++ {
+		file = fmt.Sprintf("%s-%d.yaml", base, i)
+	}
+	return file
+}
+
+// writeImported writes the chart: its Chart.yaml, a README, and the
+// manifests of files.
+func writeImported(chartDir, chartName string, opts ImportOptions, imported []*ImportedResource, files map[string][]byte) error {
+	if err := os.MkdirAll(filepath.Join(chartDir, "manifests"), 0755); err != nil {
+		return fmt.Errorf("Could not create %q: %s", chartDir, err)
+	}
+	for f, b := range files {
+		if err := ioutil.WriteFile(filepath.Join(chartDir, filepath.FromSlash(f)), b, 0644); err != nil {
+			return fmt.Errorf("Could not create %s: %s", f, err)
+		}
+	}
+
+	ns := opts.Namespace
+	if ns == "" {
+		ns = "the default namespace"
+	}
+	cf := newSkelChartfile(chartName)
+	cf.Description = fmt.Sprintf("The resources of %s that match %s.", ns, opts.Selector)
+	cf.Details = fmt.Sprintf("Imported from Kubernetes with 'helmc import --selector %s'.", opts.Selector)
+	if err := cf.Save(filepath.Join(chartDir, Chartfile)); err != nil {
+		return fmt.Errorf("Could not create Chart.yaml: %s", err)
+	}
+
+	var readme bytes.Buffer
+	fmt.Fprintf(&readme, "# %s\n\n%s %s\n\n", chartName, cf.Description, cf.Details)
+	readme.WriteString("| Kind | Name | Manifest |\n| --- | --- | --- |\n")
+	for _, r := range imported {
+		fmt.Fprintf(&readme, "| %s | %s | %s |\n", r.Kind, r.Name, r.File)
+	}
+	if err := ioutil.WriteFile(filepath.Join(chartDir, "README.md"), readme.Bytes(), 0644); err != nil {
+		return fmt.Errorf("Could not create README.md: %s", err)
+	}
+	return nil
+}
+
+func printImported(l *log.Logger, res []*ImportedResource) {
+	w := tabwriter.NewWriter(l.Out(), 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "KIND\tNAME\tMANIFEST")
+	for _, r := range res {
+		file := r.File
+		if r.Skipped != "" {
+			file = "(skipped: " + r.Skipped + ")"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", r.Kind, r.Name, file)
+	}
+	w.Flush()
+}
+
+// importDropped are the fields of the metadata of a resource that Kubernetes
+// sets itself.
+var importDropped = []string{"namespace", "uid", "resourceVersion", "creationTimestamp", "generation", "selfLink", "managedFields", "ownerReferences", "generateName"}
+
+// importDroppedAnnotations are the annotations that kubectl, Kubernetes, and
+// helmc set themselves. Those with a trailing "/" are prefixes.
+var importDroppedAnnotations = []string{
+	"kubectl.kubernetes.io/last-applied-configuration",
+	"deployment.kubernetes.io/revision",
+	"chart.helm.sh/",
+}
+
+// cleanImported removes the fields of a live resource that Kubernetes sets
+// itself or that hold defaults, and gives it the label that lint expects.
+func cleanImported(obj map[string]interface{}) {
+	delete(obj, "status")
+	md, _ := obj["metadata"].(map[string]interface{})
+	if md == nil {
+		md = map[string]interface{}{}
+		obj["metadata"] = md
+	}
+	for _, f := range importDropped {
+		delete(md, f)
+	}
+	if ann, ok := md["annotations"].(map[string]interface{}); ok {
+		for k := range ann {
+			for _, d := range importDroppedAnnotations {
+				if k == d || strings.HasSuffix(d, "/") && strings.HasPrefix(k, d) {
+					delete(ann, k)
+				}
+			}
+		}
+	}
+	labels, _ := md["labels"].(map[string]interface{})
+	if labels == nil {
+		labels = map[string]interface{}{}
+		md["labels"] = labels
+	}
+	labels["heritage"] = "helm"
+
+	spec, _ := obj["spec"].(map[string]interface{})
+	switch str(obj["kind"]) {
+	case "Service":
+		cleanService(spec)
+	case "ServiceAccount":
+		// The token secrets of a service account are created with it.
+		if secrets, ok := obj["secrets"].([]interface{}); ok {
+			kept := []interface{}{}
+			for _, s := range secrets {
+				if n := str(field(s, "name")); !strings.HasPrefix(n, str(md["name"])+"-token-") {
+					kept = append(kept, s)
+				}
+			}
+			obj["secrets"] = kept
+		}
+	case "Deployment":
+		dropDefaults(spec, map[string]interface{}{"progressDeadlineSeconds": 600.0, "revisionHistoryLimit": 10.0})
+		if s, _ := spec["strategy"].(map[string]interface{}); str(s["type"]) == "RollingUpdate" &&
+			reflect.DeepEqual(s["rollingUpdate"], map[string]interface{}{"maxSurge": "25%", "maxUnavailable": "25%"}) {
+			delete(spec, "strategy")
+		}
+	case "DaemonSet":
+		dropDefaults(spec, map[string]interface{}{"revisionHistoryLimit": 10.0})
+		if s, _ := spec["updateStrategy"].(map[string]interface{}); str(s["type"]) == "RollingUpdate" {
+			if ru, _ := s["rollingUpdate"].(map[string]interface{}); ru == nil || fmt.Sprint(ru["maxUnavailable"]) == "1" && fmt.Sprint(ru["maxSurge"]) != "1" {
+				delete(spec, "updateStrategy")
+			}
+		}
+	case "Job":
+		dropDefaults(spec, map[string]interface{}{"backoffLimit": 6.0, "completions": 1.0, "parallelism": 1.0, "completionMode": "NonIndexed", "suspend": false, "podReplacementPolicy": "TerminatingOrFailed"})
+		// Kubernetes generates the selector, and labels the pods with it.
+		if manual, _ := spec["manualSelector"].(bool); !manual {
+			delete(spec, "selector")
+			if tl, ok := field(spec, "template", "metadata", "labels").(map[string]interface{}); ok {
+				for _, l := range []string{"controller-uid", "job-name", "batch.kubernetes.io/controller-uid", "batch.kubernetes.io/job-name"} {
+					delete(tl, l)
+				}
+			}
+		}
+	}
+
+	if path, ok := manifest.PodSpecPaths[str(obj["kind"])]; ok {
+		var tmpl interface{} = obj
+		if len(path) > 1 {
+			tmpl = field(obj, path[:len(path)-1]...)
+		}
+		if tm, ok := field(tmpl, "metadata").(map[string]interface{}); ok {
+			delete(tm, "creationTimestamp")
+		}
+		if ps, ok := field(obj, path...).(map[string]interface{}); ok {
+			cleanPodSpec(ps)
+		}
+	}
+	dropEmpty(md, "annotations")
+	dropEmpty(obj, "secrets")
+}
+
+func cleanService(spec map[string]interface{}) {
+	for _, f := range []string{"clusterIP", "clusterIPs", "ipFamilies", "ipFamilyPolicy"} {
+		// A headless service keeps its clusterIP of None.
+		if f == "clusterIP" && str(spec[f]) == "None" {
+			continue
+		}
+		if f == "clusterIPs" && str(spec["clusterIP"]) == "None" {
+			continue
+		}
+		delete(spec, f)
+	}
+	dropDefaults(spec, map[string]interface{}{"type": "ClusterIP", "sessionAffinity": "None", "internalTrafficPolicy": "Cluster"})
+	ports, _ := spec["ports"].([]interface{})
+	for _, p := range ports {
+		p, _ := p.(map[string]interface{})
+		dropDefaults(p, map[string]interface{}{"protocol": "TCP"})
+		// Kubernetes allocates the node ports of a NodePort service.
+		delete(p, "nodePort")
+		if reflect.DeepEqual(p["targetPort"], p["port"]) {
+			delete(p, "targetPort")
+		}
+	}
+}
+
+// cleanPodSpec removes the defaults of a pod spec, and the service account
+// token that Kubernetes mounts in each container.
+func cleanPodSpec(spec map[string]interface{}) {
+	dropDefaults(spec, map[string]interface{}{
+		"dnsPolicy":                     "ClusterFirst",
+		"restartPolicy":                 "Always",
+		"schedulerName":                 "default-scheduler",
+		"terminationGracePeriodSeconds": 30.0,
+		"serviceAccountName":            "default",
+		"enableServiceLinks":            true,
+		"priority":                      0.0,
+		"preemptionPolicy":              "PreemptLowerPriority",
+	})
+	// serviceAccount is the deprecated name of serviceAccountName, and
+	// nodeName is where the scheduler put the pod.
+	delete(spec, "serviceAccount")
+	delete(spec, "nodeName")
+
+	tokens := map[string]bool{}
+	if vols, ok := spec["volumes"].([]interface{}); ok {
+		kept := []interface{}{}
+		for _, v := range vols {
+			if n := str(field(v, "name")); strings.HasPrefix(n, "kube-api-access-") || strings.HasPrefix(n, "default-token-") {
+				tokens[n] = true
+				continue
+			}
+			kept = append(kept, v)
+		}
+		spec["volumes"] = kept
+	}
+	if tols, ok := spec["tolerations"].([]interface{}); ok {
+		kept := []interface{}{}
+		for _, t := range tols {
+			tm, _ := t.(map[string]interface{})
+			key := str(tm["key"])
+			if (key == "node.kubernetes.io/not-ready" || key == "node.kubernetes.io/unreachable") && fmt.Sprint(tm["tolerationSeconds"]) == "300" {
+				continue
+			}
+			kept = append(kept, t)
+		}
+		spec["tolerations"] = kept
+	}
+
+	for _, key := range []string{"initContainers", "containers"} {
+		list, _ := spec[key].([]interface{})
+		for _, c := range list {
+			c, _ := c.(map[string]interface{})
+			dropDefaults(c, map[string]interface{}{"terminationMessagePath": "/dev/termination-log", "terminationMessagePolicy": "File"})
+			if mounts, ok := c["volumeMounts"].([]interface{}); ok {
+				kept := []interface{}{}
+				for _, m := range mounts {
+					if !tokens[str(field(m, "name"))] {
+						kept = append(kept, m)
+					}
+				}
+				c["volumeMounts"] = kept
+			}
+			ports, _ := c["ports"].([]interface{})
+			for _, p := range ports {
+				p, _ := p.(map[string]interface{})
+				dropDefaults(p, map[string]interface{}{"protocol": "TCP"})
+			}
+			dropEmpty(c, "resources", "volumeMounts", "securityContext")
+		}
+	}
+	dropEmpty(spec, "volumes", "tolerations", "securityContext")
+}
+
+// dropDefaults removes the fields of m that have their default value.
+func dropDefaults(m map[string]interface{}, defaults map[string]interface{}) {
+	for k, v := range defaults {
+		if reflect.DeepEqual(m[k], v) {
+			delete(m, k)
+		}
+	}
+}
+
+// dropEmpty removes the fields of m that are empty maps or lists.
+func dropEmpty(m map[string]interface{}, keys ...string) {
+	for _, k := range keys {
+		switch v := m[k].(type) {
+		case map[string]interface{}:
+			if len(v) == 0 {
+				delete(m, k)
+			}
+		case []interface{}:
+			if len(v) == 0 {
+				delete(m, k)
+			}
+		}
+	}
+}
+
+// field returns the value at path in v, or nil.
+func field(v interface{}, path ...string) interface{} {
+	for _, key := range path {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = m[key]
+	}
+	return v
+}
+
+func str(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
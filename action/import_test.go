@@ -0,0 +1,124 @@
+package action
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/helm/helm-classic/chart"
+	"github.com/helm/helm-classic/kubectl"
+	"github.com/helm/helm-classic/test"
+	"github.com/helm/helm-classic/util"
+)
+
+// importList is what kubectl returns for the resources labeled app=web.
+const importList = `{"apiVersion": "v1", "kind": "List", "items": [
+{"apiVersion": "apps/v1", "kind": "Deployment",
+ "metadata": {"name": "web", "namespace": "shop", "uid": "1234", "resourceVersion": "99", "generation": 3,
+  "creationTimestamp": "2016-05-01T00:00:00Z", "labels": {"app": "web"},
+  "annotations": {"deployment.kubernetes.io/revision": "3", "kubectl.kubernetes.io/last-applied-configuration": "{}", "owner": "team-a"}},
+ "spec": {"replicas": 2, "progressDeadlineSeconds": 600, "revisionHistoryLimit": 10,
+  "selector": {"matchLabels": {"app": "web"}},
+  "strategy": {"type": "RollingUpdate", "rollingUpdate": {"maxSurge": "25%", "maxUnavailable": "25%"}},
+  "template": {"metadata": {"creationTimestamp": null, "labels": {"app": "web"}},
+   "spec": {"dnsPolicy": "ClusterFirst", "restartPolicy": "Always", "schedulerName": "default-scheduler",
+    "securityContext": {}, "terminationGracePeriodSeconds": 30,
+    "volumes": [{"name": "cache", "emptyDir": {}}],
+    "containers": [{"name": "web", "image": "nginx:1.9", "ports": [{"containerPort": 80, "protocol": "TCP"}],
+     "resources": {"requests": {"cpu": "100m"}}, "volumeMounts": [{"name": "cache", "mountPath": "/cache"}],
+     "terminationMessagePath": "/dev/termination-log", "terminationMessagePolicy": "File"}]}}},
+ "status": {"replicas": 2}},
+{"apiVersion": "v1", "kind": "Service",
+ "metadata": {"name": "web", "namespace": "shop", "uid": "5678", "labels": {"app": "web"}},
+ "spec": {"clusterIP": "10.0.0.10", "clusterIPs": ["10.0.0.10"], "type": "ClusterIP", "sessionAffinity": "None",
+  "ports": [{"port": 80, "targetPort": 80, "protocol": "TCP"}], "selector": {"app": "web"}},
+ "status": {"loadBalancer": {}}},
+{"apiVersion": "v1", "kind": "Pod",
+ "metadata": {"name": "web-1234-abcd", "labels": {"app": "web"},
+  "ownerReferences": [{"kind": "ReplicaSet", "name": "web-1234", "controller": true}]},
+ "spec": {"containers": [{"name": "web", "image": "nginx:1.9"}]}},
+{"apiVersion": "v1", "kind": "Endpoints", "metadata": {"name": "web", "labels": {"app": "web"}}}
+]}`
+
+type importRunner struct {
+	kubectl.FakeRunner
+}
+
+func (r *importRunner) List(kinds, selector, ns string) ([]byte, error) {
+	r.FakeRunner.List(kinds, selector, ns)
+	return []byte(importList), nil
+}
+
+func TestImport(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+
+	client := &importRunner{}
+	actual := test.CaptureOutput(func() {
+		if err := Import("web", tmpHome, "shop", "app=web", nil, false, client); err != nil {
+			t.Fatal(err)
+		}
+	})
+	test.ExpectContains(t, actual, "owned by ReplicaSet web-1234")
+	test.ExpectContains(t, actual, "maintained by Kubernetes")
+	test.ExpectEquals(t, client.Calls[0], "list "+strings.Join(ImportKinds, ",")+" app=web shop")
+
+	dir := util.WorkspaceChartDirectory(tmpHome, "web")
+	b, err := ioutil.ReadFile(filepath.Join(dir, "manifests", "web-deployment.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	deployment := string(b)
+	for _, expect := range []string{"heritage: helm", "owner: team-a", "replicas: 2", "emptyDir: {}", "containerPort: 80", "cpu: 100m"} {
+		test.ExpectContains(t, deployment, expect)
+	}
+	for _, gone := range []string{"status", "uid", "resourceVersion", "generation", "creationTimestamp", "namespace", "revision", "last-applied",
+		"progressDeadlineSeconds", "strategy", "dnsPolicy", "schedulerName", "securityContext", "terminationMessage", "protocol"} {
+		if strings.Contains(deployment, gone) {
+			t.Errorf("Expected %s to be stripped:\n%s", gone, deployment)
+		}
+	}
+
+	b, err = ioutil.ReadFile(filepath.Join(dir, "manifests", "web-service.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, gone := range []string{"clusterIP", "sessionAffinity", "type", "targetPort", "status"} {
+		if strings.Contains(string(b), gone) {
+			t.Errorf("Expected %s to be stripped:\n%s", gone, b)
+		}
+	}
+	if m, _ := filepath.Glob(filepath.Join(dir, "manifests", "*")); len(m) != 2 {
+		t.Errorf("Expected only the Deployment and the Service, got %v", m)
+	}
+
+	// The chart is ready to use as it is.
+	test.CaptureOutput(func() {
+		if err := Lint(dir, tmpHome, LintOptions{}); err != nil {
+			t.Errorf("Expected the imported chart to pass lint: %s", err)
+		}
+	})
+
+	if err := Import("web", tmpHome, "shop", "app=web", nil, false, client); err == nil || !strings.Contains(err.Error(), "already in your workspace") {
+		t.Errorf("Expected an error for an existing chart, got %v", err)
+	}
+}
+
+func TestImportAdopt(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+	test.FakeUpdate(tmpHome)
+
+	client := &importRunner{}
+	test.CaptureOutput(func() {
+		if err := Import("web", tmpHome, "shop", "app=web", []string{"Deployment", "Service"}, true, client); err != nil {
+			t.Fatal(err)
+		}
+	})
+	test.ExpectEquals(t, strings.Join(client.Calls, "; "), "list Deployment,Service app=web shop; apply shop; apply shop")
+	for _, stdin := range client.Stdin {
+		test.ExpectContains(t, string(stdin), chart.AnnChartName)
+	}
+}
@@ -1,45 +1,179 @@
 package action
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
 	"text/template"
 
 	"github.com/helm/helm-classic/chart"
 	"github.com/helm/helm-classic/log"
+	"github.com/helm/helm-classic/manifest"
 	helm "github.com/helm/helm-classic/util"
 )
 
-const defaultInfoFormat = `Name: {{.Name}}
+const defaultInfoFormat = `{{if .Deprecated}}DEPRECATED{{with .DeprecationMessage}}: {{.}}{{end}}
+
+{{end}}Name: {{.Name}}
 Home: {{.Home}}
 Version: {{.Version}}
 Description: {{.Description}}
 Details: {{.Details}}
 `
 
+// chartInfo is the machine-readable description of a chart, as its
+// Chart.yaml has it.
+type chartInfo struct {
+	Name               string           `json:"name" yaml:"name"`
+	Version            string           `json:"version" yaml:"version"`
+	Description        string           `json:"description" yaml:"description"`
+	Home               string           `json:"home,omitempty" yaml:"home,omitempty"`
+	Source             []string         `json:"source,omitempty" yaml:"source,omitempty"`
+	Details            string           `json:"details,omitempty" yaml:"details,omitempty"`
+	Keywords           []string         `json:"keywords,omitempty" yaml:"keywords,omitempty"`
+	Maintainers        []string         `json:"maintainers,omitempty" yaml:"maintainers,omitempty"`
+	Dependencies       []dependencyInfo `json:"dependencies,omitempty" yaml:"dependencies,omitempty"`
+	Namespace          string           `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	Deprecated         bool             `json:"deprecated,omitempty" yaml:"deprecated,omitempty"`
+	DeprecationMessage string           `json:"deprecationMessage,omitempty" yaml:"deprecationMessage,omitempty"`
+	// Resources are the summary of the manifests of the chart, with
+	// --manifest-summary.
+	Resources []*manifest.Resource `json:"resources,omitempty" yaml:"resources,omitempty"`
+}
+
+// dependencyInfo is the machine-readable description of a dependency.
+type dependencyInfo struct {
+	Name    string `json:"name" yaml:"name"`
+	Version string `json:"version" yaml:"version"`
+	Repo    string `json:"repo,omitempty" yaml:"repo,omitempty"`
+}
+
 // Info prints information about a chart.
 //
 // - chartName to display
 // - homeDir is the helm home directory for the user
 // - format is a optional Go template
-func Info(chartName, homedir, format string) {
+// - outputFormat, if it is "json" or "yaml", prints the chart in that format
+// instead, and format is ignored
+// - summary also describes the resources that the manifests of the chart
+// create, so that they can be reviewed before it is installed (see
+// manifest.Inspect)
+//
+// A deprecated chart is reported before anything else.
+func Info(chartName, homedir, format, outputFormat string, summary bool) {
 	r := mustConfig(homedir).Repos
-	table, chartLocal := r.RepoChart(chartName)
+	table, chartLocal, err := r.Resolve(chartName)
+	if err != nil {
+		log.Die("%s", err)
+	}
 	chartPath := helm.CacheDirectory(homedir, table, chartLocal, Chartfile)
 
-	if format == "" {
-		format = defaultInfoFormat
+	// The Chart.yaml of a Git repository or directory mirror is in its
+	// metadata index.
+	var cf *chart.Chartfile
+	if t := r.Lookup(table); t != nil && t.IsHTTP() {
+		if _, err := os.Stat(chartPath); os.IsNotExist(err) {
+			if err := r.FetchChart(table, chartLocal); err != nil {
+				log.Die("Could not download %s: %s", chartName, err)
+			}
+		}
+		cf, err = chart.LoadChartfile(chartPath)
+	} else {
+		cf, err = r.CachedChart(table, chartLocal)
 	}
 
-	chart, err := chart.LoadChartfile(chartPath)
 	if err != nil {
 		log.Die("Could not find chart %s: %s", chartName, err.Error())
 	}
 
+	var resources []*manifest.Resource
+	if summary {
+		if resources, err = chartResources(filepath.Dir(chartPath)); err != nil {
+			log.Die("Could not summarize the manifests of %s: %s", chartName, err)
+		}
+	}
+
+	if outputFormat != "" {
+		if n := cf.DeprecationNotice(); n != "" {
+			log.Warn("%s", n)
+		}
+		info := &chartInfo{
+			Name:               cf.Name,
+			Version:            cf.Version,
+			Description:        cf.Description,
+			Home:               cf.Home,
+			Source:             cf.Source,
+			Details:            cf.Details,
+			Keywords:           cf.Keywords,
+			Maintainers:        cf.Maintainers,
+			Namespace:          cf.Namespace,
+			Deprecated:         cf.Deprecated,
+			DeprecationMessage: cf.DeprecationMessage,
+			Resources:          resources,
+		}
+		for _, d := range cf.Dependencies {
+			info.Dependencies = append(info.Dependencies, dependencyInfo{Name: d.Name, Version: d.Version, Repo: d.Repo})
+		}
+		if err := printFormatted(info, outputFormat); err != nil {
+			log.Die("%s", err)
+		}
+		return
+	}
+
+	// The default format starts with the deprecation. Others may not show it.
+	if format == "" {
+		format = defaultInfoFormat
+	} else if n := cf.DeprecationNotice(); n != "" {
+		log.Warn("%s", n)
+	}
+
 	tmpl, err := template.New("info").Parse(format)
 	if err != nil {
 		log.Die("%s", err)
 	}
 
-	if err = tmpl.Execute(log.Stdout, chart); err != nil {
+	if err = tmpl.Execute(log.Stdout, cf); err != nil {
 		log.Die("%s", err)
 	}
+	if summary {
+		printResources(resources)
+	}
+}
+
+// chartResources inspects the manifests of the chart in dir. A file name of
+// a resource is relative to dir.
+func chartResources(dir string) ([]*manifest.Resource, error) {
+	ch, err := chart.Load(dir)
+	if err != nil {
+		return nil, err
+	}
+	res, err := manifest.Inspect(ch.Manifests)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range res {
+		if rel, err := filepath.Rel(dir, r.Source); err == nil {
+			r.Source = filepath.ToSlash(rel)
+		}
+	}
+	return res, nil
+}
+
+// printResources lists the resources of a chart as a table, and then what
+// they require of the cluster.
+func printResources(res []*manifest.Resource) {
+	fmt.Fprintln(log.Stdout)
+	if len(res) == 0 {
+		fmt.Fprintln(log.Stdout, "Resources: none. A chart that generates its manifests only has them once it is generated.")
+		return
+	}
+	fmt.Fprintln(log.Stdout, "Resources:")
+	w := tabwriter.NewWriter(log.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "KIND\tNAME\tIMAGES\tREQUIRES")
+	for _, r := range res {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.Kind, dash(r.Name), dash(strings.Join(r.Images, ",")), dash(strings.Join(r.Requires, ", ")))
+	}
+	w.Flush()
 }
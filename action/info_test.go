@@ -1,6 +1,9 @@
 package action
 
 import (
+	"encoding/json"
+	"os"
+	"strings"
 	"testing"
 
 	"github.com/helm/helm-classic/test"
@@ -19,7 +22,7 @@ Description: All the things, all semantically, none working
 Details: This package provides a sampling of all of the different manifest types. It can be used to test ordering and other properties of a chart.`
 
 	actual := test.CaptureOutput(func() {
-		Info("kitchensink", tmpHome, format)
+		Info("kitchensink", tmpHome, format, "", false)
 	})
 
 	test.ExpectContains(t, actual, expected)
@@ -34,8 +37,56 @@ func TestInfoFormat(t *testing.T) {
 	expected := `Hello kitchensink`
 
 	actual := test.CaptureOutput(func() {
-		Info("kitchensink", tmpHome, format)
+		Info("kitchensink", tmpHome, format, "", false)
 	})
 
 	test.ExpectContains(t, actual, expected)
 }
+
+func TestInfoJSON(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+	test.FakeUpdate(tmpHome)
+
+	actual := test.CaptureOutput(func() {
+		Info("kitchensink", tmpHome, "", "json", false)
+	})
+
+	info := &chartInfo{}
+	if err := json.Unmarshal([]byte(actual), info); err != nil {
+		t.Fatalf("Expected JSON, got %q: %s", actual, err)
+	}
+	if info.Name != "kitchensink" || info.Version != "0.0.1" || info.Home != "http://github.com/helm/helm" {
+		t.Errorf("Expected the Chart.yaml of kitchensink, got %+v", info)
+	}
+}
+
+func TestInfoManifestSummary(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+	test.FakeUpdate(tmpHome)
+
+	actual := test.CaptureOutput(func() {
+		Info("kitchensink", tmpHome, "", "", true)
+	})
+	test.ExpectContains(t, actual, "Resources:")
+	test.ExpectContains(t, actual, "alpine:3.2")
+	test.ExpectContains(t, actual, "cluster-scoped PersistentVolume")
+
+	actual = test.CaptureOutput(func() {
+		Info("kitchensink", tmpHome, "", "json", true)
+	})
+	info := &chartInfo{}
+	if err := json.Unmarshal([]byte(actual[strings.Index(actual, "{"):]), info); err != nil {
+		t.Fatalf("Expected JSON, got %q: %s", actual, err)
+	}
+	for _, r := range info.Resources {
+		if r.Kind == "Pod" && r.Name == "deis-empty-pod" {
+			if r.Source != "manifests/sink-pod.yaml" {
+				t.Errorf("Expected the file relative to the chart, got %s", r.Source)
+			}
+			return
+		}
+	}
+	t.Errorf("Expected the Pod deis-empty-pod in %+v", info.Resources)
+}
@@ -1,10 +1,18 @@
 package action
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/helm/helm-classic/chart"
+	"github.com/helm/helm-classic/config"
 	"github.com/helm/helm-classic/dependency"
+	helmerrors "github.com/helm/helm-classic/errors"
 	"github.com/helm/helm-classic/kubectl"
 	"github.com/helm/helm-classic/log"
 	"github.com/helm/helm-classic/manifest"
@@ -14,132 +22,720 @@ import (
 // InstallOrder defines the order in which manifests should be installed, by Kind.
 //
 // Anything not on the list will be installed after the last listed item, in
-// an indeterminate order.
-var InstallOrder = []string{"Namespace", "Secret", "ConfigMap", "PersistentVolume", "ServiceAccount", "Service", "Pod", "ReplicationController", "Deployment", "DaemonSet", "Ingress", "Job"}
+// an indeterminate order. Namespaces and CustomResourceDefinitions go first,
+// since the other resources may be in them, or of their kinds.
+var InstallOrder = []string{"Namespace", "CustomResourceDefinition", "Secret", "ConfigMap", "PersistentVolume", "ServiceAccount", "Service", "Pod", "ReplicationController", "Deployment", "DaemonSet", "Ingress", "Job"}
 
 // UninstallOrder defines the order in which manifests are uninstalled.
 //
-// Unknown manifest types (those not explicitly referenced in this list) will
-// be uninstalled before any of these, since we know that none of the core
-// types depend on non-core types.
-var UninstallOrder = []string{"Service", "Pod", "ReplicationController", "Deployment", "DaemonSet", "ConfigMap", "Secret", "PersistentVolume", "ServiceAccount", "Ingress", "Job", "Namespace"}
+// It is the reverse of InstallOrder, except that Services are removed right
+// after Ingresses, so that traffic stops before the controllers behind them
+// go. Controllers are removed before the resources they use, and namespaces
+// last. Unknown manifest types (those not explicitly referenced in this list)
+// will be uninstalled before any of these, since we know that none of the
+// core types depend on non-core types.
+var UninstallOrder = []string{"Job", "Ingress", "Service", "DaemonSet", "Deployment", "ReplicationController", "Pod", "ServiceAccount", "PersistentVolume", "ConfigMap", "Secret", "CustomResourceDefinition", "Namespace"}
+
+// Install modes, which choose the kubectl command used for each manifest.
+const (
+	// ModeCreate creates each resource, and fails if it already exists.
+	// Keeper manifests are applied instead. This is the default.
+	ModeCreate = "create"
+	// ModeApply creates each resource, or updates it if it already exists.
+	ModeApply = "apply"
+	// ModeReplace replaces each resource, which must already exist.
+	ModeReplace = "replace"
+)
 
 // Install loads a chart into Kubernetes.
 //
 // If the chart is not found in the workspace, it is fetched and then installed.
 //
-// During install, manifests are sent to Kubernetes in the ordered specified by InstallOrder.
-func Install(chartName, home, namespace string, force bool, generate bool, exclude []string, client kubectl.Runner) {
+// During install, manifests are sent to Kubernetes in the ordered specified by
+// InstallOrder, using the kubectl command chosen by opts.Mode. In ModeCreate,
+// a resource that already exists is reported and the install continues,
+// unless opts.Atomic is set. With Atomic, the install stops at the first
+// failure and deletes the resources it created.
+//
+// A resource that fails because a Namespace or CustomResourceDefinition that
+// the install sent is not ready yet is sent again, with the backoff of
+// the Retry of the runner's settings, before it counts as a failure.
+//
+// A deprecated chart is warned about. If the configuration is strict (see
+// config.Fetch.Strict), it is refused unless opts.AcceptDeprecated is set.
+//
+// The manifests are checked against the limits of the configuration (see
+// config.Install), as opts.Limits overrides them, before any of them is sent,
+// so that a generator that goes wrong cannot flood the cluster.
+//
+// When the upload is finished (or fails), a summary of the applied resources
+// is printed. The other options are described by InstallOptions.
+//
+// Besides the errors of Fetch, a resource that Kubernetes rejects is reported
+// with a *helmerrors.KubeError.
+func Install(chartName, home string, opts InstallOptions, client kubectl.Runner) error {
+	if err := checkMode(opts.Mode); err != nil {
+		return err
+	}
+
+	// Check the client first, so that a bad kubeconfig is reported before
+	// anything is fetched or generated.
+	CheckClientPrereqs(client)
+
+	c := newClient(home, client)
+	c.Config = mustConfig(home)
+	_, err := c.Install(chartName, opts)
+	return err
+}
+
+// InstallOptions are the options of Install, DryRunInstall, and Upgrade, and
+// of the Client methods of the same names.
+type InstallOptions struct {
+	// Namespace is where the chart is installed. If it is empty, the
+	// namespace of the chart's Chart.yaml is used, if it has one.
+	Namespace string
+	// Force installs a chart whose dependencies are not satisfied, and lets
+	// its generators overwrite the files that they find changed.
+	Force bool
+	// Generate runs the chart's generators first. A value source that cannot
+	// be read stops the install before anything is sent to Kubernetes.
+	Generate bool
+	// SkipSchema and Exclude are those of GenerateOptions, for Generate.
+	SkipSchema bool
+	Exclude    []string
+	// Output is the format of the summary; if it is "json", the summary is
+	// printed as JSON.
+	Output string
+	// Mode is the kubectl command that sends the manifests: one of
+	// ModeCreate, ModeApply, or ModeReplace. It is ModeCreate if empty.
+	Mode string
+	// Atomic stops the install at the first failure and deletes the
+	// resources that it created.
+	Atomic bool
+	// Annotate annotates each resource with the chart's name, version, and
+	// digest, and the time of the install, and labels it with
+	// chart.LabelChartName, so that Prune can find it.
+	Annotate bool
+	// Labels labels each resource with chart.LabelHeritage and
+	// chart.LabelChart, so that 'helmc list --installed' can find what helmc
+	// manages, whether or not the chart is in the workspace.
+	Labels bool
+	// Preflight runs the checks of Preflight first, and changes nothing if
+	// one of them finds an error. A dry run skips them.
+	Preflight bool
+	// Values are given to the templates of the chart when Generate is set.
+	Values ValueSources
+	// AcceptDeprecated installs a deprecated chart even if the configuration
+	// is strict.
+	AcceptDeprecated bool
+	// Deps fetches the dependencies of the chart that the workspace is
+	// missing before they are checked, as FetchOptions.Deps does.
+	Deps bool
+	// Checksum, if set, is the checksum that the chart must have, such as
+	// "sha256:3f2a...", as chart.Checksum gives it. It is computed over the
+	// chart as it is installed from: in the workspace, after it is fetched,
+	// and before the generators run, so that what was reviewed is what the
+	// generators see.
+	Checksum string
+	// VerifyKeyring, if set, is the keyring that the provenance file of the
+	// chart must be signed by, as FetchOptions.VerifyKeyring says. Only a
+	// chart that install fetches can be verified.
+	VerifyKeyring string
+	// Limits override the limits of the configuration on the manifests of
+	// the chart. Zero fields keep those of the configuration.
+	Limits config.Install
+	// InjectNamespace writes Namespace into the manifests that have no
+	// metadata.namespace, so that they say where they are installed.
+	InjectNamespace bool
+	// Wait, if it is greater than zero, is how long the install waits for
+	// the Deployments, ReplicationControllers, ReplicaSets, StatefulSets, and
+	// DaemonSets it sent to have their replicas ready. Those that do not are
+	// a *helmerrors.NotReadyError; they are left installed.
+	Wait time.Duration
+	// Stateless installs from a temporary workspace, which has none of the
+	// charts of the workspace of Home: the chart is fetched, generated, and
+	// installed there, and the temporary workspace is removed afterwards.
+	// The repository caches, the configuration, the audit log, and the
+	// release history are still those of Home.
+	Stateless bool
+}
+
+// Install is like the package-level Install. It returns the outcome for each
+// resource, even if the install fails.
+//
+// The client's Kube must be ready to use: unlike the package-level Install,
+// this does not look for kubectl or check the kubeconfig.
+func (c *Client) Install(chartName string, opts InstallOptions) (res *InstallResult, err error) {
+	defer c.completed(OpInstall, chartName, c.started(OpInstall, chartName), &err)
+	if opts.Mode == "" {
+		opts.Mode = ModeCreate
+	}
+	if err := checkMode(opts.Mode); err != nil {
+		return nil, err
+	}
+	if opts.Stateless {
+		restore, err := c.useStatelessHome()
+		if err != nil {
+			return nil, err
+		}
+		defer restore()
+	}
+
+	ch, chartName, ms, err := c.installPlan(chartName, &opts)
+	if err != nil {
+		return nil, err
+	}
+
+	ops, err := installOperations(ms, opts.Namespace, opts.Mode)
+	if err != nil {
+		return nil, err
+	}
+	if _, dry := c.Kube.(kubectl.PrintRunner); opts.Preflight && !dry {
+		pre := c.preflight(ch, chartName, ops)
+		pre.log(c.Log)
+		if err := pre.err(); err != nil {
+			return nil, err
+		}
+	}
+	restore, err := c.useChartArgs(ch)
+	if err != nil {
+		return nil, err
+	}
+	defer restore()
+	defer c.useRetryEvents()()
+
+	c.Log.Info("Running `kubectl %s -f` ...", opts.Mode)
+	res, err = c.uploadManifests(ch.Chartfile.Name, ops, opts.Namespace, opts.Atomic)
+	if _, dry := c.Kube.(kubectl.PrintRunner); !dry {
+		c.auditInstall(ch, chartName, opts, res, err)
+		c.recordRelease(c.installRelease(ch, chartName, opts), ops, err)
+		if perr := res.print(c.Log, opts.Output); perr != nil {
+			c.Log.Err("Could not print install summary: %s", perr)
+		}
+	}
+	if err != nil {
+		return res, fmt.Errorf("Failed to upload manifests: %w", err)
+	}
+	if _, dry := c.Kube.(kubectl.PrintRunner); opts.Wait > 0 && !dry {
+		if err := c.waitForReady(chartName, res, opts.Wait); err != nil {
+			return res, err
+		}
+	}
+	c.Log.Info("Done")
+
+	printREADME(c.Log, chartName, c.Home)
+	return res, nil
+}
+
+// checkMode returns an error for an unknown install mode. An empty mode is ModeCreate.
+func checkMode(mode string) error {
+	switch mode {
+	case "", ModeCreate, ModeApply, ModeReplace:
+		return nil
+	}
+	return fmt.Errorf("Unknown install mode %q. Use create, apply, or replace.", mode)
+}
+
+// useChartArgs checks the kubectl flags of a chart's Chart.yaml against
+// kubectl.ChartFlags, and gives them to the kubectl commands of the
+// client's runner that follow. The returned function gives back the flags
+// that were in use before.
+func (c *Client) useChartArgs(ch *chart.Chart) (func(), error) {
+	k := ch.Chartfile.Kubectl
+	if k == nil {
+		return func() {}, nil
+	}
+	args := kubectl.Args{Apply: k.Apply, Delete: k.Delete}
+	if errs := kubectl.CheckChartArgs(args); len(errs) > 0 {
+		msgs := make([]string, len(errs))
+		for i, e := range errs {
+			msgs[i] = e.Error()
+		}
+		return nil, fmt.Errorf("%s of %s: %s", Chartfile, ch.Chartfile.Name, strings.Join(msgs, "; "))
+	}
+	if len(args.Apply) > 0 {
+		c.Log.Info("The chart gives kubectl create, apply, and replace: %s", strings.Join(args.Apply, " "))
+	}
+	if len(args.Delete) > 0 {
+		c.Log.Info("The chart gives kubectl delete: %s", strings.Join(args.Delete, " "))
+	}
+	return c.useKube(func(s *kubectl.Settings) { s.ChartArgs = args }), nil
+}
+
+// installPlan loads a chart for install, and returns its manifests as they
+// are sent to Kubernetes, in order: the pre-install hooks, the other
+// manifests, and the post-install hooks. Install, DryRunInstall, and Render all
+// use it, so that they agree on what a chart installs.
+//
+// If opts.Namespace is empty, it is set to the namespace of the chart's
+// Chart.yaml, if it has one. With opts.InjectNamespace, the namespace is
+// written into each manifest that has none, except those of cluster-scoped
+// kinds.
+func (c *Client) installPlan(chartName string, opts *InstallOptions) (*chart.Chart, string, []*manifest.Manifest, error) {
+	ch, chartName, err := c.loadForInstall(chartName, *opts)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	if opts.Namespace == "" && ch.Chartfile.Namespace != "" {
+		opts.Namespace = ch.Chartfile.Namespace
+		c.Log.Info("Using namespace %s, which the %s of %s gives", opts.Namespace, Chartfile, chartName)
+	}
+	var ann map[string]string
+	if opts.Annotate {
+		ann = c.chartAnnotations(ch, helm.WorkspaceChartDirectory(c.Home, chartName))
+	}
+	ms, err := orderHooks(installManifests(ch, ann))
+	if err != nil {
+		return nil, "", nil, err
+	}
+	if opts.Labels {
+		if err := labelManifests(ms, ownershipLabels(ch.Chartfile)); err != nil {
+			return nil, "", nil, err
+		}
+	}
+	if opts.InjectNamespace {
+		if err := c.injectNamespace(ms, opts.Namespace); err != nil {
+			return nil, "", nil, err
+		}
+	}
+	if err := c.checkLimits(chartName, ms, &opts.Limits); err != nil {
+		return nil, "", nil, err
+	}
+	return ch, chartName, ms, nil
+}
+
+// injectNamespace writes namespace into the manifests that have none, except
+// those of cluster-scoped kinds, such as Namespaces, ClusterRoles, and the
+// custom kinds that the chart defines with the Cluster scope.
+func (c *Client) injectNamespace(ms []*manifest.Manifest, namespace string) error {
+	if namespace == "" {
+		return errors.New("--inject-namespace requires a namespace. Give one with --namespace, or in the namespace of Chart.yaml")
+	}
+	clusterScoped := manifest.ClusterScope(ms)
+	for _, m := range ms {
+		if clusterScoped(m.Kind) {
+			continue
+		}
+		set, err := m.VersionedObject.SetDefaultNamespace(namespace)
+		if err != nil {
+			return fmt.Errorf("Could not set the namespace of %s %s: %s", m.Kind, m.Name, err)
+		}
+		if set {
+			c.Log.Debug("Set the namespace of %s %s to %s", m.Kind, m.Name, namespace)
+		}
+	}
+	return nil
+}
+
+// limitFlags are the install flags and the configuration keys that raise
+// each of the limits of manifest.Limits.
+var limitFlags = map[string][2]string{
+	manifest.LimitDocuments:     {"--max-documents", "install.maxDocuments"},
+	manifest.LimitDocumentBytes: {"--max-document-size", "install.maxDocumentKB"},
+	manifest.LimitTotalBytes:    {"--max-total-size", "install.maxTotalMB"},
+}
+
+// noLimits are the limits of InstallOptions that lift every limit.
+var noLimits = config.Install{MaxDocuments: -1, MaxDocumentKB: -1, MaxTotalMB: -1}
+
+// checkLimits checks the manifests of a chart against the limits of the
+// configuration, as flags override them, before any of them is sent to
+// Kubernetes.
+func (c *Client) checkLimits(chartName string, ms []*manifest.Manifest, flags *config.Install) error {
+	cfg, err := c.config()
+	if err != nil {
+		return err
+	}
+	err = manifest.CheckLimits(ms, cfg.ManifestLimits(flags))
+	if le, ok := err.(*manifest.LimitError); ok {
+		f := limitFlags[le.Limit]
+		return fmt.Errorf("Not installing %s: %s. Raise the limit with %s, or with %s in the configuration, if the chart really is that big.", chartName, le, f[0], f[1])
+	}
+	return err
+}
+
+// loadForInstall fetches a chart into the workspace if necessary, checks its
+// dependencies, and runs its generator if opts.Generate is set. A chart
+// archive is expanded into the workspace under the name in its Chart.yaml.
+//
+// It returns the loaded chart and its name in the workspace.
+func (c *Client) loadForInstall(chartName string, opts InstallOptions) (*chart.Chart, string, error) {
+	force := opts.Force
+	if !opts.Values.Empty() && !opts.Generate {
+		c.Log.Warn("--values, --set, and --set-from are only used by the templates of the generator. Add --generate to run it.")
+	}
 	ochart := chartName
-	r := mustConfig(home).Repos
+	cfg, err := c.config()
+	if err != nil {
+		return nil, "", err
+	}
+	r := cfg.Repos
 	table, chartName := r.RepoChart(chartName)
 
-	if !chartFetched(chartName, home) {
-		log.Info("No chart named %q in your workspace. Fetching now.", ochart)
-		fetch(chartName, chartName, home, table)
+	fetched := false
+	fo := FetchOptions{AcceptDeprecated: opts.AcceptDeprecated, VerifyKeyring: opts.VerifyKeyring}
+	if isChartArchive(ochart) {
+		if chartName, _, err = c.fetchArchive(ochart, "", fo); err != nil {
+			return nil, "", err
+		}
+		fetched = true
+	} else if !chartFetched(chartName, c.Home, c.Log) {
+		c.Log.Info("No chart named %q in your workspace. Fetching now.", ochart)
+		var err error
+		if table, chartName, err = r.Resolve(ochart); err != nil {
+			return nil, "", err
+		}
+		c.emit(&ChartResolved{Name: ochart, Repo: table, Chart: chartName})
+		if _, err := c.fetch(chartName, chartName, table, r.Searched(ochart), fo); err != nil {
+			return nil, "", err
+		}
+		fetched = true
+	} else if opts.VerifyKeyring != "" {
+		return nil, "", fmt.Errorf("Your workspace already has %s, which --verify cannot check, since fetching changed its Chart.yaml. Fetch it again with 'helmc fetch --verify --force %s', then install it without --verify.", chartName, ochart)
 	}
 
-	cd := helm.WorkspaceChartDirectory(home, chartName)
-	c, err := chart.Load(cd)
+	cd := helm.WorkspaceChartDirectory(c.Home, chartName)
+	ch, err := chart.Load(cd)
 	if err != nil {
-		log.Die("Failed to load chart: %s", err)
+		return nil, "", fmt.Errorf("Failed to load chart: %s", err)
+	}
+	// A chart that was just fetched was checked by the fetch.
+	if !fetched {
+		if err := c.checkDeprecated(ch.Chartfile, opts.AcceptDeprecated); err != nil {
+			return nil, "", err
+		}
+	}
+	// The chart is pinned as it was fetched, before the generators add to it.
+	if opts.Checksum != "" {
+		if err := chart.VerifyChecksum(cd, opts.Checksum); err != nil {
+			return nil, "", fmt.Errorf("Refusing to install %s: %s", chartName, err)
+		}
+		c.Log.Info("Chart %s has the checksum of --checksum", chartName)
+	}
+
+	if opts.Deps {
+		if _, err := c.fetchDeps(chartName, ch.Chartfile, FetchOptions{AcceptDeprecated: opts.AcceptDeprecated}); err != nil {
+			return nil, "", err
+		}
 	}
 
 	// Give user the option to bale if dependencies are not satisfied.
-	nope, err := dependency.Resolve(c.Chartfile, helm.WorkspaceChartDirectory(home))
+	nope, err := dependency.Resolve(ch.Chartfile, helm.WorkspaceChartDirectory(c.Home))
 
 	if err != nil {
-		log.Warn("Failed to check dependencies: %s", err)
+		c.Log.Warn("Failed to check dependencies: %s", err)
 		if !force {
-			log.Die("Re-run with --force to install anyway.")
+			return nil, "", errors.New("Re-run with --force to install anyway.")
 		}
 	} else if len(nope) > 0 {
-		log.Warn("Unsatisfied dependencies:")
+		c.Log.Warn("Unsatisfied dependencies:")
 		for _, d := range nope {
-			log.Msg("\t%s %s", d.Name, d.Version)
+			c.Log.Msg("\t%s %s", d.Name, d.Version)
 		}
 		if !force {
-			log.Die("Stopping install. Re-run with --force to install anyway.")
+			return nil, "", errors.New("Stopping install. Re-run with --force to install anyway.")
 		}
 	}
 
-	// Run the generator if -g is set.
-	if generate {
-		Generate(chartName, home, exclude, force)
+	// Run the generator if -g is set, and load the manifests it wrote.
+	if opts.Generate {
+		if _, err := c.Generate(chartName, GenerateOptions{Exclude: opts.Exclude, Force: force, SkipSchema: opts.SkipSchema, Values: opts.Values}); err != nil {
+			return nil, "", err
+		}
+		if ch, err = chart.Load(cd); err != nil {
+			return nil, "", fmt.Errorf("Failed to load chart: %s", err)
+		}
 	}
+	return ch, chartName, nil
+}
 
-	CheckKubePrereqs()
-
-	log.Info("Running `kubectl create -f` ...")
-	if err := uploadManifests(c, namespace, client); err != nil {
-		log.Die("Failed to upload manifests: %s", err)
+// uploadManifests runs the operations of installOperations, in order.
+//
+// The returned result records every resource that was attempted. Resources
+// that already exist are skipped when they are created; any other failure
+// stops the upload. If atomic is set, the upload stops at any failure, and
+// the resources that were created are deleted again.
+//
+// Hooks are run as they are uploaded (see Client.runHook), and a hook that
+// fails stops the upload.
+func (c *Client) uploadManifests(chartName string, ops []*PlanOperation, namespace string, atomic bool) (*InstallResult, error) {
+	res := &InstallResult{Chart: chartName, Resources: []*ResourceResult{}}
+	hooks := make([]string, len(ops))
+	for i, op := range ops {
+		hooks[i] = op.Hook
+	}
+	_, dry := c.Kube.(kubectl.PrintRunner)
+	stage := c.hookStages(hooks, dry)
+	exist := 0
+	for _, op := range ops {
+		stage(op.Hook)
+		err := c.uploadManifest(op, namespace, res)
+		rr := res.Resources[len(res.Resources)-1]
+		if err == nil && op.Hook != "" {
+			err = c.runHook(op, rr)
+		}
+		if err == nil {
+			continue
+		}
+		if !atomic && op.Op == ModeCreate && alreadyExists(rr) {
+			if op.Hook != "" {
+				c.Log.Warn("The %s hook %s %s already exists, so it was not run again.", op.Hook, rr.Kind, rr.Name)
+			}
+			exist++
+			continue
+		}
+		if atomic {
+			c.rollback(res)
+		}
+		return res, err
+	}
+	if exist > 0 {
+		return res, fmt.Errorf("%d of %d resources already exist", exist, len(res.Resources))
 	}
-	log.Info("Done")
+	return res, nil
+}
+
+// alreadyExists reports whether a resource failed because it already exists.
+func alreadyExists(rr *ResourceResult) bool {
+	return rr.Status == StatusFailed && (strings.Contains(rr.Error, "already exists") || strings.Contains(rr.Error, "AlreadyExists"))
+}
 
-	PrintREADME(chartName, home)
+// rollback deletes the resources that an install created, newest first.
+//
+// Resources that were configured or replaced are left as they are, since
+// their earlier state is not known.
+func (c *Client) rollback(res *InstallResult) {
+	if _, dry := c.Kube.(kubectl.PrintRunner); dry {
+		return
+	}
+	c.Log.Warn("Rolling back the resources created by this install.")
+	for i := len(res.Resources) - 1; i >= 0; i-- {
+		rr := res.Resources[i]
+		switch rr.Status {
+		case StatusCreated:
+			if out, err := c.Kube.Delete(rr.Name, rr.Kind, rr.Namespace); err != nil {
+				c.Log.Err("Could not delete %s %s: %s", rr.Kind, rr.Name, failure(out, err))
+				continue
+			}
+			rr.Status = StatusRolledBack
+		case StatusConfigured:
+			c.Log.Warn("%s %s was changed, and cannot be restored.", rr.Kind, rr.Name)
+		}
+	}
 }
 
-// uploadManifests sends manifests to Kubectl in a particular order.
-func uploadManifests(c *chart.Chart, namespace string, client kubectl.Runner) error {
+// installManifests returns a chart's manifests in the order they are installed.
+//
+// Unless annotations is nil, each manifest is annotated with them, and with
+// the file it came from. Other annotations on the manifest are kept.
+func installManifests(c *chart.Chart, annotations map[string]string) []*manifest.Manifest {
+	ms := []*manifest.Manifest{}
 
-	// Install known kinds in a predictable order.
-	for _, k := range InstallOrder {
+	// Install known kinds in a predictable order, and unknown kinds
+	// afterward. Order here is not predictable.
+	for _, k := range append(append([]string{}, InstallOrder...), c.UnknownKinds(InstallOrder)...) {
 		for _, m := range c.Kind[k] {
-			o := m.VersionedObject
-			o.AddAnnotations(map[string]string{
-				chart.AnnFile:         m.Source,
-				chart.AnnChartVersion: c.Chartfile.Version,
-				chart.AnnChartDesc:    c.Chartfile.Description,
-				chart.AnnChartName:    c.Chartfile.Name,
-			})
-			var data []byte
-			var err error
-			if data, err = o.JSON(); err != nil {
-				return err
+			if annotations != nil {
+				ann := map[string]string{chart.AnnFile: m.Source}
+				for k, v := range annotations {
+					ann[k] = v
+				}
+				m.VersionedObject.AddAnnotations(ann)
+				if name := annotations[chart.AnnChartName]; name != "" {
+					m.VersionedObject.AddLabels(map[string]string{chart.LabelChartName: name})
+				}
 			}
+			ms = append(ms, m)
+		}
+	}
+	return ms
+}
 
-			var action = client.Create
-			// If it's a keeper manifest, do "kubectl apply" instead of "create."
-			if manifest.IsKeeper(data) {
-				action = client.Apply
-			}
-			log.Debug("File: %s", string(data))
-			out, err := action(data, namespace)
-			log.Msg(string(out))
-			if err != nil {
-				return err
-			}
+// ownershipLabels returns the labels that mark a resource as installed by
+// helmc from the chart cf: chart.LabelHeritage, and chart.LabelChart with the
+// name and version of the chart.
+func ownershipLabels(cf *chart.Chartfile) map[string]string {
+	return map[string]string{
+		chart.LabelHeritage: chart.Heritage,
+		chart.LabelChart:    chart.LabelValue(cf.Name + "-" + cf.Version),
+	}
+}
+
+// labelManifests adds labels to each manifest of ms, replacing the values of
+// the same keys.
+func labelManifests(ms []*manifest.Manifest, labels map[string]string) error {
+	for _, m := range ms {
+		if err := m.VersionedObject.AddLabels(labels); err != nil {
+			return fmt.Errorf("Could not label %s %s (%s): %s", m.Kind, m.Name, m.Source, err)
 		}
 	}
+	return nil
+}
 
-	// Install unknown kinds afterward. Order here is not predictable.
-	for _, k := range c.UnknownKinds(InstallOrder) {
-		for _, o := range c.Kind[k] {
-			o.VersionedObject.AddAnnotations(map[string]string{chart.AnnFile: o.Source})
-			data, err := o.VersionedObject.JSON()
-			if err != nil {
-				return err
-			}
-			out, err := client.Create(data, namespace)
-			log.Msg(string(out))
-			if err != nil {
-				return err
-			}
+// chartAnnotations returns the annotations that record which chart a
+// resource was installed from.
+//
+// The digest identifies the exact contents of the chart in dir, so that
+// 'helmc status' can tell whether the cluster is running the local chart.
+func (c *Client) chartAnnotations(ch *chart.Chart, dir string) map[string]string {
+	ann := map[string]string{
+		chart.AnnChartName:    ch.Chartfile.Name,
+		chart.AnnChartVersion: ch.Chartfile.Version,
+		chart.AnnChartDesc:    ch.Chartfile.Description,
+		chart.AnnInstalledAt:  time.Now().UTC().Format(time.RFC3339),
+	}
+	if d, err := chart.Digest(dir); err != nil {
+		c.Log.Warn("Could not compute the digest of %s: %s", dir, err)
+	} else {
+		ann[chart.AnnChartDigest] = d
+	}
+	return ann
+}
+
+// newResourceResult adds a result for a manifest to res.
+func newResourceResult(m *manifest.Manifest, namespace string, res *InstallResult) *ResourceResult {
+	rr := &ResourceResult{Kind: m.Kind, Name: m.Name, Namespace: namespace}
+	if meta, err := m.VersionedObject.Meta(); err == nil && meta.Namespace != "" {
+		rr.Namespace = meta.Namespace
+	}
+	res.Resources = append(res.Resources, rr)
+	return rr
+}
+
+// failure describes a failed command by its output, or by the error if there is no output.
+func failure(out []byte, err error) string {
+	if msg := strings.TrimSpace(string(out)); msg != "" {
+		return msg
+	}
+	return err.Error()
+}
+
+// installOperations returns the operations that send manifests to
+// Kubernetes in mode, in order.
+//
+// In ModeCreate, keeper manifests (see manifest.IsKeeper) are applied
+// instead, so that they are left as they are if they already exist.
+func installOperations(ms []*manifest.Manifest, namespace, mode string) ([]*PlanOperation, error) {
+	ops := make([]*PlanOperation, len(ms))
+	for i, m := range ms {
+		data, err := m.VersionedObject.JSON()
+		if err != nil {
+			return nil, fmt.Errorf("Could not encode %s %s: %s", m.Kind, m.Name, err)
+		}
+		// Compact, so that a plan sends the same bytes once it is saved
+		// and loaded again.
+		var b bytes.Buffer
+		if err := json.Compact(&b, data); err != nil {
+			return nil, fmt.Errorf("Could not encode %s %s: %s", m.Kind, m.Name, err)
+		}
+		data = b.Bytes()
+		op := &PlanOperation{Op: mode, Kind: m.Kind, Name: m.Name, Namespace: namespace, Manifest: data, Hook: manifest.Hook(data)}
+		if meta, err := m.VersionedObject.Meta(); err == nil && meta.Namespace != "" {
+			op.Namespace = meta.Namespace
 		}
+		if mode == ModeCreate && manifest.IsKeeper(data) {
+			op.Op = ModeApply
+		}
+		ops[i] = op
 	}
+	return ops, nil
+}
+
+// uploadManifest sends the manifest of a single operation to Kubernetes,
+// recording the outcome on res.
+func (c *Client) uploadManifest(op *PlanOperation, namespace string, res *InstallResult) error {
+	rr := &ResourceResult{Kind: op.Kind, Name: op.Name, Namespace: op.Namespace, Hook: op.Hook}
+	res.Resources = append(res.Resources, rr)
 
+	var action = c.Kube.Create
+	switch op.Op {
+	case ModeApply:
+		action = c.Kube.Apply
+	case ModeReplace:
+		action = c.Kube.Replace
+	}
+	c.Log.Debug("File: %s", string(op.Manifest))
+	_, dry := c.Kube.(kubectl.PrintRunner)
+	out, err := c.kube().Retry.DoIf(fmt.Sprintf("%s %s %s", op.Op, op.Kind, op.Name), func(out []byte, err error) bool {
+		return !dry && waitingForDependency(op, res, failure(out, err))
+	}, func() ([]byte, error) {
+		return action(op.Manifest, namespace)
+	})
+	e := &ManifestApplied{Kind: op.Kind, Name: op.Name, Namespace: op.Namespace, Op: op.Op, Output: string(out), Printed: dry}
+	if err != nil {
+		rr.Status = StatusFailed
+		rr.Error = failure(out, err)
+		e.Status, e.Err = rr.Status, err
+		c.emit(e)
+		return &helmerrors.KubeError{Kind: rr.Kind, Name: rr.Name, Namespace: rr.Namespace, Output: strings.TrimSpace(string(out)), Err: err}
+	}
+	rr.Status = parseStatus(out, op.Op)
+	e.Status = rr.Status
+	c.emit(e)
+	if dry {
+		return nil
+	}
+	// Record the name Kubernetes assigned, which is not in the manifest if
+	// it uses generateName.
+	if kind, name, ok := parseObject(out); ok && strings.EqualFold(strings.SplitN(kind, ".", 2)[0], op.Kind) {
+		rr.Name = name
+	} else if op.Name == "" {
+		c.Log.Warn("Could not tell which %s was created from kubectl's output: %q", op.Kind, strings.TrimSpace(string(out)))
+	}
 	return nil
 }
 
+// waitingForDependency reports whether the failure msg of op is because a
+// Namespace or CustomResourceDefinition that the install of res already sent
+// is not ready yet: a namespace that is not found, or a kind that the API
+// server does not serve until the definition is established.
+func waitingForDependency(op *PlanOperation, res *InstallResult, msg string) bool {
+	for _, rr := range res.Resources {
+		if rr.Status != StatusCreated && rr.Status != StatusConfigured && !alreadyExists(rr) {
+			continue
+		}
+		switch rr.Kind {
+		case "Namespace":
+			if strings.Contains(msg, fmt.Sprintf("namespaces %q not found", rr.Name)) {
+				return true
+			}
+		case "CustomResourceDefinition":
+			if op.Kind == rr.Kind {
+				continue
+			}
+			for _, s := range []string{"no matches for kind", "ensure CRDs are installed first", "the server could not find the requested resource"} {
+				if strings.Contains(msg, s) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
 // Check by chart directory name whether a chart is fetched into the workspace.
 //
-// This does NOT check the Chart.yaml file.
-func chartFetched(chartName, home string) bool {
+// This does NOT check the Chart.yaml file. Messages go to l.
+func chartFetched(chartName, home string, l *log.Logger) bool {
 	p := helm.WorkspaceChartDirectory(home, chartName, Chartfile)
-	log.Debug("Looking for %q", p)
+	l.Debug("Looking for %q", p)
 	if fi, err := os.Stat(p); err != nil || fi.IsDir() {
-		log.Debug("No chart: %s", err)
+		l.Debug("No chart: %s", err)
 		return false
 	}
 	return true
 }
+
+// ChartNamespace returns namespace, or if it is empty, the namespace of the
+// Chart.yaml of the workspace chart chartName, which may be empty too.
+func ChartNamespace(home, chartName, namespace string) string {
+	if namespace != "" {
+		return namespace
+	}
+	cf, err := chart.LoadChartfile(helm.WorkspaceChartDirectory(home, chartName, Chartfile))
+	if err != nil || cf.Namespace == "" {
+		return ""
+	}
+	log.Info("Using namespace %s, which the %s of %s gives", cf.Namespace, Chartfile, chartName)
+	return cf.Namespace
+}
@@ -0,0 +1,163 @@
+package action
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"text/tabwriter"
+
+	"github.com/helm/helm-classic/log"
+)
+
+// Resource statuses reported in an InstallResult.
+const (
+	// StatusCreated indicates that the resource did not previously exist.
+	StatusCreated = "created"
+	// StatusConfigured indicates that the resource already existed and was updated.
+	StatusConfigured = "configured"
+	// StatusFailed indicates that Kubernetes rejected the resource.
+	StatusFailed = "failed"
+	// StatusRolledBack indicates that the resource was created, and then
+	// deleted because a later resource failed.
+	StatusRolledBack = "rolled back"
+	// StatusAccepted indicates that the resource passed a server-side dry run.
+	StatusAccepted = "accepted"
+	// StatusRejected indicates that the resource failed a server-side dry run.
+	StatusRejected = "rejected"
+	// StatusCompleted indicates that a hook Job or Pod ran to completion, and
+	// was then deleted, unless it is a keeper.
+	StatusCompleted = "completed"
+)
+
+// InstallResult describes the outcome of sending a chart's manifests to Kubernetes.
+type InstallResult struct {
+	Chart string `json:"chart"`
+	// DryRun is set if nothing was persisted. Resources are then either
+	// accepted or rejected.
+	DryRun    bool              `json:"dryRun,omitempty"`
+	Resources []*ResourceResult `json:"resources"`
+}
+
+// ResourceResult describes the outcome for a single resource.
+type ResourceResult struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	// Hook is the hook of the resource (see manifest.Hook), if it is one.
+	Hook string `json:"hook,omitempty"`
+}
+
+// Totals counts the resources in each status.
+func (r *InstallResult) Totals() map[string]int {
+	t := map[string]int{StatusCreated: 0, StatusConfigured: 0, StatusFailed: 0}
+	if r.DryRun {
+		t = map[string]int{StatusAccepted: 0, StatusRejected: 0}
+	}
+	for _, rr := range r.Resources {
+		t[rr.Status]++
+	}
+	return t
+}
+
+// Print writes the result to log.Stdout.
+//
+// The format is either "json" or "" (a human-readable table).
+func (r *InstallResult) Print(format string) error {
+	return r.print(nil, format)
+}
+
+// print writes the result to l, like Msg.
+func (r *InstallResult) print(l *log.Logger, format string) error {
+	switch format {
+	case "json":
+		b, err := json.MarshalIndent(struct {
+			*InstallResult
+			Totals map[string]int `json:"totals"`
+		}{r, r.Totals()}, "", "  ")
+		if err != nil {
+			return err
+		}
+		l.Msg(string(b))
+		return nil
+	case "", "table":
+		r.printTable(l)
+		return nil
+	}
+	return fmt.Errorf("unknown output format %q", format)
+}
+
+func (r *InstallResult) printTable(l *log.Logger) {
+	w := tabwriter.NewWriter(l.Out(), 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "KIND\tNAME\tNAMESPACE\tSTATUS")
+	for _, rr := range r.Resources {
+		ns := rr.Namespace
+		if ns == "" {
+			ns = "-"
+		}
+		status := rr.Status
+		if rr.Hook != "" {
+			status += " (" + rr.Hook + " hook)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", rr.Kind, rr.Name, ns, status)
+	}
+	w.Flush()
+
+	t := r.Totals()
+	if r.DryRun {
+		l.Msg("%d accepted, %d rejected", t[StatusAccepted], t[StatusRejected])
+	} else {
+		l.Msg("%d created, %d configured, %d failed", t[StatusCreated], t[StatusConfigured], t[StatusFailed])
+		if n := t[StatusCompleted]; n > 0 {
+			l.Msg("%d hooks completed", n)
+		}
+	}
+	for _, rr := range r.Resources {
+		if rr.Status == StatusFailed || rr.Status == StatusRejected {
+			l.Msg("%s/%s: %s", rr.Kind, rr.Name, rr.Error)
+		}
+	}
+}
+
+// statusRe matches the trailing status word kubectl prints for a resource,
+// e.g. `pod "redis" created` or `service/redis configured`. A replaced
+// resource counts as configured.
+var statusRe = regexp.MustCompile(`(?m)\b(created|configured|replaced|unchanged)\s*$`)
+
+// parseStatus determines a resource's status from kubectl's output.
+//
+// If the output cannot be understood, the status is inferred from the verb.
+func parseStatus(out []byte, verb string) string {
+	if m := statusRe.FindSubmatch(out); m != nil {
+		if string(m[1]) == StatusCreated {
+			return StatusCreated
+		}
+		return StatusConfigured
+	}
+	if verb == "apply" || verb == "replace" {
+		return StatusConfigured
+	}
+	return StatusCreated
+}
+
+// objectRe matches the resource kubectl reports, either as `pod "redis" created`
+// or, with `-o name`, as `pod/redis`.
+var objectRe = regexp.MustCompile(`(?m)^([\w.-]+)(?: "([^"]+)"|/(\S+))`)
+
+// parseObject determines the kind and name of the resource kubectl created
+// or configured.
+//
+// The name may differ from the manifest's, e.g. if the manifest uses
+// generateName. If the output cannot be understood, ok is false.
+func parseObject(out []byte) (kind, name string, ok bool) {
+	m := objectRe.FindSubmatch(out)
+	if m == nil {
+		return "", "", false
+	}
+	name = string(m[2])
+	if name == "" {
+		name = string(m[3])
+	}
+	return string(m[1]), name, true
+}
@@ -1,13 +1,23 @@
 package action
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/helm/helm-classic/chart"
+	"github.com/helm/helm-classic/config"
+	helmerrors "github.com/helm/helm-classic/errors"
+	"github.com/helm/helm-classic/helmpath"
 	"github.com/helm/helm-classic/kubectl"
 	"github.com/helm/helm-classic/test"
+	"github.com/helm/helm-classic/util"
 )
 
 func TestInstall(t *testing.T) {
@@ -26,7 +36,7 @@ func TestInstall(t *testing.T) {
 		{
 			name:     "with valid input",
 			chart:    "redis",
-			expected: []string{"hello from redis"},
+			expected: []string{"hello from redis", "Pod   redis  -          created", "1 created, 0 configured, 0 failed"},
 			client: TestRunner{
 				out: []byte("hello from redis"),
 			},
@@ -53,7 +63,7 @@ func TestInstall(t *testing.T) {
 		{
 			name:     "with a kubectl error",
 			chart:    "redis",
-			expected: []string{"Failed to upload manifests: oh snap"},
+			expected: []string{"Failed to upload manifests: Pod redis: oh snap"},
 			client: TestRunner{
 				err: errors.New("oh snap"),
 			},
@@ -70,12 +80,634 @@ func TestInstall(t *testing.T) {
 	os.Setenv("PATH", filepath.Join(test.HelmRoot, "testdata")+":"+pp)
 
 	for _, tt := range tests {
+		var err error
 		actual := test.CaptureOutput(func() {
-			Install(tt.chart, tmpHome, "", tt.force, false, []string{}, tt.client)
+			err = Install(tt.chart, tmpHome, InstallOptions{Force: tt.force, Annotate: true, Labels: true}, tt.client)
 		})
+		if err != nil {
+			actual += err.Error()
+		}
 
 		for _, exp := range tt.expected {
 			test.ExpectContains(t, actual, exp)
 		}
 	}
 }
+
+func TestInstallErrors(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+	test.FakeUpdate(tmpHome)
+
+	client := &kubectl.FakeRunner{Out: []byte(`Error from server: pods "redis" is forbidden`), Err: errors.New("exit status 1")}
+	var err error
+	test.CaptureOutput(func() {
+		err = Install("redis", tmpHome, InstallOptions{Namespace: "ns", Annotate: true, Labels: true}, client)
+	})
+	var ke *helmerrors.KubeError
+	if !errors.As(err, &ke) {
+		t.Fatalf("Expected a *KubeError, got %T: %v", err, err)
+	}
+	if ke.Kind != "Pod" || ke.Name != "redis" || ke.Namespace != "ns" {
+		t.Errorf("Expected the error to identify Pod redis in ns, got %s", ke.Resource())
+	}
+	if ke.Err != client.Err || !strings.Contains(ke.Output, "is forbidden") {
+		t.Errorf("Expected the error to carry kubectl's failure, got %q: %v", ke.Output, ke.Err)
+	}
+
+	// Don't reach for the network when the chart is not in the cache.
+	Defaults.Offline = true
+	defer func() { Defaults.Offline = false }()
+	test.CaptureOutput(func() {
+		err = Install("no-such-chart", tmpHome, InstallOptions{Annotate: true, Labels: true}, &kubectl.FakeRunner{})
+	})
+	var ne *helmerrors.ChartNotFoundError
+	if !errors.As(err, &ne) || !errors.Is(err, helmerrors.ErrChartNotFound) {
+		t.Fatalf("Expected a *ChartNotFoundError, got %T: %v", err, err)
+	}
+	if ne.Name != "no-such-chart" || len(ne.Repos) == 0 {
+		t.Errorf("Expected the error to name the chart and the repositories searched, got %s in %v", ne.Name, ne.Repos)
+	}
+}
+
+func TestParseStatus(t *testing.T) {
+	tests := []struct {
+		out, verb, expect string
+	}{
+		{`pod "redis" created`, "create", StatusCreated},
+		{`service/redis configured`, "apply", StatusConfigured},
+		{`service/redis unchanged`, "apply", StatusConfigured},
+		{`namespace "keep" created`, "apply", StatusCreated},
+		{`something unexpected`, "create", StatusCreated},
+		{`something unexpected`, "apply", StatusConfigured},
+	}
+
+	for _, tt := range tests {
+		if got := parseStatus([]byte(tt.out), tt.verb); got != tt.expect {
+			t.Errorf("parseStatus(%q, %q): expected %s, got %s", tt.out, tt.verb, tt.expect, got)
+		}
+	}
+}
+
+func TestParseObject(t *testing.T) {
+	tests := []struct {
+		out, kind, name string
+		ok              bool
+	}{
+		{`pod "redis-x7k2p" created`, "pod", "redis-x7k2p", true},
+		{"pod/redis-x7k2p\n", "pod", "redis-x7k2p", true},
+		{`deployment.extensions "web" configured`, "deployment.extensions", "web", true},
+		{`hello from redis`, "", "", false},
+	}
+
+	for _, tt := range tests {
+		kind, name, ok := parseObject([]byte(tt.out))
+		if kind != tt.kind || name != tt.name || ok != tt.ok {
+			t.Errorf("parseObject(%q): expected %s %s %t, got %s %s %t", tt.out, tt.kind, tt.name, tt.ok, kind, name, ok)
+		}
+	}
+}
+
+func TestInstallResultJSON(t *testing.T) {
+	res := &InstallResult{
+		Chart: "redis",
+		Resources: []*ResourceResult{
+			{Kind: "Service", Name: "redis", Status: StatusCreated},
+			{Kind: "Pod", Name: "redis", Namespace: "db", Status: StatusFailed, Error: "oh snap"},
+		},
+	}
+
+	actual := test.CaptureOutput(func() {
+		if err := res.Print("json"); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	test.ExpectContains(t, actual, `"namespace": "db"`)
+	test.ExpectContains(t, actual, `"error": "oh snap"`)
+	test.ExpectContains(t, actual, `"failed": 1`)
+	test.ExpectContains(t, actual, `"created": 1`)
+}
+
+func TestDryRunResult(t *testing.T) {
+	res := &InstallResult{
+		Chart:  "redis",
+		DryRun: true,
+		Resources: []*ResourceResult{
+			{Kind: "Service", Name: "redis", Status: StatusAccepted},
+			{Kind: "Pod", Name: "redis", Status: StatusRejected, Error: "denied by policy"},
+		},
+	}
+
+	actual := test.CaptureOutput(func() {
+		res.Print("")
+	})
+	test.ExpectContains(t, actual, "1 accepted, 1 rejected")
+	test.ExpectContains(t, actual, "Pod/redis: denied by policy")
+}
+
+func TestInstallOrder(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+	test.FakeUpdate(tmpHome)
+
+	pp := os.Getenv("PATH")
+	defer os.Setenv("PATH", pp)
+	os.Setenv("PATH", filepath.Join(test.HelmRoot, "testdata")+":"+pp)
+
+	client := &kubectl.FakeRunner{Out: []byte("created")}
+	test.CaptureOutput(func() {
+		Install("kitchensink", tmpHome, InstallOptions{Namespace: "ns", Force: true, Annotate: true, Labels: true}, client)
+	})
+
+	kinds := []string{}
+	for _, in := range client.Stdin {
+		m := struct{ Kind string }{}
+		json.Unmarshal(in, &m)
+		if len(kinds) == 0 || kinds[len(kinds)-1] != m.Kind {
+			kinds = append(kinds, m.Kind)
+		}
+	}
+	order := map[string]int{}
+	for i, k := range InstallOrder {
+		order[k] = i
+	}
+	// Unknown kinds are installed last.
+	rank := func(k string) int {
+		if i, ok := order[k]; ok {
+			return i
+		}
+		return len(InstallOrder)
+	}
+	for i := 1; i < len(kinds); i++ {
+		if rank(kinds[i-1]) > rank(kinds[i]) {
+			t.Errorf("Expected %s to be installed before %s: %v", kinds[i], kinds[i-1], kinds)
+		}
+	}
+	for _, c := range client.Calls {
+		if c != "create ns" {
+			t.Errorf("Expected only creates in namespace ns, got %q", c)
+		}
+	}
+}
+
+func TestInstallLimits(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+	test.FakeUpdate(tmpHome)
+
+	client := &kubectl.FakeRunner{}
+	var err error
+	test.CaptureOutput(func() {
+		err = Install("kitchensink", tmpHome, InstallOptions{Namespace: "ns", Force: true, Annotate: true, Labels: true, Limits: config.Install{MaxDocuments: 2}}, client)
+	})
+	if err == nil || !strings.Contains(err.Error(), "over the limit of 2") || !strings.Contains(err.Error(), "--max-documents") {
+		t.Errorf("Expected too many documents, with the flag that raises the limit, got %v", err)
+	}
+	if len(client.Calls) != 0 {
+		t.Errorf("Expected nothing to be sent to Kubernetes, got %v", client.Calls)
+	}
+
+	test.CaptureOutput(func() {
+		err = Install("kitchensink", tmpHome, InstallOptions{Namespace: "ns", Force: true, Annotate: true, Labels: true, Limits: config.Install{MaxDocuments: 2, MaxDocumentKB: -1}}, client)
+	})
+	if err == nil {
+		t.Error("Expected a second limit not to lift the first")
+	}
+	test.CaptureOutput(func() {
+		err = Install("kitchensink", tmpHome, InstallOptions{Namespace: "ns", Force: true, Annotate: true, Labels: true, Limits: config.Install{MaxDocuments: -1}}, client)
+	})
+	if err != nil || len(client.Calls) == 0 {
+		t.Errorf("Expected a negative limit to install the chart, got %v and %v", err, client.Calls)
+	}
+}
+
+func TestDryRunInstall(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+	test.FakeUpdate(tmpHome)
+
+	client := &kubectl.FakeRunner{Out: []byte(`Error from server: pods "redis" is forbidden`), Err: errors.New("exit status 1")}
+	var err error
+	actual := test.CaptureOutput(func() {
+		err = DryRunInstall("redis", tmpHome, InstallOptions{Annotate: true, Labels: true}, client)
+	})
+	test.ExpectContains(t, actual, "is forbidden")
+	if err == nil || err.Error() != "1 of 1 manifests were rejected" {
+		t.Errorf("Expected the rejection to be reported, got %v", err)
+	}
+	if len(client.Calls) != 1 || client.Calls[0] != "dry-run " {
+		t.Errorf("Expected a single dry run, got %v", client.Calls)
+	}
+
+	client = &kubectl.FakeRunner{}
+	actual = test.CaptureOutput(func() {
+		err = DryRunInstall("redis", tmpHome, InstallOptions{Annotate: true, Labels: true}, client)
+	})
+	if err != nil {
+		t.Errorf("Expected the dry run to succeed, got %s", err)
+	}
+	test.ExpectContains(t, actual, "1 accepted, 0 rejected")
+	test.ExpectContains(t, actual, "Nothing was changed")
+}
+
+// existsRunner fails to create the second resource, as if it already existed.
+type existsRunner struct {
+	kubectl.FakeRunner
+}
+
+func (r *existsRunner) Create(stdin []byte, ns string) ([]byte, error) {
+	r.FakeRunner.Create(stdin, ns)
+	if len(r.Calls) == 2 {
+		return []byte(`Error from server: pods "redis" already exists`), errors.New("exit status 1")
+	}
+	return []byte(`pod "redis" created`), nil
+}
+
+func TestInstallModes(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+	test.FakeUpdate(tmpHome)
+
+	pp := os.Getenv("PATH")
+	defer os.Setenv("PATH", pp)
+	os.Setenv("PATH", filepath.Join(test.HelmRoot, "testdata")+":"+pp)
+
+	for _, mode := range []string{ModeApply, ModeReplace} {
+		client := &kubectl.FakeRunner{Out: []byte(`pod "redis" configured`)}
+		test.CaptureOutput(func() {
+			Install("kitchensink", tmpHome, InstallOptions{Namespace: "ns", Force: true, Mode: mode, Annotate: true, Labels: true}, client)
+		})
+		for _, c := range client.Calls {
+			if c != mode+" ns" {
+				t.Errorf("Expected only %s calls, got %q", mode, c)
+			}
+		}
+	}
+
+	// Without --atomic, an existing resource does not stop the install.
+	client := &existsRunner{}
+	var err error
+	test.CaptureOutput(func() {
+		err = Install("kitchensink", tmpHome, InstallOptions{Namespace: "ns", Force: true, Mode: ModeCreate, Annotate: true, Labels: true}, client)
+	})
+	if err == nil || !strings.Contains(err.Error(), "resources already exist") {
+		t.Errorf("Expected existing resources to be reported, got %v", err)
+	}
+	if len(client.Calls) <= 2 {
+		t.Errorf("Expected the install to continue, got %v", client.Calls)
+	}
+	for _, c := range client.Calls {
+		if strings.HasPrefix(c, "delete") {
+			t.Errorf("Expected nothing to be deleted, got %q", c)
+		}
+	}
+
+	// With --atomic, it stops, and the first resource is deleted again.
+	client = &existsRunner{}
+	test.CaptureOutput(func() {
+		Install("kitchensink", tmpHome, InstallOptions{Namespace: "ns", Force: true, Mode: ModeCreate, Atomic: true, Annotate: true, Labels: true}, client)
+	})
+	if len(client.Calls) != 3 || !strings.HasPrefix(client.Calls[2], "delete ") {
+		t.Errorf("Expected a rollback of the first resource, got %v", client.Calls)
+	}
+
+	err = Install("kitchensink", tmpHome, InstallOptions{Namespace: "ns", Force: true, Mode: "upsert", Annotate: true, Labels: true}, client)
+	if err == nil || !strings.Contains(err.Error(), `Unknown install mode "upsert"`) {
+		t.Errorf("Expected an unknown mode to be rejected, got %v", err)
+	}
+}
+
+// pendingRunner fails to create the resources of a namespace, or of a custom
+// kind, the first pending times, as if the Namespace or
+// CustomResourceDefinition created before them were not ready yet.
+type pendingRunner struct {
+	kubectl.FakeRunner
+	pending int
+}
+
+func (r *pendingRunner) Create(stdin []byte, ns string) ([]byte, error) {
+	r.FakeRunner.Create(stdin, ns)
+	m := struct {
+		Kind     string
+		Metadata struct{ Name string }
+	}{}
+	json.Unmarshal(stdin, &m)
+	switch {
+	case m.Kind == "Namespace" || m.Kind == "CustomResourceDefinition":
+	case r.pending <= 0:
+	case m.Kind == "Pod":
+		r.pending--
+		return []byte(`Error from server (NotFound): namespaces "ns" not found`), errors.New("exit status 1")
+	default:
+		r.pending--
+		return []byte(`error: unable to recognize "STDIN": no matches for kind "Widget" in version "example.com/v1"`), errors.New("exit status 1")
+	}
+	return []byte(fmt.Sprintf("%s %q created", strings.ToLower(m.Kind), m.Metadata.Name)), nil
+}
+
+func TestInstallPendingDependencies(t *testing.T) {
+	old := kubectl.Defaults.Retry
+	defer func() { kubectl.Defaults.Retry = old }()
+	kubectl.Defaults.Retry = kubectl.RetryPolicy{Retries: 2, Backoff: time.Millisecond}
+
+	ops := []*PlanOperation{
+		{Op: ModeCreate, Kind: "Namespace", Name: "ns", Manifest: []byte(`{"kind":"Namespace","metadata":{"name":"ns"}}`)},
+		{Op: ModeCreate, Kind: "CustomResourceDefinition", Name: "widgets.example.com", Manifest: []byte(`{"kind":"CustomResourceDefinition","metadata":{"name":"widgets.example.com"}}`)},
+		{Op: ModeCreate, Kind: "Pod", Name: "x", Namespace: "ns", Manifest: []byte(`{"kind":"Pod","metadata":{"name":"x"}}`)},
+		{Op: ModeCreate, Kind: "Widget", Name: "x", Namespace: "ns", Manifest: []byte(`{"kind":"Widget","metadata":{"name":"x"}}`)},
+	}
+
+	// Within the retries, the resources are sent again until they are created.
+	client := &pendingRunner{pending: 2}
+	c := newClient(test.CreateTmpHome(), client)
+	defer os.RemoveAll(c.Home)
+	res, err := c.uploadManifests("widgets", ops, "ns", true)
+	if err != nil {
+		t.Fatalf("Expected the install to succeed once its dependencies are ready, got %s", err)
+	}
+	if len(client.Calls) != 6 {
+		t.Errorf("Expected the pod and the widget to be sent twice, got %v", client.Calls)
+	}
+	for _, rr := range res.Resources {
+		if rr.Status != StatusCreated {
+			t.Errorf("Expected %s %s to be created, got %s", rr.Kind, rr.Name, rr.Status)
+		}
+	}
+
+	// Past them, an atomic install rolls back what it created.
+	client = &pendingRunner{pending: 5}
+	c.Kube = client
+	if _, err := c.uploadManifests("widgets", ops, "ns", true); err == nil {
+		t.Fatal("Expected the install to fail")
+	}
+	expect := []string{"create ns", "create ns", "create ns", "create ns", "create ns", "delete CustomResourceDefinition widgets.example.com ", "delete Namespace ns "}
+	if strings.Join(client.Calls, ",") != strings.Join(expect, ",") {
+		t.Errorf("Expected %v, got %v", expect, client.Calls)
+	}
+
+	// A namespace that the install did not send is not waited for.
+	client = &pendingRunner{pending: 5}
+	c.Kube = client
+	c.uploadManifests("widgets", ops[2:3], "ns", false)
+	if len(client.Calls) != 1 {
+		t.Errorf("Expected the pod not to be sent again, got %v", client.Calls)
+	}
+}
+
+func TestInstallChartArgs(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+	test.FakeUpdate(tmpHome)
+
+	c := newClient(tmpHome, kubectl.PrintRunner{})
+	test.CaptureOutput(func() { c.Fetch("redis", "", FetchOptions{}) })
+	cf := util.WorkspaceChartDirectory(tmpHome, "redis", Chartfile)
+	ch, err := chart.LoadChartfile(cf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch.Kubectl = &chart.KubectlArgs{Apply: []string{"--validate=false"}}
+	ch.Save(cf)
+
+	// The dry run shows the flags of the chart.
+	actual := test.CaptureOutput(func() {
+		if _, err := c.Install("redis", InstallOptions{Namespace: "ns"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	test.ExpectContains(t, actual, "The chart gives kubectl create, apply, and replace: --validate=false")
+	test.ExpectContains(t, actual, "kubectl --namespace=ns create --validate=false -f -")
+	if args := c.kube().ChartArgs; len(args.Apply) != 0 || c.Kube != (kubectl.PrintRunner{}) {
+		t.Errorf("Expected the flags of the chart to be taken away again, got %v", args)
+	}
+	if len(kubectl.Defaults.ChartArgs.Apply) != 0 {
+		t.Errorf("Expected the flags of the chart not to reach other runners, got %v", kubectl.Defaults.ChartArgs)
+	}
+
+	ch.Kubectl.Apply = append(ch.Kubectl.Apply, "--server=https://example.com")
+	ch.Save(cf)
+	if _, err := c.Install("redis", InstallOptions{Namespace: "ns"}); err == nil || !strings.Contains(err.Error(), "Chart.yaml of redis: kubectl.apply: --server is not allowed in a chart") {
+		t.Errorf("Expected a disallowed flag to stop the install, got %v", err)
+	}
+}
+
+func TestInstallChecksum(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+	test.FakeUpdate(tmpHome)
+	src := util.CacheDirectory(tmpHome, "charts", "redis")
+	os.MkdirAll(filepath.Join(src, "tpl"), 0755)
+	ioutil.WriteFile(filepath.Join(src, "tpl", "copy.yaml"), []byte("#helm:generate cp manifests/redis-pod.yaml generated.yaml\n"), 0644)
+
+	out := test.CaptureOutput(func() {
+		if err := Fetch("redis", "", tmpHome, FetchOptions{PrintChecksum: true}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	sum := lines[len(lines)-1]
+	dir := util.WorkspaceChartDirectory(tmpHome, "redis")
+	if err := chart.VerifyChecksum(dir, sum); err != nil {
+		t.Fatalf("Expected the printed checksum to be the chart's, got %q: %s", sum, err)
+	}
+
+	// The checksum is checked before the generators change the chart.
+	client := &kubectl.FakeRunner{}
+	c := newClient(tmpHome, client)
+	c.AllowGenerators = []string{"cp"}
+	test.CaptureOutput(func() {
+		if _, err := c.Install("redis", InstallOptions{Namespace: "ns", Generate: true, Checksum: sum}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if _, err := os.Stat(filepath.Join(dir, "generated.yaml")); err != nil {
+		t.Fatalf("Expected the generator to run after the checksum was checked: %s", err)
+	}
+
+	// The generated file is now part of the chart, so it no longer matches.
+	now, _ := chart.Checksum(dir)
+	calls := len(client.Calls)
+	_, err := c.Install("redis", InstallOptions{Namespace: "ns", Checksum: sum})
+	if err == nil || !strings.Contains(err.Error(), "expected "+sum+", got "+now) {
+		t.Errorf("Expected a mismatch with both checksums, got %v", err)
+	}
+	if len(client.Calls) != calls {
+		t.Errorf("Expected nothing to be installed, got %v", client.Calls[calls:])
+	}
+}
+
+func TestInstallChecksumIgnored(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+	test.FakeUpdate(tmpHome)
+	src := util.CacheDirectory(tmpHome, "charts", "redis")
+	ioutil.WriteFile(filepath.Join(src, chart.IgnoreFile), []byte(".*\n"), 0644)
+
+	out := test.CaptureOutput(func() {
+		if err := Fetch("redis", "", tmpHome, FetchOptions{PrintChecksum: true}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	sum := lines[len(lines)-1]
+
+	// A manifest that .helmignore excludes is still installed, so it must
+	// change the checksum.
+	dir := util.WorkspaceChartDirectory(tmpHome, "redis")
+	ioutil.WriteFile(filepath.Join(dir, chart.IgnoreFile), []byte(".*\nevil.yaml\n"), 0644)
+	pod := "apiVersion: v1\nkind: Pod\nmetadata:\n  name: evil\n"
+	ioutil.WriteFile(filepath.Join(dir, "manifests", "evil.yaml"), []byte(pod), 0644)
+
+	client := &kubectl.FakeRunner{}
+	c := newClient(tmpHome, client)
+	if _, err := c.Install("redis", InstallOptions{Namespace: "ns", Checksum: sum}); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("Expected a mismatch, got %v", err)
+	}
+	if len(client.Calls) != 0 {
+		t.Errorf("Expected nothing to be installed, got %v", client.Calls)
+	}
+}
+
+func TestInstallNamespace(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+	test.FakeUpdate(tmpHome)
+	Fetch("redis", "", tmpHome, FetchOptions{})
+	dir := util.WorkspaceChartDirectory(tmpHome, "redis")
+	cf, _ := chart.LoadChartfile(filepath.Join(dir, Chartfile))
+	cf.Namespace = "cache"
+	cf.Save(filepath.Join(dir, Chartfile))
+	ns := "apiVersion: v1\nkind: Namespace\nmetadata:\n  name: cache\n"
+	ioutil.WriteFile(filepath.Join(dir, "manifests", "redis-ns.yaml"), []byte(ns), 0644)
+
+	client := &kubectl.FakeRunner{}
+	c := newClient(tmpHome, client)
+	test.CaptureOutput(func() {
+		if _, err := c.Install("redis", InstallOptions{InjectNamespace: true}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if len(client.Calls) != 2 || client.Calls[1] != "create cache" {
+		t.Fatalf("Expected the namespace of Chart.yaml, got %v", client.Calls)
+	}
+	if strings.Contains(string(client.Stdin[0]), `"namespace"`) {
+		t.Errorf("Expected the Namespace to be left alone, got %s", client.Stdin[0])
+	}
+	if !strings.Contains(string(client.Stdin[1]), `"namespace":"cache"`) {
+		t.Errorf("Expected the namespace to be injected into the pod, got %s", client.Stdin[1])
+	}
+	if got := ChartNamespace(tmpHome, "redis", "other"); got != "other" {
+		t.Errorf("Expected a given namespace to win, got %q", got)
+	}
+	test.CaptureOutput(func() {
+		if got := ChartNamespace(tmpHome, "redis", ""); got != "cache" {
+			t.Errorf("Expected the namespace of Chart.yaml, got %q", got)
+		}
+	})
+}
+
+// tenantCRD defines Tenant, a custom kind of the Cluster scope.
+const tenantCRD = `apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: tenants.example.com
+spec:
+  group: example.com
+  scope: Cluster
+  names:
+    kind: Tenant
+    plural: tenants
+`
+
+// writeManifest writes a manifest into the manifests of the chart in dir.
+func writeManifest(t *testing.T, dir, name, data string) {
+	if err := ioutil.WriteFile(filepath.Join(dir, "manifests", name), []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInstallNamespaceClusterScoped(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+	test.FakeUpdate(tmpHome)
+	Fetch("redis", "", tmpHome, FetchOptions{})
+	dir := util.WorkspaceChartDirectory(tmpHome, "redis")
+	writeManifest(t, dir, "reader.yaml", "apiVersion: rbac.authorization.k8s.io/v1\nkind: ClusterRole\nmetadata:\n  name: reader\n")
+	writeManifest(t, dir, "fast.yaml", "apiVersion: storage.k8s.io/v1\nkind: StorageClass\nmetadata:\n  name: fast\n")
+	writeManifest(t, dir, "crd.yaml", tenantCRD)
+	writeManifest(t, dir, "tenant.yaml", "apiVersion: example.com/v1\nkind: Tenant\nmetadata:\n  name: acme\n")
+
+	client := &kubectl.FakeRunner{}
+	c := newClient(tmpHome, client)
+	test.CaptureOutput(func() {
+		if _, err := c.Install("redis", InstallOptions{Namespace: "cache", InjectNamespace: true}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if len(client.Stdin) != 5 {
+		t.Fatalf("Expected 5 manifests, got %v", client.Calls)
+	}
+	for _, in := range client.Stdin {
+		pod := strings.Contains(string(in), `"kind":"Pod"`)
+		if injected := strings.Contains(string(in), `"namespace":"cache"`); injected != pod {
+			t.Errorf("Expected the namespace to be injected only into the pod, got %s", in)
+		}
+	}
+}
+
+func TestInstallStateless(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+	test.FakeUpdate(tmpHome)
+
+	client := &kubectl.FakeRunner{Out: []byte(`pod "redis" created`)}
+	test.CaptureOutput(func() {
+		if err := Install("redis", tmpHome, InstallOptions{Namespace: "cache", Annotate: true, Labels: true, Stateless: true}, client); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if len(client.Calls) != 1 || client.Calls[0] != "create cache" {
+		t.Errorf("Expected redis to be created in cache, got %v", client.Calls)
+	}
+	if chartFetched("redis", tmpHome, nil) {
+		t.Errorf("Expected a stateless install to leave the workspace alone")
+	}
+	revs, err := newClient(tmpHome, client).History("redis")
+	if err != nil || len(revs) != 1 || revs[0].Namespace != "cache" {
+		t.Errorf("Expected the install to be recorded in the home, got %v, %v", revs, err)
+	}
+	if _, err := os.Stat(helmpath.Home(tmpHome).Audit()); err != nil {
+		t.Errorf("Expected the install to be audited in the home: %s", err)
+	}
+
+	// A workspace that the environment moves is left alone too.
+	ws := filepath.Join(tmpHome, "moved")
+	os.Setenv(helmpath.WorkspaceEnvVar, ws)
+	defer os.Unsetenv(helmpath.WorkspaceEnvVar)
+	test.CaptureOutput(func() {
+		if err := Install("redis", tmpHome, InstallOptions{Namespace: "cache", Annotate: true, Labels: true, Stateless: true}, client); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if chartFetched("redis", tmpHome, nil) || os.Getenv(helmpath.WorkspaceEnvVar) != ws {
+		t.Errorf("Expected a stateless install to leave the moved workspace alone, and $%s", helmpath.WorkspaceEnvVar)
+	}
+
+	// Generators are given the temporary workspace in their environment.
+	src := util.CacheDirectory(tmpHome, "charts", "redis")
+	os.MkdirAll(filepath.Join(src, "tpl"), 0755)
+	out := filepath.Join(tmpHome, "workspace.txt")
+	ioutil.WriteFile(filepath.Join(src, "tpl", "ws.yaml"), []byte("#helm:generate sh -c 'echo $HELMC_WORKSPACE > "+out+"'\n"), 0644)
+	c := newClient(tmpHome, client)
+	c.AllowGenerators = []string{"sh"}
+	test.CaptureOutput(func() {
+		if _, err := c.Install("redis", InstallOptions{Namespace: "cache", Stateless: true, Generate: true}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	b, _ := ioutil.ReadFile(out)
+	if w := strings.TrimSpace(string(b)); w == "" || w == ws || strings.HasPrefix(w, tmpHome) {
+		t.Errorf("Expected the generator to be given the temporary workspace, got %q", w)
+	}
+}
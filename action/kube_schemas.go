@@ -0,0 +1,161 @@
+package action
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/helm/helm-classic/helmpath"
+	"github.com/helm/helm-classic/kubeschema"
+	"github.com/helm/helm-classic/repo"
+	"github.com/helm/helm-classic/validation"
+)
+
+// DefaultKubeVersion is the Kubernetes release whose schemas lint checks
+// manifests against, unless it is told otherwise.
+//
+// It is the last release to serve both extensions/v1beta1 and apps/v1, so
+// that older and newer charts are both checked.
+const DefaultKubeVersion = "v1.15.12"
+
+// KubeSchemaURL is where the Swagger document of a Kubernetes release is
+// downloaded from. %s is the release, such as v1.15.12. Tests set it to "",
+// which never downloads anything.
+var KubeSchemaURL = "https://raw.githubusercontent.com/kubernetes/kubernetes/%s/api/openapi-spec/swagger.json"
+
+// kubeSchemaFile is the name of the cached Swagger document of a release.
+const kubeSchemaFile = "swagger.json"
+
+// LintOptions are the options of Lint and LintAll.
+type LintOptions struct {
+	// KubeVersion is the Kubernetes release whose schemas manifests are
+	// checked against, such as v1.21.14, or 1.21 for v1.21.0. The default is
+	// DefaultKubeVersion.
+	KubeVersion string
+	// SchemaDir holds more schemas, such as the CustomResourceDefinitions
+	// of the custom kinds that charts use.
+	SchemaDir string
+}
+
+// kubeSchemas returns the schemas that manifests are checked against: those
+// of the Kubernetes release, and those in the schema directory.
+//
+// The schemas of a release are downloaded once, and cached in the home
+// directory, so lint works offline from then on. If they cannot be
+// downloaded, lint goes on without them. A schema directory that cannot be
+// read is an error.
+func (c *Client) kubeSchemas(opts LintOptions) (*kubeschema.Set, error) {
+	set := kubeschema.NewSet()
+	version := kubeRelease(opts.KubeVersion)
+	if b, err := c.kubeSwagger(version); err != nil {
+		c.Log.Warn("Manifests are not checked against the schemas of Kubernetes %s: %s", version, err)
+	} else if b != nil {
+		if _, err := set.AddSwagger(b); err != nil {
+			c.Log.Warn("Manifests are not checked against the schemas of Kubernetes %s, whose cached copy is unusable: %s", version, err)
+		}
+	}
+	if opts.SchemaDir != "" {
+		n, err := set.AddDir(opts.SchemaDir)
+		if err != nil {
+			return nil, fmt.Errorf("Could not load the schemas in %s: %s", opts.SchemaDir, err)
+		}
+		c.Log.Debug("Loaded %d schemas from %s", n, opts.SchemaDir)
+	}
+	if set.Len() == 0 {
+		c.Log.Info("No Kubernetes schemas are available, so manifests are not checked against them.")
+	}
+	return set, nil
+}
+
+// kubeRelease returns the tag of a Kubernetes release, as its Swagger
+// document is downloaded by: 1.21 is v1.21.0, and 1.21.3 is v1.21.3. An
+// empty version is DefaultKubeVersion.
+func kubeRelease(version string) string {
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+	if version == "" {
+		return DefaultKubeVersion
+	}
+	if strings.Count(version, ".") == 1 {
+		version += ".0"
+	}
+	return "v" + version
+}
+
+// kubeSwagger returns the Swagger document of a Kubernetes release, from the
+// cache, or else downloaded into it. It is nil if there is no cached copy,
+// and nothing may be downloaded.
+func (c *Client) kubeSwagger(version string) ([]byte, error) {
+	path := helmpath.Home(c.Home).Schemas(version, kubeSchemaFile)
+	if b, err := ioutil.ReadFile(path); err == nil {
+		c.Log.Debug("Using the cached schemas %s", path)
+		return b, nil
+	}
+	if c.Offline || KubeSchemaURL == "" {
+		c.Log.Debug("No cached schemas for Kubernetes %s, and they may not be downloaded", version)
+		return nil, nil
+	}
+
+	b, err := repo.Get(fmt.Sprintf(KubeSchemaURL, version), "")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := kubeschema.NewSet().AddSwagger(b); err != nil {
+		return nil, fmt.Errorf("the download is not a Swagger document: %s", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(path, b, 0644); err != nil {
+		return nil, err
+	}
+	c.Log.Info("Cached the schemas of Kubernetes %s in %s", version, path)
+	return b, nil
+}
+
+// lintKubeSchemas checks every manifest of a chart against the schema of its
+// kind.
+//
+// A kind of Kubernetes itself that the release does not serve as the
+// manifest's apiVersion, such as extensions/v1beta1 Deployment after 1.16,
+// is an error that says which apiVersions it is served as. Other kinds
+// without a schema are noted, and not checked.
+func (c *Client) lintKubeSchemas(set *kubeschema.Set, cv *validation.ChartValidation, parent *validation.Validation) {
+	if set.Len() == 0 {
+		return
+	}
+	parent.AddError("Manifests conform to the schemas of their kinds", func(path string, v *validation.Validation) bool {
+		ok := true
+		for _, m := range cv.Manifests {
+			src := m.Source
+			if rel, err := filepath.Rel(path, src); err == nil {
+				src = rel
+			}
+			if !set.Known(m.Version, m.Kind) && set.HasKubernetes() && kubeschema.KubernetesGroup(m.Version) {
+				if served := set.Served(m.Kind); len(served) > 0 {
+					c.Log.Err("%s: %s %s: Kubernetes does not serve %s %s; it serves %s as %s", src, m.Kind, m.Name, m.Version, m.Kind, m.Kind, strings.Join(served, ", "))
+				} else {
+					c.Log.Err("%s: %s %s: Kubernetes does not serve %s %s", src, m.Kind, m.Name, m.Version, m.Kind)
+				}
+				ok = false
+				continue
+			}
+			if !set.Known(m.Version, m.Kind) {
+				c.Log.Info("%s: there is no schema for %s %s, so %s is not checked", src, m.Version, m.Kind, m.Name)
+				continue
+			}
+			var obj interface{}
+			if err := m.VersionedObject.Object(&obj); err != nil {
+				c.Log.Err("%s: %s %s: %s", src, m.Kind, m.Name, err)
+				ok = false
+				continue
+			}
+			for _, e := range set.Validate(m.Version, m.Kind, obj) {
+				c.Log.Err("%s: %s %s: %s", src, m.Kind, m.Name, e)
+				ok = false
+			}
+		}
+		return ok
+	})
+}
@@ -1,16 +1,25 @@
 package action
 
 import (
+	"errors"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"regexp"
 
 	"github.com/google/go-github/github"
+	"github.com/helm/helm-classic/chart"
+	helmerrors "github.com/helm/helm-classic/errors"
+	"github.com/helm/helm-classic/generator"
+	"github.com/helm/helm-classic/kubectl"
+	"github.com/helm/helm-classic/kubeschema"
 	"github.com/helm/helm-classic/log"
 	"github.com/helm/helm-classic/manifest"
 	"github.com/helm/helm-classic/util"
 	"github.com/helm/helm-classic/validation"
+	"gopkg.in/yaml.v2"
 )
 
 const (
@@ -35,27 +44,83 @@ type GHRepoService interface {
 // LintAll vlaidates all charts are well-formed
 //
 // - homedir is the home directory for the user
-func LintAll(homedir string) {
-	md := util.WorkspaceChartDirectory(homedir, "*")
+// - opts are the schemas that manifests are checked against
+//
+// Every chart is checked. If any fail, a *helmerrors.LintError naming them is returned.
+func LintAll(homedir string, opts LintOptions) error {
+	return newClient(homedir, nil).LintAll(opts)
+}
+
+// LintAll is like the package-level LintAll.
+func (c *Client) LintAll(opts LintOptions) error {
+	md := util.WorkspaceChartDirectory(c.Home, "*")
 	chartPaths, err := filepath.Glob(md)
 	if err != nil {
-		log.Warn("Could not find any charts in %q: %s", md, err)
+		c.Log.Warn("Could not find any charts in %q: %s", md, err)
 	}
 
 	if len(chartPaths) == 0 {
-		log.Warn("Could not find any charts in %q", md)
-	} else {
-		for _, chartPath := range chartPaths {
-			Lint(chartPath)
+		c.Log.Warn("Could not find any charts in %q", md)
+		return nil
+	}
+	schemas, err := c.kubeSchemas(opts)
+	if err != nil {
+		return err
+	}
+	failed := []string{}
+	for _, chartPath := range chartPaths {
+		var le *helmerrors.LintError
+		if err := c.lint(chartPath, schemas); errors.As(err, &le) {
+			failed = append(failed, le.Charts...)
+		} else if err != nil {
+			return err
 		}
 	}
+	if len(failed) > 0 {
+		return &helmerrors.LintError{Charts: failed}
+	}
+	return nil
 }
 
 // Lint validates that a chart is well-formed
 //
 // - chartPath path to chart directory
-func Lint(chartPath string) {
-	cv := new(validation.ChartValidation)
+// - homedir is the home directory for the user, used to locate a lint policy
+// and the cached Kubernetes schemas
+// - opts are the schemas that manifests are checked against
+//
+// If the chart fails some necessary checks, a *helmerrors.LintError is returned.
+func Lint(chartPath, homedir string, opts LintOptions) error {
+	return newClient(homedir, nil).Lint(chartPath, opts)
+}
+
+// Lint is like the package-level Lint.
+func (c *Client) Lint(chartPath string, opts LintOptions) error {
+	schemas, err := c.kubeSchemas(opts)
+	if err != nil {
+		return err
+	}
+	return c.lint(chartPath, schemas)
+}
+
+// lint checks a chart, and its manifests against schemas.
+func (c *Client) lint(chartPath string, schemas *kubeschema.Set) error {
+	cv := c.lintValidations(chartPath, schemas)
+	if cv.Valid() {
+		c.Log.Info("Chart [%s] has passed all necessary checks", cv.ChartName())
+	} else {
+		if cv.ErrorCount > 0 {
+			return &helmerrors.LintError{Charts: []string{cv.ChartName()}}
+		}
+		c.Log.Warn("Chart [%s] has passed all necessary checks but failed some checks as well. Proceed with caution. Check out the warnings listed.", cv.ChartName())
+	}
+	return nil
+}
+
+// lintValidations returns the checks of a chart, which are run by its Valid.
+func (c *Client) lintValidations(chartPath string, schemas *kubeschema.Set) *validation.ChartValidation {
+	cv := &validation.ChartValidation{Log: c.Log}
+	policy, policyErr := c.lintPolicy(chartPath)
 
 	chartPresenceValidation := cv.AddError("Chart found at "+chartPath, func(path string, v *validation.Validation) bool {
 		stat, err := os.Stat(chartPath)
@@ -87,18 +152,31 @@ func Lint(chartPath string) {
 		return cv.Chartfile.Name == cv.ChartName()
 	})
 
-	chartYamlValidation.AddError("Chart.yaml has a version field", func(path string, v *validation.Validation) bool {
-		return cv.Chartfile.Version != ""
+	chartYamlValidation.AddError("Chart.yaml gives kubectl only allowed flags", func(path string, v *validation.Validation) bool {
+		k := cv.Chartfile.Kubectl
+		if k == nil {
+			return true
+		}
+		errs := kubectl.CheckChartArgs(kubectl.Args{Apply: k.Apply, Delete: k.Delete})
+		for _, e := range errs {
+			c.Log.Err("%s", e)
+		}
+		return len(errs) == 0
 	})
 
-	chartYamlValidation.AddWarning("Chart.yaml has a description field", func(path string, v *validation.Validation) bool {
-		return cv.Chartfile.Description != ""
+	chartYamlValidation.AddWarning("A deprecated chart gives a deprecationMessage", func(path string, v *validation.Validation) bool {
+		return !cv.Chartfile.Deprecated || cv.Chartfile.DeprecationMessage != ""
 	})
 
-	chartYamlValidation.AddWarning("Chart.yaml has a maintainers field", func(path string, v *validation.Validation) bool {
-		return cv.Chartfile.Maintainers != nil
+	chartPresenceValidation.AddError("Lint policy is valid", func(path string, v *validation.Validation) bool {
+		if policyErr != nil {
+			c.Log.Err("%s", policyErr)
+		}
+		return policyErr == nil
 	})
 
+	policy.Apply(chartYamlValidation, cv)
+
 	chartPresenceValidation.AddWarning("README.md is present and not empty", func(path string, v *validation.Validation) bool {
 		readmePath := filepath.Join(path, "README.md")
 		stat, err := os.Stat(readmePath)
@@ -106,6 +184,68 @@ func Lint(chartPath string) {
 		return err == nil && stat.Mode().IsRegular() && stat.Size() > 0
 	})
 
+	chartPresenceValidation.AddError("Files are safe to fetch", func(path string, v *validation.Validation) bool {
+		limits := chart.DefaultLimits
+		if cfg, err := c.config(); err == nil {
+			limits = cfg.Limits()
+		}
+		unsafe, err := chart.CheckFiles(chartPath, limits)
+		if err != nil {
+			c.Log.Err("Could not read the files of the chart: %s", err)
+			return false
+		}
+		for _, u := range unsafe {
+			c.Log.Err("%s", u)
+		}
+		return len(unsafe) == 0
+	})
+
+	chartPresenceValidation.AddError("Generators use only defined variables", func(path string, v *validation.Validation) bool {
+		// Nothing is run, so the values only need to be plausible.
+		defaultRepo := ""
+		if cfg, err := c.config(); err == nil {
+			defaultRepo = cfg.Repos.Default
+		}
+		env := generateEnv(c.Home, filepath.Base(chartPath), chartPath, defaultRepo, false, false, ValueSources{})
+		undefined, err := generator.Check(chartPath, nil, env)
+		if err != nil {
+			c.Log.Err("Could not read the generators: %s", err)
+			return false
+		}
+		for _, u := range undefined {
+			c.Log.Err("%s", u)
+		}
+		return len(undefined) == 0
+	})
+
+	chartPresenceValidation.AddWarning("Generated files have not been edited since they were generated", func(path string, v *validation.Validation) bool {
+		state, err := generator.LoadState(chartPath)
+		if err != nil {
+			c.Log.Warn("Could not read %s: %s", generator.StateFile, err)
+			return false
+		}
+		edited := 0
+		for _, o := range state.Sorted() {
+			if o.Edited(chartPath) {
+				c.Log.Warn("%s was edited since the generator of %s wrote it. Edit the generator's files instead, since 'helmc generate' overwrites it.", o.Path, o.Source)
+				edited++
+			}
+		}
+		return edited == 0
+	})
+
+	chartPresenceValidation.AddError("Generators run after generators that exist, without a cycle", func(path string, v *validation.Validation) bool {
+		if err := generator.CheckOrder(chartPath, nil); err != nil {
+			c.Log.Err("%s", err)
+			return false
+		}
+		return true
+	})
+
+	if _, where, _ := chart.LoadValuesSchema(chartPath); where != "" {
+		c.lintSchema(chartPath, where, chartPresenceValidation)
+	}
+
 	manifestsValidation := chartPresenceValidation.AddError("Manifests directory is present", func(path string, v *validation.Validation) bool {
 		stat, err := os.Stat(v.ChartManifestsPath())
 
@@ -121,6 +261,31 @@ func Lint(chartPath string) {
 		return err == nil && cv.Manifests != nil
 	})
 
+	c.lintKubeSchemas(schemas, cv, manifestsParsingValidation)
+	c.lintResources(chartPath, policy.Resources, cv, manifestsParsingValidation)
+
+	manifestsParsingValidation.AddError("Manifests have known hooks", func(path string, v *validation.Validation) bool {
+		_, err := orderHooks(cv.Manifests)
+		if err != nil {
+			c.Log.Err("%s", err)
+		}
+		return err == nil
+	})
+
+	manifestsParsingValidation.AddError("Manifests are within the limits of install", func(path string, v *validation.Validation) bool {
+		limits := manifest.DefaultLimits
+		if cfg, err := c.config(); err == nil {
+			limits = cfg.ManifestLimits(nil)
+		}
+		err := manifest.CheckLimits(cv.Manifests, limits)
+		if le, ok := err.(*manifest.LimitError); ok {
+			c.Log.Err("%s. helmc install refuses it unless %s raises the limit.", le, limitFlags[le.Limit][0])
+		} else if err != nil {
+			c.Log.Err("%s", err)
+		}
+		return err == nil
+	})
+
 	manifestsParsingValidation.AddWarning("Manifests have correct and valid metadata", func(path string, v *validation.Validation) bool {
 
 		success := true
@@ -158,13 +323,157 @@ func Lint(chartPath string) {
 		return success
 	})
 
-	if cv.Valid() {
-		log.Info("Chart [%s] has passed all necessary checks", cv.ChartName())
-	} else {
-		if cv.ErrorCount > 0 {
-			log.Err("Chart [%s] has failed some necessary checks. Check out the error and warning messages listed.", cv.ChartName())
-		} else {
-			log.Warn("Chart [%s] has passed all necessary checks but failed some checks as well. Proceed with caution. Check out the warnings listed.", cv.ChartName())
+	return cv
+}
+
+// lintSchema checks the values schema of a chart, which is declared where
+// chart.LoadValuesSchema says, and that the chart's default values conform to
+// it.
+func (c *Client) lintSchema(chartPath, where string, parent *validation.Validation) {
+	title, name := where, where
+	if where != chart.InSchemaFile {
+		title, name = "The "+where, "the "+where
+	}
+	var schema *chart.Schema
+	schemaValidation := parent.AddError(title+" is a valid schema", func(path string, v *validation.Validation) bool {
+		s, _, err := chart.LoadValuesSchema(path)
+		if err != nil {
+			c.Log.Err("%s", err)
+			return false
+		}
+		schema = s
+		return true
+	})
+
+	schemaValidation.AddError(chart.ValuesFile+", if present, conforms to "+name, func(path string, v *validation.Validation) bool {
+		b, err := ioutil.ReadFile(filepath.Join(path, chart.ValuesFile))
+		if os.IsNotExist(err) {
+			return true
+		} else if err != nil {
+			c.Log.Err("%s", err)
+			return false
+		}
+		var vals interface{}
+		if err := yaml.Unmarshal(b, &vals); err != nil {
+			c.Log.Err("%s is not valid YAML: %s", chart.ValuesFile, err)
+			return false
+		}
+		violations := schema.Validate(vals)
+		for _, e := range violations {
+			c.Log.Err("%s", e)
+		}
+		return len(violations) == 0
+	})
+}
+
+// lintResources checks the resources of the containers of a chart, with the
+// rule of its lint policy, if it has one.
+func (c *Client) lintResources(chartPath string, rule *validation.ResourceRule, cv *validation.ChartValidation, parent *validation.Validation) {
+	if rule == nil {
+		rule = &validation.ResourceRule{}
+	}
+	add, report := parent.AddError, c.Log.Err
+	if rule.Warning() {
+		add, report = parent.AddWarning, c.Log.Warn
+	}
+
+	var findings *validation.ResourceFindings
+	checked := false
+	check := func(get func(*validation.ResourceFindings) []*validation.ResourceFinding) bool {
+		if !checked {
+			checked = true
+			dir, ms, cleanup := c.lintManifests(chartPath, cv.Manifests)
+			defer cleanup()
+			f, err := validation.CheckResources(dir, ms, rule)
+			if err != nil {
+				c.Log.Err("Could not check the resources of the containers: %s", err)
+			}
+			findings = f
+		}
+		if findings == nil {
+			return false
+		}
+		for _, f := range get(findings) {
+			report("%s", f)
+		}
+		return len(get(findings)) == 0
+	}
+
+	add("Containers have resources.requests", func(path string, v *validation.Validation) bool {
+		return check(func(f *validation.ResourceFindings) []*validation.ResourceFinding { return f.NoRequests })
+	})
+	add("Container limits are not lower than their requests", func(path string, v *validation.Validation) bool {
+		return check(func(f *validation.ResourceFindings) []*validation.ResourceFinding { return f.LimitBelowRequest })
+	})
+	add("Container resources are quantities within the lint policy's maximums", func(path string, v *validation.Validation) bool {
+		return check(func(f *validation.ResourceFindings) []*validation.ResourceFinding { return f.OverMax })
+	})
+}
+
+// lintManifests returns the manifests of a chart as install would send them,
+// and the directory that their files are in. A chart with generators is
+// generated first, in a temporary copy, which the returned function removes,
+// so that the manifests of its templates are checked. If that fails, ms, the
+// manifests of the chart as they are, are returned.
+func (c *Client) lintManifests(chartPath string, ms []*manifest.Manifest) (string, []*manifest.Manifest, func()) {
+	defaultRepo, limits := "", chart.DefaultLimits
+	cfg, err := c.config()
+	if err == nil {
+		defaultRepo, limits = cfg.Repos.Default, cfg.Limits()
+	}
+	name := filepath.Base(chartPath)
+	env := generateEnv(c.Home, name, chartPath, defaultRepo, false, false, ValueSources{})
+	// The dry run only counts the generators, so the policy is left to the
+	// run below, whose error is reported.
+	all := &generator.Policy{Allow: []string{generator.AllowAll}}
+	if counts, err := generator.Walk(util.Context(), chartPath, generator.Options{DryRun: true, Policy: all, Env: env, Log: &log.Logger{}}); err != nil || counts.Total() == 0 {
+		return chartPath, ms, func() {}
+	}
+	// A generator with an undefined variable would not give the manifests
+	// that install does, and is reported already.
+	if undefined, err := generator.Check(chartPath, nil, env); err != nil || len(undefined) > 0 {
+		return chartPath, ms, func() {}
+	}
+
+	tmp, cleanup, err := util.TempDir("", "helmc-lint")
+	if err != nil {
+		c.Log.Warn("Could not generate the chart, so its manifests are checked as they are: %s", err)
+		return chartPath, ms, func() {}
+	}
+	dir := filepath.Join(tmp, name)
+	if _, err = chart.CopyFiles(chartPath, dir, limits, false); err == nil {
+		env = generateEnv(c.Home, name, dir, defaultRepo, true, false, ValueSources{})
+		var counts generator.Counts
+		if counts, err = generator.Walk(util.Context(), dir, generator.Options{Force: true, Policy: c.generatorPolicy(cfg), Env: env, Log: &log.Logger{}}); err == nil {
+			c.Log.Info("Ran %d generators in a copy of the chart to check the resources of its containers.", counts.Total())
+			var generated []*manifest.Manifest
+			if generated, err = manifest.ParseDir(dir); err == nil {
+				return dir, generated, cleanup
+			}
+		}
+	}
+	c.Log.Warn("Could not generate the chart, so its manifests are checked as they are: %s", err)
+	cleanup()
+	return chartPath, ms, func() {}
+}
+
+// lintPolicy finds the lint policy for a chart.
+//
+// A policy inside the chart wins over one in the home directory. If neither
+// exists, the default policy is used. So is it if the policy cannot be
+// loaded, but then the error, which names the file, is returned too, and
+// fails the lint: a policy with a typo must not silently stop applying.
+func (c *Client) lintPolicy(chartPath string) (*validation.Policy, error) {
+	for _, p := range []string{filepath.Join(chartPath, validation.PolicyFile), filepath.Join(c.Home, validation.PolicyFile)} {
+		if _, err := os.Stat(p); err != nil {
+			continue
+		}
+		policy, err := validation.LoadPolicy(p)
+		if err != nil {
+			return validation.DefaultPolicy(), fmt.Errorf("Could not load lint policy %s: %s", p, err)
 		}
+		c.Log.Debug("Using lint policy %s", p)
+		return policy, nil
 	}
+	return validation.DefaultPolicy(), nil
 }
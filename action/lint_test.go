@@ -3,12 +3,17 @@ package action
 import (
 	"fmt"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	helmerrors "github.com/helm/helm-classic/errors"
 	"github.com/helm/helm-classic/test"
 	"github.com/helm/helm-classic/util"
+	"github.com/helm/helm-classic/validation"
 
 	"gopkg.in/yaml.v2"
 )
@@ -19,10 +24,10 @@ func TestLintSuccess(t *testing.T) {
 
 	chartName := "goodChart"
 
-	Create(chartName, tmpHome)
+	Create(chartName, tmpHome, "")
 
 	output := test.CaptureOutput(func() {
-		Lint(util.WorkspaceChartDirectory(tmpHome, chartName))
+		Lint(util.WorkspaceChartDirectory(tmpHome, chartName), tmpHome, LintOptions{})
 	})
 
 	expected := "Chart [goodChart] has passed all necessary checks"
@@ -36,33 +41,59 @@ func TestLintMissingReadme(t *testing.T) {
 
 	chartName := "badChart"
 
-	Create(chartName, tmpHome)
+	Create(chartName, tmpHome, "")
 
 	os.Remove(filepath.Join(util.WorkspaceChartDirectory(tmpHome, chartName), "README.md"))
 
 	output := test.CaptureOutput(func() {
-		Lint(util.WorkspaceChartDirectory(tmpHome, chartName))
+		Lint(util.WorkspaceChartDirectory(tmpHome, chartName), tmpHome, LintOptions{})
 	})
 
 	test.ExpectContains(t, output, "README.md is present and not empty : false")
 }
 
+func TestLintSchema(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	test.FakeUpdate(tmpHome)
+
+	chartName := "goodChart"
+	Create(chartName, tmpHome, "")
+	chartDir := util.WorkspaceChartDirectory(tmpHome, chartName)
+	ioutil.WriteFile(filepath.Join(chartDir, "values.schema.yaml"), []byte("properties:\n  replicas: {type: integer}\n"), 0644)
+	ioutil.WriteFile(filepath.Join(chartDir, "values.yaml"), []byte("replicas: many\n"), 0644)
+
+	output := test.CaptureOutput(func() {
+		Lint(chartDir, tmpHome, LintOptions{})
+	})
+	test.ExpectContains(t, output, "values.schema.yaml is a valid schema : true")
+	test.ExpectContains(t, output, "replicas: expected integer, got string")
+	test.ExpectContains(t, output, "values.yaml, if present, conforms to values.schema.yaml : false")
+
+	ioutil.WriteFile(filepath.Join(chartDir, "values.schema.yaml"), []byte("properties: {replicas: {type: int}}\n"), 0644)
+	output = test.CaptureOutput(func() {
+		Lint(chartDir, tmpHome, LintOptions{})
+	})
+	test.ExpectContains(t, output, "replicas: type must be one of")
+	test.ExpectContains(t, output, "values.schema.yaml is a valid schema : false")
+}
+
 func TestLintMissingChartYaml(t *testing.T) {
 	tmpHome := test.CreateTmpHome()
 	test.FakeUpdate(tmpHome)
 
 	chartName := "badChart"
 
-	Create(chartName, tmpHome)
+	Create(chartName, tmpHome, "")
 
 	os.Remove(filepath.Join(util.WorkspaceChartDirectory(tmpHome, chartName), Chartfile))
 
+	var err error
 	output := test.CaptureOutput(func() {
-		Lint(util.WorkspaceChartDirectory(tmpHome, chartName))
+		err = Lint(util.WorkspaceChartDirectory(tmpHome, chartName), tmpHome, LintOptions{})
 	})
 
 	test.ExpectContains(t, output, "Chart.yaml is present : false")
-	test.ExpectContains(t, output, "Chart [badChart] has failed some necessary checks.")
+	expectError(t, err, helmerrors.ErrLintFailed, "Chart [badChart] has failed some necessary checks.")
 }
 
 func TestLintMismatchedChartNameAndDir(t *testing.T) {
@@ -73,7 +104,7 @@ func TestLintMismatchedChartNameAndDir(t *testing.T) {
 	createWithChart(chart, chartDir, tmpHome)
 
 	output := test.CaptureOutput(func() {
-		Lint(util.WorkspaceChartDirectory(tmpHome, chartDir))
+		Lint(util.WorkspaceChartDirectory(tmpHome, chartDir), tmpHome, LintOptions{})
 	})
 
 	test.ExpectContains(t, output, "Name declared in Chart.yaml is the same as directory name. : false")
@@ -85,16 +116,17 @@ func TestLintMissingManifestDirectory(t *testing.T) {
 
 	chartName := "brokeChart"
 
-	Create(chartName, tmpHome)
+	Create(chartName, tmpHome, "")
 
 	os.RemoveAll(filepath.Join(util.WorkspaceChartDirectory(tmpHome, chartName), "manifests"))
 
+	var err error
 	output := test.CaptureOutput(func() {
-		Lint(util.WorkspaceChartDirectory(tmpHome, chartName))
+		err = Lint(util.WorkspaceChartDirectory(tmpHome, chartName), tmpHome, LintOptions{})
 	})
 
 	test.ExpectMatches(t, output, "Manifests directory is present : false")
-	test.ExpectContains(t, output, "Chart ["+chartName+"] has failed some necessary checks")
+	expectError(t, err, helmerrors.ErrLintFailed, "Chart ["+chartName+"] has failed some necessary checks")
 }
 
 func TestLintEmptyChartYaml(t *testing.T) {
@@ -103,7 +135,7 @@ func TestLintEmptyChartYaml(t *testing.T) {
 
 	chartName := "badChart"
 
-	Create(chartName, tmpHome)
+	Create(chartName, tmpHome, "")
 
 	badChartYaml, _ := yaml.Marshal(make(map[string]string))
 
@@ -112,15 +144,16 @@ func TestLintEmptyChartYaml(t *testing.T) {
 	os.Remove(chartYaml)
 	ioutil.WriteFile(chartYaml, badChartYaml, 0644)
 
+	var err error
 	output := test.CaptureOutput(func() {
-		Lint(util.WorkspaceChartDirectory(tmpHome, chartName))
+		err = Lint(util.WorkspaceChartDirectory(tmpHome, chartName), tmpHome, LintOptions{})
 	})
 
 	test.ExpectContains(t, output, "Chart.yaml has a name field : false")
 	test.ExpectContains(t, output, "Chart.yaml has a version field : false")
 	test.ExpectContains(t, output, "Chart.yaml has a description field : false")
 	test.ExpectContains(t, output, "Chart.yaml has a maintainers field : false")
-	test.ExpectContains(t, output, fmt.Sprintf("Chart [%s] has failed some necessary checks", chartName))
+	expectError(t, err, helmerrors.ErrLintFailed, fmt.Sprintf("Chart [%s] has failed some necessary checks", chartName))
 }
 
 func TestLintBadPath(t *testing.T) {
@@ -128,9 +161,365 @@ func TestLintBadPath(t *testing.T) {
 	chartName := "badChart"
 
 	output := test.CaptureOutput(func() {
-		Lint(util.WorkspaceChartDirectory(tmpHome, chartName))
+		Lint(util.WorkspaceChartDirectory(tmpHome, chartName), tmpHome, LintOptions{})
 	})
 
 	msg := "Chart found at " + tmpHome + "/workspace/charts/" + chartName + " : false"
 	test.ExpectContains(t, output, msg)
 }
+
+func TestLintLimits(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+	test.FakeUpdate(tmpHome)
+
+	chartName := "bigChart"
+	Create(chartName, tmpHome, "")
+	manifests := filepath.Join(util.WorkspaceChartDirectory(tmpHome, chartName), "manifests")
+	files, _ := filepath.Glob(filepath.Join(manifests, "*.yaml"))
+	if len(files) == 0 {
+		t.Fatal("Expected the new chart to have manifests")
+	}
+	b, _ := ioutil.ReadFile(files[0])
+	ioutil.WriteFile(filepath.Join(manifests, "copy.yaml"), b, 0644)
+
+	f, _ := os.OpenFile(filepath.Join(tmpHome, util.Configfile), os.O_APPEND|os.O_WRONLY, 0644)
+	fmt.Fprintf(f, "install:\n  maxDocuments: %d\n", len(files))
+	f.Close()
+
+	var err error
+	output := test.CaptureOutput(func() {
+		err = Lint(util.WorkspaceChartDirectory(tmpHome, chartName), tmpHome, LintOptions{})
+	})
+	test.ExpectContains(t, output, "Manifests are within the limits of install : false")
+	test.ExpectContains(t, output, fmt.Sprintf("over the limit of %d. helmc install refuses it unless --max-documents raises the limit.", len(files)))
+	expectError(t, err, helmerrors.ErrLintFailed, fmt.Sprintf("Chart [%s] has failed some necessary checks", chartName))
+}
+
+func TestLintUnknownHook(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+	test.FakeUpdate(tmpHome)
+
+	chartName := "hookChart"
+	Create(chartName, tmpHome, "")
+	job := "apiVersion: extensions/v1beta1\nkind: Job\nmetadata:\n  name: migrate\n  annotations:\n    helm.sh/hook: pre-upgrade\n"
+	ioutil.WriteFile(filepath.Join(util.WorkspaceChartDirectory(tmpHome, chartName), "manifests", "job.yaml"), []byte(job), 0644)
+
+	var err error
+	output := test.CaptureOutput(func() {
+		err = Lint(util.WorkspaceChartDirectory(tmpHome, chartName), tmpHome, LintOptions{})
+	})
+	test.ExpectContains(t, output, "Manifests have known hooks : false")
+	test.ExpectContains(t, output, `unknown helm.sh/hook "pre-upgrade". Use pre-install, post-install, or pre-delete`)
+	expectError(t, err, helmerrors.ErrLintFailed, fmt.Sprintf("Chart [%s] has failed some necessary checks", chartName))
+}
+
+func TestLintPolicy(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	test.FakeUpdate(tmpHome)
+
+	chartName := "policyChart"
+	Create(chartName, tmpHome, "")
+
+	policy := `fields:
+  - name: description
+    required: true
+    maxLength: 20
+  - name: home
+    pattern: ^https://
+maintainers:
+  emailDomains:
+    - example.com
+`
+	ioutil.WriteFile(filepath.Join(tmpHome, validation.PolicyFile), []byte(policy), 0644)
+
+	var err error
+	output := test.CaptureOutput(func() {
+		err = Lint(util.WorkspaceChartDirectory(tmpHome, chartName), tmpHome, LintOptions{})
+	})
+
+	test.ExpectContains(t, output, "Chart.yaml has a description field : true")
+	test.ExpectContains(t, output, "Chart.yaml description is at most 20 characters (rule: maxLength) : false")
+	test.ExpectContains(t, output, "Chart.yaml home matches \"^https://\" (rule: pattern) : false")
+	test.ExpectContains(t, output, "Chart.yaml has a maintainer with an email in example.com (rule: maintainers.emailDomains) : false")
+	expectError(t, err, helmerrors.ErrLintFailed, fmt.Sprintf("Chart [%s] has failed some necessary checks", chartName))
+}
+
+func TestLintChartPolicyOverridesHome(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	test.FakeUpdate(tmpHome)
+
+	chartName := "policyChart"
+	Create(chartName, tmpHome, "")
+
+	ioutil.WriteFile(filepath.Join(tmpHome, validation.PolicyFile), []byte("fields:\n  - name: details\n    maxLength: 1\n"), 0644)
+	chartPolicy := "maintainers:\n  emailDomains: [address]\n"
+	ioutil.WriteFile(util.WorkspaceChartDirectory(tmpHome, chartName, validation.PolicyFile), []byte(chartPolicy), 0644)
+
+	output := test.CaptureOutput(func() {
+		Lint(util.WorkspaceChartDirectory(tmpHome, chartName), tmpHome, LintOptions{})
+	})
+
+	test.ExpectContains(t, output, "(rule: maintainers.emailDomains) : true")
+	if strings.Contains(output, "Chart.yaml details") {
+		t.Errorf("Expected home policy to be ignored, got %s", output)
+	}
+	test.ExpectContains(t, output, fmt.Sprintf("Chart [%s] has passed all necessary checks", chartName))
+}
+
+func TestLintMalformedPolicy(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	test.FakeUpdate(tmpHome)
+
+	chartName := "policyChart"
+	Create(chartName, tmpHome, "")
+
+	policyFile := filepath.Join(tmpHome, validation.PolicyFile)
+	ioutil.WriteFile(policyFile, []byte("fields:\n  - name: description\n   required: true\n"), 0644)
+
+	var err error
+	output := test.CaptureOutput(func() {
+		err = Lint(util.WorkspaceChartDirectory(tmpHome, chartName), tmpHome, LintOptions{})
+	})
+
+	test.ExpectContains(t, output, "Could not load lint policy "+policyFile+": ")
+	test.ExpectContains(t, output, "Lint policy is valid : false")
+	expectError(t, err, helmerrors.ErrLintFailed, fmt.Sprintf("Chart [%s] has failed some necessary checks", chartName))
+}
+
+func TestLintUndefinedGeneratorVariable(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	test.FakeUpdate(tmpHome)
+
+	chartName := "typoChart"
+
+	Create(chartName, tmpHome, "")
+	tpl := filepath.Join(util.WorkspaceChartDirectory(tmpHome, chartName), "manifests", "pod.yaml")
+	ioutil.WriteFile(tpl, []byte("#helm:generate helmc tpl -o $HELM_GENERATE_FIL.out $HELM_GENERATE_FILE\n"), 0644)
+
+	var err error
+	output := test.CaptureOutput(func() {
+		err = Lint(util.WorkspaceChartDirectory(tmpHome, chartName), tmpHome, LintOptions{})
+	})
+
+	test.ExpectContains(t, output, "undefined variable $HELM_GENERATE_FIL in generator")
+	test.ExpectContains(t, output, "Generators use only defined variables : false")
+	expectError(t, err, helmerrors.ErrLintFailed, "Chart [typoChart] has failed some necessary checks.")
+	if _, err := os.Stat(tpl + ".out"); err == nil {
+		t.Error("Expected lint not to run the generator")
+	}
+}
+
+func TestLintGeneratorCycle(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	test.FakeUpdate(tmpHome)
+
+	chartName := "cycleChart"
+
+	Create(chartName, tmpHome, "")
+	dir := util.WorkspaceChartDirectory(tmpHome, chartName)
+	ioutil.WriteFile(filepath.Join(dir, "manifests", "a.yaml"), []byte("#helm:generate echo a\n#helm:after manifests/b.yaml\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "manifests", "b.yaml"), []byte("#helm:generate echo b\n#helm:after manifests/a.yaml\n"), 0644)
+
+	var err error
+	output := test.CaptureOutput(func() {
+		err = Lint(dir, tmpHome, LintOptions{})
+	})
+
+	test.ExpectContains(t, output, "form a cycle: manifests/a.yaml -> manifests/b.yaml -> manifests/a.yaml")
+	test.ExpectContains(t, output, "Generators run after generators that exist, without a cycle : false")
+	expectError(t, err, helmerrors.ErrLintFailed, "Chart [cycleChart] has failed some necessary checks.")
+}
+
+func TestLintKubeSchemas(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	swagger, err := ioutil.ReadFile(filepath.Join(test.HelmRoot, "testdata", "schemas", "swagger.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var requested string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = r.URL.Path
+		w.Write(swagger)
+	}))
+	defer srv.Close()
+	defer func(u string) { KubeSchemaURL = u }(KubeSchemaURL)
+	KubeSchemaURL = srv.URL + "/%s/swagger.json"
+
+	chartName := "schemaChart"
+	Create(chartName, tmpHome, "")
+	chartDir := util.WorkspaceChartDirectory(tmpHome, chartName)
+	manifests := filepath.Join(chartDir, "manifests")
+	os.RemoveAll(manifests)
+	os.MkdirAll(manifests, 0755)
+	pod := `apiVersion: v1
+kind: Pod
+metadata:
+  name: schemachart
+  labels:
+    heritage: helm
+spec:
+  containers:
+  - name: web
+    image: nginx
+    port: 80
+`
+	ioutil.WriteFile(filepath.Join(manifests, "pod.yaml"), []byte(pod), 0644)
+	backup := "apiVersion: example.com/v1\nkind: Backup\nmetadata:\n  name: nightly\nspec:\n  keep: 7\n"
+	ioutil.WriteFile(filepath.Join(manifests, "backup.yaml"), []byte(backup), 0644)
+	ioutil.WriteFile(filepath.Join(manifests, "old.yaml"), []byte("apiVersion: extensions/v1beta1\nkind: Pod\nmetadata:\n  name: old\n"), 0644)
+	ioutil.WriteFile(filepath.Join(manifests, "typo.yaml"), []byte("apiVersion: v1\nkind: Servce\nmetadata:\n  name: typo\n"), 0644)
+
+	output := test.CaptureOutput(func() {
+		err = Lint(chartDir, tmpHome, LintOptions{KubeVersion: "1.21"})
+	})
+	test.ExpectEquals(t, requested, "/v1.21.0/swagger.json")
+	test.ExpectContains(t, output, "manifests/pod.yaml: Pod schemachart: spec.containers[0].port: is not a known field")
+	test.ExpectContains(t, output, "there is no schema for example.com/v1 Backup, so nightly is not checked")
+	test.ExpectContains(t, output, "manifests/old.yaml: Pod old: Kubernetes does not serve extensions/v1beta1 Pod; it serves Pod as v1")
+	test.ExpectContains(t, output, "manifests/typo.yaml: Servce typo: Kubernetes does not serve v1 Servce")
+	test.ExpectContains(t, output, "Manifests conform to the schemas of their kinds : false")
+	expectError(t, err, helmerrors.ErrLintFailed, chartName)
+
+	// The schemas are cached, and the custom kind has a schema of its own.
+	srv.Close()
+	defer func(o bool) { Defaults.Offline = o }(Defaults.Offline)
+	Defaults.Offline = true
+	ioutil.WriteFile(filepath.Join(manifests, "pod.yaml"), []byte(strings.Replace(pod, "    port: 80\n", "", 1)), 0644)
+	os.Remove(filepath.Join(manifests, "old.yaml"))
+	os.Remove(filepath.Join(manifests, "typo.yaml"))
+	output = test.CaptureOutput(func() {
+		err = Lint(chartDir, tmpHome, LintOptions{KubeVersion: "v1.21.0", SchemaDir: filepath.Join(test.HelmRoot, "testdata", "schemas")})
+	})
+	test.ExpectContains(t, output, "manifests/backup.yaml: Backup nightly: spec.schedule: is required, but not set")
+	if strings.Contains(output, "Pod schemachart:") {
+		t.Errorf("Expected the pod to conform, got %s", output)
+	}
+
+	// Without schemas, manifests are not checked.
+	os.RemoveAll(filepath.Join(tmpHome, "schemas"))
+	output = test.CaptureOutput(func() {
+		err = Lint(chartDir, tmpHome, LintOptions{KubeVersion: "1.21"})
+	})
+	test.ExpectContains(t, output, "No Kubernetes schemas are available")
+	if err != nil {
+		t.Errorf("Expected lint to pass without schemas, got %s", err)
+	}
+}
+
+func TestLintKubectlArgs(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	test.FakeUpdate(tmpHome)
+
+	Create("crdChart", tmpHome, "")
+	cf := util.WorkspaceChartDirectory(tmpHome, "crdChart", Chartfile)
+	data, _ := ioutil.ReadFile(cf)
+	data = append(data, "kubectl:\n  apply: [--validate=false]\n  delete: [--force]\n"...)
+	ioutil.WriteFile(cf, data, 0644)
+
+	var err error
+	output := test.CaptureOutput(func() {
+		err = Lint(util.WorkspaceChartDirectory(tmpHome, "crdChart"), tmpHome, LintOptions{})
+	})
+
+	test.ExpectContains(t, output, "kubectl.delete: --force is not allowed in a chart")
+	test.ExpectContains(t, output, "Chart.yaml gives kubectl only allowed flags : false")
+	expectError(t, err, helmerrors.ErrLintFailed, "Chart [crdChart] has failed some necessary checks.")
+}
+
+func TestLintResources(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	test.FakeUpdate(tmpHome)
+
+	chartName := "resourceChart"
+	Create(chartName, tmpHome, "")
+	dir := util.WorkspaceChartDirectory(tmpHome, chartName)
+	web := `apiVersion: extensions/v1beta1
+kind: Deployment
+metadata:
+  name: web
+  labels:
+    heritage: helm
+spec:
+  template:
+    spec:
+      initContainers:
+      - name: setup
+        image: alpine
+      containers:
+      - name: web
+        image: nginx
+        resources:
+          requests:
+            cpu: 200m
+            memory: 64Gi
+          limits:
+            cpu: 0.1
+            memory: lots
+`
+	ioutil.WriteFile(filepath.Join(dir, "manifests", "web.yaml"), []byte(web), 0644)
+	ioutil.WriteFile(filepath.Join(dir, validation.PolicyFile), []byte("resources:\n  max:\n    memory: 8Gi\n"), 0644)
+
+	var err error
+	output := test.CaptureOutput(func() {
+		err = Lint(dir, tmpHome, LintOptions{})
+	})
+	if err != nil {
+		t.Errorf("Expected resource findings to be warnings, got %s", err)
+	}
+	for _, expect := range []string{
+		"manifests/web.yaml: Deployment web, container setup: resources.requests is not set",
+		"manifests/web.yaml: Deployment web, container web: resources.limits.cpu (0.1) is lower than resources.requests.cpu (200m)",
+		"manifests/web.yaml: Deployment web, container web: resources.limits.memory (lots) is not a quantity",
+		"manifests/web.yaml: Deployment web, container web: resources.requests.memory (64Gi) is more than the maximum of the lint policy, 8Gi",
+		"Containers have resources.requests : false",
+		"Container limits are not lower than their requests : false",
+		"Container resources are quantities within the lint policy's maximums : false",
+	} {
+		test.ExpectContains(t, output, expect)
+	}
+	if strings.Contains(output, "Pod example-pod") {
+		t.Errorf("Expected the Pod of the starter to pass, got %s", output)
+	}
+
+	ioutil.WriteFile(filepath.Join(dir, validation.PolicyFile), []byte("resources:\n  level: error\n"), 0644)
+	test.CaptureOutput(func() {
+		err = Lint(dir, tmpHome, LintOptions{})
+	})
+	expectError(t, err, helmerrors.ErrLintFailed, "Chart [resourceChart] has failed some necessary checks.")
+}
+
+func TestLintResourcesGenerated(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	test.FakeUpdate(tmpHome)
+
+	chartName := "templatedChart"
+	Create(chartName, tmpHome, "")
+	dir := util.WorkspaceChartDirectory(tmpHome, chartName)
+	os.MkdirAll(filepath.Join(dir, "tpl"), 0755)
+	job := `#helm:generate cp tpl/job.yaml manifests/job.yaml
+apiVersion: extensions/v1beta1
+kind: Job
+metadata:
+  name: migrate
+spec:
+  template:
+    spec:
+      containers:
+      - name: migrate
+        image: alpine
+`
+	ioutil.WriteFile(filepath.Join(dir, "tpl", "job.yaml"), []byte(job), 0644)
+	defer func(a []string) { Defaults.AllowGenerators = a }(Defaults.AllowGenerators)
+	Defaults.AllowGenerators = []string{"cp"}
+
+	output := test.CaptureOutput(func() {
+		Lint(dir, tmpHome, LintOptions{})
+	})
+	test.ExpectContains(t, output, "Ran 1 generators in a copy of the chart")
+	test.ExpectContains(t, output, "manifests/job.yaml: Job migrate, container migrate: resources.requests is not set")
+	if _, err := os.Stat(filepath.Join(dir, "manifests", "job.yaml")); err == nil {
+		t.Error("Expected lint not to generate the chart itself")
+	}
+}
@@ -1,26 +1,195 @@
 package action
 
 import (
+	"encoding/json"
+	"fmt"
 	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/helm/helm-classic/chart"
+	"github.com/helm/helm-classic/kubectl"
 	"github.com/helm/helm-classic/log"
+	"github.com/helm/helm-classic/manifest"
+	"github.com/helm/helm-classic/output"
 	helm "github.com/helm/helm-classic/util"
 )
 
+// listedChart is the machine-readable description of a chart in the
+// workspace.
+type listedChart struct {
+	// Name is empty for a chart that is only in Kubernetes.
+	Name        string `json:"name,omitempty" yaml:"name,omitempty"`
+	Chart       string `json:"chart,omitempty" yaml:"chart,omitempty"`
+	Version     string `json:"version,omitempty" yaml:"version,omitempty"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	// Installed is set when the installed versions are looked up.
+	Installed *listedInstall `json:"installed,omitempty" yaml:"installed,omitempty"`
+	// Error says why the Chart.yaml could not be read.
+	Error string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// listedInstall describes the version of a chart that is installed in
+// Kubernetes. State is one of those of Status, and Error says why it could
+// not be found out.
+type listedInstall struct {
+	Version string `json:"version,omitempty" yaml:"version,omitempty"`
+	State   string `json:"state,omitempty" yaml:"state,omitempty"`
+	Error   string `json:"error,omitempty" yaml:"error,omitempty"`
+	// Resources are how many resources of a chart that is only in
+	// Kubernetes are labeled for it.
+	Resources int `json:"resources,omitempty" yaml:"resources,omitempty"`
+}
+
 // List lists all of the local charts.
-func List(homedir string) {
+//
+// If client is not nil, the version of each chart that is installed in the
+// namespace is shown too, along with whether it is the local chart. So are
+// the charts whose resources in the namespace are labeled as helmc's (see
+// ownershipLabels) with a chart and version that the workspace does not have,
+// such as those of other workspaces, or earlier versions.
+//
+// If format is "json" or "yaml", the charts are printed to stdout in that
+// format. Otherwise, on a terminal, descriptions are cut to fit its width.
+func List(homedir, namespace, format string, client kubectl.Runner) {
+	if client != nil {
+		CheckClientPrereqs(client)
+	}
 	md := helm.WorkspaceChartDirectory(homedir, "*")
 	charts, err := filepath.Glob(md)
 	if err != nil {
 		log.Warn("Could not find any charts in %q: %s", md, err)
 	}
+	managed := managedCharts(charts, namespace, client)
+
+	if format != "" {
+		list := []*listedChart{}
+		for _, c := range charts {
+			lc := &listedChart{Name: filepath.Base(c)}
+			if ch, err := chart.LoadChartfile(filepath.Join(c, Chartfile)); err == nil {
+				lc.Chart, lc.Version, lc.Description = ch.Name, ch.Version, ch.Description
+				lc.Installed = installedChart(c, namespace, client)
+			} else {
+				lc.Error = err.Error()
+			}
+			list = append(list, lc)
+		}
+		for _, m := range managed {
+			list = append(list, &listedChart{Chart: m.chart, Installed: &listedInstall{Resources: m.resources}})
+		}
+		if err := printFormatted(list, format); err != nil {
+			log.Die("%s", err)
+		}
+		return
+	}
+
+	p := output.New(log.Stderr)
 	for _, c := range charts {
 		cname := filepath.Base(c)
 		if ch, err := chart.LoadChartfile(filepath.Join(c, Chartfile)); err == nil {
-			log.Info("\t%s (%s %s) - %s", cname, ch.Name, ch.Version, ch.Description)
+			head := fmt.Sprintf("%s (%s %s) - ", cname, ch.Name, ch.Version)
+			summary := installedSummary(c, namespace, client)
+			log.Info("\t%s%s%s", head, p.Fit(ch.Description, infoIndent+output.Width(head+summary)), summary)
 			continue
 		}
 		log.Info("\t%s (unknown)", cname)
 	}
+	for _, m := range managed {
+		log.Info("\t(not in the workspace) %s [installed, %d resources]", m.chart, m.resources)
+	}
+}
+
+// managedChart is the chart, as NAME-VERSION, of resources that helmc
+// labeled, and how many there are.
+type managedChart struct {
+	chart     string
+	resources int
+}
+
+// managedCharts lists the resources of namespace that are labeled with
+// chart.LabelHeritage as helmc's, and returns their charts that none of the
+// workspace charts in dirs is, sorted. It is nil if client is nil. Resources
+// that cannot be listed are only warned about.
+func managedCharts(dirs []string, namespace string, client kubectl.Runner) []*managedChart {
+	if client == nil {
+		return nil
+	}
+	selector := chart.LabelHeritage + "=" + chart.Heritage
+	out, err := client.List(strings.Join(InstallOrder, ","), selector, namespace)
+	if err != nil {
+		log.Warn("Could not list the resources labeled %s: %s", selector, failure(out, err))
+		return nil
+	}
+	var list struct {
+		Items []map[string]interface{} `json:"items"`
+	}
+	if err := json.Unmarshal(out, &list); err != nil {
+		log.Warn("Could not read the resources labeled %s: %s", selector, err)
+		return nil
+	}
+
+	local := map[string]bool{}
+	for _, dir := range dirs {
+		if cf, err := chart.LoadChartfile(filepath.Join(dir, Chartfile)); err == nil {
+			local[chart.LabelValue(cf.Name+"-"+cf.Version)] = true
+		}
+	}
+	counts := map[string]int{}
+	for _, obj := range list.Items {
+		if c := str(field(obj, "metadata", "labels", chart.LabelChart)); c != "" && !local[c] {
+			counts[c]++
+		}
+	}
+	managed := []*managedChart{}
+	for c, n := range counts {
+		managed = append(managed, &managedChart{chart: c, resources: n})
+	}
+	sort.Slice(managed, func(i, j int) bool { return managed[i].chart < managed[j].chart })
+	return managed
+}
+
+// infoIndent is the number of columns that the prefix of log.Info and a tab
+// take.
+const infoIndent = 8
+
+// installedSummary describes the installed version of the chart in dir, as
+// installedChart finds it. It is empty if client is nil.
+func installedSummary(dir, namespace string, client kubectl.Runner) string {
+	in := installedChart(dir, namespace, client)
+	switch {
+	case in == nil:
+		return ""
+	case in.Error != "":
+		return " [" + in.Error + "]"
+	case in.State == StateMissing:
+		return " [not installed]"
+	case in.State == StateUnknown:
+		return " [installed, version unknown]"
+	}
+	return " [installed " + in.Version + ", " + in.State + "]"
+}
+
+// installedChart finds the installed version of the chart in dir, using the
+// first of its resources that has a name. It is nil if client is nil, or if
+// the chart has no such resource.
+func installedChart(dir, namespace string, client kubectl.Runner) *listedInstall {
+	if client == nil {
+		return nil
+	}
+	c, err := chart.Load(dir)
+	if err != nil {
+		return &listedInstall{Error: "could not load chart"}
+	}
+	digest, err := chart.Digest(dir)
+	if err != nil {
+		return &listedInstall{Error: "could not compute digest"}
+	}
+	for _, m := range installManifests(c, nil) {
+		if m.Name == "" || hookOf(m) == manifest.HookPreDelete {
+			continue
+		}
+		st := installedResource(m.Name, m.Kind, namespace, digest, client)
+		return &listedInstall{Version: st.Version, State: st.State}
+	}
+	return nil
 }
@@ -0,0 +1,81 @@
+package action
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/helm/helm-classic/chart"
+	"github.com/helm/helm-classic/kubectl"
+	"github.com/helm/helm-classic/test"
+	"github.com/helm/helm-classic/util"
+)
+
+// managedList is what kubectl returns for the resources labeled as helmc's.
+// redis-0.0.1 is the chart of the workspace.
+const managedList = `{"apiVersion": "v1", "kind": "List", "items": [
+{"apiVersion": "v1", "kind": "Pod", "metadata": {"name": "redis", "labels": {"heritage": "helm-classic", "chart": "redis-0.0.1"}}},
+{"apiVersion": "v1", "kind": "Service", "metadata": {"name": "web", "labels": {"heritage": "helm-classic", "chart": "web-1.2.0"}}},
+{"apiVersion": "v1", "kind": "Pod", "metadata": {"name": "web", "labels": {"heritage": "helm-classic", "chart": "web-1.2.0"}}},
+{"apiVersion": "v1", "kind": "Pod", "metadata": {"name": "old", "labels": {"heritage": "helm-classic"}}}
+]}`
+
+type managedRunner struct {
+	kubectl.FakeRunner
+}
+
+func (r *managedRunner) List(kinds, selector, ns string) ([]byte, error) {
+	r.FakeRunner.List(kinds, selector, ns)
+	return []byte(managedList), nil
+}
+
+func TestManagedCharts(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+	test.FakeUpdate(tmpHome)
+	test.CaptureOutput(func() {
+		Fetch("redis", "", tmpHome, FetchOptions{})
+	})
+
+	r := &managedRunner{}
+	dirs, _ := filepath.Glob(util.WorkspaceChartDirectory(tmpHome, "*"))
+	managed := managedCharts(dirs, "cache", r)
+	test.ExpectContains(t, r.Calls[0], chart.LabelHeritage+"="+chart.Heritage+" cache")
+	if len(managed) != 1 || managed[0].chart != "web-1.2.0" || managed[0].resources != 2 {
+		t.Errorf("Expected only web-1.2.0, with 2 resources, got %v", managed)
+	}
+	if managedCharts(dirs, "cache", nil) != nil {
+		t.Errorf("Expected nothing to be listed without a client")
+	}
+}
+
+func TestOwnershipLabels(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+	test.FakeUpdate(tmpHome)
+	test.CaptureOutput(func() {
+		Fetch("redis", "", tmpHome, FetchOptions{})
+	})
+	ch, err := chart.Load(util.WorkspaceChartDirectory(tmpHome, "redis"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch.Chartfile.Version = "1.0.0+build.5"
+	ms := installManifests(ch, nil)
+	if err := labelManifests(ms, ownershipLabels(ch.Chartfile)); err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range ms {
+		meta, err := m.VersionedObject.Meta()
+		if err != nil {
+			t.Fatal(err)
+		}
+		test.ExpectEquals(t, meta.Labels[chart.LabelHeritage], "helm-classic")
+		test.ExpectEquals(t, meta.Labels[chart.LabelChart], "redis-1.0.0_build.5")
+	}
+
+	if v := chart.LabelValue("-" + strings.Repeat("x", 70)); len(v) != 62 || v[0] != 'x' {
+		t.Errorf("Expected a label value of 62 characters, got %q", v)
+	}
+}
@@ -0,0 +1,117 @@
+package action
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/helm/helm-classic/chart"
+	"github.com/helm/helm-classic/helmpath"
+	"github.com/helm/helm-classic/log"
+	"github.com/helm/helm-classic/repo"
+	helm "github.com/helm/helm-classic/util"
+)
+
+// Package writes an archive of a chart, named after its name and version,
+// as repo.Package does.
+//
+// - chartDir is the directory of the chart
+// - dest is the directory to write the archive into; if it is empty, it is
+// the current directory
+//
+// The digest of the archive is printed last, as the index of a repository
+// records it.
+func Package(chartDir, dest string) error {
+	if dest == "" {
+		dest = "."
+	}
+	filename, cv, err := repo.Package(chartDir, dest)
+	if err != nil {
+		return fmt.Errorf("Could not package %s: %s", chartDir, err)
+	}
+	if cv.Deprecated {
+		log.Warn("%s %s is marked as deprecated.", cv.Name, cv.Version)
+	}
+	log.Info("Packaged %s %s into %s", cv.Name, cv.Version, filename)
+	log.Msg("sha256:%s", cv.Digest)
+	return nil
+}
+
+// isChartArchive returns true if a chart argument is a chart archive: a
+// file whose name ends in .tgz.
+func isChartArchive(arg string) bool {
+	if !strings.HasSuffix(arg, ".tgz") {
+		return false
+	}
+	fi, err := os.Stat(arg)
+	return err == nil && fi.Mode().IsRegular()
+}
+
+// fetchArchive expands the chart archive filename into the workspace as
+// lname, or if lname is empty, as the name in the archive's Chart.yaml. It
+// returns the local name, and false if o.IfAbsent left an identical
+// workspace chart alone.
+//
+// The chart is staged and compared with the workspace as a chart of a
+// repository is, and its origin is recorded as the file:// URL of the
+// archive.
+func (c *Client) fetchArchive(filename, lname string, o FetchOptions) (string, bool, error) {
+	cfg, err := c.config()
+	if err != nil {
+		return "", false, err
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return "", false, fmt.Errorf("Could not read chart archive: %s", err)
+	}
+
+	ws := helmpath.Home(c.Home).Workspace()
+	if err := os.MkdirAll(ws, 0755); err != nil {
+		return "", false, fmt.Errorf("Could not create %q: %s", ws, err)
+	}
+	tmp, cleanup, err := helm.TempDir(ws, ".archive-")
+	if err != nil {
+		return "", false, fmt.Errorf("Could not create a staging directory: %s", err)
+	}
+	defer cleanup()
+
+	limits := cfg.Limits()
+	if o.AllowUnsafe {
+		limits = chart.Limits{}
+	}
+	if err := repo.Expand(data, tmp, limits.Files, limits.Bytes); err != nil {
+		return "", false, fmt.Errorf("Could not expand %s: %s", filename, err)
+	}
+	cf, err := chart.LoadChartfile(filepath.Join(tmp, Chartfile))
+	if err != nil {
+		return "", false, fmt.Errorf("%s is not a chart archive: %s", filename, err)
+	}
+	if o.VerifyKeyring != "" {
+		if err := c.verifyFetched(tmp, provenancePath(filename), filename, o.VerifyKeyring); err != nil {
+			return "", false, err
+		}
+	}
+	if err := c.checkDeprecated(cf, o.AcceptDeprecated); err != nil {
+		return "", false, err
+	}
+	if lname == "" {
+		lname = cf.Name
+	}
+
+	unlock, err := c.lockChart(lname)
+	if err != nil {
+		return "", false, err
+	}
+	defer unlock()
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return "", false, err
+	}
+	fetched, err := c.stage(tmp, lname, filename, "file://"+filepath.ToSlash(abs), o)
+	if fetched {
+		c.recordFetch(lname, &LockedChart{Archive: abs})
+	}
+	return lname, fetched, err
+}
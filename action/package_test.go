@@ -0,0 +1,67 @@
+package action
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/helm/helm-classic/chart"
+	"github.com/helm/helm-classic/log"
+	"github.com/helm/helm-classic/test"
+	"github.com/helm/helm-classic/util"
+)
+
+func TestFetchArchive(t *testing.T) {
+	home := test.CreateTmpHome()
+	defer os.RemoveAll(home)
+	test.FakeUpdate(home)
+
+	dist := filepath.Join(home, "dist")
+	out := test.CaptureOutput(func() {
+		if err := Package(util.CacheDirectory(home, "charts", "redis"), dist); err != nil {
+			t.Fatal(err)
+		}
+	})
+	archives, _ := filepath.Glob(filepath.Join(dist, "redis-standalone-*.tgz"))
+	if len(archives) != 1 {
+		t.Fatalf("Expected an archive of redis-standalone, got %v:\n%s", archives, out)
+	}
+	test.ExpectContains(t, out, "sha256:")
+	archive := archives[0]
+
+	var buf bytes.Buffer
+	c := &Client{Home: home, Log: &log.Logger{Stdout: &buf, Stderr: &buf}}
+	ref, err := c.ResolveChart(archive, ChartLookup{Repos: true, Archive: true})
+	if err != nil || ref.Source != ChartInArchive {
+		t.Errorf("Expected %s to be a chart archive, got %v, %v", archive, ref, err)
+	}
+	if _, err := c.ResolveChart(archive, ChartLookup{Repos: true}); err == nil || !strings.Contains(err.Error(), "is a chart archive") {
+		t.Errorf("Expected an archive to be refused, got %v", err)
+	}
+
+	dir, err := c.Fetch(archive, "myredis", FetchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cf, err := chart.LoadChartfile(filepath.Join(dir, Chartfile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cf.Name != "myredis" || cf.From == nil || cf.From.Name != "redis-standalone" || !strings.HasPrefix(cf.From.Repo, "file://") {
+		t.Errorf("Expected myredis to be from the archive, got %+v", cf.From)
+	}
+
+	// Install expands the archive under the chart's own name.
+	_, name, ms, err := c.installPlan(archive, &InstallOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "redis-standalone" || len(ms) == 0 {
+		t.Errorf("Expected the manifests of redis-standalone, got %s with %d", name, len(ms))
+	}
+	if _, err := os.Stat(util.WorkspaceChartDirectory(home, name, Chartfile)); err != nil {
+		t.Errorf("Expected redis-standalone in the workspace: %s", err)
+	}
+}
@@ -0,0 +1,426 @@
+package action
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/helm/helm-classic/audit"
+	"github.com/helm/helm-classic/chart"
+	"github.com/helm/helm-classic/kubectl"
+	"github.com/helm/helm-classic/log"
+	"github.com/helm/helm-classic/manifest"
+	helm "github.com/helm/helm-classic/util"
+)
+
+// PlanVersion is the version of the plans that PlanInstall and PlanUninstall
+// write. ApplyPlan runs no other.
+const PlanVersion = 1
+
+// OpDelete is the operation of a plan that deletes a resource. The other
+// operations are the install modes: ModeCreate, ModeApply, and ModeReplace.
+const OpDelete = "delete"
+
+// Plan is what an install or an uninstall will do, exactly, so that it can be
+// reviewed before ApplyPlan runs it.
+type Plan struct {
+	// Version is PlanVersion.
+	Version int `json:"version"`
+	// Operation is audit.OpInstall or audit.OpUninstall.
+	Operation string    `json:"operation"`
+	Chart     PlanChart `json:"chart"`
+	// Namespace is the namespace that kubectl is given.
+	Namespace string `json:"namespace,omitempty"`
+	// Cluster is the kubeconfig identity that the plan was made with. It may
+	// only be run with the same context and cluster.
+	Cluster  PlanCluster  `json:"cluster"`
+	Settings PlanSettings `json:"settings"`
+	// Operations are run in order.
+	Operations []*PlanOperation `json:"operations"`
+	// Kept are the resources that an uninstall does not delete, as
+	// kind/name, each with the reason it is kept.
+	Kept []string `json:"kept,omitempty"`
+}
+
+// PlanChart identifies the chart of a plan.
+type PlanChart struct {
+	// Name is the name of the chart in the workspace.
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+	// Digest is the chart.Digest of the chart in the workspace.
+	Digest string `json:"digest"`
+}
+
+// PlanCluster is the kubeconfig identity of a plan.
+type PlanCluster struct {
+	Context string `json:"context"`
+	Cluster string `json:"cluster,omitempty"`
+	User    string `json:"user,omitempty"`
+}
+
+// PlanSettings are the settings of the flags that a plan was made with.
+type PlanSettings struct {
+	Mode     string `json:"mode,omitempty"`
+	Atomic   bool   `json:"atomic,omitempty"`
+	Annotate bool   `json:"annotate,omitempty"`
+	Labels   bool   `json:"labels,omitempty"`
+	Force    bool   `json:"force,omitempty"`
+	Generate bool   `json:"generate,omitempty"`
+	// Values are the values files of the templates, as absolute paths, and
+	// Set their KEY=VALUE values.
+	Values []string `json:"values,omitempty"`
+	Set    []string `json:"set,omitempty"`
+	// SetFrom are the value sources of the templates, redacted.
+	SetFrom []string `json:"setFrom,omitempty"`
+	// Env is the environment of the values, such as "staging".
+	Env string `json:"env,omitempty"`
+	// Wait is how long an uninstall waits for deleted resources to
+	// disappear, such as "5m0s".
+	Wait string `json:"wait,omitempty"`
+	// Kubectl are the extra flags of kubectl, merged from the chart, the
+	// configuration, and the flags of helmc.
+	Kubectl kubectl.Args `json:"kubectl"`
+}
+
+// PlanOperation is a single change of a plan.
+type PlanOperation struct {
+	// Op is ModeCreate, ModeApply, ModeReplace, or OpDelete.
+	Op        string `json:"op"`
+	Kind      string `json:"kind"`
+	Name      string `json:"name,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	// Manifest is the manifest that is sent to Kubernetes, as it is sent,
+	// unless Op is OpDelete.
+	Manifest json.RawMessage `json:"manifest,omitempty"`
+	// Hook is the hook of the manifest (see manifest.Hook), if it is one.
+	// A hook Job or Pod is waited on until it completes.
+	Hook string `json:"hook,omitempty"`
+}
+
+// Save writes the plan to path, as JSON. Since its manifests may hold
+// secrets, only the owner may read it.
+func (p *Plan) Save(path string) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0600)
+}
+
+// LoadPlan reads a plan that Save wrote, and checks that ApplyPlan can run it.
+func LoadPlan(path string) (*Plan, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p := &Plan{}
+	if err := json.Unmarshal(data, p); err != nil {
+		return nil, fmt.Errorf("%s is not a plan: %s", path, err)
+	}
+	if p.Version != PlanVersion {
+		return nil, fmt.Errorf("%s is a plan of version %d. This helmc runs plans of version %d", path, p.Version, PlanVersion)
+	}
+	if p.Chart.Name == "" || p.Chart.Digest == "" {
+		return nil, fmt.Errorf("%s does not name the chart and its digest", path)
+	}
+	if p.Operation != audit.OpInstall && p.Operation != audit.OpUninstall {
+		return nil, fmt.Errorf("%s plans an unknown operation %q", path, p.Operation)
+	}
+	for i, op := range p.Operations {
+		switch {
+		case p.Operation == audit.OpInstall && checkMode(op.Op) == nil && op.Op != "" && len(op.Manifest) > 0:
+			// Save indented the manifest, which was planned compact.
+			var b bytes.Buffer
+			if err := json.Compact(&b, op.Manifest); err != nil {
+				return nil, err
+			}
+			op.Manifest = b.Bytes()
+		case p.Operation == audit.OpUninstall && op.Op == OpDelete && op.Name != "":
+		default:
+			return nil, fmt.Errorf("Operation %d of %s, %q of %s %s, is not one that an %s runs", i+1, path, op.Op, op.Kind, op.Name, p.Operation)
+		}
+	}
+	return p, nil
+}
+
+// newPlan starts the plan of an operation on the workspace chart name, which
+// was loaded as ch.
+func (c *Client) newPlan(op string, ch *chart.Chart, dir, name, namespace string) (*Plan, error) {
+	d, err := chart.Digest(dir)
+	if err != nil {
+		return nil, fmt.Errorf("Could not compute the digest of %s: %s", name, err)
+	}
+	id := c.kube().ActiveIdentity()
+	return &Plan{
+		Version:    PlanVersion,
+		Operation:  op,
+		Chart:      PlanChart{Name: name, Version: ch.Chartfile.Version, Digest: d},
+		Namespace:  namespace,
+		Cluster:    PlanCluster{Context: id.Context, Cluster: id.Cluster, User: id.User},
+		Operations: []*PlanOperation{},
+	}, nil
+}
+
+// PlanInstall writes what installing a chart would do to path, and changes
+// nothing in Kubernetes. The chart is fetched and generated as for Install.
+func PlanInstall(chartName, home, path string, opts InstallOptions) error {
+	c := newClient(home, nil)
+	c.Config = mustConfig(home)
+	p, err := c.PlanInstall(chartName, opts)
+	if err != nil {
+		return err
+	}
+	return c.savePlan(p, path)
+}
+
+// PlanInstall returns what Install would do with opts, without doing it.
+func (c *Client) PlanInstall(chartName string, opts InstallOptions) (*Plan, error) {
+	if opts.Mode == "" {
+		opts.Mode = ModeCreate
+	}
+	if err := checkMode(opts.Mode); err != nil {
+		return nil, err
+	}
+	ch, chartName, ms, err := c.installPlan(chartName, &opts)
+	if err != nil {
+		return nil, err
+	}
+	ops, err := installOperations(ms, opts.Namespace, opts.Mode)
+	if err != nil {
+		return nil, err
+	}
+	restore, err := c.useChartArgs(ch)
+	if err != nil {
+		return nil, err
+	}
+	defer restore()
+
+	p, err := c.newPlan(audit.OpInstall, ch, helm.WorkspaceChartDirectory(c.Home, chartName), chartName, opts.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	p.Settings = PlanSettings{
+		Mode:     opts.Mode,
+		Atomic:   opts.Atomic,
+		Annotate: opts.Annotate,
+		Labels:   opts.Labels,
+		Force:    opts.Force,
+		Generate: opts.Generate,
+		Values:   opts.Values.AbsFiles(),
+		Set:      opts.Values.Set,
+		SetFrom:  opts.Values.Redacted(),
+		Env:      opts.Values.Env,
+		Kubectl:  c.kube().EffectiveArgs(),
+	}
+	if len(opts.Values.SetFrom) > 0 {
+		c.Log.Warn("The manifests of the plan are rendered with the values of --set-from. Keep the plan as safe as those values.")
+	}
+	p.Operations = ops
+	return p, nil
+}
+
+// PlanUninstall returns what Uninstall would do with o, without doing it.
+func (c *Client) PlanUninstall(chartName, namespace string, o UninstallOptions) (*Plan, error) {
+	if !chartFetched(chartName, c.Home, c.Log) {
+		return nil, fmt.Errorf("No chart named %q in your workspace", chartName)
+	}
+	dir := helm.WorkspaceChartDirectory(c.Home, chartName)
+	ch, err := chart.Load(dir)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load chart: %s", err)
+	}
+	restore, err := c.useChartArgs(ch)
+	if err != nil {
+		return nil, err
+	}
+	defer restore()
+
+	p, err := c.newPlan(audit.OpUninstall, ch, dir, chartName, namespace)
+	if err != nil {
+		return nil, err
+	}
+	p.Settings = PlanSettings{Force: o.Force, Kubectl: c.kube().EffectiveArgs()}
+	if o.Wait > 0 {
+		p.Settings.Wait = o.Wait.String()
+	}
+	if o.PurgeOrphans {
+		orphans, err := c.Orphans(chartName, namespace)
+		if err != nil {
+			return nil, err
+		}
+		for _, orphan := range orphans {
+			if orphan.Kept != "" {
+				p.Kept = append(p.Kept, fmt.Sprintf("%s/%s (%s)", orphan.Kind, orphan.Name, orphan.Kept))
+				continue
+			}
+			ns := orphan.Namespace
+			if ns == "" {
+				ns = namespace
+			}
+			p.Operations = append(p.Operations, &PlanOperation{Op: OpDelete, Kind: orphan.Kind, Name: orphan.Name, Namespace: ns})
+		}
+	}
+	for _, kind := range append(ch.UnknownKinds(UninstallOrder), UninstallOrder...) {
+		for _, m := range ch.Kind[kind] {
+			if reason := o.keepReason(m, kind); reason != "" {
+				p.Kept = append(p.Kept, fmt.Sprintf("%s/%s (%s)", kind, m.Name, reason))
+				continue
+			}
+			if m.Name == "" {
+				c.Log.Warn("Not planning to uninstall %s with a generated name. Use kubectl to find and delete it.", kind)
+				continue
+			}
+			if hookOf(m) == manifest.HookPreDelete {
+				c.Log.Warn("Not planning to run the %s hook %s %s. A plan only deletes resources.", manifest.HookPreDelete, kind, m.Name)
+				continue
+			}
+			p.Operations = append(p.Operations, &PlanOperation{Op: OpDelete, Kind: kind, Name: m.Name, Namespace: namespace})
+		}
+	}
+	return p, nil
+}
+
+// savePlan writes a plan to path, and says how to run it.
+func (c *Client) savePlan(p *Plan, path string) error {
+	if err := p.Save(path); err != nil {
+		return fmt.Errorf("Could not write the plan: %s", err)
+	}
+	c.Log.Info("Wrote a plan of %d operations on %s to %s. Nothing was changed.", len(p.Operations), p.Cluster.Context, path)
+	c.Log.Info("Run it with 'helmc apply-plan %s'.", path)
+	return nil
+}
+
+// ApplyPlan runs the plan in path, as it was written, without asking for
+// confirmation.
+//
+// It refuses to if the chart in the workspace has changed since the plan was
+// made, or if the kubeconfig context or cluster is another than the plan's.
+func ApplyPlan(path, home string, client kubectl.Runner) error {
+	p, err := LoadPlan(path)
+	if err != nil {
+		return err
+	}
+	CheckClientPrereqs(client)
+	c := newClient(home, client)
+	c.Config = mustConfig(home)
+	return c.ApplyPlan(p)
+}
+
+// ApplyPlan is like the package-level ApplyPlan, for a loaded plan.
+func (c *Client) ApplyPlan(p *Plan) error {
+	dir := helm.WorkspaceChartDirectory(c.Home, p.Chart.Name)
+	ch, err := c.checkPlan(p, dir)
+	if err != nil {
+		return err
+	}
+
+	// Kubernetes is given exactly the flags of the plan.
+	defer c.useKube(func(s *kubectl.Settings) {
+		s.UserArgs, s.ChartArgs, s.GracePeriod = p.Settings.Kubectl, kubectl.Args{}, -1
+	})()
+
+	if p.Operation == audit.OpUninstall {
+		return c.applyUninstallPlan(p, ch, dir)
+	}
+
+	c.Log.Info("Running the %d operations of the plan ...", len(p.Operations))
+	res, err := c.uploadManifests(ch.Chartfile.Name, p.Operations, p.Namespace, p.Settings.Atomic)
+	opts := InstallOptions{
+		Namespace: p.Namespace,
+		Mode:      p.Settings.Mode,
+		Atomic:    p.Settings.Atomic,
+		Annotate:  p.Settings.Annotate,
+		Labels:    p.Settings.Labels,
+		Force:     p.Settings.Force,
+		Generate:  p.Settings.Generate,
+		Values:    ValueSources{Files: p.Settings.Values, Set: p.Settings.Set, SetFrom: p.Settings.SetFrom, Env: p.Settings.Env},
+	}
+	c.auditInstall(ch, p.Chart.Name, opts, res, err)
+	c.recordRelease(c.installRelease(ch, p.Chart.Name, opts), p.Operations, err)
+	if perr := res.print(c.Log, ""); perr != nil {
+		c.Log.Err("Could not print install summary: %s", perr)
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to upload manifests: %w", err)
+	}
+	c.Log.Info("Done")
+
+	printREADME(c.Log, p.Chart.Name, c.Home)
+	return nil
+}
+
+// checkPlan loads the chart of a plan from dir, and checks that the plan may
+// run against it, and against the active kubeconfig identity.
+func (c *Client) checkPlan(p *Plan, dir string) (*chart.Chart, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("The chart %s of the plan is not in your workspace", p.Chart.Name)
+	}
+	d, err := chart.Digest(dir)
+	if err != nil {
+		return nil, fmt.Errorf("Could not compute the digest of %s: %s", p.Chart.Name, err)
+	}
+	if d != p.Chart.Digest {
+		return nil, fmt.Errorf("The chart %s has changed since the plan was made: its digest is %s, not %s. Make a new plan", p.Chart.Name, d, p.Chart.Digest)
+	}
+	id := c.kube().ActiveIdentity()
+	if id.Context != p.Cluster.Context || id.Cluster != p.Cluster.Cluster {
+		return nil, fmt.Errorf("The plan is for the context %s (cluster %s), not %s (cluster %s). Select its context with --kube-context, or make a new plan", dash(p.Cluster.Context), dash(p.Cluster.Cluster), dash(id.Context), dash(id.Cluster))
+	}
+	ch, err := chart.Load(dir)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load chart: %s", err)
+	}
+	return ch, nil
+}
+
+// applyUninstallPlan deletes the resources of a plan, in order. With a wait
+// setting, the resources of each kind must be gone before the next kind is
+// deleted, as in Uninstall.
+func (c *Client) applyUninstallPlan(p *Plan, ch *chart.Chart, dir string) error {
+	var wait time.Duration
+	if p.Settings.Wait != "" {
+		var err error
+		if wait, err = time.ParseDuration(p.Settings.Wait); err != nil {
+			return fmt.Errorf("The wait of the plan is not a duration: %s", err)
+		}
+	}
+
+	log.Info("Running `kubectl delete` ...")
+	sum := &uninstallSummary{kept: p.Kept, resources: []*audit.Resource{}}
+	deadline := time.Now().Add(wait)
+	remaining := 0
+	for i := 0; i < len(p.Operations); {
+		kind := p.Operations[i].Kind
+		deleted := []string{}
+		for ; i < len(p.Operations) && p.Operations[i].Kind == kind; i++ {
+			op := p.Operations[i]
+			if deleteResource(op.Name, op.Kind, op.Namespace, c.Kube, sum) {
+				deleted = append(deleted, op.Name)
+			}
+		}
+		if wait > 0 && len(deleted) > 0 {
+			remaining += waitForDeletion(deleted, p.Namespace, kind, deadline, c.Kube)
+		}
+	}
+	sum.print()
+
+	var err error
+	if remaining > 0 {
+		err = fmt.Errorf("%d resources were not deleted within %s", remaining, wait)
+	}
+	e := c.newAuditEntry(audit.OpUninstall, ch, dir, p.Namespace)
+	e.Resources = sum.resources
+	auditErr := err
+	if auditErr == nil && sum.failed > 0 {
+		auditErr = fmt.Errorf("%d resources could not be deleted", sum.failed)
+	}
+	c.recordAudit(e, auditErr)
+	if err != nil {
+		return fmt.Errorf("Failed to completely delete chart: %s", err)
+	}
+	log.Info("Done")
+	return nil
+}
@@ -0,0 +1,159 @@
+package action
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/helm/helm-classic/audit"
+	"github.com/helm/helm-classic/chart"
+	"github.com/helm/helm-classic/kubectl"
+	"github.com/helm/helm-classic/test"
+	"github.com/helm/helm-classic/util"
+)
+
+func TestPlanInstall(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+	test.FakeUpdate(tmpHome)
+	defer func() { kubectl.Defaults.Context, kubectl.Defaults.UserArgs = "", kubectl.Args{} }()
+	kubectl.Defaults.Context = "staging"
+	kubectl.Defaults.UserArgs = kubectl.Args{Apply: []string{"--validate=false"}}
+
+	kube := &kubectl.FakeRunner{Out: []byte(`pod "redis" configured`)}
+	c := newClient(tmpHome, kube)
+	var p *Plan
+	var err error
+	test.CaptureOutput(func() {
+		p, err = c.PlanInstall("redis", InstallOptions{Namespace: "cache", Mode: ModeApply, Annotate: true})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(kube.Calls) != 0 {
+		t.Errorf("Expected a plan to change nothing, got %v", kube.Calls)
+	}
+	if p.Version != PlanVersion || p.Operation != audit.OpInstall || p.Chart.Name != "redis" || p.Chart.Digest == "" || p.Cluster.Context != "staging" {
+		t.Errorf("Unexpected plan: %+v", p)
+	}
+	if len(p.Operations) != 1 || p.Operations[0].Op != ModeApply || p.Operations[0].Kind != "Pod" || !strings.Contains(string(p.Operations[0].Manifest), chart.AnnChartDigest) {
+		t.Errorf("Unexpected operations: %+v", p.Operations)
+	}
+	if strings.Join(p.Settings.Kubectl.Apply, " ") != "--validate=false" {
+		t.Errorf("Expected the kubectl flags to be recorded, got %+v", p.Settings.Kubectl)
+	}
+
+	// The plan reads back as it was written.
+	path := filepath.Join(tmpHome, "plan.json")
+	if err := p.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadPlan(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(p, loaded) {
+		t.Errorf("Expected the plan to round-trip, got:\n%+v\nnot:\n%+v", loaded, p)
+	}
+
+	// It runs as it was planned, with its own kubectl flags.
+	kubectl.Defaults.UserArgs = kubectl.Args{}
+	test.CaptureOutput(func() {
+		if err := c.ApplyPlan(loaded); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if len(kube.Calls) != 1 || kube.Calls[0] != "apply cache" || string(kube.Stdin[0]) != string(p.Operations[0].Manifest) {
+		t.Errorf("Expected the planned manifest to be applied, got %v", kube.Calls)
+	}
+	if e, err := audit.LastInstall(c.auditPath(), "redis"); err != nil || e.Parameters.Mode != ModeApply || e.Namespace != "cache" {
+		t.Errorf("Expected the install to be recorded, got %+v, %v", e, err)
+	}
+
+	kubectl.Defaults.Context = "prod"
+	if err := c.ApplyPlan(loaded); err == nil || !strings.Contains(err.Error(), "The plan is for the context staging (cluster -), not prod") {
+		t.Errorf("Expected another context to be refused, got %v", err)
+	}
+	kubectl.Defaults.Context = "staging"
+	ioutil.WriteFile(util.WorkspaceChartDirectory(tmpHome, "redis", "README.md"), []byte("changed\n"), 0644)
+	if err := c.ApplyPlan(loaded); err == nil || !strings.Contains(err.Error(), "The chart redis has changed since the plan was made") {
+		t.Errorf("Expected a changed chart to be refused, got %v", err)
+	}
+	if len(kube.Calls) != 1 {
+		t.Errorf("Expected nothing to run, got %v", kube.Calls)
+	}
+}
+
+func TestPlanUninstall(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+	test.FakeUpdate(tmpHome)
+	test.CaptureOutput(func() { Fetch("kitchensink", "", tmpHome, FetchOptions{}) })
+
+	kube := &kubectl.FakeRunner{Out: []byte("deleted")}
+	c := newClient(tmpHome, kube)
+	p, err := c.PlanUninstall("kitchensink", "ns", UninstallOptions{KeepNamespaces: true, Keep: []string{"pod/deis-empty-pod"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Operation != audit.OpUninstall || len(p.Kept) != 2 || p.Kept[1] != "Namespace/kitchensink (--keep-namespaces)" {
+		t.Errorf("Unexpected plan: %+v", p)
+	}
+	for _, op := range p.Operations {
+		if op.Op != OpDelete || op.Kind == "Namespace" || op.Name == "deis-empty-pod" {
+			t.Errorf("Unexpected operation: %+v", op)
+		}
+	}
+
+	path := filepath.Join(tmpHome, "plan.json")
+	if err := p.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadPlan(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(p, loaded) {
+		t.Errorf("Expected the plan to round-trip, got:\n%+v\nnot:\n%+v", loaded, p)
+	}
+	test.CaptureOutput(func() {
+		if err := c.ApplyPlan(loaded); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if len(kube.Calls) != len(p.Operations) {
+		t.Fatalf("Expected %d deletes, got %v", len(p.Operations), kube.Calls)
+	}
+	for i, op := range p.Operations {
+		if expect := "delete " + op.Kind + " " + op.Name + " ns"; kube.Calls[i] != expect {
+			t.Errorf("Expected %q, got %q", expect, kube.Calls[i])
+		}
+	}
+}
+
+func TestLoadPlanErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "helmc-plan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for plan, expect := range map[string]string{
+		`{"version": 2}`: "is a plan of version 2. This helmc runs plans of version 1",
+		`{"version": 1, "operation": "install", "chart": {"name": "redis"}}`:                                                    "does not name the chart and its digest",
+		`{"version": 1, "operation": "upgrade", "chart": {"name": "redis", "digest": "x"}}`:                                     `plans an unknown operation "upgrade"`,
+		`{"version": 1, "operation": "install", "chart": {"name": "redis", "digest": "x"}, "operations": [{"op": "delete"}]}`:   `Operation 1 of`,
+		`{"version": 1, "operation": "uninstall", "chart": {"name": "redis", "digest": "x"}, "operations": [{"op": "create"}]}`: `"create" of`,
+		`{"version": 1, "operation": "install", "chart": {"name": "redis", "digest": "x"}, "operations": [{"op": "apply"}]}`:    "is not one that an install runs",
+		`not json`: "is not a plan",
+	} {
+		path := filepath.Join(dir, "plan.json")
+		ioutil.WriteFile(path, []byte(plan), 0644)
+		if _, err := LoadPlan(path); err == nil || !strings.Contains(err.Error(), expect) {
+			t.Errorf("Expected %s to fail with %q, got %v", plan, expect, err)
+		}
+	}
+}
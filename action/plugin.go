@@ -1,46 +1,111 @@
 package action
 
 import (
+	"fmt"
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"text/tabwriter"
 
+	"github.com/helm/helm-classic/chart"
+	"github.com/helm/helm-classic/helmpath"
 	"github.com/helm/helm-classic/log"
+	helm "github.com/helm/helm-classic/util"
 )
 
+// exit ends the process with a plugin's exit status. Tests replace it.
+var exit = os.Exit
+
 // Plugin attempts to execute a plugin.
 //
-// It looks on the path for an executable named `helm-COMMAND`, and executes
-// that, passing it all of the arguments received after the subcommand.
+// It looks for an executable named `helm-COMMAND` in the plugins directory
+// of the home, and then on the path, and executes it, passing it all of the
+// arguments received after the subcommand.
+//
+// Output is passed directly back to the user, and the plugin's exit status
+// is passed back to the shell unchanged.
 //
-// Output is passed directly back to the user.
+// The plugin's environment has the variables of helm.HelmEnv, and also:
 //
-// This ensures that the following environment variables are set:
+//   - $HELM_DEFAULT_REPO: the local name of the default repository.
+//   - $HELM_DEFAULT_REPO_URL: the URL of the default repository.
+//   - $HELM_REPOS: the local names of all of the repositories, separated by spaces.
+//   - $HELM_PLUGIN_DIR: the directory that the plugin is in.
+//   - $HELM_COMMAND: the name of the command (as seen by Helm) that resulted in this program being executed.
 //
-//	- $HELM_HOME: points to the user's Helm home directory.
-// 	- $HELM_DEFAULT_REPO: the local name of the default repository.
-// 	- $HELM_COMMAND: the name of the command (as seen by Helm) that resulted in this program being executed.
+// If the first argument that is not a flag names a chart in the workspace,
+// the chart variables of helm.HelmEnv describe it.
 func Plugin(homedir, cmd string, args []string) {
 	if abs, err := filepath.Abs(homedir); err == nil {
 		homedir = abs
 	}
 
+	path := FindPlugin(homedir, cmd)
+	if path == "" {
+		log.Die("No plugin named %s", PluginName(cmd))
+	}
+
 	// Although helmc itself may use the new HELMC_HOME environment variable to optionally define its
 	// home directory, to maintain compatibility with plugins created for the ORIGINAL helm, we
-	// continue to support expansion of these "legacy" environment variables, including HELM_HOME.
-	os.Setenv("HELM_HOME", homedir)
-	os.Setenv("HELM_COMMAND", args[0])
-	os.Setenv("HELM_DEFAULT_REPO", mustConfig(homedir).Repos.Default)
+	// continue to set these "legacy" environment variables, including HELM_HOME.
+	repos := mustConfig(homedir).Repos
+	env := helm.HelmEnv(helmpath.Home(homedir), pluginChart(homedir, args[1:]))
+	env["HELM_COMMAND"] = args[0]
+	env["HELM_DEFAULT_REPO"] = repos.Default
+	env["HELM_DEFAULT_REPO_URL"] = ""
+	if t := repos.Lookup(repos.Default); t != nil {
+		env["HELM_DEFAULT_REPO_URL"] = t.Repo
+	}
+	env["HELM_REPOS"] = strings.Join(repos.Searched(""), " ")
+	env["HELM_PLUGIN_DIR"] = filepath.Dir(path)
+	execPlugin(path, args[1:], env)
+}
 
-	cmd = PluginName(cmd)
-	execPlugin(cmd, args[1:])
+// pluginChart returns the chart of the workspace that the first argument of
+// a plugin, other than its flags, names, or nil if it names none.
+func pluginChart(homedir string, args []string) *helm.EnvChart {
+	for _, a := range args {
+		if strings.HasPrefix(a, "-") {
+			continue
+		}
+		if a != filepath.Base(a) {
+			return nil
+		}
+		dir := helm.WorkspaceChartDirectory(homedir, a)
+		cf, err := chart.LoadChartfile(filepath.Join(dir, Chartfile))
+		if err != nil {
+			return nil
+		}
+		return &helm.EnvChart{Name: cf.Name, Version: cf.Version, Path: dir}
+	}
+	return nil
 }
 
 // HasPlugin returns true if the named plugin exists.
-func HasPlugin(name string) bool {
-	name = PluginName(name)
-	_, err := exec.LookPath(name)
-	return err == nil
+func HasPlugin(homedir, name string) bool {
+	return FindPlugin(homedir, name) != ""
+}
+
+// FindPlugin returns the path to the named plugin, or "" if there is none.
+//
+// The plugins directory of the home is searched before $PATH.
+func FindPlugin(homedir, name string) string {
+	if p := filepath.Join(helmpath.Home(homedir).Plugins(), PluginName(name)); isExecutable(p) {
+		return p
+	}
+	if p, err := exec.LookPath(PluginName(name)); err == nil {
+		return p
+	}
+	return ""
+}
+
+func isExecutable(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && !fi.IsDir() && fi.Mode()&0111 != 0
 }
 
 // PluginName returns the full plugin name.
@@ -48,13 +113,106 @@ func PluginName(name string) string {
 	return "helm-" + name
 }
 
-func execPlugin(name string, args []string) {
+type plugin struct {
+	name, path string
+}
+
+// plugins finds the executables named `helm-*` in the plugins directory of
+// the home and on $PATH.
+//
+// Like a shell, only the first executable found for a name is returned.
+func plugins(homedir string) []plugin {
+	dirs := []string{helmpath.Home(homedir).Plugins()}
+	dirs = append(dirs, filepath.SplitList(os.Getenv("PATH"))...)
+
+	found := []plugin{}
+	seen := map[string]bool{}
+	for _, dir := range dirs {
+		if dir == "" {
+			dir = "."
+		}
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, f := range files {
+			name := strings.TrimPrefix(f.Name(), PluginName(""))
+			if name == f.Name() || name == "" || seen[name] {
+				continue
+			}
+			path := filepath.Join(dir, f.Name())
+			if !isExecutable(path) {
+				continue
+			}
+			seen[name] = true
+			found = append(found, plugin{name: name, path: path})
+		}
+	}
+	return found
+}
+
+// ListPlugins prints the plugins that can be run as helmc subcommands.
+//
+// Plugins that have the name of a built-in command cannot be run, since the
+// built-in command takes precedence, and are reported with a warning.
+func ListPlugins(homedir string, builtins []string) {
+	isBuiltin := map[string]bool{}
+	for _, b := range builtins {
+		isBuiltin[b] = true
+	}
+
+	found := plugins(homedir)
+	if len(found) == 0 {
+		log.Info("No plugins found. Plugins are executables named helm-NAME in %s or on $PATH.", helmpath.Home(homedir).Plugins())
+		return
+	}
+	sort.Sort(byPluginName(found))
+
+	w := tabwriter.NewWriter(log.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tPATH")
+	for _, p := range found {
+		fmt.Fprintf(w, "%s\t%s\n", p.name, p.path)
+	}
+	w.Flush()
+
+	for _, p := range found {
+		if isBuiltin[p.name] {
+			log.Warn("Plugin %s is shadowed by the built-in command '%s' and will not be run.", p.path, p.name)
+		}
+	}
+}
+
+type byPluginName []plugin
+
+func (b byPluginName) Len() int           { return len(b) }
+func (b byPluginName) Less(i, j int) bool { return b[i].name < b[j].name }
+func (b byPluginName) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+
+func execPlugin(name string, args []string, env map[string]string) {
 	cmd := exec.Command(name, args...)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	cmd.Stdin = os.Stdin
+	cmd.Env = helm.Environ(env)
 
 	if err := cmd.Run(); err != nil {
+		if code, ok := exitStatus(err); ok {
+			exit(code)
+			return
+		}
 		log.Die(err.Error())
 	}
 }
+
+// exitStatus returns the status that a command exited with, if it ran at all.
+func exitStatus(err error) (int, bool) {
+	e, ok := err.(*exec.ExitError)
+	if !ok {
+		return 0, false
+	}
+	ws, ok := e.Sys().(syscall.WaitStatus)
+	if !ok || !ws.Exited() {
+		return 0, false
+	}
+	return ws.ExitStatus(), true
+}
@@ -3,9 +3,11 @@ package action
 import (
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/helm/helm-classic/helmpath"
 	"github.com/helm/helm-classic/test"
 )
 
@@ -44,3 +46,94 @@ func TestPlugin(t *testing.T) {
 		t.Errorf("Expected 'HELLO -a -b -c', got %v", string(b))
 	}
 }
+
+// writePlugin writes an executable plugin script into dir.
+func writePlugin(t *testing.T, dir, name, script string) string {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, PluginName(name))
+	if err := ioutil.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFindPlugin(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+	pathDir, err := ioutil.TempDir("", "helmc-path-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(pathDir)
+	defer os.Setenv("PATH", os.Getenv("PATH"))
+	os.Setenv("PATH", pathDir)
+
+	onPath := writePlugin(t, pathDir, "both", "true")
+	writePlugin(t, pathDir, "path", "true")
+	inHome := writePlugin(t, helmpath.Home(tmpHome).Plugins(), "both", "true")
+
+	if p := FindPlugin(tmpHome, "both"); p != inHome {
+		t.Errorf("Expected the home's plugin %s to be found before %s, got %q", inHome, onPath, p)
+	}
+	if !HasPlugin(tmpHome, "path") {
+		t.Error("Expected a plugin on $PATH to be found")
+	}
+	if HasPlugin(tmpHome, "missing") {
+		t.Error("Expected no plugin named missing")
+	}
+
+	found := plugins(tmpHome)
+	if len(found) != 2 || found[0].path != inHome {
+		t.Errorf("Expected each plugin once, home first, got %v", found)
+	}
+
+	out := test.CaptureOutput(func() { ListPlugins(tmpHome, []string{"both"}) })
+	test.ExpectContains(t, out, "shadowed by the built-in command 'both'")
+}
+
+func TestPluginExitStatus(t *testing.T) {
+	dir, err := ioutil.TempDir("", "helmc-plugin-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := writePlugin(t, dir, "fail", "exit 42")
+
+	code := -1
+	exit = func(c int) { code = c }
+	defer func() { exit = os.Exit }()
+
+	execPlugin(path, nil, nil)
+	if code != 42 {
+		t.Errorf("Expected exit status 42, got %d", code)
+	}
+}
+
+func TestPluginEnv(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+	test.FakeUpdate(tmpHome)
+	Fetch("redis", "", tmpHome, FetchOptions{})
+
+	dir := helmpath.Home(tmpHome).Plugins()
+	envFile := filepath.Join(tmpHome, "env")
+	writePlugin(t, dir, "env", `echo "$HELM_CHART_NAME $HELM_CHART_PATH $HELM_DEFAULT_REPO $HELM_REPOS $HELM_PLUGIN_DIR" > `+envFile)
+
+	run := func(args ...string) string {
+		Plugin(tmpHome, "env", append([]string{"env"}, args...))
+		b, err := ioutil.ReadFile(envFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.TrimSpace(string(b))
+	}
+	expected := "redis " + filepath.Join(tmpHome, "workspace", "charts", "redis") + " charts charts " + dir
+	if env := run("-v", "redis"); env != expected {
+		t.Errorf("Expected %q, got %q", expected, env)
+	}
+	if env := run("nosuchchart"); env != "charts charts "+dir {
+		t.Errorf("Expected no chart for an argument that is not one, got %q", env)
+	}
+}
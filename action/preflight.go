@@ -0,0 +1,292 @@
+package action
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/helm/helm-classic/chart"
+	helmerrors "github.com/helm/helm-classic/errors"
+	"github.com/helm/helm-classic/kubectl"
+	"github.com/helm/helm-classic/log"
+	"github.com/helm/helm-classic/manifest"
+	helm "github.com/helm/helm-classic/util"
+)
+
+// Preflight checks, in the order they are run.
+const (
+	// CheckChart is lint, with the values schema of the chart.
+	CheckChart = "chart"
+	// CheckEnvironment is the kubeconfig, the kubectl binary, and the
+	// kubectl flags of the chart.
+	CheckEnvironment = "environment"
+	// CheckCluster is whether Kubernetes can be reached.
+	CheckCluster = "cluster"
+	// CheckAuthorization is whether the user may make the changes of the
+	// install, as 'kubectl auth can-i' tells.
+	CheckAuthorization = "authorization"
+	// CheckNames is whether the names of the chart's resources are taken by
+	// resources of another chart.
+	CheckNames = "names"
+)
+
+// Levels of preflight findings.
+const (
+	LevelError   = "error"
+	LevelWarning = "warning"
+)
+
+// PreflightFinding is a problem that a preflight check found.
+type PreflightFinding struct {
+	Check   string `json:"check"`
+	Level   string `json:"level"`
+	Message string `json:"message"`
+}
+
+// PreflightResult is the outcome of the preflight checks of an install.
+type PreflightResult struct {
+	Chart    string              `json:"chart"`
+	Findings []*PreflightFinding `json:"findings"`
+}
+
+// Errors returns the number of findings that are errors.
+func (r *PreflightResult) Errors() int {
+	n := 0
+	for _, f := range r.Findings {
+		if f.Level == LevelError {
+			n++
+		}
+	}
+	return n
+}
+
+func (r *PreflightResult) add(check, level, format string, v ...interface{}) {
+	r.Findings = append(r.Findings, &PreflightFinding{Check: check, Level: level, Message: fmt.Sprintf(format, v...)})
+}
+
+// err returns a *helmerrors.PreflightError if some findings are errors.
+func (r *PreflightResult) err() error {
+	if n := r.Errors(); n > 0 {
+		return &helmerrors.PreflightError{Chart: r.Chart, Errors: n}
+	}
+	return nil
+}
+
+// print prints the findings as a table, or as JSON if format is "json".
+func (r *PreflightResult) print(l *log.Logger, format string) error {
+	switch format {
+	case "json":
+		b, err := json.MarshalIndent(r, "", "  ")
+		if err != nil {
+			return err
+		}
+		l.Msg(string(b))
+		return nil
+	case "", "table":
+		if len(r.Findings) == 0 {
+			l.Info("Preflight checks of %s found nothing.", r.Chart)
+			return nil
+		}
+		w := tabwriter.NewWriter(l.Out(), 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "CHECK\tLEVEL\tFINDING")
+		for _, f := range r.Findings {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", f.Check, f.Level, f.Message)
+		}
+		w.Flush()
+		l.Msg("%d errors, %d warnings", r.Errors(), len(r.Findings)-r.Errors())
+		return nil
+	}
+	return fmt.Errorf("unknown output format %q", format)
+}
+
+// log reports each finding on l, as an error or a warning.
+func (r *PreflightResult) log(l *log.Logger) {
+	for _, f := range r.Findings {
+		if f.Level == LevelError {
+			l.Err("Preflight %s: %s", f.Check, f.Message)
+		} else {
+			l.Warn("Preflight %s: %s", f.Check, f.Message)
+		}
+	}
+}
+
+// Preflight runs the checks that an install runs before it changes anything,
+// and changes nothing.
+//
+// The chart is linted, values schema included; the kubeconfig, kubectl, and
+// the kubectl flags of the chart are checked; and then, if Kubernetes can be
+// reached, 'kubectl auth can-i' is asked whether each kind of the chart may be
+// created (or changed, in ModeApply and ModeReplace) in its namespace, and the
+// resources of the chart are looked up, so that those taken by another chart
+// are found. Every finding is reported, as a table or, if output is "json", as
+// JSON.
+//
+// If a finding is an error, a *helmerrors.PreflightError is returned. A
+// deprecated chart is only warned about, since nothing is installed.
+func Preflight(chartName, home, namespace, mode, output string, client kubectl.Runner) error {
+	if err := checkMode(mode); err != nil {
+		return err
+	}
+
+	c := newClient(home, client)
+	c.Config = mustConfig(home)
+	_, err := c.Preflight(chartName, InstallOptions{Namespace: namespace, Mode: mode, Output: output, AcceptDeprecated: true})
+	return err
+}
+
+// Preflight is like the package-level Preflight. The options are those of the
+// install to check; the chart is fetched, and generated if opts.Generate is
+// set, as the install would.
+func (c *Client) Preflight(chartName string, opts InstallOptions) (*PreflightResult, error) {
+	if opts.Mode == "" {
+		opts.Mode = ModeCreate
+	}
+	ch, chartName, ms, err := c.installPlan(chartName, &opts)
+	if err != nil {
+		return nil, err
+	}
+	ops, err := installOperations(ms, opts.Namespace, opts.Mode)
+	if err != nil {
+		return nil, err
+	}
+	res := c.preflight(ch, chartName, ops)
+	if perr := res.print(c.Log, opts.Output); perr != nil {
+		c.Log.Err("Could not print the preflight findings: %s", perr)
+	}
+	return res, res.err()
+}
+
+// preflight runs the preflight checks of the operations of an install.
+func (c *Client) preflight(ch *chart.Chart, chartName string, ops []*PlanOperation) *PreflightResult {
+	res := &PreflightResult{Chart: ch.Chartfile.Name, Findings: []*PreflightFinding{}}
+	c.Log.Info("Running preflight checks. Use --skip-preflight to skip them.")
+
+	c.preflightChart(helm.WorkspaceChartDirectory(c.Home, chartName), res)
+	c.preflightEnvironment(ch, res)
+	if out, err := c.Kube.Version(); err != nil {
+		res.add(CheckCluster, LevelError, "Kubernetes cannot be reached: %s", failure(out, err))
+		return res
+	}
+	c.preflightAuthorization(ops, res)
+	c.preflightNames(ch.Chartfile.Name, ops, res)
+	return res
+}
+
+// preflightChart lints the chart quietly, and adds the checks that failed.
+func (c *Client) preflightChart(dir string, res *PreflightResult) {
+	quiet := *c
+	quiet.Log = &log.Logger{Stdout: ioutil.Discard, Stderr: ioutil.Discard}
+	schemas, err := quiet.kubeSchemas(LintOptions{})
+	if err != nil {
+		res.add(CheckChart, LevelWarning, "Manifests are not checked against Kubernetes schemas: %s", err)
+	}
+	cv := quiet.lintValidations(dir, schemas)
+	cv.Valid()
+	for _, msg := range cv.Errors {
+		res.add(CheckChart, LevelError, "%s", msg)
+	}
+	for _, msg := range cv.Warnings {
+		res.add(CheckChart, LevelWarning, "%s", msg)
+	}
+}
+
+func (c *Client) preflightEnvironment(ch *chart.Chart, res *PreflightResult) {
+	if err := c.kube().CheckKubeconfig(); err != nil {
+		res.add(CheckEnvironment, LevelError, "Could not read kubeconfig: %s", err)
+	}
+	if _, ok := c.Kube.(kubectl.RealRunner); ok {
+		if _, _, err := c.kube().CheckBinary(); err != nil {
+			res.add(CheckEnvironment, LevelError, "%s", err)
+		}
+	}
+	if k := ch.Chartfile.Kubectl; k != nil {
+		for _, err := range kubectl.CheckChartArgs(kubectl.Args{Apply: k.Apply, Delete: k.Delete}) {
+			res.add(CheckEnvironment, LevelError, "%s: %s", Chartfile, err)
+		}
+	}
+}
+
+// preflightVerbs are the verbs that each install operation needs.
+var preflightVerbs = map[string][]string{
+	ModeCreate:  {"create"},
+	ModeApply:   {"create", "patch"},
+	ModeReplace: {"update"},
+}
+
+// preflightAuthorization asks once for each verb, kind, and namespace of the
+// operations whether it is allowed.
+func (c *Client) preflightAuthorization(ops []*PlanOperation, res *PreflightResult) {
+	asked := map[string]bool{}
+	for _, op := range ops {
+		for _, verb := range preflightVerbs[op.Op] {
+			key := verb + " " + op.Kind + " " + op.Namespace
+			if asked[key] {
+				continue
+			}
+			asked[key] = true
+			out, err := c.Kube.CanI(verb, op.Kind, op.Namespace)
+			switch answer := strings.TrimSpace(string(out)); {
+			case answer == "yes" && err == nil:
+			case answer == "no":
+				res.add(CheckAuthorization, LevelError, "You may not %s %s resources in %s.", verb, op.Kind, namespaceName(op.Namespace))
+			default:
+				msg := answer
+				if err != nil {
+					msg = failure(out, err)
+				}
+				res.add(CheckAuthorization, LevelWarning, "Could not tell whether you may %s %s resources in %s: %s", verb, op.Kind, namespaceName(op.Namespace), msg)
+			}
+		}
+	}
+}
+
+// preflightNames looks up the resources of the operations. One that another
+// chart installed is an error. One that helmc did not install is a warning,
+// since the install fails to create it, or takes it over.
+func (c *Client) preflightNames(chartName string, ops []*PlanOperation, res *PreflightResult) {
+	for _, op := range ops {
+		// A keeper is left as it is if it exists.
+		if op.Name == "" || manifest.IsKeeper(op.Manifest) {
+			continue
+		}
+		out, err := c.Kube.GetObject(op.Name, op.Kind, op.Namespace)
+		if err != nil {
+			if !kubectl.IsNotFound(out) {
+				res.add(CheckNames, LevelWarning, "Could not look up %s %s: %s", op.Kind, op.Name, failure(out, err))
+			}
+			continue
+		}
+		var obj struct {
+			Metadata struct {
+				Labels      map[string]string `json:"labels"`
+				Annotations map[string]string `json:"annotations"`
+			} `json:"metadata"`
+		}
+		if err := json.Unmarshal(out, &obj); err != nil {
+			res.add(CheckNames, LevelWarning, "Could not read %s %s: %s", op.Kind, op.Name, err)
+			continue
+		}
+		owner := obj.Metadata.Annotations[chart.AnnChartName]
+		switch {
+		case owner == chartName:
+		case owner != "":
+			res.add(CheckNames, LevelError, "%s %s already exists in %s, installed from the chart %s.", op.Kind, op.Name, namespaceName(op.Namespace), owner)
+		case obj.Metadata.Labels["heritage"] == "helm":
+			res.add(CheckNames, LevelWarning, "%s %s already exists in %s, installed from a chart without annotations.", op.Kind, op.Name, namespaceName(op.Namespace))
+		case op.Op == ModeCreate:
+			res.add(CheckNames, LevelWarning, "%s %s already exists in %s, and was not installed by helmc. It will not be created.", op.Kind, op.Name, namespaceName(op.Namespace))
+		default:
+			res.add(CheckNames, LevelWarning, "%s %s already exists in %s, and was not installed by helmc. The install takes it over.", op.Kind, op.Name, namespaceName(op.Namespace))
+		}
+	}
+}
+
+// namespaceName names a namespace in messages.
+func namespaceName(ns string) string {
+	if ns == "" {
+		return "the default namespace"
+	}
+	return "namespace " + ns
+}
@@ -0,0 +1,139 @@
+package action
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	helmerrors "github.com/helm/helm-classic/errors"
+	"github.com/helm/helm-classic/kubectl"
+	"github.com/helm/helm-classic/test"
+)
+
+// preflightRunner answers CanI with allowed, and GetObject with existing, or
+// as if the resource did not exist.
+type preflightRunner struct {
+	kubectl.FakeRunner
+	allowed  string
+	existing string
+	down     bool
+}
+
+func (r *preflightRunner) Version() ([]byte, error) {
+	r.FakeRunner.Version()
+	if r.down {
+		return []byte("The connection to the server was refused"), errors.New("exit status 1")
+	}
+	return []byte("Server Version: v1.2.4"), nil
+}
+
+func (r *preflightRunner) CanI(verb, kind, ns string) ([]byte, error) {
+	r.FakeRunner.CanI(verb, kind, ns)
+	if r.allowed == "no" {
+		return []byte("no"), errors.New("exit status 1")
+	}
+	return []byte(r.allowed), nil
+}
+
+func (r *preflightRunner) GetObject(name, ktype, ns string) ([]byte, error) {
+	r.FakeRunner.GetObject(name, ktype, ns)
+	if r.existing == "" {
+		return []byte(`Error from server (NotFound): pods "` + name + `" not found`), errors.New("exit status 1")
+	}
+	return []byte(r.existing), nil
+}
+
+func TestPreflight(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+	test.FakeUpdate(tmpHome)
+	Fetch("redis", "", tmpHome, FetchOptions{})
+
+	tests := []struct {
+		name   string
+		runner *preflightRunner
+		errors int
+		expect string
+	}{
+		{"nothing in the way", &preflightRunner{allowed: "yes"}, 0, "0 errors"},
+		{"not allowed", &preflightRunner{allowed: "no"}, 1, "You may not create Pod resources in namespace cache."},
+		{"unknown permission", &preflightRunner{allowed: "maybe"}, 0, "Could not tell whether you may create Pod"},
+		{"taken by another chart", &preflightRunner{allowed: "yes", existing: `{"metadata": {"annotations": {"chart.helm.sh/name": "cache"}}}`}, 1, "Pod redis already exists in namespace cache, installed from the chart cache."},
+		{"ours", &preflightRunner{allowed: "yes", existing: `{"metadata": {"annotations": {"chart.helm.sh/name": "redis"}}}`}, 0, "0 errors"},
+		{"not from helmc", &preflightRunner{allowed: "yes", existing: `{"metadata": {}}`}, 0, "was not installed by helmc. It will not be created."},
+		{"unreachable", &preflightRunner{down: true}, 1, "Kubernetes cannot be reached: The connection to the server was refused"},
+	}
+	for _, tt := range tests {
+		var err error
+		actual := test.CaptureOutput(func() {
+			err = Preflight("redis", tmpHome, "cache", "", "", tt.runner)
+		})
+		if tt.errors == 0 && err != nil {
+			t.Errorf("%s: expected no error, got %s", tt.name, err)
+		}
+		var pe *helmerrors.PreflightError
+		if tt.errors > 0 && (!errors.As(err, &pe) || pe.Errors != tt.errors) {
+			t.Errorf("%s: expected %d preflight errors, got %v", tt.name, tt.errors, err)
+		}
+		if !strings.Contains(actual, tt.expect) {
+			t.Errorf("%s: expected %q in:\n%s", tt.name, tt.expect, actual)
+		}
+		// The chart is linted too, and redis has no README.
+		test.ExpectContains(t, actual, "README.md is present and not empty")
+		for _, c := range tt.runner.Calls {
+			if strings.HasPrefix(c, "create") || strings.HasPrefix(c, "apply") {
+				t.Errorf("%s: expected a preflight to change nothing, got %q", tt.name, c)
+			}
+		}
+	}
+
+	// In ModeApply, the resources must also be patched.
+	r := &preflightRunner{allowed: "yes"}
+	test.CaptureOutput(func() {
+		Preflight("redis", tmpHome, "cache", ModeApply, "json", r)
+	})
+	test.ExpectEquals(t, strings.Join(r.Calls, "; "), "version; can-i create Pod cache; can-i patch Pod cache; get Pod redis cache")
+}
+
+func TestInstallPreflight(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+	test.FakeUpdate(tmpHome)
+	Fetch("redis", "", tmpHome, FetchOptions{})
+
+	// An error stops the install before anything is changed.
+	r := &preflightRunner{allowed: "no"}
+	var err error
+	actual := test.CaptureOutput(func() {
+		err = Install("redis", tmpHome, InstallOptions{Namespace: "cache", Annotate: true, Labels: true, Preflight: true}, r)
+	})
+	expectError(t, err, helmerrors.ErrPreflightFailed, "nothing was changed")
+	test.ExpectContains(t, actual, "Preflight authorization: You may not create Pod resources")
+	for _, c := range r.Calls {
+		if strings.HasPrefix(c, "create") {
+			t.Errorf("Expected nothing to be created, got %q", c)
+		}
+	}
+
+	// Warnings do not.
+	r = &preflightRunner{allowed: "maybe"}
+	test.CaptureOutput(func() {
+		err = Install("redis", tmpHome, InstallOptions{Namespace: "cache", Annotate: true, Labels: true, Preflight: true}, r)
+	})
+	if err != nil {
+		t.Fatalf("Expected the install to go on, got %s", err)
+	}
+	if last := r.Calls[len(r.Calls)-1]; last != "create cache" {
+		t.Errorf("Expected the install to follow the preflight checks, got %v", r.Calls)
+	}
+
+	// Nor does anything, with --skip-preflight.
+	r = &preflightRunner{allowed: "no"}
+	test.CaptureOutput(func() {
+		err = Install("redis", tmpHome, InstallOptions{Namespace: "cache", Annotate: true, Labels: true}, r)
+	})
+	if err != nil || strings.Join(r.Calls, "; ") != "create cache" {
+		t.Errorf("Expected only the install, got %v: %v", r.Calls, err)
+	}
+}
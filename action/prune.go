@@ -0,0 +1,339 @@
+package action
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/helm/helm-classic/audit"
+	"github.com/helm/helm-classic/chart"
+	"github.com/helm/helm-classic/kubectl"
+	"github.com/helm/helm-classic/log"
+	"github.com/helm/helm-classic/manifest"
+	helm "github.com/helm/helm-classic/util"
+)
+
+// Resource statuses reported by Prune, besides StatusFailed.
+const (
+	// StatusDeleted indicates that the resource was deleted.
+	StatusDeleted = "deleted"
+	// StatusGone indicates that the resource was already deleted.
+	StatusGone = "gone"
+	// StatusKept indicates that the resource was not deleted, because of
+	// its OrphanResource.Kept.
+	StatusKept = "kept"
+)
+
+// PruneOptions control how Prune deletes the orphans of a chart.
+type PruneOptions struct {
+	// DryRun only lists the orphans that would be deleted.
+	DryRun bool
+	// Yes deletes without asking for confirmation.
+	Yes bool
+}
+
+// OrphanResource is a resource in Kubernetes that is labeled for a chart, but
+// that the chart no longer has a manifest for.
+type OrphanResource struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+	// Version is the version of the chart that installed the resource.
+	Version string `json:"version,omitempty"`
+	// Kept is why the resource is not deleted, if it is not.
+	Kept string `json:"kept,omitempty"`
+}
+
+// Prune deletes the resources that an earlier version of a chart installed,
+// and that the chart in the workspace no longer has.
+//
+// Install labels each resource with chart.LabelChartName. The resources of
+// the namespace with the label of the chart are listed, and those whose kind,
+// namespace, and name are not those of a manifest of the chart are orphans.
+// A renamed resource is thus an orphan, and its new name is installed anew.
+// So are the resources of the latest revision of the release (see History)
+// that the chart no longer has, whether or not they are labeled.
+// Orphans are listed, and deleted once the user confirms, unless o.Yes is
+// set. With o.DryRun, they are only listed.
+//
+// The namespace must be given, or be in the chart's Chart.yaml (see
+// ChartNamespace).
+//
+// Orphans with a keeper annotation (see manifest.IsKeeper), and those whose
+// name was generated, are not deleted. Neither are resources that were
+// neither labeled nor recorded in a revision: reinstall the chart with
+// ModeApply to label them.
+func Prune(chartName, home, namespace string, o PruneOptions, client kubectl.Runner) error {
+	// As for Uninstall, the namespace is never left to kubectl.
+	if namespace = ChartNamespace(home, chartName, namespace); namespace == "" {
+		return fmt.Errorf("Pruning requires a namespace. Did you mean '-n default'?")
+	}
+	CheckClientPrereqs(client)
+
+	c := newClient(home, client)
+	orphans, err := c.Orphans(chartName, namespace)
+	if err != nil {
+		return err
+	}
+	if len(orphans) == 0 {
+		log.Info("%s has no orphaned resources in namespace %s.", chartName, namespace)
+		return nil
+	}
+	printOrphans(orphans)
+	if o.DryRun {
+		log.Info("Would delete %d resources. Run without --dry-run to delete them.", deletable(orphans))
+		return nil
+	}
+	if deletable(orphans) == 0 {
+		log.Info("Every orphan is kept. Nothing to delete.")
+		return nil
+	}
+	if !o.Yes && !promptConfirm("Delete the listed resources?") {
+		log.Info("Aborted prune")
+		return nil
+	}
+	res, err := c.Prune(chartName, namespace, orphans)
+	printPruned(res)
+	return err
+}
+
+// Orphans returns the orphans of a chart in a namespace, in the order that
+// Prune deletes them: that of UninstallOrder, after any unknown kinds.
+func (c *Client) Orphans(chartName, namespace string) ([]*OrphanResource, error) {
+	if !chartFetched(chartName, c.Home, c.Log) {
+		return nil, fmt.Errorf("No chart named %q in your workspace", chartName)
+	}
+	ch, err := chart.Load(helm.WorkspaceChartDirectory(c.Home, chartName))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load chart: %s", err)
+	}
+	restore, err := c.useChartArgs(ch)
+	if err != nil {
+		return nil, err
+	}
+	defer restore()
+
+	selector := chart.LabelChartName + "=" + ch.Chartfile.Name
+	out, err := c.Kube.List(strings.Join(c.pruneKinds(ch, chartName), ","), selector, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("Could not list the resources labeled %s: %s", selector, failure(out, err))
+	}
+	var list struct {
+		Items []map[string]interface{} `json:"items"`
+	}
+	if err := json.Unmarshal(out, &list); err != nil {
+		return nil, fmt.Errorf("Could not read the resources labeled %s: %s", selector, err)
+	}
+
+	// A kind is cluster-scoped if it is built in as such, if the chart
+	// defines it so, or if the cluster lists its resources without a namespace.
+	ms := installManifests(ch, nil)
+	chartScope := manifest.ClusterScope(ms)
+	listed := map[string]bool{}
+	for _, obj := range list.Items {
+		if str(field(obj, "metadata", "namespace")) == "" {
+			listed[str(obj["kind"])] = true
+		}
+	}
+	clusterScoped := func(kind string) bool {
+		return listed[kind] || chartScope(kind)
+	}
+	current := map[string]bool{}
+	for _, m := range ms {
+		ns := namespace
+		if meta, err := m.VersionedObject.Meta(); err == nil && meta.Namespace != "" {
+			ns = meta.Namespace
+		}
+		current[resourceKey(m.Kind, ns, m.Name, clusterScoped)] = true
+	}
+
+	orphans := []*OrphanResource{}
+	for _, obj := range list.Items {
+		o := &OrphanResource{
+			Kind:      str(obj["kind"]),
+			Name:      str(field(obj, "metadata", "name")),
+			Namespace: str(field(obj, "metadata", "namespace")),
+			Version:   str(field(obj, "metadata", "annotations", chart.AnnChartVersion)),
+		}
+		if current[resourceKey(o.Kind, o.Namespace, o.Name, clusterScoped)] {
+			continue
+		}
+		// Kubernetes deletes the resources that a controller owns with it.
+		if refs, ok := field(obj, "metadata", "ownerReferences").([]interface{}); ok && len(refs) > 0 {
+			continue
+		}
+		if data, err := json.Marshal(obj); err == nil {
+			if a := manifest.KeptBy(data); a != "" {
+				o.Kept = fmt.Sprintf("%q annotation", a)
+			}
+		}
+		if o.Kept == "" && str(field(obj, "metadata", "generateName")) != "" {
+			o.Kept = "generated name"
+		}
+		orphans = append(orphans, o)
+		current[resourceKey(o.Kind, o.Namespace, o.Name, clusterScoped)] = true
+	}
+	recorded, err := c.recordedOrphans(chartName, namespace, current, clusterScoped)
+	if err != nil {
+		return nil, err
+	}
+	orphans = append(orphans, recorded...)
+	order := append(ch.UnknownKinds(UninstallOrder), UninstallOrder...)
+	sort.SliceStable(orphans, func(i, j int) bool {
+		a, b := orphans[i], orphans[j]
+		if x, y := sortIndex(a.Kind, order), sortIndex(b.Kind, order); x != y {
+			return x < y
+		}
+		return a.Namespace+"/"+a.Name < b.Namespace+"/"+b.Name
+	})
+	return orphans, nil
+}
+
+// recordedOrphans returns the resources of the latest revision of a release
+// in namespace whose keys are not in known, as orphans.
+func (c *Client) recordedOrphans(chartName, namespace string, known map[string]bool, clusterScoped func(string) bool) ([]*OrphanResource, error) {
+	revs, err := c.History(chartName)
+	if err != nil {
+		return nil, err
+	}
+	if len(revs) == 0 || revs[len(revs)-1].Namespace != namespace {
+		return nil, nil
+	}
+	rev := revs[len(revs)-1]
+	orphans := []*OrphanResource{}
+	for _, m := range rev.Manifests {
+		ns := m.Namespace
+		if ns == "" {
+			ns = namespace
+		}
+		if m.Hook != "" || m.Name == "" || known[resourceKey(m.Kind, ns, m.Name, clusterScoped)] {
+			continue
+		}
+		known[resourceKey(m.Kind, ns, m.Name, clusterScoped)] = true
+		o := &OrphanResource{Kind: m.Kind, Name: m.Name, Namespace: ns, Version: rev.Version}
+		if clusterScoped(m.Kind) {
+			o.Namespace = ""
+		}
+		if a := manifest.KeptBy(m.Manifest); a != "" {
+			o.Kept = fmt.Sprintf("%q annotation", a)
+		}
+		orphans = append(orphans, o)
+	}
+	return orphans, nil
+}
+
+// Prune deletes orphans of a chart that Orphans returned, and records it in
+// the audit log. It returns the outcome for each orphan. Kept orphans are
+// skipped, and those that are already gone are not an error.
+func (c *Client) Prune(chartName, namespace string, orphans []*OrphanResource) (res []*ResourceResult, err error) {
+	defer c.completed(OpPrune, chartName, c.started(OpPrune, chartName), &err)
+	dir := helm.WorkspaceChartDirectory(c.Home, chartName)
+	ch, err := chart.Load(dir)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load chart: %s", err)
+	}
+	restore, err := c.useChartArgs(ch)
+	if err != nil {
+		return nil, err
+	}
+	defer restore()
+	defer c.useRetryEvents()()
+
+	c.Log.Info("Running `kubectl delete` ...")
+	e := c.newAuditEntry(audit.OpPrune, ch, dir, namespace)
+	failed := 0
+	for _, o := range orphans {
+		rr := &ResourceResult{Kind: o.Kind, Name: o.Name, Namespace: o.Namespace, Status: StatusDeleted}
+		res = append(res, rr)
+		if o.Kept != "" {
+			rr.Status, rr.Error = StatusKept, o.Kept
+			continue
+		}
+		ns := o.Namespace
+		if ns == "" {
+			ns = namespace
+		}
+		out, derr := c.Kube.Delete(o.Name, o.Kind, ns)
+		switch {
+		case derr == nil:
+			c.Log.Debug(string(out))
+		case kubectl.IsNotFound(out):
+			rr.Status = StatusGone
+		default:
+			rr.Status, rr.Error = StatusFailed, failure(out, derr)
+			failed++
+		}
+		e.Resources = append(e.Resources, &audit.Resource{Kind: rr.Kind, Name: rr.Name, Namespace: rr.Namespace, Status: rr.Status, Error: rr.Error})
+	}
+	if failed > 0 {
+		err = fmt.Errorf("%d resources could not be deleted", failed)
+	}
+	c.recordAudit(e, err)
+	return res, err
+}
+
+// pruneKinds returns the kinds that the orphans of a chart may have: those
+// of InstallOrder, the kinds of the chart, and those of its last install.
+func (c *Client) pruneKinds(ch *chart.Chart, chartName string) []string {
+	kinds := append(append([]string{}, InstallOrder...), ch.UnknownKinds(InstallOrder)...)
+	seen := map[string]bool{}
+	for _, k := range kinds {
+		seen[k] = true
+	}
+	if e, err := audit.LastInstall(c.auditPath(), chartName); err == nil && e != nil {
+		for _, r := range e.Resources {
+			if !seen[r.Kind] {
+				seen[r.Kind] = true
+				kinds = append(kinds, r.Kind)
+			}
+		}
+	}
+	return kinds
+}
+
+// resourceKey identifies a resource by its kind, namespace, and name. The
+// namespace of a cluster-scoped kind is ignored.
+func resourceKey(kind, namespace, name string, clusterScoped func(string) bool) string {
+	if clusterScoped(kind) {
+		namespace = ""
+	}
+	return strings.ToLower(kind) + "/" + namespace + "/" + name
+}
+
+// deletable counts the orphans that are not kept.
+func deletable(orphans []*OrphanResource) int {
+	n := 0
+	for _, o := range orphans {
+		if o.Kept == "" {
+			n++
+		}
+	}
+	return n
+}
+
+// printPruned reports the outcome of a prune, with the reasons that orphans
+// were kept or could not be deleted.
+func printPruned(res []*ResourceResult) {
+	t := map[string]int{}
+	for _, rr := range res {
+		t[rr.Status]++
+	}
+	log.Info("%d deleted, %d kept, %d already gone, %d failed", t[StatusDeleted], t[StatusKept], t[StatusGone], t[StatusFailed])
+	for _, rr := range res {
+		if rr.Error != "" {
+			log.Msg("\t%s %s/%s: %s", rr.Status, rr.Kind, rr.Name, rr.Error)
+		}
+	}
+}
+
+// printOrphans lists orphans as a table.
+func printOrphans(orphans []*OrphanResource) {
+	w := tabwriter.NewWriter(log.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "KIND\tNAMESPACE\tNAME\tVERSION\tKEPT")
+	for _, o := range orphans {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", o.Kind, dash(o.Namespace), o.Name, dash(o.Version), dash(o.Kept))
+	}
+	w.Flush()
+}
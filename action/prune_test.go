@@ -0,0 +1,161 @@
+package action
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/helm/helm-classic/audit"
+	"github.com/helm/helm-classic/chart"
+	"github.com/helm/helm-classic/kubectl"
+	"github.com/helm/helm-classic/test"
+	"github.com/helm/helm-classic/util"
+)
+
+// pruneList is what kubectl returns for the resources labeled for redis. Only
+// the Pod is in the chart.
+const pruneList = `{"apiVersion": "v1", "kind": "List", "items": [
+{"apiVersion": "v1", "kind": "Pod", "metadata": {"name": "redis", "namespace": "cache"}},
+{"apiVersion": "v1", "kind": "Service", "metadata": {"name": "redis", "namespace": "cache",
+ "annotations": {"chart.helm.sh/version": "0.0.1"}}},
+{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "redis-conf", "namespace": "cache",
+ "annotations": {"helm.sh/resource-policy": "keep"}}},
+{"apiVersion": "v1", "kind": "Pod", "metadata": {"name": "redis-1234", "namespace": "cache",
+ "ownerReferences": [{"kind": "ReplicaSet", "name": "redis", "controller": true}]}},
+{"apiVersion": "v1", "kind": "Namespace", "metadata": {"name": "redis-old"}}
+]}`
+
+type pruneRunner struct {
+	kubectl.FakeRunner
+	// list is the output of List, or pruneList if it is empty.
+	list string
+}
+
+func (r *pruneRunner) List(kinds, selector, ns string) ([]byte, error) {
+	r.FakeRunner.List(kinds, selector, ns)
+	if r.list != "" {
+		return []byte(r.list), nil
+	}
+	return []byte(pruneList), nil
+}
+
+func TestOrphans(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+	test.FakeUpdate(tmpHome)
+
+	r := &pruneRunner{}
+	c := newClient(tmpHome, r)
+	var orphans []*OrphanResource
+	var err error
+	test.CaptureOutput(func() {
+		if _, err := c.Fetch("redis", "", FetchOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		orphans, err = c.Orphans("redis", "cache")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.ExpectContains(t, r.Calls[0], "chart.helm.sh/name=redis cache")
+
+	// The Service has the name of the Pod, but not its kind. Namespaces go
+	// last, and the owned Pod is left to its controller.
+	found := []string{}
+	for _, o := range orphans {
+		found = append(found, o.Kind+"/"+o.Name+" "+o.Version+" "+o.Kept)
+	}
+	test.ExpectEquals(t, strings.Join(found, ", "), `Service/redis 0.0.1 , ConfigMap/redis-conf  "helm.sh/resource-policy" annotation, Namespace/redis-old  `)
+
+	actual := test.CaptureOutput(func() {
+		if err := Prune("redis", tmpHome, "cache", PruneOptions{DryRun: true}, r); err != nil {
+			t.Fatal(err)
+		}
+	})
+	test.ExpectContains(t, actual, "Would delete 2 resources")
+	for _, call := range r.Calls {
+		if strings.HasPrefix(call, "delete") {
+			t.Errorf("Expected a dry run to delete nothing, got %s", call)
+		}
+	}
+
+	test.CaptureOutput(func() {
+		if err := Prune("redis", tmpHome, "cache", PruneOptions{Yes: true}, r); err != nil {
+			t.Fatal(err)
+		}
+	})
+	deleted := []string{}
+	for _, call := range r.Calls {
+		if strings.HasPrefix(call, "delete") {
+			deleted = append(deleted, call)
+		}
+	}
+	test.ExpectEquals(t, strings.Join(deleted, ", "), "delete Service redis cache, delete Namespace redis-old cache")
+
+	e, err := audit.Tail(c.auditPath(), 1)
+	if err != nil || len(e) != 1 {
+		t.Fatalf("Expected the prune to be recorded, got %v", err)
+	}
+	test.ExpectEquals(t, e[0].Operation, audit.OpPrune)
+	test.ExpectEquals(t, len(e[0].Resources), 2)
+
+	if err := Prune("redis", tmpHome, "", PruneOptions{}, r); err == nil {
+		t.Errorf("Expected a prune without a namespace to fail")
+	}
+}
+
+func TestInstallLabels(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+	test.FakeUpdate(tmpHome)
+	test.CaptureOutput(func() {
+		Fetch("redis", "", tmpHome, FetchOptions{})
+	})
+	ch, err := chart.Load(util.WorkspaceChartDirectory(tmpHome, "redis"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range installManifests(ch, map[string]string{chart.AnnChartName: "redis"}) {
+		meta, err := m.VersionedObject.Meta()
+		if err != nil {
+			t.Fatal(err)
+		}
+		test.ExpectEquals(t, meta.Labels[chart.LabelChartName], "redis")
+	}
+}
+
+func TestOrphansClusterScoped(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+	test.FakeUpdate(tmpHome)
+
+	// The cluster lists the cluster-scoped resources of the chart without a
+	// namespace: a ClusterRole, a Tenant of a custom kind that the chart
+	// defines, and a ClusterIssuer of a kind that the chart does not define.
+	r := &pruneRunner{list: `{"apiVersion": "v1", "kind": "List", "items": [
+{"apiVersion": "v1", "kind": "Pod", "metadata": {"name": "redis", "namespace": "cache"}},
+{"apiVersion": "rbac.authorization.k8s.io/v1", "kind": "ClusterRole", "metadata": {"name": "redis-reader"}},
+{"apiVersion": "example.com/v1", "kind": "Tenant", "metadata": {"name": "redis"}},
+{"apiVersion": "cert-manager.io/v1", "kind": "ClusterIssuer", "metadata": {"name": "redis"}}
+]}`}
+	c := newClient(tmpHome, r)
+	var orphans []*OrphanResource
+	var err error
+	test.CaptureOutput(func() {
+		if _, err := c.Fetch("redis", "", FetchOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		dir := util.WorkspaceChartDirectory(tmpHome, "redis")
+		writeManifest(t, dir, "reader.yaml", "apiVersion: rbac.authorization.k8s.io/v1\nkind: ClusterRole\nmetadata:\n  name: redis-reader\n")
+		writeManifest(t, dir, "crd.yaml", tenantCRD)
+		writeManifest(t, dir, "tenant.yaml", "apiVersion: example.com/v1\nkind: Tenant\nmetadata:\n  name: redis\n")
+		writeManifest(t, dir, "issuer.yaml", "apiVersion: cert-manager.io/v1\nkind: ClusterIssuer\nmetadata:\n  name: redis\n")
+		orphans, err = c.Orphans("redis", "cache")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, o := range orphans {
+		t.Errorf("Expected no orphans, got %s/%s in %q", o.Kind, o.Name, o.Namespace)
+	}
+}
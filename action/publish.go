@@ -1,8 +1,10 @@
 package action
 
-import "os"
-
 import (
+	"os"
+	"path/filepath"
+
+	"github.com/helm/helm-classic/chart"
 	"github.com/helm/helm-classic/log"
 	helm "github.com/helm/helm-classic/util"
 )
@@ -12,15 +14,20 @@ import (
 // - chartName being published
 // - homeDir is the helm home directory for the user
 // - force publishing even if the chart directory already exists
-func Publish(chartName, homeDir, repo string, force bool) {
+// - push commits the chart in the cache and pushes it to the repository's remote
+//
+// Publishing a new version of a deprecated chart that is still marked as
+// deprecated is warned about.
+func Publish(chartName, homeDir, repo string, force, push bool) {
 	if repo == "" {
 		repo = "charts"
 	}
 
-	if !mustConfig(homeDir).Repos.Exists(repo) {
+	cfg := mustConfig(homeDir)
+	if !cfg.Repos.Exists(repo) {
 		log.Err("Repo %s does not exist", repo)
 		log.Info("Available repositories")
-		ListRepos(homeDir)
+		ListRepos(homeDir, "")
 		return
 	}
 
@@ -34,7 +41,35 @@ func Publish(chartName, homeDir, repo string, force bool) {
 		}
 	}
 
+	checkStillDeprecated(src, dst)
 	if err := helm.CopyDir(src, dst); err != nil {
 		log.Die("failed to publish directory: %v", err)
 	}
+
+	if push {
+		if err := cfg.Repos.Push(repo, "Publish "+chartName); err != nil {
+			log.Die("Could not push %s: %s", chartName, err)
+		}
+		log.Info("Pushed %s to %s", chartName, repo)
+	}
+}
+
+// checkStillDeprecated warns if the chart in src is a new version of the
+// deprecated chart in dst, and is deprecated too.
+func checkStillDeprecated(src, dst string) {
+	next, err := chart.LoadChartfile(filepath.Join(src, Chartfile))
+	if err != nil || !next.Deprecated {
+		return
+	}
+	prev, err := chart.LoadChartfile(filepath.Join(dst, Chartfile))
+	if err != nil || !prev.Deprecated || prev.Version == next.Version {
+		return
+	}
+	warnStillDeprecated(next.Name, next.Version, prev.Version)
+}
+
+// warnStillDeprecated warns that a new version of a deprecated chart is
+// deprecated too, which is usually a Chart.yaml that was copied forward.
+func warnStillDeprecated(name, version, prev string) {
+	log.Warn("%s %s is still marked as deprecated, as %s was. Remove 'deprecated' from its Chart.yaml if the new version replaces the deprecated one.", name, version, prev)
 }
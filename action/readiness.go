@@ -0,0 +1,224 @@
+package action
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/helm/helm-classic/kubectl"
+)
+
+// Readiness states reported by Status, besides StateMissing.
+const (
+	// ReadyYes indicates that a resource is serving: a Pod whose containers
+	// are ready, a Service with endpoints, a Deployment whose replicas are
+	// available, and so on.
+	ReadyYes = "ready"
+	// ReadyNo indicates that a resource is not serving yet, or no longer.
+	ReadyNo = "not ready"
+	// ReadyNone indicates that a kind has no readiness, such as a ConfigMap.
+	// It counts as ready.
+	ReadyNone = "-"
+)
+
+// readinessKinds are the kinds that have a readiness, in the order of the
+// rollup of Status, and how their ready resources are described in it, such
+// as "2/3 pods ready".
+var readinessKinds = []struct{ kind, noun string }{
+	{"Pod", "pods ready"},
+	{"Service", "services with endpoints"},
+	{"Deployment", "deployments available"},
+	{"ReplicationController", "replication controllers ready"},
+	{"ReplicaSet", "replica sets ready"},
+	{"DaemonSet", "daemon sets ready"},
+	{"StatefulSet", "stateful sets ready"},
+	{"Job", "jobs complete"},
+	{"PersistentVolumeClaim", "claims bound"},
+}
+
+// hasReadiness reports whether the resources of a kind have a readiness.
+func hasReadiness(kind string) bool {
+	for _, k := range readinessKinds {
+		if k.kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// workload is the part of the state of a resource that readiness reads.
+type workload struct {
+	Spec struct {
+		Replicas    *int                   `json:"replicas"`
+		Type        string                 `json:"type"`
+		Selector    map[string]interface{} `json:"selector"`
+		Completions *int                   `json:"completions"`
+	} `json:"spec"`
+	Status struct {
+		Phase                  string `json:"phase"`
+		Replicas               int    `json:"replicas"`
+		ReadyReplicas          int    `json:"readyReplicas"`
+		AvailableReplicas      int    `json:"availableReplicas"`
+		UpdatedReplicas        int    `json:"updatedReplicas"`
+		DesiredNumberScheduled int    `json:"desiredNumberScheduled"`
+		NumberReady            int    `json:"numberReady"`
+		Succeeded              int    `json:"succeeded"`
+		Conditions             []struct {
+			Type   string `json:"type"`
+			Status string `json:"status"`
+		} `json:"conditions"`
+		ContainerStatuses []struct {
+			Ready bool `json:"ready"`
+		} `json:"containerStatuses"`
+	} `json:"status"`
+}
+
+// readiness returns whether the resource of a kind, whose state is data as
+// JSON, is ready, and a detail such as "2/3 available". A Service is ready
+// when its Endpoints have an address, which is read with client.
+func readiness(kind, name, ns string, data []byte, client kubectl.Runner) (string, string) {
+	if !hasReadiness(kind) {
+		return ReadyNone, ""
+	}
+	w := &workload{}
+	if err := json.Unmarshal(data, w); err != nil {
+		return ReadyNo, fmt.Sprintf("its state could not be read: %s", err)
+	}
+	st, want := w.Status, 1
+	if w.Spec.Replicas != nil {
+		want = *w.Spec.Replicas
+	}
+	switch kind {
+	case "Pod":
+		if st.Phase == "Succeeded" {
+			return ReadyYes, "completed"
+		}
+		ready := 0
+		for _, c := range st.ContainerStatuses {
+			if c.Ready {
+				ready++
+			}
+		}
+		if st.Phase != "Running" || len(st.ContainerStatuses) == 0 {
+			return ReadyNo, or(strings.ToLower(st.Phase), "pending")
+		}
+		return upTo(ready, len(st.ContainerStatuses), "containers ready")
+	case "Service":
+		if w.Spec.Type == "ExternalName" || len(w.Spec.Selector) == 0 {
+			return ReadyYes, "no selector"
+		}
+		return serviceEndpoints(name, ns, client)
+	case "Deployment":
+		if st.UpdatedReplicas < want {
+			return ReadyNo, fmt.Sprintf("%d/%d updated", st.UpdatedReplicas, want)
+		}
+		return upTo(st.AvailableReplicas, want, "available")
+	case "StatefulSet", "ReplicaSet":
+		return upTo(st.ReadyReplicas, want, "ready")
+	case "ReplicationController":
+		// Older servers do not report readyReplicas.
+		ready := st.ReadyReplicas
+		if ready == 0 && !hasField(data, "status", "readyReplicas") {
+			ready = st.Replicas
+		}
+		return upTo(ready, want, "ready")
+	case "DaemonSet":
+		return upTo(st.NumberReady, st.DesiredNumberScheduled, "ready")
+	case "Job":
+		for _, c := range st.Conditions {
+			if c.Status == "True" && c.Type == "Failed" {
+				return ReadyNo, "failed"
+			}
+		}
+		want = 1
+		if w.Spec.Completions != nil {
+			want = *w.Spec.Completions
+		}
+		return upTo(st.Succeeded, want, "succeeded")
+	case "PersistentVolumeClaim":
+		if st.Phase == "Bound" {
+			return ReadyYes, "bound"
+		}
+		return ReadyNo, or(strings.ToLower(st.Phase), "pending")
+	}
+	return ReadyNone, ""
+}
+
+// upTo returns ReadyYes if have is at least want, with a detail such as
+// "2/3 available".
+func upTo(have, want int, what string) (string, string) {
+	detail := fmt.Sprintf("%d/%d %s", have, want, what)
+	if have >= want {
+		return ReadyYes, detail
+	}
+	return ReadyNo, detail
+}
+
+// serviceEndpoints reads the Endpoints of a Service, which is ready if they
+// have an address.
+func serviceEndpoints(name, ns string, client kubectl.Runner) (string, string) {
+	out, err := client.GetObject(name, "Endpoints", ns)
+	if err != nil {
+		if kubectl.IsNotFound(out) {
+			return ReadyNo, "no endpoints"
+		}
+		return ReadyNo, "its endpoints could not be read: " + failure(out, err)
+	}
+	ep := struct {
+		Subsets []struct {
+			Addresses []json.RawMessage `json:"addresses"`
+		} `json:"subsets"`
+	}{}
+	if err := json.Unmarshal(out, &ep); err != nil {
+		return ReadyNo, fmt.Sprintf("its endpoints could not be read: %s", err)
+	}
+	n := 0
+	for _, s := range ep.Subsets {
+		n += len(s.Addresses)
+	}
+	if n == 0 {
+		return ReadyNo, "no endpoints"
+	}
+	return ReadyYes, fmt.Sprintf("%d endpoints", n)
+}
+
+// hasField reports whether a JSON object has a field at path.
+func hasField(data []byte, path ...string) bool {
+	var obj interface{}
+	if json.Unmarshal(data, &obj) != nil {
+		return false
+	}
+	for _, p := range path {
+		m, ok := obj.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		if obj, ok = m[p]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// readinessRollup counts the ready resources of each kind that has a
+// readiness, such as "1/2 pods ready, 1/1 services with endpoints". A missing
+// resource counts as not ready.
+func readinessRollup(sts []*installed) string {
+	parts := []string{}
+	for _, k := range readinessKinds {
+		ready, all := 0, 0
+		for _, st := range sts {
+			if st.Kind != k.kind {
+				continue
+			}
+			all++
+			if st.Ready == ReadyYes {
+				ready++
+			}
+		}
+		if all > 0 {
+			parts = append(parts, fmt.Sprintf("%d/%d %s", ready, all, k.noun))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
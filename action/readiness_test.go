@@ -0,0 +1,81 @@
+package action
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	helmerrors "github.com/helm/helm-classic/errors"
+	"github.com/helm/helm-classic/kubectl"
+	"github.com/helm/helm-classic/test"
+)
+
+func TestReadiness(t *testing.T) {
+	endpoints := &kubectl.FakeRunner{Out: []byte(`{"subsets": [{"addresses": [{"ip": "10.0.0.1"}, {"ip": "10.0.0.2"}]}]}`)}
+	tests := []struct {
+		kind, data string
+		client     kubectl.Runner
+		ready      string
+		detail     string
+	}{
+		{"Pod", `{"status": {"phase": "Running", "containerStatuses": [{"ready": true}, {"ready": true}]}}`, nil, ReadyYes, "2/2 containers ready"},
+		{"Pod", `{"status": {"phase": "Running", "containerStatuses": [{"ready": true}, {"ready": false}]}}`, nil, ReadyNo, "1/2 containers ready"},
+		{"Pod", `{"status": {"phase": "Pending"}}`, nil, ReadyNo, "pending"},
+		{"Pod", `{"status": {"phase": "Succeeded"}}`, nil, ReadyYes, "completed"},
+		{"Deployment", `{"spec": {"replicas": 3}, "status": {"updatedReplicas": 3, "availableReplicas": 2}}`, nil, ReadyNo, "2/3 available"},
+		{"Deployment", `{"spec": {"replicas": 3}, "status": {"updatedReplicas": 1, "availableReplicas": 3}}`, nil, ReadyNo, "1/3 updated"},
+		{"Deployment", `{"status": {"updatedReplicas": 1, "availableReplicas": 1}}`, nil, ReadyYes, "1/1 available"},
+		{"ReplicationController", `{"spec": {"replicas": 2}, "status": {"replicas": 2}}`, nil, ReadyYes, "2/2 ready"},
+		{"ReplicationController", `{"spec": {"replicas": 2}, "status": {"replicas": 2, "readyReplicas": 1}}`, nil, ReadyNo, "1/2 ready"},
+		{"DaemonSet", `{"status": {"desiredNumberScheduled": 3, "numberReady": 3}}`, nil, ReadyYes, "3/3 ready"},
+		{"Job", `{"status": {"conditions": [{"type": "Failed", "status": "True"}]}}`, nil, ReadyNo, "failed"},
+		{"PersistentVolumeClaim", `{"status": {"phase": "Bound"}}`, nil, ReadyYes, "bound"},
+		{"Service", `{"spec": {"selector": {"app": "redis"}}}`, endpoints, ReadyYes, "2 endpoints"},
+		{"Service", `{"spec": {"selector": {"app": "redis"}}}`, &kubectl.FakeRunner{Out: []byte(`{"subsets": []}`)}, ReadyNo, "no endpoints"},
+		{"Service", `{"spec": {"type": "ExternalName"}}`, nil, ReadyYes, "no selector"},
+		{"ConfigMap", `{}`, nil, ReadyNone, ""},
+	}
+	for _, tt := range tests {
+		ready, detail := readiness(tt.kind, "redis", "default", []byte(tt.data), tt.client)
+		if ready != tt.ready || detail != tt.detail {
+			t.Errorf("Expected %s %s to be %q (%s), got %q (%s)", tt.kind, tt.data, tt.ready, tt.detail, ready, detail)
+		}
+	}
+	if endpoints.Calls[0] != "get Endpoints redis default" {
+		t.Errorf("Expected the endpoints of the service to be read, got %v", endpoints.Calls)
+	}
+}
+
+func TestStatusWatch(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+	test.FakeUpdate(tmpHome)
+	Fetch("redis", "", tmpHome, FetchOptions{})
+	defer func(d time.Duration) { pollInterval = d }(pollInterval)
+	pollInterval = time.Millisecond
+
+	client := &kubectl.FakeRunner{Out: []byte(`{"status": {"phase": "Pending"}}`)}
+	var err error
+	out := test.CaptureOutput(func() {
+		err = Status("redis", tmpHome, "default", StatusOptions{Watch: true, Timeout: 20 * time.Millisecond}, client)
+	})
+	var ne *helmerrors.NotReadyError
+	if !errors.As(err, &ne) || len(ne.Resources) != 1 || !strings.Contains(ne.Resources[0], "pending") {
+		t.Errorf("Expected the watch to time out, got %v", err)
+	}
+	if len(client.Calls) < 2 {
+		t.Errorf("Expected the pod to be checked more than once, got %v", client.Calls)
+	}
+	test.ExpectContains(t, out, "Ready: 0/1 pods ready")
+
+	client = &kubectl.FakeRunner{Out: []byte(`{"status": {"phase": "Running", "containerStatuses": [{"ready": true}]}}`)}
+	out = test.CaptureOutput(func() {
+		err = Status("redis", tmpHome, "default", StatusOptions{Watch: true, Timeout: time.Minute}, client)
+	})
+	if err != nil || len(client.Calls) != 1 {
+		t.Errorf("Expected a ready pod to end the watch at once, got %v, %v", err, client.Calls)
+	}
+	test.ExpectContains(t, out, "1/1 containers ready")
+}
@@ -0,0 +1,130 @@
+package action
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/helm/helm-classic/audit"
+	"github.com/helm/helm-classic/generator"
+	"github.com/helm/helm-classic/kubectl"
+)
+
+// ReinstallOptions are the options of Reinstall.
+type ReinstallOptions struct {
+	// Show prints the parameters that the chart would be installed with, as
+	// a helmc install command, and installs nothing.
+	Show bool
+	// Override changes the recorded parameters before they are used. It may
+	// be nil.
+	Override func(*InstallOptions)
+	// Output is the format of the install summary.
+	Output string
+}
+
+// Reinstall installs a chart of the workspace again, with the parameters of
+// its last install: the namespace, the mode, and the other options of
+// Install, as the audit log records them. The chart is installed as it is
+// now, which may differ from what was installed then.
+func Reinstall(chartName, home string, opts ReinstallOptions, client kubectl.Runner) error {
+	c := newClient(home, client)
+	c.Config = mustConfig(home)
+	if !opts.Show {
+		CheckClientPrereqs(client)
+	}
+	_, err := c.Reinstall(chartName, opts)
+	return err
+}
+
+// Reinstall is like the package-level Reinstall. With Show, it returns a nil
+// result.
+func (c *Client) Reinstall(chartName string, opts ReinstallOptions) (*InstallResult, error) {
+	e, err := audit.LastInstall(c.auditPath(), chartName)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read the audit log: %s", err)
+	}
+	if e == nil {
+		return nil, fmt.Errorf("No install of %s is recorded in the audit log %s. Install it with 'helmc install' first.", chartName, c.auditPath())
+	}
+	p := e.Parameters
+	o := InstallOptions{
+		Namespace:  e.Namespace,
+		Force:      p.Force,
+		Generate:   p.Generate,
+		SkipSchema: p.SkipSchema,
+		Exclude:    p.Exclude,
+		Values:     ValueSources{Files: p.Values, Set: p.Set, Env: p.Env},
+		Mode:       p.Mode,
+		Atomic:     p.Atomic,
+		Annotate:   p.Annotate,
+		Labels:     p.Labels,
+
+		// The chart was accepted by the install that is repeated.
+		AcceptDeprecated: true,
+		InjectNamespace:  p.InjectNamespace,
+	}
+	if opts.Override != nil {
+		opts.Override(&o)
+	}
+	o.Output = opts.Output
+
+	when := e.Time.Local().Format("2006-01-02 15:04:05")
+	if opts.Show {
+		c.Log.Info("%s %s was last installed at %s (%s).", e.Chart, e.Version, when, e.Outcome)
+		shown := o
+		if len(shown.Values.SetFrom) == 0 {
+			shown.Values.SetFrom = p.SetFrom
+		}
+		c.Log.Msg("%s", installCommand(chartName, shown))
+		return nil, nil
+	}
+	if len(p.SetFrom) > 0 && len(o.Values.SetFrom) == 0 {
+		c.Log.Warn("The last install of %s read values from sources, which are not recorded: %s. Give them again with --set-from.", chartName, strings.Join(p.SetFrom, ", "))
+	}
+	c.Log.Info("Installing %s with the parameters of its install at %s", chartName, when)
+	c.Log.Debug("As if by: %s", installCommand(chartName, o))
+	return c.Install(chartName, o)
+}
+
+// installCommand returns the helmc install command that installs a chart with
+// opts, for the shell.
+func installCommand(chartName string, opts InstallOptions) string {
+	words := []string{"helmc", "install"}
+	if opts.Namespace != "" {
+		words = append(words, "--namespace", generator.ShellQuote(opts.Namespace))
+	}
+	if opts.Mode != "" && opts.Mode != ModeCreate {
+		words = append(words, "--mode", opts.Mode)
+	}
+	flags := []struct {
+		set  bool
+		name string
+	}{
+		{opts.Atomic, "--atomic"},
+		{!opts.Annotate, "--no-annotations"},
+		{!opts.Labels, "--no-labels"},
+		{opts.Force, "--force"},
+		{opts.Generate, "--generate"},
+		{opts.SkipSchema, "--skip-schema"},
+		{opts.InjectNamespace, "--inject-namespace"},
+		{opts.Values.AllowExec, "--allow-exec-values"},
+	}
+	for _, f := range flags {
+		if f.set {
+			words = append(words, f.name)
+		}
+	}
+	for _, x := range opts.Exclude {
+		words = append(words, "--exclude", generator.ShellQuote(x))
+	}
+	for _, f := range opts.Values.Files {
+		words = append(words, "--values", generator.ShellQuote(f))
+	}
+	for _, s := range opts.Values.Set {
+		words = append(words, "--set", generator.ShellQuote(s))
+	}
+	for _, s := range opts.Values.SetFrom {
+		words = append(words, "--set-from", generator.ShellQuote(s))
+	}
+	words = append(words, generator.ShellQuote(chartName))
+	return strings.Join(words, " ")
+}
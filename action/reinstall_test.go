@@ -0,0 +1,63 @@
+package action
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/helm/helm-classic/audit"
+	"github.com/helm/helm-classic/kubectl"
+	"github.com/helm/helm-classic/test"
+)
+
+func TestReinstall(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+	test.FakeUpdate(tmpHome)
+
+	c := newClient(tmpHome, &kubectl.FakeRunner{Out: []byte(`pod "redis" created`)})
+	if _, err := c.Reinstall("redis", ReinstallOptions{Show: true}); err == nil || !strings.Contains(err.Error(), "No install of redis is recorded") {
+		t.Errorf("Expected no recorded install, got %v", err)
+	}
+
+	// An entry of an older release has no parameters, and is skipped.
+	audit.Append(c.auditPath(), &audit.Entry{Time: time.Now(), Operation: audit.OpInstall, Chart: "redis", Namespace: "old"})
+	test.CaptureOutput(func() {
+		if _, err := c.Install("redis", InstallOptions{Namespace: "cache", Force: true, Mode: ModeApply, Atomic: true, Exclude: []string{"tpl dir"}}); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	actual := test.CaptureOutput(func() {
+		if _, err := c.Reinstall("redis", ReinstallOptions{Show: true}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	test.ExpectContains(t, actual, "redis 0.0.1 was last installed at")
+	test.ExpectContains(t, actual, "helmc install --namespace cache --mode apply --atomic --no-annotations --no-labels --force --exclude 'tpl dir' redis\n")
+
+	kube := &kubectl.FakeRunner{Out: []byte(`pod "redis" configured`)}
+	c.Kube = kube
+	test.CaptureOutput(func() {
+		_, err := c.Reinstall("redis", ReinstallOptions{Override: func(o *InstallOptions) {
+			o.Namespace = "staging"
+			o.Annotate = true
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+	if len(kube.Calls) == 0 || kube.Calls[0] != "apply staging" {
+		t.Errorf("Expected the recorded mode and the new namespace, got %v", kube.Calls)
+	}
+
+	// The reinstall is recorded in turn, with the parameters it ran with.
+	e, err := audit.LastInstall(c.auditPath(), "redis")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Namespace != "staging" || !e.Parameters.Annotate || !e.Parameters.Atomic || e.Parameters.Mode != ModeApply {
+		t.Errorf("Unexpected parameters of the reinstall: %s %+v", e.Namespace, e.Parameters)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"os/exec"
 	"strings"
 
+	"github.com/helm/helm-classic/kubectl"
 	"github.com/helm/helm-classic/log"
 	"github.com/helm/helm-classic/manifest"
 	helm "github.com/helm/helm-classic/util"
@@ -17,8 +18,10 @@ type kubeGetter func(string) string
 var kubeGet kubeGetter = func(m string) string {
 	log.Debug("Getting manifests from %s", m)
 
+	ctx, cancel := helm.WithTimeout(kubectl.Defaults.Timeout)
+	defer cancel()
 	a := []string{"get", "-f", m}
-	out, _ := exec.Command("kubectl", a...).CombinedOutput()
+	out, _ := exec.CommandContext(ctx, kubectl.Defaults.Path, a...).CombinedOutput()
 	return string(out)
 }
 
@@ -33,6 +36,7 @@ func Remove(chart, homedir string, force bool) {
 		log.Err("Chart not found. %s", err)
 		return
 	}
+	defer lockChart(homedir, chart)()
 
 	if !force {
 		var connectionFailure bool
@@ -60,6 +64,7 @@ func Remove(chart, homedir string, force bool) {
 	if err := os.RemoveAll(chartPath); err != nil {
 		log.Die("Could not remove chart. %s", err)
 	}
+	forgetFetch(homedir, chart)
 
 	log.Info("All clear! You have successfully removed %s from your workspace.", chart)
 }
@@ -43,7 +43,7 @@ func TestTRemove(t *testing.T) {
 		tmpHome := test.CreateTmpHome()
 		test.FakeUpdate(tmpHome)
 
-		Fetch("kitchensink", "", tmpHome)
+		Fetch("kitchensink", "", tmpHome, FetchOptions{})
 
 		// set the mock getter
 		kubeGet = tt.getter
@@ -0,0 +1,161 @@
+package action
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/helm/helm-classic/log"
+	"github.com/helm/helm-classic/manifest"
+	helm "github.com/helm/helm-classic/util"
+)
+
+// RenderedManifest is a manifest as install would send it to Kubernetes.
+type RenderedManifest struct {
+	// Source is the file the manifest came from, relative to the chart, such
+	// as manifests/deployment.yaml.
+	Source string
+	Kind   string
+	Name   string
+	YAML   []byte
+}
+
+// Render prints the manifests that install would send to Kubernetes,
+// without talking to Kubernetes.
+//
+// - chartName is the name of the chart, which is fetched if necessary
+// - homedir is the home directory for the user
+// - show are the files to print, relative to the chart or to its manifests
+// directory, as paths or glob patterns. If it is empty, every file is printed.
+//
+// Each file is preceded by a '# Source:' comment, unless a single file was
+// asked for. The remaining options are those of Install. A deprecated chart
+// is only warned about, and the limits of install are not checked, since
+// nothing is installed.
+func Render(chartName, homedir string, show []string, force, generate, skipSchema bool, exclude []string, values ValueSources, annotate, labels bool) {
+	c := newClient(homedir, nil)
+	c.Config = mustConfig(homedir)
+	rendered, err := c.Render(chartName, show, InstallOptions{
+		Force:      force,
+		Generate:   generate,
+		SkipSchema: skipSchema,
+		Exclude:    exclude,
+		Annotate:   annotate,
+		Labels:     labels,
+		Values:     values,
+		Limits:     noLimits,
+
+		AcceptDeprecated: true,
+	})
+	if err != nil {
+		log.Die("%s", err)
+	}
+
+	sources := map[string]bool{}
+	for _, r := range rendered {
+		sources[r.Source] = true
+	}
+	last := ""
+	for i, r := range rendered {
+		if i > 0 {
+			fmt.Fprintln(log.Stdout, "---")
+		}
+		if (len(show) == 0 || len(sources) > 1) && r.Source != last {
+			fmt.Fprintf(log.Stdout, "# Source: %s\n", r.Source)
+		}
+		last = r.Source
+		log.Stdout.Write(r.YAML)
+	}
+}
+
+// Render is like the package-level Render. It returns the selected manifests
+// in install order. The Namespace, Output, Mode, and Atomic options are ignored.
+//
+// A pattern of show that matches no file is an error, which lists the files
+// of the chart.
+func (c *Client) Render(chartName string, show []string, opts InstallOptions) ([]*RenderedManifest, error) {
+	_, chartName, ms, err := c.installPlan(chartName, &opts)
+	if err != nil {
+		return nil, err
+	}
+	dir := helm.WorkspaceChartDirectory(c.Home, chartName)
+
+	all := make([]*RenderedManifest, 0, len(ms))
+	for _, m := range ms {
+		data, err := m.VersionedObject.YAML()
+		if err != nil {
+			return nil, fmt.Errorf("Could not render %s %s: %s", m.Kind, m.Name, err)
+		}
+		all = append(all, &RenderedManifest{Source: renderSource(dir, m), Kind: m.Kind, Name: m.Name, YAML: data})
+	}
+	if len(show) == 0 {
+		return all, nil
+	}
+	return selectRendered(all, show)
+}
+
+// renderSource returns the file of a manifest, relative to the chart.
+func renderSource(dir string, m *manifest.Manifest) string {
+	if rel, err := filepath.Rel(dir, m.Source); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return filepath.ToSlash(m.Source)
+}
+
+// selectRendered returns the manifests whose files match any of patterns.
+func selectRendered(all []*RenderedManifest, patterns []string) ([]*RenderedManifest, error) {
+	matched := make([]bool, len(all))
+	for _, p := range patterns {
+		p = strings.TrimPrefix(filepath.ToSlash(p), "./")
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("Invalid pattern %q: %s", p, err)
+		}
+		found := false
+		for i, r := range all {
+			if renderMatch(p, r.Source) {
+				matched[i], found = true, true
+			}
+		}
+		if !found {
+			return nil, noSuchFile(p, all)
+		}
+	}
+
+	res := []*RenderedManifest{}
+	for i, r := range all {
+		if matched[i] {
+			res = append(res, r)
+		}
+	}
+	return res, nil
+}
+
+// renderMatch reports whether a pattern names a file, relative to the chart
+// or to its manifests directory.
+func renderMatch(pattern, source string) bool {
+	if ok, _ := path.Match(pattern, source); ok {
+		return true
+	}
+	ok, _ := path.Match(pattern, strings.TrimPrefix(source, "manifests/"))
+	return ok
+}
+
+// noSuchFile reports a pattern that matches none of the files of a chart.
+func noSuchFile(pattern string, all []*RenderedManifest) error {
+	files := []string{}
+	seen := map[string]bool{}
+	for _, r := range all {
+		if !seen[r.Source] {
+			seen[r.Source] = true
+			files = append(files, r.Source)
+		}
+	}
+	if len(files) == 0 {
+		return errors.New("The chart has no manifests. If they are generated, rerun with --generate.")
+	}
+	sort.Strings(files)
+	return fmt.Errorf("No manifest of the chart matches %q. The chart has:\n\t%s", pattern, strings.Join(files, "\n\t"))
+}
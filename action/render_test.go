@@ -0,0 +1,88 @@
+package action
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ghodss/yaml"
+
+	"github.com/helm/helm-classic/kubectl"
+	"github.com/helm/helm-classic/log"
+	"github.com/helm/helm-classic/test"
+)
+
+func TestRender(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+	test.FakeUpdate(tmpHome)
+	c := &Client{Home: tmpHome}
+
+	rendered, err := c.Render("kitchensink", []string{"manifests/sink-pod.yaml", "nested/*"}, InstallOptions{Force: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	sources := []string{}
+	for _, r := range rendered {
+		sources = append(sources, r.Source)
+	}
+	test.ExpectEquals(t, strings.Join(sources, " "), "manifests/nested/nested-pod.yaml manifests/nested/nested-pod.yaml manifests/sink-pod.yaml")
+
+	// Install sends the same objects.
+	client := &kubectl.FakeRunner{Out: []byte("created")}
+	c.Kube = client
+	if _, err := c.Install("kitchensink", InstallOptions{Force: true}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	all, _ := c.Render("kitchensink", nil, InstallOptions{Force: true})
+	if len(all) != len(client.Stdin) {
+		t.Fatalf("Expected %d manifests, installed %d", len(all), len(client.Stdin))
+	}
+	for i, r := range all {
+		var got, sent interface{}
+		j, _ := yaml.YAMLToJSON(r.YAML)
+		json.Unmarshal(j, &got)
+		json.Unmarshal(client.Stdin[i], &sent)
+		if !reflect.DeepEqual(got, sent) {
+			t.Errorf("Rendered %s differs from what install sent:\n%s\n%s", r.Source, j, client.Stdin[i])
+		}
+	}
+
+	_, err = c.Render("kitchensink", []string{"deployment.yaml"}, InstallOptions{Force: true})
+	if err == nil {
+		t.Fatal("Expected an error for a missing file")
+	}
+	test.ExpectContains(t, err.Error(), `No manifest of the chart matches "deployment.yaml". The chart has:`)
+	test.ExpectContains(t, err.Error(), "\tmanifests/sink-deployment.yaml\n")
+}
+
+func TestRenderOutput(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+	test.FakeUpdate(tmpHome)
+
+	var out bytes.Buffer
+	o := log.Stdout
+	log.Stdout = &out
+	defer func() { log.Stdout = o }()
+
+	Render("kitchensink", tmpHome, []string{"sink-pod.yaml"}, true, false, false, nil, ValueSources{}, true, true)
+	one := out.String()
+	test.ExpectContains(t, one, "kind: Pod")
+	test.ExpectContains(t, one, "chart.helm.sh/name: kitchensink")
+	if strings.Contains(one, "# Source:") || strings.Contains(one, "---") {
+		t.Errorf("Expected a single file without comments, got %q", one)
+	}
+
+	out.Reset()
+	Render("kitchensink", tmpHome, nil, true, false, false, nil, ValueSources{}, false, false)
+	all := out.String()
+	test.ExpectContains(t, all, "# Source: manifests/sink-namespace.yaml\n")
+	test.ExpectContains(t, all, "---\n# Source: manifests/sink-pod.yaml\n")
+	if strings.Contains(all, "chart.helm.sh/name") {
+		t.Errorf("Expected no annotations, got %q", all)
+	}
+}
@@ -1,29 +1,183 @@
 package action
 
 import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/helm/helm-classic/chart"
+	"github.com/helm/helm-classic/config"
+	"github.com/helm/helm-classic/helmpath"
 	"github.com/helm/helm-classic/log"
+	"github.com/helm/helm-classic/output"
+	"github.com/helm/helm-classic/repo"
+	helm "github.com/helm/helm-classic/util"
+	"golang.org/x/crypto/ssh/terminal"
 )
 
+// repoInfo is the machine-readable description of a repository.
+type repoInfo struct {
+	Name        string     `json:"name" yaml:"name"`
+	URL         string     `json:"url" yaml:"url"`
+	Type        string     `json:"type" yaml:"type"`
+	Ref         string     `json:"ref,omitempty" yaml:"ref,omitempty"`
+	Priority    int        `json:"priority" yaml:"priority"`
+	Default     bool       `json:"default" yaml:"default"`
+	LastUpdated *time.Time `json:"lastUpdated,omitempty" yaml:"lastUpdated,omitempty"`
+	Path        string     `json:"path" yaml:"path"`
+	// Verified is set only for repositories that have a keyring.
+	Verified *bool  `json:"verified,omitempty" yaml:"verified,omitempty"`
+	SignedBy string `json:"signedBy,omitempty" yaml:"signedBy,omitempty"`
+}
+
 // ListRepos lists the repositories.
-func ListRepos(homedir string) {
+//
+// The format is "json", "yaml", or "" for a human-readable list.
+func ListRepos(homedir, format string) {
 	rf := mustConfig(homedir).Repos
 
+	if format != "" {
+		infos := []*repoInfo{}
+		for _, t := range rf.Tables {
+			typ := t.Type
+			if typ == "" {
+				typ = config.TypeGit
+			}
+			path := filepath.Join(rf.Dir, t.Name)
+			info := &repoInfo{
+				Name:        t.Name,
+				URL:         t.Repo,
+				Type:        typ,
+				Ref:         t.Ref(),
+				Priority:    t.Priority,
+				Default:     t.Name == rf.Default,
+				LastUpdated: lastUpdated(t, path),
+				Path:        path,
+			}
+			if t.Keyring != "" {
+				signer, err := rf.VerifyIndex(t.Name)
+				verified := err == nil
+				info.Verified, info.SignedBy = &verified, signer
+			}
+			infos = append(infos, info)
+		}
+		if err := printFormatted(infos, format); err != nil {
+			log.Die("%s", err)
+		}
+		return
+	}
+
 	for _, t := range rf.Tables {
 		n := t.Name
 		if t.Name == rf.Default {
 			n += "*"
 		}
-		log.Msg("\t%s\t%s", n, t.Repo)
+		notes := []string{}
+		switch {
+		case t.IsHTTP():
+			notes = append(notes, "http")
+		case t.Tag != "":
+			notes = append(notes, "tag: "+t.Tag)
+		case t.Branch != "":
+			notes = append(notes, "branch: "+t.Branch)
+		}
+		if t.Keyring != "" {
+			if signer, err := rf.VerifyIndex(t.Name); err != nil {
+				notes = append(notes, "unverified: "+err.Error())
+			} else {
+				notes = append(notes, "signed by "+signer)
+			}
+		}
+		if t.Priority != 0 {
+			notes = append(notes, fmt.Sprintf("priority: %d", t.Priority))
+		}
+		if len(notes) == 0 {
+			log.Msg("\t%s\t%s", n, t.Repo)
+			continue
+		}
+		log.Msg("\t%s\t%s\t(%s)", n, t.Repo, strings.Join(notes, ", "))
+	}
+}
+
+// lastUpdated returns the time a repository's cache was last refreshed, or nil if it never was.
+func lastUpdated(t *config.Table, path string) *time.Time {
+	// A fresh clone has no FETCH_HEAD, so fall back to HEAD.
+	markers := []string{filepath.Join(path, ".git", "FETCH_HEAD"), filepath.Join(path, ".git", "HEAD")}
+	if t.IsHTTP() {
+		markers = []string{filepath.Join(path, repo.IndexFile)}
 	}
+	for _, m := range markers {
+		if fi, err := os.Stat(m); err == nil {
+			mt := fi.ModTime()
+			return &mt
+		}
+	}
+	return nil
+}
+
+// printFormatted writes v to log.Stdout as JSON or YAML.
+func printFormatted(v interface{}, format string) error {
+	return output.Print(log.Stdout, v, format)
 }
 
 // AddRepo adds a repo to the list of repositories.
-func AddRepo(homedir, name, repository string) {
+//
+// The table's type is "git" or "http"; if it is empty, it is guessed from the
+// URL. Unless validate is false, the URL is checked for obvious mistakes. If
+// verify is true, the remote is contacted before anything is cloned.
+//
+// If token is set, it is the token of the repository, which is kept in the
+// credentials file of the home rather than in the configuration (see
+// config.Credentials). It is "-" to read it from stdin.
+func AddRepo(homedir string, t *config.Table, token string, validate, verify bool) {
+	defer lockConfig(homedir)()
 	cfg := mustConfig(homedir)
 
-	if err := cfg.Repos.Add(name, repository); err != nil {
+	if t.Branch != "" && t.Tag != "" {
+		log.Die("Only one of --branch and --tag may be given.")
+	}
+	if token != "" {
+		if t.Auth != nil && (t.Auth.TokenFrom != "" || t.Auth.TokenFile != "") {
+			log.Die("Only one of --token, --token-from, and --token-file may be given.")
+		}
+		if cfg.Repos.Lookup(t.Name) != nil {
+			log.Die("A repository named %s already exists.", t.Name)
+		}
+		tok, err := readToken(token)
+		if err != nil {
+			log.Die("Could not read the token of %s: %s", t.Name, err)
+		}
+		if t.Auth == nil {
+			t.Auth = &config.Auth{}
+		}
+		t.Auth.UseStoredToken(tok)
+		token = tok
+	}
+
+	if validate {
+		if err := config.ValidateURL(t); err != nil {
+			log.Die("%s (use --no-validate to add it anyway)", err)
+		}
+	}
+	if verify {
+		if err := cfg.Repos.Verify(t); err != nil {
+			log.Die("Could not verify repository %s: %s", t.Name, err)
+		}
+	}
+
+	if err := cfg.Repos.Add(t); err != nil {
 		log.Die(err.Error())
 	}
+	if token != "" {
+		if err := storeToken(homedir, t.Name, token); err != nil {
+			log.Die("Could not store the token of %s: %s", t.Name, err)
+		}
+	}
 	if err := cfg.Save(""); err != nil {
 		log.Die("Could not save configuration: %s", err)
 	}
@@ -31,14 +185,317 @@ func AddRepo(homedir, name, repository string) {
 	log.Info("Hooray! Successfully added the repo.")
 }
 
-// DeleteRepo deletes a repository.
-func DeleteRepo(homedir, name string) {
+// LoginRepo stores the token of a repository in the credentials file of the
+// home, replacing any other token it had, and sets its username if one is
+// given. The token is "-" to read it from stdin, and if it is empty, it is
+// asked for on a terminal.
+func LoginRepo(homedir, name, username, token string) {
+	defer lockConfig(homedir)()
+	cfg := mustConfig(homedir)
+
+	t := cfg.Repos.Lookup(name)
+	if t == nil {
+		log.Die("No repository named %s", name)
+	}
+	tok, err := readToken(token)
+	if err != nil {
+		log.Die("Could not read the token of %s: %s", name, err)
+	}
+	if t.Auth == nil {
+		t.Auth = &config.Auth{}
+	}
+	if username != "" {
+		t.Auth.Username = username
+	}
+	t.Auth.UseStoredToken(tok)
+	if err := storeToken(homedir, name, tok); err != nil {
+		log.Die("Could not store the token of %s: %s", name, err)
+	}
+	if err := cfg.Save(""); err != nil {
+		log.Die("Could not save configuration: %s", err)
+	}
+	log.Info("Stored the token of %s in %s", name, helmpath.Home(homedir).Credentials())
+}
+
+// LogoutRepo forgets the stored token of a repository.
+func LogoutRepo(homedir, name string) {
+	defer lockConfig(homedir)()
+	cfg := mustConfig(homedir)
+
+	t := cfg.Repos.Lookup(name)
+	if t == nil {
+		log.Die("No repository named %s", name)
+	}
+	removed, err := forgetToken(homedir, name)
+	if err != nil {
+		log.Die("Could not remove the token of %s: %s", name, err)
+	}
+	if t.Auth != nil && t.Auth.TokenStored {
+		t.Auth.ForgetStoredToken()
+		if *t.Auth == (config.Auth{}) {
+			t.Auth = nil
+		}
+		if err := cfg.Save(""); err != nil {
+			log.Die("Could not save configuration: %s", err)
+		}
+	} else if !removed {
+		log.Info("No token is stored for %s.", name)
+		return
+	}
+	log.Info("Removed the stored token of %s", name)
+}
+
+// storeToken writes the token of a repository to the credentials file.
+func storeToken(homedir, name, token string) error {
+	creds, err := config.LoadCredentials(homedir)
+	if err != nil {
+		return err
+	}
+	creds.Set(name, token)
+	return creds.Save()
+}
+
+// forgetToken removes the token of a repository from the credentials file,
+// and reports whether it had one.
+func forgetToken(homedir, name string) (bool, error) {
+	creds, err := config.LoadCredentials(homedir)
+	if err != nil || !creds.Remove(name) {
+		return false, err
+	}
+	return true, creds.Save()
+}
+
+// renameToken moves the stored token of a repository to its new name.
+func renameToken(homedir, oldName, newName string) error {
+	creds, err := config.LoadCredentials(homedir)
+	if err != nil || creds.Token(oldName) == "" {
+		return err
+	}
+	creds.Rename(oldName, newName)
+	return creds.Save()
+}
+
+// readToken returns token, or if it is "-", what stdin holds, or if it is
+// empty, what the user types on a terminal.
+func readToken(token string) (string, error) {
+	switch {
+	case token == "-":
+		b, err := ioutil.ReadAll(log.Stdin)
+		if err != nil {
+			return "", err
+		}
+		token = strings.TrimSpace(string(b))
+	case token == "" && stdinIsTerminal():
+		fmt.Fprint(log.Stdout, "Token: ")
+		b, err := terminal.ReadPassword(int(log.Stdin.(*os.File).Fd()))
+		fmt.Fprintln(log.Stdout)
+		if err != nil {
+			return "", err
+		}
+		token = strings.TrimSpace(string(b))
+	case token == "":
+		return "", errors.New("give it with --token, or '--token -' to read it from stdin")
+	}
+	if token == "" {
+		return "", errors.New("the token is empty")
+	}
+	return token, nil
+}
+
+// SetRepoPriority sets the priority used to resolve unqualified chart names.
+func SetRepoPriority(homedir, name string, priority int) {
+	defer lockConfig(homedir)()
+	cfg := mustConfig(homedir)
+
+	if err := cfg.Repos.SetPriority(name, priority); err != nil {
+		log.Die("%s", err)
+	}
+	if err := cfg.Save(""); err != nil {
+		log.Die("Could not save configuration: %s", err)
+	}
+}
+
+// SetRepoDepth switches a repository between a full and a shallow clone.
+//
+// The depth is either "full" or "shallow".
+func SetRepoDepth(homedir, name, depth string) {
+	if depth != "full" && depth != "shallow" {
+		log.Die("Depth must be 'full' or 'shallow', not %q", depth)
+	}
+	defer lockConfig(homedir)()
+	cfg := mustConfig(homedir)
+
+	if err := cfg.Repos.SetDepth(name, depth == "full"); err != nil {
+		log.Die("Could not change the depth of repository %s: %s", name, err)
+	}
+	if err := cfg.Save(""); err != nil {
+		log.Die("Could not save configuration: %s", err)
+	}
+
+	log.Info("Repository %s is now a %s clone", name, depth)
+}
+
+// SetRepoRef pins a repository to a branch or tag and checks it out. An
+// empty ref unpins it.
+func SetRepoRef(homedir, name, ref string) {
+	defer lockConfig(homedir)()
 	cfg := mustConfig(homedir)
 
+	if err := cfg.Repos.SetRef(name, ref); err != nil {
+		log.Die("Could not check out %s in repository %s: %s", ref, name, err)
+	}
+	if err := cfg.Save(""); err != nil {
+		log.Die("Checked out %s, but could not save settings: %s", ref, err)
+	}
+
+	if ref == "" {
+		log.Info("Repository %s now follows its default branch", name)
+		return
+	}
+	log.Info("Repository %s is now pinned to %s", name, ref)
+}
+
+// RenameRepo changes the local name of a repository.
+//
+// The cache is moved along with the configuration entry. If the new
+// configuration cannot be saved, the cache is moved back.
+func RenameRepo(homedir, oldName, newName string) {
+	defer lockConfig(homedir)()
+	cfg := mustConfig(homedir)
+
+	if err := cfg.Repos.Rename(oldName, newName); err != nil {
+		log.Die("Could not rename repository: %s", err)
+	}
+	if err := cfg.Save(""); err != nil {
+		if rerr := cfg.Repos.Rename(newName, oldName); rerr != nil {
+			log.Err("Could not restore cache for %s: %s", oldName, rerr)
+		}
+		log.Die("Could not save configuration: %s", err)
+	}
+
+	if oldName != newName {
+		if err := renameToken(homedir, oldName, newName); err != nil {
+			log.Err("Could not move the stored token of %s to %s: %s", oldName, newName, err)
+		}
+		log.Info("Renamed %s to %s", oldName, newName)
+	}
+}
+
+// DeleteRepo deletes a repository and its cached copy.
+//
+// Unless yes is true, the user is asked to confirm first. Workspace charts
+// that were fetched from the repository are listed, and if purgeCharts is
+// true, they are removed as well.
+func DeleteRepo(homedir, name string, yes, purgeCharts bool) {
+	defer lockConfig(homedir)()
+	cfg := mustConfig(homedir)
+
+	t := cfg.Repos.Lookup(name)
+	if t == nil {
+		log.Die("Failed to delete repository: No repository named %s", name)
+	}
+
+	if !yes && !Confirm("Remove repository %s and delete its cache in %s?", name, filepath.Join(cfg.Repos.Dir, name)) {
+		log.Info("Leaving repository %s in place.", name)
+		return
+	}
+
 	if err := cfg.Repos.Delete(name); err != nil {
 		log.Die("Failed to delete repository: %s", err)
 	}
 	if err := cfg.Save(""); err != nil {
 		log.Die("Deleted repo, but could not save settings: %s", err)
 	}
+	if _, err := forgetToken(homedir, name); err != nil {
+		log.Err("Could not remove the stored token of %s: %s", name, err)
+	}
+
+	charts := chartsFrom(homedir, t.Repo)
+	if len(charts) == 0 {
+		return
+	}
+	if !purgeCharts {
+		log.Warn("These workspace charts were fetched from %s:", name)
+		for _, c := range charts {
+			log.Msg("\t%s", c)
+		}
+		log.Info("Remove them with 'helmc remove', or use --purge-charts.")
+		return
+	}
+	for _, c := range charts {
+		unlock := lockChart(homedir, c)
+		err := os.RemoveAll(helm.WorkspaceChartDirectory(homedir, c))
+		unlock()
+		if err != nil {
+			log.Err("Could not remove %s: %s", c, err)
+			continue
+		}
+		log.Info("Removed %s from the workspace", c)
+	}
+}
+
+// chartsFrom returns the names of the workspace charts fetched from a repository URL.
+func chartsFrom(homedir, repoURL string) []string {
+	res := []string{}
+	dirs, _ := filepath.Glob(helm.WorkspaceChartDirectory(homedir, "*"))
+	for _, d := range dirs {
+		c, err := chart.LoadChartfile(filepath.Join(d, Chartfile))
+		if err != nil || c.From == nil {
+			continue
+		}
+		if c.From.Repo == repoURL {
+			res = append(res, filepath.Base(d))
+		}
+	}
+	return res
+}
+
+// IndexRepo writes an index.yaml for the chart archives in dir.
+//
+// If baseURL is set, archive URLs are absolute. If merge is true, the
+// versions in an existing index.yaml are kept, even if their archives are
+// not in dir. Archives that cannot be read are skipped with a warning. A
+// chart whose two newest versions are both deprecated is warned about.
+func IndexRepo(dir, baseURL string, merge bool) {
+	idx, skipped, err := repo.BuildIndex(dir, baseURL)
+	if err != nil {
+		log.Die("Could not index %s: %s", dir, err)
+	}
+
+	ifile := filepath.Join(dir, repo.IndexFile)
+	if merge {
+		old, err := repo.LoadIndex(ifile)
+		switch {
+		case err == nil:
+			idx.Merge(old)
+		case !os.IsNotExist(err):
+			log.Die("Could not merge with %s: %s", ifile, err)
+		}
+	}
+
+	if err := idx.Save(ifile); err != nil {
+		log.Die("Could not write %s: %s", ifile, err)
+	}
+
+	n := 0
+	for _, versions := range idx.Entries {
+		n += len(versions)
+	}
+	log.Info("Wrote %s with %d versions of %d charts", ifile, n, len(idx.Entries))
+	names := make([]string, 0, len(idx.Entries))
+	for name := range idx.Entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if v := idx.Entries[name]; len(v) > 1 && v[0].Deprecated && v[1].Deprecated {
+			warnStillDeprecated(name, v[0].Version, v[1].Version)
+		}
+	}
+	if len(skipped) > 0 {
+		log.Warn("Skipped %d archives that could not be read:", len(skipped))
+		for _, s := range skipped {
+			log.Msg("\t%s", s)
+		}
+	}
 }
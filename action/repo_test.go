@@ -1,10 +1,17 @@
 package action
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/helm/helm-classic/chart"
+	"github.com/helm/helm-classic/config"
 	"github.com/helm/helm-classic/log"
 	"github.com/helm/helm-classic/test"
+	helm "github.com/helm/helm-classic/util"
 )
 
 func TestListRepos(t *testing.T) {
@@ -14,8 +21,158 @@ func TestListRepos(t *testing.T) {
 	test.FakeUpdate(homedir)
 
 	actual := test.CaptureOutput(func() {
-		ListRepos(homedir)
+		ListRepos(homedir, "")
 	})
 
 	test.ExpectContains(t, actual, "charts*\thttps://github.com/helm/charts")
 }
+
+func TestRenameRepo(t *testing.T) {
+	log.IsDebugging = true
+
+	homedir := test.CreateTmpHome()
+	test.FakeUpdate(homedir)
+
+	RenameRepo(homedir, "charts", "stable")
+
+	actual := test.CaptureOutput(func() {
+		ListRepos(homedir, "")
+	})
+	test.ExpectContains(t, actual, "stable*\thttps://github.com/helm/charts")
+
+	if _, err := os.Stat(filepath.Join(homedir, "cache", "stable", "kitchensink")); err != nil {
+		t.Errorf("Expected cache to be moved: %s", err)
+	}
+}
+
+func TestListReposFormatted(t *testing.T) {
+	log.IsDebugging = true
+
+	homedir := test.CreateTmpHome()
+	test.FakeUpdate(homedir)
+
+	actual := test.CaptureOutput(func() {
+		ListRepos(homedir, "json")
+	})
+	test.ExpectContains(t, actual, `"name": "charts"`)
+	test.ExpectContains(t, actual, `"type": "git"`)
+	test.ExpectContains(t, actual, `"default": true`)
+	test.ExpectContains(t, actual, `"path": "`+filepath.Join(homedir, "cache", "charts")+`"`)
+
+	actual = test.CaptureOutput(func() {
+		ListRepos(homedir, "yaml")
+	})
+	test.ExpectContains(t, actual, "- name: charts\n  url: https://github.com/helm/charts")
+}
+
+func TestAddRepoValidation(t *testing.T) {
+	log.IsDebugging = true
+
+	homedir := test.CreateTmpHome()
+	test.FakeUpdate(homedir)
+
+	actual := test.CaptureOutput(func() {
+		AddRepo(homedir, &config.Table{Name: "bogus", Repo: "github.com/helm/charts"}, "", true, false)
+	})
+	test.ExpectContains(t, actual, "use --no-validate")
+
+	actual = test.CaptureOutput(func() {
+		AddRepo(homedir, &config.Table{Name: "again", Repo: "https://github.com/helm/charts"}, "", true, false)
+	})
+	test.ExpectContains(t, actual, "Remote charts already points to https://github.com/helm/charts")
+}
+
+func TestDeleteRepo(t *testing.T) {
+	log.IsDebugging = true
+
+	homedir := test.CreateTmpHome()
+	test.FakeUpdate(homedir)
+
+	fetched := helm.WorkspaceChartDirectory(homedir, "myredis")
+	os.MkdirAll(fetched, 0755)
+	c := &chart.Chartfile{Name: "myredis", From: &chart.Dependency{Name: "redis", Repo: "https://github.com/helm/charts"}}
+	if err := c.Save(filepath.Join(fetched, Chartfile)); err != nil {
+		t.Fatal(err)
+	}
+
+	log.Stdin = strings.NewReader("n\n")
+	defer func() { log.Stdin = os.Stdin }()
+	test.CaptureOutput(func() {
+		DeleteRepo(homedir, "charts", false, false)
+	})
+	if _, err := os.Stat(filepath.Join(homedir, "cache", "charts")); err != nil {
+		t.Fatalf("Expected declining to keep the cache: %s", err)
+	}
+
+	actual := test.CaptureOutput(func() {
+		DeleteRepo(homedir, "charts", true, false)
+	})
+	test.ExpectContains(t, actual, "myredis")
+	if _, err := os.Stat(filepath.Join(homedir, "cache", "charts")); err == nil {
+		t.Errorf("Expected the cache to be deleted")
+	}
+	if _, err := os.Stat(fetched); err != nil {
+		t.Errorf("Expected fetched chart to be kept without --purge-charts")
+	}
+}
+
+func TestDeleteRepoPurgeCharts(t *testing.T) {
+	log.IsDebugging = true
+
+	homedir := test.CreateTmpHome()
+	test.FakeUpdate(homedir)
+
+	fetched := helm.WorkspaceChartDirectory(homedir, "myredis")
+	os.MkdirAll(fetched, 0755)
+	c := &chart.Chartfile{Name: "myredis", From: &chart.Dependency{Name: "redis", Repo: "https://github.com/helm/charts"}}
+	c.Save(filepath.Join(fetched, Chartfile))
+
+	// A missing cache is not an error.
+	os.RemoveAll(filepath.Join(homedir, "cache", "charts"))
+
+	test.CaptureOutput(func() {
+		DeleteRepo(homedir, "charts", true, true)
+	})
+	if _, err := os.Stat(fetched); err == nil {
+		t.Errorf("Expected fetched chart to be purged")
+	}
+}
+
+func TestLoginRepo(t *testing.T) {
+	homedir := test.CreateTmpHome()
+	defer os.RemoveAll(homedir)
+	test.FakeUpdate(homedir)
+
+	test.CaptureOutput(func() {
+		LoginRepo(homedir, "charts", "bot", "s3cret")
+	})
+	cfg := mustConfig(homedir)
+	a := cfg.Repos.Lookup("charts").Auth
+	if a == nil || !a.TokenStored || a.Username != "bot" {
+		t.Fatalf("Expected a stored token for bot, got %v", a)
+	}
+	if data, _ := ioutil.ReadFile(filepath.Join(homedir, helm.Configfile)); strings.Contains(string(data), "s3cret") {
+		t.Errorf("Expected the token to be kept out of the configuration file")
+	}
+	fi, err := os.Stat(filepath.Join(homedir, "credentials.yaml"))
+	if err != nil || fi.Mode().Perm() != 0600 {
+		t.Errorf("Expected a credentials file only its owner can read, got %v (%v)", fi, err)
+	}
+
+	RenameRepo(homedir, "charts", "stable")
+	creds, err := config.LoadCredentials(homedir)
+	if err != nil || creds.Token("stable") != "s3cret" || creds.Token("charts") != "" {
+		t.Errorf("Expected the token to move with the repository, got %v (%v)", creds, err)
+	}
+
+	test.CaptureOutput(func() {
+		LogoutRepo(homedir, "stable")
+	})
+	cfg = mustConfig(homedir)
+	if a := cfg.Repos.Lookup("stable").Auth; a != nil && a.TokenStored {
+		t.Errorf("Expected the stored token to be forgotten, got %v", a)
+	}
+	if creds, _ := config.LoadCredentials(homedir); creds.Token("stable") != "" {
+		t.Errorf("Expected the credentials file to lose the token")
+	}
+}
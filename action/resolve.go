@@ -0,0 +1,257 @@
+package action
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	helmerrors "github.com/helm/helm-classic/errors"
+	helm "github.com/helm/helm-classic/util"
+)
+
+// Where a chart argument was resolved.
+const (
+	// ChartInPath is a chart directory outside the workspace.
+	ChartInPath = "path"
+	// ChartInWorkspace is a chart of the workspace.
+	ChartInWorkspace = "workspace"
+	// ChartInCache is a chart of a repository, in its local cache.
+	ChartInCache = "cache"
+	// ChartInRepo is a chart of a repository that is not in its cache, such
+	// as one of the default repository before an update.
+	ChartInRepo = "repo"
+	// ChartInArchive is a chart archive, as 'helmc package' writes it.
+	ChartInArchive = "archive"
+)
+
+// ChartLookup says where a command looks for the chart that its argument
+// names.
+type ChartLookup struct {
+	// Path allows chart directories outside the workspace. A directory in
+	// the workspace is always its chart.
+	Path bool
+	// Workspace allows the charts of the workspace.
+	Workspace bool
+	// Repos allows the charts of the repositories.
+	Repos bool
+	// Archive allows chart archives, which are expanded into the workspace.
+	Archive bool
+}
+
+// describe says what a lookup allows, for errors.
+func (l ChartLookup) describe() string {
+	var what []string
+	if l.Path {
+		what = append(what, "a chart directory")
+	}
+	if l.Workspace {
+		what = append(what, "the name of a chart of your workspace")
+	}
+	if l.Repos {
+		what = append(what, "the name of a chart of a repository, such as charts/redis")
+	}
+	if l.Archive {
+		what = append(what, "a chart archive, such as redis-0.2.0.tgz")
+	}
+	if len(what) == 1 {
+		return what[0]
+	}
+	return strings.Join(what[:len(what)-1], ", ") + ", or " + what[len(what)-1]
+}
+
+// ChartRef is what a chart argument was resolved to.
+type ChartRef struct {
+	// Source is where the chart is: one of the ChartIn constants.
+	Source string
+	// Name is what the actions take for the chart: its name in the workspace,
+	// or for a chart of a repository or an archive, the name as it was given.
+	Name string
+	// Dir is the chart's directory, if it has one.
+	Dir string
+	// Repo is the repository of a chart of the cache or of a repository.
+	Repo string
+}
+
+// ResolveChart resolves the chart argument of a command.
+//
+// An argument with an explicit path marker, an absolute path or one that
+// starts with './' or '../', is always a chart directory: the chart of the
+// workspace if it is one of its directories, and otherwise a ChartInPath,
+// which only l.Path allows. A bare name is looked up in the workspace, then
+// in the caches of the repositories, and then is taken to be a chart of a
+// repository, in that order, as l allows. With l.Path, a bare name is also
+// a chart directory if it has a Chart.yaml.
+//
+// A bare name that is both a chart directory and a chart of the workspace or
+// of a cache is an *helmerrors.AmbiguousChartError, which lists how to give
+// each one. An unqualified name that is in the caches of several
+// repositories is left to the action, which reports it as
+// config.Repos.Resolve does, or lets the user choose. Each step is logged at
+// debug level.
+//
+// An existing file whose name ends in .tgz is a ChartInArchive, which only
+// l.Archive allows.
+//
+// A bare name that is none of these resolves to the workspace chart of the
+// name, or failing that, the chart of the default repository, so that the
+// action reports that it is missing in its own words.
+func (c *Client) ResolveChart(arg string, l ChartLookup) (*ChartRef, error) {
+	c.Log.Debug("Resolving chart %q", arg)
+	if isChartArchive(arg) {
+		if !l.Archive {
+			return nil, fmt.Errorf("%s is a chart archive. Give %s instead.", arg, l.describe())
+		}
+		c.Log.Debug("%s is a chart archive", arg)
+		return &ChartRef{Source: ChartInArchive, Name: arg}, nil
+	}
+	if isChartPath(arg) {
+		c.Log.Debug("%s has a path marker, so it is a chart directory", arg)
+		return c.resolvePath(arg, l)
+	}
+
+	var dir *ChartRef
+	if l.Path {
+		if fi, err := os.Stat(filepath.Join(arg, Chartfile)); err == nil && !fi.IsDir() {
+			c.Log.Debug("%s is a chart directory", arg)
+			dir = &ChartRef{Source: ChartInPath, Name: filepath.Clean(arg), Dir: filepath.Clean(arg)}
+		} else {
+			c.Log.Debug("%s is not a chart directory", arg)
+		}
+	}
+	ref := c.resolveName(arg, l)
+	switch {
+	case dir == nil && ref == nil:
+		return c.fallback(arg, l), nil
+	case ref == nil:
+		return dir, nil
+	case dir == nil, ref.Dir != "" && sameDir(dir.Dir, ref.Dir):
+		return ref, nil
+	}
+
+	e := &helmerrors.AmbiguousChartError{
+		Name:       arg,
+		Candidates: []string{"." + string(filepath.Separator) + arg},
+		Meanings:   []string{"the chart directory " + dir.Dir},
+	}
+	if ref.Source == ChartInWorkspace {
+		e.Candidates = append(e.Candidates, ref.Dir)
+		e.Meanings = append(e.Meanings, "the chart "+ref.Name+" of your workspace")
+	} else {
+		e.Candidates = append(e.Candidates, ref.Repo+"/"+ref.Name)
+		e.Meanings = append(e.Meanings, "the chart "+ref.Name+" of the repository "+ref.Repo)
+	}
+	return nil, e
+}
+
+// ResolveChart resolves the chart argument of a command. See
+// Client.ResolveChart.
+func ResolveChart(arg, home string, l ChartLookup) (*ChartRef, error) {
+	return newClient(home, nil).ResolveChart(arg, l)
+}
+
+// isChartPath returns true if a chart argument has an explicit path marker.
+func isChartPath(arg string) bool {
+	if arg == "." || arg == ".." || filepath.IsAbs(arg) {
+		return true
+	}
+	for _, sep := range []string{"/", string(filepath.Separator)} {
+		if strings.HasPrefix(arg, "."+sep) || strings.HasPrefix(arg, ".."+sep) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolvePath resolves a chart argument with a path marker. A directory of
+// the workspace is its chart.
+func (c *Client) resolvePath(arg string, l ChartLookup) (*ChartRef, error) {
+	abs, err := filepath.Abs(arg)
+	if err != nil {
+		return nil, err
+	}
+	workspace := helm.WorkspaceChartDirectory(c.Home)
+	if rel, ok := within(workspace, abs); ok {
+		c.Log.Debug("%s is the chart %s of your workspace", arg, rel)
+		return &ChartRef{Source: ChartInWorkspace, Name: rel, Dir: helm.WorkspaceChartDirectory(c.Home, rel)}, nil
+	}
+	if !l.Path {
+		return nil, fmt.Errorf("%s is a directory outside your workspace, %s. Give %s instead.", arg, workspace, l.describe())
+	}
+	return &ChartRef{Source: ChartInPath, Name: filepath.Clean(arg), Dir: filepath.Clean(arg)}, nil
+}
+
+// within returns the name of the top directory of dir that path is in, if it
+// is below dir. Symbolic links are followed, so that a home that is reached
+// through one still matches.
+func within(dir, path string) (string, bool) {
+	if d, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = d
+	}
+	if p, err := filepath.EvalSymlinks(path); err == nil {
+		path = p
+	}
+	rel, err := filepath.Rel(dir, path)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return strings.Split(rel, string(filepath.Separator))[0], true
+}
+
+// sameDir returns true if two paths are the same directory.
+func sameDir(a, b string) bool {
+	fa, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	fb, err := os.Stat(b)
+	return err == nil && os.SameFile(fa, fb)
+}
+
+// resolveName looks a bare name up in the workspace and then in the caches.
+// It returns nil if neither has it.
+func (c *Client) resolveName(arg string, l ChartLookup) *ChartRef {
+	if l.Workspace && !strings.Contains(arg, "/") {
+		if chartFetched(arg, c.Home, c.Log) {
+			c.Log.Debug("%s is a chart of your workspace", arg)
+			return &ChartRef{Source: ChartInWorkspace, Name: arg, Dir: helm.WorkspaceChartDirectory(c.Home, arg)}
+		}
+		c.Log.Debug("%s is not a chart of your workspace", arg)
+	}
+	if !l.Repos {
+		return nil
+	}
+	cfg, err := c.config()
+	if err != nil {
+		c.Log.Debug("Not looking in the caches: %s", err)
+		return nil
+	}
+	repo, name, err := cfg.Repos.Resolve(arg)
+	if err != nil {
+		// The action lists the candidates, or lets the user choose one.
+		c.Log.Debug("%s", err)
+		return nil
+	}
+	for _, r := range cfg.Repos.Candidates(name) {
+		if r == repo {
+			c.Log.Debug("%s is the chart %s of the repository %s, in its cache", arg, name, repo)
+			return &ChartRef{Source: ChartInCache, Name: arg, Dir: filepath.Join(cfg.Repos.Dir, repo, name), Repo: repo}
+		}
+	}
+	c.Log.Debug("The cache of %s does not have %s", repo, name)
+	return nil
+}
+
+// fallback is what a bare name that was not found resolves to.
+func (c *Client) fallback(arg string, l ChartLookup) *ChartRef {
+	if l.Workspace && !l.Repos {
+		c.Log.Debug("Taking %s to be a chart of your workspace", arg)
+		return &ChartRef{Source: ChartInWorkspace, Name: arg, Dir: helm.WorkspaceChartDirectory(c.Home, arg)}
+	}
+	ref := &ChartRef{Source: ChartInRepo, Name: arg}
+	if cfg, err := c.config(); err == nil {
+		ref.Repo, _ = cfg.Repos.RepoChart(arg)
+	}
+	c.Log.Debug("Taking %s to be a chart of the repository %s", arg, ref.Repo)
+	return ref
+}
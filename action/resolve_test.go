@@ -0,0 +1,111 @@
+package action
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	helmerrors "github.com/helm/helm-classic/errors"
+	"github.com/helm/helm-classic/log"
+	"github.com/helm/helm-classic/test"
+	"github.com/helm/helm-classic/util"
+)
+
+func TestResolveChart(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+	test.FakeUpdate(tmpHome)
+	test.CaptureOutput(func() {
+		Fetch("redis", "", tmpHome, FetchOptions{})
+	})
+
+	// The working directory has chart directories named like a chart of
+	// the workspace, like a chart of the cache, and like neither.
+	dir, err := ioutil.TempDir("", "helmc-resolve-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"redis", "kitchensink", "local"} {
+		os.MkdirAll(filepath.Join(dir, name), 0755)
+		ioutil.WriteFile(filepath.Join(dir, name, Chartfile), []byte("name: "+name+"\nversion: 0.1.0\n"), 0644)
+	}
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	os.Chdir(dir)
+
+	var (
+		lint    = ChartLookup{Path: true, Workspace: true}
+		install = ChartLookup{Workspace: true, Repos: true}
+		fetch   = ChartLookup{Repos: true}
+		every   = ChartLookup{Path: true, Workspace: true, Repos: true}
+	)
+	workspaceRedis := util.WorkspaceChartDirectory(tmpHome, "redis")
+	tests := []struct {
+		arg    string
+		lookup ChartLookup
+		source string
+		name   string
+		err    string
+	}{
+		// Path markers always mean a directory.
+		{"./redis", lint, ChartInPath, "redis", ""},
+		{filepath.Join(dir, "local"), lint, ChartInPath, filepath.Join(dir, "local"), ""},
+		{workspaceRedis, lint, ChartInWorkspace, "redis", ""},
+		{workspaceRedis, install, ChartInWorkspace, "redis", ""},
+		{"./local", install, "", "", "directory outside your workspace"},
+		{"./local", fetch, "", "", "Give the name of a chart of a repository"},
+
+		// Bare names are directories only where the command takes them.
+		{"local", lint, ChartInPath, "local", ""},
+		{"kitchensink", lint, ChartInPath, "kitchensink", ""},
+		{"redis", lint, "", "", "./redis"},
+		{"redis", install, ChartInWorkspace, "redis", ""},
+		{"kitchensink", every, "", "", "charts/kitchensink"},
+
+		// Then the workspace, the caches, and the repositories.
+		{"kitchensink", install, ChartInCache, "kitchensink", ""},
+		{"charts/kitchensink", install, ChartInCache, "charts/kitchensink", ""},
+		{"redis", fetch, ChartInCache, "redis", ""},
+		{"missing", install, ChartInRepo, "missing", ""},
+		{"missing", lint, ChartInWorkspace, "missing", ""},
+	}
+	for _, tt := range tests {
+		ref, err := ResolveChart(tt.arg, tmpHome, tt.lookup)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s, %+v: expected an error with %q, got %v", tt.arg, tt.lookup, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s, %+v: %s", tt.arg, tt.lookup, err)
+			continue
+		}
+		if ref.Source != tt.source || ref.Name != tt.name {
+			t.Errorf("%s, %+v: expected %s %s, got %s %s", tt.arg, tt.lookup, tt.source, tt.name, ref.Source, ref.Name)
+		}
+	}
+
+	// An ambiguous name says how to give each chart, and each way resolves.
+	_, err = ResolveChart("redis", tmpHome, lint)
+	if !errors.Is(err, helmerrors.ErrAmbiguousChart) {
+		t.Fatalf("Expected an ambiguous chart, got %v", err)
+	}
+	for _, c := range err.(*helmerrors.AmbiguousChartError).Candidates {
+		if _, err := ResolveChart(c, tmpHome, lint); err != nil {
+			t.Errorf("Expected %s to resolve, got %s", c, err)
+		}
+	}
+
+	var out bytes.Buffer
+	c := newClient(tmpHome, nil)
+	c.Log = &log.Logger{Stderr: &out, Debugging: true}
+	c.ResolveChart("kitchensink", install)
+	test.ExpectContains(t, out.String(), "kitchensink is not a chart of your workspace")
+	test.ExpectContains(t, out.String(), "kitchensink is the chart kitchensink of the repository charts, in its cache")
+}
@@ -0,0 +1,280 @@
+package action
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/helm/helm-classic/helmpath"
+)
+
+// scaffoldReadme is the README.md of the built-in scaffolds.
+const scaffoldReadme = `# [[.Name]]
+
+Describe your chart here. Link to upstream repositories, Docker images or any
+external documentation.
+
+## Configuration
+
+The manifests are generated from the templates in tpl/ with the values of
+values.yaml. Change the values, or the templates, and regenerate them:
+
+    helmc generate --force [[.Name]]
+
+or give other values when installing:
+
+    helmc install --generate --set image.tag=latest [[.Name]]
+`
+
+// scaffoldEmptyValues is the values.yaml of the empty scaffold.
+const scaffoldEmptyValues = `# The values of the templates of [[.Name]], as {{.Values.NAME}}.
+`
+
+// scaffoldDeploymentValues is the values.yaml of the deployment scaffold.
+const scaffoldDeploymentValues = `# The values of the templates of [[.Name]], as {{.Values.NAME}}.
+replicas: 1
+image:
+  repository: nginx
+  tag: "1.9"
+port: 80
+resources:
+  cpu: 100m
+  memory: 64Mi
+`
+
+// scaffoldDeployment is the Deployment template of the deployment scaffold.
+const scaffoldDeployment = `#helm:generate helmc tpl -o manifests/deployment.yaml tpl/deployment.yaml
+apiVersion: extensions/v1beta1
+kind: Deployment
+metadata:
+  name: {{.Release.Name}}
+  labels:
+    heritage: helm
+    app: {{.Release.Name}}
+spec:
+  replicas: {{.Values.replicas}}
+  template:
+    metadata:
+      labels:
+        app: {{.Release.Name}}
+    spec:
+      containers:
+      - name: {{.Release.Name}}
+        image: "{{.Values.image.repository}}:{{.Values.image.tag}}"
+        ports:
+        - containerPort: {{.Values.port}}
+          name: http
+        resources:
+          requests:
+            cpu: {{.Values.resources.cpu}}
+            memory: {{.Values.resources.memory}}
+`
+
+// scaffoldService is the Service template of the deployment scaffold.
+const scaffoldService = `#helm:generate helmc tpl -o manifests/service.yaml tpl/service.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: {{.Release.Name}}
+  labels:
+    heritage: helm
+    app: {{.Release.Name}}
+spec:
+  selector:
+    app: {{.Release.Name}}
+  ports:
+  - port: {{.Values.port}}
+    targetPort: http
+`
+
+// scaffoldDaemonSetValues is the values.yaml of the daemonset scaffold.
+const scaffoldDaemonSetValues = `# The values of the templates of [[.Name]], as {{.Values.NAME}}.
+image:
+  repository: alpine
+  tag: "3.2"
+command: ["/bin/sleep", "9000"]
+resources:
+  cpu: 10m
+  memory: 16Mi
+`
+
+// scaffoldDaemonSet is the DaemonSet template of the daemonset scaffold.
+const scaffoldDaemonSet = `#helm:generate helmc tpl -o manifests/daemonset.yaml tpl/daemonset.yaml
+apiVersion: extensions/v1beta1
+kind: DaemonSet
+metadata:
+  name: {{.Release.Name}}
+  labels:
+    heritage: helm
+    app: {{.Release.Name}}
+spec:
+  template:
+    metadata:
+      labels:
+        app: {{.Release.Name}}
+    spec:
+      containers:
+      - name: {{.Release.Name}}
+        image: "{{.Values.image.repository}}:{{.Values.image.tag}}"
+        command: {{toJson .Values.command}}
+        resources:
+          requests:
+            cpu: {{.Values.resources.cpu}}
+            memory: {{.Values.resources.memory}}
+`
+
+// scaffoldCronJobValues is the values.yaml of the cronjob scaffold.
+const scaffoldCronJobValues = `# The values of the templates of [[.Name]], as {{.Values.NAME}}.
+schedule: "0 3 * * *"
+image:
+  repository: alpine
+  tag: "3.2"
+command: ["/bin/echo", "hello"]
+resources:
+  cpu: 10m
+  memory: 16Mi
+`
+
+// scaffoldCronJob is the CronJob template of the cronjob scaffold.
+const scaffoldCronJob = `#helm:generate helmc tpl -o manifests/cronjob.yaml tpl/cronjob.yaml
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: {{.Release.Name}}
+  labels:
+    heritage: helm
+    app: {{.Release.Name}}
+spec:
+  schedule: {{quote .Values.schedule}}
+  jobTemplate:
+    spec:
+      template:
+        metadata:
+          labels:
+            app: {{.Release.Name}}
+        spec:
+          restartPolicy: OnFailure
+          containers:
+          - name: {{.Release.Name}}
+            image: "{{.Values.image.repository}}:{{.Values.image.tag}}"
+            command: {{toJson .Values.command}}
+            resources:
+              requests:
+                cpu: {{.Values.resources.cpu}}
+                memory: {{.Values.resources.memory}}
+`
+
+// builtinScaffolds are the scaffolds that 'helmc create --from' can
+// generate a chart from. Each is a starter whose files are templates; see
+// loadScaffold.
+var builtinScaffolds = map[string]builtinStarter{
+	"empty": {
+		description: "A chart with no manifests.",
+		files: map[string]string{
+			"README.md":   scaffoldReadme,
+			"values.yaml": scaffoldEmptyValues,
+		},
+	},
+	"deployment": {
+		description: "A Deployment behind a Service.",
+		files: map[string]string{
+			"README.md":           scaffoldReadme,
+			"values.yaml":         scaffoldDeploymentValues,
+			"tpl/deployment.yaml": scaffoldDeployment,
+			"tpl/service.yaml":    scaffoldService,
+		},
+	},
+	"daemonset": {
+		description: "A DaemonSet that runs a Pod on every node.",
+		files: map[string]string{
+			"README.md":          scaffoldReadme,
+			"values.yaml":        scaffoldDaemonSetValues,
+			"tpl/daemonset.yaml": scaffoldDaemonSet,
+		},
+	},
+	"cronjob": {
+		description: "A CronJob that runs a Pod on a schedule.",
+		files: map[string]string{
+			"README.md":        scaffoldReadme,
+			"values.yaml":      scaffoldCronJobValues,
+			"tpl/cronjob.yaml": scaffoldCronJob,
+		},
+	},
+}
+
+// scaffoldData is what the files of a scaffold are rendered with.
+type scaffoldData struct {
+	// Name is the name of the new chart.
+	Name string
+}
+
+// loadScaffold returns the files of the named scaffold for the chart
+// chartName, by their slash-separated paths relative to the chart.
+//
+// A scaffold is one of the built-in scaffolds, whose files are replaced by,
+// or added to, those of the directory of the same name in the scaffolds
+// directory of the home. A directory there with another name is a scaffold
+// of its own. Each file is a Go template with the delimiters [[ and ]], so
+// that it can hold the templates of 'helmc tpl', and is rendered with
+// scaffoldData.
+func loadScaffold(homedir, name, chartName string) (map[string]string, error) {
+	if name == "" || strings.HasPrefix(name, ".") || strings.ContainsAny(name, `/\`) {
+		return nil, fmt.Errorf("Invalid scaffold name %q", name)
+	}
+	files := map[string]string{}
+	b, builtin := builtinScaffolds[name]
+	for p, content := range b.files {
+		files[p] = content
+	}
+	dir := helmpath.Home(homedir).Scaffolds(name)
+	if fi, err := os.Stat(dir); err == nil && fi.IsDir() {
+		own, err := readStarterDir(dir)
+		if err != nil {
+			return nil, fmt.Errorf("Could not read scaffold %s: %s", name, err)
+		}
+		for p, content := range own {
+			files[p] = content
+		}
+	} else if !builtin {
+		return nil, fmt.Errorf("Unknown scaffold %q. Available scaffolds: %s", name, strings.Join(scaffolds(homedir), ", "))
+	}
+
+	data := scaffoldData{Name: chartName}
+	for p, content := range files {
+		t, err := template.New(p).Delims("[[", "]]").Option("missingkey=error").Parse(content)
+		if err != nil {
+			return nil, fmt.Errorf("Could not parse %s of scaffold %s: %s", p, name, err)
+		}
+		var out bytes.Buffer
+		if err := t.Execute(&out, data); err != nil {
+			return nil, fmt.Errorf("Could not render %s of scaffold %s: %s", p, name, err)
+		}
+		files[p] = out.String()
+	}
+	return files, nil
+}
+
+// scaffolds returns the names of the available scaffolds, sorted.
+func scaffolds(homedir string) []string {
+	found := map[string]bool{}
+	for name := range builtinScaffolds {
+		found[name] = true
+	}
+	if fis, err := ioutil.ReadDir(helmpath.Home(homedir).Scaffolds()); err == nil {
+		for _, fi := range fis {
+			if fi.IsDir() && !strings.HasPrefix(fi.Name(), ".") {
+				found[fi.Name()] = true
+			}
+		}
+	}
+	names := make([]string, 0, len(found))
+	for name := range found {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -2,30 +2,83 @@ package action
 
 import (
 	"github.com/helm/helm-classic/log"
+	"github.com/helm/helm-classic/output"
 	"github.com/helm/helm-classic/search"
 	helm "github.com/helm/helm-classic/util"
 )
 
-// Search looks for packages with 'term' in their name.
-func Search(term, homedir string, regexp bool) {
+// SearchOptions control how Search matches charts, and prints them.
+type SearchOptions struct {
+	// Regexp treats the term as a regular expression, rather than a
+	// substring.
+	Regexp bool
+	// Filter keeps only the charts with its keywords and maintainers.
+	Filter search.Filter
+	// Output is "json", "yaml", or "" for one line per chart.
+	Output string
+}
+
+// searchResult is a match of Search, as it is printed as JSON or YAML.
+type searchResult struct {
+	Name        string   `json:"name" yaml:"name"`
+	Version     string   `json:"version,omitempty" yaml:"version,omitempty"`
+	Description string   `json:"description,omitempty" yaml:"description,omitempty"`
+	Keywords    []string `json:"keywords,omitempty" yaml:"keywords,omitempty"`
+	Maintainers []string `json:"maintainers,omitempty" yaml:"maintainers,omitempty"`
+	Deprecated  bool     `json:"deprecated,omitempty" yaml:"deprecated,omitempty"`
+	// Score is the field that term matched in, as search.MaxScore lists
+	// them: 0 for the name.
+	Score int `json:"score" yaml:"score"`
+}
+
+// Search looks for packages with 'term' in their name, path, description,
+// details, keywords, or maintainers, and whose charts match o.Filter.
+//
+// On a terminal, descriptions are cut to fit its width.
+func Search(term, homedir string, o SearchOptions) {
 	cfg := mustConfig(homedir)
 	cdir := helm.CacheDirectory(homedir)
 
 	i := search.NewIndex(cfg, cdir)
-	res, err := i.Search(term, 5, regexp)
+	res, err := i.Search(term, search.MaxScore+1, o.Regexp)
 	if err != nil {
 		log.Die("Failed to search: %s", err)
 	}
+	res = i.Filter(res, o.Filter)
+	search.SortPriority(res)
+
+	if o.Output != "" {
+		out := []*searchResult{}
+		for _, r := range res {
+			c, _ := i.Chart(r.Name)
+			out = append(out, &searchResult{
+				Name:        r.Name,
+				Version:     c.Version,
+				Description: c.Description,
+				Keywords:    c.Keywords,
+				Maintainers: c.Maintainers,
+				Deprecated:  c.Deprecated,
+				Score:       r.Score,
+			})
+		}
+		if err := printFormatted(out, o.Output); err != nil {
+			log.Die("%s", err)
+		}
+		return
+	}
 
 	if len(res) == 0 {
 		log.Err("No results found. Try using '--regexp'.")
 		return
 	}
 
-	search.SortScore(res)
-
+	p := output.New(log.Stdout)
 	for _, r := range res {
 		c, _ := i.Chart(r.Name)
-		log.Msg("%s - %s", r.Name, c.Description)
+		head := r.Name + " - "
+		if c.Deprecated {
+			head = r.Name + " (DEPRECATED) - "
+		}
+		log.Msg("%s%s", head, p.Fit(c.Description, output.Width(head)))
 	}
 }
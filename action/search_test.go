@@ -1,8 +1,10 @@
 package action
 
 import (
+	"encoding/json"
 	"testing"
 
+	"github.com/helm/helm-classic/search"
 	"github.com/helm/helm-classic/test"
 )
 
@@ -10,7 +12,7 @@ func TestSearch(t *testing.T) {
 	tmpHome := test.CreateTmpHome()
 	test.FakeUpdate(tmpHome)
 
-	Search("homeslice", tmpHome, false)
+	Search("homeslice", tmpHome, SearchOptions{})
 }
 
 func TestSearchNotFound(t *testing.T) {
@@ -21,8 +23,24 @@ func TestSearchNotFound(t *testing.T) {
 	expected := "No results found"
 
 	actual := test.CaptureOutput(func() {
-		Search("nonexistent", tmpHome, false)
+		Search("nonexistent", tmpHome, SearchOptions{})
 	})
 
 	test.ExpectContains(t, actual, expected)
 }
+
+func TestSearchJSON(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	test.FakeUpdate(tmpHome)
+
+	actual := test.CaptureOutput(func() {
+		Search("", tmpHome, SearchOptions{Filter: search.Filter{Maintainers: []string{"gabe@deis.com"}}, Output: "json"})
+	})
+	var res []*searchResult
+	if err := json.Unmarshal([]byte(actual), &res); err != nil {
+		t.Fatalf("Expected JSON, got %s: %s", err, actual)
+	}
+	if len(res) != 1 || res[0].Name != "redis-standalone" || res[0].Version != "0.0.1" || len(res[0].Maintainers) != 1 {
+		t.Errorf("Expected only redis-standalone, got %s", actual)
+	}
+}
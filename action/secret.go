@@ -0,0 +1,207 @@
+package action
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/helm/helm-classic/log"
+)
+
+// SecretOptions control what CreateSecret puts in a Secret.
+type SecretOptions struct {
+	// Namespace is the namespace of the Secret. If it is empty, it is left out.
+	Namespace string
+	// Type is the type of the Secret. If it is empty, it is Opaque.
+	Type string
+	// From are KEY=SOURCE specs, as those of ValueSources.SetFrom, whose KEY
+	// is a key of the Secret rather than a dotted path.
+	From []string
+	// FromFiles are files, each the value of the key of its base name, or of
+	// KEY as in KEY=PATH.
+	FromFiles []string
+	// EnvFiles are files of KEY=VALUE lines, each a key of the Secret. Blank
+	// lines, and those that begin with #, are skipped.
+	EnvFiles []string
+	// Out is the file that the Secret is written to. If it is empty, it is
+	// printed.
+	Out string
+	// Force overwrites Out if it exists.
+	Force bool
+	// AllowExec allows the sources of From that run a command, as
+	// ValueSources.AllowExec does.
+	AllowExec bool
+}
+
+// secretKey matches the keys that a Secret may have.
+var secretKey = regexp.MustCompile(`^[-._a-zA-Z0-9]+$`)
+
+// secretHeader is the comment of a written Secret.
+const secretHeader = `# Generated by 'helmc secret create'. It holds secrets: do not commit it.
+`
+
+// CreateSecret writes a Secret manifest named name, with the values of
+// o.From, o.FromFiles, and o.EnvFiles, base64-encoded, so that the secrets of
+// a chart are read when it is generated, rather than kept in it.
+//
+// The sources of o.From are those of the values of templates (see
+// ValueSources). As there, the sources that run a command, such as a password
+// store entry with pass:NAME, or a SOPS-encrypted file with sops:PATH, are
+// only allowed with o.AllowExec, or when a generator runs CreateSecret for a
+// generate that the user gave --allow-exec-values. Since the command line of
+// a generator is its chart's, its own o.AllowExec does not count. A source
+// that cannot be read is an error, and nothing is written. So is a key that
+// is given twice.
+//
+// A chart generates its Secret with a generator such as:
+//
+//	#helm:generate helmc secret create --force -o manifests/db.yaml db --from password=env:DB_PASSWORD
+//
+// Since the written file holds the secrets, only its owner may read it, and
+// it should be left out of version control.
+func CreateSecret(name string, o SecretOptions) error {
+	data, err := secretManifest(name, o)
+	if err != nil {
+		return err
+	}
+	if o.Out == "" {
+		_, err := log.Stdout.Write(data)
+		return err
+	}
+	if _, err := os.Stat(o.Out); err == nil && !o.Force {
+		return fmt.Errorf("%s already exists. Use --force to overwrite it.", o.Out)
+	}
+	if err := os.MkdirAll(filepath.Dir(o.Out), 0755); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(o.Out, data, 0600); err != nil {
+		return err
+	}
+	// WriteFile keeps the mode of a file that it overwrites.
+	if err := os.Chmod(o.Out, 0600); err != nil {
+		return err
+	}
+	log.Info("Wrote Secret %s to %s", name, o.Out)
+	return nil
+}
+
+// secretManifest returns the YAML of the Secret of CreateSecret.
+func secretManifest(name string, o SecretOptions) ([]byte, error) {
+	if name == "" {
+		return nil, fmt.Errorf("A Secret needs a name")
+	}
+	values, err := secretValues(o)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("Secret %s has no values. Give them with --from, --from-file, or --from-env-file.", name)
+	}
+
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	data := yaml.MapSlice{}
+	for _, k := range keys {
+		data = append(data, yaml.MapItem{Key: k, Value: base64.StdEncoding.EncodeToString(values[k])})
+	}
+	meta := yaml.MapSlice{{Key: "name", Value: name}}
+	if o.Namespace != "" {
+		meta = append(meta, yaml.MapItem{Key: "namespace", Value: o.Namespace})
+	}
+	typ := o.Type
+	if typ == "" {
+		typ = "Opaque"
+	}
+	out, err := yaml.Marshal(yaml.MapSlice{
+		{Key: "apiVersion", Value: "v1"},
+		{Key: "kind", Value: "Secret"},
+		{Key: "metadata", Value: meta},
+		{Key: "type", Value: typ},
+		{Key: "data", Value: data},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(secretHeader), out...), nil
+}
+
+// secretValues reads the values of the Secret of o, by key.
+func secretValues(o SecretOptions) (map[string][]byte, error) {
+	values := map[string][]byte{}
+	add := func(key string, val []byte, from string) error {
+		if !secretKey.MatchString(key) {
+			return fmt.Errorf("%s: %q is not a valid key of a Secret. Use letters, digits, '-', '_', and '.'", from, key)
+		}
+		if _, ok := values[key]; ok {
+			return fmt.Errorf("%s: the key %s is given twice", from, key)
+		}
+		values[key] = val
+		return nil
+	}
+
+	for _, path := range o.EnvFiles {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		s := bufio.NewScanner(bytes.NewReader(b))
+		for n := 1; s.Scan(); n++ {
+			line := strings.TrimSpace(s.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			kv := strings.SplitN(line, "=", 2)
+			if len(kv) != 2 {
+				return nil, fmt.Errorf("%s:%d is not KEY=VALUE", path, n)
+			}
+			if err := add(strings.TrimSpace(kv[0]), []byte(kv[1]), fmt.Sprintf("%s:%d", path, n)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, spec := range o.FromFiles {
+		key, path := filepath.Base(spec), spec
+		if kv := strings.SplitN(spec, "=", 2); len(kv) == 2 {
+			key, path = kv[0], kv[1]
+		}
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := add(key, b, "--from-file "+spec); err != nil {
+			return nil, err
+		}
+	}
+
+	// As for a template, the flags of a generator are its chart's, and only
+	// the user's --allow-exec-values, in its environment, counts.
+	generating := os.Getenv("HELM_GENERATE_FILE") != ""
+	v := ValueSources{AllowExec: o.AllowExec && !generating || os.Getenv(envAllowExecValues) == "true"}
+	for _, spec := range o.From {
+		s, err := parseValueSource(spec)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid --from %q. Use KEY=SOURCE, such as password=env:DB_PASSWORD", spec)
+		}
+		val, err := v.resolve(s.kind, s.source)
+		if err != nil {
+			return nil, fmt.Errorf("--from %s: %s", s.key, err)
+		}
+		if err := add(s.key, []byte(val), "--from "+s.key); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
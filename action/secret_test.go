@@ -0,0 +1,105 @@
+package action
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/helm/helm-classic/test"
+)
+
+func TestCreateSecret(t *testing.T) {
+	dir, err := ioutil.TempDir("", "helmc-secret-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer os.Unsetenv("HELMC_TEST_PASSWORD")
+	os.Setenv("HELMC_TEST_PASSWORD", "s3cret")
+	cert, env := filepath.Join(dir, "cert.pem"), filepath.Join(dir, ".env")
+	ioutil.WriteFile(cert, []byte("CERT\n"), 0644)
+	ioutil.WriteFile(env, []byte("# app\nUSER=admin\n\nTOKEN=a=b\n"), 0644)
+
+	out := filepath.Join(dir, "manifests", "db.yaml")
+	o := SecretOptions{
+		Namespace: "prod",
+		From:      []string{"password=env:HELMC_TEST_PASSWORD"},
+		FromFiles: []string{cert, "ca.crt=" + cert},
+		EnvFiles:  []string{env},
+		Out:       out,
+	}
+	test.CaptureOutput(func() {
+		if err := CreateSecret("db", o); err != nil {
+			t.Fatal(err)
+		}
+	})
+	fi, err := os.Stat(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.ExpectEquals(t, fi.Mode().Perm(), os.FileMode(0600))
+
+	data, _ := ioutil.ReadFile(out)
+	var s struct {
+		Kind     string
+		Type     string
+		Metadata struct{ Name, Namespace string }
+		Data     map[string]string
+	}
+	if err := yaml.Unmarshal(data, &s); err != nil {
+		t.Fatal(err)
+	}
+	test.ExpectEquals(t, s.Kind+" "+s.Metadata.Name+" "+s.Metadata.Namespace+" "+s.Type, "Secret db prod Opaque")
+	want := map[string]string{"password": "s3cret", "cert.pem": "CERT\n", "ca.crt": "CERT\n", "USER": "admin", "TOKEN": "a=b"}
+	test.ExpectEquals(t, len(s.Data), len(want))
+	for k, v := range want {
+		if b, err := base64.StdEncoding.DecodeString(s.Data[k]); err != nil || string(b) != v {
+			t.Errorf("Expected %s to be %q, got %q", k, v, s.Data[k])
+		}
+	}
+
+	if err := CreateSecret("db", o); err == nil || !strings.Contains(err.Error(), "--force") {
+		t.Errorf("Expected an existing file to need --force, got %v", err)
+	}
+	o.From = append(o.From, "USER=env:HELMC_TEST_PASSWORD")
+	o.Force = true
+	if err := CreateSecret("db", o); err == nil || !strings.Contains(err.Error(), "given twice") {
+		t.Errorf("Expected a key given twice to fail, got %v", err)
+	}
+	if _, err := secretManifest("db", SecretOptions{From: []string{"bad key=env:HOME"}}); err == nil {
+		t.Errorf("Expected an invalid key to fail")
+	}
+	if _, err := secretManifest("db", SecretOptions{}); err == nil {
+		t.Errorf("Expected a Secret without values to fail")
+	}
+}
+
+func TestCreateSecretExec(t *testing.T) {
+	o := SecretOptions{From: []string{"password=cmd:echo s3cret"}}
+	if _, err := secretManifest("db", o); err == nil || !strings.Contains(err.Error(), "--allow-exec-values") {
+		t.Errorf("Expected cmd to need --allow-exec-values, got %v", err)
+	}
+	o.AllowExec = true
+	if _, err := secretManifest("db", o); err != nil {
+		t.Errorf("Expected --allow-exec-values to allow cmd, got %v", err)
+	}
+
+	// The --allow-exec-values of a generator is its chart's, and does not
+	// count; that of the user's generate does.
+	defer os.Unsetenv("HELM_GENERATE_FILE")
+	os.Setenv("HELM_GENERATE_FILE", "secret.yaml")
+	if _, err := secretManifest("db", o); err == nil || !strings.Contains(err.Error(), "--allow-exec-values") {
+		t.Errorf("Expected the --allow-exec-values of a generator to be ignored, got %v", err)
+	}
+	defer os.Unsetenv(envAllowExecValues)
+	os.Setenv(envAllowExecValues, "true")
+	o.AllowExec = false
+	if _, err := secretManifest("db", o); err != nil {
+		t.Errorf("Expected $%s to allow cmd, got %v", envAllowExecValues, err)
+	}
+}
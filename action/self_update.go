@@ -0,0 +1,119 @@
+package action
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/Masterminds/semver"
+	"github.com/helm/helm-classic/log"
+	"github.com/helm/helm-classic/release"
+	"github.com/helm/helm-classic/version"
+)
+
+// UpdateAvailable is the exit status of `helmc self-update --check` when a
+// newer version of Helm Classic has been released.
+const UpdateAvailable = 2
+
+// executable returns the path of the running helmc. Tests replace it.
+var executable = func() (string, error) {
+	p, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(p)
+}
+
+// SelfUpdate replaces the running helmc with a released version.
+//
+// If tag is empty, the latest release is installed, provided that it is
+// newer than this one. A tag installs that release, even if it is older.
+// The archive for this platform is downloaded and verified against its
+// published SHA-256 checksum before the binary is replaced.
+//
+// With check, nothing is changed: helmc exits with UpdateAvailable if the
+// latest release is newer, and with 0 otherwise.
+func SelfUpdate(tag string, check bool) {
+	if Defaults.Offline {
+		log.Die("Cannot update Helm Classic while offline.")
+	}
+	rel, err := release.Get(tag)
+	if err != nil {
+		log.Die("Could not find the release: %s", err)
+	}
+	if rel.TagName == nil {
+		log.Die("The release has no version.")
+	}
+	remote := *rel.TagName
+
+	newer, err := isNewer(remote, version.Version)
+	if err != nil {
+		log.Die("%s", err)
+	}
+	if check {
+		if newer {
+			log.Msg("A new version of Helm Classic is available. You have %s. The latest is %s", version.Version, remote)
+			exit(UpdateAvailable)
+			return
+		}
+		log.Msg("Helm Classic %s is up to date.", version.Version)
+		return
+	}
+	if tag == "" && !newer {
+		log.Info("Helm Classic %s is up to date.", version.Version)
+		return
+	}
+
+	archive, checksum, err := release.Asset(rel, runtime.GOOS, runtime.GOARCH)
+	if err != nil {
+		log.Die("%s", err)
+	}
+	if checksum == nil {
+		log.Die("Release %s does not publish a checksum for %s. Not updating.", remote, *archive.Name)
+	}
+
+	log.Info("Downloading %s ...", *archive.BrowserDownloadURL)
+	data, err := release.Download(archive)
+	if err != nil {
+		log.Die("Could not download %s: %s", *archive.Name, err)
+	}
+	sum, err := release.Download(checksum)
+	if err != nil {
+		log.Die("Could not download %s: %s", *checksum.Name, err)
+	}
+	if err := release.VerifyChecksum(data, sum); err != nil {
+		log.Die("Could not verify %s: %s", *archive.Name, err)
+	}
+	bin, err := release.Extract(data)
+	if err != nil {
+		log.Die("Could not read %s: %s", *archive.Name, err)
+	}
+
+	path, err := executable()
+	if err != nil {
+		log.Die("Could not find the running helmc: %s", err)
+	}
+	if err := release.Replace(path, bin); err != nil {
+		if os.IsPermission(err) {
+			log.Err("You do not have permission to replace %s.", path)
+			log.Info("To update by hand, run:\n\n    curl -sSL -o /tmp/helmc.zip %s && unzip -o /tmp/helmc.zip helmc -d /tmp && sudo install -m 755 /tmp/helmc %s\n", *archive.BrowserDownloadURL, path)
+			log.Die("Helm Classic was not updated.")
+		}
+		log.Die("Could not replace %s: %s", path, err)
+	}
+	log.Info("Updated %s from %s to %s.", path, version.Version, remote)
+}
+
+// isNewer reports whether the remote version is newer than the local one.
+func isNewer(remote, local string) (bool, error) {
+	r, err := semver.NewVersion(remote)
+	if err != nil {
+		return false, fmt.Errorf("Remote version %s is not well-formed", remote)
+	}
+	l, err := semver.NewVersion(local)
+	if err != nil {
+		return false, fmt.Errorf("Local version %s is not well-formed", local)
+	}
+	return r.GreaterThan(l), nil
+}
@@ -0,0 +1,82 @@
+package action
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/helm/helm-classic/release"
+	"github.com/helm/helm-classic/test"
+)
+
+func TestSelfUpdateCheck(t *testing.T) {
+	setupTestCheckLatest()
+	defer func() { release.RepoService = nil }()
+
+	code := 0
+	exit = func(c int) { code = c }
+	defer func() { exit = os.Exit }()
+
+	out := test.CaptureOutput(func() { SelfUpdate("", true) })
+	test.ExpectContains(t, out, "The latest is 9.8.7")
+	if code != UpdateAvailable {
+		t.Errorf("Expected exit status %d, got %d", UpdateAvailable, code)
+	}
+}
+
+func TestSelfUpdate(t *testing.T) {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	f, _ := w.Create("helmc")
+	f.Write([]byte("new helmc"))
+	w.Close()
+	archive := buf.Bytes()
+	sum := sha256.Sum256(archive)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if filepath.Ext(r.URL.Path) == ".sha256" {
+			fmt.Fprintf(w, "%s  helmc.zip\n", hex.EncodeToString(sum[:]))
+			return
+		}
+		w.Write(archive)
+	}))
+	defer srv.Close()
+
+	tag := "9.8.7"
+	name := fmt.Sprintf("helmc-9.8.7-%s-%s.zip", runtime.GOOS, runtime.GOARCH)
+	asset := func(n string) github.ReleaseAsset {
+		u := srv.URL + "/" + n
+		return github.ReleaseAsset{Name: &n, BrowserDownloadURL: &u}
+	}
+	release.RepoService = &MockGHRepoService{Release: &github.RepositoryRelease{
+		TagName: &tag,
+		Assets:  []github.ReleaseAsset{asset(name), asset(name + ".sha256")},
+	}}
+	defer func() { release.RepoService = nil }()
+
+	dir, err := ioutil.TempDir("", "helmc-bin-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	bin := filepath.Join(dir, "helmc")
+	ioutil.WriteFile(bin, []byte("old helmc"), 0755)
+	defer func(e func() (string, error)) { executable = e }(executable)
+	executable = func() (string, error) { return bin, nil }
+
+	out := test.CaptureOutput(func() { SelfUpdate("", false) })
+	test.ExpectContains(t, out, "to 9.8.7")
+	if b, _ := ioutil.ReadFile(bin); string(b) != "new helmc" {
+		t.Errorf("Expected the binary to be replaced, got %q", b)
+	}
+}
@@ -0,0 +1,180 @@
+package action
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/ssh/terminal"
+
+	"github.com/helm/helm-classic/chart"
+	"github.com/helm/helm-classic/helmpath"
+	"github.com/helm/helm-classic/log"
+	"github.com/helm/helm-classic/repo"
+)
+
+// The keyrings that sign and verify use, unless they are given others.
+const (
+	// DefaultKeyring is the keyring of the public keys that verify charts.
+	DefaultKeyring = "~/.gnupg/pubring.gpg"
+	// DefaultSecretKeyring is the keyring of the private keys that sign
+	// charts.
+	DefaultSecretKeyring = "~/.gnupg/secring.gpg"
+)
+
+// PassphraseVar is the environment variable of the passphrase of an
+// encrypted signing key. Without it, the passphrase is asked for on a
+// terminal.
+const PassphraseVar = "HELMC_SIGNING_PASSPHRASE"
+
+// Sign writes the provenance file of a chart, signed by a key of a keyring.
+//
+// - chartPath is a chart directory, or a chart archive
+// - keyring is the keyring of the private key
+// - key is a part of the identity of the key that signs, such as its email
+// address; if it is empty, the first private key of the keyring signs
+//
+// The provenance file is written next to the chart, named after it with
+// repo.ProvenanceExt, such as redis-0.2.0.tgz.prov. See repo.Provenance.
+func Sign(chartPath, keyring, key string) error {
+	kr, err := repo.LoadKeyring(helmpath.ExpandHome(keyring))
+	if err != nil {
+		return fmt.Errorf("Could not read keyring: %s", err)
+	}
+	signer, err := repo.SigningKey(kr, key, passphrase)
+	if err != nil {
+		return fmt.Errorf("Could not sign %s: %s", chartPath, err)
+	}
+
+	dir, cleanup, err := expandChart(chartPath)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	cf, err := chart.LoadChartfile(filepath.Join(dir, Chartfile))
+	if err != nil {
+		return fmt.Errorf("Could not read the Chart.yaml of %s: %s", chartPath, err)
+	}
+	sum, err := chart.Checksum(dir)
+	if err != nil {
+		return fmt.Errorf("Could not compute the checksum of %s: %s", chartPath, err)
+	}
+	data, err := repo.SignProvenance(&repo.Provenance{Name: cf.Name, Version: cf.Version, Checksum: sum}, signer)
+	if err != nil {
+		return fmt.Errorf("Could not sign %s: %s", chartPath, err)
+	}
+	prov := provenancePath(chartPath)
+	if err := ioutil.WriteFile(prov, data, 0644); err != nil {
+		return err
+	}
+	log.Info("Signed %s %s with key %X, in %s", cf.Name, cf.Version, signer.PrimaryKey.KeyId, prov)
+	return nil
+}
+
+// Verify checks a chart against its provenance file, as Sign writes it, and
+// the keys of a keyring.
+//
+// - chartPath is a chart directory, or a chart archive
+// - keyring is the keyring of the public keys that may have signed it
+func Verify(chartPath, keyring string) error {
+	dir, cleanup, err := expandChart(chartPath)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	p, signer, err := verifyChart(dir, provenancePath(chartPath), keyring)
+	if err != nil {
+		return fmt.Errorf("%s failed verification: %s", chartPath, err)
+	}
+	log.Info("Verified %s %s, signed by %s", p.Name, p.Version, signer)
+	return nil
+}
+
+// verifyChart checks the chart in dir against the provenance file prov, which
+// must be signed by a key of keyring. The chart must be the one that the file
+// names, with the checksum that it gives.
+//
+// It returns what the file says, and who signed it.
+func verifyChart(dir, prov, keyring string) (*repo.Provenance, string, error) {
+	data, err := ioutil.ReadFile(prov)
+	if os.IsNotExist(err) {
+		return nil, "", fmt.Errorf("no provenance file %s", prov)
+	} else if err != nil {
+		return nil, "", err
+	}
+	kr, err := repo.LoadKeyring(helmpath.ExpandHome(keyring))
+	if err != nil {
+		return nil, "", fmt.Errorf("could not read keyring: %s", err)
+	}
+	p, signer, err := repo.VerifyProvenance(data, kr)
+	if err != nil {
+		return nil, "", err
+	}
+	cf, err := chart.LoadChartfile(filepath.Join(dir, Chartfile))
+	if err != nil {
+		return nil, "", err
+	}
+	if cf.Name != p.Name || cf.Version != p.Version {
+		return nil, "", fmt.Errorf("the provenance file is of %s %s, not of %s %s", p.Name, p.Version, cf.Name, cf.Version)
+	}
+	if err := chart.VerifyChecksum(dir, p.Checksum); err != nil {
+		return nil, "", err
+	}
+	return p, signer, nil
+}
+
+// verifyFetched checks a chart that is about to be fetched, as verifyChart
+// does. label names the chart in messages.
+func (c *Client) verifyFetched(dir, prov, label, keyring string) error {
+	p, signer, err := verifyChart(dir, prov, keyring)
+	if err != nil {
+		return fmt.Errorf("Not fetching %s: it failed verification: %s. Fetch it without --verify to use it anyway.", label, err)
+	}
+	c.Log.Info("Verified %s %s, signed by %s", p.Name, p.Version, signer)
+	return nil
+}
+
+// provenancePath returns the path of the provenance file of a chart
+// directory or archive.
+func provenancePath(chartPath string) string {
+	return filepath.Clean(chartPath) + repo.ProvenanceExt
+}
+
+// expandChart returns the directory of the files of a chart: a chart
+// directory itself, or a chart archive expanded into a temporary directory,
+// which cleanup removes.
+func expandChart(chartPath string) (string, func(), error) {
+	if !isChartArchive(chartPath) {
+		return chartPath, func() {}, nil
+	}
+	data, err := ioutil.ReadFile(chartPath)
+	if err != nil {
+		return "", nil, fmt.Errorf("Could not read chart archive: %s", err)
+	}
+	tmp, err := ioutil.TempDir("", "helmc-archive-")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() { os.RemoveAll(tmp) }
+	if err := repo.Expand(data, tmp, chart.DefaultLimits.Files, chart.DefaultLimits.Bytes); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("Could not expand %s: %s", chartPath, err)
+	}
+	return tmp, cleanup, nil
+}
+
+// passphrase returns the passphrase of a signing key: $HELMC_SIGNING_PASSPHRASE,
+// or what the user types on a terminal.
+func passphrase() ([]byte, error) {
+	if pw, ok := os.LookupEnv(PassphraseVar); ok {
+		return []byte(pw), nil
+	}
+	if !stdinIsTerminal() {
+		return nil, fmt.Errorf("the key is encrypted. Set $%s to its passphrase", PassphraseVar)
+	}
+	fmt.Fprint(log.Stdout, "Passphrase: ")
+	pw, err := terminal.ReadPassword(int(log.Stdin.(*os.File).Fd()))
+	fmt.Fprintln(log.Stdout)
+	return pw, err
+}
@@ -0,0 +1,79 @@
+package action
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/helm/helm-classic/log"
+	"github.com/helm/helm-classic/repo"
+	"github.com/helm/helm-classic/test"
+	"github.com/helm/helm-classic/util"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	home := test.CreateTmpHome()
+	defer os.RemoveAll(home)
+	test.FakeUpdate(home)
+
+	key := test.SigningKey("charts")
+	secring := filepath.Join(home, "secring.gpg")
+	pubring := filepath.Join(home, "pubring.gpg")
+	other := filepath.Join(home, "other.gpg")
+	test.WriteSecretKeyring(secring, key)
+	test.WriteKeyring(pubring, key)
+	test.WriteKeyring(other, test.SigningKey("other"))
+
+	archive, _, err := repo.Package(util.CacheDirectory(home, "charts", "redis"), filepath.Join(home, "dist"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.CaptureOutput(func() {
+		if err := Sign(archive, secring, "charts"); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if _, err := os.Stat(archive + repo.ProvenanceExt); err != nil {
+		t.Fatalf("Expected a provenance file: %s", err)
+	}
+
+	out := test.CaptureOutput(func() {
+		if err := Verify(archive, pubring); err != nil {
+			t.Error(err)
+		}
+	})
+	test.ExpectContains(t, out, "Verified redis-standalone 0.0.1, signed by charts")
+	if err := Verify(archive, other); err == nil {
+		t.Errorf("Expected a chart signed by another key to fail")
+	}
+
+	var buf bytes.Buffer
+	c := &Client{Home: home, Log: &log.Logger{Stdout: &buf, Stderr: &buf}}
+	if _, err := c.Fetch(archive, "", FetchOptions{VerifyKeyring: pubring}); err != nil {
+		t.Fatal(err)
+	}
+	test.ExpectContains(t, buf.String(), "Verified redis-standalone 0.0.1")
+
+	// A chart whose files changed after it was signed is not fetched.
+	src := util.CacheDirectory(home, "charts", "redis")
+	test.CaptureOutput(func() {
+		if err := Sign(src, secring, ""); err != nil {
+			t.Fatal(err)
+		}
+	})
+	ioutil.WriteFile(filepath.Join(src, "README.md"), []byte("changed\n"), 0644)
+	if _, err := c.Fetch("charts/redis", "myredis", FetchOptions{VerifyKeyring: pubring}); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("Expected a changed chart to fail verification, got %v", err)
+	}
+	if _, err := os.Stat(util.WorkspaceChartDirectory(home, "myredis")); !os.IsNotExist(err) {
+		t.Errorf("Expected nothing to be fetched, got %v", err)
+	}
+
+	// Install does not use a workspace chart that it cannot verify.
+	if _, _, _, err := c.installPlan("redis-standalone", &InstallOptions{VerifyKeyring: pubring}); err == nil || !strings.Contains(err.Error(), "--verify cannot check") {
+		t.Errorf("Expected the workspace chart to be refused, got %v", err)
+	}
+}
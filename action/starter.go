@@ -0,0 +1,266 @@
+package action
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/helm/helm-classic/chart"
+	"github.com/helm/helm-classic/helmpath"
+	"github.com/helm/helm-classic/log"
+)
+
+// DefaultStarter is the starter that 'helmc create' copies unless another is named.
+const DefaultStarter = "default"
+
+// StarterChartName is replaced by the name of the new chart in the paths
+// and contents of a starter's files.
+const StarterChartName = "<CHARTNAME>"
+
+// readmeSkel is the README.md of the built-in starters.
+const readmeSkel = `# <CHARTNAME>
+
+Describe your chart here. Link to upstream repositories, Docker images or any
+external documentation.
+
+If your application requires any specific configuration like Secrets, you may
+include that information here.
+`
+
+// manifestSkel is an example manifest for a new chart
+const manifestSkel = `---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: example-pod
+  labels:
+    heritage: helm
+spec:
+  restartPolicy: Never
+  containers:
+  - name: example
+    image: "alpine:3.2"
+    command: ["/bin/sleep","9000"]
+    resources:
+      requests:
+        cpu: 10m
+        memory: 16Mi
+`
+
+// deploymentSkel is the Deployment of the web-service starter.
+const deploymentSkel = `---
+apiVersion: extensions/v1beta1
+kind: Deployment
+metadata:
+  name: <CHARTNAME>
+  labels:
+    heritage: helm
+    app: <CHARTNAME>
+spec:
+  replicas: 1
+  template:
+    metadata:
+      labels:
+        app: <CHARTNAME>
+    spec:
+      containers:
+      - name: <CHARTNAME>
+        image: "nginx:1.9"
+        ports:
+        - containerPort: 80
+          name: http
+        resources:
+          requests:
+            cpu: 100m
+            memory: 64Mi
+`
+
+// serviceSkel is the Service of the web-service starter.
+const serviceSkel = `---
+apiVersion: v1
+kind: Service
+metadata:
+  name: <CHARTNAME>
+  labels:
+    heritage: helm
+    app: <CHARTNAME>
+spec:
+  selector:
+    app: <CHARTNAME>
+  ports:
+  - port: 80
+    targetPort: http
+`
+
+// daemonSetSkel is the DaemonSet of the daemon starter.
+const daemonSetSkel = `---
+apiVersion: extensions/v1beta1
+kind: DaemonSet
+metadata:
+  name: <CHARTNAME>
+  labels:
+    heritage: helm
+    app: <CHARTNAME>
+spec:
+  template:
+    metadata:
+      labels:
+        app: <CHARTNAME>
+    spec:
+      containers:
+      - name: <CHARTNAME>
+        image: "alpine:3.2"
+        command: ["/bin/sleep","9000"]
+        resources:
+          requests:
+            cpu: 10m
+            memory: 16Mi
+`
+
+// builtinStarter is a starter that is compiled into helmc.
+type builtinStarter struct {
+	description string
+	// files are the contents of each file, by its slash-separated path
+	// relative to the chart.
+	files map[string]string
+}
+
+// builtinStarters are the starters that are always available. A starter in
+// the home directory with the same name takes precedence.
+var builtinStarters = map[string]builtinStarter{
+	DefaultStarter: {
+		description: "A single example Pod.",
+		files: map[string]string{
+			"README.md":                  readmeSkel,
+			"manifests/example-pod.yaml": manifestSkel,
+		},
+	},
+	"web-service": {
+		description: "A Deployment behind a Service.",
+		files: map[string]string{
+			"README.md": readmeSkel,
+			"manifests/" + StarterChartName + "-deployment.yaml": deploymentSkel,
+			"manifests/" + StarterChartName + "-service.yaml":    serviceSkel,
+		},
+	},
+	"daemon": {
+		description: "A DaemonSet that runs a Pod on every node.",
+		files: map[string]string{
+			"README.md": readmeSkel,
+			"manifests/" + StarterChartName + "-daemonset.yaml": daemonSetSkel,
+		},
+	},
+}
+
+// starter describes a starter for ListStarters.
+type starter struct {
+	name, description, path string
+}
+
+// starters returns the available starters, sorted by name. A built-in
+// starter has no path.
+func starters(homedir string) []starter {
+	found := map[string]starter{}
+	for name, b := range builtinStarters {
+		found[name] = starter{name: name, description: b.description}
+	}
+	dir := helmpath.Home(homedir).Starters()
+	if fis, err := ioutil.ReadDir(dir); err == nil {
+		for _, fi := range fis {
+			if !fi.IsDir() || strings.HasPrefix(fi.Name(), ".") {
+				continue
+			}
+			s := starter{name: fi.Name(), path: filepath.Join(dir, fi.Name())}
+			if cf, err := chart.LoadChartfile(filepath.Join(s.path, Chartfile)); err == nil {
+				s.description = cf.Description
+			}
+			found[s.name] = s
+		}
+	}
+
+	res := make([]starter, 0, len(found))
+	for _, s := range found {
+		res = append(res, s)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].name < res[j].name })
+	return res
+}
+
+// ListStarters prints the starters that 'helmc create --starter' can copy.
+func ListStarters(homedir string) {
+	w := tabwriter.NewWriter(log.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSOURCE\tDESCRIPTION")
+	for _, s := range starters(homedir) {
+		source := s.path
+		if source == "" {
+			source = "(built-in)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", s.name, source, s.description)
+	}
+	w.Flush()
+}
+
+// loadStarter returns the files of the named starter, by their
+// slash-separated paths relative to the chart.
+//
+// A starter is a directory in the starters directory of the home, or one
+// of the built-in starters. Version control directories are skipped.
+func loadStarter(homedir, name string) (map[string]string, error) {
+	if name == "" || strings.HasPrefix(name, ".") || strings.ContainsAny(name, `/\`) {
+		return nil, fmt.Errorf("Invalid starter name %q", name)
+	}
+	dir := helmpath.Home(homedir).Starters(name)
+	fi, err := os.Stat(dir)
+	if err != nil || !fi.IsDir() {
+		if b, ok := builtinStarters[name]; ok {
+			return b.files, nil
+		}
+		names := []string{}
+		for _, s := range starters(homedir) {
+			names = append(names, s.name)
+		}
+		return nil, fmt.Errorf("Unknown starter %q. Available starters: %s", name, strings.Join(names, ", "))
+	}
+
+	files, err := readStarterDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read starter %s: %s", name, err)
+	}
+	return files, nil
+}
+
+// readStarterDir returns the files of a directory, by their slash-separated
+// paths relative to it. Version control directories and files that are not
+// regular are skipped.
+func readStarterDir(dir string) (map[string]string, error) {
+	files := map[string]string{}
+	err := filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			if fi.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		b, err := ioutil.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = string(b)
+		return nil
+	})
+	return files, err
+}
@@ -0,0 +1,90 @@
+package action
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/helm/helm-classic/helmpath"
+	helm "github.com/helm/helm-classic/util"
+)
+
+// useStatelessHome makes Home a temporary home whose workspace is empty, for
+// InstallOptions.Stateless. The other parts of the home, such as the
+// configuration, the repository caches, and the values files, are links to
+// those of Home, so that a chart is fetched and generated as it would be, and
+// the audit log and the release history are still written to Home.
+//
+// The returned function restores Home, and removes the temporary home.
+func (c *Client) useStatelessHome() (func(), error) {
+	home, err := filepath.Abs(c.Home)
+	if err != nil {
+		return nil, err
+	}
+	tmp, cleanup, err := helm.TempDir("", "helmc-stateless-")
+	if err != nil {
+		return nil, err
+	}
+	// The workspace is that of tmp, even if $HELMC_WORKSPACE, or a key of the
+	// linked configuration, moves those of homes.
+	restore := helmpath.Home(tmp).UseWorkspace(helmpath.Home(tmp).InHome().Workspace)
+	if err := linkHome(home, tmp); err != nil {
+		restore()
+		cleanup()
+		return nil, fmt.Errorf("Could not create a temporary workspace: %s", err)
+	}
+	c.Log.Debug("Using the temporary workspace %s", helmpath.Home(tmp).Workspace())
+	old := c.Home
+	c.Home, c.stateHome = tmp, home
+	return func() {
+		c.Home, c.stateHome = old, ""
+		restore()
+		cleanup()
+	}, nil
+}
+
+// linkHome links each entry of home into tmp, except its workspace and its
+// locks, and creates the rest of a home in tmp.
+func linkHome(home, tmp string) error {
+	h := helmpath.Home(home)
+	skip := map[string]bool{h.InHome().Workspace: true, h.Locks(): true}
+	entries, err := ioutil.ReadDir(home)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		p := filepath.Join(home, e.Name())
+		if skip[p] {
+			continue
+		}
+		if err := os.Symlink(p, filepath.Join(tmp, e.Name())); err != nil {
+			return err
+		}
+	}
+	// The parts that the layout of home moves out of it are linked where
+	// those of tmp are.
+	t := helmpath.Home(tmp)
+	for src, dst := range map[string]string{h.Config(): t.Config(), h.Credentials(): t.Credentials(), h.Cache(): t.Cache()} {
+		if _, err := os.Lstat(dst); err == nil || src == dst {
+			continue
+		}
+		if _, err := os.Stat(src); err != nil {
+			continue
+		}
+		if err := os.Symlink(src, dst); err != nil {
+			return err
+		}
+	}
+	_, err = t.Ensure()
+	return err
+}
+
+// stateDir returns the home that the audit log and the release history are
+// in: Home, unless it is a temporary one.
+func (c *Client) stateDir() string {
+	if c.stateHome != "" {
+		return c.stateHome
+	}
+	return c.Home
+}
@@ -0,0 +1,324 @@
+package action
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/helm/helm-classic/chart"
+	helmerrors "github.com/helm/helm-classic/errors"
+	"github.com/helm/helm-classic/kubectl"
+	"github.com/helm/helm-classic/log"
+	"github.com/helm/helm-classic/manifest"
+	"github.com/helm/helm-classic/output"
+	helm "github.com/helm/helm-classic/util"
+)
+
+// Resource states reported by Status.
+const (
+	// StateCurrent indicates that the resource was installed from the local chart.
+	StateCurrent = "current"
+	// StateDrifted indicates that the resource was installed from a different
+	// version of the chart, or that the local chart has changed since.
+	StateDrifted = "drifted"
+	// StateUnknown indicates that the resource has no chart annotations.
+	StateUnknown = "unknown"
+	// StateMissing indicates that the resource is not in Kubernetes.
+	StateMissing = "missing"
+)
+
+// stateColors are the colors of the resource states on a terminal.
+var stateColors = map[string]output.Color{
+	StateCurrent: output.Green,
+	StateDrifted: output.Yellow,
+	StateMissing: output.Red,
+}
+
+// installed describes the chart that a resource in Kubernetes was installed
+// from, and whether it is ready.
+type installed struct {
+	Kind    string `json:"kind" yaml:"kind"`
+	Name    string `json:"name" yaml:"name"`
+	Version string `json:"version,omitempty" yaml:"version,omitempty"`
+	Digest  string `json:"digest,omitempty" yaml:"digest,omitempty"`
+	Since   string `json:"installedAt,omitempty" yaml:"installedAt,omitempty"`
+	State   string `json:"state" yaml:"state"`
+	// Ready is ReadyYes, ReadyNo, or ReadyNone, and Detail says more, such
+	// as "2/3 available".
+	Ready  string `json:"ready" yaml:"ready"`
+	Detail string `json:"detail,omitempty" yaml:"detail,omitempty"`
+	// data is the resource as JSON, if it was found.
+	data []byte
+}
+
+// chartStatusInfo is the machine-readable status of a chart.
+type chartStatusInfo struct {
+	Chart     string       `json:"chart" yaml:"chart"`
+	Version   string       `json:"version" yaml:"version"`
+	Digest    string       `json:"digest" yaml:"digest"`
+	Namespace string       `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	Resources []*installed `json:"resources" yaml:"resources"`
+	// Ready is the rollup, such as "1/2 pods ready".
+	Ready string `json:"ready,omitempty" yaml:"ready,omitempty"`
+}
+
+// readyColors are the colors of the readiness states on a terminal.
+var readyColors = map[string]output.Color{
+	ReadyYes: output.Green,
+	ReadyNo:  output.Yellow,
+}
+
+// StatusOptions control how Status waits for the resources of a chart.
+type StatusOptions struct {
+	// Watch checks the resources again, every few seconds, until they are
+	// all present and ready, or Timeout expires.
+	Watch   bool
+	Timeout time.Duration
+	// Output is "json" or "yaml" to print the status in that format, instead
+	// of a table.
+	Output string
+}
+
+// Status compares the resources in Kubernetes with a chart in the workspace,
+// and reports whether they are ready.
+//
+// The chart annotations that install adds to each resource are read back,
+// and their digest is compared with that of the local chart, so that a
+// cluster that is not running the local chart can be detected. The resources
+// are looked for in namespace, or that of the chart's Chart.yaml.
+//
+// The state of each resource tells whether it is ready (see readiness): a Pod
+// whose containers are ready, a Service with endpoints, a Deployment whose
+// replicas are available, and so on. A rollup counts the ready resources of
+// each kind. With o.Watch, the resources are checked until they are all
+// ready, and those that are not within o.Timeout are a
+// *helmerrors.NotReadyError.
+//
+// With o.Output, the status is printed as JSON or YAML instead.
+func Status(chartName, home, namespace string, o StatusOptions, client kubectl.Runner) error {
+	CheckClientPrereqs(client)
+	c, namespace, digest, err := loadStatusChart(home, chartName, namespace)
+	if err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(o.Timeout)
+	var sts []*installed
+	notReady := 0
+	for {
+		sts, notReady = chartStatus(c, namespace, digest, client)
+		if !o.Watch || notReady == 0 || time.Now().After(deadline) {
+			break
+		}
+		log.Info("%d of %d resources are not ready: %s. Checking again ...", notReady, len(sts), readinessRollup(sts))
+		time.Sleep(pollInterval)
+	}
+
+	if o.Output != "" {
+		err = printFormatted(&chartStatusInfo{
+			Chart:     c.Chartfile.Name,
+			Version:   c.Chartfile.Version,
+			Digest:    digest,
+			Namespace: namespace,
+			Resources: sts,
+			Ready:     readinessRollup(sts),
+		}, o.Output)
+		if err != nil {
+			return err
+		}
+	} else {
+		printStatus(c, digest, sts)
+	}
+	if o.Watch && notReady > 0 {
+		e := &helmerrors.NotReadyError{Chart: chartName, Timeout: o.Timeout}
+		for _, st := range sts {
+			if st.Ready == ReadyNo {
+				e.Resources = append(e.Resources, fmt.Sprintf("%s %s (%s)", st.Kind, st.Name, or(st.Detail, st.State)))
+			}
+		}
+		return e
+	}
+	return nil
+}
+
+// ChartStatus is how the resources of a workspace chart in Kubernetes
+// compare with it, as Status reports them.
+type ChartStatus struct {
+	Chart     string `json:"chart" yaml:"chart"`
+	Version   string `json:"version" yaml:"version"`
+	Digest    string `json:"digest" yaml:"digest"`
+	Namespace string `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	// Resources is the number of named resources of the chart. NotReady of
+	// them are missing or not ready, and Drifted are not running the local
+	// chart.
+	Resources int `json:"resources" yaml:"resources"`
+	NotReady  int `json:"notReady" yaml:"notReady"`
+	Drifted   int `json:"drifted" yaml:"drifted"`
+	// Ready is the rollup, such as "1/2 pods ready".
+	Ready string `json:"ready,omitempty" yaml:"ready,omitempty"`
+}
+
+// Status is like the package-level Status, without watching. It returns the
+// status of the chart instead of printing it.
+func (c *Client) Status(chartName, namespace string) (*ChartStatus, error) {
+	ch, namespace, digest, err := loadStatusChart(c.Home, chartName, namespace)
+	if err != nil {
+		return nil, err
+	}
+	sts, notReady := chartStatus(ch, namespace, digest, c.Kube)
+	st := &ChartStatus{
+		Chart:     ch.Chartfile.Name,
+		Version:   ch.Chartfile.Version,
+		Digest:    digest,
+		Namespace: namespace,
+		Resources: len(sts),
+		NotReady:  notReady,
+		Ready:     readinessRollup(sts),
+	}
+	for _, s := range sts {
+		if s.State != StateCurrent {
+			st.Drifted++
+		}
+	}
+	return st, nil
+}
+
+// loadStatusChart loads a workspace chart, and returns it with namespace, or
+// that of its Chart.yaml, and its digest.
+func loadStatusChart(home, chartName, namespace string) (*chart.Chart, string, string, error) {
+	if !chartFetched(chartName, home, nil) {
+		return nil, "", "", fmt.Errorf("No chart named %q in your workspace.", chartName)
+	}
+	cd := helm.WorkspaceChartDirectory(home, chartName)
+	c, err := chart.Load(cd)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("Failed to load chart: %s", err)
+	}
+	if namespace == "" {
+		namespace = c.Chartfile.Namespace
+	}
+	digest, err := chart.Digest(cd)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("Could not compute the digest of %s: %s", cd, err)
+	}
+	return c, namespace, digest, nil
+}
+
+// printStatus prints the states of the resources of a chart as a table,
+// followed by the local chart and the readiness rollup.
+func printStatus(c *chart.Chart, digest string, sts []*installed) {
+	t := &output.Table{
+		Header: []string{"KIND", "NAME", "VERSION", "DIGEST", "INSTALLED", "STATE", "READY"},
+		Flex:   1,
+		Colors: func(row []string, col int) output.Color {
+			switch col {
+			case 5:
+				return stateColors[row[col]]
+			case 6:
+				return readyColors[strings.SplitN(row[col], " (", 2)[0]]
+			}
+			return output.None
+		},
+	}
+	drifted := 0
+	for _, st := range sts {
+		if st.State != StateCurrent {
+			drifted++
+		}
+		ready := st.Ready
+		if st.Detail != "" {
+			ready += " (" + st.Detail + ")"
+		}
+		t.Add(st.Kind, st.Name, dash(st.Version), dash(shortDigest(st.Digest)), dash(st.Since), st.State, ready)
+	}
+	output.New(log.Stdout).Table(t)
+
+	log.Msg("Local chart: %s %s, digest %s", c.Chartfile.Name, c.Chartfile.Version, shortDigest(digest))
+	if r := readinessRollup(sts); r != "" {
+		log.Msg("Ready: %s", r)
+	}
+	if drifted > 0 {
+		log.Warn("%d resources are not running the local chart.", drifted)
+	}
+}
+
+// chartStatus returns the state of each named resource of a chart, in
+// install order, and how many of them are missing or not ready.
+func chartStatus(c *chart.Chart, namespace, digest string, client kubectl.Runner) ([]*installed, int) {
+	sts := []*installed{}
+	notReady := 0
+	for _, m := range installManifests(c, nil) {
+		if m.Name == "" || hookOf(m) == manifest.HookPreDelete {
+			continue
+		}
+		ns := namespace
+		if meta, err := m.VersionedObject.Meta(); err == nil && meta.Namespace != "" {
+			ns = meta.Namespace
+		}
+		st := installedResource(m.Name, m.Kind, ns, digest, client)
+		if st.State == StateMissing {
+			st.Ready = ReadyNo
+		} else if st.data != nil {
+			st.Ready, st.Detail = readiness(st.Kind, st.Name, ns, st.data, client)
+		} else {
+			st.Ready = ReadyNone
+		}
+		if st.Ready == ReadyNo {
+			notReady++
+		}
+		sts = append(sts, st)
+	}
+	return sts, notReady
+}
+
+// installedResource reads the chart annotations of a resource in Kubernetes,
+// and compares them with the digest of the local chart.
+func installedResource(name, kind, ns, digest string, client kubectl.Runner) *installed {
+	st := &installed{Kind: kind, Name: name, State: StateUnknown}
+	out, err := client.GetObject(name, kind, ns)
+	if err != nil {
+		if kubectl.IsNotFound(out) {
+			st.State = StateMissing
+		} else {
+			log.Warn("Could not get %s %s: %s", kind, name, failure(out, err))
+		}
+		return st
+	}
+
+	obj := struct {
+		Metadata struct {
+			Annotations map[string]string `json:"annotations"`
+		} `json:"metadata"`
+	}{}
+	if err := json.Unmarshal(out, &obj); err != nil {
+		log.Warn("Could not read %s %s: %s", kind, name, err)
+		return st
+	}
+	st.data = out
+	ann := obj.Metadata.Annotations
+	st.Version, st.Digest, st.Since = ann[chart.AnnChartVersion], ann[chart.AnnChartDigest], ann[chart.AnnInstalledAt]
+	switch {
+	case st.Digest == "":
+	case st.Digest == digest:
+		st.State = StateCurrent
+	default:
+		st.State = StateDrifted
+	}
+	return st
+}
+
+// shortDigest abbreviates a digest for display.
+func shortDigest(d string) string {
+	if len(d) > 12 {
+		return d[:12]
+	}
+	return d
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,102 @@
+package action
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/helm/helm-classic/chart"
+	"github.com/helm/helm-classic/kubectl"
+	"github.com/helm/helm-classic/log"
+	"github.com/helm/helm-classic/test"
+	helm "github.com/helm/helm-classic/util"
+)
+
+func TestInstallAnnotations(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+	test.FakeUpdate(tmpHome)
+
+	client := &kubectl.FakeRunner{Out: []byte(`pod "redis" created`)}
+	test.CaptureOutput(func() {
+		Install("redis", tmpHome, InstallOptions{Annotate: true, Labels: true}, client)
+	})
+	digest, _ := chart.Digest(helm.WorkspaceChartDirectory(tmpHome, "redis"))
+	for _, ann := range []string{chart.AnnChartName, chart.AnnChartVersion, chart.AnnInstalledAt, chart.AnnChartDigest, digest} {
+		if len(client.Stdin) != 1 || !strings.Contains(string(client.Stdin[0]), ann) {
+			t.Errorf("Expected %s in the manifest: %s", ann, client.Stdin)
+		}
+	}
+
+	client = &kubectl.FakeRunner{Out: []byte(`pod "redis" created`)}
+	test.CaptureOutput(func() {
+		Install("redis", tmpHome, InstallOptions{}, client)
+	})
+	if strings.Contains(string(client.Stdin[0]), "chart.helm.sh") {
+		t.Errorf("Expected no annotations: %s", client.Stdin[0])
+	}
+}
+
+func TestStatus(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+	test.FakeUpdate(tmpHome)
+	Fetch("redis", "", tmpHome, FetchOptions{})
+	digest, _ := chart.Digest(helm.WorkspaceChartDirectory(tmpHome, "redis"))
+
+	tests := []struct {
+		out      string
+		err      error
+		expected string
+	}{
+		{`{"metadata": {"annotations": {"chart.helm.sh/version": "0.1.0", "chart.helm.sh/digest": "` + digest + `"}}}`, nil, "current"},
+		{`{"metadata": {"annotations": {"chart.helm.sh/version": "0.0.9", "chart.helm.sh/digest": "0123456789abcdef"}}}`, nil, "0123456789ab"},
+		{`{"metadata": {"annotations": {"team": "data"}}}`, nil, "unknown"},
+		{`Error from server: pods "redis" not found`, errors.New("exit status 1"), "missing"},
+	}
+	for _, tt := range tests {
+		actual := test.CaptureOutput(func() {
+			Status("redis", tmpHome, "default", StatusOptions{}, &kubectl.FakeRunner{Out: []byte(tt.out), Err: tt.err})
+		})
+		test.ExpectContains(t, actual, tt.expected)
+	}
+
+	actual := test.CaptureOutput(func() {
+		List(tmpHome, "default", "", &kubectl.FakeRunner{Out: []byte(tests[1].out)})
+	})
+	test.ExpectContains(t, actual, "[installed 0.0.9, drifted]")
+}
+
+func TestStatusJSON(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+	test.FakeUpdate(tmpHome)
+	Fetch("redis", "", tmpHome, FetchOptions{})
+	digest, _ := chart.Digest(helm.WorkspaceChartDirectory(tmpHome, "redis"))
+
+	var out bytes.Buffer
+	o, e := log.Stdout, log.Stderr
+	log.Stdout, log.Stderr = &out, ioutil.Discard
+	defer func() { log.Stdout, log.Stderr = o, e }()
+
+	client := &kubectl.FakeRunner{Out: []byte(`{"metadata": {"annotations": {"chart.helm.sh/version": "0.1.0", "chart.helm.sh/digest": "` + digest + `"}}}`)}
+	if err := Status("redis", tmpHome, "default", StatusOptions{Output: "json"}, client); err != nil {
+		t.Fatal(err)
+	}
+	st := &chartStatusInfo{}
+	if err := json.Unmarshal(out.Bytes(), st); err != nil {
+		t.Fatalf("Expected JSON, got %q: %s", out.String(), err)
+	}
+	if st.Chart != "redis" || st.Digest != digest || len(st.Resources) == 0 || st.Resources[0].State != StateCurrent || st.Resources[0].Version != "0.1.0" {
+		t.Errorf("Expected redis to be current, got %+v", st)
+	}
+
+	out.Reset()
+	List(tmpHome, "default", "yaml", client)
+	test.ExpectContains(t, out.String(), "- name: redis\n")
+	test.ExpectContains(t, out.String(), "state: current")
+}
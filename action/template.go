@@ -6,40 +6,152 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
+	"strings"
 	"text/template"
 
 	"github.com/BurntSushi/toml"
-	"github.com/Masterminds/sprig"
+	"github.com/helm/helm-classic/chart"
+	"github.com/helm/helm-classic/generator"
 	"github.com/helm/helm-classic/log"
+	helm "github.com/helm/helm-classic/util"
 	"gopkg.in/yaml.v2"
 )
 
 var err error
 
+func init() {
+	generator.Template = renderGenerator
+	generator.TemplateReads = templateReads
+}
+
 //GenerateTemplate evaluates a template and writes it to an io.Writer
+//
+// The template is not in a chart, so its context has no .Chart or .Files.
 func GenerateTemplate(out io.Writer, in io.Reader, vals interface{}) {
+	generateTemplate(out, in, "helmTpl", "", vals)
+}
+
+// generateTemplate evaluates a template of the chart in chartDir, and writes it
+// to out. name names the template in errors.
+func generateTemplate(out io.Writer, in io.Reader, name, chartDir string, vals interface{}) {
 	tpl, err := ioutil.ReadAll(in)
 	if err != nil {
 		log.Die("Failed to read template file: %s", err)
 	}
 
-	if err := renderTemplate(out, string(tpl), vals); err != nil {
+	if err := renderTemplate(out, name, string(tpl), chartDir, vals); err != nil {
 		log.Die("Template rendering failed: %s", err)
 	}
 }
 
 // Template renders a template to an output file.
-func Template(out, in, data string, force bool) error {
-	var dest io.Writer
-	_, err = os.Stat(out)
-	if !(force || os.Getenv("HELM_FORCE_FLAG") == "true") && err == nil {
+//
+// If the template is in a chart that has a values schema, the values are
+// validated against it first, and nothing is rendered if they do not
+// conform. skipSchema (or $HELM_SKIP_SCHEMA, which generators are given)
+// skips the validation.
+//
+// The template is rendered with the values, the chart's metadata, and its
+// files. See templateContext. The values are those of the chart's
+// values.yaml, if it has one, the values file data, and the value sources,
+// and those that a generator is given in $HELM_VALUES_FILES, $HELM_SET, and
+// $HELM_SET_FROM, merged in that order; see ValueSources. If a source cannot
+// be read, nothing is rendered.
+func Template(out, in, data string, force, skipSchema bool, sources ValueSources) error {
+	return renderFile(out, in, data, force, skipSchema, sources, os.Getenv, log.Stdout)
+}
+
+// renderGenerator renders the template of a generator in this process, as
+// Template would if the generator executed 'helmc template'. The generator's
+// environment is read from j.Env before that of helmc.
+func renderGenerator(j *generator.TemplateJob) error {
+	getenv := func(k string) string {
+		if v, ok := j.Env[k]; ok {
+			return v
+		}
+		return os.Getenv(k)
+	}
+	sources := ValueSources{Set: j.Set, SetFrom: j.SetFrom, AllowExec: j.AllowExec, dir: j.Dir}
+	return renderFile(j.Out, j.Template, j.Values, j.Force, j.SkipSchema, sources, getenv, j.Stdout)
+}
+
+// templateReads returns the partials that the template of a generator is
+// rendered with, so that a change to one runs the generator again.
+func templateReads(j *generator.TemplateJob) []string {
+	partials, err := chartPartials(j.Dir, templateName(j.Dir, j.Template))
+	if err != nil {
+		return nil
+	}
+	res := make([]string, len(partials))
+	for i, p := range partials {
+		res[i] = filepath.Join(j.Dir, filepath.FromSlash(p))
+	}
+	return res
+}
+
+// renderFile is Template, with the environment read from getenv, and the
+// template written to stdout if out is "".
+func renderFile(out, in, data string, force, skipSchema bool, sources ValueSources, getenv func(string) string, stdout io.Writer) error {
+	if _, err := os.Stat(out); !(force || getenv("HELM_FORCE_FLAG") == "true") && err == nil {
 		return fmt.Errorf("File %s already exists. To overwrite it, please re-run this command with the --force/-f flag.", out)
 	}
+
+	chartDir := templateChart(in, getenv)
+	// The flags and the values file of a generator are the chart's, so they
+	// may not read sources: only those that helmc gives it in its
+	// environment, from the user, are read.
+	generating := getenv("HELM_GENERATE_FILE") != ""
+	if generating {
+		if len(sources.SetFrom) > 0 {
+			return fmt.Errorf("The generator of %s gives --set-from, which only the user may give, to 'helmc generate' or 'helmc install --generate'", in)
+		}
+		sources.AllowExec = false
+	}
+	sources = sources.withEnv(getenv)
+	vals, err := chartValues(chartDir, sources.Env)
+	if err != nil {
+		return err
+	}
+	if data != "" {
+		dv, err := openValues(data)
+		if err != nil {
+			return fmt.Errorf("Error opening value file: %s", err)
+		}
+		if !generating {
+			// The values file of 'helmc template' run by hand is the
+			// user's.
+			if dv, err = sources.applyValueFrom(dv, ""); err != nil {
+				return fmt.Errorf("Could not read the values of %s: %s", data, err)
+			}
+		}
+		vals = mergeValues(vals, dv)
+	}
+	log.Debug("Vals: %#v", vals)
+	if err := sources.Check(); err != nil {
+		return err
+	}
+	vals, err = sources.apply(vals)
+	if err != nil {
+		return fmt.Errorf("Could not read the values of %s: %s", in, err)
+	}
+	if !(skipSchema || getenv("HELM_SKIP_SCHEMA") == "true") {
+		if err := checkSchema(chartDir, data, vals, nil); err != nil {
+			return err
+		}
+	}
+
+	tpl, err := ioutil.ReadFile(in)
+	if err != nil {
+		return fmt.Errorf("Failed to open template file: %s", err)
+	}
+	dest := stdout
 	if out != "" {
 		f, err := os.Create(out)
 		if err != nil {
-			log.Die("Failed to open %s: %s", out, err)
+			return fmt.Errorf("Failed to open %s: %s", out, err)
 		}
 		defer func() {
 			if err := f.Close(); err != nil {
@@ -47,26 +159,196 @@ func Template(out, in, data string, force bool) error {
 			}
 		}()
 		dest = f
-	} else {
-		dest = log.Stdout
 	}
+	if err := renderTemplate(dest, templateName(chartDir, in), string(tpl), chartDir, vals); err != nil {
+		return fmt.Errorf("Template rendering failed: %s", err)
+	}
+	return nil
+}
+
+// TemplatesDir is the directory of a chart that holds its templates, and
+// PartialPrefix begins the names of its partials.
+const (
+	TemplatesDir  = "tpl"
+	PartialPrefix = "_"
+)
+
+// chartPartials returns the partials of the chart in chartDir that a
+// template called name is rendered with, by their slash-separated paths
+// relative to the chart: the .tpl files whose names begin with
+// PartialPrefix, such as tpl/_helpers.tpl, in TemplatesDir and then in the
+// directory of the template, whose templates win over those of the same
+// name. The template itself is left out, and a template that is not in a
+// chart has none.
+func chartPartials(chartDir, name string) ([]string, error) {
+	if chartDir == "" || filepath.IsAbs(name) || strings.HasPrefix(name, "..") {
+		return nil, nil
+	}
+	dirs := []string{TemplatesDir}
+	if d := path.Dir(name); d != TemplatesDir {
+		dirs = append(dirs, d)
+	}
+	partials := []string{}
+	for _, d := range dirs {
+		matches, err := filepath.Glob(filepath.Join(chartDir, filepath.FromSlash(d), PartialPrefix+"*.tpl"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(matches)
+		for _, m := range matches {
+			if p := path.Join(d, filepath.Base(m)); p != name {
+				partials = append(partials, p)
+			}
+		}
+	}
+	return partials, nil
+}
+
+// templateChart returns the directory of the chart a template belongs to, or
+// "" if it is not in a chart.
+//
+// A generator is given the chart in $HELM_CHART_PATH. Otherwise, the chart
+// is the nearest directory above the template with a Chart.yaml.
+func templateChart(tpl string, getenv func(string) string) string {
+	if p := getenv(helm.EnvChartPath); p != "" {
+		return p
+	}
+	dir, err := filepath.Abs(filepath.Dir(tpl))
+	if err != nil {
+		return ""
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(dir, Chartfile)); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
 
-	inReader, err := os.Open(in)
+// templateName names a template in errors, by its path relative to its chart.
+func templateName(chartDir, tpl string) string {
+	if abs, err := filepath.Abs(tpl); err == nil && chartDir != "" {
+		if rel, err := filepath.Rel(chartDir, abs); err == nil && !strings.HasPrefix(rel, "..") {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return tpl
+}
+
+// checkSchema validates values against the schema of a chart, if it has one
+// (see chart.LoadValuesSchema). source names the values in the error, which
+// lists every violation that skip, if it is not nil, does not skip.
+func checkSchema(chartDir, source string, vals interface{}, skip func(*chart.SchemaError) bool) error {
+	if chartDir == "" {
+		return nil
+	}
+	schema, where, err := chart.LoadValuesSchema(chartDir)
+	name := schemaName(chartDir, where)
 	if err != nil {
-		log.Die("Failed to open template file: %s", err)
+		return fmt.Errorf("Could not load %s: %s", name, err)
+	}
+	if schema == nil {
+		return nil
+	}
+	lines := []string{}
+	for _, v := range schema.Validate(vals) {
+		if skip == nil || !skip(v) {
+			lines = append(lines, v.Error())
+		}
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	if source == "" {
+		source = "no values file"
+	}
+	return fmt.Errorf("The values (%s) do not conform to %s. Rerun with --skip-schema to render anyway.\n\t%s", source, name, strings.Join(lines, "\n\t"))
+}
+
+// schemaName names where the schema of the chart in chartDir is declared, as
+// chart.LoadValuesSchema says, for a message.
+func schemaName(chartDir, where string) string {
+	if where == chart.InSchemaFile {
+		return filepath.Join(chartDir, where)
+	}
+	return "the " + strings.Replace(where, Chartfile, filepath.Join(chartDir, Chartfile), 1)
+}
+
+// checkValues validates the values that sources give the templates of the
+// chart in chartPath against its schema, before any of its generators runs,
+// so that a missing or mistyped key fails the generate, or the install, at
+// once. The sources of SetFrom and of the valueFrom mappings are not read:
+// their keys only have to be set.
+//
+// A required key is not reported if a template of the chart is rendered with
+// values of its own, as with 'helmc template -d', which may set it. Each
+// template is checked again, with all of its values, as it is rendered.
+func checkValues(chartPath string, exclude []string, env map[string]string, sources ValueSources) error {
+	vals, err := chartValues(chartPath, sources.Env)
+	if err != nil {
+		return err
+	}
+	sources.sourced = map[string]bool{}
+	if vals, err = sources.apply(vals); err != nil {
+		return err
+	}
+	own := false
+	if jobs, err := generator.Templates(chartPath, exclude, env); err == nil {
+		for _, j := range jobs {
+			own = own || j.Values != "" || len(j.Set) > 0 || len(j.SetFrom) > 0
+		}
+	}
+	skip := func(e *chart.SchemaError) bool {
+		return sources.sourced[e.Path] || e.Missing && own
 	}
+	return checkSchema(chartPath, sourcesName(sources), vals, skip)
+}
+
+// sourcesName names the values that sources give a chart, for a message.
+func sourcesName(sources ValueSources) string {
+	names := []string{chart.ValuesFile}
+	if sources.Env != "" {
+		names = append(names, chart.EnvValuesFile(sources.Env))
+	}
+	names = append(names, sources.Files...)
+	if len(sources.Set) > 0 {
+		names = append(names, "--set")
+	}
+	if len(sources.SetFrom) > 0 {
+		names = append(names, "--set-from")
+	}
+	return strings.Join(names, ", ")
+}
 
+// chartValues returns the values of the chart in chartDir, from its
+// values.yaml, or nil if it has none, or chartDir is "". If env is set, the
+// values file of the environment, such as values-staging.yaml, is merged
+// over them.
+func chartValues(chartDir, env string) (interface{}, error) {
+	if chartDir == "" {
+		return nil, nil
+	}
+	files := []string{chart.ValuesFile}
+	if env != "" {
+		files = append(files, chart.EnvValuesFile(env))
+	}
 	var vals interface{}
-	if data != "" {
-		var err error
-		vals, err = openValues(data)
+	for _, f := range files {
+		path := filepath.Join(chartDir, f)
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			continue
+		}
+		fv, err := readValues(path)
 		if err != nil {
-			log.Die("Error opening value file: %s", err)
+			return nil, fmt.Errorf("Could not read %s: %s", path, err)
 		}
+		vals = mergeValues(vals, fv)
 	}
-
-	GenerateTemplate(dest, inReader, vals)
-	return nil
+	return vals, nil
 }
 
 // openValues opens a values file and tries to parse it with the right parser.
@@ -74,13 +356,22 @@ func Template(out, in, data string, force bool) error {
 // It returns an interface{} containing data, if found. Any error opening or
 // parsing the file will be passed back.
 func openValues(filename string) (interface{}, error) {
-	data, err := ioutil.ReadFile(filename)
-	if err != nil {
+	if _, err := os.Stat(filename); err != nil {
 		// We generate a warning here, but do not require that a values
 		// file exists.
 		log.Warn("Skipped file %s: %s", filename, err)
 		return map[string]interface{}{}, nil
 	}
+	return readValues(filename)
+}
+
+// readValues parses a values file with the parser of its extension. Unlike
+// openValues, a file that cannot be read is an error.
+func readValues(filename string) (interface{}, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
 
 	ext := filepath.Ext(filename)
 	var um func(p []byte, v interface{}) error
@@ -102,16 +393,38 @@ func openValues(filename string) (interface{}, error) {
 
 // renderTemplate renders a template and values into an output stream.
 //
-// tpl should be a string template.
-func renderTemplate(out io.Writer, tpl string, vals interface{}) error {
-	t, err := template.New("helmTpl").Funcs(sprig.TxtFuncMap()).Parse(tpl)
+// tpl should be a string template, which is called name in errors, and is in
+// the chart in chartDir, or in no chart if chartDir is "". The partials of
+// the chart (see chartPartials) are parsed with it, so that it can use their
+// templates with 'template' and 'include'.
+func renderTemplate(out io.Writer, name, tpl, chartDir string, vals interface{}) error {
+	t := template.New(name)
+	funcs := templateFuncs()
+	funcs["include"] = includeFunc(t)
+	t.Funcs(funcs)
+	partials, err := chartPartials(chartDir, name)
 	if err != nil {
 		return err
 	}
+	for _, p := range partials {
+		b, err := ioutil.ReadFile(filepath.Join(chartDir, filepath.FromSlash(p)))
+		if err != nil {
+			return err
+		}
+		if _, err := t.New(p).Parse(string(b)); err != nil {
+			return err
+		}
+	}
+	if _, err := t.Parse(tpl); err != nil {
+		return err
+	}
 
-	log.Debug("Vals: %#v", vals)
+	ctx, err := templateContext(chartDir, vals)
+	if err != nil {
+		return err
+	}
 
-	if err = t.ExecuteTemplate(out, "helmTpl", vals); err != nil {
+	if err = t.ExecuteTemplate(out, name, ctx); err != nil {
 		return err
 	}
 	return nil
@@ -0,0 +1,235 @@
+package action
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/Masterminds/sprig"
+	"github.com/helm/helm-classic/chart"
+	"github.com/helm/helm-classic/log"
+	"gopkg.in/yaml.v2"
+)
+
+// reservedContext are the top-level names of the template context. A value
+// with one of these names is only available under .Values.
+var reservedContext = []string{"Chart", "Files", "Release", "Values"}
+
+// templateFuncs returns the functions of templates: those of Sprig, and the
+// helpers that charts rely on, whose semantics are fixed here so that they do
+// not change with Sprig.
+func templateFuncs() template.FuncMap {
+	f := sprig.TxtFuncMap()
+	for k, v := range helperFuncs {
+		f[k] = v
+	}
+	return f
+}
+
+var helperFuncs = template.FuncMap{
+	"default":  defaultValue,
+	"quote":    quote,
+	"indent":   indent,
+	"toYaml":   toYaml,
+	"b64enc":   b64enc,
+	"trunc":    trunc,
+	"required": required,
+}
+
+// maxIncludeDepth is how deep include may nest, so that a partial that
+// includes itself fails instead of running forever.
+const maxIncludeDepth = 100
+
+// includeFunc returns the include function of the templates of t, which
+// renders the named template of t with data and returns the result, so that,
+// unlike the 'template' action, it can be piped:
+//
+//	{{include "labels" . | indent 4}}
+func includeFunc(t *template.Template) func(string, interface{}) (string, error) {
+	depth := 0
+	return func(name string, data interface{}) (string, error) {
+		if depth >= maxIncludeDepth {
+			return "", fmt.Errorf("include %q: nested more than %d deep", name, maxIncludeDepth)
+		}
+		depth++
+		defer func() { depth-- }()
+		var b bytes.Buffer
+		if err := t.ExecuteTemplate(&b, name, data); err != nil {
+			return "", err
+		}
+		return b.String(), nil
+	}
+}
+
+// defaultValue returns given, unless it is missing or empty, in which case it
+// returns d.
+//
+//	{{default "nginx" .Values.image}}
+//
+// nil, false, 0, "", and empty lists and maps are empty.
+func defaultValue(d interface{}, given ...interface{}) interface{} {
+	if len(given) == 0 || isEmpty(given[0]) {
+		return d
+	}
+	return given[0]
+}
+
+// quote returns each value as a double-quoted string, separated by spaces.
+// nil values are left out.
+func quote(v ...interface{}) string {
+	res := make([]string, 0, len(v))
+	for _, s := range v {
+		if s != nil {
+			res = append(res, strconv.Quote(fmt.Sprint(s)))
+		}
+	}
+	return strings.Join(res, " ")
+}
+
+// indent prefixes every line of s, including the first, with n spaces.
+func indent(n int, s string) string {
+	pad := strings.Repeat(" ", n)
+	return pad + strings.Replace(s, "\n", "\n"+pad, -1)
+}
+
+// toYaml returns v as YAML, without the final newline, so that it can be
+// piped to indent.
+func toYaml(v interface{}) (string, error) {
+	b, err := yaml.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(b), "\n"), nil
+}
+
+// b64enc returns the standard base-64 encoding of v.
+func b64enc(v interface{}) string {
+	return base64.StdEncoding.EncodeToString([]byte(fmt.Sprint(v)))
+}
+
+// trunc returns the first n characters of s, or, if n is negative, the last
+// -n. A string that is no longer than that is returned as it is.
+func trunc(n int, s string) string {
+	r := []rune(s)
+	switch {
+	case n >= 0 && len(r) > n:
+		return string(r[:n])
+	case n < 0 && len(r) > -n:
+		return string(r[len(r)+n:])
+	}
+	return s
+}
+
+// required returns v, or fails rendering with msg if v is missing or the
+// empty string.
+//
+//	{{required "image is needed to run the pod" .Values.image}}
+func required(msg string, v interface{}) (interface{}, error) {
+	if s, ok := v.(string); v == nil || (ok && s == "") {
+		return nil, errors.New(msg)
+	}
+	return v, nil
+}
+
+// isEmpty reports whether a value is nil or the zero value of its type, or
+// an empty list or map.
+func isEmpty(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return rv.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return rv.IsNil()
+	case reflect.Bool:
+		return !rv.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return rv.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return rv.Float() == 0
+	}
+	return false
+}
+
+// templateContext returns what a template of the chart in chartDir is
+// rendered with:
+//
+// - .Values: the values
+// - .Chart: the chart's Chart.yaml, such as .Chart.Name and .Chart.Version
+// - .Release.Name: the name the chart has in the workspace, which differs
+// from .Chart.Name if it was fetched under another name
+// - .Files: the files of the chart, as in {{.Files.Get "config/nginx.conf"}}
+//
+// So that older templates keep working, the top-level values are also in the
+// context, as in {{.Namespace}}, unless their names are taken.
+//
+// chartDir is "" for a template that is not in a chart, which has an empty
+// .Chart and no .Files.
+func templateContext(chartDir string, vals interface{}) (map[string]interface{}, error) {
+	ctx := map[string]interface{}{}
+	switch m := vals.(type) {
+	case map[string]interface{}:
+		for k, v := range m {
+			ctx[k] = v
+		}
+	case map[interface{}]interface{}:
+		for k, v := range m {
+			ctx[fmt.Sprint(k)] = v
+		}
+	}
+	for _, k := range reservedContext {
+		if _, ok := ctx[k]; ok {
+			log.Warn("The value %s is hidden by the template's .%s. Use .Values.%s instead.", k, k, k)
+		}
+	}
+
+	if vals == nil {
+		vals = map[string]interface{}{}
+	}
+	chartfile := &chart.Chartfile{}
+	release := ""
+	if chartDir != "" {
+		var err error
+		if chartfile, err = chart.LoadChartfile(filepath.Join(chartDir, Chartfile)); err != nil {
+			return nil, fmt.Errorf("Could not load the %s of %s: %s", Chartfile, chartDir, err)
+		}
+		release = filepath.Base(chartDir)
+	}
+	ctx["Values"] = vals
+	ctx["Chart"] = chartfile
+	ctx["Release"] = map[string]interface{}{"Name": release}
+	ctx["Files"] = chartFiles(chartDir)
+	return ctx, nil
+}
+
+// chartFiles are the files of a chart, for templates.
+type chartFiles string
+
+// Get returns the contents of a file of the chart, by its path relative to the
+// chart. The contents are not rendered. It is an error if the file does not
+// exist or is outside of the chart.
+func (f chartFiles) Get(name string) (string, error) {
+	if f == "" {
+		return "", fmt.Errorf("cannot get %s: the template is not in a chart", name)
+	}
+	clean := filepath.Clean(filepath.FromSlash(name))
+	if filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("cannot get %s: it is not in the chart", name)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(string(f), clean))
+	if err != nil {
+		return "", fmt.Errorf("cannot get %s: %s", name, err)
+	}
+	return string(b), nil
+}
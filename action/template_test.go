@@ -2,10 +2,14 @@ package action
 
 import (
 	"bytes"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/helm/helm-classic/chart"
+	"github.com/helm/helm-classic/generator"
 	"github.com/helm/helm-classic/log"
 	"github.com/helm/helm-classic/test"
 	"github.com/helm/helm-classic/util"
@@ -21,20 +25,20 @@ func TestTemplate(t *testing.T) {
 	defer func() { log.Stdout = o }()
 
 	// TOML
-	Template("", tpl, val, false)
+	Template("", tpl, val, false, false, ValueSources{})
 	if out.String() != "Hello World!\n" {
 		t.Errorf("Expected Hello World!, got %q", out.String())
 	}
 
 	// force false
 	os.Setenv("HELM_FORCE_FLAG", "false")
-	if err = Template(tpl, val, "", false); err == nil {
+	if err = Template(tpl, val, "", false, false, ValueSources{}); err == nil {
 		t.Errorf("Expected error but got nil")
 	}
 	tpl1 := filepath.Join(dir, "two.yaml")
 	util.CopyFile(tpl, tpl1)
 	// force true
-	if err = Template(tpl1, val, "", true); err != nil {
+	if err = Template(tpl1, val, "", true, false, ValueSources{}); err != nil {
 		t.Errorf("error force-generating template (%s)", err.Error())
 	}
 	defer os.Remove(tpl1)
@@ -42,7 +46,7 @@ func TestTemplate(t *testing.T) {
 	// YAML
 	val = filepath.Join(dir, "one.yaml")
 	out.Reset()
-	Template("", tpl, val, false)
+	Template("", tpl, val, false, false, ValueSources{})
 	if out.String() != "Hello World!\n" {
 		t.Errorf("Expected Hello World!, got %q", out.String())
 	}
@@ -50,15 +54,212 @@ func TestTemplate(t *testing.T) {
 	// JSON
 	val = filepath.Join(dir, "one.json")
 	out.Reset()
-	Template("", tpl, val, false)
+	Template("", tpl, val, false, false, ValueSources{})
 	if out.String() != "Hello World!\n" {
 		t.Errorf("Expected Hello World!, got %q", out.String())
 	}
 
 	// No data
 	out.Reset()
-	Template("", tpl, "", false)
+	Template("", tpl, "", false, false, ValueSources{})
 	if out.String() != "Hello Clowns!\n" {
 		t.Errorf("Expected Hello Clowns!, got %q", out.String())
 	}
 }
+
+func TestTemplateSchema(t *testing.T) {
+	dir, err := ioutil.TempDir("", "helmc-schema-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		Chartfile:        "name: schema\nversion: 0.1.0\n",
+		chart.SchemaFile: "type: object\nadditionalProperties: false\nproperties:\n  image: {type: string}\n",
+		"tpl/pod.tpl":    "image: {{.image}}\n",
+		"tpl/good.yaml":  "image: redis\n",
+		"tpl/typo.yaml":  "imagee: redis\n",
+	}
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(p), 0755)
+		if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tpl := filepath.Join(dir, "tpl/pod.tpl")
+	var out bytes.Buffer
+	o := log.Stdout
+	log.Stdout = &out
+	defer func() { log.Stdout = o }()
+
+	if err := Template("", tpl, filepath.Join(dir, "tpl/good.yaml"), false, false, ValueSources{}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	test.ExpectEquals(t, out.String(), "image: redis\n")
+
+	out.Reset()
+	err = Template("", tpl, filepath.Join(dir, "tpl/typo.yaml"), false, false, ValueSources{})
+	if err == nil {
+		t.Fatal("Expected the values to be rejected")
+	}
+	test.ExpectContains(t, err.Error(), "imagee: is not a known key (expected one of image)")
+	test.ExpectEquals(t, out.String(), "")
+
+	if err := Template("", tpl, filepath.Join(dir, "tpl/typo.yaml"), false, true, ValueSources{}); err != nil {
+		t.Errorf("Expected --skip-schema to render, got %s", err)
+	}
+}
+
+func TestTemplateHelpers(t *testing.T) {
+	vals := map[string]interface{}{
+		"name":  "nginx",
+		"empty": "",
+		"zero":  0,
+		"list":  []interface{}{"a", "b"},
+		"map":   map[interface{}]interface{}{"port": 80, "host": "example.com"},
+	}
+	tests := []struct {
+		tpl, expect string
+	}{
+		{`{{default "x" .Values.name}}`, "nginx"},
+		{`{{default "x" .Values.missing}}`, "x"},
+		{`{{default "x" .Values.empty}}`, "x"},
+		{`{{default 8080 .Values.zero}}`, "8080"},
+		{`{{.Values.missing | default "x"}}`, "x"},
+		{`{{quote .Values.name}}`, `"nginx"`},
+		{`{{quote "a" 1 .Values.missing}}`, `"a" "1"`},
+		{`{{quote "say \"hi\""}}`, `"say \"hi\""`},
+		{`{{indent 2 "a\nb"}}`, "  a\n  b"},
+		{`{{toYaml .Values.list}}`, "- a\n- b"},
+		{`{{toYaml .Values.map | indent 2}}`, "  host: example.com\n  port: 80"},
+		{`{{b64enc .Values.name}}`, "bmdpbng="},
+		{`{{b64enc 42}}`, "NDI="},
+		{`{{trunc 3 .Values.name}}`, "ngi"},
+		{`{{trunc -2 .Values.name}}`, "nx"},
+		{`{{trunc 10 .Values.name}}`, "nginx"},
+		{`{{trunc 2 "héllo"}}`, "hé"},
+		{`{{required "name is needed" .Values.name}}`, "nginx"},
+		{`{{required "zero is set" .Values.zero}}`, "0"},
+		{`{{.name}}`, "nginx"},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		if err := renderTemplate(&out, "test", tt.tpl, "", vals); err != nil {
+			t.Errorf("%s: unexpected error: %s", tt.tpl, err)
+			continue
+		}
+		if out.String() != tt.expect {
+			t.Errorf("%s: expected %q, got %q", tt.tpl, tt.expect, out.String())
+		}
+	}
+}
+
+func TestTemplateRequired(t *testing.T) {
+	tpl := "image: {{.Values.image}}\nname: {{required \"name is needed\" .Values.name}}\n"
+	for _, vals := range []interface{}{nil, map[string]interface{}{"name": ""}} {
+		var out bytes.Buffer
+		err := renderTemplate(&out, "tpl/pod.tpl", tpl, "", vals)
+		if err == nil {
+			t.Fatalf("Expected %v to fail rendering", vals)
+		}
+		test.ExpectContains(t, err.Error(), "tpl/pod.tpl:2:")
+		test.ExpectContains(t, err.Error(), "name is needed")
+	}
+}
+
+func TestTemplateContext(t *testing.T) {
+	dir, err := ioutil.TempDir("", "helmc-context-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	dir = filepath.Join(dir, "cache")
+	files := map[string]string{
+		Chartfile:           "name: redis\nversion: 1.2.3\n",
+		"config/redis.conf": "maxmemory 2mb\n",
+		"tpl/pod.tpl": `chart: {{.Chart.Name}}-{{.Chart.Version}}
+release: {{.Release.Name}}
+image: {{.Values.image}} {{.image}}
+conf: {{.Files.Get "config/redis.conf" | b64enc}}
+`,
+		"tpl/escape.tpl":  `{{.Files.Get "../secret"}}`,
+		"tpl/values.yaml": "image: redis\n",
+	}
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(p), 0755)
+		if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var out bytes.Buffer
+	o := log.Stdout
+	log.Stdout = &out
+	defer func() { log.Stdout = o }()
+
+	if err := Template("", filepath.Join(dir, "tpl/pod.tpl"), filepath.Join(dir, "tpl/values.yaml"), false, false, ValueSources{}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	test.ExpectEquals(t, out.String(), "chart: redis-1.2.3\nrelease: cache\nimage: redis redis\nconf: bWF4bWVtb3J5IDJtYgo=\n")
+
+	err = renderTemplate(&out, "tpl/escape.tpl", files["tpl/escape.tpl"], dir, nil)
+	if err == nil {
+		t.Fatal("Expected a file outside of the chart to be refused")
+	}
+	test.ExpectContains(t, err.Error(), "is not in the chart")
+
+	err = renderTemplate(&out, "t", `{{.Files.Get "config/redis.conf"}}`, "", nil)
+	if err == nil {
+		t.Fatal("Expected .Files to be empty outside of a chart")
+	}
+	test.ExpectContains(t, err.Error(), "not in a chart")
+}
+
+func TestTemplatePartials(t *testing.T) {
+	dir, err := ioutil.TempDir("", "helmc-partials-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		Chartfile:               "name: web\nversion: 0.1.0\n",
+		"values.yaml":           "app: web\n",
+		"tpl/_helpers.tpl":      `{{define "labels"}}app: {{.Values.app}}{{"\n"}}chart: {{.Chart.Name}}{{end}}`,
+		"tpl/_loop.tpl":         `{{define "loop"}}{{include "loop" .}}{{end}}`,
+		"tpl/web.yaml":          "metadata:\n  labels:\n{{include \"labels\" . | indent 4}}\n",
+		"tpl/extra/svc.yaml":    `{{template "labels" .}} {{include "port" .}}`,
+		"tpl/extra/_ports.tpl":  `{{define "port"}}80{{end}}`,
+		"tpl/loop.yaml":         `{{include "loop" .}}`,
+		"manifests/_other.tpl":  `{{define "labels"}}wrong{{end}}`,
+		"manifests/plain.yaml":  `{{include "labels" .}}`,
+		"manifests/_ignore.txt": "",
+	}
+	for name, data := range files {
+		os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755)
+		ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0644)
+	}
+
+	out := filepath.Join(dir, "out.yaml")
+	render := func(tpl string) (string, error) {
+		err := Template(out, filepath.Join(dir, tpl), "", true, false, ValueSources{})
+		b, _ := ioutil.ReadFile(out)
+		return string(b), err
+	}
+	if s, err := render("tpl/web.yaml"); err != nil || s != "metadata:\n  labels:\n    app: web\n    chart: web\n" {
+		t.Errorf("Expected the included labels, got %q (%v)", s, err)
+	}
+	if s, err := render("tpl/extra/svc.yaml"); err != nil || s != "app: web\nchart: web 80" {
+		t.Errorf("Expected the partials of tpl and of the template's directory, got %q (%v)", s, err)
+	}
+	if s, err := render("manifests/plain.yaml"); err != nil || s != "wrong" {
+		t.Errorf("Expected the partials of the template's directory to win over those of tpl, got %q (%v)", s, err)
+	}
+	if _, err := render("tpl/loop.yaml"); err == nil || !strings.Contains(err.Error(), "nested more than") {
+		t.Errorf("Expected a partial that includes itself to fail, got %v", err)
+	}
+
+	// The partials are inputs of the generators of the templates.
+	reads := templateReads(&generator.TemplateJob{Dir: dir, Template: filepath.Join(dir, "tpl", "extra", "svc.yaml")})
+	test.ExpectEquals(t, strings.Join(reads, " "), strings.Join([]string{filepath.Join(dir, "tpl", "_helpers.tpl"), filepath.Join(dir, "tpl", "_loop.tpl"), filepath.Join(dir, "tpl", "extra", "_ports.tpl")}, " "))
+}
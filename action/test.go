@@ -0,0 +1,184 @@
+package action
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	helmerrors "github.com/helm/helm-classic/errors"
+	"github.com/helm/helm-classic/kubectl"
+	"github.com/helm/helm-classic/manifest"
+	helm "github.com/helm/helm-classic/util"
+)
+
+// The outcomes of a test, as TestResult.Status reports them.
+const (
+	TestPassed = "passed"
+	TestFailed = "failed"
+)
+
+// TestOptions control how Client.Test runs the tests of a chart.
+type TestOptions struct {
+	// Namespace is the namespace of the release. If it is empty, it is the
+	// namespace of the latest revision.
+	Namespace string
+	// Timeout bounds how long the tests may take, all together. Zero is
+	// hookTimeout.
+	Timeout time.Duration
+	// Keep leaves the tests in the cluster once they are done, rather than
+	// deleting them.
+	Keep bool
+}
+
+// TestResult is the outcome of a test of a chart.
+type TestResult struct {
+	Kind, Name, Namespace string
+	// Status is TestPassed or TestFailed.
+	Status string
+	// Reason says how a test failed.
+	Reason string
+	// Logs are the logs of a test that failed, if it has any.
+	Logs string
+	// Duration is how long after the tests were created the test was done.
+	Duration time.Duration
+}
+
+// Test runs the tests of the release of a workspace chart, and reports each.
+//
+// - chartName is the name of the chart in the workspace
+// - home is the home directory for the user
+// - namespace is the namespace of the release; if it is empty, it is the
+// namespace of the latest revision
+// - timeout bounds how long the tests may take; zero is five minutes
+// - keep leaves the tests in the cluster
+func Test(chartName, home, namespace string, timeout time.Duration, keep bool, client kubectl.Runner) error {
+	CheckClientPrereqs(client)
+	c := newClient(home, client)
+	c.Config = mustConfig(home)
+	_, err := c.Test(chartName, TestOptions{Namespace: namespace, Timeout: timeout, Keep: keep})
+	return err
+}
+
+// Test is like the package-level Test. It returns the outcome of each test,
+// even if some failed.
+//
+// The tests of a chart are the Pods and Jobs of its manifest.TestsDir, each
+// with the manifest.HookTest hook. They are created in the namespace of the
+// release, all at once, and each is then waited on until it completes, as a
+// hook is. Those that are done are deleted, unless Keep is set or they are
+// keepers (see manifest.IsKeeper).
+//
+// A test that could not be created, or that failed or did not complete in
+// time, fails, with its logs. If any failed, the error is a
+// *helmerrors.TestError.
+func (c *Client) Test(chartName string, opts TestOptions) (results []*TestResult, err error) {
+	defer c.completed(OpTest, chartName, c.started(OpTest, chartName), &err)
+	revs, err := c.History(chartName)
+	if err != nil {
+		return nil, err
+	}
+	if len(revs) == 0 {
+		return nil, fmt.Errorf("Chart %s has never been installed, so there is nothing to test. Install it with 'helmc install %s'.", chartName, chartName)
+	}
+	base := revs[len(revs)-1]
+	if opts.Namespace == "" {
+		opts.Namespace = base.Namespace
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = hookTimeout
+	}
+	tests, err := chartTests(helm.WorkspaceChartDirectory(c.Home, chartName))
+	if err != nil {
+		return nil, err
+	}
+	if len(tests) == 0 {
+		return nil, fmt.Errorf("Chart %s has no tests. Add Pods or Jobs with the annotation %s: %s to its %s directory.", chartName, manifest.HookAnnotation, manifest.HookTest, manifest.TestsDir)
+	}
+	defer c.useRetryEvents()()
+
+	c.Log.Info("Running %d tests of %s in namespace %s ...", len(tests), chartName, dash(opts.Namespace))
+	start := time.Now()
+	deadline := start.Add(opts.Timeout)
+	res := &InstallResult{Resources: []*ResourceResult{}}
+	ops := make([]*PlanOperation, len(tests))
+	for i, m := range tests {
+		data, err := m.VersionedObject.JSON()
+		if err != nil {
+			return nil, fmt.Errorf("Could not encode %s %s (%s): %s", m.Kind, m.Name, m.Source, err)
+		}
+		ops[i] = &PlanOperation{Op: ModeCreate, Kind: m.Kind, Name: m.Name, Namespace: opts.Namespace, Manifest: data, Hook: manifest.HookTest}
+		c.uploadManifest(ops[i], opts.Namespace, res)
+	}
+
+	e := &helmerrors.TestError{Chart: chartName, Total: len(tests)}
+	for i, rr := range res.Resources {
+		tr := &TestResult{Kind: rr.Kind, Name: rr.Name, Namespace: opts.Namespace, Status: TestPassed}
+		results = append(results, tr)
+		if rr.Status == StatusFailed {
+			tr.Status, tr.Reason = TestFailed, "it could not be created: "+rr.Error
+		} else if reason := c.waitForCompletion(rr, deadline, opts.Timeout); reason != "" {
+			tr.Status, tr.Reason = TestFailed, reason
+			if out, err := c.Kube.Logs(rr.Name, rr.Kind, rr.Namespace); err != nil {
+				c.Log.Warn("Could not read the logs of %s %s: %s", rr.Kind, rr.Name, failure(out, err))
+			} else {
+				tr.Logs = string(out)
+			}
+		}
+		tr.Duration = time.Since(start).Round(time.Second)
+		c.printTest(tr)
+		if tr.Status == TestFailed {
+			e.Failed = append(e.Failed, fmt.Sprintf("%s %s (%s)", tr.Kind, tr.Name, tr.Reason))
+		}
+		if rr.Status != StatusFailed {
+			c.cleanupTest(ops[i], rr, opts.Keep)
+		}
+	}
+
+	c.Log.Msg("%d passed, %d failed", len(tests)-len(e.Failed), len(e.Failed))
+	if len(e.Failed) > 0 {
+		return results, e
+	}
+	return results, nil
+}
+
+// chartTests returns the tests of the chart in dir. A manifest of its tests
+// directory that is not a Pod or a Job, or not a test, is an error.
+func chartTests(dir string) ([]*manifest.Manifest, error) {
+	ms, err := manifest.ParseTests(dir)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read the tests of %s: %s", dir, err)
+	}
+	for _, m := range ms {
+		if m.Kind != "Pod" && m.Kind != "Job" {
+			return nil, fmt.Errorf("%s %s (%s) is not a Pod or a Job, so it cannot be a test", m.Kind, m.Name, m.Source)
+		}
+		if h := hookOf(m); h != manifest.HookTest {
+			return nil, fmt.Errorf("%s %s (%s) does not have the annotation %s: %s", m.Kind, m.Name, m.Source, manifest.HookAnnotation, manifest.HookTest)
+		}
+	}
+	return ms, nil
+}
+
+// printTest prints the outcome of a test, with the logs of one that failed.
+func (c *Client) printTest(tr *TestResult) {
+	if tr.Status == TestPassed {
+		c.Log.Msg("PASS\t%s %s (%s)", tr.Kind, tr.Name, tr.Duration)
+		return
+	}
+	c.Log.Msg("FAIL\t%s %s (%s): %s", tr.Kind, tr.Name, tr.Duration, tr.Reason)
+	if logs := strings.TrimRight(tr.Logs, "\n"); logs != "" {
+		c.Log.Msg("\t%s", strings.Replace(logs, "\n", "\n\t", -1))
+	}
+}
+
+// cleanupTest deletes a test that is done, unless keep is set or it is a
+// keeper.
+func (c *Client) cleanupTest(op *PlanOperation, rr *ResourceResult, keep bool) {
+	if a := manifest.KeptBy(op.Manifest); keep || a != "" {
+		c.Log.Debug("Keeping the test %s %s", rr.Kind, rr.Name)
+		return
+	}
+	if out, err := c.Kube.Delete(rr.Name, rr.Kind, rr.Namespace); err != nil && !kubectl.IsNotFound(out) {
+		c.Log.Warn("Could not delete the test %s %s: %s", rr.Kind, rr.Name, failure(out, err))
+	}
+}
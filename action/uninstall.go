@@ -1,11 +1,15 @@
 package action
 
 import (
+	"encoding/json"
+	"fmt"
 	"io"
 	"strings"
+	"time"
 
 	"golang.org/x/crypto/ssh/terminal"
 
+	"github.com/helm/helm-classic/audit"
 	"github.com/helm/helm-classic/chart"
 	"github.com/helm/helm-classic/kubectl"
 	"github.com/helm/helm-classic/log"
@@ -13,17 +17,80 @@ import (
 	helm "github.com/helm/helm-classic/util"
 )
 
+// UninstallOptions control how Uninstall deletes a chart.
+type UninstallOptions struct {
+	// Yes deletes without asking for confirmation.
+	Yes bool
+	// Force also deletes the resources whose manifests are annotated to be
+	// kept, and does not ask for confirmation.
+	Force bool
+	// Wait is how long to wait for deleted resources to disappear. See Uninstall.
+	Wait time.Duration
+	// Keep lists resources that are not deleted, as kind/name. Kinds are
+	// matched regardless of case.
+	Keep []string
+	// KeepNamespaces keeps every Namespace of the chart.
+	KeepNamespaces bool
+	// NoHooks deletes the chart without running its pre-delete hooks.
+	NoHooks bool
+	// PurgeOrphans also deletes the orphans of the chart: the resources that
+	// an earlier version of it installed, and that it no longer has. See
+	// Client.Orphans.
+	PurgeOrphans bool
+	// Plan, if it is set, is a file that the plan of the uninstall is
+	// written to, instead. See PlanUninstall.
+	Plan string
+}
+
 // Uninstall removes a chart from Kubernetes.
 //
 // Manifests are removed from Kubernetes in the order specified by
-// chart.UninstallOrder. Any unknown types are removed before that sequence
-// is run.
-func Uninstall(chartName, home, namespace string, force bool, client kubectl.Runner) {
+// UninstallOrder, which is mostly the reverse of InstallOrder. Any unknown types are
+// removed before that sequence is run. Resources that are already gone are
+// not an error.
+//
+// The namespace must be given, or be in the chart's Chart.yaml (see
+// ChartNamespace).
+//
+// Resources named by o.Keep or o.KeepNamespaces are not deleted, nor are
+// keeper manifests (see manifest.IsKeeper) unless o.Force is set. They are
+// listed as kept in the summary. Hooks that the install deleted once they
+// completed are skipped.
+//
+// Before anything is deleted, the pre-delete hooks of the chart are created,
+// in install order, and each Job or Pod among them is waited on until it
+// completes, and then deleted, as an install does with its hooks. A hook that
+// fails stops the uninstall, and is left in the cluster. o.NoHooks skips them.
+//
+// If o.Wait is greater than zero, Uninstall waits for the resources of each
+// kind to disappear before deleting the next kind, for up to o.Wait in all.
+// Resources that are still present are reported, with their finalizers.
+//
+// With o.PurgeOrphans, the orphans of the chart are listed with its
+// resources, and deleted first, once the hooks ran, as Prune deletes them.
+func Uninstall(chartName, home, namespace string, o UninstallOptions, client kubectl.Runner) {
 	// This is a stop-gap until kubectl respects namespaces in manifests.
-	if namespace == "" {
+	if namespace = ChartNamespace(home, chartName, namespace); namespace == "" {
 		log.Die("This command requires a namespace. Did you mean '-n default'?")
 	}
-	if !chartFetched(chartName, home) {
+	for _, k := range o.Keep {
+		if kind, name := splitKeep(k); kind == "" || name == "" {
+			log.Die("Invalid --keep %q. Resources are kept as kind/name, e.g. Namespace/shared.", k)
+		}
+	}
+	if o.Plan != "" {
+		c := newClient(home, client)
+		p, err := c.PlanUninstall(chartName, namespace, o)
+		if err == nil {
+			err = c.savePlan(p, o.Plan)
+		}
+		if err != nil {
+			log.Die("%s", err)
+		}
+		return
+	}
+	CheckClientPrereqs(client)
+	if !chartFetched(chartName, home, nil) {
 		log.Info("No chart named %q in your workspace. Nothing to delete.", chartName)
 		return
 	}
@@ -33,21 +100,157 @@ func Uninstall(chartName, home, namespace string, force bool, client kubectl.Run
 	if err != nil {
 		log.Die("Failed to load chart: %s", err)
 	}
-	if err := deleteChart(c, namespace, true, client); err != nil {
+	hc := newClient(home, client)
+	restore, err := hc.useChartArgs(c)
+	if err != nil {
+		log.Die("%s", err)
+	}
+	defer restore()
+
+	if _, err := deleteChart(c, namespace, true, o, client); err != nil {
 		log.Die("Failed to list charts: %s", err)
 	}
-	if !force && !promptConfirm("Uninstall the listed objects?") {
+	var orphans []*OrphanResource
+	if o.PurgeOrphans {
+		if orphans, err = hc.Orphans(chartName, namespace); err != nil {
+			log.Die("Failed to list the orphans of %s: %s", chartName, err)
+		}
+		if len(orphans) > 0 {
+			log.Info("Orphans of %s, which it no longer has:", chartName)
+			printOrphans(orphans)
+		} else {
+			log.Info("%s has no orphaned resources in namespace %s.", chartName, namespace)
+		}
+	}
+	if !o.Yes && !o.Force && !promptConfirm("Uninstall the listed objects?") {
 		log.Info("Aborted uninstall")
 		return
 	}
 
-	CheckKubePrereqs()
+	if err := hc.deleteInstalled(c, cd, chartName, namespace, orphans, o); err != nil {
+		log.Die("%s", err)
+	}
+	log.Info("Done")
+}
+
+// Uninstall is like the package-level Uninstall, but it never prompts, and
+// it does not list what it deletes beforehand. A chart that is not in the
+// workspace is an error.
+func (c *Client) Uninstall(chartName, namespace string, o UninstallOptions) (err error) {
+	defer c.completed(OpUninstall, chartName, c.started(OpUninstall, chartName), &err)
+	if namespace = ChartNamespace(c.Home, chartName, namespace); namespace == "" {
+		return fmt.Errorf("Uninstalling %s requires a namespace", chartName)
+	}
+	if !chartFetched(chartName, c.Home, c.Log) {
+		return fmt.Errorf("No chart named %q in your workspace", chartName)
+	}
+	cd := helm.WorkspaceChartDirectory(c.Home, chartName)
+	ch, err := chart.Load(cd)
+	if err != nil {
+		return fmt.Errorf("Failed to load chart: %s", err)
+	}
+	restore, err := c.useChartArgs(ch)
+	if err != nil {
+		return err
+	}
+	defer restore()
+
+	var orphans []*OrphanResource
+	if o.PurgeOrphans {
+		if orphans, err = c.Orphans(chartName, namespace); err != nil {
+			return fmt.Errorf("Failed to list the orphans of %s: %s", chartName, err)
+		}
+	}
+	return c.deleteInstalled(ch, cd, chartName, namespace, orphans, o)
+}
+
+// deleteInstalled runs the pre-delete hooks of the chart ch, unless o.NoHooks
+// is set, deletes its orphans, and then its resources, and records the
+// uninstall in the audit log.
+func (c *Client) deleteInstalled(ch *chart.Chart, cd, chartName, namespace string, orphans []*OrphanResource, o UninstallOptions) error {
+	var err error
+	e := c.newAuditEntry(audit.OpUninstall, ch, cd, namespace)
+	if !o.NoHooks {
+		e.Resources, err = c.runPreDeleteHooks(preDeleteHooks(ch), namespace)
+		if err != nil {
+			c.recordAudit(e, err)
+			return fmt.Errorf("Failed to run the %s hooks, so nothing was deleted: %s", manifest.HookPreDelete, err)
+		}
+	}
+
+	if deletable(orphans) > 0 {
+		res, err := c.Prune(chartName, namespace, orphans)
+		printPruned(res)
+		if err != nil {
+			c.recordAudit(e, err)
+			return fmt.Errorf("Failed to delete the orphans of %s, so the chart was not deleted: %s", chartName, err)
+		}
+	}
 
 	log.Info("Running `kubectl delete` ...")
-	if err := deleteChart(c, namespace, false, client); err != nil {
-		log.Die("Failed to completely delete chart: %s", err)
+	sum, err := deleteChart(ch, namespace, false, o, c.Kube)
+	sum.print()
+	e.Resources = append(e.Resources, sum.resources...)
+	auditErr := err
+	if auditErr == nil && sum.failed > 0 {
+		auditErr = fmt.Errorf("%d resources could not be deleted", sum.failed)
+	}
+	c.recordAudit(e, auditErr)
+	if err != nil {
+		return fmt.Errorf("Failed to completely delete chart: %s", err)
+	}
+	return nil
+}
+
+// splitKeep splits a --keep value into its kind and name.
+func splitKeep(k string) (string, string) {
+	i := strings.Index(k, "/")
+	if i < 0 {
+		return "", ""
+	}
+	return k[:i], k[i+1:]
+}
+
+// keepReason returns why the manifest m of kind ktype is kept, or "" if it is
+// deleted.
+func (o UninstallOptions) keepReason(m *manifest.Manifest, ktype string) string {
+	if o.KeepNamespaces && ktype == "Namespace" {
+		return "--keep-namespaces"
+	}
+	for _, k := range o.Keep {
+		if kind, name := splitKeep(k); strings.EqualFold(kind, ktype) && name == m.Name {
+			return "--keep " + k
+		}
+	}
+	if o.Force {
+		return ""
+	}
+	if data, err := m.VersionedObject.JSON(); err == nil {
+		if a := manifest.KeptBy(data); a != "" {
+			return fmt.Sprintf("%q annotation", a)
+		}
+	}
+	return ""
+}
+
+// uninstallSummary counts what happened to each resource of a chart.
+type uninstallSummary struct {
+	deleted, gone, skipped, failed int
+	// kept are the resources that were not deleted, as kind/name, each with
+	// the reason it was kept.
+	kept []string
+	// resources are the resources that were deleted, or that could not be,
+	// for the audit log.
+	resources []*audit.Resource
+}
+
+// print reports the counts, and lists the kept resources so that operators
+// know they remain.
+func (s *uninstallSummary) print() {
+	log.Info("%d deleted, %d kept, %d already gone, %d skipped, %d failed", s.deleted, len(s.kept), s.gone, s.skipped, s.failed)
+	for _, k := range s.kept {
+		log.Msg("\tkept %s", k)
 	}
-	log.Info("Done")
 }
 
 // promptConfirm prompts a user to confirm (or deny) something.
@@ -106,40 +309,147 @@ func (x *rw) Write(b []byte) (int, error) {
 	return x.w.Write(b)
 }
 
+// pollInterval is how often deleted resources are checked while waiting.
+var pollInterval = 2 * time.Second
+
 // deleteChart deletes all of the Kubernetes manifests associated with this chart.
-func deleteChart(c *chart.Chart, ns string, dry bool, client kubectl.Runner) error {
+//
+// If o.Wait is greater than zero, it waits for each kind to be deleted, and
+// returns an error if any resources remain when the time is up.
+func deleteChart(c *chart.Chart, ns string, dry bool, o UninstallOptions, client kubectl.Runner) (*uninstallSummary, error) {
 	// Unknown kinds get uninstalled first because we know that core kinds
-	// do not depend on them.
-	for _, kind := range c.UnknownKinds(UninstallOrder) {
-		uninstallKind(c.Kind[kind], ns, kind, dry, client)
-	}
+	// do not depend on them. The known kinds follow in a particular order.
+	kinds := append(c.UnknownKinds(UninstallOrder), UninstallOrder...)
 
-	// Uninstall all of the known kinds in a particular order.
-	for _, kind := range UninstallOrder {
-		uninstallKind(c.Kind[kind], ns, kind, dry, client)
+	sum := &uninstallSummary{resources: []*audit.Resource{}}
+	deadline := time.Now().Add(o.Wait)
+	remaining := 0
+	for _, kind := range kinds {
+		deleted := uninstallKind(c.Kind[kind], ns, kind, dry, o, client, sum)
+		if dry || o.Wait <= 0 || len(deleted) == 0 {
+			continue
+		}
+		remaining += waitForDeletion(deleted, ns, kind, deadline, client)
 	}
 
-	return nil
+	if remaining > 0 {
+		return sum, fmt.Errorf("%d resources were not deleted within %s", remaining, o.Wait)
+	}
+	return sum, nil
 }
 
-func uninstallKind(kind []*manifest.Manifest, ns, ktype string, dry bool, client kubectl.Runner) {
-	for _, o := range kind {
+// uninstallKind deletes the manifests of one kind, counting them in sum, and
+// returns the names of those that were deleted.
+func uninstallKind(kind []*manifest.Manifest, ns, ktype string, dry bool, o UninstallOptions, client kubectl.Runner, sum *uninstallSummary) []string {
+	deleted := []string{}
+	for _, m := range kind {
+		reason := o.keepReason(m, ktype)
+		hook := hookOf(m)
 		if dry {
-			log.Msg("%s/%s", ktype, o.Name)
-		} else {
-			// If it's a keeper manifest, skip uninstall.
-			if data, err := o.VersionedObject.JSON(); err == nil {
-				if manifest.IsKeeper(data) {
-					log.Warn("Not uninstalling %s %s because of \"helm-keep\" annotation.\n"+
-						"---> Use kubectl to uninstall keeper manifests.\n", ktype, o.Name)
-					continue
-				}
+			switch {
+			case reason != "":
+				log.Msg("%s/%s (kept: %s)", ktype, m.Name, reason)
+			case hook != "":
+				log.Msg("%s/%s (%s hook)", ktype, m.Name, hook)
+			default:
+				log.Msg("%s/%s", ktype, m.Name)
 			}
-			out, err := client.Delete(o.Name, ktype, ns)
-			if err != nil {
-				log.Warn("Could not delete %s %s (Skipping): %s", ktype, o.Name, err)
+			continue
+		}
+		if reason != "" {
+			log.Info("Not uninstalling %s %s because of %s.", ktype, m.Name, reason)
+			sum.kept = append(sum.kept, fmt.Sprintf("%s/%s (%s)", ktype, m.Name, reason))
+			continue
+		}
+		if m.Name == "" {
+			log.Warn("Not uninstalling %s with a generated name. Use kubectl to find and delete it.", ktype)
+			sum.skipped++
+			continue
+		}
+		if hook != "" && hookDeleted(m.Name, ktype, ns, client) {
+			log.Info("Skipping the %s hook %s %s, which was deleted when it completed.", hook, ktype, m.Name)
+			sum.skipped++
+			continue
+		}
+		if deleteResource(m.Name, ktype, ns, client, sum) {
+			deleted = append(deleted, m.Name)
+		}
+	}
+	return deleted
+}
+
+// hookDeleted reports whether a hook is gone, as the install deletes hooks
+// once they complete.
+func hookDeleted(name, ktype, ns string, client kubectl.Runner) bool {
+	out, err := client.GetObject(name, ktype, ns)
+	return err != nil && kubectl.IsNotFound(out)
+}
+
+// deleteResource deletes a single resource, counting it in sum, and reports
+// whether it was deleted. A resource that is already gone is not.
+func deleteResource(name, ktype, ns string, client kubectl.Runner, sum *uninstallSummary) bool {
+	out, err := client.Delete(name, ktype, ns)
+	r := &audit.Resource{Kind: ktype, Name: name, Namespace: ns, Status: "deleted"}
+	sum.resources = append(sum.resources, r)
+	if err != nil {
+		if kubectl.IsNotFound(out) {
+			log.Info("%s %s is already gone", ktype, name)
+			r.Status = "gone"
+			sum.gone++
+			return false
+		}
+		log.Warn("Could not delete %s %s (Skipping): %s", ktype, name, err)
+		r.Status, r.Error = StatusFailed, failure(out, err)
+		sum.failed++
+		log.Info(string(out))
+		return false
+	}
+	sum.deleted++
+	log.Info(string(out))
+	return true
+}
+
+// waitForDeletion polls until the named resources are gone or the deadline
+// passes. It reports the resources that remain, and returns how many there are.
+func waitForDeletion(names []string, ns, ktype string, deadline time.Time, client kubectl.Runner) int {
+	log.Info("Waiting for %d %s resources to be deleted ...", len(names), ktype)
+	for {
+		left := []string{}
+		for _, name := range names {
+			if out, err := client.GetObject(name, ktype, ns); err == nil || !kubectl.IsNotFound(out) {
+				left = append(left, name)
 			}
-			log.Info(string(out))
 		}
+		names = left
+		if len(names) == 0 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(pollInterval)
+	}
+
+	for _, name := range names {
+		log.Warn("%s %s %s", ktype, name, terminatingState(name, ktype, ns, client))
+	}
+	return len(names)
+}
+
+// terminatingState describes why a deleted resource still exists.
+func terminatingState(name, ktype, ns string, client kubectl.Runner) string {
+	out, err := client.GetObject(name, ktype, ns)
+	if err != nil {
+		return "could not be checked: " + failure(out, err)
+	}
+	obj := struct {
+		Metadata struct {
+			DeletionTimestamp string   `json:"deletionTimestamp"`
+			Finalizers        []string `json:"finalizers"`
+		} `json:"metadata"`
+	}{}
+	if err := json.Unmarshal(out, &obj); err != nil || obj.Metadata.DeletionTimestamp == "" {
+		return "still exists"
+	}
+	if len(obj.Metadata.Finalizers) == 0 {
+		return "is stuck in Terminating"
 	}
+	return fmt.Sprintf("is stuck in Terminating, waiting for finalizers: %s", strings.Join(obj.Metadata.Finalizers, ", "))
 }
@@ -2,11 +2,16 @@ package action
 
 import (
 	"errors"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/helm/helm-classic/kubectl"
 	"github.com/helm/helm-classic/test"
+	"github.com/helm/helm-classic/util"
 )
 
 func TestUninstall(t *testing.T) {
@@ -49,10 +54,10 @@ func TestUninstall(t *testing.T) {
 	test.FakeUpdate(tmpHome)
 
 	for _, tt := range tests {
-		Fetch(tt.chart, "", tmpHome)
+		Fetch(tt.chart, "", tmpHome, FetchOptions{})
 
 		actual := test.CaptureOutput(func() {
-			Uninstall(tt.chart, tmpHome, "default", tt.force, tt.client)
+			Uninstall(tt.chart, tmpHome, "default", UninstallOptions{Yes: tt.force}, tt.client)
 		})
 
 		for _, exp := range tt.expected {
@@ -60,3 +65,201 @@ func TestUninstall(t *testing.T) {
 		}
 	}
 }
+
+func TestUninstallOrder(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+	test.FakeUpdate(tmpHome)
+	Fetch("kitchensink", "", tmpHome, FetchOptions{})
+
+	client := &kubectl.FakeRunner{}
+	test.CaptureOutput(func() {
+		Uninstall("kitchensink", tmpHome, "default", UninstallOptions{Yes: true}, client)
+	})
+
+	if len(client.Calls) == 0 {
+		t.Fatal("Expected resources to be deleted")
+	}
+	order := map[string]int{}
+	for i, k := range UninstallOrder {
+		order[k] = i
+	}
+	last := -1
+	for _, c := range client.Calls {
+		kind := strings.Fields(c)[1]
+		i, known := order[kind]
+		if !known {
+			if last >= 0 {
+				t.Errorf("Expected unknown kind %s to be deleted before the known kinds", kind)
+			}
+			continue
+		}
+		if i < last {
+			t.Errorf("Expected %s to be deleted earlier: %v", kind, client.Calls)
+		}
+		last = i
+	}
+}
+
+func TestUninstallKeep(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+	test.FakeUpdate(tmpHome)
+	Fetch("kitchensink", "", tmpHome, FetchOptions{})
+	Fetch("keep", "", tmpHome, FetchOptions{})
+
+	client := &kubectl.FakeRunner{}
+	actual := test.CaptureOutput(func() {
+		Uninstall("kitchensink", tmpHome, "default", UninstallOptions{Yes: true, Keep: []string{"configmap/drone"}, KeepNamespaces: true}, client)
+	})
+	for _, c := range client.Calls {
+		if strings.HasPrefix(c, "delete Namespace ") || strings.HasPrefix(c, "delete ConfigMap drone ") {
+			t.Errorf("Expected %q not to be called", c)
+		}
+	}
+	test.ExpectContains(t, actual, "ConfigMap/drone (kept: --keep configmap/drone)")
+	test.ExpectContains(t, actual, "kept ConfigMap/drone (--keep configmap/drone)")
+	test.ExpectContains(t, actual, "kept Namespace/kitchensink (--keep-namespaces)")
+	test.ExpectMatches(t, actual, `\d+ deleted, 2 kept, 0 already gone, \d+ skipped, 0 failed`)
+
+	// The annotation keeps a resource, unless forced.
+	client = &kubectl.FakeRunner{}
+	actual = test.CaptureOutput(func() {
+		Uninstall("keep", tmpHome, "default", UninstallOptions{Yes: true}, client)
+	})
+	test.ExpectContains(t, actual, `kept Namespace/keep ("helm-keep" annotation)`)
+	if len(client.Calls) != 0 {
+		t.Errorf("Expected nothing to be deleted, got %v", client.Calls)
+	}
+	client = &kubectl.FakeRunner{}
+	actual = test.CaptureOutput(func() {
+		Uninstall("keep", tmpHome, "default", UninstallOptions{Force: true}, client)
+	})
+	test.ExpectContains(t, actual, "1 deleted, 0 kept")
+	if len(client.Calls) != 1 || client.Calls[0] != "delete Namespace keep default" {
+		t.Errorf("Expected the namespace to be deleted, got %v", client.Calls)
+	}
+}
+
+func TestUninstallOrderKinds(t *testing.T) {
+	if len(UninstallOrder) != len(InstallOrder) {
+		t.Fatalf("Expected UninstallOrder to have the kinds of InstallOrder")
+	}
+	for _, k := range InstallOrder {
+		if sortIndex(k, UninstallOrder) == len(UninstallOrder) {
+			t.Errorf("Expected %s to be uninstalled", k)
+		}
+	}
+	// Traffic stops first, then the controllers go, then what they use.
+	want := []string{"Ingress", "Service", "Deployment", "ConfigMap", "Secret", "Namespace"}
+	for i := 1; i < len(want); i++ {
+		if sortIndex(want[i-1], UninstallOrder) > sortIndex(want[i], UninstallOrder) {
+			t.Errorf("Expected %s to be uninstalled before %s: %v", want[i-1], want[i], UninstallOrder)
+		}
+	}
+}
+
+// stuckRunner deletes resources, but they stay in Terminating. Pods are already gone.
+type stuckRunner struct {
+	kubectl.FakeRunner
+}
+
+func (r *stuckRunner) Delete(name, ktype, ns string) ([]byte, error) {
+	r.FakeRunner.Delete(name, ktype, ns)
+	if ktype == "Pod" {
+		return []byte(`Error from server: pods "` + name + `" not found`), errors.New("exit status 1")
+	}
+	return []byte(ktype + " deleted"), nil
+}
+
+func (r *stuckRunner) GetObject(name, ktype, ns string) ([]byte, error) {
+	r.FakeRunner.GetObject(name, ktype, ns)
+	return []byte(`{"metadata": {"deletionTimestamp": "2016-05-01T00:00:00Z", "finalizers": ["example.com/cleanup"]}}`), nil
+}
+
+func TestUninstallWait(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+	test.FakeUpdate(tmpHome)
+	Fetch("redis", "", tmpHome, FetchOptions{})
+	Fetch("kitchensink", "", tmpHome, FetchOptions{})
+
+	defer func(d time.Duration) { pollInterval = d }(pollInterval)
+	pollInterval = time.Millisecond
+
+	// redis only has a pod, which is already gone.
+	client := &stuckRunner{}
+	actual := test.CaptureOutput(func() {
+		Uninstall("redis", tmpHome, "default", UninstallOptions{Yes: true, Wait: time.Second}, client)
+	})
+	test.ExpectContains(t, actual, "Pod redis is already gone")
+	test.ExpectContains(t, actual, "Done")
+
+	client = &stuckRunner{}
+	actual = test.CaptureOutput(func() {
+		Uninstall("kitchensink", tmpHome, "default", UninstallOptions{Yes: true, Wait: 10 * time.Millisecond}, client)
+	})
+	test.ExpectContains(t, actual, "resources were not deleted within 10ms")
+	gets := 0
+	for _, c := range client.Calls {
+		if strings.HasPrefix(c, "get ") {
+			gets++
+		}
+	}
+	if gets == 0 {
+		t.Errorf("Expected deleted resources to be polled: %v", client.Calls)
+	}
+}
+
+func TestTerminatingState(t *testing.T) {
+	state := terminatingState("data", "PersistentVolume", "", &stuckRunner{})
+	if state != "is stuck in Terminating, waiting for finalizers: example.com/cleanup" {
+		t.Errorf("Unexpected state %q", state)
+	}
+	state = terminatingState("data", "PersistentVolume", "", &kubectl.FakeRunner{Out: []byte(`{"metadata": {}}`)})
+	if state != "still exists" {
+		t.Errorf("Unexpected state %q", state)
+	}
+}
+
+func TestUninstallPurgeOrphans(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+	test.FakeUpdate(tmpHome)
+
+	// The install records a Service that the chart then loses.
+	r := &pruneRunner{}
+	manifests := util.WorkspaceChartDirectory(tmpHome, "redis", "manifests")
+	svc := filepath.Join(manifests, "redis-admin.yaml")
+	test.CaptureOutput(func() {
+		Fetch("redis", "", tmpHome, FetchOptions{})
+		if err := ioutil.WriteFile(svc, []byte("apiVersion: v1\nkind: Service\nmetadata:\n  name: redis-admin\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := Install("redis", tmpHome, InstallOptions{Namespace: "cache", Force: true, Annotate: true, Labels: true}, r); err != nil {
+			t.Fatal(err)
+		}
+	})
+	os.Remove(svc)
+
+	r.Calls = nil
+	actual := test.CaptureOutput(func() {
+		Uninstall("redis", tmpHome, "cache", UninstallOptions{Yes: true, PurgeOrphans: true}, r)
+	})
+	test.ExpectContains(t, actual, "Orphans of redis")
+	deleted := []string{}
+	for _, call := range r.Calls {
+		if strings.HasPrefix(call, "delete") {
+			deleted = append(deleted, call)
+		}
+	}
+	// The orphans go first, in uninstall order, and the kept ConfigMap stays.
+	test.ExpectEquals(t, strings.Join(deleted, ", "), "delete Service redis cache, delete Service redis-admin cache, delete Namespace redis-old cache, delete Pod redis cache")
+
+	p, err := newClient(tmpHome, r).PlanUninstall("redis", "cache", UninstallOptions{PurgeOrphans: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.ExpectEquals(t, len(p.Operations), 4)
+	test.ExpectEquals(t, p.Kept[0], `ConfigMap/redis-conf ("helm.sh/resource-policy" annotation)`)
+}
@@ -10,7 +10,13 @@ import (
 )
 
 // Update fetches the remote repo into the home directory.
-func Update(home string) {
+//
+// If failFast is true, the first repository that fails to update stops the update.
+// Otherwise, up to jobs repositories are updated at once; zero is
+// config.MaxParallelUpdates. If reindex is true, the metadata index of each Git repository and directory
+// mirror is then regenerated from scratch, instead of only for the charts that
+// changed.
+func Update(home string, failFast, reindex bool, jobs int) {
 	home, err := filepath.Abs(home)
 	if err != nil {
 		log.Die("Could not generate absolute path for %q: %s", home, err)
@@ -19,9 +25,21 @@ func Update(home string) {
 	CheckLocalPrereqs(home)
 
 	rc := mustConfig(home).Repos
-	if err := rc.UpdateAll(); err != nil {
+	rc.Jobs = jobs
+	if err := rc.UpdateAll(failFast); err != nil {
 		log.Die("Not all repos could be updated: %s", err)
 	}
+	if reindex {
+		for _, t := range rc.Tables {
+			if t.IsHTTP() {
+				continue
+			}
+			log.Debug("Reindexing %s", t.Name)
+			if err := rc.Reindex(t.Name); err != nil {
+				log.Die("Could not index repository %s: %s", t.Name, err)
+			}
+		}
+	}
 	log.Info("Done")
 }
 
@@ -33,6 +51,9 @@ func Update(home string) {
 // The passed-in version is the base version that will be checked against the
 // remote release list.
 func CheckLatest(version string) {
+	if Defaults.Offline {
+		return
+	}
 	ver, err := release.LatestVersion()
 	if err != nil {
 		log.Warn("Skipped Helm Classic version check: %s", err)
@@ -43,3 +43,7 @@ func setupTestCheckLatest() {
 func (m *MockGHRepoService) GetLatestRelease(o, p string) (*github.RepositoryRelease, *github.Response, error) {
 	return m.Release, nil, nil
 }
+
+func (m *MockGHRepoService) GetReleaseByTag(o, p, tag string) (*github.RepositoryRelease, *github.Response, error) {
+	return m.Release, nil, nil
+}
@@ -0,0 +1,240 @@
+package action
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/helm/helm-classic/audit"
+	"github.com/helm/helm-classic/chart"
+	"github.com/helm/helm-classic/history"
+	"github.com/helm/helm-classic/kubectl"
+	"github.com/helm/helm-classic/kubediff"
+	"github.com/helm/helm-classic/manifest"
+	helm "github.com/helm/helm-classic/util"
+)
+
+// StatusUnchanged is the status that Upgrade reports for a resource whose
+// manifest is the one that the release already sent, and which it leaves
+// alone. Upgrade also reports StatusDeleted, StatusGone, and StatusKept.
+const StatusUnchanged = "unchanged"
+
+// Upgrade changes the release of a workspace chart to what the chart now
+// generates, without uninstalling it first.
+//
+// The options are those of Install, except that opts.Namespace is the
+// namespace of the release; if it is empty, it is the namespace of the latest
+// revision. Manifests are always sent with 'kubectl apply', whatever
+// opts.Mode is, and Deps, Checksum, VerifyKeyring, Atomic, Preflight, Wait,
+// and Stateless are ignored.
+func Upgrade(chartName, home string, opts InstallOptions, client kubectl.Runner) error {
+	CheckClientPrereqs(client)
+	c := newClient(home, client)
+	c.Config = mustConfig(home)
+	_, err := c.Upgrade(chartName, opts)
+	return err
+}
+
+// Upgrade is like the package-level Upgrade. It returns the outcome for each
+// resource, even if the upgrade fails.
+//
+// The base of the upgrade is the latest revision of the release, as
+// 'helmc history' lists it. Each manifest of the chart is compared with the
+// one that the revision sent for the same kind, namespace, and name, leaving
+// out the chart annotations that change on every install (see
+// chart.AnnChartVersion). Those that are the same are left alone, and the
+// others are applied, as by 'kubectl apply'. The resources of the revision
+// that the chart no longer has are then deleted, unless they are keepers (see
+// manifest.IsKeeper). Hooks are neither run nor deleted.
+//
+// The upgrade is recorded in the audit log, and as a new revision of the
+// release with all of the chart's manifests, so that a later upgrade, or a
+// rollback, starts from them.
+func (c *Client) Upgrade(chartName string, opts InstallOptions) (res *InstallResult, err error) {
+	defer c.completed(OpUpgrade, chartName, c.started(OpUpgrade, chartName), &err)
+	revs, err := c.History(chartName)
+	if err != nil {
+		return nil, err
+	}
+	if len(revs) == 0 {
+		return nil, fmt.Errorf("Chart %s has never been installed, so there is nothing to upgrade. Install it with 'helmc install %s'.", chartName, chartName)
+	}
+	base := revs[len(revs)-1]
+	if base.Status == history.Failed {
+		c.Log.Warn("Revision %d of %s failed: %s. The upgrade starts from the manifests it had.", base.Revision, chartName, base.Error)
+	}
+	if opts.Namespace == "" {
+		opts.Namespace = base.Namespace
+	} else if opts.Namespace != base.Namespace {
+		return nil, fmt.Errorf("Revision %d of %s is in namespace %q, not %q. Upgrade it without --namespace, or uninstall it and install it into %q.", base.Revision, chartName, dash(base.Namespace), opts.Namespace, opts.Namespace)
+	}
+	opts.Mode = ModeApply
+	opts.Deps, opts.Checksum, opts.VerifyKeyring = false, "", ""
+
+	ch, chartName, ms, err := c.installPlan(chartName, &opts)
+	if err != nil {
+		return nil, err
+	}
+	ops, err := installOperations(ms, opts.Namespace, ModeApply)
+	if err != nil {
+		return nil, err
+	}
+	changed, unchanged, removed, err := upgradeOperations(base, ops)
+	if err != nil {
+		return nil, err
+	}
+
+	restore, err := c.useChartArgs(ch)
+	if err != nil {
+		return nil, err
+	}
+	defer restore()
+	defer c.useRetryEvents()()
+
+	c.Log.Info("Upgrading %s from revision %d, %s %s, to %s %s ...", chartName, base.Revision, base.Chart, dash(base.Version), ch.Chartfile.Name, dash(ch.Chartfile.Version))
+	c.Log.Info("Running `kubectl apply -f` on %d changed resources ...", len(changed))
+	res, err = c.uploadManifests(ch.Chartfile.Name, changed, opts.Namespace, false)
+	for _, op := range unchanged {
+		res.Resources = append(res.Resources, &ResourceResult{Kind: op.Kind, Name: op.Name, Namespace: op.Namespace, Status: StatusUnchanged})
+	}
+	if err == nil {
+		err = c.deleteRemoved(removed, opts.Namespace, res)
+	}
+
+	if _, dry := c.Kube.(kubectl.PrintRunner); !dry {
+		e := c.newAuditEntry(audit.OpUpgrade, ch, helm.WorkspaceChartDirectory(c.Home, chartName), opts.Namespace)
+		for _, rr := range res.Resources {
+			e.Resources = append(e.Resources, &audit.Resource{Kind: rr.Kind, Name: rr.Name, Namespace: rr.Namespace, Status: rr.Status, Error: rr.Error})
+		}
+		c.recordAudit(e, err)
+		r := c.installRelease(ch, chartName, opts)
+		r.Description = fmt.Sprintf("upgrade from %d", base.Revision)
+		c.recordRelease(r, ops, err)
+		if perr := res.print(c.Log, opts.Output); perr != nil {
+			c.Log.Err("Could not print install summary: %s", perr)
+		}
+		if opts.Output == "" {
+			t := res.Totals()
+			c.Log.Msg("%d unchanged, %d deleted", t[StatusUnchanged], t[StatusDeleted]+t[StatusGone])
+		}
+	}
+	if err != nil {
+		return res, fmt.Errorf("Failed to upgrade: %w", err)
+	}
+	c.Log.Info("Done")
+	return res, nil
+}
+
+// upgradeOperations compares the operations of a chart with the manifests of
+// the revision base. It returns the operations whose manifests changed, or
+// that base does not have, and those that did not change, in order, and the
+// manifests of base that the operations no longer have.
+//
+// Hooks are left out of all three. A manifest without a name, which
+// Kubernetes names with its generateName, cannot be compared, and counts as
+// changed.
+func upgradeOperations(base *history.Revision, ops []*PlanOperation) (changed, unchanged []*PlanOperation, removed []*history.Manifest, err error) {
+	old := map[string]*history.Manifest{}
+	for _, m := range base.Manifests {
+		if m.Hook == "" && m.Name != "" {
+			old[upgradeKey(m.Kind, m.Namespace, m.Name)] = m
+		}
+	}
+	for _, op := range ops {
+		if op.Hook != "" {
+			continue
+		}
+		key := upgradeKey(op.Kind, op.Namespace, op.Name)
+		m, ok := old[key]
+		if op.Name == "" || !ok {
+			changed = append(changed, op)
+			continue
+		}
+		delete(old, key)
+		same, err := sameManifest(m.Manifest, op.Manifest)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("Could not compare %s %s with revision %d: %s", op.Kind, op.Name, base.Revision, err)
+		}
+		if same {
+			unchanged = append(unchanged, op)
+		} else {
+			changed = append(changed, op)
+		}
+	}
+	// Keep the order of the revision, so that deletes are predictable.
+	for _, m := range base.Manifests {
+		if _, ok := old[upgradeKey(m.Kind, m.Namespace, m.Name)]; ok && m.Hook == "" && m.Name != "" {
+			removed = append(removed, m)
+		}
+	}
+	return changed, unchanged, removed, nil
+}
+
+// upgradeKey identifies a manifest of a release by its kind, namespace, and
+// name.
+func upgradeKey(kind, namespace, name string) string {
+	return kind + "/" + namespace + "/" + name
+}
+
+// upgradeAnnotations are the chart annotations that sameManifest ignores,
+// since they change whenever the chart does, or on every install.
+var upgradeAnnotations = []string{chart.AnnChartVersion, chart.AnnChartDigest, chart.AnnChartDesc, chart.AnnInstalledAt}
+
+// sameManifest reports whether two JSON manifests describe the same
+// resource, leaving out upgradeAnnotations.
+func sameManifest(a, b []byte) (bool, error) {
+	objs := make([]map[string]interface{}, 2)
+	for i, data := range [][]byte{a, b} {
+		obj, err := kubediff.Decode(data)
+		if err != nil {
+			return false, err
+		}
+		if meta, ok := obj["metadata"].(map[string]interface{}); ok {
+			if ann, ok := meta["annotations"].(map[string]interface{}); ok {
+				for _, k := range upgradeAnnotations {
+					delete(ann, k)
+				}
+				if len(ann) == 0 {
+					delete(meta, "annotations")
+				}
+			}
+		}
+		objs[i] = obj
+	}
+	return reflect.DeepEqual(objs[0], objs[1]), nil
+}
+
+// deleteRemoved deletes the resources of a release that an upgrade no longer
+// has, recording each on res. Keepers are left alone.
+func (c *Client) deleteRemoved(removed []*history.Manifest, namespace string, res *InstallResult) error {
+	if len(removed) == 0 {
+		return nil
+	}
+	c.Log.Info("Running `kubectl delete` on %d removed resources ...", len(removed))
+	failed := 0
+	for _, m := range removed {
+		rr := &ResourceResult{Kind: m.Kind, Name: m.Name, Namespace: m.Namespace, Status: StatusDeleted}
+		res.Resources = append(res.Resources, rr)
+		if kept := manifest.KeptBy(m.Manifest); kept != "" {
+			rr.Status, rr.Error = StatusKept, fmt.Sprintf("%q annotation", kept)
+			continue
+		}
+		ns := m.Namespace
+		if ns == "" {
+			ns = namespace
+		}
+		out, err := c.Kube.Delete(m.Name, m.Kind, ns)
+		switch {
+		case err == nil:
+			c.Log.Debug(string(out))
+		case kubectl.IsNotFound(out):
+			rr.Status = StatusGone
+		default:
+			rr.Status, rr.Error = StatusFailed, failure(out, err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d removed resources could not be deleted", failed)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package action
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/helm/helm-classic/kubectl"
+	"github.com/helm/helm-classic/test"
+	"github.com/helm/helm-classic/util"
+)
+
+func TestUpgrade(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+	test.FakeUpdate(tmpHome)
+	Fetch("redis", "", tmpHome, FetchOptions{})
+
+	client := &kubectl.FakeRunner{}
+	upgrade := func() error {
+		client.Calls = nil
+		var err error
+		test.CaptureOutput(func() {
+			err = Upgrade("redis", tmpHome, InstallOptions{Annotate: true, Labels: true}, client)
+		})
+		return err
+	}
+	if err := upgrade(); err == nil || !strings.Contains(err.Error(), "never been installed") {
+		t.Errorf("Expected an upgrade before an install to fail, got %v", err)
+	}
+	test.CaptureOutput(func() {
+		if err := Install("redis", tmpHome, InstallOptions{Namespace: "cache", Force: true, Annotate: true, Labels: true}, client); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	// Nothing changed, though the installed-at annotation did.
+	if err := upgrade(); err != nil {
+		t.Fatal(err)
+	}
+	if len(client.Calls) != 0 {
+		t.Errorf("Expected an unchanged chart to send nothing, got %v", client.Calls)
+	}
+
+	manifests := util.WorkspaceChartDirectory(tmpHome, "redis", "manifests")
+	svc := "apiVersion: v1\nkind: Service\nmetadata:\n  name: redis\nspec:\n  ports:\n  - port: 6379\n"
+	if err := ioutil.WriteFile(filepath.Join(manifests, "redis-svc.yaml"), []byte(svc), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := upgrade(); err != nil {
+		t.Fatal(err)
+	}
+	if len(client.Calls) != 1 || client.Calls[0] != "apply cache" || !strings.Contains(string(client.Stdin[len(client.Stdin)-1]), `"Service"`) {
+		t.Errorf("Expected only the new service to be applied into cache, got %v", client.Calls)
+	}
+
+	os.Remove(filepath.Join(manifests, "redis-pod.yaml"))
+	if err := upgrade(); err != nil {
+		t.Fatal(err)
+	}
+	if len(client.Calls) != 1 || client.Calls[0] != "delete Pod redis cache" {
+		t.Errorf("Expected only the removed pod to be deleted, got %v", client.Calls)
+	}
+
+	revs, _ := newClient(tmpHome, client).History("redis")
+	if len(revs) != 4 || revs[3].Description != "upgrade from 3" || len(revs[3].Manifests) != 1 || revs[3].Manifests[0].Kind != "Service" {
+		t.Errorf("Expected the upgrades to be revisions 2 to 4, got %v", revs)
+	}
+
+	client.Calls = nil
+	test.CaptureOutput(func() {
+		if err := Upgrade("redis", tmpHome, InstallOptions{Namespace: "other", Annotate: true, Labels: true}, client); err == nil {
+			t.Errorf("Expected an upgrade into another namespace to fail")
+		}
+	})
+}
@@ -0,0 +1,679 @@
+package action
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/helm/helm-classic/log"
+	"gopkg.in/yaml.v2"
+)
+
+// Kinds of value sources.
+const (
+	// SourceEnv is the value of an environment variable, as in
+	// "env:DB_PASSWORD".
+	SourceEnv = "env"
+	// SourceFile is the contents of a file, as in "file:./cert.pem".
+	SourceFile = "file"
+	// SourceCmd is what a command prints, without its trailing newline, as in
+	// "cmd:vault read -field=password secret/db". It is only allowed with
+	// ValueSources.AllowExec.
+	SourceCmd = "cmd"
+	// SourcePass is the first line of an entry of the pass password store,
+	// as in "pass:db/password". It runs 'pass show', so it is only allowed
+	// with ValueSources.AllowExec.
+	SourcePass = "pass"
+	// SourceSops is a file that SOPS decrypts, as in "sops:secrets.enc.yaml",
+	// or a value of it, as in "sops:secrets.enc.yaml#db.password". It runs
+	// 'sops --decrypt', so it is only allowed with ValueSources.AllowExec.
+	SourceSops = "sops"
+)
+
+// sourceKinds lists the kinds of value sources, for errors.
+const sourceKinds = "env:NAME, file:PATH, cmd:COMMAND, pass:NAME, or sops:PATH[#KEY]"
+
+// ValueFromKey is the key of a values file's mapping that is replaced by the
+// value of a source, as in:
+//
+//	password:
+//	  valueFrom: env:DB_PASSWORD
+const ValueFromKey = "valueFrom"
+
+// Redacted stands in for the value of a source wherever it is recorded.
+const Redacted = "<redacted>"
+
+// The environment variables that give the value sources to 'helmc template'
+// when a generator runs it.
+const (
+	envValuesFiles     = "HELM_VALUES_FILES"
+	envSet             = "HELM_SET"
+	envSetFrom         = "HELM_SET_FROM"
+	envAllowExecValues = "HELM_ALLOW_EXEC_VALUES"
+	envEnvironment     = "HELM_ENV"
+)
+
+// ValueSources are the values of a chart's templates that are given when
+// the templates are rendered, rather than kept in the chart: values files,
+// values set on the command line, and values read from sources, such as
+// secrets.
+//
+// They are merged over the values of the chart, in this order, each winning
+// over those before it:
+//
+//  1. the chart's values.yaml
+//  2. the chart's values file of Env, such as values-staging.yaml
+//  3. the values file of the template, as in 'helmc template -d'
+//  4. Files, in order
+//  5. Set
+//  6. SetFrom
+//
+// The values of SetFrom are never recorded: the audit log and plans only
+// have Redacted specs.
+type ValueSources struct {
+	// Files are values files, as in "-f prod.yaml", merged in order.
+	Files []string
+	// Set are KEY=VALUE specs, such as "image.tag=1.10". KEY is a dotted
+	// path into the values. A VALUE of true or false is a boolean, an
+	// integer is a number, and null is null; anything else is a string.
+	// Quote it, as in 'port="80"', for a string.
+	Set []string
+	// SetFrom are KEY=SOURCE specs, such as "db.password=env:DB_PASSWORD".
+	// KEY is a dotted path into the values, and SOURCE is one of the Source
+	// kinds, a colon, and its argument.
+	SetFrom []string
+	// AllowExec allows the SourceCmd sources, of SetFrom and of values
+	// files.
+	AllowExec bool
+	// Env is the environment, such as "staging", whose values file of the
+	// chart, values-ENV.yaml, is merged over its values.yaml. A chart
+	// without one has no values of the environment.
+	Env string
+
+	// dir is the directory of the relative files and of the commands of the
+	// sources: that of the generator that renders them in this process, or
+	// the working directory if it is "".
+	dir string
+	// sourced, if it is not nil, makes apply set the keys of SetFrom and of
+	// the valueFrom mappings to Redacted, without reading their sources, and
+	// records their dotted paths.
+	sourced map[string]bool
+}
+
+// Empty reports whether v gives no values.
+func (v ValueSources) Empty() bool {
+	return len(v.Files) == 0 && len(v.Set) == 0 && len(v.SetFrom) == 0
+}
+
+// AbsFiles returns the values files as absolute paths, as they are recorded,
+// so that they are found from any directory.
+func (v ValueSources) AbsFiles() []string {
+	if len(v.Files) == 0 {
+		return nil
+	}
+	files := make([]string, len(v.Files))
+	for i, f := range v.Files {
+		files[i] = v.path(f)
+		if abs, err := filepath.Abs(files[i]); err == nil {
+			files[i] = abs
+		}
+	}
+	return files
+}
+
+// parseSet parses a KEY=VALUE spec of Set.
+func parseSet(spec string) (string, interface{}, error) {
+	kv := strings.SplitN(spec, "=", 2)
+	if len(kv) != 2 || kv[0] == "" {
+		return "", nil, fmt.Errorf("Invalid --set %q. Use KEY=VALUE, such as image.tag=1.2", spec)
+	}
+	var val interface{}
+	if err := yaml.Unmarshal([]byte(kv[1]), &val); err != nil {
+		return "", nil, fmt.Errorf("Invalid --set %q: %s", spec, err)
+	}
+	switch val.(type) {
+	case bool, int, string:
+	case nil:
+		if kv[1] == "" {
+			val = ""
+		}
+	default:
+		// Other values are strings as they were given, so that an image tag
+		// such as 1.10 is not the number 1.1, and "a: b" is not a mapping.
+		val = kv[1]
+	}
+	return kv[0], val, nil
+}
+
+// valueSource is a parsed SetFrom spec.
+type valueSource struct {
+	key    string
+	kind   string
+	source string
+}
+
+// parseValueSource parses a KEY=SOURCE spec.
+func parseValueSource(spec string) (*valueSource, error) {
+	kv := strings.SplitN(spec, "=", 2)
+	if len(kv) != 2 || kv[0] == "" {
+		return nil, fmt.Errorf("Invalid --set-from %q. Use KEY=SOURCE, such as db.password=env:DB_PASSWORD", spec)
+	}
+	kind, source, err := splitSource(kv[1])
+	if err != nil {
+		return nil, fmt.Errorf("Invalid --set-from %q: %s", spec, err)
+	}
+	return &valueSource{key: kv[0], kind: kind, source: source}, nil
+}
+
+// splitSource splits a SOURCE into its kind and argument.
+func splitSource(s string) (string, string, error) {
+	kv := strings.SplitN(s, ":", 2)
+	if len(kv) != 2 || kv[1] == "" {
+		return "", "", fmt.Errorf("%q is not a value source. Use %s", s, sourceKinds)
+	}
+	switch kv[0] {
+	case SourceEnv, SourceFile, SourceCmd, SourcePass, SourceSops:
+		return kv[0], kv[1], nil
+	}
+	return "", "", fmt.Errorf("unknown value source %q. Use %s", kv[0], sourceKinds)
+}
+
+// Check checks that the values files exist, and parses the Set and SetFrom
+// specs, and checks that their variables are set and their files exist, so
+// that a value that is missing is found before anything is generated. It runs
+// no command, but refuses the sources that run one unless AllowExec is set.
+func (v ValueSources) Check() error {
+	for _, f := range v.Files {
+		if _, err := os.Stat(v.path(f)); err != nil {
+			return fmt.Errorf("Could not read the values file %s: %s", f, err)
+		}
+	}
+	for _, spec := range v.Set {
+		if _, _, err := parseSet(spec); err != nil {
+			return err
+		}
+	}
+	for _, spec := range v.SetFrom {
+		s, err := parseValueSource(spec)
+		if err != nil {
+			return err
+		}
+		switch s.kind {
+		case SourceEnv:
+			if _, ok := os.LookupEnv(s.source); !ok {
+				return fmt.Errorf("--set-from %s: $%s is not set", s.key, s.source)
+			}
+		case SourceFile:
+			if _, err := os.Stat(v.path(s.source)); err != nil {
+				return fmt.Errorf("--set-from %s: %s", s.key, err)
+			}
+		case SourceCmd, SourcePass, SourceSops:
+			if !v.AllowExec {
+				return fmt.Errorf("--set-from %s runs a command. Add --allow-exec-values to allow it.", s.key)
+			}
+			if s.kind == SourceSops {
+				if _, err := os.Stat(v.path(sopsFile(s.source))); err != nil {
+					return fmt.Errorf("--set-from %s: %s", s.key, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Redacted returns the SetFrom specs as they are recorded: with their kind,
+// but without the argument, as in "db.password=env:<redacted>".
+func (v ValueSources) Redacted() []string {
+	if len(v.SetFrom) == 0 {
+		return nil
+	}
+	res := make([]string, len(v.SetFrom))
+	for i, spec := range v.SetFrom {
+		if s, err := parseValueSource(spec); err == nil {
+			res[i] = s.key + "=" + s.kind + ":" + Redacted
+		} else {
+			res[i] = Redacted
+		}
+	}
+	return res
+}
+
+// resolve reads a source. With sourced, it is Redacted instead.
+func (v ValueSources) resolve(kind, source string) (string, error) {
+	if v.sourced != nil {
+		return Redacted, nil
+	}
+	switch kind {
+	case SourceEnv:
+		val, ok := os.LookupEnv(source)
+		if !ok {
+			return "", fmt.Errorf("$%s is not set", source)
+		}
+		return val, nil
+	case SourceFile:
+		b, err := ioutil.ReadFile(v.path(source))
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	case SourceCmd, SourcePass, SourceSops:
+		if !v.AllowExec {
+			return "", fmt.Errorf("%s: sources run a command. Add --allow-exec-values to allow it", kind)
+		}
+		args := strings.Fields(source)
+		switch kind {
+		case SourcePass:
+			args = []string{"pass", "show", source}
+		case SourceSops:
+			args = []string{"sops", "--decrypt"}
+			if i := strings.Index(source, "#"); i >= 0 {
+				args = append(args, "--extract", sopsPath(source[i+1:]))
+			}
+			args = append(args, v.path(sopsFile(source)))
+		}
+		if len(args) == 0 {
+			return "", errors.New("empty command")
+		}
+		var out bytes.Buffer
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Dir = v.dir
+		cmd.Stdout = &out
+		cmd.Stderr = log.Stderr
+		if err := cmd.Run(); err != nil {
+			return "", fmt.Errorf("%s failed: %s", args[0], err)
+		}
+		if kind == SourcePass {
+			// The password is the first line; the others are notes.
+			return strings.TrimRight(strings.SplitN(out.String(), "\n", 2)[0], "\r"), nil
+		}
+		return strings.TrimRight(out.String(), "\r\n"), nil
+	}
+	return "", fmt.Errorf("unknown value source %q", kind)
+}
+
+// sopsFile returns the file of a SourceSops source, without its key.
+func sopsFile(source string) string {
+	if i := strings.Index(source, "#"); i >= 0 {
+		return source[:i]
+	}
+	return source
+}
+
+// sopsPath returns the dotted key of a SourceSops source as the path of
+// 'sops --extract', as in ["db"]["password"].
+func sopsPath(key string) string {
+	var b strings.Builder
+	for _, k := range strings.Split(key, ".") {
+		b.WriteString(`[` + strconv.Quote(k) + `]`)
+	}
+	return b.String()
+}
+
+// merge merges the values files over vals, and then sets the keys of Set. It
+// returns the values, which are a new map if vals is nil. The valueFrom
+// mappings are left as they are, and SetFrom is not read.
+func (v ValueSources) merge(vals interface{}) (interface{}, error) {
+	for _, f := range v.Files {
+		fv, err := readValues(v.path(f))
+		if err != nil {
+			return nil, fmt.Errorf("Could not read the values file %s: %s", f, err)
+		}
+		vals = mergeValues(vals, fv)
+	}
+	for _, spec := range v.Set {
+		key, val, err := parseSet(spec)
+		if err != nil {
+			return nil, err
+		}
+		if vals, err = setValue(vals, strings.Split(key, "."), val); err != nil {
+			return nil, fmt.Errorf("--set %s: %s", key, err)
+		}
+	}
+	if vals == nil {
+		vals = map[string]interface{}{}
+	}
+	return vals, nil
+}
+
+// apply merges the values files and Set over vals, replaces the valueFrom
+// mappings with the values of their sources, and then sets the keys of
+// SetFrom. It returns the values, which are a new map if vals is nil.
+//
+// vals are the values of the chart, which may not have a valueFrom mapping:
+// only the user reads sources, so that a chart cannot render the user's
+// secrets into its manifests. Any source that cannot be read is an error,
+// so that nothing is rendered without it.
+func (v ValueSources) apply(vals interface{}) (interface{}, error) {
+	if path, ok := findValueFrom(vals, ""); ok {
+		return nil, fmt.Errorf("%s: the values of the chart may not read a source. Give it in a file of --values, or with --set-from", path)
+	}
+	vals, err := v.merge(vals)
+	if err != nil {
+		return nil, err
+	}
+	vals, err = v.applyValueFrom(vals, "")
+	if err != nil {
+		return nil, err
+	}
+	for _, spec := range v.SetFrom {
+		s, err := parseValueSource(spec)
+		if err != nil {
+			return nil, err
+		}
+		if v.sourced != nil {
+			v.sourced[s.key] = true
+		}
+		val, err := v.resolve(s.kind, s.source)
+		if err != nil {
+			return nil, fmt.Errorf("--set-from %s: %s", s.key, err)
+		}
+		if vals, err = setValue(vals, strings.Split(s.key, "."), val); err != nil {
+			return nil, fmt.Errorf("--set-from %s: %s", s.key, err)
+		}
+	}
+	return vals, nil
+}
+
+// applyValueFrom replaces each mapping whose only key is ValueFromKey with the
+// value of its source. path is the dotted path of vals, for errors.
+func (v ValueSources) applyValueFrom(vals interface{}, path string) (interface{}, error) {
+	join := func(k interface{}) string {
+		if path == "" {
+			return fmt.Sprint(k)
+		}
+		return path + "." + fmt.Sprint(k)
+	}
+	switch m := vals.(type) {
+	case map[string]interface{}:
+		if src, ok := m[ValueFromKey].(string); ok && len(m) == 1 {
+			return v.resolveValueFrom(src, path)
+		}
+		for k, e := range m {
+			r, err := v.applyValueFrom(e, join(k))
+			if err != nil {
+				return nil, err
+			}
+			m[k] = r
+		}
+	case map[interface{}]interface{}:
+		if src, ok := m[ValueFromKey].(string); ok && len(m) == 1 {
+			return v.resolveValueFrom(src, path)
+		}
+		for k, e := range m {
+			r, err := v.applyValueFrom(e, join(k))
+			if err != nil {
+				return nil, err
+			}
+			m[k] = r
+		}
+	case []interface{}:
+		for i, e := range m {
+			r, err := v.applyValueFrom(e, join(i))
+			if err != nil {
+				return nil, err
+			}
+			m[i] = r
+		}
+	}
+	return vals, nil
+}
+
+func (v ValueSources) resolveValueFrom(src, path string) (interface{}, error) {
+	if v.sourced != nil {
+		v.sourced[path] = true
+	}
+	kind, source, err := splitSource(src)
+	if err == nil {
+		var val string
+		if val, err = v.resolve(kind, source); err == nil {
+			return val, nil
+		}
+	}
+	if path != "" {
+		path += "."
+	}
+	return nil, fmt.Errorf("%s%s: %s", path, ValueFromKey, err)
+}
+
+// findValueFrom returns the dotted path of a valueFrom of vals, as the errors
+// of applyValueFrom give it, and whether there is one. path is the dotted
+// path of vals.
+func findValueFrom(vals interface{}, path string) (string, bool) {
+	join := func(k string) string {
+		if path == "" {
+			return k
+		}
+		return path + "." + k
+	}
+	if m, ok := stringMap(vals); ok {
+		if _, ok := m[ValueFromKey].(string); ok && len(m) == 1 {
+			return join(ValueFromKey), true
+		}
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if p, ok := findValueFrom(m[k], join(k)); ok {
+				return p, true
+			}
+		}
+	}
+	if l, ok := vals.([]interface{}); ok {
+		for i, e := range l {
+			if p, ok := findValueFrom(e, join(strconv.Itoa(i))); ok {
+				return p, true
+			}
+		}
+	}
+	return "", false
+}
+
+// mergeValues merges the values of src over those of dst: mappings are
+// merged, key by key, and any other value of src replaces that of dst. The
+// mappings of the result are new, with string keys, so that neither is
+// changed.
+func mergeValues(dst, src interface{}) interface{} {
+	d, dok := stringMap(dst)
+	s, sok := stringMap(src)
+	if !dok || !sok {
+		return src
+	}
+	res := make(map[string]interface{}, len(d)+len(s))
+	for k, e := range d {
+		res[k] = e
+	}
+	for k, e := range s {
+		if de, ok := res[k]; ok {
+			res[k] = mergeValues(de, e)
+		} else {
+			res[k] = e
+		}
+	}
+	return res
+}
+
+// stringMap returns a mapping of values, which YAML may decode with keys of
+// any type, with string keys. It returns false if v is not a mapping.
+func stringMap(v interface{}) (map[string]interface{}, bool) {
+	switch m := v.(type) {
+	case map[string]interface{}:
+		return m, true
+	case map[interface{}]interface{}:
+		res := make(map[string]interface{}, len(m))
+		for k, e := range m {
+			res[fmt.Sprint(k)] = e
+		}
+		return res, true
+	}
+	return nil, false
+}
+
+// setValue sets the value at a dotted path of vals, creating the mappings
+// that are missing. It returns vals, or a new map if vals is nil.
+func setValue(vals interface{}, path []string, val interface{}) (interface{}, error) {
+	if vals == nil {
+		vals = map[string]interface{}{}
+	}
+	if len(path) == 0 {
+		return val, nil
+	}
+	switch m := vals.(type) {
+	case map[string]interface{}:
+		r, err := setValue(m[path[0]], path[1:], val)
+		if err != nil {
+			return nil, err
+		}
+		m[path[0]] = r
+	case map[interface{}]interface{}:
+		r, err := setValue(m[path[0]], path[1:], val)
+		if err != nil {
+			return nil, err
+		}
+		m[path[0]] = r
+	default:
+		return nil, fmt.Errorf("%s is not a mapping", path[0])
+	}
+	return vals, nil
+}
+
+// env gives the value sources to the 'helmc template' runs of generators.
+// Relative files are made absolute, since generators run in the chart.
+func (v ValueSources) env(env map[string]string) {
+	if v.Env != "" {
+		env[envEnvironment] = v.Env
+	}
+	if len(v.Files) > 0 {
+		env[envValuesFiles] = strings.Join(v.AbsFiles(), "\n")
+	}
+	if len(v.Set) > 0 {
+		env[envSet] = strings.Join(v.Set, "\n")
+	}
+	if len(v.SetFrom) == 0 && !v.AllowExec {
+		return
+	}
+	specs := make([]string, 0, len(v.SetFrom))
+	for _, spec := range v.SetFrom {
+		if s, err := parseValueSource(spec); err == nil && s.kind == SourceFile {
+			if abs, err := filepath.Abs(s.source); err == nil {
+				spec = s.key + "=" + SourceFile + ":" + abs
+			}
+		}
+		specs = append(specs, spec)
+	}
+	env[envSetFrom] = strings.Join(specs, "\n")
+	env[envAllowExecValues] = strconv.FormatBool(v.AllowExec)
+}
+
+// The environment variables that give generators the values of their chart.
+const (
+	// envValues is the file of the values, as YAML.
+	envValues = "HELM_VALUES"
+	// envValuePrefix, and the path of a value, are the variable of each
+	// value, as in HELM_VALUE_IMAGE_TAG for image.tag.
+	envValuePrefix = "HELM_VALUE"
+)
+
+// generatorEnv gives the generators of the chart in chartPath its values:
+// those of its values.yaml and the values file of Env, merged with Files and
+// Set, which are written to
+// file, as YAML, only readable by the owner, and named by $HELM_VALUES. Each
+// value is also in a variable of its own, whose name is its path, in upper
+// case, with an underscore for each dot or other character that is not a
+// letter or digit, as in $HELM_VALUE_IMAGE_TAG. Items of lists are numbered
+// from 0, as in $HELM_VALUE_PORTS_0.
+//
+// SetFrom is not read, so that secrets are never written; the templates
+// that 'helmc template' renders read it themselves.
+func (v ValueSources) generatorEnv(env map[string]string, chartPath, file string) error {
+	vals, err := chartValues(chartPath, v.Env)
+	if err != nil {
+		return err
+	}
+	if vals, err = v.merge(vals); err != nil {
+		return err
+	}
+	data, err := yaml.Marshal(vals)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(file, data, 0600); err != nil {
+		return err
+	}
+	env[envValues] = file
+	valueVars(env, envValuePrefix, vals)
+	return nil
+}
+
+// valueVars sets a variable of env for each scalar of vals, under name.
+func valueVars(env map[string]string, name string, vals interface{}) {
+	if m, ok := stringMap(vals); ok {
+		for k, e := range m {
+			valueVars(env, name+"_"+envName(k), e)
+		}
+		return
+	}
+	switch x := vals.(type) {
+	case []interface{}:
+		for i, e := range x {
+			valueVars(env, name+"_"+strconv.Itoa(i), e)
+		}
+	case nil:
+		env[name] = ""
+	default:
+		env[name] = fmt.Sprint(x)
+	}
+}
+
+// envName returns a key of the values as a part of a variable name.
+func envName(key string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, key)
+}
+
+// withEnv adds the value sources that a generator was given, in the
+// environment that getenv reads, to v.
+func (v ValueSources) withEnv(getenv func(string) string) ValueSources {
+	if s := getenv(envValuesFiles); s != "" {
+		v.Files = append(strings.Split(s, "\n"), v.Files...)
+	}
+	if s := getenv(envSet); s != "" {
+		v.Set = append(strings.Split(s, "\n"), v.Set...)
+	}
+	if s := getenv(envSetFrom); s != "" {
+		v.SetFrom = append(strings.Split(s, "\n"), v.SetFrom...)
+	}
+	if getenv(envAllowExecValues) == "true" {
+		v.AllowExec = true
+	}
+	if v.Env == "" {
+		v.Env = getenv(envEnvironment)
+	}
+	return v
+}
+
+// path returns a file of a source, relative to the directory of v.
+func (v ValueSources) path(file string) string {
+	if v.dir == "" || filepath.IsAbs(file) {
+		return file
+	}
+	return filepath.Join(v.dir, file)
+}
@@ -0,0 +1,367 @@
+package action
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/helm/helm-classic/audit"
+	"github.com/helm/helm-classic/chart"
+	"github.com/helm/helm-classic/kubectl"
+	"github.com/helm/helm-classic/log"
+	"github.com/helm/helm-classic/test"
+	"github.com/helm/helm-classic/util"
+)
+
+func TestValueSourceEnv(t *testing.T) {
+	defer os.Unsetenv("HELMC_TEST_PASSWORD")
+	os.Setenv("HELMC_TEST_PASSWORD", "s3cret")
+
+	v := ValueSources{SetFrom: []string{"db.password=env:HELMC_TEST_PASSWORD"}}
+	if err := v.Check(); err != nil {
+		t.Fatal(err)
+	}
+	vals, err := v.apply(map[string]interface{}{"db": map[string]interface{}{"user": "app"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := vals.(map[string]interface{})["db"].(map[string]interface{})
+	test.ExpectEquals(t, db["password"], "s3cret")
+	test.ExpectEquals(t, db["user"], "app")
+
+	// A missing variable is an error, not an empty value.
+	v = ValueSources{SetFrom: []string{"db.password=env:HELMC_TEST_MISSING"}}
+	if err := v.Check(); err == nil || !strings.Contains(err.Error(), "$HELMC_TEST_MISSING is not set") {
+		t.Errorf("Expected a missing variable to be reported, got %v", err)
+	}
+	if _, err := v.apply(nil); err == nil {
+		t.Errorf("Expected a missing variable to fail")
+	}
+}
+
+func TestValueSourceFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "helmc-values-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cert := filepath.Join(dir, "cert.pem")
+	ioutil.WriteFile(cert, []byte("-----BEGIN CERTIFICATE-----\n"), 0600)
+
+	v := ValueSources{SetFrom: []string{"tls.cert=file:" + cert}}
+	vals, err := v.apply(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.ExpectEquals(t, vals.(map[string]interface{})["tls"].(map[string]interface{})["cert"], "-----BEGIN CERTIFICATE-----\n")
+
+	v = ValueSources{SetFrom: []string{"tls.cert=file:" + filepath.Join(dir, "missing.pem")}}
+	if err := v.Check(); err == nil {
+		t.Errorf("Expected a missing file to be reported")
+	}
+
+	// Generators run in the chart, so they are given absolute paths.
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	os.Chdir(dir)
+	env := map[string]string{}
+	ValueSources{SetFrom: []string{"tls.cert=file:cert.pem"}}.env(env)
+	abs, _ := filepath.Abs("cert.pem")
+	test.ExpectEquals(t, env[envSetFrom], "tls.cert=file:"+abs)
+	test.ExpectEquals(t, env[envAllowExecValues], "false")
+}
+
+func TestValueSourceCmd(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("echo and false are not commands on Windows")
+	}
+	v := ValueSources{SetFrom: []string{"token=cmd:echo t0ken"}}
+	if err := v.Check(); err == nil || !strings.Contains(err.Error(), "--allow-exec-values") {
+		t.Errorf("Expected a command to need --allow-exec-values, got %v", err)
+	}
+	if _, err := v.apply(nil); err == nil {
+		t.Errorf("Expected a command not to run without AllowExec")
+	}
+
+	v.AllowExec = true
+	vals, err := v.apply(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.ExpectEquals(t, vals.(map[string]interface{})["token"], "t0ken")
+
+	v.SetFrom = []string{"token=cmd:false"}
+	if _, err := v.apply(nil); err == nil || !strings.Contains(err.Error(), "false failed") {
+		t.Errorf("Expected a failing command to be reported, got %v", err)
+	}
+}
+
+func TestValueSourcePassSops(t *testing.T) {
+	v := ValueSources{SetFrom: []string{"db.password=pass:prod/db"}}
+	if err := v.Check(); err == nil || !strings.Contains(err.Error(), "--allow-exec-values") {
+		t.Errorf("Expected pass to need --allow-exec-values, got %v", err)
+	}
+	v = ValueSources{SetFrom: []string{"db.password=sops:missing.enc.yaml#db.password"}, AllowExec: true}
+	if err := v.Check(); err == nil || !strings.Contains(err.Error(), "missing.enc.yaml") {
+		t.Errorf("Expected a missing SOPS file to be reported, got %v", err)
+	}
+	test.ExpectEquals(t, sopsFile("secrets.enc.yaml#db.password"), "secrets.enc.yaml")
+	test.ExpectEquals(t, sopsPath("db.password"), `["db"]["password"]`)
+}
+
+func TestValueFrom(t *testing.T) {
+	defer os.Unsetenv("HELMC_TEST_PASSWORD")
+	os.Setenv("HELMC_TEST_PASSWORD", "s3cret")
+	dir, err := ioutil.TempDir("", "helmc-values-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	tpl := filepath.Join(dir, "pod.tpl")
+	ioutil.WriteFile(tpl, []byte("{{.db.user}}:{{.db.password}} {{index .hosts 0}}"), 0644)
+	vals := filepath.Join(dir, "values.yaml")
+	ioutil.WriteFile(vals, []byte("db:\n  user: app\n  password:\n    valueFrom: env:HELMC_TEST_PASSWORD\nhosts:\n- valueFrom: env:HELMC_TEST_PASSWORD\n"), 0644)
+
+	var out bytes.Buffer
+	o := log.Stdout
+	log.Stdout = &out
+	defer func() { log.Stdout = o }()
+
+	if err := Template("", tpl, vals, false, false, ValueSources{}); err != nil {
+		t.Fatal(err)
+	}
+	test.ExpectEquals(t, out.String(), "app:s3cret s3cret")
+
+	// --set-from wins over the values file.
+	out.Reset()
+	if err := Template("", tpl, vals, false, false, ValueSources{SetFrom: []string{"db.user=env:HELMC_TEST_PASSWORD"}}); err != nil {
+		t.Fatal(err)
+	}
+	test.ExpectEquals(t, out.String(), "s3cret:s3cret s3cret")
+
+	ioutil.WriteFile(vals, []byte("db:\n  password:\n    valueFrom: env:HELMC_TEST_MISSING\n"), 0644)
+	out.Reset()
+	err = Template("", tpl, vals, false, false, ValueSources{})
+	if err == nil || !strings.Contains(err.Error(), "db.password.valueFrom: $HELMC_TEST_MISSING is not set") {
+		t.Errorf("Expected the missing variable to be reported with its path, got %v", err)
+	}
+	test.ExpectEquals(t, out.String(), "")
+}
+
+func TestValueFromChart(t *testing.T) {
+	defer os.Unsetenv("HELMC_TEST_PASSWORD")
+	os.Setenv("HELMC_TEST_PASSWORD", "s3cret")
+	homedir := test.CreateTmpHome()
+	defer os.RemoveAll(homedir)
+	dir := util.WorkspaceChartDirectory(homedir, "thirdparty")
+	files := map[string]string{
+		Chartfile:         "name: thirdparty\nversion: 0.1.0\n",
+		"tpl/pod.yaml":    "#helm:generate helm tpl -o manifests/pod.yaml $HELM_GENERATE_FILE\nkey: {{.aws.key}}\n",
+		"manifests/.keep": "",
+	}
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(p), 0755)
+		ioutil.WriteFile(p, []byte(content), 0644)
+	}
+	pod := filepath.Join(dir, "manifests", "pod.yaml")
+
+	// The chart's own values may not read a source, whether the template
+	// is rendered by hand or by a generator.
+	for _, values := range []string{chart.ValuesFile, chart.EnvValuesFile("prod")} {
+		ioutil.WriteFile(filepath.Join(dir, values), []byte("aws:\n  key:\n    valueFrom: env:HELMC_TEST_PASSWORD\n"), 0644)
+		test.CaptureOutput(func() {
+			err := Template("", filepath.Join(dir, "tpl", "pod.yaml"), "", false, true, ValueSources{Env: "prod"})
+			if err == nil || !strings.Contains(err.Error(), "aws.key.valueFrom: the values of the chart may not read a source") {
+				t.Errorf("Expected the valueFrom of %s to be refused, got %v", values, err)
+			}
+			err = Generate("thirdparty", homedir, GenerateOptions{Force: true, Values: ValueSources{Env: "prod"}})
+			if err == nil || !strings.Contains(err.Error(), "aws.key.valueFrom") {
+				t.Errorf("Expected the generate to be refused, got %v", err)
+			}
+		})
+		if _, err := os.Stat(pod); err == nil {
+			t.Fatalf("Expected nothing to be rendered from the valueFrom of %s", values)
+		}
+		os.Remove(filepath.Join(dir, values))
+	}
+
+	// Neither may the flags of its generators.
+	ioutil.WriteFile(filepath.Join(dir, "tpl", "pod.yaml"), []byte("#helm:generate helm tpl --set-from aws.key=env:HELMC_TEST_PASSWORD -o manifests/pod.yaml $HELM_GENERATE_FILE\nkey: {{.aws.key}}\n"), 0644)
+	test.CaptureOutput(func() {
+		err := Generate("thirdparty", homedir, GenerateOptions{Force: true, SkipSchema: true})
+		if err == nil || !strings.Contains(err.Error(), "gives --set-from, which only the user may give") {
+			t.Errorf("Expected the --set-from of the generator to be refused, got %v", err)
+		}
+	})
+	if _, err := os.Stat(pod); err == nil {
+		t.Fatal("Expected nothing to be rendered from the --set-from of the generator")
+	}
+
+	// The user's values files and --set-from still read sources.
+	ioutil.WriteFile(filepath.Join(dir, "tpl", "pod.yaml"), []byte(files["tpl/pod.yaml"]), 0644)
+	mine := filepath.Join(homedir, "mine.yaml")
+	ioutil.WriteFile(mine, []byte("aws:\n  key:\n    valueFrom: env:HELMC_TEST_PASSWORD\n"), 0644)
+	test.CaptureOutput(func() {
+		if err := Generate("thirdparty", homedir, GenerateOptions{Force: true, Values: ValueSources{Files: []string{mine}}}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	b, _ := ioutil.ReadFile(pod)
+	test.ExpectContains(t, string(b), "key: s3cret\n")
+}
+
+func TestValueSourcesRedacted(t *testing.T) {
+	v := ValueSources{SetFrom: []string{"db.password=env:DB_PASSWORD", "tls.cert=file:./cert.pem", "token=cmd:vault read secret/db", "nonsense"}}
+	test.ExpectEquals(t, strings.Join(v.Redacted(), " "), "db.password=env:<redacted> tls.cert=file:<redacted> token=cmd:<redacted> <redacted>")
+	if (ValueSources{}).Redacted() != nil {
+		t.Errorf("Expected no sources to record nothing")
+	}
+}
+
+func TestInstallValueSources(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+	test.FakeUpdate(tmpHome)
+	defer os.Unsetenv("HELMC_TEST_PASSWORD")
+	os.Setenv("HELMC_TEST_PASSWORD", "s3cret")
+
+	r := &kubectl.FakeRunner{Out: []byte(`pod "redis" created`)}
+	c := newClient(tmpHome, r)
+	test.CaptureOutput(func() {
+		if _, err := c.Fetch("redis", "", FetchOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	gen := filepath.Join(util.WorkspaceChartDirectory(tmpHome, "redis"), "gen.txt")
+	ioutil.WriteFile(gen, []byte("#helm:generate true\n"), 0644)
+	c.AllowGenerators = []string{"true"}
+
+	// A missing variable stops the install before anything is sent.
+	test.CaptureOutput(func() {
+		_, err := c.Install("redis", InstallOptions{Namespace: "cache", Generate: true, Values: ValueSources{SetFrom: []string{"password=env:HELMC_TEST_MISSING"}}})
+		if err == nil {
+			t.Errorf("Expected a missing variable to fail the install")
+		}
+	})
+	test.ExpectEquals(t, len(r.Calls), 0)
+
+	test.CaptureOutput(func() {
+		if _, err := c.Install("redis", InstallOptions{Namespace: "cache", Generate: true, Values: ValueSources{SetFrom: []string{"password=env:HELMC_TEST_PASSWORD"}}}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	e, err := audit.LastInstall(c.auditPath(), "redis")
+	if err != nil || e == nil {
+		t.Fatalf("Expected the install to be recorded, got %v", err)
+	}
+	test.ExpectEquals(t, strings.Join(e.Parameters.SetFrom, " "), "password=env:<redacted>")
+	b, _ := ioutil.ReadFile(c.auditPath())
+	if strings.Contains(string(b), "HELMC_TEST_PASSWORD") || strings.Contains(string(b), "s3cret") {
+		t.Errorf("Expected the audit log not to have the source, got %s", b)
+	}
+
+	var p *Plan
+	test.CaptureOutput(func() {
+		p, err = c.PlanInstall("redis", InstallOptions{Namespace: "cache", Generate: true, Values: ValueSources{SetFrom: []string{"password=env:HELMC_TEST_PASSWORD"}}})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.ExpectEquals(t, strings.Join(p.Settings.SetFrom, " "), "password=env:<redacted>")
+}
+
+func TestValuesPrecedence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "helmc-values-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer os.Unsetenv("HELMC_TEST_ENV")
+	os.Setenv("HELMC_TEST_ENV", "prod")
+	files := map[string]string{
+		Chartfile:        "name: web\nversion: 0.1.0\n",
+		"values.yaml":    "image:\n  repo: nginx\n  tag: \"1.0\"\nreplicas: 1\nenv: dev\n",
+		"values-eu.yaml": "image:\n  repo: httpd\nenv: qa\nregion: eu-west\n",
+		"tpl/data.yaml":  "env: staging\nregion: local\n",
+		"prod.yaml":      "image:\n  tag: \"2.0\"\nregion: eu\n",
+		"tpl/web.tpl":    "{{.Values.image.repo}}:{{.Values.image.tag}} {{.Values.replicas}} {{.Values.env}} {{.Values.region}} {{.Values.debug}}",
+		"tpl/unused.txt": "",
+	}
+	for name, data := range files {
+		os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755)
+		ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0644)
+	}
+
+	out := filepath.Join(dir, "web.txt")
+	sources := ValueSources{
+		Files:   []string{filepath.Join(dir, "prod.yaml")},
+		Set:     []string{"replicas=3", "region=us", "debug=true"},
+		SetFrom: []string{"env=env:HELMC_TEST_ENV"},
+	}
+	if err := Template(out, filepath.Join(dir, "tpl/web.tpl"), filepath.Join(dir, "tpl/data.yaml"), false, false, sources); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadFile(out)
+	test.ExpectEquals(t, string(b), "nginx:2.0 3 prod us true")
+
+	// The values of the environment are merged over values.yaml, and under
+	// the rest.
+	if err := Template(out, filepath.Join(dir, "tpl/web.tpl"), "", true, false, ValueSources{Env: "eu"}); err != nil {
+		t.Fatal(err)
+	}
+	b, _ = ioutil.ReadFile(out)
+	test.ExpectEquals(t, string(b), "httpd:1.0 1 qa eu-west <no value>")
+	if err := Template(out, filepath.Join(dir, "tpl/web.tpl"), filepath.Join(dir, "tpl/data.yaml"), true, false, ValueSources{Env: "eu", Set: []string{"replicas=2"}}); err != nil {
+		t.Fatal(err)
+	}
+	b, _ = ioutil.ReadFile(out)
+	test.ExpectEquals(t, string(b), "httpd:1.0 2 staging local <no value>")
+	env := map[string]string{}
+	ValueSources{Env: "eu"}.env(env)
+	test.ExpectEquals(t, env["HELM_ENV"], "eu")
+
+	for spec, expected := range map[string]interface{}{"replicas=3": 3, "tag=1.10": "1.10", `port="80"`: "80", "debug=false": false, "cmd=a: b": "a: b", "empty=": "", "none=null": nil} {
+		if _, val, err := parseSet(spec); err != nil || val != expected {
+			t.Errorf("Expected %q to set %#v, got %#v, %v", spec, expected, val, err)
+		}
+	}
+	if err := (ValueSources{Set: []string{"replicas"}}).Check(); err == nil || !strings.Contains(err.Error(), "Use KEY=VALUE") {
+		t.Errorf("Expected a --set without a value to fail, got %v", err)
+	}
+	if err := (ValueSources{Files: []string{filepath.Join(dir, "missing.yaml")}}).Check(); err == nil {
+		t.Error("Expected a missing values file to fail")
+	}
+}
+
+func TestGeneratorValues(t *testing.T) {
+	dir, err := ioutil.TempDir("", "helmc-values-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "values.yaml"), []byte("image:\n  tag: \"1.0\"\nports: [80, 443]\nsecret:\n  valueFrom: env:DB_PASSWORD\n"), 0644)
+
+	env := map[string]string{}
+	file := filepath.Join(dir, "rendered", "web.yaml")
+	if err := (ValueSources{Set: []string{"image.tag=2.0", "image.pull-policy=Always"}}).generatorEnv(env, dir, file); err != nil {
+		t.Fatal(err)
+	}
+	test.ExpectEquals(t, env["HELM_VALUES"], file)
+	test.ExpectEquals(t, env["HELM_VALUE_IMAGE_TAG"], "2.0")
+	test.ExpectEquals(t, env["HELM_VALUE_IMAGE_PULL_POLICY"], "Always")
+	test.ExpectEquals(t, env["HELM_VALUE_PORTS_1"], "443")
+	test.ExpectEquals(t, env["HELM_VALUE_SECRET_VALUEFROM"], "env:DB_PASSWORD")
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.ExpectContains(t, string(b), "pull-policy: Always")
+	if fi, err := os.Stat(file); err != nil || runtime.GOOS != "windows" && fi.Mode().Perm() != 0600 {
+		t.Errorf("Expected the values to be only readable by the owner, got %v, %v", fi, err)
+	}
+}
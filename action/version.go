@@ -0,0 +1,78 @@
+package action
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/helm/helm-classic/kubectl"
+	"github.com/helm/helm-classic/log"
+	"github.com/helm/helm-classic/version"
+)
+
+// versionReport is the JSON form of Version's output.
+type versionReport struct {
+	version.Info
+	Kubernetes *kubeVersions `json:"kubernetes,omitempty"`
+}
+
+type kubeVersions struct {
+	Client string `json:"client,omitempty"`
+	Server string `json:"server,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Version prints the version of Helm Classic, and the commit, date and Go
+// version it was built with.
+//
+// With short, only the version is printed. If server is true, the versions
+// of the Kubernetes client and server are printed too. A cluster that cannot
+// be contacted is reported as a warning. output may be "json".
+func Version(output string, short, server bool, client kubectl.Runner) {
+	r := versionReport{Info: version.Get()}
+	if server {
+		r.Kubernetes = &kubeVersions{}
+		v, err := kubectl.ClusterVersions(client)
+		if v.Client != nil {
+			r.Kubernetes.Client = "v" + v.Client.String()
+		}
+		if err != nil {
+			r.Kubernetes.Error = err.Error()
+		} else {
+			r.Kubernetes.Server = "v" + v.Server.String()
+		}
+	}
+
+	switch output {
+	case "json":
+		b, err := json.MarshalIndent(r, "", "  ")
+		if err != nil {
+			log.Die("Could not print the version: %s", err)
+		}
+		log.Msg(string(b))
+		return
+	case "", "text":
+	default:
+		log.Die("Unknown output format %q", output)
+	}
+
+	if short {
+		log.Msg(r.Version)
+	} else {
+		log.Msg("Helm Classic: %s", r.Info)
+	}
+	if k := r.Kubernetes; k != nil {
+		if k.Client != "" {
+			log.Msg("Kubernetes client: %s", k.Client)
+		}
+		if k.Error != "" {
+			log.Warn("%s", k.Error)
+		} else {
+			log.Msg("Kubernetes server: %s", k.Server)
+		}
+	}
+}
+
+// buildInfo describes this build of Helm Classic for bug reports.
+func buildInfo() string {
+	return fmt.Sprintf("Helm Classic %s", version.Get())
+}
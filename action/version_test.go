@@ -0,0 +1,29 @@
+package action
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/helm/helm-classic/kubectl"
+	"github.com/helm/helm-classic/test"
+	"github.com/helm/helm-classic/version"
+)
+
+func TestVersion(t *testing.T) {
+	defer func(c string) { version.GitCommit = c }(version.GitCommit)
+	version.GitCommit = "3eed1e3a9e85"
+
+	out := test.CaptureOutput(func() { Version("", true, false, &kubectl.FakeRunner{}) })
+	if out != version.Version+"\n" {
+		t.Errorf("Expected only the version, got %q", out)
+	}
+
+	out = test.CaptureOutput(func() { Version("json", false, false, &kubectl.FakeRunner{}) })
+	r := versionReport{}
+	if err := json.Unmarshal([]byte(out), &r); err != nil {
+		t.Fatalf("Could not parse %q: %s", out, err)
+	}
+	if r.Version != version.Version || r.GitCommit != "3eed1e3a9e85" || r.Kubernetes != nil {
+		t.Errorf("Unexpected report %+v", r)
+	}
+}
@@ -0,0 +1,77 @@
+package action
+
+import (
+	"fmt"
+	"time"
+
+	helmerrors "github.com/helm/helm-classic/errors"
+	"github.com/helm/helm-classic/kubectl"
+)
+
+// waitKinds are the kinds of the resources that an install with
+// InstallOptions.Wait waits for: those that run replicas.
+var waitKinds = map[string]bool{
+	"Deployment":            true,
+	"ReplicationController": true,
+	"ReplicaSet":            true,
+	"StatefulSet":           true,
+	"DaemonSet":             true,
+}
+
+// waitForReady polls the resources of res that run replicas until each one
+// has the replicas it wants ready, as readiness says, or until timeout. Hooks
+// and resources that failed are not waited for.
+//
+// If some are still not ready, it returns a *helmerrors.NotReadyError that
+// lists them.
+func (c *Client) waitForReady(chartName string, res *InstallResult, timeout time.Duration) error {
+	pending := []*ResourceResult{}
+	for _, rr := range res.Resources {
+		if waitKinds[rr.Kind] && rr.Hook == "" && (rr.Status == StatusCreated || rr.Status == StatusConfigured) {
+			pending = append(pending, rr)
+		}
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	c.Log.Info("Waiting up to %s for %d resources to be ready ...", timeout, len(pending))
+	deadline := time.Now().Add(timeout)
+	details := map[*ResourceResult]string{}
+	for {
+		waiting := pending[:0]
+		for _, rr := range pending {
+			out, err := c.Kube.GetObject(rr.Name, rr.Kind, rr.Namespace)
+			if err != nil {
+				if kubectl.IsNotFound(out) {
+					details[rr] = "not found"
+				} else {
+					details[rr] = "could not be checked: " + failure(out, err)
+				}
+				waiting = append(waiting, rr)
+				continue
+			}
+			ready, detail := readiness(rr.Kind, rr.Name, rr.Namespace, out, c.Kube)
+			if ready == ReadyNo {
+				details[rr] = detail
+				waiting = append(waiting, rr)
+				continue
+			}
+			c.Log.Info("%s %s is ready (%s)", rr.Kind, rr.Name, detail)
+		}
+		pending = waiting
+		if len(pending) == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(pollInterval)
+	}
+
+	e := &helmerrors.NotReadyError{Chart: chartName, Timeout: timeout}
+	for _, rr := range pending {
+		e.Resources = append(e.Resources, fmt.Sprintf("%s %s (%s)", rr.Kind, rr.Name, details[rr]))
+	}
+	return e
+}
@@ -0,0 +1,63 @@
+package action
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	helmerrors "github.com/helm/helm-classic/errors"
+	"github.com/helm/helm-classic/kubectl"
+	"github.com/helm/helm-classic/test"
+	"github.com/helm/helm-classic/util"
+)
+
+func TestInstallWait(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+	test.FakeUpdate(tmpHome)
+	Fetch("redis", "", tmpHome, FetchOptions{})
+	defer func(d time.Duration) { pollInterval = d }(pollInterval)
+	pollInterval = time.Millisecond
+
+	deploy := "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: redis-master\nspec:\n  replicas: 2\n"
+	manifests := util.WorkspaceChartDirectory(tmpHome, "redis", "manifests")
+	if err := ioutil.WriteFile(filepath.Join(manifests, "redis-deploy.yaml"), []byte(deploy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	install := func(client kubectl.Runner) error {
+		var err error
+		test.CaptureOutput(func() {
+			err = Install("redis", tmpHome, InstallOptions{Annotate: true, Labels: true, Wait: 20 * time.Millisecond}, client)
+		})
+		return err
+	}
+
+	client := &kubectl.FakeRunner{Out: []byte(`{"spec": {"replicas": 2}, "status": {"updatedReplicas": 2, "availableReplicas": 1}}`)}
+	err := install(client)
+	var ne *helmerrors.NotReadyError
+	if !errors.As(err, &ne) || len(ne.Resources) != 1 || !strings.Contains(ne.Resources[0], "Deployment redis-master (1/2 available)") {
+		t.Fatalf("Expected the deployment not to be ready, got %v", err)
+	}
+	gets := 0
+	for _, call := range client.Calls {
+		if strings.HasPrefix(call, "get Deployment redis-master") {
+			gets++
+		}
+		if strings.HasPrefix(call, "get Pod") {
+			t.Errorf("Expected only the workloads to be waited for, got %q", call)
+		}
+	}
+	if gets < 2 {
+		t.Errorf("Expected the deployment to be checked more than once, got %v", client.Calls)
+	}
+
+	client = &kubectl.FakeRunner{Out: []byte(`{"spec": {"replicas": 2}, "status": {"updatedReplicas": 2, "availableReplicas": 2}}`)}
+	if err := install(client); err != nil {
+		t.Errorf("Expected the deployment to be ready, got %v", err)
+	}
+}
@@ -0,0 +1,251 @@
+package action
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/helm/helm-classic/chart"
+	"github.com/helm/helm-classic/log"
+	helm "github.com/helm/helm-classic/util"
+)
+
+// Whether an orphaned chart has local modifications.
+const (
+	// ChangesNone is a chart that is the same as a cached copy.
+	ChangesNone = "none"
+	// ChangesLocal is a chart that differs from every cached copy. It is
+	// never deleted by WorkspaceGC.
+	ChangesLocal = "modified"
+	// ChangesUnknown is a chart that has no cached copy to be compared with.
+	ChangesUnknown = "unknown"
+)
+
+// Whether an orphaned chart appears to be installed.
+const (
+	InstalledYes     = "yes"
+	InstalledNo      = "no"
+	InstalledUnknown = "unknown"
+)
+
+// Orphan is a workspace chart that was fetched from a repository, but whose
+// origin no configured repository has any more.
+type Orphan struct {
+	// Chart is the name of the chart in the workspace.
+	Chart string
+	// Origin is the chart it was fetched from, as name@version of a
+	// repository URL.
+	Origin string
+	Digest string
+	// Modified is when a file of the chart was last modified.
+	Modified time.Time
+	// Installed is InstalledYes if any of the chart's manifests are in
+	// Kubernetes, InstalledNo if none are, and InstalledUnknown if
+	// Kubernetes could not be asked.
+	Installed string
+	// Changes is ChangesNone, ChangesLocal, or ChangesUnknown.
+	Changes string
+	// Copies are the cached charts, as repo/chart, that the chart was
+	// compared with.
+	Copies []string
+}
+
+// WorkspaceGC deletes the workspace charts whose origin is gone.
+//
+// - homedir is the home directory for the user
+// - yes deletes them without asking first
+// - dryRun prints the report, and deletes nothing
+//
+// Every orphaned chart is listed. Those that have local modifications, or
+// that appear to be installed, are kept.
+func WorkspaceGC(homedir string, yes, dryRun bool) {
+	c := newClient(homedir, nil)
+	c.Config = mustConfig(homedir)
+	orphans, err := c.WorkspaceOrphans()
+	if err != nil {
+		log.Die("%s", err)
+	}
+	if len(orphans) == 0 {
+		log.Info("Every workspace chart fetched from a repository still has its origin.")
+		return
+	}
+
+	w := tabwriter.NewWriter(log.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "CHART\tORIGIN\tDIGEST\tLAST MODIFIED\tINSTALLED\tCHANGES")
+	for _, o := range orphans {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", o.Chart, o.Origin, shortDigest(o.Digest), o.Modified.Format("2006-01-02 15:04:05"), o.Installed, o.Changes)
+	}
+	w.Flush()
+
+	doomed := []string{}
+	for _, o := range orphans {
+		switch {
+		case o.Changes == ChangesLocal:
+			log.Warn("Keeping %s: it has local modifications relative to %s.", o.Chart, strings.Join(o.Copies, ", "))
+		case o.Installed == InstalledYes:
+			log.Warn("Keeping %s: it appears to be installed. Remove it with 'helmc remove --force'.", o.Chart)
+		case o.Installed == InstalledUnknown:
+			log.Warn("Keeping %s: could not determine if it is installed. Remove it with 'helmc remove --force'.", o.Chart)
+		default:
+			doomed = append(doomed, o.Chart)
+		}
+	}
+	if len(doomed) == 0 {
+		log.Info("No chart can be deleted.")
+		return
+	}
+	if dryRun {
+		log.Info("Would delete %d charts: %s", len(doomed), strings.Join(doomed, ", "))
+		return
+	}
+	if !yes && !Confirm("Delete %d charts from the workspace: %s?", len(doomed), strings.Join(doomed, ", ")) {
+		log.Info("Leaving the workspace as it is.")
+		return
+	}
+	for _, name := range doomed {
+		unlock := lockChart(homedir, name)
+		err := os.RemoveAll(helm.WorkspaceChartDirectory(homedir, name))
+		unlock()
+		if err != nil {
+			log.Err("Could not remove %s: %s", name, err)
+			continue
+		}
+		forgetFetch(homedir, name)
+		log.Info("Removed %s from the workspace", name)
+	}
+}
+
+// WorkspaceOrphans returns the workspace charts whose origin no configured
+// repository has, sorted by name.
+//
+// The origin of a chart is where its Chart.yaml says it was fetched from.
+// It is gone if its repository is no longer configured, or no longer has the
+// chart. Charts that were not fetched, such as those made with 'helmc
+// create', have no origin, and are never orphans.
+//
+// Each orphan is compared with every cached chart of the same name, in any
+// repository, to find its local modifications.
+func (c *Client) WorkspaceOrphans() ([]*Orphan, error) {
+	cfg, err := c.config()
+	if err != nil {
+		return nil, err
+	}
+	dirs, err := filepath.Glob(helm.WorkspaceChartDirectory(c.Home, "*"))
+	if err != nil {
+		return nil, err
+	}
+	res := []*Orphan{}
+	for _, dir := range dirs {
+		cf, err := chart.LoadChartfile(filepath.Join(dir, Chartfile))
+		if err != nil || cf.From == nil {
+			continue
+		}
+		name := filepath.Base(dir)
+		if _, _, err := cachedSource(cfg.Repos, cf.From, name); err == nil {
+			continue
+		}
+
+		o := &Orphan{Chart: name, Origin: cf.From.Name + "@" + cf.From.Version}
+		if cf.From.Repo != "" {
+			o.Origin += " of " + cf.From.Repo
+		}
+		if o.Digest, err = chart.Digest(dir); err != nil {
+			return nil, fmt.Errorf("Could not compute the digest of %s: %s", dir, err)
+		}
+		if o.Modified, err = lastModified(dir); err != nil {
+			return nil, err
+		}
+		if o.Changes, o.Copies, err = cachedChanges(cfg.Repos.Dir, dir, name, cf.From); err != nil {
+			return nil, err
+		}
+		switch installed, err := checkManifests(dir); {
+		case err != nil:
+			c.Log.Debug("Could not check whether %s is installed: %s", name, err)
+			o.Installed = InstalledUnknown
+		case len(installed) > 0:
+			o.Installed = InstalledYes
+		default:
+			o.Installed = InstalledNo
+		}
+		res = append(res, o)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Chart < res[j].Chart })
+	return res, nil
+}
+
+// cachedChanges compares the workspace chart lname, in dir, with every chart
+// in the cache whose Chart.yaml names the chart it was fetched from.
+//
+// It returns the Changes of the chart, and the cached charts, as repo/chart,
+// that were compared.
+func cachedChanges(cacheDir, dir, lname string, from *chart.Dependency) (string, []string, error) {
+	candidates, err := filepath.Glob(filepath.Join(cacheDir, "*", "*", Chartfile))
+	if err != nil {
+		return "", nil, err
+	}
+	copies := []string{}
+	for _, cfile := range candidates {
+		sc, err := chart.LoadChartfile(cfile)
+		if err != nil || sc.Name != from.Name {
+			continue
+		}
+		src := filepath.Dir(cfile)
+		source := filepath.Base(filepath.Dir(src)) + "/" + filepath.Base(src)
+		copies = append(copies, source)
+
+		same, err := sameAsCached(src, dir, lname, from.Repo)
+		if err != nil {
+			return "", nil, err
+		}
+		if same {
+			return ChangesNone, []string{source}, nil
+		}
+	}
+	if len(copies) == 0 {
+		return ChangesUnknown, copies, nil
+	}
+	return ChangesLocal, copies, nil
+}
+
+// sameAsCached reports whether the workspace chart lname, in dir, is what
+// fetching the cached chart in src from origin would make.
+func sameAsCached(src, dir, lname, origin string) (bool, error) {
+	tmp, cleanup, err := helm.TempDir("", "helmc-gc")
+	if err != nil {
+		return false, err
+	}
+	defer cleanup()
+	if _, err := chart.CopyFiles(src, tmp, chart.Limits{}, false); err != nil {
+		return false, fmt.Errorf("Failed copying %s to %s: %s", src, tmp, err)
+	}
+	if err := writeFetchedChartfile(src, tmp, lname, origin); err != nil {
+		return false, err
+	}
+	files, err := diffCharts(tmp, dir, src, lname)
+	if err != nil {
+		return false, err
+	}
+	return len(files) == 0, nil
+}
+
+// lastModified returns when a file of the chart in dir was last modified.
+func lastModified(dir string) (time.Time, error) {
+	var last time.Time
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() && fi.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if fi.ModTime().After(last) {
+			last = fi.ModTime()
+		}
+		return nil
+	})
+	return last, err
+}
@@ -0,0 +1,232 @@
+package action
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/helm/helm-classic/chart"
+	"github.com/helm/helm-classic/helmpath"
+	"github.com/helm/helm-classic/lock"
+	"github.com/helm/helm-classic/log"
+	helm "github.com/helm/helm-classic/util"
+)
+
+// WorkspaceLockFile is the file of the workspace in which every fetch records
+// the chart it fetched, so that 'helmc fetch --locked' can fetch the same
+// charts again.
+const WorkspaceLockFile = "helmc.lock"
+
+const workspaceLockHeader = `# The charts of this workspace, as 'helmc fetch' fetched them. Fetch the
+# same charts again, into another workspace, with 'helmc fetch --locked'.
+`
+
+// WorkspaceLock is the content of a WorkspaceLockFile.
+type WorkspaceLock struct {
+	// Charts are sorted by name.
+	Charts []*LockedChart `yaml:"charts"`
+}
+
+// LockedChart is a chart that was fetched into the workspace.
+type LockedChart struct {
+	// Name is the name of the chart in the workspace.
+	Name string `yaml:"name"`
+	// Repo and Chart name the chart of a repository that it was fetched
+	// from.
+	Repo  string `yaml:"repo,omitempty"`
+	Chart string `yaml:"chart,omitempty"`
+	// Archive is the chart archive that it was fetched from, instead.
+	Archive string `yaml:"archive,omitempty"`
+	Version string `yaml:"version"`
+	// Origin is the URL of the repository, or of the archive.
+	Origin string `yaml:"origin,omitempty"`
+	// Commit is the commit that the cache of a Git repository had checked
+	// out.
+	Commit string `yaml:"commit,omitempty"`
+	// Digest is the digest of the chart as it was fetched. See chart.Digest.
+	Digest string `yaml:"digest"`
+}
+
+// source is what Fetch takes for the chart that lc was fetched from.
+func (lc *LockedChart) source() string {
+	if lc.Archive != "" {
+		return lc.Archive
+	}
+	return lc.Repo + "/" + lc.Chart
+}
+
+// LoadWorkspaceLock reads a WorkspaceLockFile. A file that does not exist is
+// an empty lock.
+func LoadWorkspaceLock(path string) (*WorkspaceLock, error) {
+	lk := &WorkspaceLock{}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return lk, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, lk); err != nil {
+		return nil, fmt.Errorf("Could not parse %s: %s", path, err)
+	}
+	return lk, nil
+}
+
+// Save writes lk to path.
+func (lk *WorkspaceLock) Save(path string) error {
+	data, err := yaml.Marshal(lk)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append([]byte(workspaceLockHeader), data...), 0644)
+}
+
+// Lookup returns the chart of a name, or nil.
+func (lk *WorkspaceLock) Lookup(name string) *LockedChart {
+	for _, lc := range lk.Charts {
+		if lc.Name == name {
+			return lc
+		}
+	}
+	return nil
+}
+
+// set adds lc, or replaces the chart of its name.
+func (lk *WorkspaceLock) set(lc *LockedChart) {
+	lk.remove(lc.Name)
+	lk.Charts = append(lk.Charts, lc)
+	sort.Slice(lk.Charts, func(i, j int) bool { return lk.Charts[i].Name < lk.Charts[j].Name })
+}
+
+// remove removes the chart of a name.
+func (lk *WorkspaceLock) remove(name string) {
+	charts := lk.Charts[:0]
+	for _, lc := range lk.Charts {
+		if lc.Name != name {
+			charts = append(charts, lc)
+		}
+	}
+	lk.Charts = charts
+}
+
+// updateWorkspaceLock changes the WorkspaceLockFile of a home with fn, under
+// a lock, since fetches of other charts change it too. The file is only
+// written if fn returns true.
+func updateWorkspaceLock(homedir string, fn func(lk *WorkspaceLock) bool) error {
+	l, err := lock.Acquire(helmpath.Home(homedir).Locks(WorkspaceLockFile + ".lock"))
+	if err != nil {
+		return err
+	}
+	defer l.Release()
+	path := helmpath.Home(homedir).Workspace(WorkspaceLockFile)
+	lk, err := LoadWorkspaceLock(path)
+	if err != nil {
+		return err
+	}
+	if !fn(lk) {
+		return nil
+	}
+	return lk.Save(path)
+}
+
+// recordFetch records the workspace chart lname, which was just fetched, in
+// the WorkspaceLockFile. A chart that cannot be recorded is only warned
+// about, since the fetch itself succeeded.
+func (c *Client) recordFetch(lname string, lc *LockedChart) {
+	dir := helm.WorkspaceChartDirectory(c.Home, lname)
+	cf, err := chart.LoadChartfile(filepath.Join(dir, Chartfile))
+	if err == nil {
+		lc.Digest, err = chart.Digest(dir)
+	}
+	if err == nil {
+		lc.Name, lc.Version = lname, cf.Version
+		if cf.From != nil {
+			lc.Version, lc.Origin = cf.From.Version, cf.From.Repo
+		}
+		err = updateWorkspaceLock(c.Home, func(lk *WorkspaceLock) bool {
+			lk.set(lc)
+			return true
+		})
+	}
+	if err != nil {
+		c.Log.Warn("Could not record %s in %s: %s", lname, WorkspaceLockFile, err)
+	}
+}
+
+// forgetFetch removes the chart lname, which was removed from the workspace,
+// from the WorkspaceLockFile.
+func forgetFetch(homedir, lname string) {
+	err := updateWorkspaceLock(homedir, func(lk *WorkspaceLock) bool {
+		if lk.Lookup(lname) == nil {
+			return false
+		}
+		lk.remove(lname)
+		return true
+	})
+	if err != nil {
+		log.Warn("Could not remove %s from %s: %s", lname, WorkspaceLockFile, err)
+	}
+}
+
+// checkLocked returns an error unless a fetched chart, whose digest is
+// digest, is the one that o.Lock records.
+func checkLocked(o FetchOptions, label, digest string) error {
+	if o.Lock == nil || o.Lock.Digest == digest {
+		return nil
+	}
+	return fmt.Errorf("%s has digest %s, not %s as the lock file records, so the chart changed since it was locked. Fetch it without --locked to take the new one.", label, digest, o.Lock.Digest)
+}
+
+// FetchLocked fetches the charts of a WorkspaceLockFile into the workspace,
+// as they were when they were recorded.
+//
+// - lockfile is the file; if it is empty, it is the WorkspaceLockFile of the
+// workspace
+// - homedir is the home directory for the user
+// - names are the charts to fetch, by their names in the workspace; if none
+// are given, every chart of the file is fetched
+// - o are the options of each fetch; its Repo, Deps, and Lock are ignored
+//
+// A chart of a Git repository is only fetched if the cache of the repository
+// has the commit of the lock checked out, and every chart only if it has the
+// digest of the lock, so that the workspace is the same as the one that the
+// lock was recorded from, or the fetch fails. A workspace chart that is
+// already the same is left alone.
+func FetchLocked(lockfile, homedir string, names []string, o FetchOptions) error {
+	if lockfile == "" {
+		lockfile = helmpath.Home(homedir).Workspace(WorkspaceLockFile)
+	}
+	lk, err := LoadWorkspaceLock(lockfile)
+	if err != nil {
+		return err
+	}
+	charts := lk.Charts
+	if len(names) > 0 {
+		charts = nil
+		for _, name := range names {
+			lc := lk.Lookup(name)
+			if lc == nil {
+				return fmt.Errorf("%s does not have a chart named %s", lockfile, name)
+			}
+			charts = append(charts, lc)
+		}
+	}
+	if len(charts) == 0 {
+		return fmt.Errorf("%s has no charts. Fetching a chart records it there.", lockfile)
+	}
+
+	c := newClient(homedir, nil)
+	c.Config = mustConfig(homedir)
+	o.Repo, o.Deps, o.IfAbsent = "", false, true
+	for _, lc := range charts {
+		o.Lock = lc
+		if _, err := c.Fetch(lc.source(), lc.Name, o); err != nil {
+			return fmt.Errorf("Could not fetch %s as locked: %w", lc.Name, err)
+		}
+	}
+	c.Log.Info("Fetched %d charts as %s records them", len(charts), lockfile)
+	return nil
+}
@@ -0,0 +1,86 @@
+package action
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/helm/helm-classic/test"
+	"github.com/helm/helm-classic/util"
+)
+
+func TestWorkspaceGC(t *testing.T) {
+	kg := kubeGet
+	defer func() { kubeGet = kg }()
+	kubeGet = mockNotFoundGetter
+
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+	test.FakeUpdate(tmpHome)
+	for _, name := range []string{"keep", "kitchensink", "redis", "dep1"} {
+		if err := Fetch(name, "", tmpHome, FetchOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	Create("mine", tmpHome, "")
+
+	// The charts repository no longer has keep, kitchensink, or redis. Copies
+	// of kitchensink and redis are left in the cache of another repository,
+	// and redis has been changed since it was fetched.
+	cache := util.CacheDirectory(tmpHome)
+	os.MkdirAll(filepath.Join(cache, "old"), 0755)
+	os.RemoveAll(filepath.Join(cache, "charts", "keep"))
+	os.Rename(filepath.Join(cache, "charts", "kitchensink"), filepath.Join(cache, "old", "kitchensink"))
+	os.Rename(filepath.Join(cache, "charts", "redis"), filepath.Join(cache, "old", "redis"))
+	ioutil.WriteFile(filepath.Join(util.WorkspaceChartDirectory(tmpHome, "redis"), "notes.txt"), []byte("Mine.\n"), 0644)
+
+	c := newClient(tmpHome, nil)
+	c.Config = mustConfig(tmpHome)
+	orphans, err := c.WorkspaceOrphans()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orphans) != 3 {
+		t.Fatalf("Expected 3 orphans, got %d", len(orphans))
+	}
+	expect := []struct{ chart, changes string }{
+		{"keep", ChangesUnknown},
+		{"kitchensink", ChangesNone},
+		{"redis", ChangesLocal},
+	}
+	for i, e := range expect {
+		o := orphans[i]
+		test.ExpectEquals(t, o.Chart, e.chart)
+		test.ExpectEquals(t, o.Changes, e.changes)
+		test.ExpectEquals(t, o.Installed, InstalledNo)
+		if len(o.Digest) != 64 || o.Modified.IsZero() {
+			t.Errorf("Expected %s to have a digest and a modification time, got %q and %s", o.Chart, o.Digest, o.Modified)
+		}
+	}
+	test.ExpectEquals(t, strings.Join(orphans[2].Copies, ","), "old/redis")
+
+	actual := test.CaptureOutput(func() { WorkspaceGC(tmpHome, false, true) })
+	test.ExpectContains(t, actual, "Keeping redis: it has local modifications relative to old/redis.")
+	test.ExpectContains(t, actual, "Would delete 2 charts: keep, kitchensink")
+	if _, err := os.Stat(util.WorkspaceChartDirectory(tmpHome, "keep")); err != nil {
+		t.Errorf("Expected a dry run to delete nothing: %s", err)
+	}
+
+	// Charts that appear to be installed are kept.
+	kubeGet = mockFoundGetter
+	actual = test.CaptureOutput(func() { WorkspaceGC(tmpHome, true, false) })
+	test.ExpectContains(t, actual, "Keeping kitchensink: it appears to be installed.")
+	test.ExpectContains(t, actual, "No chart can be deleted.")
+
+	kubeGet = mockNotFoundGetter
+	actual = test.CaptureOutput(func() { WorkspaceGC(tmpHome, true, false) })
+	test.ExpectContains(t, actual, "Removed kitchensink from the workspace")
+	for name, exists := range map[string]bool{"keep": false, "kitchensink": false, "redis": true, "dep1": true, "mine": true} {
+		_, err := os.Stat(util.WorkspaceChartDirectory(tmpHome, name))
+		if exists != (err == nil) {
+			t.Errorf("Expected %s to exist: %t", name, exists)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+// Package audit records the changes that Helm Classic makes to Kubernetes.
+//
+// The audit log is a file of JSON lines, one Entry per install or uninstall,
+// that is only ever appended to. Processes that share a log take its lock
+// while they write, so entries are never interleaved.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/helm/helm-classic/lock"
+)
+
+// Operations recorded in an Entry.
+const (
+	OpInstall   = "install"
+	OpUninstall = "uninstall"
+	OpPrune     = "prune"
+	OpRollback  = "rollback"
+	OpUpgrade   = "upgrade"
+)
+
+// Outcomes of an operation.
+const (
+	// Succeeded is an operation that did all it set out to do.
+	Succeeded = "succeeded"
+	// Failed is an operation that stopped, or that left some resources
+	// unchanged. Its resources say which.
+	Failed = "failed"
+)
+
+// Entry is a single operation on Kubernetes.
+type Entry struct {
+	Time time.Time `json:"time"`
+	// User, Context, and Cluster are the kubeconfig names that the operation
+	// ran as.
+	User    string `json:"user"`
+	Context string `json:"context"`
+	Cluster string `json:"cluster"`
+	// Operation is OpInstall, OpUninstall, OpPrune, OpRollback, or OpUpgrade.
+	Operation string `json:"operation"`
+	Chart     string `json:"chart"`
+	Version   string `json:"version"`
+	Digest    string `json:"digest,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	// Resources are the resources that were changed, or that Kubernetes
+	// refused to change.
+	Resources []*Resource `json:"resources"`
+	// Outcome is Succeeded or Failed, and Error says why an operation failed.
+	Outcome string `json:"outcome"`
+	Error   string `json:"error,omitempty"`
+	// Parameters are those that an install ran with. Uninstalls, and the
+	// installs of older releases, have none.
+	Parameters *Parameters `json:"parameters,omitempty"`
+}
+
+// Parameters are the options of an install, as they were given, so that the
+// install can be repeated. Its namespace is that of the Entry.
+type Parameters struct {
+	// Workspace is the name of the chart in the workspace, which may differ
+	// from the name in its Chart.yaml.
+	Workspace  string   `json:"workspace"`
+	Mode       string   `json:"mode"`
+	Atomic     bool     `json:"atomic,omitempty"`
+	Annotate   bool     `json:"annotate"`
+	Labels     bool     `json:"labels,omitempty"`
+	Force      bool     `json:"force,omitempty"`
+	Generate   bool     `json:"generate,omitempty"`
+	SkipSchema bool     `json:"skipSchema,omitempty"`
+	Exclude    []string `json:"exclude,omitempty"`
+	// InjectNamespace is set if the namespace was written into the
+	// manifests that had none.
+	InjectNamespace bool `json:"injectNamespace,omitempty"`
+	// Values are the values files of the templates, as absolute paths, and
+	// Set their KEY=VALUE values.
+	Values []string `json:"values,omitempty"`
+	Set    []string `json:"set,omitempty"`
+	// SetFrom are the value sources of the templates, redacted, such as
+	// "db.password=env:<redacted>".
+	SetFrom []string `json:"setFrom,omitempty"`
+	// Env is the environment whose values file of the chart was merged
+	// over its values.yaml, such as "staging".
+	Env string `json:"env,omitempty"`
+}
+
+// Resource is what an operation did to one resource.
+type Resource struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+	// Status is the outcome for the resource, such as "created" or "deleted".
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Append adds an entry to the end of the log at path, creating the log and
+// its directory if necessary.
+//
+// The log's lock, path.lock, is held while the entry is written.
+func Append(path string, e *Entry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	l, err := lock.Acquire(path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer l.Release()
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Tail returns the last n entries of the log at path, oldest first. If n is
+// not positive, every entry is returned.
+//
+// A log that does not exist has no entries. A line that is not an entry is
+// an error, which gives its line number.
+func Tail(path string, n int) ([]*Entry, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return []*Entry{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return tail(f, path, n)
+}
+
+// LastInstall returns the latest install of the chart that has the name in
+// the workspace, whether it succeeded or not, or nil if the log at path has
+// none with Parameters.
+func LastInstall(path, workspace string) (*Entry, error) {
+	entries, err := Tail(path, 0)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		if e.Operation == OpInstall && e.Parameters != nil && e.Parameters.Workspace == workspace {
+			return e, nil
+		}
+	}
+	return nil, nil
+}
+
+func tail(r io.Reader, path string, n int) ([]*Entry, error) {
+	entries := []*Entry{}
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; s.Scan(); line++ {
+		b := bytes.TrimSpace(s.Bytes())
+		if len(b) == 0 {
+			continue
+		}
+		e := &Entry{}
+		if err := json.Unmarshal(b, e); err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", path, line, err)
+		}
+		entries = append(entries, e)
+		if n > 0 && len(entries) > n {
+			entries = entries[1:]
+		}
+	}
+	return entries, s.Err()
+}
@@ -0,0 +1,58 @@
+package audit
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAppendTail(t *testing.T) {
+	dir, err := ioutil.TempDir("", "helmc-audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "logs", "audit.log")
+
+	if entries, err := Tail(path, 10); err != nil || len(entries) != 0 {
+		t.Errorf("Expected a missing log to be empty, got %v, %v", entries, err)
+	}
+	for _, chart := range []string{"a", "b", "c"} {
+		e := &Entry{Time: time.Now(), Operation: OpInstall, Chart: chart, Outcome: Succeeded,
+			Resources: []*Resource{{Kind: "Pod", Name: chart, Status: "created"}}}
+		if err := Append(path, e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := os.Stat(path + ".lock"); !os.IsNotExist(err) {
+		t.Errorf("Expected the lock to be released, got %v", err)
+	}
+
+	entries, err := Tail(path, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Chart != "b" || entries[1].Chart != "c" {
+		t.Errorf("Expected the last two entries, got %+v", entries)
+	}
+	if entries, _ := Tail(path, 0); len(entries) != 3 {
+		t.Errorf("Expected every entry, got %d", len(entries))
+	}
+	if entries[1].Resources[0].Name != "c" {
+		t.Errorf("Expected the resources to be read back, got %+v", entries[1].Resources[0])
+	}
+
+	b, _ := ioutil.ReadFile(path)
+	if n := strings.Count(string(b), "\n"); n != 3 {
+		t.Errorf("Expected one line per entry, got %d", n)
+	}
+	f, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	f.WriteString("not json\n")
+	f.Close()
+	if _, err := Tail(path, 0); err == nil || !strings.Contains(err.Error(), "audit.log:4:") {
+		t.Errorf("Expected the bad line to be reported, got %v", err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/helm/helm-classic/manifest"
 )
@@ -76,8 +77,46 @@ const (
 
 	// AnnChartName is the annotation key for a chart name.
 	AnnChartName = "chart.helm.sh/name"
+
+	// AnnChartDigest is the annotation key for the digest of the installed chart.
+	AnnChartDigest = "chart.helm.sh/digest"
+
+	// AnnInstalledAt is the annotation key for the time a chart was installed, in RFC 3339 format.
+	AnnInstalledAt = "chart.helm.sh/installed-at"
+
+	// LabelChartName is the label key for the name of the chart that a
+	// resource was installed from. Unlike the annotations, it can be
+	// selected on.
+	LabelChartName = "chart.helm.sh/name"
+
+	// LabelHeritage is the label key that marks a resource as managed, with
+	// the value Heritage for those that helmc installed.
+	LabelHeritage = "heritage"
+
+	// Heritage is the value of LabelHeritage for the resources of helmc.
+	Heritage = "helm-classic"
+
+	// LabelChart is the label key for the name and version of the chart
+	// that a resource was installed from, as NAME-VERSION.
+	LabelChart = "chart"
 )
 
+// LabelValue returns s as a Kubernetes label value: at most 63 characters of
+// letters, digits, '-', '_', and '.', beginning and ending with a letter or a
+// digit. Other characters, such as the '+' of a semantic version, become '_'.
+func LabelValue(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			b[i] = '_'
+		}
+	}
+	if len(b) > 63 {
+		b = b[:63]
+	}
+	return strings.Trim(string(b), "-_.")
+}
+
 // attachManifests sorts manifests into their respective categories, adding to the Chart.
 func (c *Chart) attachManifests(manifests []*manifest.Manifest) {
 	c.Manifests = manifests
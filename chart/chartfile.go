@@ -1,13 +1,16 @@
 package chart
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 
 	"github.com/Masterminds/semver"
 	"github.com/helm/helm-classic/log"
+	helm "github.com/helm/helm-classic/util"
 	"gopkg.in/yaml.v2"
 )
 
@@ -21,8 +24,44 @@ type Chartfile struct {
 	Description  string            `yaml:"description"`
 	Maintainers  []string          `yaml:"maintainers,omitempty"`
 	Details      string            `yaml:"details,omitempty"`
+	Keywords     []string          `yaml:"keywords,omitempty"`
 	Dependencies []*Dependency     `yaml:"dependencies,omitempty"`
 	PreInstall   map[string]string `yaml:"preinstall,omitempty"`
+	Kubectl      *KubectlArgs      `yaml:"kubectl,omitempty"`
+	// Namespace is the namespace that the chart is installed into, and
+	// uninstalled from, when no other is given.
+	Namespace string `yaml:"namespace,omitempty"`
+	// Deprecated marks a chart that is superseded, and should no longer be
+	// used. DeprecationMessage says why, and what to use instead.
+	Deprecated         bool   `yaml:"deprecated,omitempty"`
+	DeprecationMessage string `yaml:"deprecationMessage,omitempty"`
+	// ValuesSchema describes the values of the chart's templates, as a
+	// SchemaFile does, and ValueTypes does so with the simpler spec of
+	// ParseValueTypes. A chart declares at most one schema; see
+	// LoadValuesSchema.
+	ValuesSchema interface{} `yaml:"valuesSchema,omitempty"`
+	ValueTypes   interface{} `yaml:"valueTypes,omitempty"`
+}
+
+// DeprecationNotice returns what to tell the user of a deprecated chart, or ""
+// if the chart is not deprecated.
+func (c *Chartfile) DeprecationNotice() string {
+	switch {
+	case !c.Deprecated:
+		return ""
+	case c.DeprecationMessage == "":
+		return fmt.Sprintf("Chart %s is deprecated.", c.Name)
+	}
+	return fmt.Sprintf("Chart %s is deprecated: %s", c.Name, c.DeprecationMessage)
+}
+
+// KubectlArgs are extra flags that a chart needs kubectl to be given, such as
+// --validate=false for a chart of custom resources.
+type KubectlArgs struct {
+	// Apply is given to kubectl create, apply, and replace.
+	Apply []string `yaml:"apply,omitempty"`
+	// Delete is given to kubectl delete.
+	Delete []string `yaml:"delete,omitempty"`
 }
 
 // Dependency describes a specific dependency.
@@ -54,29 +93,27 @@ func (c *Chartfile) Save(filename string) error {
 
 // RepoName gets the name of the Git repo, or an empty string if none is found.
 func RepoName(chartpath string) string {
-	wd, err := os.Getwd()
+	name, err := RepoOrigin(chartpath)
 	if err != nil {
-		log.Err("Could not get working directory: %s", err)
-		return ""
+		log.Err("%s", err)
 	}
-	defer func() {
-		if err := os.Chdir(wd); err != nil {
-			log.Die("Unrecoverable error: %s", err)
-		}
-	}()
+	return name
+}
 
-	if err := os.Chdir(chartpath); err != nil {
-		log.Err("Could not find chartpath %s: %s", chartpath, err)
-		return ""
+// RepoOrigin returns the URL of the origin remote of the Git repo that holds chartpath.
+func RepoOrigin(chartpath string) (string, error) {
+	if _, err := os.Stat(chartpath); err != nil {
+		return "", fmt.Errorf("Could not find chartpath %s: %s", chartpath, err)
 	}
 
-	out, err := exec.Command("git", "config", "--get", "remote.origin.url").CombinedOutput()
+	cmd := exec.Command("git", "config", "--get", "remote.origin.url")
+	cmd.Dir = chartpath
+	out, err := helm.Exec("git config "+filepath.Base(chartpath), cmd)
 	if err != nil {
-		log.Err("Git failed to get the origin name: %s %s", err, string(out))
-		return ""
+		return "", fmt.Errorf("Git failed to get the origin name: %s", err)
 	}
 
-	return strings.TrimSpace(string(out))
+	return strings.TrimSpace(string(out)), nil
 }
 
 // VersionOK returns true if the given version meets the constraints.
@@ -0,0 +1,166 @@
+package chart
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ChecksumPrefix starts a checksum, which is a SHA-256 digest of every file
+// of a chart.
+const ChecksumPrefix = "sha256:"
+
+// Digest returns a SHA-256 digest of the chart in dir.
+//
+// The digest covers the path and contents of every file in the chart, so it
+// changes whenever the chart does. Version control directories, and the
+// files that the chart's .helmignore excludes, are skipped.
+func Digest(dir string) (string, error) {
+	h := sha256.New()
+	err := walkFiles(dir, nil, func(rel string, b []byte) {
+		fmt.Fprintf(h, "%s\x00%d\x00", rel, len(b))
+		h.Write(b)
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Checksum returns a checksum of the chart in dir to pin it by, or to sign
+// it by, such as "sha256:3f2a...".
+//
+// Unlike Digest, it covers every file that CopyFiles copies and Load reads:
+// .helmignore and the files that it excludes, version control directories,
+// whether each file may be executed, and each symlink, by what it points to.
+// A chart cannot be changed in a way that is fetched or installed without
+// changing its checksum.
+func Checksum(dir string) (string, error) {
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	err = filepath.Walk(root, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		mode := fi.Mode()
+		switch {
+		case mode.IsDir():
+			fmt.Fprintf(h, "d\x00%s\x00", rel)
+		case mode&os.ModeSymlink != 0:
+			link, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			// CopyFiles makes a link inside the chart relative, so the
+			// checksum names what it points to relative to the chart.
+			if dest := linkTarget(p, link); within(root, dest) {
+				if link, err = filepath.Rel(root, dest); err != nil {
+					return err
+				}
+				link = filepath.ToSlash(link)
+			}
+			fmt.Fprintf(h, "l\x00%s\x00%s\x00", rel, link)
+		case mode.IsRegular():
+			b, err := ioutil.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(h, "f\x00%s\x00%t\x00%d\x00", rel, mode&0111 != 0, len(b))
+			h.Write(b)
+		default:
+			fmt.Fprintf(h, "o\x00%s\x00", rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return ChecksumPrefix + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// VerifyChecksum checks that the chart in dir has the checksum want. The
+// "sha256:" of want may be left out, but no other algorithm is known. The
+// error of a mismatch gives both checksums.
+func VerifyChecksum(dir, want string) error {
+	want = strings.ToLower(strings.TrimSpace(want))
+	if i := strings.Index(want, ":"); i >= 0 && want[:i+1] != ChecksumPrefix {
+		return fmt.Errorf("unknown checksum algorithm %q: use %s<digest>", want[:i], ChecksumPrefix)
+	}
+	if !strings.HasPrefix(want, ChecksumPrefix) {
+		want = ChecksumPrefix + want
+	}
+	if _, err := hex.DecodeString(want[len(ChecksumPrefix):]); err != nil || len(want) != len(ChecksumPrefix)+2*sha256.Size {
+		return fmt.Errorf("malformed checksum %q: expected %s and 64 hex digits", want, ChecksumPrefix)
+	}
+	got, err := Checksum(dir)
+	if err != nil {
+		return err
+	}
+	if got != want {
+		return fmt.Errorf("checksum mismatch: expected %s, got %s", want, got)
+	}
+	return nil
+}
+
+// Files returns the SHA-256 digest of each file of the chart in dir, by its
+// slash-separated path relative to dir.
+//
+// Version control directories, and the files that ig excludes, are skipped.
+// If ig is nil, the chart's own .helmignore is used, as for Digest.
+func Files(dir string, ig *Ignore) (map[string]string, error) {
+	files := map[string]string{}
+	err := walkFiles(dir, ig, func(rel string, b []byte) {
+		sum := sha256.Sum256(b)
+		files[rel] = hex.EncodeToString(sum[:])
+	})
+	return files, err
+}
+
+// walkFiles calls fn with the path and contents of each file of the chart
+// in dir that ig does not exclude, in lexical order. If ig is nil, it is
+// loaded from dir.
+func walkFiles(dir string, ig *Ignore, fn func(rel string, b []byte)) error {
+	if ig == nil {
+		var err error
+		if ig, err = LoadIgnore(dir); err != nil {
+			return err
+		}
+	}
+	return filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if fi.IsDir() {
+			if fi.Name() == ".git" || (rel != "." && ig.Ignored(rel, true)) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !fi.Mode().IsRegular() || ig.Ignored(rel, false) {
+			return nil
+		}
+		b, err := ioutil.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		fn(rel, b)
+		return nil
+	})
+}
@@ -0,0 +1,176 @@
+package chart
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDigest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "digest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.MkdirAll(filepath.Join(dir, "manifests"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "Chart.yaml"), []byte("name: redis\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "manifests", "pod.yaml"), []byte("kind: Pod\n"), 0644)
+
+	d1, err := Digest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d2, _ := Digest(dir); d1 != d2 {
+		t.Errorf("Expected the digest to be stable, got %s and %s", d1, d2)
+	}
+
+	// Version control files do not count.
+	os.MkdirAll(filepath.Join(dir, ".git"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, ".git", "HEAD"), []byte("ref: master\n"), 0644)
+	if d, _ := Digest(dir); d != d1 {
+		t.Errorf("Expected .git to be ignored")
+	}
+
+	ioutil.WriteFile(filepath.Join(dir, "manifests", "pod.yaml"), []byte("kind: Service\n"), 0644)
+	if d, _ := Digest(dir); d == d1 {
+		t.Errorf("Expected the digest to change with the chart")
+	}
+}
+
+func TestDigestHelmignore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "digest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.MkdirAll(filepath.Join(dir, "manifests"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "Chart.yaml"), []byte("name: redis\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, IgnoreFile), []byte("# Local files\n*.bak\nnotes/\nmanifests/local-*.yaml\n"), 0644)
+
+	d1, err := Digest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.MkdirAll(filepath.Join(dir, "notes"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "notes", "todo.txt"), []byte("todo\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "manifests", "pod.yaml.bak"), []byte("kind: Pod\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "manifests", "local-pod.yaml"), []byte("kind: Pod\n"), 0644)
+	if d, _ := Digest(dir); d != d1 {
+		t.Errorf("Expected ignored files not to change the digest")
+	}
+
+	files, err := Files(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || files["Chart.yaml"] == "" || files[IgnoreFile] == "" {
+		t.Errorf("Expected only Chart.yaml and .helmignore, got %v", files)
+	}
+
+	ioutil.WriteFile(filepath.Join(dir, "manifests", "pod.yaml"), []byte("kind: Pod\n"), 0644)
+	if d, _ := Digest(dir); d == d1 {
+		t.Errorf("Expected a file that is not ignored to change the digest")
+	}
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	dir, err := ioutil.TempDir("", "digest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "Chart.yaml"), []byte("name: redis\n"), 0644)
+
+	sum, err := Checksum(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(sum, ChecksumPrefix) || len(sum) != len(ChecksumPrefix)+64 {
+		t.Errorf("Expected a sha256 checksum, got %s", sum)
+	}
+	d := strings.TrimPrefix(sum, ChecksumPrefix)
+	for _, want := range []string{sum, d, strings.ToUpper(sum)} {
+		if err := VerifyChecksum(dir, want); err != nil {
+			t.Errorf("Expected %s to match: %s", want, err)
+		}
+	}
+
+	other := "sha256:" + strings.Repeat("0", 64)
+	if err := VerifyChecksum(dir, other); err == nil || !strings.Contains(err.Error(), "expected "+other+", got "+sum) {
+		t.Errorf("Expected a mismatch with both checksums, got %v", err)
+	}
+	for _, bad := range []string{"md5:" + d, "sha256:abc", "sha256:" + strings.Repeat("z", 64)} {
+		if err := VerifyChecksum(dir, bad); err == nil || strings.Contains(err.Error(), "mismatch") {
+			t.Errorf("Expected %s to be refused, got %v", bad, err)
+		}
+	}
+}
+
+func TestChecksumCoversEverything(t *testing.T) {
+	dir, err := ioutil.TempDir("", "digest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.MkdirAll(filepath.Join(dir, "manifests"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "Chart.yaml"), []byte("name: redis\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "manifests", "pod.yaml"), []byte("kind: Pod\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, IgnoreFile), []byte(".*\n"), 0644)
+
+	sum, err := Checksum(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// What CopyFiles copies has the same checksum.
+	dst, err := ioutil.TempDir("", "digest-copy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dst)
+	if _, err := CopyFiles(dir, dst, Limits{}, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyChecksum(dst, sum); err != nil {
+		t.Errorf("Expected a copy to have the same checksum: %s", err)
+	}
+
+	tamper := []struct {
+		name   string
+		change func()
+	}{
+		{"a changed .helmignore", func() {
+			ioutil.WriteFile(filepath.Join(dir, IgnoreFile), []byte(".*\nevil.yaml\n"), 0644)
+		}},
+		{"an ignored manifest", func() {
+			ioutil.WriteFile(filepath.Join(dir, "manifests", "evil.yaml"), []byte("kind: Pod\n"), 0644)
+		}},
+		{"a symlinked manifest", func() {
+			ioutil.WriteFile(filepath.Join(dir, ".evil"), []byte("kind: Pod\n"), 0644)
+			os.Symlink("../.evil", filepath.Join(dir, "manifests", "link.yaml"))
+		}},
+		{"an executable file", func() {
+			os.Chmod(filepath.Join(dir, "manifests", "pod.yaml"), 0755)
+		}},
+	}
+	for _, tt := range tamper {
+		tt.change()
+		if err := VerifyChecksum(dir, sum); err == nil {
+			t.Errorf("Expected the checksum to change with %s", tt.name)
+		}
+		if sum, err = Checksum(dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Load installs what the checksum covers.
+	ch, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(ch.Manifests); n != 3 {
+		t.Errorf("Expected 3 manifests, got %d", n)
+	}
+}
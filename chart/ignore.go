@@ -0,0 +1,71 @@
+package chart
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// IgnoreFile is the name of the file that lists the files of a chart that
+// are not part of it, such as editor backups and local notes.
+const IgnoreFile = ".helmignore"
+
+// Ignore is the set of patterns in a chart's .helmignore.
+//
+// Each line of the file is a shell pattern, as understood by path.Match.
+// Blank lines and lines that start with '#' are skipped. A pattern with a
+// slash is matched against the path relative to the chart, and one without
+// against the base name of each file and directory. A trailing slash
+// matches directories only.
+type Ignore struct {
+	patterns []string
+}
+
+// LoadIgnore reads the .helmignore of the chart in dir. A chart without one
+// ignores nothing.
+func LoadIgnore(dir string) (*Ignore, error) {
+	f, err := os.Open(filepath.Join(dir, IgnoreFile))
+	if os.IsNotExist(err) {
+		return &Ignore{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ig := &Ignore{}
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if _, err := path.Match(line, ""); err != nil {
+			return nil, err
+		}
+		ig.patterns = append(ig.patterns, line)
+	}
+	return ig, s.Err()
+}
+
+// Ignored returns true if the file or directory at the slash-separated
+// path rel, relative to the chart, is ignored.
+func (ig *Ignore) Ignored(rel string, isDir bool) bool {
+	for _, p := range ig.patterns {
+		if strings.HasSuffix(p, "/") {
+			if !isDir {
+				continue
+			}
+			p = strings.TrimSuffix(p, "/")
+		}
+		name := path.Base(rel)
+		if strings.Contains(p, "/") {
+			name = rel
+		}
+		if ok, _ := path.Match(strings.TrimPrefix(p, "/"), name); ok {
+			return true
+		}
+	}
+	return false
+}
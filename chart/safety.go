@@ -0,0 +1,259 @@
+package chart
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	helm "github.com/helm/helm-classic/util"
+)
+
+// Limits bound the files of a chart that is fetched or unpacked. Zero is no
+// bound.
+type Limits struct {
+	// Files is the number of regular files.
+	Files int
+	// Bytes is the total size of the regular files.
+	Bytes int64
+}
+
+// DefaultLimits are the limits of a chart, unless the configuration sets
+// others. They are far above what any real chart needs.
+var DefaultLimits = Limits{Files: 5000, Bytes: 100 << 20}
+
+// UnsafeFile is a file of a chart that is not safe to copy as it is: a
+// symlink that points outside the chart, a setuid or setgid file, or a file
+// that is not a regular file. A chart that exceeds its limits is reported as
+// an UnsafeFile without a Path.
+type UnsafeFile struct {
+	// Path is the path of the file in the chart, with slashes.
+	Path string
+	// Problem says what is unsafe about the file.
+	Problem string
+	// Fix says what CopyFiles did about it.
+	Fix string
+}
+
+func (u *UnsafeFile) Error() string {
+	msg := u.Problem
+	if u.Path != "" {
+		msg = u.Path + ": " + msg
+	}
+	if u.Fix != "" {
+		msg += " (" + u.Fix + ")"
+	}
+	return msg
+}
+
+// CheckFiles returns the unsafe files of the chart in dir, and whether it
+// exceeds the limits, without changing anything.
+func CheckFiles(dir string, limits Limits) ([]*UnsafeFile, error) {
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return nil, err
+	}
+	unsafe := []*UnsafeFile{}
+	files, size := 0, int64(0)
+	err = filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if fi.Mode().IsRegular() {
+			files++
+			size += fi.Size()
+		}
+		if p := fileProblem(root, path, fi); p != "" {
+			unsafe = append(unsafe, &UnsafeFile{Path: filepath.ToSlash(rel), Problem: p})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if limits.Files > 0 && files > limits.Files {
+		unsafe = append(unsafe, &UnsafeFile{Problem: fmt.Sprintf("the chart has %d files, more than the limit of %d", files, limits.Files)})
+	}
+	if limits.Bytes > 0 && size > limits.Bytes {
+		unsafe = append(unsafe, &UnsafeFile{Problem: fmt.Sprintf("the chart has %s of files, more than the limit of %s", helm.ByteSize(size), helm.ByteSize(limits.Bytes))})
+	}
+	return unsafe, nil
+}
+
+// fileProblem returns what is unsafe about a file of the chart in root, or
+// "" if nothing is.
+func fileProblem(root, path string, fi os.FileInfo) string {
+	mode := fi.Mode()
+	switch {
+	case mode&os.ModeSymlink != 0:
+		target, err := os.Readlink(path)
+		if err != nil {
+			return fmt.Sprintf("is a symlink that cannot be read: %s", err)
+		}
+		if !within(root, linkTarget(path, target)) {
+			return fmt.Sprintf("is a symlink to %s, outside the chart", target)
+		}
+	case mode&(os.ModeDevice|os.ModeCharDevice|os.ModeNamedPipe|os.ModeSocket|os.ModeIrregular) != 0:
+		return "is not a regular file or a directory"
+	case mode&os.ModeSetuid != 0 && mode&os.ModeSetgid != 0:
+		return "is setuid and setgid"
+	case mode&os.ModeSetuid != 0:
+		return "is setuid"
+	case mode&os.ModeSetgid != 0:
+		return "is setgid"
+	}
+	return ""
+}
+
+// linkTarget returns the file that the symlink at path points to, with any
+// symlinks on the way to it resolved, as far as they exist.
+func linkTarget(path, target string) string {
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(path), target)
+	}
+	if real, err := filepath.EvalSymlinks(target); err == nil {
+		return real
+	}
+	return filepath.Clean(target)
+}
+
+// within reports whether path is root, or inside it.
+func within(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// CopyFiles copies the chart in src into the directory dst, and makes the copy
+// safe to use:
+//
+//   - Symlinks that point outside the chart are left out. Those that point
+//     inside it are copied as symlinks.
+//   - Files that are not regular files or directories, such as devices and
+//     pipes, are left out.
+//   - Permissions are normalized: directories and files that may be executed,
+//     such as the scripts of hooks and generators, are 0755, and other files
+//     0644. Setuid and setgid bits are cleared.
+//   - A chart that exceeds the limits is not copied at all.
+//
+// Every unsafe file is returned, with what was done about it. With
+// allowUnsafe, unsafe files are copied as they are: a symlink outside the
+// chart is copied as what it points to, and setuid and setgid bits are kept.
+// A chart that exceeds the limits is copied, and only files that are not
+// regular files or directories are still left out.
+func CopyFiles(src, dst string, limits Limits, allowUnsafe bool) ([]*UnsafeFile, error) {
+	unsafe, err := CheckFiles(src, limits)
+	if err != nil {
+		return nil, err
+	}
+	skip := map[string]bool{}
+	for _, u := range unsafe {
+		switch {
+		case u.Path == "" && !allowUnsafe:
+			return unsafe, u
+		case u.Path == "":
+			u.Fix = "copied anyway, with --allow-unsafe"
+		case strings.HasPrefix(u.Problem, "is not a regular file"):
+			u.Fix = "left out"
+			skip[u.Path] = true
+		case allowUnsafe:
+			u.Fix = "copied as it is, with --allow-unsafe"
+		case strings.HasPrefix(u.Problem, "is a symlink"):
+			u.Fix = "left out"
+			skip[u.Path] = true
+		default:
+			u.Fix = "cleared"
+		}
+	}
+
+	root, err := filepath.EvalSymlinks(src)
+	if err != nil {
+		return nil, err
+	}
+	err = filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if skip[filepath.ToSlash(rel)] {
+			return nil
+		}
+		target := filepath.Join(dst, rel)
+		mode := fi.Mode()
+		switch {
+		case mode.IsDir():
+			return os.MkdirAll(target, 0755)
+		case mode&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			dest := linkTarget(path, link)
+			if !within(root, dest) {
+				// Only with allowUnsafe: the link is copied as what it
+				// points to.
+				ti, err := os.Stat(dest)
+				if err != nil {
+					return err
+				}
+				if ti.IsDir() {
+					_, err := CopyFiles(dest, target, Limits{}, true)
+					return err
+				}
+				return copyFile(dest, target, normalMode(ti.Mode())|ti.Mode()&(os.ModeSetuid|os.ModeSetgid))
+			}
+			if filepath.IsAbs(link) {
+				if link, err = filepath.Rel(filepath.Dir(path), dest); err != nil {
+					return err
+				}
+			}
+			return os.Symlink(link, target)
+		case mode.IsRegular():
+			m := normalMode(mode)
+			if allowUnsafe {
+				m |= mode & (os.ModeSetuid | os.ModeSetgid)
+			}
+			return copyFile(path, target, m)
+		}
+		return nil
+	})
+	return unsafe, err
+}
+
+// normalMode returns the permissions of the copy of a file with mode: 0755 if
+// anyone may execute it, and otherwise 0644.
+func normalMode(mode os.FileMode) os.FileMode {
+	if mode&0111 != 0 {
+		return 0755
+	}
+	return 0644
+}
+
+// copyFile copies the file src to dst, with mode.
+func copyFile(src, dst string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode.Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	// The umask may have cleared bits, and OpenFile does not set setuid.
+	return os.Chmod(dst, mode)
+}
@@ -0,0 +1,111 @@
+package chart
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// unsafeChart creates a chart with a file of each kind that CheckFiles reports.
+func unsafeChart(t *testing.T) (string, string) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Symlinks and setuid bits need a Unix system")
+	}
+	dir, err := ioutil.TempDir("", "helmc-safety")
+	if err != nil {
+		t.Fatal(err)
+	}
+	outside := filepath.Join(dir, "secret")
+	ioutil.WriteFile(outside, []byte("secret\n"), 0600)
+	src := filepath.Join(dir, "chart")
+	os.MkdirAll(filepath.Join(src, "manifests"), 0777)
+	os.MkdirAll(filepath.Join(src, "tpl"), 0777)
+	ioutil.WriteFile(filepath.Join(src, "Chart.yaml"), []byte("name: chart\n"), 0666)
+	ioutil.WriteFile(filepath.Join(src, "manifests", "pod.yaml"), []byte("kind: Pod\n"), 0664)
+	ioutil.WriteFile(filepath.Join(src, "tpl", "gen.sh"), []byte("#!/bin/sh\n"), 0775)
+	ioutil.WriteFile(filepath.Join(src, "tpl", "suid"), []byte("#!/bin/sh\n"), 0755)
+	os.Chmod(filepath.Join(src, "tpl", "suid"), 0755|os.ModeSetuid)
+	os.Symlink("../secret", filepath.Join(src, "escape"))
+	os.Symlink(outside, filepath.Join(src, "manifests", "abs"))
+	os.Symlink("../Chart.yaml", filepath.Join(src, "manifests", "inside"))
+	return dir, src
+}
+
+func TestCheckFiles(t *testing.T) {
+	dir, src := unsafeChart(t)
+	defer os.RemoveAll(dir)
+
+	unsafe, err := CheckFiles(src, Limits{Files: 3, Bytes: 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make([]string, len(unsafe))
+	for i, u := range unsafe {
+		got[i] = u.Error()
+	}
+	expect := []string{
+		"escape: is a symlink to ../secret, outside the chart",
+		"manifests/abs: is a symlink to " + filepath.Join(dir, "secret") + ", outside the chart",
+		"tpl/suid: is setuid",
+		"the chart has 4 files, more than the limit of 3",
+		"the chart has 42 B of files, more than the limit of 30 B",
+	}
+	if strings.Join(got, "\n") != strings.Join(expect, "\n") {
+		t.Errorf("Expected:\n%s\ngot:\n%s", strings.Join(expect, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func TestCopyFiles(t *testing.T) {
+	dir, src := unsafeChart(t)
+	defer os.RemoveAll(dir)
+
+	dst := filepath.Join(dir, "copy")
+	if _, err := CopyFiles(src, dst, Limits{Files: 3}, false); err == nil || !strings.Contains(err.Error(), "more than the limit of 3") {
+		t.Errorf("Expected the chart to be too big, got %v", err)
+	}
+	if _, err := os.Stat(dst); !os.IsNotExist(err) {
+		t.Error("Expected nothing to be copied")
+	}
+
+	unsafe, err := CopyFiles(src, dst, DefaultLimits, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unsafe) != 3 || unsafe[0].Fix != "left out" || unsafe[2].Fix != "cleared" {
+		t.Errorf("Unexpected unsafe files: %v", unsafe)
+	}
+	for name, mode := range map[string]os.FileMode{
+		"Chart.yaml":         0644,
+		"manifests":          os.ModeDir | 0755,
+		"manifests/pod.yaml": 0644,
+		"tpl/gen.sh":         0755,
+		"tpl/suid":           0755,
+	} {
+		if fi, err := os.Stat(filepath.Join(dst, name)); err != nil || fi.Mode() != mode {
+			t.Errorf("Expected %s to be %s, got %v", name, mode, fi)
+		}
+	}
+	for _, name := range []string{"escape", "manifests/abs"} {
+		if _, err := os.Lstat(filepath.Join(dst, name)); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be left out", name)
+		}
+	}
+	if link, err := os.Readlink(filepath.Join(dst, "manifests", "inside")); err != nil || link != "../Chart.yaml" {
+		t.Errorf("Expected the symlink inside the chart to be kept, got %q, %v", link, err)
+	}
+
+	// With allowUnsafe, everything is copied as it is.
+	all := filepath.Join(dir, "all")
+	if _, err := CopyFiles(src, all, Limits{Files: 3}, true); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := ioutil.ReadFile(filepath.Join(all, "escape")); err != nil || string(b) != "secret\n" {
+		t.Errorf("Expected the target of the symlink to be copied, got %q, %v", b, err)
+	}
+	if fi, err := os.Stat(filepath.Join(all, "tpl", "suid")); err != nil || fi.Mode()&os.ModeSetuid == 0 {
+		t.Errorf("Expected the setuid bit to be kept, got %v", fi)
+	}
+}
@@ -0,0 +1,462 @@
+package chart
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// SchemaFile is the optional file of a chart that describes its values.
+const SchemaFile = "values.schema.yaml"
+
+// ValuesFile is the optional file of a chart that holds its default values.
+const ValuesFile = "values.yaml"
+
+// EnvValuesFile returns the optional file of a chart that holds its values
+// for an environment, such as values-staging.yaml, which are merged over
+// those of ValuesFile.
+func EnvValuesFile(env string) string {
+	return "values-" + env + ".yaml"
+}
+
+// The types a schema can require, as in JSON Schema.
+var schemaTypes = []string{"object", "array", "string", "integer", "number", "boolean"}
+
+// Schema describes the values of a chart, with a small subset of JSON Schema:
+//
+//	type: object
+//	additionalProperties: false
+//	required: [image]
+//	properties:
+//	  image:
+//	    type: object
+//	    properties:
+//	      tag: {type: string}
+//	      pullPolicy: {type: string, enum: [Always, IfNotPresent, Never]}
+//
+// Other JSON Schema keywords are rejected, so that a misspelled keyword does
+// not silently allow anything. Keys that are not in properties are allowed
+// unless additionalProperties is false.
+type Schema struct {
+	Type        string
+	Description string
+	Default     interface{}
+	Properties  map[string]*Schema
+	Required    []string
+	// AdditionalProperties allows keys that are not in Properties. It is true
+	// unless set.
+	AdditionalProperties bool
+	Items                *Schema
+	Enum                 []interface{}
+}
+
+// LoadSchema loads and checks a schema file.
+func LoadSchema(path string) (*Schema, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseSchema(b)
+}
+
+// ParseSchema parses and checks a schema.
+//
+// The returned error lists every problem with the schema, by path.
+func ParseSchema(data []byte) (*Schema, error) {
+	var raw interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	return schemaOf(raw, parseSchema)
+}
+
+// schemaOf builds a schema from decoded YAML with parse, and fails with
+// every problem that parse found.
+func schemaOf(raw interface{}, parse func(interface{}, string, *[]string) *Schema) (*Schema, error) {
+	var problems []string
+	s := parse(raw, "", &problems)
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("invalid schema:\n\t%s", strings.Join(problems, "\n\t"))
+	}
+	return s, nil
+}
+
+// ParseValueTypes builds a schema from the simpler spec of the ValueTypes of
+// a Chartfile, which is decoded YAML:
+//
+//	image:
+//	  repository: string
+//	  tag?: string
+//	replicas: integer
+//	ports: "[]integer"
+//	pullPolicy?: [Always, IfNotPresent, Never]
+//
+// Each key is given a type of Schema, a mapping of the keys of an object, or
+// a list of the values it may have. An array is "array", or "[]" and the type
+// of its items. A key is required unless it ends with "?". Keys that are not
+// given are not allowed, so that a misspelled key is reported.
+func ParseValueTypes(raw interface{}) (*Schema, error) {
+	return schemaOf(raw, parseValueTypes)
+}
+
+// parseValueTypes builds the schema at path from a spec of ParseValueTypes,
+// adding what is wrong with it to problems.
+func parseValueTypes(raw interface{}, path string, problems *[]string) *Schema {
+	bad := func(format string, v ...interface{}) {
+		*problems = append(*problems, schemaPath(path)+": "+fmt.Sprintf(format, v...))
+	}
+	if m, ok := stringMap(raw); ok {
+		s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			name := strings.TrimSuffix(k, "?")
+			if _, dup := s.Properties[name]; dup || name == "" {
+				bad("key %q is given more than once, or is empty", k)
+				continue
+			}
+			s.Properties[name] = parseValueTypes(m[k], joinKey(path, name), problems)
+			if name == k {
+				s.Required = append(s.Required, name)
+			}
+		}
+		return s
+	}
+	switch v := raw.(type) {
+	case []interface{}:
+		if len(v) == 0 {
+			bad("a list must give the values allowed")
+		}
+		return &Schema{Enum: v, AdditionalProperties: true}
+	case string:
+		t := strings.TrimPrefix(v, "[]")
+		if !contains(schemaTypes, t) {
+			bad("type must be one of %s, or [] and one of them, not %q", strings.Join(schemaTypes, ", "), v)
+		}
+		if t != v {
+			return &Schema{Type: "array", Items: &Schema{Type: t, AdditionalProperties: true}, AdditionalProperties: true}
+		}
+		return &Schema{Type: t, AdditionalProperties: true}
+	}
+	bad("expected a type, a mapping of keys, or a list of values, got %s", typeName(raw))
+	return &Schema{AdditionalProperties: true}
+}
+
+// Where a chart declares the schema of its values; see LoadValuesSchema.
+const (
+	InSchemaFile   = SchemaFile
+	InValuesSchema = "valuesSchema of Chart.yaml"
+	InValueTypes   = "valueTypes of Chart.yaml"
+)
+
+// LoadValuesSchema loads the schema of the values of the chart in dir, and
+// says where it is declared: in its SchemaFile, or in the ValuesSchema or
+// ValueTypes of its Chart.yaml, as one of the In constants. A chart without
+// a schema has a nil one, and where is "". A chart that declares more than
+// one is an error.
+func LoadValuesSchema(dir string) (s *Schema, where string, err error) {
+	var found []string
+	path := filepath.Join(dir, SchemaFile)
+	if _, err := os.Stat(path); err == nil {
+		found = append(found, InSchemaFile)
+		if s, err = LoadSchema(path); err != nil {
+			return nil, InSchemaFile, err
+		}
+	}
+	if cf, err := LoadChartfile(filepath.Join(dir, "Chart.yaml")); err == nil {
+		if cf.ValuesSchema != nil {
+			found = append(found, InValuesSchema)
+			if s, err = schemaOf(cf.ValuesSchema, parseSchema); err != nil {
+				return nil, InValuesSchema, err
+			}
+		}
+		if cf.ValueTypes != nil {
+			found = append(found, InValueTypes)
+			if s, err = ParseValueTypes(cf.ValueTypes); err != nil {
+				return nil, InValueTypes, err
+			}
+		}
+	}
+	switch len(found) {
+	case 0:
+		return nil, "", nil
+	case 1:
+		return s, found[0], nil
+	}
+	return nil, found[0], fmt.Errorf("the values schema is declared more than once, by the %s; keep one", strings.Join(found, " and the "))
+}
+
+// parseSchema builds the schema at path from decoded YAML, adding what is
+// wrong with it to problems.
+func parseSchema(raw interface{}, path string, problems *[]string) *Schema {
+	s := &Schema{AdditionalProperties: true}
+	m, ok := stringMap(raw)
+	if !ok {
+		*problems = append(*problems, fmt.Sprintf("%s: expected a schema, got %s", schemaPath(path), typeName(raw)))
+		return s
+	}
+	bad := func(format string, v ...interface{}) {
+		*problems = append(*problems, schemaPath(path)+": "+fmt.Sprintf(format, v...))
+	}
+
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := m[k]
+		switch k {
+		case "type":
+			t, ok := v.(string)
+			if !ok || !contains(schemaTypes, t) {
+				bad("type must be one of %s, not %v", strings.Join(schemaTypes, ", "), v)
+			}
+			s.Type = t
+		case "description":
+			s.Description = fmt.Sprint(v)
+		case "default":
+			s.Default = v
+		case "properties":
+			props, ok := stringMap(v)
+			if !ok {
+				bad("properties must map keys to schemas")
+				continue
+			}
+			s.Properties = map[string]*Schema{}
+			for name, p := range props {
+				s.Properties[name] = parseSchema(p, joinKey(path, name), problems)
+			}
+		case "required":
+			list, ok := v.([]interface{})
+			if !ok {
+				bad("required must be a list of keys")
+				continue
+			}
+			for _, r := range list {
+				name, ok := r.(string)
+				if !ok {
+					bad("required must be a list of keys, not %v", r)
+					continue
+				}
+				s.Required = append(s.Required, name)
+			}
+		case "additionalProperties":
+			b, ok := v.(bool)
+			if !ok {
+				bad("additionalProperties must be true or false")
+			}
+			s.AdditionalProperties = b || !ok
+		case "items":
+			s.Items = parseSchema(v, path+"[]", problems)
+		case "enum":
+			list, ok := v.([]interface{})
+			if !ok || len(list) == 0 {
+				bad("enum must be a list of the allowed values")
+				continue
+			}
+			s.Enum = list
+		default:
+			bad("unsupported keyword %q (use %s)", k, "type, description, default, properties, required, additionalProperties, items, or enum")
+		}
+	}
+
+	if s.Type != "object" && s.Type != "" && (s.Properties != nil || s.Required != nil) {
+		bad("properties and required need type object, not %s", s.Type)
+	}
+	if s.Type != "array" && s.Type != "" && s.Items != nil {
+		bad("items needs type array, not %s", s.Type)
+	}
+	for _, r := range s.Required {
+		if _, ok := s.Properties[r]; !ok && !s.AdditionalProperties {
+			bad("required key %q is not in properties, and no other key is allowed", r)
+		}
+	}
+	if s.Type != "" {
+		for _, e := range s.Enum {
+			if !hasType(e, s.Type) {
+				bad("enum value %v is not of type %s", e, s.Type)
+			}
+		}
+		if s.Default != nil && !hasType(s.Default, s.Type) {
+			bad("default %v is not of type %s", s.Default, s.Type)
+		}
+	}
+	return s
+}
+
+// SchemaError is a value that does not conform to a schema.
+type SchemaError struct {
+	// Path is the key of the value, such as image.tag or ports[0].name.
+	Path    string
+	Message string
+	// Missing is set if the key is required, but not set.
+	Missing bool
+}
+
+func (e *SchemaError) Error() string {
+	return schemaPath(e.Path) + ": " + e.Message
+}
+
+// Validate returns every way in which values do not conform to the schema,
+// sorted by path.
+//
+// values is decoded YAML, TOML, or JSON. No values at all are validated as an
+// empty object.
+func (s *Schema) Validate(values interface{}) []*SchemaError {
+	if values == nil {
+		values = map[string]interface{}{}
+	}
+	errs := []*SchemaError{}
+	s.validate(values, "", &errs)
+	sort.SliceStable(errs, func(i, j int) bool { return errs[i].Path < errs[j].Path })
+	return errs
+}
+
+func (s *Schema) validate(v interface{}, path string, errs *[]*SchemaError) {
+	fail := func(p, format string, a ...interface{}) {
+		*errs = append(*errs, &SchemaError{Path: p, Message: fmt.Sprintf(format, a...)})
+	}
+	if s.Type != "" && !hasType(v, s.Type) {
+		fail(path, "expected %s, got %s", s.Type, typeName(v))
+		return
+	}
+	if len(s.Enum) > 0 && !inEnum(v, s.Enum) {
+		allowed := make([]string, len(s.Enum))
+		for i, e := range s.Enum {
+			allowed[i] = fmt.Sprint(e)
+		}
+		fail(path, "%v is not one of %s", v, strings.Join(allowed, ", "))
+	}
+
+	if m, ok := stringMap(v); ok {
+		for _, r := range s.Required {
+			if _, ok := m[r]; !ok {
+				*errs = append(*errs, &SchemaError{Path: joinKey(path, r), Message: "is required, but not set", Missing: true})
+			}
+		}
+		for k, sub := range m {
+			if p, ok := s.Properties[k]; ok {
+				p.validate(sub, joinKey(path, k), errs)
+			} else if !s.AdditionalProperties {
+				fail(joinKey(path, k), "is not a known key%s", knownKeys(s.Properties))
+			}
+		}
+	}
+	if list, ok := v.([]interface{}); ok && s.Items != nil {
+		for i, item := range list {
+			s.Items.validate(item, path+"["+strconv.Itoa(i)+"]", errs)
+		}
+	}
+}
+
+// knownKeys lists the keys of properties, for an error message.
+func knownKeys(props map[string]*Schema) string {
+	if len(props) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(props))
+	for k := range props {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return " (expected one of " + strings.Join(names, ", ") + ")"
+}
+
+// stringMap returns a decoded YAML, TOML, or JSON object with string keys.
+func stringMap(v interface{}) (map[string]interface{}, bool) {
+	switch m := v.(type) {
+	case map[string]interface{}:
+		return m, true
+	case map[interface{}]interface{}:
+		res := make(map[string]interface{}, len(m))
+		for k, v := range m {
+			res[fmt.Sprint(k)] = v
+		}
+		return res, true
+	}
+	return nil, false
+}
+
+// typeName returns the schema type of a decoded value.
+func typeName(v interface{}) string {
+	if _, ok := stringMap(v); ok {
+		return "object"
+	}
+	switch x := v.(type) {
+	case nil:
+		return "null"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float32, float64:
+		if f := reflect.ValueOf(x).Float(); f == math.Trunc(f) {
+			return "integer"
+		}
+		return "number"
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return "integer"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// hasType reports whether a decoded value is of a schema type. Every integer
+// is also a number.
+func hasType(v interface{}, t string) bool {
+	n := typeName(v)
+	return n == t || (t == "number" && n == "integer")
+}
+
+// inEnum reports whether a value is one of the allowed values. Numbers are
+// compared by value, whatever their Go type.
+func inEnum(v interface{}, enum []interface{}) bool {
+	for _, e := range enum {
+		if reflect.DeepEqual(v, e) || (isNumber(v) && isNumber(e) && fmt.Sprint(v) == fmt.Sprint(e)) {
+			return true
+		}
+	}
+	return false
+}
+
+func isNumber(v interface{}) bool {
+	n := typeName(v)
+	return n == "integer" || n == "number"
+}
+
+func joinKey(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// schemaPath names the path of a value for a message.
+func schemaPath(path string) string {
+	if path == "" {
+		return "(top level)"
+	}
+	return path
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
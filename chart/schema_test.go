@@ -0,0 +1,173 @@
+package chart
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+const testSchema = `
+type: object
+additionalProperties: false
+required: [image]
+properties:
+  image:
+    type: object
+    required: [tag]
+    properties:
+      tag: {type: string}
+      pullPolicy: {type: string, enum: [Always, IfNotPresent, Never]}
+  replicas: {type: integer}
+  ports:
+    type: array
+    items: {type: integer}
+`
+
+func TestSchemaValidate(t *testing.T) {
+	s, err := ParseSchema([]byte(testSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string][]string{
+		"image: {tag: v1}\nreplicas: 3\nports: [80, 443]": nil,
+		"imagee: {tag: v1}": {
+			"image: is required, but not set",
+			"imagee: is not a known key (expected one of image, ports, replicas)",
+		},
+		"image: {tag: 1, pullPolicy: Sometimes}\nports: [80, http]": {
+			"image.pullPolicy: Sometimes is not one of Always, IfNotPresent, Never",
+			"image.tag: expected string, got integer",
+			"ports[1]: expected integer, got string",
+		},
+		"": {"image: is required, but not set"},
+	}
+	for in, expect := range tests {
+		var vals interface{}
+		if err := yaml.Unmarshal([]byte(in), &vals); err != nil {
+			t.Fatal(err)
+		}
+		got := []string{}
+		for _, e := range s.Validate(vals) {
+			got = append(got, e.Error())
+		}
+		if strings.Join(got, "\n") != strings.Join(expect, "\n") {
+			t.Errorf("Validate(%q):\n%s\nexpected:\n%s", in, strings.Join(got, "\n"), strings.Join(expect, "\n"))
+		}
+	}
+}
+
+func TestSchemaJSONNumbers(t *testing.T) {
+	s, err := ParseSchema([]byte("properties: {replicas: {type: integer, enum: [1, 3]}, ratio: {type: number}}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	vals := map[string]interface{}{"replicas": float64(3), "ratio": 0.5}
+	if errs := s.Validate(vals); len(errs) != 0 {
+		t.Errorf("Expected JSON numbers to conform, got %v", errs)
+	}
+}
+
+func TestParseSchemaInvalid(t *testing.T) {
+	_, err := ParseSchema([]byte(`
+type: map
+properties:
+  tag: {type: string, enum: [1]}
+  name: {tpye: string}
+`))
+	if err == nil {
+		t.Fatal("Expected an invalid schema")
+	}
+	for _, msg := range []string{
+		"(top level): type must be one of",
+		"tag: enum value 1 is not of type string",
+		`name: unsupported keyword "tpye"`,
+	} {
+		if !strings.Contains(err.Error(), msg) {
+			t.Errorf("Expected %q in %q", msg, err)
+		}
+	}
+}
+
+func TestParseValueTypes(t *testing.T) {
+	var raw interface{}
+	yaml.Unmarshal([]byte(`
+image:
+  repository: string
+  tag?: string
+replicas: integer
+ports: "[]integer"
+pullPolicy?: [Always, IfNotPresent, Never]
+`), &raw)
+	s, err := ParseValueTypes(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string][]string{
+		"image: {repository: redis}\nreplicas: 1\nports: [80]": nil,
+		"image: {repository: redis, tga: v1}\nreplicas: one\nports: [http]\npullPolicy: Sometimes": {
+			"image.tga: is not a known key (expected one of repository, tag)",
+			"pullPolicy: Sometimes is not one of Always, IfNotPresent, Never",
+			"ports[0]: expected integer, got string",
+			"replicas: expected integer, got string",
+		},
+		"image: {}": {
+			"image.repository: is required, but not set",
+			"ports: is required, but not set",
+			"replicas: is required, but not set",
+		},
+	}
+	for in, expect := range tests {
+		var vals interface{}
+		yaml.Unmarshal([]byte(in), &vals)
+		got := []string{}
+		for _, e := range s.Validate(vals) {
+			got = append(got, e.Error())
+		}
+		sort.Strings(got)
+		sort.Strings(expect)
+		if strings.Join(got, "\n") != strings.Join(expect, "\n") {
+			t.Errorf("Validate(%q):\n%s\nexpected:\n%s", in, strings.Join(got, "\n"), strings.Join(expect, "\n"))
+		}
+	}
+
+	yaml.Unmarshal([]byte("name: str\nports: []\nsize: 3\n"), &raw)
+	_, err = ParseValueTypes(raw)
+	if err == nil {
+		t.Fatal("Expected invalid value types")
+	}
+	for _, expect := range []string{`name: type must be one of`, `ports: a list must give the values allowed`, `size: expected a type`} {
+		if !strings.Contains(err.Error(), expect) {
+			t.Errorf("Expected %q in %s", expect, err)
+		}
+	}
+}
+
+func TestLoadValuesSchema(t *testing.T) {
+	dir, err := ioutil.TempDir("", "helmc-schema-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cf := filepath.Join(dir, "Chart.yaml")
+
+	ioutil.WriteFile(cf, []byte("name: schema\nversion: 0.1.0\n"), 0644)
+	if s, where, err := LoadValuesSchema(dir); s != nil || where != "" || err != nil {
+		t.Errorf("Expected no schema, got %v, %q, %v", s, where, err)
+	}
+
+	ioutil.WriteFile(cf, []byte("name: schema\nversion: 0.1.0\nvaluesSchema:\n  required: [image]\n"), 0644)
+	if s, where, err := LoadValuesSchema(dir); err != nil || where != InValuesSchema || len(s.Validate(nil)) != 1 {
+		t.Errorf("Expected the valuesSchema of Chart.yaml, got %q, %v", where, err)
+	}
+
+	ioutil.WriteFile(filepath.Join(dir, SchemaFile), []byte("type: object\n"), 0644)
+	if _, _, err := LoadValuesSchema(dir); err == nil || !strings.Contains(err.Error(), "declared more than once, by the values.schema.yaml and the valuesSchema of Chart.yaml") {
+		t.Errorf("Expected two schemas to be an error, got %v", err)
+	}
+}
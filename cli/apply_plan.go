@@ -0,0 +1,32 @@
+package cli
+
+import (
+	"github.com/codegangsta/cli"
+	"github.com/helm/helm-classic/action"
+	"github.com/helm/helm-classic/kubectl"
+)
+
+const applyPlanDescription = `Run a plan that 'helmc install --plan' or 'helmc uninstall --plan' wrote.
+
+The operations of the plan are run in order, exactly as they were planned:
+the same manifests are sent, with the same kubectl flags, and the same
+resources are deleted. Nothing is fetched or generated, and nothing is asked.
+
+The plan is refused if the chart in your workspace has changed since the plan
+was made, or if the kubeconfig context or cluster is another than the one the
+plan was made with. Make a new plan instead.
+
+Plans are JSON, with a 'version' field. This release of helmc writes and runs
+plans of version 1.
+`
+
+var applyPlanCmd = cli.Command{
+	Name:        "apply-plan",
+	Usage:       "Run a plan that install or uninstall wrote with --plan.",
+	Description: applyPlanDescription,
+	ArgsUsage:   "[plan-file]",
+	Action: func(c *cli.Context) {
+		minArgs(c, 1, "apply-plan")
+		die(action.ApplyPlan(c.Args()[0], home(c), kubectl.Client))
+	},
+}
@@ -0,0 +1,50 @@
+package cli
+
+import (
+	"github.com/codegangsta/cli"
+	"github.com/helm/helm-classic/action"
+)
+
+const auditDescription = `Read the audit log of the changes that helmc makes to Kubernetes.
+
+Every install and uninstall that reaches Kubernetes is appended to the log,
+whether it succeeds or fails: when it ran, the user, context, and cluster of
+the kubeconfig, the chart's name, version, and digest, the namespace, each
+resource that was changed or refused, and the outcome. Installs also record
+their options, which 'helmc reinstall' replays. Dry runs are not
+recorded, and recording cannot be turned off. If the log cannot be written, a
+warning is printed, and the operation is not failed.
+
+The log is a file of JSON lines, audit.log in the home directory, which is
+only ever appended to. Commands that share it take its lock while they write.
+To keep it elsewhere, such as on a volume that outlives a CI job, set
+audit.path:
+
+	helmc config set audit.path /mnt/audit/helmc.log
+`
+
+var auditCmd = cli.Command{
+	Name:        "audit",
+	Usage:       "Read the audit log of changes to Kubernetes.",
+	Description: auditDescription,
+	Subcommands: []cli.Command{
+		{
+			Name:  "tail",
+			Usage: "Print the latest entries of the audit log.",
+			Flags: []cli.Flag{
+				cli.IntFlag{
+					Name:  "n",
+					Value: 10,
+					Usage: "The number of entries to print. Use 0 for every entry.",
+				},
+				cli.StringFlag{
+					Name:  "output,o",
+					Usage: "Print the entries as 'json' or 'yaml' instead of a table.",
+				},
+			},
+			Action: func(c *cli.Context) {
+				action.AuditTail(home(c), c.Int("n"), outputFormat(c))
+			},
+		},
+	},
+}
@@ -0,0 +1,395 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/codegangsta/cli"
+	"github.com/helm/helm-classic/action"
+	"github.com/helm/helm-classic/log"
+	"github.com/helm/helm-classic/version"
+)
+
+const completionDescription = `Print a script that completes the commands, subcommands, and flags of
+helmc in bash, zsh, or fish, and the names of charts and repositories as
+their arguments.
+
+The script is built from the command definitions of helmc, so it knows every
+command of the version that printed it; print it again when helmc is
+upgraded. Chart names are those of the workspace and of the caches of the
+repositories, and repository names those of the configuration. They are read
+from your home each time they are completed, without touching the network.
+`
+
+const completionBashDescription = `Print the completion script for bash. Load it in the current shell with
+
+	source <(helmc completion bash)
+
+or install it for every shell, e.g. into
+/etc/bash_completion.d/helmc, or ~/.local/share/bash-completion/completions/helmc.
+`
+
+const completionZshDescription = `Print the completion script for zsh. Load it in the current shell with
+
+	source <(helmc completion zsh)
+
+or install it for every shell as a file named _helmc in a directory of
+$fpath, and run 'compinit'.
+`
+
+const completionFishDescription = `Print the completion script for fish. Load it in the current shell with
+
+	helmc completion fish | source
+
+or install it for every shell as ~/.config/fish/completions/helmc.fish.
+`
+
+// completionCmd returns the command that prints the completion scripts.
+//
+// Like the reference pages of docsCmd, the scripts complete the command tree
+// built by newApp.
+func completionCmd(newApp func() *cli.App) cli.Command {
+	shell := func(name, desc string, script func(*completionNode) []byte) cli.Command {
+		return cli.Command{
+			Name:        name,
+			Usage:       "Print the completion script for " + name + ".",
+			Description: desc,
+			Action: func(c *cli.Context) {
+				log.Stdout.Write(script(completionTree(newApp())))
+			},
+		}
+	}
+	return cli.Command{
+		Name:        "completion",
+		Usage:       "Print a script that completes helmc commands in bash, zsh, or fish.",
+		Description: completionDescription,
+		Subcommands: []cli.Command{
+			shell("bash", completionBashDescription, bashCompletion),
+			shell("zsh", completionZshDescription, zshCompletion),
+			shell("fish", completionFishDescription, fishCompletion),
+			{
+				// The scripts run this to complete arguments. It is one of
+				// the hiddenCommands.
+				Name:      "names",
+				Usage:     "Print the names that the scripts complete arguments to.",
+				ArgsUsage: "[" + action.CompleteCharts + "|" + action.CompleteRepos + "]",
+				Action: func(c *cli.Context) {
+					minArgs(c, 1, "completion names")
+					for _, n := range action.CompletionNames(home(c), c.Args()[0]) {
+						log.Msg("%s", n)
+					}
+				},
+			},
+		},
+	}
+}
+
+// completionNode is helmc, or one of its visible commands, as the completion
+// scripts complete it.
+type completionNode struct {
+	// Path is the command's path, without "helmc". It is empty for helmc itself.
+	Path []string
+	// Names are the name of the command and its aliases.
+	Names []string
+	Usage string
+	Flags []docFlag
+	// Args is the kind of names that its arguments complete to, as
+	// action.CompletionNames takes it, or "".
+	Args     string
+	Commands []*completionNode
+}
+
+// Key identifies the node in the scripts: its path, joined by spaces.
+func (n *completionNode) Key() string {
+	return strings.Join(n.Path, " ")
+}
+
+// helpFlag is the flag that the cli package gives every command.
+var helpFlag = docFlag{Names: []string{"--help", "-h"}, Usage: "Show help."}
+
+// completionTree describes the app and its visible commands.
+func completionTree(app *cli.App) *completionNode {
+	root := &completionNode{Flags: append(docFlags(app.Flags), helpFlag)}
+	root.Commands = completionNodes(root, app.Commands)
+	return root
+}
+
+// completionNodes describes the visible commands of a parent.
+func completionNodes(parent *completionNode, cmds []cli.Command) []*completionNode {
+	nodes := []*completionNode{}
+	for _, cmd := range cmds {
+		path := append(append([]string{}, parent.Path...), cmd.Name)
+		if hiddenCommand(path) {
+			continue
+		}
+		n := &completionNode{
+			Path:  path,
+			Names: append([]string{cmd.Name}, cmd.Aliases...),
+			Usage: strings.SplitN(cmd.Usage, "\n", 2)[0],
+			Flags: append(docFlags(cmd.Flags), helpFlag),
+		}
+		switch {
+		case strings.Contains(cmd.ArgsUsage, "chart"):
+			n.Args = action.CompleteCharts
+		case n.Path[0] == "repository" && strings.Contains(cmd.ArgsUsage, "name"):
+			n.Args = action.CompleteRepos
+		}
+		n.Commands = completionNodes(n, cmd.Subcommands)
+		nodes = append(nodes, n)
+	}
+	return nodes
+}
+
+// completionNodeList returns n and the nodes below it, depth first.
+func completionNodeList(n *completionNode) []*completionNode {
+	nodes := []*completionNode{n}
+	for _, c := range n.Commands {
+		nodes = append(nodes, completionNodeList(c)...)
+	}
+	return nodes
+}
+
+// completionHeader is the comment that starts a script.
+func completionHeader(shell string) string {
+	return fmt.Sprintf("# %s completion for helmc. Written by 'helmc completion %s', from the\n# commands of helmc %s; write it again when helmc is upgraded.\n", shell, shell, version.Version)
+}
+
+// flagNames returns the names of the flags, e.g. "--namespace" and "-n".
+func flagNames(flags []docFlag) []string {
+	names := []string{}
+	for _, f := range flags {
+		names = append(names, f.Names...)
+	}
+	return names
+}
+
+// shSubcommands writes the function __helmc_sub, which bash and zsh share:
+// given the key of a command and a word, it prints the name of the
+// subcommand that the word names, by its name or an alias, or nothing.
+func shSubcommands(b *bytes.Buffer, root *completionNode) {
+	b.WriteString("__helmc_sub() {\n    case \"$1/$2\" in\n")
+	for _, n := range completionNodeList(root) {
+		for _, c := range n.Commands {
+			pats := []string{}
+			for _, name := range c.Names {
+				pats = append(pats, shQuote(n.Key()+"/"+name))
+			}
+			fmt.Fprintf(b, "    %s) echo %s ;;\n", strings.Join(pats, "|"), shQuote(c.Names[0]))
+		}
+	}
+	b.WriteString("    esac\n}\n\n")
+}
+
+// shArgs writes the function __helmc_args, which prints the kind of names
+// that the arguments of a command complete to.
+func shArgs(b *bytes.Buffer, root *completionNode) {
+	kinds := map[string][]string{}
+	for _, n := range completionNodeList(root) {
+		if n.Args != "" {
+			kinds[n.Args] = append(kinds[n.Args], shQuote(n.Key()))
+		}
+	}
+	b.WriteString("__helmc_args() {\n    case \"$1\" in\n")
+	for _, kind := range []string{action.CompleteCharts, action.CompleteRepos} {
+		if len(kinds[kind]) > 0 {
+			fmt.Fprintf(b, "    %s) echo %s ;;\n", strings.Join(kinds[kind], "|"), kind)
+		}
+	}
+	b.WriteString("    esac\n}\n\n")
+}
+
+// bashCompletion renders the completion script for bash.
+func bashCompletion(root *completionNode) []byte {
+	var b bytes.Buffer
+	b.WriteString(completionHeader("bash") + "\n")
+	shSubcommands(&b, root)
+
+	b.WriteString("__helmc_words() {\n    case \"$1\" in\n")
+	for _, n := range completionNodeList(root) {
+		if len(n.Commands) == 0 {
+			continue
+		}
+		names := []string{}
+		for _, c := range n.Commands {
+			names = append(names, c.Names[0])
+		}
+		fmt.Fprintf(&b, "    %s) echo %s ;;\n", shQuote(n.Key()), shQuote(strings.Join(names, " ")))
+	}
+	b.WriteString("    esac\n}\n\n")
+
+	b.WriteString("__helmc_flags() {\n    case \"$1\" in\n")
+	for _, n := range completionNodeList(root) {
+		fmt.Fprintf(&b, "    %s) echo %s ;;\n", shQuote(n.Key()), shQuote(strings.Join(flagNames(n.Flags), " ")))
+	}
+	b.WriteString("    esac\n}\n\n")
+	shArgs(&b, root)
+
+	b.WriteString(`_helmc() {
+    local cur="${COMP_WORDS[COMP_CWORD]}" cmdpath="" sub words i
+    for ((i = 1; i < COMP_CWORD; i++)); do
+        [[ "${COMP_WORDS[i]}" == -* ]] && continue
+        sub="$(__helmc_sub "$cmdpath" "${COMP_WORDS[i]}")"
+        [[ -n "$sub" ]] && cmdpath="${cmdpath:+$cmdpath }$sub"
+    done
+    if [[ "$cur" == -* ]]; then
+        COMPREPLY=($(compgen -W "$(__helmc_flags "$cmdpath")" -- "$cur"))
+        return
+    fi
+    words="$(__helmc_words "$cmdpath")"
+    case "$(__helmc_args "$cmdpath")" in
+    charts) words="$words $("${COMP_WORDS[0]}" completion names charts 2>/dev/null)" ;;
+    repos) words="$words $("${COMP_WORDS[0]}" completion names repos 2>/dev/null)" ;;
+    esac
+    COMPREPLY=($(compgen -W "$words" -- "$cur"))
+}
+
+complete -F _helmc helmc
+`)
+	return b.Bytes()
+}
+
+// zshCompletion renders the completion script for zsh, which describes each
+// command and flag.
+func zshCompletion(root *completionNode) []byte {
+	var b bytes.Buffer
+	b.WriteString("#compdef helmc\n" + completionHeader("zsh") + "\n")
+	shSubcommands(&b, root)
+
+	b.WriteString("__helmc_cmds() {\n    case \"$1\" in\n")
+	for _, n := range completionNodeList(root) {
+		if len(n.Commands) == 0 {
+			continue
+		}
+		items := []string{}
+		for _, c := range n.Commands {
+			items = append(items, shQuote(zshEscape(c.Names[0])+":"+c.Usage))
+		}
+		fmt.Fprintf(&b, "    %s) cmds=(\n        %s\n    ) ;;\n", shQuote(n.Key()), strings.Join(items, "\n        "))
+	}
+	b.WriteString("    esac\n}\n\n")
+
+	b.WriteString("__helmc_opts() {\n    case \"$1\" in\n")
+	for _, n := range completionNodeList(root) {
+		items := []string{}
+		for _, f := range n.Flags {
+			for _, name := range f.Names {
+				items = append(items, shQuote(zshEscape(name)+":"+f.Usage))
+			}
+		}
+		fmt.Fprintf(&b, "    %s) opts=(\n        %s\n    ) ;;\n", shQuote(n.Key()), strings.Join(items, "\n        "))
+	}
+	b.WriteString("    esac\n}\n\n")
+	shArgs(&b, root)
+
+	b.WriteString(`_helmc() {
+    local cmdpath="" sub i
+    local -a cmds opts
+    for ((i = 2; i < CURRENT; i++)); do
+        [[ "${words[i]}" == -* ]] && continue
+        sub="$(__helmc_sub "$cmdpath" "${words[i]}")"
+        [[ -n "$sub" ]] && cmdpath="${cmdpath:+$cmdpath }$sub"
+    done
+    if [[ "${words[CURRENT]}" == -* ]]; then
+        __helmc_opts "$cmdpath"
+        _describe -t options 'option' opts
+        return
+    fi
+    __helmc_cmds "$cmdpath"
+    (( ${#cmds} )) && _describe -t commands 'command' cmds
+    case "$(__helmc_args "$cmdpath")" in
+    charts) compadd -- ${(f)"$(${words[1]} completion names charts 2>/dev/null)"} ;;
+    repos) compadd -- ${(f)"$(${words[1]} completion names repos 2>/dev/null)"} ;;
+    esac
+}
+
+if [[ "${funcstack[1]}" == "_helmc" ]]; then
+    _helmc "$@"
+else
+    compdef _helmc helmc
+fi
+`)
+	return b.Bytes()
+}
+
+// fishCompletion renders the completion script for fish.
+func fishCompletion(root *completionNode) []byte {
+	var b bytes.Buffer
+	b.WriteString(completionHeader("fish") + "\n")
+
+	b.WriteString("function __helmc_sub\n    switch \"$argv[1]/$argv[2]\"\n")
+	for _, n := range completionNodeList(root) {
+		for _, c := range n.Commands {
+			pats := []string{}
+			for _, name := range c.Names {
+				pats = append(pats, fishQuote(n.Key()+"/"+name))
+			}
+			fmt.Fprintf(&b, "        case %s\n            echo %s\n", strings.Join(pats, " "), fishQuote(c.Names[0]))
+		}
+	}
+	b.WriteString("    end\nend\n\n")
+
+	b.WriteString(`function __helmc_path
+    set -l cmdpath ""
+    for word in (commandline -opc)[2..-1]
+        string match -q -- '-*' $word; and continue
+        set -l sub (__helmc_sub "$cmdpath" $word)
+        if test -n "$sub"
+            if test -n "$cmdpath"
+                set cmdpath "$cmdpath $sub"
+            else
+                set cmdpath $sub
+            end
+        end
+    end
+    echo $cmdpath
+end
+
+function __helmc_at
+    set -l p (__helmc_path)
+    test "$p" = "$argv[1]"
+end
+
+complete -c helmc -f
+`)
+	for _, n := range completionNodeList(root) {
+		cond := fishQuote("__helmc_at " + shQuote(n.Key()))
+		for _, c := range n.Commands {
+			fmt.Fprintf(&b, "complete -c helmc -n %s -a %s -d %s\n", cond, fishQuote(c.Names[0]), fishQuote(c.Usage))
+		}
+		for _, f := range n.Flags {
+			opts := []string{}
+			for _, name := range f.Names {
+				if strings.HasPrefix(name, "--") {
+					opts = append(opts, "-l "+fishQuote(name[2:]))
+				} else {
+					opts = append(opts, "-s "+fishQuote(name[1:]))
+				}
+			}
+			if f.Arg != "" {
+				opts = append(opts, "-r")
+			}
+			fmt.Fprintf(&b, "complete -c helmc -n %s %s -d %s\n", cond, strings.Join(opts, " "), fishQuote(f.Usage))
+		}
+		if n.Args != "" {
+			fmt.Fprintf(&b, "complete -c helmc -n %s -a %s\n", cond, fishQuote("(helmc completion names "+n.Args+" 2>/dev/null)"))
+		}
+	}
+	return b.Bytes()
+}
+
+// shQuote quotes s for bash and zsh.
+func shQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// zshEscape escapes the colons of a name that _describe takes.
+func zshEscape(s string) string {
+	return strings.Replace(s, ":", `\:`, -1)
+}
+
+// fishQuote quotes s for fish.
+func fishQuote(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	return "'" + strings.Replace(s, "'", `\'`, -1) + "'"
+}
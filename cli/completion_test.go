@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/helm/helm-classic/test"
+)
+
+func TestCompletion(t *testing.T) {
+	tree := completionTree(Cli())
+	render := map[string]func(*completionNode) []byte{
+		"bash": bashCompletion,
+		"zsh":  zshCompletion,
+		"fish": fishCompletion,
+	}
+	scripts := map[string][]string{
+		"bash": {
+			"'/repository'|'/repo') echo 'repository' ;;",
+			"'repository/add') echo 'add' ;;",
+			"'') echo 'apply-plan audit completion ",
+			"'install') echo '--namespace -n ",
+			"completion names charts 2>/dev/null",
+			"complete -F _helmc helmc\n",
+		},
+		"zsh": {
+			"#compdef helmc\n",
+			"'install:Install a named package into Kubernetes.'",
+			"'--namespace:",
+			"completion names repos 2>/dev/null",
+			"compdef _helmc helmc\n",
+		},
+		"fish": {
+			"complete -c helmc -n '__helmc_at \\'\\'' -a 'install' -d ",
+			"complete -c helmc -n '__helmc_at \\'install\\'' -l 'namespace' -s 'n' -r",
+			"-a '(helmc completion names charts 2>/dev/null)'",
+		},
+	}
+	for shell, expects := range scripts {
+		out := string(render[shell](tree))
+		for _, expect := range expects {
+			test.ExpectContains(t, out, expect)
+		}
+		// Hidden commands are not completed.
+		if strings.Contains(out, "'docs'") || strings.Contains(out, "'names'") {
+			t.Errorf("Expected the %s script to leave out hidden commands", shell)
+		}
+	}
+}
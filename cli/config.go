@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"github.com/codegangsta/cli"
+	"github.com/helm/helm-classic/action"
+)
+
+const configDescription = `Read and change the configuration file, $HELMC_HOME/config.yaml.
+
+Keys are the names of the file, separated by dots. An entry of a list is given
+by its index, or a repository by its name:
+
+	repos.default
+	repos.tables[2].branch
+	repos.tables[charts].priority
+	kubectl.path
+	profiles.prod.namespace
+
+Values are parsed for the type of their key, so that a priority must be a
+number and 'full' must be true or false. The configuration is checked before
+it is saved, and the file is locked while it changes, as with 'helmc repo'.
+An unknown key is reported with the nearest key that exists.
+
+Profiles hold the defaults of an environment: a namespace, a kubeconfig
+context, and a kubeconfig file. The global '--profile prod' flag, or
+$HELMC_PROFILE, makes those of the prod profile the defaults of install,
+uninstall, status, and the other commands that work with Kubernetes. Flags
+that are given still take precedence. Setting a key of a profile creates it:
+
+	helmc config set profiles.prod.namespace prod
+	helmc config set profiles.prod.kubeContext prod-cluster
+	helmc --profile prod install redis
+
+A profile is also an environment of the charts' values: when it is selected,
+a chart's values-prod.yaml, if it has one, is merged over its values.yaml for
+its templates. install, upgrade, render, and generate also select a profile
+with '--env', after the command:
+
+	helmc install --generate --env prod redis
+`
+
+var configCmd = cli.Command{
+	Name:        "config",
+	Usage:       "Read and change the configuration.",
+	Description: configDescription,
+	Subcommands: []cli.Command{
+		{
+			Name:      "get",
+			Usage:     "Print the value of a key.",
+			ArgsUsage: "[key]",
+			Action: func(c *cli.Context) {
+				minArgs(c, 1, "config get")
+				action.ConfigGet(home(c), c.Args()[0])
+			},
+		},
+		{
+			Name:      "set",
+			Usage:     "Set the value of a key.",
+			ArgsUsage: "[key] [value]",
+			Action: func(c *cli.Context) {
+				minArgs(c, 2, "config set")
+				action.ConfigSet(home(c), c.Args()[0], c.Args()[1])
+			},
+		},
+		{
+			Name:      "unset",
+			Usage:     "Return a key to its default.",
+			ArgsUsage: "[key]",
+			Action: func(c *cli.Context) {
+				minArgs(c, 1, "config unset")
+				action.ConfigUnset(home(c), c.Args()[0])
+			},
+		},
+		{
+			Name:  "profiles",
+			Usage: "List the profiles, marking the one that --profile selects.",
+			Action: func(c *cli.Context) {
+				action.ConfigProfiles(home(c), c.GlobalString("profile"))
+			},
+		},
+		{
+			Name:  "view",
+			Usage: "Print the configuration, as changed by flags and the environment, without secrets.",
+			Action: func(c *cli.Context) {
+				action.ConfigView(home(c))
+			},
+		},
+	},
+}
@@ -1,21 +1,120 @@
 package cli
 
 import (
+	"fmt"
+
 	"github.com/codegangsta/cli"
 	"github.com/helm/helm-classic/action"
 )
 
 const createDescription = `This will scaffold a new chart named 'chart-name' in your
 local workdir. To edit the resulting chart, you may edit the files directly or
-use the 'helmc edit' command.`
+use the 'helmc edit' command.
+
+The chart is copied from a starter: 'default', with a single example Pod,
+unless another is named with '--starter'. Besides the built-in starters,
+every directory in the 'starters' directory of your Helm Classic home is a
+starter. '<CHARTNAME>' in the paths and contents of a starter's files is
+replaced by the name of the new chart, and the name in its Chart.yaml is
+set. Files with 'helm:generate' directives are copied intact; generators
+are not run until 'helmc generate'.
+
+Use '--list-starters' to list the starters that are available.
+
+'--from' generates the chart from a scaffold instead: 'deployment', with a
+Deployment behind a Service; 'daemonset'; 'cronjob'; or 'empty', with no
+manifests. A scaffold has a values.yaml, a README.md, and templates in tpl/
+whose 'helm:generate' headers write the manifests, and the chart is generated
+once it is created. Change values.yaml and run 'helmc generate --force' to
+regenerate them. A directory in the 'scaffolds' directory of your Helm
+Classic home with the name of a built-in scaffold replaces its files or adds
+to them, and one with another name is a scaffold of its own. Each file of a
+scaffold is a Go template with the delimiters '[[' and ']]', so that it can
+hold the templates of 'helmc tpl'; '[[.Name]]' is the name of the chart.
+
+The fields of the new Chart.yaml are those of an example, unless they are
+given with '--description', '--chart-version', '--maintainer', '--email', and
+'--keywords'. '--no-examples' leaves out the example manifests of the starter.
+
+'--interactive' asks for each of these, with a default that an empty answer
+accepts, and checks each answer. The name of the chart and the flags that are
+given are the defaults. Once the chart is created, the equivalent command
+without prompts is printed, so that scripts can create the same chart, and
+the chart is linted. '--interactive' only runs in a terminal.`
 
 var createCmd = cli.Command{
 	Name:        "create",
 	Usage:       "Create a chart in the local workspace.",
 	Description: createDescription,
 	ArgsUsage:   "[chart-name]",
-	Action: func(c *cli.Context) {
-		minArgs(c, 1, "create")
-		action.Create(c.Args()[0], home(c))
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "starter",
+			Value: action.DefaultStarter,
+			Usage: "The starter to copy the chart from.",
+		},
+		cli.StringFlag{
+			Name:  "from",
+			Usage: "The scaffold to generate the chart from: deployment, daemonset, cronjob, or empty.",
+		},
+		cli.BoolFlag{
+			Name:  "list-starters",
+			Usage: "List the available starters.",
+		},
+		cli.StringFlag{
+			Name:  "description",
+			Usage: "The description of the chart.",
+		},
+		cli.StringFlag{
+			Name:  "chart-version",
+			Usage: "The SemVer version of the chart. The default is 0.1.0.",
+		},
+		cli.StringFlag{
+			Name:  "maintainer",
+			Usage: "The name of the maintainer of the chart.",
+		},
+		cli.StringFlag{
+			Name:  "email",
+			Usage: "The email address of the maintainer of the chart.",
+		},
+		cli.StringFlag{
+			Name:  "keywords",
+			Usage: "The keywords of the chart, separated by commas.",
+		},
+		cli.BoolFlag{
+			Name:  "no-examples",
+			Usage: "Leave out the example manifests of the starter.",
+		},
+		cli.BoolFlag{
+			Name:  "interactive, i",
+			Usage: "Ask for the name and fields of the chart.",
+		},
 	},
+	Action: create,
+}
+
+func create(c *cli.Context) {
+	if c.Bool("list-starters") {
+		action.ListStarters(home(c))
+		return
+	}
+	o := action.CreateOptions{
+		Starter:     c.String("starter"),
+		Description: c.String("description"),
+		Version:     c.String("chart-version"),
+		Maintainer:  c.String("maintainer"),
+		Email:       c.String("email"),
+		Keywords:    action.SplitKeywords(c.String("keywords")),
+		NoExamples:  c.Bool("no-examples"),
+		Scaffold:    c.String("from"),
+	}
+	if o.Scaffold != "" && c.IsSet("starter") {
+		die(fmt.Errorf("--from generates the chart from a scaffold, so it cannot be given with --starter"))
+	}
+	if c.Bool("interactive") {
+		die(action.CreateInteractive(c.Args().First(), home(c), o))
+		return
+	}
+	minArgs(c, 1, "create")
+	action.CreateChart(c.Args()[0], home(c), o)
 }
@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"github.com/codegangsta/cli"
+	"github.com/helm/helm-classic/action"
+)
+
+const depsDescription = `Show the dependencies of a chart in your workspace, or of a repository.
+
+Each dependency is resolved against the other charts in the workspace, and so
+are the dependencies of the charts that satisfy it. A dependency that the
+workspace does not have is resolved against the caches of the repositories,
+as 'helmc fetch --deps' would, and is marked 'to fetch'; nothing is fetched.
+So 'helmc deps charts/redis' shows what fetching redis with its dependencies
+pulls in. The charts of an HTTP repository do not list their dependencies
+until they are fetched.
+
+The tree lists each chart as name@version, with the repository it was or
+would be fetched from and the constraint it was required with. Dependencies
+that no chart satisfies are marked missing, and dependencies that lead back to
+a chart that depends on them are marked as cycles.
+
+With '--graph dot', the tree is printed as a Graphviz graph, e.g. for
+'helmc deps mychart --graph dot | dot -Tsvg > deps.svg'. With '--graph json',
+the nodes and edges are printed as JSON. Nodes and edges are sorted, so the
+output only changes when the dependencies do.`
+
+var depsCmd = cli.Command{
+	Name:        "deps",
+	Usage:       "Show the dependency tree of a chart, and what fetching it pulls in.",
+	Description: depsDescription,
+	ArgsUsage:   "[chart-name]",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "graph",
+			Usage: "Print the tree as a graph, in 'dot' or 'json'.",
+		},
+	},
+	Action: func(c *cli.Context) {
+		minArgs(c, 1, "deps")
+		action.Deps(chartName(c, c.Args()[0], depsChart), home(c), c.String("graph"))
+	},
+}
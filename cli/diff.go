@@ -0,0 +1,40 @@
+package cli
+
+import (
+	"github.com/codegangsta/cli"
+	"github.com/helm/helm-classic/action"
+	"github.com/helm/helm-classic/kubectl"
+)
+
+const diffDescription = `Compare the resources that Kubernetes runs with the manifests of a chart in
+your workspace.
+
+Each resource is read with 'kubectl get -o json', and a unified diff from it
+to its manifest is printed, as YAML. Before they are compared, the status of
+the resource, the metadata that Kubernetes keeps, such as its uid and
+resourceVersion, and every field that the manifest does not set, such as the
+defaults of Kubernetes and the annotations of install, are left out. So the
+diff shows what installing the chart again would change. A field that was
+removed from a manifest after it was installed is not shown.
+
+Resources are 'unchanged', 'changed', or 'not-installed' if Kubernetes does
+not have them. Resources that the manifests do not name their namespace of
+are looked for in the namespace of '--namespace'.`
+
+var diffCmd = cli.Command{
+	Name:        "diff",
+	Usage:       "Show how the resources in Kubernetes differ from the manifests of a chart.",
+	Description: diffDescription,
+	ArgsUsage:   "[chart-name]",
+	Action: func(c *cli.Context) {
+		minArgs(c, 1, "diff")
+		die(action.Diff(chartName(c, c.Args()[0], workspaceChart), home(c), namespace(c), kubectl.Client))
+	},
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "namespace, n",
+			Value: "",
+			Usage: "The Kubernetes namespace to look in.",
+		},
+	},
+}
@@ -0,0 +1,29 @@
+package cli
+
+import (
+	"github.com/codegangsta/cli"
+	"github.com/helm/helm-classic/action"
+)
+
+const diffLocalDescription = `Compare a chart in your workspace with the cached chart it was fetched from.
+
+The files that were added, removed, or modified in the workspace are listed.
+Files that the chart's .helmignore excludes are not compared. If the cached
+chart has been updated since it was fetched, its changes are listed too.`
+
+var diffLocalCmd = cli.Command{
+	Name:        "diff-local",
+	Usage:       "List the local modifications of a chart in your workspace.",
+	Description: diffLocalDescription,
+	ArgsUsage:   "[chart-name]",
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "unified, u",
+			Usage: "Print a unified diff of each file.",
+		},
+	},
+	Action: func(c *cli.Context) {
+		minArgs(c, 1, "diff-local")
+		action.DiffLocal(chartName(c, c.Args()[0], workspaceChart), home(c), c.Bool("unified"))
+	},
+}
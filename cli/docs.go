@@ -0,0 +1,411 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/codegangsta/cli"
+	"github.com/helm/helm-classic/log"
+	"github.com/helm/helm-classic/version"
+)
+
+const docsDescription = `Writes a reference page for helmc and for each of its commands, built from
+the command definitions: the synopsis, the description, every flag with its
+default, and examples.
+
+With '--format man', the pages are man pages in section 1, e.g.
+'helmc-install.1'. With '--format markdown', they are Markdown files, e.g.
+'helmc-install.md', that link to each other.
+
+The pages only change when the commands do, so they can be committed and
+diffed. Each page names the version of helmc that wrote it.
+`
+
+// docsCmd returns the hidden command that writes the reference pages.
+//
+// The pages document the command tree built by newApp, rather than the one
+// that is running, so that they show the defaults of the flags instead of
+// values set by this invocation.
+func docsCmd(newApp func() *cli.App) cli.Command {
+	return cli.Command{
+		Name:        "docs",
+		Usage:       "Write reference pages for every command.",
+		Description: docsDescription,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "format",
+				Value: "markdown",
+				Usage: "The format of the pages: 'man' or 'markdown'.",
+			},
+			cli.StringFlag{
+				Name:  "dir",
+				Value: ".",
+				Usage: "The directory to write the pages to. It is created if necessary.",
+			},
+		},
+		Action: func(c *cli.Context) {
+			dir := c.String("dir")
+			n, err := writeDocs(newApp(), c.String("format"), dir)
+			if err != nil {
+				log.Die("Could not write the reference pages: %s", err)
+			}
+			log.Info("Wrote %d pages to %s", n, dir)
+		},
+	}
+}
+
+// hiddenCommands are the commands, by their path without "helmc", that the
+// reference pages and the completion scripts leave out. The cli package that
+// helmc is built with has no Command.Hidden, so they are listed here.
+var hiddenCommands = map[string]bool{
+	"docs":             true,
+	"completion names": true,
+}
+
+// hiddenCommand reports whether the command at path is left out of the
+// reference pages and the completion scripts.
+func hiddenCommand(path []string) bool {
+	return hiddenCommands[strings.Join(path, " ")]
+}
+
+// docPage describes helmc, or one of its commands.
+type docPage struct {
+	// Path is the command's path, without "helmc". It is empty for helmc itself.
+	Path        []string
+	Usage       string
+	Synopsis    string
+	Description string
+	Aliases     []string
+	Flags       []docFlag
+	Examples    []example
+	// Commands are the pages of the subcommands.
+	Commands []*docPage
+	// Parent is nil for helmc itself.
+	Parent *docPage
+}
+
+// Name is the full command, e.g. "helmc repository add".
+func (p *docPage) Name() string {
+	return strings.Join(append([]string{"helmc"}, p.Path...), " ")
+}
+
+// File is the base name of the page, e.g. "helmc-repository-add".
+func (p *docPage) File() string {
+	return strings.Join(append([]string{"helmc"}, p.Path...), "-")
+}
+
+// docFlag describes a flag.
+type docFlag struct {
+	// Names are the flag and its aliases, e.g. "--namespace" and "-n".
+	Names []string
+	// Arg is the placeholder for the flag's value. It is empty for boolean flags.
+	Arg     string
+	Default string
+	Usage   string
+	EnvVar  string
+}
+
+// writeDocs writes a page for the app and each of its visible commands to
+// dir, and returns the number of pages.
+func writeDocs(app *cli.App, format, dir string) (int, error) {
+	var render func(*docPage) []byte
+	var ext string
+	switch format {
+	case "man":
+		render, ext = manPage, ".1"
+	case "markdown", "md":
+		render, ext = markdownPage, ".md"
+	default:
+		return 0, fmt.Errorf("unknown format %q. Use 'man' or 'markdown'", format)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return 0, err
+	}
+
+	pages := docPages(appPage(app))
+	for _, p := range pages {
+		if err := ioutil.WriteFile(filepath.Join(dir, p.File()+ext), render(p), 0644); err != nil {
+			return 0, err
+		}
+	}
+	return len(pages), nil
+}
+
+// appPage describes the app and, through Commands, the command tree.
+func appPage(app *cli.App) *docPage {
+	usage, desc := app.Usage, ""
+	if i := strings.Index(usage, "\n"); i >= 0 {
+		usage, desc = usage[:i], usage[i+1:]
+	}
+	p := &docPage{
+		Usage:       usage,
+		Synopsis:    "helmc [global options] command [command options] [arguments...]",
+		Description: desc,
+		Flags:       docFlags(app.Flags),
+		Examples:    examples[""],
+	}
+	p.Commands = commandPages(p, app.Commands)
+	return p
+}
+
+// commandPages describes the visible commands of a parent, in the order they are defined.
+func commandPages(parent *docPage, cmds []cli.Command) []*docPage {
+	pages := []*docPage{}
+	for _, cmd := range cmds {
+		path := append(append([]string{}, parent.Path...), cmd.Name)
+		if hiddenCommand(path) {
+			continue
+		}
+		p := &docPage{
+			Path:        path,
+			Usage:       cmd.Usage,
+			Description: cmd.Description,
+			Aliases:     cmd.Aliases,
+			Flags:       docFlags(cmd.Flags),
+			Examples:    examples[strings.Join(path, " ")],
+			Parent:      parent,
+		}
+		p.Synopsis = p.Name()
+		if len(cmd.Subcommands) > 0 {
+			p.Synopsis += " command"
+		}
+		if len(p.Flags) > 0 {
+			p.Synopsis += " [options]"
+		}
+		if cmd.ArgsUsage != "" {
+			p.Synopsis += " " + cmd.ArgsUsage
+		}
+		p.Commands = commandPages(p, cmd.Subcommands)
+		pages = append(pages, p)
+	}
+	return pages
+}
+
+// docPages returns p and the pages below it, depth first.
+func docPages(p *docPage) []*docPage {
+	pages := []*docPage{p}
+	for _, c := range p.Commands {
+		pages = append(pages, docPages(c)...)
+	}
+	return pages
+}
+
+// docFlags describes the flags, in the order they are defined.
+func docFlags(flags []cli.Flag) []docFlag {
+	res := []docFlag{}
+	for _, f := range flags {
+		var d docFlag
+		switch f := f.(type) {
+		case cli.BoolFlag:
+			d = docFlag{Usage: f.Usage, EnvVar: f.EnvVar}
+		case cli.BoolTFlag:
+			d = docFlag{Default: "true", Usage: f.Usage, EnvVar: f.EnvVar}
+		case cli.StringFlag:
+			d = docFlag{Arg: "value", Default: f.Value, Usage: f.Usage, EnvVar: f.EnvVar}
+		case cli.IntFlag:
+			d = docFlag{Arg: "n", Default: strconv.Itoa(f.Value), Usage: f.Usage, EnvVar: f.EnvVar}
+		case cli.DurationFlag:
+			d = docFlag{Arg: "duration", Default: f.Value.String(), Usage: f.Usage, EnvVar: f.EnvVar}
+		case cli.StringSliceFlag:
+			d = docFlag{Arg: "value", Usage: f.Usage + " May be given more than once.", EnvVar: f.EnvVar}
+			if f.Value != nil {
+				d.Default = strings.Join(f.Value.Value(), ",")
+			}
+		case cli.GenericFlag:
+			d = docFlag{Arg: "value", Usage: f.Usage, EnvVar: f.EnvVar}
+			if f.Value != nil {
+				d.Default = f.Value.String()
+			}
+		default:
+			d = docFlag{Usage: f.String()}
+		}
+		for _, name := range strings.Split(f.GetName(), ",") {
+			name = strings.TrimSpace(name)
+			if len(name) == 1 {
+				d.Names = append(d.Names, "-"+name)
+			} else {
+				d.Names = append(d.Names, "--"+name)
+			}
+		}
+		res = append(res, d)
+	}
+	return res
+}
+
+// footer names the version of helmc that wrote a page.
+func footer() string {
+	return "helmc " + version.Version
+}
+
+// markdownPage renders a page as Markdown.
+func markdownPage(p *docPage) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "# %s\n\n%s\n\n", p.Name(), p.Usage)
+	fmt.Fprintf(&b, "## Synopsis\n\n```\n%s\n```\n\n", p.Synopsis)
+	if d := strings.TrimSpace(p.Description); d != "" {
+		fmt.Fprintf(&b, "%s\n\n", d)
+	}
+	if len(p.Aliases) > 0 {
+		fmt.Fprintf(&b, "## Aliases\n\n`%s`\n\n", strings.Join(p.Aliases, "`, `"))
+	}
+	if len(p.Commands) > 0 {
+		b.WriteString("## Commands\n\n")
+		for _, c := range p.Commands {
+			fmt.Fprintf(&b, "- [%s](%s.md): %s\n", c.Name(), c.File(), c.Usage)
+		}
+		b.WriteString("\n")
+	}
+	if len(p.Flags) > 0 {
+		if p.Parent == nil {
+			b.WriteString("## Global Options\n\n")
+		} else {
+			b.WriteString("## Options\n\n")
+		}
+		for _, f := range p.Flags {
+			names := strings.Join(f.Names, ", ")
+			if f.Arg != "" {
+				names += " " + f.Arg
+			}
+			fmt.Fprintf(&b, "- `%s`: %s", names, f.Usage)
+			if f.Default != "" {
+				fmt.Fprintf(&b, " Default: `%s`.", f.Default)
+			}
+			if f.EnvVar != "" {
+				fmt.Fprintf(&b, " Environment: `$%s`.", f.EnvVar)
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString("\n")
+	}
+	if len(p.Examples) > 0 {
+		b.WriteString("## Examples\n\n")
+		for _, e := range p.Examples {
+			fmt.Fprintf(&b, "%s:\n\n```\n%s\n```\n\n", e.Description, e.Command)
+		}
+	}
+	if p.Parent != nil {
+		b.WriteString("## See Also\n\n")
+		for q := p.Parent; q != nil; q = q.Parent {
+			fmt.Fprintf(&b, "- [%s](%s.md): %s\n", q.Name(), q.File(), q.Usage)
+		}
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "---\n\n*%s*\n", footer())
+	return b.Bytes()
+}
+
+// manPage renders a page as a man page in section 1.
+//
+// The version of helmc is the source, which man prints in the footer. The
+// date is left out, so that the page only changes when the command does.
+func manPage(p *docPage) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, ".TH \"%s\" \"1\" \"\" \"%s\" \"Helm Classic Manual\"\n", strings.ToUpper(manEscape(p.File())), manEscape(footer()))
+	fmt.Fprintf(&b, ".SH NAME\n%s \\- %s\n", manEscape(p.File()), manEscape(p.Usage))
+	fmt.Fprintf(&b, ".SH SYNOPSIS\n\\fB%s\\fP%s\n", manEscape(p.Name()), manEscape(strings.TrimPrefix(p.Synopsis, p.Name())))
+	if d := strings.TrimSpace(p.Description); d != "" {
+		fmt.Fprintf(&b, ".SH DESCRIPTION\n%s", manText(d))
+	}
+	if len(p.Aliases) > 0 {
+		fmt.Fprintf(&b, ".SH ALIASES\n%s\n", manEscape(strings.Join(p.Aliases, ", ")))
+	}
+	if len(p.Commands) > 0 {
+		b.WriteString(".SH COMMANDS\n")
+		for _, c := range p.Commands {
+			fmt.Fprintf(&b, ".TP\n\\fB%s\\fP\n%s\n", manEscape(c.Path[len(c.Path)-1]), manEscape(c.Usage))
+		}
+	}
+	if len(p.Flags) > 0 {
+		if p.Parent == nil {
+			b.WriteString(".SH GLOBAL OPTIONS\n")
+		} else {
+			b.WriteString(".SH OPTIONS\n")
+		}
+		for _, f := range p.Flags {
+			names := []string{}
+			for _, n := range f.Names {
+				names = append(names, "\\fB"+manEscape(n)+"\\fP")
+			}
+			arg := ""
+			if f.Arg != "" {
+				arg = " \\fI" + f.Arg + "\\fP"
+			}
+			fmt.Fprintf(&b, ".TP\n%s%s\n%s", strings.Join(names, ", "), arg, manEscape(f.Usage))
+			if f.Default != "" {
+				fmt.Fprintf(&b, " Default: %s.", manEscape(f.Default))
+			}
+			if f.EnvVar != "" {
+				fmt.Fprintf(&b, " Environment: $%s.", manEscape(f.EnvVar))
+			}
+			b.WriteString("\n")
+		}
+	}
+	if len(p.Examples) > 0 {
+		b.WriteString(".SH EXAMPLES\n")
+		for _, e := range p.Examples {
+			fmt.Fprintf(&b, ".PP\n%s:\n.PP\n.RS\n.nf\n", manEscape(e.Description))
+			for _, line := range strings.Split(e.Command, "\n") {
+				fmt.Fprintf(&b, "%s\n", manEscape(line))
+			}
+			b.WriteString(".fi\n.RE\n")
+		}
+	}
+	if p.Parent != nil {
+		refs := []string{}
+		for q := p.Parent; q != nil; q = q.Parent {
+			refs = append(refs, "\\fB"+manEscape(q.File())+"\\fP(1)")
+		}
+		fmt.Fprintf(&b, ".SH SEE ALSO\n%s\n", strings.Join(refs, ", "))
+	}
+	return b.Bytes()
+}
+
+// manText renders the paragraphs of a description.
+//
+// Indented lines that follow a blank line, such as example commands, are
+// printed as they are written. Other lines are filled.
+func manText(s string) string {
+	var b bytes.Buffer
+	literal, blank := false, false
+	for _, line := range strings.Split(s, "\n") {
+		indented := strings.HasPrefix(line, "\t") || strings.HasPrefix(line, "  ")
+		switch {
+		case strings.TrimSpace(line) == "":
+			if literal {
+				b.WriteString(".fi\n.RE\n")
+				literal = false
+			}
+			if !blank {
+				b.WriteString(".PP\n")
+			}
+			blank = true
+			continue
+		case indented && (blank || literal):
+			if !literal {
+				b.WriteString(".RS\n.nf\n")
+				literal = true
+			}
+		}
+		b.WriteString(manEscape(strings.TrimSpace(line)) + "\n")
+		blank = false
+	}
+	if literal {
+		b.WriteString(".fi\n.RE\n")
+	}
+	return b.String()
+}
+
+// manEscape escapes the characters that roff would interpret.
+func manEscape(s string) string {
+	s = strings.Replace(s, `\`, `\e`, -1)
+	s = strings.Replace(s, "-", `\-`, -1)
+	if strings.HasPrefix(s, ".") || strings.HasPrefix(s, "'") {
+		s = `\&` + s
+	}
+	return s
+}
@@ -0,0 +1,90 @@
+package cli
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/helm/helm-classic/test"
+	"github.com/helm/helm-classic/version"
+)
+
+func TestDocs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "helmc-docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, format := range []string{"man", "markdown"} {
+		test.CaptureOutput(func() {
+			Cli().Run([]string{"helmc", "docs", "--format", format, "--dir", filepath.Join(dir, format)})
+		})
+	}
+
+	man, err := ioutil.ReadFile(filepath.Join(dir, "man", "helmc-install.1"))
+	if err != nil {
+		t.Fatalf("Expected a man page for install: %s", err)
+	}
+	for _, expect := range []string{
+		`.TH "HELMC\-INSTALL" "1" "" "helmc ` + strings.Replace(version.Version, "-", `\-`, -1) + `"`,
+		".SH SYNOPSIS\n\\fBhelmc install\\fP [options] [chart\\-name...]\n",
+		"\\fB\\-\\-mode\\fP \\fIvalue\\fP\n",
+		"Default: create.",
+		".SH EXAMPLES\n",
+		"helmc install \\-\\-dry\\-run=server redis\n",
+		"\\fBhelmc\\fP(1)",
+	} {
+		test.ExpectContains(t, string(man), expect)
+	}
+
+	md, err := ioutil.ReadFile(filepath.Join(dir, "markdown", "helmc-repository-add.md"))
+	if err != nil {
+		t.Fatalf("Expected a Markdown page for repository add: %s", err)
+	}
+	for _, expect := range []string{
+		"# helmc repository add\n",
+		"helmc repository add [options] [name] [url]\n",
+		"- `--priority n`: Priority for resolving unqualified chart names. Higher wins. Default: `0`.\n",
+		"- [helmc repository](helmc-repository.md)",
+		"*helmc " + version.Version + "*\n",
+	} {
+		test.ExpectContains(t, string(md), expect)
+	}
+
+	top, _ := ioutil.ReadFile(filepath.Join(dir, "markdown", "helmc.md"))
+	test.ExpectContains(t, string(top), "- `--client value`: How to talk to Kubernetes")
+	test.ExpectContains(t, string(top), "Environment: `$HELMC_CLIENT`.")
+	if strings.Contains(string(top), "helmc-docs.md") {
+		t.Errorf("Expected the hidden docs command to be left out")
+	}
+
+	// The pages are the same every time.
+	again := filepath.Join(dir, "again")
+	if _, err := writeDocs(Cli(), "man", again); err != nil {
+		t.Fatal(err)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "man", "*"))
+	for _, f := range files {
+		a, _ := ioutil.ReadFile(f)
+		b, _ := ioutil.ReadFile(filepath.Join(again, filepath.Base(f)))
+		if string(a) != string(b) {
+			t.Errorf("Expected %s to be the same when written again", filepath.Base(f))
+		}
+	}
+}
+
+func TestExamples(t *testing.T) {
+	for _, p := range docPages(appPage(Cli())) {
+		key := strings.Join(p.Path, " ")
+		if len(examples[key]) == 0 {
+			t.Errorf("Expected examples for %q", p.Name())
+		}
+		delete(examples, key)
+	}
+	for key := range examples {
+		t.Errorf("Examples for %q, which is not a command", key)
+	}
+}
@@ -3,10 +3,27 @@ package cli
 import (
 	"github.com/codegangsta/cli"
 	"github.com/helm/helm-classic/action"
+	"github.com/helm/helm-classic/kubectl"
 )
 
 const doctorDescription = `This will run a series of checks to ensure that your
-experience with helmc is trouble-free.`
+experience with helmc is trouble-free, and say how to fix each problem that
+it finds:
+
+- home: the home, and wherever its configuration file, cache, and workspace
+  are, can be written, and the configuration file is valid.
+- tools: git is on $PATH, unless the native Git backend is used.
+- kubectl: kubectl is found, and is within one minor version of the cluster.
+- cluster: the kubeconfig can be read, and Kubernetes can be reached with the
+  current context.
+- repositories: each repository can be reached, and is in the cache. They are
+  not checked with --offline.
+- generators: the programs that the 'helm:generate' headers of the charts of
+  the workspace run are found, on $PATH or in their chart.
+
+The findings are printed as a table, or as JSON with '--output json'. The
+command fails if one is an error.
+`
 
 var doctorCmd = cli.Command{
 	Name:        "doctor",
@@ -14,6 +31,12 @@ var doctorCmd = cli.Command{
 	Description: doctorDescription,
 	ArgsUsage:   "",
 	Action: func(c *cli.Context) {
-		action.Doctor(home(c))
+		die(action.Doctor(home(c), c.String("output"), kubectl.Client))
+	},
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "output,o",
+			Usage: "Format of the findings. Use 'json' for machine-readable output.",
+		},
 	},
 }
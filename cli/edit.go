@@ -3,19 +3,58 @@ package cli
 import (
 	"github.com/codegangsta/cli"
 	"github.com/helm/helm-classic/action"
+	"github.com/helm/helm-classic/kubectl"
+	"github.com/helm/helm-classic/log"
 )
 
 const editDescription = `Existing charts in the workspace can be edited using this command.
 'helmc edit' will open all of the chart files in a single editor (as specified
-by the $EDITOR environment variable).`
+by the $EDITOR environment variable).
+
+When the editor exits, 'helmc edit' lists the files that you changed, and, if
+the chart was fetched from a repository, how it now differs from the cached
+chart, as 'helmc diff-local' does. The generators that read a changed file,
+as 'helmc generate --watch' finds them, are run again; --no-generate skips
+them.
+
+If the chart is installed, you are then asked whether to upgrade it with the
+changes, as 'helmc upgrade' does. --yes upgrades it without asking, and
+--no-apply never does. Without a terminal, it is only upgraded with --yes.
+`
 
 var editCmd = cli.Command{
 	Name:        "edit",
 	Usage:       "Edit a named chart in the local workspace.",
 	Description: editDescription,
 	ArgsUsage:   "[chart-name]",
+	Flags: []cli.Flag{
+		cli.StringSliceFlag{
+			Name:  "exclude,x",
+			Usage: "Files or directories whose generators are not run again, relative to the chart.",
+		},
+		cli.BoolFlag{
+			Name:  "no-generate",
+			Usage: "Do not run the generators that read the changed files.",
+		},
+		cli.BoolFlag{
+			Name:  "yes,y",
+			Usage: "Upgrade the installed chart with the changes without asking.",
+		},
+		cli.BoolFlag{
+			Name:  "no-apply",
+			Usage: "Do not offer to upgrade the installed chart with the changes.",
+		},
+	},
 	Action: func(c *cli.Context) {
 		minArgs(c, 1, "edit")
-		action.Edit(c.Args()[0], home(c))
+		if c.Bool("yes") && c.Bool("no-apply") {
+			log.Die("Give either --yes or --no-apply, not both.")
+		}
+		action.Edit(chartName(c, c.Args()[0], workspaceChart), home(c), action.EditOptions{
+			Exclude:    c.StringSlice("exclude"),
+			NoGenerate: c.Bool("no-generate"),
+			Yes:        c.Bool("yes"),
+			NoApply:    c.Bool("no-apply"),
+		}, kubectl.Client)
 	},
 }
@@ -0,0 +1,215 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	helmerrors "github.com/helm/helm-classic/errors"
+	"github.com/helm/helm-classic/log"
+	helm "github.com/helm/helm-classic/util"
+)
+
+// Exit statuses, by the kind of failure. Any other failure exits with 1.
+// Status 2 is used by 'helmc self-update --check'.
+const (
+	exitChartNotFound = 3
+	exitAmbiguous     = 4
+	exitRepo          = 5
+	exitKube          = 6
+	exitLint          = 7
+	exitPreflight     = 8
+	exitNotReady      = 9
+	exitTestFailed    = 10
+)
+
+// Values of --error-format.
+const (
+	errorFormatText = "text"
+	errorFormatJSON = "json"
+)
+
+// exit is log.Exit, which waits for an interrupted command to clean up.
+// Tests replace it.
+var exit = log.Exit
+
+// errExiting is what app.After returns when a command reported errors
+// without exiting.
+var errExiting = errors.New("Exiting with errors")
+
+// failed is the report of the error that die exits for, and lastChart is
+// the latest chart argument that chartArg resolved. They are for the report
+// of --error-format json.
+var (
+	failed    *errorReport
+	lastChart string
+)
+
+// errorReport describes a failure. With --error-format json, it is written to
+// stderr, as JSON, as the last line that helmc writes.
+type errorReport struct {
+	// Type is the kind of failure, such as "chart-not-found". Its exit
+	// status is ExitCode.
+	Type    string `json:"type"`
+	Message string `json:"message"`
+	// Chart is the chart that the failure is about, if it is known.
+	Chart string `json:"chart,omitempty"`
+	// Resource is the Kubernetes resource that was rejected, as in "Pod
+	// redis in namespace default".
+	Resource string `json:"resource,omitempty"`
+	// Hint says what to do about the failure, if there is anything.
+	Hint     string `json:"hint,omitempty"`
+	ExitCode int    `json:"exitCode"`
+}
+
+// text is the message of a report, as it is printed by default.
+func (r *errorReport) text() string {
+	if r.Type == "chart-not-found" {
+		return r.Message + "\n" + r.Hint
+	}
+	return r.Message
+}
+
+// die reports an error returned by an action, and exits with the status for
+// its kind. It does nothing if err is nil.
+//
+// This is the one place where the errors of the action layer are turned
+// into messages for the user and exit statuses.
+func die(err error) {
+	if err == nil {
+		return
+	}
+	r := report(err)
+	log.Err("%s", r.text())
+	failed = r
+	exit(r.ExitCode)
+}
+
+// Exit ends helmc with the error that Cli's Run returned, and otherwise with
+// status 0. It is for main, so that every failure, including those of
+// flags, is reported by die.
+func Exit(err error) {
+	if err == errExiting {
+		// The errors were reported as they happened.
+		fmt.Fprintln(log.Stderr, err)
+		exit(1)
+		return
+	}
+	die(err)
+	exit(0)
+}
+
+// describe returns the message and exit status for an error.
+func describe(err error) (string, int) {
+	r := report(err)
+	return r.text(), r.ExitCode
+}
+
+// report returns the report of an error, which has its message, its exit
+// status, and the chart and resource that it is about.
+func report(err error) *errorReport {
+	r := &errorReport{Type: "error", Message: err.Error(), Chart: lastChart, ExitCode: 1}
+	var (
+		nf *helmerrors.ChartNotFoundError
+		ae *helmerrors.AmbiguousChartError
+		re *helmerrors.RepoError
+		ke *helmerrors.KubeError
+		le *helmerrors.LintError
+		pe *helmerrors.PreflightError
+		ge *helmerrors.GeneratorError
+		he *helmerrors.HookError
+		ne *helmerrors.NotReadyError
+		te *helmerrors.TestError
+	)
+	switch {
+	case errors.As(err, &nf):
+		r.Type, r.Chart, r.ExitCode = "chart-not-found", nf.Name, exitChartNotFound
+		r.Hint = "Run `helmc search` to find a chart, or `helmc update` to refresh your repositories."
+	case errors.As(err, &ae):
+		r.Type, r.Chart, r.ExitCode = "ambiguous-chart", ae.Name, exitAmbiguous
+		r.Hint = "Give one of " + strings.Join(ae.Candidates, ", ") + " instead."
+	case errors.As(err, &re):
+		r.Type, r.ExitCode = "repository", exitRepo
+		r.Hint = fmt.Sprintf("Check the URL of %s with `helmc repo list`, and run `helmc update` again.", re.Repo)
+	case errors.As(err, &ke):
+		r.Type, r.Resource, r.ExitCode = "kubernetes", ke.Resource(), exitKube
+		r.Hint = "Fix the manifest of the resource, or check that kubectl can reach the cluster."
+	case errors.As(err, &le):
+		r.Type, r.ExitCode = "lint", exitLint
+		if len(le.Charts) == 1 {
+			r.Chart = le.Charts[0]
+		}
+		r.Hint = "Fix the failed checks that were listed, and run `helmc lint` again."
+	case errors.As(err, &pe):
+		r.Type, r.Chart, r.ExitCode = "preflight", pe.Chart, exitPreflight
+		r.Hint = "Fix the findings that were listed, or re-run with --skip-preflight."
+	case errors.As(err, &he):
+		r.Type = "hook"
+		r.Resource = (&helmerrors.KubeError{Kind: he.Kind, Name: he.Name, Namespace: he.Namespace}).Resource()
+		r.Hint = fmt.Sprintf("The hook was left in the cluster. Inspect it with `kubectl describe %s %s`, and delete it before installing again.", strings.ToLower(he.Kind), he.Name)
+	case errors.As(err, &ne):
+		r.Type, r.Chart, r.ExitCode = "not-ready", ne.Chart, exitNotReady
+		r.Hint = fmt.Sprintf("The resources were installed. See what they are waiting for with `helmc status %s`, or wait longer with --timeout.", ne.Chart)
+	case errors.As(err, &te):
+		r.Type, r.Chart, r.ExitCode = "test", te.Chart, exitTestFailed
+		r.Hint = fmt.Sprintf("Re-run with `helmc test --keep %s` to leave the tests in the cluster, and inspect them with `kubectl describe`.", te.Chart)
+	case errors.As(err, &ge):
+		r.Type = "generator"
+		if ge.Chart != "" {
+			r.Chart = ge.Chart
+			r.Hint = fmt.Sprintf("Run `helmc generate --explain %s %s` to see how the generator runs.", ge.File, ge.Chart)
+		}
+	}
+	return r
+}
+
+// exitReport is the report of a failure that die did not exit for, such as
+// a log.Die, a timeout, or an interrupt. Its message is that of the latest
+// error that was logged.
+func exitReport(code int) *errorReport {
+	r := &errorReport{Type: "error", Message: log.LastErr(), Chart: lastChart, ExitCode: code}
+	switch code {
+	case helm.ExitTimeout:
+		r.Type, r.Hint = "timeout", "Give a longer --timeout, or none."
+	case helm.ExitInterrupted, helm.ExitTerminated:
+		r.Type = "interrupted"
+	}
+	if r.Message == "" {
+		r.Message = fmt.Sprintf("helmc exited with status %d", code)
+	}
+	return r
+}
+
+// jsonErrors is whether --error-format is json.
+var jsonErrors bool
+
+// writeErrorReport writes the report of a failure to stderr, for
+// --error-format json.
+func writeErrorReport(code int) {
+	if code == 0 {
+		return
+	}
+	r := failed
+	if r == nil || r.ExitCode != code {
+		r = exitReport(code)
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(log.Stderr, "%s\n", b)
+}
+
+// useErrorFormat sets how failures are reported.
+func useErrorFormat(format string) error {
+	switch format {
+	case errorFormatText:
+		jsonErrors = false
+	case errorFormatJSON:
+		jsonErrors = true
+	default:
+		return fmt.Errorf("Unknown --error-format %q. Use %s or %s.", format, errorFormatText, errorFormatJSON)
+	}
+	return nil
+}
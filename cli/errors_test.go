@@ -0,0 +1,112 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	helmerrors "github.com/helm/helm-classic/errors"
+	"github.com/helm/helm-classic/log"
+	"github.com/helm/helm-classic/test"
+)
+
+func TestDescribe(t *testing.T) {
+	tests := []struct {
+		err  error
+		code int
+	}{
+		{errors.New("boom"), 1},
+		{&helmerrors.ChartNotFoundError{Name: "redis", Repos: []string{"charts"}}, exitChartNotFound},
+		{&helmerrors.AmbiguousChartError{Name: "redis", Candidates: []string{"a/redis", "b/redis"}}, exitAmbiguous},
+		{fmt.Errorf("Could not download redis: %w", &helmerrors.RepoError{Repo: "charts", Err: errors.New("boom")}), exitRepo},
+		{fmt.Errorf("Failed to upload manifests: %w", &helmerrors.KubeError{Kind: "Pod", Name: "redis", Err: errors.New("boom")}), exitKube},
+		{&helmerrors.LintError{Charts: []string{"redis"}}, exitLint},
+		{&helmerrors.PreflightError{Chart: "redis", Errors: 2}, exitPreflight},
+		{&helmerrors.NotReadyError{Chart: "redis", Resources: []string{"Deployment redis (1/3 available)"}}, exitNotReady},
+		{&helmerrors.TestError{Chart: "redis", Failed: []string{"Pod redis-ping (the Pod failed)"}, Total: 2}, exitTestFailed},
+	}
+	for _, tt := range tests {
+		msg, code := describe(tt.err)
+		if code != tt.code {
+			t.Errorf("Expected %q to exit with %d, got %d", tt.err, tt.code, code)
+		}
+		if msg == "" {
+			t.Errorf("Expected a message for %q", tt.err)
+		}
+	}
+}
+
+func TestErrorReport(t *testing.T) {
+	tests := []struct {
+		err            error
+		typ, chart, rs string
+		code           int
+	}{
+		{&helmerrors.ChartNotFoundError{Name: "redis", Repos: []string{"charts"}}, "chart-not-found", "redis", "", exitChartNotFound},
+		{&helmerrors.LintError{Charts: []string{"redis"}}, "lint", "redis", "", exitLint},
+		{fmt.Errorf("Failed to complete generation: %w", &helmerrors.GeneratorError{Chart: "redis", File: "gen.yaml", Command: "false", Err: errors.New("exit status 1")}), "generator", "redis", "", 1},
+		{fmt.Errorf("Failed to upload manifests: %w", &helmerrors.KubeError{Kind: "Pod", Name: "redis", Namespace: "cache", Err: errors.New("boom")}), "kubernetes", "", "Pod redis in namespace cache", exitKube},
+		{fmt.Errorf("Failed to upload manifests: %w", &helmerrors.HookError{Hook: "pre-install", Kind: "Job", Name: "migrate", Namespace: "cache", Reason: "the Job failed", Logs: "no database\n"}), "hook", "", "Job migrate in namespace cache", 1},
+	}
+	for _, tt := range tests {
+		r := report(tt.err)
+		if r.Type != tt.typ || r.Chart != tt.chart || r.Resource != tt.rs || r.ExitCode != tt.code {
+			t.Errorf("Expected %s %q %q %d for %q, got %+v", tt.typ, tt.chart, tt.rs, tt.code, tt.err, r)
+		}
+		if r.Message != tt.err.Error() || r.Hint == "" {
+			t.Errorf("Expected the message and a hint for %q, got %+v", tt.err, r)
+		}
+	}
+}
+
+func TestErrorFormatJSON(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+	test.FakeUpdate(tmpHome)
+
+	code := 0
+	exit = func(c int) {
+		if code == 0 {
+			code = c
+		}
+	}
+	defer func() {
+		exit = log.Exit
+		useErrorFormat(errorFormatText)
+		failed, log.ErrorState = nil, false
+	}()
+
+	// The document of the error that die exited for is the last line,
+	// whatever was logged before it.
+	output := test.CaptureOutput(func() {
+		Cli().Run([]string{"helmc", "--home", tmpHome, "--error-format", "json", "fetch", "charts/nonesuch"})
+		log.AtExit(code)
+	})
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	var r errorReport
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &r); err != nil {
+		t.Fatalf("Expected a JSON document on the last line, got %q: %s", output, err)
+	}
+	test.ExpectEquals(t, r.Type, "chart-not-found")
+	test.ExpectEquals(t, r.ExitCode, exitChartNotFound)
+	test.ExpectEquals(t, r.Chart, "nonesuch")
+
+	// A failure that die did not exit for has the latest error.
+	failed = nil
+	output = test.CaptureOutput(func() {
+		log.Err("Could not find chart: redis")
+		writeErrorReport(1)
+	})
+	test.ExpectContains(t, output, `{"type":"error","message":"Could not find chart: redis"`)
+	output = test.CaptureOutput(func() {
+		writeErrorReport(0)
+	})
+	test.ExpectEquals(t, output, "")
+
+	if err := useErrorFormat("xml"); err == nil {
+		t.Errorf("Expected an unknown format to fail")
+	}
+}
@@ -0,0 +1,361 @@
+package cli
+
+// examples are shown in the reference pages of 'helmc docs', keyed by the
+// command's path without "helmc", e.g. "repository add".
+//
+// Each example is a description and the command it describes. Every visible
+// command has at least one; TestExamples checks this.
+var examples = map[string][]example{
+	"": {
+		{"Download the chart repositories", "helmc update"},
+		{"Find a chart, fetch it into your workspace, and install it", "helmc search redis\nhelmc fetch redis\nhelmc install redis"},
+		{"In CI, keep the description of a failure as JSON", "helmc --error-format json install redis 2>helmc.log || tail -n 1 helmc.log | jq .type"},
+	},
+	"apply-plan": {
+		{"Run a reviewed plan of an install", "helmc apply-plan redis-plan.json"},
+		{"Run a plan against the context it was made for", "helmc --kube-context prod apply-plan redis-plan.json"},
+	},
+	"audit": {
+		{"Print the latest changes to Kubernetes", "helmc audit tail"},
+	},
+	"audit tail": {
+		{"Print the last 50 entries of the audit log", "helmc audit tail -n 50"},
+		{"Print the failed operations, with jq", "helmc audit tail -n 0 --output json | jq '.[] | select(.outcome == \"failed\")'"},
+	},
+	"completion": {
+		{"Complete helmc commands in the current bash shell", "source <(helmc completion bash)"},
+	},
+	"completion bash": {
+		{"Complete helmc commands in every bash shell", "helmc completion bash > ~/.local/share/bash-completion/completions/helmc"},
+	},
+	"completion fish": {
+		{"Complete helmc commands in every fish shell", "helmc completion fish > ~/.config/fish/completions/helmc.fish"},
+	},
+	"completion zsh": {
+		{"Complete helmc commands in every zsh shell, from a directory of $fpath", "helmc completion zsh > \"${fpath[1]}/_helmc\""},
+	},
+	"config": {
+		{"Print the configuration that commands use", "helmc config view"},
+	},
+	"config get": {
+		{"Print the name of the default repository", "helmc config get repos.default"},
+		{"Print the settings of the charts repository", "helmc config get repos.tables[charts]"},
+	},
+	"config profiles": {
+		{"List the profiles", "helmc config profiles"},
+		{"List the profiles, marking the one that $HELMC_PROFILE selects", "HELMC_PROFILE=prod helmc config profiles"},
+	},
+	"config set": {
+		{"Pin the third repository to a branch", "helmc config set repos.tables[2].branch stable"},
+		{"Prefer the charts repository for unqualified names", "helmc config set repos.tables[charts].priority 10"},
+		{"Make prod the namespace of the prod profile", "helmc config set profiles.prod.namespace prod"},
+		{"Wait for every resource that kubectl deletes to be gone", "helmc config set kubectl.deleteArgs '--wait --timeout=2m'"},
+	},
+	"config unset": {
+		{"Go back to the kubectl on your PATH", "helmc config unset kubectl.path"},
+	},
+	"config view": {
+		{"Print the configuration, with the kubectl set by $HELMC_KUBECTL", "HELMC_KUBECTL=/opt/bin/kubectl helmc config view"},
+	},
+	"create": {
+		{"Create a chart named mychart in your workspace", "helmc create mychart"},
+		{"Create a chart with a Deployment and a Service", "helmc create --starter web-service mychart"},
+		{"List the starters that charts can be created from", "helmc create --list-starters"},
+		{"Generate a chart with a CronJob from its values.yaml and templates", "helmc create --from cronjob nightly"},
+		{"Create a chart by answering questions about it", "helmc create --interactive"},
+		{"Create a chart with its own fields, and no example manifests", "helmc create --description 'A cache' --chart-version 1.0.0 --maintainer 'Jo Doe' --email jo@example.com --keywords cache,redis --no-examples mychart"},
+	},
+	"deps": {
+		{"Show the dependency tree of mychart", "helmc deps mychart"},
+		{"Show what fetching redis with its dependencies would pull in", "helmc deps charts/redis"},
+		{"Render the dependencies of mychart as an SVG image", "helmc deps mychart --graph dot | dot -Tsvg > deps.svg"},
+	},
+	"diff": {
+		{"Show how the resources of redis in the cache namespace differ from the workspace chart", "helmc diff --namespace cache redis"},
+	},
+	"diff-local": {
+		{"List the files of redis that you changed in your workspace", "helmc diff-local redis"},
+		{"Show the changes as a unified diff", "helmc diff-local -u redis"},
+	},
+	"doctor": {
+		{"Check that helmc, its home, the cluster, and the repositories are set up correctly", "helmc doctor"},
+		{"Check everything but the repositories, without the network", "helmc --offline doctor"},
+		{"Print the problems found as JSON, for a script", "helmc doctor -o json"},
+	},
+	"edit": {
+		{"Open the redis chart of your workspace in $EDITOR", "helmc edit redis"},
+		{"Edit redis, and upgrade the installed chart with the changes without asking", "helmc edit --yes redis"},
+		{"Edit redis with vim, without running its generators or offering to upgrade it", "EDITOR=vim helmc edit --no-generate --no-apply redis"},
+	},
+	"export": {
+		{"Bundle redis and its dependencies, with what their generators write", "helmc export --generate redis redis-bundle.tgz"},
+		{"Bundle redis, rendering its templates with the values of prod", "helmc export -g -f values-prod.yaml redis redis-bundle.tgz"},
+	},
+	"fetch": {
+		{"Fetch the redis chart into your workspace", "helmc fetch redis"},
+		{"Fetch the redis chart of the charts repository as myredis", "helmc fetch charts/redis myredis"},
+		{"Fetch redis from the mycharts repository, replacing any other redis in your workspace", "helmc fetch --repo mycharts --force redis"},
+		{"Fetch redis only if your workspace does not have it yet", "helmc fetch --if-absent redis"},
+		{"Fetch a chart with symlinks outside it, or more files than the limits allow", "helmc fetch --allow-unsafe mychart"},
+		{"Fetch the deprecated chart oldchart, although fetch.strict is set", "helmc fetch --accept-deprecated oldchart"},
+		{"Fetch redis, and print the checksum to pin it by", "helmc fetch --print-checksum redis"},
+		{"Fetch mychart, and the charts it depends on, recording them in Chart.lock", "helmc fetch --deps mycharts/mychart"},
+		{"Fetch the charts of helmc.lock again, as they were recorded", "helmc fetch --locked"},
+		{"Fetch redis as the lock file of a project records it", "helmc fetch --locked --lockfile ./helmc.lock redis"},
+		{"Expand a packaged chart into your workspace as myredis", "helmc fetch ./redis-0.2.0.tgz myredis"},
+		{"Fetch redis only if its provenance file is signed by a key of the team", "helmc fetch --verify --keyring team.gpg charts/redis"},
+	},
+	"generate": {
+		{"Run the generators of the mychart chart", "helmc generate mychart"},
+		{"List the generators that would run, skipping the tpl directory", "helmc generate --dry-run --exclude=tpl mychart"},
+		{"Print the plan of the generators of mychart as JSON, without running them", "helmc generate --dry-run -o json mychart"},
+		{"Fail on generators that use undefined variables", "helmc generate --strict-env mychart"},
+		{"Print a script that runs the generator of tpl/pod.yaml by hand", "helmc generate --explain tpl/pod.yaml mychart"},
+		{"Run the generators again whenever their files change", "helmc generate --watch mychart"},
+		{"Run up to 8 generators at a time", "helmc generate --jobs 8 mychart"},
+		{"Only run the generators of mychart whose inputs changed", "helmc generate --incremental mychart"},
+		{"Kill each generator of mychart that runs for more than two minutes", "helmc generate --generator-timeout 2m mychart"},
+		{"Run the generators, printing their output as it is", "helmc generate --verbose mychart"},
+		{"Allow the generators of mychart to run only templates and sed", "helmc --allow-generators=tpl,sed generate mychart"},
+		{"Delete the files that the generators of mychart wrote", "helmc generate --clean mychart"},
+		{"Run the generators, with a database password from the environment", "helmc generate --set-from db.password=env:DB_PASSWORD mychart"},
+		{"Run the generators with the values of prod.yaml, and three replicas", "helmc generate --values prod.yaml --set replicas=3 mychart"},
+	},
+	"home": {
+		{"Print the Helm Classic home", "helmc home"},
+		{"Print where a CI job finds its configuration, cache, and workspace", "HELMC_CACHE=/shared/helmc-cache helmc home --paths"},
+	},
+	"history": {
+		{"List the installs and rollbacks of redis", "helmc history redis"},
+		{"Print the revisions of redis, with their manifests, as JSON", "helmc history -o json redis"},
+	},
+	"import": {
+		{"Create the redis chart from the resources labeled app=redis in the cache namespace", "helmc import --selector app=redis --namespace cache redis"},
+		{"Import only the Deployment and Service, and let the new chart own them", "helmc import -l app=redis --kinds Deployment --kinds Service --adopt redis"},
+	},
+	"info": {
+		{"Describe the redis chart", "helmc info redis"},
+		{"Print the version of the redis chart", "helmc info --format '{{.Version}}' redis"},
+		{"Print the Chart.yaml of the redis chart as JSON", "helmc -o json info redis"},
+		{"Review the resources, images, and cluster requirements of redis before installing it", "helmc info --manifest-summary redis"},
+	},
+	"install": {
+		{"Install the redis chart into the default namespace", "helmc install redis"},
+		{"Install redis with the namespace and context of the prod profile", "helmc --profile prod install redis"},
+		{"Install redis into the staging environment, with its values-staging.yaml", "helmc install --generate --env staging redis"},
+		{"Install redis into the cache namespace, creating or updating its resources", "helmc install --namespace cache --mode apply redis"},
+		{"Install redis into the tenant-a namespace, writing it into manifests that have none", "helmc install --namespace tenant-a --inject-namespace redis"},
+		{"Ask Kubernetes to validate the manifests of redis, without installing them", "helmc install --dry-run=server redis"},
+		{"Write the plan of installing redis for review, and install nothing", "helmc install --namespace cache --plan redis-plan.json redis"},
+		{"Install redis from a bundle of 'helmc export', without network access", "helmc install --from-bundle redis-bundle.tgz"},
+		{"Install redis without the preflight checks", "helmc install --skip-preflight redis"},
+		{"Install redis, appending what it does to events.jsonl as JSON lines", "helmc --events-file events.jsonl install redis"},
+		{"Install redis, and write where the time went for the textfile collector of Prometheus", "helmc --metrics-file /var/lib/node_exporter/helmc.prom install redis"},
+		{"Install redis without the heritage and chart labels on its resources", "helmc install --no-labels redis"},
+		{"Install the deprecated chart oldchart, although fetch.strict is set", "helmc install --accept-deprecated oldchart"},
+		{"Install mychart, fetching the charts it depends on that the workspace is missing", "helmc install --deps mychart"},
+		{"Install a packaged chart, expanding it into your workspace first", "helmc install ./redis-0.2.0.tgz"},
+		{"Install charts/redis in a CI job, without a workspace, with the image tag of the build", "helmc install --stateless --generate --set image.tag=\"$TAG\" charts/redis"},
+		{"Install a packaged chart only if it verifies against its provenance file", "helmc install --verify --keyring team.gpg ./redis-0.2.0.tgz"},
+		{"Install redis only if it is the content that was reviewed", "helmc install --namespace cache --checksum sha256:<digest> redis"},
+		{"Install mychart, whose generator makes more than the 1000 manifests that install allows by default", "helmc install --generate --max-documents 5000 mychart"},
+		{"Install redis, then delete the resources that its new version no longer has", "helmc install --namespace cache --mode apply --prune --yes redis"},
+		{"Install redis, and wait up to ten minutes for its workloads to be ready, as in a CI pipeline", "helmc install --wait --timeout 10m redis"},
+		{"Generate and install mychart, with a password that a command reads from a vault", "helmc install --generate --allow-exec-values --set-from 'db.password=cmd:vault read -field=password secret/db' mychart"},
+		{"Generate and install mychart with the values of prod.yaml over its own, and another image tag", "helmc install --generate -f prod.yaml --set image.tag=1.10 mychart"},
+		{"Generate and install a third-party chart, allowing its generators to run only templates and sed", "helmc --allow-generators=tpl,sed install --generate thirdparty"},
+	},
+	"lint": {
+		{"Check the mychart chart of your workspace", "helmc lint mychart"},
+		{"Check every chart in your workspace", "helmc lint --all"},
+		{"Also check the custom resources of mychart against their definitions", "helmc lint --schema-dir ./crds mychart"},
+		{"Check the manifests of mychart against the schemas of Kubernetes 1.21", "helmc lint --kube-version=1.21 mychart"},
+	},
+	"list": {
+		{"List the charts in your workspace", "helmc list"},
+		{"List the charts installed in the cache namespace", "helmc list --installed --namespace cache"},
+		{"List the charts of your workspace as YAML, for a script", "helmc -o yaml list"},
+	},
+	"package": {
+		{"Package the redis chart of your workspace into the current directory", "helmc package redis"},
+		{"Package the chart in ./mychart into the public directory, then index it", "helmc package --destination public ./mychart\nhelmc repository index public"},
+	},
+	"plugins": {
+		{"List the plugins that can be run", "helmc plugins list"},
+	},
+	"plugins list": {
+		{"List the plugins that can be run, and where they are", "helmc plugins list"},
+	},
+	"preflight": {
+		{"Check that redis can be installed into the cache namespace", "helmc preflight --namespace cache redis"},
+		{"Check an install with --mode apply, and print the findings as JSON for CI", "helmc preflight --mode apply -o json redis"},
+	},
+	"prune": {
+		{"List the resources of redis that its chart no longer has, and delete nothing", "helmc prune --namespace cache --dry-run redis"},
+		{"Delete the resources that redis no longer has, without asking", "helmc prune -n cache -y redis"},
+	},
+	"publish": {
+		{"Copy the mychart chart from your workspace into the default repository", "helmc publish mychart"},
+		{"Publish mychart into the mycharts repository, replacing an earlier copy", "helmc publish --repo mycharts --force mychart"},
+		{"Publish mychart into the mycharts repository, and push it to its remote", "helmc publish --repo mycharts --push mychart"},
+	},
+	"reinstall": {
+		{"Install redis again, as it was last installed", "helmc reinstall redis"},
+		{"Print the parameters that redis was last installed with", "helmc reinstall --show redis"},
+		{"Install redis again, into the staging namespace, updating its resources", "helmc reinstall --namespace staging --mode apply redis"},
+		{"Install mychart again, giving its value sources again", "helmc reinstall --set-from db.password=env:DB_PASSWORD mychart"},
+		{"Install mychart again, as last installed but with another image tag", "helmc reinstall --set image.tag=1.11 mychart"},
+	},
+	"remove": {
+		{"Remove the redis chart from your workspace", "helmc remove redis"},
+	},
+	"render": {
+		{"Print the deployment of mychart as install would send it", "helmc render mychart --show deployment.yaml"},
+		{"Print every service of mychart", "helmc render mychart --show 'manifests/*-svc.yaml'"},
+		{"Run the generators, then print every manifest with its source file", "helmc render mychart --generate --show-all"},
+		{"Print the manifests of mychart, rendered with a certificate from a file", "helmc render mychart --generate --show-all --set-from tls.cert=file:./cert.pem"},
+		{"Print the manifests of mychart, rendered with the values of prod.yaml", "helmc render mychart --generate --show-all -f prod.yaml"},
+	},
+	"repository": {
+		{"List the chart repositories", "helmc repository list"},
+		{"Add a Git repository of charts", "helmc repository add mycharts https://github.com/example/charts"},
+	},
+	"repository add": {
+		{"Add a Git repository of charts", "helmc repository add mycharts https://github.com/example/charts"},
+		{"Add an HTTP repository by the URL of the directory of its index.yaml", "helmc repository add stable https://charts.example.com"},
+		{"Add an HTTP repository whose index is signed by a key in a keyring", "helmc repository add --keyring ~/.gnupg/pubring.gpg stable https://charts.example.com/index.yaml"},
+		{"Add a private Git repository over SSH, pinned to the stable branch", "helmc repository add --ssh-key ~/.ssh/id_rsa --branch stable private git@github.com:example/charts.git"},
+		{"Add a Git repository that is cloned without the git binary", "helmc repository add --git-backend native mycharts https://github.com/example/charts"},
+		{"Add a private HTTP repository, storing the token that it reads from stdin", "echo \"$CHARTS_TOKEN\" | helmc repository add --username bot --token - stable https://charts.example.com"},
+	},
+	"repository login": {
+		{"Store the token of the stable repository, which is asked for", "helmc repository login --username bot stable"},
+		{"Store a token that a CI job has in a variable", "echo \"$CHARTS_TOKEN\" | helmc repository login --token - stable"},
+	},
+	"repository logout": {
+		{"Forget the stored token of the stable repository", "helmc repository logout stable"},
+	},
+	"repository set-branch": {
+		{"Pin the mycharts repository to the v1.0 tag", "helmc repository set-branch mycharts v1.0"},
+	},
+	"repository set-depth": {
+		{"Fetch the whole history of the mycharts repository", "helmc repository set-depth mycharts full"},
+	},
+	"repository set-priority": {
+		{"Prefer the charts of mycharts to those of other repositories", "helmc repository set-priority mycharts 10"},
+	},
+	"repository rename": {
+		{"Rename the mycharts repository to team", "helmc repository rename mycharts team"},
+	},
+	"repository list": {
+		{"List the chart repositories", "helmc repository list"},
+		{"List the chart repositories as JSON", "helmc repository list --output json"},
+	},
+	"repository index": {
+		{"Index the packaged charts in ./dist for serving from a URL", "helmc repository index --url https://charts.example.com ./dist"},
+	},
+	"repository remove": {
+		{"Remove the mycharts repository without asking", "helmc repository remove --yes mycharts"},
+	},
+	"rollback": {
+		{"Send the manifests of the first install of redis to Kubernetes again", "helmc rollback redis 1"},
+	},
+	"secret": {
+		{"Print a Secret with a password from the environment", "helmc secret create db --from password=env:DB_PASSWORD"},
+	},
+	"secret create": {
+		{"Write the Secret of a TLS certificate and its key", "helmc secret create --type kubernetes.io/tls -o manifests/tls.yaml web-tls --from-file tls.crt=./cert.pem --from-file tls.key=./key.pem"},
+		{"Make a Secret of the variables of a .env file, in the prod namespace", "helmc secret create -n prod app-env --from-env-file .env"},
+		{"Make a Secret of a password of the pass store, and of a SOPS-encrypted value", "helmc secret create db --from password=pass:prod/db --from api-key=sops:secrets.enc.yaml#api.key"},
+	},
+	"search": {
+		{"Find the charts whose name, description, keywords, or maintainers mention redis", "helmc search redis"},
+		{"Find the charts whose name starts with nginx", "helmc search --regexp '^nginx'"},
+		{"List the database charts that the platform team maintains", "helmc search --keyword database --maintainer platform@example.com"},
+		{"Print the matching charts as JSON, for a script", "helmc search --output json redis"},
+		{"Print the whole descriptions, however narrow the terminal", "helmc --no-truncate search redis"},
+	},
+	"self-update": {
+		{"Install the latest release of helmc", "helmc self-update"},
+		{"Check for a newer release, without installing it", "helmc self-update --check"},
+	},
+	"sign": {
+		{"Sign a packaged chart with the key of ops@example.com, writing redis-0.2.0.tgz.prov", "helmc sign --key ops@example.com redis-0.2.0.tgz"},
+		{"Sign the chart in ./mychart with a key of another keyring, whose passphrase is in a variable", "HELMC_SIGNING_PASSPHRASE=\"$PASS\" helmc sign --keyring ci-secring.gpg ./mychart"},
+	},
+	"stack": {
+		{"Install the charts of shop.yaml, in order, and roll them back if one fails", "helmc stack install shop.yaml"},
+	},
+	"stack install": {
+		{"Install the charts of a stack into the cluster of the kubeconfig context prod", "helmc --kube-context prod stack install shop.yaml"},
+		{"Install a stack, and show where the time went", "helmc --timings stack install shop.yaml"},
+	},
+	"stack uninstall": {
+		{"Uninstall the charts of a stack, newest first, without asking", "helmc stack uninstall --yes shop.yaml"},
+		{"Uninstall a stack, and wait for each kind of resource to be gone", "helmc stack uninstall --wait --timeout 2m shop.yaml"},
+	},
+	"stack status": {
+		{"Show the revision and readiness of each chart of a stack", "helmc stack status shop.yaml"},
+		{"Print the status of each chart of a stack as JSON", "helmc stack status -o json shop.yaml"},
+	},
+	"status": {
+		{"Show whether the resources of the redis chart are installed, and ready", "helmc status redis"},
+		{"Wait up to two minutes for the resources of redis to be ready", "helmc status --watch --timeout 2m redis"},
+		{"Print the state of each resource of redis as JSON", "helmc -o json status redis"},
+	},
+	"target": {
+		{"Show the Kubernetes cluster that helmc will talk to", "helmc target"},
+	},
+	"test": {
+		{"Run the tests of redis against its release", "helmc test redis"},
+		{"Run the tests of redis in the cache namespace, for up to ten minutes, and leave them in the cluster", "helmc test --namespace cache --timeout 10m --keep redis"},
+	},
+	"template": {
+		{"Render a template with values from a TOML file", "helmc template --values values.toml --out manifests/pod.yaml pod.tpl.yaml"},
+		{"Render a template even though its values do not match the chart's schema", "helmc template --skip-schema --values values.toml pod.tpl.yaml"},
+		{"Render a template with a password from the environment and a certificate from a file", "helmc template --values values.toml --set-from db.password=env:DB_PASSWORD --set-from tls.cert=file:./cert.pem pod.tpl.yaml"},
+		{"Render a template with another image tag than its values file has", "helmc template --values values.toml --set image.tag=1.10 pod.tpl.yaml"},
+		{"Render a template whose values file reads a value from a command", "helmc template --allow-exec-values --values values.yaml pod.tpl.yaml"},
+	},
+	"uninstall": {
+		{"Uninstall the redis chart from the cache namespace", "helmc uninstall --namespace cache redis"},
+		{"Uninstall redis without asking, and wait for its resources to be deleted", "helmc uninstall -y --wait redis"},
+		{"Uninstall redis without running its pre-delete hooks", "helmc uninstall --no-hooks --namespace cache redis"},
+		{"Uninstall redis, but keep its namespace and a shared config map", "helmc uninstall --keep-namespaces --keep ConfigMap/redis-config redis"},
+		{"Uninstall redis, with the resources that earlier versions of it installed", "helmc uninstall --purge-orphans --namespace cache redis"},
+		{"Write the plan of uninstalling redis for review, and delete nothing", "helmc uninstall --namespace cache --plan redis-uninstall.json redis"},
+	},
+	"update": {
+		{"Update every chart repository", "helmc update"},
+		{"Update the repositories, stopping at the first failure", "helmc update --fail-fast"},
+		{"Update at most two repositories at once", "helmc update --jobs 2"},
+		{"Update the repositories, and index their charts from scratch", "helmc update --reindex"},
+		{"Update the repositories, and print how long each one took", "helmc --timings update"},
+	},
+	"upgrade": {
+		{"Upgrade the release of redis to the chart in the workspace, sending only what changed", "helmc upgrade redis"},
+		{"Upgrade redis with a new image tag for its templates", "helmc upgrade --generate --set image.tag=3.2 redis"},
+		{"Print the kubectl commands of an upgrade of redis, and change nothing", "helmc upgrade --dry-run redis"},
+	},
+	"verify": {
+		{"Verify a packaged chart against the keys of your GnuPG keyring", "helmc verify redis-0.2.0.tgz"},
+		{"Verify the chart in ./mychart against the keys of the team", "helmc verify --keyring team.gpg ./mychart"},
+	},
+	"version": {
+		{"Print the version of helmc", "helmc version"},
+		{"Print the versions of helmc, kubectl, and the Kubernetes API server", "helmc version --server"},
+	},
+	"workspace": {
+		{"List the workspace charts whose origin is gone", "helmc workspace gc --dry-run"},
+	},
+	"workspace gc": {
+		{"List the workspace charts whose origin is gone, and delete them after asking", "helmc workspace gc"},
+		{"Delete them without asking, as in a script", "helmc workspace gc --yes"},
+	},
+}
+
+// example is one example of the use of a command.
+type example struct {
+	Description string
+	Command     string
+}
@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"github.com/codegangsta/cli"
+	"github.com/helm/helm-classic/action"
+)
+
+const exportDescription = `Write a bundle of a chart of the workspace, with all of its dependencies,
+that 'helmc install --from-bundle' installs on a machine that cannot reach
+the repositories, such as one of an air-gapped cluster.
+
+The dependencies of the chart that the workspace is missing are fetched
+first, as with 'helmc fetch --deps'. With '--generate', the generators of
+the chart and of each dependency then run, and what they write is bundled, so
+that the machine that installs the bundle needs neither the tools of the
+generators nor their network access. The values of '--values', '--set', and
+'--set-from' are given to the templates of the chart only.
+
+The bundle is a gzipped tar archive with a bundle.yaml, which lists each
+chart with its version and digest, and each chart as it is in the workspace.
+The charts are checked against their digests when the bundle is installed, so
+a bundle that was changed since it was exported is refused.
+`
+
+var exportCmd = cli.Command{
+	Name:        "export",
+	Usage:       "Write a chart and its dependencies into a bundle, to install without network access.",
+	Description: exportDescription,
+	ArgsUsage:   "[chart-name] [bundle.tgz]",
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "generate,g",
+			Usage: "Run the generators of the chart and its dependencies, and bundle what they write.",
+		},
+		cli.BoolFlag{
+			Name:  "skip-schema",
+			Usage: "With --generate, render templates without validating their values against the chart's values schema.",
+		},
+		cli.StringSliceFlag{
+			Name:  "values,f",
+			Usage: "Merge a values file over the chart's values.yaml for its templates. Can be given more than once; later files win.",
+		},
+		cli.StringSliceFlag{
+			Name:  "set",
+			Usage: "Set a value of the templates, as KEY=VALUE, such as image.tag=1.2. Wins over the values files. Can be given more than once.",
+		},
+		cli.StringSliceFlag{
+			Name:  "set-from",
+			Usage: "Set a value of the templates from a source, as KEY=env:NAME, KEY=file:PATH, KEY=cmd:COMMAND, KEY=pass:NAME, or KEY=sops:PATH[#KEY] (if -g is set). Can be given more than once.",
+		},
+		cli.BoolFlag{
+			Name:  "allow-exec-values",
+			Usage: "Allow the cmd:, pass:, and sops: value sources, which run a command.",
+		},
+		envFlag,
+		cli.BoolFlag{
+			Name:  "force",
+			Usage: "Overwrite the bundle if it exists.",
+		},
+	},
+	Action: func(c *cli.Context) {
+		minArgs(c, 2, "export")
+		die(useEnv(c))
+		die(action.Export(chartName(c, c.Args()[0], workspaceChart), home(c), c.Args()[1], action.ExportOptions{
+			Generate:   c.Bool("generate"),
+			SkipSchema: c.Bool("skip-schema"),
+			Values:     valueSources(c),
+			Force:      c.Bool("force"),
+		}))
+	},
+}
@@ -1,6 +1,8 @@
 package cli
 
 import (
+	"fmt"
+
 	"github.com/codegangsta/cli"
 	"github.com/helm/helm-classic/action"
 )
@@ -10,13 +12,73 @@ From this point, the copied chart may be safely modified to your needs.
 
 If an optional 'chart-name' is specified, the chart will be copied to a directory
 of that name. For example, 'helmc fetch nginx www' will copy the the contents of
-the 'nginx' chart into a directory named 'www' in your workspace.`
+the 'nginx' chart into a directory named 'www' in your workspace.
+
+A 'chart' that is a file ending in '.tgz', such as './nginx-0.1.0.tgz', is a
+chart archive, as 'helmc package' writes it. It is expanded into the
+workspace under the name in its Chart.yaml, unless a 'chart-name' is given.
+
+A chart name without a repository, such as 'nginx', is fetched from the
+repository with the highest priority that has it. If several repositories
+tie, helmc asks which one to fetch when run in a terminal, and fails
+otherwise. Use '--repo' to name the repository instead.
+
+If the workspace already has a different chart of the same name, the fetch
+fails. Use '--force' to replace it, or '--if-absent' to leave an identical
+chart alone without fetching again. How the name was resolved, and what was
+done to the workspace, is always logged.
+
+Fetched charts are made safe to use: symlinks that point outside the chart,
+and files that are not regular files, are left out, setuid and setgid bits
+are cleared, and permissions are normalized to 0644, or 0755 for files that
+may be executed, such as the scripts of generators. Each such file is
+reported. A chart with more files, or bigger files, than the limits of
+fetch.maxFiles and fetch.maxSizeMB in the configuration is not fetched.
+'--allow-unsafe' copies the chart as it is, and lifts the limits.
+
+A chart that its author marked as deprecated is fetched with a warning, which
+gives the reason if the chart has one. If fetch.strict is set in the
+configuration, it is not fetched unless '--accept-deprecated' is given.
+
+The dependencies of a chart are declared in its Chart.yaml, with a name, a
+semantic version range, such as '~1.2' or '>= 1.0, < 2', and optionally the
+URL or the name of the repository they come from. '--deps' fetches those
+that the workspace is missing, and their own in turn. Each is fetched from
+the repository with the highest version of it that satisfies the range;
+equal versions go to the repository with the highest priority. Charts that
+depend on each other, and ranges that no repository satisfies, or that a
+chart already fetched for another range does not satisfy, are errors. What
+every dependency was resolved to is written to 'Chart.lock' in the chart,
+each after those it depends on.
+
+'--verify' checks the chart against its provenance file before it is copied
+into the workspace, as 'helmc verify' does: the file must be signed by a key
+of '--keyring', and name the chart's name, version, and checksum. The
+provenance file is next to the chart in a Git repository or a directory
+mirror, such as 'nginx.prov' next to 'nginx', and next to the archive in an
+HTTP repository or on disk, such as 'nginx-0.1.0.tgz.prov'. A chart that
+fails verification is not fetched.
+
+'--print-checksum' prints the checksum of the chart in the workspace last,
+such as 'sha256:3f2a...'. Once the chart has been reviewed, give it to
+'helmc install --checksum' to install only that content.
+
+Every chart that is fetched is recorded in 'helmc.lock' in the workspace,
+with the repository and chart, or the archive, it came from, its version, the
+commit that the cache of a Git repository had checked out, and its digest.
+'--locked' fetches the charts of that file again, such as into the workspace
+of another home, or those named as arguments, by their names in the
+workspace. '--lockfile' reads another file, such as one committed with the
+code that deploys the charts. A chart is only fetched if it is the same as it
+was when it was recorded: the cache of its Git repository must have the same
+commit checked out, and the chart must have the same digest. Otherwise the
+fetch fails, and the workspace chart is left alone.`
 
 var fetchCmd = cli.Command{
 	Name:        "fetch",
 	Usage:       "Fetch a Chart to your working directory.",
 	Description: fetchDescription,
-	ArgsUsage:   "[chart] [chart-name]",
+	ArgsUsage:   "[chart] [chart-name] | --locked [chart-name...]",
 	Action:      fetch,
 	Flags: []cli.Flag{
 		cli.StringFlag{
@@ -24,20 +86,91 @@ var fetchCmd = cli.Command{
 			Value: "default",
 			Usage: "The Kubernetes destination namespace.",
 		},
+		cli.StringFlag{
+			Name:  "repo",
+			Usage: "The repository to fetch the chart from.",
+		},
+		cli.BoolFlag{
+			Name:  "force, f",
+			Usage: "Replace a different chart of the same name in the workspace.",
+		},
+		cli.BoolFlag{
+			Name:  "if-absent",
+			Usage: "Do nothing if the workspace already has the chart, unchanged.",
+		},
+		cli.BoolFlag{
+			Name:  "allow-unsafe",
+			Usage: "Copy symlinks outside the chart, and setuid and setgid files, as they are, and fetch charts over the size limits.",
+		},
+		cli.BoolFlag{
+			Name:  "accept-deprecated",
+			Usage: "Fetch a deprecated chart even if fetch.strict is set.",
+		},
+		cli.BoolFlag{
+			Name:  "deps",
+			Usage: "Fetch the dependencies of the chart that the workspace is missing, and theirs, and write them to Chart.lock.",
+		},
+		cli.BoolFlag{
+			Name:  "print-checksum",
+			Usage: "Print the checksum of the fetched chart, for 'helmc install --checksum'.",
+		},
+		cli.BoolFlag{
+			Name:  "locked",
+			Usage: "Fetch the charts of the lock file again, exactly as they were recorded.",
+		},
+		cli.StringFlag{
+			Name:  "lockfile",
+			Usage: "The lock file that --locked reads, instead of the workspace's helmc.lock.",
+		},
+		verifyFlag,
+		keyringFlag,
 	},
 }
 
 func fetch(c *cli.Context) {
 	home := home(c)
+	if c.Bool("locked") {
+		fetchLocked(c, home)
+		return
+	}
+	if c.IsSet("lockfile") {
+		die(fmt.Errorf("--lockfile is the file that --locked reads. Add --locked."))
+	}
 	minArgs(c, 1, "fetch")
 
 	a := c.Args()
-	chart := a[0]
+	chart := chartName(c, a[0], fetchChart)
 
 	var lname string
 	if len(a) == 2 {
 		lname = a[1]
 	}
 
-	action.Fetch(chart, lname, home)
+	die(action.Fetch(chart, lname, home, action.FetchOptions{
+		Repo:        c.String("repo"),
+		Force:       c.Bool("force"),
+		IfAbsent:    c.Bool("if-absent"),
+		AllowUnsafe: c.Bool("allow-unsafe"),
+
+		AcceptDeprecated: c.Bool("accept-deprecated"),
+		PrintChecksum:    c.Bool("print-checksum"),
+		Deps:             c.Bool("deps"),
+		VerifyKeyring:    verifyKeyring(c),
+	}))
+}
+
+// fetchLocked fetches the charts of a lock file again, for 'fetch --locked'.
+func fetchLocked(c *cli.Context, home string) {
+	for _, f := range []string{"repo", "deps", "if-absent"} {
+		if c.IsSet(f) {
+			die(fmt.Errorf("--locked fetches each chart from where the lock file says, as it was, so it cannot be combined with --%s", f))
+		}
+	}
+	die(action.FetchLocked(c.String("lockfile"), home, c.Args(), action.FetchOptions{
+		Force:       c.Bool("force"),
+		AllowUnsafe: c.Bool("allow-unsafe"),
+
+		AcceptDeprecated: c.Bool("accept-deprecated"),
+		VerifyKeyring:    verifyKeyring(c),
+	}))
 }
@@ -1,6 +1,8 @@
 package cli
 
 import (
+	"fmt"
+
 	"github.com/codegangsta/cli"
 	"github.com/helm/helm-classic/action"
 )
@@ -13,7 +15,9 @@ header. If it finds the header, it will execute the corresponding command.
 The header is in the form '#helm:generate CMD [ARGS]'. 'CMD' can be any command
 that Helm Classic finds on $PATH. Optional 'ARGS' are arguments that will be passed on
 to the command. Generator commands can begin with any of the following sequences:
-'#helm:generate', '//helm:generate', or '/*helm:generate'.
+'#helm:generate', '//helm:generate', or '/*helm:generate'. The header is the
+first line of the file, except that a shebang, such as '#!/bin/bash', empty
+lines, and YAML '---' separators may come before it.
 
 For example, to embed a generate instruction in a YAML file, one may do the
 following:
@@ -23,6 +27,12 @@ following:
 SPECIAL NOTE: For compatibility with older charts, Helm Classic will translate the 'helm' command
 used within any generator header to the equivalent 'helmc' command.
 
+A generator whose command is 'helm template' or 'helm tpl' (or 'helmc ...')
+is rendered by the running helmc itself, without executing a process for
+each file, so charts of templates need no helmc on $PATH. A command with a
+flag that 'helmc template' does not know, or with flags after the template,
+is executed as any other.
+
 If CMD is an absolute path, Helm Classic will attempt to execute it even if it is not
 on $PATH. Combined with the $HELM_GENERATE_DIR environment variable, charts can
 include their own local scripts:
@@ -42,22 +52,90 @@ could run a sed substitution just as easily:
 	#helm:generate sed -i -e s|ubuntu-debootstrap|fluffy-bunny| my/pod.yaml
 
 Note that 'helmc generate' does not execute inside of a shell. However, it does
-expand environment variables. The following variables are made available by the
-Helm Classic system:
+expand environment variables, and arguments may be quoted with single or
+double quotes, as in a shell. On Windows, the built-in commands of cmd.exe and
+.bat and .cmd scripts run with 'cmd /c', and .ps1 scripts with PowerShell.
+
+The following variables are made available by the Helm Classic system:
 
 - HELM_HOME: The Helm home directory
 - HELM_DEFAULT_REPO: The repository alias for the default repository.
 - HELM_GENERATE_FILE: The present file's name
 - HELM_GENERATE_DIR: The absolute path to the chart directory of the present chart
+- HELM_SKIP_SCHEMA: 'true' if '--skip-schema' was given, otherwise 'false'
+- HELM_VALUES: A YAML file of the values of the chart: its values.yaml, merged
+  with its values-ENV.yaml of '--env', the files of '--values', and the values
+  of '--set', in that order
+- HELM_VALUE_<PATH>: Each of those values, by its path in upper case with
+  underscores, as in HELM_VALUE_IMAGE_TAG for image.tag
+- HELM_VALUES_FILES, HELM_SET: The '--values' files and '--set' values, one per
+  line, if any were given
+- HELM_SET_FROM: The '--set-from' value sources, one per line, if any were given
+- HELM_ALLOW_EXEC_VALUES: 'true' if '--allow-exec-values' was given
+- HELM_ENV: The environment of '--env' or '--profile', if one was given
 
 SPECIAL NOTE: For compatibility with older charts, Helm Classic honors these old, "special"
 variables and does not replace them with 'HELMC_*' equivalents.
 
+Use '$$' for a literal '$'. To turn off expansion for one generator, declare
+it with 'helm:generate:raw' instead of 'helm:generate':
+
+	#helm:generate:raw sed -i -e s|$|;| my/pod.yaml
+
+A variable that is not defined expands to the empty string. With
+'--strict-env', it is an error instead, naming the variable, the file, and
+the generator, and nothing more is run. The variables above, and those in the
+environment of helmc, are defined. 'helmc lint' checks every generator in the
+same way, without running any.
+
+Since a generator runs whatever command its chart declares, generating a
+chart from someone else runs their commands. Only the templates that helmc
+renders itself may run, unless you allow more commands: list them in
+'generate.allow' of the configuration file, or give them to the global
+'--allow-generators' flag, separated by commas:
+
+	$ helmc config set generate.allow "tpl sed"
+	$ helmc --allow-generators=tpl,sed generate foo
+
+'tpl' allows the templates that helmc renders itself, a name such as 'sed'
+allows the command of that name on $PATH, and an absolute path allows that
+file. If a generator runs any other command, nothing runs, and the error
+names the file and the command. '--allow-generators=all' allows every
+command, for the charts you trust. Without either, only 'tpl' is allowed.
+The same policy applies to 'helmc install --generate', 'helmc render
+--generate', and the generators that 'helmc lint' runs.
+
+The values of a chart are those of its values.yaml, merged with the files
+of '--values', which may be given more than once, and then with '--set
+KEY=VALUE', as in '--set image.tag=1.10'. Mappings are merged key by key, and
+later values win. The templates that 'helmc template' renders get them too,
+along with the values file of the template and '--set-from'; see 'helmc help
+template'. $HELM_VALUES is $HELMC_HOME/values/<chart>.yaml, written again by
+each run, and only readable by its owner. The values of '--set-from' are
+never written to it, nor to the HELM_VALUE_* variables.
+
 By default, 'helmc generate' will execute every generator that it finds in a
 project. Generators can be mixed, with different files using different
 generators. The order of generation is the order in which the directory contents
 are listed.
 
+A generator that needs the output of others runs after them when it names
+their files in 'helm:after' comments, on the lines that follow its header,
+relative to the chart:
+
+	#helm:generate helm tpl -o manifests/pod.yaml tpl/pod.yaml
+	#helm:after tpl/values.yaml tpl/config.yaml
+
+Naming a file without a generator, or generators that run after each other
+in a cycle, is an error, and nothing runs. 'helmc lint' checks both. A named
+generator that '--exclude' leaves out is not waited for.
+
+A file may declare several commands, on 'helm:generate' lines that follow its
+header. They run in order, and one that fails stops those after it:
+
+	#helm:generate helm tpl -o manifests/pod.yaml tpl/pod.yaml
+	#helm:generate kubeval manifests/pod.yaml
+
 The environment variables listed above are also available to generators.
 
 For charts that contain multiple different generator template sets, you may
@@ -67,6 +145,72 @@ prevent generators from being run using the '--exclude' flag:
 
 The above will prevent the generator from traversing the 'foo' chart's 'tpl/'
 or 'sed/' directories.
+
+To see which generators would run, and with what expanded commands, without
+running any of them, use '--dry-run'. It prints a table of the files that
+declare them, in the order that they would run, with the files that each
+runs after and its expanded command. With '--output json', it prints the
+same as JSON, along with each directive before expansion, the files that it
+declares as its output, and the variables that it uses but that are not
+defined.
+
+Each line that a generator prints, on stdout or stderr, is logged with the
+file that declares it, as '[generate tpl/pod.yaml] ...', so that the output
+of many generators can be told apart. Lines with a warning are logged as
+warnings. With '--verbose', the output is printed as it is instead, without
+prefixes, as generators that print manifests to stdout may need.
+
+With '--jobs N', up to N generators run at a time, which speeds up charts
+with many of them. Their messages and output are printed once each is done,
+in the order that they run. Each starts once those it runs after are done.
+A generator that fails does not stop the others, except those that run
+after it; the first failure, in that order, is the error of the command,
+and the others are reported too. The default, '--jobs 1', runs them one
+after the other and stops at the first failure.
+
+With '--incremental', a generator only runs if its inputs changed since it
+last ran: its command and environment, the file that declares it, and the
+files that its arguments name. A generator whose declared output is gone or
+was edited runs too. The inputs are recorded in the '.helm-generate.lock'
+file of the chart. '--force' runs every generator, and records them.
+
+With '--generator-timeout 2m', a generator that runs for longer than two
+minutes is killed, along with every process that it started, and fails. A
+Ctrl-C kills the running generators in the same way, and no others start.
+Generators are not given the terminal as stdin, so a command that waits for
+input gets none instead of hanging; a file or pipe given to helmc on stdin
+is passed on.
+
+To reproduce a single generator by hand, use '--explain' with its file:
+
+	$ helmc generate --explain tpl/pod.yaml foo
+
+This prints the directive, the expanded command and the directory it runs
+in, as a shell script that changes to the directory, exports every variable
+that the generator is given ('export KEY='value''), and runs the command. It
+can be copied or piped to a shell. Nothing is run. A file without a
+generator, or one that '--exclude' or a '.' or '_' directory excludes, is an
+error that says so. With '--debug', the same script is logged when a
+generator fails.
+
+While developing a chart, '--watch' runs the generators, and then keeps
+watching the chart and runs again each generator whose files change: the
+file that declares it, and the files of the chart that its command names,
+but not the file of an '-o', '--out', or '--output' flag. A burst of changes,
+such as an editor saving, triggers one run. Files that a run changes, and
+files that '--exclude', a '.' or '_' directory, or the chart's .helmignore
+leaves out, trigger nothing. A run that fails is reported, and watching goes
+on. Press Ctrl-C to stop; helmc prints how many runs there were.
+
+When a generator names the file that it writes, with '-o', '--out', or
+'--output', the file, its generator, and the SHA-256 of its content are
+recorded in the '.helm-generate-state' file of the chart, one per line and
+sorted, so that it can be kept in version control. Charts whose generators
+name no output have no such file. '--clean' deletes the recorded files:
+those whose generator is gone or no longer writes them, and those that
+'helmc generate' would write again. Files that were edited since they were
+generated are kept, unless '--force' is given, and 'helmc lint' warns about
+them. With '--dry-run', the files are listed, and none is deleted.
 `
 
 var generateCmd = cli.Command{
@@ -83,13 +227,112 @@ var generateCmd = cli.Command{
 			Name:  "force,f",
 			Usage: "Force an overwrite if files already exist when generating manifests.",
 		},
+		cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "List the generators that would run, without running them.",
+		},
+		cli.StringFlag{
+			Name:  "output,o",
+			Usage: "Format of the '--dry-run' plan. Use 'json' for machine-readable output.",
+		},
+		cli.BoolFlag{
+			Name:  "strict-env",
+			Usage: "Fail if a generator uses a variable that is not defined.",
+		},
+		cli.StringFlag{
+			Name:  "explain",
+			Usage: "Print how the generator of a file, relative to the chart, would be run, as a shell script. Nothing is run.",
+		},
+		cli.BoolFlag{
+			Name:  "skip-schema",
+			Usage: "Run the generators, and render templates, without validating their values against the chart's values schema.",
+		},
+		cli.StringSliceFlag{
+			Name:  "values",
+			Usage: "Merge a values file over the chart's values.yaml for its templates. Can be given more than once; later files win.",
+		},
+		cli.StringSliceFlag{
+			Name:  "set",
+			Usage: "Set a value of the templates, as KEY=VALUE, such as image.tag=1.2. Wins over the values files. Can be given more than once.",
+		},
+		cli.StringSliceFlag{
+			Name:  "set-from",
+			Usage: "Set a value of the templates from a source, as KEY=env:NAME, KEY=file:PATH, KEY=cmd:COMMAND, KEY=pass:NAME, or KEY=sops:PATH[#KEY]. Can be given more than once.",
+		},
+		cli.BoolFlag{
+			Name:  "allow-exec-values",
+			Usage: "Allow the cmd:, pass:, and sops: value sources, which run a command.",
+		},
+		envFlag,
+		cli.BoolFlag{
+			Name:  "clean",
+			Usage: "Delete the files that the generators wrote, as .helm-generate-state records them, instead of running the generators.",
+		},
+		cli.BoolFlag{
+			Name:  "verbose",
+			Usage: "Print the output of the generators as it is, instead of logging each line with the file of its generator.",
+		},
+		cli.IntFlag{
+			Name:  "jobs,j",
+			Value: 1,
+			Usage: "How many generators may run at a time.",
+		},
+		cli.BoolFlag{
+			Name:  "incremental",
+			Usage: "Only run the generators whose inputs changed since they last ran, as .helm-generate.lock records them.",
+		},
+		cli.DurationFlag{
+			Name:  "generator-timeout",
+			Usage: "Kill each generator that runs for longer than this, such as 2m. Zero is no limit.",
+		},
+		cli.BoolFlag{
+			Name:  "watch,w",
+			Usage: "Keep watching the chart, and run each generator again when its files change.",
+		},
 	},
 	Action: func(c *cli.Context) {
 		home := home(c)
 		minArgs(c, 1, "generate")
+		die(useEnv(c))
 		force := c.Bool("force")
 		a := c.Args()
-		chart := a[0]
-		action.Generate(chart, home, c.StringSlice("exclude"), force)
+		chart := chartName(c, a[0], workspaceChart)
+		if c.Bool("clean") {
+			if c.Bool("watch") || c.String("explain") != "" {
+				die(fmt.Errorf("--clean cannot be combined with --watch or --explain"))
+			}
+			die(action.GenerateClean(chart, home, force, c.Bool("dry-run")))
+			return
+		}
+		if c.String("output") != "" && !c.Bool("dry-run") {
+			die(fmt.Errorf("--output only applies to --dry-run"))
+		}
+		opts := action.GenerateOptions{
+			Exclude:     c.StringSlice("exclude"),
+			Force:       force,
+			Strict:      c.Bool("strict-env"),
+			SkipSchema:  c.Bool("skip-schema"),
+			Verbose:     c.Bool("verbose"),
+			Incremental: c.Bool("incremental"),
+			Jobs:        c.Int("jobs"),
+			Timeout:     c.Duration("generator-timeout"),
+			Values:      valueSources(c),
+		}
+		if f := c.String("explain"); f != "" {
+			action.ExplainGenerator(chart, home, f, opts)
+			return
+		}
+		if c.Bool("watch") {
+			if c.Bool("dry-run") {
+				die(fmt.Errorf("--watch cannot be combined with --dry-run"))
+			}
+			action.GenerateWatch(chart, home, opts)
+			return
+		}
+		if c.Bool("dry-run") {
+			die(action.GeneratePlan(chart, home, opts, c.String("output")))
+			return
+		}
+		die(action.Generate(chart, home, opts))
 	},
 }
@@ -1,20 +1,19 @@
 package cli
 
 import (
-	"errors"
+	"strings"
 
 	"github.com/codegangsta/cli"
 	"github.com/helm/helm-classic/action"
+	"github.com/helm/helm-classic/config"
+	"github.com/helm/helm-classic/kubectl"
 	"github.com/helm/helm-classic/log"
+	"github.com/helm/helm-classic/metrics"
+	"github.com/helm/helm-classic/output"
+	helm "github.com/helm/helm-classic/util"
+	"github.com/helm/helm-classic/version"
 )
 
-// version is the version of the app.
-//
-// This value is overwritten by the linker during build. The default version
-// here is SemVer 2, but basically indicates that this was a one-off build
-// and should not be trusted.
-var version = "0.1.0"
-
 const globalUsage = `Helm Classic - A Kubernetes package manager
 
 To begin working with Helm Classic, run the 'helmc update' command:
@@ -33,7 +32,47 @@ For more information on Helm Classic, go to http://helm.sh.
 
 ENVIRONMENT:
 $HELMC_HOME:     Set an alternative location for Helm files. By default, these
-				are stored in ~/.helmc
+				are stored in ~/.helmc, or on a new install, in
+				%LOCALAPPDATA%\helmc on Windows and $XDG_DATA_HOME/helmc
+				on Linux if $XDG_DATA_HOME is set. The --home flag takes
+				precedence.
+$HELMC_OFFLINE:  If set to true, behave as if --offline were given.
+$HELMC_CLIENT:   How to talk to Kubernetes, as if --client were given.
+$HELMC_USE_KUBECTL: If set to true, behave as if --use-kubectl were given.
+$HELMC_KUBE_CONTEXT: The kubeconfig context to use, as if --kube-context were given.
+$HELMC_KUBECTL:  The kubectl binary to use, as if --kubectl-path were given.
+$HELMC_TRACE_GIT: The git tracing level, as if --trace-git were given.
+$HELMC_GIT_BACKEND: The Git backend, as if --git-backend were given.
+$HELMC_NO_PROGRESS: If set to true, behave as if --no-progress were given.
+$HELMC_NO_TRUNCATE: If set to true, behave as if --no-truncate were given.
+$NO_COLOR:       If set, print no colors on a terminal.
+$HELMC_ALLOW_GENERATORS: The commands that generators may run, as if --allow-generators were given.
+$HELMC_PROFILE:  The profile to use, as if --profile were given.
+$HELMC_ERROR_FORMAT: How to report a failure, as if --error-format were given.
+$HELMC_SIGNING_PASSPHRASE: The passphrase of the encrypted key of 'helmc sign'.
+
+EXIT STATUS:
+1:  A command failed.
+2:  'helmc self-update --check' found a newer release.
+3:  No repository has the chart.
+4:  The chart name is in more than one repository. Qualify it as REPO/CHART.
+5:  A repository could not be updated or read.
+6:  Kubernetes rejected a resource.
+7:  A chart failed some necessary lint checks.
+8:  The preflight checks of an install failed.
+9:  The resources of a chart were not ready in time, with 'install --wait' or
+    'status --watch'.
+10: Tests of a chart failed, with 'helmc test'.
+124: The command ran longer than --timeout.
+130: The command was interrupted with Ctrl-C.
+143: The command was terminated with SIGTERM.
+
+With --error-format json, a failure also writes a JSON document to stderr, as
+its last line. It has the kind of failure ('type', such as 'chart-not-found',
+'ambiguous-chart', 'repository', 'kubernetes', 'lint', 'preflight',
+'not-ready', 'test', 'generator', 'timeout', 'interrupted', or 'error'), the 'message', the
+'chart' and 'resource' it is about, if they are known, a 'hint' of what to do,
+and the 'exitCode'.
 
 `
 
@@ -42,51 +81,200 @@ func Cli() *cli.App {
 	app := cli.NewApp()
 	app.Name = "helmc"
 	app.Usage = globalUsage
-	app.Version = version
+	app.Version = version.Version
 	app.EnableBashCompletion = true
 	app.After = func(c *cli.Context) error {
 		if log.ErrorState {
-			return errors.New("Exiting with errors")
+			return errExiting
 		}
 		return nil
 	}
 
+	traceGit := new(traceLevel)
 	app.Flags = []cli.Flag{
-		cli.StringFlag{
-			Name:   "home",
-			Value:  "$HOME/.helmc",
-			Usage:  "The location of your Helm Classic files",
-			EnvVar: "HELMC_HOME",
-		},
+		homeFlag,
 		cli.BoolFlag{
 			Name:  "debug",
 			Usage: "Enable verbose debugging output",
 		},
+		cli.StringFlag{
+			Name:   "error-format",
+			Value:  errorFormatText,
+			Usage:  "How to report a failure: 'text', or 'json' to also write a JSON document that describes it as the last line of stderr",
+			EnvVar: "HELMC_ERROR_FORMAT",
+		},
+		cli.StringFlag{
+			Name:   "client",
+			Value:  kubectl.ClientNative,
+			Usage:  "How to talk to Kubernetes: 'native' calls the API server directly, with the credentials of the kubeconfig, and 'exec' runs kubectl",
+			EnvVar: "HELMC_CLIENT",
+		},
+		cli.BoolFlag{
+			Name:   "use-kubectl",
+			Usage:  "Run kubectl for every Kubernetes command, as '--client exec' does, for kubeconfig auth providers and the kubectl arguments of the configuration",
+			EnvVar: "HELMC_USE_KUBECTL",
+		},
+		cli.StringFlag{
+			Name:   "kubectl-path",
+			Usage:  "The kubectl binary to use, with --use-kubectl. Overrides the kubectl path in the configuration file",
+			EnvVar: "HELMC_KUBECTL",
+		},
+		cli.StringFlag{
+			Name:  "kubeconfig",
+			Usage: "The kubeconfig file to use, instead of $KUBECONFIG or ~/.kube/config",
+		},
+		cli.StringFlag{
+			Name:   "kube-context",
+			Usage:  "The kubeconfig context to use. By default, the current context is used",
+			EnvVar: "HELMC_KUBE_CONTEXT",
+		},
+		cli.StringFlag{
+			Name:  "cluster",
+			Usage: "The kubeconfig cluster to use, instead of the context's",
+		},
+		cli.StringFlag{
+			Name:  "user",
+			Usage: "The kubeconfig user to use, instead of the context's",
+		},
+		cli.IntFlag{
+			Name:  "retries",
+			Value: kubectl.Defaults.Retry.Retries,
+			Usage: "The number of times to retry a Kubernetes request that fails for a transient reason",
+		},
+		cli.DurationFlag{
+			Name:  "retry-backoff",
+			Value: kubectl.Defaults.Retry.MaxBackoff,
+			Usage: "The longest delay between retries. The delay starts at 500ms and doubles with each retry",
+		},
+		cli.BoolFlag{
+			Name:   "offline",
+			Usage:  "Fail instead of using the network. Only directory mirrors are updated",
+			EnvVar: "HELMC_OFFLINE",
+		},
+		cli.StringFlag{
+			Name:   "allow-generators",
+			Usage:  "The commands that chart generators may run, separated by commas, such as 'tpl,sed', in addition to the generate.allow of the configuration file. Without either, only 'tpl' may run. Use 'all' to allow any",
+			EnvVar: "HELMC_ALLOW_GENERATORS",
+		},
+		cli.BoolFlag{
+			Name:   "no-progress",
+			Usage:  "Do not show the progress of clones and downloads. Progress is shown on a single line on a terminal, and otherwise logged every 10s",
+			EnvVar: "HELMC_NO_PROGRESS",
+		},
+		cli.BoolFlag{
+			Name:   "no-truncate",
+			Usage:  "Print whole lines on a terminal. By default, the tables and descriptions of list, search, and status are cut to its width",
+			EnvVar: "HELMC_NO_TRUNCATE",
+		},
+		cli.StringFlag{
+			Name:  "output, o",
+			Usage: "Print the results of list, search, info, status, history, repo list, and audit tail as 'json' or 'yaml', instead of tables. The --output of a command wins over it",
+		},
+		cli.GenericFlag{
+			Name:   "trace-git",
+			Value:  traceGit,
+			Usage:  "Log every git command, its progress, and how long it took. Give it twice, or as --trace-git=2, to turn on GIT_TRACE and GIT_CURL_VERBOSE too",
+			EnvVar: "HELMC_TRACE_GIT",
+		},
+		cli.StringFlag{
+			Name:   "git-backend",
+			Value:  config.BackendExec,
+			Usage:  "How to work with Git repositories: 'exec' runs git, 'native' uses a Git implementation built into helmc. Repositories added with --git-backend keep their own",
+			EnvVar: "HELMC_GIT_BACKEND",
+		},
+		cli.StringFlag{
+			Name:   "profile",
+			Usage:  "The profile of the configuration whose namespace, kubeconfig, and context are the defaults. See 'helmc config profiles'",
+			EnvVar: "HELMC_PROFILE,HELM_PROFILE",
+		},
+		cli.DurationFlag{
+			Name:  "timeout",
+			Usage: "How long the whole command may run, such as 10m. When it is up, or on Ctrl-C, external commands are killed, and half-done work is undone. By default, there is no bound",
+		},
+		cli.DurationFlag{
+			Name:  "git-timeout",
+			Value: config.DefaultGitTimeout,
+			Usage: "How long each git clone or fetch may take. Zero is no bound",
+		},
+		cli.DurationFlag{
+			Name:  "kube-timeout",
+			Value: kubectl.DefaultTimeout,
+			Usage: "How long each Kubernetes request, retries included, may take. Zero is no bound",
+		},
+		cli.StringFlag{
+			Name:   "events-file",
+			Usage:  "Append each event of fetch, generate, install, and the other operations, such as a generator that ran or a resource that was created, to this file, as a line of JSON",
+			EnvVar: eventsFileEnvVar,
+		},
+		cli.StringFlag{
+			Name:   "events-webhook",
+			Usage:  "Post each event, as --events-file writes it, to this URL",
+			EnvVar: "HELMC_EVENTS_WEBHOOK",
+		},
+		cli.BoolFlag{
+			Name:   "timings",
+			Usage:  "When the command ends, print to stderr how long each phase took: each repository update, operation, generator, and Kubernetes request",
+			EnvVar: "HELMC_TIMINGS",
+		},
+		cli.StringFlag{
+			Name:   "metrics-file",
+			Usage:  "When the command ends, write the time that each phase took to this file, in the text format of Prometheus",
+			EnvVar: "HELMC_METRICS_FILE",
+		},
 	}
 
 	app.Commands = []cli.Command{
+		applyPlanCmd,
+		auditCmd,
+		completionCmd(Cli),
+		configCmd,
 		createCmd,
+		depsCmd,
+		diffCmd,
+		diffLocalCmd,
 		doctorCmd,
 		editCmd,
+		exportCmd,
 		fetchCmd,
+		historyCmd,
 		homeCmd,
+		importCmd,
 		infoCmd,
 		installCmd,
 		lintCmd,
 		listCmd,
+		packageCmd,
+		pluginsCmd,
+		preflightCmd,
+		pruneCmd,
 		publishCmd,
+		reinstallCmd,
 		removeCmd,
+		renderCmd,
 		repositoryCmd,
+		rollbackCmd,
 		searchCmd,
+		secretCmd,
+		selfUpdateCmd,
+		signCmd,
+		stackCmd,
+		statusCmd,
 		targetCmd,
+		testCmd,
 		uninstallCmd,
 		updateCmd,
+		upgradeCmd,
+		verifyCmd,
+		versionCmd,
+		workspaceCmd,
 		generateCmd,
 		tplCmd,
+		docsCmd(Cli),
 	}
+	addHomeFlag(app.Commands)
 
 	app.CommandNotFound = func(c *cli.Context, command string) {
-		if action.HasPlugin(command) {
+		if action.HasPlugin(home(c), command) {
 			action.Plugin(home(c), command, c.Args())
 			return
 		}
@@ -96,7 +284,60 @@ func Cli() *cli.App {
 	}
 
 	app.Before = func(c *cli.Context) error {
+		failed, lastChart = nil, ""
+		metrics.Reset()
+		if err := useErrorFormat(c.String("error-format")); err != nil {
+			return err
+		}
+		log.AtExit = atExit
+		timings, metricsFile = c.Bool("timings"), c.String("metrics-file")
 		log.IsDebugging = c.Bool("debug")
+		helm.HandleInterrupts()
+		helm.SetTimeout(c.Duration("timeout"))
+		resolvedHome = ""
+		action.Defaults.Offline = c.Bool("offline")
+		action.Defaults.TraceGit = int(*traceGit)
+		action.Defaults.GitBackend = c.String("git-backend")
+		action.Defaults.GitTimeout = c.Duration("git-timeout")
+		action.Defaults.AllowGenerators = strings.FieldsFunc(c.String("allow-generators"), func(r rune) bool { return r == ',' || r == ' ' })
+		if err := useEventsFile(c.String("events-file")); err != nil {
+			return err
+		}
+		action.Defaults.EventsWebhook = c.String("events-webhook")
+		helm.ProgressMode = progressMode(c.Bool("no-progress"))
+		output.NoTruncate = c.Bool("no-truncate")
+		output.Format = c.String("output")
+		if err := output.CheckFormat(output.Format); err != nil {
+			return err
+		}
+		if err := config.CheckGitBackend(action.Defaults.GitBackend); err != nil {
+			return err
+		}
+		name := c.String("client")
+		if c.Bool("use-kubectl") {
+			name = kubectl.ClientExec
+		}
+		client, err := kubectl.NewClient(name)
+		if err != nil {
+			return err
+		}
+		kubectl.Client = client
+		kubectl.Defaults.Retry.Retries = c.Int("retries")
+		kubectl.Defaults.Retry.MaxBackoff = c.Duration("retry-backoff")
+		kubectl.Defaults.Timeout = c.Duration("kube-timeout")
+		kubectl.Defaults.Path = kubectlPath(c)
+		kubectl.Defaults.UserArgs = kubectlArgs(c)
+		if _, native := client.(*kubectl.NativeRunner); native && (len(kubectl.Defaults.UserArgs.Apply) > 0 || len(kubectl.Defaults.UserArgs.Delete) > 0) {
+			log.Warn("The kubectl arguments of the configuration are only given to kubectl. Use --use-kubectl to run it.")
+		}
+		kubectl.Defaults.Kubeconfig = c.String("kubeconfig")
+		kubectl.Defaults.Context = c.String("kube-context")
+		kubectl.Defaults.Cluster = c.String("cluster")
+		kubectl.Defaults.User = c.String("user")
+		if err := useProfile(c); err != nil {
+			return err
+		}
+		warnShadowedPlugin(c)
 		return nil
 	}
 
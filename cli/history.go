@@ -0,0 +1,35 @@
+package cli
+
+import (
+	"github.com/codegangsta/cli"
+	"github.com/helm/helm-classic/action"
+)
+
+const historyDescription = `List the revisions of the release of a chart in your workspace.
+
+Every install of a chart that reaches Kubernetes, whether it succeeds or
+fails, and every rollback, is a revision of its release, numbered from 1:
+when it ran, the chart's name, version, and digest, the namespace, what made
+it, and whether it succeeded. Each revision keeps the manifests that were
+sent, as they were sent, so that 'helmc rollback' can send them again.
+
+The revisions are kept in the releases directory of the home directory, one
+file per revision, which only the owner may read, since the manifests may
+hold secrets. Dry runs are not recorded.`
+
+var historyCmd = cli.Command{
+	Name:        "history",
+	Usage:       "List the revisions of the release of a chart.",
+	Description: historyDescription,
+	ArgsUsage:   "[chart-name]",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "output,o",
+			Usage: "Print the revisions as 'json' or 'yaml' instead of a table.",
+		},
+	},
+	Action: func(c *cli.Context) {
+		minArgs(c, 1, "history")
+		die(action.History(chartName(c, c.Args()[0], workspaceChart), home(c), outputFormat(c)))
+	},
+}
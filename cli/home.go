@@ -2,6 +2,7 @@ package cli
 
 import (
 	"github.com/codegangsta/cli"
+	"github.com/helm/helm-classic/helmpath"
 	"github.com/helm/helm-classic/log"
 )
 
@@ -10,6 +11,21 @@ var homeCmd = cli.Command{
 	Usage:     "Displays the location of the Helm Classic home.",
 	ArgsUsage: "",
 	Action: func(c *cli.Context) {
-		log.Msg(home(c))
+		h := helmpath.Home(home(c))
+		if !c.Bool("paths") {
+			log.Msg(h.String())
+			return
+		}
+		l := h.Layout()
+		log.Msg("home: %s", h)
+		log.Msg("config: %s", l.Config)
+		log.Msg("cache: %s", l.Cache)
+		log.Msg("workspace: %s", l.Workspace)
+	},
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "paths",
+			Usage: "Also print where the configuration file, the cache, and the workspace are, which may be outside the home.",
+		},
 	},
 }
@@ -0,0 +1,56 @@
+package cli
+
+import (
+	"github.com/codegangsta/cli"
+	"github.com/helm/helm-classic/action"
+	"github.com/helm/helm-classic/kubectl"
+)
+
+const importDescription = `This creates the chart 'chart-name' in your workspace from resources that
+are already in Kubernetes: those of the namespace that match the label
+selector given with '--selector'.
+
+Each resource is written to its own manifest, without the fields that
+Kubernetes sets itself, such as status, uid, resourceVersion, and
+creationTimestamp, nor those that only hold defaults. Endpoints, Events,
+service account tokens, and resources owned by a controller, such as the pods
+of a Deployment, are skipped. Every manifest is given the label
+'heritage: helm'.
+
+By default, the kinds that 'helmc install' knows are looked for. Use '--kinds'
+to choose others.
+
+With '--adopt', the new chart is then installed with 'kubectl apply', so that
+the live resources get its labels and annotations, and 'helmc status', 'helmc
+list --installed', and 'helmc uninstall' work with them at once.
+`
+
+var importCmd = cli.Command{
+	Name:        "import",
+	Usage:       "Create a chart from resources in Kubernetes.",
+	Description: importDescription,
+	ArgsUsage:   "[chart-name]",
+	Action: func(c *cli.Context) {
+		minArgs(c, 1, "import")
+		die(action.Import(c.Args()[0], home(c), namespace(c), c.String("selector"), c.StringSlice("kinds"), c.Bool("adopt"), kubectl.Client))
+	},
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "selector, l",
+			Usage: "The label selector of the resources to import, such as 'app=redis'. Required.",
+		},
+		cli.StringFlag{
+			Name:  "namespace, n",
+			Value: "",
+			Usage: "The Kubernetes namespace to import from.",
+		},
+		cli.StringSliceFlag{
+			Name:  "kinds",
+			Usage: "A kind of resource to import. May be given more than once. Defaults to the kinds that 'helmc install' knows.",
+		},
+		cli.BoolFlag{
+			Name:  "adopt",
+			Usage: "Install the new chart with 'kubectl apply', so that the live resources belong to it.",
+		},
+	},
+}
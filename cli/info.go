@@ -14,9 +14,13 @@ var infoCmd = cli.Command{
 			Name:  "format",
 			Usage: "Print using a Go template",
 		},
+		cli.BoolFlag{
+			Name:  "manifest-summary",
+			Usage: "Also list the resources that the chart creates: their kinds, names, images, and what they require of the cluster.",
+		},
 	},
 	Action: func(c *cli.Context) {
 		minArgs(c, 1, "info")
-		action.Info(c.Args()[0], home(c), c.String("format"))
+		action.Info(chartName(c, c.Args()[0], repoChart), home(c), c.String("format"), outputFormat(c), c.Bool("manifest-summary"))
 	},
 }
@@ -1,8 +1,12 @@
 package cli
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/codegangsta/cli"
 	"github.com/helm/helm-classic/action"
+	"github.com/helm/helm-classic/config"
 	"github.com/helm/helm-classic/kubectl"
 )
 
@@ -11,8 +15,124 @@ will be uploaded into Kubernetes. If no chart named 'chart-name' is found in
 your workspace, Helm Classic will look for a chart with that name, install it into the
 workspace, and then immediately upload it to Kubernetes.
 
+A 'chart-name' that starts with './' or '../', or an absolute path, is a
+directory, which must be a chart of your workspace. A directory named like the
+chart in the current directory is never used. A file ending in '.tgz' is a
+chart archive, as 'helmc package' writes it, which is expanded into the
+workspace under the name in its Chart.yaml, and installed from there. Run
+with --debug to see how each name was resolved.
+
+With '--from-bundle FILE', the charts of a bundle that 'helmc export' wrote
+are first added to the workspace, after they are checked against the digests
+that the bundle records, and the chart of the bundle is installed, unless
+other charts are given. Nothing is fetched, so this works on a machine that
+cannot reach the repositories. A chart of the workspace that differs from
+that of the bundle is only replaced with '--force'. Since the bundle holds
+what the generators wrote when it was exported with '--generate', install it
+without '--generate'.
+
 When multiple charts are specified, Helm Classic will attempt to install all of them,
 following the resolution process described above.
+
+'--env staging' selects the staging profile, as the global '--profile'
+does, and merges the chart's values-staging.yaml, if it has one, over its
+values.yaml for its templates, before the files of '--values'. An environment
+thus gives both the cluster and the values that one workspace installs into
+it.
+
+The namespace is that of '--namespace', or of the profile, or else the
+'namespace' of the chart's Chart.yaml, if it has one. Otherwise, kubectl
+chooses. A manifest that names its own namespace in metadata.namespace keeps
+it. With '--inject-namespace', the namespace is also written into each
+manifest that has none, except those of cluster-scoped kinds such as
+Namespace, so that a '--plan' or a '--dry-run' shows where each resource
+goes.
+
+By default, each manifest is sent with 'kubectl create', and resources that
+already exist are reported without stopping the install. Use '--mode apply' to
+create or update resources, or '--mode replace' to replace existing ones. With
+'--atomic', the install stops at the first failure and deletes the resources
+it created.
+
+Before anything is changed, the preflight checks of 'helmc preflight' are
+run, and every finding is reported. If one is an error, nothing is installed.
+Use '--skip-preflight' to install without them. A '--dry-run' skips them too.
+
+With '--generate', the templates of the chart are rendered with its
+values.yaml, merged with the files of '--values' (-f), in order, and then the
+KEY=VALUE values of '--set', as in '--set image.tag=1.10'. The other
+generators are given the same values in $HELM_VALUES and the HELM_VALUE_*
+variables. See 'helmc help generate'.
+
+Templates can also read values from the environment, files, or commands with
+'--set-from', as in '--set-from db.password=env:DB_PASSWORD', which wins over
+the others. See 'helmc help template'. The generator runs before anything is
+sent to Kubernetes, so a source that cannot be read stops the install first.
+The values files and '--set' are recorded; only the redacted sources, such as
+'db.password=env:<redacted>', are.
+
+With '--wait', the install then waits, for up to '--timeout', for the
+Deployments, ReplicationControllers, ReplicaSets, StatefulSets, and
+DaemonSets it sent to have their replicas ready, as 'helmc status' reports
+them. If some are not ready in time, they are listed with what they are
+waiting for, and helmc exits with status 9. They are left installed.
+
+With '--stateless', the chart is fetched into a temporary workspace, generated
+and installed from there, and the temporary workspace is removed, so that your
+workspace is neither read nor changed: a chart of your workspace with the same
+name is not used. This suits CI jobs, which install a chart of a repository,
+such as 'charts/redis', or an archive, with '--set' and '--values' for their
+templates. The repository caches and the configuration of your home are used,
+and the install is still recorded in the audit log and the release history.
+Since the chart is not kept, use '--deps' if it has dependencies.
+
+With '--prune', the resources that an earlier version of the chart installed,
+and that it no longer has, are deleted after the install, as by 'helmc prune'.
+With '--dry-run', they are only listed.
+
+With '--plan FILE', nothing is installed. Instead, the chart is fetched and
+generated as for an install, and the plan of the install is written to FILE:
+the chart and its digest, the kubeconfig context, the settings of the flags,
+and each operation, in order, with the manifest it sends. Once the plan has
+been reviewed, 'helmc apply-plan FILE' runs it as it is. The manifests of a
+plan are rendered, so they hold any values that '--set-from' read.
+
+A chart that its author marked as deprecated is installed with a warning. If
+fetch.strict is set in the configuration, it is refused unless
+'--accept-deprecated' is given.
+
+A chart whose dependencies the workspace does not satisfy is not installed,
+unless '--force' is given. '--deps' fetches them first, as 'helmc fetch
+--deps' does.
+
+With '--checksum', the chart is only installed if its content is what was
+reviewed: its checksum, which 'helmc fetch --print-checksum' prints, must be
+the one given, as in '--checksum sha256:3f2a...'. On a mismatch, both
+checksums are printed and nothing is installed. The checksum is computed over
+the chart in the workspace, after it is fetched and before the generators of
+'--generate' run, so files that an earlier generate left in the chart count.
+It can only be given with a single chart.
+
+With '--verify', a chart that install fetches, from a repository or an
+archive, is first checked against its provenance file and the keys of
+'--keyring', as 'helmc fetch --verify' does. A chart that is already in the
+workspace cannot be verified, and is not installed.
+
+Manifests with a 'helm.sh/hook: pre-install' annotation are applied before
+the others, and those with 'helm.sh/hook: post-install' after them. A hook Job
+or Pod is waited on until it completes, and then deleted, unless it is a
+keeper. A hook that fails stops the install, with its logs, and is left in
+the cluster. With '--dry-run', the commands of the hooks are printed apart.
+
+Before anything is sent to Kubernetes, the manifests are checked against
+limits on their number, on the size of each one, and on their total size, so
+that a generator that goes wrong cannot flood the cluster. The defaults, 1000
+manifests of at most 2048 KiB and 64 MiB in all, are far above what a chart
+needs. A chart over a limit is refused, and the error names the flag that
+raises it: '--max-documents', '--max-document-size' (in KiB), or
+'--max-total-size' (in MiB). The install.maxDocuments, install.maxDocumentKB,
+and install.maxTotalMB keys of the configuration set them for every install,
+and a negative value removes a limit. 'helmc lint' checks the same limits.
 `
 
 var installCmd = cli.Command{
@@ -25,38 +145,279 @@ var installCmd = cli.Command{
 		cli.StringFlag{
 			Name:  "namespace, n",
 			Value: "",
-			Usage: "The Kubernetes destination namespace.",
+			Usage: "The Kubernetes destination namespace. It defaults to the namespace of the chart's Chart.yaml.",
+		},
+		cli.BoolFlag{
+			Name:  "inject-namespace",
+			Usage: "Write the namespace into the manifests that have no metadata.namespace.",
 		},
 		cli.BoolFlag{
 			Name:  "force, aye-aye",
 			Usage: "Perform install even if dependencies are unsatisfied.",
 		},
-		cli.BoolFlag{
+		cli.GenericFlag{
 			Name:  "dry-run",
-			Usage: "Fetch the chart, but only display the underlying kubectl commands.",
+			Value: new(dryRunMode),
+			Usage: "Fetch the chart, but only display the underlying kubectl commands. With --dry-run=server, send the manifests to Kubernetes for validation without persisting them.",
 		},
 		cli.BoolFlag{
 			Name:  "generate,g",
 			Usage: "Run the generator before installing.",
 		},
+		cli.BoolFlag{
+			Name:  "skip-schema",
+			Usage: "With --generate, render templates without validating their values against the chart's values schema.",
+		},
 		cli.StringSliceFlag{
 			Name:  "exclude,x",
 			Usage: "Files or directories to exclude from the generator (if -g is set).",
 		},
+		cli.StringSliceFlag{
+			Name:  "values,f",
+			Usage: "Merge a values file over the chart's values.yaml for its templates. Can be given more than once; later files win.",
+		},
+		cli.StringSliceFlag{
+			Name:  "set",
+			Usage: "Set a value of the templates, as KEY=VALUE, such as image.tag=1.2. Wins over the values files. Can be given more than once.",
+		},
+		cli.StringSliceFlag{
+			Name:  "set-from",
+			Usage: "Set a value of the templates from a source, as KEY=env:NAME, KEY=file:PATH, KEY=cmd:COMMAND, KEY=pass:NAME, or KEY=sops:PATH[#KEY] (if -g is set). Can be given more than once.",
+		},
+		cli.BoolFlag{
+			Name:  "allow-exec-values",
+			Usage: "Allow the cmd:, pass:, and sops: value sources, which run a command.",
+		},
+		envFlag,
+		cli.StringFlag{
+			Name:  "mode",
+			Value: action.ModeCreate,
+			Usage: "How to send manifests to Kubernetes: 'create' fails for resources that already exist, 'apply' creates or updates them, and 'replace' replaces resources that must already exist.",
+		},
+		cli.BoolFlag{
+			Name:  "no-annotations",
+			Usage: "Do not annotate resources with the chart's name, version, and digest.",
+		},
+		cli.BoolFlag{
+			Name:  "no-labels",
+			Usage: "Do not label resources with heritage=helm-classic and chart=NAME-VERSION.",
+		},
+		cli.BoolFlag{
+			Name:  "atomic",
+			Usage: "Stop at the first resource that fails, after its retries, and delete the resources that were created.",
+		},
+		cli.StringFlag{
+			Name:  "plan",
+			Usage: "Write what the install would do to this file, as JSON, and change nothing. Run it with 'helmc apply-plan'.",
+		},
+		cli.StringFlag{
+			Name:  "output,o",
+			Usage: "Format of the install summary. Use 'json' for machine-readable output.",
+		},
+		cli.BoolFlag{
+			Name:  "skip-preflight",
+			Usage: "Install without running the preflight checks first.",
+		},
+		cli.BoolFlag{
+			Name:  "wait",
+			Usage: "Wait for the chart's workloads to have their replicas ready before exiting.",
+		},
+		cli.DurationFlag{
+			Name:  "timeout",
+			Value: 5 * time.Minute,
+			Usage: "How long to wait for the workloads to be ready, with --wait.",
+		},
+		cli.BoolFlag{
+			Name:  "stateless",
+			Usage: "Fetch the chart into a temporary workspace and install it from there, leaving your workspace alone.",
+		},
+		cli.BoolFlag{
+			Name:  "prune",
+			Usage: "After the install, delete the resources of the chart that it no longer has. See 'helmc help prune'.",
+		},
+		cli.BoolFlag{
+			Name:  "yes, y",
+			Usage: "With --prune, do not ask for confirmation.",
+		},
+		cli.BoolFlag{
+			Name:  "accept-deprecated",
+			Usage: "Install a deprecated chart even if fetch.strict is set.",
+		},
+		cli.BoolFlag{
+			Name:  "deps",
+			Usage: "Fetch the dependencies of the chart that the workspace is missing, and theirs, before installing it.",
+		},
+		cli.StringFlag{
+			Name:  "checksum",
+			Usage: "Install the chart only if it has this checksum, as 'helmc fetch --print-checksum' prints it.",
+		},
+		cli.IntFlag{
+			Name:  "max-documents",
+			Usage: "The most manifests that the chart may install. Overrides install.maxDocuments; a negative number is no limit.",
+		},
+		cli.IntFlag{
+			Name:  "max-document-size",
+			Usage: "The largest manifest that the chart may install, in KiB. Overrides install.maxDocumentKB; a negative number is no limit.",
+		},
+		cli.IntFlag{
+			Name:  "max-total-size",
+			Usage: "The total size of the manifests that the chart may install, in MiB. Overrides install.maxTotalMB; a negative number is no limit.",
+		},
+		cli.StringFlag{
+			Name:  "from-bundle",
+			Usage: "Add the charts of a bundle of 'helmc export' to the workspace, and install its chart, or the charts given, fetching nothing.",
+		},
+		verifyFlag,
+		keyringFlag,
 	},
 }
 
 func install(c *cli.Context) {
-	minArgs(c, 1, "install")
+	args := c.Args()
+	bundle := c.String("from-bundle")
+	if bundle == "" {
+		minArgs(c, 1, "install")
+	}
+	die(useEnv(c))
 	h := home(c)
 	force := c.Bool("force")
+	if bundle != "" {
+		if c.Bool("stateless") || c.Bool("deps") || c.Bool("verify") {
+			die(fmt.Errorf("--from-bundle installs what the bundle has, in your workspace, so it cannot be given with --stateless, --deps, or --verify"))
+		}
+		name, err := action.ImportBundle(bundle, h, force)
+		die(err)
+		if len(args) == 0 {
+			args = cli.Args{name}
+		}
+	}
 
 	client := kubectl.Client
-	if c.Bool("dry-run") {
+	mode := *c.Generic("dry-run").(*dryRunMode)
+	if mode == dryRunClient {
 		client = kubectl.PrintRunner{}
 	}
 
-	for _, chart := range c.Args() {
-		action.Install(chart, h, c.String("namespace"), force, c.Bool("generate"), c.StringSlice("exclude"), client)
+	ns := namespace(c)
+	if c.String("checksum") != "" && len(args) > 1 {
+		die(fmt.Errorf("--checksum is the checksum of a single chart. Install the charts one at a time"))
+	}
+	if plan := c.String("plan"); plan != "" {
+		if len(args) > 1 || mode != dryRunNone || c.Bool("prune") || c.Bool("wait") || c.Bool("stateless") {
+			die(fmt.Errorf("--plan takes a single chart, and no --dry-run, --prune, --wait, or --stateless"))
+		}
+		die(action.PlanInstall(chartName(c, args[0], installChart), h, plan, action.InstallOptions{
+			Namespace:  ns,
+			Force:      force,
+			Generate:   c.Bool("generate"),
+			SkipSchema: c.Bool("skip-schema"),
+			Exclude:    c.StringSlice("exclude"),
+			Mode:       c.String("mode"),
+			Atomic:     c.Bool("atomic"),
+			Annotate:   !c.Bool("no-annotations"),
+			Labels:     !c.Bool("no-labels"),
+			Values:     valueSources(c),
+			Checksum:   c.String("checksum"),
+			Limits:     installLimits(c),
+
+			AcceptDeprecated: c.Bool("accept-deprecated"),
+			Deps:             c.Bool("deps"),
+			VerifyKeyring:    verifyKeyring(c),
+			InjectNamespace:  c.Bool("inject-namespace"),
+		}))
+		return
+	}
+	prune, stateless := c.Bool("prune"), c.Bool("stateless")
+	lookup := installChart
+	if stateless {
+		if prune {
+			die(fmt.Errorf("--prune deletes what a chart of your workspace no longer has, so it cannot be given with --stateless"))
+		}
+		lookup = fetchChart
+	}
+	opts := action.InstallOptions{
+		Namespace:  ns,
+		Force:      force,
+		Generate:   c.Bool("generate"),
+		SkipSchema: c.Bool("skip-schema"),
+		Exclude:    c.StringSlice("exclude"),
+		Output:     c.String("output"),
+		Mode:       c.String("mode"),
+		Atomic:     c.Bool("atomic"),
+		Annotate:   !c.Bool("no-annotations"),
+		Labels:     !c.Bool("no-labels"),
+		Preflight:  !c.Bool("skip-preflight"),
+		Values:     valueSources(c),
+		Checksum:   c.String("checksum"),
+		Limits:     installLimits(c),
+		Stateless:  stateless,
+
+		AcceptDeprecated: c.Bool("accept-deprecated"),
+		Deps:             c.Bool("deps"),
+		VerifyKeyring:    verifyKeyring(c),
+		InjectNamespace:  c.Bool("inject-namespace"),
+	}
+	if c.Bool("wait") {
+		opts.Wait = c.Duration("timeout")
+	}
+	for _, arg := range args {
+		chart := chartName(c, arg, lookup)
+		if prune && action.ChartNamespace(h, chart, ns) == "" {
+			die(fmt.Errorf("--prune requires a namespace. Did you mean '-n default'?"))
+		}
+		if mode == dryRunServer {
+			die(action.DryRunInstall(chart, h, opts, client))
+		} else {
+			die(action.Install(chart, h, opts, client))
+		}
+		if prune {
+			// A dry run only lists the orphans, which reads the cluster.
+			die(action.Prune(chart, h, ns, action.PruneOptions{DryRun: mode != dryRunNone, Yes: c.Bool("yes")}, kubectl.Client))
+		}
+	}
+}
+
+// dryRunMode is the value of --dry-run.
+//
+// It acts as a boolean flag, so that a bare --dry-run still prints the
+// kubectl commands, but also accepts --dry-run=server.
+type dryRunMode string
+
+const (
+	dryRunNone   dryRunMode = ""
+	dryRunClient dryRunMode = "client"
+	dryRunServer dryRunMode = "server"
+)
+
+func (d *dryRunMode) Set(v string) error {
+	switch v {
+	case "true", "client":
+		*d = dryRunClient
+	case "false":
+		*d = dryRunNone
+	case "server":
+		*d = dryRunServer
+	default:
+		return fmt.Errorf("--dry-run must be 'client' or 'server', not %q", v)
+	}
+	return nil
+}
+
+func (d *dryRunMode) String() string {
+	return string(*d)
+}
+
+// IsBoolFlag allows --dry-run to be given without a value.
+func (d *dryRunMode) IsBoolFlag() bool {
+	return true
+}
+
+// installLimits returns the limits of the manifests that the flags of
+// install give.
+func installLimits(c *cli.Context) config.Install {
+	return config.Install{
+		MaxDocuments:  c.Int("max-documents"),
+		MaxDocumentKB: c.Int("max-document-size"),
+		MaxTotalMB:    c.Int("max-total-size"),
 	}
 }
@@ -1,24 +1,72 @@
 package cli
 
 import (
-	"os"
-	"path/filepath"
-
 	"github.com/codegangsta/cli"
 	"github.com/helm/helm-classic/action"
-	"github.com/helm/helm-classic/util"
 )
 
+const lintDescription = `Check that a chart is well-formed.
+
+The chart is a chart of the workspace, or any chart directory. A name that is
+both, such as 'redis' where './redis' has a Chart.yaml, is an error that says
+how to give each one: './redis' for the directory, or the workspace chart's
+full path.
+
+The contents of Chart.yaml are checked against a lint policy. If the chart or
+the Helm Classic home contains a 'lint-policy.yaml' file, its rules are used
+instead of the default rules. See docs/authoring_charts.md for the format.
+
+If the chart has a values schema, in a 'values.schema.yaml', or as the
+'valuesSchema' or 'valueTypes' of its Chart.yaml, the schema is checked, and
+so is the chart's 'values.yaml', if it has one, against the schema.
+
+Every manifest is checked against the OpenAPI schema of its kind, as given by
+the --kube-version release of Kubernetes. Unknown fields and values of the
+wrong type are errors, which give the path of the field, such as
+'spec.template.spec.containers[0].image'. The schemas are downloaded the first
+time, and cached in the Helm Classic home, so lint works offline afterwards.
+The release may be given as 1.21, which is v1.21.0.
+
+A kind of Kubernetes itself that the release does not serve as the
+manifest's apiVersion, such as extensions/v1beta1 Deployment in 1.16 and
+later, or a misspelled kind, is an error, which says what apiVersions the
+kind is served as. Custom kinds that have no schema are noted, and not
+checked.
+
+The schemas of custom kinds are read from the --schema-dir directory, which
+may hold CustomResourceDefinitions, as YAML or JSON, and Swagger documents,
+such as the output of 'kubectl get --raw /openapi/v2'.
+
+The containers of Pods, and of the pod templates of ReplicationControllers,
+Deployments, DaemonSets, Jobs and the like, are checked for resources: a
+container without 'resources.requests', a limit lower than its request, and,
+if the lint policy has 'resources.max', a value above the maximum are
+reported with the file, the container, and the field. These are warnings
+unless the policy says 'level: error'. If the chart has generators, they are
+run in a temporary copy of the chart first, so that the manifests of its
+templates are checked; the chart itself is not changed.
+`
+
 var lintCmd = cli.Command{
-	Name:      "lint",
-	Usage:     "Validates given chart",
-	ArgsUsage: "[chart-name]",
-	Action:    lint,
+	Name:        "lint",
+	Usage:       "Validates given chart",
+	Description: lintDescription,
+	ArgsUsage:   "[chart-name]",
+	Action:      lint,
 	Flags: []cli.Flag{
 		cli.BoolFlag{
 			Name:  "all",
 			Usage: "Check all available charts",
 		},
+		cli.StringFlag{
+			Name:  "schema-dir",
+			Usage: "Read the schemas of custom kinds from this directory",
+		},
+		cli.StringFlag{
+			Name:  "kube-version",
+			Value: action.DefaultKubeVersion,
+			Usage: "Check manifests against the schemas of this Kubernetes release",
+		},
 	},
 }
 
@@ -26,25 +74,14 @@ func lint(c *cli.Context) {
 	home := home(c)
 
 	all := c.Bool("all")
+	opts := action.LintOptions{SchemaDir: c.String("schema-dir"), KubeVersion: c.String("kube-version")}
 
 	if all {
-		action.LintAll(home)
+		die(action.LintAll(home, opts))
 		return
 	}
 
 	minArgs(c, 1, "lint")
 
-	a := c.Args()
-	chartNameOrPath := a[0]
-
-	fromHome := util.WorkspaceChartDirectory(home, chartNameOrPath)
-	fromAbs := filepath.Clean(chartNameOrPath)
-
-	_, err := os.Stat(fromAbs)
-
-	if err == nil {
-		action.Lint(fromAbs)
-	} else {
-		action.Lint(fromHome)
-	}
+	die(action.Lint(chartArg(c, c.Args()[0], lintChart).Dir, home, opts))
 }
@@ -10,6 +10,11 @@ import (
 	"github.com/helm/helm-classic/util"
 )
 
+func init() {
+	// Never download Kubernetes schemas.
+	action.KubeSchemaURL = ""
+}
+
 func TestLintAllNone(t *testing.T) {
 	tmpHome := test.CreateTmpHome()
 	test.FakeUpdate(tmpHome)
@@ -26,7 +31,7 @@ func TestLintSingle(t *testing.T) {
 	test.FakeUpdate(tmpHome)
 
 	chartName := "goodChart"
-	action.Create(chartName, tmpHome)
+	action.Create(chartName, tmpHome, "")
 
 	output := test.CaptureOutput(func() {
 		Cli().Run([]string{"helmc", "--home", tmpHome, "lint", chartName})
@@ -40,7 +45,7 @@ func TestLintChartByPath(t *testing.T) {
 	home2 := test.CreateTmpHome()
 
 	chartName := "goodChart"
-	action.Create(chartName, home1)
+	action.Create(chartName, home1, "")
 
 	output := test.CaptureOutput(func() {
 		Cli().Run([]string{"helmc", "--home", home2, "lint", util.WorkspaceChartDirectory(home1, chartName)})
@@ -55,10 +60,10 @@ func TestLintAll(t *testing.T) {
 
 	missingReadmeChart := "missingReadme"
 
-	action.Create(missingReadmeChart, tmpHome)
+	action.Create(missingReadmeChart, tmpHome, "")
 	os.Remove(util.WorkspaceChartDirectory(tmpHome, missingReadmeChart, "README.md"))
 
-	action.Create("goodChart", tmpHome)
+	action.Create("goodChart", tmpHome, "")
 
 	output := test.CaptureOutput(func() {
 		Cli().Run([]string{"helmc", "--home", tmpHome, "lint", "--all"})
@@ -3,10 +3,18 @@ package cli
 import (
 	"github.com/codegangsta/cli"
 	"github.com/helm/helm-classic/action"
+	"github.com/helm/helm-classic/kubectl"
 )
 
 const listDescription = `This prints all of the packages that are currently installed in
 the workspace. Packages are printed by the local name.
+
+With '--installed', the version of each package that is installed in
+Kubernetes is printed too, and whether it is 'current' (the same as the
+workspace copy) or 'drifted'. The resources of the namespace that
+'helmc install' labeled 'heritage=helm-classic' are listed as well, and the
+charts and versions of those that are not in the workspace are printed, with
+how many resources each has.
 `
 
 var listCmd = cli.Command{
@@ -16,6 +24,21 @@ var listCmd = cli.Command{
 	Description: listDescription,
 	ArgsUsage:   "",
 	Action: func(c *cli.Context) {
-		action.List(home(c))
+		var client kubectl.Runner
+		if c.Bool("installed") {
+			client = kubectl.Client
+		}
+		action.List(home(c), c.String("namespace"), outputFormat(c), client)
+	},
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "installed",
+			Usage: "Show the version of each package that is installed in Kubernetes.",
+		},
+		cli.StringFlag{
+			Name:  "namespace, n",
+			Value: "",
+			Usage: "The Kubernetes namespace to look in, with --installed.",
+		},
 	},
 }
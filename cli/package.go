@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"github.com/codegangsta/cli"
+	"github.com/helm/helm-classic/action"
+)
+
+const packageDescription = `Write an archive of a chart, to publish in an HTTP repository.
+
+The chart is a chart of the workspace, or any chart directory, as for
+'helmc lint'. The archive is named after the name and version in its
+Chart.yaml, such as 'redis-0.2.0.tgz', and is written into the current
+directory, or the --destination directory. An archive of the same name is
+replaced.
+
+The files of the chart are under a top-level directory named after the chart,
+with Chart.yaml first. Directories whose names start with '_' or '.', such as
+'.git', the files that the chart's .helmignore excludes, and files that are
+not regular files are left out. Packaging the same chart twice gives the same
+archive, and the same digest, which is printed last, as 'sha256:...'.
+
+'helmc repository index' builds the index of a directory of archives. An
+archive can also be given to 'helmc fetch' and 'helmc install' in place of
+the name of a chart, such as './redis-0.2.0.tgz'; it is expanded into the
+workspace under the name in its Chart.yaml.
+`
+
+var packageCmd = cli.Command{
+	Name:        "package",
+	Usage:       "Write a versioned archive of a chart.",
+	Description: packageDescription,
+	ArgsUsage:   "[chart-name]",
+	Action: func(c *cli.Context) {
+		minArgs(c, 1, "package")
+		die(action.Package(chartArg(c, c.Args()[0], lintChart).Dir, c.String("destination")))
+	},
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "destination, d",
+			Usage: "Write the archive into this directory, rather than the current one.",
+		},
+	},
+}
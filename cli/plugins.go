@@ -0,0 +1,57 @@
+package cli
+
+import (
+	"github.com/codegangsta/cli"
+	"github.com/helm/helm-classic/action"
+	"github.com/helm/helm-classic/helmpath"
+	"github.com/helm/helm-classic/log"
+)
+
+const pluginsDescription = `Plugins are executables named 'helm-NAME', and are run as 'helmc NAME'.
+
+They are looked for in the 'plugins' directory of your Helm Classic home, and
+then on $PATH. A plugin that has the name of a built-in command is never run.
+`
+
+var pluginsCmd = cli.Command{
+	Name:        "plugins",
+	Aliases:     []string{"plugin"},
+	Usage:       "Work with helmc plugins.",
+	Description: pluginsDescription,
+	Subcommands: []cli.Command{
+		{
+			Name:      "list",
+			Usage:     "List the plugins that can be run, and where they are.",
+			ArgsUsage: "",
+			Action: func(c *cli.Context) {
+				action.ListPlugins(home(c), builtinCommands(c.App.Commands))
+			},
+		},
+	},
+}
+
+// builtinCommands returns the names and aliases of commands.
+func builtinCommands(cmds []cli.Command) []string {
+	names := []string{}
+	for _, cmd := range cmds {
+		names = append(names, cmd.Name)
+		names = append(names, cmd.Aliases...)
+	}
+	return names
+}
+
+// warnShadowedPlugin warns if the command being run is built in, but a
+// plugin of the same name exists. The built-in command always wins.
+func warnShadowedPlugin(c *cli.Context) {
+	name := c.Args().First()
+	if name == "" || c.App.Command(name) == nil {
+		return
+	}
+	h, err := helmpath.Resolve(c.GlobalString("home"))
+	if err != nil {
+		return
+	}
+	if path := action.FindPlugin(h.String(), name); path != "" {
+		log.Warn("Plugin %s is shadowed by the built-in command '%s'. Running the built-in command.", path, name)
+	}
+}
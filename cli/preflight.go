@@ -0,0 +1,50 @@
+package cli
+
+import (
+	"github.com/codegangsta/cli"
+	"github.com/helm/helm-classic/action"
+	"github.com/helm/helm-classic/kubectl"
+)
+
+const preflightDescription = `This runs the checks that 'helmc install' runs before it changes anything,
+and changes nothing. It is meant for CI, to stop a change before it is
+deployed.
+
+The chart is linted, with its values schema; the kubeconfig, kubectl, and the
+kubectl flags of the chart's Chart.yaml are checked; and Kubernetes must be
+reachable. Then 'kubectl auth can-i' is asked whether each kind of the chart
+may be created in its namespace (or patched, with '--mode apply', or updated,
+with '--mode replace'), and each resource of the chart is looked up: one that
+another chart installed is an error, and one that helmc did not install is a
+warning.
+
+Every finding is reported, as a table, or as JSON with '--output json'. The
+command exits with status 8 if one is an error.
+`
+
+var preflightCmd = cli.Command{
+	Name:        "preflight",
+	Usage:       "Check that a chart can be installed, without installing it.",
+	Description: preflightDescription,
+	ArgsUsage:   "[chart-name]",
+	Action: func(c *cli.Context) {
+		minArgs(c, 1, "preflight")
+		die(action.Preflight(chartName(c, c.Args()[0], installChart), home(c), namespace(c), c.String("mode"), c.String("output"), kubectl.Client))
+	},
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "namespace, n",
+			Value: "",
+			Usage: "The Kubernetes namespace that the chart would be installed into.",
+		},
+		cli.StringFlag{
+			Name:  "mode",
+			Value: action.ModeCreate,
+			Usage: "The mode of the install to check: 'create', 'apply', or 'replace'.",
+		},
+		cli.StringFlag{
+			Name:  "output,o",
+			Usage: "Format of the findings. Use 'json' for machine-readable output.",
+		},
+	},
+}
@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/helm/helm-classic/action"
+	"github.com/helm/helm-classic/helmpath"
+	"github.com/helm/helm-classic/kubectl"
+	"github.com/helm/helm-classic/test"
+)
+
+func TestProfile(t *testing.T) {
+	tmpHome := test.CreateTmpHome()
+	defer os.RemoveAll(tmpHome)
+	test.FakeUpdate(tmpHome)
+	defer func() { kubectl.Defaults.Context, kubectl.Defaults.Kubeconfig, profile = "", "", nil }()
+	// The home of subcommands is that of the environment.
+	defer os.Setenv(helmpath.EnvVar, os.Getenv(helmpath.EnvVar))
+	os.Setenv(helmpath.EnvVar, tmpHome)
+
+	// An unknown profile fails before the command runs.
+	var err error
+	test.CaptureOutput(func() {
+		err = Cli().Run([]string{"helmc", "--profile", "prod", "config", "profiles"})
+	})
+	if err == nil || !strings.Contains(err.Error(), `Unknown profile "prod": no profiles are defined`) {
+		t.Errorf("Expected an unknown profile, got %v", err)
+	}
+
+	test.CaptureOutput(func() {
+		action.ConfigSet(tmpHome, "profiles.prod.namespace", "prod")
+		action.ConfigSet(tmpHome, "profiles.prod.kubeContext", "prod-cluster")
+		action.ConfigSet(tmpHome, "profiles.dev.namespace", "dev")
+	})
+	actual := test.CaptureOutput(func() {
+		err = Cli().Run([]string{"helmc", "--profile", "prod", "config", "profiles"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.ExpectContains(t, actual, "   dev   dev        -             -\n*  prod  prod       prod-cluster  -\n")
+	test.ExpectEquals(t, kubectl.Defaults.Context, "prod-cluster")
+	test.ExpectEquals(t, profile.Namespace, "prod")
+
+	// Flags take precedence over the profile.
+	test.CaptureOutput(func() {
+		Cli().Run([]string{"helmc", "--profile", "prod", "--kube-context", "mine", "config", "profiles"})
+	})
+	test.ExpectEquals(t, kubectl.Defaults.Context, "mine")
+
+	test.CaptureOutput(func() {
+		err = Cli().Run([]string{"helmc", "--profile", "staging", "config", "profiles"})
+	})
+	if err == nil || !strings.Contains(err.Error(), "The profiles are: dev, prod") {
+		t.Errorf("Expected the profiles to be listed, got %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+package cli
+
+import (
+	"github.com/codegangsta/cli"
+	"github.com/helm/helm-classic/action"
+	"github.com/helm/helm-classic/kubectl"
+)
+
+const pruneDescription = `Delete the resources that an earlier version of a chart installed, and that
+the chart in your workspace no longer has.
+
+'helmc install' labels each resource with the name of its chart, as
+'chart.helm.sh/name'. Prune lists the resources of the namespace with the
+label of 'chart-name', and those that the chart has no manifest for are
+orphans. A resource is matched by its kind, namespace, and name, so a renamed
+resource is an orphan: the old name is deleted, and the new one is installed.
+
+Orphans are listed, with the version of the chart that installed them, and
+deleted once you confirm, unless '--yes' is given. With '--dry-run', they are
+only listed, for review.
+
+Orphans with a 'helm.sh/resource-policy: keep' or 'helm-keep: "true"'
+annotation are not deleted, nor those whose name was generated, nor those a
+controller owns. Resources installed before helmc labeled them are not found;
+install the chart once with '--mode apply' to label them.
+
+'helmc install --prune' prunes each chart after it is installed.
+`
+
+var pruneCmd = cli.Command{
+	Name:        "prune",
+	Usage:       "Delete the resources that a chart no longer has.",
+	Description: pruneDescription,
+	ArgsUsage:   "[chart-name]",
+	Action: func(c *cli.Context) {
+		minArgs(c, 1, "prune")
+		die(action.Prune(chartName(c, c.Args()[0], workspaceChart), home(c), namespace(c), action.PruneOptions{
+			DryRun: c.Bool("dry-run"),
+			Yes:    c.Bool("yes"),
+		}, kubectl.Client))
+	},
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "namespace, n",
+			Value: "",
+			Usage: "The Kubernetes namespace of the chart. It defaults to the namespace of the chart's Chart.yaml.",
+		},
+		cli.BoolFlag{
+			Name:  "yes, aye-aye, y",
+			Usage: "Do not ask for confirmation.",
+		},
+		cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "List the resources that would be deleted, and delete nothing.",
+		},
+	},
+}
@@ -7,6 +7,9 @@ import (
 
 const publishDescription = `This copies a chart from the workdir into the cache. Doing so
 is the first stage of contributing a chart upstream.
+
+With --push, the chart is then committed in the cached copy of the Git
+repository, and pushed to the repository's remote.
 `
 
 var publishCmd = cli.Command{
@@ -16,7 +19,7 @@ var publishCmd = cli.Command{
 	ArgsUsage:   "[chart-name]",
 	Action: func(c *cli.Context) {
 		minArgs(c, 1, "publish")
-		action.Publish(c.Args()[0], home(c), c.String("repo"), c.Bool("force"))
+		action.Publish(chartName(c, c.Args()[0], workspaceChart), home(c), c.String("repo"), c.Bool("force"), c.Bool("push"))
 	},
 	Flags: []cli.Flag{
 		cli.BoolFlag{
@@ -27,5 +30,9 @@ var publishCmd = cli.Command{
 			Name:  "repo",
 			Usage: "Publish to a specific chart repository.",
 		},
+		cli.BoolFlag{
+			Name:  "push",
+			Usage: "Commit the chart and push it to the Git repository's remote.",
+		},
 	},
 }
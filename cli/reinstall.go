@@ -0,0 +1,154 @@
+package cli
+
+import (
+	"github.com/codegangsta/cli"
+	"github.com/helm/helm-classic/action"
+	"github.com/helm/helm-classic/kubectl"
+)
+
+const reinstallDescription = `Install a chart of your workspace again, with the parameters of its
+last install.
+
+Every install records its namespace, mode, and other options in the audit
+log. Reinstall replays those of the last install of 'chart-name', whether it
+succeeded or not, against the chart as it is now in your workspace. Any flag
+that is given replaces the recorded value: '--mode apply' reinstalls with
+'kubectl apply', and '--atomic=false' turns off a recorded '--atomic'.
+
+The values files of '--values', as absolute paths, and the values of '--set'
+are recorded, so the files are read again as they are now. The values
+that '--set-from' read are never recorded, nor are their sources, so give
+'--set-from' again to reinstall a chart that used it.
+
+With '--show', the parameters are printed as a 'helmc install' command, and
+nothing is installed.
+`
+
+var reinstallCmd = cli.Command{
+	Name:        "reinstall",
+	Usage:       "Install a chart again, with the parameters of its last install.",
+	Description: reinstallDescription,
+	ArgsUsage:   "[chart-name]",
+	Action:      reinstall,
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "show",
+			Usage: "Print the parameters as a 'helmc install' command, and install nothing.",
+		},
+		cli.StringFlag{
+			Name:  "namespace, n",
+			Usage: "The Kubernetes destination namespace, instead of the recorded one.",
+		},
+		cli.StringFlag{
+			Name:  "mode",
+			Usage: "How to send manifests to Kubernetes: 'create', 'apply', or 'replace', instead of the recorded mode.",
+		},
+		cli.BoolFlag{
+			Name:  "atomic",
+			Usage: "Stop at the first resource that fails, after its retries, and delete the resources that were created. Use --atomic=false to turn off a recorded --atomic.",
+		},
+		cli.BoolFlag{
+			Name:  "no-annotations",
+			Usage: "Do not annotate resources with the chart's name, version, and digest. Use --no-annotations=false to annotate them again.",
+		},
+		cli.BoolFlag{
+			Name:  "no-labels",
+			Usage: "Do not label resources with heritage=helm-classic and chart=NAME-VERSION. Use --no-labels=false to label them again.",
+		},
+		cli.BoolFlag{
+			Name:  "force, aye-aye",
+			Usage: "Perform install even if dependencies are unsatisfied.",
+		},
+		cli.BoolFlag{
+			Name:  "generate,g",
+			Usage: "Run the generator before installing.",
+		},
+		cli.BoolFlag{
+			Name:  "skip-schema",
+			Usage: "With --generate, render templates without validating their values against the chart's values schema.",
+		},
+		cli.StringSliceFlag{
+			Name:  "exclude,x",
+			Usage: "Files or directories to exclude from the generator, instead of the recorded ones.",
+		},
+		cli.StringSliceFlag{
+			Name:  "values,f",
+			Usage: "Merge a values file over the chart's values.yaml for its templates. Can be given more than once; later files win.",
+		},
+		cli.StringSliceFlag{
+			Name:  "set",
+			Usage: "Set a value of the templates, as KEY=VALUE, such as image.tag=1.2. Wins over the values files. Can be given more than once.",
+		},
+		cli.StringSliceFlag{
+			Name:  "set-from",
+			Usage: "Set a value of the templates from a source, as KEY=env:NAME, KEY=file:PATH, KEY=cmd:COMMAND, KEY=pass:NAME, or KEY=sops:PATH[#KEY]. The sources of the last install are not recorded, so give them again. Can be given more than once.",
+		},
+		cli.BoolFlag{
+			Name:  "allow-exec-values",
+			Usage: "Allow the cmd:, pass:, and sops: value sources, which run a command.",
+		},
+		cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "Only display the underlying kubectl commands.",
+		},
+		cli.StringFlag{
+			Name:  "output,o",
+			Usage: "Format of the install summary. Use 'json' for machine-readable output.",
+		},
+	},
+}
+
+func reinstall(c *cli.Context) {
+	minArgs(c, 1, "reinstall")
+
+	client := kubectl.Client
+	if c.Bool("dry-run") {
+		client = kubectl.PrintRunner{}
+	}
+	die(action.Reinstall(chartName(c, c.Args()[0], workspaceChart), home(c), action.ReinstallOptions{
+		Show:     c.Bool("show"),
+		Output:   c.String("output"),
+		Override: func(o *action.InstallOptions) { reinstallOverrides(c, o) },
+	}, client))
+}
+
+// reinstallOverrides replaces the recorded parameters with the flags that
+// were given.
+func reinstallOverrides(c *cli.Context, o *action.InstallOptions) {
+	if c.IsSet("namespace") {
+		o.Namespace = c.String("namespace")
+	}
+	if c.IsSet("mode") {
+		o.Mode = c.String("mode")
+	}
+	if c.IsSet("atomic") {
+		o.Atomic = c.Bool("atomic")
+	}
+	if c.IsSet("no-annotations") {
+		o.Annotate = !c.Bool("no-annotations")
+	}
+	if c.IsSet("no-labels") {
+		o.Labels = !c.Bool("no-labels")
+	}
+	if c.IsSet("force") {
+		o.Force = c.Bool("force")
+	}
+	if c.IsSet("generate") {
+		o.Generate = c.Bool("generate")
+	}
+	if c.IsSet("values") {
+		o.Values.Files = c.StringSlice("values")
+	}
+	if c.IsSet("set") {
+		o.Values.Set = c.StringSlice("set")
+	}
+	if c.IsSet("set-from") || c.IsSet("allow-exec-values") {
+		o.Values.SetFrom, o.Values.AllowExec = c.StringSlice("set-from"), c.Bool("allow-exec-values")
+	}
+	if c.IsSet("skip-schema") {
+		o.SkipSchema = c.Bool("skip-schema")
+	}
+	if c.IsSet("exclude") {
+		o.Exclude = c.StringSlice("exclude")
+	}
+}
@@ -25,8 +25,8 @@ func remove(c *cli.Context) {
 	force := c.Bool("force")
 
 	a := c.Args()
-	for _, chart := range a {
-		action.Remove(chart, h, force)
+	for _, arg := range a {
+		action.Remove(chartName(c, arg, workspaceChart), h, force)
 	}
 
 }
@@ -0,0 +1,93 @@
+package cli
+
+import (
+	"github.com/codegangsta/cli"
+	"github.com/helm/helm-classic/action"
+	"github.com/helm/helm-classic/log"
+)
+
+const renderDescription = `Print the manifests of a chart exactly as 'helmc install' would send them
+to Kubernetes, with the annotations that install adds, without talking to
+Kubernetes.
+
+Select files with '--show', by their path in the chart or in its manifests
+directory, e.g. '--show manifests/deployment.yaml' or '--show deployment.yaml'.
+Glob patterns such as '--show "*-svc.yaml"' select several files, and
+'--show' can be given more than once. A file that the chart does not have is
+reported with the list of those it does have. '--show-all' prints every file.
+When more than one file is printed, each begins with a '# Source:' comment.
+
+Templates are rendered into manifests by the chart's generators. With
+'--generate', they are run first, as by 'helmc install --generate', so
+'--show' selects the manifest that a template renders to.
+`
+
+var renderCmd = cli.Command{
+	Name:        "render",
+	Usage:       "Print the manifests that install would send to Kubernetes.",
+	Description: renderDescription,
+	ArgsUsage:   "[chart-name]",
+	Flags: []cli.Flag{
+		cli.StringSliceFlag{
+			Name:  "show",
+			Usage: "A file to print, relative to the chart or its manifests directory. Glob patterns are allowed.",
+		},
+		cli.BoolFlag{
+			Name:  "show-all",
+			Usage: "Print every file of the chart.",
+		},
+		cli.BoolFlag{
+			Name:  "force, aye-aye",
+			Usage: "Render even if dependencies are unsatisfied, and let generators overwrite files.",
+		},
+		cli.BoolFlag{
+			Name:  "generate,g",
+			Usage: "Run the generator before rendering.",
+		},
+		cli.BoolFlag{
+			Name:  "skip-schema",
+			Usage: "With --generate, render templates without validating their values against the chart's values schema.",
+		},
+		cli.StringSliceFlag{
+			Name:  "exclude,x",
+			Usage: "Files or directories to exclude from the generator (if -g is set).",
+		},
+		cli.StringSliceFlag{
+			Name:  "values,f",
+			Usage: "Merge a values file over the chart's values.yaml for its templates. Can be given more than once; later files win.",
+		},
+		cli.StringSliceFlag{
+			Name:  "set",
+			Usage: "Set a value of the templates, as KEY=VALUE, such as image.tag=1.2. Wins over the values files. Can be given more than once.",
+		},
+		cli.StringSliceFlag{
+			Name:  "set-from",
+			Usage: "Set a value of the templates from a source, as KEY=env:NAME, KEY=file:PATH, KEY=cmd:COMMAND, KEY=pass:NAME, or KEY=sops:PATH[#KEY] (if -g is set). Can be given more than once.",
+		},
+		cli.BoolFlag{
+			Name:  "allow-exec-values",
+			Usage: "Allow the cmd:, pass:, and sops: value sources, which run a command.",
+		},
+		envFlag,
+		cli.BoolFlag{
+			Name:  "no-annotations",
+			Usage: "Do not add the chart annotations that install adds.",
+		},
+		cli.BoolFlag{
+			Name:  "no-labels",
+			Usage: "Do not label resources with heritage=helm-classic and chart=NAME-VERSION.",
+		},
+	},
+	Action: func(c *cli.Context) {
+		minArgs(c, 1, "render")
+		die(useEnv(c))
+		show := c.StringSlice("show")
+		if len(show) == 0 && !c.Bool("show-all") {
+			log.Die("Give the files to print with --show, or --show-all to print them all.")
+		}
+		if c.Bool("show-all") {
+			show = nil
+		}
+		action.Render(chartName(c, c.Args()[0], installChart), home(c), show, c.Bool("force"), c.Bool("generate"), c.Bool("skip-schema"), c.StringSlice("exclude"), valueSources(c), !c.Bool("no-annotations"), !c.Bool("no-labels"))
+	},
+}
@@ -1,10 +1,25 @@
 package cli
 
 import (
+	"strconv"
+
 	"github.com/codegangsta/cli"
 	"github.com/helm/helm-classic/action"
+	"github.com/helm/helm-classic/config"
+	"github.com/helm/helm-classic/log"
 )
 
+const repoLoginDescription = `The token is stored in credentials.yaml in your Helm Classic home, which
+only you may read, and not in config.yaml, so that the configuration can be
+shared without it. It is sent to the repository as an HTTP Authorization
+header, for git over HTTPS and for HTTP repositories alike, and replaces any
+'--token-from' or '--token-file' that the repository had.
+
+Give the token on stdin to keep it out of your shell history:
+
+	echo "$CHARTS_TOKEN" | helmc repo login --username bot --token - stable
+`
+
 var repositoryCmd = cli.Command{
 	Name:    "repository",
 	Aliases: []string{"repo"},
@@ -13,30 +28,223 @@ var repositoryCmd = cli.Command{
 		{
 			Name:      "add",
 			Usage:     "Add a remote chart repository.",
-			ArgsUsage: "[name] [git url]",
+			ArgsUsage: "[name] [url]",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "type",
+					Usage: "The repository type: 'git' or 'http'. By default, URLs ending in .yaml, and HTTP(S) URLs with an index.yaml, are 'http'.",
+				},
+				cli.StringFlag{
+					Name:  "branch",
+					Usage: "Pin the repository to a branch.",
+				},
+				cli.StringFlag{
+					Name:  "tag",
+					Usage: "Pin the repository to a tag.",
+				},
+				cli.BoolFlag{
+					Name:  "full",
+					Usage: "Clone the whole history of a Git repository, instead of only the latest commits.",
+				},
+				cli.IntFlag{
+					Name:  "priority",
+					Usage: "Priority for resolving unqualified chart names. Higher wins.",
+				},
+				cli.StringFlag{
+					Name:  "keyring",
+					Usage: "Path to an OpenPGP keyring. The index of an HTTP repository must be signed by one of its keys.",
+				},
+				cli.BoolFlag{
+					Name:  "verify",
+					Usage: "Contact the remote to check the repository before adding it.",
+				},
+				cli.BoolFlag{
+					Name:  "no-validate",
+					Usage: "Do not check that the URL is well-formed.",
+				},
+				cli.StringFlag{
+					Name:  "ssh-key",
+					Usage: "Path to a private key for an SSH Git URL.",
+				},
+				cli.StringFlag{
+					Name:  "username",
+					Usage: "User name for an HTTPS repository.",
+				},
+				cli.StringFlag{
+					Name:  "token-from",
+					Usage: "Environment variable that holds the HTTPS token.",
+				},
+				cli.StringFlag{
+					Name:  "token-file",
+					Usage: "File that holds the HTTPS token.",
+				},
+				cli.StringFlag{
+					Name:  "token",
+					Usage: "The HTTPS token, which is stored in credentials.yaml of your home, not in the configuration. Use '-' to read it from stdin.",
+				},
+				cli.StringFlag{
+					Name:  "git-backend",
+					Usage: "The Git backend of the repository: 'exec' or 'native'. By default, the global --git-backend is used.",
+				},
+			},
 			Action: func(c *cli.Context) {
 				minArgs(c, 2, "add")
 				a := c.Args()
-				action.AddRepo(home(c), a[0], a[1])
+				t := &config.Table{
+					Name:     a[0],
+					Repo:     a[1],
+					Type:     c.String("type"),
+					Branch:   c.String("branch"),
+					Tag:      c.String("tag"),
+					Auth:     repoAuth(c),
+					Priority: c.Int("priority"),
+					Full:     c.Bool("full"),
+					Keyring:  c.String("keyring"),
+					Backend:  c.String("git-backend"),
+				}
+				action.AddRepo(home(c), t, c.String("token"), !c.Bool("no-validate"), c.Bool("verify"))
+			},
+		},
+		{
+			Name:        "login",
+			Usage:       "Store the token of a private repository.",
+			Description: repoLoginDescription,
+			ArgsUsage:   "[name]",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "username",
+					Usage: "User name for the HTTPS repository. By default, the token is sent as a bearer token.",
+				},
+				cli.StringFlag{
+					Name:  "token",
+					Usage: "The token. Use '-' to read it from stdin. By default, it is asked for on a terminal.",
+				},
+			},
+			Action: func(c *cli.Context) {
+				minArgs(c, 1, "login")
+				action.LoginRepo(home(c), c.Args()[0], c.String("username"), c.String("token"))
+			},
+		},
+		{
+			Name:      "logout",
+			Usage:     "Forget the stored token of a repository.",
+			ArgsUsage: "[name]",
+			Action: func(c *cli.Context) {
+				minArgs(c, 1, "logout")
+				action.LogoutRepo(home(c), c.Args()[0])
+			},
+		},
+		{
+			Name:      "set-branch",
+			Usage:     "Pin a remote chart repository to a branch or tag, or unpin it with \"\".",
+			ArgsUsage: "[name] [ref]",
+			Action: func(c *cli.Context) {
+				minArgs(c, 2, "set-branch")
+				a := c.Args()
+				action.SetRepoRef(home(c), a[0], a[1])
+			},
+		},
+		{
+			Name:      "set-depth",
+			Usage:     "Switch a Git repository between a full and a shallow clone.",
+			ArgsUsage: "[name] [full|shallow]",
+			Action: func(c *cli.Context) {
+				minArgs(c, 2, "set-depth")
+				a := c.Args()
+				action.SetRepoDepth(home(c), a[0], a[1])
+			},
+		},
+		{
+			Name:      "set-priority",
+			Usage:     "Set the priority used to resolve unqualified chart names.",
+			ArgsUsage: "[name] [priority]",
+			Action: func(c *cli.Context) {
+				minArgs(c, 2, "set-priority")
+				a := c.Args()
+				p, err := strconv.Atoi(a[1])
+				if err != nil {
+					log.Die("Priority must be an integer: %s", a[1])
+				}
+				action.SetRepoPriority(home(c), a[0], p)
+			},
+		},
+		{
+			Name:      "rename",
+			Aliases:   []string{"mv"},
+			Usage:     "Rename a remote chart repository.",
+			ArgsUsage: "[old name] [new name]",
+			Action: func(c *cli.Context) {
+				minArgs(c, 2, "rename")
+				a := c.Args()
+				action.RenameRepo(home(c), a[0], a[1])
 			},
 		},
 		{
 			Name:    "list",
 			Aliases: []string{"ls"},
 			Usage:   "List all remote chart repositories.",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "output,o",
+					Usage: "Print the list as 'json' or 'yaml'.",
+				},
+			},
+			Action: func(c *cli.Context) {
+				action.ListRepos(home(c), outputFormat(c))
+			},
+		},
+		{
+			Name:      "index",
+			Usage:     "Write an index.yaml for a directory of packaged charts.",
+			ArgsUsage: "[dir]",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "url",
+					Usage: "The URL the charts will be served from. By default, archive URLs are relative to the index.",
+				},
+				cli.BoolFlag{
+					Name:  "merge",
+					Usage: "Keep the versions in an existing index.yaml, even if their archives are not in the directory.",
+				},
+			},
 			Action: func(c *cli.Context) {
-				action.ListRepos(home(c))
+				minArgs(c, 1, "index")
+				action.IndexRepo(c.Args()[0], c.String("url"), c.Bool("merge"))
 			},
 		},
 		{
 			Name:      "remove",
 			Aliases:   []string{"rm"},
 			Usage:     "Remove a remote chart repository.",
-			ArgsUsage: "[name] [git url]",
+			ArgsUsage: "[name]",
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "yes,y",
+					Usage: "Do not ask for confirmation.",
+				},
+				cli.BoolFlag{
+					Name:  "purge-charts",
+					Usage: "Also remove workspace charts that were fetched from the repository.",
+				},
+			},
 			Action: func(c *cli.Context) {
 				minArgs(c, 1, "remove")
-				action.DeleteRepo(home(c), c.Args()[0])
+				action.DeleteRepo(home(c), c.Args()[0], c.Bool("yes"), c.Bool("purge-charts"))
 			},
 		},
 	},
 }
+
+// repoAuth builds repository credentials from the flags, or returns nil if none were given.
+func repoAuth(c *cli.Context) *config.Auth {
+	a := &config.Auth{
+		SSHKey:    c.String("ssh-key"),
+		Username:  c.String("username"),
+		TokenFrom: c.String("token-from"),
+		TokenFile: c.String("token-file"),
+	}
+	if *a == (config.Auth{}) {
+		return nil
+	}
+	return a
+}
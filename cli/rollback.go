@@ -0,0 +1,38 @@
+package cli
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/codegangsta/cli"
+	"github.com/helm/helm-classic/action"
+	"github.com/helm/helm-classic/kubectl"
+)
+
+const rollbackDescription = `Send the manifests of an earlier revision of the release of a chart to
+Kubernetes again.
+
+The manifests are those that the revision sent, as 'helmc history' lists
+them, whatever the chart in your workspace is now. They are applied, as by
+'kubectl apply', into the namespace of the revision, so that the resources
+that exist are changed back, and those that are missing are created. Hooks
+are not run again. Resources that the latest revision has, and the revision
+rolled back to does not, are left in Kubernetes, and listed.
+
+A rollback is recorded in the audit log, and as a new revision of the
+release, so it can be rolled back in turn.`
+
+var rollbackCmd = cli.Command{
+	Name:        "rollback",
+	Usage:       "Roll the release of a chart back to an earlier revision.",
+	Description: rollbackDescription,
+	ArgsUsage:   "[chart-name] [revision]",
+	Action: func(c *cli.Context) {
+		minArgs(c, 2, "rollback")
+		rev, err := strconv.Atoi(c.Args()[1])
+		if err != nil || rev < 1 {
+			die(fmt.Errorf("Revision %q is not a revision number. See 'helmc history %s'", c.Args()[1], c.Args()[0]))
+		}
+		die(action.Rollback(chartName(c, c.Args()[0], workspaceChart), home(c), rev, kubectl.Client))
+	},
+}
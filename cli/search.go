@@ -3,13 +3,27 @@ package cli
 import (
 	"github.com/codegangsta/cli"
 	"github.com/helm/helm-classic/action"
+	helmsearch "github.com/helm/helm-classic/search"
 )
 
 const searchDescription = `This provides a simple interface for searching the chart cache
 for charts matching a given pattern.
 
+The string is looked for in the name of each chart, its path in the
+repository, and its description, details, keywords, and maintainers, as the
+Chart.yaml files of the repositories, or the index files of HTTP
+repositories, give them. Matches in the name come first.
+
 If no string is provided, or if the special string '*' is provided, this will
-list all available charts.
+list all available charts. Charts that are deprecated are marked '(DEPRECATED)'.
+
+'--keyword' keeps only the charts that have a keyword, and '--maintainer' only
+those with a maintainer that contains a name or an email address. Both are
+matched regardless of case, and may be given more than once: a chart must
+match each of them.
+
+With '--output json' or '--output yaml', each chart is printed with its
+version, description, keywords, and maintainers, for scripts.
 `
 
 var searchCmd = cli.Command{
@@ -23,6 +37,18 @@ var searchCmd = cli.Command{
 			Name:  "regexp,r",
 			Usage: "Use a regular expression instead of a substring match.",
 		},
+		cli.StringSliceFlag{
+			Name:  "keyword,k",
+			Usage: "Only list the charts that have this keyword. May be repeated.",
+		},
+		cli.StringSliceFlag{
+			Name:  "maintainer,m",
+			Usage: "Only list the charts with a maintainer that contains this name or email address. May be repeated.",
+		},
+		cli.StringFlag{
+			Name:  "output,o",
+			Usage: "Print the charts as 'json' or 'yaml'.",
+		},
 	},
 }
 
@@ -31,5 +57,9 @@ func search(c *cli.Context) {
 	if len(c.Args()) > 0 {
 		term = c.Args()[0]
 	}
-	action.Search(term, home(c), c.Bool("regexp"))
+	action.Search(term, home(c), action.SearchOptions{
+		Regexp: c.Bool("regexp"),
+		Filter: helmsearch.Filter{Keywords: c.StringSlice("keyword"), Maintainers: c.StringSlice("maintainer")},
+		Output: outputFormat(c),
+	})
 }
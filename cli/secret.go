@@ -0,0 +1,104 @@
+package cli
+
+import (
+	"github.com/codegangsta/cli"
+	"github.com/helm/helm-classic/action"
+)
+
+const secretDescription = `Make Kubernetes Secrets from local files, the environment, and secret
+stores, so that the secrets of a chart never have to be kept in it.
+`
+
+const secretCreateDescription = `Write a Secret manifest named 'name', whose values are read now, and
+base64-encoded.
+
+Each '--from KEY=SOURCE' is the key KEY, read from one of these sources:
+
+- env:NAME: the environment variable NAME. It is an error if it is not set.
+- file:PATH: the contents of the file PATH.
+- cmd:COMMAND: what COMMAND prints, without its trailing newline. The command
+  is not run in a shell.
+- pass:NAME: the first line of the entry NAME of the pass password store.
+- sops:PATH: the file PATH decrypted by SOPS, or with sops:PATH#KEY, the
+  value at KEY, a dotted path such as 'db.password', of the decrypted file.
+
+As for 'helmc template', the sources that run a command, cmd:, pass:, and
+sops:, need '--allow-exec-values'. When a chart's generator runs 'helmc secret
+create', its command line is the chart's, so only the '--allow-exec-values'
+that you give 'helmc generate' or 'helmc install --generate' counts.
+
+Each '--from-file [KEY=]PATH' is the key KEY, or the base name of PATH, with
+the contents of PATH. Each '--from-env-file PATH' is a file of KEY=VALUE
+lines, each a key; blank lines and those that begin with '#' are skipped. A
+key that is given twice is an error.
+
+The Secret is printed, or written to the file of '--out', which only its
+owner may read. A chart can generate its Secret when it is installed, so that
+only the generator is kept in the chart:
+
+	#helm:generate helmc secret create --force -o manifests/db-secret.yaml db --from password=env:DB_PASSWORD
+
+Keep the generated file out of version control, as with a '.gitignore'.
+`
+
+var secretCmd = cli.Command{
+	Name:        "secret",
+	Usage:       "Make Kubernetes Secrets from files, the environment, and secret stores.",
+	Description: secretDescription,
+	Subcommands: []cli.Command{
+		{
+			Name:        "create",
+			Usage:       "Write a Secret manifest whose values are read from sources.",
+			Description: secretCreateDescription,
+			ArgsUsage:   "name",
+			Action: func(c *cli.Context) {
+				minArgs(c, 1, "secret create")
+				die(action.CreateSecret(c.Args()[0], action.SecretOptions{
+					Namespace: c.String("namespace"),
+					Type:      c.String("type"),
+					From:      c.StringSlice("from"),
+					FromFiles: c.StringSlice("from-file"),
+					EnvFiles:  c.StringSlice("from-env-file"),
+					Out:       c.String("out"),
+					Force:     c.Bool("force"),
+					AllowExec: c.Bool("allow-exec-values"),
+				}))
+			},
+			Flags: []cli.Flag{
+				cli.StringSliceFlag{
+					Name:  "from",
+					Usage: "A key of the Secret, from a source: KEY=env:NAME, KEY=file:PATH, KEY=cmd:COMMAND, KEY=pass:NAME, or KEY=sops:PATH[#KEY]. Can be given more than once.",
+				},
+				cli.StringSliceFlag{
+					Name:  "from-file",
+					Usage: "A key of the Secret with the contents of a file, as [KEY=]PATH. Can be given more than once.",
+				},
+				cli.StringSliceFlag{
+					Name:  "from-env-file",
+					Usage: "A file of KEY=VALUE lines, each a key of the Secret. Can be given more than once.",
+				},
+				cli.StringFlag{
+					Name:  "namespace, n",
+					Usage: "The namespace of the Secret. By default, the manifest has none.",
+				},
+				cli.StringFlag{
+					Name:  "type",
+					Value: "Opaque",
+					Usage: "The type of the Secret, such as kubernetes.io/tls.",
+				},
+				cli.StringFlag{
+					Name:  "out, o",
+					Usage: "The file to write the Secret to. By default, it is printed.",
+				},
+				cli.BoolFlag{
+					Name:  "force, f",
+					Usage: "Overwrite the file of --out if it exists.",
+				},
+				cli.BoolFlag{
+					Name:  "allow-exec-values",
+					Usage: "Allow the cmd:, pass:, and sops: sources, which run a command.",
+				},
+			},
+		},
+	},
+}
@@ -0,0 +1,32 @@
+package cli
+
+import (
+	"github.com/codegangsta/cli"
+	"github.com/helm/helm-classic/action"
+)
+
+const selfUpdateDescription = `Downloads a released helmc for this platform, verifies its SHA-256
+checksum, and replaces the running helmc with it.
+
+With no version, the latest release is installed if it is newer. With a
+version, that release is installed, even if it is older.
+
+'--check' only reports whether a newer release exists. It exits with 2 if
+there is one, and 0 if not, so that it can be run from cron.
+`
+
+var selfUpdateCmd = cli.Command{
+	Name:        "self-update",
+	Usage:       "Update helmc to the latest release, or to a given version.",
+	Description: selfUpdateDescription,
+	ArgsUsage:   "[version]",
+	Action: func(c *cli.Context) {
+		action.SelfUpdate(c.Args().First(), c.Bool("check"))
+	},
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "check",
+			Usage: "Only report whether an update is available. Exits with 2 if it is.",
+		},
+	},
+}
@@ -0,0 +1,47 @@
+package cli
+
+import (
+	"github.com/codegangsta/cli"
+	"github.com/helm/helm-classic/action"
+)
+
+const signDescription = `Sign a chart, so that others can verify that it is the chart you published.
+
+The chart is a chart of the workspace, a chart directory, or a chart archive,
+as 'helmc package' writes it. Its provenance file is written next to it,
+named after it with '.prov', such as 'redis-0.2.0.tgz.prov' or 'redis.prov'.
+Publish it with the chart: next to the chart directory in a Git repository,
+or next to the archive in an HTTP repository.
+
+The provenance file gives the chart's name, its version, and the checksum of
+its files, as 'helmc fetch --print-checksum' prints it, clearsigned with an
+OpenPGP key. The key is the first private key of '--keyring' whose identity
+contains '--key', such as an email address, or without '--key', the first
+private key. If the key is encrypted, its passphrase is read from
+$HELMC_SIGNING_PASSPHRASE, or asked for on a terminal.
+
+'helmc verify' checks a chart against its provenance file, and 'helmc fetch
+--verify' and 'helmc install --verify' check the charts they fetch.
+`
+
+var signCmd = cli.Command{
+	Name:        "sign",
+	Usage:       "Write a signed provenance file for a chart.",
+	Description: signDescription,
+	ArgsUsage:   "[chart]",
+	Action: func(c *cli.Context) {
+		minArgs(c, 1, "sign")
+		die(action.Sign(signPath(c, c.Args()[0]), c.String("keyring"), c.String("key")))
+	},
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "keyring",
+			Value: action.DefaultSecretKeyring,
+			Usage: "The keyring of the private key that signs.",
+		},
+		cli.StringFlag{
+			Name:  "key",
+			Usage: "A part of the identity of the key that signs, such as its email address. By default, the first private key signs.",
+		},
+	},
+}
@@ -0,0 +1,149 @@
+package cli
+
+import (
+	"time"
+
+	"github.com/codegangsta/cli"
+	"github.com/helm/helm-classic/action"
+	"github.com/helm/helm-classic/kubectl"
+	"github.com/helm/helm-classic/stack"
+)
+
+const stackDescription = `Install, uninstall, and check a stack: a set of charts, listed in a stack
+file with their values, in the order they are installed.
+
+	name: shop
+	namespace: shop
+	charts:
+	- name: db
+	  chart: postgres
+	  values: [db-values.yaml]
+	  wait: 2m
+	- name: web
+	  chart: charts/nginx
+	  namespace: shop-web
+	  set: [replicas=2]
+	  env: prod
+	  generate: true
+	  checksum: sha256:5c2c...
+
+Each chart has the 'name' it has in the workspace, which is the last element
+of its 'chart' if it is not given, and is fetched from its 'chart' if the
+workspace does not have it. It is installed into its 'namespace', or that of
+the stack, or else that of its Chart.yaml.
+
+'values', 'set', 'setFrom', and 'env' give the values of a chart, as the
+flags of 'helmc install' do; values files are relative to the stack file.
+'generate' runs the generators of the chart first, 'checksum' installs it
+only if it has that checksum, as 'helmc fetch --print-checksum' prints it,
+and 'wait' waits for its workloads to be ready before the next chart is
+installed.
+`
+
+const stackInstallDescription = `Install the charts of a stack, in order.
+
+The charts that the workspace does not have are fetched first, so that a
+chart that cannot be found stops the install before anything changes in
+Kubernetes. Each chart is then installed as 'helmc install --mode apply
+--atomic' installs it, so that installing a stack again brings each chart up
+to date.
+
+If a chart fails, the resources that it created are deleted, and the charts
+before it are rolled back, newest first: to their deployed revision, as
+'helmc rollback' does, if they had one, or else by deleting the resources
+that they created. The stack is then as it was before the install.
+`
+
+const stackUninstallDescription = `Uninstall the charts of a stack, in reverse order, as 'helmc uninstall'
+does, so that a chart goes before those it was installed after.
+
+The charts that the workspace does not have are skipped. The first chart
+that fails stops the uninstall, and leaves the charts before it installed.
+You are asked to confirm first, unless --yes or --force is given.
+`
+
+const stackStatusDescription = `Report the status of each chart of a stack: its latest revision, as 'helmc
+history' lists it, and whether its resources in Kubernetes are ready and
+running the workspace chart, as 'helmc status' reports them.
+`
+
+var stackCmd = cli.Command{
+	Name:        "stack",
+	Usage:       "Install and uninstall a set of charts as one.",
+	Description: stackDescription,
+	Subcommands: []cli.Command{
+		{
+			Name:        "install",
+			Usage:       "Install the charts of a stack, and roll them back if one fails.",
+			Description: stackInstallDescription,
+			ArgsUsage:   "stack-file",
+			Action: func(c *cli.Context) {
+				minArgs(c, 1, "stack install")
+				die(stack.Install(c.Args()[0], home(c), kubectl.Client))
+			},
+		},
+		{
+			Name:        "uninstall",
+			Usage:       "Uninstall the charts of a stack, in reverse order.",
+			Description: stackUninstallDescription,
+			ArgsUsage:   "stack-file",
+			Action: func(c *cli.Context) {
+				minArgs(c, 1, "stack uninstall")
+				kubectl.Defaults.GracePeriod = c.Int("grace-period")
+				o := action.UninstallOptions{
+					Yes:     c.Bool("yes"),
+					Force:   c.Bool("force"),
+					NoHooks: c.Bool("no-hooks"),
+				}
+				if c.Bool("wait") {
+					o.Wait = c.Duration("timeout")
+				}
+				die(stack.Uninstall(c.Args()[0], home(c), o, kubectl.Client))
+			},
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "yes, aye-aye, y",
+					Usage: "Do not ask for confirmation.",
+				},
+				cli.BoolFlag{
+					Name:  "force",
+					Usage: "Also delete resources annotated to be kept, and do not ask for confirmation.",
+				},
+				cli.BoolFlag{
+					Name:  "no-hooks",
+					Usage: "Do not run the pre-delete hooks of the charts.",
+				},
+				cli.IntFlag{
+					Name:  "grace-period",
+					Value: -1,
+					Usage: "Seconds given to each resource to terminate. A negative value uses the resource's default.",
+				},
+				cli.BoolFlag{
+					Name:  "wait",
+					Usage: "Wait for each kind of resource to be deleted before deleting the next.",
+				},
+				cli.DurationFlag{
+					Name:  "timeout",
+					Value: 5 * time.Minute,
+					Usage: "How long to wait for the resources of each chart to be deleted, with --wait.",
+				},
+			},
+		},
+		{
+			Name:        "status",
+			Usage:       "Report the status of each chart of a stack.",
+			Description: stackStatusDescription,
+			ArgsUsage:   "stack-file",
+			Action: func(c *cli.Context) {
+				minArgs(c, 1, "stack status")
+				die(stack.Status(c.Args()[0], home(c), outputFormat(c), kubectl.Client))
+			},
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "output,o",
+					Usage: "Print the status as 'json' or 'yaml' instead of a table.",
+				},
+			},
+		},
+	},
+}
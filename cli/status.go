@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"time"
+
+	"github.com/codegangsta/cli"
+	"github.com/helm/helm-classic/action"
+	"github.com/helm/helm-classic/kubectl"
+)
+
+const statusDescription = `For each resource in the chart, this shows the chart version and
+digest recorded when it was installed, and compares the digest with the chart
+in your workspace. Resources are 'current' if they were installed from the
+workspace copy, 'drifted' if not, 'unknown' if they were installed without
+annotations, and 'missing' if they are not in Kubernetes.
+
+It also shows whether each resource is ready: a Pod whose containers are
+ready, a Service whose endpoints have an address, a Deployment whose replicas
+are updated and available, a ReplicationController, ReplicaSet, DaemonSet, or
+StatefulSet whose replicas are ready, a Job that succeeded, and a
+PersistentVolumeClaim that is bound. Other kinds, such as ConfigMaps, have no
+readiness. A rollup after the table counts the ready resources of each kind,
+as in 'Ready: 1/1 pods ready, 0/1 services with endpoints'.
+
+With '--watch', the resources are checked every few seconds until they are
+all present and ready, and then shown. If they are not within '--timeout',
+they are shown as they are, and the command fails, so that a script can wait
+for an install to come up.
+`
+
+var statusCmd = cli.Command{
+	Name:        "status",
+	Usage:       "Show what is installed in Kubernetes from a chart, and whether it is ready.",
+	Description: statusDescription,
+	ArgsUsage:   "[chart-name]",
+	Action: func(c *cli.Context) {
+		minArgs(c, 1, "status")
+		die(action.Status(chartName(c, c.Args()[0], workspaceChart), home(c), namespace(c), action.StatusOptions{
+			Watch:   c.Bool("watch"),
+			Timeout: c.Duration("timeout"),
+			Output:  outputFormat(c),
+		}, kubectl.Client))
+	},
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "namespace, n",
+			Value: "",
+			Usage: "The Kubernetes namespace to look in. It defaults to the namespace of the chart's Chart.yaml.",
+		},
+		cli.BoolFlag{
+			Name:  "watch, w",
+			Usage: "Check the resources again until they are all ready, or --timeout expires.",
+		},
+		cli.DurationFlag{
+			Name:  "timeout",
+			Value: 5 * time.Minute,
+			Usage: "How long --watch waits for the resources to be ready.",
+		},
+	},
+}
@@ -18,16 +18,93 @@ the 'helmc generate' system.
 'helmc template' uses Go's built-in text template system to provide template
 substitution inside of a chart. In addition to the built-in template commands,
 'helmc template' supports all of the template functions provided by the Sprig
-library (https://github.com/Masterminds/sprig).
+library (https://github.com/Masterminds/sprig), and the helpers default,
+required, quote, indent, toYaml, b64enc, and trunc, which charts can rely on.
+'required' stops rendering with a message, and the template's line, when a
+value is missing:
 
-If a values data file is provided, 'helmc template' will use that as a source
-for values. If none is specified, only default values will be used. Helm Classic uses
-simple extension scanning to determine the file type of the values data file.
+	image: {{required "image is needed" .Values.image}}
+
+A template in a chart can use its values as '.Values', the chart's Chart.yaml
+as '.Chart' (e.g. '.Chart.Version'), the chart's name in the workspace as
+'.Release.Name', and the chart's other files with '.Files.Get "path"'.
+Top-level values can also be used directly, as in '.Namespace'.
+
+The partials of a chart are the '.tpl' files whose names begin with '_', such
+as 'tpl/_helpers.tpl', in its 'tpl' directory and in the directory of the
+template. Their templates, which they declare with 'define', can be used by
+every template of the chart, with the 'template' action or with 'include',
+which returns the result so that it can be piped:
+
+	labels:
+	{{include "web.labels" . | indent 4}}
+
+A partial of the template's directory wins over one of 'tpl' that defines the
+same template. Generators see a change to a partial as a change to the
+templates that use it.
+
+If the template is in a chart with a 'values.yaml', its values are used. If a
+values data file is provided, 'helmc template' merges it over them. If neither
+is there, only default values will be used. Helm Classic uses simple extension
+scanning to determine the file type of the values data file.
 
 - YAML: .yaml, .yml
 - TOML: .toml
 - JSON: .json
 
+'--set KEY=VALUE' sets the value at KEY, a dotted path such as 'image.tag', over
+the values file. VALUE is a boolean if it is true or false, a number if it is
+an integer, and a string otherwise.
+
+Values can also be read when the template is rendered, instead of being kept
+in the values file, which suits secrets. '--set-from KEY=SOURCE' sets the value
+at KEY, a dotted path such as 'db.password', from one of these sources:
+
+- env:NAME: the environment variable NAME. It is an error if it is not set.
+- file:PATH: the contents of the file PATH.
+- cmd:COMMAND: what COMMAND prints, without its trailing newline. The command
+  is not run in a shell. Since it can run anything, cmd: sources are refused
+  unless '--allow-exec-values' is given.
+- pass:NAME: the first line of the entry NAME of the pass password store, as
+  'pass show NAME' prints it.
+- sops:PATH: the file PATH decrypted by SOPS, or with sops:PATH#KEY, the
+  value at KEY, a dotted path such as 'db.password', of the decrypted file.
+  Like cmd:, pass: and sops: run a command, and need '--allow-exec-values'.
+
+	$ helmc template -d values.yaml --set-from db.password=env:DB_PASSWORD \
+	    --set-from tls.cert=file:./cert.pem pod.tpl
+
+In the values file, a mapping whose only key is 'valueFrom' is replaced in the
+same way:
+
+	db:
+	  password:
+	    valueFrom: cmd:vault read -field=password secret/db
+
+Only you read sources: in '--set-from', in the values file when you run
+'helmc template' yourself, and in the files of 'helmc generate --values'. The
+values.yaml of a chart, and the values file and '--set-from' of its
+generators, may not read one, so that a chart cannot render your secrets into
+its manifests. Nor does the '--allow-exec-values' of a generator count.
+
+'--set-from' wins over the values file and '--set'. A source that cannot be read, such as
+a missing variable or a command that fails, is an error, and nothing is
+rendered. The values are never logged, and the audit log and plans only
+record the sources as 'KEY=env:<redacted>'. 'helmc generate' passes its
+'--values', '--set', '--set-from', and '--allow-exec-values' to the templates
+it renders in $HELM_VALUES_FILES, $HELM_SET, $HELM_SET_FROM, and
+$HELM_ALLOW_EXEC_VALUES. The values are merged in this order, later ones
+winning: the chart's values.yaml, the values file, $HELM_VALUES_FILES,
+$HELM_SET and '--set', and $HELM_SET_FROM and '--set-from'.
+
+If the template is in a chart with a values schema (a 'values.schema.yaml',
+or the 'valuesSchema' or 'valueTypes' of its Chart.yaml), the values are
+validated against it before anything is rendered, and every value that does
+not conform is reported with its path, such as 'image.tag'. A template run by
+'helmc generate' belongs to the chart being generated; otherwise, the chart is
+the nearest directory above the template with a Chart.yaml. Use
+'--skip-schema' to render anyway. See 'helmc help lint'.
+
 If an output file is specified, the results will be written to the output
 file instead of STDOUT. Writing to the source template file is unsupported.
 (In other words, don't set the source and output to the same file.)
@@ -53,6 +130,22 @@ var tplCmd = cli.Command{
 			Name:  "force,f",
 			Usage: "Forces to overwrite an exiting file",
 		},
+		cli.BoolFlag{
+			Name:  "skip-schema",
+			Usage: "Render without validating the values against the chart's values schema.",
+		},
+		cli.StringSliceFlag{
+			Name:  "set",
+			Usage: "Set a value of the template, as KEY=VALUE, such as image.tag=1.2. Wins over the values file. Can be given more than once.",
+		},
+		cli.StringSliceFlag{
+			Name:  "set-from",
+			Usage: "Set a value of the templates from a source, as KEY=env:NAME, KEY=file:PATH, KEY=cmd:COMMAND, KEY=pass:NAME, or KEY=sops:PATH[#KEY]. Can be given more than once.",
+		},
+		cli.BoolFlag{
+			Name:  "allow-exec-values",
+			Usage: "Allow the cmd:, pass:, and sops: value sources, which run a command.",
+		},
 	},
 	Action: func(c *cli.Context) {
 		minArgs(c, 1, "template")
@@ -60,7 +153,10 @@ var tplCmd = cli.Command{
 		a := c.Args()
 		force := c.Bool("force")
 		filename := a[0]
-		err := action.Template(c.String("out"), filename, c.String("values"), force)
+		// The --values of 'helmc template' is the file of the template's
+		// own values, not one of the values files of generate.
+		sources := action.ValueSources{Set: c.StringSlice("set"), SetFrom: c.StringSlice("set-from"), AllowExec: c.Bool("allow-exec-values")}
+		err := action.Template(c.String("out"), filename, c.String("values"), force, c.Bool("skip-schema"), sources)
 		if err != nil {
 			log.Die(err.Error())
 		}
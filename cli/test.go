@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"time"
+
+	"github.com/codegangsta/cli"
+	"github.com/helm/helm-classic/action"
+	"github.com/helm/helm-classic/kubectl"
+)
+
+const testDescription = `Run the tests of a chart in your workspace against its release.
+
+The tests of a chart are the Pods and Jobs of its 'tests' directory, which is
+not installed. Each must have the annotation 'helm.sh/hook: test':
+
+    apiVersion: v1
+    kind: Pod
+    metadata:
+      name: redis-ping
+      annotations:
+        helm.sh/hook: test
+    spec:
+      restartPolicy: Never
+      containers:
+      - name: ping
+        image: redis:3.0
+        command: ["redis-cli", "-h", "redis", "ping"]
+
+They are all created in the namespace of the release, which is that of its
+latest revision unless '--namespace' is given, and each is then waited on
+until it completes. A Pod passes if it succeeds, and a Job if it completes.
+A test that could not be created, or that fails or does not complete within
+'--timeout', fails, and its logs are printed. Each test is reported as it is
+done, then how many passed and failed.
+
+The tests are deleted once they are done, unless '--keep' is given or they
+are keepers, with the 'helm-keep' or 'helm.sh/resource-policy' annotation.
+'helmc test' exits with status 10 if any test failed.`
+
+var testCmd = cli.Command{
+	Name:        "test",
+	Usage:       "Run the tests of a chart against its release.",
+	Description: testDescription,
+	ArgsUsage:   "[chart-name]",
+	Action: func(c *cli.Context) {
+		minArgs(c, 1, "test")
+		die(action.Test(chartName(c, c.Args()[0], workspaceChart), home(c), namespace(c), c.Duration("timeout"), c.Bool("keep"), kubectl.Client))
+	},
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "namespace, n",
+			Usage: "The Kubernetes namespace of the release. It defaults to that of its latest revision.",
+		},
+		cli.DurationFlag{
+			Name:  "timeout",
+			Value: 5 * time.Minute,
+			Usage: "How long the tests may take, all together.",
+		},
+		cli.BoolFlag{
+			Name:  "keep",
+			Usage: "Leave the tests in the cluster once they are done.",
+		},
+	},
+}
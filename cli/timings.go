@@ -0,0 +1,93 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/helm/helm-classic/log"
+	"github.com/helm/helm-classic/metrics"
+	"github.com/helm/helm-classic/output"
+)
+
+// timings is whether --timings was given, and metricsFile is the file of
+// --metrics-file.
+var (
+	timings     bool
+	metricsFile string
+)
+
+// atExit is log.AtExit: it writes the timings and the metrics of the
+// command, and then, with --error-format json, the report of its failure,
+// which must be the last line.
+func atExit(code int) {
+	if timings {
+		writeTimings(log.Stderr)
+	}
+	if metricsFile != "" {
+		if err := writeMetricsFile(metricsFile); err != nil {
+			log.Warn("Could not write the metrics to %s: %s", metricsFile, err)
+		}
+	}
+	if jsonErrors {
+		writeErrorReport(code)
+	}
+}
+
+// writeTimings writes the breakdown of where the time of the command went,
+// for --timings. The operations include the phases that ran inside them,
+// such as the generators of a generate, so the times do not add up.
+func writeTimings(w io.Writer) {
+	t := &output.Table{Header: []string{"PHASE", "NAME", "COUNT", "FAILED", "TOTAL", "MAX"}, Flex: 1}
+	for _, m := range metrics.Timings() {
+		t.Add(m.Phase, m.Name, strconv.Itoa(m.Count), strconv.Itoa(m.Failures), round(m.Total).String(), round(m.Max).String())
+	}
+	if len(t.Rows) > 0 {
+		output.New(w).Table(t)
+	}
+	fmt.Fprintf(w, "Total: %s\n", round(metrics.Elapsed()))
+}
+
+// round rounds a duration of the timings to a millisecond, or to a
+// microsecond if it is shorter.
+func round(d time.Duration) time.Duration {
+	if d < time.Millisecond {
+		return d.Round(time.Microsecond)
+	}
+	return d.Round(time.Millisecond)
+}
+
+// writeMetricsFile writes the metrics to path in the text format of
+// Prometheus. The file is replaced in one rename, so that a collector never
+// reads half of it.
+func writeMetricsFile(path string) error {
+	var b bytes.Buffer
+	if err := metrics.WriteText(&b); err != nil {
+		return err
+	}
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(dir, "."+filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(b.Bytes()); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(f.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
@@ -1,6 +1,9 @@
 package cli
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/codegangsta/cli"
 	"github.com/helm/helm-classic/action"
 	"github.com/helm/helm-classic/kubectl"
@@ -9,6 +12,36 @@ import (
 const uninstallDescription = `For each supplied 'chart-name', this will connect to Kubernetes
 and remove all of the manifests specified.
 
+Resources are deleted in the reverse of the order they are installed in:
+ingresses and services first, so that traffic stops, then the controllers,
+then the config maps and secrets they use, and namespaces last. With '--wait', each kind of resource
+must be gone before the next is deleted, and resources that are stuck in
+Terminating are reported along with their finalizers.
+
+Before anything is deleted, the manifests of the chart annotated with
+'helm.sh/hook: pre-delete' are created, in install order. Each Job or Pod
+among them must complete, and is then deleted. A hook that fails stops the
+uninstall with its logs, and nothing is deleted; it is left in the cluster so
+that it can be inspected. Use '--no-hooks' to uninstall without them. A plan
+does not run them.
+
+Resources named with '--keep kind/name', and namespaces with
+'--keep-namespaces', are not deleted. Neither are resources whose manifests
+have a 'helm.sh/resource-policy: keep' or 'helm-keep: "true"' annotation,
+unless '--force' is given. Kept resources are listed in the summary, since
+they remain in the cluster.
+
+With '--purge-orphans', the resources that an earlier version of the chart
+installed, and that it no longer has, are listed and deleted too, before the
+rest of the chart, as 'helmc prune' deletes them. They are found by the labels
+that 'helmc install' gives resources, and in the latest revision of the
+release that 'helmc history' shows.
+
+With '--plan FILE', nothing is deleted, and nothing is asked. Instead, the
+plan of the uninstall is written to FILE, with each resource that would be
+deleted, in order, and each that would be kept. 'helmc apply-plan FILE' runs
+it once it has been reviewed.
+
 This will not alter the charts in your workspace.
 `
 
@@ -21,19 +54,73 @@ var uninstallCmd = cli.Command{
 		minArgs(c, 1, "uninstall")
 
 		client := kubectl.Client
-		for _, chart := range c.Args() {
-			action.Uninstall(chart, home(c), c.String("namespace"), c.Bool("force"), client)
+		kubectl.Defaults.GracePeriod = c.Int("grace-period")
+		o := action.UninstallOptions{
+			Yes:            c.Bool("yes"),
+			Force:          c.Bool("force"),
+			Keep:           c.StringSlice("keep"),
+			KeepNamespaces: c.Bool("keep-namespaces"),
+			NoHooks:        c.Bool("no-hooks"),
+			PurgeOrphans:   c.Bool("purge-orphans"),
+			Plan:           c.String("plan"),
+		}
+		if o.Plan != "" && len(c.Args()) > 1 {
+			die(fmt.Errorf("--plan takes a single chart"))
+		}
+		if c.Bool("wait") {
+			o.Wait = c.Duration("timeout")
+		}
+		for _, arg := range c.Args() {
+			action.Uninstall(chartName(c, arg, workspaceChart), home(c), namespace(c), o, client)
 		}
 	},
 	Flags: []cli.Flag{
 		cli.StringFlag{
 			Name:  "namespace, n",
 			Value: "",
-			Usage: "The Kubernetes destination namespace.",
+			Usage: "The Kubernetes destination namespace. It defaults to the namespace of the chart's Chart.yaml.",
 		},
 		cli.BoolFlag{
-			Name:  "force, aye-aye, y",
+			Name:  "yes, aye-aye, y",
 			Usage: "Do not ask for confirmation.",
 		},
+		cli.BoolFlag{
+			Name:  "force",
+			Usage: "Also delete resources annotated to be kept, and do not ask for confirmation.",
+		},
+		cli.StringSliceFlag{
+			Name:  "keep",
+			Usage: "Do not delete the resource kind/name. May be repeated.",
+		},
+		cli.BoolFlag{
+			Name:  "keep-namespaces",
+			Usage: "Do not delete the chart's namespaces.",
+		},
+		cli.BoolFlag{
+			Name:  "no-hooks",
+			Usage: "Do not run the chart's pre-delete hooks.",
+		},
+		cli.BoolFlag{
+			Name:  "purge-orphans",
+			Usage: "Also delete the resources that an earlier version of the chart installed, and that it no longer has.",
+		},
+		cli.IntFlag{
+			Name:  "grace-period",
+			Value: -1,
+			Usage: "Seconds given to each resource to terminate. A negative value uses the resource's default.",
+		},
+		cli.BoolFlag{
+			Name:  "wait",
+			Usage: "Wait for each kind of resource to be deleted before deleting the next.",
+		},
+		cli.DurationFlag{
+			Name:  "timeout",
+			Value: 5 * time.Minute,
+			Usage: "How long to wait for resources to be deleted, with --wait.",
+		},
+		cli.StringFlag{
+			Name:  "plan",
+			Usage: "Write what the uninstall would do to this file, as JSON, and delete nothing. Run it with 'helmc apply-plan'.",
+		},
 	},
 }
@@ -1,8 +1,12 @@
 package cli
 
 import (
+	"fmt"
+
 	"github.com/codegangsta/cli"
 	"github.com/helm/helm-classic/action"
+	"github.com/helm/helm-classic/config"
+	"github.com/helm/helm-classic/version"
 )
 
 const updateDescription = `This will synchronize the local repository with the upstream GitHub project.
@@ -13,7 +17,22 @@ The first time 'helmc update' is run, the necessary directory structures are
 created and then the Git repository is pulled in full.
 
 Subsequent calls to 'helmc update' will simply synchronize the local cache
-with the remote.`
+with the remote.
+
+Repositories are updated in parallel, up to '--jobs' at a time, and a line
+reports each one as it finishes, with its status and how long it took. If one
+repository fails to update, the others are still updated, and 'helmc update'
+reports every failure before exiting with an error. Use '--fail-fast' to
+update one repository at a time and stop at the first failure.
+
+HTTP repositories that were added with a keyring must publish a signed
+index. An index that fails verification does not replace the cached copy
+unless '--insecure-skip-verify' is given.
+
+After each update, the charts of Git repositories and directory mirrors are
+indexed in the cache, so that 'helmc search', 'helmc info', and 'helmc fetch'
+need not read every Chart.yaml. Only the charts that changed are indexed
+again. Use '--reindex' to index every chart from scratch.`
 
 // updateCmd represents the CLI command for fetching the latest version of all charts from Github.
 var updateCmd = cli.Command{
@@ -23,15 +42,39 @@ var updateCmd = cli.Command{
 	Description: updateDescription,
 	ArgsUsage:   "",
 	Action: func(c *cli.Context) {
+		if c.Bool("fail-fast") && c.IsSet("jobs") {
+			die(fmt.Errorf("--fail-fast updates one repository at a time, so it cannot be combined with --jobs"))
+		}
+		if c.Int("jobs") < 1 {
+			die(fmt.Errorf("--jobs must be at least 1, not %d", c.Int("jobs")))
+		}
 		if !c.Bool("no-version-check") {
-			action.CheckLatest(version)
+			action.CheckLatest(version.Version)
 		}
-		action.Update(home(c))
+		action.Defaults.InsecureSkipVerify = c.Bool("insecure-skip-verify")
+		action.Update(home(c), c.Bool("fail-fast"), c.Bool("reindex"), c.Int("jobs"))
 	},
 	Flags: []cli.Flag{
 		cli.BoolFlag{
 			Name:  "no-version-check",
 			Usage: "Disable Helm Classic's automatic check for newer versions of itself.",
 		},
+		cli.BoolFlag{
+			Name:  "fail-fast",
+			Usage: "Update one repository at a time and stop at the first failure.",
+		},
+		cli.IntFlag{
+			Name:  "jobs",
+			Value: config.MaxParallelUpdates,
+			Usage: "The most repositories to update at once.",
+		},
+		cli.BoolFlag{
+			Name:  "insecure-skip-verify",
+			Usage: "Accept repository indices that fail signature verification.",
+		},
+		cli.BoolFlag{
+			Name:  "reindex",
+			Usage: "Regenerate the metadata index of every repository from scratch.",
+		},
 	},
 }
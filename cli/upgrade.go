@@ -0,0 +1,131 @@
+package cli
+
+import (
+	"github.com/codegangsta/cli"
+	"github.com/helm/helm-classic/action"
+	"github.com/helm/helm-classic/kubectl"
+)
+
+const upgradeDescription = `Change the release of a chart in your workspace to what the chart now
+generates, without uninstalling it first.
+
+The base of the upgrade is the latest revision of the release, as 'helmc
+history' lists it, and its namespace, unless '--namespace' names the same one.
+The manifests of the chart are generated as for 'helmc install', and each is
+compared with the one that the revision sent for the same kind, namespace,
+and name. The chart annotations, which change with every version of the
+chart, are left out of the comparison, so a resource that is left alone keeps
+those of the revision that last changed it, and 'helmc status' shows them.
+
+Manifests that changed, and those that are new, are applied, as by 'kubectl
+apply'. Those that did not change are not sent at all. The resources of the
+revision that the chart no longer has are then deleted, unless they are
+keepers. Hooks are neither run nor deleted. A resource that was deleted by
+hand since the revision, and whose manifest did not change, is not created
+again: use 'helmc install --mode apply' to send every manifest.
+
+An upgrade is recorded in the audit log, and as a new revision of the
+release with all of the chart's manifests, so it can be rolled back with
+'helmc rollback', and upgraded again.`
+
+var upgradeCmd = cli.Command{
+	Name:        "upgrade",
+	Usage:       "Upgrade the release of a chart, sending only the resources that changed.",
+	Description: upgradeDescription,
+	ArgsUsage:   "[chart-name]",
+	Action:      upgrade,
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "namespace, n",
+			Usage: "The Kubernetes namespace of the release. It defaults to that of its latest revision.",
+		},
+		cli.BoolFlag{
+			Name:  "force, aye-aye",
+			Usage: "Upgrade even if dependencies are unsatisfied.",
+		},
+		cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "Only display the underlying kubectl commands.",
+		},
+		cli.BoolFlag{
+			Name:  "generate,g",
+			Usage: "Run the generator before upgrading.",
+		},
+		cli.BoolFlag{
+			Name:  "skip-schema",
+			Usage: "With --generate, render templates without validating their values against the chart's values schema.",
+		},
+		cli.StringSliceFlag{
+			Name:  "exclude,x",
+			Usage: "Files or directories to exclude from the generator (if -g is set).",
+		},
+		cli.StringSliceFlag{
+			Name:  "values,f",
+			Usage: "Merge a values file over the chart's values.yaml for its templates. Can be given more than once; later files win.",
+		},
+		cli.StringSliceFlag{
+			Name:  "set",
+			Usage: "Set a value of the templates, as KEY=VALUE, such as image.tag=1.2. Wins over the values files. Can be given more than once.",
+		},
+		cli.StringSliceFlag{
+			Name:  "set-from",
+			Usage: "Set a value of the templates from a source, as KEY=env:NAME, KEY=file:PATH, KEY=cmd:COMMAND, KEY=pass:NAME, or KEY=sops:PATH[#KEY] (if -g is set). Can be given more than once.",
+		},
+		cli.BoolFlag{
+			Name:  "allow-exec-values",
+			Usage: "Allow the cmd:, pass:, and sops: value sources, which run a command.",
+		},
+		envFlag,
+		cli.BoolFlag{
+			Name:  "no-annotations",
+			Usage: "Do not annotate resources with the chart's name, version, and digest.",
+		},
+		cli.BoolFlag{
+			Name:  "no-labels",
+			Usage: "Do not label resources with heritage=helm-classic and chart=NAME-VERSION.",
+		},
+		cli.StringFlag{
+			Name:  "output,o",
+			Usage: "Format of the upgrade summary. Use 'json' for machine-readable output.",
+		},
+		cli.BoolFlag{
+			Name:  "accept-deprecated",
+			Usage: "Upgrade to a deprecated chart even if fetch.strict is set.",
+		},
+		cli.IntFlag{
+			Name:  "max-documents",
+			Usage: "The most manifests that the chart may install. Overrides install.maxDocuments; a negative number is no limit.",
+		},
+		cli.IntFlag{
+			Name:  "max-document-size",
+			Usage: "The largest manifest that the chart may install, in KiB. Overrides install.maxDocumentKB; a negative number is no limit.",
+		},
+		cli.IntFlag{
+			Name:  "max-total-size",
+			Usage: "The total size of the manifests that the chart may install, in MiB. Overrides install.maxTotalMB; a negative number is no limit.",
+		},
+	},
+}
+
+func upgrade(c *cli.Context) {
+	minArgs(c, 1, "upgrade")
+	die(useEnv(c))
+	client := kubectl.Client
+	if c.Bool("dry-run") {
+		client = kubectl.PrintRunner{}
+	}
+	die(action.Upgrade(chartName(c, c.Args()[0], workspaceChart), home(c), action.InstallOptions{
+		Namespace:  namespace(c),
+		Force:      c.Bool("force"),
+		Generate:   c.Bool("generate"),
+		SkipSchema: c.Bool("skip-schema"),
+		Exclude:    c.StringSlice("exclude"),
+		Values:     valueSources(c),
+		Output:     c.String("output"),
+		Annotate:   !c.Bool("no-annotations"),
+		Labels:     !c.Bool("no-labels"),
+		Limits:     installLimits(c),
+
+		AcceptDeprecated: c.Bool("accept-deprecated"),
+	}, client))
+}
@@ -1,15 +1,306 @@
 package cli
 
 import (
+	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strconv"
 
 	"github.com/codegangsta/cli"
+	"github.com/helm/helm-classic/action"
+	"github.com/helm/helm-classic/config"
+	"github.com/helm/helm-classic/helmpath"
+	"github.com/helm/helm-classic/kubectl"
 	"github.com/helm/helm-classic/log"
+	"github.com/helm/helm-classic/output"
+	helm "github.com/helm/helm-classic/util"
+	"golang.org/x/crypto/ssh/terminal"
 )
 
-// home runs the --home flag through os.ExpandEnv.
+// resolvedHome is the home directory, once it has been resolved.
+var resolvedHome helmpath.Home
+
+// home returns the Helm Classic home directory.
+//
+// A --home flag given to the command takes precedence over the global one.
+// The home is resolved and created the first time it is needed, and the
+// same home is used for the rest of the invocation.
 func home(c *cli.Context) string {
-	return os.ExpandEnv(c.GlobalString("home"))
+	if resolvedHome != "" {
+		return resolvedHome.String()
+	}
+	flag := c.String("home")
+	if flag == "" {
+		flag = c.GlobalString("home")
+	}
+	h, err := helmpath.Resolve(flag)
+	if err != nil {
+		log.Die("Could not resolve the Helm Classic home: %s", err)
+	}
+	if _, err := h.Ensure(); err != nil {
+		log.Die("Could not create the Helm Classic home: %s", err)
+	}
+	log.Debug("Home: %s (config: %s, cache: %s, workspace: %s)", h, h.Config(), h.Cache(), h.Workspace())
+	resolvedHome = h
+	return h.String()
+}
+
+// kubectlPath returns the kubectl binary to use.
+//
+// --kubectl-path (or $HELMC_KUBECTL) takes precedence over the kubectl entry
+// of the configuration file.
+func kubectlPath(c *cli.Context) string {
+	if p := c.GlobalString("kubectl-path"); p != "" {
+		return p
+	}
+	cfg := globalConfig(c)
+	if cfg == nil || cfg.Kubectl == nil || cfg.Kubectl.Path == "" {
+		return kubectl.DefaultPath
+	}
+	return cfg.Kubectl.Path
+}
+
+// globalConfig returns the configuration file of the global home, or nil if
+// it cannot be read. The file is only read, never created, so that a missing
+// home is left for the command to report.
+func globalConfig(c *cli.Context) *config.Configfile {
+	h, err := helmpath.Resolve(c.GlobalString("home"))
+	if err != nil {
+		return nil
+	}
+	data, err := ioutil.ReadFile(h.Config())
+	if err != nil {
+		return nil
+	}
+	cfg, err := config.Parse(data)
+	if err != nil {
+		return nil
+	}
+	return cfg
+}
+
+// kubectlArgs returns the extra kubectl flags of the configuration file.
+func kubectlArgs(c *cli.Context) kubectl.Args {
+	cfg := globalConfig(c)
+	if cfg == nil || cfg.Kubectl == nil {
+		return kubectl.Args{}
+	}
+	return kubectl.Args{Apply: cfg.Kubectl.ApplyArgs, Delete: cfg.Kubectl.DeleteArgs}
+}
+
+// profile is the profile that --profile or --env selected, if any, and
+// profileName its name.
+var (
+	profile     *config.Profile
+	profileName string
+)
+
+// useProfile selects the profile that --profile (or $HELMC_PROFILE) names,
+// and makes its kubeconfig and context the defaults of --kubeconfig and
+// --kube-context. An unknown profile is an error.
+func useProfile(c *cli.Context) error {
+	profile, profileName = nil, ""
+	return selectProfile(c, c.GlobalString("profile"))
+}
+
+// envFlag is the flag of the commands that render charts for an
+// environment.
+var envFlag = cli.StringFlag{
+	Name:  "env",
+	Usage: "The environment: the profile to use, as --profile selects it, whose values-ENV.yaml of the chart is merged over its values.yaml.",
+}
+
+// useEnv selects the profile that --env names, as useProfile does. It is an
+// error if --profile names another.
+func useEnv(c *cli.Context) error {
+	name := c.String("env")
+	if name == "" || name == profileName {
+		return nil
+	}
+	if profileName != "" {
+		return fmt.Errorf("--env %s and --profile %s name different environments", name, profileName)
+	}
+	return selectProfile(c, name)
+}
+
+// selectProfile selects the named profile, if name is set.
+func selectProfile(c *cli.Context, name string) error {
+	if name == "" {
+		return nil
+	}
+	cfg := globalConfig(c)
+	if cfg == nil {
+		cfg = &config.Configfile{}
+	}
+	p, err := cfg.Profile(name)
+	if err != nil {
+		return err
+	}
+	profile, profileName = p, name
+	log.Debug("Using the profile %s: %+v", name, *p)
+	if kubectl.Defaults.Context == "" {
+		kubectl.Defaults.Context = p.KubeContext
+	}
+	if kubectl.Defaults.Kubeconfig == "" && p.Kubeconfig != "" {
+		kubectl.Defaults.Kubeconfig = helmpath.ExpandHome(os.ExpandEnv(p.Kubeconfig))
+	}
+	return nil
+}
+
+// namespace returns the namespace that --namespace gives, or else that of
+// the profile.
+func namespace(c *cli.Context) string {
+	if ns := c.String("namespace"); ns != "" || profile == nil {
+		return ns
+	}
+	return profile.Namespace
+}
+
+// outputFormat returns the format that --output gives, or else that of the
+// global --output.
+func outputFormat(c *cli.Context) string {
+	if f := c.String("output"); f != "" {
+		return f
+	}
+	return output.Format
+}
+
+// valueSources returns the value sources of --values, --set, --set-from, and
+// --allow-exec-values, and the environment of the profile.
+func valueSources(c *cli.Context) action.ValueSources {
+	return action.ValueSources{
+		Files:     c.StringSlice("values"),
+		Set:       c.StringSlice("set"),
+		SetFrom:   c.StringSlice("set-from"),
+		AllowExec: c.Bool("allow-exec-values"),
+		Env:       profileName,
+	}
+}
+
+// signPath resolves the chart argument of sign and verify, and returns the
+// path of the chart: its directory, or its archive.
+func signPath(c *cli.Context, arg string) string {
+	ref := chartArg(c, arg, signChart)
+	if ref.Source == action.ChartInArchive {
+		return ref.Name
+	}
+	return ref.Dir
+}
+
+// verifyFlag and keyringFlag are the flags of the commands that verify the
+// charts they fetch.
+var (
+	verifyFlag = cli.BoolFlag{
+		Name:  "verify",
+		Usage: "Verify the chart against its provenance file, and the keys of --keyring, before it is used.",
+	}
+	keyringFlag = cli.StringFlag{
+		Name:  "keyring",
+		Value: action.DefaultKeyring,
+		Usage: "The keyring of the public keys that --verify accepts.",
+	}
+)
+
+// verifyKeyring returns the keyring that --verify checks charts against, or
+// "" without --verify.
+func verifyKeyring(c *cli.Context) string {
+	if !c.Bool("verify") {
+		return ""
+	}
+	return c.String("keyring")
+}
+
+// The lookups of the commands that take a chart argument, for chartArg.
+var (
+	// workspaceChart is a chart of the workspace.
+	workspaceChart = action.ChartLookup{Workspace: true}
+	// installChart is a chart of the workspace, or of a repository or an
+	// archive, which is fetched first.
+	installChart = action.ChartLookup{Workspace: true, Repos: true, Archive: true}
+	// depsChart is a chart of the workspace, or of a repository, which is
+	// not fetched.
+	depsChart = action.ChartLookup{Workspace: true, Repos: true}
+	// repoChart is a chart of a repository.
+	repoChart = action.ChartLookup{Repos: true}
+	// fetchChart is a chart of a repository, or an archive.
+	fetchChart = action.ChartLookup{Repos: true, Archive: true}
+	// lintChart is a chart of the workspace, or any chart directory.
+	lintChart = action.ChartLookup{Path: true, Workspace: true}
+	// signChart is a chart of the workspace, any chart directory, or an
+	// archive.
+	signChart = action.ChartLookup{Path: true, Workspace: true, Archive: true}
+)
+
+// chartArg resolves the chart argument of a command with
+// action.ResolveChart. An argument that is ambiguous, or that the command
+// does not take, exits.
+//
+// Every command that takes a chart resolves it here, so that they all agree
+// on what a chart argument means.
+func chartArg(c *cli.Context, arg string, l action.ChartLookup) *action.ChartRef {
+	lastChart = arg
+	ref, err := action.ResolveChart(arg, home(c), l)
+	die(err)
+	lastChart = ref.Name
+	return ref
+}
+
+// chartName resolves the chart argument of a command, and returns what its
+// action takes.
+func chartName(c *cli.Context, arg string, l action.ChartLookup) string {
+	return chartArg(c, arg, l).Name
+}
+
+// traceLevel is the value of a flag that may be given more than once.
+//
+// Like a boolean flag, it needs no value, but each use raises the level by
+// one, so that --trace-git --trace-git is the same as --trace-git=2.
+type traceLevel int
+
+// Set raises the level for "true", clears it for "false", and otherwise sets it to a number.
+func (t *traceLevel) Set(v string) error {
+	switch v {
+	case "true":
+		*t++
+	case "false":
+		*t = 0
+	default:
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return fmt.Errorf("expected a level such as 1 or 2, not %q", v)
+		}
+		*t = traceLevel(n)
+	}
+	return nil
+}
+
+func (t *traceLevel) String() string {
+	return strconv.Itoa(int(*t))
+}
+
+// IsBoolFlag lets the flag be given without a value.
+func (t *traceLevel) IsBoolFlag() bool {
+	return true
+}
+
+// homeFlag is added to every command, so that --home may follow the command name.
+var homeFlag = cli.StringFlag{
+	Name:  "home",
+	Usage: "The location of your Helm Classic files. Overrides $HELMC_HOME and the platform default",
+}
+
+// addHomeFlag adds homeFlag to each command and its subcommands.
+//
+// The flags and subcommands are copied first, since the command definitions
+// are shared by every app that Cli builds.
+func addHomeFlag(cmds []cli.Command) {
+	for i := range cmds {
+		flags := cmds[i].Flags
+		cmds[i].Flags = append(flags[:len(flags):len(flags)], homeFlag)
+		cmds[i].Subcommands = append([]cli.Command{}, cmds[i].Subcommands...)
+		addHomeFlag(cmds[i].Subcommands)
+	}
 }
 
 // minArgs checks to see if the right number of args are passed.
@@ -26,3 +317,36 @@ func minArgs(c *cli.Context, i int, name string) {
 		log.Die("")
 	}
 }
+
+// progressMode returns how long operations show their progress: on a single
+// line if stderr is a terminal, and otherwise logged from time to time, so
+// that CI logs are not flooded.
+func progressMode(off bool) int {
+	switch {
+	case off:
+		return helm.ProgressOff
+	case terminal.IsTerminal(int(os.Stderr.Fd())):
+		return helm.ProgressTerminal
+	}
+	return helm.ProgressLog
+}
+
+// eventsFileEnvVar names the events file of --events-file.
+const eventsFileEnvVar = "HELMC_EVENTS_FILE"
+
+// useEventsFile makes the package-level operations append their events to
+// path, if it is set. The path is made absolute, and exported as
+// $HELMC_EVENTS_FILE, so that the helmc of a generator or plugin, which runs
+// in another directory, appends to the same file.
+func useEventsFile(path string) error {
+	action.Defaults.EventsFile = ""
+	if path == "" {
+		return nil
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("Could not use the events file %s: %s", path, err)
+	}
+	action.Defaults.EventsFile = abs
+	return os.Setenv(eventsFileEnvVar, abs)
+}
@@ -0,0 +1,36 @@
+package cli
+
+import (
+	"github.com/codegangsta/cli"
+	"github.com/helm/helm-classic/action"
+)
+
+const verifyDescription = `Check a chart against its provenance file, as 'helmc sign' writes it.
+
+The chart is a chart of the workspace, a chart directory, or a chart archive.
+Its provenance file is next to it, named after it with '.prov'. The file must
+be signed by a key of '--keyring', and give the name, the version, and the
+checksum of the chart. The signer is printed.
+
+A chart of the workspace that was fetched no longer matches the provenance
+file of its repository, since fetching changes its Chart.yaml. Use 'helmc
+fetch --verify' to verify a chart as it is fetched.
+`
+
+var verifyCmd = cli.Command{
+	Name:        "verify",
+	Usage:       "Verify a chart against its signed provenance file.",
+	Description: verifyDescription,
+	ArgsUsage:   "[chart]",
+	Action: func(c *cli.Context) {
+		minArgs(c, 1, "verify")
+		die(action.Verify(signPath(c, c.Args()[0]), c.String("keyring")))
+	},
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "keyring",
+			Value: action.DefaultKeyring,
+			Usage: "The keyring of the public keys that may have signed the chart.",
+		},
+	},
+}
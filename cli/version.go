@@ -0,0 +1,38 @@
+package cli
+
+import (
+	"github.com/codegangsta/cli"
+	"github.com/helm/helm-classic/action"
+	"github.com/helm/helm-classic/kubectl"
+)
+
+const versionDescription = `Prints the version of Helm Classic, with the Git commit, build date and Go
+version it was built with. Please include this when reporting a bug.
+
+With '--server', the versions of kubectl and of the Kubernetes API server are
+shown too. If the cluster cannot be reached, a warning is printed instead.
+`
+
+var versionCmd = cli.Command{
+	Name:        "version",
+	Usage:       "Print the version of helmc, and optionally of Kubernetes.",
+	Description: versionDescription,
+	ArgsUsage:   "",
+	Action: func(c *cli.Context) {
+		action.Version(c.String("output"), c.Bool("short"), c.Bool("server"), kubectl.Client)
+	},
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "server",
+			Usage: "Also print the Kubernetes client and server versions.",
+		},
+		cli.BoolFlag{
+			Name:  "short",
+			Usage: "Print only the version number.",
+		},
+		cli.StringFlag{
+			Name:  "output,o",
+			Usage: "Output format. Use 'json' for machine-readable output.",
+		},
+	},
+}
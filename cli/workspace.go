@@ -0,0 +1,50 @@
+package cli
+
+import (
+	"github.com/codegangsta/cli"
+	"github.com/helm/helm-classic/action"
+)
+
+const workspaceGCDescription = `Delete the workspace charts whose origin is gone.
+
+A chart's origin is the repository and chart it was fetched from, as recorded
+in its Chart.yaml. The origin is gone when the repository is no longer
+configured, or no longer has the chart. Charts that were never fetched, such as
+those made with 'helmc create', are left alone.
+
+Each such chart is listed with its digest, when it was last modified, whether
+any of its manifests are in Kubernetes, and whether it has local changes. The
+changes are found by comparing the chart with every cached chart of the same
+name, in any repository: 'modified' means it differs from all of them, and
+'unknown' that there is none to compare with.
+
+Charts with local modifications are never deleted, nor are charts that appear
+to be installed, or whose installation could not be checked. The others are
+deleted once you confirm, or with --yes. Use --dry-run to print the report
+without deleting anything.
+`
+
+var workspaceCmd = cli.Command{
+	Name:  "workspace",
+	Usage: "Manage the charts in your workspace.",
+	Subcommands: []cli.Command{
+		{
+			Name:        "gc",
+			Usage:       "Delete workspace charts whose source repository no longer has them.",
+			Description: workspaceGCDescription,
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "yes, y",
+					Usage: "Delete without asking first.",
+				},
+				cli.BoolFlag{
+					Name:  "dry-run",
+					Usage: "Print the report, and delete nothing.",
+				},
+			},
+			Action: func(c *cli.Context) {
+				action.WorkspaceGC(home(c), c.Bool("yes"), c.Bool("dry-run"))
+			},
+		},
+	},
+}
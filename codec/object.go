@@ -111,6 +111,37 @@ func (m *Object) AddAnnotations(ann map[string]string) error {
 	return m.addMDItem("annotations", ann)
 }
 
+// SetDefaultNamespace sets the namespace of an object to ns, unless its
+// metadata already has one. It returns true if it set it.
+//
+// See the notes on AddLabels for performance implications.
+func (m *Object) SetDefaultNamespace(ns string) (bool, error) {
+	var d interface{}
+	if err := m.dec(m.data, &d); err != nil {
+		return false, err
+	}
+	val, ok := d.(map[string]interface{})
+	if !ok {
+		return false, errors.New("Top level object is not a map")
+	}
+	md, ok := val["metadata"].(map[string]interface{})
+	if !ok {
+		md = map[string]interface{}{}
+		val["metadata"] = md
+	}
+	if s, ok := md["namespace"].(string); ok && s != "" {
+		return false, nil
+	}
+	md["namespace"] = ns
+
+	var b bytes.Buffer
+	if err := YAML.Encode(&b).One(d); err != nil {
+		return false, err
+	}
+	m.data = b.Bytes()
+	return true, nil
+}
+
 // addMDItem adds the given key/hash combo to a generic object.
 //
 // TODO: In the future we might want to make this more flexible. If it turns
@@ -128,8 +159,8 @@ func (m *Object) addMDItem(key string, value map[string]string) error {
 				key: value,
 			}
 		} else if mmd, ok := md.(map[string]interface{}); ok {
-			if l, ok := mmd[key]; ok {
-				ll := l.(map[string]interface{})
+			// Existing entries with other keys are kept.
+			if ll, ok := mmd[key].(map[string]interface{}); ok {
 				for k, v := range value {
 					ll[k] = v
 				}
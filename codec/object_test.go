@@ -144,3 +144,52 @@ func TestAddLabels(t *testing.T) {
 		t.Errorf("Failed to decode into pod: %s", err)
 	}
 }
+
+func TestAddAnnotations(t *testing.T) {
+	for _, in := range []string{
+		"kind: Pod\nmetadata:\n  name: redis\n  annotations:\n    team: data\n",
+		"kind: Pod\nmetadata:\n  name: redis\n  annotations:\n",
+	} {
+		m, err := YAML.Decode([]byte(in)).One()
+		if err != nil {
+			t.Fatalf("Failed parse: %s", err)
+		}
+		if err := m.AddAnnotations(map[string]string{"chart.helm.sh/name": "redis"}); err != nil {
+			t.Fatalf("Failed to add annotations: %s", err)
+		}
+		meta, err := m.Meta()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if meta.Annotations["chart.helm.sh/name"] != "redis" {
+			t.Errorf("Expected the annotation to be added: %v", meta.Annotations)
+		}
+		if strings.Contains(in, "team") && meta.Annotations["team"] != "data" {
+			t.Errorf("Expected existing annotations to be kept: %v", meta.Annotations)
+		}
+	}
+}
+
+func TestSetDefaultNamespace(t *testing.T) {
+	for in, expect := range map[string]string{
+		"kind: Pod\nmetadata:\n  name: redis\n":                    "cache",
+		"kind: Pod\nmetadata:\n  name: redis\n  namespace: data\n": "data",
+		"kind: Pod\n": "cache",
+	} {
+		m, err := YAML.Decode([]byte(in)).One()
+		if err != nil {
+			t.Fatalf("Failed parse: %s", err)
+		}
+		set, err := m.SetDefaultNamespace("cache")
+		if err != nil {
+			t.Fatal(err)
+		}
+		meta, err := m.Meta()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if meta.Namespace != expect || set != (expect == "cache") {
+			t.Errorf("Expected namespace %q of %q, got %q (set: %t)", expect, in, meta.Namespace, set)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package config
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/helm/helm-classic/helmpath"
+)
+
+// Auth describes the credentials used to access a private repository.
+//
+// Git repositories reached over SSH use SSHKey. HTTPS repositories, whether
+// Git or HTTP, use Username and a token. The token is never stored in the
+// configuration file; it is read from an environment variable (TokenFrom), a
+// file (TokenFile), or the credentials file of the home (TokenStored).
+type Auth struct {
+	// SSHKey is the path to a private key for SSH Git URLs.
+	SSHKey string `yaml:"sshKey,omitempty"`
+	// Username is the HTTPS user name. If it is empty, the token is sent as a bearer token.
+	Username string `yaml:"username,omitempty"`
+	// TokenFrom names an environment variable that holds the token.
+	TokenFrom string `yaml:"tokenFrom,omitempty"`
+	// TokenFile is the path to a file that holds the token.
+	TokenFile string `yaml:"tokenFile,omitempty"`
+	// TokenStored keeps the token in the credentials file of the home, under
+	// the local name of the repository. See Credentials.
+	TokenStored bool `yaml:"tokenStored,omitempty"`
+
+	// stored is the token of the credentials file, and storedErr why it
+	// could not be read.
+	stored    string
+	storedErr error
+}
+
+// ForgetStoredToken stops using the token of the credentials file.
+func (a *Auth) ForgetStoredToken() {
+	a.TokenStored, a.stored, a.storedErr = false, "", nil
+}
+
+// UseStoredToken makes tok the token of the credentials file, as it is stored
+// there. It is for a repository that is not saved yet.
+func (a *Auth) UseStoredToken(tok string) {
+	a.TokenFrom, a.TokenFile = "", ""
+	a.TokenStored, a.stored, a.storedErr = true, tok, nil
+}
+
+// String describes the authentication method without revealing any secret.
+func (a *Auth) String() string {
+	methods := []string{}
+	if a.SSHKey != "" {
+		methods = append(methods, "ssh key "+a.SSHKey)
+	}
+	switch {
+	case a.TokenFrom != "":
+		methods = append(methods, "token from $"+a.TokenFrom)
+	case a.TokenFile != "":
+		methods = append(methods, "token from file "+a.TokenFile)
+	case a.TokenStored:
+		methods = append(methods, "stored token")
+	}
+	if len(methods) == 0 {
+		return "none"
+	}
+	return strings.Join(methods, ", ")
+}
+
+// token reads the token from its environment variable or file.
+func (a *Auth) token() (string, error) {
+	switch {
+	case a.TokenFrom != "":
+		tok := os.Getenv(a.TokenFrom)
+		if tok == "" {
+			return "", fmt.Errorf("environment variable %s is not set", a.TokenFrom)
+		}
+		return tok, nil
+	case a.TokenFile != "":
+		b, err := ioutil.ReadFile(helmpath.ExpandHome(a.TokenFile))
+		if err != nil {
+			return "", fmt.Errorf("could not read token file %s", a.TokenFile)
+		}
+		return strings.TrimSpace(string(b)), nil
+	case a.TokenStored:
+		if a.storedErr != nil {
+			return "", a.storedErr
+		}
+		if a.stored == "" {
+			return "", errors.New("no token is stored for it. Store one with 'helmc repo login'")
+		}
+		return a.stored, nil
+	}
+	return "", nil
+}
+
+// header returns the value of the HTTP Authorization header, or "" if there is no token.
+func (a *Auth) header() (string, error) {
+	tok, err := a.token()
+	if err != nil || tok == "" {
+		return "", err
+	}
+	if a.Username == "" {
+		return "Bearer " + tok, nil
+	}
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(a.Username+":"+tok)), nil
+}
+
+// gitEnv returns the environment variables that pass the credentials to git.
+//
+// The token is passed as an extra HTTP header through git's environment
+// configuration, so it never appears on a command line or in .git/config.
+func (a *Auth) gitEnv() (map[string]string, error) {
+	env := map[string]string{}
+	if a.SSHKey != "" {
+		key := helmpath.ExpandHome(a.SSHKey)
+		if _, err := os.Stat(key); err != nil {
+			return nil, fmt.Errorf("ssh key %s not found", a.SSHKey)
+		}
+		env["GIT_SSH_COMMAND"] = fmt.Sprintf("ssh -i %q -o IdentitiesOnly=yes", key)
+	}
+	h, err := a.header()
+	if err != nil {
+		return nil, err
+	}
+	if h != "" {
+		env["GIT_CONFIG_COUNT"] = "1"
+		env["GIT_CONFIG_KEY_0"] = "http.extraHeader"
+		env["GIT_CONFIG_VALUE_0"] = "Authorization: " + h
+	}
+	return env, nil
+}
+
+// withGit runs fn with the table's Git backend.
+//
+// The backend is given the table's credentials. The exec backend passes them
+// to git through its environment, so they never appear on a command line or
+// in this process's environment. Errors are annotated with the repository
+// name and the authentication method that was attempted.
+func (r *Repos) withGit(t *Table, fn func(gb gitBackend) error) error {
+	name := r.backendName(t)
+	newBackend, ok := gitBackends[name]
+	if !ok {
+		return fmt.Errorf("Repository '%s': %s", t.Name, CheckGitBackend(name))
+	}
+	gb, err := newBackend(t, r)
+	if err != nil {
+		return authError(t, err)
+	}
+	if err = fn(gb); err != nil && t.Auth != nil {
+		return authError(t, err)
+	}
+	return err
+}
+
+// authorization returns the HTTP Authorization header for an HTTP repository.
+func (t *Table) authorization() (string, error) {
+	if t.Auth == nil {
+		return "", nil
+	}
+	h, err := t.Auth.header()
+	if err != nil {
+		return "", authError(t, err)
+	}
+	return h, nil
+}
+
+func authError(t *Table, err error) error {
+	return fmt.Errorf("Repository '%s' (auth: %s): %w", t.Name, t.Auth, err)
+}
@@ -0,0 +1,150 @@
+package config
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/helm/helm-classic/test"
+)
+
+func TestAuthHeader(t *testing.T) {
+	os.Setenv("HELMC_TEST_TOKEN", "s3cret")
+	defer os.Unsetenv("HELMC_TEST_TOKEN")
+
+	a := &Auth{TokenFrom: "HELMC_TEST_TOKEN"}
+	if h, err := a.header(); err != nil || h != "Bearer s3cret" {
+		t.Errorf("Expected bearer token, got %q (%v)", h, err)
+	}
+
+	a.Username = "bot"
+	if h, err := a.header(); err != nil || h != "Basic Ym90OnMzY3JldA==" {
+		t.Errorf("Expected basic auth, got %q (%v)", h, err)
+	}
+
+	if s := a.String(); strings.Contains(s, "s3cret") || s != "token from $HELMC_TEST_TOKEN" {
+		t.Errorf("Unexpected description %q", s)
+	}
+
+	missing := &Auth{TokenFrom: "HELMC_TEST_NO_SUCH_TOKEN"}
+	if _, err := missing.header(); err == nil {
+		t.Errorf("Expected an error for an unset token variable")
+	}
+}
+
+func TestAuthTokenFile(t *testing.T) {
+	f, err := ioutil.TempFile("", "helmc-token-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("filetoken\n")
+	f.Close()
+
+	a := &Auth{TokenFile: f.Name()}
+	if tok, err := a.token(); err != nil || tok != "filetoken" {
+		t.Errorf("Expected filetoken, got %q (%v)", tok, err)
+	}
+}
+
+func TestWithGitAuth(t *testing.T) {
+	key := filepath.Join(test.HelmRoot, "testdata", "config.yaml")
+	os.Unsetenv("GIT_SSH_COMMAND")
+
+	tbl := &Table{Name: "private", Auth: &Auth{SSHKey: key}}
+	r := &Repos{}
+	var seen string
+	err := r.withGit(tbl, func(gb gitBackend) error {
+		seen = gb.(*gitRunner).env["GIT_SSH_COMMAND"]
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(seen, key) {
+		t.Errorf("Expected GIT_SSH_COMMAND to use %s, got %q", key, seen)
+	}
+	if v := os.Getenv("GIT_SSH_COMMAND"); v != "" {
+		t.Errorf("Expected the process environment to be left alone, got %q", v)
+	}
+
+	tbl.Auth.SSHKey = "/no/such/key"
+	err = r.withGit(tbl, func(gitBackend) error { return nil })
+	if err == nil || !strings.Contains(err.Error(), "Repository 'private' (auth: ssh key /no/such/key)") {
+		t.Errorf("Expected an error naming the repo and auth method, got %v", err)
+	}
+}
+
+func TestHTTPRepoAuth(t *testing.T) {
+	os.Setenv("HELMC_TEST_TOKEN", "s3cret")
+	defer os.Unsetenv("HELMC_TEST_TOKEN")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer s3cret" {
+			http.Error(w, "denied", http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("entries: {}\n"))
+	}))
+	defer ts.Close()
+
+	cache := test.CreateTmpHome()
+	defer os.RemoveAll(cache)
+
+	r := &Repos{Dir: cache}
+	if err := r.Add(&Table{Name: "anon", Repo: ts.URL + "/index.yaml"}); err == nil {
+		t.Errorf("Expected unauthenticated request to fail")
+	}
+
+	r = &Repos{Dir: cache}
+	tbl := &Table{Name: "private", Repo: ts.URL + "/index.yaml", Auth: &Auth{TokenFrom: "HELMC_TEST_TOKEN"}}
+	if err := r.Add(tbl); err != nil {
+		t.Fatalf("Expected authenticated request to succeed: %s", err)
+	}
+
+	os.Setenv("HELMC_TEST_TOKEN", "wrong")
+	err := r.Update("private")
+	if err == nil {
+		t.Fatal("Expected a bad token to fail")
+	}
+	if msg := err.Error(); strings.Contains(msg, "wrong") || !strings.Contains(msg, "auth: token from $HELMC_TEST_TOKEN") {
+		t.Errorf("Unexpected error %q", msg)
+	}
+}
+
+func TestAuthStoredToken(t *testing.T) {
+	home, err := ioutil.TempDir("", "helmc-credentials-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(home)
+
+	repos := &Repos{Tables: []*Table{
+		{Name: "private", Auth: &Auth{Username: "bot", TokenStored: true}},
+		{Name: "other", Auth: &Auth{TokenStored: true}},
+	}}
+	creds, err := LoadCredentials(home)
+	if err != nil {
+		t.Fatal(err)
+	}
+	creds.Set("private", "s3cret")
+	if err := creds.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	repos.useCredentials(home)
+	a := repos.Tables[0].Auth
+	if tok, err := a.token(); err != nil || tok != "s3cret" {
+		t.Errorf("Expected the stored token, got %q (%v)", tok, err)
+	}
+	if s := a.String(); strings.Contains(s, "s3cret") {
+		t.Errorf("Expected the description to leave out the token, got %q", s)
+	}
+	if _, err := repos.Tables[1].Auth.token(); err == nil || !strings.Contains(err.Error(), "helmc repo login") {
+		t.Errorf("Expected a repository without a stored token to fail, got %v", err)
+	}
+}
@@ -0,0 +1,119 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Git backends.
+const (
+	// BackendExec runs the git binary. It is the default.
+	BackendExec = "exec"
+	// BackendNative uses a Git implementation written in Go, so that no git
+	// binary is needed.
+	BackendNative = "native"
+)
+
+// DefaultGitTimeout is the GitTimeout that helmc uses, unless it is told
+// otherwise.
+const DefaultGitTimeout = 5 * time.Minute
+
+// gitBackend clones the cached copy of one Git repository, and opens it.
+//
+// A backend is made for each operation on a table, with the table's
+// credentials. See gitBackends.
+type gitBackend interface {
+	// clone clones url into dir. Unless full is set, only the most recent
+	// commit of each branch is fetched.
+	clone(url, dir string, full bool) error
+	// open returns the clone in dir.
+	open(dir string) (gitClone, error)
+	// lsRemote checks that url is a Git repository that can be read.
+	lsRemote(url string) error
+}
+
+// gitClone is a local clone of a Git repository, whose remote is "origin".
+type gitClone interface {
+	// path returns the directory of the clone.
+	path() string
+	// isDirty returns true if tracked files have changes that are not committed.
+	isDirty() bool
+	// head returns the commit that is checked out.
+	head() (string, error)
+	// detached returns true if HEAD is not a branch.
+	detached() bool
+	// resolve returns the commit that a ref, such as refs/tags/v1, points to.
+	resolve(ref string) (string, error)
+	// fetch fetches the branches of origin. If tags is set, every tag is
+	// fetched too, and tags that moved are updated. If refspecs are given,
+	// only they are fetched, and only their most recent commit.
+	fetch(tags bool, refspecs ...string) error
+	// unshallow fetches the history that a shallow clone does not have.
+	unshallow() error
+	// checkout checks out a branch or a tag.
+	checkout(ref string) error
+	// defaultBranch returns the branch that HEAD of origin points to.
+	defaultBranch() (string, error)
+	// merge fast-forwards the current branch to the branch of origin.
+	merge(branch string) error
+	// pull fast-forwards the current branch to its upstream, as fetched.
+	pull() error
+	// diff lists the top-level entries that changed between a commit and
+	// HEAD, in the form of `git diff-tree --name-status`.
+	diff(since string) (string, error)
+	// commit commits every change, including new files. If nothing changed,
+	// it does nothing.
+	commit(message string) error
+	// push pushes the current branch to the branch of the same name on origin.
+	push() error
+}
+
+// gitBackends make the backend of a table, by name.
+//
+// The native backend adds itself in git_native.go.
+var gitBackends = map[string]func(t *Table, r *Repos) (gitBackend, error){
+	BackendExec: newExecBackend,
+}
+
+// GitBackends returns the names of the Git backends.
+func GitBackends() []string {
+	names := make([]string, 0, len(gitBackends))
+	for name := range gitBackends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CheckGitBackend returns an error if there is no Git backend by that name.
+//
+// An empty name is the default backend.
+func CheckGitBackend(name string) error {
+	if _, ok := gitBackends[name]; !ok && name != "" {
+		return fmt.Errorf("Unknown Git backend %q (use %s)", name, strings.Join(GitBackends(), " or "))
+	}
+	return nil
+}
+
+// backendName returns the name of the Git backend for a table.
+//
+// A table's own backend wins over GitBackend.
+func (r *Repos) backendName(t *Table) string {
+	switch {
+	case t.Backend != "":
+		return t.Backend
+	case r.GitBackend != "":
+		return r.GitBackend
+	}
+	return BackendExec
+}
+
+// isShallow returns true if a local clone has only part of its history.
+func isShallow(g gitClone) bool {
+	_, err := os.Stat(filepath.Join(g.path(), ".git", "shallow"))
+	return err == nil
+}
@@ -0,0 +1,174 @@
+package config
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/helm/helm-classic/log"
+	"github.com/helm/helm-classic/test"
+	"github.com/helm/helm-classic/util"
+)
+
+// TestGitBackends runs the same operations with every Git backend, each
+// against its own copy of a local fixture repository, and checks that they
+// leave identical caches.
+func TestGitBackends(t *testing.T) {
+	// Fixed dates make the commits on each copy of the fixture identical.
+	for k, v := range map[string]string{
+		"GIT_AUTHOR_NAME":     "helmc",
+		"GIT_AUTHOR_EMAIL":    "helmc@example.com",
+		"GIT_COMMITTER_NAME":  "helmc",
+		"GIT_COMMITTER_EMAIL": "helmc@example.com",
+		"GIT_AUTHOR_DATE":     "2016-06-01T12:00:00Z",
+		"GIT_COMMITTER_DATE":  "2016-06-01T12:00:00Z",
+	} {
+		os.Setenv(k, v)
+		defer os.Unsetenv(k)
+	}
+
+	fixture := gitFixture(t)
+	defer os.RemoveAll(fixture)
+
+	layouts := map[string]string{}
+	for _, backend := range GitBackends() {
+		layouts[backend] = backendLayout(t, backend, fixture)
+	}
+	for backend, layout := range layouts {
+		if layout != layouts[BackendExec] {
+			t.Errorf("Expected the %s backend to leave the same cache as the exec backend.\n%s:\n%s\nexec:\n%s", backend, backend, layout, layouts[BackendExec])
+		}
+	}
+}
+
+// backendLayout adds, updates, pins, deepens, and pushes to repositories
+// with a Git backend, and returns a description of the cache it leaves.
+func backendLayout(t *testing.T, backend, fixture string) string {
+	// The operations change the remote, so each backend has a copy.
+	remote, err := ioutil.TempDir("", "helmc-git-remote")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(remote)
+	if err := util.CopyDir(fixture, remote); err != nil {
+		t.Fatal(err)
+	}
+	cache := test.CreateTmpHome()
+	defer os.RemoveAll(cache)
+
+	git := func(dir string, args ...string) string {
+		out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput()
+		if err != nil {
+			t.Fatalf("%s: git %v: %s %s", backend, args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+
+	var b bytes.Buffer
+	url := "file://" + remote
+	r := &Repos{Dir: cache, GitBackend: backend, Log: &log.Logger{Stdout: &b, Stderr: &b}}
+	for _, tbl := range []*Table{
+		{Name: "shallow", Repo: url, Type: TypeGit},
+		{Name: "full", Repo: remote, Full: true},
+		{Name: "tagged", Repo: url, Type: TypeGit, Tag: "v1"},
+		{Name: "branched", Repo: url, Type: TypeGit, Branch: "stable"},
+	} {
+		if err := r.Add(tbl); err != nil {
+			t.Fatalf("%s: Could not add %s: %s", backend, tbl.Name, err)
+		}
+	}
+
+	// A new chart, a commit on the pinned branch, and a tag that moves.
+	commit := func(chart, version string) {
+		os.MkdirAll(filepath.Join(remote, chart), 0755)
+		ioutil.WriteFile(filepath.Join(remote, chart, "Chart.yaml"), []byte("name: "+chart+"\nversion: "+version+"\n"), 0644)
+		git(remote, "add", "-A")
+		git(remote, "commit", "-q", "-m", chart+" "+version)
+	}
+	commit("redis", "0.1.0")
+	git(remote, "checkout", "-q", "stable")
+	commit("alpine", "0.1.1")
+	git(remote, "checkout", "-q", "-")
+	git(remote, "tag", "-f", "v1", "HEAD")
+	if err := r.UpdateAll(false); err != nil {
+		t.Fatalf("%s: Could not update: %s\n%s", backend, err, b.String())
+	}
+	if !strings.Contains(b.String(), "Added 1 charts") {
+		t.Errorf("%s: Expected the new chart to be reported, got %q", backend, b.String())
+	}
+	if !strings.Contains(b.String(), "Tag v1 in repository 'tagged' moved") {
+		t.Errorf("%s: Expected a moved tag warning, got %q", backend, b.String())
+	}
+
+	// A shallow clone is deepened for a tag it does not have.
+	git(remote, "tag", "v0", "HEAD~2")
+	if err := r.SetRef("shallow", "v0"); err != nil {
+		t.Fatalf("%s: Could not pin a shallow clone to an old tag: %s", backend, err)
+	}
+	if err := r.SetDepth("shallow", true); err != nil {
+		t.Fatalf("%s: Could not switch to a full clone: %s", backend, err)
+	}
+
+	// Unpinning a tag checks out the default branch of the remote.
+	if err := r.SetRef("tagged", ""); err != nil {
+		t.Fatalf("%s: Could not unpin a tag: %s", backend, err)
+	}
+	if b := git(filepath.Join(cache, "tagged"), "symbolic-ref", "--short", "HEAD"); b != git(remote, "symbolic-ref", "--short", "HEAD") {
+		t.Errorf("%s: Expected the unpinned clone on the default branch, got %s", backend, b)
+	}
+
+	// Publishing pushes to a bare repository.
+	bare := remote + ".git"
+	defer os.RemoveAll(bare)
+	git(remote, "clone", "-q", "--bare", remote, bare)
+	if err := r.Add(&Table{Name: "pushed", Repo: "file://" + bare, Type: TypeGit, Full: true}); err != nil {
+		t.Fatalf("%s: Could not add a bare repository: %s", backend, err)
+	}
+	os.MkdirAll(filepath.Join(cache, "pushed", "nginx"), 0755)
+	ioutil.WriteFile(filepath.Join(cache, "pushed", "nginx", "Chart.yaml"), []byte("name: nginx\nversion: 0.1.0\n"), 0644)
+	if err := r.Push("pushed", "Publish nginx"); err != nil {
+		t.Fatalf("%s: Could not push: %s", backend, err)
+	}
+	if err := r.Push("pushed", "Publish nothing"); err != nil {
+		t.Errorf("%s: Expected pushing without changes to succeed: %s", backend, err)
+	}
+
+	// The layout is every file outside .git, with the commit and depth of
+	// each clone. The pushed commit differs in its author and date, so only
+	// its files are compared.
+	lines := []string{"remote: " + git(bare, "show", "HEAD:nginx/Chart.yaml")}
+	filepath.Walk(cache, func(path string, fi os.FileInfo, err error) error {
+		rel, _ := filepath.Rel(cache, path)
+		switch {
+		case err != nil:
+			return err
+		case fi.IsDir() && fi.Name() == ".git":
+			shallow := fileExists(filepath.Join(path, "shallow"))
+			if filepath.Dir(rel) == "pushed" {
+				lines = append(lines, fmt.Sprintf("%s shallow=%t", rel, shallow))
+			} else {
+				lines = append(lines, fmt.Sprintf("%s %s shallow=%t", rel, git(filepath.Dir(path), "rev-parse", "HEAD"), shallow))
+			}
+			return filepath.SkipDir
+		case fi.IsDir() || strings.HasSuffix(path, ".lock"):
+			return nil
+		}
+		data, _ := ioutil.ReadFile(path)
+		lines = append(lines, fmt.Sprintf("%s %x", rel, sha256.Sum256(data)))
+		return nil
+	})
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -5,12 +5,21 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
-	"github.com/Masterminds/vcs"
+	"github.com/helm/helm-classic/chart"
+	helmerrors "github.com/helm/helm-classic/errors"
+	"github.com/helm/helm-classic/helmpath"
+	"github.com/helm/helm-classic/lock"
 	"github.com/helm/helm-classic/log"
+	"github.com/helm/helm-classic/manifest"
+	"github.com/helm/helm-classic/metrics"
+	"github.com/helm/helm-classic/repo"
+	helm "github.com/helm/helm-classic/util"
 	"golang.org/x/crypto/ssh/terminal"
 	"gopkg.in/yaml.v2"
 )
@@ -18,52 +27,331 @@ import (
 // ErrNotFound indicates no local repository could be found.
 var ErrNotFound = errors.New("No local repository")
 
+// repoError wraps a failure to update or read the named repository in a
+// *helmerrors.RepoError, keeping what git wrote to stderr.
+func repoError(name string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var re *helmerrors.RepoError
+	if errors.As(err, &re) {
+		return err
+	}
+	re = &helmerrors.RepoError{Repo: name, Err: err}
+	var ee *helm.ExecError
+	if errors.As(err, &ee) {
+		re.Stderr = ee.Tail
+	}
+	return re
+}
+
 // Configfile is the top-level conifguration object for Helm Classic.
 type Configfile struct {
 	// filename may contain a reference back to the file that was read into
 	// this object.
 	filename string
+	// migrated names the older format the file was read in, if any.
+	migrated string
+
+	// APIVersion is the version of the configuration format.
+	APIVersion string `yaml:"apiVersion"`
 
 	// Repos points to the repository configuration
 	Repos     *Repos     `yaml:"repos"`
 	Workspace *Workspace `yaml:"workspace"`
+	// Kubectl configures the kubectl client.
+	Kubectl *Kubectl `yaml:"kubectl,omitempty"`
+	// Audit configures the audit log of changes to Kubernetes.
+	Audit *Audit `yaml:"audit,omitempty"`
+	// Profiles are named sets of defaults, one per environment, that
+	// --profile selects.
+	Profiles map[string]*Profile `yaml:"profiles,omitempty"`
+	// Fetch bounds the charts that are fetched.
+	Fetch *Fetch `yaml:"fetch,omitempty"`
+	// Install bounds the manifests that are installed.
+	Install *Install `yaml:"install,omitempty"`
+	// Generate restricts the commands that generators run.
+	Generate *Generate `yaml:"generate,omitempty"`
+}
+
+// Fetch holds the limits of a chart that is downloaded into the cache, or
+// fetched into the workspace, and whether a deprecated chart may be. For the
+// limits, zero is the default of chart.DefaultLimits, and a negative number
+// is no bound.
+type Fetch struct {
+	// MaxFiles is the number of files that a chart may have.
+	MaxFiles int `yaml:"maxFiles,omitempty"`
+	// MaxSizeMB is the total size, in MiB, of the files of a chart.
+	MaxSizeMB int `yaml:"maxSizeMB,omitempty"`
+	// Strict refuses to fetch or install a deprecated chart, unless
+	// --accept-deprecated is given. Otherwise, it is only warned about.
+	Strict bool `yaml:"strict,omitempty"`
+}
+
+// Strict returns true if deprecated charts are refused; see Fetch.Strict.
+func (c *Configfile) Strict() bool {
+	return c.Fetch != nil && c.Fetch.Strict
+}
+
+// Limits returns the limits of the charts that are fetched.
+func (c *Configfile) Limits() chart.Limits {
+	l := chart.DefaultLimits
+	if c.Fetch == nil {
+		return l
+	}
+	switch n := c.Fetch.MaxFiles; {
+	case n < 0:
+		l.Files = 0
+	case n > 0:
+		l.Files = n
+	}
+	switch n := c.Fetch.MaxSizeMB; {
+	case n < 0:
+		l.Bytes = 0
+	case n > 0:
+		l.Bytes = int64(n) << 20
+	}
+	return l
+}
+
+// Install holds the limits of the manifests that an install sends to
+// Kubernetes, and that lint checks. Zero is the default of
+// manifest.DefaultLimits, and a negative number is no bound.
+type Install struct {
+	// MaxDocuments is the number of manifest documents.
+	MaxDocuments int `yaml:"maxDocuments,omitempty"`
+	// MaxDocumentKB is the size, in KiB, of a single document.
+	MaxDocumentKB int `yaml:"maxDocumentKB,omitempty"`
+	// MaxTotalMB is the total size, in MiB, of the documents.
+	MaxTotalMB int `yaml:"maxTotalMB,omitempty"`
+}
+
+// ManifestLimits returns the limits of the manifests that are installed.
+// The fields of flags that are not zero, such as those of the flags of
+// install, take precedence over the configuration. flags may be nil.
+func (c *Configfile) ManifestLimits(flags *Install) manifest.Limits {
+	l := manifest.DefaultLimits
+	for _, in := range []*Install{c.Install, flags} {
+		if in == nil {
+			continue
+		}
+		switch n := in.MaxDocuments; {
+		case n < 0:
+			l.Documents = 0
+		case n > 0:
+			l.Documents = n
+		}
+		switch n := in.MaxDocumentKB; {
+		case n < 0:
+			l.DocumentBytes = 0
+		case n > 0:
+			l.DocumentBytes = int64(n) << 10
+		}
+		switch n := in.MaxTotalMB; {
+		case n < 0:
+			l.TotalBytes = 0
+		case n > 0:
+			l.TotalBytes = int64(n) << 20
+		}
+	}
+	return l
+}
+
+// Generate is the policy of the generators of charts, which run the
+// commands that the charts declare.
+type Generate struct {
+	// Allow are the commands that generators may run, such as "tpl" for the
+	// templates that helmc renders, or "sed". If it is empty, and no
+	// --allow-generators is given, only those templates may run. See
+	// generator.Policy.
+	Allow []string `yaml:"allow,omitempty"`
+}
+
+// Profile holds the defaults of an environment, such as prod, for the
+// commands that work with Kubernetes. The flags of a command take precedence
+// over them.
+type Profile struct {
+	// Namespace is the namespace of install, uninstall, and status.
+	Namespace string `yaml:"namespace,omitempty"`
+	// KubeContext is the kubeconfig context, as --kube-context gives it.
+	KubeContext string `yaml:"kubeContext,omitempty"`
+	// Kubeconfig is the kubeconfig file, as --kubeconfig gives it. A leading
+	// ~ and environment variables are expanded.
+	Kubeconfig string `yaml:"kubeconfig,omitempty"`
+}
+
+// Profile returns the profile with a name, or an error that lists the
+// profiles that exist.
+func (c *Configfile) Profile(name string) (*Profile, error) {
+	if p, ok := c.Profiles[name]; ok && p != nil {
+		return p, nil
+	}
+	if len(c.Profiles) == 0 {
+		return nil, fmt.Errorf("Unknown profile %q: no profiles are defined. Define one with 'helmc config set profiles.%s.namespace NAMESPACE'.", name, name)
+	}
+	return nil, fmt.Errorf("Unknown profile %q. The profiles are: %s", name, strings.Join(c.ProfileNames(), ", "))
+}
+
+// ProfileNames returns the names of the profiles, sorted.
+func (c *Configfile) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for n := range c.Profiles {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Audit describes where the audit log is written.
+type Audit struct {
+	// Path is the file of the audit log. A leading ~ and environment
+	// variables are expanded, and a relative path is relative to the home
+	// directory. If it is empty, the log is audit.log in the home directory.
+	Path string `yaml:"path,omitempty"`
+}
+
+// Kubectl describes the kubectl binary to use.
+type Kubectl struct {
+	// Path is the location of kubectl. The --kubectl-path flag and the
+	// $HELMC_KUBECTL environment variable take precedence over it.
+	Path string `yaml:"path,omitempty"`
+	// ApplyArgs are extra flags for every kubectl create, apply, and replace.
+	// Charts may declare their own in Chart.yaml; these win over them.
+	ApplyArgs []string `yaml:"applyArgs,omitempty"`
+	// DeleteArgs are extra flags for every kubectl delete. --grace-period
+	// wins over them.
+	DeleteArgs []string `yaml:"deleteArgs,omitempty"`
 }
 
 // Repos describes a collection of repository (table) mappings.
 type Repos struct {
-	// Dir points to the directory where the Git repositories are stored.
-	// Currently we do not expose this as a changeable option, but we may
-	// choose to in the future.
+	// Dir points to the directory where the Git repositories are stored, as
+	// helmpath.Home.Cache resolves it.
 	Dir string `yaml:"-"`
+	// Cache moves the cache out of the home, to share it between homes. See
+	// helmpath.Home.Layout.
+	Cache string `yaml:"cache,omitempty"`
 	// Default is the local name of the default repository.
 	Default string `yaml:"default"`
 	// Tables is a list of table items.
 	Tables []*Table `yaml:"tables"`
+
+	// Offline forbids any operation that needs the network.
+	//
+	// When it is set, only directory mirrors can be updated, and anything
+	// that would run git against a remote or fetch a URL fails immediately.
+	Offline bool `yaml:"-"`
+	// InsecureSkipVerify accepts an HTTP repository's index even if it fails
+	// verification against the repository's keyring. A warning is printed
+	// instead.
+	InsecureSkipVerify bool `yaml:"-"`
+	// TraceGit is the level of git tracing.
+	//
+	// At level 1, every git command is logged with its directory and how
+	// long it took, and git reports its progress. At level 2, git's own
+	// tracing is turned on as well, with $GIT_TRACE and $GIT_CURL_VERBOSE.
+	TraceGit int `yaml:"-"`
+	// GitBackend is the backend of the Git repositories that do not name
+	// one. By default, it is BackendExec.
+	GitBackend string `yaml:"-"`
+	// GitTimeout bounds each Git operation that uses the network, such as a
+	// clone or a fetch. Zero is no bound. helmc uses DefaultGitTimeout.
+	GitTimeout time.Duration `yaml:"-"`
+	// Jobs is the most repositories UpdateAll updates at once. Zero is
+	// MaxParallelUpdates.
+	Jobs int `yaml:"-"`
+	// Limits bound the charts that are downloaded from HTTP repositories.
+	// Load sets them from the Fetch section.
+	Limits chart.Limits `yaml:"-"`
+	// Log receives the messages of operations on the repositories. If it is
+	// nil, they are printed with the package-level log functions.
+	Log *log.Logger `yaml:"-"`
 }
 
 // Workspace describes a workspace location and configuration.
 type Workspace struct {
-	// Dir indicates where the workspace is.
-	// Currently, this is not exposed via configuration.
+	// Dir indicates where the workspace is, as helmpath.Home.Workspace
+	// resolves it.
 	Dir string `yaml:"-"`
+	// Path moves the workspace out of the home. See helmpath.Home.Layout.
+	Path string `yaml:"path,omitempty"`
 }
 
 // Table describes a single table entry.
 type Table struct {
 	// Name is the local name of the repository.
 	Name string `yaml:"name"`
-	// Repo is the remote Git URL to the repository.
+	// Repo is the remote Git URL to the repository, or the URL of the index
+	// file for an HTTP repository.
 	Repo string `yaml:"repo"`
+	// Type is either "git" or "http". An empty type means "git".
+	Type string `yaml:"type,omitempty"`
+	// Branch pins the repository to a branch. It is ignored if Tag is set.
+	Branch string `yaml:"branch,omitempty"`
+	// Tag pins the repository to a tag. The checkout is a detached HEAD.
+	Tag string `yaml:"tag,omitempty"`
+	// Auth holds credentials for a private repository.
+	Auth *Auth `yaml:"auth,omitempty"`
+	// Priority orders repositories when resolving unqualified chart names.
+	// Higher numbers win. The default is 0.
+	Priority int `yaml:"priority,omitempty"`
+	// Full clones a Git repository with its whole history. By default, only
+	// the most recent commit of each branch is cloned.
+	Full bool `yaml:"full,omitempty"`
+	// Keyring is the path to an OpenPGP keyring. If it is set, the index of
+	// an HTTP repository must be signed by one of its keys.
+	Keyring string `yaml:"keyring,omitempty"`
+	// Backend is the Git backend of a Git repository, either BackendExec or
+	// BackendNative. If it is empty, Repos.GitBackend is used.
+	Backend string `yaml:"backend,omitempty"`
+}
+
+// Repository types.
+const (
+	// TypeGit is a repository that is cloned with Git.
+	TypeGit = "git"
+	// TypeHTTP is a repository that publishes an index file over HTTP(S).
+	TypeHTTP = "http"
+	// TypeDir is a mirror of a repository in a local directory.
+	TypeDir = "dir"
+)
+
+// IsHTTP returns true if the table is an HTTP repository.
+func (t *Table) IsHTTP() bool {
+	return t.Type == TypeHTTP
 }
 
-// Load loads a configuration by filename.
+// IsDir returns true if the table is a local directory mirror.
+func (t *Table) IsDir() bool {
+	return t.Type == TypeDir
+}
+
+// Ref returns the ref that a table is pinned to, or "" if it tracks the default branch.
+func (t *Table) Ref() string {
+	if t.Tag != "" {
+		return t.Tag
+	}
+	return t.Branch
+}
+
+// Load loads a configuration by filename, in the home that is its directory.
 func Load(filename string) (*Configfile, error) {
-	b, err := ioutil.ReadFile(filename)
+	abs, err := filepath.Abs(filename)
 	if err != nil {
 		return nil, err
 	}
+	return load(abs, helmpath.Home(filepath.Dir(abs)))
+}
 
-	abs, err := filepath.Abs(filename)
+// LoadHome loads the configuration of a home, wherever its layout puts it.
+func LoadHome(home string) (*Configfile, error) {
+	h := helmpath.Home(home)
+	return load(h.Config(), h)
+}
+
+// load loads the configuration file abs of the home h.
+func load(abs string, h helmpath.Home) (*Configfile, error) {
+	b, err := ioutil.ReadFile(abs)
 	if err != nil {
 		return nil, err
 	}
@@ -73,8 +361,14 @@ func Load(filename string) (*Configfile, error) {
 		return cfg, err
 	}
 	cfg.filename = abs
+
+	if cfg.migrated != "" {
+		if err := cfg.saveMigrated(b); err != nil {
+			return cfg, err
+		}
+	}
 	if cfg.Repos.Dir == "" {
-		cfg.Repos.Dir = filepath.Join(filepath.Dir(abs), "cache")
+		cfg.Repos.Dir = h.Cache()
 	}
 
 	if cfg.Workspace == nil {
@@ -82,13 +376,18 @@ func Load(filename string) (*Configfile, error) {
 	}
 
 	if cfg.Workspace.Dir == "" {
-		cfg.Workspace.Dir = filepath.Join(filepath.Dir(abs), "workspace")
+		cfg.Workspace.Dir = h.Workspace()
 	}
+	cfg.Repos.Limits = cfg.Limits()
+	cfg.Repos.useCredentials(h.String())
 
 	return cfg, nil
 }
 
 // Parse parses a byte slice into a *Configfile.
+//
+// Files in an older format are migrated in memory. Files written by a newer
+// version of Helm Classic are rejected.
 func Parse(data []byte) (*Configfile, error) {
 	r := &Configfile{
 		filename: "config.yaml",
@@ -97,6 +396,9 @@ func Parse(data []byte) (*Configfile, error) {
 	if err := yaml.Unmarshal(data, r); err != nil {
 		return r, err
 	}
+	if err := r.migrate(); err != nil {
+		return r, err
+	}
 	return r, nil
 }
 
@@ -105,6 +407,7 @@ func (c *Configfile) Save(filename string) error {
 	if filename == "" {
 		filename = c.filename
 	}
+	c.APIVersion = APIVersion
 	b, err := yaml.Marshal(c)
 	if err != nil {
 		return err
@@ -124,27 +427,171 @@ func (r *Repos) RepoChart(name string) (string, string) {
 	return res[0], res[1]
 }
 
-// Add adds the named remote and then fetches it.
-func (r *Repos) Add(name, repo string) error {
+// Resolve takes a chart name and returns a repo name and a chart name.
+//
+// A fully qualified name is simply split. For an unqualified name, every
+// repository that has the chart in its cache is a candidate, and the one
+// with the highest priority wins. If several candidates share the highest
+// priority, the name is ambiguous and an error listing the candidates is
+// returned, even if one of them is the default repository. If no repository
+// has the chart, the default repo is returned.
+func (r *Repos) Resolve(name string) (string, string, error) {
+	if strings.Contains(name, "/") {
+		t, c := r.RepoChart(name)
+		return t, c, nil
+	}
+
+	var best []*Table
+	for _, t := range r.Tables {
+		if !r.hasChart(t, name) {
+			continue
+		}
+		switch {
+		case len(best) == 0 || t.Priority > best[0].Priority:
+			best = []*Table{t}
+		case t.Priority == best[0].Priority:
+			best = append(best, t)
+		}
+	}
+
+	switch len(best) {
+	case 0:
+		return r.Default, name, nil
+	case 1:
+		return best[0].Name, name, nil
+	}
+
+	candidates := make([]string, len(best))
+	for i, t := range best {
+		candidates[i] = t.Name + "/" + name
+	}
+	return "", name, &helmerrors.AmbiguousChartError{Name: name, Candidates: candidates}
+}
+
+// Searched returns the repositories that Resolve looks for a chart name in:
+// the named repository for a fully qualified name, and every repository
+// otherwise.
+func (r *Repos) Searched(name string) []string {
+	if strings.Contains(name, "/") {
+		t, _ := r.RepoChart(name)
+		return []string{t}
+	}
+	names := make([]string, len(r.Tables))
+	for i, t := range r.Tables {
+		names[i] = t.Name
+	}
+	return names
+}
+
+// Candidates returns the repositories that have a chart in their local
+// cache, in the order of the tables. These are the repositories that Resolve
+// chooses among for an unqualified name.
+func (r *Repos) Candidates(chartName string) []string {
+	names := []string{}
+	for _, t := range r.Tables {
+		if r.hasChart(t, chartName) {
+			names = append(names, t.Name)
+		}
+	}
+	return names
+}
+
+// hasChart returns true if a chart is in the local cache of a table.
+func (r *Repos) hasChart(t *Table, chartName string) bool {
+	if t.IsHTTP() {
+		idx, err := repo.LoadIndex(filepath.Join(r.Dir, t.Name, repo.IndexFile))
+		return err == nil && idx.Latest(chartName) != nil
+	}
+	if m, err := r.Metadata(t.Name); err == nil {
+		_, ok := m.Charts[chartName]
+		return ok
+	}
+	_, err := os.Stat(filepath.Join(r.Dir, t.Name, chartName, "Chart.yaml"))
+	return err == nil
+}
+
+// CachedChart returns the Chart.yaml of a chart in the local cache of a
+// repository, from its metadata index. For an HTTP repository, it describes
+// the latest version in the index file.
+func (r *Repos) CachedChart(name, chartName string) (*chart.Chartfile, error) {
+	t := r.Lookup(name)
+	if t == nil {
+		return nil, ErrNotFound
+	}
+	if !t.IsHTTP() {
+		if m, err := r.Metadata(t.Name); err == nil {
+			if cm, ok := m.Charts[chartName]; ok {
+				return cm.Chart, nil
+			}
+		}
+		return chart.LoadChartfile(filepath.Join(r.Dir, t.Name, chartName, "Chart.yaml"))
+	}
+	idx, err := repo.LoadIndex(filepath.Join(r.Dir, t.Name, repo.IndexFile))
+	if err != nil {
+		return nil, err
+	}
+	cv := idx.Latest(chartName)
+	if cv == nil {
+		return nil, &helmerrors.ChartNotFoundError{Name: chartName, Repos: []string{name}}
+	}
+	return cv.Chartfile(), nil
+}
+
+// Add adds the remote described by the table and then fetches it.
+func (r *Repos) Add(nt *Table) error {
 	for _, r := range r.Tables {
-		if r.Name == name {
-			return fmt.Errorf("Remote %s already exists, and is pointed to %s", name, r.Repo)
+		if r.Name == nt.Name {
+			return fmt.Errorf("Remote %s already exists, and is pointed to %s", nt.Name, r.Repo)
+		}
+		if r.Repo == nt.Repo && r.Ref() == nt.Ref() {
+			return fmt.Errorf("Remote %s already points to %s", r.Name, nt.Repo)
 		}
 	}
 
-	nt := &Table{
-		Name: name,
-		Repo: repo,
+	if nt.Type == "" {
+		nt.Type = DetectType(nt.Repo)
+		if nt.Type == TypeGit && r.servesIndex(nt) {
+			r.Log.Debug("Found %s, so %s is an HTTP repository", nt.IndexURL(), nt.Repo)
+			nt.Type = TypeHTTP
+		}
+	}
+	if err := nt.check(); err != nil {
+		return err
 	}
 
 	r.Tables = append(r.Tables, nt)
-	if err := r.Update(name); err != nil {
+	if err := r.Update(nt.Name); err != nil {
 		return err
 	}
 
 	return nil
 }
 
+// check checks that the settings of a table go together. An empty type is
+// a Git repository, as in files written before types were recorded.
+func (t *Table) check() error {
+	typ := t.Type
+	if typ == "" {
+		typ = TypeGit
+	}
+	switch typ {
+	case TypeGit:
+	case TypeHTTP, TypeDir:
+		if t.Ref() != "" {
+			return fmt.Errorf("Only Git repositories can be pinned to a branch or tag")
+		}
+	default:
+		return fmt.Errorf("Unknown repository type %q (use 'git', 'http', or 'dir')", t.Type)
+	}
+	if t.Keyring != "" && typ != TypeHTTP {
+		return fmt.Errorf("Only HTTP repositories can be verified with a keyring")
+	}
+	if t.Backend != "" && typ != TypeGit {
+		return fmt.Errorf("Only Git repositories have a Git backend")
+	}
+	return CheckGitBackend(t.Backend)
+}
+
 // Exists checks if a repo exists by name
 func (r *Repos) Exists(name string) bool {
 	for _, r := range r.Tables {
@@ -155,90 +602,436 @@ func (r *Repos) Exists(name string) bool {
 	return false
 }
 
+// Lookup returns the named table, or nil if there is no such table.
+func (r *Repos) Lookup(name string) *Table {
+	for _, t := range r.Tables {
+		if t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
+
 // Update performs an update of the local copy.
 //
-// This does a Git fast-forward pull from the remote repo.
+// This does a Git fast-forward pull from the remote repo. If the repo is
+// pinned to a branch or tag, that ref is checked out. For HTTP repos, the
+// index file is downloaded.
+//
+// A failure is returned as a *helmerrors.RepoError.
 func (r *Repos) Update(name string) error {
-	for _, t := range r.Tables {
-		if t.Name == name {
-			rpath := filepath.Join(r.Dir, name)
-			g, err := ensureRepo(t.Repo, rpath)
+	t := r.Lookup(name)
+	if t == nil {
+		return ErrNotFound
+	}
+	l, err := r.lockRepo(name)
+	if err != nil {
+		return err
+	}
+	defer l.Release()
+	return repoError(name, r.update(t))
+}
+
+func (r *Repos) update(t *Table) error {
+	rpath := filepath.Join(r.Dir, t.Name)
+	if t.IsDir() {
+		_, err := r.updateDir(t, rpath)
+		return err
+	}
+	if err := r.checkOnline(t); err != nil {
+		return err
+	}
+	out := &log.Buffer{Log: r.Log}
+	defer out.Flush()
+	if t.IsHTTP() {
+		_, err := r.updateIndex(t, rpath, out)
+		return err
+	}
+	return r.withGit(t, func(gb gitBackend) error {
+		g, err := ensureRepo(t, rpath, gb)
+		if err != nil {
+			return err
+		}
+		return updateTable(t, g, out)
+	})
+}
+
+// Commit returns the commit that the cache of the named Git repository has
+// checked out. It is "" for HTTP repositories and directory mirrors.
+func (r *Repos) Commit(name string) (string, error) {
+	t := r.Lookup(name)
+	if t == nil {
+		return "", ErrNotFound
+	}
+	if t.IsHTTP() || t.IsDir() {
+		return "", nil
+	}
+	var commit string
+	err := r.withGit(t, func(gb gitBackend) error {
+		g, err := gb.open(filepath.Join(r.Dir, t.Name))
+		if err != nil {
+			return err
+		}
+		commit, err = g.head()
+		return err
+	})
+	return commit, err
+}
+
+// SetRef pins the named repository to a branch or tag and checks it out.
+//
+// If ref names a tag on the remote, the repository is pinned to the tag.
+// Otherwise it is treated as a branch. An empty ref unpins the repository,
+// and checks out the default branch of the remote again.
+func (r *Repos) SetRef(name, ref string) error {
+	t := r.Lookup(name)
+	if t == nil {
+		return ErrNotFound
+	}
+	if t.Type != TypeGit && t.Type != "" {
+		return fmt.Errorf("Only Git repositories can be pinned to a branch or tag")
+	}
+	if err := r.checkOnline(t); err != nil {
+		return err
+	}
+	l, err := r.lockRepo(name)
+	if err != nil {
+		return err
+	}
+	defer l.Release()
+
+	out := &log.Buffer{Log: r.Log}
+	defer out.Flush()
+	return r.withGit(t, func(gb gitBackend) error {
+		g, err := ensureRepo(t, filepath.Join(r.Dir, name), gb)
+		if err != nil {
+			return err
+		}
+		if err := g.fetch(true); err != nil {
+			return err
+		}
+
+		t.Branch, t.Tag = "", ""
+		if ref == "" {
+			b, err := g.defaultBranch()
 			if err != nil {
-				return err
+				return fmt.Errorf("Repository '%s' has no default branch: %s", t.Name, err)
 			}
-			return g.Update()
+			return updateBranch(&Table{Name: t.Name, Branch: b}, g)
+		}
+		hasTag := func() bool {
+			_, err := g.resolve("refs/tags/" + ref)
+			return err == nil
+		}
+		if hasTag() || isShallow(g) && deepen(g, ref) == nil && hasTag() {
+			t.Tag = ref
+			return g.checkout(ref)
+		}
+		t.Branch = ref
+		return updateBranch(t, g)
+	})
+}
+
+// Push commits everything in the local copy of a Git repository and pushes
+// the current branch to the remote.
+//
+// A failure is returned as a *helmerrors.RepoError.
+func (r *Repos) Push(name, message string) error {
+	t := r.Lookup(name)
+	if t == nil {
+		return ErrNotFound
+	}
+	if t.Type != TypeGit && t.Type != "" {
+		return fmt.Errorf("Only Git repositories can be pushed to")
+	}
+	if err := r.checkOnline(t); err != nil {
+		return err
+	}
+	l, err := r.lockRepo(name)
+	if err != nil {
+		return err
+	}
+	defer l.Release()
+
+	return repoError(name, r.withGit(t, func(gb gitBackend) error {
+		g, err := ensureRepo(t, filepath.Join(r.Dir, name), gb)
+		if err != nil {
+			return err
+		}
+		if g.detached() {
+			return fmt.Errorf("Repository '%s' is pinned to tag %s, which cannot be pushed to", name, t.Tag)
+		}
+		if err := g.commit(message); err != nil {
+			return err
+		}
+		return g.push()
+	}))
+}
+
+// updateTable brings a local clone up to date with its remote, honoring any pinned ref.
+func updateTable(t *Table, g gitClone, out *log.Buffer) error {
+	if t.Tag != "" {
+		return updateTag(t, g, out)
+	}
+	if t.Branch != "" {
+		return updateBranch(t, g)
+	}
+	return pull(g)
+}
+
+// pull fetches from origin and, unless HEAD is detached, merges the current
+// branch with its upstream.
+func pull(g gitClone) error {
+	if err := g.fetch(false); err != nil {
+		return err
+	}
+	if g.detached() {
+		// A detached HEAD has nothing to merge.
+		return nil
+	}
+	return g.pull()
+}
+
+// updateBranch fast-forwards the local copy of a pinned branch.
+func updateBranch(t *Table, g gitClone) error {
+	if err := g.fetch(false); err != nil {
+		return err
+	}
+	if err := g.checkout(t.Branch); err != nil {
+		if !isShallow(g) || deepen(g, t.Branch) != nil || g.checkout(t.Branch) != nil {
+			return fmt.Errorf("Repository '%s' has no branch %s: %s", t.Name, t.Branch, err)
 		}
 	}
-	return ErrNotFound
+	return g.merge(t.Branch)
 }
 
-func ensureRepo(repo, dir string) (*vcs.GitRepo, error) {
+// updateTag checks out a pinned tag.
+//
+// This is a no-op unless the tag has moved on the remote since the last
+// update, in which case a warning is issued and the new target is checked out.
+func updateTag(t *Table, g gitClone, out *log.Buffer) error {
+	tagRef := "refs/tags/" + t.Tag
+	prev, _ := g.resolve(tagRef)
+
+	if err := g.fetch(true); err != nil {
+		return err
+	}
+	cur, err := g.resolve(tagRef)
+	if err != nil && isShallow(g) && deepen(g, t.Tag) == nil {
+		cur, err = g.resolve(tagRef)
+	}
+	if err != nil {
+		return fmt.Errorf("Repository '%s' has no tag %s", t.Name, t.Tag)
+	}
+	head, _ := g.head()
+
+	if prev != "" && prev != cur {
+		out.Warn("Tag %s in repository '%s' moved from %s to %s", t.Tag, t.Name, prev, cur)
+	} else if head == cur {
+		return nil
+	}
+	return g.checkout(t.Tag)
+}
+
+// ensureRepo returns the local clone of a table, cloning it if necessary.
+//
+// Unless the table asks for a full clone, the clone is shallow. A clone that
+// fails, or is interrupted, is removed.
+func ensureRepo(t *Table, dir string, gb gitBackend) (gitClone, error) {
+	created := false
 	if fi, err := os.Stat(dir); err != nil {
 		if err := os.MkdirAll(dir, 0755); err != nil {
 			return nil, err
 		}
+		created = true
 	} else if !fi.IsDir() {
 		return nil, fmt.Errorf("File %s exists, but is not a directory.", dir)
 	}
-	git, err := vcs.NewGitRepo(repo, dir)
-	if err != nil {
-		return nil, err
-	}
 
-	git.Logger = log.New()
-
-	if !git.CheckLocal() {
-		if err := git.Get(); err != nil {
-			return git, err
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err != nil {
+		// A clone that did not finish would later be taken for a clone.
+		undo := func() { os.RemoveAll(filepath.Join(dir, ".git")) }
+		if created {
+			undo = func() { os.RemoveAll(dir) }
+		}
+		forget := helm.OnInterrupt(undo)
+		err := gb.clone(t.Repo, dir, t.Full)
+		forget()
+		if err != nil {
+			undo()
+			return nil, err
 		}
 	}
-	return git, nil
+	return gb.open(dir)
 }
 
-// UpdateAll does a git fast-forward pull from each remote repo.
-func (r *Repos) UpdateAll() error {
-	for _, table := range r.Tables {
-		log.Info("Checking repository %s", table.Name)
-		rpath := filepath.Join(r.Dir, table.Name)
-		g, err := ensureRepo(table.Repo, rpath)
-		if err != nil {
-			return err
-		}
+// MaxParallelUpdates is the most repositories UpdateAll updates at once,
+// unless Repos.Jobs says otherwise.
+const MaxParallelUpdates = 8
 
-		if g.IsDirty() {
-			return fmt.Errorf("Repository '%s' is dirty.  Commit changes before updating", table.Name)
-		}
+// Update results reported by UpdateAll.
+const (
+	statusUpdated   = "updated"
+	statusUnchanged = "unchanged"
+	statusSkipped   = "skipped"
+	statusFailed    = "failed"
+)
 
-		initialVersion, err := g.Version()
-		if err != nil {
-			return fmt.Errorf("Could not get current sha of repository '%s'.", table.Name)
+// UpdateAll does a git fast-forward pull from each remote repo, and
+// downloads the index of each HTTP repo.
+//
+// Repositories are updated concurrently, up to Jobs at a time. A progress
+// line is printed as each one finishes, such as "[2/5] stable: updated in
+// 1.2s". The output for each repository is collected and printed as a block,
+// in configuration order, followed by a summary of every repository's status.
+// A failure does not stop the other updates; an error is returned at the end
+// if any repository failed.
+//
+// If failFast is true, repositories are updated one at a time, and the first
+// failure stops the update.
+func (r *Repos) UpdateAll(failFast bool) error {
+	if failFast {
+		for _, table := range r.Tables {
+			out := &log.Buffer{Log: r.Log}
+			_, err := r.updateOne(table, out)
+			out.Flush()
+			if err != nil {
+				return err
+			}
 		}
+		return nil
+	}
 
-		if err := g.Update(); err != nil {
-			return err
+	type result struct {
+		out     log.Buffer
+		status  string
+		err     error
+		elapsed time.Duration
+		done    chan bool
+	}
+	results := make([]*result, len(r.Tables))
+	for i := range results {
+		results[i] = &result{out: log.Buffer{Log: r.Log}, done: make(chan bool)}
+	}
+
+	workers := r.Jobs
+	if workers <= 0 {
+		workers = MaxParallelUpdates
+	}
+	if workers > len(r.Tables) {
+		workers = len(r.Tables)
+	}
+	var mu sync.Mutex
+	finished := 0
+	jobs := make(chan int, len(r.Tables))
+	for i := range r.Tables {
+		jobs <- i
+	}
+	close(jobs)
+	for w := 0; w < workers; w++ {
+		go func() {
+			for i := range jobs {
+				res := results[i]
+				start := time.Now()
+				res.status, res.err = r.updateOne(r.Tables[i], &res.out)
+				res.elapsed = time.Since(start).Round(100 * time.Millisecond)
+				mu.Lock()
+				finished++
+				r.Log.Info("[%d/%d] %s: %s in %s", finished, len(r.Tables), r.Tables[i].Name, res.status, res.elapsed)
+				mu.Unlock()
+				close(res.done)
+			}
+		}()
+	}
+
+	// The workers report their progress while the blocks are printed, so
+	// both write to r.Log under mu.
+	failed := []string{}
+	for i, res := range results {
+		<-res.done
+		mu.Lock()
+		res.out.Flush()
+		if res.err != nil {
+			r.Log.Err("%s", res.err)
+			failed = append(failed, r.Tables[i].Name)
 		}
-		diff, err := repoChartDiff(rpath, initialVersion)
-		if err != nil {
-			return err
+		mu.Unlock()
+	}
+
+	if len(r.Tables) > 1 {
+		for i, res := range results {
+			r.Log.Msg("\t%s\t%s\t%s", r.Tables[i].Name, res.status, res.elapsed)
 		}
-		printSummary(diff)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d repositories failed to update: %s", len(failed), len(r.Tables), strings.Join(failed, ", "))
 	}
 	return nil
 }
 
-func repoChartDiff(rpath, initialVersion string) (string, error) {
-	// build git diff-tree command
-	cmd := exec.Command("git", "-C", rpath, "diff-tree", "--name-status", fmt.Sprintf("%s..HEAD", initialVersion))
+// updateOne updates a single repository for UpdateAll, writing its messages to out.
+func (r *Repos) updateOne(table *Table, out *log.Buffer) (status string, err error) {
+	defer func(start time.Time) { metrics.Observe(metrics.Update, table.Name, time.Since(start), err) }(time.Now())
+	out.Info("Checking repository %s", table.Name)
+	rpath := filepath.Join(r.Dir, table.Name)
+
+	l, err := r.lockRepo(table.Name)
+	if err != nil {
+		return statusFailed, err
+	}
+	defer l.Release()
+
+	var diff string
+	switch {
+	case table.IsDir():
+		diff, err = r.updateDir(table, rpath)
+	case r.Offline:
+		out.Warn("Skipping repository %s: it needs the network, and --offline is set", table.Name)
+		return statusSkipped, nil
+	case table.IsHTTP():
+		diff, err = r.updateIndex(table, rpath, out)
+	default:
+		err = r.withGit(table, func(gb gitBackend) error {
+			diff, err = updateGit(table, rpath, out, gb)
+			return err
+		})
+	}
+	if err != nil {
+		return statusFailed, repoError(table.Name, err)
+	}
 
-	log.Debug("git diff cmd: %s", cmd.Args)
+	printSummary(out, diff)
+	if !table.IsHTTP() {
+		if err := r.reindexChanged(table.Name, diff); err != nil {
+			out.Warn("Could not index repository %s: %s", table.Name, err)
+		}
+	}
+	if diff == "" {
+		return statusUnchanged, nil
+	}
+	return statusUpdated, nil
+}
 
-	out, err := cmd.CombinedOutput()
+// updateGit updates a Git repository and returns the charts that changed.
+func updateGit(table *Table, rpath string, out *log.Buffer, gb gitBackend) (string, error) {
+	g, err := ensureRepo(table, rpath, gb)
 	if err != nil {
 		return "", err
 	}
 
-	// cleanup any trailing whitespace
-	return strings.TrimSpace(string(out)), nil
+	if g.isDirty() {
+		return "", fmt.Errorf("Repository '%s' is dirty.  Commit changes before updating", table.Name)
+	}
+
+	initialVersion, err := g.head()
+	if err != nil {
+		return "", fmt.Errorf("Could not get current sha of repository '%s'.", table.Name)
+	}
+
+	if err := updateTable(table, g, out); err != nil {
+		return "", err
+	}
+	return g.diff(initialVersion)
 }
 
 type repoSummary map[string][]string
@@ -254,9 +1047,9 @@ func (s repoSummary) add(status, chart string) {
 }
 
 // printSummary prints a diff of charts after upate
-func printSummary(diff string) {
+func printSummary(out *log.Buffer, diff string) {
 	if len(diff) == 0 {
-		log.Msg("Already up-to-date.")
+		out.Msg("Already up-to-date.")
 		return
 	}
 
@@ -283,11 +1076,11 @@ func printSummary(diff string) {
 	for st, charts := range s {
 		switch st {
 		case "A":
-			log.Msg("Added %d charts", len(charts))
+			out.Msg("Added %d charts", len(charts))
 		case "D":
-			log.Msg("Sent %d charts to the depths", len(charts))
+			out.Msg("Sent %d charts to the depths", len(charts))
 		case "M":
-			log.Msg("Updated %d charts", len(charts))
+			out.Msg("Updated %d charts", len(charts))
 		}
 
 		line := ""
@@ -295,13 +1088,13 @@ func printSummary(diff string) {
 			// if adding this column passes the max
 			// print and reset to zero
 			if len(line)+colwidth > maxwidth {
-				log.Msg(line)
+				out.Msg(line)
 				line = ""
 			}
 			// append to line with padding
 			line = fmt.Sprintf("%s%-29s", line, ch)
 		}
-		log.Msg(line)
+		out.Msg(line)
 	}
 }
 
@@ -327,13 +1120,83 @@ func (r *Repos) Delete(name string) error {
 	return r.deleteRepo(name)
 }
 
+// SetPriority sets the priority of the named repository.
+func (r *Repos) SetPriority(name string, priority int) error {
+	t := r.Lookup(name)
+	if t == nil {
+		return fmt.Errorf("No repository named %s", name)
+	}
+	t.Priority = priority
+	return nil
+}
+
+// Rename changes the local name of a repository and moves its cache.
+//
+// Renaming a repository to its current name is a no-op. If the cache cannot
+// be moved, the table is left unchanged.
+func (r *Repos) Rename(oldName, newName string) error {
+	if oldName == newName {
+		return nil
+	}
+	t := r.Lookup(oldName)
+	if t == nil {
+		return fmt.Errorf("No repository named %s", oldName)
+	}
+	if e := r.Lookup(newName); e != nil {
+		return fmt.Errorf("Remote %s already exists, and is pointed to %s", newName, e.Repo)
+	}
+	if newName == "" || strings.ContainsAny(newName, "/\\") {
+		return fmt.Errorf("Invalid repository name %q", newName)
+	}
+
+	l, err := r.lockRepo(oldName)
+	if err != nil {
+		return err
+	}
+	defer l.Release()
+
+	src, dst := filepath.Join(r.Dir, oldName), filepath.Join(r.Dir, newName)
+	if _, err := os.Stat(dst); err == nil {
+		return fmt.Errorf("Cache directory %s already exists", dst)
+	}
+	if _, err := os.Stat(src); err == nil {
+		r.Log.Debug("Moving %s to %s", src, dst)
+		if err := os.Rename(src, dst); err != nil {
+			return err
+		}
+		// The index moves with the copy. If it cannot, the copy is scanned again.
+		os.Rename(r.metadataPath(oldName), r.metadataPath(newName))
+	}
+
+	t.Name = newName
+	if r.Default == oldName {
+		r.Default = newName
+	}
+	return nil
+}
+
 func (r *Repos) deleteRepo(name string) error {
+	l, err := r.lockRepo(name)
+	if err != nil {
+		return err
+	}
+	defer l.Release()
+
 	rpath := filepath.Join(r.Dir, name)
 	if fi, err := os.Stat(rpath); err != nil || !fi.IsDir() {
-		log.Info("Deleted nothing. No repo named %s", name)
+		r.Log.Info("Deleted nothing. No repo named %s", name)
 		return nil
 	}
 
-	log.Debug("Deleting %s", rpath)
+	r.Log.Debug("Deleting %s", rpath)
+	os.Remove(r.metadataPath(name))
 	return os.RemoveAll(rpath)
 }
+
+// lockRepo takes the lock on the cached copy of the named repository.
+//
+// The lock is held while the copy is cloned, fetched, or removed, so that
+// concurrent helmc processes do not leave it half updated.
+func (r *Repos) lockRepo(name string) (*lock.Lock, error) {
+	return lock.Acquire(filepath.Join(r.Dir, name+".lock"))
+}
@@ -2,12 +2,22 @@ package config
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
 	"testing"
 
+	helmerrors "github.com/helm/helm-classic/errors"
 	"github.com/helm/helm-classic/log"
+	"github.com/helm/helm-classic/manifest"
+	"github.com/helm/helm-classic/repo"
 	"github.com/helm/helm-classic/test"
 	"github.com/helm/helm-classic/util"
 )
@@ -16,7 +26,7 @@ func TestEnsureRepo(t *testing.T) {
 	tmpHome := test.CreateTmpHome()
 
 	repo := "https://github.com/helm/charts"
-	ensureRepo(repo, filepath.Join(tmpHome, "cache", "charts"))
+	ensureRepo(&Table{Name: "charts", Repo: repo}, filepath.Join(tmpHome, "cache", "charts"), &gitRunner{name: "charts"})
 }
 
 func TestParseConfigfile(t *testing.T) {
@@ -43,6 +53,21 @@ func TestParseConfigfile(t *testing.T) {
 	}
 }
 
+func TestManifestLimits(t *testing.T) {
+	cfg, err := Parse([]byte(util.DefaultConfigfile + "install:\n  maxDocuments: 10\n  maxTotalMB: -1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := cfg.ManifestLimits(nil)
+	if l.Documents != 10 || l.DocumentBytes != manifest.DefaultLimits.DocumentBytes || l.TotalBytes != 0 {
+		t.Errorf("Expected the configuration to override the defaults, got %+v", l)
+	}
+	l = cfg.ManifestLimits(&Install{MaxDocuments: -1, MaxDocumentKB: 4, MaxTotalMB: 1})
+	if l.Documents != 0 || l.DocumentBytes != 4<<10 || l.TotalBytes != 1<<20 {
+		t.Errorf("Expected the flags to override the configuration, got %+v", l)
+	}
+}
+
 func TestLoadConfigfile(t *testing.T) {
 	cfg, err := Load("../testdata/Configfile.yaml")
 	if err != nil {
@@ -94,7 +119,9 @@ M	owncloud`
 		"Added 1 charts\njenkins",
 	}
 
-	printSummary(diff)
+	out := &log.Buffer{}
+	printSummary(out, diff)
+	out.Flush()
 	actual := b.String()
 
 	for _, exp := range expected {
@@ -103,3 +130,430 @@ M	owncloud`
 		}
 	}
 }
+
+// gitFixture creates a local Git repository with one chart, a tag v1 on the
+// first commit, and a "stable" branch.
+func gitFixture(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "helmc-git-fixture")
+	if err != nil {
+		t.Fatal(err)
+	}
+	run := func(args ...string) string {
+		args = append([]string{"-C", dir, "-c", "user.name=helmc", "-c", "user.email=helmc@example.com"}, args...)
+		out, err := exec.Command("git", args...).CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %s %s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	commit := func(version string) {
+		os.MkdirAll(filepath.Join(dir, "alpine"), 0755)
+		ioutil.WriteFile(filepath.Join(dir, "alpine", "Chart.yaml"), []byte("name: alpine\nversion: "+version+"\n"), 0644)
+		run("add", "-A")
+		run("commit", "-q", "-m", version)
+	}
+
+	run("init", "-q")
+	commit("0.1.0")
+	run("tag", "v1")
+	run("branch", "stable")
+	commit("0.2.0")
+	return dir
+}
+
+func gitHead(t *testing.T, dir string) string {
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "HEAD").CombinedOutput()
+	if err != nil {
+		t.Fatalf("rev-parse: %s %s", err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func TestPinnedRepos(t *testing.T) {
+	remote := gitFixture(t)
+	defer os.RemoveAll(remote)
+	cache := test.CreateTmpHome()
+	defer os.RemoveAll(cache)
+
+	r := &Repos{Dir: cache}
+	if err := r.Add(&Table{Name: "tagged", Repo: remote, Tag: "v1"}); err != nil {
+		t.Fatalf("Could not add tagged repo: %s", err)
+	}
+	if err := r.Add(&Table{Name: "branched", Repo: remote, Branch: "stable"}); err != nil {
+		t.Fatalf("Could not add branched repo: %s", err)
+	}
+
+	v1 := gitHead(t, filepath.Join(cache, "tagged"))
+	if b := gitHead(t, filepath.Join(cache, "branched")); b != v1 {
+		t.Errorf("Expected stable branch at %s, got %s", v1, b)
+	}
+	if h := gitHead(t, remote); h == v1 {
+		t.Fatalf("Expected tag v1 to differ from remote HEAD")
+	}
+
+	// Updating an unmoved tag is a no-op.
+	var b bytes.Buffer
+	log.Stderr = &b
+	defer func() { log.Stderr = os.Stderr }()
+	if err := r.Update("tagged"); err != nil {
+		t.Fatalf("Could not update tagged repo: %s", err)
+	}
+	if strings.Contains(b.String(), "moved") {
+		t.Errorf("Did not expect a moved tag warning: %s", b.String())
+	}
+
+	// Moving the tag is detected.
+	exec.Command("git", "-C", remote, "tag", "-f", "v1", "HEAD").Run()
+	if err := r.Update("tagged"); err != nil {
+		t.Fatalf("Could not update tagged repo: %s", err)
+	}
+	if !strings.Contains(b.String(), "Tag v1 in repository 'tagged' moved") {
+		t.Errorf("Expected a moved tag warning, got %q", b.String())
+	}
+	if h := gitHead(t, filepath.Join(cache, "tagged")); h != gitHead(t, remote) {
+		t.Errorf("Expected tagged repo to follow the moved tag")
+	}
+
+	// Repinning to a branch.
+	if err := r.SetRef("tagged", "stable"); err != nil {
+		t.Fatalf("Could not set ref: %s", err)
+	}
+	if r.Tables[0].Branch != "stable" || r.Tables[0].Tag != "" {
+		t.Errorf("Expected tagged repo to be pinned to branch stable, got %+v", r.Tables[0])
+	}
+	if h := gitHead(t, filepath.Join(cache, "tagged")); h != v1 {
+		t.Errorf("Expected tagged repo to be at stable (%s), got %s", v1, h)
+	}
+	if err := r.SetRef("tagged", "nope"); err == nil {
+		t.Errorf("Expected an error for a missing ref")
+	}
+
+	// Unpinning follows the default branch of the remote, even from a tag.
+	if err := r.SetRef("tagged", "v1"); err != nil {
+		t.Fatalf("Could not set ref: %s", err)
+	}
+	if err := r.SetRef("tagged", ""); err != nil {
+		t.Fatalf("Could not unpin: %s", err)
+	}
+	if r.Tables[0].Branch != "" || r.Tables[0].Tag != "" {
+		t.Errorf("Expected tagged repo to be unpinned, got %+v", r.Tables[0])
+	}
+	out, err := exec.Command("git", "-C", filepath.Join(cache, "tagged"), "symbolic-ref", "--short", "HEAD").CombinedOutput()
+	branch, _ := exec.Command("git", "-C", remote, "symbolic-ref", "--short", "HEAD").Output()
+	if err != nil || string(out) != string(branch) {
+		t.Errorf("Expected the unpinned repo on the default branch %s, got %s %v", branch, out, err)
+	}
+	if h := gitHead(t, filepath.Join(cache, "tagged")); h != gitHead(t, remote) {
+		t.Errorf("Expected the unpinned repo at the head of the remote, got %s", h)
+	}
+}
+
+func TestHTTPRepo(t *testing.T) {
+	archive := test.ChartArchive("redis", map[string]string{
+		"Chart.yaml": "name: redis\nversion: 0.2.0\n",
+	})
+	sum := sha256.Sum256(archive)
+	index := `apiVersion: v1
+entries:
+  redis:
+  - version: 0.1.0
+    digest: deadbeef
+    url: redis-0.1.0.tgz
+  - version: 0.2.0
+    digest: ` + hex.EncodeToString(sum[:]) + `
+    url: redis-0.2.0.tgz
+`
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/stable/index.yaml":
+			w.Write([]byte(index))
+		case "/stable/redis-0.2.0.tgz":
+			w.Write(archive)
+		case "/stable/redis-0.2.0.tgz.prov":
+			w.Write([]byte("provenance"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	cache := test.CreateTmpHome()
+	defer os.RemoveAll(cache)
+
+	r := &Repos{Dir: cache}
+	if err := r.Add(&Table{Name: "stable", Repo: ts.URL + "/stable/index.yaml"}); err != nil {
+		t.Fatalf("Could not add HTTP repo: %s", err)
+	}
+	if typ := r.Tables[0].Type; typ != TypeHTTP {
+		t.Errorf("Expected type to be detected as http, got %q", typ)
+	}
+	if _, err := os.Stat(filepath.Join(cache, "stable", "index.yaml")); err != nil {
+		t.Errorf("Expected index to be cached: %s", err)
+	}
+
+	if err := r.FetchChart("stable", "redis"); err != nil {
+		t.Fatalf("Could not fetch chart: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(cache, "stable", "redis", "Chart.yaml")); err != nil {
+		t.Errorf("Expected chart to be expanded into the cache: %s", err)
+	}
+	if b, err := ioutil.ReadFile(filepath.Join(cache, "stable", "redis.prov")); err != nil || string(b) != "provenance" {
+		t.Errorf("Expected the provenance file next to the chart, got %q, %v", b, err)
+	}
+	if err := r.FetchChart("stable", "memcached"); err == nil {
+		t.Errorf("Expected an error for a chart that is not in the index")
+	}
+
+	// A corrupted archive fails verification.
+	archive = append(archive, 0)
+	if err := r.FetchChart("stable", "redis"); err == nil || !strings.Contains(err.Error(), "verification") {
+		t.Errorf("Expected a verification error, got %v", err)
+	}
+
+	if err := r.Add(&Table{Name: "pinned", Repo: ts.URL + "/stable/index.yaml", Tag: "v1"}); err == nil {
+		t.Errorf("Expected an error pinning an HTTP repo")
+	}
+
+	// A repository added by the directory of its index is HTTP too.
+	if err := r.Add(&Table{Name: "base", Repo: ts.URL + "/stable/"}); err != nil {
+		t.Fatalf("Could not add HTTP repo by its base URL: %s", err)
+	}
+	if typ := r.Tables[len(r.Tables)-1].Type; typ != TypeHTTP {
+		t.Errorf("Expected a base URL with an index to be http, got %q", typ)
+	}
+}
+
+func TestIndexDiff(t *testing.T) {
+	old, _ := repo.ParseIndex([]byte("entries:\n  a:\n  - {version: 1.0.0, url: a.tgz}\n  b:\n  - {version: 1.0.0, url: b.tgz}\n"))
+	cur, _ := repo.ParseIndex([]byte("entries:\n  a:\n  - {version: 1.1.0, url: a.tgz}\n  c:\n  - {version: 1.0.0, url: c.tgz}\n"))
+
+	expect := "M\ta\nA\tc\nD\tb"
+	if d := indexDiff(old, cur); d != expect {
+		t.Errorf("Expected %q, got %q", expect, d)
+	}
+}
+
+func TestRename(t *testing.T) {
+	cache := test.CreateTmpHome()
+	defer os.RemoveAll(cache)
+	os.MkdirAll(filepath.Join(cache, "charts", "redis"), 0755)
+
+	r := &Repos{
+		Dir:     cache,
+		Default: "charts",
+		Tables: []*Table{
+			{Name: "charts", Repo: "https://github.com/helm/charts"},
+			{Name: "other", Repo: "https://github.com/helm/other"},
+		},
+	}
+
+	if err := r.Rename("charts", "charts"); err != nil {
+		t.Errorf("Expected renaming to the same name to be a no-op: %s", err)
+	}
+	if err := r.Rename("charts", "other"); err == nil || !strings.Contains(err.Error(), "https://github.com/helm/other") {
+		t.Errorf("Expected an error naming the existing entry, got %v", err)
+	}
+	if err := r.Rename("nope", "yup"); err == nil {
+		t.Errorf("Expected an error for a missing repo")
+	}
+
+	if err := r.Rename("charts", "stable"); err != nil {
+		t.Fatal(err)
+	}
+	if r.Tables[0].Name != "stable" || r.Default != "stable" {
+		t.Errorf("Expected table and default to be renamed, got %s and %s", r.Tables[0].Name, r.Default)
+	}
+	if _, err := os.Stat(filepath.Join(cache, "stable", "redis")); err != nil {
+		t.Errorf("Expected cache to be moved: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(cache, "charts")); err == nil {
+		t.Errorf("Expected old cache to be gone")
+	}
+}
+
+func TestResolve(t *testing.T) {
+	cache := test.CreateTmpHome()
+	defer os.RemoveAll(cache)
+	for _, d := range []string{"charts/redis", "mine/redis", "other/redis", "mine/nginx"} {
+		os.MkdirAll(filepath.Join(cache, d), 0755)
+		ioutil.WriteFile(filepath.Join(cache, d, "Chart.yaml"), []byte("name: x\n"), 0644)
+	}
+
+	r := &Repos{
+		Dir:     cache,
+		Default: "charts",
+		Tables: []*Table{
+			{Name: "charts"},
+			{Name: "mine"},
+			{Name: "other"},
+		},
+	}
+
+	expect := func(name, table string) {
+		tbl, c, err := r.Resolve(name)
+		if err != nil {
+			t.Errorf("Could not resolve %s: %s", name, err)
+		} else if tbl != table || c != filepath.Base(name) {
+			t.Errorf("Expected %s to resolve to %s, got %s/%s", name, table, tbl, c)
+		}
+	}
+
+	expect("other/redis", "other")
+	expect("nginx", "mine")
+	expect("missing", "charts")
+
+	// Ties are ambiguous, even with the default repository among them.
+	_, _, err := r.Resolve("redis")
+	if err == nil || !strings.Contains(err.Error(), "charts/redis, mine/redis, other/redis") {
+		t.Errorf("Expected an ambiguity error listing candidates, got %v", err)
+	}
+
+	r.Tables[1].Priority = 5
+	expect("redis", "mine")
+
+	r.Tables[2].Priority = 5
+	_, _, err = r.Resolve("redis")
+	if err == nil || !strings.Contains(err.Error(), "mine/redis, other/redis") {
+		t.Errorf("Expected an ambiguity error listing candidates, got %v", err)
+	}
+	var ae *helmerrors.AmbiguousChartError
+	if !errors.As(err, &ae) || !errors.Is(err, helmerrors.ErrAmbiguousChart) {
+		t.Fatalf("Expected an *AmbiguousChartError, got %T", err)
+	}
+	if ae.Name != "redis" || strings.Join(ae.Candidates, " ") != "mine/redis other/redis" {
+		t.Errorf("Expected the candidates of redis, got %s: %v", ae.Name, ae.Candidates)
+	}
+}
+
+func TestUpdateRepoError(t *testing.T) {
+	cache := test.CreateTmpHome()
+	defer os.RemoveAll(cache)
+
+	missing := filepath.Join(cache, "no-such-remote")
+	r := &Repos{Dir: cache, Tables: []*Table{{Name: "broken", Repo: "file://" + missing, Type: TypeGit}}}
+	err := r.Update("broken")
+
+	var re *helmerrors.RepoError
+	if !errors.As(err, &re) {
+		t.Fatalf("Expected a *RepoError, got %T: %v", err, err)
+	}
+	if re.Repo != "broken" {
+		t.Errorf("Expected the error to name the repository, got %q", re.Repo)
+	}
+	if !strings.Contains(strings.Join(re.Stderr, "\n"), "no-such-remote") {
+		t.Errorf("Expected git's stderr to be kept, got %q", re.Stderr)
+	}
+
+	if err := r.Update("nope"); err != ErrNotFound {
+		t.Errorf("Expected ErrNotFound for an unknown repository, got %v", err)
+	}
+}
+
+func TestShallowRepos(t *testing.T) {
+	remote := gitFixture(t)
+	defer os.RemoveAll(remote)
+	cache := test.CreateTmpHome()
+	defer os.RemoveAll(cache)
+
+	// Local paths ignore --depth, so use a file:// URL.
+	url := "file://" + remote
+	r := &Repos{Dir: cache}
+	if err := r.Add(&Table{Name: "shallow", Repo: url, Type: TypeGit}); err != nil {
+		t.Fatalf("Could not add shallow repo: %s", err)
+	}
+	rpath := filepath.Join(cache, "shallow")
+	if _, err := os.Stat(filepath.Join(rpath, ".git", "shallow")); err != nil {
+		t.Fatalf("Expected a shallow clone: %s", err)
+	}
+
+	// The tag points at history the clone does not have.
+	if err := r.SetRef("shallow", "v1"); err != nil {
+		t.Fatalf("Expected a shallow clone to deepen for a tag: %s", err)
+	}
+	if r.Tables[0].Tag != "v1" {
+		t.Errorf("Expected shallow repo to be pinned to v1, got %+v", r.Tables[0])
+	}
+
+	if err := r.SetDepth("shallow", true); err != nil {
+		t.Fatalf("Could not switch to a full clone: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(rpath, ".git", "shallow")); err == nil {
+		t.Errorf("Expected a full clone")
+	}
+	if !r.Tables[0].Full {
+		t.Errorf("Expected table to be marked full")
+	}
+
+	if err := r.SetDepth("shallow", false); err != nil {
+		t.Fatalf("Could not switch to a shallow clone: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(rpath, ".git", "shallow")); err != nil {
+		t.Errorf("Expected a shallow clone again: %s", err)
+	}
+
+	full := &Table{Name: "full", Repo: url, Type: TypeGit, Full: true}
+	if err := r.Add(full); err != nil {
+		t.Fatalf("Could not add full repo: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(cache, "full", ".git", "shallow")); err == nil {
+		t.Errorf("Expected a full clone")
+	}
+}
+
+func TestConcurrentUpdate(t *testing.T) {
+	remote := gitFixture(t)
+	defer os.RemoveAll(remote)
+	cache := test.CreateTmpHome()
+	defer os.RemoveAll(cache)
+
+	// Each update has its own configuration, as separate processes would.
+	const n = 4
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		r := &Repos{Dir: cache, Tables: []*Table{{Name: "charts", Repo: "file://" + remote}}}
+		go func() { errs <- r.Update("charts") }()
+	}
+	for i := 0; i < n; i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("Concurrent update failed: %s", err)
+		}
+	}
+
+	rpath := filepath.Join(cache, "charts")
+	if out, err := exec.Command("git", "-C", rpath, "fsck", "--strict").CombinedOutput(); err != nil {
+		t.Errorf("Clone is corrupt: %s %s", err, out)
+	}
+	if h := gitHead(t, rpath); h != gitHead(t, remote) {
+		t.Errorf("Expected clone at %s, got %s", gitHead(t, remote), h)
+	}
+	if _, err := os.Stat(rpath + ".lock"); !os.IsNotExist(err) {
+		t.Errorf("Expected the lock to be released")
+	}
+}
+
+func TestTraceGit(t *testing.T) {
+	remote := gitFixture(t)
+	defer os.RemoveAll(remote)
+	cache := test.CreateTmpHome()
+	defer os.RemoveAll(cache)
+
+	var b bytes.Buffer
+	r := &Repos{Dir: cache, TraceGit: 1, Log: &log.Logger{Stderr: &b}}
+	if err := r.Add(&Table{Name: "traced", Repo: "file://" + remote, Type: TypeGit}); err != nil {
+		t.Fatalf("Could not add repo: %s", err)
+	}
+	for _, expect := range []string{
+		"[git clone traced] Running git clone --progress --depth 1",
+		"[git clone traced] Cloning into",
+		"[git clone traced] Finished in",
+		"[git fetch traced] Running git fetch --progress origin in " + filepath.Join(cache, "traced"),
+	} {
+		if !strings.Contains(b.String(), expect) {
+			t.Errorf("Expected %q in %q", expect, b.String())
+		}
+	}
+
+	if args := progressArgs(false, []string{"fetch", "-q", "origin"}); strings.Join(args, " ") != "fetch -q origin" {
+		t.Errorf("Expected arguments without progress to be unchanged, got %v", args)
+	}
+}
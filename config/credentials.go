@@ -0,0 +1,120 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/helm/helm-classic/helmpath"
+	"gopkg.in/yaml.v2"
+)
+
+// Credentials are the tokens of private repositories that helmc keeps
+// itself, as 'helmc repo login' stores them.
+//
+// They are in their own file of the home, credentials.yaml, which only its
+// owner may read, so that the configuration file can be shared, or kept in
+// version control, without them. A repository whose Auth has TokenStored
+// uses the token of its local name.
+type Credentials struct {
+	// Repos maps the local name of a repository to its credential.
+	Repos map[string]*Credential `yaml:"repositories,omitempty"`
+
+	filename string
+}
+
+// Credential is the secret of a repository.
+type Credential struct {
+	Token string `yaml:"token"`
+}
+
+// LoadCredentials reads the credentials file of a home. A file that does not
+// exist has no credentials.
+func LoadCredentials(home string) (*Credentials, error) {
+	c := &Credentials{Repos: map[string]*Credential{}, filename: helmpath.Home(home).Credentials()}
+	b, err := ioutil.ReadFile(c.filename)
+	if os.IsNotExist(err) {
+		return c, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(b, c); err != nil {
+		return nil, err
+	}
+	if c.Repos == nil {
+		c.Repos = map[string]*Credential{}
+	}
+	return c, nil
+}
+
+// Save writes the credentials file, readable only by its owner.
+func (c *Credentials) Save() error {
+	b, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(c.filename), ".credentials-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	// TempFile creates the file with mode 0600.
+	return os.Rename(tmp.Name(), c.filename)
+}
+
+// Token returns the token of a repository, or "" if there is none.
+func (c *Credentials) Token(name string) string {
+	if cr := c.Repos[name]; cr != nil {
+		return cr.Token
+	}
+	return ""
+}
+
+// Set stores the token of a repository, replacing any other.
+func (c *Credentials) Set(name, token string) {
+	c.Repos[name] = &Credential{Token: token}
+}
+
+// Remove forgets the token of a repository. It reports whether there was one.
+func (c *Credentials) Remove(name string) bool {
+	_, ok := c.Repos[name]
+	delete(c.Repos, name)
+	return ok
+}
+
+// Rename moves the token of a repository to its new local name.
+func (c *Credentials) Rename(oldName, newName string) {
+	if cr, ok := c.Repos[oldName]; ok {
+		delete(c.Repos, oldName)
+		c.Repos[newName] = cr
+	}
+}
+
+// useCredentials gives each repository whose Auth has TokenStored its token.
+// The credentials file is only read if one does, and an error reading it is
+// that of their tokens, so that the other repositories still work.
+func (r *Repos) useCredentials(home string) {
+	var creds *Credentials
+	var err error
+	for _, t := range r.Tables {
+		if t.Auth == nil || !t.Auth.TokenStored {
+			continue
+		}
+		if creds == nil && err == nil {
+			creds, err = LoadCredentials(home)
+		}
+		if err != nil {
+			t.Auth.storedErr = fmt.Errorf("could not read %s: %s", helmpath.Home(home).Credentials(), err)
+			continue
+		}
+		t.Auth.stored = creds.Token(t.Name)
+	}
+}
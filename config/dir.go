@@ -0,0 +1,112 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/helm/helm-classic/chart"
+	"github.com/helm/helm-classic/util"
+)
+
+// checkOnline returns an error if the table needs the network and Offline is set.
+func (r *Repos) checkOnline(t *Table) error {
+	if r.Offline && !t.IsDir() {
+		return fmt.Errorf("Repository '%s' needs the network to reach %s, but --offline is set", t.Name, t.Repo)
+	}
+	return nil
+}
+
+// dirPath returns the local path of a file:// URL.
+func dirPath(u string) (string, error) {
+	p, err := url.Parse(u)
+	if err != nil {
+		return "", fmt.Errorf("Invalid URL %q: %s", u, err)
+	}
+	if p.Scheme != "file" || (p.Host != "" && p.Host != "localhost") {
+		return "", fmt.Errorf("Invalid URL %q: directory mirrors must use file:///path", u)
+	}
+	return filepath.FromSlash(p.Path), nil
+}
+
+// checkMirror ensures that a directory exists and holds at least one chart.
+func checkMirror(dir string) error {
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("Mirror directory %s does not exist", dir)
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("Mirror %s is not a directory", dir)
+	}
+	charts, _ := filepath.Glob(filepath.Join(dir, "*", "Chart.yaml"))
+	if len(charts) == 0 {
+		return fmt.Errorf("Mirror directory %s contains no charts", dir)
+	}
+	return nil
+}
+
+// updateDir refreshes the cached copy of a directory mirror.
+//
+// It returns the changed charts in the same form as `git diff-tree --name-status`.
+func (r *Repos) updateDir(t *Table, rpath string) (string, error) {
+	src, err := dirPath(t.Repo)
+	if err != nil {
+		return "", err
+	}
+	if err := checkMirror(src); err != nil {
+		return "", fmt.Errorf("Repository '%s': %s", t.Name, err)
+	}
+
+	before := chartVersions(rpath)
+	r.Log.Debug("Copying %s to %s", src, rpath)
+	if err := os.RemoveAll(rpath); err != nil {
+		return "", err
+	}
+	if err := util.CopyDir(src, rpath); err != nil {
+		return "", fmt.Errorf("Could not copy mirror %s: %s", src, err)
+	}
+	after := chartVersions(rpath)
+
+	lines := []string{}
+	for _, name := range sortedKeys(after) {
+		v, ok := before[name]
+		switch {
+		case !ok:
+			lines = append(lines, "A\t"+name)
+		case v != after[name]:
+			lines = append(lines, "M\t"+name)
+		}
+	}
+	for _, name := range sortedKeys(before) {
+		if _, ok := after[name]; !ok {
+			lines = append(lines, "D\t"+name)
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// chartVersions maps the chart directories in dir to their versions.
+func chartVersions(dir string) map[string]string {
+	res := map[string]string{}
+	files, _ := filepath.Glob(filepath.Join(dir, "*", "Chart.yaml"))
+	for _, f := range files {
+		c, err := chart.LoadChartfile(f)
+		if err != nil {
+			continue
+		}
+		res[filepath.Base(filepath.Dir(f))] = c.Version
+	}
+	return res
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
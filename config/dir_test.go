@@ -0,0 +1,144 @@
+package config
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/helm/helm-classic/log"
+	"github.com/helm/helm-classic/test"
+)
+
+func mirrorFixture(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "helmc-mirror-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []string{"alpine", "redis"} {
+		os.MkdirAll(filepath.Join(dir, c), 0755)
+		ioutil.WriteFile(filepath.Join(dir, c, "Chart.yaml"), []byte("name: "+c+"\nversion: 0.1.0\n"), 0644)
+	}
+	return dir
+}
+
+func TestDirRepo(t *testing.T) {
+	mirror := mirrorFixture(t)
+	defer os.RemoveAll(mirror)
+	cache := test.CreateTmpHome()
+	defer os.RemoveAll(cache)
+
+	r := &Repos{Dir: cache}
+	tbl := &Table{Name: "mirror", Repo: "file://" + mirror}
+	if err := ValidateURL(tbl); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Add(tbl); err != nil {
+		t.Fatal(err)
+	}
+	if tbl.Type != TypeDir {
+		t.Errorf("Expected type dir, got %q", tbl.Type)
+	}
+	if _, err := os.Stat(filepath.Join(cache, "mirror", "redis", "Chart.yaml")); err != nil {
+		t.Errorf("Expected mirror to be copied into the cache: %s", err)
+	}
+
+	os.RemoveAll(filepath.Join(mirror, "alpine"))
+	ioutil.WriteFile(filepath.Join(mirror, "redis", "Chart.yaml"), []byte("name: redis\nversion: 0.2.0\n"), 0644)
+	os.MkdirAll(filepath.Join(mirror, "nginx"), 0755)
+	ioutil.WriteFile(filepath.Join(mirror, "nginx", "Chart.yaml"), []byte("name: nginx\nversion: 0.1.0\n"), 0644)
+
+	diff, err := (&Repos{Dir: cache}).updateDir(tbl, filepath.Join(cache, "mirror"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := "A\tnginx\nM\tredis\nD\talpine"; diff != expect {
+		t.Errorf("Expected diff %q, got %q", expect, diff)
+	}
+}
+
+func TestCheckMirror(t *testing.T) {
+	empty, err := ioutil.TempDir("", "helmc-mirror-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(empty)
+
+	if err := ValidateURL(&Table{Repo: "file://" + empty}); err == nil || !strings.Contains(err.Error(), "contains no charts") {
+		t.Errorf("Expected an error for an empty mirror, got %v", err)
+	}
+	if err := ValidateURL(&Table{Repo: "file:///no/such/mirror"}); err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Errorf("Expected an error for a missing mirror, got %v", err)
+	}
+}
+
+func TestOffline(t *testing.T) {
+	mirror := mirrorFixture(t)
+	defer os.RemoveAll(mirror)
+	cache := test.CreateTmpHome()
+	defer os.RemoveAll(cache)
+
+	r := &Repos{Dir: cache, Offline: true, Tables: []*Table{
+		{Name: "charts", Repo: "https://github.com/helm/charts"},
+	}}
+	if err := r.Update("charts"); err == nil || !strings.Contains(err.Error(), "--offline") {
+		t.Errorf("Expected an offline error, got %v", err)
+	}
+	if err := r.Add(&Table{Name: "mirror", Repo: "file://" + mirror}); err != nil {
+		t.Errorf("Expected mirrors to work offline: %s", err)
+	}
+	if err := r.UpdateAll(false); err != nil {
+		t.Errorf("Expected UpdateAll to skip network repos: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(cache, "charts")); err == nil {
+		t.Errorf("Did not expect the network repo to be cloned")
+	}
+}
+
+func TestUpdateAll(t *testing.T) {
+	good := mirrorFixture(t)
+	defer os.RemoveAll(good)
+	bad := mirrorFixture(t)
+	cache := test.CreateTmpHome()
+	defer os.RemoveAll(cache)
+
+	r := &Repos{Dir: cache}
+	for _, tbl := range []*Table{
+		{Name: "bad", Repo: "file://" + bad},
+		{Name: "good", Repo: "file://" + good},
+	} {
+		if err := r.Add(tbl); err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.RemoveAll(bad)
+	os.MkdirAll(filepath.Join(good, "nginx"), 0755)
+	ioutil.WriteFile(filepath.Join(good, "nginx", "Chart.yaml"), []byte("name: nginx\nversion: 0.1.0\n"), 0644)
+	nginx := filepath.Join(cache, "good", "nginx", "Chart.yaml")
+
+	// With fail-fast, the first failure stops the update.
+	err := r.UpdateAll(true)
+	if err == nil || !strings.Contains(err.Error(), "bad") {
+		t.Errorf("Expected the bad repo to fail, got %v", err)
+	}
+	if _, err := os.Stat(nginx); err == nil {
+		t.Errorf("Did not expect the good repo to be updated after a failure")
+	}
+
+	// Otherwise, every repo is attempted and the failures are reported at the end.
+	var out bytes.Buffer
+	r.Log = &log.Logger{Stdout: &out, Stderr: &out}
+	r.Jobs = 1
+	err = r.UpdateAll(false)
+	if !strings.Contains(out.String(), "[1/2] bad: failed in ") || !strings.Contains(out.String(), "[2/2] good: updated in ") {
+		t.Errorf("Expected a progress line for each repo, in the order they finished, got %q", out.String())
+	}
+	if err == nil || !strings.Contains(err.Error(), "1 of 2 repositories failed to update: bad") {
+		t.Errorf("Expected the bad repo to be reported, got %v", err)
+	}
+	if _, err := os.Stat(nginx); err != nil {
+		t.Errorf("Expected the good repo to be updated: %s", err)
+	}
+}
@@ -0,0 +1,157 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	helm "github.com/helm/helm-classic/util"
+)
+
+// newExecBackend returns a runner for the git commands of a table.
+func newExecBackend(t *Table, r *Repos) (gitBackend, error) {
+	gr := &gitRunner{name: t.Name, trace: r.TraceGit, timeout: r.GitTimeout, log: r.Log}
+	if t.Auth != nil {
+		env, err := t.Auth.gitEnv()
+		if err != nil {
+			return nil, err
+		}
+		gr.env = env
+	}
+	return gr, nil
+}
+
+func (gr *gitRunner) clone(url, dir string, full bool) error {
+	if !full {
+		return shallowClone(gr, url, dir)
+	}
+	if _, err := gr.run("", "clone", "-q", url, dir); err != nil {
+		return fmt.Errorf("Unable to clone %s: %w", helm.Redact(url), err)
+	}
+	return nil
+}
+
+// shallowClone clones only the most recent commit of each branch of a repository.
+func shallowClone(gr *gitRunner, url, dir string) error {
+	gr.log.Debug("Shallow cloning %s into %s", url, dir)
+	if _, err := gr.run("", "clone", "-q", "--depth", "1", "--no-single-branch", url, dir); err != nil {
+		return fmt.Errorf("Unable to clone %s: %w", helm.Redact(url), err)
+	}
+	return nil
+}
+
+func (gr *gitRunner) open(dir string) (gitClone, error) {
+	return &gitRepo{dir: dir, gitRunner: gr}, nil
+}
+
+func (gr *gitRunner) lsRemote(url string) error {
+	_, err := gr.run("", "ls-remote", "--heads", url)
+	return err
+}
+
+// gitRepo is a local clone, and the runner for its git commands.
+type gitRepo struct {
+	dir string
+	*gitRunner
+}
+
+// git runs a git command in the repository's directory.
+//
+// Its stderr is logged with a prefix such as "git fetch myrepo".
+func (g *gitRepo) git(args ...string) error {
+	_, err := g.output(args...)
+	return err
+}
+
+// output runs a git command in the repository's directory and returns its stdout.
+func (g *gitRepo) output(args ...string) ([]byte, error) {
+	return g.run(g.dir, args...)
+}
+
+func (g *gitRepo) path() string {
+	return g.dir
+}
+
+func (g *gitRepo) isDirty() bool {
+	out, err := g.output("diff")
+	return err != nil || len(out) != 0
+}
+
+func (g *gitRepo) head() (string, error) {
+	out, err := g.output("rev-parse", "HEAD")
+	return strings.TrimSpace(string(out)), err
+}
+
+func (g *gitRepo) detached() bool {
+	_, err := g.output("symbolic-ref", "-q", "HEAD")
+	return err != nil
+}
+
+func (g *gitRepo) resolve(ref string) (string, error) {
+	out, err := g.output("rev-parse", "-q", "--verify", ref+"^{commit}")
+	return strings.TrimSpace(string(out)), err
+}
+
+func (g *gitRepo) fetch(tags bool, refspecs ...string) error {
+	args := []string{"fetch"}
+	if tags {
+		args = append(args, "--tags", "--force")
+	}
+	if len(refspecs) > 0 {
+		args = append(args, "-q", "--depth", "1")
+	}
+	return g.git(append(append(args, "origin"), refspecs...)...)
+}
+
+func (g *gitRepo) unshallow() error {
+	return g.git("fetch", "-q", "--unshallow", "--tags", "origin")
+}
+
+func (g *gitRepo) checkout(ref string) error {
+	return g.git("checkout", "-q", ref)
+}
+
+func (g *gitRepo) defaultBranch() (string, error) {
+	out, err := g.output("ls-remote", "--symref", "origin", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		if f := strings.Fields(line); len(f) == 3 && f[0] == "ref:" && f[2] == "HEAD" {
+			return strings.TrimPrefix(f[1], "refs/heads/"), nil
+		}
+	}
+	// The remote does not say, so the branch that the clone started on is used.
+	out, err = g.output("symbolic-ref", "-q", "--short", "refs/remotes/origin/HEAD")
+	if err != nil {
+		return "", fmt.Errorf("origin does not name one")
+	}
+	return strings.TrimPrefix(strings.TrimSpace(string(out)), "origin/"), nil
+}
+
+func (g *gitRepo) merge(branch string) error {
+	return g.git("merge", "-q", "--ff-only", "origin/"+branch)
+}
+
+func (g *gitRepo) pull() error {
+	return g.git("pull", "-q")
+}
+
+func (g *gitRepo) diff(since string) (string, error) {
+	out, err := g.output("diff-tree", "--name-status", since+"..HEAD")
+	return strings.TrimSpace(string(out)), err
+}
+
+func (g *gitRepo) commit(message string) error {
+	out, err := g.output("status", "--porcelain")
+	if err != nil || len(out) == 0 {
+		return err
+	}
+	if err := g.git("add", "-A"); err != nil {
+		return err
+	}
+	return g.git("commit", "-q", "-m", message)
+}
+
+func (g *gitRepo) push() error {
+	return g.git("push", "-q", "origin", "HEAD")
+}
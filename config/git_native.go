@@ -0,0 +1,508 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/helm/helm-classic/helmpath"
+	"github.com/helm/helm-classic/log"
+	helm "github.com/helm/helm-classic/util"
+	gogit "gopkg.in/src-d/go-git.v4"
+	gitconfig "gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	githttp "gopkg.in/src-d/go-git.v4/plumbing/transport/http"
+	gitssh "gopkg.in/src-d/go-git.v4/plumbing/transport/ssh"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+)
+
+func init() {
+	gitBackends[BackendNative] = newNativeBackend
+}
+
+// nativeBackend runs the Git operations of one repository with go-git, in
+// this process.
+type nativeBackend struct {
+	// name is the repository, as used in the log prefix.
+	name string
+	// auth holds the credentials, if any.
+	auth transport.AuthMethod
+	// trace is the level of git tracing. See Repos.TraceGit.
+	trace int
+	// timeout bounds each operation. See Repos.GitTimeout.
+	timeout time.Duration
+	log     *log.Logger
+}
+
+// newNativeBackend returns a native backend for the Git operations of a table.
+func newNativeBackend(t *Table, r *Repos) (gitBackend, error) {
+	nb := &nativeBackend{name: t.Name, trace: r.TraceGit, timeout: r.GitTimeout, log: r.Log}
+	if t.Auth != nil {
+		auth, err := t.Auth.nativeAuth(t.Repo)
+		if err != nil {
+			return nil, err
+		}
+		nb.auth = auth
+	}
+	return nb, nil
+}
+
+// nativeAuth returns the credentials for go-git to reach url.
+//
+// SSH URLs use the SSH key, and HTTP URLs the token, as with git.
+func (a *Auth) nativeAuth(url string) (transport.AuthMethod, error) {
+	ep, err := transport.NewEndpoint(url)
+	if err != nil {
+		return nil, err
+	}
+	switch ep.Protocol {
+	case "ssh":
+		if a.SSHKey == "" {
+			return nil, nil
+		}
+		user := ep.User
+		if user == "" {
+			user = "git"
+		}
+		auth, err := gitssh.NewPublicKeysFromFile(user, helmpath.ExpandHome(a.SSHKey), "")
+		if err != nil {
+			return nil, fmt.Errorf("ssh key %s could not be read: %s", a.SSHKey, err)
+		}
+		return auth, nil
+	case "http", "https":
+		tok, err := a.token()
+		if err != nil || tok == "" {
+			return nil, err
+		}
+		if a.Username == "" {
+			return &githttp.TokenAuth{Token: tok}, nil
+		}
+		return &githttp.BasicAuth{Username: a.Username, Password: tok}, nil
+	}
+	return nil, nil
+}
+
+// run runs one Git operation that uses the network, and logs it as
+// gitRunner.run logs a git command.
+//
+// fn is given the context of the operation, which is done after the
+// backend's timeout. When tracing, or when progress is shown, it is also
+// given a writer for go-git's progress reports.
+func (nb *nativeBackend) run(op, dir string, fn func(ctx context.Context, progress io.Writer) error) error {
+	prefix := fmt.Sprintf("git %s %s", op, nb.name)
+	ctx, cancel := helm.WithTimeout(nb.timeout)
+	defer cancel()
+	if nb.trace == 0 {
+		nb.log.Debug("Running native git %s in %s", op, dir)
+		if helm.ProgressMode == helm.ProgressOff {
+			return fn(ctx, nil)
+		}
+		progress := &helm.Stderr{Prefix: prefix, Log: nb.log, Progress: true}
+		err := fn(ctx, progress)
+		progress.Flush()
+		return err
+	}
+
+	nb.log.Info("[%s] Running native git %s in %s", prefix, op, dir)
+	progress := &helm.Stderr{Prefix: prefix, Verbose: true, Log: nb.log, Progress: true}
+	start := time.Now()
+	err := fn(ctx, progress)
+	progress.Flush()
+	if err != nil {
+		nb.log.Info("[%s] Failed after %s", prefix, time.Since(start))
+	} else {
+		nb.log.Info("[%s] Finished in %s", prefix, time.Since(start))
+	}
+	return err
+}
+
+func (nb *nativeBackend) clone(url, dir string, full bool) error {
+	o := &gogit.CloneOptions{URL: url, Auth: nb.auth}
+	if !full {
+		o.Depth = 1
+	}
+	err := nb.run("clone", dir, func(ctx context.Context, progress io.Writer) error {
+		o.Progress = progress
+		_, err := gogit.PlainCloneContext(ctx, dir, false, o)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("Unable to clone %s: %w", helm.Redact(url), err)
+	}
+	return nil
+}
+
+func (nb *nativeBackend) open(dir string) (gitClone, error) {
+	repo, err := gogit.PlainOpen(dir)
+	if err != nil {
+		return nil, fmt.Errorf("Could not open %s: %s", dir, err)
+	}
+	return &nativeRepo{dir: dir, repo: repo, nativeBackend: nb}, nil
+}
+
+func (nb *nativeBackend) lsRemote(url string) error {
+	rem := gogit.NewRemote(memory.NewStorage(), &gitconfig.RemoteConfig{Name: "origin", URLs: []string{url}})
+	_, err := nb.list(rem, "")
+	return err
+}
+
+// list returns the references of a remote, as ls-remote does. dir is the
+// clone that the remote belongs to, for the log.
+func (nb *nativeBackend) list(rem *gogit.Remote, dir string) ([]*plumbing.Reference, error) {
+	var refs []*plumbing.Reference
+	err := nb.run("ls-remote", dir, func(ctx context.Context, _ io.Writer) error {
+		// go-git cannot cancel a listing, so it is abandoned instead.
+		done := make(chan error, 1)
+		go func() {
+			list, err := rem.List(&gogit.ListOptions{Auth: nb.auth})
+			refs = list
+			done <- err
+		}()
+		select {
+		case err := <-done:
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	return refs, err
+}
+
+// nativeRepo is a local clone, opened with go-git.
+type nativeRepo struct {
+	dir  string
+	repo *gogit.Repository
+	*nativeBackend
+}
+
+func (g *nativeRepo) path() string {
+	return g.dir
+}
+
+func (g *nativeRepo) isDirty() bool {
+	w, err := g.repo.Worktree()
+	if err != nil {
+		return true
+	}
+	st, err := w.Status()
+	if err != nil {
+		return true
+	}
+	for _, fs := range st {
+		if fs.Worktree != gogit.Unmodified && fs.Worktree != gogit.Untracked {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *nativeRepo) head() (string, error) {
+	ref, err := g.repo.Head()
+	if err != nil {
+		return "", err
+	}
+	return ref.Hash().String(), nil
+}
+
+func (g *nativeRepo) detached() bool {
+	ref, err := g.repo.Head()
+	return err != nil || !ref.Name().IsBranch()
+}
+
+func (g *nativeRepo) resolve(ref string) (string, error) {
+	r, err := g.repo.Reference(plumbing.ReferenceName(ref), true)
+	if err != nil {
+		return "", err
+	}
+	h := r.Hash()
+	if tag, err := g.repo.TagObject(h); err == nil {
+		c, err := tag.Commit()
+		if err != nil {
+			return "", err
+		}
+		h = c.Hash
+	}
+	return h.String(), nil
+}
+
+func (g *nativeRepo) fetch(tags bool, refspecs ...string) error {
+	o := &gogit.FetchOptions{RemoteName: "origin", Auth: g.auth}
+	if tags {
+		o.Tags = gogit.AllTags
+		o.Force = true
+	}
+	for _, rs := range refspecs {
+		o.RefSpecs = append(o.RefSpecs, gitconfig.RefSpec(rs))
+	}
+	if len(refspecs) > 0 {
+		o.Depth = 1
+	}
+	return g.run("fetch", g.dir, func(ctx context.Context, progress io.Writer) error {
+		o.Progress = progress
+		if err := g.repo.FetchContext(ctx, o); err != nil && err != gogit.NoErrAlreadyUpToDate {
+			return err
+		}
+		return nil
+	})
+}
+
+// unshallow clones the repository again with its whole history, since
+// go-git cannot deepen a shallow clone. The checkout is kept.
+func (g *nativeRepo) unshallow() error {
+	if g.isDirty() {
+		return fmt.Errorf("Repository '%s' is dirty.  Commit changes before switching to a full clone", g.name)
+	}
+	head, err := g.repo.Head()
+	if err != nil {
+		return err
+	}
+	rem, err := g.repo.Remote("origin")
+	if err != nil {
+		return err
+	}
+
+	if err := os.RemoveAll(g.dir); err != nil {
+		return err
+	}
+	if err := g.clone(rem.Config().URLs[0], g.dir, true); err != nil {
+		return err
+	}
+	if g.repo, err = gogit.PlainOpen(g.dir); err != nil {
+		return err
+	}
+	if head.Name().IsBranch() {
+		return g.checkout(head.Name().Short())
+	}
+	w, err := g.repo.Worktree()
+	if err != nil {
+		return err
+	}
+	return w.Checkout(&gogit.CheckoutOptions{Hash: head.Hash()})
+}
+
+// checkout checks out ref as git does: a local branch first, then a tag, and
+// then a new branch that starts at the branch of origin.
+func (g *nativeRepo) checkout(ref string) error {
+	w, err := g.repo.Worktree()
+	if err != nil {
+		return err
+	}
+	branch := plumbing.NewBranchReferenceName(ref)
+	if _, err := g.repo.Reference(branch, false); err == nil {
+		return w.Checkout(&gogit.CheckoutOptions{Branch: branch})
+	}
+	if h, err := g.resolve("refs/tags/" + ref); err == nil {
+		return w.Checkout(&gogit.CheckoutOptions{Hash: plumbing.NewHash(h)})
+	}
+	remote, err := g.repo.Reference(plumbing.NewRemoteReferenceName("origin", ref), true)
+	if err != nil {
+		return fmt.Errorf("No branch or tag %s", ref)
+	}
+	return w.Checkout(&gogit.CheckoutOptions{Branch: branch, Hash: remote.Hash(), Create: true})
+}
+
+func (g *nativeRepo) defaultBranch() (string, error) {
+	rem, err := g.repo.Remote("origin")
+	if err != nil {
+		return "", err
+	}
+	refs, err := g.list(rem, g.dir)
+	if err != nil {
+		return "", err
+	}
+	for _, ref := range refs {
+		if ref.Name() == plumbing.HEAD && ref.Type() == plumbing.SymbolicReference && ref.Target().IsBranch() {
+			return ref.Target().Short(), nil
+		}
+	}
+	// The remote does not say, so the branch that the clone started on is used.
+	ref, err := g.repo.Reference(plumbing.NewRemoteReferenceName("origin", "HEAD"), false)
+	if err != nil || ref.Type() != plumbing.SymbolicReference {
+		return "", fmt.Errorf("origin does not name one")
+	}
+	return strings.TrimPrefix(ref.Target().Short(), "origin/"), nil
+}
+
+func (g *nativeRepo) merge(branch string) error {
+	target, err := g.repo.Reference(plumbing.NewRemoteReferenceName("origin", branch), true)
+	if err != nil {
+		return fmt.Errorf("No branch origin/%s", branch)
+	}
+	head, err := g.repo.Head()
+	if err != nil {
+		return err
+	}
+	if head.Hash() == target.Hash() {
+		return nil
+	}
+	ok, err := g.descends(target.Hash(), head.Hash())
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("Not possible to fast-forward to origin/%s", branch)
+	}
+
+	w, err := g.repo.Worktree()
+	if err != nil {
+		return err
+	}
+	return w.Reset(&gogit.ResetOptions{Commit: target.Hash(), Mode: gogit.HardReset})
+}
+
+// descends returns true if the commit from has ancestor in its history.
+//
+// The history of a shallow clone ends early, so false may mean that the
+// ancestor was not fetched.
+func (g *nativeRepo) descends(from, ancestor plumbing.Hash) (bool, error) {
+	iter, err := g.repo.Log(&gogit.LogOptions{From: from})
+	if err != nil {
+		return false, err
+	}
+	found := false
+	err = iter.ForEach(func(c *object.Commit) error {
+		if c.Hash == ancestor {
+			found = true
+			return storer.ErrStop
+		}
+		return nil
+	})
+	if err != nil && err != plumbing.ErrObjectNotFound {
+		return false, err
+	}
+	return found, nil
+}
+
+func (g *nativeRepo) pull() error {
+	head, err := g.repo.Head()
+	if err != nil {
+		return err
+	}
+	return g.merge(head.Name().Short())
+}
+
+func (g *nativeRepo) diff(since string) (string, error) {
+	from, err := g.entries(plumbing.NewHash(since))
+	if err != nil {
+		return "", err
+	}
+	head, err := g.repo.Head()
+	if err != nil {
+		return "", err
+	}
+	to, err := g.entries(head.Hash())
+	if err != nil {
+		return "", err
+	}
+
+	names := []string{}
+	for name := range from {
+		names = append(names, name)
+	}
+	for name := range to {
+		if _, ok := from[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	res := ""
+	for _, name := range names {
+		f, inFrom := from[name]
+		t, inTo := to[name]
+		st := "M"
+		switch {
+		case !inFrom:
+			st = "A"
+		case !inTo:
+			st = "D"
+		case f == t:
+			continue
+		}
+		if res != "" {
+			res += "\n"
+		}
+		res += st + "\t" + name
+	}
+	return res, nil
+}
+
+// entries maps the top-level entries of a commit's tree to their hashes.
+func (g *nativeRepo) entries(commit plumbing.Hash) (map[string]plumbing.Hash, error) {
+	c, err := g.repo.CommitObject(commit)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := c.Tree()
+	if err != nil {
+		return nil, err
+	}
+	res := map[string]plumbing.Hash{}
+	for _, e := range tree.Entries {
+		res[e.Name] = e.Hash
+	}
+	return res, nil
+}
+
+func (g *nativeRepo) commit(message string) error {
+	w, err := g.repo.Worktree()
+	if err != nil {
+		return err
+	}
+	st, err := w.Status()
+	if err != nil || st.IsClean() {
+		return err
+	}
+	for path, fs := range st {
+		if fs.Worktree == gogit.Deleted {
+			_, err = w.Remove(path)
+		} else {
+			_, err = w.Add(path)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	_, err = w.Commit(message, &gogit.CommitOptions{Author: signature()})
+	return err
+}
+
+// signature returns the author of a commit: $GIT_AUTHOR_NAME and
+// $GIT_AUTHOR_EMAIL, as git would take them, or else Helm Classic.
+func signature() *object.Signature {
+	s := &object.Signature{
+		Name:  os.Getenv("GIT_AUTHOR_NAME"),
+		Email: os.Getenv("GIT_AUTHOR_EMAIL"),
+		When:  time.Now(),
+	}
+	if s.Name == "" {
+		s.Name = "Helm Classic"
+	}
+	if s.Email == "" {
+		s.Email = "helmc@localhost"
+	}
+	return s
+}
+
+func (g *nativeRepo) push() error {
+	head, err := g.repo.Head()
+	if err != nil {
+		return err
+	}
+	spec := gitconfig.RefSpec(head.Name() + ":" + head.Name())
+	o := &gogit.PushOptions{RemoteName: "origin", RefSpecs: []gitconfig.RefSpec{spec}, Auth: g.auth}
+	return g.run("push", g.dir, func(ctx context.Context, progress io.Writer) error {
+		o.Progress = progress
+		if err := g.repo.PushContext(ctx, o); err != nil && err != gogit.NoErrAlreadyUpToDate {
+			return err
+		}
+		return nil
+	})
+}
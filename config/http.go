@@ -0,0 +1,271 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/helm/helm-classic/log"
+	"github.com/helm/helm-classic/repo"
+	helm "github.com/helm/helm-classic/util"
+)
+
+// DetectType guesses the type of a repository from its URL.
+//
+// file:// URLs are local directory mirrors, and URLs that point at a YAML
+// file are HTTP repositories. Everything else is assumed to be a Git
+// repository, though Add checks an HTTP(S) URL for an index first.
+func DetectType(u string) string {
+	if strings.HasPrefix(u, "file://") {
+		return TypeDir
+	}
+	if isYAML(u) {
+		return TypeHTTP
+	}
+	return TypeGit
+}
+
+// servesIndex reports whether the HTTP(S) URL of a table, which DetectType
+// takes to be a Git repository, is the top of an HTTP repository: whether it
+// has an index with entries.
+//
+// It is only asked when a repository is added without a type, so that a
+// repository can be added by the URL of the directory of its index.
+func (r *Repos) servesIndex(t *Table) bool {
+	if !strings.HasPrefix(t.Repo, "http://") && !strings.HasPrefix(t.Repo, "https://") || strings.HasSuffix(t.Repo, ".git") {
+		return false
+	}
+	if r.checkOnline(t) != nil {
+		return false
+	}
+	auth, err := t.authorization()
+	if err != nil {
+		return false
+	}
+	data, err := repo.Get(t.IndexURL(), auth)
+	if err != nil {
+		return false
+	}
+	idx, err := repo.ParseIndex(data)
+	return err == nil && len(idx.Entries) > 0
+}
+
+func isYAML(u string) bool {
+	return strings.HasSuffix(u, ".yaml") || strings.HasSuffix(u, ".yml")
+}
+
+// IndexURL returns the URL of an HTTP repository's index file.
+//
+// If the table's URL does not name a YAML file, the index is assumed to be
+// at the top of the repository.
+func (t *Table) IndexURL() string {
+	if isYAML(t.Repo) {
+		return t.Repo
+	}
+	return strings.TrimSuffix(t.Repo, "/") + "/" + repo.IndexFile
+}
+
+// updateIndex downloads the index for an HTTP repository into rpath.
+//
+// It returns the changes since the previous index, in the same form as
+// `git diff-tree --name-status`.
+//
+// If the table has a keyring, the index must be signed by one of its keys.
+// An index that fails verification does not replace the cached one, unless
+// InsecureSkipVerify is set.
+func (r *Repos) updateIndex(t *Table, rpath string, out *log.Buffer) (string, error) {
+	if err := os.MkdirAll(rpath, 0755); err != nil {
+		return "", err
+	}
+
+	auth, err := t.authorization()
+	if err != nil {
+		return "", err
+	}
+	u := t.IndexURL()
+	r.Log.Debug("Fetching index %s", u)
+	data, err := repo.Get(u, auth)
+	if err != nil {
+		if t.Auth != nil {
+			return "", authError(t, fmt.Errorf("could not fetch index: %s", err))
+		}
+		return "", fmt.Errorf("Could not fetch index for repository '%s': %s", t.Name, err)
+	}
+	cur, err := repo.ParseIndex(data)
+	if err != nil {
+		return "", fmt.Errorf("Index for repository '%s' is malformed: %s", t.Name, err)
+	}
+
+	var sig []byte
+	if t.Keyring != "" {
+		var signer string
+		sig, signer, err = checkIndex(t, data, auth)
+		switch {
+		case err == nil:
+			out.Info("Index for repository '%s' is signed by %s", t.Name, signer)
+		case r.InsecureSkipVerify:
+			out.Warn("Index for repository '%s' failed verification: %s. Using it anyway, since --insecure-skip-verify is set.", t.Name, err)
+			sig = nil
+		default:
+			return "", fmt.Errorf("Index for repository '%s' failed verification: %s. The cached index was not replaced. Use --insecure-skip-verify to accept it anyway.", t.Name, err)
+		}
+	}
+
+	ifile := filepath.Join(rpath, repo.IndexFile)
+	old, err := repo.LoadIndex(ifile)
+	if err != nil {
+		old = &repo.Index{}
+	}
+
+	if err := ioutil.WriteFile(ifile, data, 0644); err != nil {
+		return "", err
+	}
+	if err := writeSignature(rpath, sig); err != nil {
+		return "", err
+	}
+	return indexDiff(old, cur), nil
+}
+
+// indexDiff compares the latest version of each chart in two indices.
+func indexDiff(old, cur *repo.Index) string {
+	lines := []string{}
+	for _, name := range cur.Names() {
+		o := old.Latest(name)
+		switch n := cur.Latest(name); {
+		case o == nil:
+			lines = append(lines, "A\t"+name)
+		case o.Version != n.Version || o.Digest != n.Digest:
+			lines = append(lines, "M\t"+name)
+		}
+	}
+	for _, name := range old.Names() {
+		if _, ok := cur.Entries[name]; !ok {
+			lines = append(lines, "D\t"+name)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// FetchChart downloads the latest version of a chart from an HTTP repository
+// and expands it into the cache.
+//
+// The archive's digest is checked against the index before anything is
+// written. For Git repositories, this is a no-op, since the cache already
+// holds every chart.
+//
+// A failure is returned as a *helmerrors.RepoError.
+func (r *Repos) FetchChart(name, chartName string) error {
+	t := r.Lookup(name)
+	if t == nil {
+		return ErrNotFound
+	}
+	if !t.IsHTTP() {
+		return nil
+	}
+	if err := r.checkOnline(t); err != nil {
+		return repoError(name, err)
+	}
+
+	l, err := r.lockRepo(name)
+	if err != nil {
+		return err
+	}
+	defer l.Release()
+	return repoError(name, r.fetchChart(t, chartName))
+}
+
+func (r *Repos) fetchChart(t *Table, chartName string) error {
+	name := t.Name
+	rpath := filepath.Join(r.Dir, name)
+	idx, err := repo.LoadIndex(filepath.Join(rpath, repo.IndexFile))
+	if err != nil {
+		out := &log.Buffer{Log: r.Log}
+		_, err = r.updateIndex(t, rpath, out)
+		out.Flush()
+		if err != nil {
+			return err
+		}
+		if idx, err = repo.LoadIndex(filepath.Join(rpath, repo.IndexFile)); err != nil {
+			return err
+		}
+	}
+
+	cv := idx.Latest(chartName)
+	if cv == nil {
+		return fmt.Errorf("Chart %s is not in the index for repository '%s'", chartName, name)
+	}
+	u, err := cv.ResolveURL(t.IndexURL())
+	if err != nil {
+		return err
+	}
+
+	// Credentials are only sent to the host that serves the index.
+	auth := ""
+	if sameHost(u, t.IndexURL()) {
+		if auth, err = t.authorization(); err != nil {
+			return err
+		}
+	}
+
+	r.Log.Debug("Downloading %s", u)
+	data, err := repo.Get(u, auth)
+	if err != nil {
+		if auth != "" {
+			return authError(t, err)
+		}
+		return err
+	}
+	if err := repo.Verify(data, cv.Digest); err != nil {
+		return fmt.Errorf("Chart %s %s failed verification: %s", chartName, cv.Version, err)
+	}
+
+	tmp, cleanup, err := helm.TempDir(rpath, "."+chartName)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	if err := repo.Expand(data, tmp, r.Limits.Files, r.Limits.Bytes); err != nil {
+		return fmt.Errorf("Could not expand chart %s: %s", chartName, err)
+	}
+
+	dest := filepath.Join(rpath, chartName)
+	if err := os.RemoveAll(dest); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, dest); err != nil {
+		return err
+	}
+	return r.fetchProvenance(u+repo.ProvenanceExt, auth, dest+repo.ProvenanceExt)
+}
+
+// fetchProvenance downloads the provenance file of an archive, from u, next
+// to the chart that is expanded from it, so that 'helmc fetch --verify' can
+// check the chart. An archive without one is not an error, but a stale file
+// is removed.
+func (r *Repos) fetchProvenance(u, auth, filename string) error {
+	data, err := repo.Get(u, auth)
+	if err != nil {
+		r.Log.Debug("No provenance file: %s", err)
+		if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	return ioutil.WriteFile(filename, data, 0644)
+}
+
+// sameHost returns true if two URLs have the same scheme and host.
+func sameHost(a, b string) bool {
+	ua, err := url.Parse(a)
+	if err != nil {
+		return false
+	}
+	ub, err := url.Parse(b)
+	if err != nil {
+		return false
+	}
+	return ua.Scheme == ub.Scheme && ua.Host == ub.Host
+}
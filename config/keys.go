@@ -0,0 +1,329 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Keys address a setting in the configuration by the names of the YAML file,
+// separated by dots. An entry of a list is given by its index or, for a list
+// of repositories, by its name, and an entry of a map, such as a profile, by
+// its key:
+//
+//	repos.default
+//	repos.tables[2].branch
+//	repos.tables[charts].priority
+//	kubectl.path
+//	profiles.prod.namespace
+
+// managedKeys are keys that only helmc may change, with the reason. A list
+// index is written as [].
+var managedKeys = map[string]string{
+	"apiVersion":          "it is the version of the file format",
+	"repos":               "it holds every repository",
+	"repos.tables":        "use 'helmc repo remove', which deletes the cache too",
+	"repos.tables[].name": "use 'helmc repo rename', which moves the cache too",
+}
+
+// keyPart is one step of a key: a field, or an entry of a list.
+type keyPart struct {
+	// name is the YAML name of a field, or the index or name of an entry.
+	name  string
+	entry bool
+}
+
+// parseKey splits a key into its parts.
+func parseKey(key string) ([]keyPart, error) {
+	parts := []keyPart{}
+	for _, seg := range strings.Split(key, ".") {
+		field := seg
+		if i := strings.Index(seg, "["); i >= 0 {
+			field = seg[:i]
+		}
+		if field == "" {
+			return nil, fmt.Errorf("Invalid key %q: every part must have a name", key)
+		}
+		parts = append(parts, keyPart{name: field})
+		for rest := seg[len(field):]; rest != ""; {
+			end := strings.Index(rest, "]")
+			if rest[0] != '[' || end < 2 {
+				return nil, fmt.Errorf("Invalid key %q: expected [index] after %s", key, field)
+			}
+			parts = append(parts, keyPart{name: rest[1:end], entry: true})
+			rest = rest[end+1:]
+		}
+	}
+	return parts, nil
+}
+
+// keyString joins parts back into a key.
+func keyString(parts []keyPart) string {
+	var b strings.Builder
+	for i, p := range parts {
+		switch {
+		case p.entry:
+			b.WriteString("[" + p.name + "]")
+		case i > 0:
+			b.WriteString("." + p.name)
+		default:
+			b.WriteString(p.name)
+		}
+	}
+	return b.String()
+}
+
+// lookupKey finds the value a key addresses.
+//
+// If create is set, unset sections on the way are created, so that the value
+// can be set. Otherwise, the value under an unset section is a zero value that
+// is not part of the configuration. Either way, the key is checked against
+// the fields of the configuration.
+//
+// An unknown key is reported with the longest prefix that is valid, and the
+// keys under it.
+func (c *Configfile) lookupKey(key string, create bool) (reflect.Value, []keyPart, error) {
+	parts, err := parseKey(key)
+	if err != nil {
+		return reflect.Value{}, nil, err
+	}
+
+	v := reflect.ValueOf(c).Elem()
+	for i, p := range parts {
+		for v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if create {
+					v.Set(reflect.New(v.Type().Elem()))
+				} else {
+					v = reflect.New(v.Type().Elem())
+				}
+			}
+			v = v.Elem()
+		}
+		prefix := keyString(parts[:i])
+
+		if p.entry {
+			if v.Kind() != reflect.Slice {
+				return reflect.Value{}, nil, unknownKey(key, prefix, v)
+			}
+			e, err := entry(v, p.name)
+			if err != nil {
+				return reflect.Value{}, nil, fmt.Errorf("Unknown key %q: %s %s", key, prefix, err)
+			}
+			v = e
+			continue
+		}
+
+		if v.Kind() == reflect.Map {
+			v = mapEntry(v, p.name, create)
+			continue
+		}
+
+		f, ok := field(v, p.name)
+		if !ok {
+			return reflect.Value{}, nil, unknownKey(key, prefix, v)
+		}
+		v = f
+	}
+	return v, parts, nil
+}
+
+// mapEntry returns the entry of a map of pointers with a key. A missing entry
+// is created if create is set, and is otherwise a nil pointer that is not
+// part of the map.
+func mapEntry(m reflect.Value, key string, create bool) reflect.Value {
+	k := reflect.ValueOf(key)
+	if e := m.MapIndex(k); e.IsValid() && !e.IsNil() {
+		return e
+	}
+	if !create {
+		return reflect.Zero(m.Type().Elem())
+	}
+	if m.IsNil() {
+		m.Set(reflect.MakeMap(m.Type()))
+	}
+	e := reflect.New(m.Type().Elem().Elem())
+	m.SetMapIndex(k, e)
+	return e
+}
+
+// field returns the field of a struct with a YAML name.
+func field(v reflect.Value, name string) (reflect.Value, bool) {
+	if v.Kind() != reflect.Struct {
+		return reflect.Value{}, false
+	}
+	for i := 0; i < v.NumField(); i++ {
+		if yamlName(v.Type().Field(i)) == name {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// yamlName returns the name of a field in the file, or "" if it is not saved.
+func yamlName(f reflect.StructField) string {
+	if f.PkgPath != "" {
+		return ""
+	}
+	name := strings.Split(f.Tag.Get("yaml"), ",")[0]
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		return strings.ToLower(f.Name)
+	}
+	return name
+}
+
+// entry returns an entry of a list, by index or by the entry's name.
+func entry(v reflect.Value, name string) (reflect.Value, error) {
+	if i, err := strconv.Atoi(name); err == nil {
+		if i < 0 || i >= v.Len() {
+			return reflect.Value{}, fmt.Errorf("has %d entries, numbered from 0", v.Len())
+		}
+		return v.Index(i), nil
+	}
+	names := []string{}
+	for i := 0; i < v.Len(); i++ {
+		e := reflect.Indirect(v.Index(i))
+		if n, ok := field(e, "name"); ok && n.Kind() == reflect.String {
+			if n.String() == name {
+				return v.Index(i), nil
+			}
+			names = append(names, n.String())
+		}
+	}
+	if len(names) == 0 {
+		return reflect.Value{}, fmt.Errorf("has no entry %q", name)
+	}
+	return reflect.Value{}, fmt.Errorf("has no entry named %q (use one of %s, or an index)", name, strings.Join(names, ", "))
+}
+
+// keyNames returns the YAML names of the fields of a struct.
+func keyNames(v reflect.Value) []string {
+	names := []string{}
+	if v.Kind() != reflect.Struct {
+		return names
+	}
+	for i := 0; i < v.NumField(); i++ {
+		if n := yamlName(v.Type().Field(i)); n != "" {
+			names = append(names, n)
+		}
+	}
+	return names
+}
+
+// unknownKey reports a key that does not exist under prefix, whose value is v.
+func unknownKey(key, prefix string, v reflect.Value) error {
+	names := keyNames(v)
+	switch {
+	case v.Kind() == reflect.Slice:
+		return fmt.Errorf("Unknown key %q: %s is a list, so it needs an [index]", key, prefix)
+	case len(names) == 0:
+		return fmt.Errorf("Unknown key %q: %s is a single value, with no keys under it", key, prefix)
+	case prefix == "":
+		return fmt.Errorf("Unknown key %q. The top-level keys are: %s", key, strings.Join(names, ", "))
+	}
+	return fmt.Errorf("Unknown key %q. The nearest valid key is %s, which has: %s", key, prefix, strings.Join(names, ", "))
+}
+
+// managed returns why a key may not be changed, or "" if it may be.
+func managed(parts []keyPart) string {
+	generic := make([]keyPart, len(parts))
+	for i, p := range parts {
+		if p.entry {
+			p.name = ""
+		}
+		generic[i] = p
+	}
+	return managedKeys[keyString(generic)]
+}
+
+// Get returns the value of a key, or nil if it is not set.
+func (c *Configfile) Get(key string) (interface{}, error) {
+	v, _, err := c.lookupKey(key, false)
+	if err != nil {
+		return nil, err
+	}
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return nil, nil
+	}
+	return v.Interface(), nil
+}
+
+// Set parses a value for the type of a key, and sets it.
+//
+// Only single values, such as strings, numbers, and booleans, can be set,
+// and lists of strings, which are given separated by spaces. Sections that
+// the key needs are created.
+func (c *Configfile) Set(key, value string) error {
+	v, parts, err := c.lookupKey(key, true)
+	if err != nil {
+		return err
+	}
+	if why := managed(parts); why != "" {
+		return fmt.Errorf("%s cannot be set: %s", key, why)
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s must be true or false, not %q", key, value)
+		}
+		v.SetBool(b)
+	case reflect.Int:
+		i, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s must be a whole number, not %q", key, value)
+		}
+		v.SetInt(int64(i))
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("%s is a list, and cannot be set as a whole", key)
+		}
+		v.Set(reflect.ValueOf(strings.Fields(value)))
+	case reflect.Map:
+		return fmt.Errorf("%s is a map. Set a key of one of its entries, as in %s.NAME.KEY", key, key)
+	default:
+		t := v.Type()
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		return fmt.Errorf("%s is a section. Set one of its keys: %s", key, strings.Join(keyNames(reflect.New(t).Elem()), ", "))
+	}
+	return nil
+}
+
+// Unset returns a key to its default, which removes it from the file.
+//
+// Unsetting a section unsets every key in it. Entries of a list cannot be
+// unset; they are removed with the commands that manage them.
+func (c *Configfile) Unset(key string) error {
+	v, parts, err := c.lookupKey(key, false)
+	if err != nil {
+		return err
+	}
+	if parts[len(parts)-1].entry {
+		return fmt.Errorf("%s is an entry of a list, and cannot be unset", key)
+	}
+	if why := managed(parts); why != "" {
+		return fmt.Errorf("%s cannot be unset: %s", key, why)
+	}
+	if len(parts) > 1 {
+		// An entry of a map is removed from it.
+		if m, _, err := c.lookupKey(keyString(parts[:len(parts)-1]), false); err == nil && m.Kind() == reflect.Map {
+			if !m.IsNil() {
+				m.SetMapIndex(reflect.ValueOf(parts[len(parts)-1].name), reflect.Value{})
+			}
+			return nil
+		}
+	}
+	if v.CanSet() {
+		v.Set(reflect.Zero(v.Type()))
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+const keysConfigfile = `apiVersion: v1
+repos:
+  default: charts
+  tables:
+    - name: charts
+      repo: https://github.com/helm/charts
+    - name: mine
+      repo: https://example.com/mine.git
+      branch: stable
+`
+
+func TestConfigKeys(t *testing.T) {
+	cfg, err := Parse([]byte(keysConfigfile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for key, expect := range map[string]interface{}{
+		"repos.default":           "charts",
+		"repos.tables[1].branch":  "stable",
+		"repos.tables[mine].repo": "https://example.com/mine.git",
+		"repos.tables[0].full":    false,
+		"kubectl":                 nil,
+		"kubectl.path":            "",
+	} {
+		v, err := cfg.Get(key)
+		if err != nil {
+			t.Errorf("Could not get %s: %s", key, err)
+		} else if v != expect {
+			t.Errorf("Expected %s to be %v, got %v", key, expect, v)
+		}
+	}
+	if cfg.Kubectl != nil {
+		t.Error("Expected getting kubectl.path not to create the kubectl section")
+	}
+
+	for key, value := range map[string]string{
+		"repos.tables[mine].priority": "10",
+		"repos.tables[0].full":        "true",
+		"kubectl.path":                "/opt/bin/kubectl",
+	} {
+		if err := cfg.Set(key, value); err != nil {
+			t.Errorf("Could not set %s: %s", key, err)
+		}
+	}
+	if cfg.Repos.Tables[1].Priority != 10 || !cfg.Repos.Tables[0].Full || cfg.Kubectl.Path != "/opt/bin/kubectl" {
+		t.Errorf("Expected the values to be set, got %+v %+v %+v", cfg.Repos.Tables[0], cfg.Repos.Tables[1], cfg.Kubectl)
+	}
+
+	if err := cfg.Unset("repos.tables[1].branch"); err != nil {
+		t.Errorf("Could not unset a branch: %s", err)
+	}
+	// A list of strings is set from words.
+	if err := cfg.Set("kubectl.deleteArgs", "--wait  --grace-period=30"); err != nil {
+		t.Errorf("Could not set a list of strings: %s", err)
+	}
+	if a := cfg.Kubectl.DeleteArgs; len(a) != 2 || a[0] != "--wait" || a[1] != "--grace-period=30" {
+		t.Errorf("Unexpected kubectl.deleteArgs: %q", a)
+	}
+	if err := cfg.Unset("kubectl"); err != nil {
+		t.Errorf("Could not unset a section: %s", err)
+	}
+	if cfg.Repos.Tables[1].Branch != "" || cfg.Kubectl != nil {
+		t.Errorf("Expected the keys to be unset")
+	}
+
+	// Profiles are a map, whose entries are created as they are set.
+	if v, err := cfg.Get("profiles.prod.namespace"); err != nil || v != "" || cfg.Profiles != nil {
+		t.Errorf("Expected an unset profile to be empty, and not created, got %v, %v", v, err)
+	}
+	for key, value := range map[string]string{
+		"profiles.prod.namespace":   "prod",
+		"profiles.prod.kubeContext": "prod-cluster",
+		"profiles.dev.namespace":    "dev",
+	} {
+		if err := cfg.Set(key, value); err != nil {
+			t.Errorf("Could not set %s: %s", key, err)
+		}
+	}
+	if p, err := cfg.Profile("prod"); err != nil || p.Namespace != "prod" || p.KubeContext != "prod-cluster" {
+		t.Errorf("Unexpected profile: %+v, %v", p, err)
+	}
+	if err := cfg.Set("profiles", "x"); err == nil || !strings.Contains(err.Error(), "profiles.NAME.KEY") {
+		t.Errorf("Expected setting the profiles to fail, got %v", err)
+	}
+	if err := cfg.Unset("profiles.dev"); err != nil {
+		t.Errorf("Could not unset a profile: %s", err)
+	}
+	if _, err := cfg.Profile("dev"); err == nil || !strings.Contains(err.Error(), "The profiles are: prod") {
+		t.Errorf("Expected dev to be removed, got %v", err)
+	}
+}
+
+func TestConfigKeyErrors(t *testing.T) {
+	cfg, err := Parse([]byte(keysConfigfile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for key, expect := range map[string]string{
+		"repos.tables[1].brnch": "The nearest valid key is repos.tables[1], which has: name, repo, type, branch",
+		"repo.default":          "The top-level keys are: apiVersion, repos, workspace, kubectl, audit, profiles",
+		"repos.tables[5]":       "repos.tables has 2 entries",
+		"repos.tables[theirs]":  "use one of charts, mine, or an index",
+		"repos.tables.name":     "repos.tables is a list, so it needs an [index]",
+		"repos.default.name":    "repos.default is a single value",
+		"repos.tables[0":        "expected [index] after tables",
+	} {
+		if _, err := cfg.Get(key); err == nil || !strings.Contains(err.Error(), expect) {
+			t.Errorf("Expected %s to fail with %q, got %v", key, expect, err)
+		}
+	}
+
+	for key, expect := range map[string]string{
+		"repos.tables[0].priority": "must be a whole number",
+		"repos.tables[0].full":     "must be true or false",
+		"repos.tables[0]":          "is a section. Set one of its keys: name, repo",
+		"repos.tables[0].name":     "helmc repo rename",
+		"apiVersion":               "cannot be set",
+	} {
+		if err := cfg.Set(key, "x"); err == nil || !strings.Contains(err.Error(), expect) {
+			t.Errorf("Expected setting %s to fail with %q, got %v", key, expect, err)
+		}
+	}
+	if err := cfg.Unset("repos.tables[0]"); err == nil {
+		t.Error("Expected unsetting a repository to fail")
+	}
+}
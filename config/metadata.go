@@ -0,0 +1,202 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/helm/helm-classic/chart"
+)
+
+// metadataVersion is the version of the metadata index format. An index of
+// another version is rebuilt.
+const metadataVersion = 2
+
+// Metadata is the index of the charts in the cached copy of a Git repository
+// or directory mirror, so that search, info, and fetch need not read every
+// Chart.yaml of the repository.
+//
+// It is kept in the cache, beside the repository's copy, and regenerated after
+// each update. HTTP repositories do not need one: their index file is cached.
+type Metadata struct {
+	Version int `json:"version"`
+	// Charts are by the name of the chart's directory.
+	Charts map[string]*ChartMetadata `json:"charts"`
+}
+
+// ChartMetadata describes a chart of a Metadata index.
+type ChartMetadata struct {
+	Chart *chart.Chartfile `json:"chart"`
+	// Path is the chart's directory, relative to the repository's copy.
+	Path string `json:"path"`
+	// Digest is the chart.Digest of the chart.
+	Digest string `json:"digest"`
+}
+
+// Names returns the names of the charts' directories, in order.
+func (m *Metadata) Names() []string {
+	names := make([]string, 0, len(m.Charts))
+	for n := range m.Charts {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// chart returns the metadata of a chart directory. m may be nil.
+func (m *Metadata) chart(name string) (*ChartMetadata, bool) {
+	if m == nil {
+		return nil, false
+	}
+	cm, ok := m.Charts[name]
+	return cm, ok
+}
+
+// metadataPath is the file of a repository's metadata index.
+func (r *Repos) metadataPath(name string) string {
+	return filepath.Join(r.Dir, name+".index.json")
+}
+
+// Metadata returns the metadata index of a Git repository or directory
+// mirror.
+//
+// An index that is missing, that cannot be read, or that is older than the
+// repository's copy, is rebuilt by scanning the copy, and saved again if
+// possible. Scanning never fails on a chart that cannot be read: it is left
+// out, as search has always done.
+func (r *Repos) Metadata(name string) (*Metadata, error) {
+	if r.Dir == "" {
+		return nil, errors.New("the repositories have no cache directory")
+	}
+	rpath := filepath.Join(r.Dir, name)
+	if m, ok := r.loadMetadata(name); ok {
+		return m, nil
+	}
+	m, err := scanMetadata(rpath, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.saveMetadata(name, m); err != nil {
+		r.Log.Debug("Could not save the metadata index of %s: %s", name, err)
+	}
+	return m, nil
+}
+
+// Reindex regenerates the metadata index of a Git repository or directory
+// mirror from scratch.
+func (r *Repos) Reindex(name string) error {
+	m, err := scanMetadata(filepath.Join(r.Dir, name), nil, nil)
+	if err != nil {
+		return err
+	}
+	return r.saveMetadata(name, m)
+}
+
+// reindexChanged regenerates the metadata index of a repository after its
+// update, rescanning only the charts of the update's diff if its index is
+// up to date otherwise.
+func (r *Repos) reindexChanged(name, diff string) error {
+	old, ok := r.readMetadata(name)
+	if !ok {
+		return r.Reindex(name)
+	}
+	changed := map[string]bool{}
+	for _, line := range strings.Split(diff, "\n") {
+		if kv := strings.SplitN(line, "\t", 2); len(kv) == 2 {
+			changed[kv[1]] = true
+		}
+	}
+	m, err := scanMetadata(filepath.Join(r.Dir, name), old, changed)
+	if err != nil {
+		return err
+	}
+	return r.saveMetadata(name, m)
+}
+
+// loadMetadata reads the metadata index of a repository. It returns false if
+// the index is missing, unreadable, of another version, or stale.
+func (r *Repos) loadMetadata(name string) (*Metadata, bool) {
+	fi, err := os.Stat(r.metadataPath(name))
+	if err != nil || metadataStale(filepath.Join(r.Dir, name), fi) {
+		return nil, false
+	}
+	return r.readMetadata(name)
+}
+
+// readMetadata is loadMetadata, stale or not.
+func (r *Repos) readMetadata(name string) (*Metadata, bool) {
+	b, err := ioutil.ReadFile(r.metadataPath(name))
+	if err != nil {
+		return nil, false
+	}
+	m := &Metadata{}
+	if err := json.Unmarshal(b, m); err != nil || m.Version != metadataVersion || m.Charts == nil {
+		r.Log.Debug("Rebuilding the metadata index of %s", name)
+		return nil, false
+	}
+	return m, true
+}
+
+// metadataStale reports whether the copy of a repository changed after its
+// index was written. A Git update rewrites .git/index, and a mirror update
+// recreates the copy.
+func metadataStale(rpath string, index os.FileInfo) bool {
+	for _, p := range []string{rpath, filepath.Join(rpath, ".git", "index")} {
+		if fi, err := os.Stat(p); err == nil && fi.ModTime().After(index.ModTime()) {
+			return true
+		}
+	}
+	return false
+}
+
+// saveMetadata writes the metadata index of a repository, through a
+// temporary file so that a reader never sees half of it.
+func (r *Repos) saveMetadata(name string, m *Metadata) error {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(r.Dir, name+".index-")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(b)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), r.metadataPath(name))
+}
+
+// scanMetadata reads the charts of a repository's copy. If old is not nil,
+// the charts that it has and that are not in changed are taken from it.
+func scanMetadata(rpath string, old *Metadata, changed map[string]bool) (*Metadata, error) {
+	files, err := filepath.Glob(filepath.Join(rpath, "*", "Chart.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	m := &Metadata{Version: metadataVersion, Charts: map[string]*ChartMetadata{}}
+	for _, f := range files {
+		dir := filepath.Dir(f)
+		name := filepath.Base(dir)
+		if cm, ok := old.chart(name); ok && !changed[name] {
+			m.Charts[name] = cm
+			continue
+		}
+		c, err := chart.LoadChartfile(f)
+		if err != nil {
+			// This is not a chart. Skip it.
+			continue
+		}
+		digest, _ := chart.Digest(dir)
+		m.Charts[name] = &ChartMetadata{Chart: c, Path: name, Digest: digest}
+	}
+	return m, nil
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/helm/helm-classic/test"
+)
+
+func TestMetadata(t *testing.T) {
+	mirror := mirrorFixture(t)
+	defer os.RemoveAll(mirror)
+	cache := test.CreateTmpHome()
+	defer os.RemoveAll(cache)
+
+	r := &Repos{Dir: cache, Tables: []*Table{{Name: "mirror", Repo: "file://" + mirror, Type: TypeDir}}}
+	if err := r.UpdateAll(true); err != nil {
+		t.Fatal(err)
+	}
+	index := filepath.Join(cache, "mirror.index.json")
+	if _, err := os.Stat(index); err != nil {
+		t.Fatalf("Expected an update to write the index: %s", err)
+	}
+	m, err := r.Metadata("mirror")
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.ExpectEquals(t, len(m.Charts), 2)
+	redis := m.Charts["redis"]
+	if redis == nil || redis.Chart.Version != "0.1.0" || redis.Path != "redis" || len(redis.Digest) != 64 {
+		t.Errorf("Expected redis 0.1.0 with a digest, got %+v", redis)
+	}
+
+	// Only the charts that an update changed are read again.
+	m.Charts["alpine"].Chart.Description = "from the index"
+	if err := r.saveMetadata("mirror", m); err != nil {
+		t.Fatal(err)
+	}
+	ioutil.WriteFile(filepath.Join(cache, "mirror", "redis", "Chart.yaml"), []byte("name: redis\nversion: 0.2.0\n"), 0644)
+	if err := r.reindexChanged("mirror", "M\tredis"); err != nil {
+		t.Fatal(err)
+	}
+	m, _ = r.readMetadata("mirror")
+	test.ExpectEquals(t, m.Charts["alpine"].Chart.Description, "from the index")
+	test.ExpectEquals(t, m.Charts["redis"].Chart.Version, "0.2.0")
+
+	// Reindex reads every chart.
+	if err := r.Reindex("mirror"); err != nil {
+		t.Fatal(err)
+	}
+	m, _ = r.readMetadata("mirror")
+	test.ExpectEquals(t, m.Charts["alpine"].Chart.Description, "")
+
+	// A stale index is rebuilt.
+	os.MkdirAll(filepath.Join(cache, "mirror", "nginx"), 0755)
+	ioutil.WriteFile(filepath.Join(cache, "mirror", "nginx", "Chart.yaml"), []byte("name: nginx\nversion: 0.1.0\n"), 0644)
+	earlier := time.Now().Add(-time.Minute)
+	os.Chtimes(index, earlier, earlier)
+	if m, err = r.Metadata("mirror"); err != nil || m.Charts["nginx"] == nil {
+		t.Errorf("Expected a stale index to be rebuilt, got %v: %v", m, err)
+	}
+
+	// So is a corrupt one, silently.
+	ioutil.WriteFile(index, []byte("{not json"), 0644)
+	if m, err = r.Metadata("mirror"); err != nil || len(m.Charts) != 3 {
+		t.Errorf("Expected a corrupt index to be rebuilt, got %v: %v", m, err)
+	}
+	if _, ok := r.loadMetadata("mirror"); !ok {
+		t.Errorf("Expected the rebuilt index to be saved")
+	}
+
+	// Lookups use it.
+	if c, err := r.CachedChart("mirror", "nginx"); err != nil || c.Version != "0.1.0" {
+		t.Errorf("Expected nginx 0.1.0, got %v: %v", c, err)
+	}
+	test.ExpectEquals(t, len(r.Candidates("nginx")), 1)
+}
@@ -0,0 +1,53 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/helm/helm-classic/log"
+)
+
+// APIVersion is the current version of the configuration format.
+const APIVersion = "v1"
+
+// migrations upgrades a configuration from the keyed version to the next one.
+//
+// The empty version is the original, unversioned format.
+var migrations = map[string]func(*Configfile) string{
+	"": func(c *Configfile) string {
+		// v1 only adds the apiVersion field.
+		return "v1"
+	},
+}
+
+// migrate upgrades a freshly parsed configuration to the current version.
+func (c *Configfile) migrate() error {
+	from := c.APIVersion
+	for c.APIVersion != APIVersion {
+		m, ok := migrations[c.APIVersion]
+		if !ok {
+			return fmt.Errorf("%s was written by a newer version of Helm Classic (apiVersion %q; this version understands %q). Upgrade helmc to use it", c.filename, c.APIVersion, APIVersion)
+		}
+		c.APIVersion = m(c)
+	}
+	if from == "" {
+		c.migrated = "unversioned"
+	} else if from != APIVersion {
+		c.migrated = from
+	}
+	return nil
+}
+
+// saveMigrated backs up the original contents of a migrated file and then
+// rewrites it in the current format.
+func (c *Configfile) saveMigrated(orig []byte) error {
+	backup := fmt.Sprintf("%s.%s.bak", c.filename, time.Now().UTC().Format("20060102150405"))
+	if err := ioutil.WriteFile(backup, orig, 0644); err != nil {
+		return fmt.Errorf("Could not back up %s before migrating it: %s", c.filename, err)
+	}
+
+	log.Info("Migrated %s from %s to %s. The original is in %s", c.filename, c.migrated, APIVersion, backup)
+	c.migrated = ""
+	return c.Save("")
+}
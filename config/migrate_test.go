@@ -0,0 +1,88 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const legacyConfigfile = `repos:
+  default: charts
+  tables:
+    - name: charts
+      repo: https://github.com/helm/charts
+`
+
+func writeConfig(t *testing.T, content string) (string, string) {
+	dir, err := ioutil.TempDir("", "helmc-migrate-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := filepath.Join(dir, "config.yaml")
+	if err := ioutil.WriteFile(f, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir, f
+}
+
+func TestMigrateLegacy(t *testing.T) {
+	dir, f := writeConfig(t, legacyConfigfile)
+	defer os.RemoveAll(dir)
+
+	cfg, err := Load(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.APIVersion != APIVersion {
+		t.Errorf("Expected apiVersion %s, got %q", APIVersion, cfg.APIVersion)
+	}
+	if cfg.Repos.Tables[0].Name != "charts" {
+		t.Errorf("Expected tables to survive migration")
+	}
+
+	b, _ := ioutil.ReadFile(f)
+	if !strings.Contains(string(b), "apiVersion: v1") {
+		t.Errorf("Expected migrated file to be rewritten, got:\n%s", b)
+	}
+
+	backups, _ := filepath.Glob(f + ".*.bak")
+	if len(backups) != 1 {
+		t.Fatalf("Expected one backup, got %v", backups)
+	}
+	if b, _ := ioutil.ReadFile(backups[0]); string(b) != legacyConfigfile {
+		t.Errorf("Expected backup to hold the original, got:\n%s", b)
+	}
+
+	// Loading again does not migrate again.
+	if _, err := Load(f); err != nil {
+		t.Fatal(err)
+	}
+	if backups, _ := filepath.Glob(f + ".*.bak"); len(backups) != 1 {
+		t.Errorf("Expected no further backups, got %v", backups)
+	}
+}
+
+func TestMigrateNewer(t *testing.T) {
+	dir, f := writeConfig(t, "apiVersion: v99\nrepos:\n  default: charts\n")
+	defer os.RemoveAll(dir)
+
+	_, err := Load(f)
+	if err == nil || !strings.Contains(err.Error(), "newer version of Helm Classic") {
+		t.Errorf("Expected a newer version error, got %v", err)
+	}
+	if b, _ := ioutil.ReadFile(f); !strings.Contains(string(b), "v99") {
+		t.Errorf("Expected the file to be left alone")
+	}
+}
+
+func TestParseCurrent(t *testing.T) {
+	cfg, err := Parse([]byte("apiVersion: v1\n" + legacyConfigfile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.migrated != "" {
+		t.Errorf("Did not expect a current file to be migrated")
+	}
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/helm/helm-classic/log"
+)
+
+// deepen fetches a ref that a shallow clone does not have.
+//
+// The ref is tried first as a tag and then as a branch.
+func deepen(g gitClone, ref string) error {
+	if g.fetch(false, "+refs/tags/"+ref+":refs/tags/"+ref) == nil {
+		return nil
+	}
+	return g.fetch(false, "+refs/heads/"+ref+":refs/remotes/origin/"+ref)
+}
+
+// SetDepth switches a Git repository between a full and a shallow clone.
+//
+// Switching to a full clone fetches the missing history. Switching to a
+// shallow clone discards the local copy and clones it again.
+func (r *Repos) SetDepth(name string, full bool) error {
+	t := r.Lookup(name)
+	if t == nil {
+		return ErrNotFound
+	}
+	if t.Type != TypeGit && t.Type != "" {
+		return fmt.Errorf("Only Git repositories can be shallow")
+	}
+	if err := r.checkOnline(t); err != nil {
+		return err
+	}
+
+	l, err := r.lockRepo(name)
+	if err != nil {
+		return err
+	}
+	defer l.Release()
+
+	rpath := filepath.Join(r.Dir, name)
+	out := &log.Buffer{Log: r.Log}
+	defer out.Flush()
+	return r.withGit(t, func(gb gitBackend) error {
+		t.Full = full
+		g, err := ensureRepo(t, rpath, gb)
+		if err != nil {
+			return err
+		}
+
+		if full {
+			if !isShallow(g) {
+				return nil
+			}
+			return g.unshallow()
+		}
+
+		if isShallow(g) {
+			return nil
+		}
+		if g.isDirty() {
+			return fmt.Errorf("Repository '%s' is dirty.  Commit changes before switching to a shallow clone", name)
+		}
+		if err := os.RemoveAll(rpath); err != nil {
+			return err
+		}
+		if g, err = ensureRepo(t, rpath, gb); err != nil {
+			return err
+		}
+		return updateTable(t, g, out)
+	})
+}
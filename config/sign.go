@@ -0,0 +1,80 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/helm/helm-classic/helmpath"
+	"github.com/helm/helm-classic/repo"
+)
+
+// checkIndex verifies a freshly downloaded index against the table's keyring.
+//
+// It returns the detached signature, if there is one, so that it can be
+// cached with the index, and the identity of the signer.
+func checkIndex(t *Table, data []byte, auth string) ([]byte, string, error) {
+	kr, err := repo.LoadKeyring(helmpath.ExpandHome(t.Keyring))
+	if err != nil {
+		return nil, "", err
+	}
+
+	var sig []byte
+	if !repo.IsClearsigned(data) {
+		u := t.IndexURL() + repo.SignatureExt
+		if sig, err = repo.Get(u, auth); err != nil {
+			return nil, "", fmt.Errorf("could not fetch signature: %s", err)
+		}
+	}
+	signer, err := repo.VerifySignature(data, sig, kr)
+	if err != nil {
+		return nil, "", err
+	}
+	return sig, signer, nil
+}
+
+// writeSignature caches the detached signature of an index, or removes a
+// stale one if sig is nil.
+func writeSignature(rpath string, sig []byte) error {
+	sfile := filepath.Join(rpath, repo.IndexFile+repo.SignatureExt)
+	if sig == nil {
+		if err := os.Remove(sfile); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	return ioutil.WriteFile(sfile, sig, 0644)
+}
+
+// VerifyIndex checks the cached index of the named repository against its keyring.
+//
+// It returns the identity of the signer. If the repository has no keyring,
+// it returns "" and no error. Since the cached copy is checked, this also
+// detects an index that was modified after it was downloaded.
+func (r *Repos) VerifyIndex(name string) (string, error) {
+	t := r.Lookup(name)
+	if t == nil {
+		return "", ErrNotFound
+	}
+	if t.Keyring == "" {
+		return "", nil
+	}
+
+	rpath := filepath.Join(r.Dir, name)
+	data, err := ioutil.ReadFile(filepath.Join(rpath, repo.IndexFile))
+	if err != nil {
+		return "", fmt.Errorf("no cached index")
+	}
+	var sig []byte
+	if !repo.IsClearsigned(data) {
+		if sig, err = ioutil.ReadFile(filepath.Join(rpath, repo.IndexFile+repo.SignatureExt)); err != nil {
+			return "", fmt.Errorf("no cached signature")
+		}
+	}
+	kr, err := repo.LoadKeyring(helmpath.ExpandHome(t.Keyring))
+	if err != nil {
+		return "", err
+	}
+	return repo.VerifySignature(data, sig, kr)
+}
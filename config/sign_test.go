@@ -0,0 +1,67 @@
+package config
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/helm/helm-classic/repo"
+	"github.com/helm/helm-classic/test"
+)
+
+func TestSignedIndex(t *testing.T) {
+	key := test.SigningKey("charts")
+	index := []byte("apiVersion: v1\nentries:\n  redis:\n  - {version: 0.1.0, url: redis-0.1.0.tgz}\n")
+	sig := test.DetachSign(key, index)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/index.yaml":
+			w.Write(index)
+		case "/index.yaml.asc":
+			w.Write(sig)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	cache := test.CreateTmpHome()
+	os.MkdirAll(cache, 0755)
+	defer os.RemoveAll(cache)
+	keyring := filepath.Join(cache, "keyring.asc")
+	test.WriteKeyring(keyring, key)
+
+	r := &Repos{Dir: cache}
+	if err := r.Add(&Table{Name: "signed", Repo: ts.URL + "/index.yaml", Keyring: keyring}); err != nil {
+		t.Fatalf("Could not add signed repo: %s", err)
+	}
+	if signer, err := r.VerifyIndex("signed"); err != nil || !strings.HasPrefix(signer, "charts") {
+		t.Errorf("Expected index to be verified, got %q, %v", signer, err)
+	}
+
+	// A tampered index does not replace the verified one.
+	good := index
+	index = append(index, []byte("  evil:\n  - {version: 0.1.0, url: evil.tgz}\n")...)
+	if err := r.Update("signed"); err == nil || !strings.Contains(err.Error(), "failed verification") {
+		t.Errorf("Expected a verification error, got %v", err)
+	}
+	if b, _ := ioutil.ReadFile(filepath.Join(cache, "signed", repo.IndexFile)); string(b) != string(good) {
+		t.Errorf("Expected the cached index to be kept")
+	}
+
+	r.InsecureSkipVerify = true
+	if err := r.Update("signed"); err != nil {
+		t.Errorf("Expected --insecure-skip-verify to accept the index: %s", err)
+	}
+	if _, err := r.VerifyIndex("signed"); err == nil {
+		t.Errorf("Expected the accepted index to be reported as unverified")
+	}
+
+	if err := r.Add(&Table{Name: "git", Repo: "https://github.com/helm/charts", Keyring: keyring}); err == nil {
+		t.Errorf("Expected an error adding a keyring to a Git repo")
+	}
+}
@@ -0,0 +1,96 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/helm/helm-classic/log"
+	helm "github.com/helm/helm-classic/util"
+)
+
+// gitRunner runs git for one repository. It is the exec backend.
+type gitRunner struct {
+	// name is the repository, as used in the log prefix.
+	name string
+	// env holds the credentials for git, if any.
+	env map[string]string
+	// trace is the level of git tracing. See Repos.TraceGit.
+	trace int
+	// timeout bounds the commands that use the network. See Repos.GitTimeout.
+	timeout time.Duration
+	log     *log.Logger
+}
+
+// networkCommands are the git commands that talk to a remote.
+var networkCommands = map[string]bool{"clone": true, "fetch": true, "pull": true, "push": true, "ls-remote": true}
+
+// run runs git and returns its stdout.
+//
+// The log prefix names the repository, e.g. "git fetch charts". If dir is
+// empty, git runs in the current directory. Secrets are redacted from
+// everything that is logged. Commands that talk to the remote are killed
+// after the runner's timeout.
+func (gr *gitRunner) run(dir string, args ...string) ([]byte, error) {
+	prefix := fmt.Sprintf("git %s %s", args[0], gr.name)
+	ctx := helm.Context()
+	if networkCommands[args[0]] {
+		var cancel context.CancelFunc
+		ctx, cancel = helm.WithTimeout(gr.timeout)
+		defer cancel()
+	}
+	cmd := exec.Command("git", progressArgs(gr.trace > 0 || helm.ProgressMode != helm.ProgressOff, args)...)
+	cmd.Dir = dir
+	env := map[string]string{}
+	for k, v := range gr.env {
+		env[k] = v
+	}
+	if gr.trace > 1 {
+		env["GIT_TRACE"] = "1"
+		env["GIT_CURL_VERBOSE"] = "1"
+	}
+	if len(env) > 0 {
+		cmd.Env = helm.Environ(env)
+	}
+
+	line := helm.Redact(strings.Join(cmd.Args, " "))
+	stderr := &helm.Stderr{Prefix: prefix, Verbose: gr.trace > 0, Log: gr.log, Progress: true}
+	if gr.trace == 0 {
+		gr.log.Debug("Running %s", line)
+		return stderr.ExecContext(ctx, cmd)
+	}
+
+	if dir == "" {
+		dir, _ = os.Getwd()
+	}
+	gr.log.Info("[%s] Running %s in %s", prefix, line, dir)
+	start := time.Now()
+	out, err := stderr.ExecContext(ctx, cmd)
+	if err != nil {
+		gr.log.Info("[%s] Failed after %s", prefix, time.Since(start))
+	} else {
+		gr.log.Info("[%s] Finished in %s", prefix, time.Since(start))
+	}
+	return out, err
+}
+
+// progressArgs asks git to report the progress of network operations, when
+// tracing or when progress is shown.
+func progressArgs(progress bool, args []string) []string {
+	switch {
+	case !progress:
+		return args
+	case args[0] != "clone" && args[0] != "fetch" && args[0] != "pull" && args[0] != "push":
+		return args
+	}
+	res := []string{args[0], "--progress"}
+	for _, a := range args[1:] {
+		if a != "-q" && a != "--quiet" {
+			res = append(res, a)
+		}
+	}
+	return res
+}
@@ -0,0 +1,135 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/helm/helm-classic/repo"
+	helm "github.com/helm/helm-classic/util"
+)
+
+// scpURL matches scp-style Git URLs, e.g. git@github.com:helm/charts.git.
+var scpURL = regexp.MustCompile(`^([\w.-]+@)?[\w.-]+:[^/\\]`)
+
+// ValidateURL checks that a table's URL is well-formed for its type.
+//
+// This does not contact the remote. Git repositories may be remote URLs,
+// scp-style addresses, or absolute local paths. HTTP repositories must use
+// http or https. For directory mirrors, the directory must exist and hold
+// at least one chart.
+func ValidateURL(t *Table) error {
+	typ := t.Type
+	if typ == "" {
+		typ = DetectType(t.Repo)
+	}
+
+	if typ == TypeDir {
+		dir, err := dirPath(t.Repo)
+		if err != nil {
+			return err
+		}
+		return checkMirror(dir)
+	}
+
+	if typ == TypeGit && (filepath.IsAbs(t.Repo) || (scpURL.MatchString(t.Repo) && !strings.Contains(t.Repo, "://"))) {
+		return nil
+	}
+
+	u, err := url.Parse(t.Repo)
+	if err != nil {
+		return fmt.Errorf("Invalid URL %q: %s", t.Repo, err)
+	}
+
+	schemes := []string{"http", "https"}
+	if typ == TypeGit {
+		schemes = append(schemes, "git", "ssh", "file")
+	}
+	for _, s := range schemes {
+		if u.Scheme == s {
+			if u.Host == "" && s != "file" {
+				return fmt.Errorf("Invalid URL %q: no host", t.Repo)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("Invalid URL %q: %s repositories must use one of %s", t.Repo, typ, strings.Join(schemes, ", "))
+}
+
+// Verify contacts the remote to check that a table points to a usable repository.
+//
+// For Git repositories, this lists the remote's branches with the table's Git
+// backend, as `git ls-remote` does. For HTTP repositories, the index is
+// downloaded and parsed. A directory mirror must hold charts.
+func (r *Repos) Verify(t *Table) error {
+	if err := r.checkOnline(t); err != nil {
+		return err
+	}
+	if t.IsDir() {
+		dir, err := dirPath(t.Repo)
+		if err != nil {
+			return err
+		}
+		return checkMirror(dir)
+	}
+	if t.Type == TypeHTTP || t.Type == "" && DetectType(t.Repo) == TypeHTTP {
+		auth, err := t.authorization()
+		if err != nil {
+			return err
+		}
+		data, err := repo.Get(t.IndexURL(), auth)
+		if err != nil {
+			return fmt.Errorf("Could not fetch index for %s: %s", t.Repo, err)
+		}
+		if _, err := repo.ParseIndex(data); err != nil {
+			return fmt.Errorf("Index at %s is malformed: %s", t.IndexURL(), err)
+		}
+		if t.Keyring != "" {
+			if _, _, err := checkIndex(t, data, auth); err != nil {
+				return fmt.Errorf("Index at %s failed verification: %s", t.IndexURL(), err)
+			}
+		}
+		return nil
+	}
+
+	return r.withGit(t, func(gb gitBackend) error {
+		if err := gb.lsRemote(t.Repo); err != nil {
+			return fmt.Errorf("%s is not a Git repository: %s", helm.Redact(t.Repo), err)
+		}
+		return nil
+	})
+}
+
+// Validate checks a configuration before it is saved.
+//
+// Each repository must have a unique name and a URL, and settings that go
+// together, as Repos.Add requires. The default repository must be one of
+// them. URLs are not checked; see ValidateURL.
+func (c *Configfile) Validate() error {
+	if c.Repos == nil {
+		return fmt.Errorf("repos: the configuration has no repositories section")
+	}
+	names := map[string]bool{}
+	for i, t := range c.Repos.Tables {
+		switch {
+		case t == nil:
+			return fmt.Errorf("repos.tables[%d] is empty", i)
+		case t.Name == "":
+			return fmt.Errorf("repos.tables[%d]: a repository must have a name", i)
+		case names[t.Name]:
+			return fmt.Errorf("repos.tables[%d]: there is already a repository named %s", i, t.Name)
+		case t.Repo == "":
+			return fmt.Errorf("repos.tables[%d]: repository %s must have a repo URL", i, t.Name)
+		}
+		names[t.Name] = true
+		if err := t.check(); err != nil {
+			return fmt.Errorf("repos.tables[%d]: %s", i, err)
+		}
+	}
+	if d := c.Repos.Default; d != "" && !names[d] {
+		return fmt.Errorf("repos.default: there is no repository named %s", d)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestValidateURL(t *testing.T) {
+	good := []*Table{
+		{Repo: "https://github.com/helm/charts"},
+		{Repo: "git@github.com:helm/charts.git"},
+		{Repo: "ssh://git@github.com/helm/charts.git"},
+		{Repo: "file:///srv/charts", Type: TypeGit},
+		{Repo: "/srv/charts"},
+		{Repo: "https://example.com/charts/index.yaml"},
+		{Repo: "https://example.com/charts", Type: TypeHTTP},
+	}
+	for _, tbl := range good {
+		if err := ValidateURL(tbl); err != nil {
+			t.Errorf("Expected %s to be valid: %s", tbl.Repo, err)
+		}
+	}
+
+	bad := []*Table{
+		{Repo: "github.com/helm/charts"},
+		{Repo: "htps://github.com/helm/charts"},
+		{Repo: "https:///charts"},
+		{Repo: "git@github.com:helm/charts.git", Type: TypeHTTP},
+		{Repo: "file:///srv/charts/index.yaml"},
+	}
+	for _, tbl := range bad {
+		if err := ValidateURL(tbl); err == nil {
+			t.Errorf("Expected %s to be invalid", tbl.Repo)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	remote := gitFixture(t)
+	defer os.RemoveAll(remote)
+	r := &Repos{}
+	if err := r.Verify(&Table{Repo: remote}); err != nil {
+		t.Errorf("Expected fixture to verify: %s", err)
+	}
+	if err := r.Verify(&Table{Repo: remote + "-missing"}); err == nil {
+		t.Errorf("Expected a missing repository to fail verification")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for expect, data := range map[string]string{
+		"": keysConfigfile,
+		"repos.default: there is no repository named gone":            "repos:\n  default: gone\n  tables:\n    - name: charts\n      repo: https://github.com/helm/charts\n",
+		"repos.tables[1]: there is already a repository named charts": "repos:\n  tables:\n    - name: charts\n      repo: a\n    - name: charts\n      repo: b\n",
+		"repos.tables[0]: repository charts must have a repo URL":     "repos:\n  tables:\n    - name: charts\n",
+		"repos.tables[0]: Only Git repositories can be pinned":        "repos:\n  tables:\n    - name: charts\n      repo: https://example.com/index.yaml\n      type: http\n      tag: v1\n",
+		"repos.tables[0]: Unknown Git backend":                        "repos:\n  tables:\n    - name: charts\n      repo: a\n      backend: libgit\n",
+	} {
+		cfg, err := Parse([]byte(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = cfg.Validate()
+		switch {
+		case expect == "" && err != nil:
+			t.Errorf("Expected a valid configuration, got %s", err)
+		case expect != "" && (err == nil || !strings.HasPrefix(err.Error(), expect)):
+			t.Errorf("Expected %q, got %v", expect, err)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 
@@ -40,19 +41,13 @@ func Resolve(cf *chart.Chartfile, installdir string) ([]*chart.Dependency, error
 		resolved := false
 		for n, chart := range cache {
 			log.Debug("Checking if %s (%s) %s meets %s %s", chart.Name, n, chart.Version, check.Name, check.Version)
-			if chart.From != nil {
-				log.Debug("✔︎")
-				if satisfies(chart.From, check) {
-					resolved = true
-					break
-				}
-			} else {
+			if chart.From == nil {
 				log.Info("Chart %s is pre-0.2.0. Legacy mode enabled.", chart.Name)
-				if chart.Name == check.Name && check.VersionOK(chart.Version) {
-					log.Debug("✔︎")
-					resolved = true
-					break
-				}
+			}
+			if meets(chart, check) {
+				log.Debug("✔︎")
+				resolved = true
+				break
 			}
 		}
 		if !resolved {
@@ -63,6 +58,17 @@ func Resolve(cf *chart.Chartfile, installdir string) ([]*chart.Dependency, error
 	return res, nil
 }
 
+// meets checks that a chart in the workspace satisfies a dependency.
+//
+// Charts fetched before 0.2.0 do not record where they came from, so only
+// their name and version are checked.
+func meets(cf *chart.Chartfile, check *chart.Dependency) bool {
+	if cf.From == nil {
+		return cf.Name == check.Name && check.VersionOK(cf.Version)
+	}
+	return satisfies(cf.From, check)
+}
+
 // satisfies checks that this satisfies the dependency spec in that.
 func satisfies(this, that *chart.Dependency) bool {
 	if this.Name != that.Name {
@@ -134,6 +140,8 @@ func dependencyCache(chartdir string) (map[string]*chart.Chartfile, error) {
 //	- ssh://user@HOST/PATH.git -> HOST/PATH.git
 //	- user@HOST:PATH.git  -> HOST/PATH.git
 //
+// The name always uses forward slashes, whatever the platform.
+//
 // In the case where no suitable normalization can be found, this will return
 // the original string, assuming that there is some additional Git representation
 // that we don't know about.
@@ -150,7 +158,7 @@ func canonicalRepo(name string) (string, error) {
 			u.Host = "localhost"
 		}
 
-		return filepath.Join(u.Host, u.Path), nil
+		return path.Join(u.Host, u.Path), nil
 	} else if i := strings.Index(name, "@"); i > 0 && i < strings.Index(name, ":") {
 
 		a := strings.SplitN(name, "@", 2)
@@ -163,8 +171,8 @@ func canonicalRepo(name string) (string, error) {
 			return name, fmt.Errorf("Could not parse SCP name %s: ':' split failed", name)
 		}
 
-		return filepath.Join(a[0], a[1]), nil
+		return path.Join(a[0], a[1]), nil
 	}
 	// Is a filepath
-	return filepath.Join("localhost", name), nil
+	return path.Join("localhost", filepath.ToSlash(name)), nil
 }
@@ -1,7 +1,11 @@
 package dependency
 
 import (
+	"fmt"
+	"io/ioutil"
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/helm/helm-classic/chart"
@@ -120,3 +124,71 @@ func TestSatisfies(t *testing.T) {
 		t.Errorf("Expected aa to not satisfy b because of version constraint.")
 	}
 }
+
+func TestBuild(t *testing.T) {
+	cf, err := chart.LoadChartfile(filepath.Join(testInstalldir, "deptest/Chart.yaml"))
+	if err != nil {
+		t.Fatalf("Could not load chartfile deptest/Chart.yaml: %s", err)
+	}
+	g, err := Build(cf, testInstalldir, nil)
+	if err != nil {
+		t.Fatalf("Could not build the graph: %s", err)
+	}
+
+	ids := []string{}
+	for _, n := range g.Nodes {
+		ids = append(ids, fmt.Sprintf("%s missing=%t", n.ID, n.Missing))
+	}
+	expect := []string{
+		"bogodep@~10.21 missing=true",
+		"dep1@1.2.3 missing=false",
+		"dep2@1.2.9 missing=false",
+		"dep3@<=2.0 missing=true",
+		"deptest@2.3.4 missing=false",
+		"honkIfYouLoveDucks@5.6.7 missing=true",
+		"kitchensink@0.0.1 missing=false",
+	}
+	if strings.Join(ids, "\n") != strings.Join(expect, "\n") {
+		t.Errorf("Expected nodes:\n%s\ngot:\n%s", strings.Join(expect, "\n"), strings.Join(ids, "\n"))
+	}
+	if e := g.Children("kitchensink@0.0.1"); len(e) != 1 || e[0].To != "bogodep@~10.21" || e[0].Constraint != "~10.21" {
+		t.Errorf("Expected kitchensink to depend on bogodep, got %v", e)
+	}
+	for _, e := range g.Edges {
+		if e.Cycle {
+			t.Errorf("Unexpected cycle %s -> %s", e.From, e.To)
+		}
+	}
+}
+
+func TestBuildCycle(t *testing.T) {
+	dir, err := ioutil.TempDir("", "helmc-deps")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for name, dep := range map[string]string{"a": "b", "b": "c", "c": "a"} {
+		os.Mkdir(filepath.Join(dir, name), 0755)
+		data := fmt.Sprintf("name: %s\nversion: 1.0.0\ndependencies:\n  - name: %s\n    version: \"^1\"\n", name, dep)
+		if err := ioutil.WriteFile(filepath.Join(dir, name, "Chart.yaml"), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cf, err := chart.LoadChartfile(filepath.Join(dir, "a", "Chart.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := Build(cf, dir, nil)
+	if err != nil {
+		t.Fatalf("Could not build the graph: %s", err)
+	}
+	if len(g.Nodes) != 3 || len(g.Edges) != 3 {
+		t.Fatalf("Expected 3 nodes and 3 edges, got %d and %d", len(g.Nodes), len(g.Edges))
+	}
+	for _, e := range g.Edges {
+		if cycle := e.From == "c@1.0.0"; e.Cycle != cycle {
+			t.Errorf("Expected %s -> %s to have cycle=%t", e.From, e.To, cycle)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package dependency
+
+import (
+	"os"
+	"sort"
+
+	"github.com/helm/helm-classic/chart"
+)
+
+// Node is a chart in a dependency graph.
+type Node struct {
+	// ID is name@version, or name@constraint for a missing chart.
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+	// Repo is the URL of the repository the chart was fetched from, if known.
+	Repo string `json:"repo,omitempty"`
+	// Missing is set if no chart in the workspace satisfies the dependency,
+	// nor any chart of the source of Build.
+	Missing bool `json:"missing,omitempty"`
+	// Fetch is set if the workspace does not have the chart, and resolving
+	// the dependencies would fetch it from Repo.
+	Fetch bool `json:"fetch,omitempty"`
+}
+
+// Edge is a dependency of one chart on another.
+type Edge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Constraint is the version constraint that From declares.
+	Constraint string `json:"constraint"`
+	// Cycle is set if To depends, directly or not, on From.
+	Cycle bool `json:"cycle,omitempty"`
+}
+
+// Graph is the dependency tree of a chart, resolved against the charts in a
+// workspace.
+//
+// Nodes are sorted by ID, and edges by the IDs they join, so that the same
+// workspace always gives the same graph.
+type Graph struct {
+	// Root is the ID of the chart whose dependencies these are.
+	Root  string  `json:"root"`
+	Nodes []*Node `json:"nodes"`
+	Edges []*Edge `json:"edges"`
+}
+
+// Node returns the node with an ID, or nil.
+func (g *Graph) Node(id string) *Node {
+	i := sort.Search(len(g.Nodes), func(i int) bool { return g.Nodes[i].ID >= id })
+	if i < len(g.Nodes) && g.Nodes[i].ID == id {
+		return g.Nodes[i]
+	}
+	return nil
+}
+
+// Children returns the edges from the node with an ID.
+func (g *Graph) Children(id string) []*Edge {
+	res := []*Edge{}
+	for _, e := range g.Edges {
+		if e.From == id {
+			res = append(res, e)
+		}
+	}
+	return res
+}
+
+// Build resolves the dependencies of a chart, and of each chart that
+// satisfies them, against the charts in installdir.
+//
+// Dependencies are satisfied as they are by Resolve. If several charts
+// satisfy one, the first by directory name is used. If src is not nil, a
+// dependency that no chart of the workspace satisfies is resolved against its
+// candidates as by ResolveAll, to a node marked Fetch; src.Fetch must only
+// read the Chart.yaml of the candidate, not copy it into the workspace.
+// Unsatisfied dependencies are missing nodes, which have no dependencies of
+// their own.
+func Build(cf *chart.Chartfile, installdir string, src Source) (*Graph, error) {
+	cache, err := dependencyCache(installdir)
+	if err != nil && !(src != nil && os.IsNotExist(err)) {
+		return nil, err
+	}
+	dirs := make([]string, 0, len(cache))
+	for d := range cache {
+		dirs = append(dirs, d)
+	}
+	sort.Strings(dirs)
+
+	nodes := map[string]*Node{}
+	g := &Graph{Root: chartNode(cf).ID, Edges: []*Edge{}}
+	nodes[g.Root] = chartNode(cf)
+
+	// visiting holds the charts on the current path from the root.
+	visiting := map[string]bool{}
+	var visit func(id string, cf *chart.Chartfile)
+	visit = func(id string, cf *chart.Chartfile) {
+		visiting[id] = true
+		for _, d := range cf.Dependencies {
+			if err != nil {
+				return
+			}
+			e := &Edge{From: id, Constraint: d.Version}
+			g.Edges = append(g.Edges, e)
+
+			var dep *chart.Chartfile
+			for _, dir := range dirs {
+				if meets(cache[dir], d) {
+					dep = cache[dir]
+					break
+				}
+			}
+			var fetch *Candidate
+			if dep == nil && src != nil {
+				if fetch, _, err = bestCandidate(src, d); err == nil && fetch != nil {
+					dep, err = src.Fetch(fetch)
+				}
+				if err != nil {
+					return
+				}
+			}
+			if dep == nil {
+				e.To = d.Name + "@" + d.Version
+				nodes[e.To] = &Node{ID: e.To, Name: d.Name, Repo: d.Repo, Missing: true}
+				continue
+			}
+
+			n := chartNode(dep)
+			if fetch != nil {
+				n.Repo, n.Fetch = fetch.Origin, true
+			}
+			e.To = n.ID
+			e.Cycle = visiting[n.ID]
+			if _, seen := nodes[n.ID]; !seen {
+				nodes[n.ID] = n
+				visit(n.ID, dep)
+			}
+		}
+		visiting[id] = false
+	}
+	err = nil
+	visit(g.Root, cf)
+	if err != nil {
+		return nil, err
+	}
+
+	g.Nodes = make([]*Node, 0, len(nodes))
+	for _, n := range nodes {
+		g.Nodes = append(g.Nodes, n)
+	}
+	sort.Slice(g.Nodes, func(i, j int) bool { return g.Nodes[i].ID < g.Nodes[j].ID })
+	sort.SliceStable(g.Edges, func(i, j int) bool {
+		a, b := g.Edges[i], g.Edges[j]
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		return a.To < b.To
+	})
+	return g, nil
+}
+
+// chartNode describes a chart by the name and version that dependencies are
+// matched against.
+func chartNode(cf *chart.Chartfile) *Node {
+	if cf.From == nil {
+		return &Node{ID: cf.Name + "@" + cf.Version, Name: cf.Name, Version: cf.Version}
+	}
+	f := cf.From
+	return &Node{ID: f.Name + "@" + f.Version, Name: f.Name, Version: f.Version, Repo: f.Repo}
+}
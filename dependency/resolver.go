@@ -0,0 +1,287 @@
+package dependency
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"gopkg.in/yaml.v2"
+
+	"github.com/helm/helm-classic/chart"
+)
+
+// LockFile is the file of a chart in which ResolveAll's results are
+// recorded, by Lock.Save.
+const LockFile = "Chart.lock"
+
+// Candidate is a chart of a repository that a missing dependency could be
+// resolved to.
+type Candidate struct {
+	// Repo is the name of the repository.
+	Repo string
+	// Origin is the URL of the repository, which the repo of a dependency is
+	// matched against.
+	Origin  string
+	Name    string
+	Version string
+}
+
+// Source is where ResolveAll finds the charts that the workspace is missing.
+type Source interface {
+	// Candidates returns the charts of a name that the repositories have,
+	// the preferred first.
+	Candidates(name string) ([]*Candidate, error)
+	// Fetch copies a candidate into the workspace, as a chart of its name,
+	// and returns its Chart.yaml.
+	Fetch(c *Candidate) (*chart.Chartfile, error)
+}
+
+// Resolved is a chart that a dependency was resolved to.
+type Resolved struct {
+	Name    string `yaml:"name"`
+	Version string `yaml:"version"`
+	// Repo is the URL of the repository the chart was fetched from, if known.
+	Repo string `yaml:"repo,omitempty"`
+	// Chart is the name of the chart in the workspace.
+	Chart string `yaml:"chart"`
+	// RequiredBy are the IDs, name@version, of the charts that depend on it.
+	RequiredBy []string `yaml:"requiredBy"`
+	// Fetched is set if ResolveAll fetched the chart, rather than found it
+	// in the workspace.
+	Fetched bool `yaml:"-"`
+}
+
+// CycleError is returned by ResolveAll for charts that depend on each other.
+type CycleError struct {
+	// Path are the IDs of the charts of the cycle, starting and ending with
+	// the same one.
+	Path []string
+}
+
+func (e *CycleError) Error() string {
+	return "Dependency cycle: " + strings.Join(e.Path, " -> ")
+}
+
+// ConflictError is returned by ResolveAll for a dependency that no chart of
+// a repository satisfies.
+type ConflictError struct {
+	// From is the ID of the chart that declares the dependency.
+	From       string
+	Dependency *chart.Dependency
+	// Found are the versions that the repositories have, as repo/name@version,
+	// or the chart that was already resolved for another dependency of the
+	// same name.
+	Found []string
+}
+
+func (e *ConflictError) Error() string {
+	d := e.Dependency
+	msg := fmt.Sprintf("No chart satisfies %s %s, which %s depends on", d.Name, d.Version, e.From)
+	if d.Repo != "" {
+		msg += ", from " + d.Repo
+	}
+	if len(e.Found) == 0 {
+		return msg + ". No repository has it; see 'helmc update'."
+	}
+	return msg + ". Found " + strings.Join(e.Found, ", ") + "."
+}
+
+// ResolveAll resolves the dependencies of a chart, and those of each chart
+// that satisfies them in turn, fetching the charts that the workspace in
+// installdir is missing from src.
+//
+// A dependency that a chart of the workspace satisfies, as with Resolve, is
+// resolved to it. Any other is resolved to the chart of the highest version
+// among the candidates of src that satisfy its constraint and repo; the repo
+// of a dependency may be the URL of a repository, or its name. Versions that
+// are equal are broken by the order of the candidates. Each chart is fetched
+// as the chart of its name, so a chart that was fetched for one dependency
+// must satisfy every other of that name.
+//
+// The charts are returned in the order in which they are installed: each
+// after those it depends on. Charts that depend on each other return a
+// *CycleError, and a dependency that nothing satisfies a *ConflictError; the
+// charts fetched before stay in the workspace.
+func ResolveAll(cf *chart.Chartfile, installdir string, src Source) ([]*Resolved, error) {
+	cache, err := dependencyCache(installdir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	r := &resolver{src: src, cache: cache, fetched: map[string][2]string{}, byID: map[string]*Resolved{}, onPath: map[string]bool{}}
+	if err := r.visit(chartNode(cf).ID, cf); err != nil {
+		return nil, err
+	}
+	return r.order, nil
+}
+
+// resolver is the state of ResolveAll.
+type resolver struct {
+	src   Source
+	cache map[string]*chart.Chartfile
+	// fetched are the IDs of the charts that were fetched, by name, and
+	// the ID of the chart each was fetched for.
+	fetched map[string][2]string
+	byID    map[string]*Resolved
+	order   []*Resolved
+	// path are the IDs of the charts from the root to the current one.
+	path   []string
+	onPath map[string]bool
+}
+
+func (r *resolver) visit(id string, cf *chart.Chartfile) error {
+	r.path = append(r.path, id)
+	r.onPath[id] = true
+	defer func() {
+		r.path = r.path[:len(r.path)-1]
+		r.onPath[id] = false
+	}()
+
+	for _, d := range cf.Dependencies {
+		dir, dep, err := r.find(id, d)
+		if err != nil {
+			return err
+		}
+		n := chartNode(dep)
+		if r.onPath[n.ID] {
+			i := 0
+			for r.path[i] != n.ID {
+				i++
+			}
+			return &CycleError{Path: append(append([]string{}, r.path[i:]...), n.ID)}
+		}
+		if res := r.byID[n.ID]; res != nil {
+			res.RequiredBy = append(res.RequiredBy, id)
+			continue
+		}
+		res := &Resolved{Name: n.Name, Version: n.Version, Repo: n.Repo, Chart: dir, RequiredBy: []string{id}}
+		res.Fetched = r.fetched[dir][0] == n.ID
+		r.byID[n.ID] = res
+		if err := r.visit(n.ID, dep); err != nil {
+			return err
+		}
+		r.order = append(r.order, res)
+	}
+	return nil
+}
+
+// find returns the workspace chart that satisfies a dependency of the chart
+// from, fetching it if necessary, and its directory name.
+func (r *resolver) find(from string, d *chart.Dependency) (string, *chart.Chartfile, error) {
+	for _, dir := range sortedDirs(r.cache) {
+		if meets(r.cache[dir], d) {
+			return dir, r.cache[dir], nil
+		}
+	}
+	if f, ok := r.fetched[d.Name]; ok {
+		return "", nil, &ConflictError{From: from, Dependency: d, Found: []string{f[0] + ", for " + f[1]}}
+	}
+
+	best, found, err := bestCandidate(r.src, d)
+	if err != nil {
+		return "", nil, err
+	}
+	if best == nil {
+		return "", nil, &ConflictError{From: from, Dependency: d, Found: found}
+	}
+
+	dep, err := r.src.Fetch(best)
+	if err != nil {
+		return "", nil, err
+	}
+	// The cache of a Git repository may be newer than its metadata.
+	if n := chartNode(dep); n.Name != d.Name || !d.VersionOK(n.Version) {
+		return "", nil, &ConflictError{From: from, Dependency: d, Found: []string{best.Repo + "/" + n.ID + ", as fetched"}}
+	}
+	if r.cache == nil {
+		r.cache = map[string]*chart.Chartfile{}
+	}
+	r.cache[best.Name] = dep
+	r.fetched[best.Name] = [2]string{chartNode(dep).ID, from}
+	return best.Name, dep, nil
+}
+
+// bestCandidate returns the candidate of src of the highest version that
+// satisfies the constraint and repo of a dependency, or nil, and all of the
+// candidates, as repo/name@version.
+func bestCandidate(src Source, d *chart.Dependency) (*Candidate, []string, error) {
+	candidates, err := src.Candidates(d.Name)
+	if err != nil {
+		return nil, nil, err
+	}
+	var best *Candidate
+	var bestVersion *semver.Version
+	found := make([]string, len(candidates))
+	for i, c := range candidates {
+		found[i] = c.Repo + "/" + c.Name + "@" + c.Version
+		if c.Repo != d.Repo && !satisfies(&chart.Dependency{Name: c.Name, Version: c.Version, Repo: c.Origin}, d) {
+			continue
+		}
+		if !d.VersionOK(c.Version) {
+			continue
+		}
+		v, err := semver.NewVersion(c.Version)
+		if err != nil {
+			continue
+		}
+		if best == nil || v.GreaterThan(bestVersion) {
+			best, bestVersion = c, v
+		}
+	}
+	return best, found, nil
+}
+
+// sortedDirs returns the directories of a dependency cache, sorted, so that
+// the first by name satisfies a dependency, as in Build.
+func sortedDirs(cache map[string]*chart.Chartfile) []string {
+	dirs := make([]string, 0, len(cache))
+	for d := range cache {
+		dirs = append(dirs, d)
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// Lock is the content of a LockFile: the charts that the dependencies of a
+// chart were resolved to, in the order in which they are installed.
+type Lock struct {
+	Dependencies []*Resolved `yaml:"dependencies"`
+}
+
+const lockHeader = `# The charts that the dependencies of this chart were resolved to, by
+# 'helmc fetch --deps' or 'helmc install --deps', each after those it depends
+# on.
+`
+
+// LoadLock reads the LockFile of the chart in dir.
+func LoadLock(dir string) (*Lock, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, LockFile))
+	if err != nil {
+		return nil, err
+	}
+	lk := &Lock{}
+	if err := yaml.Unmarshal(data, lk); err != nil {
+		return nil, fmt.Errorf("Could not parse %s: %s", LockFile, err)
+	}
+	return lk, nil
+}
+
+// Save writes lk to the LockFile of the chart in dir. A lock without
+// dependencies removes the file.
+func (lk *Lock) Save(dir string) error {
+	file := filepath.Join(dir, LockFile)
+	if len(lk.Dependencies) == 0 {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	data, err := yaml.Marshal(lk)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, append([]byte(lockHeader), data...), 0644)
+}
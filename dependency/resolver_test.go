@@ -0,0 +1,157 @@
+package dependency
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/helm/helm-classic/chart"
+)
+
+// fakeSource has the charts of its repositories, by name and repository,
+// and fetches them into a workspace.
+type fakeSource struct {
+	workspace string
+	// charts are repo/name@version: dependencies, as name@constraint.
+	charts  map[string][]string
+	fetched []string
+}
+
+func (s *fakeSource) Candidates(name string) ([]*Candidate, error) {
+	res := []*Candidate{}
+	for _, repo := range []string{"a", "b"} {
+		for id := range s.charts {
+			if strings.HasPrefix(id, repo+"/"+name+"@") {
+				res = append(res, &Candidate{Repo: repo, Origin: "https://example.com/" + repo, Name: name, Version: strings.SplitN(id, "@", 2)[1]})
+			}
+		}
+	}
+	return res, nil
+}
+
+func (s *fakeSource) Fetch(c *Candidate) (*chart.Chartfile, error) {
+	id := c.Repo + "/" + c.Name + "@" + c.Version
+	s.fetched = append(s.fetched, id)
+	cf := &chart.Chartfile{Name: c.Name, Version: c.Version, From: &chart.Dependency{Name: c.Name, Version: c.Version, Repo: c.Origin}}
+	for _, d := range s.charts[id] {
+		f := strings.SplitN(d, "@", 2)
+		cf.Dependencies = append(cf.Dependencies, &chart.Dependency{Name: f[0], Version: f[1]})
+	}
+	os.MkdirAll(filepath.Join(s.workspace, c.Name), 0755)
+	return cf, cf.Save(filepath.Join(s.workspace, c.Name, "Chart.yaml"))
+}
+
+// resolveAll resolves the dependencies of app@1.0.0 in an empty workspace,
+// which the caller removes.
+func resolveAll(t *testing.T, charts map[string][]string, deps ...*chart.Dependency) ([]*Resolved, *fakeSource, error) {
+	dir, err := ioutil.TempDir("", "helmc-resolve")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := &fakeSource{workspace: dir, charts: charts}
+	res, err := ResolveAll(&chart.Chartfile{Name: "app", Version: "1.0.0", Dependencies: deps}, dir, src)
+	return res, src, err
+}
+
+func TestResolveAll(t *testing.T) {
+	res, src, err := resolveAll(t, map[string][]string{
+		"a/web@1.2.0":   {"db@^9", "cache@~1.0"},
+		"a/db@9.1.0":    nil,
+		"b/db@9.4.0":    nil,
+		"b/db@10.0.0":   nil,
+		"a/cache@1.0.3": {"db@>=9.2"},
+	}, &chart.Dependency{Name: "web", Version: "^1"}, &chart.Dependency{Name: "db", Version: "^9"})
+	defer os.RemoveAll(src.workspace)
+	if err != nil {
+		t.Fatalf("Could not resolve: %s", err)
+	}
+	got := []string{}
+	for _, r := range res {
+		got = append(got, fmt.Sprintf("%s@%s %s fetched=%t", r.Name, r.Version, strings.Join(r.RequiredBy, ","), r.Fetched))
+	}
+	expect := []string{
+		"db@9.4.0 web@1.2.0,cache@1.0.3,app@1.0.0 fetched=true",
+		"cache@1.0.3 web@1.2.0 fetched=true",
+		"web@1.2.0 app@1.0.0 fetched=true",
+	}
+	if strings.Join(got, "\n") != strings.Join(expect, "\n") {
+		t.Errorf("Expected each chart after its dependencies:\n%s\ngot:\n%s", strings.Join(expect, "\n"), strings.Join(got, "\n"))
+	}
+	if len(src.fetched) != 3 {
+		t.Errorf("Expected each chart to be fetched once, got %v", src.fetched)
+	}
+
+	// What the workspace has is not fetched again.
+	src.fetched = nil
+	if _, err := ResolveAll(&chart.Chartfile{Name: "app", Version: "1.0.0", Dependencies: []*chart.Dependency{{Name: "web", Version: "^1"}}}, src.workspace, src); err != nil || len(src.fetched) != 0 {
+		t.Errorf("Expected nothing to be fetched, got %v, %v", src.fetched, err)
+	}
+}
+
+func TestResolveAllRepo(t *testing.T) {
+	charts := map[string][]string{"a/db@9.4.0": nil, "b/db@9.1.0": nil}
+	for _, repo := range []string{"b", "https://example.com/b"} {
+		res, src, err := resolveAll(t, charts, &chart.Dependency{Name: "db", Version: "^9", Repo: repo})
+		os.RemoveAll(src.workspace)
+		if err != nil || len(res) != 1 || res[0].Version != "9.1.0" || res[0].Repo != "https://example.com/b" {
+			t.Errorf("Expected db of %s, got %v, %v", repo, res, err)
+		}
+	}
+}
+
+func TestResolveAllErrors(t *testing.T) {
+	_, src, err := resolveAll(t, map[string][]string{
+		"a/web@1.0.0": {"db@^9"},
+		"a/db@9.0.0":  {"web@^1"},
+	}, &chart.Dependency{Name: "web", Version: "^1"})
+	os.RemoveAll(src.workspace)
+	var ce *CycleError
+	if !errors.As(err, &ce) || strings.Join(ce.Path, " ") != "web@1.0.0 db@9.0.0 web@1.0.0" {
+		t.Errorf("Expected a cycle, got %v", err)
+	}
+
+	_, src, err = resolveAll(t, map[string][]string{"a/db@10.0.0": nil}, &chart.Dependency{Name: "db", Version: "^9"})
+	os.RemoveAll(src.workspace)
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) || conflict.From != "app@1.0.0" || !strings.Contains(err.Error(), "Found a/db@10.0.0") {
+		t.Errorf("Expected no chart to satisfy db, got %v", err)
+	}
+
+	// The db that web needs is not the one that app needs.
+	_, src, err = resolveAll(t, map[string][]string{
+		"a/web@1.0.0": {"db@^10"},
+		"a/db@9.0.0":  nil,
+		"b/db@10.0.0": nil,
+	}, &chart.Dependency{Name: "db", Version: "^9"}, &chart.Dependency{Name: "web", Version: "^1"})
+	os.RemoveAll(src.workspace)
+	if !errors.As(err, &conflict) || conflict.From != "web@1.0.0" || !strings.Contains(err.Error(), "db@9.0.0, for app@1.0.0") {
+		t.Errorf("Expected a conflict between the versions of db, got %v", err)
+	}
+}
+
+func TestLock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "helmc-lock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	lk := &Lock{Dependencies: []*Resolved{{Name: "db", Version: "9.4.0", Repo: "https://example.com/b", Chart: "db", RequiredBy: []string{"app@1.0.0"}}}}
+	if err := lk.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadLock(dir)
+	if err != nil || !reflect.DeepEqual(loaded, lk) {
+		t.Errorf("Expected %v, got %v, %v", lk, loaded, err)
+	}
+	if err := (&Lock{}).Save(dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, LockFile)); !os.IsNotExist(err) {
+		t.Errorf("Expected a lock without dependencies to remove the file, got %v", err)
+	}
+}
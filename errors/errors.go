@@ -0,0 +1,287 @@
+// Package errors defines the errors that Helm Classic operations return.
+//
+// Callers tell failures apart with errors.Is and errors.As from the standard
+// library. For example:
+//
+//	if errors.Is(err, helmerrors.ErrChartNotFound) {
+//		// Suggest 'helmc search'.
+//	}
+//	var ke *helmerrors.KubeError
+//	if errors.As(err, &ke) {
+//		// ke.Kind and ke.Name identify the resource.
+//	}
+//
+// Since this package has the name of the standard library's, it is imported
+// as helmerrors.
+package errors
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrChartNotFound matches a *ChartNotFoundError.
+	ErrChartNotFound = errors.New("chart not found")
+	// ErrAmbiguousChart matches an *AmbiguousChartError.
+	ErrAmbiguousChart = errors.New("chart name is ambiguous")
+	// ErrLintFailed matches a *LintError.
+	ErrLintFailed = errors.New("failed some necessary lint checks")
+	// ErrPreflightFailed matches a *PreflightError.
+	ErrPreflightFailed = errors.New("failed preflight checks")
+	// ErrGeneratorFailed matches a *GeneratorError.
+	ErrGeneratorFailed = errors.New("generator failed")
+	// ErrHookFailed matches a *HookError.
+	ErrHookFailed = errors.New("hook failed")
+	// ErrNotReady matches a *NotReadyError.
+	ErrNotReady = errors.New("resources not ready")
+	// ErrTestFailed matches a *TestError.
+	ErrTestFailed = errors.New("tests failed")
+)
+
+// ChartNotFoundError indicates that no repository has a chart.
+type ChartNotFoundError struct {
+	// Name is the chart name, as it was given.
+	Name string
+	// Repos are the repositories that were searched.
+	Repos []string
+}
+
+func (e *ChartNotFoundError) Error() string {
+	if len(e.Repos) == 0 {
+		return fmt.Sprintf("Chart %s not found", e.Name)
+	}
+	return fmt.Sprintf("Chart %s not found in %s", e.Name, strings.Join(e.Repos, ", "))
+}
+
+// Is makes errors.Is(err, ErrChartNotFound) true.
+func (e *ChartNotFoundError) Is(target error) bool {
+	return target == ErrChartNotFound
+}
+
+// AmbiguousChartError indicates that an unqualified chart name is in several
+// repositories of the same priority, or that a chart argument could be both a
+// chart directory and a chart name.
+type AmbiguousChartError struct {
+	// Name is the chart name, as it was given.
+	Name string
+	// Candidates are the fully qualified names that it could mean, or for a
+	// chart argument, what to give instead to mean each chart.
+	Candidates []string
+	// Meanings, if set, describe each candidate, such as "the chart
+	// directory ./redis".
+	Meanings []string
+}
+
+func (e *AmbiguousChartError) Error() string {
+	if len(e.Meanings) != len(e.Candidates) {
+		return fmt.Sprintf("Chart name %s is ambiguous. Use one of: %s", e.Name, strings.Join(e.Candidates, ", "))
+	}
+	width := 0
+	for _, c := range e.Candidates {
+		if len(c) > width {
+			width = len(c)
+		}
+	}
+	lines := []string{fmt.Sprintf("Chart %s could mean more than one chart. Use one of:", e.Name)}
+	for i, c := range e.Candidates {
+		lines = append(lines, fmt.Sprintf("\t%-*s  for %s", width, c, e.Meanings[i]))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Is makes errors.Is(err, ErrAmbiguousChart) true.
+func (e *AmbiguousChartError) Is(target error) bool {
+	return target == ErrAmbiguousChart
+}
+
+// LintError indicates that one or more charts failed some necessary lint checks.
+//
+// The checks themselves are reported as they are run.
+type LintError struct {
+	// Charts are the charts that failed.
+	Charts []string
+}
+
+func (e *LintError) Error() string {
+	if len(e.Charts) == 1 {
+		return fmt.Sprintf("Chart [%s] has failed some necessary checks. Check out the error and warning messages listed.", e.Charts[0])
+	}
+	return fmt.Sprintf("Charts %s have failed some necessary checks. Check out the error and warning messages listed.", strings.Join(e.Charts, ", "))
+}
+
+// Is makes errors.Is(err, ErrLintFailed) true.
+func (e *LintError) Is(target error) bool {
+	return target == ErrLintFailed
+}
+
+// PreflightError indicates that the preflight checks of an install found
+// errors, so that nothing was changed.
+//
+// The findings themselves are reported as they are found.
+type PreflightError struct {
+	Chart string
+	// Errors is the number of findings that are errors.
+	Errors int
+}
+
+func (e *PreflightError) Error() string {
+	return fmt.Sprintf("Chart [%s] has failed %d preflight checks, and nothing was changed. Fix them, or re-run with --skip-preflight.", e.Chart, e.Errors)
+}
+
+// Is makes errors.Is(err, ErrPreflightFailed) true.
+func (e *PreflightError) Is(target error) bool {
+	return target == ErrPreflightFailed
+}
+
+// GeneratorError indicates that a generator of a chart failed.
+type GeneratorError struct {
+	// Chart is the name of the chart in the workspace, if it is known.
+	Chart string
+	// File is the file that declares the generator.
+	File string
+	// Command is the generator's command, as it was run.
+	Command string
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *GeneratorError) Error() string {
+	return fmt.Sprintf("failed to execute %s (%s): %s", e.Command, e.File, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *GeneratorError) Unwrap() error {
+	return e.Err
+}
+
+// Is makes errors.Is(err, ErrGeneratorFailed) true.
+func (e *GeneratorError) Is(target error) bool {
+	return target == ErrGeneratorFailed
+}
+
+// RepoError is a failure to update or read a chart repository.
+type RepoError struct {
+	// Repo is the name of the repository.
+	Repo string
+	// Stderr holds the last lines that git wrote to stderr, if git failed.
+	Stderr []string
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *RepoError) Error() string {
+	msg := e.Err.Error()
+	// Most errors from the config package already name the repository.
+	if strings.Contains(msg, "'"+e.Repo+"'") {
+		return msg
+	}
+	return fmt.Sprintf("Repository '%s': %s", e.Repo, msg)
+}
+
+// Unwrap returns the underlying error.
+func (e *RepoError) Unwrap() error {
+	return e.Err
+}
+
+// KubeError is a request for a Kubernetes resource that kubectl rejected.
+type KubeError struct {
+	Kind, Name, Namespace string
+	// Output is what kubectl printed, if anything.
+	Output string
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *KubeError) Error() string {
+	msg := e.Output
+	if msg == "" {
+		msg = e.Err.Error()
+	}
+	return fmt.Sprintf("%s: %s", e.Resource(), msg)
+}
+
+// Unwrap returns the underlying error.
+func (e *KubeError) Unwrap() error {
+	return e.Err
+}
+
+// Resource describes the resource, e.g. "Pod redis in namespace default".
+func (e *KubeError) Resource() string {
+	r := e.Kind
+	if e.Name != "" {
+		r += " " + e.Name
+	}
+	if e.Namespace != "" {
+		r += " in namespace " + e.Namespace
+	}
+	return r
+}
+
+// HookError indicates that a hook of an install did not complete, so that
+// the install was stopped. The hook is left in the cluster, to be inspected.
+type HookError struct {
+	// Hook is the hook, such as "pre-install".
+	Hook                  string
+	Kind, Name, Namespace string
+	// Reason says how the hook failed.
+	Reason string
+	// Logs are the logs of the hook, if it has any.
+	Logs string
+}
+
+func (e *HookError) Error() string {
+	r := &KubeError{Kind: e.Kind, Name: e.Name, Namespace: e.Namespace}
+	msg := fmt.Sprintf("The %s hook %s failed: %s", e.Hook, r.Resource(), e.Reason)
+	if logs := strings.TrimRight(e.Logs, "\n"); logs != "" {
+		msg += "\nIts logs:\n" + logs
+	}
+	return msg
+}
+
+// Is makes errors.Is(err, ErrHookFailed) true.
+func (e *HookError) Is(target error) bool {
+	return target == ErrHookFailed
+}
+
+// NotReadyError indicates that the resources of a chart were not ready
+// within the time that was allowed. They were installed, and are left as
+// they are.
+type NotReadyError struct {
+	Chart   string
+	Timeout time.Duration
+	// Resources describe the resources that were not ready, such as
+	// "Deployment redis (1/3 available)".
+	Resources []string
+}
+
+func (e *NotReadyError) Error() string {
+	return fmt.Sprintf("%d resources of %s were not ready within %s: %s", len(e.Resources), e.Chart, e.Timeout, strings.Join(e.Resources, ", "))
+}
+
+// Is makes errors.Is(err, ErrNotReady) true.
+func (e *NotReadyError) Is(target error) bool {
+	return target == ErrNotReady
+}
+
+// TestError indicates that tests of a chart, as 'helmc test' runs them,
+// failed.
+type TestError struct {
+	Chart string
+	// Failed describe the tests that failed, such as "Pod redis-ping (the
+	// Pod failed)".
+	Failed []string
+	// Total is the number of tests that ran.
+	Total int
+}
+
+func (e *TestError) Error() string {
+	return fmt.Sprintf("%d of %d tests of %s failed: %s", len(e.Failed), e.Total, e.Chart, strings.Join(e.Failed, ", "))
+}
+
+// Is makes errors.Is(err, ErrTestFailed) true.
+func (e *TestError) Is(target error) bool {
+	return target == ErrTestFailed
+}
@@ -0,0 +1,124 @@
+package generator
+
+import (
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// TemplateJob is a generator whose command is 'helm template' or 'helm tpl',
+// which Walk renders in this process with Template, instead of executing
+// helmc once for each file.
+type TemplateJob struct {
+	// Template is the file of the template, and Values and Out are those of
+	// its --values and --out flags, or "". They are absolute.
+	Template, Values, Out string
+	// Force, SkipSchema, Set, SetFrom, and AllowExec are the other flags of
+	// the command. Force is also set by the force of Walk, as it is passed on
+	// to the commands that it executes.
+	Force, SkipSchema bool
+	Set, SetFrom      []string
+	AllowExec         bool
+	// Dir is the chart directory, in which the command would be executed.
+	Dir string
+	// Env is the environment that the command would be given, in addition
+	// to that of helmc.
+	Env map[string]string
+	// Stdout receives the template if Out is "".
+	Stdout io.Writer
+}
+
+// Template renders a TemplateJob. Package action, which implements
+// 'helmc template', sets it. While it is nil, every generator is executed.
+var Template func(j *TemplateJob) error
+
+// TemplateReads returns the files that the template of a TemplateJob reads
+// besides those that its command names, such as the partials of its chart, as
+// absolute paths. Package action sets it.
+var TemplateReads func(j *TemplateJob) []string
+
+// templateReads returns the files that the template of the expanded command
+// line, run in dir, reads besides those it names, or nil if it is not a
+// TemplateJob.
+func templateReads(dir, line string) []string {
+	if TemplateReads == nil {
+		return nil
+	}
+	j, ok := templateJob(line, dir, false)
+	if !ok {
+		return nil
+	}
+	return TemplateReads(j)
+}
+
+// templateJob returns the TemplateJob of an expanded command, and whether it
+// is one. Only 'helm' or 'helmc', 'template' or 'tpl', and the flags of
+// 'helmc template', followed by one template, are: anything else, such as an
+// unknown flag or a flag after the template, is left to helmc to execute.
+func templateJob(command, dir string, force bool) (*TemplateJob, bool) {
+	args := fields(command)
+	if len(args) < 3 || args[0] != "helm" && args[0] != "helmc" || args[1] != "template" && args[1] != "tpl" {
+		return nil, false
+	}
+	j := &TemplateJob{Force: force, Dir: dir}
+	abs := func(p string) string {
+		if p == "" || filepath.IsAbs(p) {
+			return p
+		}
+		return filepath.Join(dir, p)
+	}
+	args = args[2:]
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			if i != len(args)-1 {
+				return nil, false
+			}
+			j.Template = abs(arg)
+			return j, true
+		}
+		name, value, hasValue := strings.TrimLeft(arg, "-"), "", false
+		if eq := strings.Index(name, "="); eq >= 0 {
+			name, value, hasValue = name[:eq], name[eq+1:], true
+		}
+		switch name {
+		case "out", "o", "values", "d", "set", "set-from":
+			if !hasValue {
+				if i++; i == len(args) {
+					return nil, false
+				}
+				value = args[i]
+			}
+			switch name {
+			case "out", "o":
+				j.Out = abs(value)
+			case "values", "d":
+				j.Values = abs(value)
+			case "set":
+				j.Set = append(j.Set, value)
+			default:
+				j.SetFrom = append(j.SetFrom, value)
+			}
+		case "force", "f", "skip-schema", "allow-exec-values":
+			b := true
+			if hasValue {
+				var err error
+				if b, err = strconv.ParseBool(value); err != nil {
+					return nil, false
+				}
+			}
+			switch name {
+			case "force", "f":
+				j.Force = j.Force || b
+			case "skip-schema":
+				j.SkipSchema = b
+			default:
+				j.AllowExec = b
+			}
+		default:
+			return nil, false
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,91 @@
+package generator
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/helm/helm-classic/log"
+)
+
+func TestTemplateJob(t *testing.T) {
+	dir := "/charts/mychart"
+	for command, expected := range map[string]*TemplateJob{
+		"helm tpl tpl/pod.yaml": {Template: "/charts/mychart/tpl/pod.yaml"},
+		"helmc template -o manifests/pod.yaml -d /values.toml /charts/mychart/tpl/pod.yaml": {
+			Template: "/charts/mychart/tpl/pod.yaml",
+			Values:   "/values.toml",
+			Out:      "/charts/mychart/manifests/pod.yaml",
+		},
+		"helm tpl --out=pod.yaml --values tpl/values.yaml -f --skip-schema pod.tpl": {
+			Template:   "/charts/mychart/pod.tpl",
+			Values:     "/charts/mychart/tpl/values.yaml",
+			Out:        "/charts/mychart/pod.yaml",
+			Force:      true,
+			SkipSchema: true,
+		},
+		"helm tpl --set image.tag=1.2 --set-from a=env:A --set-from=b=file:b.txt --allow-exec-values=true pod.tpl": {
+			Template:  "/charts/mychart/pod.tpl",
+			Set:       []string{"image.tag=1.2"},
+			SetFrom:   []string{"a=env:A", "b=file:b.txt"},
+			AllowExec: true,
+		},
+		// Left to helmc.
+		"helm tpl":                             nil,
+		"helm tpl -o":                          nil,
+		"helm tpl --verbose pod.tpl":           nil,
+		"helm tpl pod.tpl -o pod.yaml":         nil,
+		"helm tpl --skip-schema=maybe pod.tpl": nil,
+		"helm lint pod.tpl":                    nil,
+		"sed -i -e s|a|b| pod.yaml":            nil,
+		"/usr/bin/helm tpl pod.tpl":            nil,
+	} {
+		j, ok := templateJob(command, dir, false)
+		if expected == nil {
+			if ok {
+				t.Errorf("Expected %q to be executed, got %+v", command, j)
+			}
+			continue
+		}
+		expected.Dir = dir
+		if !ok || !reflect.DeepEqual(j, expected) {
+			t.Errorf("Expected %q to be %+v, got %+v", command, expected, j)
+		}
+	}
+
+	// The force of Walk is passed on, as it is to the commands it executes.
+	if j, ok := templateJob("helm tpl pod.tpl", dir, true); !ok || !j.Force {
+		t.Errorf("Expected a forced template, got %+v", j)
+	}
+}
+
+func TestWalkTemplate(t *testing.T) {
+	dir := orderChart(t, map[string]string{
+		"pod.yaml": "#helm:generate helm tpl -o out.yaml $HELM_GENERATE_FILE\n",
+		"sed.yaml": "#helm:generate echo executed\n",
+	})
+	defer os.RemoveAll(dir)
+	var jobs []*TemplateJob
+	Template = func(j *TemplateJob) error {
+		jobs = append(jobs, j)
+		return nil
+	}
+	defer func() { Template = nil }()
+
+	var stdout bytes.Buffer
+	if _, err := Walk(context.Background(), dir, Options{Verbose: true, Env: map[string]string{"HELM_SKIP_SCHEMA": "true"}, Log: &log.Logger{Stdout: &stdout}, Policy: allowAll}); err != nil {
+		t.Fatalf("Failed to walk: %s", err)
+	}
+	if len(jobs) != 1 || jobs[0].Template != filepath.Join(dir, "pod.yaml") || jobs[0].Out != filepath.Join(dir, "out.yaml") {
+		t.Fatalf("Expected pod.yaml to be rendered in-process, got %+v", jobs)
+	}
+	if jobs[0].Env["HELM_SKIP_SCHEMA"] != "true" || jobs[0].Env["HELM_GENERATE_FILE"] == "" {
+		t.Errorf("Expected the environment of the generator, got %v", jobs[0].Env)
+	}
+	if stdout.String() != "executed\n" {
+		t.Errorf("Expected the other generator to be executed, got %q", stdout.String())
+	}
+}
@@ -0,0 +1,170 @@
+package generator
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Invocation is how Walk would run the generator of one file.
+type Invocation struct {
+	// File is the file that declares the generator.
+	File string
+	// Directive is the command of the directive, before expansion.
+	Directive string
+	// Raw is set for a 'helm:generate:raw' directive.
+	Raw bool
+	// Command is the program that is run, and Args its arguments, after
+	// expansion.
+	Command string
+	Args    []string
+	// Dir is the working directory, which is the chart's.
+	Dir string
+	// Env is the environment that Helm Classic adds to that of helmc.
+	Env map[string]string
+	// Undefined are the variables of the directive that are not defined,
+	// and so expand to the empty string.
+	Undefined []string
+}
+
+// Explain returns how Walk, given the same arguments, would run the
+// generator of file, without running it: an Invocation for each of its
+// commands, in order.
+//
+// file is relative to the chart directory dir, or absolute. It is an error
+// if the file declares no generator, or if Walk would skip it, in which case
+// the error names the rule that excludes it.
+func Explain(dir, file string, exclude []string, force, strict bool, env map[string]string) ([]*Invocation, error) {
+	path := file
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, file)
+	}
+	rel, err := filepath.Rel(dir, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil, fmt.Errorf("%s is not in the chart %s", file, dir)
+	}
+
+	// Walk skips the file if any directory on the way is skipped.
+	excludes := excludeMap(dir, exclude)
+	p := dir
+	for _, part := range append([]string{""}, strings.Split(filepath.Dir(rel), string(filepath.Separator))...) {
+		if part == "." {
+			continue
+		}
+		p = filepath.Join(p, part)
+		if why := exclusion(p, true, excludes); why != "" {
+			return nil, fmt.Errorf("%s is not generated, because it is excluded by %s", rel, why)
+		}
+	}
+	if why := exclusion(path, false, excludes); why != "" {
+		return nil, fmt.Errorf("%s is not generated, because it is excluded by %s", rel, why)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	headers, err := readGenerators(f)
+	if err != nil {
+		return nil, err
+	}
+	if len(headers) == 0 {
+		return nil, fmt.Errorf("%s has no generator. A generator is declared on the first line, as '#helm:generate CMD [ARGS]', after at most a shebang, empty lines and '---' separators", rel)
+	}
+
+	var res []*Invocation
+	for _, h := range headers {
+		line := h.line
+		vars := generateVars(env, dir, path, line)
+		undefined := []string{}
+		if !h.raw {
+			for _, u := range undefinedVars(line, path, vars) {
+				undefined = append(undefined, u.Name)
+			}
+			if line, err = expand(line, path, vars, strict); err != nil {
+				return nil, err
+			}
+		}
+		vars["HELM_GENERATE_COMMAND_EXPANDED"] = line
+
+		inv, err := newInvocation(dir, path, vars, force)
+		if err != nil {
+			return nil, err
+		}
+		inv.Directive, inv.Raw, inv.Undefined = h.line, h.raw, undefined
+		res = append(res, inv)
+	}
+	return res, nil
+}
+
+// newInvocation describes the generator of file, whose variables, including
+// the expanded command, are vars.
+func newInvocation(dir, file string, vars map[string]string, force bool) (*Invocation, error) {
+	name, args, err := commandArgs(vars["HELM_GENERATE_COMMAND_EXPANDED"], force)
+	if err != nil {
+		return nil, err
+	}
+	return &Invocation{
+		File:      file,
+		Directive: vars["HELM_GENERATE_COMMAND"],
+		Command:   name,
+		Args:      args,
+		Dir:       dir,
+		Env:       vars,
+	}, nil
+}
+
+// Script returns a shell script that runs the generator as Walk would: it
+// changes to the working directory, exports the environment, and runs the
+// command. Comments describe the directive.
+func (inv *Invocation) Script() string {
+	var b strings.Builder
+	directive := "helm:generate"
+	if inv.Raw {
+		directive = "helm:generate:raw"
+	}
+	fmt.Fprintf(&b, "# File:      %s\n", inv.File)
+	fmt.Fprintf(&b, "# Directive: %s %s\n", directive, inv.Directive)
+	fmt.Fprintf(&b, "# Command:   %s\n", inv.Env["HELM_GENERATE_COMMAND_EXPANDED"])
+	fmt.Fprintf(&b, "# Directory: %s\n", inv.Dir)
+	if len(inv.Undefined) > 0 {
+		fmt.Fprintf(&b, "# Undefined, so empty: $%s\n", strings.Join(inv.Undefined, ", $"))
+	}
+
+	fmt.Fprintf(&b, "cd %s\n", singleQuote(inv.Dir))
+	names := make([]string, 0, len(inv.Env))
+	for k := range inv.Env {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		fmt.Fprintf(&b, "export %s=%s\n", k, singleQuote(inv.Env[k]))
+	}
+
+	words := []string{ShellQuote(inv.Command)}
+	for _, a := range inv.Args {
+		words = append(words, ShellQuote(a))
+	}
+	b.WriteString(strings.Join(words, " ") + "\n")
+	return b.String()
+}
+
+// shellSafe matches words that the shell leaves as they are.
+var shellSafe = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// ShellQuote quotes a word for the shell, if it needs it.
+func ShellQuote(s string) string {
+	if shellSafe.MatchString(s) {
+		return s
+	}
+	return singleQuote(s)
+}
+
+// singleQuote quotes a word for the shell in single quotes.
+func singleQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
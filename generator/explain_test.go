@@ -0,0 +1,96 @@
+package generator
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/helm/helm-classic/log"
+)
+
+func TestExplain(t *testing.T) {
+	dir, err := ioutil.TempDir("", "helmc-explain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.MkdirAll(filepath.Join(dir, "tpl"), 0755)
+	os.MkdirAll(filepath.Join(dir, "_old"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "tpl/pod.yaml"), []byte("#helm:generate helm tpl -o manifests/pod.yaml $HELM_GENERATE_FILE s|a|b| $NOT_SET\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "_old/pod.yaml"), []byte("#helm:generate echo old\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "plain.yaml"), []byte("kind: Pod\n"), 0644)
+	env := map[string]string{"HELM_HOME": "/it's/home"}
+
+	invs, err := Explain(dir, "tpl/pod.yaml", nil, true, false, env)
+	if err != nil || len(invs) != 1 {
+		t.Fatalf("Unexpected invocations %v, %v", invs, err)
+	}
+	inv := invs[0]
+	file := filepath.Join(dir, "tpl/pod.yaml")
+	if inv.Command != "helmc" || strings.Join(inv.Args, " ") != "tpl -f -o manifests/pod.yaml "+file+" s|a|b|" {
+		t.Errorf("Unexpected command %s %v", inv.Command, inv.Args)
+	}
+	if inv.Dir != dir || inv.Env["HELM_GENERATE_FILE"] != file || len(inv.Undefined) != 1 || inv.Undefined[0] != "NOT_SET" {
+		t.Errorf("Unexpected invocation %+v", inv)
+	}
+
+	script := inv.Script()
+	for _, s := range []string{
+		"# Directive: helm:generate helm tpl -o manifests/pod.yaml $HELM_GENERATE_FILE s|a|b| $NOT_SET\n",
+		"# Undefined, so empty: $NOT_SET\n",
+		"cd '" + dir + "'\n",
+		`export HELM_HOME='/it'\''s/home'` + "\n",
+		"export HELM_GENERATE_DIR='" + dir + "'\n",
+		"\nhelmc tpl -f -o manifests/pod.yaml " + file + " 's|a|b|'\n",
+	} {
+		if !strings.Contains(script, s) {
+			t.Errorf("Expected %q in:\n%s", s, script)
+		}
+	}
+
+	// The script runs in a shell as it reads.
+	if _, err := exec.LookPath("sh"); err == nil {
+		out, err := exec.Command("sh", "-c", strings.Replace(script, "helmc tpl", "echo $HELM_HOME", 1)).CombinedOutput()
+		if err != nil || !strings.HasPrefix(string(out), "/it's/home -f -o") {
+			t.Errorf("Expected the script to run, got %q, %v", out, err)
+		}
+	}
+
+	for file, msg := range map[string]string{
+		"plain.yaml":     "plain.yaml has no generator",
+		"_old/pod.yaml":  `excluded by the directory _old, whose name starts with "_"`,
+		"tpl/pod.yaml":   "excluded by --exclude tpl",
+		"../outside.txt": "is not in the chart",
+	} {
+		_, err := Explain(dir, file, []string{"tpl"}, false, false, env)
+		if err == nil || !strings.Contains(err.Error(), msg) {
+			t.Errorf("Expected %q for %s, got %v", msg, file, err)
+		}
+	}
+	if _, err := Explain(dir, "tpl/pod.yaml", nil, false, true, env); err == nil || !strings.Contains(err.Error(), "$NOT_SET") {
+		t.Errorf("Expected a strict explanation to fail, got %v", err)
+	}
+}
+
+func TestWalkExplainsFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "helmc-explain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "fail.yaml"), []byte("#helm:generate false $HELM_GENERATE_FILE\n"), 0644)
+
+	var b bytes.Buffer
+	l := &log.Logger{Stdout: &b, Stderr: &b, Debugging: true}
+	if _, err := Walk(context.Background(), dir, Options{Verbose: true, Log: l, Policy: allowAll}); err == nil {
+		t.Fatal("Expected the generator to fail")
+	}
+	if !strings.Contains(b.String(), "To run the generator by hand:") || !strings.Contains(b.String(), "export HELM_GENERATE_FILE=") {
+		t.Errorf("Expected the invocation in the debug output, got %q", b.String())
+	}
+}
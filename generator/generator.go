@@ -2,53 +2,374 @@ package generator
 
 import (
 	"bufio"
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 
+	helmerrors "github.com/helm/helm-classic/errors"
 	"github.com/helm/helm-classic/log"
+	"github.com/helm/helm-classic/metrics"
+	helm "github.com/helm/helm-classic/util"
+	"golang.org/x/crypto/ssh/terminal"
 )
 
 // GeneratorKeyword is used to generate new charts
 const GeneratorKeyword = "helm:generate "
 
+// RawGeneratorKeyword declares a generator whose command is run as it is,
+// without expanding variables.
+const RawGeneratorKeyword = "helm:generate:raw "
+
+// Options are the options of Walk.
+type Options struct {
+	// Exclude are files and directories, relative to the chart, that are
+	// not walked.
+	Exclude []string
+	// Force makes the generators overwrite the files that they write, and
+	// run even if they are up to date, when Incremental is set.
+	Force bool
+	// DryRun finds and logs the generators, but does not execute them.
+	DryRun bool
+	// Strict makes a variable that is neither in the environment nor one of
+	// GenerateVars an *UndefinedVariableError, instead of expanding to the
+	// empty string.
+	Strict bool
+	// Verbose writes the output of the generators to Log as it is.
+	Verbose bool
+	// Incremental only runs the generators whose inputs or outputs changed
+	// since they last ran.
+	Incremental bool
+	// Jobs is how many generators run at a time. With 1 or less, they run one
+	// after the other.
+	Jobs int
+	// Timeout, if it is positive, is how long each command may run.
+	Timeout time.Duration
+	// Policy is the commands that may run. A nil Policy allows only
+	// AllowTemplate.
+	Policy *Policy
+	// Env is the environment of the generators, usually from helm.HelmEnv.
+	Env map[string]string
+	// Log gets the messages, and the generators' output. If it is nil, they
+	// are printed with the package-level log functions.
+	Log *log.Logger
+	// Hooks, if it is not nil, is told about each generator that is executed.
+	Hooks *Hooks
+}
+
 // Walk walks a chart directory and executes generators as it finds them.
 //
-// Returns the number of generators executed.
+// Returns the number of generators executed, by file. A file may declare
+// several commands, on consecutive helm:generate lines, which each count,
+// and run in order, each with the $HELM_GENERATE_COMMAND of its own. One that
+// fails stops those after it.
 //
 // Walking will error out whenever a generator cannot be completely executed.
 // This includes cases such as not finding the generator referenced, and
 // cases where the generator itself exits with a non-zero exit code.
-func Walk(dir string, exclude []string, force bool) (int, error) {
+//
+// Each generator's environment is o.Env plus the $HELM_GENERATE_* variables
+// for its file. Variables in the command are expanded from the same
+// environment, unless the directive is 'helm:generate:raw'. '$$' expands to
+// '$'. With o.Strict, an undefined variable is an error.
+//
+// A generator whose command is 'helm template' or 'helm tpl' is rendered in
+// this process by Template, if it is set, rather than by executing helmc for
+// each file. See TemplateJob.
+//
+// With o.Incremental, a generator only runs if its Inputs, or the files that
+// it declares as its outputs, changed since it last ran, as the LockFile of
+// the chart records them, unless o.Force is set. Each generator that runs is
+// recorded there, and one that fails runs the next time.
+//
+// The files that the generators declare as their output, with one of
+// OutputFlags, are recorded in the StateFile of the chart, with their
+// digests, once the generators that write them succeed.
+//
+// Each line that a generator prints, on stdout or stderr, is logged with
+// o.Log.Info, with the file of the generator as its prefix, so that the
+// output of many generators can be told apart; a line with a warning is
+// logged with o.Log.Warn. With o.Verbose, the output is written to o.Log as
+// it is, instead.
+//
+// Only the commands that o.Policy allows may run. If a generator runs
+// another, the walk stops with a *PolicyError before anything runs.
+//
+// The generators are found first, and then ordered: a generator runs after
+// the generators of the files that its AfterKeyword comments name, and
+// otherwise in the order of the files. Naming a file without a generator is
+// an error, and so is a cycle, a *CycleError. Nothing runs then.
+//
+// The generators then run, o.Jobs of them at a time. With 1 or less, they run
+// one after the other, and the first that fails stops the walk. With more,
+// they run in a pool of that many workers, each once those it runs after are
+// done, and the others run even if one fails, except those that run after
+// it. The messages and output of each are held until it is done, and then
+// printed in order, so that they do not mix. The error is that of the first
+// generator in that order that failed, and the failures of the others are
+// logged.
+//
+// The commands that are executed are killed, with every process that they
+// started, when ctx is done, and each after o.Timeout. No generator starts
+// once ctx is done. A generator that is killed fails with an error that
+// wraps that of its context. Generators do not read the terminal: the stdin
+// of a command is that of helmc only if it is not one.
+func Walk(ctx context.Context, dir string, o Options) (Counts, error) {
+	return run(ctx, dir, o, nil)
+}
+
+// Counts are the numbers of generators that Walk ran, by the file that
+// declares them, relative to the chart, with slashes.
+type Counts map[string]int
+
+// Total returns the number of generators of every file.
+func (c Counts) Total() int {
+	total := 0
+	for _, n := range c {
+		total += n
+	}
+	return total
+}
+
+// Hooks are told about each generator that Walk executes. Either may be nil.
+// When generators run in parallel, the calls are not concurrent.
+type Hooks struct {
+	// Started is called before a generator is executed, with the file that
+	// declares it and its expanded command.
+	Started func(file, command string)
+	// Finished is called after the generator exited, with how long it ran,
+	// and the error if it failed.
+	Finished func(file, command string, d time.Duration, err error)
+}
+
+// job is a generator that Walk found.
+type job struct {
+	// path is the file that declares the generator, and line its expanded
+	// command.
+	path, line string
+	// directive is its command before expansion, and raw is set for a
+	// 'helm:generate:raw' directive.
+	directive string
+	raw       bool
+	// index is the position of the command among those of its file, from 0.
+	index int
+	// vars is its environment.
+	vars map[string]string
+	// after are the jobs that it runs after, by index.
+	after []int
+}
+
+// run is Walk, for only the generators for which match returns true. match
+// is given the file and the expanded command. If it is nil, every generator
+// runs.
+func run(ctx context.Context, dir string, o Options, match func(path, line string) bool) (Counts, error) {
+	l := o.Log
+	counts := Counts{}
+	todo, err := find(dir, o.Exclude, o.Strict, o.Env, l, match)
+	if err != nil {
+		return counts, err
+	}
+	if err := o.Policy.check(dir, todo); err != nil {
+		return counts, err
+	}
+	if o.DryRun {
+		for _, j := range todo {
+			l.Info("Would run %s (%s)", j.line, j.path)
+			counts[relSlash(dir, j.path)]++
+		}
+		return counts, nil
+	}
+
+	state, err := LoadState(dir)
+	if err != nil {
+		l.Warn("Could not read %s, and starting it over: %s", StateFile, err)
+		state = State{}
+	}
+	var inc *inputLock
+	if o.Incremental {
+		lock, err := LoadLock(dir)
+		if err != nil {
+			l.Warn("Could not read %s, and running every generator: %s", LockFile, err)
+			lock = Lock{}
+		}
+		inc = &inputLock{lock: lock, state: state}
+	}
+	errs := runJobs(ctx, dir, todo, o.Force, o.Verbose, o.Jobs, o.Timeout, inc, l, o.Hooks)
+	count, skipped, recorded := 0, 0, false
+	var first error
+	for i, j := range todo {
+		if errs[i] == errUpToDate {
+			skipped++
+		}
+		if errs[i] == errNotRun || errs[i] == errUpToDate {
+			continue
+		}
+		count++
+		counts[relSlash(dir, j.path)]++
+		switch {
+		case errs[i] != nil && first == nil:
+			first = errs[i]
+		case errs[i] != nil:
+			l.Err("%s", errs[i])
+		case len(Outputs(dir, j.line)) > 0:
+			state.record(dir, j.path, j.line)
+			recorded = true
+		}
+	}
+	if recorded {
+		if serr := state.Save(dir); serr != nil {
+			l.Warn("Could not write %s: %s", StateFile, serr)
+		}
+	}
+	if inc != nil {
+		if match == nil {
+			// Forget the generators that are gone.
+			found := map[string]bool{}
+			for _, j := range todo {
+				found[lockKey(dir, j)] = true
+			}
+			for src := range inc.lock {
+				if !found[src] {
+					delete(inc.lock, src)
+				}
+			}
+		}
+		if serr := inc.lock.Save(dir); serr != nil {
+			l.Warn("Could not write %s: %s", LockFile, serr)
+		}
+		if skipped > 0 {
+			l.Info("Skipped %d generators whose inputs did not change.", skipped)
+		}
+	}
+	if first == nil && count+skipped < len(todo) {
+		// Only ctx stops a walk without an error of a generator.
+		first = ctx.Err()
+	}
+	return counts, first
+}
+
+// find returns the jobs of the generators that run would run, in the order
+// that they would run in.
+func find(dir string, exclude []string, strict bool, env map[string]string, l *log.Logger, match func(path, line string) bool) ([]*job, error) {
+	var todo []*job
+	last, index := "", 0
+	err := walk(dir, exclude, func(path, directive string, raw bool) error {
+		if path == last {
+			index++
+		} else {
+			last, index = path, 0
+		}
+		line := directive
+		vars := generateVars(env, dir, path, line)
+		if !raw {
+			expanded, err := expand(line, path, vars, strict)
+			if err != nil {
+				if match != nil && !match(path, line) {
+					return nil
+				}
+				return err
+			}
+			line = expanded
+		}
+		if match != nil && !match(path, line) {
+			return nil
+		}
+		vars["HELM_GENERATE_COMMAND_EXPANDED"] = line
+		l.Debug("File: %s, Command: %s", path, line)
+		todo = append(todo, &job{path: path, line: line, directive: directive, raw: raw, index: index, vars: vars})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return order(dir, todo)
+}
 
-	excludes := make(map[string]bool, len(exclude))
-	for i := 0; i < len(exclude); i++ {
-		excludes[filepath.Join(dir, exclude[i])] = true
+// runOne executes the generator of a job, with its messages and output on
+// l, and returns a *helmerrors.GeneratorError if it fails. See Walk for ctx
+// and timeout. If inc is not nil, a job whose inputs did not change is not
+// run, and the result is errUpToDate.
+func runOne(ctx context.Context, dir string, j *job, force, verbose bool, timeout time.Duration, inc *inputLock, l *log.Logger, h *Hooks) error {
+	if inc != nil && !force && inc.upToDate(dir, j) {
+		l.Debug("Not running the generator of %s, whose inputs did not change", relSlash(dir, j.path))
+		return errUpToDate
 	}
+	// Execute the command in the chart's directory to make relative
+	// paths usable.
+	if h != nil && h.Started != nil {
+		h.Started(j.path, j.line)
+	}
+	start := time.Now()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	err := execute(ctx, j.line, j.path, dir, force, verbose, j.vars, l)
+	if inc != nil {
+		inc.record(dir, j, err)
+	}
+	d := time.Since(start)
+	metrics.Observe(metrics.Generator, relSlash(dir, j.path), d, err)
+	if h != nil && h.Finished != nil {
+		h.Finished(j.path, j.line, d, err)
+	}
+	if err != nil {
+		if inv, ierr := newInvocation(dir, j.path, j.vars, force); ierr == nil {
+			l.Debug("To run the generator by hand:\n%s", inv.Script())
+		}
+		return &helmerrors.GeneratorError{File: j.path, Command: j.line, Err: err}
+	}
+	return nil
+}
+
+// Check finds the generators of a chart directory, as Walk does, and
+// returns an *UndefinedVariableError for each variable in their commands
+// that would not be defined. Nothing is executed.
+//
+// env is the environment that Walk would be given; placeholder values will
+// do, since only the names are checked.
+func Check(dir string, exclude []string, env map[string]string) ([]*UndefinedVariableError, error) {
+	undefined := []*UndefinedVariableError{}
+	err := walk(dir, exclude, func(path, line string, raw bool) error {
+		if !raw {
+			undefined = append(undefined, undefinedVars(line, path, generateVars(env, dir, path, line))...)
+		}
+		return nil
+	})
+	return undefined, err
+}
+
+// walk calls fn with each command of each generator in dir, in the order of
+// its file, and whether it is a raw directive. Excluded files and directories, and directories whose
+// names start with '.' or '_', are skipped.
+func walk(dir string, exclude []string, fn func(path, line string, raw bool) error) error {
+	excludes := excludeMap(dir, exclude)
 
-	count := 0
-	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+	return filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
 
 		// dive-bomb if we hit an error.
 		if err != nil {
 			return err
 		}
 
-		// Exclude anything explicitly excluded.
-		if excludes[path] == true {
+		// Skip anything explicitly excluded, and the contents of directories
+		// whose prefix is . or _.
+		if exclusion(path, fi.IsDir(), excludes) != "" {
 			if fi.IsDir() {
 				return filepath.SkipDir
 			}
 			return nil
 		}
 
-		// Skip directory entries. If the directory prefix is . or _, skip the
-		// contents of the directory as well.
+		// Skip directory entries.
 		if fi.IsDir() {
-			return skip(path)
+			return nil
 		}
 
 		f, err := os.Open(path)
@@ -57,54 +378,160 @@ func Walk(dir string, exclude []string, force bool) (int, error) {
 		}
 		defer f.Close()
 
-		line, err := readGenerator(f)
+		headers, err := readGenerators(f)
 		if err != nil {
 			return err
 		}
-		if line == "" {
-			return nil
+		for _, h := range headers {
+			if err := fn(path, h.line, h.raw); err != nil {
+				return err
+			}
 		}
-		// Run the generator.
-		os.Setenv("HELM_GENERATE_COMMAND", line)
-		os.Setenv("HELM_GENERATE_FILE", path)
-		os.Setenv("HELM_GENERATE_DIR", dir)
-		line = os.ExpandEnv(line)
-		os.Setenv("HELM_GENERATE_COMMAND_EXPANDED", line)
-		log.Debug("File: %s, Command: %s", path, line)
-		count++
+		return nil
+	})
+}
 
-		// Execute the command in the file's directory to make relative
-		// paths usable.
-		origin, err := os.Getwd()
-		if err != nil {
-			log.Warn("Could not get PWD: %s", err)
-		} else if err := os.Chdir(dir); err != nil {
-			log.Warn("Could not change directory to %s: %s", dir, err)
-		} else {
-			origin = dir
-			defer func() {
-				if e := os.Chdir(origin); e != nil {
-					log.Warn("Could not return to %s: %s", origin, e)
-				}
-			}()
+// GenerateVars are the variables that Walk sets for each generator, in
+// addition to its environment.
+var GenerateVars = []string{
+	"HELM_GENERATE_COMMAND",
+	"HELM_GENERATE_COMMAND_EXPANDED",
+	"HELM_GENERATE_FILE",
+	"HELM_GENERATE_DIR",
+}
+
+// generateVars returns the variables of the generator in file: env, and
+// the $HELM_GENERATE_* variables. $HELM_GENERATE_COMMAND_EXPANDED is only
+// known after expansion, so it is empty.
+func generateVars(env map[string]string, dir, file, line string) map[string]string {
+	vars := map[string]string{}
+	for k, v := range env {
+		vars[k] = v
+	}
+	vars["HELM_GENERATE_COMMAND"] = line
+	vars["HELM_GENERATE_COMMAND_EXPANDED"] = ""
+	vars["HELM_GENERATE_FILE"] = file
+	vars["HELM_GENERATE_DIR"] = dir
+	return vars
+}
+
+// UndefinedVariableError indicates that a generator's command uses a
+// variable that is not defined.
+type UndefinedVariableError struct {
+	// Name is the variable, without '$'.
+	Name string
+	// File is the file of the directive.
+	File string
+	// Directive is the command, before expansion.
+	Directive string
+}
+
+func (e *UndefinedVariableError) Error() string {
+	return fmt.Sprintf("undefined variable $%s in generator %q (%s). Use $$ for a literal $, or helm:generate:raw to turn off expansion.", e.Name, e.Directive, e.File)
+}
+
+// expand replaces $var and ${var} in the command of the generator in file,
+// using vars before the environment of this process, and '$$' by '$'.
+//
+// If strict is true, the first undefined variable is returned as an
+// *UndefinedVariableError. Otherwise, undefined variables are empty.
+func expand(line, file string, vars map[string]string, strict bool) (string, error) {
+	var undefined []string
+	res := os.Expand(line, func(k string) string {
+		v, ok := lookup(k, vars)
+		if !ok {
+			undefined = append(undefined, k)
 		}
-		err = execute(line, force)
-		if err != nil {
-			return fmt.Errorf("failed to execute %s (%s): %s", line, path, err)
+		return v
+	})
+	if strict && len(undefined) > 0 {
+		return "", &UndefinedVariableError{Name: undefined[0], File: file, Directive: line}
+	}
+	return res, nil
+}
+
+// undefinedVars returns an error for each undefined variable in the command
+// of the generator in file.
+func undefinedVars(line, file string, vars map[string]string) []*UndefinedVariableError {
+	res := []*UndefinedVariableError{}
+	os.Expand(line, func(k string) string {
+		if _, ok := lookup(k, vars); !ok {
+			res = append(res, &UndefinedVariableError{Name: k, File: file, Directive: line})
 		}
-		return nil
+		return ""
 	})
+	return res
+}
+
+// lookup returns the value of a variable in a command, and whether it is defined.
+func lookup(k string, vars map[string]string) (string, bool) {
+	if k == "$" {
+		return "$", true
+	}
+	if v, ok := vars[k]; ok {
+		return v, true
+	}
+	return os.LookupEnv(k)
+}
+
+// execute runs a generator in dir with vars as its environment. Each line of
+// its output is logged with the file that declared it, unless verbose is
+// true, in which case the output is written to l as it is. A 'helm template'
+// that Template can render is rendered in this process instead; see
+// templateJob.
+func execute(ctx context.Context, command, file, dir string, force, verbose bool, vars map[string]string, l *log.Logger) error {
+	// Both stdout and stderr go to one writer, so that their lines are
+	// logged in the order that they were printed.
+	lines := &helm.Stderr{Prefix: "generate " + relSlash(dir, file), Verbose: true, Log: l}
+	var out io.Writer = lines
+	if verbose {
+		out, lines.Raw = l.Out(), l.ErrOut()
+	}
+
+	if Template != nil {
+		if j, ok := templateJob(command, dir, force); ok {
+			j.Env, j.Stdout = vars, out
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			l.Debug("Rendering %s in helmc, instead of executing it", j.Template)
+			err := Template(j)
+			lines.Flush()
+			return err
+		}
+	}
+
+	name, args, err := commandArgs(command, force)
+	if err != nil {
+		return err
+	}
 
-	return count, err
+	cmd := exec.Command(name, args...)
+	cmd.Dir = dir
+	cmd.Stdout = out
+	// A command that reads the terminal would stay in the foreground,
+	// outside of a process group of its own, and could not be killed with
+	// the processes that it started.
+	if !terminal.IsTerminal(int(os.Stdin.Fd())) {
+		cmd.Stdin = os.Stdin
+	}
+	cmd.Env = helm.Environ(vars)
+	_, err = lines.ExecContext(ctx, cmd)
+	return err
 }
 
-func execute(command string, force bool) error {
-	args := strings.Fields(command)
+// commandArgs splits an expanded command into the program that is run, and
+// its arguments, with splitArgs.
+func commandArgs(command string, force bool) (string, []string, error) {
+	args, err := splitArgs(command)
+	if err != nil {
+		return "", nil, err
+	}
 	if len(args) == 0 {
-		return errors.New("empty command")
+		return "", nil, errors.New("empty command")
 	}
 	name := args[0]
-	if args[0] == "helm" && (args[1] == "template" || args[1] == "tpl") && force {
+	if args[0] == "helm" && len(args) > 1 && (args[1] == "template" || args[1] == "tpl") && force {
 		args = append([]string{args[1], "-f"}, args[2:]...)
 	} else {
 		args = args[1:]
@@ -119,16 +546,30 @@ func execute(command string, force bool) error {
 	if name == "helm" {
 		name = "helmc"
 	}
+	name, args = platformCommand(name, args)
+	return name, args, nil
+}
 
-	cmd := exec.Command(name, args...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	cmd.Stdin = os.Stdin
+// excludeMap maps the paths of the --exclude entries of a chart to the entries.
+func excludeMap(dir string, exclude []string) map[string]string {
+	excludes := make(map[string]string, len(exclude))
+	for _, e := range exclude {
+		excludes[filepath.Join(dir, e)] = e
+	}
+	return excludes
+}
 
-	if err := cmd.Run(); err != nil {
-		return err
+// exclusion returns why walk skips a path, or "" if it does not. The
+// contents of a skipped directory are skipped too.
+func exclusion(path string, isDir bool, excludes map[string]string) string {
+	if e, ok := excludes[path]; ok {
+		return fmt.Sprintf("--exclude %s", e)
 	}
-	return nil
+	if isDir && skip(path) != nil {
+		base := filepath.Base(path)
+		return fmt.Sprintf("the directory %s, whose name starts with %q", base, base[:1])
+	}
+	return ""
 }
 
 // skip indicates whether the directory's contents should be skipped.
@@ -143,6 +584,12 @@ func skip(path string) error {
 	return nil
 }
 
+// HeaderLines is how many lines of a file are read for its helm:generate
+// header. The header is the first line that is neither empty, a shebang on
+// the first line, such as '#!/bin/bash', nor a YAML document separator,
+// '---', and it must be one of these lines.
+const HeaderLines = 10
+
 // Read the generator from a file.
 //
 // An error indicates that something went wrong.
@@ -150,63 +597,112 @@ func skip(path string) error {
 // An empty string indicates that there was no generator.
 //
 // A string is to be treated as the value of the generator, without the
-// `helm:generate` prefix.
-func readGenerator(file *os.File) (string, error) {
+// `helm:generate` prefix. raw is true for a `helm:generate:raw` directive.
+//
+// Only the first command of a file with several is returned. See
+// readGenerators.
+func readGenerator(file *os.File) (line string, raw bool, err error) {
+	headers, err := readGenerators(file)
+	if err != nil || len(headers) == 0 {
+		return "", false, err
+	}
+	return headers[0].line, headers[0].raw, nil
+}
 
-	f := bufio.NewReader(file)
+// header is one helm:generate line of a file.
+type header struct {
+	line string
+	raw  bool
+}
 
-	// Look for leading `//`, `#`, or `/*`
-	var b []byte
-	var err error
-	if b, err = f.Peek(3); err != nil {
-		return "", nil
+// readGenerators reads the commands of the generator of a file, in order:
+// its header, and the helm:generate lines that follow it, before the first
+// line that is neither one nor a helm:after comment. A file without a
+// generator has none.
+func readGenerators(file *os.File) ([]header, error) {
+	sc := bufio.NewScanner(file)
+	headers, _ := scanHeaders(sc)
+	if err := sc.Err(); err != nil && err != bufio.ErrTooLong {
+		return nil, err
 	}
+	return headers, nil
+}
 
-	offset := 0
-	suffix := ""
-	if b[0] == '#' {
-		offset++
-		if b[1] == ' ' {
-			offset++
+// scanHeaders scans the top of a file: its helm:generate header, and the
+// helm:generate lines and helm:after comments that follow it, in any order.
+// It returns the commands, and the files of the helm:after comments.
+func scanHeaders(sc *bufio.Scanner) ([]header, []string) {
+	line, raw, ok := scanHeader(sc)
+	if !ok {
+		return nil, nil
+	}
+	headers := []header{{line, raw}}
+	var after []string
+	for sc.Scan() {
+		if line, raw, ok := headerLine(sc.Text()); ok {
+			headers = append(headers, header{line, raw})
+			continue
 		}
-	} else if b[0] == '/' && (b[1] == '/' || b[1] == '*') {
-		offset += 2
-		if b[2] == ' ' {
-			offset++
+		text, ok := afterComment(sc.Text())
+		if !ok {
+			break
 		}
-		if b[1] == '*' {
-			suffix = "*/"
+		for _, a := range strings.Fields(text) {
+			after = append(after, filepath.ToSlash(filepath.Clean(filepath.FromSlash(a))))
 		}
-	} else {
-		return "", nil
-	}
-
-	if _, err := f.Discard(offset); err != nil {
-		return "", err
 	}
+	return headers, after
+}
 
-	// If we get here, we have a comment header. Next, check if it's a helm:generate header.
-	if b, err = f.Peek(len(GeneratorKeyword)); err != nil {
-		return "", nil
+// scanHeader scans the lines of a file up to its helm:generate header, and
+// returns the header, as readGenerator does, and whether there is one. The
+// lines that follow the header are left to scan.
+func scanHeader(sc *bufio.Scanner) (line string, raw bool, ok bool) {
+	for n := 0; n < HeaderLines && sc.Scan(); n++ {
+		text := sc.Text()
+		if n == 0 && strings.HasPrefix(text, "#!") {
+			continue
+		}
+		if t := strings.TrimSpace(text); t == "" || t == "---" {
+			continue
+		}
+		return headerLine(text)
 	}
+	return "", false, false
+}
 
-	slug := string(b)
-	if slug != GeneratorKeyword {
-		return "", nil
-	}
-	if _, err := f.Discard(len(GeneratorKeyword)); err != nil {
-		return "", err
+// headerLine returns the command of a helm:generate header, and whether the
+// line is one.
+//
+// The header is a `//`, `#`, or `/*` comment, followed by at most one space,
+// and the keyword.
+func headerLine(text string) (line string, raw bool, ok bool) {
+	suffix := ""
+	switch {
+	case strings.HasPrefix(text, "#"):
+		text = text[1:]
+	case strings.HasPrefix(text, "//"):
+		text = text[2:]
+	case strings.HasPrefix(text, "/*"):
+		text, suffix = text[2:], "*/"
+	default:
+		return "", false, false
 	}
+	text = strings.TrimPrefix(text, " ")
 
-	// At this point, we know that we have a helm:generate header. Read to EOL.
-	line, err := f.ReadString('\n')
-	if err != nil {
-		return "", err
+	// If we get here, we have a comment header. Next, check if it's a helm:generate header.
+	switch {
+	case strings.HasPrefix(text, RawGeneratorKeyword):
+		text, raw = text[len(RawGeneratorKeyword):], true
+	case strings.HasPrefix(text, GeneratorKeyword):
+		text = text[len(GeneratorKeyword):]
+	default:
+		return "", false, false
 	}
 
-	line = strings.TrimSpace(line)
+	line = strings.TrimSpace(text)
 	if len(suffix) > 0 {
 		line = strings.TrimSpace(strings.TrimSuffix(line, suffix))
 	}
-	return line, err
+	return line, raw, line != ""
 }
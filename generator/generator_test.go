@@ -1,11 +1,25 @@
 package generator
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
+
+	helmerrors "github.com/helm/helm-classic/errors"
+	"github.com/helm/helm-classic/log"
 )
 
+// allowAll is the policy of the tests that run commands other than the
+// templates of helmc.
+var allowAll = &Policy{Allow: []string{AllowAll}}
+
 func TestSkip(t *testing.T) {
 	pass := []string{
 		".foo/.bar/baz",
@@ -34,15 +48,15 @@ func TestSkip(t *testing.T) {
 
 func TestReadGenerator(t *testing.T) {
 	dir := "../testdata/generator"
-	pass := []string{"one.yaml", "two.yaml", "three.txt", "four/four.txt", "four/five.txt"}
-	fail := []string{"fail.txt", "fail2.txt"}
+	pass := []string{"one.yaml", "two.yaml", "three.txt", "four/four.txt", "four/five.txt", "six.sh", "seven.yaml"}
+	fail := []string{"fail.txt", "fail2.txt", "fail3.yaml"}
 
 	for _, p := range pass {
 		f, err := os.Open(filepath.Join(dir, p))
 		if err != nil {
 			t.Errorf("failed to read %s: %s", p, err)
 		}
-		out, err := readGenerator(f)
+		out, _, err := readGenerator(f)
 		if err != nil {
 			t.Errorf("%s failed read generator: %s", p, err)
 		}
@@ -57,7 +71,7 @@ func TestReadGenerator(t *testing.T) {
 		if err != nil {
 			t.Errorf("failed to read %s: %s", p, err)
 		}
-		out, err := readGenerator(f)
+		out, _, err := readGenerator(f)
 		if err != nil {
 			t.Errorf("%s failed read generator: %s", p, err)
 		}
@@ -69,13 +83,229 @@ func TestReadGenerator(t *testing.T) {
 	}
 }
 
+func TestReadGeneratorPreamble(t *testing.T) {
+	dir := orderChart(t, map[string]string{
+		"a.sh":    "#!/bin/sh\n\n//helm:generate:raw echo $a\n#helm:after b.yaml\n",
+		"b.yaml":  "---\n---\n#helm:generate echo b\n",
+		"late.sh": strings.Repeat("\n", HeaderLines) + "#helm:generate echo late\n",
+		"c.yaml":  "---\n#!/bin/sh\n#helm:generate echo c\n",
+		"d.txt":   "#helm:generate echo d",
+	})
+	defer os.RemoveAll(dir)
+	for file, expected := range map[string]string{"a.sh": "echo $a", "b.yaml": "echo b", "late.sh": "", "c.yaml": "", "d.txt": "echo d"} {
+		f, err := os.Open(filepath.Join(dir, file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		line, _, err := readGenerator(f)
+		f.Close()
+		if err != nil || line != expected {
+			t.Errorf("Expected the generator of %s to be %q, got %q, %v", file, expected, line, err)
+		}
+	}
+	if after, err := readAfter(filepath.Join(dir, "a.sh")); err != nil || len(after) != 1 || after[0] != "b.yaml" {
+		t.Errorf("Expected the helm:after comment after the header, got %v, %v", after, err)
+	}
+}
+
 func TestWalk(t *testing.T) {
 	dir := "../testdata/generator"
-	count, err := Walk(dir, []string{}, false)
+	count, err := Walk(context.Background(), dir, Options{Verbose: true, Policy: allowAll})
+	if err != nil {
+		t.Fatalf("Failed to walk: %s", err)
+	}
+	if count.Total() != 7 {
+		t.Errorf("Expected 7 executes, got %d", count.Total())
+	}
+}
+
+func TestWalkParallel(t *testing.T) {
+	dir, err := ioutil.TempDir("", "helmc-generator")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"a", "b", "c", "d"} {
+		ioutil.WriteFile(filepath.Join(dir, name+".yaml"), []byte("#helm:generate sleep 0.5\n"), 0644)
+		ioutil.WriteFile(filepath.Join(dir, name+"-echo.yaml"), []byte("#helm:generate echo "+name+"\n"), 0644)
+	}
+
+	var stdout, stderr bytes.Buffer
+	l := &log.Logger{Stdout: &stdout, Stderr: &stderr}
+	started := 0
+	h := &Hooks{Started: func(file, command string) { started++ }}
+	start := time.Now()
+	count, err := Walk(context.Background(), dir, Options{Verbose: true, Jobs: 4, Log: l, Hooks: h, Policy: allowAll})
 	if err != nil {
 		t.Fatalf("Failed to walk: %s", err)
 	}
-	if count != 5 {
-		t.Errorf("Expected 5 executes, got %d", count)
+	if d := time.Since(start); d > 1500*time.Millisecond {
+		t.Errorf("Expected the generators to run at the same time, but they took %s", d)
+	}
+	if count.Total() != 8 || started != 8 {
+		t.Errorf("Expected 8 executes, got %d and %d starts", count.Total(), started)
+	}
+	if stdout.String() != "a\nb\nc\nd\n" {
+		t.Errorf("Expected the output in the order of the files, got %q", stdout.String())
+	}
+
+	// Every generator runs, and the first failure in the order of the files
+	// is the error.
+	ioutil.WriteFile(filepath.Join(dir, "b.yaml"), []byte("#helm:generate false b\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "d.yaml"), []byte("#helm:generate false d\n"), 0644)
+	stdout.Reset()
+	stderr.Reset()
+	count, err = Walk(context.Background(), dir, Options{Verbose: true, Jobs: 4, Log: l, Policy: allowAll})
+	var ge *helmerrors.GeneratorError
+	if !errors.As(err, &ge) || ge.File != filepath.Join(dir, "b.yaml") {
+		t.Errorf("Expected b.yaml to fail, got %v", err)
+	}
+	if count.Total() != 8 || stdout.String() != "a\nb\nc\nd\n" {
+		t.Errorf("Expected every generator to run, got %d, %q", count.Total(), stdout.String())
+	}
+	if !strings.Contains(stderr.String(), "false d") {
+		t.Errorf("Expected the failure of d.yaml to be logged, got %q", stderr.String())
+	}
+
+	// One at a time, the first failure stops the walk.
+	stdout.Reset()
+	count, err = Walk(context.Background(), dir, Options{Verbose: true, Log: l, Policy: allowAll})
+	if err == nil || count.Total() != 4 || stdout.String() != "a\nb\n" {
+		t.Errorf("Expected the walk to stop at b.yaml, got %d, %q, %v", count.Total(), stdout.String(), err)
+	}
+}
+
+func TestExpand(t *testing.T) {
+	os.Setenv("HELM_TEST_DEFINED", "env")
+	defer os.Unsetenv("HELM_TEST_DEFINED")
+	vars := map[string]string{"HELM_HOME": "/home", "HELM_EMPTY": ""}
+
+	tests := []struct {
+		in, out, undefined string
+	}{
+		{"helmc tpl $HELM_HOME/x ${HELM_TEST_DEFINED}", "helmc tpl /home/x env", ""},
+		{"sed -e s/$$/;/ $HELM_EMPTY", "sed -e s/$/;/ ", ""},
+		{"helmc tpl $HELM_GENERATE_FIL", "helmc tpl ", "HELM_GENERATE_FIL"},
+	}
+	for _, tt := range tests {
+		out, err := expand(tt.in, "a.yaml", vars, false)
+		if err != nil || out != tt.out {
+			t.Errorf("Expected %q to expand to %q, got %q, %v", tt.in, tt.out, out, err)
+		}
+		_, err = expand(tt.in, "a.yaml", vars, true)
+		if tt.undefined == "" && err != nil {
+			t.Errorf("Expected %q to expand strictly: %s", tt.in, err)
+		}
+		if ue, ok := err.(*UndefinedVariableError); tt.undefined != "" && (!ok || ue.Name != tt.undefined || ue.File != "a.yaml" || ue.Directive != tt.in) {
+			t.Errorf("Expected %q to fail on $%s, got %v", tt.in, tt.undefined, err)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "helmc-generator")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "ok.yaml"), []byte("#helm:generate echo $HELM_GENERATE_FILE $HELM_HOME\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "typo.yaml"), []byte("#helm:generate echo $HELM_GENERATE_FIL\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "raw.yaml"), []byte("#helm:generate:raw echo $NOT_EXPANDED\n"), 0644)
+
+	env := map[string]string{"HELM_HOME": "/home"}
+	undefined, err := Check(dir, nil, env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(undefined) != 1 || undefined[0].Name != "HELM_GENERATE_FIL" || undefined[0].File != filepath.Join(dir, "typo.yaml") {
+		t.Errorf("Expected only $HELM_GENERATE_FIL to be undefined, got %v", undefined)
+	}
+
+	var b bytes.Buffer
+	l := &log.Logger{Stdout: &b, Stderr: &b}
+	if _, err := Walk(context.Background(), dir, Options{DryRun: true, Strict: true, Verbose: true, Env: env, Log: l, Policy: allowAll}); err == nil || !strings.Contains(err.Error(), "$HELM_GENERATE_FIL") {
+		t.Errorf("Expected a strict walk to fail, got %v", err)
+	}
+	if _, err := Walk(context.Background(), dir, Options{Exclude: []string{"typo.yaml"}, DryRun: true, Strict: true, Verbose: true, Env: env, Log: l, Policy: allowAll}); err != nil {
+		t.Errorf("Expected a strict walk to succeed: %s", err)
+	}
+	if !strings.Contains(b.String(), "Would run echo $NOT_EXPANDED") {
+		t.Errorf("Expected a raw directive not to be expanded, got %q", b.String())
+	}
+}
+
+func TestWalkOutput(t *testing.T) {
+	dir := orderChart(t, map[string]string{
+		"a.yaml":       "#helm:generate sh $HELM_GENERATE_DIR/_bin/out.sh\n",
+		"tpl/a.yaml":   "#helm:generate sh $HELM_GENERATE_DIR/_bin/warn.sh\n",
+		"_bin/out.sh":  "echo out; echo err >&2\n",
+		"_bin/warn.sh": "echo warning: careful >&2\n",
+	})
+	defer os.RemoveAll(dir)
+
+	// Each line is logged with the file of its generator.
+	var stdout, stderr bytes.Buffer
+	l := &log.Logger{Stdout: &stdout, Stderr: &stderr}
+	if _, err := Walk(context.Background(), dir, Options{Jobs: 4, Log: l, Policy: allowAll}); err != nil {
+		t.Fatalf("Failed to walk: %s", err)
+	}
+	for _, line := range []string{"[generate a.yaml] out", "[generate a.yaml] err", "[WARN] [generate tpl/a.yaml] warning: careful"} {
+		if !strings.Contains(stderr.String(), line) {
+			t.Errorf("Expected %q to be logged, got %q", line, stderr.String())
+		}
+	}
+	if stdout.Len() > 0 {
+		t.Errorf("Expected nothing on stdout, got %q", stdout.String())
+	}
+
+	// A verbose walk writes the output as it is.
+	stdout.Reset()
+	stderr.Reset()
+	if _, err := Walk(context.Background(), dir, Options{Exclude: []string{"tpl"}, Verbose: true, Log: l, Policy: allowAll}); err != nil {
+		t.Fatalf("Failed to walk: %s", err)
+	}
+	if stdout.String() != "out\n" || stderr.String() != "err\n" {
+		t.Errorf("Expected the raw output, got %q and %q", stdout.String(), stderr.String())
+	}
+}
+
+func TestWalkTimeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("The generators are shell scripts")
+	}
+	dir := orderChart(t, map[string]string{
+		"a.yaml":       "#helm:generate sh $HELM_GENERATE_DIR/_bin/hang.sh\n",
+		"b.yaml":       "#helm:generate echo b\n",
+		"_bin/hang.sh": "sleep 30 &\nwait\n",
+	})
+	defer os.RemoveAll(dir)
+	var stdout bytes.Buffer
+	l := &log.Logger{Stdout: &stdout}
+
+	// The sleep is killed with the script: otherwise, its stdout would stay
+	// open, and Walk would wait for it.
+	start := time.Now()
+	_, err := Walk(context.Background(), dir, Options{Verbose: true, Timeout: 100 * time.Millisecond, Log: l, Policy: allowAll})
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("Expected a.yaml to time out, got %v", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("Expected the generator and its children to be killed, took %s", d)
+	}
+	if stdout.Len() > 0 {
+		t.Errorf("Expected b.yaml not to run after the failure, got %q", stdout.String())
+	}
+
+	// Once ctx is done, nothing starts.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, jobs := range []int{1, 4} {
+		count, err := Walk(ctx, dir, Options{Verbose: true, Jobs: jobs, Log: l, Policy: allowAll})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected the walk of %d jobs to be stopped, got %d, %v", jobs, count.Total(), err)
+		}
+	}
+	if stdout.Len() > 0 {
+		t.Errorf("Expected nothing to run, got %q", stdout.String())
 	}
 }
@@ -0,0 +1,197 @@
+package generator
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// LockFile is the file of a chart in which an incremental Walk records the
+// inputs of the generators that it ran, so that the next one only runs the
+// generators whose inputs changed.
+const LockFile = ".helm-generate.lock"
+
+const lockHeader = `# The inputs of the generators that 'helmc generate --incremental' ran. Each
+# line is the file of a generator, one of its inputs, and the SHA-256 of the
+# input; the input without a name is the command and its environment. A
+# generator runs again when one of them changes. Give --force, or delete this
+# file, to run every generator.
+`
+
+// Lock is the content of a LockFile: the digests of the inputs of each
+// generator, by the file of the generator, relative to the chart, with
+// slashes. The commands after the first of a file with several are recorded
+// as the file, '#', and their position, from 1, such as "tpl/pod.yaml#2".
+// See Inputs.
+type Lock map[string]map[string]string
+
+// LoadLock reads the LockFile of the chart in dir. A chart without one has an
+// empty lock.
+func LoadLock(dir string) (Lock, error) {
+	lk := Lock{}
+	data, err := ioutil.ReadFile(filepath.Join(dir, LockFile))
+	if os.IsNotExist(err) {
+		return lk, nil
+	} else if err != nil {
+		return nil, err
+	}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; sc.Scan(); n++ {
+		line := sc.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		f := strings.Split(line, "\t")
+		if len(f) != 3 {
+			return nil, fmt.Errorf("%s:%d: expected the file of a generator, an input, and a digest, separated by tabs", LockFile, n)
+		}
+		if lk[f[0]] == nil {
+			lk[f[0]] = map[string]string{}
+		}
+		lk[f[0]][f[1]] = f[2]
+	}
+	return lk, sc.Err()
+}
+
+// Save writes lk to the LockFile of the chart in dir, one input per line,
+// sorted, as State.Save does. An empty lock removes the file.
+func (lk Lock) Save(dir string) error {
+	file := filepath.Join(dir, LockFile)
+	if len(lk) == 0 {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	var b bytes.Buffer
+	b.WriteString(lockHeader)
+	sources := make([]string, 0, len(lk))
+	for src := range lk {
+		sources = append(sources, src)
+	}
+	sort.Strings(sources)
+	for _, src := range sources {
+		for _, in := range sortedKeys(lk[src]) {
+			fmt.Fprintf(&b, "%s\t%s\t%s\n", src, in, lk[src][in])
+		}
+	}
+	return ioutil.WriteFile(file, b.Bytes(), 0644)
+}
+
+// sortedKeys returns the keys of m, sorted.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Inputs returns the digests of the inputs of the generator of file, whose
+// expanded command is line, and whose environment is vars: those of the
+// command and vars, under "", of file, of each other file that an argument of
+// the command names, as the Watcher finds them, and of those that its
+// template reads (see TemplateReads). The files of the chart are relative to
+// dir, with slashes, and the others are absolute. Arguments that are not
+// files, or are directories, are left out.
+func Inputs(dir, file, line string, vars map[string]string) map[string]string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n", line)
+	for _, k := range sortedKeys(vars) {
+		fmt.Fprintf(h, "%s=%s\n", k, vars[k])
+	}
+	res := map[string]string{"": "sha256:" + hex.EncodeToString(h.Sum(nil))}
+	for _, p := range append(append([]string{file}, argPaths(dir, line)...), templateReads(dir, line)...) {
+		if fi, err := os.Stat(p); err != nil || !fi.Mode().IsRegular() {
+			continue
+		}
+		digest, err := FileDigest(p)
+		if err != nil {
+			continue
+		}
+		name := relSlash(dir, p)
+		if name == "" || strings.HasPrefix(name, "../") {
+			name = filepath.ToSlash(p)
+		}
+		res[name] = digest
+	}
+	return res
+}
+
+// lockKey returns the entry of the Lock of the generator of j.
+func lockKey(dir string, j *job) string {
+	src := relSlash(dir, j.path)
+	if j.index > 0 {
+		src = fmt.Sprintf("%s#%d", src, j.index+1)
+	}
+	return src
+}
+
+// errUpToDate is the result of a job that an incremental walk did not run,
+// because its inputs did not change.
+var errUpToDate = errors.New("up to date")
+
+// inputLock is the state of an incremental walk, which the jobs that run
+// in parallel share.
+type inputLock struct {
+	mu    sync.Mutex
+	lock  Lock
+	state State
+}
+
+// upToDate returns true if the inputs of the generator of j are those that
+// the lock recorded, and the files that it declares as outputs are there, as
+// it wrote them.
+func (inc *inputLock) upToDate(dir string, j *job) bool {
+	inc.mu.Lock()
+	defer inc.mu.Unlock()
+	src := relSlash(dir, j.path)
+	prev, ok := inc.lock[lockKey(dir, j)]
+	if !ok {
+		return false
+	}
+	cur := Inputs(dir, j.path, j.line, j.vars)
+	if len(cur) != len(prev) {
+		return false
+	}
+	for in, digest := range cur {
+		if prev[in] != digest {
+			return false
+		}
+	}
+	for _, out := range Outputs(dir, j.line) {
+		if out == src {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(out))); err != nil {
+			return false
+		}
+		if o, ok := inc.state[out]; ok && o.Edited(dir) {
+			return false
+		}
+	}
+	return true
+}
+
+// record records the inputs of the generator of j, after it ran, or forgets
+// them if it failed, so that it runs again.
+func (inc *inputLock) record(dir string, j *job, err error) {
+	inc.mu.Lock()
+	defer inc.mu.Unlock()
+	key := lockKey(dir, j)
+	if err != nil {
+		delete(inc.lock, key)
+		return
+	}
+	inc.lock[key] = Inputs(dir, j.path, j.line, j.vars)
+}
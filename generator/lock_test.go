@@ -0,0 +1,110 @@
+package generator
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/helm/helm-classic/log"
+)
+
+func TestLock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "helm-generate-lock-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	lk, err := LoadLock(dir)
+	if err != nil || len(lk) != 0 {
+		t.Fatalf("Expected an empty lock without a lock file, got %v, %v", lk, err)
+	}
+	lk["tpl/pod.yaml"] = map[string]string{"": "sha256:1", "tpl/pod.yaml": "sha256:2", "values.toml": "sha256:3"}
+	lk["a.yaml"] = map[string]string{"": "sha256:4"}
+	if err := lk.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := ioutil.ReadFile(filepath.Join(dir, LockFile))
+	if !strings.HasSuffix(string(data), "\na.yaml\t\tsha256:4\ntpl/pod.yaml\t\tsha256:1\ntpl/pod.yaml\ttpl/pod.yaml\tsha256:2\ntpl/pod.yaml\tvalues.toml\tsha256:3\n") {
+		t.Errorf("Expected the inputs one per line, sorted, got\n%s", data)
+	}
+	if loaded, err := LoadLock(dir); err != nil || !reflect.DeepEqual(loaded, lk) {
+		t.Errorf("Expected %v, got %v, %v", lk, loaded, err)
+	}
+
+	if err := (Lock{}).Save(dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, LockFile)); !os.IsNotExist(err) {
+		t.Errorf("Expected an empty lock to remove the lock file, got %v", err)
+	}
+}
+
+func TestInputs(t *testing.T) {
+	dir := orderChart(t, map[string]string{
+		"tpl/pod.yaml": "#helm:generate helm tpl -o pod.yaml -d values.toml tpl/pod.yaml\n",
+		"values.toml":  "a = 1\n",
+	})
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "tpl", "pod.yaml")
+	line := "helm tpl -o pod.yaml -d values.toml tpl/pod.yaml"
+	in := Inputs(dir, file, line, map[string]string{"A": "1"})
+	if _, ok := in[""]; !ok || len(in) != 3 || in["tpl/pod.yaml"] == "" || in["values.toml"] == "" {
+		t.Errorf("Expected the command, the template and the values, and not the output or the flags, got %v", in)
+	}
+	if other := Inputs(dir, file, line, map[string]string{"A": "2"}); other[""] == in[""] {
+		t.Error("Expected the environment to be an input")
+	}
+}
+
+func TestWalkIncremental(t *testing.T) {
+	dir := orderChart(t, map[string]string{
+		"a.yaml":      "#helm:generate sh $HELM_GENERATE_DIR/_bin/out.sh a out/a.txt\n",
+		"b.yaml":      "#helm:generate sh $HELM_GENERATE_DIR/_bin/out.sh b --out out/b.txt values.toml\n",
+		"values.toml": "a = 1\n",
+		"_bin/out.sh": "echo $1; mkdir -p out; echo $1 > out/$1.txt\n",
+	})
+	defer os.RemoveAll(dir)
+	var stdout bytes.Buffer
+	l := &log.Logger{Stdout: &stdout}
+	walk := func(force bool) string {
+		stdout.Reset()
+		if _, err := Walk(context.Background(), dir, Options{Force: force, Verbose: true, Incremental: true, Log: l, Policy: allowAll}); err != nil {
+			t.Fatalf("Failed to walk: %s", err)
+		}
+		return stdout.String()
+	}
+
+	if out := walk(false); out != "a\nb\n" {
+		t.Errorf("Expected every generator to run the first time, got %q", out)
+	}
+	if out := walk(false); out != "" {
+		t.Errorf("Expected nothing to run, got %q", out)
+	}
+
+	// An input that changed, an output that was edited, and force.
+	ioutil.WriteFile(filepath.Join(dir, "values.toml"), []byte("a = 2\n"), 0644)
+	if out := walk(false); out != "b\n" {
+		t.Errorf("Expected b.yaml to run after its values changed, got %q", out)
+	}
+	ioutil.WriteFile(filepath.Join(dir, "out", "b.txt"), []byte("edited\n"), 0644)
+	if out := walk(false); out != "b\n" {
+		t.Errorf("Expected b.yaml to run after its output was edited, got %q", out)
+	}
+	if out := walk(true); out != "a\nb\n" {
+		t.Errorf("Expected force to run every generator, got %q", out)
+	}
+
+	// A generator that is gone is forgotten.
+	os.Remove(filepath.Join(dir, "a.yaml"))
+	walk(false)
+	lk, err := LoadLock(dir)
+	if _, ok := lk["a.yaml"]; err != nil || ok || lk["b.yaml"] == nil {
+		t.Errorf("Expected only b.yaml to be recorded, got %v, %v", lk, err)
+	}
+}
@@ -0,0 +1,209 @@
+package generator
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// AfterKeyword declares the files whose generators must run before the
+// generator of a file. Any number of 'helm:after' comments may follow the
+// 'helm:generate' line, each with one or more files, relative to the chart:
+//
+//	#helm:generate helmc tpl -o manifests/pod.yaml tpl/pod.yaml
+//	#helm:after tpl/values.yaml
+const AfterKeyword = "helm:after "
+
+// readAfter reads the files of the 'helm:after' comments of a generator,
+// which follow its header, relative to the chart and with slashes.
+func readAfter(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	_, after := scanHeaders(sc)
+	return after, sc.Err()
+}
+
+// afterComment returns the files of a 'helm:after' comment, and whether the
+// line is one.
+func afterComment(line string) (string, bool) {
+	line = strings.TrimSpace(line)
+	suffix := ""
+	switch {
+	case strings.HasPrefix(line, "#"):
+		line = line[1:]
+	case strings.HasPrefix(line, "//"):
+		line = line[2:]
+	case strings.HasPrefix(line, "/*"):
+		line, suffix = line[2:], "*/"
+	default:
+		return "", false
+	}
+	line = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(line), suffix))
+	if !strings.HasPrefix(line+" ", AfterKeyword) {
+		return "", false
+	}
+	return line[len(AfterKeyword)-1:], true
+}
+
+// CycleError indicates that the 'helm:after' comments of generators form a
+// cycle, so that none of them can run first.
+type CycleError struct {
+	// Files are the files of the generators, relative to the chart, each
+	// running after the one before it, and the first one again last.
+	Files []string
+}
+
+func (e *CycleError) Error() string {
+	return fmt.Sprintf("the helm:after comments of the generators form a cycle: %s", strings.Join(e.Files, " -> "))
+}
+
+// order sorts todo so that each generator runs after those that its
+// 'helm:after' comments name, and otherwise in the order of the files, and
+// sets the after of each job to the jobs that it waits for. The commands of
+// a file with several run one after the other, the first after those that
+// the file names, and a generator that names the file runs after the last. A generator may
+// name one that is not in todo, such as one that is excluded, which is then
+// not waited for. Naming a file without a generator, or a cycle, is an
+// error.
+func order(dir string, todo []*job) ([]*job, error) {
+	index := map[string]int{}
+	for i, j := range todo {
+		index[relSlash(dir, j.path)] = i
+	}
+	deps := make([][]int, len(todo))
+	for i, j := range todo {
+		if i > 0 && todo[i-1].path == j.path {
+			deps[i] = []int{i - 1}
+			continue
+		}
+		after, err := readAfter(j.path)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range after {
+			if k, ok := index[a]; ok {
+				deps[i] = append(deps[i], k)
+			} else if !hasGenerator(filepath.Join(dir, filepath.FromSlash(a))) {
+				return nil, fmt.Errorf("%s runs after %s, which has no generator", relSlash(dir, j.path), a)
+			}
+		}
+	}
+
+	// Kahn's algorithm, which takes the first file that is ready each time,
+	// so that the order is that of the files wherever it can be.
+	waiting := make([]int, len(todo))
+	dependents := make([][]int, len(todo))
+	for i, ds := range deps {
+		waiting[i] = len(ds)
+		for _, d := range ds {
+			dependents[d] = append(dependents[d], i)
+		}
+	}
+	var ready, sorted []int
+	for i := range todo {
+		if waiting[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+	for len(ready) > 0 {
+		i := ready[0]
+		ready = ready[1:]
+		sorted = append(sorted, i)
+		for _, d := range dependents[i] {
+			if waiting[d]--; waiting[d] == 0 {
+				ready = append(ready, d)
+				sort.Ints(ready)
+			}
+		}
+	}
+	if len(sorted) < len(todo) {
+		return nil, cycle(dir, todo, deps, waiting)
+	}
+
+	pos := make([]int, len(todo))
+	res := make([]*job, len(todo))
+	for p, i := range sorted {
+		pos[i], res[p] = p, todo[i]
+	}
+	for i, j := range todo {
+		j.after = nil
+		for _, d := range deps[i] {
+			j.after = append(j.after, pos[d])
+		}
+	}
+	return res, nil
+}
+
+// hasGenerator returns true if a file declares a generator.
+func hasGenerator(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	line, _, err := readGenerator(f)
+	return err == nil && line != ""
+}
+
+// cycle finds a cycle among the jobs that are still waiting once Kahn's
+// algorithm is stuck.
+func cycle(dir string, todo []*job, deps [][]int, waiting []int) *CycleError {
+	start := 0
+	for i, w := range waiting {
+		if w > 0 {
+			start = i
+			break
+		}
+	}
+	// Every waiting job waits for another waiting job, so following them
+	// must come back to one that was seen.
+	seen := map[int]int{}
+	path := []int{}
+	for i := start; ; {
+		if at, ok := seen[i]; ok {
+			path = append(path[at:], i)
+			break
+		}
+		seen[i] = len(path)
+		path = append(path, i)
+		for _, d := range deps[i] {
+			if waiting[d] > 0 {
+				i = d
+				break
+			}
+		}
+	}
+	// The path follows what each job waits for; the cycle reads in the order
+	// that they would run.
+	// The commands of a file are one step of it.
+	var files []string
+	for k := len(path) - 1; k >= 0; k-- {
+		if f := relSlash(dir, todo[path[k]].path); len(files) == 0 || files[len(files)-1] != f {
+			files = append(files, f)
+		}
+	}
+	return &CycleError{Files: files}
+}
+
+// CheckOrder finds the generators of a chart directory, as Walk does, and
+// returns the error that ordering them would be, such as a *CycleError, or
+// nil. Nothing is executed.
+func CheckOrder(dir string, exclude []string) error {
+	var todo []*job
+	err := walk(dir, exclude, func(path, line string, raw bool) error {
+		todo = append(todo, &job{path: path, line: line})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	_, err = order(dir, todo)
+	return err
+}
@@ -0,0 +1,160 @@
+package generator
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/helm/helm-classic/log"
+)
+
+func TestAfterComment(t *testing.T) {
+	for line, expected := range map[string]string{
+		"#helm:after tpl/values.yaml":     "tpl/values.yaml",
+		"# helm:after a.yaml b.yaml":      "a.yaml b.yaml",
+		"//helm:after a.yaml":             "a.yaml",
+		"/* helm:after a.yaml */":         "a.yaml",
+		"#helm:after":                     "",
+		"#helm:generate helmc tpl a.yaml": "-",
+		"apiVersion: v1":                  "-",
+		"#helm:aftermath a.yaml":          "-",
+	} {
+		text, ok := afterComment(line)
+		if expected == "-" && ok || expected != "-" && (!ok || strings.TrimSpace(text) != expected) {
+			t.Errorf("Expected %q to name %q, got %q, %v", line, expected, text, ok)
+		}
+	}
+}
+
+// orderChart writes the files of a chart, and returns its directory.
+func orderChart(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "helmc-generator")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755)
+		ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+	}
+	return dir
+}
+
+func TestOrder(t *testing.T) {
+	dir := orderChart(t, map[string]string{
+		"a.yaml":     "#helm:generate echo a\n#helm:after tpl/c.yaml\n",
+		"b.yaml":     "#helm:generate echo b\napiVersion: v1\n#helm:after a.yaml\n",
+		"tpl/c.yaml": "//helm:generate echo c\n//helm:after b.yaml d.yaml\n",
+		"d.yaml":     "#helm:generate echo d\n",
+	})
+	defer os.RemoveAll(dir)
+	var stdout bytes.Buffer
+	l := &log.Logger{Stdout: &stdout}
+	for _, jobs := range []int{1, 4} {
+		stdout.Reset()
+		if _, err := Walk(context.Background(), dir, Options{Verbose: true, Jobs: jobs, Log: l, Policy: allowAll}); err != nil {
+			t.Fatalf("Failed to walk: %s", err)
+		}
+		// A helm:after that does not follow the directive is not one.
+		if stdout.String() != "b\nd\nc\na\n" {
+			t.Errorf("Expected c to run after b and d, and a after c, with %d jobs, got %q", jobs, stdout.String())
+		}
+	}
+
+	// A cycle is an error, and nothing runs.
+	ioutil.WriteFile(filepath.Join(dir, "d.yaml"), []byte("#helm:generate echo d\n#helm:after a.yaml\n"), 0644)
+	stdout.Reset()
+	_, err := Walk(context.Background(), dir, Options{Verbose: true, Log: l, Policy: allowAll})
+	var ce *CycleError
+	if !errors.As(err, &ce) || strings.Join(ce.Files, " ") != "a.yaml d.yaml tpl/c.yaml a.yaml" {
+		t.Errorf("Expected a cycle of a, c, and d, got %v", err)
+	}
+	if stdout.Len() > 0 || CheckOrder(dir, nil) == nil {
+		t.Errorf("Expected nothing to run, and CheckOrder to fail too, got %q", stdout.String())
+	}
+
+	ioutil.WriteFile(filepath.Join(dir, "d.yaml"), []byte("#helm:generate echo d\n#helm:after missing.yaml\n"), 0644)
+	if err := CheckOrder(dir, nil); err == nil || err.Error() != "d.yaml runs after missing.yaml, which has no generator" {
+		t.Errorf("Expected a missing generator, got %v", err)
+	}
+	// One that is excluded is not waited for.
+	ioutil.WriteFile(filepath.Join(dir, "d.yaml"), []byte("#helm:generate echo d\n"), 0644)
+	stdout.Reset()
+	if _, err := Walk(context.Background(), dir, Options{Exclude: []string{"tpl"}, Verbose: true, Log: l, Policy: allowAll}); err != nil || stdout.String() != "a\nb\nd\n" {
+		t.Errorf("Expected a to run without the excluded c, got %q, %v", stdout.String(), err)
+	}
+}
+
+func TestOrderParallel(t *testing.T) {
+	dir := orderChart(t, map[string]string{
+		"a.yaml":  "#helm:generate cat out.txt\n#helm:after z.yaml\n",
+		"b.yaml":  "#helm:generate echo b\n",
+		"z.yaml":  "#helm:generate cp src.txt out.txt\n",
+		"src.txt": "z\n",
+	})
+	defer os.RemoveAll(dir)
+	var stdout, stderr bytes.Buffer
+	l := &log.Logger{Stdout: &stdout, Stderr: &stderr}
+	count, err := Walk(context.Background(), dir, Options{Verbose: true, Jobs: 4, Log: l, Policy: allowAll})
+	if err != nil || count.Total() != 3 || stdout.String() != "b\nz\n" {
+		t.Errorf("Expected a.yaml to read what z.yaml wrote, got %d, %q, %v", count.Total(), stdout.String(), err)
+	}
+
+	// The generators that run after one that failed do not run.
+	os.Remove(filepath.Join(dir, "out.txt"))
+	os.Remove(filepath.Join(dir, "src.txt"))
+	stdout.Reset()
+	count, err = Walk(context.Background(), dir, Options{Verbose: true, Jobs: 4, Log: l, Policy: allowAll})
+	if err == nil || count.Total() != 2 || stdout.String() != "b\n" {
+		t.Errorf("Expected only z.yaml to fail, and a.yaml not to run, got %d, %q, %v", count.Total(), stdout.String(), err)
+	}
+	if !strings.Contains(stderr.String(), "Not running the generator of a.yaml, which runs after z.yaml, since it failed.") {
+		t.Errorf("Expected a.yaml to be skipped, got %q", stderr.String())
+	}
+}
+
+func TestWalkCommands(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("The generators are shell scripts")
+	}
+	dir := orderChart(t, map[string]string{
+		"a.yaml":      "#helm:generate sh $HELM_GENERATE_DIR/_bin/cmd.sh one\n#helm:after c.yaml\n#helm:generate sh $HELM_GENERATE_DIR/_bin/cmd.sh two\nkind: Pod\n",
+		"b.yaml":      "#helm:generate echo b\n#helm:after a.yaml\n",
+		"c.yaml":      "#helm:generate echo c\n",
+		"_bin/cmd.sh": "echo $1: $HELM_GENERATE_COMMAND_EXPANDED\n",
+		"fail.yaml":   "#helm:generate false\n#helm:generate echo not run\n",
+	})
+	defer os.RemoveAll(dir)
+	cmd := filepath.Join(dir, "_bin", "cmd.sh")
+	expected := "c\none: sh " + cmd + " one\ntwo: sh " + cmd + " two\nb\n"
+	for _, jobs := range []int{1, 4} {
+		var stdout bytes.Buffer
+		counts, err := Walk(context.Background(), dir, Options{Exclude: []string{"fail.yaml"}, Verbose: true, Jobs: jobs, Log: &log.Logger{Stdout: &stdout}, Policy: allowAll})
+		if err != nil {
+			t.Fatalf("Failed to walk: %s", err)
+		}
+		if stdout.String() != expected {
+			t.Errorf("Expected the commands of a.yaml in order, after c.yaml and before b.yaml, with %d jobs, got %q", jobs, stdout.String())
+		}
+		if !reflect.DeepEqual(counts, Counts{"a.yaml": 2, "b.yaml": 1, "c.yaml": 1}) || counts.Total() != 4 {
+			t.Errorf("Expected the counts of each file, got %v", counts)
+		}
+	}
+
+	var stdout bytes.Buffer
+	counts, err := Walk(context.Background(), dir, Options{Exclude: []string{"a.yaml", "b.yaml", "c.yaml"}, Verbose: true, Log: &log.Logger{Stdout: &stdout}, Policy: allowAll})
+	if err == nil || counts["fail.yaml"] != 1 || stdout.Len() > 0 {
+		t.Errorf("Expected the command after the one that failed not to run, got %v, %q, %v", counts, stdout.String(), err)
+	}
+
+	invs, err := Explain(dir, "a.yaml", nil, false, false, nil)
+	if err != nil || len(invs) != 2 || invs[1].Args[1] != "two" || invs[1].Env["HELM_GENERATE_COMMAND"] != "sh $HELM_GENERATE_DIR/_bin/cmd.sh two" {
+		t.Errorf("Expected both commands of a.yaml to be explained, got %v, %v", invs, err)
+	}
+}
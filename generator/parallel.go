@@ -0,0 +1,178 @@
+package generator
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/helm/helm-classic/log"
+)
+
+// errNotRun is the result of a job that runJobs did not start, because one
+// before it failed.
+var errNotRun = errors.New("not run")
+
+// runJobs runs the generators of todo, jobs of them at a time, and returns
+// the error of each, in the order of todo. See Walk.
+func runJobs(ctx context.Context, dir string, todo []*job, force, verbose bool, jobs int, timeout time.Duration, inc *inputLock, l *log.Logger, h *Hooks) []error {
+	errs := make([]error, len(todo))
+	if jobs <= 1 || len(todo) <= 1 {
+		for i, j := range todo {
+			if errs[i] = runOne(ctx, dir, j, force, verbose, timeout, inc, l, h); errs[i] != nil && errs[i] != errUpToDate {
+				for k := i + 1; k < len(errs); k++ {
+					errs[k] = errNotRun
+				}
+				break
+			}
+		}
+		return errs
+	}
+
+	waiting := make([]int, len(todo))
+	dependents := make([][]int, len(todo))
+	var ready []int
+	for i, j := range todo {
+		waiting[i] = len(j.after)
+		for _, a := range j.after {
+			dependents[a] = append(dependents[a], i)
+		}
+		if waiting[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+
+	// Each generator logs to buffers of its own, which are copied to l in
+	// order, as soon as the generators before it are done.
+	var mu sync.Mutex
+	hooks := serialHooks(h, &mu)
+	outs := make([]*bufferedLog, len(todo))
+	done := make([]bool, len(todo))
+	next := 0
+	flush := func() {
+		for next < len(todo) && done[next] {
+			if outs[next] != nil {
+				outs[next].copyTo(l)
+				outs[next] = nil
+			}
+			next++
+		}
+	}
+	// skip marks the jobs that run after a failed one as not run.
+	var skip func(i int)
+	skip = func(i int) {
+		for _, d := range dependents[i] {
+			if !done[d] {
+				errs[d], done[d] = errNotRun, true
+				l.Warn("Not running the generator of %s, which runs after %s, since it failed.", relSlash(dir, todo[d].path), relSlash(dir, todo[i].path))
+				skip(d)
+			}
+		}
+	}
+
+	finished := make(chan int)
+	running := 0
+	for {
+		if ctx.Err() != nil {
+			for _, i := range ready {
+				errs[i], done[i] = errNotRun, true
+			}
+			ready = nil
+		}
+		for running < jobs && len(ready) > 0 {
+			i := ready[0]
+			ready = ready[1:]
+			running++
+			go func(i int) {
+				b := newBufferedLog(l)
+				err := runOne(ctx, dir, todo[i], force, verbose, timeout, inc, b.Logger, hooks)
+				mu.Lock()
+				errs[i], outs[i] = err, b
+				mu.Unlock()
+				finished <- i
+			}(i)
+		}
+		if running == 0 {
+			break
+		}
+		i := <-finished
+		running--
+		mu.Lock()
+		done[i] = true
+		if errs[i] != nil && errs[i] != errUpToDate {
+			skip(i)
+		} else {
+			for _, d := range dependents[i] {
+				if waiting[d]--; waiting[d] == 0 && !done[d] {
+					ready = append(ready, d)
+				}
+			}
+			sort.Ints(ready)
+		}
+		flush()
+		mu.Unlock()
+	}
+	return errs
+}
+
+// serialHooks returns hooks that call those of h while holding mu.
+func serialHooks(h *Hooks, mu *sync.Mutex) *Hooks {
+	if h == nil {
+		return nil
+	}
+	s := &Hooks{}
+	if h.Started != nil {
+		s.Started = func(file, command string) {
+			mu.Lock()
+			defer mu.Unlock()
+			h.Started(file, command)
+		}
+	}
+	if h.Finished != nil {
+		s.Finished = func(file, command string, d time.Duration, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			h.Finished(file, command, d, err)
+		}
+	}
+	return s
+}
+
+// bufferedLog is a log.Logger that holds the messages and output of a
+// generator that runs in parallel with others.
+type bufferedLog struct {
+	*log.Logger
+	stdout, stderr bytes.Buffer
+}
+
+// newBufferedLog returns a bufferedLog that debugs if l does.
+func newBufferedLog(l *log.Logger) *bufferedLog {
+	b := &bufferedLog{}
+	debugging := log.IsDebugging
+	if l != nil {
+		debugging = l.Debugging
+	}
+	b.Logger = &log.Logger{Stdout: &b.stdout, Stderr: &b.stderr, Debugging: debugging}
+	return b
+}
+
+// copyTo writes what b holds to l: the messages, and then the output.
+func (b *bufferedLog) copyTo(l *log.Logger) {
+	stdout, stderr := log.Stdout, log.Stderr
+	if l != nil {
+		stdout, stderr = orDiscard(l.Stdout), orDiscard(l.Stderr)
+	}
+	stderr.Write(b.stderr.Bytes())
+	stdout.Write(b.stdout.Bytes())
+}
+
+func orDiscard(w io.Writer) io.Writer {
+	if w == nil {
+		return ioutil.Discard
+	}
+	return w
+}
@@ -0,0 +1,61 @@
+package generator
+
+// Step is a generator that Walk would run, as WalkPlan returns it.
+type Step struct {
+	// File is the file that declares the generator, relative to the chart,
+	// with slashes.
+	File string `json:"file"`
+	// Directive is the command of the directive, before expansion.
+	Directive string `json:"directive"`
+	// Raw is set for a 'helm:generate:raw' directive, whose command is not
+	// expanded.
+	Raw bool `json:"raw,omitempty"`
+	// Command is the command that runs, after expansion.
+	Command string `json:"command"`
+	// After are the files of the generators that it runs after, relative to
+	// the chart, with slashes.
+	After []string `json:"after,omitempty"`
+	// Outputs are the files that it declares with one of OutputFlags, and
+	// which Walk records in the StateFile. Like Walk, it leaves out the file
+	// of the generator itself.
+	Outputs []string `json:"outputs,omitempty"`
+	// Undefined are the variables of the directive that are not defined, and
+	// so expand to the empty string.
+	Undefined []string `json:"undefined,omitempty"`
+}
+
+// WalkPlan returns the generators that Walk, given the same arguments, would
+// run, in the order that it would run them. Nothing is executed.
+//
+// The errors are those that Walk would stop with before running anything,
+// such as an *UndefinedVariableError if strict is true, or a *CycleError.
+func WalkPlan(dir string, exclude []string, strict bool, env map[string]string) ([]*Step, error) {
+	todo, err := find(dir, exclude, strict, env, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	plan := make([]*Step, len(todo))
+	for i, j := range todo {
+		s := &Step{
+			File:      relSlash(dir, j.path),
+			Directive: j.directive,
+			Raw:       j.raw,
+			Command:   j.line,
+		}
+		for _, out := range Outputs(dir, j.line) {
+			if out != s.File {
+				s.Outputs = append(s.Outputs, out)
+			}
+		}
+		for _, a := range j.after {
+			s.After = append(s.After, relSlash(dir, todo[a].path))
+		}
+		if !j.raw {
+			for _, u := range undefinedVars(j.directive, j.path, generateVars(env, dir, j.path, j.directive)) {
+				s.Undefined = append(s.Undefined, u.Name)
+			}
+		}
+		plan[i] = s
+	}
+	return plan, nil
+}
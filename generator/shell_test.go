@@ -0,0 +1,82 @@
+package generator
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/helm/helm-classic/log"
+)
+
+func TestSplitArgs(t *testing.T) {
+	for command, expected := range map[string][]string{
+		"sed -i -e s|a|b| pod.yaml":              {"sed", "-i", "-e", "s|a|b|", "pod.yaml"},
+		"  echo\ta  \n":                          {"echo", "a"},
+		`echo 'a  b' "c d"`:                      {"echo", "a  b", "c d"},
+		`"C:\Program Files\gen.exe" -o pod.yaml`: {`C:\Program Files\gen.exe`, "-o", "pod.yaml"},
+		`C:\tools\gen.exe s/\./x/`:               {`C:\tools\gen.exe`, `s/\./x/`},
+		`"/charts/my chart"/gen.sh --name='a b'`: {"/charts/my chart/gen.sh", "--name=a b"},
+		`echo "say \"hi\"" 'it''s' ""`:           {"echo", `say "hi"`, "its", ""},
+		`echo "'" '"'`:                           {"echo", "'", `"`},
+		"":                                       nil,
+	} {
+		args, err := splitArgs(command)
+		if err != nil || !reflect.DeepEqual(args, expected) {
+			t.Errorf("Expected %q to be %q, got %q, %v", command, expected, args, err)
+		}
+	}
+
+	for _, command := range []string{`echo 'a`, `echo "a`, `echo "a\"`} {
+		if _, err := splitArgs(command); err == nil {
+			t.Errorf("Expected %q to be unterminated", command)
+		}
+		if _, _, err := commandArgs(command, false); err == nil {
+			t.Errorf("Expected %q not to run", command)
+		}
+	}
+}
+
+func TestPlatformCommand(t *testing.T) {
+	defer func(g string) { goos = g }(goos)
+
+	goos = "linux"
+	if name, args := platformCommand("echo", []string{"a"}); name != "echo" || !reflect.DeepEqual(args, []string{"a"}) {
+		t.Errorf("Expected echo to run as it is, got %s %q", name, args)
+	}
+
+	goos = "windows"
+	for _, c := range []struct {
+		name     string
+		expected []string
+	}{
+		{"echo", []string{"cmd", "/c", "echo", "a"}},
+		{"COPY", []string{"cmd", "/c", "COPY", "a"}},
+		{`C:\charts\gen.bat`, []string{"cmd", "/c", `C:\charts\gen.bat`, "a"}},
+		{"gen.CMD", []string{"cmd", "/c", "gen.CMD", "a"}},
+		{"gen.ps1", []string{"powershell", "-NoProfile", "-ExecutionPolicy", "Bypass", "-File", "gen.ps1", "a"}},
+		{"sed", []string{"sed", "a"}},
+		{"echo.exe", []string{"echo.exe", "a"}},
+		{"helmc", []string{"helmc", "a"}},
+	} {
+		name, args := platformCommand(c.name, []string{"a"})
+		if got := append([]string{name}, args...); !reflect.DeepEqual(got, c.expected) {
+			t.Errorf("Expected %s to run as %q, got %q", c.name, c.expected, got)
+		}
+	}
+}
+
+func TestWalkQuoted(t *testing.T) {
+	dir := orderChart(t, map[string]string{
+		"a.yaml": "#helm:generate echo 'a  b' \"$HELM_GENERATE_DIR\"\n",
+	})
+	defer os.RemoveAll(dir)
+	var stdout bytes.Buffer
+	if _, err := Walk(context.Background(), dir, Options{Verbose: true, Log: &log.Logger{Stdout: &stdout}, Policy: allowAll}); err != nil {
+		t.Fatalf("Failed to walk: %s", err)
+	}
+	if expected := "a  b " + dir + "\n"; stdout.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, stdout.String())
+	}
+}
@@ -0,0 +1,197 @@
+package generator
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// StateFile is the file of a chart in which Walk records the files that its
+// generators wrote. Only generators that declare an output, with one of
+// OutputFlags, are recorded, and a chart without any has no state file.
+const StateFile = ".helm-generate-state"
+
+const stateHeader = `# The files that 'helmc generate' wrote, from the -o, --out, or --output of
+# a generator. Each line is the file, the file of its generator, and the
+# SHA-256 of what the generator wrote. 'helmc generate --clean' deletes them,
+# and 'helmc lint' warns about the ones that were edited since.
+`
+
+// Output is a file that a generator wrote.
+type Output struct {
+	// Path is the file, relative to the chart, with slashes.
+	Path string
+	// Source is the file that declares the generator, relative to the chart,
+	// with slashes.
+	Source string
+	// Digest is the SHA-256 of the file when the generator wrote it, as
+	// "sha256:<hex>".
+	Digest string
+}
+
+// State is the content of a StateFile: the outputs of the generators of a
+// chart, by path.
+type State map[string]*Output
+
+// LoadState reads the StateFile of the chart in dir. A chart without one has
+// an empty state.
+func LoadState(dir string) (State, error) {
+	s := State{}
+	data, err := ioutil.ReadFile(filepath.Join(dir, StateFile))
+	if os.IsNotExist(err) {
+		return s, nil
+	} else if err != nil {
+		return nil, err
+	}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; sc.Scan(); n++ {
+		line := sc.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		f := strings.Split(line, "\t")
+		if len(f) != 3 {
+			return nil, fmt.Errorf("%s:%d: expected a file, the file of its generator, and a digest, separated by tabs", StateFile, n)
+		}
+		s[f[0]] = &Output{Path: f[0], Source: f[1], Digest: f[2]}
+	}
+	return s, sc.Err()
+}
+
+// Save writes s to the StateFile of the chart in dir, one output per line,
+// sorted by path, so that the file can be kept in version control without
+// churn. An empty state removes the file.
+func (s State) Save(dir string) error {
+	file := filepath.Join(dir, StateFile)
+	if len(s) == 0 {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	var b bytes.Buffer
+	b.WriteString(stateHeader)
+	for _, o := range s.Sorted() {
+		fmt.Fprintf(&b, "%s\t%s\t%s\n", o.Path, o.Source, o.Digest)
+	}
+	return ioutil.WriteFile(file, b.Bytes(), 0644)
+}
+
+// Sorted returns the outputs of s, sorted by path.
+func (s State) Sorted() []*Output {
+	res := make([]*Output, 0, len(s))
+	for _, o := range s {
+		res = append(res, o)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Path < res[j].Path })
+	return res
+}
+
+// record records the outputs that the generator of source wrote when it ran
+// the expanded command line. A declared output that the command did not
+// write is not recorded, and neither is the file of the generator itself.
+func (s State) record(dir, source, line string) {
+	src := relSlash(dir, source)
+	for _, out := range Outputs(dir, line) {
+		if out == src {
+			continue
+		}
+		digest, err := FileDigest(filepath.Join(dir, filepath.FromSlash(out)))
+		if err != nil {
+			continue
+		}
+		s[out] = &Output{Path: out, Source: src, Digest: digest}
+	}
+}
+
+// Edited returns true if the file of o differs from what its generator
+// wrote. A file that is gone is not edited.
+func (o *Output) Edited(dir string) bool {
+	digest, err := FileDigest(filepath.Join(dir, filepath.FromSlash(o.Path)))
+	return err == nil && digest != o.Digest
+}
+
+// Outputs returns the files of the chart in dir that an expanded command
+// writes: the values of its OutputFlags, relative to dir, with slashes.
+// Files outside of the chart are left out.
+func Outputs(dir, line string) []string {
+	var res []string
+	args := fields(line)
+	for i := 0; i < len(args); i++ {
+		name, value := args[i], ""
+		if eq := strings.Index(name, "="); eq > 0 && strings.HasPrefix(name, "-") {
+			name, value = name[:eq], name[eq+1:]
+		}
+		output := false
+		for _, f := range OutputFlags {
+			output = output || name == f
+		}
+		if !output {
+			continue
+		}
+		if value == "" && name == args[i] && i+1 < len(args) {
+			i++
+			value = args[i]
+		}
+		if value == "" {
+			continue
+		}
+		if !filepath.IsAbs(value) {
+			value = filepath.Join(dir, value)
+		}
+		if rel := relSlash(dir, value); rel != "" && rel != "." && !strings.HasPrefix(rel, "../") {
+			res = append(res, rel)
+		}
+	}
+	return res
+}
+
+// Declared returns the outputs that the generators of the chart in dir
+// declare, mapped to the file of their generator, relative to dir, with
+// slashes. Nothing is run. env is the environment that Walk would be given.
+//
+// A file that declares itself is left out: it is usually an output that
+// kept the directive of its template.
+func Declared(dir string, exclude []string, env map[string]string) (map[string]string, error) {
+	res := map[string]string{}
+	err := walk(dir, exclude, func(path, line string, raw bool) error {
+		if !raw {
+			line, _ = expand(line, path, generateVars(env, dir, path, line), false)
+		}
+		src := relSlash(dir, path)
+		for _, out := range Outputs(dir, line) {
+			if out != src {
+				res[out] = src
+			}
+		}
+		return nil
+	})
+	return res, err
+}
+
+// FileDigest returns the SHA-256 of a file, as "sha256:<hex>".
+func FileDigest(file string) (string, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// relSlash returns path relative to dir, with slashes, or "" if it cannot
+// be made relative.
+func relSlash(dir, path string) string {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return ""
+	}
+	return filepath.ToSlash(rel)
+}
@@ -0,0 +1,90 @@
+package generator
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOutputs(t *testing.T) {
+	dir := filepath.FromSlash("/charts/web")
+	for line, expected := range map[string][]string{
+		"helmc tpl -o manifests/pod.yaml -d values.toml tpl/pod.yaml": {"manifests/pod.yaml"},
+		"helmc tpl --out=manifests/svc.yaml tpl/svc.yaml":             {"manifests/svc.yaml"},
+		"gen --output " + filepath.Join(dir, "manifests", "a.yaml"):   {"manifests/a.yaml"},
+		"gen --output ../other/a.yaml":                                nil,
+		"sed -i -e s|a|b| manifests/pod.yaml":                         nil,
+		"gen -o":                                                      nil,
+	} {
+		if actual := Outputs(dir, line); !reflect.DeepEqual(actual, expected) {
+			t.Errorf("Expected the outputs of %q to be %v, got %v", line, expected, actual)
+		}
+	}
+}
+
+func TestState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "helm-generate-state-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := LoadState(dir)
+	if err != nil || len(s) != 0 {
+		t.Fatalf("Expected an empty state without a state file, got %v, %v", s, err)
+	}
+	s["manifests/svc.yaml"] = &Output{Path: "manifests/svc.yaml", Source: "tpl/svc.yaml", Digest: "sha256:2"}
+	s["manifests/pod.yaml"] = &Output{Path: "manifests/pod.yaml", Source: "tpl/pod.yaml", Digest: "sha256:1"}
+	if err := s.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := ioutil.ReadFile(filepath.Join(dir, StateFile))
+	if !strings.HasSuffix(string(data), "\nmanifests/pod.yaml\ttpl/pod.yaml\tsha256:1\nmanifests/svc.yaml\ttpl/svc.yaml\tsha256:2\n") {
+		t.Errorf("Expected the outputs one per line, sorted, got\n%s", data)
+	}
+
+	loaded, err := LoadState(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, s) {
+		t.Errorf("Expected %v, got %v", s, loaded)
+	}
+
+	if err := (State{}).Save(dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, StateFile)); !os.IsNotExist(err) {
+		t.Errorf("Expected an empty state to remove the state file, got %v", err)
+	}
+
+	ioutil.WriteFile(filepath.Join(dir, StateFile), []byte("manifests/pod.yaml tpl/pod.yaml\n"), 0644)
+	if _, err := LoadState(dir); err == nil || !strings.Contains(err.Error(), StateFile+":1:") {
+		t.Errorf("Expected the line of a bad entry, got %v", err)
+	}
+}
+
+func TestDeclared(t *testing.T) {
+	dir, err := ioutil.TempDir("", "helm-generate-declared-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.MkdirAll(filepath.Join(dir, "tpl"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "tpl", "pod.yaml"), []byte("#helm:generate helmc tpl -o $OUT/pod.yaml $HELM_GENERATE_FILE\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "tpl", "sed.yaml"), []byte("#helm:generate sed -i -e s|a|b| manifests/pod.yaml\n"), 0644)
+	// An output that kept the directive of its template declares itself.
+	ioutil.WriteFile(filepath.Join(dir, "tpl", "self.yaml"), []byte("#helm:generate helmc tpl -o tpl/self.yaml tpl/self.yaml\n"), 0644)
+
+	declared, err := Declared(dir, nil, map[string]string{"OUT": "manifests"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"manifests/pod.yaml": "tpl/pod.yaml"}
+	if !reflect.DeepEqual(declared, expected) {
+		t.Errorf("Expected %v, got %v", expected, declared)
+	}
+}
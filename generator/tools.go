@@ -0,0 +1,102 @@
+package generator
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/helm/helm-classic/log"
+)
+
+// Tool is a program that the generators of a chart run.
+type Tool struct {
+	// Name is the program, as the generators name it once they are
+	// expanded.
+	Name string `json:"name"`
+	// Path is where the program was found, or "" if it was not.
+	Path string `json:"path,omitempty"`
+	// Files are the files whose generators run it, relative to the chart.
+	Files []string `json:"files"`
+}
+
+// Tools returns the programs that Walk, given the same arguments, would run
+// for the generators of the chart dir, sorted by name, and where each is
+// found, without running any of them.
+//
+// A program is found as Walk runs it: on $PATH, or if its name has a
+// separator, relative to the chart. The templates that helmc renders itself
+// are not programs; see Template.
+func Tools(dir string, exclude []string, env map[string]string) ([]*Tool, error) {
+	quiet := &log.Logger{Stdout: ioutil.Discard, Stderr: ioutil.Discard}
+	todo, err := find(dir, exclude, false, env, quiet, nil)
+	if err != nil {
+		return nil, err
+	}
+	byName, seen := map[string]*Tool{}, map[string]bool{}
+	for _, j := range todo {
+		if _, ok := templateJob(j.line, dir, false); ok && Template != nil {
+			continue
+		}
+		name, _, err := commandArgs(j.line, false)
+		if err != nil {
+			return nil, err
+		}
+		t, ok := byName[name]
+		if !ok {
+			t = &Tool{Name: name, Path: lookTool(dir, name)}
+			byName[name] = t
+		}
+		if f := relSlash(dir, j.path); !seen[name+"\x00"+f] {
+			seen[name+"\x00"+f] = true
+			t.Files = append(t.Files, f)
+		}
+	}
+	tools := make([]*Tool, 0, len(byName))
+	for _, t := range byName {
+		tools = append(tools, t)
+	}
+	sort.Slice(tools, func(i, j int) bool { return tools[i].Name < tools[j].Name })
+	return tools, nil
+}
+
+// lookTool returns where the program name of a generator of the chart dir
+// is, or "" if it is not found.
+func lookTool(dir, name string) string {
+	if !strings.ContainsAny(name, `/\`) {
+		p, err := exec.LookPath(name)
+		if err != nil {
+			return ""
+		}
+		return p
+	}
+	p := name
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(dir, p)
+	}
+	fi, err := os.Stat(p)
+	if err != nil || fi.IsDir() || goos != "windows" && fi.Mode()&0111 == 0 {
+		return ""
+	}
+	return p
+}
+
+// Templates returns the generators of the chart dir that render a template
+// of helmc itself, given the same arguments as Tools, in the order that they
+// are found. Each is returned whether or not Template is set.
+func Templates(dir string, exclude []string, env map[string]string) ([]*TemplateJob, error) {
+	quiet := &log.Logger{Stdout: ioutil.Discard, Stderr: ioutil.Discard}
+	todo, err := find(dir, exclude, false, env, quiet, nil)
+	if err != nil {
+		return nil, err
+	}
+	jobs := []*TemplateJob{}
+	for _, j := range todo {
+		if t, ok := templateJob(j.line, dir, false); ok {
+			jobs = append(jobs, t)
+		}
+	}
+	return jobs, nil
+}
@@ -0,0 +1,47 @@
+package generator
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTools(t *testing.T) {
+	dir, err := ioutil.TempDir("", "helmc-generator")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "a.yaml"), []byte("#helm:generate echo a\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "b.yaml"), []byte("#helm:generate echo b\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "c.yaml"), []byte("#helm:generate $TOOL -o c\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "d.yaml"), []byte("#helm:generate ./gen.sh\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "gen.sh"), []byte("#!/bin/sh\n"), 0644)
+
+	env := map[string]string{"TOOL": "helmc-no-such-tool"}
+	tools, err := Tools(dir, []string{"gen.sh"}, env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tools) != 3 {
+		t.Fatalf("Expected 3 programs, got %d", len(tools))
+	}
+	if tools[0].Name != "./gen.sh" || tools[0].Path != "" {
+		t.Errorf("Expected ./gen.sh not to be found until it is executable, got %+v", tools[0])
+	}
+	if tools[1].Name != "echo" || tools[1].Path == "" || len(tools[1].Files) != 2 || tools[1].Files[0] != "a.yaml" {
+		t.Errorf("Expected echo to be found for a.yaml and b.yaml, got %+v", tools[1])
+	}
+	if tools[2].Name != "helmc-no-such-tool" || tools[2].Path != "" || len(tools[2].Files) != 1 {
+		t.Errorf("Expected the expanded program not to be found, got %+v", tools[2])
+	}
+
+	os.Chmod(filepath.Join(dir, "gen.sh"), 0755)
+	if tools, err = Tools(dir, []string{"gen.sh"}, env); err != nil {
+		t.Fatal(err)
+	}
+	if tools[0].Path != filepath.Join(dir, "gen.sh") {
+		t.Errorf("Expected ./gen.sh to be found relative to the chart, got %q", tools[0].Path)
+	}
+}
@@ -0,0 +1,333 @@
+package generator
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/helm/helm-classic/log"
+)
+
+// WatchInterval is how often a Watcher looks for files that changed.
+var WatchInterval = 250 * time.Millisecond
+
+// WatchSettle is how long the files must stay unchanged after a change before
+// a Watcher runs the generators, so that a burst of writes, such as an editor
+// saving a file, triggers one run.
+var WatchSettle = 500 * time.Millisecond
+
+// OutputFlags are the flags of a generator's command whose value is a file
+// that it writes, such as the -o of 'helmc tpl'. The file is not one that the
+// generator reads.
+var OutputFlags = []string{"-o", "--out", "--output"}
+
+// Watcher runs the generators of a chart directory again when the files that
+// they read change.
+//
+// Its fields are the Options of Walk, which it runs without DryRun or
+// Incremental. Files are watched where Walk looks
+// for generators: excluded files, and directories whose names start with '.'
+// or '_', are not.
+type Watcher struct {
+	Dir     string
+	Exclude []string
+	Force   bool
+	Strict  bool
+	Verbose bool
+	Jobs    int
+	Timeout time.Duration
+	Policy  *Policy
+	Env     map[string]string
+	Log     *log.Logger
+	Hooks   *Hooks
+	// Ignored, if it is not nil, returns true for the files and directories
+	// whose changes are not watched, such as those of the chart's
+	// .helmignore. rel is relative to Dir, and slash-separated.
+	Ignored func(rel string, isDir bool) bool
+	// Lock, if it is not nil, is called before each run. The function that
+	// it returns is called after the run.
+	Lock func() (func(), error)
+
+	mu      sync.Mutex
+	summary WatchSummary
+}
+
+// WatchSummary counts what a Watcher did.
+type WatchSummary struct {
+	// Runs is the number of runs, the first one included.
+	Runs int
+	// Failed is the number of runs that failed.
+	Failed int
+	// Generators is the number of generators that were run.
+	Generators int
+}
+
+func (s WatchSummary) String() string {
+	return fmt.Sprintf("%d runs (%d failed), %d generators run", s.Runs, s.Failed, s.Generators)
+}
+
+// Summary returns what the Watcher has done so far. It may be called while
+// Watch runs.
+func (w *Watcher) Summary() WatchSummary {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.summary
+}
+
+// Watch runs every generator, as Walk does, and then, until ctx is done, runs
+// again the generators whose files change.
+//
+// The files of a generator are the file that declares it, and the files of
+// the chart that its command names, such as the values of 'helmc tpl -d'.
+// The value of an OutputFlags flag is not one of them. Files that change
+// while the generators run are taken to be their output, and do not trigger
+// another run, so that a generator that writes a file it reads does not run
+// in a loop.
+//
+// A run that fails is logged, and watching goes on. An error is returned only
+// if the chart directory cannot be read.
+func (w *Watcher) Watch(ctx context.Context) error {
+	w.Dir = filepath.Clean(w.Dir)
+	snap, err := w.run(ctx, nil)
+	if err != nil {
+		return err
+	}
+	w.Log.Info("Watching %s for changes. Press Ctrl-C to stop.", w.Dir)
+
+	tick := time.NewTicker(WatchInterval)
+	defer tick.Stop()
+	changed := map[string]bool{}
+	var last time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-tick.C:
+		}
+
+		cur, err := w.snapshot()
+		if err != nil {
+			return err
+		}
+		if files := cur.changes(snap); len(files) > 0 {
+			for _, f := range files {
+				changed[f] = true
+			}
+			snap, last = cur, time.Now()
+			continue
+		}
+		if len(changed) == 0 || time.Since(last) < WatchSettle {
+			continue
+		}
+
+		if snap, err = w.run(ctx, changed); err != nil {
+			return err
+		}
+		changed = map[string]bool{}
+	}
+}
+
+// run runs the generators that read one of the changed files, or all of them
+// if changed is nil, and counts the run. It returns the snapshot of the files
+// after the run. A run that ctx cuts short is not counted.
+func (w *Watcher) run(ctx context.Context, changed map[string]bool) (snapshot, error) {
+	var match func(path, line string) bool
+	if changed != nil {
+		w.Log.Info("Changed: %s", strings.Join(w.rel(changed), ", "))
+		match = func(path, line string) bool {
+			return changed[path] || reads(w.Dir, line, changed)
+		}
+	}
+
+	counts, err := w.runLocked(ctx, match)
+	count := counts.Total()
+	snap, serr := w.snapshot()
+	if serr != nil || ctx.Err() != nil {
+		return snap, serr
+	}
+	if err == nil && count == 0 && changed != nil {
+		w.Log.Info("No generator reads the files that changed.")
+		return snap, nil
+	}
+
+	w.mu.Lock()
+	w.summary.Runs++
+	w.summary.Generators += count
+	if err != nil {
+		w.summary.Failed++
+	}
+	w.mu.Unlock()
+
+	if err != nil {
+		w.Log.Err("Failed to complete generation: %s", err)
+	} else {
+		w.Log.Info("Ran %d generators.", count)
+	}
+	return snap, nil
+}
+
+// RunChanged runs once the generators that read one of files, as Watch does
+// when they change, and returns how many ran. files are absolute, or
+// relative to Dir.
+func (w *Watcher) RunChanged(ctx context.Context, files []string) (Counts, error) {
+	w.Dir = filepath.Clean(w.Dir)
+	changed := map[string]bool{}
+	for _, f := range files {
+		if !filepath.IsAbs(f) {
+			f = filepath.Join(w.Dir, f)
+		}
+		changed[filepath.Clean(f)] = true
+	}
+	return w.runLocked(ctx, func(path, line string) bool {
+		return changed[path] || reads(w.Dir, line, changed)
+	})
+}
+
+func (w *Watcher) runLocked(ctx context.Context, match func(path, line string) bool) (Counts, error) {
+	if w.Lock != nil {
+		unlock, err := w.Lock()
+		if err != nil {
+			return nil, err
+		}
+		defer unlock()
+	}
+	return run(ctx, w.Dir, Options{
+		Exclude: w.Exclude,
+		Force:   w.Force,
+		Strict:  w.Strict,
+		Verbose: w.Verbose,
+		Jobs:    w.Jobs,
+		Timeout: w.Timeout,
+		Policy:  w.Policy,
+		Env:     w.Env,
+		Log:     w.Log,
+		Hooks:   w.Hooks,
+	}, match)
+}
+
+// rel returns the files, relative to the chart, in order.
+func (w *Watcher) rel(files map[string]bool) []string {
+	res := make([]string, 0, len(files))
+	for f := range files {
+		if r, err := filepath.Rel(w.Dir, f); err == nil {
+			f = r
+		}
+		res = append(res, filepath.ToSlash(f))
+	}
+	sort.Strings(res)
+	return res
+}
+
+// reads returns true if one of the argPaths of the command, or of the files
+// its template reads, is one of files.
+func reads(dir, line string, files map[string]bool) bool {
+	for _, p := range append(argPaths(dir, line), templateReads(dir, line)...) {
+		if files[p] {
+			return true
+		}
+	}
+	return false
+}
+
+// argPaths returns the arguments of the command, and the values of its
+// --flag=value arguments, as clean paths. Relative arguments are relative to
+// the chart, where the command runs. The values of OutputFlags are skipped.
+func argPaths(dir, line string) []string {
+	var res []string
+	args := fields(line)
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if strings.HasPrefix(arg, "-") {
+			name := strings.SplitN(arg, "=", 2)[0]
+			output := false
+			for _, f := range OutputFlags {
+				output = output || name == f
+			}
+			switch {
+			case output && name == arg:
+				i++
+				continue
+			case output:
+				continue
+			case name != arg:
+				arg = arg[len(name)+1:]
+			}
+		}
+		if arg == "" {
+			continue
+		}
+		if !filepath.IsAbs(arg) {
+			arg = filepath.Join(dir, arg)
+		}
+		res = append(res, filepath.Clean(arg))
+	}
+	return res
+}
+
+type fileState struct {
+	mod  time.Time
+	size int64
+}
+
+// snapshot is the state of the watched files of a chart, by path.
+type snapshot map[string]fileState
+
+// snapshot returns the state of the files that the Watcher watches.
+func (w *Watcher) snapshot() (snapshot, error) {
+	excludes := excludeMap(w.Dir, w.Exclude)
+	snap := snapshot{}
+	err := filepath.Walk(w.Dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			// A file that an editor replaces may be gone by the time it is read.
+			if os.IsNotExist(err) && path != w.Dir {
+				return nil
+			}
+			return err
+		}
+		if path != w.Dir && w.ignored(path, fi.IsDir(), excludes) {
+			if fi.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !fi.IsDir() {
+			snap[path] = fileState{mod: fi.ModTime(), size: fi.Size()}
+		}
+		return nil
+	})
+	return snap, err
+}
+
+func (w *Watcher) ignored(path string, isDir bool, excludes map[string]string) bool {
+	if exclusion(path, isDir, excludes) != "" {
+		return true
+	}
+	if w.Ignored == nil {
+		return false
+	}
+	rel, err := filepath.Rel(w.Dir, path)
+	return err == nil && w.Ignored(filepath.ToSlash(rel), isDir)
+}
+
+// changes returns the files that were added, changed, or removed since old,
+// in order.
+func (s snapshot) changes(old snapshot) []string {
+	var files []string
+	for f, st := range s {
+		if o, ok := old[f]; !ok || !o.mod.Equal(st.mod) || o.size != st.size {
+			files = append(files, f)
+		}
+	}
+	for f := range old {
+		if _, ok := s[f]; !ok {
+			files = append(files, f)
+		}
+	}
+	sort.Strings(files)
+	return files
+}
@@ -0,0 +1,107 @@
+package generator
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/helm/helm-classic/log"
+)
+
+func TestReads(t *testing.T) {
+	files := map[string]bool{"/chart/tpl/values.toml": true, "/chart/manifests/pod.yaml": true}
+	for line, expect := range map[string]bool{
+		"helmc tpl -d tpl/values.toml -o manifests/pod.yaml tpl/pod.yaml": true,
+		"helmc tpl --values=tpl/values.toml tpl/pod.yaml":                  true,
+		"helmc tpl --out=manifests/pod.yaml tpl/pod.yaml":                  false,
+		"helmc tpl -o manifests/pod.yaml tpl/pod.yaml":                     false,
+		"sed -i -e s|a|b| /chart/manifests/pod.yaml":                       true,
+	} {
+		if actual := reads("/chart", line, files); actual != expect {
+			t.Errorf("Expected reads(%q) to be %t", line, expect)
+		}
+	}
+}
+
+func TestWatch(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("The test generator is a shell script")
+	}
+	defer func(i, s time.Duration) { WatchInterval, WatchSettle = i, s }(WatchInterval, WatchSettle)
+	WatchInterval, WatchSettle = 10*time.Millisecond, 50*time.Millisecond
+
+	tmp, err := ioutil.TempDir("", "helmc-watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	dir := filepath.Join(tmp, "chart")
+	runs := filepath.Join(tmp, "runs")
+	os.MkdirAll(filepath.Join(dir, "_bin"), 0755)
+	// The generator records that it ran, and writes its output.
+	ioutil.WriteFile(filepath.Join(dir, "_bin", "gen.sh"), []byte("#!/bin/sh\necho $1 >> $RUNS\n[ $1 != fail ] && echo $1 > $HELM_GENERATE_DIR/$1.out\n"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "values.txt"), []byte("one\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "a.yaml"), []byte("#helm:generate $HELM_GENERATE_DIR/_bin/gen.sh a -d values.txt -o a.out\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "b.yaml"), []byte("#helm:generate $HELM_GENERATE_DIR/_bin/gen.sh b\n"), 0644)
+
+	var b bytes.Buffer
+	w := &Watcher{
+		Dir:     dir,
+		Env:     map[string]string{"RUNS": runs},
+		Policy:  allowAll,
+		Log:     &log.Logger{Stdout: ioutil.Discard, Stderr: &b},
+		Ignored: func(rel string, isDir bool) bool { return strings.HasSuffix(rel, ".swp") },
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- w.Watch(ctx) }()
+
+	// expectRuns waits for the Watcher to finish a run, and checks which
+	// generators have run so far.
+	expectRuns := func(n int, expect string) {
+		t.Helper()
+		for start := time.Now(); w.Summary().Runs < n; time.Sleep(10 * time.Millisecond) {
+			if time.Since(start) > 5*time.Second {
+				t.Fatalf("Expected %d runs, got %s", n, w.Summary())
+			}
+		}
+		got, _ := ioutil.ReadFile(runs)
+		if actual := strings.Join(strings.Fields(string(got)), " "); actual != expect {
+			t.Fatalf("Expected the generators %q to have run, got %q", expect, actual)
+		}
+	}
+	expectRuns(1, "a b")
+
+	// A file that the command names runs its generator, and only that one.
+	ioutil.WriteFile(filepath.Join(dir, "values.txt"), []byte("two\n"), 0644)
+	expectRuns(2, "a b a")
+
+	// Ignored files, and the outputs of the generators, run nothing.
+	ioutil.WriteFile(filepath.Join(dir, ".values.txt.swp"), []byte("x"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "a.out"), []byte("edited\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "b.yaml"), []byte("#helm:generate $HELM_GENERATE_DIR/_bin/gen.sh b\n# changed\n"), 0644)
+	expectRuns(3, "a b a b")
+
+	// A run that fails does not stop the watching.
+	ioutil.WriteFile(filepath.Join(dir, "b.yaml"), []byte("#helm:generate $HELM_GENERATE_DIR/_bin/gen.sh fail\n"), 0644)
+	expectRuns(4, "a b a b fail")
+	ioutil.WriteFile(filepath.Join(dir, "b.yaml"), []byte("#helm:generate $HELM_GENERATE_DIR/_bin/gen.sh b\n"), 0644)
+	expectRuns(5, "a b a b fail b")
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if s := w.Summary(); s != (WatchSummary{Runs: 5, Failed: 1, Generators: 6}) {
+		t.Errorf("Unexpected summary: %s", s)
+	}
+	if out := b.String(); !strings.Contains(out, "Changed: values.txt") || !strings.Contains(out, "Failed to complete generation") {
+		t.Errorf("Unexpected output: %s", out)
+	}
+}
@@ -1,7 +1,13 @@
 package main
 
-import "github.com/helm/helm-classic/cli"
+import (
+	"os"
+
+	"github.com/helm/helm-classic/cli"
+)
 
 func main() {
-	cli.Cli().RunAndExitOnError()
+	// An interrupted command must not exit before it has cleaned up, so
+	// cli.Exit waits for it.
+	cli.Exit(cli.Cli().Run(os.Args))
 }
@@ -0,0 +1,454 @@
+// Package helmpath resolves the Helm Classic home directory and the paths within it.
+//
+// The home directory is chosen, in order of precedence, from the --home flag,
+// the $HELMC_HOME environment variable, and the platform default. Every
+// command, and every generator or plugin that Helm Classic runs, sees the
+// same home.
+//
+// The configuration file, the repository cache, and the workspace are in the
+// home unless its Layout puts them elsewhere. Every path of Helm Classic is
+// resolved here, so that the rest of it never joins the parts of a home
+// itself.
+package helmpath
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// EnvVar is the environment variable that overrides the default home.
+const EnvVar = "HELMC_HOME"
+
+// LegacyEnvVar is the environment variable that the original Helm used.
+//
+// It is set for generators and plugins, which may have been written for the
+// original Helm, but it is never read.
+const LegacyEnvVar = "HELM_HOME"
+
+// The environment variables that move a part of the home elsewhere. Each
+// wins over the key of the configuration file that moves the same part.
+const (
+	// ConfigEnvVar is the configuration file.
+	ConfigEnvVar = "HELMC_CONFIG"
+	// CacheEnvVar is the directory of the repository cache.
+	CacheEnvVar = "HELMC_CACHE"
+	// WorkspaceEnvVar is the workspace directory.
+	WorkspaceEnvVar = "HELMC_WORKSPACE"
+)
+
+// DefaultHome is the home directory used if neither the flag nor the
+// environment sets one, and the platform has no location of its own.
+//
+// It is also the legacy home: if it exists, it is used on every platform.
+const DefaultHome = "~/.helmc"
+
+// goos is the operating system whose conventions are followed. Tests replace it.
+var goos = runtime.GOOS
+
+// The layout of the home directory.
+const (
+	configFile         = "config.yaml"
+	credentialsFile    = "credentials.yaml"
+	cachePath          = "cache"
+	workspacePath      = "workspace"
+	workspaceChartPath = "charts"
+	pluginsPath        = "plugins"
+	startersPath       = "starters"
+	scaffoldsPath      = "scaffolds"
+	locksPath          = "locks"
+	auditFile          = "audit.log"
+	schemasPath        = "schemas"
+	releasesPath       = "releases"
+	valuesPath         = "values"
+)
+
+// DefaultConfig is the configuration file written to a new home directory.
+const DefaultConfig = `apiVersion: v1
+repos:
+  default: charts
+  tables:
+    - name: charts
+      repo: https://github.com/helm/charts
+workspace:
+`
+
+// Home is the absolute path of a Helm Classic home directory.
+type Home string
+
+// Resolve determines the home directory.
+//
+// flag is the value of the --home flag, which is empty if it was not given.
+// Environment variables and a leading ~ are expanded, and the result is
+// made absolute.
+func Resolve(flag string) (Home, error) {
+	h := flag
+	if h == "" {
+		h = os.Getenv(EnvVar)
+	}
+	if h == "" {
+		h = defaultHome()
+	}
+	h = ExpandHome(os.ExpandEnv(h))
+	abs, err := filepath.Abs(h)
+	if err != nil {
+		return "", err
+	}
+	return Home(abs), nil
+}
+
+// defaultHome returns the home directory for the platform.
+//
+// An existing ~/.helmc is always used, so that upgrading never moves a home.
+// Otherwise, Windows uses %LOCALAPPDATA%\helmc, and Linux and other Unix
+// systems use $XDG_DATA_HOME/helmc if $XDG_DATA_HOME is set. Everywhere
+// else, the default is ~/.helmc.
+func defaultHome() string {
+	legacy := ExpandHome(DefaultHome)
+	if fi, err := os.Stat(legacy); err == nil && fi.IsDir() {
+		return legacy
+	}
+	switch goos {
+	case "windows":
+		if d := os.Getenv("LOCALAPPDATA"); d != "" {
+			return filepath.Join(d, "helmc")
+		}
+	case "darwin":
+	default:
+		// Relative paths are invalid in XDG variables, and are ignored.
+		if d := os.Getenv("XDG_DATA_HOME"); filepath.IsAbs(d) {
+			return filepath.Join(d, "helmc")
+		}
+	}
+	return legacy
+}
+
+// UserHome returns the user's home directory.
+//
+// This is $HOME, or on Windows, where $HOME is rarely set, %USERPROFILE%.
+func UserHome() string {
+	if h := os.Getenv("HOME"); h != "" || goos != "windows" {
+		return h
+	}
+	if h := os.Getenv("USERPROFILE"); h != "" {
+		return h
+	}
+	return os.Getenv("HOMEDRIVE") + os.Getenv("HOMEPATH")
+}
+
+// ExpandHome replaces a leading ~ in a path with the user's home directory.
+func ExpandHome(p string) string {
+	if p == "~" || strings.HasPrefix(p, "~/") || goos == "windows" && strings.HasPrefix(p, `~\`) {
+		return filepath.Join(UserHome(), p[1:])
+	}
+	return p
+}
+
+// Layout is where the parts of a home that may be outside it are.
+type Layout struct {
+	// Config is the configuration file. The credentials file is next to it.
+	Config string
+	// Cache is the directory of the clones of the repositories.
+	Cache string
+	// Workspace is the workspace directory.
+	Workspace string
+}
+
+// Layout returns where the configuration file, the cache, and the workspace
+// of the home are.
+//
+// Each is in the home, unless it is moved:
+//
+//   - When the home is the default one of $XDG_DATA_HOME, the configuration
+//     file is in $XDG_CONFIG_HOME/helmc, and the cache is $XDG_CACHE_HOME/helmc;
+//     either defaults to its XDG location under ~. A configuration file or a
+//     cache that the home already has stays in it.
+//   - The repos.cache and workspace.path keys of the configuration file move
+//     the cache and the workspace. Relative paths are relative to the file.
+//   - $HELMC_CONFIG, $HELMC_CACHE, and $HELMC_WORKSPACE win over the rest.
+//   - A workspace given to the home with UseWorkspace wins over all of them.
+//
+// This lets several homes share a cache, as CI jobs on a shared volume do,
+// while each keeps its own workspace.
+func (h Home) Layout() Layout {
+	l := h.InHome()
+	if h.isXDG() {
+		if !exists(l.Config) {
+			l.Config = filepath.Join(xdgDir("XDG_CONFIG_HOME", ".config"), "helmc", configFile)
+		}
+		if !exists(l.Cache) {
+			l.Cache = filepath.Join(xdgDir("XDG_CACHE_HOME", ".cache"), "helmc")
+		}
+	}
+	if p := os.Getenv(ConfigEnvVar); p != "" {
+		l.Config = envPath(p)
+	}
+	keys := readLayoutKeys(l.Config)
+	if p := keys.Repos.Cache; p != "" {
+		l.Cache = keyPath(l.Config, p)
+	}
+	if p := keys.Workspace.Path; p != "" {
+		l.Workspace = keyPath(l.Config, p)
+	}
+	if p := os.Getenv(CacheEnvVar); p != "" {
+		l.Cache = envPath(p)
+	}
+	if p := os.Getenv(WorkspaceEnvVar); p != "" {
+		l.Workspace = envPath(p)
+	}
+	pinned.Lock()
+	if p, ok := pinned.workspaces[filepath.Clean(string(h))]; ok {
+		l.Workspace = p
+	}
+	pinned.Unlock()
+	return l
+}
+
+// pinned holds the workspaces that UseWorkspace gives homes.
+var pinned = struct {
+	sync.Mutex
+	workspaces map[string]string
+}{workspaces: map[string]string{}}
+
+// UseWorkspace makes dir the workspace of the home, whatever the
+// configuration file or the environment say, until the returned function is
+// called. It is meant for a temporary home; the environment of the process
+// is not changed, so other homes keep their workspaces, and the programs
+// that the home runs are given dir in theirs.
+func (h Home) UseWorkspace(dir string) func() {
+	pinned.Lock()
+	defer pinned.Unlock()
+	pinned.workspaces[filepath.Clean(string(h))] = dir
+	return func() {
+		pinned.Lock()
+		defer pinned.Unlock()
+		delete(pinned.workspaces, filepath.Clean(string(h)))
+	}
+}
+
+// InHome returns the layout that keeps every part in the home, which is the
+// Layout of a home that nothing moves.
+func (h Home) InHome() Layout {
+	return Layout{
+		Config:    filepath.Join(string(h), configFile),
+		Cache:     filepath.Join(string(h), cachePath),
+		Workspace: filepath.Join(string(h), workspacePath),
+	}
+}
+
+// isXDG reports whether the home is the default one that follows the XDG
+// base directory specification.
+func (h Home) isXDG() bool {
+	if goos == "windows" || goos == "darwin" {
+		return false
+	}
+	d := os.Getenv("XDG_DATA_HOME")
+	return filepath.IsAbs(d) && string(h) == filepath.Join(d, "helmc")
+}
+
+// xdgDir returns the directory of an XDG variable, or its default under ~.
+func xdgDir(envVar, def string) string {
+	// Relative paths are invalid in XDG variables, and are ignored.
+	if d := os.Getenv(envVar); filepath.IsAbs(d) {
+		return d
+	}
+	return filepath.Join(UserHome(), def)
+}
+
+// envPath expands a path of an environment variable and makes it absolute.
+func envPath(p string) string {
+	p = ExpandHome(os.ExpandEnv(p))
+	if abs, err := filepath.Abs(p); err == nil {
+		return abs
+	}
+	return p
+}
+
+// keyPath expands a path of a key of the configuration file, relative to the
+// directory of the file, or of the file that it links to.
+func keyPath(config, p string) string {
+	p = ExpandHome(p)
+	if filepath.IsAbs(p) {
+		return p
+	}
+	if real, err := filepath.EvalSymlinks(config); err == nil {
+		config = real
+	}
+	return filepath.Join(filepath.Dir(config), p)
+}
+
+// exists reports whether a path exists.
+func exists(p string) bool {
+	_, err := os.Stat(p)
+	return err == nil
+}
+
+// layoutKeys are the keys of the configuration file that move parts of the
+// home. The config package defines the rest of the file.
+type layoutKeys struct {
+	Repos struct {
+		Cache string `yaml:"cache"`
+	} `yaml:"repos"`
+	Workspace struct {
+		Path string `yaml:"path"`
+	} `yaml:"workspace"`
+}
+
+// readKeys caches the layoutKeys of each configuration file, until the file
+// changes, since every path of a home reads them.
+var readKeys = struct {
+	sync.Mutex
+	files map[string]layoutFile
+}{files: map[string]layoutFile{}}
+
+type layoutFile struct {
+	mod  time.Time
+	size int64
+	keys layoutKeys
+}
+
+// readLayoutKeys returns the layoutKeys of a configuration file. A file that
+// cannot be read or parsed moves nothing; loading it reports why.
+func readLayoutKeys(config string) layoutKeys {
+	fi, err := os.Stat(config)
+	if err != nil {
+		return layoutKeys{}
+	}
+	readKeys.Lock()
+	defer readKeys.Unlock()
+	if f, ok := readKeys.files[config]; ok && f.mod.Equal(fi.ModTime()) && f.size == fi.Size() {
+		return f.keys
+	}
+	f := layoutFile{mod: fi.ModTime(), size: fi.Size()}
+	if b, err := ioutil.ReadFile(config); err == nil {
+		yaml.Unmarshal(b, &f.keys)
+	}
+	readKeys.files[config] = f
+	return f.keys
+}
+
+// String returns the home directory.
+func (h Home) String() string {
+	return string(h)
+}
+
+// Config returns the path to the configuration file.
+func (h Home) Config() string {
+	return h.Layout().Config
+}
+
+// Credentials returns the path to the file of the tokens of private
+// repositories, which are kept out of the configuration file, next to it.
+func (h Home) Credentials() string {
+	return filepath.Join(filepath.Dir(h.Config()), credentialsFile)
+}
+
+// Audit returns the path to the default audit log.
+func (h Home) Audit() string {
+	return filepath.Join(string(h), auditFile)
+}
+
+// Cache returns a path within the repository cache.
+func (h Home) Cache(paths ...string) string {
+	return filepath.Join(append([]string{h.Layout().Cache}, paths...)...)
+}
+
+// Workspace returns a path within the workspace.
+func (h Home) Workspace(paths ...string) string {
+	return filepath.Join(append([]string{h.Layout().Workspace}, paths...)...)
+}
+
+// WorkspaceCharts returns a path within the workspace's chart directory.
+func (h Home) WorkspaceCharts(paths ...string) string {
+	return filepath.Join(append([]string{h.Layout().Workspace, workspaceChartPath}, paths...)...)
+}
+
+// Plugins returns a path within the plugins directory.
+//
+// The directory is optional, so Ensure does not create it.
+func (h Home) Plugins(paths ...string) string {
+	return filepath.Join(append([]string{string(h), pluginsPath}, paths...)...)
+}
+
+// Starters returns a path within the directory of chart starters.
+//
+// The directory is optional, so Ensure does not create it.
+func (h Home) Starters(paths ...string) string {
+	return filepath.Join(append([]string{string(h), startersPath}, paths...)...)
+}
+
+// Scaffolds returns a path within the directory of the scaffolds that
+// override or add to the built-in ones.
+//
+// The directory is optional, so Ensure does not create it.
+func (h Home) Scaffolds(paths ...string) string {
+	return filepath.Join(append([]string{string(h), scaffoldsPath}, paths...)...)
+}
+
+// Locks returns a path within the directory of lock files.
+//
+// Locks are created on demand, so Ensure does not create it.
+func (h Home) Locks(paths ...string) string {
+	return filepath.Join(append([]string{string(h), locksPath}, paths...)...)
+}
+
+// Schemas returns a path within the cache of Kubernetes schemas.
+//
+// Schemas are downloaded on demand, so Ensure does not create it.
+func (h Home) Schemas(paths ...string) string {
+	return filepath.Join(append([]string{string(h), schemasPath}, paths...)...)
+}
+
+// Releases returns a path within the directory of release histories.
+//
+// Releases are recorded on demand, so Ensure does not create it.
+func (h Home) Releases(paths ...string) string {
+	return filepath.Join(append([]string{string(h), releasesPath}, paths...)...)
+}
+
+// Values returns a path within the directory of the values that generators
+// are given.
+//
+// The values are rendered on demand, so Ensure does not create it.
+func (h Home) Values(paths ...string) string {
+	return filepath.Join(append([]string{string(h), valuesPath}, paths...)...)
+}
+
+// Ensure creates any missing parts of the home directory, wherever its
+// Layout puts them.
+//
+// Directories are created with mode 0755. If there is no configuration
+// file, DefaultConfig is written with mode 0644. It returns the paths that
+// were created.
+func (h Home) Ensure() ([]string, error) {
+	created := []string{}
+	l := h.Layout()
+	for _, p := range []string{string(h), filepath.Dir(l.Config), l.Cache, l.Workspace, filepath.Join(l.Workspace, workspaceChartPath)} {
+		fi, err := os.Stat(p)
+		if err == nil {
+			if !fi.IsDir() {
+				return created, fmt.Errorf("%s must be a directory", p)
+			}
+			continue
+		}
+		if err := os.MkdirAll(p, 0755); err != nil {
+			return created, err
+		}
+		created = append(created, p)
+	}
+
+	if _, err := os.Stat(l.Config); os.IsNotExist(err) {
+		if err := ioutil.WriteFile(l.Config, []byte(DefaultConfig), 0644); err != nil {
+			return created, err
+		}
+		created = append(created, l.Config)
+	}
+	return created, nil
+}
@@ -0,0 +1,220 @@
+package helmpath
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	defer os.Setenv(EnvVar, os.Getenv(EnvVar))
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+	defer os.Setenv("XDG_DATA_HOME", os.Getenv("XDG_DATA_HOME"))
+	os.Setenv("HOME", "/home/helm")
+	os.Unsetenv("XDG_DATA_HOME")
+
+	os.Unsetenv(EnvVar)
+	if h, _ := Resolve(""); h != "/home/helm/.helmc" {
+		t.Errorf("Expected the default home, got %s", h)
+	}
+
+	os.Setenv(EnvVar, "~/env")
+	if h, _ := Resolve(""); h != "/home/helm/env" {
+		t.Errorf("Expected $%s to override the default, got %s", EnvVar, h)
+	}
+	if h, _ := Resolve("$HOME/flag"); h != "/home/helm/flag" {
+		t.Errorf("Expected the flag to override $%s, got %s", EnvVar, h)
+	}
+
+	if h, _ := Resolve("relative"); !filepath.IsAbs(h.String()) {
+		t.Errorf("Expected an absolute path, got %s", h)
+	}
+}
+
+func TestDefaultHome(t *testing.T) {
+	dir, err := ioutil.TempDir("", "helmpath")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+	defer os.Setenv("XDG_DATA_HOME", os.Getenv("XDG_DATA_HOME"))
+	defer os.Setenv("LOCALAPPDATA", os.Getenv("LOCALAPPDATA"))
+	defer func(g string) { goos = g }(goos)
+	os.Setenv("HOME", dir)
+	legacy := filepath.Join(dir, ".helmc")
+
+	goos = "linux"
+	os.Unsetenv("XDG_DATA_HOME")
+	if h := defaultHome(); h != legacy {
+		t.Errorf("Expected %s without $XDG_DATA_HOME, got %s", legacy, h)
+	}
+	os.Setenv("XDG_DATA_HOME", "relative")
+	if h := defaultHome(); h != legacy {
+		t.Errorf("Expected a relative $XDG_DATA_HOME to be ignored, got %s", h)
+	}
+	os.Setenv("XDG_DATA_HOME", filepath.Join(dir, "data"))
+	if h := defaultHome(); h != filepath.Join(dir, "data", "helmc") {
+		t.Errorf("Expected $XDG_DATA_HOME to be used, got %s", h)
+	}
+
+	goos = "darwin"
+	if h := defaultHome(); h != legacy {
+		t.Errorf("Expected %s on darwin, got %s", legacy, h)
+	}
+
+	goos = "windows"
+	os.Setenv("LOCALAPPDATA", filepath.Join(dir, "AppData", "Local"))
+	if h := defaultHome(); h != filepath.Join(dir, "AppData", "Local", "helmc") {
+		t.Errorf("Expected %%LOCALAPPDATA%% to be used, got %s", h)
+	}
+	os.Unsetenv("HOME")
+	os.Setenv("USERPROFILE", dir)
+	defer os.Unsetenv("USERPROFILE")
+	if h := UserHome(); h != dir {
+		t.Errorf("Expected %%USERPROFILE%% as the home, got %s", h)
+	}
+	os.Setenv("HOME", dir)
+
+	// An existing legacy home always wins.
+	os.Mkdir(legacy, 0755)
+	for _, g := range []string{"linux", "windows"} {
+		goos = g
+		if h := defaultHome(); h != legacy {
+			t.Errorf("Expected the existing %s on %s, got %s", legacy, g, h)
+		}
+	}
+}
+
+func TestLayout(t *testing.T) {
+	h := Home("/h")
+	for expect, got := range map[string]string{
+		"/h/config.yaml":            h.Config(),
+		"/h/cache/charts/redis":     h.Cache("charts", "redis"),
+		"/h/workspace":              h.Workspace(),
+		"/h/workspace/charts/redis": h.WorkspaceCharts("redis"),
+	} {
+		if expect != got {
+			t.Errorf("Expected %s, got %s", expect, got)
+		}
+	}
+}
+
+func TestLayoutMoves(t *testing.T) {
+	dir, err := ioutil.TempDir("", "helmpath")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, v := range []string{"HOME", "XDG_DATA_HOME", "XDG_CONFIG_HOME", "XDG_CACHE_HOME", ConfigEnvVar, CacheEnvVar, WorkspaceEnvVar} {
+		defer os.Setenv(v, os.Getenv(v))
+		os.Unsetenv(v)
+	}
+	defer func(g string) { goos = g }(goos)
+	goos = "linux"
+	os.Setenv("HOME", dir)
+
+	// The XDG home keeps its configuration and its cache in their XDG
+	// directories.
+	os.Setenv("XDG_DATA_HOME", filepath.Join(dir, "data"))
+	os.Setenv("XDG_CACHE_HOME", filepath.Join(dir, "cache"))
+	h := Home(filepath.Join(dir, "data", "helmc"))
+	expect := Layout{
+		Config:    filepath.Join(dir, ".config", "helmc", "config.yaml"),
+		Cache:     filepath.Join(dir, "cache", "helmc"),
+		Workspace: filepath.Join(dir, "data", "helmc", "workspace"),
+	}
+	if l := h.Layout(); l != expect {
+		t.Errorf("Expected the XDG layout %v, got %v", expect, l)
+	}
+	if _, err := h.Ensure(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(expect.Config); err != nil {
+		t.Errorf("Expected the configuration file in the XDG directory: %s", err)
+	}
+
+	// One that has a cache of its own keeps it.
+	os.MkdirAll(h.InHome().Cache, 0755)
+	if c := h.Cache(); c != h.InHome().Cache {
+		t.Errorf("Expected the existing cache of the home, got %s", c)
+	}
+
+	// Keys of the configuration file move the cache and the workspace.
+	ioutil.WriteFile(h.Config(), []byte("repos:\n  cache: /shared/cache\nworkspace:\n  path: ws\n"), 0644)
+	if l := h.Layout(); l.Cache != "/shared/cache" || l.Workspace != filepath.Join(dir, ".config", "helmc", "ws") {
+		t.Errorf("Expected the keys of the configuration to move the cache and the workspace, got %v", l)
+	}
+
+	// The environment wins over them.
+	os.Setenv(CacheEnvVar, "~/ci-cache")
+	os.Setenv(WorkspaceEnvVar, "/tmp/ws")
+	os.Setenv(ConfigEnvVar, filepath.Join(dir, "none.yaml"))
+	expect = Layout{Config: filepath.Join(dir, "none.yaml"), Cache: filepath.Join(dir, "ci-cache"), Workspace: "/tmp/ws"}
+	if l := Home("/h").Layout(); l != expect {
+		t.Errorf("Expected the layout of the environment %v, got %v", expect, l)
+	}
+	if c := Home("/h").Credentials(); c != filepath.Join(dir, "credentials.yaml") {
+		t.Errorf("Expected the credentials next to the configuration, got %s", c)
+	}
+}
+
+func TestUseWorkspace(t *testing.T) {
+	defer os.Setenv(WorkspaceEnvVar, os.Getenv(WorkspaceEnvVar))
+	os.Setenv(WorkspaceEnvVar, "/tmp/ws")
+
+	restore := Home("/tmp/stateless/").UseWorkspace("/tmp/stateless/workspace")
+	if w := Home("/tmp/stateless").Workspace(); w != "/tmp/stateless/workspace" {
+		t.Errorf("Expected the workspace given to the home to win over the environment, got %s", w)
+	}
+	if w := Home("/h").Workspace(); w != "/tmp/ws" || os.Getenv(WorkspaceEnvVar) != "/tmp/ws" {
+		t.Errorf("Expected other homes and the environment to be left alone, got %s", w)
+	}
+	restore()
+	if w := Home("/tmp/stateless").Workspace(); w != "/tmp/ws" {
+		t.Errorf("Expected the environment to move the workspace again, got %s", w)
+	}
+}
+
+func TestEnsure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "helmpath")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	h := Home(filepath.Join(dir, "home"))
+
+	created, err := h.Ensure()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(created) != 5 {
+		t.Errorf("Expected 5 paths to be created, got %v", created)
+	}
+	if fi, err := os.Stat(h.WorkspaceCharts()); err != nil || fi.Mode().Perm() != 0755&^umask() {
+		t.Errorf("Expected workspace charts directory with mode 0755, got %v", fi.Mode())
+	}
+	if fi, err := os.Stat(h.Config()); err != nil || fi.Mode().Perm() != 0644&^umask() {
+		t.Errorf("Expected config file with mode 0644, got %v", fi.Mode())
+	}
+
+	if created, _ := h.Ensure(); len(created) != 0 {
+		t.Errorf("Expected an existing home to be left alone, got %v", created)
+	}
+
+	ioutil.WriteFile(filepath.Join(dir, "file"), nil, 0644)
+	if _, err := Home(filepath.Join(dir, "file")).Ensure(); err == nil {
+		t.Errorf("Expected an error for a home that is a file")
+	}
+}
+
+// umask returns the bits that the process umask removes from new files.
+func umask() os.FileMode {
+	dir, _ := ioutil.TempDir("", "umask")
+	defer os.RemoveAll(dir)
+	p := filepath.Join(dir, "f")
+	os.Mkdir(p, 0777)
+	fi, _ := os.Stat(p)
+	return 0777 &^ fi.Mode().Perm()
+}
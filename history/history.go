@@ -0,0 +1,166 @@
+// Package history records the history of the installs of each chart, so that
+// an earlier install can be restored.
+//
+// Each install of a chart is a revision of its release, numbered from 1. A
+// revision keeps the manifests that were sent to Kubernetes, as they were
+// sent. The history of a release is a directory of files, one per revision,
+// that are only ever added to. Processes that share a history take its lock
+// while they add a revision, so that revisions are never numbered twice.
+package history
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/helm/helm-classic/lock"
+)
+
+// Statuses of a revision.
+const (
+	// Deployed is a revision whose every manifest was sent.
+	Deployed = "deployed"
+	// Failed is a revision that stopped before every manifest was sent.
+	Failed = "failed"
+)
+
+// ErrNotFound is returned for a revision that a history does not have.
+var ErrNotFound = errors.New("no such revision")
+
+// Revision is one revision of the release of a chart.
+type Revision struct {
+	// Name is the release's name: the name of the chart in the workspace.
+	Name     string    `json:"name"`
+	Revision int       `json:"revision"`
+	Time     time.Time `json:"time"`
+	// Chart, Version, and Digest are those of the chart that was installed.
+	Chart     string `json:"chart"`
+	Version   string `json:"version"`
+	Digest    string `json:"digest,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	// Status is Deployed or Failed, and Error says why a revision failed.
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	// Description says what made the revision, such as "install (apply)" or
+	// "rollback to 2".
+	Description string `json:"description"`
+	// Manifests are the manifests that were sent, in order.
+	Manifests []*Manifest `json:"manifests"`
+}
+
+// Manifest is a manifest of a revision, as it was sent to Kubernetes.
+type Manifest struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	// Hook is the install hook of the manifest, if it is one.
+	Hook     string          `json:"hook,omitempty"`
+	Manifest json.RawMessage `json:"manifest"`
+}
+
+// Record adds r to the history of its release in dir, as the revision after
+// the latest, and sets r.Revision.
+//
+// The history's lock, dir/name.lock, is held while the revision is written.
+// Since manifests may hold secrets, only the owner may read it.
+func Record(dir string, r *Revision) error {
+	hdir := filepath.Join(dir, r.Name)
+	if err := os.MkdirAll(hdir, 0700); err != nil {
+		return err
+	}
+	l, err := lock.Acquire(hdir + ".lock")
+	if err != nil {
+		return err
+	}
+	defer l.Release()
+
+	revs, err := revisions(hdir)
+	if err != nil {
+		return err
+	}
+	r.Revision = 1
+	if len(revs) > 0 {
+		r.Revision = revs[len(revs)-1] + 1
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(hdir, revisionFile(r.Revision)), append(data, '\n'), 0600)
+}
+
+// List returns the revisions of the release of name in dir, oldest first. A
+// release that was never installed has none.
+func List(dir, name string) ([]*Revision, error) {
+	hdir := filepath.Join(dir, name)
+	revs, err := revisions(hdir)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*Revision, 0, len(revs))
+	for _, rev := range revs {
+		r, err := load(hdir, rev)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, r)
+	}
+	return res, nil
+}
+
+// Get returns a revision of the release of name in dir. A revision that
+// the history does not have is ErrNotFound.
+func Get(dir, name string, revision int) (*Revision, error) {
+	r, err := load(filepath.Join(dir, name), revision)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("Release %s has no revision %d: %w", name, revision, ErrNotFound)
+	}
+	return r, err
+}
+
+// revisionFile is the file of a revision in the directory of its history.
+func revisionFile(revision int) string {
+	return strconv.Itoa(revision) + ".json"
+}
+
+// load reads a revision from the directory of its history.
+func load(hdir string, revision int) (*Revision, error) {
+	file := filepath.Join(hdir, revisionFile(revision))
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	r := &Revision{}
+	if err := json.Unmarshal(data, r); err != nil {
+		return nil, fmt.Errorf("%s: %s", file, err)
+	}
+	return r, nil
+}
+
+// revisions returns the numbers of the revisions in the directory of a
+// history, sorted. Files that are not revisions are ignored.
+func revisions(hdir string) ([]int, error) {
+	fis, err := ioutil.ReadDir(hdir)
+	if os.IsNotExist(err) {
+		return []int{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	revs := []int{}
+	for _, fi := range fis {
+		n, err := strconv.Atoi(strings.TrimSuffix(fi.Name(), ".json"))
+		if err != nil || n < 1 || fi.Name() != revisionFile(n) {
+			continue
+		}
+		revs = append(revs, n)
+	}
+	sort.Ints(revs)
+	return revs, nil
+}
@@ -0,0 +1,43 @@
+package history
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecord(t *testing.T) {
+	dir, err := ioutil.TempDir("", "helmc-release")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if revs, err := List(dir, "redis"); err != nil || len(revs) != 0 {
+		t.Errorf("Expected no revisions, got %v, %v", revs, err)
+	}
+	for i := 1; i <= 2; i++ {
+		r := &Revision{Name: "redis", Chart: "redis", Status: Deployed, Manifests: []*Manifest{{Kind: "Pod", Name: "redis", Manifest: []byte(`{"kind":"Pod"}`)}}}
+		if err := Record(dir, r); err != nil {
+			t.Fatal(err)
+		}
+		if r.Revision != i {
+			t.Errorf("Expected revision %d, got %d", i, r.Revision)
+		}
+	}
+	fi, err := os.Stat(filepath.Join(dir, "redis", "2.json"))
+	if err != nil || fi.Mode().Perm() != 0600 {
+		t.Errorf("Expected a revision only the owner can read, got %v, %v", fi, err)
+	}
+
+	revs, err := List(dir, "redis")
+	if err != nil || len(revs) != 2 || revs[0].Revision != 1 || !strings.Contains(string(revs[1].Manifests[0].Manifest), `"Pod"`) {
+		t.Errorf("Expected both revisions, got %v, %v", revs, err)
+	}
+	if _, err := Get(dir, "redis", 3); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
@@ -2,27 +2,15 @@ package kubectl
 
 // Apply uploads a chart to Kubernetes
 func (r RealRunner) Apply(stdin []byte, ns string) ([]byte, error) {
-	args := []string{"apply", "-f", "-"}
-
-	if ns != "" {
-		args = append([]string{"--namespace=" + ns}, args...)
-	}
-
-	cmd := command(args...)
-	assignStdin(cmd, stdin)
-
-	return cmd.CombinedOutput()
+	s := r.settings()
+	return s.run(stdin, s.changeArgs(ns, "apply")...)
 }
 
 // Apply returns the commands to kubectl
 func (r PrintRunner) Apply(stdin []byte, ns string) ([]byte, error) {
-	args := []string{"apply", "-f", "-"}
-
-	if ns != "" {
-		args = append([]string{"--namespace=" + ns}, args...)
-	}
+	args := r.settings().changeArgs(ns, "apply")
 
-	cmd := command(args...)
+	cmd := r.settings().command(args...)
 	assignStdin(cmd, stdin)
 
 	return []byte(cmd.String()), nil
@@ -0,0 +1,120 @@
+package kubectl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Args are extra flags for the kubectl commands that change resources.
+type Args struct {
+	// Apply is added to every create, apply, and replace.
+	Apply []string `json:"apply,omitempty"`
+	// Delete is added to every delete.
+	Delete []string `json:"delete,omitempty"`
+}
+
+// ChartFlags are the flags that a chart may declare, for create, apply, and
+// replace, and for delete. Flags that choose the cluster, the namespace, or
+// the resources, or that bypass graceful deletion, are not among them.
+var ChartFlags = struct {
+	Apply  []string
+	Delete []string
+}{
+	Apply:  []string{"--field-manager", "--validate"},
+	Delete: []string{"--cascade", "--grace-period", "--timeout", "--wait"},
+}
+
+// flagName returns the name of a flag given as "--name" or "--name=value".
+func flagName(arg string) string {
+	return strings.SplitN(arg, "=", 2)[0]
+}
+
+// CheckChartArgs returns an error for each flag of a chart that is not in
+// ChartFlags, or that is not of the form --name or --name=value.
+func CheckChartArgs(a Args) []error {
+	errs := []error{}
+	check := func(cmd string, args, allowed []string) {
+		for _, arg := range args {
+			name := flagName(arg)
+			switch {
+			case !strings.HasPrefix(name, "--") || len(name) == 2:
+				errs = append(errs, fmt.Errorf("kubectl.%s: %q is not a flag of the form --name or --name=value", cmd, arg))
+			case !contains(allowed, name):
+				errs = append(errs, fmt.Errorf("kubectl.%s: %s is not allowed in a chart. Allowed flags are %s", cmd, name, strings.Join(allowed, ", ")))
+			}
+		}
+	}
+	check("apply", a.Apply, ChartFlags.Apply)
+	check("delete", a.Delete, ChartFlags.Delete)
+	return errs
+}
+
+func contains(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// MergeArgs returns the flags of user, followed by those of chart that user
+// does not give. A flag of the chart never overrides one of the user.
+func MergeArgs(chart, user []string) []string {
+	given := map[string]bool{}
+	for _, arg := range user {
+		given[flagName(arg)] = true
+	}
+	var args []string
+	args = append(args, user...)
+	for _, arg := range chart {
+		if given[flagName(arg)] {
+			continue
+		}
+		given[flagName(arg)] = true
+		args = append(args, arg)
+	}
+	return args
+}
+
+// EffectiveArgs returns the extra flags that the kubectl commands that follow
+// are given: those of ChartArgs merged with UserArgs and GracePeriod.
+func (s *Settings) EffectiveArgs() Args {
+	return Args{Apply: s.applyFlags(), Delete: s.deleteFlags()}
+}
+
+func (s *Settings) applyFlags() []string {
+	return MergeArgs(s.ChartArgs.Apply, s.UserArgs.Apply)
+}
+
+// deleteFlags returns the extra flags of a kubectl delete. GracePeriod, if it
+// is set, wins over those of both the user and the chart.
+func (s *Settings) deleteFlags() []string {
+	flags := []string{}
+	if s.GracePeriod >= 0 {
+		flags = append(flags, "--grace-period="+strconv.Itoa(s.GracePeriod))
+	}
+	return MergeArgs(s.ChartArgs.Delete, MergeArgs(s.UserArgs.Delete, flags))
+}
+
+// changeArgs returns the arguments of a kubectl command that creates or
+// changes the resources on stdin: the verb and its flags, and then the
+// extra flags.
+func (s *Settings) changeArgs(ns string, verb ...string) []string {
+	args := append(append([]string{}, verb...), s.applyFlags()...)
+	args = append(args, "-f", "-")
+	if ns != "" {
+		args = append([]string{"--namespace=" + ns}, args...)
+	}
+	return args
+}
+
+// deleteArgs returns the arguments of a kubectl delete.
+func (s *Settings) deleteArgs(name, ktype, ns string) []string {
+	args := append([]string{"delete", ktype, name}, s.deleteFlags()...)
+	if ns != "" {
+		args = append([]string{"--namespace=" + ns}, args...)
+	}
+	return args
+}
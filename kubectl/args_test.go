@@ -0,0 +1,77 @@
+package kubectl
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMergeArgs(t *testing.T) {
+	for _, tt := range []struct {
+		chart, user []string
+		expect      string
+	}{
+		{nil, nil, ""},
+		{[]string{"--validate=false"}, nil, "--validate=false"},
+		{nil, []string{"--validate=true"}, "--validate=true"},
+		// The user wins, whether each gives a value or not.
+		{[]string{"--validate=false"}, []string{"--validate=true"}, "--validate=true"},
+		{[]string{"--validate=false"}, []string{"--validate"}, "--validate"},
+		{[]string{"--wait", "--grace-period=60"}, []string{"--grace-period=5"}, "--grace-period=5 --wait"},
+		// A flag that the chart gives twice is given once.
+		{[]string{"--wait=true", "--wait=false"}, nil, "--wait=true"},
+	} {
+		if actual := strings.Join(MergeArgs(tt.chart, tt.user), " "); actual != tt.expect {
+			t.Errorf("Merging %v into %v: expected %q, got %q", tt.chart, tt.user, tt.expect, actual)
+		}
+	}
+}
+
+func TestCheckChartArgs(t *testing.T) {
+	errs := CheckChartArgs(Args{
+		Apply:  []string{"--validate=false", "--server=https://evil", "validate"},
+		Delete: []string{"--grace-period=30", "--force", "--validate=false"},
+	})
+	expect := []string{
+		"kubectl.apply: --server is not allowed in a chart. Allowed flags are --field-manager, --validate",
+		`kubectl.apply: "validate" is not a flag of the form --name or --name=value`,
+		"kubectl.delete: --force is not allowed in a chart",
+		"kubectl.delete: --validate is not allowed in a chart",
+	}
+	if len(errs) != len(expect) {
+		t.Fatalf("Expected %d errors, got %v", len(expect), errs)
+	}
+	for i, e := range expect {
+		if !strings.Contains(errs[i].Error(), e) {
+			t.Errorf("Expected %q, got %q", e, errs[i])
+		}
+	}
+	if errs := CheckChartArgs(Args{Apply: []string{"--validate=false"}, Delete: []string{"--wait", "--cascade=foreground"}}); len(errs) != 0 {
+		t.Errorf("Expected allowed flags to pass, got %v", errs)
+	}
+}
+
+func TestPrintChartArgs(t *testing.T) {
+	s := DefaultSettings()
+	s.ChartArgs = Args{Apply: []string{"--validate=false"}, Delete: []string{"--grace-period=60", "--wait"}}
+	s.UserArgs = Args{Delete: []string{"--wait=false"}}
+	s.GracePeriod = 10
+
+	var client Runner = PrintRunner{Settings: &s}
+	out, _ := client.Apply([]byte("data"), "ns")
+	if expect := "[CMD] kubectl --namespace=ns apply --validate=false -f - < data"; string(out) != expect {
+		t.Errorf("Expected %q, got %q", expect, out)
+	}
+	out, _ = client.Create([]byte("data"), "")
+	if expect := "[CMD] kubectl create --validate=false -f - < data"; string(out) != expect {
+		t.Errorf("Expected %q, got %q", expect, out)
+	}
+	out, _ = client.DryRun([]byte("data"), "")
+	if expect := "[CMD] kubectl apply --dry-run=server --validate=false -f - < data"; string(out) != expect {
+		t.Errorf("Expected %q, got %q", expect, out)
+	}
+	// --grace-period wins over the configuration, and both over the chart.
+	out, _ = client.Delete("redis", "pod", "ns")
+	if expect := "[CMD] kubectl --namespace=ns delete pod redis --grace-period=10 --wait=false "; string(out) != expect {
+		t.Errorf("Expected %q, got %q", expect, out)
+	}
+}
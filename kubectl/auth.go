@@ -0,0 +1,77 @@
+package kubectl
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// canIArgs returns the arguments of a kubectl auth can-i of verb on the
+// resources of kind.
+func canIArgs(verb, kind, ns string) []string {
+	args := []string{"auth", "can-i", verb, strings.ToLower(kind)}
+	if ns != "" {
+		args = append([]string{"--namespace=" + ns}, args...)
+	}
+	return args
+}
+
+// CanI asks whether the user may verb the resources of kind. The output is
+// "yes" or "no", and "no" comes with an error, as kubectl exits with 1.
+func (r RealRunner) CanI(verb, kind, ns string) ([]byte, error) {
+	return r.settings().run(nil, canIArgs(verb, kind, ns)...)
+}
+
+// CanI returns the commands to kubectl
+func (r PrintRunner) CanI(verb, kind, ns string) ([]byte, error) {
+	cmd := r.settings().command(canIArgs(verb, kind, ns)...)
+	return []byte(cmd.String()), nil
+}
+
+// errDenied is the error of a CanI that is answered "no".
+var errDenied = errors.New("not allowed")
+
+// CanI asks whether the user may verb the resources of kind, with a
+// SelfSubjectAccessReview. Like kubectl, it answers "yes" or "no", and "no"
+// comes with an error.
+func (r *NativeRunner) CanI(verb, kind, ns string) ([]byte, error) {
+	ns, err := r.namespace(ns)
+	if err != nil {
+		return []byte(err.Error()), err
+	}
+	m := r.mapping("", kind)
+	attrs := map[string]interface{}{"verb": verb, "group": m.group(), "resource": m.resource}
+	if !m.cluster {
+		attrs["namespace"] = ns
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"apiVersion": "authorization.k8s.io/v1",
+		"kind":       "SelfSubjectAccessReview",
+		"spec":       map[string]interface{}{"resourceAttributes": attrs},
+	})
+	if err != nil {
+		return []byte(err.Error()), err
+	}
+	code, b, err := r.do("POST", "/apis/authorization.k8s.io/v1/selfsubjectaccessreviews", body)
+	if err != nil {
+		return []byte(err.Error()), err
+	}
+	if code != http.StatusCreated && code != http.StatusOK {
+		err := statusError(code, b)
+		return []byte(err.Error()), err
+	}
+	review := struct {
+		Status struct {
+			Allowed bool `json:"allowed"`
+		} `json:"status"`
+	}{}
+	if err := json.Unmarshal(b, &review); err != nil {
+		return []byte(err.Error()), fmt.Errorf("Could not read the access review: %s", err)
+	}
+	if !review.Status.Allowed {
+		return []byte("no\n"), errDenied
+	}
+	return []byte("yes\n"), nil
+}
@@ -0,0 +1,22 @@
+package kubectl
+
+import (
+	"testing"
+)
+
+func TestPrintCanI(t *testing.T) {
+	var client Runner = PrintRunner{}
+
+	expected := `[CMD] kubectl --namespace=shop auth can-i create deployment `
+
+	out, err := client.CanI("create", "Deployment", "shop")
+	if err != nil {
+		t.Error(err)
+	}
+
+	actual := string(out)
+
+	if expected != actual {
+		t.Fatalf("actual %s != expected %s", actual, expected)
+	}
+}
@@ -0,0 +1,66 @@
+package kubectl
+
+import (
+	"fmt"
+	"os/exec"
+	"sync"
+
+	"github.com/Masterminds/semver"
+	helm "github.com/helm/helm-classic/util"
+)
+
+// MinVersion is the oldest kubectl client that Helm Classic supports.
+const MinVersion = "1.2.0"
+
+// clientVersion is the version of a kubectl binary.
+type clientVersion struct {
+	version *semver.Version
+	err     error
+}
+
+// clientVersions caches the version of each kubectl binary, by path.
+var clientVersions struct {
+	sync.Mutex
+	byPath map[string]*clientVersion
+}
+
+// ClientVersion returns the version of the kubectl binary at s.Path.
+//
+// It runs `kubectl version --client`, which does not contact the cluster,
+// once per invocation for each binary.
+func (s *Settings) ClientVersion() (*semver.Version, error) {
+	clientVersions.Lock()
+	defer clientVersions.Unlock()
+	if v, ok := clientVersions.byPath[s.Path]; ok {
+		return v.version, v.err
+	}
+	v := &clientVersion{}
+	out, err := helm.Exec("kubectl version", s.command("version", "--client").Cmd)
+	if err != nil {
+		v.err = fmt.Errorf("could not determine the kubectl version: %s", err)
+	} else if v.version, err = parseVersion(out, "Client"); err != nil {
+		v.err = fmt.Errorf("could not determine the kubectl version: %s", err)
+	}
+	if clientVersions.byPath == nil {
+		clientVersions.byPath = map[string]*clientVersion{}
+	}
+	clientVersions.byPath[s.Path] = v
+	return v.version, v.err
+}
+
+// CheckBinary makes sure that s.Path is an executable kubectl of at least
+// MinVersion, and returns its resolved location and version.
+func (s *Settings) CheckBinary() (string, *semver.Version, error) {
+	path, err := exec.LookPath(s.Path)
+	if err != nil {
+		return "", nil, fmt.Errorf("Could not find kubectl at '%s': %s. Install kubectl, or give its location with --kubectl-path or $HELMC_KUBECTL", s.Path, err)
+	}
+	v, err := s.ClientVersion()
+	if err != nil {
+		return path, nil, fmt.Errorf("Could not run %s: %s", path, err)
+	}
+	if v.LessThan(semver.MustParse(MinVersion)) {
+		return path, v, fmt.Errorf("kubectl %s at %s is too old. Version %s or later is required: upgrade it, or choose another with --kubectl-path", v, path, MinVersion)
+	}
+	return path, v, nil
+}
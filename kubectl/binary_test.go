@@ -0,0 +1,66 @@
+package kubectl
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeKubectl writes a kubectl that reports the given client version.
+func fakeKubectl(t *testing.T, version string) string {
+	dir, err := ioutil.TempDir("", "helmc-kubectl-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "kubectl1.5")
+	script := "#!/bin/sh\necho 'Client Version: " + version + "'\n"
+	if err := ioutil.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// withKubectl returns settings for the kubectl at path, which is looked up
+// afresh.
+func withKubectl(path string) *Settings {
+	clientVersions.Lock()
+	delete(clientVersions.byPath, path)
+	clientVersions.Unlock()
+	s := DefaultSettings()
+	s.Path = path
+	return &s
+}
+
+func TestCheckBinary(t *testing.T) {
+	path := fakeKubectl(t, "v1.18.2")
+	defer os.RemoveAll(filepath.Dir(path))
+	s := withKubectl(path)
+
+	resolved, v, err := s.CheckBinary()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if resolved != path || v.String() != "1.18.2" {
+		t.Errorf("Expected 1.18.2 at %s, got %s at %s", path, v, resolved)
+	}
+}
+
+func TestCheckBinaryTooOld(t *testing.T) {
+	path := fakeKubectl(t, "v1.1.8")
+	defer os.RemoveAll(filepath.Dir(path))
+	s := withKubectl(path)
+
+	if _, _, err := s.CheckBinary(); err == nil || !strings.Contains(err.Error(), "too old") {
+		t.Errorf("Expected kubectl 1.1.8 to be too old, got %v", err)
+	}
+}
+
+func TestCheckBinaryMissing(t *testing.T) {
+	s := withKubectl("/no/such/kubectl")
+
+	if _, _, err := s.CheckBinary(); err == nil || !strings.Contains(err.Error(), "--kubectl-path") {
+		t.Errorf("Expected an error naming --kubectl-path, got %v", err)
+	}
+}
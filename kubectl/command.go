@@ -2,18 +2,95 @@ package kubectl
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os/exec"
+	"sort"
 	"strings"
+	"time"
+
+	"github.com/helm/helm-classic/metrics"
+	helm "github.com/helm/helm-classic/util"
 )
 
 type cmd struct {
 	*exec.Cmd
 }
 
-func command(args ...string) *cmd {
-	return &cmd{exec.Command(Path, args...)}
+// command returns a kubectl command, with the global flags of s.
+func (s *Settings) command(args ...string) *cmd {
+	return &cmd{exec.Command(s.Path, append(s.globalArgs(), args...)...)}
+}
+
+// globalArgs returns the flags that are passed to every kubectl command.
+func (s *Settings) globalArgs() []string {
+	args := []string{}
+	for flag, v := range map[string]string{"--context": s.Context, "--cluster": s.Cluster, "--user": s.User, "--kubeconfig": s.Kubeconfig} {
+		if v != "" {
+			args = append(args, flag+"="+v)
+		}
+	}
+	sort.Strings(args)
+	return args
+}
+
+// run executes a kubectl command, retrying transient failures according to
+// s.Retry, until s.Timeout.
+//
+// The command is rebuilt for each attempt, since stdin is consumed. kubectl's
+// stderr is logged as it is written. If the command fails, the end of stderr
+// is appended to the output, since that is where kubectl reports errors.
+func (s *Settings) run(stdin []byte, args ...string) ([]byte, error) {
+	verb := "kubectl"
+	for _, a := range args {
+		if !strings.HasPrefix(a, "-") {
+			verb += " " + a
+			break
+		}
+	}
+	prefix := verb
+	if obj := objectName(stdin); obj != "" {
+		prefix += " " + obj
+	}
+	ctx, cancel := helm.WithTimeout(s.Timeout)
+	defer cancel()
+	start := time.Now()
+	out, err := s.Retry.Do(verb, func() ([]byte, error) {
+		c := s.command(args...)
+		if stdin != nil {
+			assignStdin(c, stdin)
+		}
+		out, err := (&helm.Stderr{Prefix: prefix}).ExecContext(ctx, c.Cmd)
+		if e, ok := err.(*helm.ExecError); ok && len(e.Tail) > 0 {
+			if len(out) > 0 && out[len(out)-1] != '\n' {
+				out = append(out, '\n')
+			}
+			out = append(out, strings.Join(e.Tail, "\n")...)
+		}
+		return out, err
+	})
+	metrics.Observe(metrics.Kubectl, verb, time.Since(start), err)
+	return out, err
+}
+
+// objectName returns the kind and name of the object in a manifest, e.g. "Pod/redis".
+func objectName(stdin []byte) string {
+	obj := struct {
+		Kind     string `json:"kind"`
+		Metadata struct {
+			Name         string `json:"name"`
+			GenerateName string `json:"generateName"`
+		} `json:"metadata"`
+	}{}
+	if len(stdin) == 0 || json.Unmarshal(stdin, &obj) != nil || obj.Kind == "" {
+		return ""
+	}
+	name := obj.Metadata.Name
+	if name == "" {
+		name = obj.Metadata.GenerateName
+	}
+	return obj.Kind + "/" + name
 }
 
 func assignStdin(cmd *cmd, in []byte) {
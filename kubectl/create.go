@@ -2,27 +2,15 @@ package kubectl
 
 // Create uploads a chart to Kubernetes
 func (r RealRunner) Create(stdin []byte, ns string) ([]byte, error) {
-	args := []string{"create", "-f", "-"}
-
-	if ns != "" {
-		args = append([]string{"--namespace=" + ns}, args...)
-	}
-
-	cmd := command(args...)
-	assignStdin(cmd, stdin)
-
-	return cmd.CombinedOutput()
+	s := r.settings()
+	return s.run(stdin, s.changeArgs(ns, "create")...)
 }
 
 // Create returns the commands to kubectl
 func (r PrintRunner) Create(stdin []byte, ns string) ([]byte, error) {
-	args := []string{"create", "-f", "-"}
-
-	if ns != "" {
-		args = append([]string{"--namespace=" + ns}, args...)
-	}
+	args := r.settings().changeArgs(ns, "create")
 
-	cmd := command(args...)
+	cmd := r.settings().command(args...)
 	assignStdin(cmd, stdin)
 
 	return []byte(cmd.String()), nil
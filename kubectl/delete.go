@@ -1,25 +1,21 @@
 package kubectl
 
-// Delete removes a chart from Kubernetes.
-func (r RealRunner) Delete(name, ktype, ns string) ([]byte, error) {
+import "strings"
 
-	args := []string{"delete", ktype, name}
+// IsNotFound reports whether kubectl's output says that a resource does not exist.
+func IsNotFound(out []byte) bool {
+	return strings.Contains(string(out), "NotFound") || strings.Contains(string(out), "not found")
+}
 
-	if ns != "" {
-		args = append([]string{"--namespace=" + ns}, args...)
-	}
-	return command(args...).CombinedOutput()
+// Delete removes a chart from Kubernetes.
+func (r RealRunner) Delete(name, ktype, ns string) ([]byte, error) {
+	s := r.settings()
+	return s.run(nil, s.deleteArgs(name, ktype, ns)...)
 }
 
 // Delete returns the commands to kubectl
 func (r PrintRunner) Delete(name, ktype, ns string) ([]byte, error) {
-
-	args := []string{"delete", ktype, name}
-
-	if ns != "" {
-		args = append([]string{"--namespace=" + ns}, args...)
-	}
-
-	cmd := command(args...)
+	s := r.settings()
+	cmd := s.command(s.deleteArgs(name, ktype, ns)...)
 	return []byte(cmd.String()), nil
 }
@@ -0,0 +1,53 @@
+package kubectl
+
+import (
+	"fmt"
+
+	"github.com/Masterminds/semver"
+)
+
+// serverDryRunFlag returns the flag that makes kubectl send a request to the
+// server without persisting it.
+func (s *Settings) serverDryRunFlag() (string, error) {
+	v, err := s.ClientVersion()
+	if err != nil {
+		return "", err
+	}
+	return dryRunFlagFor(v)
+}
+
+// dryRunFlagFor chooses the dry-run flag for a kubectl version.
+//
+// kubectl 1.18 and later take --dry-run=server. From 1.13 to 1.17 this was
+// --server-dry-run. Older versions cannot do a server-side dry run.
+func dryRunFlagFor(v *semver.Version) (string, error) {
+	major, minor := v.Major(), v.Minor()
+	switch {
+	case major > 1 || minor >= 18:
+		return "--dry-run=server", nil
+	case minor >= 13:
+		return "--server-dry-run", nil
+	}
+	return "", fmt.Errorf("kubectl %d.%d does not support server-side dry runs. Version 1.13 or later is required", major, minor)
+}
+
+// DryRun sends a chart to Kubernetes for validation, without persisting it
+func (r RealRunner) DryRun(stdin []byte, ns string) ([]byte, error) {
+	s := r.settings()
+	flag, err := s.serverDryRunFlag()
+	if err != nil {
+		return []byte(err.Error()), err
+	}
+	return s.run(stdin, s.changeArgs(ns, "apply", flag)...)
+}
+
+// DryRun returns the commands to kubectl
+func (r PrintRunner) DryRun(stdin []byte, ns string) ([]byte, error) {
+	s := r.settings()
+	args := s.changeArgs(ns, "apply", "--dry-run=server")
+
+	cmd := s.command(args...)
+	assignStdin(cmd, stdin)
+
+	return []byte(cmd.String()), nil
+}
@@ -0,0 +1,36 @@
+package kubectl
+
+import "testing"
+
+func TestDryRunFlagFor(t *testing.T) {
+	tests := []struct {
+		version, flag string
+	}{
+		{`Client Version: v1.28.2`, "--dry-run=server"},
+		{`Client Version: version.Info{Major:"1", Minor:"18", GitVersion:"v1.18.0"}`, "--dry-run=server"},
+		{`Client Version: version.Info{Major:"1", Minor:"15", GitVersion:"v1.15.3"}`, "--server-dry-run"},
+		{`Client Version: version.Info{Major:"1", Minor:"2", GitVersion:"v1.2.4"}`, ""},
+		{`garbage`, ""},
+	}
+	for _, tt := range tests {
+		var flag string
+		v, err := parseVersion([]byte(tt.version), "Client")
+		if err == nil {
+			flag, err = dryRunFlagFor(v)
+		}
+		if flag != tt.flag {
+			t.Errorf("Expected %q for %q, got %q", tt.flag, tt.version, flag)
+		}
+		if tt.flag == "" && err == nil {
+			t.Errorf("Expected an error for %q", tt.version)
+		}
+	}
+}
+
+func TestPrintDryRun(t *testing.T) {
+	out, _ := PrintRunner{}.DryRun([]byte("{}"), "default")
+	expected := "[CMD] kubectl --namespace=default apply --dry-run=server -f - < {}"
+	if string(out) != expected {
+		t.Errorf("Expected %q, got %q", expected, out)
+	}
+}
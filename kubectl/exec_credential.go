@@ -0,0 +1,70 @@
+package kubectl
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// execConfig is the exec credential plugin of a kubeconfig user, a command
+// that prints the credentials to use, such as the token of a cloud provider.
+type execConfig struct {
+	APIVersion string   `yaml:"apiVersion"`
+	Command    string   `yaml:"command"`
+	Args       []string `yaml:"args"`
+	Env        []struct {
+		Name  string `yaml:"name"`
+		Value string `yaml:"value"`
+	} `yaml:"env"`
+}
+
+// execStatus is the status of the ExecCredential that a plugin prints. The
+// certificate and key are PEM, not base64.
+type execStatus struct {
+	Token                 string `json:"token"`
+	ClientCertificateData string `json:"clientCertificateData"`
+	ClientKeyData         string `json:"clientKeyData"`
+}
+
+// credential runs the plugin, as kubectl does, and returns the credentials
+// that it prints. The plugin is not interactive: its stdin is not a
+// terminal, so it must not prompt.
+func (e *execConfig) credential() (*execStatus, error) {
+	if e.Command == "" {
+		return nil, fmt.Errorf("the exec plugin has no command")
+	}
+	info, err := json.Marshal(map[string]interface{}{
+		"apiVersion": e.APIVersion,
+		"kind":       "ExecCredential",
+		"spec":       map[string]interface{}{"interactive": false},
+	})
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(e.Command, e.Args...)
+	cmd.Env = append(os.Environ(), "KUBERNETES_EXEC_INFO="+string(info))
+	for _, v := range e.Env {
+		cmd.Env = append(cmd.Env, v.Name+"="+v.Value)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s failed: %s: %s", e.Command, err, msg)
+		}
+		return nil, fmt.Errorf("%s failed: %s", e.Command, err)
+	}
+	cred := struct {
+		Status *execStatus `json:"status"`
+	}{}
+	if err := json.Unmarshal(stdout.Bytes(), &cred); err != nil {
+		return nil, fmt.Errorf("could not read the ExecCredential that %s printed: %s", e.Command, err)
+	}
+	if cred.Status == nil || (cred.Status.Token == "" && cred.Status.ClientCertificateData == "") {
+		return nil, fmt.Errorf("%s printed no token or client certificate", e.Command)
+	}
+	return cred.Status, nil
+}
@@ -0,0 +1,83 @@
+package kubectl
+
+import "fmt"
+
+// FakeRunner implements Runner for unit tests, without a cluster.
+//
+// Every call is recorded in Calls, and returns Out and Err.
+type FakeRunner struct {
+	Out []byte
+	Err error
+	// Calls records each call, e.g. "create default" or "delete Pod redis default".
+	Calls []string
+	// Stdin records the input passed to each call that takes manifests.
+	Stdin [][]byte
+}
+
+func (r *FakeRunner) record(stdin []byte, format string, v ...interface{}) ([]byte, error) {
+	r.Calls = append(r.Calls, fmt.Sprintf(format, v...))
+	if stdin != nil {
+		r.Stdin = append(r.Stdin, stdin)
+	}
+	return r.Out, r.Err
+}
+
+// ClusterInfo records the call
+func (r *FakeRunner) ClusterInfo() ([]byte, error) {
+	return r.record(nil, "cluster-info")
+}
+
+// Apply records the call
+func (r *FakeRunner) Apply(stdin []byte, ns string) ([]byte, error) {
+	return r.record(stdin, "apply %s", ns)
+}
+
+// Create records the call
+func (r *FakeRunner) Create(stdin []byte, ns string) ([]byte, error) {
+	return r.record(stdin, "create %s", ns)
+}
+
+// Replace records the call
+func (r *FakeRunner) Replace(stdin []byte, ns string) ([]byte, error) {
+	return r.record(stdin, "replace %s", ns)
+}
+
+// Delete records the call
+func (r *FakeRunner) Delete(name, ktype, ns string) ([]byte, error) {
+	return r.record(nil, "delete %s %s %s", ktype, name, ns)
+}
+
+// Get records the call
+func (r *FakeRunner) Get(stdin []byte, ns string) ([]byte, error) {
+	return r.record(stdin, "get %s", ns)
+}
+
+// GetObject records the call
+func (r *FakeRunner) GetObject(name, ktype, ns string) ([]byte, error) {
+	return r.record(nil, "get %s %s %s", ktype, name, ns)
+}
+
+// CanI records the call
+func (r *FakeRunner) CanI(verb, kind, ns string) ([]byte, error) {
+	return r.record(nil, "can-i %s %s %s", verb, kind, ns)
+}
+
+// List records the call
+func (r *FakeRunner) List(kinds, selector, ns string) ([]byte, error) {
+	return r.record(nil, "list %s %s %s", kinds, selector, ns)
+}
+
+// Logs records the call
+func (r *FakeRunner) Logs(name, ktype, ns string) ([]byte, error) {
+	return r.record(nil, "logs %s %s %s", ktype, name, ns)
+}
+
+// DryRun records the call
+func (r *FakeRunner) DryRun(stdin []byte, ns string) ([]byte, error) {
+	return r.record(stdin, "dry-run %s", ns)
+}
+
+// Version records the call
+func (r *FakeRunner) Version() ([]byte, error) {
+	return r.record(nil, "version")
+}
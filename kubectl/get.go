@@ -7,10 +7,7 @@ func (r RealRunner) Get(stdin []byte, ns string) ([]byte, error) {
 	if ns != "" {
 		args = append([]string{"--namespace=" + ns}, args...)
 	}
-	cmd := command(args...)
-	assignStdin(cmd, stdin)
-
-	return cmd.CombinedOutput()
+	return r.settings().run(stdin, args...)
 }
 
 // Get returns the commands to kubectl
@@ -20,8 +17,30 @@ func (r PrintRunner) Get(stdin []byte, ns string) ([]byte, error) {
 	if ns != "" {
 		args = append([]string{"--namespace=" + ns}, args...)
 	}
-	cmd := command(args...)
+	cmd := r.settings().command(args...)
 	assignStdin(cmd, stdin)
 
 	return []byte(cmd.String()), nil
 }
+
+// GetObject returns a single Kubernetes resource as JSON
+func (r RealRunner) GetObject(name, ktype, ns string) ([]byte, error) {
+	args := []string{"get", ktype, name, "-o", "json"}
+
+	if ns != "" {
+		args = append([]string{"--namespace=" + ns}, args...)
+	}
+	return r.settings().run(nil, args...)
+}
+
+// GetObject returns the commands to kubectl
+func (r PrintRunner) GetObject(name, ktype, ns string) ([]byte, error) {
+	args := []string{"get", ktype, name, "-o", "json"}
+
+	if ns != "" {
+		args = append([]string{"--namespace=" + ns}, args...)
+	}
+
+	cmd := r.settings().command(args...)
+	return []byte(cmd.String()), nil
+}
@@ -0,0 +1,233 @@
+package kubectl
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// kindInfo is how the API server serves a built-in kind.
+type kindInfo struct {
+	// version is the apiVersion of a manifest that does not give one.
+	version string
+	// resource is the name of the kind in API paths. If it is empty, it is
+	// the plural of the kind.
+	resource string
+	// cluster is set if the resources of the kind do not belong to a namespace.
+	cluster bool
+}
+
+// kinds lists the built-in kinds of Kubernetes. The kinds that a
+// manifest of the extensions API group could use default to that group.
+var kinds = map[string]kindInfo{
+	"Binding":               {version: "v1"},
+	"ComponentStatus":       {version: "v1", cluster: true},
+	"ConfigMap":             {version: "v1"},
+	"Endpoints":             {version: "v1", resource: "endpoints"},
+	"Event":                 {version: "v1"},
+	"LimitRange":            {version: "v1"},
+	"Namespace":             {version: "v1", cluster: true},
+	"Node":                  {version: "v1", cluster: true},
+	"PersistentVolume":      {version: "v1", cluster: true},
+	"PersistentVolumeClaim": {version: "v1"},
+	"Pod":                   {version: "v1"},
+	"PodTemplate":           {version: "v1"},
+	"ReplicationController": {version: "v1"},
+	"ResourceQuota":         {version: "v1"},
+	"Secret":                {version: "v1"},
+	"Service":               {version: "v1"},
+	"ServiceAccount":        {version: "v1"},
+
+	"DaemonSet":               {version: "extensions/v1beta1"},
+	"Deployment":              {version: "extensions/v1beta1"},
+	"HorizontalPodAutoscaler": {version: "extensions/v1beta1"},
+	"Ingress":                 {version: "extensions/v1beta1"},
+	"Job":                     {version: "extensions/v1beta1"},
+	"NetworkPolicy":           {version: "extensions/v1beta1"},
+	"PodSecurityPolicy":       {version: "extensions/v1beta1", cluster: true},
+	"ReplicaSet":              {version: "extensions/v1beta1"},
+
+	"ControllerRevision": {version: "apps/v1"},
+	"StatefulSet":        {version: "apps/v1"},
+	"CronJob":            {version: "batch/v1"},
+
+	"MutatingAdmissionPolicy":          {version: "admissionregistration.k8s.io/v1alpha1", cluster: true},
+	"MutatingAdmissionPolicyBinding":   {version: "admissionregistration.k8s.io/v1alpha1", cluster: true},
+	"MutatingWebhookConfiguration":     {version: "admissionregistration.k8s.io/v1", cluster: true},
+	"ValidatingAdmissionPolicy":        {version: "admissionregistration.k8s.io/v1", cluster: true},
+	"ValidatingAdmissionPolicyBinding": {version: "admissionregistration.k8s.io/v1", cluster: true},
+	"ValidatingWebhookConfiguration":   {version: "admissionregistration.k8s.io/v1", cluster: true},
+	"CustomResourceDefinition":         {version: "apiextensions.k8s.io/v1", cluster: true},
+	"APIService":                       {version: "apiregistration.k8s.io/v1", cluster: true},
+
+	"SelfSubjectReview":        {version: "authentication.k8s.io/v1", cluster: true},
+	"TokenReview":              {version: "authentication.k8s.io/v1", cluster: true},
+	"LocalSubjectAccessReview": {version: "authorization.k8s.io/v1"},
+	"SelfSubjectAccessReview":  {version: "authorization.k8s.io/v1", cluster: true},
+	"SelfSubjectRulesReview":   {version: "authorization.k8s.io/v1", cluster: true},
+	"SubjectAccessReview":      {version: "authorization.k8s.io/v1", cluster: true},
+
+	"CertificateSigningRequest":  {version: "certificates.k8s.io/v1", cluster: true},
+	"ClusterTrustBundle":         {version: "certificates.k8s.io/v1beta1", cluster: true},
+	"Lease":                      {version: "coordination.k8s.io/v1"},
+	"EndpointSlice":              {version: "discovery.k8s.io/v1"},
+	"FlowSchema":                 {version: "flowcontrol.apiserver.k8s.io/v1", cluster: true},
+	"PriorityLevelConfiguration": {version: "flowcontrol.apiserver.k8s.io/v1", cluster: true},
+	"IngressClass":               {version: "networking.k8s.io/v1", cluster: true},
+	"IPAddress":                  {version: "networking.k8s.io/v1", cluster: true},
+	"ServiceCIDR":                {version: "networking.k8s.io/v1", cluster: true},
+	"RuntimeClass":               {version: "node.k8s.io/v1", cluster: true},
+	"PodDisruptionBudget":        {version: "policy/v1"},
+	"ClusterRole":                {version: "rbac.authorization.k8s.io/v1", cluster: true},
+	"ClusterRoleBinding":         {version: "rbac.authorization.k8s.io/v1", cluster: true},
+	"Role":                       {version: "rbac.authorization.k8s.io/v1"},
+	"RoleBinding":                {version: "rbac.authorization.k8s.io/v1"},
+	"DeviceClass":                {version: "resource.k8s.io/v1", cluster: true},
+	"ResourceClaim":              {version: "resource.k8s.io/v1"},
+	"ResourceClaimTemplate":      {version: "resource.k8s.io/v1"},
+	"ResourceSlice":              {version: "resource.k8s.io/v1", cluster: true},
+	"PriorityClass":              {version: "scheduling.k8s.io/v1", cluster: true},
+	"CSIDriver":                  {version: "storage.k8s.io/v1", cluster: true},
+	"CSINode":                    {version: "storage.k8s.io/v1", cluster: true},
+	"CSIStorageCapacity":         {version: "storage.k8s.io/v1"},
+	"StorageClass":               {version: "storage.k8s.io/v1", cluster: true},
+	"VolumeAttachment":           {version: "storage.k8s.io/v1", cluster: true},
+	"VolumeAttributesClass":      {version: "storage.k8s.io/v1", cluster: true},
+	"StorageVersionMigration":    {version: "storagemigration.k8s.io/v1alpha1", cluster: true},
+}
+
+// ClusterScoped reports whether the resources of a built-in kind do not
+// belong to a namespace, such as Namespaces and ClusterRoles.
+//
+// The scope of a custom kind is known only to the API server, so it is
+// reported as namespaced.
+func ClusterScoped(kind string) bool {
+	return kinds[kind].cluster
+}
+
+// mapping is where the API server serves the resources of a kind.
+type mapping struct {
+	apiVersion, resource string
+	cluster              bool
+}
+
+// staticMapping returns where a kind is served, from kinds. An empty
+// apiVersion is the default of the kind, or v1.
+func staticMapping(apiVersion, kind string) mapping {
+	k, ok := kinds[kind]
+	if !ok {
+		k.version = "v1"
+	}
+	if apiVersion == "" {
+		apiVersion = k.version
+	}
+	if k.resource == "" {
+		k.resource = plural(kind)
+	}
+	return mapping{apiVersion: apiVersion, resource: k.resource, cluster: k.cluster}
+}
+
+// group returns the API group of the mapping, which is empty for the core group.
+func (m mapping) group() string {
+	if i := strings.Index(m.apiVersion, "/"); i >= 0 {
+		return m.apiVersion[:i]
+	}
+	return ""
+}
+
+// path returns the API path of the resources in namespace ns, and of the
+// named resource if name is set.
+func (m mapping) path(ns, name string) string {
+	p := "/api/" + m.apiVersion
+	if strings.Contains(m.apiVersion, "/") {
+		p = "/apis/" + m.apiVersion
+	}
+	if !m.cluster {
+		p += "/namespaces/" + ns
+	}
+	p += "/" + m.resource
+	if name != "" {
+		p += "/" + name
+	}
+	return p
+}
+
+// resourcePath returns the API path for a kind, and for a named resource if
+// name is set, from kinds alone.
+func resourcePath(apiVersion, kind, ns, name string) string {
+	return staticMapping(apiVersion, kind).path(ns, name)
+}
+
+// plural returns the resource name for a kind that kinds does not name,
+// e.g. "ingresses" for "Ingress".
+func plural(kind string) string {
+	k := strings.ToLower(kind)
+	switch {
+	case strings.HasSuffix(k, "s"), strings.HasSuffix(k, "x"),
+		strings.HasSuffix(k, "ch"), strings.HasSuffix(k, "sh"):
+		return k + "es"
+	case strings.HasSuffix(k, "y") && !strings.HasSuffix(k, "ay") && !strings.HasSuffix(k, "ey") && !strings.HasSuffix(k, "oy"):
+		return strings.TrimSuffix(k, "y") + "ies"
+	}
+	return k + "s"
+}
+
+// apiResource is a resource in the discovery document of an API group version.
+type apiResource struct {
+	Name       string `json:"name"`
+	Kind       string `json:"kind"`
+	Namespaced bool   `json:"namespaced"`
+}
+
+// mapping returns where the API server serves a kind of apiVersion, or of
+// the default version of the kind if apiVersion is empty.
+//
+// The resource name and scope are asked of the API server's discovery
+// documents, once for each apiVersion, so that custom kinds are found too.
+// If the server does not list the kind, they are taken from kinds.
+func (r *NativeRunner) mapping(apiVersion, kind string) mapping {
+	m := staticMapping(apiVersion, kind)
+	for _, res := range r.discover(m.apiVersion) {
+		if res.Kind == kind && !strings.Contains(res.Name, "/") {
+			m.resource, m.cluster = res.Name, !res.Namespaced
+			break
+		}
+	}
+	return m
+}
+
+// discover returns the resources that the API server serves in apiVersion,
+// or nothing if it cannot say. The answer is kept for the life of the runner.
+func (r *NativeRunner) discover(apiVersion string) []apiResource {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if list, ok := r.discovered[apiVersion]; ok {
+		return list
+	}
+	if r.discovered == nil {
+		r.discovered = map[string][]apiResource{}
+	}
+	p := "/api/" + apiVersion
+	if strings.Contains(apiVersion, "/") {
+		p = "/apis/" + apiVersion
+	}
+	doc := struct {
+		Resources []apiResource `json:"resources"`
+	}{}
+	code, b, err := r.do("GET", p, nil)
+	if err != nil {
+		// The request that needs the mapping reports the error.
+		return nil
+	}
+	if code == http.StatusOK {
+		json.Unmarshal(b, &doc)
+	}
+	r.discovered[apiVersion] = doc.Resources
+	return doc.Resources
+}
+
+// resourcePath returns the API path for a kind, and for a named resource if
+// name is set, as the API server serves it.
+func (r *NativeRunner) resourcePath(apiVersion, kind, ns, name string) string {
+	return r.mapping(apiVersion, kind).path(ns, name)
+}
@@ -0,0 +1,348 @@
+package kubectl
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/helm/helm-classic/helmpath"
+	"gopkg.in/yaml.v2"
+)
+
+// Config describes how to connect to a Kubernetes API server.
+type Config struct {
+	// Server is the URL of the API server.
+	Server string
+	// Namespace is used when neither the caller nor the manifest names one.
+	Namespace string
+	// Token is a bearer token. If it is empty, Username and Password are
+	// used for basic authentication, if they are set.
+	Token    string
+	Username string
+	Password string
+	// TLS holds the CA and client certificates.
+	TLS *tls.Config
+}
+
+// kubeconfig is the subset of kubectl's configuration file that Helm Classic reads.
+type kubeconfig struct {
+	CurrentContext string `yaml:"current-context"`
+	Clusters       []struct {
+		Name    string `yaml:"name"`
+		Cluster struct {
+			Server                   string `yaml:"server"`
+			CertificateAuthority     string `yaml:"certificate-authority"`
+			CertificateAuthorityData string `yaml:"certificate-authority-data"`
+			InsecureSkipTLSVerify    bool   `yaml:"insecure-skip-tls-verify"`
+		} `yaml:"cluster"`
+	} `yaml:"clusters"`
+	Users []struct {
+		Name string `yaml:"name"`
+		User struct {
+			Token                 string      `yaml:"token"`
+			Username              string      `yaml:"username"`
+			Password              string      `yaml:"password"`
+			ClientCertificate     string      `yaml:"client-certificate"`
+			ClientCertificateData string      `yaml:"client-certificate-data"`
+			ClientKey             string      `yaml:"client-key"`
+			ClientKeyData         string      `yaml:"client-key-data"`
+			Exec                  *execConfig `yaml:"exec"`
+			AuthProvider          *struct {
+				Name string `yaml:"name"`
+			} `yaml:"auth-provider"`
+		} `yaml:"user"`
+	} `yaml:"users"`
+	Contexts []struct {
+		Name    string `yaml:"name"`
+		Context struct {
+			Cluster   string `yaml:"cluster"`
+			User      string `yaml:"user"`
+			Namespace string `yaml:"namespace"`
+		} `yaml:"context"`
+	} `yaml:"contexts"`
+}
+
+// DefaultKubeconfig returns the kubeconfig files that kubectl would read.
+//
+// This is s.Kubeconfig if it is set, then $KUBECONFIG, which may list
+// several files, then ~/.kube/config.
+func (s *Settings) DefaultKubeconfig() string {
+	if s.Kubeconfig != "" {
+		return s.Kubeconfig
+	}
+	if kc := os.Getenv("KUBECONFIG"); kc != "" {
+		return kc
+	}
+	return filepath.Join(helmpath.UserHome(), ".kube", "config")
+}
+
+// CheckKubeconfig makes sure the kubeconfig files can be read.
+//
+// A missing file is only an error if it was named by s.Kubeconfig, since
+// kubectl ignores missing files in $KUBECONFIG and the default file is
+// optional.
+func (s *Settings) CheckKubeconfig() error {
+	_, err := loadKubeconfig(s.DefaultKubeconfig())
+	if err != nil && (s.Kubeconfig != "" || !os.IsNotExist(err)) {
+		return err
+	}
+	return nil
+}
+
+// loadKubeconfig reads and merges a list of kubeconfig files.
+//
+// As with kubectl, the first file to set the current context, or to define a
+// cluster, user, or context of a given name, wins. Files that do not exist
+// are skipped, but at least one must exist.
+func loadKubeconfig(paths string) (*kubeconfig, error) {
+	merged := &kubeconfig{}
+	var missing error
+	loaded := false
+	for _, filename := range filepath.SplitList(paths) {
+		b, err := ioutil.ReadFile(filename)
+		if os.IsNotExist(err) {
+			if missing == nil {
+				missing = err
+			}
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		kc := &kubeconfig{}
+		if err := yaml.Unmarshal(b, kc); err != nil {
+			return nil, fmt.Errorf("could not parse %s: %s", filename, err)
+		}
+		kc.resolvePaths(filepath.Dir(filename))
+		merged.merge(kc)
+		loaded = true
+	}
+	if !loaded {
+		return nil, missing
+	}
+	return merged, nil
+}
+
+// resolvePaths makes certificate paths absolute. Relative paths are relative
+// to the directory of the kubeconfig file.
+func (kc *kubeconfig) resolvePaths(dir string) {
+	abs := func(file string) string {
+		if file == "" || filepath.IsAbs(file) {
+			return file
+		}
+		return filepath.Join(dir, file)
+	}
+	for i := range kc.Clusters {
+		c := &kc.Clusters[i].Cluster
+		c.CertificateAuthority = abs(c.CertificateAuthority)
+	}
+	for i := range kc.Users {
+		u := &kc.Users[i].User
+		u.ClientCertificate = abs(u.ClientCertificate)
+		u.ClientKey = abs(u.ClientKey)
+		// As with kubectl, a bare command is looked up in $PATH.
+		if u.Exec != nil && strings.ContainsRune(u.Exec.Command, filepath.Separator) {
+			u.Exec.Command = abs(u.Exec.Command)
+		}
+	}
+}
+
+// merge adds the settings of other that kc does not already have.
+func (kc *kubeconfig) merge(other *kubeconfig) {
+	if kc.CurrentContext == "" {
+		kc.CurrentContext = other.CurrentContext
+	}
+	// Lookups take the first entry of a name, so appending keeps the first
+	// definition.
+	kc.Clusters = append(kc.Clusters, other.Clusters...)
+	kc.Users = append(kc.Users, other.Users...)
+	kc.Contexts = append(kc.Contexts, other.Contexts...)
+}
+
+// LoadConfig reads the connection settings for a context from a list of
+// kubeconfig files, separated as in $KUBECONFIG.
+//
+// If context is empty, the file's current context is used. If cluster or
+// user is set, it replaces the one named by the context, as kubectl's
+// --cluster and --user flags do.
+//
+// A user whose credentials come from an exec plugin, as those of cloud
+// providers do, has the plugin run. A user of an auth provider is an error,
+// since only kubectl implements them.
+func LoadConfig(paths, context, cluster, user string) (*Config, error) {
+	kc, err := loadKubeconfig(paths)
+	if err != nil {
+		return nil, err
+	}
+	if context == "" {
+		context = kc.CurrentContext
+	}
+	for _, c := range kc.Contexts {
+		if c.Name != context {
+			continue
+		}
+		if cluster == "" {
+			cluster = c.Context.Cluster
+		}
+		if user == "" {
+			user = c.Context.User
+		}
+		cfg := &Config{Namespace: c.Context.Namespace, TLS: &tls.Config{}}
+		found := false
+		for _, cl := range kc.Clusters {
+			if cl.Name != cluster {
+				continue
+			}
+			found = true
+			cfg.Server = cl.Cluster.Server
+			cfg.TLS.InsecureSkipVerify = cl.Cluster.InsecureSkipTLSVerify
+			ca, err := fileOrData(cl.Cluster.CertificateAuthority, cl.Cluster.CertificateAuthorityData)
+			if err != nil {
+				return nil, err
+			}
+			if ca != nil {
+				cfg.TLS.RootCAs = x509.NewCertPool()
+				if !cfg.TLS.RootCAs.AppendCertsFromPEM(ca) {
+					return nil, fmt.Errorf("no certificates in the certificate authority of cluster %s", cl.Name)
+				}
+			}
+			break
+		}
+		if !found {
+			return nil, fmt.Errorf("no cluster named %s in %s", cluster, paths)
+		}
+		for _, u := range kc.Users {
+			if u.Name != user {
+				continue
+			}
+			cfg.Token, cfg.Username, cfg.Password = u.User.Token, u.User.Username, u.User.Password
+			cert, err := fileOrData(u.User.ClientCertificate, u.User.ClientCertificateData)
+			if err != nil {
+				return nil, err
+			}
+			key, err := fileOrData(u.User.ClientKey, u.User.ClientKeyData)
+			if err != nil {
+				return nil, err
+			}
+			if ap := u.User.AuthProvider; ap != nil && cfg.Token == "" {
+				return nil, fmt.Errorf("user %s authenticates with the %s auth provider, which only kubectl supports. Use --use-kubectl, or a kubeconfig with an exec plugin", u.Name, ap.Name)
+			}
+			if u.User.Exec != nil && cfg.Token == "" && cert == nil {
+				st, err := u.User.Exec.credential()
+				if err != nil {
+					return nil, fmt.Errorf("could not get the credentials of user %s: %s", u.Name, err)
+				}
+				cfg.Token = st.Token
+				if st.ClientCertificateData != "" {
+					cert, key = []byte(st.ClientCertificateData), []byte(st.ClientKeyData)
+				}
+			}
+			if cert != nil && key != nil {
+				pair, err := tls.X509KeyPair(cert, key)
+				if err != nil {
+					return nil, fmt.Errorf("could not load client certificate for user %s: %s", u.Name, err)
+				}
+				cfg.TLS.Certificates = []tls.Certificate{pair}
+			}
+			break
+		}
+		return cfg, nil
+	}
+	if context == "" {
+		return nil, fmt.Errorf("%s has no current context", paths)
+	}
+	return nil, fmt.Errorf("no context named %s in %s", context, paths)
+}
+
+// fileOrData returns the contents of a file, or the base64-decoded data if no file is named.
+func fileOrData(file, data string) ([]byte, error) {
+	switch {
+	case file != "":
+		return ioutil.ReadFile(file)
+	case data != "":
+		return base64.StdEncoding.DecodeString(strings.TrimSpace(data))
+	}
+	return nil, nil
+}
+
+// httpClient returns an HTTP client for the configuration.
+func (c *Config) httpClient() *http.Client {
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: c.TLS, Proxy: http.ProxyFromEnvironment}}
+}
+
+// authorize adds the configured credentials to a request.
+func (c *Config) authorize(req *http.Request) {
+	switch {
+	case c.Token != "":
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	case c.Username != "":
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+}
+
+// ActiveContext describes the kubeconfig context that Kubernetes commands will use.
+//
+// This is s.Context if it is set, or else the current context of the
+// default kubeconfig file. Any Cluster and User overrides are included.
+func (s *Settings) ActiveContext() string {
+	ctx := s.Context
+	if ctx == "" {
+		if kc, err := loadKubeconfig(s.DefaultKubeconfig()); err == nil {
+			ctx = kc.CurrentContext
+		}
+	}
+	if ctx == "" {
+		ctx = "(none)"
+	}
+	overrides := []string{}
+	if s.Cluster != "" {
+		overrides = append(overrides, "cluster "+s.Cluster)
+	}
+	if s.User != "" {
+		overrides = append(overrides, "user "+s.User)
+	}
+	if len(overrides) > 0 {
+		ctx += " (" + strings.Join(overrides, ", ") + ")"
+	}
+	return ctx
+}
+
+// Identity names who Kubernetes commands run as, in the kubeconfig.
+type Identity struct {
+	Context string
+	Cluster string
+	User    string
+}
+
+// ActiveIdentity returns the context, cluster, and user that Kubernetes
+// commands will use: s.Context, s.Cluster, and s.User if they are set, or
+// else those of the default kubeconfig file. A name that cannot be found is
+// empty.
+func (s *Settings) ActiveIdentity() Identity {
+	id := Identity{Context: s.Context, Cluster: s.Cluster, User: s.User}
+	kc, err := loadKubeconfig(s.DefaultKubeconfig())
+	if err != nil {
+		return id
+	}
+	if id.Context == "" {
+		id.Context = kc.CurrentContext
+	}
+	for _, c := range kc.Contexts {
+		if c.Name != id.Context {
+			continue
+		}
+		if id.Cluster == "" {
+			id.Cluster = c.Context.Cluster
+		}
+		if id.User == "" {
+			id.User = c.Context.User
+		}
+		break
+	}
+	return id
+}
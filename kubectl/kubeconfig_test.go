@@ -0,0 +1,212 @@
+package kubectl
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testKubeconfig = `apiVersion: v1
+kind: Config
+current-context: dev
+clusters:
+- name: dev-cluster
+  cluster:
+    server: https://dev.example.com
+    insecure-skip-tls-verify: true
+- name: prod-cluster
+  cluster:
+    server: https://prod.example.com
+contexts:
+- name: dev
+  context:
+    cluster: dev-cluster
+    user: dev-user
+    namespace: sandbox
+- name: prod
+  context:
+    cluster: prod-cluster
+    user: prod-user
+users:
+- name: dev-user
+  user:
+    token: dev-token
+- name: prod-user
+  user:
+    username: admin
+    password: hunter2
+`
+
+func TestLoadConfig(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "kubeconfig")
+	defer os.RemoveAll(dir)
+	f := filepath.Join(dir, "config")
+	ioutil.WriteFile(f, []byte(testKubeconfig), 0600)
+
+	cfg, err := LoadConfig(f, "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Server != "https://dev.example.com" || cfg.Token != "dev-token" || cfg.Namespace != "sandbox" || !cfg.TLS.InsecureSkipVerify {
+		t.Errorf("Unexpected config for the current context: %+v", cfg)
+	}
+
+	cfg, err = LoadConfig(f, "prod", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Server != "https://prod.example.com" || cfg.Username != "admin" || cfg.TLS.InsecureSkipVerify {
+		t.Errorf("Unexpected config for prod: %+v", cfg)
+	}
+
+	if _, err := LoadConfig(f, "staging", "", ""); err == nil {
+		t.Errorf("Expected an error for an unknown context")
+	}
+
+	cfg, err = LoadConfig(f, "dev", "prod-cluster", "prod-user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Server != "https://prod.example.com" || cfg.Username != "admin" || cfg.Namespace != "sandbox" {
+		t.Errorf("Expected --cluster and --user to override the context: %+v", cfg)
+	}
+	if _, err := LoadConfig(f, "dev", "nope", ""); err == nil {
+		t.Errorf("Expected an error for an unknown cluster")
+	}
+}
+
+func TestActiveContext(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "kubeconfig")
+	defer os.RemoveAll(dir)
+	f := filepath.Join(dir, "config")
+	ioutil.WriteFile(f, []byte(testKubeconfig), 0600)
+	defer os.Setenv("KUBECONFIG", os.Getenv("KUBECONFIG"))
+	os.Setenv("KUBECONFIG", f)
+	s := &Settings{}
+
+	if c := s.ActiveContext(); c != "dev" {
+		t.Errorf("Expected the current context, got %q", c)
+	}
+	s.Context, s.Cluster = "prod", "dev-cluster"
+	if c := s.ActiveContext(); c != "prod (cluster dev-cluster)" {
+		t.Errorf("Expected the selected context, got %q", c)
+	}
+}
+
+func TestActiveIdentity(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "kubeconfig")
+	defer os.RemoveAll(dir)
+	f := filepath.Join(dir, "config")
+	ioutil.WriteFile(f, []byte(testKubeconfig), 0600)
+	defer os.Setenv("KUBECONFIG", os.Getenv("KUBECONFIG"))
+	os.Setenv("KUBECONFIG", f)
+	s := &Settings{}
+
+	if id := s.ActiveIdentity(); id != (Identity{Context: "dev", Cluster: "dev-cluster", User: "dev-user"}) {
+		t.Errorf("Expected the current context, got %+v", id)
+	}
+	s.Context, s.User = "prod", "dev-user"
+	if id := s.ActiveIdentity(); id != (Identity{Context: "prod", Cluster: "prod-cluster", User: "dev-user"}) {
+		t.Errorf("Expected the selected context and user, got %+v", id)
+	}
+}
+
+func TestLoadConfigMerge(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "kubeconfig")
+	defer os.RemoveAll(dir)
+	first := filepath.Join(dir, "first")
+	second := filepath.Join(dir, "second")
+	ioutil.WriteFile(first, []byte(`current-context: prod
+users:
+- name: prod-user
+  user:
+    token: override-token
+`), 0600)
+	ioutil.WriteFile(second, []byte(testKubeconfig), 0600)
+
+	paths := strings.Join([]string{filepath.Join(dir, "missing"), first, second}, string(filepath.ListSeparator))
+	cfg, err := LoadConfig(paths, "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Server != "https://prod.example.com" || cfg.Token != "override-token" {
+		t.Errorf("Expected the first file to win: %+v", cfg)
+	}
+
+	if _, err := LoadConfig(filepath.Join(dir, "missing"), "", "", ""); err == nil {
+		t.Errorf("Expected an error when no file exists")
+	}
+}
+
+func TestLoadConfigExec(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "kubeconfig")
+	defer os.RemoveAll(dir)
+	os.MkdirAll(filepath.Join(dir, "bin"), 0755)
+	plugin := "#!/bin/sh\ncat <<EOF\n{\"kind\": \"ExecCredential\", \"status\": {\"token\": \"$TOKEN_PREFIX-token\"}}\nEOF\n"
+	ioutil.WriteFile(filepath.Join(dir, "bin", "token"), []byte(plugin), 0755)
+	f := filepath.Join(dir, "config")
+	ioutil.WriteFile(f, []byte(`current-context: cloud
+clusters:
+- name: cloud
+  cluster:
+    server: https://cloud.example.com
+contexts:
+- name: cloud
+  context:
+    cluster: cloud
+    user: cloud-user
+- name: legacy
+  context:
+    cluster: cloud
+    user: legacy-user
+users:
+- name: cloud-user
+  user:
+    exec:
+      apiVersion: client.authentication.k8s.io/v1beta1
+      command: ./bin/token
+      env:
+      - name: TOKEN_PREFIX
+        value: exec
+- name: legacy-user
+  user:
+    auth-provider:
+      name: gcp
+`), 0600)
+
+	cfg, err := LoadConfig(f, "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Token != "exec-token" {
+		t.Errorf("Expected the token of the exec plugin, got %+v", cfg)
+	}
+	if _, err := LoadConfig(f, "legacy", "", ""); err == nil || !strings.Contains(err.Error(), "--use-kubectl") {
+		t.Errorf("Expected an auth provider to need kubectl, got %v", err)
+	}
+}
+
+func TestCheckKubeconfig(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "kubeconfig")
+	defer os.RemoveAll(dir)
+	defer os.Setenv("KUBECONFIG", os.Getenv("KUBECONFIG"))
+	s := &Settings{}
+
+	os.Setenv("KUBECONFIG", filepath.Join(dir, "missing"))
+	if err := s.CheckKubeconfig(); err != nil {
+		t.Errorf("Expected a missing file in $KUBECONFIG to be ignored: %s", err)
+	}
+
+	s.Kubeconfig = filepath.Join(dir, "missing")
+	if err := s.CheckKubeconfig(); err == nil {
+		t.Errorf("Expected an error for a missing --kubeconfig")
+	}
+
+	s.Kubeconfig = filepath.Join(dir, "bad")
+	ioutil.WriteFile(s.Kubeconfig, []byte("clusters: {"), 0600)
+	if err := s.CheckKubeconfig(); err == nil {
+		t.Errorf("Expected an error for a malformed kubeconfig")
+	}
+}
@@ -1,7 +1,111 @@
 package kubectl
 
-// Path is the path of the kubectl binary
-var Path = "kubectl"
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultPath is the kubectl binary used if none is configured.
+const DefaultPath = "kubectl"
+
+// DefaultTimeout is the Timeout unless --kube-timeout says otherwise.
+const DefaultTimeout = 2 * time.Minute
+
+// Settings are how a Runner reaches the cluster, and the flags that it gives
+// kubectl.
+//
+// Each runner may have its own, so that runners for different clusters can
+// be used in one process. A runner without any uses Defaults.
+type Settings struct {
+	// Path is the path of the kubectl binary. A bare name is looked up on $PATH.
+	Path string
+	// Kubeconfig is the kubeconfig file to use instead of $KUBECONFIG or
+	// ~/.kube/config. It is given to kubectl as --kubeconfig.
+	Kubeconfig string
+	// Context, Cluster, and User select a kubeconfig context, and override
+	// its cluster and user. They are passed to every kubectl command, and the
+	// native client reads its connection from the same context. Empty values
+	// leave the choice to the kubeconfig file.
+	Context string
+	Cluster string
+	User    string
+	// Timeout bounds each request to the cluster, with its retries, whether
+	// it is a kubectl command or a request of the native client. Zero is no
+	// bound.
+	Timeout time.Duration
+	// Retry is how requests that fail for transient reasons are retried.
+	Retry RetryPolicy
+	// UserArgs are the extra flags of the configuration file. They are
+	// trusted, and not checked against ChartFlags. The native client does
+	// not run kubectl, and ignores them.
+	UserArgs Args
+	// ChartArgs are the extra flags that the chart being installed or
+	// uninstalled declares in its Chart.yaml, checked with CheckChartArgs.
+	// They are merged with UserArgs and the flags of helmc, which win over
+	// them. The native client ignores them too.
+	ChartArgs Args
+	// GracePeriod is the number of seconds that deleted resources are given
+	// to terminate. If it is negative, each resource's default is used.
+	GracePeriod int
+}
+
+// DefaultSettings returns the settings that helmc starts with.
+func DefaultSettings() Settings {
+	return Settings{
+		Path:        DefaultPath,
+		Timeout:     DefaultTimeout,
+		Retry:       RetryPolicy{Retries: 3, Backoff: 500 * time.Millisecond, MaxBackoff: 10 * time.Second},
+		GracePeriod: -1,
+	}
+}
+
+// Defaults are the settings of the runners that have none. helmc sets them
+// from its global flags and its configuration file.
+var Defaults = DefaultSettings()
+
+// orDefaults returns s, or Defaults if s is nil.
+func (s *Settings) orDefaults() *Settings {
+	if s == nil {
+		return &Defaults
+	}
+	return s
+}
+
+// SettingsOf returns the settings of a runner of this package, or Defaults
+// for a runner that has none, or that is of another package, such as a
+// FakeRunner.
+func SettingsOf(r Runner) *Settings {
+	if r, ok := r.(interface{ settings() *Settings }); ok {
+		return r.settings()
+	}
+	return &Defaults
+}
+
+// WithSettings returns a runner like r, with the settings s. A runner of
+// another package, which has no settings, is returned as it is.
+func WithSettings(r Runner, s *Settings) Runner {
+	switch r := r.(type) {
+	case RealRunner:
+		return RealRunner{Settings: s}
+	case PrintRunner:
+		return PrintRunner{Settings: s}
+	case *NativeRunner:
+		return &NativeRunner{Config: r.Config, Settings: s}
+	}
+	return r
+}
+
+func (r RealRunner) settings() *Settings {
+	return r.Settings.orDefaults()
+}
+
+func (r PrintRunner) settings() *Settings {
+	return r.Settings.orDefaults()
+}
+
+func (r *NativeRunner) settings() *Settings {
+	return r.Settings.orDefaults()
+}
 
 // Runner is an interface to wrap kubectl convenience methods
 type Runner interface {
@@ -11,17 +115,58 @@ type Runner interface {
 	Apply([]byte, string) ([]byte, error)
 	// Create uploads a chart to Kubernetes
 	Create([]byte, string) ([]byte, error)
+	// Replace replaces resources in Kubernetes, which must already exist
+	Replace([]byte, string) ([]byte, error)
 	// Delete removes a chart from Kubernetes.
 	Delete(string, string, string) ([]byte, error)
 	// Get returns Kubernetes resources
 	Get([]byte, string) ([]byte, error)
+	// GetObject returns a single Kubernetes resource as JSON
+	GetObject(string, string, string) ([]byte, error)
+	// List returns the resources of some kinds that match a label selector,
+	// as a JSON List
+	List(string, string, string) ([]byte, error)
+	// CanI asks whether the user may do a verb to the resources of a kind.
+	// The output is "yes" or "no"
+	CanI(string, string, string) ([]byte, error)
+	// DryRun sends a chart to Kubernetes for validation, without persisting it
+	DryRun([]byte, string) ([]byte, error)
+	// Logs returns the logs of a Pod, or of the Pods of a Job
+	Logs(string, string, string) ([]byte, error)
+	// Version returns the Kubernetes version
+	Version() ([]byte, error)
 }
 
 // RealRunner implements Runner to execute kubectl commands
-type RealRunner struct{}
+type RealRunner struct {
+	// Settings are the runner's. If it is nil, Defaults are used.
+	Settings *Settings
+}
 
 // PrintRunner implements Runner to return a []byte of the command to be executed
-type PrintRunner struct{}
+type PrintRunner struct {
+	// Settings are the runner's. If it is nil, Defaults are used.
+	Settings *Settings
+}
 
 // Client stores the instance of Runner
 var Client Runner = RealRunner{}
+
+// Client names accepted by NewClient.
+const (
+	// ClientExec runs the kubectl binary.
+	ClientExec = "exec"
+	// ClientNative talks to the Kubernetes API server directly.
+	ClientNative = "native"
+)
+
+// NewClient returns the Runner with the given name.
+func NewClient(name string) (Runner, error) {
+	switch name {
+	case "", ClientExec:
+		return RealRunner{}, nil
+	case ClientNative:
+		return &NativeRunner{}, nil
+	}
+	return nil, fmt.Errorf("unknown client %q (use %q or %q)", name, ClientExec, ClientNative)
+}
@@ -1,5 +1,7 @@
 package kubectl
 
+import "testing"
+
 type TestRunner struct {
 	Runner
 
@@ -10,3 +12,73 @@ type TestRunner struct {
 func (r TestRunner) Get(stdin []byte, ns string) ([]byte, error) {
 	return r.out, r.err
 }
+
+func TestNewClient(t *testing.T) {
+	if c, _ := NewClient(""); c != (RealRunner{}) {
+		t.Errorf("Expected the exec client by default, got %T", c)
+	}
+	if c, _ := NewClient(ClientNative); c == nil {
+		t.Errorf("Expected a native client")
+	} else if _, ok := c.(*NativeRunner); !ok {
+		t.Errorf("Expected a native client, got %T", c)
+	}
+	if _, err := NewClient("telepathy"); err == nil {
+		t.Errorf("Expected an error for an unknown client")
+	}
+}
+
+func TestGlobalArgs(t *testing.T) {
+	s := DefaultSettings()
+	s.Context, s.User, s.Kubeconfig = "prod", "admin", "/tmp/ci.kubeconfig"
+
+	out, _ := PrintRunner{Settings: &s}.Delete("redis", "Pod", "default")
+	expected := "[CMD] kubectl --context=prod --kubeconfig=/tmp/ci.kubeconfig --user=admin --namespace=default delete Pod redis "
+	if string(out) != expected {
+		t.Errorf("Expected %q, got %q", expected, out)
+	}
+}
+
+func TestGracePeriod(t *testing.T) {
+	s := DefaultSettings()
+	s.GracePeriod = 30
+
+	out, _ := PrintRunner{Settings: &s}.Delete("redis", "Pod", "default")
+	expected := "[CMD] kubectl --namespace=default delete Pod redis --grace-period=30 "
+	if string(out) != expected {
+		t.Errorf("Expected %q, got %q", expected, out)
+	}
+}
+
+func TestWithSettings(t *testing.T) {
+	staging, prod := DefaultSettings(), DefaultSettings()
+	staging.Context, prod.Context = "staging", "prod"
+	a, b := WithSettings(PrintRunner{}, &staging), WithSettings(PrintRunner{}, &prod)
+
+	if SettingsOf(a) != &staging || SettingsOf(b) != &prod {
+		t.Error("Expected each runner to keep its own settings")
+	}
+	if SettingsOf(PrintRunner{}) != &Defaults || SettingsOf(&FakeRunner{}) != &Defaults {
+		t.Error("Expected a runner without settings to use Defaults")
+	}
+	for r, ctx := range map[Runner]string{a: "staging", b: "prod"} {
+		out, _ := r.Delete("redis", "Pod", "default")
+		expected := "[CMD] kubectl --context=" + ctx + " --namespace=default delete Pod redis "
+		if string(out) != expected {
+			t.Errorf("Expected %q, got %q", expected, out)
+		}
+	}
+}
+
+func TestObjectName(t *testing.T) {
+	tests := map[string]string{
+		`{"kind": "Pod", "metadata": {"name": "redis"}}`:            "Pod/redis",
+		`{"kind": "Job", "metadata": {"generateName": "migrate-"}}`: "Job/migrate-",
+		`not json`: "",
+		``:         "",
+	}
+	for in, expect := range tests {
+		if got := objectName([]byte(in)); got != expect {
+			t.Errorf("Expected %q for %q, got %q", expect, in, got)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package kubectl
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// listArgs returns the arguments of a kubectl get of the resources of kinds,
+// a comma-separated list, that match selector.
+func listArgs(kinds, selector, ns string) []string {
+	args := []string{"get", kinds, "--selector=" + selector, "-o", "json"}
+	if ns != "" {
+		args = append([]string{"--namespace=" + ns}, args...)
+	}
+	return args
+}
+
+// List returns the resources of kinds that match a label selector, as a JSON List
+func (r RealRunner) List(kinds, selector, ns string) ([]byte, error) {
+	return r.settings().run(nil, listArgs(kinds, selector, ns)...)
+}
+
+// List returns the commands to kubectl
+func (r PrintRunner) List(kinds, selector, ns string) ([]byte, error) {
+	cmd := r.settings().command(listArgs(kinds, selector, ns)...)
+	return []byte(cmd.String()), nil
+}
+
+// List returns the resources of kinds that match a label selector, as a JSON
+// List, as kubectl does. Unlike the lists of the API server, every item has
+// its kind and apiVersion.
+func (r *NativeRunner) List(kinds, selector, ns string) ([]byte, error) {
+	ns, err := r.namespace(ns)
+	if err != nil {
+		return []byte(err.Error()), err
+	}
+	items := []map[string]interface{}{}
+	for _, kind := range strings.Split(kinds, ",") {
+		kind = strings.TrimSpace(kind)
+		code, b, err := r.do("GET", r.resourcePath("", kind, ns, "")+"?labelSelector="+url.QueryEscape(selector), nil)
+		if err != nil {
+			return []byte(err.Error()), err
+		}
+		if code != http.StatusOK {
+			err := statusError(code, b)
+			return []byte(err.Error()), err
+		}
+		list := struct {
+			APIVersion string                   `json:"apiVersion"`
+			Items      []map[string]interface{} `json:"items"`
+		}{}
+		if err := json.Unmarshal(b, &list); err != nil {
+			return []byte(err.Error()), err
+		}
+		for _, item := range list.Items {
+			item["kind"], item["apiVersion"] = kind, list.APIVersion
+			items = append(items, item)
+		}
+	}
+	out, err := json.Marshal(map[string]interface{}{"apiVersion": "v1", "kind": "List", "items": items})
+	if err != nil {
+		return []byte(err.Error()), err
+	}
+	return out, nil
+}
@@ -0,0 +1,22 @@
+package kubectl
+
+import (
+	"testing"
+)
+
+func TestPrintList(t *testing.T) {
+	var client Runner = PrintRunner{}
+
+	expected := `[CMD] kubectl --namespace=shop get Deployment,Service --selector=app=web -o json `
+
+	out, err := client.List("Deployment,Service", "app=web", "shop")
+	if err != nil {
+		t.Error(err)
+	}
+
+	actual := string(out)
+
+	if expected != actual {
+		t.Fatalf("actual %s != expected %s", actual, expected)
+	}
+}
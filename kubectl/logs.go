@@ -0,0 +1,104 @@
+package kubectl
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// logsArgs returns the arguments of a kubectl logs of every container of a
+// resource, such as a Pod or a Job.
+func logsArgs(name, ktype, ns string) []string {
+	args := []string{"logs", strings.ToLower(ktype) + "/" + name, "--all-containers=true"}
+	if ns != "" {
+		args = append([]string{"--namespace=" + ns}, args...)
+	}
+	return args
+}
+
+// Logs returns the logs of the containers of a Pod, or of the Pods of a Job.
+func (r RealRunner) Logs(name, ktype, ns string) ([]byte, error) {
+	return r.settings().run(nil, logsArgs(name, ktype, ns)...)
+}
+
+// Logs returns the commands to kubectl
+func (r PrintRunner) Logs(name, ktype, ns string) ([]byte, error) {
+	cmd := r.settings().command(logsArgs(name, ktype, ns)...)
+	return []byte(cmd.String()), nil
+}
+
+// Logs returns the logs of the containers of a Pod, or of the Pods of a Job,
+// which are those with its job-name label. When there are several
+// containers, the logs of each are headed by its Pod and container.
+func (r *NativeRunner) Logs(name, ktype, ns string) ([]byte, error) {
+	ns, err := r.namespace(ns)
+	if err != nil {
+		return []byte(err.Error()), err
+	}
+	pods := []string{name}
+	if ktype == "Job" {
+		out, err := r.List("Pod", "job-name="+name, ns)
+		if err != nil {
+			return out, err
+		}
+		list := struct {
+			Items []struct {
+				Metadata struct {
+					Name string `json:"name"`
+				} `json:"metadata"`
+			} `json:"items"`
+		}{}
+		if err := json.Unmarshal(out, &list); err != nil {
+			return []byte(err.Error()), err
+		}
+		pods = pods[:0]
+		for _, item := range list.Items {
+			pods = append(pods, item.Metadata.Name)
+		}
+	} else if ktype != "Pod" {
+		err := fmt.Errorf("%s %s has no logs. Only Pods and Jobs do", ktype, name)
+		return []byte(err.Error()), err
+	}
+
+	type container struct{ pod, name string }
+	var containers []container
+	for _, pod := range pods {
+		out, err := r.GetObject(pod, "Pod", ns)
+		if err != nil {
+			return out, err
+		}
+		obj := struct {
+			Spec struct {
+				Containers []struct {
+					Name string `json:"name"`
+				} `json:"containers"`
+			} `json:"spec"`
+		}{}
+		if err := json.Unmarshal(out, &obj); err != nil {
+			return []byte(err.Error()), err
+		}
+		for _, c := range obj.Spec.Containers {
+			containers = append(containers, container{pod, c.Name})
+		}
+	}
+
+	var logs bytes.Buffer
+	for _, c := range containers {
+		code, b, err := r.do("GET", r.resourcePath("", "Pod", ns, c.pod)+"/log?container="+url.QueryEscape(c.name), nil)
+		if err != nil {
+			return []byte(err.Error()), err
+		}
+		if code != http.StatusOK {
+			err := statusError(code, b)
+			return []byte(err.Error()), err
+		}
+		if len(containers) > 1 {
+			fmt.Fprintf(&logs, "==> pod/%s %s <==\n", c.pod, c.name)
+		}
+		logs.Write(b)
+	}
+	return logs.Bytes(), nil
+}
@@ -0,0 +1,54 @@
+package kubectl
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPrintLogs(t *testing.T) {
+	var client Runner = PrintRunner{}
+
+	expected := `[CMD] kubectl --namespace=shop logs job/migrate --all-containers=true `
+
+	out, err := client.Logs("migrate", "Job", "shop")
+	if err != nil {
+		t.Error(err)
+	}
+	if actual := string(out); expected != actual {
+		t.Fatalf("actual %s != expected %s", actual, expected)
+	}
+}
+
+func TestNativeLogs(t *testing.T) {
+	containers := func(names ...string) map[string]interface{} {
+		cs := []interface{}{}
+		for _, n := range names {
+			cs = append(cs, map[string]interface{}{"name": n})
+		}
+		return map[string]interface{}{"containers": cs}
+	}
+	api := &fakeAPI{objects: map[string]map[string]interface{}{
+		"/api/v1/namespaces/shop/pods/web": {
+			"metadata": map[string]interface{}{"name": "web"},
+			"spec":     containers("web"),
+		},
+		"/api/v1/namespaces/shop/pods/migrate-x7k2p": {
+			"metadata": map[string]interface{}{"name": "migrate-x7k2p", "labels": map[string]interface{}{"job-name": "migrate"}},
+			"spec":     containers("migrate", "proxy"),
+		},
+	}}
+	ts := httptest.NewServer(api)
+	defer ts.Close()
+	client := &NativeRunner{Config: &Config{Server: ts.URL, Token: "secret"}}
+
+	if out, err := client.Logs("web", "Pod", "shop"); err != nil || string(out) != "logs of web\n" {
+		t.Errorf("Expected the logs of the Pod, got %q: %v", out, err)
+	}
+	expected := "==> pod/migrate-x7k2p migrate <==\nlogs of migrate\n==> pod/migrate-x7k2p proxy <==\nlogs of proxy\n"
+	if out, err := client.Logs("migrate", "Job", "shop"); err != nil || string(out) != expected {
+		t.Errorf("Expected the logs of the Pod of the Job, got %q: %v", out, err)
+	}
+	if _, err := client.Logs("web", "Service", "shop"); err == nil {
+		t.Error("Expected a Service to have no logs")
+	}
+}
@@ -0,0 +1,492 @@
+package kubectl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/helm/helm-classic/codec"
+	"github.com/helm/helm-classic/metrics"
+	helm "github.com/helm/helm-classic/util"
+)
+
+// NativeRunner implements Runner by calling the Kubernetes API server
+// directly, so kubectl does not need to be installed.
+//
+// Its output imitates kubectl's, e.g. `pod "redis" created`, so that callers
+// can treat both runners alike. Apply is a server-side apply, which merges
+// the manifest into an existing resource as `kubectl apply` does. On servers
+// that do not support it, before Kubernetes 1.16, the resource is replaced.
+type NativeRunner struct {
+	// Config is the connection to the API server. If it is nil, it is read
+	// from the kubeconfig files the first time it is needed, using the
+	// Kubeconfig, Context, Cluster, and User of Settings.
+	Config *Config
+	// Settings are the runner's. If it is nil, Defaults are used.
+	Settings *Settings
+
+	mu sync.Mutex
+	// discovered are the resources that the API server serves, by apiVersion.
+	discovered map[string][]apiResource
+}
+
+func (r *NativeRunner) config() (*Config, error) {
+	if r.Config == nil {
+		s := r.settings()
+		cfg, err := LoadConfig(s.DefaultKubeconfig(), s.Context, s.Cluster, s.User)
+		if err != nil {
+			return nil, fmt.Errorf("could not load kubeconfig: %s", err)
+		}
+		r.Config = cfg
+	}
+	return r.Config, nil
+}
+
+// do sends a request to the API server and returns the status code and body.
+//
+// Connection errors, 429, and 5xx responses are retried according to the
+// Retry of the runner's Settings, until their Timeout.
+func (r *NativeRunner) do(method, path string, body []byte) (int, []byte, error) {
+	return r.doAs(method, path, jsonType, body)
+}
+
+// Content types of request bodies.
+const (
+	jsonType = "application/json"
+	// applyType makes a PATCH a server-side apply.
+	applyType = "application/apply-patch+yaml"
+)
+
+// doAs is like do, for a body of the given content type.
+func (r *NativeRunner) doAs(method, path, contentType string, body []byte) (int, []byte, error) {
+	s := r.settings()
+	ctx, cancel := helm.WithTimeout(s.Timeout)
+	defer cancel()
+	var code int
+	start := time.Now()
+	b, err := s.Retry.Do(method+" "+path, func() ([]byte, error) {
+		var (
+			b   []byte
+			err error
+		)
+		code, b, err = r.send(ctx, method, path, contentType, body)
+		if err == nil && (code == http.StatusTooManyRequests || code >= 500) {
+			return b, transientStatus(code)
+		}
+		return b, err
+	})
+	failed := err
+	if failed == nil && code >= 400 {
+		failed = statusError(code, b)
+	}
+	metrics.Observe(metrics.Kubectl, method+" "+resourceOf(path), time.Since(start), failed)
+	if _, ok := err.(transientStatus); ok {
+		// The retries ran out. Let the caller report the response.
+		err = nil
+	}
+	return code, b, err
+}
+
+// resourceOf returns the resource of an API path, such as "pods" for
+// /api/v1/namespaces/cache/pods/redis, to name a request in the metrics
+// without its namespace and name.
+func resourceOf(path string) string {
+	path = strings.SplitN(path, "?", 2)[0]
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	switch {
+	case len(parts) > 2 && parts[0] == "api":
+		parts = parts[2:]
+	case len(parts) > 3 && parts[0] == "apis":
+		parts = parts[3:]
+	default:
+		return path
+	}
+	if len(parts) > 2 && parts[0] == "namespaces" {
+		parts = parts[2:]
+	}
+	return parts[0]
+}
+
+// transientStatus is a response status that may succeed if the request is repeated.
+type transientStatus int
+
+func (s transientStatus) Error() string {
+	return fmt.Sprintf("%d %s", int(s), http.StatusText(int(s)))
+}
+
+// send sends a single request to the API server.
+func (r *NativeRunner) send(ctx context.Context, method, path, contentType string, body []byte) (int, []byte, error) {
+	cfg, err := r.config()
+	if err != nil {
+		return 0, nil, err
+	}
+	req, err := http.NewRequest(method, strings.TrimSuffix(cfg.Server, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Accept", "application/json")
+	cfg.authorize(req)
+
+	res, err := cfg.httpClient().Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer res.Body.Close()
+	b, err := ioutil.ReadAll(res.Body)
+	return res.StatusCode, b, err
+}
+
+// statusError turns an unsuccessful API response into an error.
+func statusError(code int, body []byte) error {
+	st := struct {
+		Message string `json:"message"`
+	}{}
+	if json.Unmarshal(body, &st) == nil && st.Message != "" {
+		return fmt.Errorf("Error from server: %s", st.Message)
+	}
+	return fmt.Errorf("Error from server: %s", http.StatusText(code))
+}
+
+// resource is a single decoded manifest.
+type resource struct {
+	apiVersion, kind, name, namespace string
+	data                              map[string]interface{}
+}
+
+// path returns the API path of a resource, in namespace ns unless it gives
+// its own, and with its name if named is set.
+func (r *NativeRunner) path(res *resource, ns string, named bool) string {
+	if res.namespace != "" {
+		ns = res.namespace
+	}
+	name := ""
+	if named {
+		name = res.name
+	}
+	return r.resourcePath(res.apiVersion, res.kind, ns, name)
+}
+
+func (res *resource) String() string {
+	return fmt.Sprintf("%s %q", strings.ToLower(res.kind), res.name)
+}
+
+// decode reads the manifests in stdin, which may be JSON or YAML.
+func decode(stdin []byte) ([]*resource, error) {
+	docs, err := codec.YAML.Decode(stdin).All()
+	if err != nil {
+		return nil, err
+	}
+	out := []*resource{}
+	for _, d := range docs {
+		meta, err := d.Meta()
+		if err != nil {
+			return nil, err
+		}
+		if meta.Kind == "" || (meta.Name == "" && meta.GenerateName == "") {
+			return nil, fmt.Errorf("manifest must have a kind and a metadata.name or metadata.generateName")
+		}
+		data := map[string]interface{}{}
+		if err := d.Object(&data); err != nil {
+			return nil, err
+		}
+		out = append(out, &resource{
+			apiVersion: meta.APIVersion,
+			kind:       meta.Kind,
+			name:       meta.Name,
+			namespace:  meta.Namespace,
+			data:       data,
+		})
+	}
+	return out, nil
+}
+
+// namespace returns ns, or the configured namespace if ns is empty.
+func (r *NativeRunner) namespace(ns string) (string, error) {
+	if ns != "" {
+		return ns, nil
+	}
+	cfg, err := r.config()
+	if err != nil {
+		return "", err
+	}
+	if cfg.Namespace != "" {
+		return cfg.Namespace, nil
+	}
+	return "default", nil
+}
+
+// each runs fn on every manifest in stdin, collecting the output.
+//
+// It stops at the first error, as kubectl does.
+func (r *NativeRunner) each(stdin []byte, ns string, fn func(*resource, string) (string, error)) ([]byte, error) {
+	rs, err := decode(stdin)
+	if err != nil {
+		return []byte(err.Error()), err
+	}
+	if ns, err = r.namespace(ns); err != nil {
+		return []byte(err.Error()), err
+	}
+	var out bytes.Buffer
+	for _, res := range rs {
+		line, err := fn(res, ns)
+		if err != nil {
+			out.WriteString(err.Error() + "\n")
+			return out.Bytes(), err
+		}
+		out.WriteString(line + "\n")
+	}
+	return out.Bytes(), nil
+}
+
+// dryRunQuery asks the API server to validate a request without persisting it.
+const dryRunQuery = "?dryRun=All"
+
+// create posts a resource to the API server. query is appended to the path.
+func (r *NativeRunner) create(res *resource, ns, query string) (string, error) {
+	body, err := json.Marshal(res.data)
+	if err != nil {
+		return "", err
+	}
+	code, b, err := r.do("POST", r.path(res, ns, false)+query, body)
+	if err != nil {
+		return "", err
+	}
+	if code != http.StatusCreated && code != http.StatusOK {
+		return "", statusError(code, b)
+	}
+	// The server assigns the name if the manifest uses generateName.
+	created := struct {
+		Metadata struct {
+			Name string `json:"name"`
+		} `json:"metadata"`
+	}{}
+	if json.Unmarshal(b, &created) == nil && created.Metadata.Name != "" {
+		res.name = created.Metadata.Name
+	}
+	return res.String() + " created" + dryRunSuffix(query), nil
+}
+
+// dryRunSuffix marks the output of a dry run, as kubectl does.
+func dryRunSuffix(query string) string {
+	if query == dryRunQuery {
+		return " (server dry run)"
+	}
+	return ""
+}
+
+// Create uploads a chart to Kubernetes
+func (r *NativeRunner) Create(stdin []byte, ns string) ([]byte, error) {
+	return r.each(stdin, ns, func(res *resource, ns string) (string, error) {
+		return r.create(res, ns, "")
+	})
+}
+
+// Apply uploads a chart to Kubernetes, merging it into any resources that already exist
+func (r *NativeRunner) Apply(stdin []byte, ns string) ([]byte, error) {
+	return r.each(stdin, ns, func(res *resource, ns string) (string, error) {
+		return r.apply(res, ns, "")
+	})
+}
+
+// DryRun sends a chart to Kubernetes for validation, without persisting it
+func (r *NativeRunner) DryRun(stdin []byte, ns string) ([]byte, error) {
+	return r.each(stdin, ns, func(res *resource, ns string) (string, error) {
+		return r.apply(res, ns, dryRunQuery)
+	})
+}
+
+// Replace replaces resources in Kubernetes, which must already exist
+func (r *NativeRunner) Replace(stdin []byte, ns string) ([]byte, error) {
+	return r.each(stdin, ns, func(res *resource, ns string) (string, error) {
+		return r.replace(res, ns, "", false)
+	})
+}
+
+// applyQuery names helmc as the manager of the fields that a server-side
+// apply sets, and takes them over from other managers, such as kubectl, as
+// `kubectl apply` would.
+const applyQuery = "fieldManager=helmc&force=true"
+
+// apply creates a resource, or merges the manifest into it if it already
+// exists, with a server-side apply. If the server does not support that, the
+// resource is replaced instead, as is a manifest without an apiVersion,
+// which a server-side apply requires. A manifest with only a generateName is
+// created.
+func (r *NativeRunner) apply(res *resource, ns, query string) (string, error) {
+	if res.name == "" {
+		return r.create(res, ns, query)
+	}
+	if res.apiVersion == "" {
+		return r.replace(res, ns, query, true)
+	}
+	body, err := json.Marshal(res.data)
+	if err != nil {
+		return "", err
+	}
+	q := "?" + applyQuery
+	if query != "" {
+		q = query + "&" + applyQuery
+	}
+	code, b, err := r.doAs("PATCH", r.path(res, ns, true)+q, applyType, body)
+	if err != nil {
+		return "", err
+	}
+	switch code {
+	case http.StatusCreated:
+		return res.String() + " created" + dryRunSuffix(query), nil
+	case http.StatusOK:
+		return res.String() + " configured" + dryRunSuffix(query), nil
+	case http.StatusUnsupportedMediaType:
+		return r.replace(res, ns, query, true)
+	}
+	return "", statusError(code, b)
+}
+
+// replace replaces an existing resource. If the resource does not exist, it
+// is created if create is set, and is an error otherwise.
+func (r *NativeRunner) replace(res *resource, ns, query string, create bool) (string, error) {
+	code, b, err := r.do("GET", r.path(res, ns, true), nil)
+	if err != nil {
+		return "", err
+	}
+	switch code {
+	case http.StatusNotFound:
+		if !create {
+			return "", statusError(code, b)
+		}
+		return r.create(res, ns, query)
+	case http.StatusOK:
+	default:
+		return "", statusError(code, b)
+	}
+
+	// The update must carry the current resourceVersion.
+	cur := struct {
+		Metadata struct {
+			ResourceVersion string `json:"resourceVersion"`
+		} `json:"metadata"`
+	}{}
+	if err := json.Unmarshal(b, &cur); err != nil {
+		return "", err
+	}
+	if md, ok := res.data["metadata"].(map[string]interface{}); ok {
+		md["resourceVersion"] = cur.Metadata.ResourceVersion
+	}
+	body, err := json.Marshal(res.data)
+	if err != nil {
+		return "", err
+	}
+	code, b, err = r.do("PUT", r.path(res, ns, true)+query, body)
+	if err != nil {
+		return "", err
+	}
+	if code != http.StatusOK {
+		return "", statusError(code, b)
+	}
+	verb := " replaced"
+	if create {
+		verb = " configured"
+	}
+	return res.String() + verb + dryRunSuffix(query), nil
+}
+
+// Delete removes a chart from Kubernetes.
+func (r *NativeRunner) Delete(name, ktype, ns string) ([]byte, error) {
+	ns, err := r.namespace(ns)
+	if err != nil {
+		return []byte(err.Error()), err
+	}
+	res := &resource{kind: ktype, name: name}
+	var opts []byte
+	if grace := r.settings().GracePeriod; grace >= 0 {
+		opts, _ = json.Marshal(map[string]interface{}{
+			"kind":               "DeleteOptions",
+			"apiVersion":         "v1",
+			"gracePeriodSeconds": grace,
+		})
+	}
+	code, b, err := r.do("DELETE", r.path(res, ns, true), opts)
+	if err != nil {
+		return []byte(err.Error()), err
+	}
+	if code != http.StatusOK {
+		err := statusError(code, b)
+		return []byte(err.Error()), err
+	}
+	return []byte(res.String() + " deleted\n"), nil
+}
+
+// GetObject returns a single Kubernetes resource as JSON
+func (r *NativeRunner) GetObject(name, ktype, ns string) ([]byte, error) {
+	ns, err := r.namespace(ns)
+	if err != nil {
+		return []byte(err.Error()), err
+	}
+	res := &resource{kind: ktype, name: name}
+	code, b, err := r.do("GET", r.path(res, ns, true), nil)
+	if err != nil {
+		return []byte(err.Error()), err
+	}
+	if code != http.StatusOK {
+		err := statusError(code, b)
+		return []byte(err.Error()), err
+	}
+	return b, nil
+}
+
+// Get returns Kubernetes resources
+func (r *NativeRunner) Get(stdin []byte, ns string) ([]byte, error) {
+	return r.each(stdin, ns, func(res *resource, ns string) (string, error) {
+		code, b, err := r.do("GET", r.path(res, ns, true), nil)
+		if err != nil {
+			return "", err
+		}
+		if code != http.StatusOK {
+			return "", statusError(code, b)
+		}
+		return string(bytes.TrimSpace(b)), nil
+	})
+}
+
+// serverVersion returns the gitVersion reported by the API server.
+func (r *NativeRunner) serverVersion() (string, error) {
+	code, b, err := r.do("GET", "/version", nil)
+	if err != nil {
+		return "", err
+	}
+	if code != http.StatusOK {
+		return "", statusError(code, b)
+	}
+	v := struct {
+		GitVersion string `json:"gitVersion"`
+	}{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return "", err
+	}
+	return v.GitVersion, nil
+}
+
+// ClusterInfo returns Kubernetes cluster info
+func (r *NativeRunner) ClusterInfo() ([]byte, error) {
+	if _, err := r.serverVersion(); err != nil {
+		return []byte(err.Error()), err
+	}
+	return []byte(fmt.Sprintf("Kubernetes master is running at %s\n", r.Config.Server)), nil
+}
+
+// Version returns the Kubernetes server version
+func (r *NativeRunner) Version() ([]byte, error) {
+	v, err := r.serverVersion()
+	if err != nil {
+		return []byte(err.Error()), err
+	}
+	return []byte(fmt.Sprintf("Server Version: %s\n", v)), nil
+}
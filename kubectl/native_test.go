@@ -0,0 +1,416 @@
+package kubectl
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeAPI is a minimal Kubernetes API server that stores objects by path.
+type fakeAPI struct {
+	sync.Mutex
+	objects  map[string]map[string]interface{}
+	requests []string
+	// noApply answers server-side applies as a server before 1.16 does.
+	noApply bool
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+	f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+	if r.Header.Get("Authorization") != "Bearer secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if r.URL.Path == "/version" {
+		w.Write([]byte(`{"major": "1", "minor": "2", "gitVersion": "v1.2.4"}`))
+		return
+	}
+
+	var obj map[string]interface{}
+	if r.Body != nil {
+		b, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(b, &obj)
+	}
+	if r.URL.Path == "/apis/authorization.k8s.io/v1/selfsubjectaccessreviews" {
+		// Only pods may be changed.
+		attrs := obj["spec"].(map[string]interface{})["resourceAttributes"].(map[string]interface{})
+		obj["status"] = map[string]interface{}{"allowed": attrs["resource"] == "pods"}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(obj)
+		return
+	}
+	switch r.Method {
+	case "POST":
+		md := obj["metadata"].(map[string]interface{})
+		if gn, ok := md["generateName"].(string); ok && md["name"] == nil {
+			md["name"] = gn + "x7k2p"
+		}
+		name := md["name"].(string)
+		p := r.URL.Path + "/" + name
+		if _, ok := f.objects[p]; ok {
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte(`{"kind": "Status", "message": "already exists"}`))
+			return
+		}
+		md["resourceVersion"] = "1"
+		if r.URL.Query().Get("dryRun") == "" {
+			f.objects[p] = obj
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(obj)
+	case "PATCH":
+		if f.noApply || r.Header.Get("Content-Type") != "application/apply-patch+yaml" || r.URL.Query().Get("fieldManager") != "helmc" {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		code := http.StatusOK
+		if _, ok := f.objects[r.URL.Path]; !ok {
+			code = http.StatusCreated
+		}
+		obj["metadata"].(map[string]interface{})["resourceVersion"] = "1"
+		if r.URL.Query().Get("dryRun") == "" {
+			f.objects[r.URL.Path] = obj
+		}
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(obj)
+	case "PUT":
+		if obj["metadata"].(map[string]interface{})["resourceVersion"] != "1" {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		if r.URL.Query().Get("dryRun") == "" {
+			f.objects[r.URL.Path] = obj
+		}
+	case "GET", "DELETE":
+		if pod := strings.TrimSuffix(r.URL.Path, "/log"); pod != r.URL.Path && f.objects[pod] != nil {
+			w.Write([]byte("logs of " + r.URL.Query().Get("container") + "\n"))
+			return
+		}
+		o, ok := f.objects[r.URL.Path]
+		if !ok && r.Method == "GET" && r.URL.Query().Get("labelSelector") != "" {
+			f.list(w, r.URL.Path, r.URL.Query().Get("labelSelector"))
+			return
+		}
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"kind": "Status", "message": "not found"}`))
+			return
+		}
+		if r.Method == "DELETE" {
+			delete(f.objects, r.URL.Path)
+		}
+		json.NewEncoder(w).Encode(o)
+	}
+}
+
+// list writes the objects under path whose labels match a selector of the
+// form key=value.
+func (f *fakeAPI) list(w http.ResponseWriter, path, selector string) {
+	kv := strings.SplitN(selector, "=", 2)
+	items := []map[string]interface{}{}
+	for p, o := range f.objects {
+		labels, _ := o["metadata"].(map[string]interface{})["labels"].(map[string]interface{})
+		if strings.HasPrefix(p, path+"/") && len(kv) == 2 && labels[kv[0]] == kv[1] {
+			items = append(items, o)
+		}
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"apiVersion": "v1", "kind": "PodList", "items": items})
+}
+
+func TestNativeRunner(t *testing.T) {
+	api := &fakeAPI{objects: map[string]map[string]interface{}{}}
+	ts := httptest.NewServer(api)
+	defer ts.Close()
+	var client Runner = &NativeRunner{Config: &Config{Server: ts.URL, Token: "secret"}}
+
+	pod := []byte(`{"kind": "Pod", "apiVersion": "v1", "metadata": {"name": "redis"}}`)
+	out, err := client.Create(pod, "")
+	if err != nil {
+		t.Fatalf("Could not create: %s (%s)", err, out)
+	}
+	if string(out) != "pod \"redis\" created\n" {
+		t.Errorf("Unexpected output %q", out)
+	}
+	if _, ok := api.objects["/api/v1/namespaces/default/pods/redis"]; !ok {
+		t.Errorf("Expected pod in the default namespace, got requests %v", api.requests)
+	}
+
+	if out, err := client.Create(pod, ""); err == nil || !strings.Contains(string(out), "already exists") {
+		t.Errorf("Expected an error creating the pod twice, got %q", out)
+	}
+	if out, _ := client.Apply(pod, ""); string(out) != "pod \"redis\" configured\n" {
+		t.Errorf("Expected apply to update the pod, got %q", out)
+	}
+
+	deploy := []byte("kind: Deployment\napiVersion: extensions/v1beta1\nmetadata:\n  name: web\n")
+	if out, err := client.Apply(deploy, "prod"); err != nil || string(out) != "deployment \"web\" created\n" {
+		t.Errorf("Expected apply to create the deployment, got %q, %v", out, err)
+	}
+	if _, ok := api.objects["/apis/extensions/v1beta1/namespaces/prod/deployments/web"]; !ok {
+		t.Errorf("Expected deployment in the prod namespace, got requests %v", api.requests)
+	}
+
+	if out, err := client.Get(pod, ""); err != nil || !strings.Contains(string(out), `"redis"`) {
+		t.Errorf("Expected to get the pod, got %q, %v", out, err)
+	}
+	if out, err := client.Delete("redis", "Pod", ""); err != nil || string(out) != "pod \"redis\" deleted\n" {
+		t.Errorf("Expected to delete the pod, got %q, %v", out, err)
+	}
+	if _, err := client.Delete("redis", "Pod", ""); err == nil {
+		t.Errorf("Expected an error deleting a missing pod")
+	}
+
+	if out, err := client.Version(); err != nil || string(out) != "Server Version: v1.2.4\n" {
+		t.Errorf("Unexpected version %q, %v", out, err)
+	}
+	if out, _ := client.ClusterInfo(); !strings.Contains(string(out), ts.URL) {
+		t.Errorf("Expected cluster info to name the server, got %q", out)
+	}
+
+	bad := &NativeRunner{Config: &Config{Server: ts.URL}}
+	if _, err := bad.Version(); err == nil {
+		t.Errorf("Expected an error without credentials")
+	}
+}
+
+func TestResourcePath(t *testing.T) {
+	for expect, got := range map[string]string{
+		"/api/v1/namespaces/ns/services":                                        resourcePath("", "Service", "ns", ""),
+		"/api/v1/persistentvolumes/data":                                        resourcePath("v1", "PersistentVolume", "ns", "data"),
+		"/apis/extensions/v1beta1/namespaces/ns/ingresses":                      resourcePath("", "Ingress", "ns", ""),
+		"/apis/extensions/v1beta1/namespaces/ns/networkpolicies":                resourcePath("", "NetworkPolicy", "ns", ""),
+		"/apis/apiextensions.k8s.io/v1/customresourcedefinitions":               resourcePath("", "CustomResourceDefinition", "ns", ""),
+		"/api/v1/namespaces/ns/endpoints/web":                                   resourcePath("", "Endpoints", "ns", "web"),
+		"/apis/rbac.authorization.k8s.io/v1/clusterroles/reader":                resourcePath("", "ClusterRole", "ns", "reader"),
+		"/apis/rbac.authorization.k8s.io/v1/namespaces/ns/roles":                resourcePath("", "Role", "ns", ""),
+		"/apis/storage.k8s.io/v1/storageclasses/fast":                           resourcePath("storage.k8s.io/v1", "StorageClass", "ns", "fast"),
+		"/apis/scheduling.k8s.io/v1/priorityclasses/high":                       resourcePath("", "PriorityClass", "ns", "high"),
+		"/apis/admissionregistration.k8s.io/v1/validatingwebhookconfigurations": resourcePath("", "ValidatingWebhookConfiguration", "ns", ""),
+	} {
+		if expect != got {
+			t.Errorf("Expected %s, got %s", expect, got)
+		}
+	}
+}
+
+func TestPlural(t *testing.T) {
+	for kind, expect := range map[string]string{
+		"Ingress":     "ingresses",
+		"Policy":      "policies",
+		"Gateway":     "gateways",
+		"Mailbox":     "mailboxes",
+		"Patch":       "patches",
+		"Certificate": "certificates",
+	} {
+		if got := plural(kind); got != expect {
+			t.Errorf("Expected %s for %s, got %s", expect, kind, got)
+		}
+	}
+}
+
+func TestClusterScoped(t *testing.T) {
+	for _, kind := range []string{"Namespace", "Node", "ClusterRole", "ClusterRoleBinding", "StorageClass", "PriorityClass", "MutatingWebhookConfiguration", "ValidatingWebhookConfiguration", "APIService", "IngressClass"} {
+		if !ClusterScoped(kind) {
+			t.Errorf("Expected %s to be cluster-scoped", kind)
+		}
+	}
+	for _, kind := range []string{"Pod", "Endpoints", "Role", "RoleBinding", "Lease", "ClusterIssuer"} {
+		if ClusterScoped(kind) {
+			t.Errorf("Expected %s to be namespaced", kind)
+		}
+	}
+}
+
+func TestNativeClusterScoped(t *testing.T) {
+	api := &fakeAPI{objects: map[string]map[string]interface{}{
+		// The discovery document of a custom group.
+		"/apis/cert-manager.io/v1": {"resources": []interface{}{
+			map[string]interface{}{"name": "clusterissuers", "kind": "ClusterIssuer", "namespaced": false},
+			map[string]interface{}{"name": "clusterissuers/status", "kind": "ClusterIssuer", "namespaced": false},
+			map[string]interface{}{"name": "issuers", "kind": "Issuer", "namespaced": true},
+		}},
+	}}
+	ts := httptest.NewServer(api)
+	defer ts.Close()
+	client := &NativeRunner{Config: &Config{Server: ts.URL, Token: "secret"}}
+
+	manifests := "kind: ClusterRole\napiVersion: rbac.authorization.k8s.io/v1\nmetadata:\n  name: reader\n" +
+		"---\nkind: StorageClass\napiVersion: storage.k8s.io/v1\nmetadata:\n  name: fast\n" +
+		"---\nkind: ClusterIssuer\napiVersion: cert-manager.io/v1\nmetadata:\n  name: letsencrypt\n" +
+		"---\nkind: Issuer\napiVersion: cert-manager.io/v1\nmetadata:\n  name: local\n"
+	if out, err := client.Apply([]byte(manifests), "prod"); err != nil {
+		t.Fatalf("Could not apply: %s (%s)", err, out)
+	}
+	for _, p := range []string{
+		"/apis/rbac.authorization.k8s.io/v1/clusterroles/reader",
+		"/apis/storage.k8s.io/v1/storageclasses/fast",
+		"/apis/cert-manager.io/v1/clusterissuers/letsencrypt",
+		"/apis/cert-manager.io/v1/namespaces/prod/issuers/local",
+	} {
+		if _, ok := api.objects[p]; !ok {
+			t.Errorf("Expected %s, got requests %v", p, api.requests)
+		}
+	}
+	discovered := 0
+	for _, r := range api.requests {
+		if r == "GET /apis/cert-manager.io/v1" {
+			discovered++
+		}
+	}
+	if discovered != 1 {
+		t.Errorf("Expected the group to be discovered once, got requests %v", api.requests)
+	}
+
+	if out, err := client.Delete("reader", "ClusterRole", "prod"); err != nil {
+		t.Errorf("Could not delete the ClusterRole: %s (%s)", err, out)
+	}
+}
+
+func TestResourceOf(t *testing.T) {
+	for path, expect := range map[string]string{
+		"/api/v1/namespaces/ns/pods/redis":                           "pods",
+		"/api/v1/namespaces/ns":                                      "namespaces",
+		"/apis/apps/v1/namespaces/ns/deployments?fieldManager=helmc": "deployments",
+		"/apis/apiextensions.k8s.io/v1/customresourcedefinitions/x":  "customresourcedefinitions",
+		"/version": "/version",
+	} {
+		if got := resourceOf(path); got != expect {
+			t.Errorf("Expected %s for %s, got %s", expect, path, got)
+		}
+	}
+}
+
+func TestNativeGenerateName(t *testing.T) {
+	api := &fakeAPI{objects: map[string]map[string]interface{}{}}
+	ts := httptest.NewServer(api)
+	defer ts.Close()
+	client := &NativeRunner{Config: &Config{Server: ts.URL, Token: "secret"}}
+
+	pod := []byte(`{"kind": "Pod", "apiVersion": "v1", "metadata": {"generateName": "redis-"}}`)
+	out, err := client.Create(pod, "")
+	if err != nil {
+		t.Fatalf("Could not create: %s (%s)", err, out)
+	}
+	if string(out) != "pod \"redis-x7k2p\" created\n" {
+		t.Errorf("Expected the generated name, got %q", out)
+	}
+}
+
+func TestNativeDryRun(t *testing.T) {
+	api := &fakeAPI{objects: map[string]map[string]interface{}{}}
+	ts := httptest.NewServer(api)
+	defer ts.Close()
+	client := &NativeRunner{Config: &Config{Server: ts.URL, Token: "secret"}}
+
+	pod := []byte(`{"kind": "Pod", "apiVersion": "v1", "metadata": {"name": "redis"}}`)
+	out, err := client.DryRun(pod, "")
+	if err != nil {
+		t.Fatalf("Could not dry run: %s (%s)", err, out)
+	}
+	if string(out) != "pod \"redis\" created (server dry run)\n" {
+		t.Errorf("Unexpected output %q", out)
+	}
+	if len(api.objects) != 0 {
+		t.Errorf("Expected nothing to be persisted, got %v", api.objects)
+	}
+}
+
+func TestNativeApply(t *testing.T) {
+	api := &fakeAPI{objects: map[string]map[string]interface{}{}}
+	ts := httptest.NewServer(api)
+	defer ts.Close()
+	client := &NativeRunner{Config: &Config{Server: ts.URL, Token: "secret"}}
+
+	pod := []byte(`{"kind": "Pod", "apiVersion": "v1", "metadata": {"name": "redis"}}`)
+	if out, err := client.Apply(pod, ""); err != nil || string(out) != "pod \"redis\" created\n" {
+		t.Fatalf("Expected apply to create the pod, got %q, %v", out, err)
+	}
+	if out, err := client.Apply(pod, ""); err != nil || string(out) != "pod \"redis\" configured\n" {
+		t.Errorf("Expected apply to configure the pod, got %q, %v", out, err)
+	}
+	if strings.Join(api.requests, ",") != "GET /api/v1,PATCH /api/v1/namespaces/default/pods/redis,PATCH /api/v1/namespaces/default/pods/redis" {
+		t.Errorf("Expected server-side applies, got %v", api.requests)
+	}
+
+	// An older server cannot apply, so the pod is replaced.
+	api.noApply, api.requests = true, nil
+	if out, err := client.Apply(pod, ""); err != nil || string(out) != "pod \"redis\" configured\n" {
+		t.Errorf("Expected apply to replace the pod, got %q, %v", out, err)
+	}
+	if len(api.requests) != 3 || api.requests[2] != "PUT /api/v1/namespaces/default/pods/redis" {
+		t.Errorf("Expected the pod to be replaced, got %v", api.requests)
+	}
+}
+
+func TestNativeReplace(t *testing.T) {
+	api := &fakeAPI{objects: map[string]map[string]interface{}{}}
+	ts := httptest.NewServer(api)
+	defer ts.Close()
+	client := &NativeRunner{Config: &Config{Server: ts.URL, Token: "secret"}}
+
+	pod := []byte(`{"kind": "Pod", "apiVersion": "v1", "metadata": {"name": "redis"}}`)
+	if _, err := client.Replace(pod, ""); err == nil {
+		t.Errorf("Expected an error replacing a missing resource")
+	}
+	if out, err := client.Create(pod, ""); err != nil {
+		t.Fatalf("Could not create: %s (%s)", err, out)
+	}
+	out, err := client.Replace(pod, "")
+	if err != nil {
+		t.Fatalf("Could not replace: %s (%s)", err, out)
+	}
+	if string(out) != "pod \"redis\" replaced\n" {
+		t.Errorf("Unexpected output %q", out)
+	}
+}
+
+func TestNativeList(t *testing.T) {
+	api := &fakeAPI{objects: map[string]map[string]interface{}{
+		"/api/v1/namespaces/shop/pods/web":   {"metadata": map[string]interface{}{"name": "web", "labels": map[string]interface{}{"app": "web"}}},
+		"/api/v1/namespaces/shop/pods/db":    {"metadata": map[string]interface{}{"name": "db", "labels": map[string]interface{}{"app": "db"}}},
+		"/api/v1/namespaces/other/pods/web2": {"metadata": map[string]interface{}{"name": "web2", "labels": map[string]interface{}{"app": "web"}}},
+	}}
+	ts := httptest.NewServer(api)
+	defer ts.Close()
+	client := &NativeRunner{Config: &Config{Server: ts.URL, Token: "secret"}}
+
+	out, err := client.List("Pod", "app=web", "shop")
+	if err != nil {
+		t.Fatalf("Could not list: %s (%s)", err, out)
+	}
+	var list struct {
+		Items []struct {
+			Kind     string `json:"kind"`
+			Metadata struct {
+				Name string `json:"name"`
+			} `json:"metadata"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(out, &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Items) != 1 || list.Items[0].Kind != "Pod" || list.Items[0].Metadata.Name != "web" {
+		t.Errorf("Expected the Pod web, got %s", out)
+	}
+}
+
+func TestNativeCanI(t *testing.T) {
+	api := &fakeAPI{objects: map[string]map[string]interface{}{}}
+	ts := httptest.NewServer(api)
+	defer ts.Close()
+	client := &NativeRunner{Config: &Config{Server: ts.URL, Token: "secret"}}
+
+	if out, err := client.CanI("create", "Pod", "shop"); err != nil || string(out) != "yes\n" {
+		t.Errorf("Expected pods to be allowed, got %q: %v", out, err)
+	}
+	if out, err := client.CanI("create", "Secret", "shop"); err == nil || string(out) != "no\n" {
+		t.Errorf("Expected secrets to be denied, got %q: %v", out, err)
+	}
+}
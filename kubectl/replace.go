@@ -0,0 +1,17 @@
+package kubectl
+
+// Replace replaces resources in Kubernetes, which must already exist
+func (r RealRunner) Replace(stdin []byte, ns string) ([]byte, error) {
+	s := r.settings()
+	return s.run(stdin, s.changeArgs(ns, "replace")...)
+}
+
+// Replace returns the commands to kubectl
+func (r PrintRunner) Replace(stdin []byte, ns string) ([]byte, error) {
+	args := r.settings().changeArgs(ns, "replace")
+
+	cmd := r.settings().command(args...)
+	assignStdin(cmd, stdin)
+
+	return []byte(cmd.String()), nil
+}
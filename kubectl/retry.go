@@ -0,0 +1,132 @@
+package kubectl
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/helm/helm-classic/log"
+)
+
+// RetryPolicy controls how requests that fail for transient reasons, such as
+// an API server that is restarting or rate limiting, are retried.
+type RetryPolicy struct {
+	// Retries is the number of times a request is retried. Zero disables retries.
+	Retries int
+	// Backoff is the delay before the first retry. It doubles with each retry.
+	Backoff time.Duration
+	// MaxBackoff caps the delay between retries.
+	MaxBackoff time.Duration
+	// Notify, if it is not nil, is told about each retry instead of the log.
+	Notify func(*Retrying)
+}
+
+// Retrying describes a request that failed, and is about to be retried.
+type Retrying struct {
+	// What describes the request, e.g. "kubectl create".
+	What string
+	// Reason is the first line of the failure.
+	Reason string
+	// Delay is how long it waits before the retry.
+	Delay time.Duration
+	// Attempt is the number of the retry, from 1 to Retries.
+	Attempt int
+	Retries int
+}
+
+// sleep waits between retries. Tests replace it.
+var sleep = time.Sleep
+
+// permanentErrors are failures that retrying cannot fix.
+var permanentErrors = []string{
+	"AlreadyExists", "already exists",
+	"Conflict", "conflict",
+	"Invalid", "is invalid",
+	"BadRequest",
+	"Forbidden", "forbidden",
+	"Unauthorized",
+	"NotFound", "not found",
+	"error validating",
+}
+
+// transientErrors are failures that may succeed if the request is repeated.
+var transientErrors = []string{
+	"connection refused", "was refused",
+	"connection reset",
+	"i/o timeout",
+	"TLS handshake timeout",
+	"timed out", "Timeout", "timeout",
+	"Unable to connect to the server",
+	"TooManyRequests", "Too Many Requests", "too many requests", "try again later",
+	"InternalError", "Internal Server Error", "an error on the server",
+	"Bad Gateway",
+	"ServiceUnavailable", "Service Unavailable", "the server is currently unable to handle the request",
+	"Gateway Timeout", "the server was unable to return a response",
+}
+
+// transient reports whether a failed request is worth retrying.
+//
+// kubectl reports errors on its output, so both the output and the error
+// are inspected. Validation and conflict errors are never retried, even if
+// they also mention a transient condition, and neither is a request that
+// ran out of time or was stopped.
+func transient(out []byte, err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if _, ok := err.(transientStatus); ok {
+		return true
+	}
+	msg := string(out) + " " + err.Error()
+	for _, p := range permanentErrors {
+		if strings.Contains(msg, p) {
+			return false
+		}
+	}
+	for _, t := range transientErrors {
+		if strings.Contains(msg, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// Do runs fn, retrying transient failures with exponential backoff.
+//
+// what describes the request in the log, e.g. "kubectl create".
+func (p RetryPolicy) Do(what string, fn func() ([]byte, error)) ([]byte, error) {
+	return p.DoIf(what, transient, fn)
+}
+
+// DoIf is like Do, but retries the failures that retry reports, instead of
+// the transient ones.
+func (p RetryPolicy) DoIf(what string, retry func(out []byte, err error) bool, fn func() ([]byte, error)) ([]byte, error) {
+	delay := p.Backoff
+	for attempt := 1; ; attempt++ {
+		out, err := fn()
+		if err == nil || attempt > p.Retries || !retry(out, err) {
+			return out, err
+		}
+		if p.MaxBackoff > 0 && delay > p.MaxBackoff {
+			delay = p.MaxBackoff
+		}
+		r := &Retrying{What: what, Reason: strings.TrimSpace(firstLine(out, err)), Delay: delay, Attempt: attempt, Retries: p.Retries}
+		if p.Notify != nil {
+			p.Notify(r)
+		} else {
+			log.Info("%s failed: %s. Retrying in %s (retry %d of %d)", r.What, r.Reason, r.Delay, r.Attempt, r.Retries)
+		}
+		sleep(delay)
+		delay *= 2
+	}
+}
+
+// firstLine returns the first line of kubectl's output, or the error if there is no output.
+func firstLine(out []byte, err error) string {
+	s := strings.TrimSpace(string(out))
+	if s == "" {
+		return err.Error()
+	}
+	return strings.SplitN(s, "\n", 2)[0]
+}
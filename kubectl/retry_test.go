@@ -0,0 +1,106 @@
+package kubectl
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func stubSleep() (*[]time.Duration, func()) {
+	delays := []time.Duration{}
+	sleep = func(d time.Duration) { delays = append(delays, d) }
+	return &delays, func() { sleep = time.Sleep }
+}
+
+func TestRetryPolicy(t *testing.T) {
+	delays, restore := stubSleep()
+	defer restore()
+
+	p := RetryPolicy{Retries: 4, Backoff: time.Second, MaxBackoff: 3 * time.Second}
+	calls := 0
+	out, err := p.Do("kubectl create", func() ([]byte, error) {
+		calls++
+		if calls < 5 {
+			return []byte("The connection to the server localhost:8080 was refused - did you specify the right host or port?"), errors.New("exit status 1")
+		}
+		return []byte("pod \"redis\" created"), nil
+	})
+	if err != nil || string(out) != "pod \"redis\" created" {
+		t.Fatalf("Expected success after retries, got %q, %v", out, err)
+	}
+	expected := []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second}
+	if len(*delays) != len(expected) {
+		t.Fatalf("Expected delays %v, got %v", expected, *delays)
+	}
+	for i, d := range expected {
+		if (*delays)[i] != d {
+			t.Errorf("Expected delays %v, got %v", expected, *delays)
+		}
+	}
+
+	calls = 0
+	if _, err := p.Do("kubectl create", func() ([]byte, error) {
+		calls++
+		return []byte("Error from server: pods \"redis\" already exists"), errors.New("exit status 1")
+	}); err == nil || calls != 1 {
+		t.Errorf("Expected a conflict to fail without retrying, got %d calls", calls)
+	}
+
+	calls = 0
+	p.Retries = 2
+	retries := []*Retrying{}
+	p.Notify = func(r *Retrying) { retries = append(retries, r) }
+	if _, err := p.Do("kubectl create", func() ([]byte, error) {
+		calls++
+		return []byte("Unable to connect to the server: i/o timeout"), errors.New("exit status 1")
+	}); err == nil || calls != 3 {
+		t.Errorf("Expected to give up after 2 retries, got %d calls", calls)
+	}
+	if len(retries) != 2 || retries[1].Attempt != 2 || retries[1].Reason != "Unable to connect to the server: i/o timeout" {
+		t.Errorf("Expected to be told about 2 retries, got %v", retries)
+	}
+}
+
+func TestTransient(t *testing.T) {
+	for msg, expect := range map[string]bool{
+		"dial tcp 10.0.0.1:443: getsockopt: connection refused":                                            true,
+		"Error from server: the server has received too many requests and has asked us to try again later": true,
+		"Error from server (TooManyRequests): slow down":                                                   true,
+		"Error from server: the server is currently unable to handle the request":                          true,
+		"The Pod \"redis\" is invalid: spec.containers: Required value":                                    false,
+		"error validating \"STDIN\": error validating data: timeout is not a field":                        false,
+		"Error from server: Operation cannot be fulfilled: Conflict":                                       false,
+	} {
+		if got := transient([]byte(msg), errors.New("exit status 1")); got != expect {
+			t.Errorf("Expected transient(%q) to be %t", msg, expect)
+		}
+	}
+}
+
+func TestNativeRetry(t *testing.T) {
+	_, restore := stubSleep()
+	defer restore()
+
+	failures := 2
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"gitVersion": "v1.2.4"}`))
+	}))
+	defer ts.Close()
+
+	client := &NativeRunner{Config: &Config{Server: ts.URL}}
+	if out, err := client.Version(); err != nil || string(out) != "Server Version: v1.2.4\n" {
+		t.Errorf("Expected the request to be retried, got %q, %v", out, err)
+	}
+
+	failures = 10
+	if _, err := client.Version(); err == nil {
+		t.Errorf("Expected an error once the retries ran out")
+	}
+}
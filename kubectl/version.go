@@ -0,0 +1,119 @@
+package kubectl
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/Masterminds/semver"
+)
+
+// Version returns the client and server versions of Kubernetes
+func (r RealRunner) Version() ([]byte, error) {
+	return r.settings().run(nil, "version")
+}
+
+// Version returns the commands to kubectl
+func (r PrintRunner) Version() ([]byte, error) {
+	cmd := r.settings().command("version")
+	return []byte(cmd.String()), nil
+}
+
+// Versions holds the versions of the Kubernetes client and server.
+//
+// Client is nil when the runner does not use kubectl. Server is nil when the
+// cluster could not be contacted.
+type Versions struct {
+	Client *semver.Version
+	Server *semver.Version
+}
+
+// UnreachableError indicates that the Kubernetes server did not report its version.
+type UnreachableError struct {
+	Err error
+}
+
+func (e *UnreachableError) Error() string {
+	return fmt.Sprintf("could not contact the Kubernetes server: %s", e.Err)
+}
+
+// IsUnreachable reports whether err is an UnreachableError.
+func IsUnreachable(err error) bool {
+	_, ok := err.(*UnreachableError)
+	return ok
+}
+
+// clusterVersions are the versions reported by a runner.
+type clusterVersions struct {
+	versions *Versions
+	err      error
+}
+
+// versionKey is the kubectl binary and the cluster that runners ask for
+// their versions.
+type versionKey struct {
+	path, kubeconfig, context, cluster, user string
+}
+
+// versionCache holds the versions reported by the first call to
+// ClusterVersions, by the binary and cluster of the runner.
+var versionCache struct {
+	sync.Mutex
+	byKey map[versionKey]*clusterVersions
+}
+
+// ClusterVersions returns the client and server versions reported by the runner.
+//
+// The versions are only queried once for the runners of the same binary
+// and cluster; later calls return the same result.
+// If the server cannot be contacted, the client version is still returned,
+// along with an UnreachableError, so that callers that do not need the
+// cluster can carry on.
+func ClusterVersions(r Runner) (*Versions, error) {
+	versionCache.Lock()
+	defer versionCache.Unlock()
+	s := SettingsOf(r)
+	k := versionKey{s.Path, s.Kubeconfig, s.Context, s.Cluster, s.User}
+	v, ok := versionCache.byKey[k]
+	if !ok {
+		v = &clusterVersions{}
+		v.versions, v.err = queryVersions(r)
+		if versionCache.byKey == nil {
+			versionCache.byKey = map[versionKey]*clusterVersions{}
+		}
+		versionCache.byKey[k] = v
+	}
+	return v.versions, v.err
+}
+
+func queryVersions(r Runner) (*Versions, error) {
+	out, err := r.Version()
+	v := &Versions{}
+	v.Client, _ = parseVersion(out, "Client")
+	server, perr := parseVersion(out, "Server")
+	if err == nil && perr == nil {
+		v.Server = server
+		return v, nil
+	}
+	if err == nil {
+		err = perr
+	}
+	return v, &UnreachableError{Err: fmt.Errorf("%s", firstLine(out, err))}
+}
+
+var versionRe = regexp.MustCompile(`(Client|Server) Version:.*?v(\d+\.\d+\.\d+[^\s",}]*)`)
+
+// parseVersion finds the Client or Server version in the output of `kubectl version`.
+//
+// Both the current (`Client Version: v1.28.2`) and the older
+// (`Client Version: version.Info{... GitVersion:"v1.2.4" ...}`) formats are understood.
+func parseVersion(out []byte, which string) (*semver.Version, error) {
+	for _, line := range strings.Split(string(out), "\n") {
+		m := versionRe.FindStringSubmatch(line)
+		if m != nil && m[1] == which {
+			return semver.NewVersion(m[2])
+		}
+	}
+	return nil, fmt.Errorf("no %s version in %q", strings.ToLower(which), strings.TrimSpace(string(out)))
+}
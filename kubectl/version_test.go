@@ -0,0 +1,61 @@
+package kubectl
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	out := []byte(`Client Version: version.Info{Major:"1", Minor:"2", GitVersion:"v1.2.4", GitCommit:"3eed1e3"}
+Server Version: v1.28.2+k3s1
+`)
+	if v, err := parseVersion(out, "Client"); err != nil || v.String() != "1.2.4" {
+		t.Errorf("Expected client 1.2.4, got %v (%v)", v, err)
+	}
+	if v, err := parseVersion(out, "Server"); err != nil || v.String() != "1.28.2+k3s1" {
+		t.Errorf("Expected server 1.28.2+k3s1, got %v (%v)", v, err)
+	}
+	if _, err := parseVersion([]byte("Client Version: v1.28.2"), "Server"); err == nil {
+		t.Error("Expected an error for a missing server version")
+	}
+}
+
+func resetVersionCache() {
+	versionCache.byKey = nil
+}
+
+func TestClusterVersions(t *testing.T) {
+	defer resetVersionCache()
+	resetVersionCache()
+
+	client := &FakeRunner{Out: []byte("Client Version: v1.18.0\nServer Version: v1.17.3\n")}
+	v, err := ClusterVersions(client)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if v.Client.Minor() != 18 || v.Server.Minor() != 17 {
+		t.Errorf("Expected 1.18 and 1.17, got %s and %s", v.Client, v.Server)
+	}
+
+	ClusterVersions(client)
+	if len(client.Calls) != 1 {
+		t.Errorf("Expected the versions to be cached, got calls %v", client.Calls)
+	}
+}
+
+func TestClusterVersionsUnreachable(t *testing.T) {
+	defer resetVersionCache()
+	resetVersionCache()
+
+	client := &FakeRunner{
+		Out: []byte("Client Version: v1.18.0\nThe connection to the server localhost:8080 was refused\n"),
+		Err: errors.New("exit status 1"),
+	}
+	v, err := ClusterVersions(client)
+	if !IsUnreachable(err) {
+		t.Fatalf("Expected an UnreachableError, got %v", err)
+	}
+	if v.Client == nil || v.Server != nil {
+		t.Errorf("Expected only a client version, got %+v", v)
+	}
+}
@@ -0,0 +1,167 @@
+// Package kubediff compares the resources that Kubernetes runs with the
+// manifests they were installed from.
+//
+// A resource that Kubernetes returns has much that its manifest does not:
+// its status, the metadata that the API server keeps, and the fields that it
+// defaulted. Normalize leaves those out, so that Diff only shows what the
+// manifest would change.
+package kubediff
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"gopkg.in/yaml.v2"
+)
+
+// serverMetadata are the fields of metadata that the API server sets.
+var serverMetadata = []string{
+	"creationTimestamp",
+	"deletionGracePeriodSeconds",
+	"deletionTimestamp",
+	"generation",
+	"managedFields",
+	"resourceVersion",
+	"selfLink",
+	"uid",
+}
+
+// lastApplied is the annotation in which 'kubectl apply' records the manifest
+// it applied.
+const lastApplied = "kubectl.kubernetes.io/last-applied-configuration"
+
+// Decode parses a JSON or YAML resource, as JSON would decode it.
+func Decode(data []byte) (map[string]interface{}, error) {
+	var v interface{}
+	if err := yaml.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	obj, ok := jsonValue(v).(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("not an object")
+	}
+	return obj, nil
+}
+
+// jsonValue converts what YAML decodes to what JSON would, so that the same
+// resource compares equal from either: maps have string keys, and numbers are
+// float64.
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[fmt.Sprint(k)] = jsonValue(e)
+		}
+		return m
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = jsonValue(e)
+		}
+		return v
+	case []interface{}:
+		for i, e := range v {
+			v[i] = jsonValue(e)
+		}
+		return v
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case uint64:
+		return float64(v)
+	case float32:
+		return float64(v)
+	}
+	return v
+}
+
+// Normalize returns what of a live resource its manifest, desired, can set:
+// live without its status, the metadata of the API server, the annotation of
+// 'kubectl apply', and every field that desired does not have.
+//
+// A field that desired does not have is taken to be defaulted, or set by the
+// cluster, so a field that was removed from a manifest after it was
+// installed is left out too. Lists are compared item by item if they have as
+// many items, and kept whole otherwise.
+func Normalize(live, desired map[string]interface{}) map[string]interface{} {
+	// The maps that prune returns are new, so they can be changed.
+	res := prune(live, desired).(map[string]interface{})
+	delete(res, "status")
+	if md, ok := res["metadata"].(map[string]interface{}); ok {
+		for _, f := range serverMetadata {
+			delete(md, f)
+		}
+		if ann, ok := md["annotations"].(map[string]interface{}); ok {
+			delete(ann, lastApplied)
+		}
+	}
+	return res
+}
+
+// prune removes the fields of live that desired does not have.
+func prune(live, desired interface{}) interface{} {
+	switch d := desired.(type) {
+	case map[string]interface{}:
+		l, ok := live.(map[string]interface{})
+		if !ok {
+			return live
+		}
+		res := map[string]interface{}{}
+		for k, v := range l {
+			if dv, ok := d[k]; ok {
+				res[k] = prune(v, dv)
+			}
+		}
+		return res
+	case []interface{}:
+		l, ok := live.([]interface{})
+		if !ok || len(l) != len(d) {
+			return live
+		}
+		res := make([]interface{}, len(l))
+		for i := range l {
+			res[i] = prune(l[i], d[i])
+		}
+		return res
+	}
+	return live
+}
+
+// Diff returns a unified diff from the normalized live resource to the
+// manifest, as YAML with sorted keys, whose files are labelled from and to.
+// It returns "" if they are the same. A nil live resource, one that is not in
+// Kubernetes, is diffed from /dev/null.
+func Diff(live, desired map[string]interface{}, from, to string) (string, error) {
+	var a []string
+	if live == nil {
+		from = "/dev/null"
+	} else {
+		y, err := yaml.Marshal(Normalize(live, desired))
+		if err != nil {
+			return "", err
+		}
+		a = splitLines(string(y))
+	}
+	y, err := yaml.Marshal(desired)
+	if err != nil {
+		return "", err
+	}
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        a,
+		B:        splitLines(string(y)),
+		FromFile: from,
+		ToFile:   to,
+		Context:  3,
+	})
+}
+
+// splitLines splits YAML into lines for a diff, keeping their line endings.
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
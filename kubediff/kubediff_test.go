@@ -0,0 +1,86 @@
+package kubediff
+
+import (
+	"strings"
+	"testing"
+)
+
+const manifest = `apiVersion: v1
+kind: Pod
+metadata:
+  name: redis
+  labels:
+    app: redis
+spec:
+  containers:
+  - name: redis
+    image: redis:3
+    ports:
+    - containerPort: 6379
+`
+
+const live = `{
+  "apiVersion": "v1",
+  "kind": "Pod",
+  "metadata": {
+    "name": "redis",
+    "namespace": "default",
+    "uid": "6c6f2f0e",
+    "resourceVersion": "1234",
+    "creationTimestamp": "2016-03-01T10:00:00Z",
+    "labels": {"app": "redis", "chart.helm.sh/name": "redis"},
+    "annotations": {"chart.helm.sh/version": "0.1.0"}
+  },
+  "spec": {
+    "containers": [{
+      "name": "redis",
+      "image": "redis:3",
+      "imagePullPolicy": "IfNotPresent",
+      "ports": [{"containerPort": 6379, "protocol": "TCP"}]
+    }],
+    "dnsPolicy": "ClusterFirst",
+    "restartPolicy": "Always"
+  },
+  "status": {"phase": "Running"}
+}`
+
+func decode(t *testing.T, data string) map[string]interface{} {
+	obj, err := Decode([]byte(data))
+	if err != nil {
+		t.Fatalf("Could not decode: %s", err)
+	}
+	return obj
+}
+
+func TestDiff(t *testing.T) {
+	desired := decode(t, manifest)
+	d, err := Diff(decode(t, live), desired, "live/Pod/redis", "redis/Pod/redis")
+	if err != nil || d != "" {
+		t.Errorf("Expected the defaults, the status, and the server metadata to be left out, got %q, %v", d, err)
+	}
+
+	changed := strings.Replace(live, `"image": "redis:3"`, `"image": "redis:2"`, 1)
+	d, err = Diff(decode(t, changed), desired, "live/Pod/redis", "redis/Pod/redis")
+	if err != nil || !strings.Contains(d, "--- live/Pod/redis\n+++ redis/Pod/redis\n") || !strings.Contains(d, "\n-  - image: redis:2\n+  - image: redis:3\n") {
+		t.Errorf("Expected the image to differ, got\n%s, %v", d, err)
+	}
+	if strings.Contains(d, "IfNotPresent") || strings.Contains(d, "Running") {
+		t.Errorf("Expected only the fields of the manifest, got\n%s", d)
+	}
+
+	d, err = Diff(nil, desired, "live/Pod/redis", "redis/Pod/redis")
+	if err != nil || !strings.HasPrefix(d, "--- /dev/null\n") || !strings.Contains(d, "+kind: Pod\n") {
+		t.Errorf("Expected the whole manifest to be added, got\n%s, %v", d, err)
+	}
+}
+
+func TestNormalizeLists(t *testing.T) {
+	desired := decode(t, manifest)
+	// A container that the manifest does not have keeps the list whole.
+	extra := strings.Replace(live, `"containers": [{`, `"containers": [{"name": "sidecar", "image": "busybox"}, {`, 1)
+	n := Normalize(decode(t, extra), desired)
+	cs := n["spec"].(map[string]interface{})["containers"].([]interface{})
+	if len(cs) != 2 || cs[1].(map[string]interface{})["imagePullPolicy"] != "IfNotPresent" {
+		t.Errorf("Expected both containers as they are, got %v", cs)
+	}
+}
@@ -0,0 +1,513 @@
+// Package kubeschema validates Kubernetes manifests against the OpenAPI
+// schemas of their kinds, without a cluster.
+//
+// The schemas come from the Swagger document of a Kubernetes release, which
+// describes every built-in kind, and from CustomResourceDefinitions, which
+// describe their own kinds.
+package kubeschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/helm/helm-classic/codec"
+)
+
+// Schema is the part of an OpenAPI schema that validation uses.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Additional        `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	AnyOf                []*Schema          `json:"anyOf,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+	// PreserveUnknown allows fields that are not in Properties.
+	PreserveUnknown bool `json:"x-kubernetes-preserve-unknown-fields,omitempty"`
+	// IntOrString allows either an integer or a string.
+	IntOrString bool `json:"x-kubernetes-int-or-string,omitempty"`
+	// Kinds are the kinds that a Swagger definition is the schema of.
+	Kinds []*GroupVersionKind `json:"x-kubernetes-group-version-kind,omitempty"`
+
+	// quantity is a string that may also be given as a number.
+	quantity bool
+}
+
+// Additional is the additionalProperties of a schema: either true or false,
+// or the schema of every field that is not in Properties.
+type Additional struct {
+	Allowed bool
+	Schema  *Schema
+}
+
+// UnmarshalJSON decodes either a boolean or a schema.
+func (a *Additional) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, &a.Allowed); err == nil {
+		return nil
+	}
+	a.Allowed, a.Schema = true, &Schema{}
+	return json.Unmarshal(b, a.Schema)
+}
+
+// GroupVersionKind names a kind. Group is empty for the core kinds.
+type GroupVersionKind struct {
+	Group   string `json:"group"`
+	Version string `json:"version"`
+	Kind    string `json:"kind"`
+}
+
+// APIVersion returns the apiVersion of the kind, as in a manifest.
+func (g *GroupVersionKind) APIVersion() string {
+	if g.Group == "" {
+		return g.Version
+	}
+	return g.Group + "/" + g.Version
+}
+
+// Set is the schemas of some kinds, by apiVersion and kind.
+type Set struct {
+	// definitions are the Swagger definitions, which $refs name.
+	definitions map[string]*Schema
+	kinds       map[string]*Schema
+	// served are the apiVersions of each kind of Kubernetes itself, those
+	// of the io.k8s definitions of Swagger documents.
+	served map[string][]string
+}
+
+// NewSet returns a set without any schemas.
+func NewSet() *Set {
+	return &Set{definitions: map[string]*Schema{}, kinds: map[string]*Schema{}, served: map[string][]string{}}
+}
+
+func kindKey(apiVersion, kind string) string {
+	return apiVersion + "/" + kind
+}
+
+// Len returns the number of kinds that the set has schemas for.
+func (s *Set) Len() int {
+	return len(s.kinds)
+}
+
+// Known reports whether the set has a schema for a kind.
+func (s *Set) Known(apiVersion, kind string) bool {
+	_, ok := s.kinds[kindKey(apiVersion, kind)]
+	return ok
+}
+
+// HasKubernetes reports whether the set has the kinds of Kubernetes itself,
+// from a Swagger document.
+func (s *Set) HasKubernetes() bool {
+	return len(s.served) > 0
+}
+
+// Served returns the apiVersions, sorted, that Kubernetes serves a kind of
+// its own as. It is empty for other kinds.
+func (s *Set) Served(kind string) []string {
+	return s.served[kind]
+}
+
+// KubernetesGroup reports whether an apiVersion is in a group of Kubernetes
+// itself: the core group, a group without a domain such as apps or batch, or
+// a group in k8s.io. Other groups are those of custom kinds.
+func KubernetesGroup(apiVersion string) bool {
+	i := strings.LastIndex(apiVersion, "/")
+	if i < 0 {
+		return true
+	}
+	group := apiVersion[:i]
+	return !strings.Contains(group, ".") || strings.HasSuffix(group, ".k8s.io")
+}
+
+// AddSwagger adds the kinds of a Swagger 2.0 document, such as the
+// api/openapi-spec/swagger.json of a Kubernetes release, or what the
+// /openapi/v2 endpoint of a cluster returns.
+//
+// It returns the number of kinds that were added.
+func (s *Set) AddSwagger(data []byte) (int, error) {
+	doc := struct {
+		Definitions map[string]*Schema `json:"definitions"`
+	}{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return 0, err
+	}
+	if len(doc.Definitions) == 0 {
+		return 0, fmt.Errorf("the document has no definitions")
+	}
+	n := 0
+	for name, d := range doc.Definitions {
+		d.quantity = quantities[name]
+		s.definitions[name] = d
+		for _, k := range d.Kinds {
+			s.kinds[kindKey(k.APIVersion(), k.Kind)] = d
+			n++
+			if strings.HasPrefix(name, "io.k8s.") && KubernetesGroup(k.APIVersion()) {
+				s.serve(k.Kind, k.APIVersion())
+			}
+		}
+	}
+	return n, nil
+}
+
+// serve records that Kubernetes serves a kind as an apiVersion.
+func (s *Set) serve(kind, apiVersion string) {
+	vs := s.served[kind]
+	i := sort.SearchStrings(vs, apiVersion)
+	if i < len(vs) && vs[i] == apiVersion {
+		return
+	}
+	s.served[kind] = append(vs[:i], append([]string{apiVersion}, vs[i:]...)...)
+}
+
+// crd is the part of a CustomResourceDefinition, of apiextensions.k8s.io/v1
+// or v1beta1, that has its schemas.
+type crd struct {
+	Kind string `json:"kind"`
+	Spec struct {
+		Group string `json:"group"`
+		Names struct {
+			Kind string `json:"kind"`
+		} `json:"names"`
+		// Version and Validation are those of v1beta1, which may be shared
+		// by every version.
+		Version    string     `json:"version"`
+		Validation *crdSchema `json:"validation"`
+		Versions   []struct {
+			Name   string     `json:"name"`
+			Schema *crdSchema `json:"schema"`
+		} `json:"versions"`
+	} `json:"spec"`
+}
+
+type crdSchema struct {
+	OpenAPIV3Schema *Schema `json:"openAPIV3Schema"`
+}
+
+// AddCRD adds the kinds of a CustomResourceDefinition, as JSON.
+//
+// Every version that has a schema is added. Since the metadata of a custom
+// resource is that of every object, not of its CRD, it is checked only if
+// the set also has the Swagger definitions of Kubernetes. It returns the
+// number of kinds that were added.
+func (s *Set) AddCRD(data []byte) (int, error) {
+	c := &crd{}
+	if err := json.Unmarshal(data, c); err != nil {
+		return 0, err
+	}
+	if c.Kind != "CustomResourceDefinition" {
+		return 0, fmt.Errorf("expected a CustomResourceDefinition, got %q", c.Kind)
+	}
+	if c.Spec.Group == "" || c.Spec.Names.Kind == "" {
+		return 0, fmt.Errorf("the CustomResourceDefinition has no group or kind")
+	}
+	add := func(version string, sch *crdSchema) {
+		if sch == nil || sch.OpenAPIV3Schema == nil {
+			return
+		}
+		root := *sch.OpenAPIV3Schema
+		root.Properties = map[string]*Schema{
+			"apiVersion": {Type: "string"},
+			"kind":       {Type: "string"},
+			"metadata":   {Ref: objectMetaRef},
+		}
+		for k, v := range sch.OpenAPIV3Schema.Properties {
+			if k != "metadata" {
+				root.Properties[k] = v
+			}
+		}
+		s.kinds[kindKey(c.Spec.Group+"/"+version, c.Spec.Names.Kind)] = &root
+	}
+
+	n := len(s.kinds)
+	for _, v := range c.Spec.Versions {
+		if v.Schema != nil {
+			add(v.Name, v.Schema)
+		} else {
+			add(v.Name, c.Spec.Validation)
+		}
+	}
+	if len(c.Spec.Versions) == 0 {
+		add(c.Spec.Version, c.Spec.Validation)
+	}
+	return len(s.kinds) - n, nil
+}
+
+// objectMetaRef is the Swagger definition of the metadata of every object.
+const objectMetaRef = "#/definitions/io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta"
+
+// AddDir adds the schemas of every file in dir, and its subdirectories.
+//
+// A .json file is either a Swagger document or a CustomResourceDefinition. A
+// .yaml or .yml file may hold several documents, and its
+// CustomResourceDefinitions are added. Other files are ignored. It returns
+// the number of kinds that were added.
+func (s *Set) AddDir(dir string) (int, error) {
+	n := 0
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			return nil
+		}
+		var added int
+		switch filepath.Ext(path) {
+		case ".json":
+			added, err = s.addJSONFile(path)
+		case ".yaml", ".yml":
+			added, err = s.addYAMLFile(path)
+		default:
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %s", path, err)
+		}
+		n += added
+		return nil
+	})
+	return n, err
+}
+
+func (s *Set) addJSONFile(path string) (int, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	probe := struct {
+		Definitions json.RawMessage `json:"definitions"`
+	}{}
+	if err := json.Unmarshal(b, &probe); err != nil {
+		return 0, err
+	}
+	if probe.Definitions != nil {
+		return s.AddSwagger(b)
+	}
+	return s.AddCRD(b)
+}
+
+func (s *Set) addYAMLFile(path string) (int, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	docs, err := codec.YAML.Decode(b).All()
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, d := range docs {
+		j, err := d.JSON()
+		if err != nil {
+			return n, err
+		}
+		kind := struct {
+			Kind string `json:"kind"`
+		}{}
+		if json.Unmarshal(j, &kind); kind.Kind != "CustomResourceDefinition" {
+			continue
+		}
+		added, err := s.AddCRD(j)
+		if err != nil {
+			return n, err
+		}
+		n += added
+	}
+	return n, nil
+}
+
+// FieldError is a field of an object that does not conform to its schema.
+type FieldError struct {
+	// Path is the field, such as spec.containers[0].image.
+	Path    string
+	Message string
+}
+
+func (e *FieldError) Error() string {
+	if e.Path == "" {
+		return "(top level): " + e.Message
+	}
+	return e.Path + ": " + e.Message
+}
+
+// Validate returns every way in which an object, as decoded JSON, does not
+// conform to the schema of its kind, sorted by path.
+//
+// Fields that the schema does not have are errors, unless the schema allows
+// them. If the set has no schema for the kind, there are no errors.
+func (s *Set) Validate(apiVersion, kind string, obj interface{}) []*FieldError {
+	errs := []*FieldError{}
+	if sch, ok := s.kinds[kindKey(apiVersion, kind)]; ok {
+		s.validate(sch, obj, "", &errs)
+	}
+	sort.SliceStable(errs, func(i, j int) bool { return errs[i].Path < errs[j].Path })
+	return errs
+}
+
+// resolve follows the $refs of a schema. A $ref to a definition that the set
+// does not have is nil, which allows anything.
+func (s *Set) resolve(sch *Schema) *Schema {
+	for i := 0; sch != nil && sch.Ref != "" && i < 32; i++ {
+		sch = s.definitions[strings.TrimPrefix(sch.Ref, "#/definitions/")]
+	}
+	return sch
+}
+
+func (s *Set) validate(sch *Schema, v interface{}, path string, errs *[]*FieldError) {
+	sch = s.resolve(sch)
+	if sch == nil || v == nil {
+		// A null field is as good as a missing one.
+		return
+	}
+	fail := func(p, format string, a ...interface{}) {
+		*errs = append(*errs, &FieldError{Path: p, Message: fmt.Sprintf(format, a...)})
+	}
+
+	if alts := append(sch.AnyOf, sch.OneOf...); len(alts) > 0 {
+		ok := false
+		for _, alt := range alts {
+			var e []*FieldError
+			s.validate(alt, v, path, &e)
+			if len(e) == 0 {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			fail(path, "matches none of the allowed schemas")
+			return
+		}
+	}
+
+	if t := typeName(v); !s.hasType(sch, t) {
+		fail(path, "expected %s, got %s", expected(sch), t)
+		return
+	}
+	if len(sch.Enum) > 0 && !inEnum(v, sch.Enum) {
+		allowed := make([]string, len(sch.Enum))
+		for i, e := range sch.Enum {
+			allowed[i] = fmt.Sprint(e)
+		}
+		fail(path, "%v is not one of %s", v, strings.Join(allowed, ", "))
+	}
+
+	switch x := v.(type) {
+	case map[string]interface{}:
+		for _, r := range sch.Required {
+			if _, ok := x[r]; !ok {
+				fail(joinKey(path, r), "is required, but not set")
+			}
+		}
+		for k, sub := range x {
+			if p, ok := sch.Properties[k]; ok {
+				s.validate(p, sub, joinKey(path, k), errs)
+				continue
+			}
+			switch a := sch.AdditionalProperties; {
+			case a != nil && a.Schema != nil:
+				s.validate(a.Schema, sub, joinKey(path, k), errs)
+			case a != nil && !a.Allowed:
+				fail(joinKey(path, k), "is not a known field%s", knownFields(sch.Properties))
+			case a == nil && len(sch.Properties) > 0 && !sch.PreserveUnknown:
+				fail(joinKey(path, k), "is not a known field%s", knownFields(sch.Properties))
+			}
+		}
+	case []interface{}:
+		for i, item := range x {
+			s.validate(sch.Items, item, path+"["+strconv.Itoa(i)+"]", errs)
+		}
+	}
+}
+
+// hasType reports whether a value of type t conforms to the type of a
+// schema.
+//
+// Kubernetes accepts an integer for a quantity, as in "cpu: 1", and either
+// an integer or a string for an int-or-string, as in "port: http".
+func (s *Set) hasType(sch *Schema, t string) bool {
+	if sch.IntOrString || sch.Format == "int-or-string" {
+		return t == "integer" || t == "string"
+	}
+	switch sch.Type {
+	case "":
+		// Objects with properties need not say that they are objects.
+		return len(sch.Properties) == 0 || t == "object"
+	case "number":
+		return t == "number" || t == "integer"
+	case "string":
+		return t == "string" || sch.quantity && (t == "integer" || t == "number")
+	}
+	return sch.Type == t
+}
+
+// quantities are the Swagger definitions whose values may be numbers.
+var quantities = map[string]bool{"io.k8s.apimachinery.pkg.api.resource.Quantity": true}
+
+// expected describes the type of a schema, for a message.
+func expected(sch *Schema) string {
+	switch {
+	case sch.IntOrString || sch.Format == "int-or-string":
+		return "integer or string"
+	case sch.Type == "":
+		return "object"
+	}
+	return sch.Type
+}
+
+// typeName returns the JSON type of a decoded value.
+func typeName(v interface{}) string {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64:
+		if x == math.Trunc(x) {
+			return "integer"
+		}
+		return "number"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// inEnum reports whether a value is one of the allowed values.
+func inEnum(v interface{}, enum []interface{}) bool {
+	for _, e := range enum {
+		if fmt.Sprint(v) == fmt.Sprint(e) {
+			return true
+		}
+	}
+	return false
+}
+
+// knownFields lists the fields of properties, for an error message.
+func knownFields(props map[string]*Schema) string {
+	if len(props) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(props))
+	for k := range props {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return " (expected one of " + strings.Join(names, ", ") + ")"
+}
+
+func joinKey(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
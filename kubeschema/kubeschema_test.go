@@ -0,0 +1,130 @@
+package kubeschema
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func testSet(t *testing.T) *Set {
+	s := NewSet()
+	b, err := ioutil.ReadFile("../testdata/schemas/swagger.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := s.AddSwagger(b); err != nil || n != 2 {
+		t.Fatalf("Expected 2 kinds, got %d (%v)", n, err)
+	}
+	return s
+}
+
+func decode(t *testing.T, doc string) interface{} {
+	var v interface{}
+	if err := json.Unmarshal([]byte(doc), &v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func messages(errs []*FieldError) string {
+	m := make([]string, len(errs))
+	for i, e := range errs {
+		m[i] = e.Error()
+	}
+	return strings.Join(m, "\n")
+}
+
+func TestValidate(t *testing.T) {
+	s := testSet(t)
+
+	good := decode(t, `{"apiVersion": "v1", "kind": "Pod", "metadata": {"name": "web", "labels": {"app": "web"}},
+		"spec": {"containers": [{"name": "web", "image": "nginx", "ports": [{"containerPort": 80}],
+		"resources": {"limits": {"cpu": 1, "memory": "128Mi"}}, "env": null}]}}`)
+	if errs := s.Validate("v1", "Pod", good); len(errs) > 0 {
+		t.Errorf("Expected no errors, got:\n%s", messages(errs))
+	}
+
+	bad := decode(t, `{"apiVersion": "v1", "kind": "Pod", "metadata": {"name": "web", "labels": {"tier": 1}},
+		"spec": {"containers": [{"name": "web", "image": 5, "imagePullPolicy": "Always",
+		"ports": [{"containerPort": "80"}, {"name": "http"}]}], "restartPolicy": "Never"}, "status": {}}`)
+	expect := strings.Join([]string{
+		"metadata.labels.tier: expected string, got integer",
+		"spec.containers[0].image: expected string, got integer",
+		"spec.containers[0].imagePullPolicy: is not a known field (expected one of env, image, name, ports, resources)",
+		"spec.containers[0].ports[0].containerPort: expected integer, got string",
+		"spec.containers[0].ports[1].containerPort: is required, but not set",
+		"status: is not a known field (expected one of apiVersion, kind, metadata, spec)",
+	}, "\n")
+	if got := messages(s.Validate("v1", "Pod", bad)); got != expect {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expect, got)
+	}
+
+	svc := decode(t, `{"apiVersion": "v1", "kind": "Service", "spec": {"ports": [{"port": 80, "targetPort": "http"}, {"port": 81, "targetPort": 8081}, {"port": 82, "targetPort": true}]}}`)
+	if got := messages(s.Validate("v1", "Service", svc)); got != "spec.ports[2].targetPort: expected integer or string, got boolean" {
+		t.Errorf("Unexpected errors for the service:\n%s", got)
+	}
+
+	if s.Known("extensions/v1beta1", "Job") {
+		t.Error("Expected no schema for extensions/v1beta1 Job")
+	}
+	if errs := s.Validate("extensions/v1beta1", "Job", bad); len(errs) > 0 {
+		t.Errorf("Expected a kind without a schema to pass, got:\n%s", messages(errs))
+	}
+}
+
+func TestServed(t *testing.T) {
+	s := NewSet()
+	if s.HasKubernetes() {
+		t.Error("Expected an empty set not to have the kinds of Kubernetes")
+	}
+	s = testSet(t)
+	if !s.HasKubernetes() || strings.Join(s.Served("Pod"), ",") != "v1" || len(s.Served("Deployment")) != 0 {
+		t.Errorf("Expected Pod to be served as v1, got %v", s.Served("Pod"))
+	}
+	for v, expect := range map[string]bool{"v1": true, "apps/v1": true, "networking.k8s.io/v1": true, "example.com/v1": false, "cert-manager.io/v1": false} {
+		if KubernetesGroup(v) != expect {
+			t.Errorf("Expected KubernetesGroup(%q) to be %t", v, expect)
+		}
+	}
+}
+
+func TestAddDir(t *testing.T) {
+	s := NewSet()
+	n, err := s.AddDir("../testdata/schemas")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 || !s.Known("example.com/v1", "Backup") || !s.Known("v1", "Pod") || s.Known("v1", "ConfigMap") {
+		t.Fatalf("Expected Pod, Service, and Backup, got %d kinds", n)
+	}
+
+	backup := decode(t, `{"apiVersion": "example.com/v1", "kind": "Backup", "metadata": {"name": "nightly", "labels": {"a": 1}},
+		"spec": {"keep": "seven", "options": {"anything": ["goes"]}, "extra": true}}`)
+	expect := strings.Join([]string{
+		"metadata.labels.a: expected string, got integer",
+		"spec.extra: is not a known field (expected one of keep, options, schedule)",
+		"spec.keep: expected integer, got string",
+		"spec.schedule: is required, but not set",
+	}, "\n")
+	if got := messages(s.Validate("example.com/v1", "Backup", backup)); got != expect {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expect, got)
+	}
+
+	// Without the Swagger definitions, the metadata is not checked.
+	crdOnly := NewSet()
+	b, _ := ioutil.ReadFile("../testdata/schemas/crd.yaml")
+	dir, err := ioutil.TempDir("", "kubeschema")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(dir+"/crd.yml", b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := crdOnly.AddDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	if got := messages(crdOnly.Validate("example.com/v1", "Backup", backup)); strings.Contains(got, "metadata") {
+		t.Errorf("Expected the metadata to be unchecked, got:\n%s", got)
+	}
+}
@@ -0,0 +1,166 @@
+// Package lock provides advisory file locks between Helm Classic processes.
+//
+// A lock is a file that holds the pid of the process that owns it. A lock
+// whose owner is no longer running is stale, and is reclaimed by the next
+// process that wants it.
+package lock
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/helm/helm-classic/log"
+)
+
+// Timeout is how long Acquire waits for a lock that is held by another process.
+var Timeout = 2 * time.Minute
+
+// pollInterval is how often Acquire checks a held lock.
+var pollInterval = 100 * time.Millisecond
+
+// emptyGrace is how long an empty lock file is left alone. The owner may
+// not have written its pid yet.
+const emptyGrace = 10 * time.Second
+
+// Lock is a held lock.
+type Lock struct {
+	path string
+}
+
+// Acquire takes the lock at path, waiting up to Timeout if another process holds it.
+//
+// The directory of path is created if necessary.
+func Acquire(path string) (*Lock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(Timeout)
+	waiting := false
+	for {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			_, err = fmt.Fprintf(f, "%d\n", os.Getpid())
+			f.Close()
+			if err != nil {
+				os.Remove(path)
+				return nil, err
+			}
+			log.Debug("Acquired lock %s", path)
+			return &Lock{path: path}, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+
+		pid, ok := owner(path)
+		if ok && pid == 0 {
+			// Released while we looked.
+			continue
+		}
+		if stale(path, pid, ok) {
+			if err := reclaim(path, pid, ok); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out after %s waiting for %s, held by process %d", Timeout, path, pid)
+		}
+		if !waiting {
+			log.Info("Waiting for %s, held by process %d...", path, pid)
+			waiting = true
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// Release gives up the lock.
+func (l *Lock) Release() error {
+	log.Debug("Releasing lock %s", l.path)
+	return os.Remove(l.path)
+}
+
+// owner reads the pid from a lock file.
+//
+// It returns 0 and true if the file no longer exists, and false if the file
+// does not hold a pid.
+func owner(path string) (int, bool) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return 0, true
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return 0, false
+	}
+	return pid, true
+}
+
+// stale returns true if the lock at path can be taken from its owner.
+func stale(path string, pid int, ok bool) bool {
+	if !ok {
+		fi, err := os.Stat(path)
+		return err == nil && time.Since(fi.ModTime()) > emptyGrace
+	}
+	if running(pid) {
+		return false
+	}
+	// Make sure the lock was not reclaimed and taken by another process
+	// since it was read.
+	again, _ := owner(path)
+	return again == pid
+}
+
+// reclaim removes the stale lock at path, whose owner was read as pid and ok.
+//
+// Processes that find the same stale lock race to reclaim it, and the
+// first may have taken the lock again by the time the others get here. So
+// the file is first moved to a name of this process's own, which only one
+// of them can do, and only removed if it is still the stale lock. If it is
+// not, it is put back for the process that holds it.
+func reclaim(path string, pid int, ok bool) error {
+	tmp := fmt.Sprintf("%s.%d-%d.stale", path, os.Getpid(), time.Now().UnixNano())
+	if err := os.Rename(path, tmp); err != nil {
+		if os.IsNotExist(err) {
+			// Another process reclaimed it.
+			return nil
+		}
+		return err
+	}
+	if !stale(tmp, pid, ok) {
+		err := os.Link(tmp, path)
+		os.Remove(tmp)
+		if err != nil && !os.IsExist(err) {
+			return err
+		}
+		return nil
+	}
+	log.Warn("Removed stale lock %s left by process %d", path, pid)
+	return os.Remove(tmp)
+}
+
+// running returns true if a process with the given pid exists.
+//
+// On Windows, finding a process fails if it does not exist. Elsewhere, it
+// always succeeds, and the process is sent the null signal instead.
+func running(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	defer p.Release()
+	if runtime.GOOS == "windows" {
+		return true
+	}
+	err = p.Signal(syscall.Signal(0))
+	return err == nil || err == syscall.EPERM
+}
@@ -0,0 +1,187 @@
+package lock
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func tmpLock(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "helmc-lock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(dir, "locks", "test.lock"), func() { os.RemoveAll(dir) }
+}
+
+func TestAcquireRelease(t *testing.T) {
+	path, cleanup := tmpLock(t)
+	defer cleanup()
+
+	l, err := Acquire(path)
+	if err != nil {
+		t.Fatalf("Could not acquire lock: %s", err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Expected a lock file: %s", err)
+	}
+	if pid := strings.TrimSpace(string(data)); pid != strconv.Itoa(os.Getpid()) {
+		t.Errorf("Expected lock to hold pid %d, got %q", os.Getpid(), pid)
+	}
+
+	if err := l.Release(); err != nil {
+		t.Fatalf("Could not release lock: %s", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected lock file to be removed")
+	}
+
+	l, err = Acquire(path)
+	if err != nil {
+		t.Fatalf("Could not acquire lock again: %s", err)
+	}
+	l.Release()
+}
+
+func TestAcquireTimeout(t *testing.T) {
+	path, cleanup := tmpLock(t)
+	defer cleanup()
+	defer func(d time.Duration) { Timeout = d }(Timeout)
+	Timeout = 300 * time.Millisecond
+
+	l, err := Acquire(path)
+	if err != nil {
+		t.Fatalf("Could not acquire lock: %s", err)
+	}
+	defer l.Release()
+
+	_, err = Acquire(path)
+	if err == nil {
+		t.Fatalf("Expected a held lock to time out")
+	}
+	if !strings.Contains(err.Error(), "held by process "+strconv.Itoa(os.Getpid())) {
+		t.Errorf("Expected the error to name the owner, got %q", err)
+	}
+}
+
+func TestAcquireWaits(t *testing.T) {
+	path, cleanup := tmpLock(t)
+	defer cleanup()
+
+	l, err := Acquire(path)
+	if err != nil {
+		t.Fatalf("Could not acquire lock: %s", err)
+	}
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		l.Release()
+	}()
+
+	start := time.Now()
+	l2, err := Acquire(path)
+	if err != nil {
+		t.Fatalf("Expected to acquire the lock once released: %s", err)
+	}
+	defer l2.Release()
+	if time.Since(start) < 200*time.Millisecond {
+		t.Errorf("Expected Acquire to wait for the owner")
+	}
+}
+
+// deadPid returns the pid of a process that has exited, which a stale lock
+// holds.
+func deadPid(t *testing.T) int {
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Skipf("Could not run a process: %s", err)
+	}
+	return cmd.Process.Pid
+}
+
+func TestStaleLock(t *testing.T) {
+	path, cleanup := tmpLock(t)
+	defer cleanup()
+
+	dead := deadPid(t)
+	os.MkdirAll(filepath.Dir(path), 0755)
+	ioutil.WriteFile(path, []byte(strconv.Itoa(dead)+"\n"), 0644)
+
+	l, err := Acquire(path)
+	if err != nil {
+		t.Fatalf("Expected a stale lock to be reclaimed: %s", err)
+	}
+	defer l.Release()
+	data, _ := ioutil.ReadFile(path)
+	if pid := strings.TrimSpace(string(data)); pid != strconv.Itoa(os.Getpid()) {
+		t.Errorf("Expected lock to hold pid %d, got %q", os.Getpid(), pid)
+	}
+}
+
+func TestReclaimTakenLock(t *testing.T) {
+	path, cleanup := tmpLock(t)
+	defer cleanup()
+	dead := deadPid(t)
+
+	// Another process reclaimed the stale lock and took it after this one
+	// read it.
+	os.MkdirAll(filepath.Dir(path), 0755)
+	ioutil.WriteFile(path, []byte(strconv.Itoa(os.Getpid())+"\n"), 0644)
+	if err := reclaim(path, dead, true); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if pid := strings.TrimSpace(string(data)); err != nil || pid != strconv.Itoa(os.Getpid()) {
+		t.Errorf("Expected the lock of its new owner to be left alone, got %q, %v", pid, err)
+	}
+	if entries, _ := ioutil.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("Expected only the lock to be left, got %d files", len(entries))
+	}
+}
+
+func TestStaleLockRace(t *testing.T) {
+	path, cleanup := tmpLock(t)
+	defer cleanup()
+	dead := deadPid(t)
+	os.MkdirAll(filepath.Dir(path), 0755)
+	defer func(d time.Duration) { pollInterval = d }(pollInterval)
+	pollInterval = time.Millisecond
+
+	for round := 0; round < 20; round++ {
+		ioutil.WriteFile(path, []byte(strconv.Itoa(dead)+"\n"), 0644)
+		var holders, most int32
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				l, err := Acquire(path)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				n := atomic.AddInt32(&holders, 1)
+				for {
+					m := atomic.LoadInt32(&most)
+					if n <= m || atomic.CompareAndSwapInt32(&most, m, n) {
+						break
+					}
+				}
+				time.Sleep(time.Millisecond)
+				atomic.AddInt32(&holders, -1)
+				l.Release()
+			}()
+		}
+		wg.Wait()
+		if most != 1 {
+			t.Fatalf("Expected one holder of the lock at a time, got %d", most)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package log
+
+import (
+	"log"
+
+	pretty "github.com/deis/pkg/prettyprint"
+)
+
+// Buffer collects messages so that they can be printed together later.
+//
+// It is used by tasks that run concurrently, so that their output does not
+// interleave. Messages keep their order and destination when flushed. A
+// Buffer is not safe for use by multiple goroutines.
+type Buffer struct {
+	// Log is where the messages are flushed to. If it is nil, they are
+	// printed with the package-level functions.
+	Log *Logger
+
+	entries []func()
+}
+
+// Msg buffers a message for Msg.
+func (b *Buffer) Msg(format string, v ...interface{}) {
+	b.entries = append(b.entries, func() { b.Log.Msg(format, v...) })
+}
+
+// Info buffers a message for Info.
+func (b *Buffer) Info(format string, v ...interface{}) {
+	b.entries = append(b.entries, func() { b.Log.Info(format, v...) })
+}
+
+// Warn buffers a message for Warn.
+func (b *Buffer) Warn(format string, v ...interface{}) {
+	b.entries = append(b.entries, func() { b.Log.Warn(format, v...) })
+}
+
+// Err buffers a message for Err. ErrorState is set when the buffer is
+// flushed, unless it has a Log.
+func (b *Buffer) Err(format string, v ...interface{}) {
+	b.entries = append(b.entries, func() { b.Log.Err(format, v...) })
+}
+
+// Write buffers raw output for Stdout.
+func (b *Buffer) Write(p []byte) (int, error) {
+	c := make([]byte, len(p))
+	copy(c, p)
+	b.entries = append(b.entries, func() { b.Log.Out().Write(c) })
+	return len(p), nil
+}
+
+// Logger creates a *log.Logger that writes to the buffer, styled like New.
+func (b *Buffer) Logger() *log.Logger {
+	return log.New(b, pretty.Colorize("{{.Yellow}}--->{{.Default}} "), 0)
+}
+
+// Flush prints the buffered messages and empties the buffer.
+func (b *Buffer) Flush() {
+	for _, e := range b.entries {
+		e()
+	}
+	b.entries = nil
+}
@@ -5,6 +5,8 @@ import (
 	"io"
 	"log"
 	"os"
+	"sync"
+	"sync/atomic"
 
 	pretty "github.com/deis/pkg/prettyprint"
 )
@@ -36,7 +38,7 @@ func New() *log.Logger {
 //
 // No prettification.
 func Msg(format string, v ...interface{}) {
-	fmt.Fprintf(Stdout, appendNewLine(format), v...)
+	write(Stdout, "", format, v...)
 }
 
 // Die prints an error and then call os.Exit(1).
@@ -45,40 +47,93 @@ func Die(format string, v ...interface{}) {
 	if IsDebugging {
 		panic(fmt.Sprintf(format, v...))
 	}
-	os.Exit(1)
+	Exit(1)
 }
 
 // CleanExit prints a message and then exits with 0.
 func CleanExit(format string, v ...interface{}) {
 	Info(format, v...)
-	os.Exit(0)
+	Exit(0)
+}
+
+// halted is set by Halt.
+var halted int32
+
+// Halt makes every later Exit, Die, and CleanExit block forever instead of
+// exiting.
+//
+// It is for the code that stops helmc when it is interrupted, so that the
+// command it interrupts cannot exit before the cleanup is done.
+func Halt() {
+	atomic.StoreInt32(&halted, 1)
+}
+
+// AtExit, if set, is called with the status code just before helmc exits,
+// whether through Exit, Die, or CleanExit, or because it was stopped. It is
+// for what must be the last thing that helmc writes.
+var AtExit func(code int)
+
+// atExit makes RunAtExit call AtExit once.
+var atExit sync.Once
+
+// Exit exits with the status code, unless Halt has been called.
+func Exit(code int) {
+	if atomic.LoadInt32(&halted) != 0 {
+		select {}
+	}
+	RunAtExit(code)
+	os.Exit(code)
+}
+
+// RunAtExit calls AtExit, if it is set and has not been called yet. Code
+// that exits without Exit calls it first.
+func RunAtExit(code int) {
+	atExit.Do(func() {
+		if AtExit != nil {
+			AtExit(code)
+		}
+	})
+}
+
+// lastErr is the message of the latest Err.
+var lastErr atomic.Value
+
+// LastErr returns the message of the latest Err, without its prefix, or ""
+// if there was none.
+func LastErr() string {
+	s, _ := lastErr.Load().(string)
+	return s
 }
 
 // Err prints an error message. It does not cause an exit.
 func Err(format string, v ...interface{}) {
-	fmt.Fprint(Stderr, pretty.Colorize("{{.Red}}[ERROR]{{.Default}} "))
-	fmt.Fprintf(Stderr, appendNewLine(format), v...)
+	write(Stderr, "{{.Red}}[ERROR]{{.Default}} ", format, v...)
+	lastErr.Store(fmt.Sprintf(format, v...))
 	ErrorState = true
 }
 
 // Info prints a green-tinted message.
 func Info(format string, v ...interface{}) {
-	fmt.Fprint(Stderr, pretty.Colorize("{{.Green}}--->{{.Default}} "))
-	fmt.Fprintf(Stderr, appendNewLine(format), v...)
+	write(Stderr, "{{.Green}}--->{{.Default}} ", format, v...)
+}
+
+// Progress prints a green-tinted message that stays on one line of a
+// terminal: the line is cleared first, and no newline is added, so that the
+// next Progress replaces it. The last one should end with a newline.
+func Progress(format string, v ...interface{}) {
+	writeLine(Stderr, "{{.Green}}--->{{.Default}} ", format, v...)
 }
 
 // Debug prints a cyan-tinted message if IsDebugging is true.
 func Debug(format string, v ...interface{}) {
 	if IsDebugging {
-		fmt.Fprint(Stderr, pretty.Colorize("{{.Cyan}}[DEBUG]{{.Default}} "))
-		fmt.Fprintf(Stderr, appendNewLine(format), v...)
+		write(Stderr, "{{.Cyan}}[DEBUG]{{.Default}} ", format, v...)
 	}
 }
 
 // Warn prints a yellow-tinted warning message.
 func Warn(format string, v ...interface{}) {
-	fmt.Fprint(Stderr, pretty.Colorize("{{.Yellow}}[WARN]{{.Default}} "))
-	fmt.Fprintf(Stderr, appendNewLine(format), v...)
+	write(Stderr, "{{.Yellow}}[WARN]{{.Default}} ", format, v...)
 }
 
 func appendNewLine(format string) string {
@@ -0,0 +1,134 @@
+package log
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	pretty "github.com/deis/pkg/prettyprint"
+)
+
+// Logger writes messages in the style of the package-level functions, to
+// writers of its own.
+//
+// Programs that embed Helm Classic give a Logger to each operation, so that
+// its messages go where they choose. A nil *Logger writes to the
+// package-level Stdout and Stderr and honors IsDebugging, so that code that
+// takes a *Logger behaves like the rest of helmc when it is given none.
+type Logger struct {
+	// Stdout receives Msg, and the output of commands. If it is nil, these
+	// are discarded.
+	Stdout io.Writer
+	// Stderr receives Info, Warn, Err, and Debug. If it is nil, these are
+	// discarded.
+	Stderr io.Writer
+	// Debugging enables Debug.
+	Debugging bool
+}
+
+// Msg is like the package-level Msg.
+func (l *Logger) Msg(format string, v ...interface{}) {
+	if l == nil {
+		Msg(format, v...)
+		return
+	}
+	write(l.Stdout, "", format, v...)
+}
+
+// Err is like the package-level Err. Only a nil Logger sets ErrorState.
+func (l *Logger) Err(format string, v ...interface{}) {
+	if l == nil {
+		Err(format, v...)
+		return
+	}
+	write(l.Stderr, "{{.Red}}[ERROR]{{.Default}} ", format, v...)
+}
+
+// Info is like the package-level Info.
+func (l *Logger) Info(format string, v ...interface{}) {
+	if l == nil {
+		Info(format, v...)
+		return
+	}
+	write(l.Stderr, "{{.Green}}--->{{.Default}} ", format, v...)
+}
+
+// Progress is like the package-level Progress.
+func (l *Logger) Progress(format string, v ...interface{}) {
+	if l == nil {
+		Progress(format, v...)
+		return
+	}
+	writeLine(l.Stderr, "{{.Green}}--->{{.Default}} ", format, v...)
+}
+
+// Debug is like the package-level Debug, but honors Debugging.
+func (l *Logger) Debug(format string, v ...interface{}) {
+	if l == nil {
+		Debug(format, v...)
+		return
+	}
+	if l.Debugging {
+		write(l.Stderr, "{{.Cyan}}[DEBUG]{{.Default}} ", format, v...)
+	}
+}
+
+// Warn is like the package-level Warn.
+func (l *Logger) Warn(format string, v ...interface{}) {
+	if l == nil {
+		Warn(format, v...)
+		return
+	}
+	write(l.Stderr, "{{.Yellow}}[WARN]{{.Default}} ", format, v...)
+}
+
+// IsDebugging returns true if Debug messages are written.
+func (l *Logger) IsDebugging() bool {
+	if l == nil {
+		return IsDebugging
+	}
+	return l.Debugging
+}
+
+// Out returns the writer for Msg.
+func (l *Logger) Out() io.Writer {
+	if l == nil {
+		return Stdout
+	}
+	if l.Stdout == nil {
+		return ioutil.Discard
+	}
+	return l.Stdout
+}
+
+// ErrOut returns the writer for Info, Warn, Err, and Debug.
+func (l *Logger) ErrOut() io.Writer {
+	if l == nil {
+		return Stderr
+	}
+	if l.Stderr == nil {
+		return ioutil.Discard
+	}
+	return l.Stderr
+}
+
+// write prints a message with a colorized prefix, unless w is nil.
+func write(w io.Writer, prefix, format string, v ...interface{}) {
+	if w == nil {
+		return
+	}
+	if prefix != "" {
+		fmt.Fprint(w, pretty.Colorize(prefix))
+	}
+	fmt.Fprintf(w, appendNewLine(format), v...)
+}
+
+// writeLine is like write, but replaces the current line of a terminal, and
+// adds no newline.
+func writeLine(w io.Writer, prefix, format string, v ...interface{}) {
+	if w == nil {
+		return
+	}
+	fmt.Fprint(w, "\r\x1b[K"+pretty.Colorize(prefix))
+	fmt.Fprintf(w, format, v...)
+}
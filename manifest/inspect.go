@@ -0,0 +1,196 @@
+package manifest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/helm/helm-classic/kubectl"
+)
+
+// PodSpecPaths are the kinds whose manifests hold pods, and where the spec of
+// the pod is in each.
+var PodSpecPaths = map[string][]string{
+	"Pod":                   {"spec"},
+	"ReplicationController": {"spec", "template", "spec"},
+	"ReplicaSet":            {"spec", "template", "spec"},
+	"Deployment":            {"spec", "template", "spec"},
+	"DaemonSet":             {"spec", "template", "spec"},
+	"StatefulSet":           {"spec", "template", "spec"},
+	"Job":                   {"spec", "template", "spec"},
+	"CronJob":               {"spec", "jobTemplate", "spec", "template", "spec"},
+}
+
+// PodSpec returns the spec of the pod of obj, a manifest of kind, or nil if
+// the kind holds no pod, or obj has no spec there.
+func PodSpec(kind string, obj map[string]interface{}) map[string]interface{} {
+	path, ok := PodSpecPaths[kind]
+	if !ok {
+		return nil
+	}
+	var spec interface{} = obj
+	for _, key := range path {
+		m, ok := spec.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		spec = m[key]
+	}
+	m, _ := spec.(map[string]interface{})
+	return m
+}
+
+// Containers returns the init containers and the containers of a pod spec,
+// in that order.
+func Containers(spec map[string]interface{}) []map[string]interface{} {
+	res := []map[string]interface{}{}
+	for _, key := range []string{"initContainers", "containers"} {
+		list, _ := spec[key].([]interface{})
+		for _, item := range list {
+			if c, ok := item.(map[string]interface{}); ok {
+				res = append(res, c)
+			}
+		}
+	}
+	return res
+}
+
+// Resource is what Inspect finds in a manifest.
+type Resource struct {
+	Kind      string `json:"kind" yaml:"kind"`
+	Name      string `json:"name,omitempty" yaml:"name,omitempty"`
+	Namespace string `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	// Source is the file of the manifest.
+	Source string `json:"source,omitempty" yaml:"source,omitempty"`
+	// Images are the images of the containers of its pod, sorted.
+	Images []string `json:"images,omitempty" yaml:"images,omitempty"`
+	// Requires are what the cluster must provide, or allow, for it, such as
+	// "LoadBalancer Service" or "hostPath volume /var/log".
+	Requires []string `json:"requires,omitempty" yaml:"requires,omitempty"`
+}
+
+// ClusterScope returns a func that reports whether the resources of a kind
+// do not belong to a namespace: the built-in kinds that kubectl.ClusterScoped
+// knows, and the custom kinds that a CustomResourceDefinition of ms defines
+// with the Cluster scope.
+func ClusterScope(ms []*Manifest) func(kind string) bool {
+	custom := map[string]bool{}
+	for _, m := range ms {
+		if m.Kind != "CustomResourceDefinition" {
+			continue
+		}
+		var obj map[string]interface{}
+		if err := m.VersionedObject.Object(&obj); err != nil {
+			continue
+		}
+		if kind, ok := lookup(obj, "spec", "names", "kind").(string); ok {
+			custom[kind] = lookup(obj, "spec", "scope") == "Cluster"
+		}
+	}
+	return func(kind string) bool {
+		if cluster, ok := custom[kind]; ok {
+			return cluster
+		}
+		return kubectl.ClusterScoped(kind)
+	}
+}
+
+// Inspect describes each manifest of ms: its kind and name, the images that
+// it runs, and what it requires of the cluster, such as storage, external
+// load balancers, or access to the nodes.
+func Inspect(ms []*Manifest) ([]*Resource, error) {
+	res := make([]*Resource, 0, len(ms))
+	clusterScoped := ClusterScope(ms)
+	for _, m := range ms {
+		var obj map[string]interface{}
+		if err := m.VersionedObject.Object(&obj); err != nil {
+			return nil, fmt.Errorf("%s: %s", m.Source, err)
+		}
+		r := &Resource{Kind: m.Kind, Name: m.Name, Source: m.Source}
+		r.Namespace, _ = lookup(obj, "metadata", "namespace").(string)
+		if clusterScoped(m.Kind) {
+			r.Requires = append(r.Requires, "cluster-scoped "+m.Kind)
+		}
+		switch m.Kind {
+		case "PersistentVolumeClaim":
+			r.Requires = append(r.Requires, claim(lookup(obj, "spec")))
+		case "StatefulSet":
+			list, _ := lookup(obj, "spec", "volumeClaimTemplates").([]interface{})
+			for _, t := range list {
+				r.Requires = append(r.Requires, claim(lookup(t, "spec")))
+			}
+		case "Service":
+			if t, _ := lookup(obj, "spec", "type").(string); t == "LoadBalancer" || t == "NodePort" {
+				r.Requires = append(r.Requires, t+" Service")
+			}
+		case "Ingress":
+			r.Requires = append(r.Requires, "Ingress controller")
+		}
+		if spec := PodSpec(m.Kind, obj); spec != nil {
+			inspectPod(spec, r)
+		}
+		sort.Strings(r.Images)
+		res = append(res, r)
+	}
+	return res, nil
+}
+
+// inspectPod adds the images and the requirements of a pod spec to r.
+func inspectPod(spec map[string]interface{}, r *Resource) {
+	for _, f := range []string{"hostNetwork", "hostPID", "hostIPC"} {
+		if b, _ := spec[f].(bool); b {
+			r.Requires = append(r.Requires, f)
+		}
+	}
+	vols, _ := spec["volumes"].([]interface{})
+	for _, v := range vols {
+		if p, ok := lookup(v, "hostPath", "path").(string); ok {
+			r.Requires = append(r.Requires, "hostPath volume "+p)
+		}
+	}
+	seen := map[string]bool{}
+	for _, c := range Containers(spec) {
+		name, _ := c["name"].(string)
+		if image, _ := c["image"].(string); image != "" && !seen[image] {
+			seen[image] = true
+			r.Images = append(r.Images, image)
+		}
+		if b, _ := lookup(c, "securityContext", "privileged").(bool); b {
+			r.Requires = append(r.Requires, "privileged container "+name)
+		}
+		ports, _ := c["ports"].([]interface{})
+		for _, p := range ports {
+			if hp := lookup(p, "hostPort"); hp != nil {
+				r.Requires = append(r.Requires, fmt.Sprintf("hostPort %v", hp))
+			}
+		}
+	}
+}
+
+// claim describes the storage that the spec of a PersistentVolumeClaim asks
+// for.
+func claim(spec interface{}) string {
+	parts := []string{}
+	if s := lookup(spec, "resources", "requests", "storage"); s != nil {
+		parts = append(parts, fmt.Sprintf("%v", s))
+	}
+	if c, ok := lookup(spec, "storageClassName").(string); ok {
+		parts = append(parts, "class "+c)
+	}
+	if len(parts) == 0 {
+		return "PersistentVolumeClaim"
+	}
+	return "PersistentVolumeClaim (" + strings.Join(parts, ", ") + ")"
+}
+
+// lookup returns the value at path in v, a tree of mappings, or nil.
+func lookup(v interface{}, path ...string) interface{} {
+	for _, key := range path {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = m[key]
+	}
+	return v
+}
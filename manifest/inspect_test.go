@@ -0,0 +1,172 @@
+package manifest
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const inspectManifests = `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  namespace: shop
+spec:
+  type: LoadBalancer
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      hostNetwork: true
+      initContainers:
+      - name: migrate
+        image: shop/migrate:1.0
+      containers:
+      - name: web
+        image: shop/web:1.0
+        ports:
+        - containerPort: 80
+          hostPort: 8080
+      - name: agent
+        image: shop/agent:2.1
+        securityContext:
+          privileged: true
+      volumes:
+      - name: logs
+        hostPath:
+          path: /var/log
+---
+apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: db
+spec:
+  volumeClaimTemplates:
+  - metadata:
+      name: data
+    spec:
+      storageClassName: fast
+      resources:
+        requests:
+          storage: 10Gi
+  template:
+    spec:
+      containers:
+      - name: db
+        image: postgres:16
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: reader
+`
+
+func TestInspect(t *testing.T) {
+	dir, err := ioutil.TempDir("", "helm-inspect-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "all.yaml")
+	if err := ioutil.WriteFile(file, []byte(inspectManifests), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ms, err := Parse(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := Inspect(ms)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []*Resource{
+		{Kind: "Service", Name: "web", Namespace: "shop", Source: file, Requires: []string{"LoadBalancer Service"}},
+		{Kind: "Deployment", Name: "web", Source: file,
+			Images:   []string{"shop/agent:2.1", "shop/migrate:1.0", "shop/web:1.0"},
+			Requires: []string{"hostNetwork", "hostPath volume /var/log", "hostPort 8080", "privileged container agent"}},
+		{Kind: "StatefulSet", Name: "db", Source: file, Images: []string{"postgres:16"}, Requires: []string{"PersistentVolumeClaim (10Gi, class fast)"}},
+		{Kind: "ClusterRole", Name: "reader", Source: file, Requires: []string{"cluster-scoped ClusterRole"}},
+	}
+	if len(res) != len(expect) {
+		t.Fatalf("Expected %d resources, got %d", len(expect), len(res))
+	}
+	for i := range expect {
+		if !reflect.DeepEqual(res[i], expect[i]) {
+			t.Errorf("Expected %+v, got %+v", expect[i], res[i])
+		}
+	}
+}
+
+func TestClusterScope(t *testing.T) {
+	dir, err := ioutil.TempDir("", "helm-inspect-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "crds.yaml")
+	crds := `apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: tenants.example.com
+spec:
+  scope: Cluster
+  names:
+    kind: Tenant
+---
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: projects.example.com
+spec:
+  scope: Namespaced
+  names:
+    kind: Project
+`
+	if err := ioutil.WriteFile(file, []byte(crds), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ms, err := Parse(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clusterScoped := ClusterScope(ms)
+	for kind, expect := range map[string]bool{
+		"Tenant":                   true,
+		"Project":                  false,
+		"ClusterRoleBinding":       true,
+		"CustomResourceDefinition": true,
+		"ConfigMap":                false,
+	} {
+		if clusterScoped(kind) != expect {
+			t.Errorf("Expected %s to be cluster-scoped: %t", kind, expect)
+		}
+	}
+}
+
+func TestPodSpec(t *testing.T) {
+	obj := map[string]interface{}{
+		"spec": map[string]interface{}{
+			"jobTemplate": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"template": map[string]interface{}{
+						"spec": map[string]interface{}{"containers": []interface{}{map[string]interface{}{"name": "run"}}},
+					},
+				},
+			},
+		},
+	}
+	spec := PodSpec("CronJob", obj)
+	if cs := Containers(spec); len(cs) != 1 || cs[0]["name"] != "run" {
+		t.Errorf("Expected the container run, got %v", cs)
+	}
+	if PodSpec("Service", obj) != nil || PodSpec("Pod", map[string]interface{}{}) != nil {
+		t.Error("Expected no pod spec for a Service, or a Pod without one")
+	}
+}
@@ -0,0 +1,83 @@
+package manifest
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// The limits of Limits, as LimitError names them.
+const (
+	LimitDocuments     = "documents"
+	LimitDocumentBytes = "document size"
+	LimitTotalBytes    = "total size"
+)
+
+// Limits bound the manifests that are sent to Kubernetes at once, so that a
+// generator that goes wrong cannot flood a cluster. A zero limit is no bound.
+type Limits struct {
+	// Documents is the number of manifest documents.
+	Documents int
+	// DocumentBytes is the size of a single document, as JSON.
+	DocumentBytes int64
+	// TotalBytes is the total size of the documents, as JSON.
+	TotalBytes int64
+}
+
+// DefaultLimits are the limits of the manifests of a chart, unless the
+// configuration or the flags set others. They are far above what any real
+// chart needs: Kubernetes itself refuses objects of more than about 1.5MiB.
+var DefaultLimits = Limits{Documents: 1000, DocumentBytes: 2 << 20, TotalBytes: 64 << 20}
+
+// LimitError is a set of manifests that exceeds one of its Limits.
+type LimitError struct {
+	// Limit is the limit that was exceeded, one of the Limit constants.
+	Limit string
+	// Value is what was observed, and Max the limit.
+	Value, Max int64
+	// Manifest names the document that is too big, for LimitDocumentBytes.
+	Manifest string
+}
+
+func (e *LimitError) Error() string {
+	value, max := fmt.Sprint(e.Value), fmt.Sprint(e.Max)
+	if e.Limit != LimitDocuments {
+		value, max = fmt.Sprintf("%d bytes", e.Value), fmt.Sprintf("%d bytes", e.Max)
+	}
+	if e.Manifest != "" {
+		return fmt.Sprintf("%s has a %s of %s, over the limit of %s", e.Manifest, e.Limit, value, max)
+	}
+	return fmt.Sprintf("the manifests have %s %s, over the limit of %s", value, e.Limit, max)
+}
+
+// CheckLimits checks manifests against l. It returns a *LimitError for the
+// first limit that they exceed: that of the number of documents, then that
+// of each document, and then that of the total size.
+func CheckLimits(ms []*Manifest, l Limits) error {
+	if l.Documents > 0 && len(ms) > l.Documents {
+		return &LimitError{Limit: LimitDocuments, Value: int64(len(ms)), Max: int64(l.Documents)}
+	}
+	var total int64
+	for _, m := range ms {
+		data, err := m.VersionedObject.JSON()
+		if err != nil {
+			return fmt.Errorf("%s %s: %s", m.Kind, m.Name, err)
+		}
+		n := int64(len(data))
+		if l.DocumentBytes > 0 && n > l.DocumentBytes {
+			return &LimitError{Limit: LimitDocumentBytes, Value: n, Max: l.DocumentBytes, Manifest: describe(m)}
+		}
+		total += n
+	}
+	if l.TotalBytes > 0 && total > l.TotalBytes {
+		return &LimitError{Limit: LimitTotalBytes, Value: total, Max: l.TotalBytes}
+	}
+	return nil
+}
+
+// describe names a manifest in errors, by its kind, name, and file.
+func describe(m *Manifest) string {
+	if m.Source == "" {
+		return fmt.Sprintf("%s %s", m.Kind, m.Name)
+	}
+	return fmt.Sprintf("%s %s (%s)", m.Kind, m.Name, filepath.Base(m.Source))
+}
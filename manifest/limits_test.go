@@ -0,0 +1,46 @@
+package manifest
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckLimits(t *testing.T) {
+	ms, err := Parse("../testdata/three-pods-and-three-services.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckLimits(ms, DefaultLimits); err != nil {
+		t.Errorf("Expected the default limits to allow six manifests, got %s", err)
+	}
+	if err := CheckLimits(ms, Limits{}); err != nil {
+		t.Errorf("Expected no limits to allow anything, got %s", err)
+	}
+
+	var total int64
+	for _, m := range ms {
+		data, _ := m.VersionedObject.JSON()
+		total += int64(len(data))
+	}
+	tests := []struct {
+		limits Limits
+		limit  string
+		value  int64
+		msg    string
+	}{
+		{Limits{Documents: 5}, LimitDocuments, 6, "the manifests have 6 documents, over the limit of 5"},
+		{Limits{DocumentBytes: 10}, LimitDocumentBytes, 0, "(three-pods-and-three-services.yaml) has a document size of"},
+		{Limits{TotalBytes: total - 1}, LimitTotalBytes, total, "over the limit of"},
+	}
+	for _, tt := range tests {
+		err := CheckLimits(ms, tt.limits)
+		le, ok := err.(*LimitError)
+		if !ok {
+			t.Errorf("%+v: expected a *LimitError, got %v", tt.limits, err)
+			continue
+		}
+		if le.Limit != tt.limit || (tt.value != 0 && le.Value != tt.value) || !strings.Contains(le.Error(), tt.msg) {
+			t.Errorf("%+v: expected %s of %d with %q, got %+v: %s", tt.limits, tt.limit, tt.value, tt.msg, le, le)
+		}
+	}
+}
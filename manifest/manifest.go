@@ -2,11 +2,12 @@
 package manifest
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
-	"regexp"
+	"strings"
 
 	"github.com/helm/helm-classic/codec"
 	"github.com/helm/helm-classic/log"
@@ -89,7 +90,25 @@ func Parse(filename string) ([]*Manifest, error) {
 // This will return an error if the directory does not exist, or if there is an
 // error parsing or decoding any yaml files.
 func ParseDir(chartDir string) ([]*Manifest, error) {
-	dir := filepath.Join(chartDir, "manifests")
+	return parseDir(filepath.Join(chartDir, "manifests"))
+}
+
+// TestsDir is the directory of a chart that has the manifests of its tests.
+// See HookTest.
+const TestsDir = "tests"
+
+// ParseTests parses the manifests of the tests directory of a chart
+// directory, as ParseDir does. A chart without one has no tests.
+func ParseTests(chartDir string) ([]*Manifest, error) {
+	ms, err := parseDir(filepath.Join(chartDir, TestsDir))
+	if os.IsNotExist(err) {
+		return ms, nil
+	}
+	return ms, err
+}
+
+// parseDir parses the YAML files of dir, and of its subdirectories.
+func parseDir(dir string) ([]*Manifest, error) {
 	files := []*Manifest{}
 
 	if _, err := os.Stat(dir); err != nil {
@@ -126,9 +145,77 @@ func ParseDir(chartDir string) ([]*Manifest, error) {
 	return files, filepath.Walk(dir, walker)
 }
 
-// IsKeeper returns true if a manifest has a "helm-keep": "true" annotation.
+// Annotations that mark a manifest as a keeper.
+const (
+	// KeepAnnotation is the original keeper annotation, set to "true".
+	KeepAnnotation = "helm-keep"
+	// ResourcePolicyAnnotation is the keeper annotation shared with Helm, set
+	// to ResourcePolicyKeep.
+	ResourcePolicyAnnotation = "helm.sh/resource-policy"
+	// ResourcePolicyKeep is the resource policy of a keeper.
+	ResourcePolicyKeep = "keep"
+)
+
+// IsKeeper returns true if a manifest has a "helm-keep": "true" or a
+// "helm.sh/resource-policy": "keep" annotation.
 func IsKeeper(data []byte) bool {
-	// Look for ("helm-keep": "true") up to 10 lines after ("annotations:").
-	var keep = regexp.MustCompile(`\"annotations\":\s+\{(\n.*){0,10}\"helm-keep\":\s+\"true\"`)
-	return keep.Match(data)
+	return KeptBy(data) != ""
+}
+
+// KeptBy returns the annotation that marks a JSON manifest as a keeper, or ""
+// if it is not one.
+func KeptBy(data []byte) string {
+	switch a := annotations(data); {
+	case a[KeepAnnotation] == "true":
+		return KeepAnnotation
+	case a[ResourcePolicyAnnotation] == ResourcePolicyKeep:
+		return ResourcePolicyAnnotation
+	}
+	return ""
+}
+
+// The hook annotation, and its values.
+const (
+	// HookAnnotation marks a manifest as a hook, which is applied before or
+	// after the other manifests of an install, or before an uninstall,
+	// rather than with them.
+	HookAnnotation = "helm.sh/hook"
+	// HookPreInstall is a hook that is applied before the other manifests.
+	HookPreInstall = "pre-install"
+	// HookPostInstall is a hook that is applied after the other manifests.
+	HookPostInstall = "post-install"
+	// HookPreDelete is a hook that is not installed, but applied when the
+	// chart is uninstalled, before anything is deleted.
+	HookPreDelete = "pre-delete"
+	// HookTest is a test of a chart, a Pod or a Job in its TestsDir, which
+	// 'helmc test' runs against the release of the chart.
+	HookTest = "test"
+)
+
+// Hook returns the hook of a JSON manifest, as its "helm.sh/hook" annotation
+// gives it, or "" if it is not a hook. The value is not checked: see
+// CheckHook.
+func Hook(data []byte) string {
+	return strings.TrimSpace(annotations(data)[HookAnnotation])
+}
+
+// CheckHook returns an error if hook is not one that an install or an
+// uninstall runs.
+func CheckHook(hook string) error {
+	switch hook {
+	case "", HookPreInstall, HookPostInstall, HookPreDelete:
+		return nil
+	}
+	return fmt.Errorf("unknown %s %q. Use %s, %s, or %s", HookAnnotation, hook, HookPreInstall, HookPostInstall, HookPreDelete)
+}
+
+// annotations returns the annotations of a JSON manifest.
+func annotations(data []byte) map[string]string {
+	var obj struct {
+		Metadata struct {
+			Annotations map[string]string `json:"annotations"`
+		} `json:"metadata"`
+	}
+	json.Unmarshal(data, &obj)
+	return obj.Metadata.Annotations
 }
@@ -90,4 +90,30 @@ func TestIsKeeper(t *testing.T) {
 	if !IsKeeper(data) {
 		t.Errorf("Expected true for %s", testKeeperManifest)
 	}
+
+	// test that the resource policy annotation is detected
+	data = []byte(`{"kind": "Namespace", "metadata": {"name": "shared", "annotations": {"helm.sh/resource-policy": "keep"}}}`)
+	if by := KeptBy(data); by != ResourcePolicyAnnotation {
+		t.Errorf("Expected a keeper by %s, got %q", ResourcePolicyAnnotation, by)
+	}
+	data = []byte(`{"kind": "Namespace", "metadata": {"name": "shared", "annotations": {"helm.sh/resource-policy": "delete"}}}`)
+	if IsKeeper(data) {
+		t.Error("Expected false for another resource policy")
+	}
+}
+
+func TestHook(t *testing.T) {
+	data := []byte(`{"kind": "Job", "metadata": {"name": "migrate", "annotations": {"helm.sh/hook": "pre-install"}}}`)
+	if h := Hook(data); h != HookPreInstall || CheckHook(h) != nil {
+		t.Errorf("Expected a pre-install hook, got %q", h)
+	}
+	if h := Hook([]byte(`{"kind": "Job", "metadata": {"name": "migrate"}}`)); h != "" {
+		t.Errorf("Expected no hook, got %q", h)
+	}
+	if err := CheckHook(HookPreDelete); err != nil {
+		t.Errorf("Expected pre-delete to be a hook, got %v", err)
+	}
+	if err := CheckHook("pre-upgrade"); err == nil || !strings.Contains(err.Error(), `"pre-upgrade"`) {
+		t.Errorf("Expected an unknown hook, got %v", err)
+	}
 }
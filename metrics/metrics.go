@@ -0,0 +1,136 @@
+// Package metrics records where the time of a helmc command goes.
+//
+// Each phase of a command, such as the update of a repository, the run of a
+// generator, or a Kubernetes request, is observed with how long it took and
+// whether it failed. The observations are totalled by phase and name, for
+// the --timings report, and for tools that run the operations of helmc
+// themselves and want to know where the time is spent. WriteText writes
+// them in the text format of Prometheus.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The phases that helmc observes, other than its operations. An operation,
+// such as a fetch or an install, is observed under its own name, with the
+// chart as its name.
+const (
+	// Update is the update of a repository, named after the repository.
+	Update = "update"
+	// Generator is the run of a generator, named after the file, relative
+	// to the chart, that declares it.
+	Generator = "generator"
+	// Kubectl is a Kubernetes request, retries included, whether kubectl
+	// runs it or it is sent to the API server. It is named after the
+	// command, such as "kubectl create", or the method and resource, such
+	// as "POST pods".
+	Kubectl = "kubectl"
+)
+
+// Timing is the total of the observations of a phase with a name.
+type Timing struct {
+	Phase string `json:"phase"`
+	Name  string `json:"name"`
+	// Count is the number of observations, and Failures the number of them
+	// that failed.
+	Count    int `json:"count"`
+	Failures int `json:"failures"`
+	// Total is the time of every observation, and Max that of the longest.
+	Total time.Duration `json:"total"`
+	Max   time.Duration `json:"max"`
+}
+
+var (
+	mu sync.Mutex
+	// timings are in the order that they were first observed, and byKey
+	// finds them by phase and name.
+	timings []*Timing
+	byKey   = map[string]*Timing{}
+	start   = time.Now()
+)
+
+// Reset forgets every observation, and starts the clock of Elapsed again.
+func Reset() {
+	mu.Lock()
+	defer mu.Unlock()
+	timings, byKey, start = nil, map[string]*Timing{}, time.Now()
+}
+
+// Observe records that a phase with a name took d, and failed if err is not
+// nil. It is safe to call concurrently.
+func Observe(phase, name string, d time.Duration, err error) {
+	mu.Lock()
+	defer mu.Unlock()
+	k := phase + "\x00" + name
+	t, ok := byKey[k]
+	if !ok {
+		t = &Timing{Phase: phase, Name: name}
+		byKey[k] = t
+		timings = append(timings, t)
+	}
+	t.Count++
+	if err != nil {
+		t.Failures++
+	}
+	t.Total += d
+	if d > t.Max {
+		t.Max = d
+	}
+}
+
+// Timings returns a copy of the totals, in the order that each was first
+// observed.
+func Timings() []Timing {
+	mu.Lock()
+	defer mu.Unlock()
+	res := make([]Timing, len(timings))
+	for i, t := range timings {
+		res[i] = *t
+	}
+	return res
+}
+
+// Elapsed returns the time since the metrics were last Reset, or since
+// helmc started.
+func Elapsed() time.Duration {
+	mu.Lock()
+	defer mu.Unlock()
+	return time.Since(start)
+}
+
+// WriteText writes the totals, and the elapsed time, to w in the text
+// format of Prometheus, as the textfile collector of the node exporter reads
+// it.
+func WriteText(w io.Writer) error {
+	ts := Timings()
+	var b strings.Builder
+	metric := func(name, typ, help string, value func(t Timing) float64) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+		for _, t := range ts {
+			fmt.Fprintf(&b, "%s{%s} %g\n", name, labels(t), value(t))
+		}
+	}
+	fmt.Fprintf(&b, "# HELP helmc_phase_seconds The time that each phase of the command took.\n# TYPE helmc_phase_seconds summary\n")
+	for _, t := range ts {
+		fmt.Fprintf(&b, "helmc_phase_seconds_sum{%s} %g\n", labels(t), t.Total.Seconds())
+		fmt.Fprintf(&b, "helmc_phase_seconds_count{%s} %d\n", labels(t), t.Count)
+	}
+	metric("helmc_phase_max_seconds", "gauge", "The time of the longest run of each phase.", func(t Timing) float64 { return t.Max.Seconds() })
+	metric("helmc_phase_failures_total", "counter", "The runs of each phase that failed.", func(t Timing) float64 { return float64(t.Failures) })
+	fmt.Fprintf(&b, "# HELP helmc_command_seconds The time that the command ran.\n# TYPE helmc_command_seconds gauge\nhelmc_command_seconds %g\n", Elapsed().Seconds())
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// escape escapes a label value as the text format of Prometheus does.
+var escape = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// labels returns the labels of a timing.
+func labels(t Timing) string {
+	return `phase="` + escape.Replace(t.Phase) + `",name="` + escape.Replace(t.Name) + `"`
+}
@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestObserve(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	Observe(Kubectl, "kubectl create", 100*time.Millisecond, nil)
+	Observe(Update, "charts", time.Second, errors.New("offline"))
+	Observe(Kubectl, "kubectl create", 300*time.Millisecond, errors.New("conflict"))
+
+	ts := Timings()
+	if len(ts) != 2 {
+		t.Fatalf("Expected 2 timings, got %v", ts)
+	}
+	expect := Timing{Phase: Kubectl, Name: "kubectl create", Count: 2, Failures: 1, Total: 400 * time.Millisecond, Max: 300 * time.Millisecond}
+	if ts[0] != expect {
+		t.Errorf("Expected %v, got %v", expect, ts[0])
+	}
+	if ts[1].Phase != Update || ts[1].Failures != 1 {
+		t.Errorf("Expected the failed update second, got %v", ts[1])
+	}
+
+	Reset()
+	if ts := Timings(); len(ts) != 0 {
+		t.Errorf("Expected no timings after Reset, got %v", ts)
+	}
+}
+
+func TestWriteText(t *testing.T) {
+	Reset()
+	defer Reset()
+
+	Observe(Generator, `tpl/"a".yaml`, 1500*time.Millisecond, nil)
+	var b bytes.Buffer
+	if err := WriteText(&b); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		"# TYPE helmc_phase_seconds summary",
+		`helmc_phase_seconds_sum{phase="generator",name="tpl/\"a\".yaml"} 1.5`,
+		`helmc_phase_seconds_count{phase="generator",name="tpl/\"a\".yaml"} 1`,
+		`helmc_phase_max_seconds{phase="generator",name="tpl/\"a\".yaml"} 1.5`,
+		`helmc_phase_failures_total{phase="generator",name="tpl/\"a\".yaml"} 0`,
+		"# TYPE helmc_command_seconds gauge",
+	} {
+		if !strings.Contains(b.String(), line+"\n") {
+			t.Errorf("Expected %q in\n%s", line, b.String())
+		}
+	}
+}
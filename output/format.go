@@ -0,0 +1,51 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// The structured formats of Print.
+const (
+	JSON = "json"
+	YAML = "yaml"
+)
+
+// Format is the structured format that commands which support one print in,
+// instead of their tables, unless they are given their own. helmc sets it
+// from its global --output flag. It is empty for the human-readable output.
+var Format string
+
+// CheckFormat returns an error unless format is empty, JSON, or YAML.
+func CheckFormat(format string) error {
+	switch format {
+	case "", JSON, YAML:
+		return nil
+	}
+	return fmt.Errorf("unknown output format %q (use 'json' or 'yaml')", format)
+}
+
+// Print writes v to w as JSON or YAML, ending with a newline.
+//
+// JSON is indented, and uses the json tags of v; YAML uses its yaml tags.
+func Print(w io.Writer, v interface{}, format string) error {
+	var b []byte
+	var err error
+	switch format {
+	case JSON:
+		b, err = json.MarshalIndent(v, "", "  ")
+	case YAML:
+		b, err = yaml.Marshal(v)
+	default:
+		return fmt.Errorf("unknown output format %q (use 'json' or 'yaml')", format)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, strings.TrimSpace(string(b)))
+	return err
+}
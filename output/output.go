@@ -0,0 +1,172 @@
+// Package output fits what helmc prints to the terminal it prints on.
+//
+// On a terminal, tables and long lines are cut to its width, and states and
+// diffs are colored. Anywhere else, such as a pipe or a CI log, the output
+// is plain and whole, so that it stays the same from one run to the next.
+package output
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// NoTruncate prints whole lines, even on a terminal that is too narrow for
+// them. helmc sets it from its --no-truncate flag.
+var NoTruncate bool
+
+// Ellipsis ends the text that is cut to fit.
+const Ellipsis = "…"
+
+// Color is an ANSI color.
+type Color string
+
+// The colors of Paint.
+const (
+	None   Color = ""
+	Red    Color = "\x1b[31m"
+	Green  Color = "\x1b[32m"
+	Yellow Color = "\x1b[33m"
+	Cyan   Color = "\x1b[36m"
+	Bold   Color = "\x1b[1m"
+
+	reset = "\x1b[0m"
+)
+
+// Printer prints text that fits a terminal.
+type Printer struct {
+	// W receives the text.
+	W io.Writer
+	// Width is the number of columns of the terminal. If it is 0, nothing is
+	// cut.
+	Width int
+	// Color turns on the colors of Paint.
+	Color bool
+}
+
+// New returns a Printer for w. If w is a terminal, the Printer fits its
+// width, unless NoTruncate is set, and colors its text, unless $NO_COLOR is
+// set or $TERM is "dumb". Otherwise, its text is plain and whole.
+func New(w io.Writer) *Printer {
+	p := &Printer{W: w}
+	f, ok := w.(*os.File)
+	if !ok || !terminal.IsTerminal(int(f.Fd())) {
+		return p
+	}
+	if width, _, err := terminal.GetSize(int(f.Fd())); err == nil && !NoTruncate {
+		p.Width = width
+	}
+	_, noColor := os.LookupEnv("NO_COLOR")
+	p.Color = !noColor && os.Getenv("TERM") != "dumb"
+	return p
+}
+
+// Paint returns s in color c, if p has colors.
+func (p *Printer) Paint(c Color, s string) string {
+	if !p.Color || c == None || s == "" {
+		return s
+	}
+	return string(c) + s + reset
+}
+
+// Fit returns s, cut to the columns that are left of the width of p once
+// used columns of the line are taken, such as those of a prefix.
+func (p *Printer) Fit(s string, used int) string {
+	if p.Width == 0 {
+		return s
+	}
+	return Truncate(s, p.Width-used)
+}
+
+// Diff prints a unified diff, with its added lines in green, its removed
+// lines in red, and its file and hunk headers set apart.
+func (p *Printer) Diff(diff string) {
+	if !p.Color {
+		fmt.Fprint(p.W, diff)
+		return
+	}
+	lines := strings.SplitAfter(diff, "\n")
+	for _, l := range lines {
+		text := strings.TrimRight(l, "\n")
+		c := None
+		switch {
+		case strings.HasPrefix(text, "+++ "), strings.HasPrefix(text, "--- "):
+			c = Bold
+		case strings.HasPrefix(text, "@@"):
+			c = Cyan
+		case strings.HasPrefix(text, "+"):
+			c = Green
+		case strings.HasPrefix(text, "-"):
+			c = Red
+		}
+		fmt.Fprint(p.W, p.Paint(c, text)+l[len(text):])
+	}
+}
+
+// Width returns the number of columns that s takes on a terminal. East Asian
+// wide characters take two, and combining marks and control characters none.
+func Width(s string) int {
+	n := 0
+	for _, r := range s {
+		n += runeWidth(r)
+	}
+	return n
+}
+
+// Truncate cuts s to at most width columns. Text that is cut ends with
+// Ellipsis. Characters are never split.
+func Truncate(s string, width int) string {
+	if Width(s) <= width {
+		return s
+	}
+	if width <= 0 {
+		return ""
+	}
+	n, end := 0, 0
+	for i, r := range s {
+		w := runeWidth(r)
+		if n+w > width-1 {
+			end = i
+			break
+		}
+		n += w
+		end = i + utf8.RuneLen(r)
+	}
+	return s[:end] + Ellipsis
+}
+
+// wide are the ranges of the East Asian wide and fullwidth characters, and of
+// the emoji, which take two columns.
+var wide = []struct{ lo, hi rune }{
+	{0x1100, 0x115F},
+	{0x2E80, 0x303E},
+	{0x3041, 0x33FF},
+	{0x3400, 0x4DBF},
+	{0x4E00, 0x9FFF},
+	{0xA000, 0xA4CF},
+	{0xAC00, 0xD7A3},
+	{0xF900, 0xFAFF},
+	{0xFE30, 0xFE4F},
+	{0xFF00, 0xFF60},
+	{0xFFE0, 0xFFE6},
+	{0x1F300, 0x1F64F},
+	{0x1F900, 0x1F9FF},
+	{0x20000, 0x3FFFD},
+}
+
+func runeWidth(r rune) int {
+	if r < 0x20 || r == 0x7F || unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf) {
+		return 0
+	}
+	for _, w := range wide {
+		if r >= w.lo && r <= w.hi {
+			return 2
+		}
+	}
+	return 1
+}
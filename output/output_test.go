@@ -0,0 +1,177 @@
+package output
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWidth(t *testing.T) {
+	for s, expected := range map[string]int{
+		"":         0,
+		"redis":    5,
+		"café":     4,
+		"café":    4,
+		"日本語":      6,
+		"a\tb":     2,
+		"Ｒｅｄｉｓ 캐시": 15,
+	} {
+		if w := Width(s); w != expected {
+			t.Errorf("Expected %q to be %d columns, got %d", s, expected, w)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	for _, tt := range []struct {
+		s        string
+		width    int
+		expected string
+	}{
+		{"redis", 6, "redis"},
+		{"redis", 5, "redis"},
+		{"redis", 4, "red…"},
+		{"redis", 1, "…"},
+		{"redis", 0, ""},
+		{"redis", -3, ""},
+		{"", 0, ""},
+		{"café au lait", 5, "café…"},
+		{"café au lait", 5, "café…"},
+		// A wide character that does not fit whole is left out.
+		{"日本語のチャート", 6, "日本…"},
+		{"日本語のチャート", 5, "日本…"},
+		{"日本語", 6, "日本語"},
+	} {
+		actual := Truncate(tt.s, tt.width)
+		if actual != tt.expected {
+			t.Errorf("Expected %q cut to %d to be %q, got %q", tt.s, tt.width, tt.expected, actual)
+		}
+		if tt.width > 0 && Width(actual) > tt.width {
+			t.Errorf("Expected %q to fit in %d columns", actual, tt.width)
+		}
+	}
+}
+
+func TestFit(t *testing.T) {
+	p := &Printer{Width: 20}
+	if s := p.Fit("A Redis cache for your cluster", 8); s != "A Redis cac…" {
+		t.Errorf("Expected the description to fit the rest of the line, got %q", s)
+	}
+	p.Width = 0
+	if s := p.Fit("A Redis cache for your cluster", 8); s != "A Redis cache for your cluster" {
+		t.Errorf("Expected a whole line without a terminal, got %q", s)
+	}
+}
+
+func TestTable(t *testing.T) {
+	table := &Table{
+		Header: []string{"KIND", "NAME", "STATE"},
+		Flex:   1,
+		Colors: func(row []string, col int) Color {
+			if col == 2 && row[col] == "current" {
+				return Green
+			}
+			return None
+		},
+	}
+	table.Add("Service", "redis-master-with-a-long-name", "current")
+	table.Add("Pod", "キャッシュ", "missing")
+
+	var b bytes.Buffer
+	(&Printer{W: &b}).Table(table)
+	expected := `KIND     NAME                           STATE
+Service  redis-master-with-a-long-name  current
+Pod      キャッシュ                     missing
+`
+	if b.String() != expected {
+		t.Errorf("Expected a whole table without a terminal, got\n%s", b.String())
+	}
+
+	b.Reset()
+	(&Printer{W: &b, Width: 30, Color: true}).Table(table)
+	expected = "KIND     NAME          STATE\n" +
+		"Service  redis-maste…  \x1b[32mcurrent\x1b[0m\n" +
+		"Pod      キャッシュ    missing\n"
+	if b.String() != expected {
+		t.Errorf("Expected the names to be cut, got\n%q", b.String())
+	}
+	for _, l := range strings.Split(strings.TrimSpace(b.String()), "\n") {
+		if w := Width(strings.Replace(strings.Replace(l, string(Green), "", 1), reset, "", 1)); w > 30 {
+			t.Errorf("Expected %q to fit in 30 columns, got %d", l, w)
+		}
+	}
+
+	// Columns are not cut narrower than minColumn, or their header, even if
+	// the table still does not fit.
+	b.Reset()
+	(&Printer{W: &b, Width: 20}).Table(table)
+	expected = "KIND     NAME      STATE\n" +
+		"Service  redis-m…  current\n" +
+		"Pod      キャッ…   missing\n"
+	if b.String() != expected {
+		t.Errorf("Expected the names to be cut to their narrowest, got\n%q", b.String())
+	}
+
+	// When the flexible column is at its narrowest, the widest of the others
+	// are cut too.
+	table = &Table{Header: []string{"A", "B"}}
+	table.Add("aaaaaaaaaa aaaaaaaaaa", "bbbbbbbbbbbbbbbb")
+	b.Reset()
+	(&Printer{W: &b, Width: 20}).Table(table)
+	expected = "A         B\n" +
+		"aaaaaaa…  bbbbbbbbb…\n"
+	if b.String() != expected {
+		t.Errorf("Expected both columns to be cut, got\n%q", b.String())
+	}
+}
+
+func TestDiff(t *testing.T) {
+	diff := "--- a/values.toml\n+++ b/values.toml\n@@ -1 +1 @@\n-replicas = 1\n+replicas = 3\n context\n"
+	var b bytes.Buffer
+	(&Printer{W: &b}).Diff(diff)
+	if b.String() != diff {
+		t.Errorf("Expected a plain diff without colors, got %q", b.String())
+	}
+
+	b.Reset()
+	(&Printer{W: &b, Color: true}).Diff(diff)
+	for _, l := range []string{
+		"\x1b[1m--- a/values.toml\x1b[0m\n",
+		"\x1b[36m@@ -1 +1 @@\x1b[0m\n",
+		"\x1b[31m-replicas = 1\x1b[0m\n",
+		"\x1b[32m+replicas = 3\x1b[0m\n",
+		"\n context\n",
+	} {
+		if !strings.Contains(b.String(), l) {
+			t.Errorf("Expected %q in the colored diff, got %q", l, b.String())
+		}
+	}
+}
+
+func TestNewPlain(t *testing.T) {
+	var b bytes.Buffer
+	if p := New(&b); p.Width != 0 || p.Color {
+		t.Errorf("Expected plain, whole output on a buffer, got %+v", p)
+	}
+}
+
+func TestPrint(t *testing.T) {
+	v := struct {
+		Name string `json:"name" yaml:"name"`
+	}{"redis"}
+	for format, expected := range map[string]string{
+		JSON: "{\n  \"name\": \"redis\"\n}\n",
+		YAML: "name: redis\n",
+	} {
+		var b bytes.Buffer
+		if err := Print(&b, v, format); err != nil {
+			t.Fatal(err)
+		}
+		if b.String() != expected {
+			t.Errorf("Expected %q as %s, got %q", expected, format, b.String())
+		}
+	}
+	if err := Print(&bytes.Buffer{}, v, "table"); err == nil {
+		t.Errorf("Expected an unknown format to fail")
+	}
+}
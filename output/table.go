@@ -0,0 +1,107 @@
+package output
+
+import (
+	"fmt"
+	"strings"
+)
+
+// minColumn is the narrowest that a column is cut to. Headers are never cut.
+const minColumn = 8
+
+// Table is a table of text, printed in columns that are separated by two
+// spaces, as a text/tabwriter with a padding of 2 prints them.
+type Table struct {
+	// Header is the first row.
+	Header []string
+	// Rows are the rows under the header. Each has a cell for every column.
+	Rows [][]string
+	// Flex is the column that is cut first when the table is too wide for
+	// the terminal, such as that of names or descriptions. The widest of the
+	// others are cut next.
+	Flex int
+	// Colors returns the color of a cell of a row, or None. It may be nil.
+	Colors func(row []string, col int) Color
+}
+
+// Add adds a row.
+func (t *Table) Add(cells ...string) {
+	t.Rows = append(t.Rows, cells)
+}
+
+// Table prints t, fitted to the width of p.
+func (p *Printer) Table(t *Table) {
+	widths := t.widths(p.Width)
+	line := func(row []string, colors bool) {
+		var b strings.Builder
+		for i, cell := range row {
+			cell = Truncate(cell, widths[i])
+			pad := ""
+			if i < len(row)-1 {
+				pad = strings.Repeat(" ", widths[i]-Width(cell)+2)
+			}
+			if colors && t.Colors != nil {
+				cell = p.Paint(t.Colors(row, i), cell)
+			}
+			b.WriteString(cell + pad)
+		}
+		fmt.Fprintln(p.W, b.String())
+	}
+	line(t.Header, false)
+	for _, row := range t.Rows {
+		line(row, true)
+	}
+}
+
+// widths returns the width of each column of t. If max is not 0, and the
+// table is wider, the columns are narrowed to fit it: first Flex, and then
+// the widest of the others, as far as minColumn or their header.
+func (t *Table) widths(max int) []int {
+	widths := make([]int, len(t.Header))
+	for _, row := range append([][]string{t.Header}, t.Rows...) {
+		for i, cell := range row {
+			if w := Width(cell); w > widths[i] {
+				widths[i] = w
+			}
+		}
+	}
+	if max == 0 {
+		return widths
+	}
+	total := 2 * (len(widths) - 1)
+	for _, w := range widths {
+		total += w
+	}
+	floor := func(i int) int {
+		if h := Width(t.Header[i]); h > minColumn {
+			return h
+		}
+		return minColumn
+	}
+	narrow := func(i, by int) int {
+		if cut := widths[i] - floor(i); cut < by {
+			by = cut
+		}
+		if by > 0 {
+			widths[i] -= by
+			return by
+		}
+		return 0
+	}
+	over := total - max
+	if over > 0 && t.Flex >= 0 && t.Flex < len(widths) {
+		over -= narrow(t.Flex, over)
+	}
+	for over > 0 {
+		widest := -1
+		for i, w := range widths {
+			if w > floor(i) && (widest < 0 || w > widths[widest]) {
+				widest = i
+			}
+		}
+		if widest < 0 {
+			break
+		}
+		over -= narrow(widest, 1)
+	}
+	return widths
+}
@@ -16,6 +16,7 @@ var RepoService GHRepoService
 // GHRepoService is a restricted interface to GitHub client operations.
 type GHRepoService interface {
 	GetLatestRelease(string, string) (*github.RepositoryRelease, *github.Response, error)
+	GetReleaseByTag(string, string, string) (*github.RepositoryRelease, *github.Response, error)
 }
 
 // Latest returns information on the latest Helm Classic version.
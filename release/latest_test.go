@@ -59,3 +59,7 @@ func init() {
 func (m *MockGHRepoService) GetLatestRelease(o, p string) (*github.RepositoryRelease, *github.Response, error) {
 	return m.Release, nil, nil
 }
+
+func (m *MockGHRepoService) GetReleaseByTag(o, p, tag string) (*github.RepositoryRelease, *github.Response, error) {
+	return m.Release, nil, nil
+}
@@ -0,0 +1,151 @@
+package release
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/go-github/github"
+	helm "github.com/helm/helm-classic/util"
+)
+
+// ChecksumSuffix is the suffix of the asset that holds an archive's SHA-256
+// checksum, e.g. helmc-0.9.0-linux-amd64.zip.sha256.
+const ChecksumSuffix = ".sha256"
+
+// httpClient downloads release assets. Tests replace it.
+var httpClient = http.DefaultClient
+
+// Get returns the release with the given tag, or the latest release if the
+// tag is empty.
+func Get(tag string) (*github.RepositoryRelease, error) {
+	if tag == "" {
+		return Latest()
+	}
+	if RepoService == nil {
+		RepoService = github.NewClient(nil).Repositories
+	}
+	rel, _, err := RepoService.GetReleaseByTag(Owner, Project, tag)
+	return rel, err
+}
+
+// Asset finds the archive of a release for an operating system and
+// architecture, and the asset that holds its checksum.
+//
+// Archives are named like helmc-0.9.0-linux-amd64.zip. The checksum is nil
+// if the release does not publish one.
+func Asset(rel *github.RepositoryRelease, goos, goarch string) (archive, checksum *github.ReleaseAsset, err error) {
+	suffix := fmt.Sprintf("-%s-%s.zip", goos, goarch)
+	for i := range rel.Assets {
+		if a := &rel.Assets[i]; a.Name != nil && strings.HasSuffix(*a.Name, suffix) {
+			archive = a
+			break
+		}
+	}
+	if archive == nil {
+		return nil, nil, fmt.Errorf("release %s has no archive for %s/%s", tagName(rel), goos, goarch)
+	}
+	for i := range rel.Assets {
+		if a := &rel.Assets[i]; a.Name != nil && *a.Name == *archive.Name+ChecksumSuffix {
+			checksum = a
+		}
+	}
+	return archive, checksum, nil
+}
+
+func tagName(rel *github.RepositoryRelease) string {
+	if rel.TagName == nil {
+		return "(untagged)"
+	}
+	return *rel.TagName
+}
+
+// Download fetches a release asset.
+func Download(a *github.ReleaseAsset) ([]byte, error) {
+	if a.BrowserDownloadURL == nil {
+		return nil, fmt.Errorf("asset %s has no download URL", *a.Name)
+	}
+	res, err := httpClient.Get(*a.BrowserDownloadURL)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("could not download %s: %s", *a.BrowserDownloadURL, res.Status)
+	}
+	return ioutil.ReadAll(res.Body)
+}
+
+// VerifyChecksum checks data against a published SHA-256 checksum.
+//
+// The checksum is in the format written by sha256sum: the hex digest,
+// optionally followed by the file name.
+func VerifyChecksum(data, published []byte) error {
+	fields := strings.Fields(string(published))
+	if len(fields) == 0 {
+		return fmt.Errorf("the published checksum is empty")
+	}
+	sum := sha256.Sum256(data)
+	if actual := hex.EncodeToString(sum[:]); !strings.EqualFold(actual, fields[0]) {
+		return fmt.Errorf("checksum mismatch: expected %s, got %s", fields[0], actual)
+	}
+	return nil
+}
+
+// Extract returns the helmc binary from a release archive.
+func Extract(archive []byte) ([]byte, error) {
+	r, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range r.File {
+		if name := filepath.Base(f.Name); name != "helmc" && name != "helmc.exe" {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		return ioutil.ReadAll(rc)
+	}
+	return nil, fmt.Errorf("the archive does not contain helmc")
+}
+
+// Replace atomically replaces the file at path with data.
+//
+// The data is written to a temporary file in the same directory, which is
+// then renamed over path, so that path is never partly written. The mode of
+// the existing file is kept.
+func Replace(path string, data []byte) error {
+	mode := os.FileMode(0755)
+	if fi, err := os.Stat(path); err == nil {
+		mode = fi.Mode()
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".helmc-update-")
+	if err != nil {
+		return err
+	}
+	defer helm.OnInterrupt(func() { os.Remove(tmp.Name()) })()
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
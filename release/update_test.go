@@ -0,0 +1,105 @@
+package release
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-github/github"
+)
+
+func asset(name string) github.ReleaseAsset {
+	u := "http://example.com/" + name
+	return github.ReleaseAsset{Name: &name, BrowserDownloadURL: &u}
+}
+
+func TestAsset(t *testing.T) {
+	tag := "0.9.0"
+	rel := &github.RepositoryRelease{TagName: &tag, Assets: []github.ReleaseAsset{
+		asset("helmc-0.9.0-darwin-amd64.zip"),
+		asset("helmc-0.9.0-linux-amd64.zip"),
+		asset("helmc-0.9.0-linux-amd64.zip.sha256"),
+		asset("helmc-0.9.0-linux-386.zip"),
+	}}
+
+	a, sum, err := Asset(rel, "linux", "amd64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *a.Name != "helmc-0.9.0-linux-amd64.zip" || sum == nil || *sum.Name != "helmc-0.9.0-linux-amd64.zip.sha256" {
+		t.Errorf("Unexpected assets %v and %v", a, sum)
+	}
+
+	if _, sum, _ := Asset(rel, "linux", "386"); sum != nil {
+		t.Errorf("Expected no checksum, got %v", sum)
+	}
+	if _, _, err := Asset(rel, "windows", "amd64"); err == nil {
+		t.Error("Expected an error for a missing platform")
+	}
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	data := []byte("helmc")
+	sum := sha256.Sum256(data)
+	published := []byte(hex.EncodeToString(sum[:]) + "  helmc-0.9.0-linux-amd64.zip\n")
+
+	if err := VerifyChecksum(data, published); err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+	if err := VerifyChecksum([]byte("tampered"), published); err == nil {
+		t.Error("Expected a mismatch")
+	}
+	if err := VerifyChecksum(data, nil); err == nil {
+		t.Error("Expected an error for an empty checksum")
+	}
+}
+
+func TestExtract(t *testing.T) {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, body := range map[string]string{"README.md": "readme", "helmc": "binary"} {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(body))
+	}
+	w.Close()
+
+	bin, err := Extract(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bin) != "binary" {
+		t.Errorf("Expected the helmc binary, got %q", bin)
+	}
+}
+
+func TestReplace(t *testing.T) {
+	dir, err := ioutil.TempDir("", "helmc-replace-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "helmc")
+	if err := ioutil.WriteFile(path, []byte("old"), 0750); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Replace(path, []byte("new")); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadFile(path)
+	fi, _ := os.Stat(path)
+	if string(b) != "new" || fi.Mode().Perm() != 0750 {
+		t.Errorf("Expected new contents with mode 0750, got %q with %s", b, fi.Mode())
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 1 {
+		t.Errorf("Expected the temporary file to be gone, found %d files", len(files))
+	}
+}
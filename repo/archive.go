@@ -0,0 +1,139 @@
+package repo
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	helm "github.com/helm/helm-classic/util"
+)
+
+// Client is the HTTP client used to fetch indices and archives.
+var Client = http.DefaultClient
+
+// Get fetches the body of the given URL, showing the progress of the
+// download as util.ProgressMode says.
+//
+// If authorization is not empty, it is sent as the Authorization header. The
+// download ends if the command is stopped.
+func Get(u, authorization string) ([]byte, error) {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(helm.Context())
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	res, err := Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", u, res.Status)
+	}
+	body := &helm.ProgressReader{R: res.Body, Size: res.ContentLength, Progress: helm.Progress{Prefix: "GET " + helm.Redact(u)}}
+	defer body.Done()
+	return ioutil.ReadAll(body)
+}
+
+// Verify checks that data matches a hex-encoded SHA-256 digest.
+//
+// A leading "sha256:" on the digest is ignored.
+func Verify(data []byte, digest string) error {
+	digest = strings.TrimPrefix(strings.ToLower(digest), "sha256:")
+	if digest == "" {
+		return fmt.Errorf("no digest to verify against")
+	}
+	sum := sha256.Sum256(data)
+	if got := hex.EncodeToString(sum[:]); got != digest {
+		return fmt.Errorf("digest mismatch: expected %s, got %s", digest, got)
+	}
+	return nil
+}
+
+// Expand unpacks a gzipped tar archive of a chart into dest.
+//
+// Chart archives contain a single top-level directory, which is stripped, so
+// the chart's Chart.yaml ends up at the top of dest.
+//
+// Only directories and regular files are unpacked: symlinks, and anything
+// else, are skipped. Files are written 0755 if anyone may execute them, and
+// otherwise 0644, so that setuid and setgid bits are never set. An archive
+// with more than maxFiles files, or more than maxBytes of them, is an error.
+// Zero is no bound.
+func Expand(data []byte, dest string, maxFiles int, maxBytes int64) error {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	files, size := 0, int64(0)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		parts := strings.SplitN(filepath.ToSlash(h.Name), "/", 2)
+		if len(parts) < 2 || parts[1] == "" {
+			continue
+		}
+		rel := filepath.Clean(filepath.FromSlash(parts[1]))
+		if filepath.IsAbs(rel) || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("illegal path in archive: %s", h.Name)
+		}
+		target := filepath.Join(dest, rel)
+
+		switch h.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg, tar.TypeRegA:
+			if files++; maxFiles > 0 && files > maxFiles {
+				return fmt.Errorf("the archive has more than %d files", maxFiles)
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			mode := os.FileMode(0644)
+			if h.Mode&0111 != 0 {
+				mode = 0755
+			}
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
+			if err != nil {
+				return err
+			}
+			var r io.Reader = tr
+			if maxBytes > 0 {
+				// Read one byte more than is left, to tell an archive that
+				// is too big from one that fits exactly.
+				r = io.LimitReader(tr, maxBytes-size+1)
+			}
+			n, err := io.Copy(f, r)
+			f.Close()
+			if err != nil {
+				return err
+			}
+			if size += n; maxBytes > 0 && size > maxBytes {
+				return fmt.Errorf("the archive has more than %s of files", helm.ByteSize(maxBytes))
+			}
+		}
+	}
+}
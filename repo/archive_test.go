@@ -0,0 +1,111 @@
+package repo
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/helm/helm-classic/test"
+)
+
+func TestGet(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/index.yaml" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(testIndex))
+	}))
+	defer ts.Close()
+
+	b, err := Get(ts.URL+"/index.yaml", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != testIndex {
+		t.Errorf("Unexpected body %q", b)
+	}
+
+	if _, err := Get(ts.URL+"/missing.yaml", ""); err == nil {
+		t.Error("Expected an error for a 404")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	data := []byte("chart data")
+	sum := sha256.Sum256(data)
+	digest := hex.EncodeToString(sum[:])
+
+	if err := Verify(data, digest); err != nil {
+		t.Error(err)
+	}
+	if err := Verify(data, "sha256:"+digest); err != nil {
+		t.Error(err)
+	}
+	if err := Verify([]byte("tampered"), digest); err == nil {
+		t.Error("Expected a digest mismatch")
+	}
+	if err := Verify(data, ""); err == nil {
+		t.Error("Expected an error for a missing digest")
+	}
+}
+
+func TestExpand(t *testing.T) {
+	dest, err := ioutil.TempDir("", "helmc-expand-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dest)
+
+	data := test.ChartArchive("redis", map[string]string{
+		"Chart.yaml":           "name: redis\nversion: 0.10.0\n",
+		"manifests/redis.yaml": "kind: Pod\n",
+	})
+	if err := Expand(data, dest, 2, 100); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, f := range []string{"Chart.yaml", "manifests/redis.yaml"} {
+		if fi, err := os.Stat(filepath.Join(dest, f)); err != nil {
+			t.Errorf("Expected %s to be expanded: %s", f, err)
+		} else if fi.Mode() != 0644 {
+			t.Errorf("Expected %s to be 0644, got %s", f, fi.Mode())
+		}
+	}
+
+	if err := Expand(data, dest, 1, 0); err == nil || err.Error() != "the archive has more than 1 files" {
+		t.Errorf("Expected too many files, got %v", err)
+	}
+	if err := Expand(data, dest, 0, 30); err == nil || err.Error() != "the archive has more than 30 B of files" {
+		t.Errorf("Expected too many bytes, got %v", err)
+	}
+}
+
+func TestExpandRejectsEscapes(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	body := "evil"
+	tw.WriteHeader(&tar.Header{Name: "redis/../../evil", Mode: 0644, Size: int64(len(body))})
+	tw.Write([]byte(body))
+	tw.Close()
+	gz.Close()
+
+	dest, err := ioutil.TempDir("", "helmc-expand-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dest)
+
+	if err := Expand(buf.Bytes(), dest, 0, 0); err == nil {
+		t.Error("Expected an error for a path outside the chart")
+	}
+}
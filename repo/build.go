@@ -0,0 +1,179 @@
+package repo
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"github.com/helm/helm-classic/chart"
+	"gopkg.in/yaml.v2"
+)
+
+// IndexAPIVersion is the version of the index format that BuildIndex writes.
+const IndexAPIVersion = "v1"
+
+// BuildIndex creates an index of the chart archives (*.tgz) in dir.
+//
+// Each archive's URL is its file name, relative to baseURL if one is given.
+// Archives that cannot be read are left out of the index; their names are
+// returned, along with the reason, in skipped.
+func BuildIndex(dir, baseURL string) (idx *Index, skipped []string, err error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.tgz"))
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Strings(files)
+
+	idx = &Index{APIVersion: IndexAPIVersion, Entries: map[string][]*ChartVersion{}}
+	for _, f := range files {
+		name := filepath.Base(f)
+		cv, err := archiveVersion(f)
+		if err != nil {
+			skipped = append(skipped, fmt.Sprintf("%s: %s", name, err))
+			continue
+		}
+		cv.URL = name
+		if baseURL != "" {
+			cv.URL = strings.TrimSuffix(baseURL, "/") + "/" + name
+		}
+		idx.Entries[cv.Name] = append(idx.Entries[cv.Name], cv)
+	}
+	idx.Sort()
+	return idx, skipped, nil
+}
+
+// archiveVersion describes a chart archive from the Chart.yaml inside it.
+func archiveVersion(filename string) (*ChartVersion, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	cf, err := archiveChartfile(data)
+	if err != nil {
+		return nil, err
+	}
+	if cf.Name == "" || cf.Version == "" {
+		return nil, fmt.Errorf("Chart.yaml must have a name and a version")
+	}
+	sum := sha256.Sum256(data)
+	return &ChartVersion{
+		Name:        cf.Name,
+		Version:     cf.Version,
+		Description: cf.Description,
+		Digest:      hex.EncodeToString(sum[:]),
+
+		Keywords:           cf.Keywords,
+		Maintainers:        cf.Maintainers,
+		Deprecated:         cf.Deprecated,
+		DeprecationMessage: cf.DeprecationMessage,
+	}, nil
+}
+
+// archiveChartfile reads the Chart.yaml at the top of a chart archive.
+func archiveChartfile(data []byte) (*chart.Chartfile, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("no Chart.yaml in archive")
+		} else if err != nil {
+			return nil, err
+		}
+		parts := strings.Split(filepath.ToSlash(filepath.Clean(h.Name)), "/")
+		if len(parts) != 2 || parts[1] != "Chart.yaml" {
+			continue
+		}
+		b, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		cf := &chart.Chartfile{}
+		if err := yaml.Unmarshal(b, cf); err != nil {
+			return nil, fmt.Errorf("malformed Chart.yaml: %s", err)
+		}
+		return cf, nil
+	}
+}
+
+// Merge adds the versions in other that are not already in the index.
+//
+// A version that is in both is taken from the index, not from other.
+func (i *Index) Merge(other *Index) {
+	if i.Entries == nil {
+		i.Entries = map[string][]*ChartVersion{}
+	}
+	for name, versions := range other.Entries {
+		for _, ov := range versions {
+			if !i.has(name, ov.Version) {
+				i.Entries[name] = append(i.Entries[name], ov)
+			}
+		}
+	}
+	i.Sort()
+}
+
+func (i *Index) has(name, version string) bool {
+	for _, cv := range i.Entries[name] {
+		if cv.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+// Sort orders the versions of each chart from newest to oldest.
+//
+// Versions that are not valid SemVer sort last, in lexical order.
+func (i *Index) Sort() {
+	for _, versions := range i.Entries {
+		sort.Sort(byVersion(versions))
+	}
+}
+
+// Save writes the index to a file.
+//
+// The output is deterministic: saving the same index twice produces the
+// same file.
+func (i *Index) Save(filename string) error {
+	if i.APIVersion == "" {
+		i.APIVersion = IndexAPIVersion
+	}
+	b, err := yaml.Marshal(i)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, b, 0644)
+}
+
+type byVersion []*ChartVersion
+
+func (b byVersion) Len() int      { return len(b) }
+func (b byVersion) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b byVersion) Less(i, j int) bool {
+	vi, ei := semver.NewVersion(b[i].Version)
+	vj, ej := semver.NewVersion(b[j].Version)
+	switch {
+	case ei != nil && ej != nil:
+		return b[i].Version < b[j].Version
+	case ei != nil:
+		return false
+	case ej != nil:
+		return true
+	}
+	return vi.GreaterThan(vj)
+}
@@ -0,0 +1,83 @@
+package repo
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/helm/helm-classic/test"
+)
+
+func writeArchives(t *testing.T, dir string) {
+	for file, cf := range map[string]string{
+		"redis-0.1.0.tgz":  "name: redis\nversion: 0.1.0\n",
+		"redis-0.10.0.tgz": "name: redis\nversion: 0.10.0\ndescription: A key-value store\n",
+		"redis-0.2.0.tgz":  "name: redis\nversion: 0.2.0\n",
+		"nginx-1.0.0.tgz":  "name: nginx\nversion: 1.0.0\ndeprecated: true\ndeprecationMessage: Use caddy.\n",
+	} {
+		a := test.ChartArchive("chart", map[string]string{"Chart.yaml": cf})
+		if err := ioutil.WriteFile(filepath.Join(dir, file), a, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ioutil.WriteFile(filepath.Join(dir, "broken-0.1.0.tgz"), []byte("not an archive"), 0644)
+}
+
+func TestBuildIndex(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "helmc-index")
+	defer os.RemoveAll(dir)
+	writeArchives(t, dir)
+
+	idx, skipped, err := BuildIndex(dir, "https://charts.example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(skipped) != 1 {
+		t.Errorf("Expected the broken archive to be skipped, got %v", skipped)
+	}
+	versions := []string{}
+	for _, cv := range idx.Entries["redis"] {
+		versions = append(versions, cv.Version)
+	}
+	if expect := "0.10.0 0.2.0 0.1.0"; strings.Join(versions, " ") != expect {
+		t.Errorf("Expected versions %s, got %v", expect, versions)
+	}
+	latest := idx.Latest("redis")
+	if latest.URL != "https://charts.example.com/redis-0.10.0.tgz" || latest.Description != "A key-value store" {
+		t.Errorf("Unexpected entry %+v", latest)
+	}
+	if nginx := idx.Latest("nginx"); !nginx.Deprecated || nginx.DeprecationMessage != "Use caddy." {
+		t.Errorf("Expected nginx to be deprecated, got %+v", nginx)
+	}
+	data, _ := ioutil.ReadFile(filepath.Join(dir, "redis-0.10.0.tgz"))
+	if err := Verify(data, latest.Digest); err != nil {
+		t.Errorf("Expected the digest to match the archive: %s", err)
+	}
+
+	// Indexing the same directory twice produces the same file.
+	f1, f2 := filepath.Join(dir, "1.yaml"), filepath.Join(dir, "2.yaml")
+	idx.Save(f1)
+	idx2, _, _ := BuildIndex(dir, "https://charts.example.com/")
+	idx2.Save(f2)
+	b1, _ := ioutil.ReadFile(f1)
+	b2, _ := ioutil.ReadFile(f2)
+	if !bytes.Equal(b1, b2) {
+		t.Errorf("Expected identical indices:\n%s\n%s", b1, b2)
+	}
+}
+
+func TestMergeIndex(t *testing.T) {
+	cur, _ := ParseIndex([]byte("entries:\n  redis:\n  - {version: 0.2.0, url: new.tgz}\n"))
+	old, _ := ParseIndex([]byte("entries:\n  redis:\n  - {version: 0.2.0, url: old.tgz}\n  - {version: 0.1.0, url: http://elsewhere/redis-0.1.0.tgz}\n  memcached:\n  - {version: 1.0.0, url: memcached.tgz}\n"))
+	cur.Merge(old)
+
+	if r := cur.Entries["redis"]; len(r) != 2 || r[0].URL != "new.tgz" || r[1].Version != "0.1.0" {
+		t.Errorf("Expected the new 0.2.0 and the old 0.1.0, got %v", r)
+	}
+	if len(cur.Entries["memcached"]) != 1 {
+		t.Errorf("Expected memcached to be kept")
+	}
+}
@@ -0,0 +1,77 @@
+package repo
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/helm/helm-classic/chart"
+)
+
+// BundleDir is the top-level directory of a bundle, which Expand strips.
+const BundleDir = "bundle"
+
+// BundleCharts is the directory of a bundle that holds its charts.
+const BundleCharts = "charts"
+
+// WriteBundle writes a bundle to filename: a gzipped tar archive that holds
+// the file index, with the contents data, and the chart in each directory of
+// dirs, under BundleCharts and its name in dirs.
+//
+// The files of each chart are those that chart.Digest covers, so that a
+// chart that is expanded from the bundle has the digest of the chart it was
+// written from. As with Package, nothing in them depends on when or by whom
+// the bundle was written.
+func WriteBundle(filename, index string, data []byte, dirs map[string]string) error {
+	names := make([]string, 0, len(dirs))
+	for n := range dirs {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	if err := addFile(tw, BundleDir+"/"+index, 0644, data); err != nil {
+		return err
+	}
+	for _, name := range names {
+		files, err := chart.Files(dirs[name], nil)
+		if err != nil {
+			return err
+		}
+		rels := make([]string, 0, len(files))
+		for rel := range files {
+			rels = append(rels, rel)
+		}
+		sort.Strings(rels)
+		for _, rel := range rels {
+			p := filepath.Join(dirs[name], filepath.FromSlash(rel))
+			fi, err := os.Stat(p)
+			if err != nil {
+				return err
+			}
+			b, err := ioutil.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			if err := addFile(tw, BundleDir+"/"+BundleCharts+"/"+name+"/"+rel, fi.Mode(), b); err != nil {
+				return err
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, buf.Bytes(), 0644)
+}
@@ -0,0 +1,141 @@
+// Package repo provides support for HTTP chart repositories.
+//
+// An HTTP repository is described by an index file that lists every version
+// of every chart the repository offers, along with the URL of a gzipped tar
+// archive for each version.
+package repo
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"sort"
+
+	"github.com/Masterminds/semver"
+	"github.com/helm/helm-classic/chart"
+	"golang.org/x/crypto/openpgp/clearsign"
+	"gopkg.in/yaml.v2"
+)
+
+// IndexFile is the name of a repository's index file.
+const IndexFile = "index.yaml"
+
+// Index describes the charts in an HTTP repository.
+type Index struct {
+	// APIVersion is the version of the index format.
+	APIVersion string `yaml:"apiVersion"`
+	// Entries maps chart names to the available versions of that chart.
+	Entries map[string][]*ChartVersion `yaml:"entries"`
+}
+
+// ChartVersion describes a single packaged version of a chart.
+type ChartVersion struct {
+	Name        string `yaml:"name"`
+	Version     string `yaml:"version"`
+	Description string `yaml:"description,omitempty"`
+	// Digest is the hex-encoded SHA-256 of the archive.
+	Digest string `yaml:"digest"`
+	// URL is the location of the archive. It may be relative to the index.
+	URL string `yaml:"url"`
+	// Deprecated and DeprecationMessage are those of the Chart.yaml, so that
+	// a deprecated chart is known without downloading it.
+	Deprecated         bool   `yaml:"deprecated,omitempty"`
+	DeprecationMessage string `yaml:"deprecationMessage,omitempty"`
+	// Keywords and Maintainers are those of the Chart.yaml, so that
+	// 'helmc search' can find the chart by them.
+	Keywords    []string `yaml:"keywords,omitempty"`
+	Maintainers []string `yaml:"maintainers,omitempty"`
+}
+
+// Chartfile returns what the index says of the Chart.yaml of the version.
+func (cv *ChartVersion) Chartfile() *chart.Chartfile {
+	return &chart.Chartfile{
+		Name:               cv.Name,
+		Version:            cv.Version,
+		Description:        cv.Description,
+		Keywords:           cv.Keywords,
+		Maintainers:        cv.Maintainers,
+		Deprecated:         cv.Deprecated,
+		DeprecationMessage: cv.DeprecationMessage,
+	}
+}
+
+// LoadIndex reads an index from a file.
+func LoadIndex(filename string) (*Index, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return ParseIndex(b)
+}
+
+// ParseIndex parses YAML data into an *Index.
+//
+// If the data is clearsigned, the signature is ignored; use VerifySignature
+// to check it.
+func ParseIndex(data []byte) (*Index, error) {
+	if b, _ := clearsign.Decode(data); b != nil {
+		data = b.Plaintext
+	}
+	i := &Index{}
+	if err := yaml.Unmarshal(data, i); err != nil {
+		return nil, err
+	}
+	for name, versions := range i.Entries {
+		for _, v := range versions {
+			if v.URL == "" {
+				return nil, fmt.Errorf("chart %s %s has no url", name, v.Version)
+			}
+			if v.Name == "" {
+				v.Name = name
+			}
+		}
+	}
+	return i, nil
+}
+
+// Names returns the sorted names of the charts in the index.
+func (i *Index) Names() []string {
+	names := make([]string, 0, len(i.Entries))
+	for n := range i.Entries {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Latest returns the newest version of the named chart, or nil if there is none.
+//
+// Versions that are not valid SemVer sort before all valid versions.
+func (i *Index) Latest(name string) *ChartVersion {
+	var latest *ChartVersion
+	var lv *semver.Version
+	for _, cv := range i.Entries[name] {
+		v, err := semver.NewVersion(cv.Version)
+		if err != nil {
+			if latest == nil {
+				latest = cv
+			}
+			continue
+		}
+		if lv == nil || v.GreaterThan(lv) {
+			latest, lv = cv, v
+		}
+	}
+	return latest
+}
+
+// ResolveURL returns the absolute URL of a chart archive.
+//
+// Relative archive URLs are resolved against the URL the index was fetched from.
+func (cv *ChartVersion) ResolveURL(indexURL string) (string, error) {
+	base, err := url.Parse(indexURL)
+	if err != nil {
+		return "", err
+	}
+	ref, err := url.Parse(cv.URL)
+	if err != nil {
+		return "", err
+	}
+	return base.ResolveReference(ref).String(), nil
+}
@@ -0,0 +1,66 @@
+package repo
+
+import "testing"
+
+const testIndex = `apiVersion: v1
+entries:
+  redis:
+  - version: 0.9.0
+    digest: aaa
+    url: redis-0.9.0.tgz
+  - version: 0.10.0
+    description: A Redis server
+    digest: bbb
+    url: https://example.com/archives/redis-0.10.0.tgz
+  nginx:
+  - version: 1.0.0
+    digest: ccc
+    url: nginx-1.0.0.tgz
+`
+
+func TestParseIndex(t *testing.T) {
+	i, err := ParseIndex([]byte(testIndex))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if names := i.Names(); len(names) != 2 || names[0] != "nginx" || names[1] != "redis" {
+		t.Errorf("Unexpected names %v", names)
+	}
+	if n := i.Entries["redis"][0].Name; n != "redis" {
+		t.Errorf("Expected name to default to the entry key, got %q", n)
+	}
+
+	latest := i.Latest("redis")
+	if latest == nil || latest.Version != "0.10.0" {
+		t.Fatalf("Expected 0.10.0 to be latest, got %v", latest)
+	}
+	if i.Latest("memcached") != nil {
+		t.Error("Expected no entry for memcached")
+	}
+
+	if _, err := ParseIndex([]byte("entries:\n  redis:\n  - version: 1.0.0\n")); err == nil {
+		t.Error("Expected an error for an entry without a url")
+	}
+}
+
+func TestResolveURL(t *testing.T) {
+	i, _ := ParseIndex([]byte(testIndex))
+	base := "https://charts.example.com/stable/index.yaml"
+
+	for _, tt := range []struct {
+		cv     *ChartVersion
+		expect string
+	}{
+		{i.Entries["redis"][0], "https://charts.example.com/stable/redis-0.9.0.tgz"},
+		{i.Entries["redis"][1], "https://example.com/archives/redis-0.10.0.tgz"},
+	} {
+		u, err := tt.cv.ResolveURL(base)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u != tt.expect {
+			t.Errorf("Expected %s, got %s", tt.expect, u)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package repo
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/helm/helm-classic/chart"
+)
+
+// ArchiveName returns the file name of the archive of a version of a chart.
+func ArchiveName(name, version string) string {
+	return name + "-" + version + ".tgz"
+}
+
+// Package writes an archive of the chart in dir into the directory dest, as
+// ArchiveName names it from the chart's Chart.yaml. It returns the path of
+// the archive, and its ChartVersion, with the SHA-256 digest of the archive
+// and its file name as its URL.
+//
+// The files of the chart are under a top-level directory named after the
+// chart, Chart.yaml first, so that its metadata is read without reading the
+// rest of the archive. Directories whose names start with '_' or '.', the
+// files that the chart's .helmignore excludes, and files that are not
+// regular files are left out. Files are archived 0755 if anyone may execute
+// them, and otherwise 0644, with no owner and a fixed time, so that
+// packaging the same chart twice produces the same archive.
+func Package(dir, dest string) (string, *ChartVersion, error) {
+	cf, err := chart.LoadChartfile(filepath.Join(dir, "Chart.yaml"))
+	if err != nil {
+		return "", nil, err
+	}
+	if cf.Name == "" || cf.Version == "" {
+		return "", nil, fmt.Errorf("Chart.yaml must have a name and a version")
+	}
+	if strings.ContainsAny(cf.Name, `/\`) {
+		return "", nil, fmt.Errorf("chart name %q has a path separator", cf.Name)
+	}
+
+	files, err := packageFiles(dir)
+	if err != nil {
+		return "", nil, err
+	}
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, rel := range files {
+		p := filepath.Join(dir, filepath.FromSlash(rel))
+		fi, err := os.Stat(p)
+		if err != nil {
+			return "", nil, err
+		}
+		b, err := ioutil.ReadFile(p)
+		if err != nil {
+			return "", nil, err
+		}
+		if err := addFile(tw, cf.Name+"/"+rel, fi.Mode(), b); err != nil {
+			return "", nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return "", nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return "", nil, err
+	}
+
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return "", nil, err
+	}
+	name := ArchiveName(cf.Name, cf.Version)
+	filename := filepath.Join(dest, name)
+	if err := ioutil.WriteFile(filename, buf.Bytes(), 0644); err != nil {
+		return "", nil, err
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	return filename, &ChartVersion{
+		Name:        cf.Name,
+		Version:     cf.Version,
+		Description: cf.Description,
+		URL:         name,
+		Digest:      hex.EncodeToString(sum[:]),
+
+		Keywords:           cf.Keywords,
+		Maintainers:        cf.Maintainers,
+		Deprecated:         cf.Deprecated,
+		DeprecationMessage: cf.DeprecationMessage,
+	}, nil
+}
+
+// packageFiles returns the slash-separated paths of the files of the chart
+// in dir that Package archives, Chart.yaml first and the rest in lexical
+// order.
+func packageFiles(dir string) ([]string, error) {
+	ig, err := chart.LoadIgnore(dir)
+	if err != nil {
+		return nil, err
+	}
+	files := []string{}
+	err = filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == "." {
+			return nil
+		}
+		if fi.IsDir() {
+			if strings.HasPrefix(fi.Name(), "_") || strings.HasPrefix(fi.Name(), ".") || ig.Ignored(rel, true) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if fi.Mode().IsRegular() && !ig.Ignored(rel, false) {
+			files = append(files, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i] == "Chart.yaml" && files[j] != "Chart.yaml"
+	})
+	return files, nil
+}
+
+// addFile writes a file of an archive, 0755 if anyone may execute it, and
+// otherwise 0644, with no owner and a fixed time.
+func addFile(tw *tar.Writer, name string, mode os.FileMode, b []byte) error {
+	m := int64(0644)
+	if mode&0111 != 0 {
+		m = 0755
+	}
+	h := &tar.Header{
+		Name:     name,
+		Mode:     m,
+		Size:     int64(len(b)),
+		ModTime:  time.Unix(0, 0),
+		Typeflag: tar.TypeReg,
+	}
+	if err := tw.WriteHeader(h); err != nil {
+		return err
+	}
+	_, err := tw.Write(b)
+	return err
+}
@@ -0,0 +1,87 @@
+package repo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPackage(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "helmc-package")
+	defer os.RemoveAll(dir)
+	src := filepath.Join(dir, "src")
+	for file, body := range map[string]string{
+		"Chart.yaml":              "name: redis\nversion: 0.2.0\ndescription: A key-value store\n",
+		"manifests/redis-rc.yaml": "kind: ReplicationController\n",
+		"notes.txt":               "ignored\n",
+		".helmignore":             "notes.txt\n",
+		"_tpl/config.tpl":         "left out\n",
+		".git/HEAD":               "left out\n",
+		"manifests/.tmp/x":        "left out\n",
+	} {
+		p := filepath.Join(src, filepath.FromSlash(file))
+		os.MkdirAll(filepath.Dir(p), 0755)
+		if err := ioutil.WriteFile(p, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := packageFiles(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []string{"Chart.yaml", ".helmignore", "manifests/redis-rc.yaml"}
+	if len(files) != len(expect) {
+		t.Fatalf("Expected %v, got %v", expect, files)
+	}
+	for i := range expect {
+		if files[i] != expect[i] {
+			t.Errorf("Expected %v, got %v", expect, files)
+		}
+	}
+
+	out := filepath.Join(dir, "out")
+	filename, cv, err := Package(src, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filename != filepath.Join(out, "redis-0.2.0.tgz") || cv.URL != "redis-0.2.0.tgz" {
+		t.Errorf("Expected the archive to be named after the chart, got %s, %s", filename, cv.URL)
+	}
+
+	// The index reads the archive as Package describes it.
+	idx, skipped, err := BuildIndex(out, "")
+	if err != nil || len(skipped) > 0 {
+		t.Fatalf("Could not index the archive: %v, %v", skipped, err)
+	}
+	got := idx.Latest("redis")
+	if got == nil || got.Digest != cv.Digest || got.Description != "A key-value store" {
+		t.Errorf("Expected the index to have %v, got %v", cv, got)
+	}
+
+	data, _ := ioutil.ReadFile(filename)
+	if err := Verify(data, cv.Digest); err != nil {
+		t.Error(err)
+	}
+	exp := filepath.Join(dir, "expanded")
+	if err := Expand(data, exp, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := ioutil.ReadFile(filepath.Join(exp, "manifests", "redis-rc.yaml")); err != nil || string(b) != "kind: ReplicationController\n" {
+		t.Errorf("Expected the manifest to be archived, got %q, %v", b, err)
+	}
+
+	// Packaging again gives the same archive.
+	later := time.Now().Add(time.Hour)
+	os.Chtimes(filepath.Join(src, "Chart.yaml"), later, later)
+	if _, again, err := Package(src, out); err != nil || again.Digest != cv.Digest {
+		t.Errorf("Expected the same digest %s, got %v, %v", cv.Digest, again, err)
+	}
+
+	ioutil.WriteFile(filepath.Join(src, "Chart.yaml"), []byte("name: redis\n"), 0644)
+	if _, _, err := Package(src, out); err == nil {
+		t.Errorf("Expected an error for a chart without a version")
+	}
+}
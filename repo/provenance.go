@@ -0,0 +1,115 @@
+package repo
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/clearsign"
+	"gopkg.in/yaml.v2"
+)
+
+// ProvenanceExt is appended to the path of a chart directory, or of a chart
+// archive, or to the URL of an archive, to find its provenance file.
+const ProvenanceExt = ".prov"
+
+// Provenance is what a provenance file says about a chart: which chart it
+// is, and the checksum of its files, as chart.Checksum computes it.
+//
+// The checksum covers the files of the chart rather than the bytes of an
+// archive, so that the same provenance file verifies an archive and the
+// chart that is expanded from it, as fetch does.
+type Provenance struct {
+	Name     string `yaml:"name"`
+	Version  string `yaml:"version"`
+	Checksum string `yaml:"checksum"`
+}
+
+// SignProvenance returns a provenance file: p as YAML, clearsigned by the
+// private key of signer.
+func SignProvenance(p *Provenance, signer *openpgp.Entity) ([]byte, error) {
+	if signer.PrivateKey == nil {
+		return nil, fmt.Errorf("key %X has no private key", signer.PrimaryKey.KeyId)
+	}
+	body, err := yaml.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	w, err := clearsign.Encode(&buf, signer.PrivateKey, nil)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(body); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// VerifyProvenance checks the signature of a provenance file against the keys
+// in a keyring, and returns what the file says, and the identity of the key
+// that signed it, as VerifySignature gives it.
+func VerifyProvenance(data []byte, keyring openpgp.EntityList) (*Provenance, string, error) {
+	b, _ := clearsign.Decode(data)
+	if b == nil {
+		return nil, "", fmt.Errorf("provenance file is not signed")
+	}
+	signer, err := VerifySignature(data, nil, keyring)
+	if err != nil {
+		return nil, "", err
+	}
+	p := &Provenance{}
+	if err := yaml.Unmarshal(b.Plaintext, p); err != nil {
+		return nil, "", fmt.Errorf("malformed provenance file: %s", err)
+	}
+	if p.Name == "" || p.Checksum == "" {
+		return nil, "", fmt.Errorf("provenance file must have a name and a checksum")
+	}
+	return p, signer, nil
+}
+
+// SigningKey returns the key of a keyring that signs: the first one with a
+// private key whose identity contains name, or if name is empty, the first
+// one with a private key.
+//
+// A private key that is encrypted is decrypted with the passphrase that
+// passphrase returns. It is only called if one is needed.
+func SigningKey(keyring openpgp.EntityList, name string, passphrase func() ([]byte, error)) (*openpgp.Entity, error) {
+	for _, e := range keyring {
+		if e.PrivateKey == nil || !hasIdentity(e, name) {
+			continue
+		}
+		if e.PrivateKey.Encrypted {
+			pw, err := passphrase()
+			if err != nil {
+				return nil, err
+			}
+			if err := e.PrivateKey.Decrypt(pw); err != nil {
+				return nil, fmt.Errorf("could not decrypt key %X: %s", e.PrimaryKey.KeyId, err)
+			}
+		}
+		return e, nil
+	}
+	if name != "" {
+		return nil, fmt.Errorf("no private key of %q in the keyring", name)
+	}
+	return nil, fmt.Errorf("no private key in the keyring")
+}
+
+// hasIdentity returns true if an identity of e contains name, or if name is
+// empty.
+func hasIdentity(e *openpgp.Entity, name string) bool {
+	if name == "" {
+		return true
+	}
+	for id := range e.Identities {
+		if strings.Contains(id, name) {
+			return true
+		}
+	}
+	return false
+}
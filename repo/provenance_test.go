@@ -0,0 +1,42 @@
+package repo
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/helm/helm-classic/test"
+	"golang.org/x/crypto/openpgp"
+)
+
+func TestProvenance(t *testing.T) {
+	good := test.SigningKey("good")
+	evil := test.SigningKey("evil")
+	p := &Provenance{Name: "redis", Version: "0.2.0", Checksum: "sha256:abc"}
+
+	data, err := SignProvenance(p, good)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, signer, err := VerifyProvenance(data, openpgp.EntityList{good})
+	if err != nil || *got != *p || !strings.Contains(signer, "good") {
+		t.Errorf("Expected %v signed by good, got %v, %q, %v", p, got, signer, err)
+	}
+	if _, _, err := VerifyProvenance(data, openpgp.EntityList{evil}); err == nil {
+		t.Errorf("Expected a provenance file of an unknown key to fail")
+	}
+	tampered := bytes.Replace(data, []byte("0.2.0"), []byte("0.3.0"), 1)
+	if _, _, err := VerifyProvenance(tampered, openpgp.EntityList{good}); err == nil {
+		t.Errorf("Expected a changed provenance file to fail")
+	}
+	if _, _, err := VerifyProvenance([]byte("name: redis\n"), openpgp.EntityList{good}); err == nil {
+		t.Errorf("Expected an unsigned provenance file to fail")
+	}
+
+	if k, err := SigningKey(openpgp.EntityList{evil, good}, "good", nil); err != nil || k != good {
+		t.Errorf("Expected the key of good, got %v, %v", k, err)
+	}
+	if _, err := SigningKey(openpgp.EntityList{evil}, "good", nil); err == nil {
+		t.Errorf("Expected no key of good")
+	}
+}
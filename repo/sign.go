@@ -0,0 +1,79 @@
+package repo
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/clearsign"
+)
+
+// SignatureExt is appended to the index URL to find its detached signature.
+const SignatureExt = ".asc"
+
+// IsClearsigned returns true if data is an OpenPGP clearsigned message.
+//
+// A clearsigned index carries its signature inline, so no detached signature
+// is needed.
+func IsClearsigned(data []byte) bool {
+	b, _ := clearsign.Decode(data)
+	return b != nil
+}
+
+// LoadKeyring reads an OpenPGP keyring, either ASCII-armored or binary.
+func LoadKeyring(filename string) (openpgp.EntityList, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if kr, err := openpgp.ReadArmoredKeyRing(f); err == nil {
+		return kr, nil
+	}
+	if _, err := f.Seek(0, 0); err != nil {
+		return nil, err
+	}
+	kr, err := openpgp.ReadKeyRing(f)
+	if err != nil {
+		return nil, fmt.Errorf("could not read keyring %s: %s", filename, err)
+	}
+	return kr, nil
+}
+
+// VerifySignature checks an index against the keys in a keyring.
+//
+// If sig is nil, data must be clearsigned. Otherwise, sig is the detached
+// signature of data, either ASCII-armored or binary. On success, it returns
+// the identity of the key that made the signature.
+func VerifySignature(data, sig []byte, keyring openpgp.EntityList) (string, error) {
+	var signer *openpgp.Entity
+	var err error
+	switch {
+	case sig != nil:
+		signer, err = openpgp.CheckArmoredDetachedSignature(keyring, bytes.NewReader(data), bytes.NewReader(sig))
+		if err != nil {
+			signer, err = openpgp.CheckDetachedSignature(keyring, bytes.NewReader(data), bytes.NewReader(sig))
+		}
+	default:
+		b, _ := clearsign.Decode(data)
+		if b == nil {
+			return "", fmt.Errorf("index is not signed")
+		}
+		signer, err = openpgp.CheckDetachedSignature(keyring, bytes.NewReader(b.Bytes), b.ArmoredSignature.Body)
+	}
+	if err != nil {
+		return "", err
+	}
+	names := []string{}
+	for name := range signer.Identities {
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return fmt.Sprintf("key %X", signer.PrimaryKey.KeyId), nil
+	}
+	sort.Strings(names)
+	return names[0], nil
+}
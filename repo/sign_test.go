@@ -0,0 +1,54 @@
+package repo
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/helm/helm-classic/test"
+)
+
+func TestVerifySignature(t *testing.T) {
+	good := test.SigningKey("good")
+	evil := test.SigningKey("evil")
+	dir := test.CreateTmpHome()
+	os.MkdirAll(dir, 0755)
+	defer os.RemoveAll(dir)
+	krfile := filepath.Join(dir, "keyring.asc")
+	test.WriteKeyring(krfile, good)
+	kr, err := LoadKeyring(krfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := []byte("apiVersion: v1\nentries: {}\n")
+	signer, err := VerifySignature(data, test.DetachSign(good, data), kr)
+	if err != nil {
+		t.Fatalf("Expected detached signature to verify: %s", err)
+	}
+	if signer != "good (test) <good@example.com>" {
+		t.Errorf("Unexpected signer %q", signer)
+	}
+
+	if _, err := VerifySignature(data, test.DetachSign(evil, data), kr); err == nil {
+		t.Errorf("Expected a signature from an unknown key to fail")
+	}
+	if _, err := VerifySignature(append(data, '#'), test.DetachSign(good, data), kr); err == nil {
+		t.Errorf("Expected a modified index to fail")
+	}
+	if _, err := VerifySignature(data, nil, kr); err == nil {
+		t.Errorf("Expected an unsigned index to fail")
+	}
+
+	cs := test.Clearsign(good, data)
+	if !IsClearsigned(cs) || IsClearsigned(data) {
+		t.Errorf("Expected only the clearsigned index to be detected")
+	}
+	if _, err := VerifySignature(cs, nil, kr); err != nil {
+		t.Errorf("Expected clearsigned index to verify: %s", err)
+	}
+	idx, err := ParseIndex(cs)
+	if err != nil || idx.APIVersion != "v1" {
+		t.Errorf("Expected clearsigned index to parse, got %v, %v", idx, err)
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/helm/helm-classic/chart"
 	"github.com/helm/helm-classic/config"
 	"github.com/helm/helm-classic/log"
+	"github.com/helm/helm-classic/repo"
 )
 
 // Result is a search result.
@@ -20,26 +21,58 @@ import (
 type Result struct {
 	Name  string
 	Score int
+	// Priority is the priority of the repository the chart is in.
+	Priority int
 }
 
 // Index is a searchable index of chart information.
 type Index struct {
-	lines  map[string]string
-	charts map[string]*chart.Chartfile
+	lines      map[string]string
+	charts     map[string]*chart.Chartfile
+	priorities map[string]int
 }
 
 const sep = "\v"
 
+// MaxScore is the score of a match in the last field of a chart's line: its
+// maintainers. The fields are, in order, the name of the chart, its path in
+// the repository, and its description, details, keywords, and maintainers.
+// A threshold above MaxScore matches every field.
+const MaxScore = 5
+
 // NewIndex creats a new Index.
 //
 // NewIndex indexes all of the chart tables configured in the config.yaml file.
-// For that reason, it may cause substantial overhead on a large set of repos.
+// If cachedir is the cache of the repositories, cfg.Repos.Dir, the metadata
+// index of each Git repository and directory mirror is used; see
+// config.Repos.Metadata. Otherwise every Chart.yaml is read, which may cause
+// substantial overhead on a large set of repos.
 func NewIndex(cfg *config.Configfile, cachedir string) *Index {
-	lines := map[string]string{}
-	charts := map[string]*chart.Chartfile{}
+	i := &Index{
+		lines:      map[string]string{},
+		charts:     map[string]*chart.Chartfile{},
+		priorities: map[string]int{},
+	}
 	for _, table := range cfg.Repos.Tables {
 		def := cfg.Repos.Default == table.Name
 
+		if table.IsHTTP() {
+			i.addHTTP(table, def, cachedir)
+			continue
+		}
+
+		if cfg.Repos.Dir != "" && cfg.Repos.Dir == cachedir {
+			m, err := cfg.Repos.Metadata(table.Name)
+			if err != nil {
+				log.Err("Failed to read table %s: %s", table.Name, err)
+				continue
+			}
+			for _, n := range m.Names() {
+				i.addChart(table, def, n, m.Charts[n].Chart)
+			}
+			continue
+		}
+
 		base := filepath.Join(cachedir, table.Name, "*/")
 		dirs, err := filepath.Glob(base)
 		if err != nil {
@@ -47,22 +80,56 @@ func NewIndex(cfg *config.Configfile, cachedir string) *Index {
 		}
 
 		for _, dir := range dirs {
-			bname := filepath.Base(dir)
 			c, err := chart.LoadChartfile(filepath.Join(dir, "Chart.yaml"))
 			if err != nil {
 				// This is not a chart. Skip it.
 				continue
 			}
-			name := table.Name + "/" + c.Name
-			if def {
-				name = c.Name
-			}
-			line := c.Name + sep + table.Name + "/" + bname + sep + c.Description + sep + c.Details
-			lines[name] = strings.ToLower(line)
-			charts[name] = c
+			i.addChart(table, def, filepath.Base(dir), c)
 		}
 	}
-	return &Index{lines: lines, charts: charts}
+	return i
+}
+
+// addChart adds a chart of a Git repository or directory mirror, from its
+// directory bname.
+func (i *Index) addChart(table *config.Table, def bool, bname string, c *chart.Chartfile) {
+	name := table.Name + "/" + c.Name
+	if def {
+		name = c.Name
+	}
+	i.add(name, indexLine(c, table.Name+"/"+bname), c, table.Priority)
+}
+
+// indexLine returns the searchable line of a chart at path, with the fields
+// that MaxScore lists.
+func indexLine(c *chart.Chartfile, path string) string {
+	return strings.Join([]string{c.Name, path, c.Description, c.Details, strings.Join(c.Keywords, " "), strings.Join(c.Maintainers, ", ")}, sep)
+}
+
+func (i *Index) add(name, line string, c *chart.Chartfile, priority int) {
+	i.lines[name] = strings.ToLower(line)
+	i.charts[name] = c
+	i.priorities[name] = priority
+}
+
+// addHTTP adds the latest version of each chart in an HTTP repository's
+// cached index file.
+func (i *Index) addHTTP(table *config.Table, def bool, cachedir string) {
+	idx, err := repo.LoadIndex(filepath.Join(cachedir, table.Name, repo.IndexFile))
+	if err != nil {
+		log.Err("Failed to read index for table %s: %s", table.Name, err)
+		return
+	}
+	for _, n := range idx.Names() {
+		cv := idx.Latest(n)
+		c := cv.Chartfile()
+		name := table.Name + "/" + c.Name
+		if def {
+			name = c.Name
+		}
+		i.add(name, indexLine(c, table.Name+"/"+n), c, table.Priority)
+	}
 }
 
 // Search searches an index for the given term.
@@ -107,7 +174,7 @@ func (i *Index) SearchLiteral(term string, threshold int) []*Result {
 	for k, v := range i.lines {
 		res := strings.Index(v, term)
 		if score := i.calcScore(res, v); res != -1 && score < threshold {
-			buf = append(buf, &Result{Name: k, Score: score})
+			buf = append(buf, &Result{Name: k, Score: score, Priority: i.priorities[k]})
 		}
 	}
 	return buf
@@ -126,12 +193,56 @@ func (i *Index) SearchRegexp(re string, threshold int) ([]*Result, error) {
 			continue
 		}
 		if score := i.calcScore(ind[0], v); ind[0] >= 0 && score < threshold {
-			buf = append(buf, &Result{Name: k, Score: score})
+			buf = append(buf, &Result{Name: k, Score: score, Priority: i.priorities[k]})
 		}
 	}
 	return buf, nil
 }
 
+// Filter narrows search results down by the metadata of their charts. Its
+// zero value matches every chart.
+type Filter struct {
+	// Keywords are keywords that a chart must all have, regardless of case.
+	Keywords []string
+	// Maintainers must each be part of one of the chart's maintainers,
+	// regardless of case, such as a name or an email address.
+	Maintainers []string
+}
+
+// Match reports whether a chart has the keywords and the maintainers of f.
+func (f Filter) Match(c *chart.Chartfile) bool {
+	for _, k := range f.Keywords {
+		found := false
+		for _, ck := range c.Keywords {
+			found = found || strings.EqualFold(ck, k)
+		}
+		if !found {
+			return false
+		}
+	}
+	for _, m := range f.Maintainers {
+		found := false
+		for _, cm := range c.Maintainers {
+			found = found || strings.Contains(strings.ToLower(cm), strings.ToLower(m))
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// Filter returns the results whose charts match f, in order.
+func (i *Index) Filter(res []*Result, f Filter) []*Result {
+	out := []*Result{}
+	for _, r := range res {
+		if c, ok := i.charts[r.Name]; ok && f.Match(c) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
 // ErrNoChart indicates that a chart is not in the chart cache.
 var ErrNoChart = errors.New("no such chart")
 
@@ -147,6 +258,17 @@ func (i *Index) Chart(name string) (*chart.Chartfile, error) {
 	return c, nil
 }
 
+// Names returns the names of the charts of the index, sorted: repo/chart,
+// or just chart for those of the default repository.
+func (i *Index) Names() []string {
+	names := make([]string, 0, len(i.charts))
+	for n := range i.charts {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // SortScore does an in-place sort of the results.
 //
 // Lowest scores are highest on the list. Matching scores are subsorted alphabetically.
@@ -176,3 +298,28 @@ func (s scoreSorter) Less(a, b int) bool {
 	}
 	return first.Name < second.Name
 }
+
+// SortPriority does an in-place sort of the results by repository priority.
+//
+// Results from higher priority repositories are first. Within a priority,
+// results are sorted alphabetically by Name.
+func SortPriority(r []*Result) {
+	sort.Sort(prioritySorter(r))
+}
+
+// prioritySorter sorts results by priority, and subsorts by alpha Name.
+type prioritySorter []*Result
+
+// Len returns the length of this prioritySorter.
+func (s prioritySorter) Len() int { return len(s) }
+
+// Swap performs an in-place swap.
+func (s prioritySorter) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+
+// Less compares a to b, and returns true if a is less than b.
+func (s prioritySorter) Less(a, b int) bool {
+	if p, q := s[a].Priority, s[b].Priority; p != q {
+		return p > q
+	}
+	return s[a].Name < s[b].Name
+}
@@ -1,9 +1,14 @@
 package search
 
 import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/helm/helm-classic/chart"
 	"github.com/helm/helm-classic/config"
 )
 
@@ -112,6 +117,49 @@ func TestSearchNotFound(t *testing.T) {
 	}
 }
 
+func TestSearchByMaintainer(t *testing.T) {
+	i := NewIndex(testConfig, testCacheDir)
+	charts, err := i.Search("gabe@deis.com", MaxScore+1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(charts) != 1 || charts[0].Name != "redis-standalone" || charts[0].Score != MaxScore {
+		t.Errorf("Expected redis-standalone by its maintainer, got %v", charts)
+	}
+	if charts, _ := i.Search("gabe@deis.com", MaxScore, false); len(charts) != 0 {
+		t.Errorf("Expected a lower threshold to leave out the maintainers, got %v", charts)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	c := &chart.Chartfile{Keywords: []string{"Database", "cache"}, Maintainers: []string{"Jane Doe <jane@example.com>"}}
+	tests := []struct {
+		f     Filter
+		match bool
+	}{
+		{Filter{}, true},
+		{Filter{Keywords: []string{"database"}}, true},
+		{Filter{Keywords: []string{"database", "cache"}}, true},
+		{Filter{Keywords: []string{"database", "queue"}}, false},
+		{Filter{Keywords: []string{"data"}}, false},
+		{Filter{Maintainers: []string{"JANE@example"}}, true},
+		{Filter{Maintainers: []string{"john"}}, false},
+		{Filter{Keywords: []string{"cache"}, Maintainers: []string{"doe"}}, true},
+	}
+	for _, tt := range tests {
+		if m := tt.f.Match(c); m != tt.match {
+			t.Errorf("Expected %+v to match %v, got %v", tt.f, tt.match, m)
+		}
+	}
+
+	i := NewIndex(testConfig, testCacheDir)
+	all, _ := i.Search("", MaxScore+1, false)
+	res := i.Filter(all, Filter{Maintainers: []string{"gabe"}})
+	if len(all) < 2 || len(res) != 1 || res[0].Name != "redis-standalone" {
+		t.Errorf("Expected only redis-standalone to be maintained by gabe, got %v of %d", res, len(all))
+	}
+}
+
 func TestCalcScore(t *testing.T) {
 	i := NewIndex(testConfig, testCacheDir)
 
@@ -130,3 +178,137 @@ func TestCalcScore(t *testing.T) {
 		t.Errorf("Expected 3, got %d", r)
 	}
 }
+
+func TestSearchHTTPIndex(t *testing.T) {
+	cachedir, err := ioutil.TempDir("", "helmc-search-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cachedir)
+
+	index := `entries:
+  redis:
+  - {version: 0.1.0, description: old redis, url: redis-0.1.0.tgz}
+  - {version: 0.2.0, description: A Redis key-value store, url: redis-0.2.0.tgz}
+`
+	os.MkdirAll(filepath.Join(cachedir, "stable"), 0755)
+	if err := ioutil.WriteFile(filepath.Join(cachedir, "stable", "index.yaml"), []byte(index), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Configfile{
+		Repos: &config.Repos{
+			Default: "charts",
+			Tables: []*config.Table{
+				{Name: "stable", Repo: "https://example.com/stable/index.yaml", Type: config.TypeHTTP},
+			},
+		},
+	}
+	i := NewIndex(cfg, cachedir)
+	charts, err := i.Search("key-value", 100, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(charts) != 1 || charts[0].Name != "stable/redis" {
+		t.Fatalf("Expected stable/redis, got %v", charts)
+	}
+	if c, _ := i.Chart("stable/redis"); c.Version != "0.2.0" {
+		t.Errorf("Expected latest version 0.2.0, got %s", c.Version)
+	}
+}
+
+func TestSortPriority(t *testing.T) {
+	in := []*Result{
+		{Name: "charts/bbb", Score: 0},
+		{Name: "charts/aaa", Score: 1},
+		{Name: "mine/zzz", Score: 2, Priority: 10},
+		{Name: "mine/yyy", Score: 0, Priority: 10},
+	}
+	expect := []string{"mine/yyy", "mine/zzz", "charts/aaa", "charts/bbb"}
+	SortPriority(in)
+
+	for i, e := range expect {
+		if in[i].Name != e {
+			t.Errorf("Sort error on index %d: expected %s, got %s", i, e, in[i].Name)
+		}
+	}
+}
+
+// benchmarkRepo returns a cache with a Git repository of n charts, and the
+// configuration of its repositories.
+func benchmarkRepo(b *testing.B, n int) (string, *config.Configfile) {
+	cachedir, err := ioutil.TempDir("", "helmc-search-")
+	if err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i < n; i++ {
+		name := fmt.Sprintf("chart%03d", i)
+		dir := filepath.Join(cachedir, "charts", name)
+		os.MkdirAll(filepath.Join(dir, "manifests"), 0755)
+		cf := fmt.Sprintf("name: %s\nversion: 0.1.%d\ndescription: Chart number %d.\nmaintainers:\n- Someone <someone@example.com>\ndetails: |\n  %s\nkeywords: [benchmark, %s]\n",
+			name, i, i, strings.Repeat("A chart for benchmarks. ", 20), name)
+		ioutil.WriteFile(filepath.Join(dir, "Chart.yaml"), []byte(cf), 0644)
+		ioutil.WriteFile(filepath.Join(dir, "manifests", name+".yaml"), []byte("kind: Pod\n"), 0644)
+	}
+	cfg := &config.Configfile{
+		Repos: &config.Repos{
+			Default: "charts",
+			Tables:  []*config.Table{{Name: "charts", Repo: "https://github.com/helm/charts"}},
+		},
+	}
+	return cachedir, cfg
+}
+
+// BenchmarkNewIndex compares reading every Chart.yaml of a repository of 500
+// charts with reading its metadata index.
+func BenchmarkNewIndex(b *testing.B) {
+	cachedir, cfg := benchmarkRepo(b, 500)
+	defer os.RemoveAll(cachedir)
+
+	b.Run("scan", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			NewIndex(cfg, cachedir)
+		}
+	})
+	b.Run("metadata", func(b *testing.B) {
+		cfg.Repos.Dir = cachedir
+		defer func() { cfg.Repos.Dir = "" }()
+		if err := cfg.Repos.Reindex("charts"); err != nil {
+			b.Fatal(err)
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if res := NewIndex(cfg, cachedir).SearchLiteral("chart499", 5); len(res) != 1 {
+				b.Fatalf("Expected 1 result, got %d", len(res))
+			}
+		}
+	})
+}
+
+func TestSearchMetadata(t *testing.T) {
+	cachedir, err := ioutil.TempDir("", "helmc-search-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cachedir)
+	os.MkdirAll(filepath.Join(cachedir, "charts", "db"), 0755)
+	ioutil.WriteFile(filepath.Join(cachedir, "charts", "db", "Chart.yaml"), []byte("name: db\nversion: 0.1.0\ndescription: A database.\nkeywords: [postgres]\n"), 0644)
+
+	cfg := &config.Configfile{
+		Repos: &config.Repos{
+			Dir:     cachedir,
+			Default: "charts",
+			Tables:  []*config.Table{{Name: "charts", Repo: "https://github.com/helm/charts"}},
+		},
+	}
+	charts, err := NewIndex(cfg, cachedir).Search("postgres", 100, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(charts) != 1 || charts[0].Name != "db" {
+		t.Fatalf("Expected db to be found by its keyword, got %v", charts)
+	}
+	if _, err := os.Stat(filepath.Join(cachedir, "charts.index.json")); err != nil {
+		t.Errorf("Expected the metadata index to be written: %s", err)
+	}
+}
@@ -0,0 +1,229 @@
+package stack
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/helm/helm-classic/action"
+	"github.com/helm/helm-classic/history"
+	"github.com/helm/helm-classic/kubectl"
+	"github.com/helm/helm-classic/log"
+	"github.com/helm/helm-classic/util"
+)
+
+// Outcomes of the charts of a stack operation.
+const (
+	// StatusInstalled is a chart that was installed.
+	StatusInstalled = "installed"
+	// StatusUninstalled is a chart that was uninstalled.
+	StatusUninstalled = "uninstalled"
+	// StatusFailed is the chart that stopped the operation.
+	StatusFailed = "failed"
+	// StatusRolledBack is a chart that was installed, and then rolled back,
+	// because a chart after it failed.
+	StatusRolledBack = "rolled back"
+	// StatusNotRun is a chart that the operation stopped before.
+	StatusNotRun = "not run"
+	// StatusSkipped is a chart that the workspace does not have, and that
+	// is not uninstalled.
+	StatusSkipped = "skipped"
+)
+
+// Outcome is what an operation did with a chart of a stack.
+type Outcome struct {
+	Name      string `json:"name" yaml:"name"`
+	Namespace string `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	Status    string `json:"status" yaml:"status"`
+	Error     string `json:"error,omitempty" yaml:"error,omitempty"`
+
+	// result is the outcome of the install of the chart, and previous is
+	// the deployed revision that it had before, or 0.
+	result   *action.InstallResult
+	previous int
+}
+
+// outcomes returns an outcome for each chart of s, which has not run.
+func (s *Stack) outcomes() []*Outcome {
+	outs := make([]*Outcome, len(s.Charts))
+	for i, e := range s.Charts {
+		outs[i] = &Outcome{Name: e.Name, Namespace: s.namespace(e), Status: StatusNotRun}
+	}
+	return outs
+}
+
+// Install installs the stack of a stack file, as Stack.Install does, and
+// prints the outcome of each of its charts.
+//
+// - file is the stack file
+// - home is the home directory for the user
+// - client sends the manifests to Kubernetes
+func Install(file, home string, client kubectl.Runner) error {
+	s, err := Load(file)
+	if err != nil {
+		return err
+	}
+	action.CheckClientPrereqs(client)
+	outs, err := s.Install(newClient(home, client))
+	printOutcomes(outs)
+	if err == nil {
+		log.Info("Installed the %d charts of the stack %s.", len(outs), s.Name)
+	}
+	return err
+}
+
+// Install installs the charts of s, in order.
+//
+// The charts that the workspace does not have are fetched first, so that a
+// chart that cannot be found stops the install before anything is sent to
+// Kubernetes. A chart in the workspace is installed as it is, even if it
+// differs from what its entry is fetched from.
+//
+// Each chart is then installed, with apply, so that installing a stack again
+// brings each chart up to date. An install is atomic: if one fails, the
+// resources that it created are deleted, and the charts before it are rolled
+// back, newest first. A chart that had a deployed revision is rolled back to
+// it, as by Client.Rollback; the resources that the install of another chart
+// created are deleted. The error returned is that of the chart that failed.
+//
+// It returns the outcome for each chart, even if the install fails.
+func (s *Stack) Install(c *action.Client) ([]*Outcome, error) {
+	outs := s.outcomes()
+	for _, e := range s.Charts {
+		if _, err := os.Stat(util.WorkspaceChartDirectory(c.Home, e.Name, action.Chartfile)); err == nil {
+			continue
+		}
+		if _, err := c.Fetch(e.Chart, e.Name, action.FetchOptions{}); err != nil {
+			return outs, fmt.Errorf("Could not fetch %s, so nothing of the stack %s was installed: %w", e.Name, s.Name, err)
+		}
+	}
+
+	for i, e := range s.Charts {
+		o := outs[i]
+		revs, err := c.History(e.Name)
+		if err != nil {
+			o.Status, o.Error = StatusFailed, err.Error()
+			s.undo(c, outs[:i])
+			return outs, err
+		}
+		for j := len(revs) - 1; j >= 0 && o.previous == 0; j-- {
+			if revs[j].Status == history.Deployed {
+				o.previous = revs[j].Revision
+			}
+		}
+
+		c.Log.Info("Installing %s, chart %d of %d of the stack %s ...", e.Name, i+1, len(s.Charts), s.Name)
+		o.result, err = c.Install(e.Name, s.installOptions(e))
+		if err != nil {
+			o.Status, o.Error = StatusFailed, err.Error()
+			s.undo(c, outs[:i+1])
+			return outs, fmt.Errorf("Failed to install %s of the stack %s, so the charts before it were rolled back: %w", e.Name, s.Name, err)
+		}
+		o.Status = StatusInstalled
+	}
+	return outs, nil
+}
+
+// undo rolls back the installs of outs, newest first. One that cannot be
+// rolled back is logged, and keeps its status.
+func (s *Stack) undo(c *action.Client, outs []*Outcome) {
+	for i := len(outs) - 1; i >= 0; i-- {
+		o := outs[i]
+		if o.result == nil {
+			continue
+		}
+		var err error
+		if o.previous > 0 {
+			c.Log.Info("Rolling %s back to revision %d ...", o.Name, o.previous)
+			_, err = c.Rollback(o.Name, o.previous)
+		} else {
+			err = deleteCreated(c, o.result)
+		}
+		if err != nil {
+			c.Log.Err("Could not roll back %s: %s", o.Name, err)
+			continue
+		}
+		if o.Status == StatusInstalled {
+			o.Status = StatusRolledBack
+		}
+	}
+}
+
+// deleteCreated deletes the resources that an install created, newest
+// first. Those that it configured are left as they are, since their earlier
+// state is not known.
+func deleteCreated(c *action.Client, res *action.InstallResult) error {
+	failed := 0
+	for i := len(res.Resources) - 1; i >= 0; i-- {
+		rr := res.Resources[i]
+		switch rr.Status {
+		case action.StatusCreated:
+			if out, err := c.Kube.Delete(rr.Name, rr.Kind, rr.Namespace); err != nil {
+				c.Log.Err("Could not delete %s %s: %s", rr.Kind, rr.Name, or(strings.TrimSpace(string(out)), err.Error()))
+				failed++
+				continue
+			}
+			rr.Status = action.StatusRolledBack
+		case action.StatusConfigured:
+			c.Log.Warn("%s %s was changed, and cannot be restored.", rr.Kind, rr.Name)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d resources could not be deleted", failed)
+	}
+	return nil
+}
+
+// Uninstall uninstalls the stack of a stack file, as Stack.Uninstall does,
+// and prints the outcome of each of its charts. Unless o.Yes or o.Force is
+// set, the user is asked to confirm first.
+func Uninstall(file, home string, o action.UninstallOptions, client kubectl.Runner) error {
+	s, err := Load(file)
+	if err != nil {
+		return err
+	}
+	names := make([]string, len(s.Charts))
+	for i, e := range s.Charts {
+		names[len(names)-1-i] = e.Name
+	}
+	if !o.Yes && !o.Force && !action.Confirm("Uninstall the %d charts of the stack %s: %s?", len(names), s.Name, strings.Join(names, ", ")) {
+		log.Info("Aborted uninstall")
+		return nil
+	}
+	action.CheckClientPrereqs(client)
+	outs, err := s.Uninstall(newClient(home, client), o)
+	printOutcomes(outs)
+	return err
+}
+
+// Uninstall uninstalls the charts of s, in reverse order, as Client.Uninstall
+// does with o, so that a chart goes before those it was installed after. The
+// charts that the workspace does not have are skipped. The first chart that
+// fails stops the uninstall, and the charts before it are left installed.
+//
+// It returns the outcome for each chart, even if the uninstall fails.
+func (s *Stack) Uninstall(c *action.Client, o action.UninstallOptions) ([]*Outcome, error) {
+	outs := s.outcomes()
+	for i := len(s.Charts) - 1; i >= 0; i-- {
+		e, out := s.Charts[i], outs[i]
+		if _, err := os.Stat(util.WorkspaceChartDirectory(c.Home, e.Name, action.Chartfile)); err != nil {
+			c.Log.Info("No chart named %s in your workspace. Skipping it.", e.Name)
+			out.Status = StatusSkipped
+			continue
+		}
+		c.Log.Info("Uninstalling %s, chart %d of %d of the stack %s ...", e.Name, i+1, len(s.Charts), s.Name)
+		if err := c.Uninstall(e.Name, out.Namespace, o); err != nil {
+			out.Status, out.Error = StatusFailed, err.Error()
+			return outs, fmt.Errorf("Failed to uninstall %s of the stack %s, so the charts before it were left installed: %w", e.Name, s.Name, err)
+		}
+		out.Status = StatusUninstalled
+	}
+	return outs, nil
+}
+
+func or(a, b string) string {
+	if a != "" {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,141 @@
+package stack
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/helm/helm-classic/action"
+	"github.com/helm/helm-classic/kubectl"
+	"github.com/helm/helm-classic/test"
+)
+
+// failingRunner is a FakeRunner whose applies into the namespace fail fail.
+type failingRunner struct {
+	kubectl.FakeRunner
+	fail string
+}
+
+func (r *failingRunner) Apply(stdin []byte, ns string) ([]byte, error) {
+	out, err := r.FakeRunner.Apply(stdin, ns)
+	if ns == r.fail {
+		return []byte("Error from server (Forbidden): pods is forbidden"), errors.New("exit status 1")
+	}
+	return out, err
+}
+
+// changes returns the calls of r that change Kubernetes, in order.
+func (r *failingRunner) changes() []string {
+	calls := []string{}
+	for _, c := range r.Calls {
+		if strings.HasPrefix(c, "apply ") || strings.HasPrefix(c, "delete ") {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+func statuses(outs []*Outcome) []string {
+	res := []string{}
+	for _, o := range outs {
+		res = append(res, o.Name+" "+o.Status)
+	}
+	return res
+}
+
+func TestInstall(t *testing.T) {
+	home := test.CreateTmpHome()
+	test.FakeUpdate(home)
+	s, err := Load(writeStack(t, `charts:
+- name: db
+  chart: redis
+  namespace: data
+- name: web
+  chart: redis
+  namespace: front
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kube := &failingRunner{FakeRunner: kubectl.FakeRunner{Out: []byte(`pod "redis" created`)}, fail: "front"}
+	c := &action.Client{Home: home, Kube: kube}
+	outs, err := s.Install(c)
+	if err == nil || !strings.Contains(err.Error(), "Failed to install web of the stack shop, so the charts before it were rolled back") {
+		t.Fatalf("Expected web to fail, got %v", err)
+	}
+	if got, want := statuses(outs), []string{"db rolled back", "web failed"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+	// db was new, so the Pod that it created is deleted.
+	if got, want := kube.changes(), []string{"apply data", "apply front", "delete Pod redis data"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	kube = &failingRunner{FakeRunner: kubectl.FakeRunner{Out: []byte(`pod "redis" created`)}}
+	c.Kube = kube
+	if outs, err = s.Install(c); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := statuses(outs), []string{"db installed", "web installed"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	// db has a deployed revision now, so it is rolled back to it.
+	kube = &failingRunner{FakeRunner: kubectl.FakeRunner{Out: []byte(`pod "redis" configured`)}, fail: "front"}
+	c.Kube = kube
+	outs, _ = s.Install(c)
+	if got, want := statuses(outs), []string{"db rolled back", "web failed"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+	revs, err := c.History("db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(revs); n != 4 || revs[n-1].Description != "rollback to 2" {
+		t.Errorf("Expected db to be rolled back to revision 2, got %d revisions", n)
+	}
+	// web had a deployed revision too, but its rollback fails as well.
+	if got, want := kube.changes(), []string{"apply data", "apply front", "apply front", "apply data"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestUninstall(t *testing.T) {
+	home := test.CreateTmpHome()
+	test.FakeUpdate(home)
+	s, err := Load(writeStack(t, `namespace: shop
+charts:
+- name: db
+  chart: redis
+- name: web
+  chart: redis
+  namespace: front
+- name: absent
+  chart: redis
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &action.Client{Home: home}
+	for _, name := range []string{"db", "web"} {
+		if _, err := c.Fetch("redis", name, action.FetchOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	kube := &failingRunner{}
+	c.Kube = kube
+	outs, err := s.Uninstall(c, action.UninstallOptions{Yes: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := statuses(outs), []string{"db uninstalled", "web uninstalled", "absent skipped"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+	// The charts go in reverse order.
+	if got, want := kube.changes(), []string{"delete Pod redis front", "delete Pod redis shop"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,170 @@
+// Package stack installs a set of charts, a stack, as one.
+//
+// A stack file lists the charts of the stack in the order they are installed,
+// each with its values:
+//
+//	name: shop
+//	namespace: shop
+//	charts:
+//	- name: db
+//	  chart: postgres
+//	  values: [db-values.yaml]
+//	  wait: 2m
+//	- name: web
+//	  chart: charts/nginx
+//	  set: [replicas=2]
+//	  checksum: sha256:5c2c...
+//
+// Each chart is installed as 'helmc install --mode apply --atomic' installs
+// it, under its name in the workspace. If one of them fails, those installed
+// before it are rolled back, so that the stack is either installed as a whole
+// or left as it was.
+package stack
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/helm/helm-classic/action"
+	"github.com/helm/helm-classic/kubectl"
+	"github.com/helm/helm-classic/log"
+	"github.com/helm/helm-classic/output"
+)
+
+// Stack is the set of charts of a stack file.
+type Stack struct {
+	// Name names the stack in messages. It is the base name of the file if
+	// the file does not have one.
+	Name string `yaml:"name,omitempty"`
+	// Namespace is the namespace of the charts that do not have one.
+	Namespace string `yaml:"namespace,omitempty"`
+	// Charts are installed in order, and uninstalled in reverse.
+	Charts []*Entry `yaml:"charts"`
+}
+
+// Entry is a chart of a stack.
+type Entry struct {
+	// Name is the name of the chart in the workspace. It is the last element
+	// of Chart if it is not given.
+	Name string `yaml:"name,omitempty"`
+	// Chart is what the chart is fetched from, if the workspace does not
+	// have it: a chart of a repository, or a chart archive (.tgz), as
+	// 'helmc fetch' takes it.
+	Chart string `yaml:"chart"`
+	// Namespace is the namespace that the chart is installed into. It is
+	// that of the stack, or else that of the chart's Chart.yaml, if it is
+	// not given.
+	Namespace string `yaml:"namespace,omitempty"`
+	// Values, Set, SetFrom, and Env are the values of the chart, as the
+	// flags of 'helmc install' give them. Values files are relative to the
+	// stack file.
+	Values  []string `yaml:"values,omitempty"`
+	Set     []string `yaml:"set,omitempty"`
+	SetFrom []string `yaml:"setFrom,omitempty"`
+	Env     string   `yaml:"env,omitempty"`
+	// Generate runs the chart's generators before it is installed.
+	Generate bool `yaml:"generate,omitempty"`
+	// Checksum, if it is set, is the checksum that the chart must have, as
+	// 'helmc fetch --print-checksum' prints it. See chart.Checksum.
+	Checksum string `yaml:"checksum,omitempty"`
+	// Wait, if it is set, is how long the chart's workloads have to be
+	// ready before the next chart is installed.
+	Wait time.Duration `yaml:"wait,omitempty"`
+}
+
+// Load reads the stack file, and checks it.
+func Load(file string) (*Stack, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	s := &Stack{}
+	if err := yaml.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("Could not parse the stack file %s: %s", file, err)
+	}
+	if s.Name == "" {
+		s.Name = strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	}
+	if err := s.check(filepath.Dir(file)); err != nil {
+		return nil, fmt.Errorf("The stack file %s is not valid: %s", file, err)
+	}
+	return s, nil
+}
+
+// check fills in the names of the entries, and makes their values files
+// relative to dir, the directory of the stack file. It returns an error for
+// an entry without a chart, and for names that are not unique.
+func (s *Stack) check(dir string) error {
+	if len(s.Charts) == 0 {
+		return fmt.Errorf("it has no charts")
+	}
+	names := map[string]bool{}
+	for i, e := range s.Charts {
+		if e == nil || e.Chart == "" {
+			return fmt.Errorf("chart %d has no 'chart'", i+1)
+		}
+		if e.Name == "" {
+			if strings.HasSuffix(e.Chart, ".tgz") {
+				return fmt.Errorf("chart %d is the archive %s, and has no 'name'", i+1, e.Chart)
+			}
+			e.Name = path.Base(e.Chart)
+		}
+		if names[e.Name] {
+			return fmt.Errorf("two charts are named %s. Give one of them another 'name'", e.Name)
+		}
+		names[e.Name] = true
+		for j, f := range e.Values {
+			if !filepath.IsAbs(f) {
+				e.Values[j] = filepath.Join(dir, f)
+			}
+		}
+	}
+	return nil
+}
+
+// namespace returns the namespace of e, or that of the stack. It is empty if
+// neither has one.
+func (s *Stack) namespace(e *Entry) string {
+	if e.Namespace != "" {
+		return e.Namespace
+	}
+	return s.Namespace
+}
+
+// installOptions returns the options that e is installed with.
+func (s *Stack) installOptions(e *Entry) action.InstallOptions {
+	return action.InstallOptions{
+		Namespace: s.namespace(e),
+		Generate:  e.Generate,
+		Mode:      action.ModeApply,
+		Atomic:    true,
+		Annotate:  true,
+		Labels:    true,
+		Preflight: true,
+		Values:    action.ValueSources{Files: e.Values, Set: e.Set, SetFrom: e.SetFrom, Env: e.Env},
+		Checksum:  e.Checksum,
+		Wait:      e.Wait,
+	}
+}
+
+// newClient returns a client with the default settings of the package-level
+// functions of action, for those of stack.
+func newClient(home string, kube kubectl.Runner) *action.Client {
+	return &action.Client{Settings: action.Defaults, Home: home, Kube: kube, Events: action.LogEvents(nil)}
+}
+
+// printOutcomes prints the outcome of each chart of a stack operation, as a
+// table.
+func printOutcomes(outs []*Outcome) {
+	t := &output.Table{Header: []string{"CHART", "NAMESPACE", "STATUS", "ERROR"}, Flex: 3}
+	for _, o := range outs {
+		t.Add(o.Name, or(o.Namespace, "-"), o.Status, or(o.Error, "-"))
+	}
+	output.New(log.Stdout).Table(t)
+}
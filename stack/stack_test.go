@@ -0,0 +1,84 @@
+package stack
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/helm/helm-classic/action"
+	"github.com/helm/helm-classic/log"
+)
+
+func init() {
+	// Turn on debug output, convert os.Exit(1) to panic()
+	log.IsDebugging = true
+	// Never download Kubernetes schemas.
+	action.KubeSchemaURL = ""
+}
+
+// writeStack writes a stack file into a temporary directory, and returns
+// its path.
+func writeStack(t *testing.T, data string) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "helmc-stack")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	file := filepath.Join(dir, "shop.yaml")
+	if err := ioutil.WriteFile(file, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestLoad(t *testing.T) {
+	file := writeStack(t, `namespace: shop
+charts:
+- chart: charts/redis
+  values: [redis.yaml, /etc/redis.yaml]
+  wait: 2m
+  checksum: sha256:abc
+- name: web
+  chart: nginx
+  namespace: front
+`)
+	s, err := Load(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Name != "shop" {
+		t.Errorf("Expected the stack to be named after its file, got %q", s.Name)
+	}
+	db, web := s.Charts[0], s.Charts[1]
+	if db.Name != "redis" || web.Name != "web" {
+		t.Errorf("Expected the charts redis and web, got %s and %s", db.Name, web.Name)
+	}
+	if want := filepath.Join(filepath.Dir(file), "redis.yaml"); db.Values[0] != want || db.Values[1] != "/etc/redis.yaml" {
+		t.Errorf("Expected the values files to be relative to the stack file, got %v", db.Values)
+	}
+	o := s.installOptions(db)
+	if o.Namespace != "shop" || o.Wait != 2*time.Minute || o.Checksum != "sha256:abc" || o.Mode != action.ModeApply || !o.Atomic {
+		t.Errorf("Unexpected install options of redis: %+v", o)
+	}
+	if ns := s.namespace(web); ns != "front" {
+		t.Errorf("Expected web to be installed into front, got %q", ns)
+	}
+
+	for _, tt := range []struct {
+		data, msg string
+	}{
+		{"charts: []", "it has no charts"},
+		{"charts:\n- name: db", "chart 1 has no 'chart'"},
+		{"charts:\n- chart: ./redis-0.1.0.tgz", "chart 1 is the archive ./redis-0.1.0.tgz, and has no 'name'"},
+		{"charts:\n- chart: redis\n- chart: other/redis", "two charts are named redis"},
+		{"charts: {", "Could not parse the stack file"},
+	} {
+		if _, err := Load(writeStack(t, tt.data)); err == nil || !strings.Contains(err.Error(), tt.msg) {
+			t.Errorf("Expected %q for %q, got %v", tt.msg, tt.data, err)
+		}
+	}
+}
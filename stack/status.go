@@ -0,0 +1,144 @@
+package stack
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/helm/helm-classic/action"
+	"github.com/helm/helm-classic/history"
+	"github.com/helm/helm-classic/kubectl"
+	"github.com/helm/helm-classic/log"
+	"github.com/helm/helm-classic/output"
+	"github.com/helm/helm-classic/util"
+)
+
+// States of the charts of a stack, as Status reports them.
+const (
+	// StateReady is a chart whose resources are all present, ready, and
+	// running the workspace chart.
+	StateReady = "ready"
+	// StateNotReady is a chart with resources that are missing or not ready.
+	StateNotReady = "not ready"
+	// StateDrifted is a chart with resources that are not running the
+	// workspace chart.
+	StateDrifted = "drifted"
+	// StateFailed is a chart whose latest revision failed.
+	StateFailed = "failed"
+	// StateNotInstalled is a chart that was never installed.
+	StateNotInstalled = "not installed"
+	// StateNotFetched is a chart that the workspace does not have.
+	StateNotFetched = "not fetched"
+)
+
+// ChartStatus is the status of a chart of a stack.
+type ChartStatus struct {
+	Name string `json:"name" yaml:"name"`
+	// Revision is the latest revision of the release of the chart, or 0 if
+	// it was never installed.
+	Revision int `json:"revision,omitempty" yaml:"revision,omitempty"`
+	// State is one of the State constants.
+	State string `json:"state" yaml:"state"`
+	// Status is how the resources of the chart in Kubernetes compare with
+	// it. It is nil if the chart was never installed.
+	Status *action.ChartStatus `json:"status,omitempty" yaml:"status,omitempty"`
+}
+
+// stateColors are the colors of the chart states on a terminal.
+var stateColors = map[string]output.Color{
+	StateReady:    output.Green,
+	StateNotReady: output.Yellow,
+	StateDrifted:  output.Yellow,
+	StateFailed:   output.Red,
+}
+
+// Status prints the status of each chart of the stack of a stack file, as
+// Stack.Status returns it, as a table, or as JSON or YAML if format is "json"
+// or "yaml".
+func Status(file, home, format string, client kubectl.Runner) error {
+	s, err := Load(file)
+	if err != nil {
+		return err
+	}
+	action.CheckClientPrereqs(client)
+	sts, err := s.Status(newClient(home, client))
+	if err != nil {
+		return err
+	}
+	if format != "" {
+		return output.Print(log.Stdout, sts, format)
+	}
+
+	t := &output.Table{
+		Header: []string{"CHART", "VERSION", "NAMESPACE", "REVISION", "READY", "STATE"},
+		Flex:   4,
+		Colors: func(row []string, col int) output.Color {
+			if col == 5 {
+				return stateColors[row[col]]
+			}
+			return output.None
+		},
+	}
+	ready := 0
+	for _, st := range sts {
+		version, ns, rev, rollup := "-", "-", "-", "-"
+		if st.Status != nil {
+			version, ns, rollup = or(st.Status.Version, "-"), or(st.Status.Namespace, "-"), or(st.Status.Ready, "-")
+		}
+		if st.Revision > 0 {
+			rev = fmt.Sprint(st.Revision)
+		}
+		if st.State == StateReady {
+			ready++
+		}
+		t.Add(st.Name, version, ns, rev, rollup, st.State)
+	}
+	output.New(log.Stdout).Table(t)
+	if ready < len(sts) {
+		log.Warn("%d of the %d charts of the stack %s are not ready.", len(sts)-ready, len(sts), s.Name)
+	}
+	return nil
+}
+
+// Status returns the status of each chart of s, in order.
+//
+// The resources of a chart are looked for in the namespace of its entry, or
+// else in that of its latest revision, as Client.Status looks for them.
+func (s *Stack) Status(c *action.Client) ([]*ChartStatus, error) {
+	sts := []*ChartStatus{}
+	for _, e := range s.Charts {
+		st := &ChartStatus{Name: e.Name}
+		sts = append(sts, st)
+		if _, err := os.Stat(util.WorkspaceChartDirectory(c.Home, e.Name, action.Chartfile)); err != nil {
+			st.State = StateNotFetched
+			continue
+		}
+		revs, err := c.History(e.Name)
+		if err != nil {
+			return sts, err
+		}
+		if len(revs) == 0 {
+			st.State = StateNotInstalled
+			continue
+		}
+		latest := revs[len(revs)-1]
+		st.Revision = latest.Revision
+		ns := s.namespace(e)
+		if ns == "" {
+			ns = latest.Namespace
+		}
+		if st.Status, err = c.Status(e.Name, ns); err != nil {
+			return sts, err
+		}
+		switch {
+		case latest.Status == history.Failed:
+			st.State = StateFailed
+		case st.Status.NotReady > 0:
+			st.State = StateNotReady
+		case st.Status.Drifted > 0:
+			st.State = StateDrifted
+		default:
+			st.State = StateReady
+		}
+	}
+	return sts, nil
+}
@@ -0,0 +1,53 @@
+package stack
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/helm/helm-classic/action"
+	"github.com/helm/helm-classic/kubectl"
+	"github.com/helm/helm-classic/test"
+)
+
+func TestStatus(t *testing.T) {
+	home := test.CreateTmpHome()
+	test.FakeUpdate(home)
+	s, err := Load(writeStack(t, `namespace: shop
+charts:
+- name: db
+  chart: redis
+- name: web
+  chart: redis
+- name: absent
+  chart: redis
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The Pod is ready, but has no chart annotations, which makes it drifted.
+	pod := `{"kind":"Pod","metadata":{"name":"redis"},"status":{"phase":"Running","containerStatuses":[{"ready":true}]}}`
+	c := &action.Client{Home: home, Kube: &kubectl.FakeRunner{Out: []byte(pod)}}
+	for _, name := range []string{"db", "web"} {
+		if _, err := c.Fetch("redis", name, action.FetchOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := c.Install("db", s.installOptions(s.Charts[0])); err != nil {
+		t.Fatal(err)
+	}
+
+	sts, err := s.Status(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	states := []string{}
+	for _, st := range sts {
+		states = append(states, st.Name+" "+st.State)
+	}
+	if want := []string{"db drifted", "web not installed", "absent not fetched"}; !reflect.DeepEqual(states, want) {
+		t.Errorf("Expected %v, got %v", want, states)
+	}
+	if db := sts[0]; db.Revision != 1 || db.Status.Namespace != "shop" || db.Status.Resources != 1 || db.Status.NotReady != 0 {
+		t.Errorf("Unexpected status of db: revision %d, %+v", db.Revision, db.Status)
+	}
+}
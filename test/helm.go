@@ -1,6 +1,9 @@
 package test
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -11,9 +14,13 @@ import (
 
 	"github.com/helm/helm-classic/log"
 	"github.com/helm/helm-classic/util"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	"golang.org/x/crypto/openpgp/clearsign"
 )
 
-const tmpConfigfile = `repos:
+const tmpConfigfile = `apiVersion: v1
+repos:
   default: charts
   tables:
     - name: charts
@@ -21,7 +28,7 @@ const tmpConfigfile = `repos:
 `
 
 // HelmRoot - dir root of the project
-var HelmRoot = filepath.Join(os.Getenv("GOPATH"), "src/github.com/helm/helm-classic/")
+var HelmRoot = filepath.Join(os.Getenv("GOPATH"), "src", "github.com", "helm", "helm-classic")
 
 // CreateTmpHome create a temporary directory for $HELMC_HOME
 func CreateTmpHome() string {
@@ -37,11 +44,11 @@ func FakeUpdate(home string) {
 	ioutil.WriteFile(filepath.Join(home, util.Configfile), []byte(tmpConfigfile), 0755)
 
 	// absolute path to testdata charts
-	testChartsPath := filepath.Join(HelmRoot, "testdata/charts")
+	testChartsPath := filepath.Join(HelmRoot, "testdata", "charts")
 
 	// copy testdata charts into cache
 	// mock git clone
-	util.CopyDir(testChartsPath, filepath.Join(home, "cache/charts"))
+	util.CopyDir(testChartsPath, filepath.Join(home, "cache", "charts"))
 }
 
 // ExpectEquals assert a == b
@@ -100,3 +107,77 @@ func CaptureOutput(fn func()) (out string) {
 	out = string(b)
 	return
 }
+
+// ChartArchive builds a gzipped tar archive of a chart for use in HTTP repository tests.
+//
+// files maps paths within the chart to their contents. Each path is placed
+// under a top-level directory named after the chart.
+func ChartArchive(name string, files map[string]string) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for p, body := range files {
+		tw.WriteHeader(&tar.Header{Name: name + "/" + p, Mode: 0644, Size: int64(len(body))})
+		tw.Write([]byte(body))
+	}
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+// SigningKey generates an OpenPGP key for signing test repository indices.
+func SigningKey(name string) *openpgp.Entity {
+	e, err := openpgp.NewEntity(name, "test", name+"@example.com", nil)
+	if err != nil {
+		panic(err)
+	}
+	return e
+}
+
+// WriteKeyring writes the public halves of the keys to an armored keyring file.
+func WriteKeyring(filename string, keys ...*openpgp.Entity) {
+	var buf bytes.Buffer
+	w, _ := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+	for _, k := range keys {
+		k.Serialize(w)
+	}
+	w.Close()
+	if err := ioutil.WriteFile(filename, buf.Bytes(), 0644); err != nil {
+		panic(err)
+	}
+}
+
+// WriteSecretKeyring writes the keys, with their private halves, to an
+// armored keyring file.
+func WriteSecretKeyring(filename string, keys ...*openpgp.Entity) {
+	var buf bytes.Buffer
+	w, _ := armor.Encode(&buf, openpgp.PrivateKeyType, nil)
+	for _, k := range keys {
+		k.SerializePrivate(w, nil)
+	}
+	w.Close()
+	if err := ioutil.WriteFile(filename, buf.Bytes(), 0600); err != nil {
+		panic(err)
+	}
+}
+
+// DetachSign returns an armored detached signature of data.
+func DetachSign(key *openpgp.Entity, data []byte) []byte {
+	var buf bytes.Buffer
+	if err := openpgp.ArmoredDetachSign(&buf, key, bytes.NewReader(data), nil); err != nil {
+		panic(err)
+	}
+	return buf.Bytes()
+}
+
+// Clearsign returns data as a clearsigned message.
+func Clearsign(key *openpgp.Entity, data []byte) []byte {
+	var buf bytes.Buffer
+	w, err := clearsign.Encode(&buf, key.PrivateKey, nil)
+	if err != nil {
+		panic(err)
+	}
+	w.Write(data)
+	w.Close()
+	return buf.Bytes()
+}
@@ -0,0 +1,3 @@
+#!/bin/bash
+# helm:generate echo foo bar baz
+echo "This script is also a generator."
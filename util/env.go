@@ -0,0 +1,95 @@
+package util
+
+import (
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/helm/helm-classic/helmpath"
+	"github.com/helm/helm-classic/log"
+	"github.com/helm/helm-classic/version"
+)
+
+// The environment variables that every plugin and generator receives.
+const (
+	EnvHome      = helmpath.LegacyEnvVar
+	EnvConfig    = "HELM_CONFIG"
+	EnvCache     = "HELM_CACHE"
+	EnvWorkspace = "HELM_WORKSPACE"
+	EnvDebug     = "HELM_DEBUG"
+	EnvVersion   = "HELM_VERSION"
+)
+
+// The environment variables that describe the chart a plugin or generator
+// works on. They are only set when there is a chart.
+const (
+	EnvChartName    = "HELM_CHART_NAME"
+	EnvChartVersion = "HELM_CHART_VERSION"
+	EnvChartPath    = "HELM_CHART_PATH"
+)
+
+// EnvChart identifies the chart that a plugin or generator works on.
+type EnvChart struct {
+	Name, Version, Path string
+}
+
+// HelmEnv returns the variables that Helm Classic exports to plugins and
+// generators. chart may be nil.
+//
+// $HELMC_HOME is set along with $HELM_HOME, and the variables of
+// helmpath.Home.Layout along with the others, so that a helmc run by a plugin
+// or generator uses the same home, laid out the same way.
+func HelmEnv(home helmpath.Home, chart *EnvChart) map[string]string {
+	l := home.Layout()
+	vars := map[string]string{
+		EnvHome:                  home.String(),
+		helmpath.EnvVar:          home.String(),
+		EnvConfig:                l.Config,
+		helmpath.ConfigEnvVar:    l.Config,
+		EnvCache:                 l.Cache,
+		helmpath.CacheEnvVar:     l.Cache,
+		EnvWorkspace:             l.Workspace,
+		helmpath.WorkspaceEnvVar: l.Workspace,
+		EnvDebug:                 strconv.FormatBool(log.IsDebugging),
+		EnvVersion:               version.Version,
+	}
+	if chart != nil {
+		vars[EnvChartName] = chart.Name
+		vars[EnvChartVersion] = chart.Version
+		vars[EnvChartPath] = chart.Path
+	}
+	return vars
+}
+
+// Environ returns the environment for a child process: the environment of
+// this process, with vars added. Variables in vars replace those of the
+// same name. The environment of this process is not changed.
+func Environ(vars map[string]string) []string {
+	env := []string{}
+	for _, kv := range os.Environ() {
+		if _, ok := vars[strings.SplitN(kv, "=", 2)[0]]; !ok {
+			env = append(env, kv)
+		}
+	}
+	names := make([]string, 0, len(vars))
+	for k := range vars {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		env = append(env, k+"="+vars[k])
+	}
+	return env
+}
+
+// Expand replaces $var and ${var} in s, using vars before the environment
+// of this process.
+func Expand(s string, vars map[string]string) string {
+	return os.Expand(s, func(k string) string {
+		if v, ok := vars[k]; ok {
+			return v
+		}
+		return os.Getenv(k)
+	})
+}
@@ -0,0 +1,66 @@
+package util
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/helm/helm-classic/helmpath"
+	"github.com/helm/helm-classic/version"
+)
+
+func TestHelmEnv(t *testing.T) {
+	defer func(v string) { version.Version = v }(version.Version)
+	version.Version = "1.2.3"
+
+	vars := HelmEnv(helmpath.Home("/tmp/home"), nil)
+	expect := map[string]string{
+		"HELM_HOME":      "/tmp/home",
+		"HELMC_HOME":     "/tmp/home",
+		"HELM_CONFIG":    "/tmp/home/config.yaml",
+		"HELM_CACHE":     "/tmp/home/cache",
+		"HELM_WORKSPACE": "/tmp/home/workspace",
+		"HELM_DEBUG":     "false",
+		"HELM_VERSION":   "1.2.3",
+	}
+	for k, v := range expect {
+		if vars[k] != v {
+			t.Errorf("Expected %s=%q, got %q", k, v, vars[k])
+		}
+	}
+	if _, ok := vars[EnvChartName]; ok {
+		t.Errorf("Expected no chart variables without a chart")
+	}
+
+	vars = HelmEnv(helmpath.Home("/tmp/home"), &EnvChart{Name: "redis", Version: "0.1.0", Path: "/tmp/home/workspace/charts/redis"})
+	if vars[EnvChartName] != "redis" || vars[EnvChartVersion] != "0.1.0" || vars[EnvChartPath] != "/tmp/home/workspace/charts/redis" {
+		t.Errorf("Expected the chart variables, got %v", vars)
+	}
+}
+
+func TestEnviron(t *testing.T) {
+	defer os.Setenv("HELM_TEST_VAR", os.Getenv("HELM_TEST_VAR"))
+	os.Setenv("HELM_TEST_VAR", "parent")
+
+	env := Environ(map[string]string{"HELM_TEST_VAR": "child", "HELM_TEST_NEW": "new"})
+	joined := "\n" + strings.Join(env, "\n") + "\n"
+	if !strings.Contains(joined, "\nHELM_TEST_VAR=child\n") || strings.Contains(joined, "HELM_TEST_VAR=parent") {
+		t.Errorf("Expected HELM_TEST_VAR to be replaced, got %v", env)
+	}
+	if !strings.Contains(joined, "\nHELM_TEST_NEW=new\n") {
+		t.Errorf("Expected HELM_TEST_NEW to be added, got %v", env)
+	}
+	if os.Getenv("HELM_TEST_VAR") != "parent" || os.Getenv("HELM_TEST_NEW") != "" {
+		t.Error("Expected the parent environment to be unchanged")
+	}
+}
+
+func TestExpand(t *testing.T) {
+	defer os.Setenv("HELM_TEST_VAR", os.Getenv("HELM_TEST_VAR"))
+	os.Setenv("HELM_TEST_VAR", "parent")
+
+	out := Expand("$HELM_CHART_NAME ${HELM_TEST_VAR}", map[string]string{EnvChartName: "redis"})
+	if out != "redis parent" {
+		t.Errorf("Expected 'redis parent', got %q", out)
+	}
+}
@@ -0,0 +1,261 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/helm/helm-classic/log"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// TailLines is the number of stderr lines that a failed command reports.
+const TailLines = 20
+
+// ExecError is returned when an external command fails.
+//
+// It carries the last lines the command wrote to stderr, so that the failure
+// can be diagnosed from the log alone.
+type ExecError struct {
+	Prefix string
+	Err    error
+	Tail   []string
+}
+
+func (e *ExecError) Error() string {
+	what := "failed: " + e.Err.Error()
+	switch {
+	case errors.Is(e.Err, context.DeadlineExceeded):
+		what = "timed out"
+	case errors.Is(e.Err, context.Canceled):
+		what = "was stopped"
+	}
+	if len(e.Tail) == 0 {
+		return fmt.Sprintf("%s %s", e.Prefix, what)
+	}
+	return fmt.Sprintf("%s %s\n%s", e.Prefix, what, strings.Join(e.Tail, "\n"))
+}
+
+// Unwrap returns the error from running the command.
+func (e *ExecError) Unwrap() error {
+	return e.Err
+}
+
+// Stderr is a line-buffered writer for the stderr of an external command.
+//
+// Each line is logged with the prefix: warnings go to log.Warn, everything
+// else to log.Debug, or to log.Info if Verbose is set. A carriage return
+// ends a line, so that progress reports are logged as they are made. Secrets
+// are removed with Redact. The last TailLines lines are kept.
+//
+// Lines are logged to Log, or with the package-level log functions if it is nil.
+//
+// If Progress is set, and ProgressMode is not ProgressOff, the progress
+// reports of the command, such as git's "Receiving objects: 45%", are shown
+// with a Progress instead of being logged one by one.
+//
+// If Raw is set, each line is written to it as it is, without the prefix,
+// instead of being logged. Secrets are still removed.
+type Stderr struct {
+	Prefix   string
+	Verbose  bool
+	Log      *log.Logger
+	Progress bool
+	Raw      io.Writer
+
+	mu       sync.Mutex
+	partial  []byte
+	tail     []string
+	progress *Progress
+}
+
+// progressRe matches a progress report, e.g. "Receiving objects:  45% (9/20)"
+// or "remote: Counting objects: 20, done.".
+var progressRe = regexp.MustCompile(`^(remote: )?[A-Za-z][A-Za-z ]*: +\d+(%|,|\s|$)`)
+
+// Write logs each complete line in p, and buffers the rest.
+func (s *Stderr) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.partial = append(s.partial, p...)
+	for {
+		i := bytes.IndexAny(s.partial, "\r\n")
+		if i < 0 {
+			break
+		}
+		s.line(string(s.partial[:i]), s.partial[i] == '\r')
+		s.partial = s.partial[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush logs a final line that did not end with a newline.
+func (s *Stderr) Flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.partial) > 0 {
+		s.line(string(s.partial), false)
+		s.partial = nil
+	}
+	if s.progress != nil {
+		s.progress.Done()
+	}
+}
+
+// Tail returns the last lines written, oldest first.
+func (s *Stderr) Tail() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.tail...)
+}
+
+// line logs a line, which ended with a carriage return if cr is set.
+func (s *Stderr) line(l string, cr bool) {
+	if s.Raw != nil {
+		l = Redact(l)
+		fmt.Fprintln(s.Raw, l)
+		s.keep(l)
+		return
+	}
+	if strings.TrimSpace(l) == "" {
+		return
+	}
+	l = Redact(l)
+	if s.Progress && ProgressMode != ProgressOff && (cr || progressRe.MatchString(l)) {
+		if s.progress == nil {
+			s.progress = &Progress{Prefix: s.Prefix, Log: s.Log}
+		}
+		s.progress.Update("%s", l)
+		return
+	}
+	if s.progress != nil {
+		s.progress.Interrupt()
+	}
+	switch lower := strings.ToLower(l); {
+	case strings.Contains(lower, "warning") || strings.Contains(lower, "[warn]"):
+		s.Log.Warn("[%s] %s", s.Prefix, l)
+	case s.Verbose:
+		s.Log.Info("[%s] %s", s.Prefix, l)
+	default:
+		s.Log.Debug("[%s] %s", s.Prefix, l)
+	}
+	s.keep(l)
+}
+
+// keep keeps a line for Tail.
+func (s *Stderr) keep(l string) {
+	s.tail = append(s.tail, l)
+	if len(s.tail) > TailLines {
+		s.tail = s.tail[len(s.tail)-TailLines:]
+	}
+}
+
+// Exec runs an external command, sending its stderr through Stderr.
+//
+// prefix identifies the command in the log, e.g. "git update myrepo". The
+// command's stdout is returned, unless cmd.Stdout was already set. If the
+// command fails, the error is an *ExecError.
+func Exec(prefix string, cmd *exec.Cmd) ([]byte, error) {
+	return (&Stderr{Prefix: prefix}).Exec(cmd)
+}
+
+// ExecVerbose is like Exec, but logs all of the command's stderr with log.Info.
+func ExecVerbose(prefix string, cmd *exec.Cmd) ([]byte, error) {
+	return (&Stderr{Prefix: prefix, Verbose: true}).Exec(cmd)
+}
+
+// Exec is like the package-level Exec, but logs the command's stderr as s does.
+//
+// The command is killed if the whole command is stopped. See Context.
+func (s *Stderr) Exec(cmd *exec.Cmd) ([]byte, error) {
+	return s.ExecContext(Context(), cmd)
+}
+
+// ExecContext is like Exec, but the command is killed when ctx is done, in
+// which case the error wraps the error of ctx.
+func (s *Stderr) ExecContext(ctx context.Context, cmd *exec.Cmd) ([]byte, error) {
+	var out bytes.Buffer
+	if cmd.Stdout == nil {
+		cmd.Stdout = &out
+	}
+	cmd.Stderr = s
+
+	addRunning(1)
+	err := runContext(ctx, cmd)
+	addRunning(-1)
+	s.Flush()
+	if err != nil {
+		return out.Bytes(), &ExecError{Prefix: s.Prefix, Err: err, Tail: s.Tail()}
+	}
+	return out.Bytes(), nil
+}
+
+// killWait is how long runContext waits for the output of a killed command
+// to be closed. A command that reads the terminal is not in a group of its
+// own, and so its children may outlive it.
+const killWait = 2 * time.Second
+
+// runContext runs a command until it exits, or until ctx is done.
+//
+// The command runs in a process group of its own, so that killing it also
+// kills every process that it started, such as the helpers of git. A command
+// whose stdin is a terminal stays in the foreground, so that it can read it.
+func runContext(ctx context.Context, cmd *exec.Cmd) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	f, ok := cmd.Stdin.(*os.File)
+	group := !ok || !terminal.IsTerminal(int(f.Fd()))
+	if group {
+		setProcessGroup(cmd)
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		kill(cmd, group)
+		select {
+		case <-done:
+		case <-time.After(killWait):
+		}
+		return ctx.Err()
+	}
+}
+
+var (
+	userinfoRe = regexp.MustCompile(`([A-Za-z][A-Za-z0-9+.-]*://)([^/@\s:]*)(:[^/@\s]*)?@`)
+	authRe     = regexp.MustCompile(`(?i)(authorization:\s*)(\w+\s+)?\S+`)
+)
+
+// Redact hides the secrets in a log line.
+//
+// Passwords in URLs are replaced, as is the user of an HTTP URL without a
+// password, since that is often a token. The credentials in Authorization
+// headers, which git prints when GIT_CURL_VERBOSE is set, are replaced too.
+func Redact(s string) string {
+	s = userinfoRe.ReplaceAllStringFunc(s, func(m string) string {
+		p := userinfoRe.FindStringSubmatch(m)
+		scheme, user := p[1], p[2]
+		if p[3] != "" {
+			return scheme + user + ":REDACTED@"
+		}
+		if scheme = strings.ToLower(scheme); scheme == "http://" || scheme == "https://" {
+			return p[1] + "REDACTED@"
+		}
+		return m
+	})
+	return authRe.ReplaceAllString(s, "${1}${2}REDACTED")
+}
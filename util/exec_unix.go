@@ -0,0 +1,27 @@
+//go:build !windows
+// +build !windows
+
+package util
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup makes a command the leader of a new process group.
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+// kill kills a command that has started, and, if it leads a process group,
+// every process in the group.
+func kill(cmd *exec.Cmd, group bool) {
+	if group {
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		return
+	}
+	cmd.Process.Kill()
+}
@@ -0,0 +1,21 @@
+package util
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup starts a command in a new process group, so that it does
+// not get the Ctrl-C of the console, which helmc handles.
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.CreationFlags |= syscall.CREATE_NEW_PROCESS_GROUP
+}
+
+// kill kills a command that has started. Windows has no way to kill the
+// processes that it started with it.
+func kill(cmd *exec.Cmd, group bool) {
+	cmd.Process.Kill()
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/helm/helm-classic/helmpath"
 	"github.com/helm/helm-classic/log"
 )
 
@@ -13,37 +14,21 @@ import (
 const Configfile = "config.yaml"
 
 // DefaultConfigfile is the default Helm Classic configuration.
-const DefaultConfigfile = `repos:
-  default: charts
-  tables:
-    - name: charts
-      repo: https://github.com/helm/charts
-workspace:
-`
+const DefaultConfigfile = helmpath.DefaultConfig
 
 // EnsureHome ensures that a HELMC_HOME exists.
 func EnsureHome(home string) {
-
-	must := []string{home, CacheDirectory(home), filepath.Join(home, workspacePath)}
-
-	for _, p := range must {
-		if fi, err := os.Stat(p); err != nil {
+	h := helmpath.Home(home)
+	created, err := h.Ensure()
+	for _, p := range created {
+		if p == h.Config() {
+			log.Info("Creating %s", p)
+		} else {
 			log.Debug("Creating %s", p)
-			if err := os.MkdirAll(p, 0755); err != nil {
-				log.Die("Could not create %q: %s", p, err)
-			}
-		} else if !fi.IsDir() {
-			log.Die("%s must be a directory.", home)
 		}
 	}
-
-	refi := filepath.Join(home, Configfile)
-	if _, err := os.Stat(refi); err != nil {
-		log.Info("Creating %s", refi)
-		// Attempt to create a Repos.yaml
-		if err := ioutil.WriteFile(refi, []byte(DefaultConfigfile), 0755); err != nil {
-			log.Die("Could not create %s: %s", refi, err)
-		}
+	if err != nil {
+		log.Die("Could not create %q: %s", home, err)
 	}
 
 	if err := os.Chdir(home); err != nil {
@@ -109,7 +94,7 @@ func CopyDir(src, dst string) error {
 	return failure
 }
 
-//CopyFile copies file from src to dst
+// CopyFile copies file from src to dst
 func CopyFile(src string, dst string) error {
 	data, err := ioutil.ReadFile(src)
 	if err == nil {
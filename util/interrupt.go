@@ -0,0 +1,192 @@
+package util
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/helm/helm-classic/log"
+)
+
+// Exit statuses of a command that is stopped.
+const (
+	// ExitInterrupted is the status after SIGINT, as a shell reports it.
+	ExitInterrupted = 130
+	// ExitTerminated is the status after SIGTERM.
+	ExitTerminated = 143
+	// ExitTimeout is the status after the --timeout of the command, as
+	// timeout(1) reports it.
+	ExitTimeout = 124
+)
+
+// StopGrace is how long Stop waits for the external commands that it kills
+// to exit, before it cleans up.
+var StopGrace = 5 * time.Second
+
+var (
+	mu       sync.Mutex
+	ctx      context.Context
+	cancel   context.CancelFunc
+	deadline *time.Timer
+	cleanups = map[int]func(){}
+	nextID   int
+
+	// running is the number of external commands that Exec is waiting for.
+	running int
+
+	handleSignals sync.Once
+	stopping      sync.Once
+)
+
+func init() {
+	ctx, cancel = context.WithCancel(context.Background())
+}
+
+// Context returns the context of the whole command. It is done when the
+// command is stopped, which kills every external command that runs with it.
+func Context() context.Context {
+	mu.Lock()
+	defer mu.Unlock()
+	return ctx
+}
+
+// WithTimeout returns the context of one operation, which is done after d,
+// or when the whole command is stopped. If d is not positive, only the
+// latter bounds it.
+func WithTimeout(d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return context.WithCancel(Context())
+	}
+	return context.WithTimeout(Context(), d)
+}
+
+// SetTimeout bounds how long the whole command may run. When the time is
+// up, the command is stopped, and exits with ExitTimeout. Zero removes the
+// bound.
+func SetTimeout(d time.Duration) {
+	mu.Lock()
+	defer mu.Unlock()
+	if deadline != nil {
+		deadline.Stop()
+		deadline = nil
+	}
+	if d > 0 {
+		deadline = time.AfterFunc(d, func() {
+			Stop(ExitTimeout, "Timed out after %s", d)
+		})
+	}
+}
+
+// HandleInterrupts stops the command, as Stop does, when helmc gets SIGINT
+// or SIGTERM. A second signal exits at once, without cleaning up.
+func HandleInterrupts() {
+	handleSignals.Do(func() {
+		sigs := make(chan os.Signal, 2)
+		signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			code := ExitInterrupted
+			if <-sigs == syscall.SIGTERM {
+				code = ExitTerminated
+			}
+			go Stop(code, "Interrupted")
+			<-sigs
+			os.Exit(code)
+		}()
+	})
+}
+
+// Stop ends the command: it reports why, kills the external commands that
+// are running, waits up to StopGrace for them to exit, runs the cleanups
+// that OnInterrupt registered, newest first, and exits with code.
+//
+// From the moment Stop is called, every other attempt to exit blocks, so that
+// the command cannot exit before the cleanup is done. Only the first call to
+// Stop does anything; later calls block.
+func Stop(code int, format string, v ...interface{}) {
+	log.Halt()
+	first := false
+	stopping.Do(func() { first = true })
+	if !first {
+		select {}
+	}
+
+	log.Err(format, v...)
+	mu.Lock()
+	cancel()
+	mu.Unlock()
+
+	for start := time.Now(); runningCommands() > 0; time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > StopGrace {
+			log.Warn("Some commands did not exit after %s", StopGrace)
+			break
+		}
+	}
+
+	mu.Lock()
+	ids := make([]int, 0, len(cleanups))
+	for id := range cleanups {
+		ids = append(ids, id)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(ids)))
+	fns := make([]func(), len(ids))
+	for i, id := range ids {
+		fns[i] = cleanups[id]
+	}
+	mu.Unlock()
+	for _, fn := range fns {
+		fn()
+	}
+	log.RunAtExit(code)
+	os.Exit(code)
+}
+
+// addRunning counts the external commands that are running.
+func addRunning(n int) {
+	mu.Lock()
+	defer mu.Unlock()
+	running += n
+}
+
+func runningCommands() int {
+	mu.Lock()
+	defer mu.Unlock()
+	return running
+}
+
+// OnInterrupt registers fn to run if the command is stopped, before helmc
+// exits. It is for undoing work that is half done, such as a clone that is
+// not complete.
+//
+// The returned function unregisters fn. Call it once the work is done, or
+// has been undone.
+func OnInterrupt(fn func()) func() {
+	mu.Lock()
+	defer mu.Unlock()
+	id := nextID
+	nextID++
+	cleanups[id] = fn
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+		delete(cleanups, id)
+	}
+}
+
+// TempDir creates a temporary directory, as ioutil.TempDir does, which is
+// also removed if the command is stopped. The returned function removes it.
+func TempDir(dir, prefix string) (string, func(), error) {
+	tmp, err := ioutil.TempDir(dir, prefix)
+	if err != nil {
+		return "", nil, err
+	}
+	forget := OnInterrupt(func() { os.RemoveAll(tmp) })
+	return tmp, func() {
+		os.RemoveAll(tmp)
+		forget()
+	}, nil
+}
@@ -0,0 +1,153 @@
+//go:build !windows
+// +build !windows
+
+package util
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/helm/helm-classic/log"
+)
+
+func TestExecContextTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := (&Stderr{Prefix: "sleep"}).ExecContext(ctx, exec.Command("sh", "-c", "sleep 60"))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the deadline to be exceeded, got %v", err)
+	}
+	if err.Error() != "sleep timed out" {
+		t.Errorf("Unexpected message: %s", err)
+	}
+	if d := time.Since(start); d > 10*time.Second {
+		t.Errorf("Expected the command to be killed, but it took %s", d)
+	}
+}
+
+func TestOnInterrupt(t *testing.T) {
+	tmp, cleanup, err := TempDir("", "helmc-interrupt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	n := len(cleanups)
+	mu.Unlock()
+	if n == 0 {
+		t.Fatal("Expected TempDir to register a cleanup")
+	}
+	cleanup()
+	if _, err := os.Stat(tmp); !os.IsNotExist(err) {
+		t.Errorf("Expected %s to be removed", tmp)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(cleanups) != n-1 {
+		t.Errorf("Expected the cleanup to be unregistered")
+	}
+}
+
+// TestStop runs a slow command in a copy of the test binary, stops it with
+// Ctrl-C, and with a timeout, and checks that everything was cleaned up.
+func TestStop(t *testing.T) {
+	if os.Getenv("HELMC_TEST_STOP") != "" {
+		stopHelper()
+		return
+	}
+
+	for _, tt := range []struct {
+		mode string
+		code int
+	}{
+		{"interrupt", ExitInterrupted},
+		{"timeout", ExitTimeout},
+	} {
+		dir, err := ioutil.TempDir("", "helmc-stop")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		cmd := exec.Command(os.Args[0], "-test.run=^TestStop$")
+		cmd.Env = append(os.Environ(), "HELMC_TEST_STOP="+tt.mode, "HELMC_TEST_DIR="+dir)
+		if err := cmd.Start(); err != nil {
+			t.Fatal(err)
+		}
+		pid := waitForFile(t, filepath.Join(dir, "pid"))
+		if tt.mode == "interrupt" {
+			cmd.Process.Signal(os.Interrupt)
+		}
+		err = cmd.Wait()
+		if ee, ok := err.(*exec.ExitError); !ok || ee.ExitCode() != tt.code {
+			t.Errorf("%s: expected exit status %d, got %v", tt.mode, tt.code, err)
+		}
+
+		tmp, _ := ioutil.ReadFile(filepath.Join(dir, "tmp"))
+		if _, err := os.Stat(string(tmp)); len(tmp) == 0 || !os.IsNotExist(err) {
+			t.Errorf("%s: expected the temporary directory %q to be removed", tt.mode, tmp)
+		}
+		if !exited(pid) {
+			syscall.Kill(pid, syscall.SIGKILL)
+			t.Errorf("%s: expected the child of the command, %d, to be killed", tt.mode, pid)
+		}
+	}
+}
+
+// stopHelper is the helmc of TestStop. It creates a temporary directory, and
+// runs a command that starts a child, and waits for it.
+func stopHelper() {
+	dir := os.Getenv("HELMC_TEST_DIR")
+	log.Stderr = ioutil.Discard
+	HandleInterrupts()
+	if os.Getenv("HELMC_TEST_STOP") == "timeout" {
+		SetTimeout(500 * time.Millisecond)
+	}
+
+	tmp, cleanup, err := TempDir("", "helmc-stop-tmp")
+	if err != nil {
+		log.Die("%s", err)
+	}
+	defer cleanup()
+	ioutil.WriteFile(filepath.Join(dir, "tmp"), []byte(tmp), 0644)
+
+	script := "sleep 60 & echo $! > " + filepath.Join(dir, "pid.tmp") + "; mv " + filepath.Join(dir, "pid.tmp") + " " + filepath.Join(dir, "pid") + "; wait"
+	if _, err := Exec("slow", exec.Command("sh", "-c", script)); err != nil {
+		log.Die("%s", err)
+	}
+	log.Die("The slow command was not stopped")
+}
+
+// waitForFile returns the number in a file, once it exists.
+func waitForFile(t *testing.T, path string) int {
+	for start := time.Now(); time.Since(start) < 10*time.Second; time.Sleep(10 * time.Millisecond) {
+		if b, err := ioutil.ReadFile(path); err == nil {
+			pid, err := strconv.Atoi(strings.TrimSpace(string(b)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return pid
+		}
+	}
+	t.Fatalf("%s was not written", path)
+	return 0
+}
+
+// exited reports whether a process has gone, within a few seconds.
+func exited(pid int) bool {
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(10 * time.Millisecond) {
+		if err := syscall.Kill(pid, 0); err == syscall.ESRCH {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,143 @@
+package util
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/helm/helm-classic/log"
+)
+
+// Ways of showing the progress of long operations.
+const (
+	// ProgressOff shows progress only in debug output.
+	ProgressOff = iota
+	// ProgressLog logs the progress of an operation every ProgressInterval,
+	// for output that is not a terminal, such as a CI log. Operations that
+	// finish sooner log nothing.
+	ProgressLog
+	// ProgressTerminal shows every report on a single line of a terminal,
+	// which is updated in place.
+	ProgressTerminal
+)
+
+// ProgressMode is how long operations show their progress. helmc sets it
+// from --no-progress and whether its output is a terminal.
+var ProgressMode = ProgressOff
+
+// ProgressInterval is how often progress is logged in ProgressLog mode.
+var ProgressInterval = 10 * time.Second
+
+// Progress shows the progress of one operation, such as a clone or a
+// download, as ProgressMode says.
+//
+// Reports are logged to Log, or with the package-level log functions if it
+// is nil. Done must be called when the operation is over.
+type Progress struct {
+	Prefix string
+	Log    *log.Logger
+
+	mu    sync.Mutex
+	start time.Time
+	// logged is when a report was last logged, in ProgressLog mode.
+	logged time.Time
+	// last is the latest report.
+	last string
+	// shown is set if a report is on the terminal, or has been logged.
+	shown bool
+}
+
+// Update reports the latest progress.
+func (p *Progress) Update(format string, v ...interface{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	if p.start.IsZero() {
+		p.start = now
+	}
+	p.last = fmt.Sprintf(format, v...)
+
+	switch ProgressMode {
+	case ProgressTerminal:
+		p.Log.Progress("[%s] %s", p.Prefix, p.last)
+		p.shown = true
+	case ProgressLog:
+		since := p.logged
+		if since.IsZero() {
+			since = p.start
+		}
+		if now.Sub(since) >= ProgressInterval {
+			p.Log.Info("[%s] %s", p.Prefix, p.last)
+			p.logged, p.shown = now, true
+		}
+	}
+}
+
+// Interrupt ends the line on the terminal, so that another message can be
+// logged. The next report starts a new line.
+func (p *Progress) Interrupt() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if ProgressMode == ProgressTerminal && p.shown {
+		p.Log.Progress("[%s] %s\n", p.Prefix, p.last)
+		p.shown = false
+	}
+}
+
+// Done ends the report with the latest progress. It is only logged if
+// progress was shown, or otherwise as a debug message.
+func (p *Progress) Done() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch {
+	case p.last == "":
+	case ProgressMode == ProgressTerminal && p.shown:
+		p.Log.Progress("[%s] %s\n", p.Prefix, p.last)
+	case p.shown:
+		p.Log.Info("[%s] %s", p.Prefix, p.last)
+	default:
+		p.Log.Debug("[%s] %s", p.Prefix, p.last)
+	}
+	p.shown = false
+	p.last = ""
+}
+
+// ProgressReader reports the bytes read from a download.
+type ProgressReader struct {
+	// R is the body of the download.
+	R io.Reader
+	// Size is the length of the download, or -1 if it is not known.
+	Size int64
+	Progress
+
+	n int64
+}
+
+// Read reads from R, and reports the bytes read so far.
+func (r *ProgressReader) Read(b []byte) (int, error) {
+	n, err := r.R.Read(b)
+	r.n += int64(n)
+	if ProgressMode != ProgressOff || err == io.EOF {
+		if r.Size > 0 {
+			r.Update("%s of %s (%d%%)", ByteSize(r.n), ByteSize(r.Size), r.n*100/r.Size)
+		} else {
+			r.Update("%s", ByteSize(r.n))
+		}
+	}
+	return n, err
+}
+
+// ByteSize describes a number of bytes, e.g. "1.5 MiB".
+func ByteSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,88 @@
+package util
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/helm/helm-classic/log"
+)
+
+// withProgress sets ProgressMode and ProgressInterval for a test.
+func withProgress(mode int, interval time.Duration) func() {
+	m, i := ProgressMode, ProgressInterval
+	ProgressMode, ProgressInterval = mode, interval
+	return func() {
+		ProgressMode, ProgressInterval = m, i
+	}
+}
+
+func TestProgressTerminal(t *testing.T) {
+	defer withProgress(ProgressTerminal, time.Hour)()
+	var out bytes.Buffer
+	p := &Progress{Prefix: "GET x", Log: &log.Logger{Stderr: &out}}
+	p.Update("%d%%", 10)
+	p.Update("%d%%", 50)
+	p.Interrupt()
+	p.Update("%d%%", 100)
+	p.Done()
+
+	s := out.String()
+	if strings.Count(s, "\r\x1b[K") != 5 {
+		t.Errorf("Expected every report to replace the line, got %q", s)
+	}
+	if !strings.Contains(s, "[GET x] 50%\n") || !strings.HasSuffix(s, "[GET x] 100%\n") {
+		t.Errorf("Expected the interrupted and last reports to end lines, got %q", s)
+	}
+}
+
+func TestProgressLog(t *testing.T) {
+	defer withProgress(ProgressLog, time.Hour)()
+	var out bytes.Buffer
+	p := &Progress{Prefix: "clone", Log: &log.Logger{Stderr: &out}}
+	p.Update("1%%")
+	p.Update("99%%")
+	p.Done()
+	if out.Len() != 0 {
+		t.Errorf("Expected a quick operation to log nothing, got %q", out.String())
+	}
+
+	ProgressInterval = 0
+	p.Update("1%%")
+	p.Update("100%%")
+	p.Done()
+	s := out.String()
+	if strings.Contains(s, "\r") || strings.Count(s, "[clone] 100%\n") != 2 {
+		t.Errorf("Expected logged lines, got %q", s)
+	}
+}
+
+func TestProgressReader(t *testing.T) {
+	defer withProgress(ProgressLog, 0)()
+	var out bytes.Buffer
+	r := &ProgressReader{R: strings.NewReader(strings.Repeat("x", 2048)), Size: 4096}
+	r.Log = &log.Logger{Stderr: &out}
+	var sink bytes.Buffer
+	if _, err := sink.ReadFrom(r); err != nil {
+		t.Fatal(err)
+	}
+	r.Done()
+	if !strings.Contains(out.String(), "2.0 KiB of 4.0 KiB (50%)") {
+		t.Errorf("Expected the bytes read, got %q", out.String())
+	}
+}
+
+func TestByteSize(t *testing.T) {
+	tests := map[int64]string{
+		0:               "0 B",
+		1023:            "1023 B",
+		1536:            "1.5 KiB",
+		5 * 1024 * 1024: "5.0 MiB",
+	}
+	for n, expect := range tests {
+		if s := ByteSize(n); s != expect {
+			t.Errorf("ByteSize(%d) = %q, expected %q", n, s, expect)
+		}
+	}
+}
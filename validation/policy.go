@@ -0,0 +1,233 @@
+package validation
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/mail"
+	"regexp"
+	"strings"
+
+	"github.com/helm/helm-classic/chart"
+	"gopkg.in/yaml.v2"
+)
+
+// PolicyFile is the name of the file that holds Chart.yaml and resource lint
+// rules.
+//
+// It may be placed at the top level of a chart, or in the Helm Classic home
+// directory. A chart-level policy takes precedence over the home policy.
+const PolicyFile = "lint-policy.yaml"
+
+// Policy describes the content rules that lint applies to a Chart.yaml file,
+// and the resources that the containers of a chart may request.
+type Policy struct {
+	// Fields holds per-field rules.
+	Fields []*FieldRule `yaml:"fields"`
+	// Maintainers holds rules about the maintainers list.
+	Maintainers *MaintainerRule `yaml:"maintainers,omitempty"`
+	// Resources holds rules about the resources of containers. Containers
+	// are checked for requests, and for limits below them, even if it is nil.
+	Resources *ResourceRule `yaml:"resources,omitempty"`
+}
+
+// FieldRule describes the constraints on a single Chart.yaml field.
+type FieldRule struct {
+	// Name is the Chart.yaml key, e.g. "description" or "home".
+	Name string `yaml:"name"`
+	// Level is either "error" or "warning". The default is "error".
+	Level string `yaml:"level,omitempty"`
+	// Required indicates that the field must be present and non-empty.
+	Required bool `yaml:"required,omitempty"`
+	// Pattern is a regular expression the field value must match.
+	Pattern string `yaml:"pattern,omitempty"`
+	// MaxLength is the maximum number of characters allowed. Zero is unlimited.
+	MaxLength int `yaml:"maxLength,omitempty"`
+}
+
+// MaintainerRule describes constraints on the maintainers of a chart.
+type MaintainerRule struct {
+	// Level is either "error" or "warning". The default is "error".
+	Level string `yaml:"level,omitempty"`
+	// Min is the minimum number of maintainers.
+	Min int `yaml:"min,omitempty"`
+	// EmailDomains restricts maintainer email addresses to these domains.
+	//
+	// If set, at least one maintainer must have an address in one of them.
+	EmailDomains []string `yaml:"emailDomains,omitempty"`
+}
+
+// DefaultPolicy returns the policy used when no policy file is found.
+//
+// It is equivalent to the checks lint has always performed on Chart.yaml.
+func DefaultPolicy() *Policy {
+	return &Policy{
+		Fields: []*FieldRule{
+			{Name: "version", Required: true},
+			{Name: "description", Required: true, Level: "warning"},
+			{Name: "maintainers", Required: true, Level: "warning"},
+		},
+	}
+}
+
+// LoadPolicy reads a policy from a YAML file.
+func LoadPolicy(filename string) (*Policy, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePolicy(b)
+}
+
+// ParsePolicy parses YAML data into a *Policy, validating its rules.
+func ParsePolicy(data []byte) (*Policy, error) {
+	p := &Policy{}
+	if err := yaml.Unmarshal(data, p); err != nil {
+		return nil, err
+	}
+	for _, f := range p.Fields {
+		if _, ok := chartfileFields[f.Name]; !ok {
+			return nil, fmt.Errorf("unknown Chart.yaml field %q in policy", f.Name)
+		}
+		if err := checkLevel(f.Level); err != nil {
+			return nil, err
+		}
+		if f.Pattern != "" {
+			if _, err := regexp.Compile(f.Pattern); err != nil {
+				return nil, fmt.Errorf("bad pattern for field %s: %s", f.Name, err)
+			}
+		}
+	}
+	if p.Maintainers != nil {
+		if err := checkLevel(p.Maintainers.Level); err != nil {
+			return nil, err
+		}
+	}
+	if p.Resources != nil {
+		if err := p.Resources.check(); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+func checkLevel(level string) error {
+	switch level {
+	case "", "error", "warning":
+		return nil
+	}
+	return fmt.Errorf("unknown rule level %q (use 'error' or 'warning')", level)
+}
+
+// chartfileFields maps Chart.yaml keys to accessors for their values.
+var chartfileFields = map[string]func(*chart.Chartfile) []string{
+	"name":        func(c *chart.Chartfile) []string { return []string{c.Name} },
+	"home":        func(c *chart.Chartfile) []string { return []string{c.Home} },
+	"version":     func(c *chart.Chartfile) []string { return []string{c.Version} },
+	"description": func(c *chart.Chartfile) []string { return []string{c.Description} },
+	"details":     func(c *chart.Chartfile) []string { return []string{c.Details} },
+	"source":      func(c *chart.Chartfile) []string { return c.Source },
+	"maintainers": func(c *chart.Chartfile) []string { return c.Maintainers },
+}
+
+// Apply adds the policy's rules as children of the given validation.
+//
+// The cv.Chartfile must be populated by the time the rules run, so the parent
+// should be the validation that loads Chart.yaml.
+func (p *Policy) Apply(parent *Validation, cv *ChartValidation) {
+	for _, f := range p.Fields {
+		f.apply(parent, cv)
+	}
+	if p.Maintainers != nil {
+		p.Maintainers.apply(parent, cv)
+	}
+}
+
+func (f *FieldRule) apply(parent *Validation, cv *ChartValidation) {
+	values := func() []string {
+		vals := []string{}
+		for _, v := range chartfileFields[f.Name](cv.Chartfile) {
+			if v != "" {
+				vals = append(vals, v)
+			}
+		}
+		return vals
+	}
+
+	if f.Required {
+		add(parent, f.Level, fmt.Sprintf("Chart.yaml has a %s field", f.Name), func(path string, v *Validation) bool {
+			return len(values()) > 0
+		})
+	}
+
+	if f.MaxLength > 0 {
+		msg := fmt.Sprintf("Chart.yaml %s is at most %d characters (rule: maxLength)", f.Name, f.MaxLength)
+		add(parent, f.Level, msg, func(path string, v *Validation) bool {
+			for _, val := range values() {
+				if len([]rune(val)) > f.MaxLength {
+					return false
+				}
+			}
+			return true
+		})
+	}
+
+	if f.Pattern != "" {
+		re := regexp.MustCompile(f.Pattern)
+		msg := fmt.Sprintf("Chart.yaml %s matches %q (rule: pattern)", f.Name, f.Pattern)
+		add(parent, f.Level, msg, func(path string, v *Validation) bool {
+			for _, val := range values() {
+				if !re.MatchString(val) {
+					return false
+				}
+			}
+			return true
+		})
+	}
+}
+
+func (m *MaintainerRule) apply(parent *Validation, cv *ChartValidation) {
+	if m.Min > 0 {
+		msg := fmt.Sprintf("Chart.yaml has at least %d maintainers (rule: maintainers.min)", m.Min)
+		add(parent, m.Level, msg, func(path string, v *Validation) bool {
+			return len(cv.Chartfile.Maintainers) >= m.Min
+		})
+	}
+
+	if len(m.EmailDomains) > 0 {
+		msg := fmt.Sprintf("Chart.yaml has a maintainer with an email in %s (rule: maintainers.emailDomains)", strings.Join(m.EmailDomains, ", "))
+		add(parent, m.Level, msg, func(path string, v *Validation) bool {
+			for _, maint := range cv.Chartfile.Maintainers {
+				if m.allowed(maint) {
+					return true
+				}
+			}
+			return false
+		})
+	}
+}
+
+// allowed returns true if the maintainer's email is in one of the allowed domains.
+func (m *MaintainerRule) allowed(maintainer string) bool {
+	addr, err := mail.ParseAddress(maintainer)
+	if err != nil {
+		return false
+	}
+	at := strings.LastIndex(addr.Address, "@")
+	if at < 0 {
+		return false
+	}
+	domain := strings.ToLower(addr.Address[at+1:])
+	for _, d := range m.EmailDomains {
+		if domain == strings.ToLower(d) {
+			return true
+		}
+	}
+	return false
+}
+
+func add(parent *Validation, level, message string, fn Validator) *Validation {
+	if level == "warning" {
+		return parent.AddWarning(message, fn)
+	}
+	return parent.AddError(message, fn)
+}
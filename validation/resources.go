@@ -0,0 +1,206 @@
+package validation
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/helm/helm-classic/manifest"
+)
+
+// ResourceRule describes the resources that the containers of a chart's
+// manifests may request.
+type ResourceRule struct {
+	// Level is either "error" or "warning". Unlike other rules, the default
+	// is "warning".
+	Level string `yaml:"level,omitempty"`
+	// Max is the most of each resource, such as "cpu" or "memory", that a
+	// container may request or be limited to, as a Kubernetes quantity.
+	Max map[string]string `yaml:"max,omitempty"`
+}
+
+func (r *ResourceRule) check() error {
+	if err := checkLevel(r.Level); err != nil {
+		return err
+	}
+	for name, q := range r.Max {
+		if _, err := ParseQuantity(q); err != nil {
+			return fmt.Errorf("bad maximum for resource %s: %s", name, err)
+		}
+	}
+	return nil
+}
+
+// Warning returns true if the findings of the rule are warnings.
+func (r *ResourceRule) Warning() bool {
+	return r.Level == "" || r.Level == "warning"
+}
+
+// ResourceFinding is a problem with the resources of a container.
+type ResourceFinding struct {
+	// File is the manifest, relative to the chart.
+	File      string
+	Kind      string
+	Name      string
+	Container string
+	// Field is the offending field of the container, such as
+	// resources.limits.cpu.
+	Field   string
+	Problem string
+}
+
+func (f *ResourceFinding) Error() string {
+	return fmt.Sprintf("%s: %s %s, container %s: %s %s", f.File, f.Kind, f.Name, f.Container, f.Field, f.Problem)
+}
+
+// ResourceFindings are the findings of CheckResources, by the check that
+// found them.
+type ResourceFindings struct {
+	// NoRequests are the containers without resources.requests.
+	NoRequests []*ResourceFinding
+	// LimitBelowRequest are the limits that are lower than the request of
+	// the same resource.
+	LimitBelowRequest []*ResourceFinding
+	// OverMax are the requests and limits that are more than the rule allows,
+	// and those that are not quantities.
+	OverMax []*ResourceFinding
+}
+
+type container struct {
+	name             string
+	requests, limits map[string]interface{}
+}
+
+// CheckResources checks the resources of the containers, init containers
+// included, of the manifests that hold pods. File names in the findings are
+// relative to chartDir.
+func CheckResources(chartDir string, manifests []*manifest.Manifest, rule *ResourceRule) (*ResourceFindings, error) {
+	max := map[string]float64{}
+	for name, q := range rule.Max {
+		v, err := ParseQuantity(q)
+		if err != nil {
+			return nil, fmt.Errorf("bad maximum for resource %s: %s", name, err)
+		}
+		max[name] = v
+	}
+
+	res := &ResourceFindings{}
+	for _, m := range manifests {
+		if _, ok := manifest.PodSpecPaths[m.Kind]; !ok {
+			continue
+		}
+		var obj map[string]interface{}
+		if err := m.VersionedObject.Object(&obj); err != nil {
+			return nil, fmt.Errorf("%s: %s", m.Source, err)
+		}
+		file := m.Source
+		if rel, err := filepath.Rel(chartDir, m.Source); err == nil {
+			file = filepath.ToSlash(rel)
+		}
+		for _, c := range containers(manifest.PodSpec(m.Kind, obj)) {
+			finding := func(field, problem string) *ResourceFinding {
+				return &ResourceFinding{File: file, Kind: m.Kind, Name: m.Name, Container: c.name, Field: field, Problem: problem}
+			}
+			if len(c.requests) == 0 {
+				res.NoRequests = append(res.NoRequests, finding("resources.requests", "is not set"))
+			}
+			requests := quantities(c.requests, "resources.requests", &res.OverMax, finding)
+			limits := quantities(c.limits, "resources.limits", &res.OverMax, finding)
+			for _, l := range limits {
+				if req, ok := find(requests, l.name); ok && l.value < req.value {
+					res.LimitBelowRequest = append(res.LimitBelowRequest, finding(l.field, fmt.Sprintf("(%s) is lower than %s (%s)", l.raw, req.field, req.raw)))
+				}
+			}
+			for _, q := range append(requests, limits...) {
+				if most, ok := max[q.name]; ok && q.value > most {
+					res.OverMax = append(res.OverMax, finding(q.field, fmt.Sprintf("(%s) is more than the maximum of the lint policy, %s", q.raw, rule.Max[q.name])))
+				}
+			}
+		}
+	}
+	return res, nil
+}
+
+// containers returns the containers and init containers of a pod spec.
+func containers(spec map[string]interface{}) []*container {
+	res := []*container{}
+	for _, item := range manifest.Containers(spec) {
+		c := &container{}
+		c.name, _ = item["name"].(string)
+		if r, ok := item["resources"].(map[string]interface{}); ok {
+			c.requests, _ = r["requests"].(map[string]interface{})
+			c.limits, _ = r["limits"].(map[string]interface{})
+		}
+		res = append(res, c)
+	}
+	return res
+}
+
+// quantity is a value of the requests or limits of a container.
+type quantity struct {
+	// field is the field of the value, such as resources.limits.cpu.
+	field string
+	name  string
+	raw   string
+	value float64
+}
+
+// quantities parses the values of a requests or limits map, in the order of
+// their names. A value that is not a quantity is added to bad instead.
+func quantities(vals map[string]interface{}, field string, bad *[]*ResourceFinding, finding func(field, problem string) *ResourceFinding) []quantity {
+	names := make([]string, 0, len(vals))
+	for name := range vals {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	res := []quantity{}
+	for _, name := range names {
+		q := quantity{field: field + "." + name, name: name, raw: fmt.Sprint(vals[name])}
+		v, err := ParseQuantity(q.raw)
+		if err != nil {
+			*bad = append(*bad, finding(q.field, fmt.Sprintf("(%s) is not a quantity", q.raw)))
+			continue
+		}
+		q.value = v
+		res = append(res, q)
+	}
+	return res
+}
+
+func find(qs []quantity, name string) (quantity, bool) {
+	for _, q := range qs {
+		if q.name == name {
+			return q, true
+		}
+	}
+	return quantity{}, false
+}
+
+// quantitySuffixes are the multipliers of the suffixes of Kubernetes
+// quantities.
+var quantitySuffixes = map[string]float64{
+	"n": 1e-9, "u": 1e-6, "m": 1e-3, "": 1,
+	"k": 1e3, "M": 1e6, "G": 1e9, "T": 1e12, "P": 1e15, "E": 1e18,
+	"Ki": 1 << 10, "Mi": 1 << 20, "Gi": 1 << 30, "Ti": 1 << 40, "Pi": 1 << 50, "Ei": 1 << 60,
+}
+
+// ParseQuantity returns the value of a Kubernetes quantity, such as "500m",
+// "2", "1.5Gi", or "1e9".
+func ParseQuantity(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	num := strings.TrimRightFunc(s, unicode.IsLetter)
+	mult, ok := quantitySuffixes[s[len(num):]]
+	if !ok {
+		return 0, fmt.Errorf("%q has an unknown suffix %q", s, s[len(num):])
+	}
+	v, err := strconv.ParseFloat(num, 64)
+	if err != nil || v < 0 || math.IsInf(v, 0) || math.IsNaN(v) {
+		return 0, fmt.Errorf("%q is not a quantity", s)
+	}
+	return v * mult, nil
+}
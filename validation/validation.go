@@ -20,6 +20,13 @@ type ChartValidation struct {
 	Manifests    []*manifest.Manifest
 	ErrorCount   int
 	WarningCount int
+	// Errors and Warnings are the messages of the validations that failed,
+	// once Valid has run.
+	Errors   []string
+	Warnings []string
+	// Log receives the outcome of each validation. If it is nil, the
+	// package-level log functions are used.
+	Log *log.Logger
 }
 
 const (
@@ -154,7 +161,7 @@ func (cv *ChartValidation) walk(talker func(v *Validation) bool) {
 func (cv *ChartValidation) Valid() bool {
 	var valid = true
 
-	fmt.Printf("\nVerifying %s chart is a valid chart...\n", cv.ChartName())
+	fmt.Fprintf(cv.Log.Out(), "\nVerifying %s chart is a valid chart...\n", cv.ChartName())
 	cv.walk(func(v *Validation) bool {
 		v.path = cv.Path
 		vv := v.valid()
@@ -162,16 +169,18 @@ func (cv *ChartValidation) Valid() bool {
 			switch v.level {
 			case 2:
 				cv.ErrorCount = cv.ErrorCount + 1
+				cv.Errors = append(cv.Errors, v.Message)
 				msg := v.Message + " : " + strconv.FormatBool(vv)
-				log.Err(msg)
+				cv.Log.Err(msg)
 			case 1:
 				cv.WarningCount = cv.WarningCount + 1
+				cv.Warnings = append(cv.Warnings, v.Message)
 				msg := v.Message + " : " + strconv.FormatBool(vv)
-				log.Warn(msg)
+				cv.Log.Warn(msg)
 			}
 		} else {
 			msg := v.Message + " : " + strconv.FormatBool(vv)
-			log.Info(msg)
+			cv.Log.Info(msg)
 		}
 
 		valid = valid && vv
@@ -0,0 +1,60 @@
+// Package version describes the build of Helm Classic.
+//
+// The values are set by the linker when Helm Classic is built with make:
+//
+//	go build -ldflags "-X github.com/helm/helm-classic/version.Version=0.9.0 \
+//		-X github.com/helm/helm-classic/version.GitCommit=$(git rev-parse HEAD) \
+//		-X github.com/helm/helm-classic/version.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+package version
+
+import (
+	"fmt"
+	"runtime"
+)
+
+var (
+	// Version is the SemVer version of Helm Classic.
+	//
+	// The default here indicates that this was a one-off build and should
+	// not be trusted.
+	Version = "0.1.0"
+	// GitCommit is the Git SHA that Helm Classic was built from.
+	GitCommit = ""
+	// BuildDate is the time that Helm Classic was built, in RFC 3339 format.
+	BuildDate = ""
+)
+
+// Info describes a build of Helm Classic.
+type Info struct {
+	Version   string `json:"version"`
+	GitCommit string `json:"gitCommit"`
+	BuildDate string `json:"buildDate"`
+	GoVersion string `json:"goVersion"`
+	Platform  string `json:"platform"`
+}
+
+// Get returns the description of this build.
+func Get() Info {
+	return Info{
+		Version:   Version,
+		GitCommit: GitCommit,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+}
+
+// String describes the build on one line, e.g.
+// "0.9.0 (commit 3eed1e3, built 2016-05-04T10:00:00Z, go1.6.2 linux/amd64)".
+func (i Info) String() string {
+	commit, built := i.GitCommit, i.BuildDate
+	if commit == "" {
+		commit = "unknown"
+	} else if len(commit) > 7 {
+		commit = commit[:7]
+	}
+	if built == "" {
+		built = "unknown"
+	}
+	return fmt.Sprintf("%s (commit %s, built %s, %s %s)", i.Version, commit, built, i.GoVersion, i.Platform)
+}
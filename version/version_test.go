@@ -0,0 +1,26 @@
+package version
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestGet(t *testing.T) {
+	defer func(v, c, d string) { Version, GitCommit, BuildDate = v, c, d }(Version, GitCommit, BuildDate)
+	Version, GitCommit, BuildDate = "0.9.0", "3eed1e3a9e85a4e8f3b6b2f8c6e6f2d3e1a0b9c8", "2016-05-04T10:00:00Z"
+
+	i := Get()
+	if i.Version != "0.9.0" || i.GoVersion != runtime.Version() {
+		t.Errorf("Unexpected info %+v", i)
+	}
+	expect := "0.9.0 (commit 3eed1e3, built 2016-05-04T10:00:00Z, " + runtime.Version()
+	if !strings.HasPrefix(i.String(), expect) {
+		t.Errorf("Expected %q to start with %q", i.String(), expect)
+	}
+
+	GitCommit, BuildDate = "", ""
+	if s := Get().String(); !strings.Contains(s, "commit unknown, built unknown") {
+		t.Errorf("Expected an unknown commit and date, got %q", s)
+	}
+}